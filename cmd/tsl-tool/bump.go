@@ -0,0 +1,98 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirosfoundation/g119612/pkg/dsig"
+	"github.com/sirosfoundation/g119612/pkg/etsi119612"
+	"github.com/sirosfoundation/g119612/pkg/logging"
+	"github.com/sirosfoundation/g119612/pkg/pipeline"
+)
+
+// bump implements "tsl-tool bump --in tsl.xml --out new.xml --next-update 90d
+// --sign cert.pem key.pem". It loads a TSL, increments its sequence number,
+// sets its issue date to now and its next update --next-update later, signs
+// it again and writes it to --out once the signed list validates. It returns
+// the process exit code.
+func bump(args []string, logger logging.Logger) int {
+	fs := flag.NewFlagSet("bump", flag.ContinueOnError)
+	in := fs.String("in", "", "TSL file to reissue")
+	out := fs.String("out", "", "File to write the reissued TSL to")
+	nextUpdate := fs.String("next-update", "", "Time from now to the next update, e.g. 90d or 2160h")
+	sign := fs.String("sign", "", "PEM certificate file, followed by the PEM key file as an argument")
+
+	// --sign takes two values, so the key file is left as a positional
+	// argument; accept the other flags before and after it
+	var rest []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return 1
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		rest = append(rest, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if *in == "" || *out == "" || *nextUpdate == "" || *sign == "" || len(rest) != 1 {
+		fmt.Fprintln(os.Stderr, "Error: bump requires --in, --out, --next-update and --sign <cert.pem> <key.pem>")
+		return 1
+	}
+	keyFile := rest[0]
+	validity, err := parseValidity(*nextUpdate)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid --next-update '%s'\n", *nextUpdate)
+		return 1
+	}
+
+	data, err := os.ReadFile(*in)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	tsl, err := etsi119612.ParseTSL(data, *in, etsi119612.DefaultTSLFetchOptions)
+	if err != nil {
+		logger.Error("Failed to load TSL", logging.F("file", *in), logging.F("error", err))
+		return 1
+	}
+
+	signed, err := pipeline.ReissueTSL(tsl, dsig.NewFileSigner(*sign, keyFile), validity, clock.Now(), etsi119612.DefaultTSLFetchOptions)
+	if err != nil {
+		logger.Error("Failed to reissue TSL", logging.F("file", *in), logging.F("error", err))
+		return 1
+	}
+	if readOnly {
+		logger.Info("Read-only mode: not writing reissued TSL", logging.F("would_write", *out))
+		return 0
+	}
+	if err := os.WriteFile(*out, signed, 0644); err != nil {
+		logger.Error("Failed to write TSL", logging.F("file", *out), logging.F("error", err))
+		return 1
+	}
+
+	info := tsl.StatusList.TslSchemeInformation
+	logger.Info("Reissued TSL",
+		logging.F("file", *out),
+		logging.F("sequence_number", info.TSLSequenceNumber),
+		logging.F("issued", info.ListIssueDateTime),
+		logging.F("next_update", info.TslNextUpdate.DateTime))
+	return 0
+}
+
+// parseValidity parses a duration as time.ParseDuration does, also accepting
+// a whole number of days such as "90d".
+func parseValidity(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(value)
+}
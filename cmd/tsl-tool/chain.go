@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/sirosfoundation/g119612/pkg/logging"
+	"github.com/sirosfoundation/g119612/pkg/pipeline"
+)
+
+// chain implements "tsl-tool chain --cert leaf.pem <pipeline.yaml>". It runs
+// the pipeline, verifies the first certificate of the PEM file against the
+// pool built by its select step, or the named pool given by --pool, using the
+// other certificates of the file as intermediates, and prints every chain
+// found with the TSL, provider and service listing its anchor. With --evidence
+// it writes an evidence bundle of the first chain (see
+// pipeline.EvidenceBundle). It returns the process exit code: 0 if at least
+// one chain was found, 1 otherwise.
+func chain(args []string, logger logging.Logger) int {
+	fs := flag.NewFlagSet("chain", flag.ContinueOnError)
+	certFile := fs.String("cert", "", "PEM file with the leaf certificate, optionally followed by intermediates")
+	poolName := fs.String("pool", "", "Verify against the pool selected with name:NAME instead of the last one")
+	evidenceFile := fs.String("evidence", "", "Write an evidence bundle of the first chain to this ZIP file")
+
+	// Accept flags before and after the pipeline argument
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return 1
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if len(positional) != 1 {
+		fmt.Fprintln(os.Stderr, "Error: chain expects exactly one pipeline YAML file argument")
+		return 1
+	}
+	if *certFile == "" {
+		fmt.Fprintln(os.Stderr, "Error: chain requires --cert")
+		return 1
+	}
+	certs, err := readCertificates(*certFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	pl, err := loadPipeline(positional[0])
+	if err != nil {
+		logger.Error("Failed to load pipeline",
+			logging.F("file", positional[0]),
+			logging.F("error", err))
+		return 1
+	}
+	ctx, err := pl.WithLogger(logger).Process(pipeline.NewContext())
+	if err != nil {
+		logger.Error("Pipeline processing failed", logging.F("error", err))
+		return 1
+	}
+	if *poolName != "" {
+		if ctx, err = pipeline.UsePool(ctx, *poolName); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+	}
+
+	chains, err := ctx.Verify(certs[0], certs[1:]...)
+	if err != nil {
+		fmt.Fprintf(os.Stdout, "Verification of %s failed: %v\n", certs[0].Subject, err)
+		return 1
+	}
+	writeChains(os.Stdout, ctx, chains)
+	if *evidenceFile != "" {
+		if err := writeEvidence(*evidenceFile, ctx, *poolName, chains[0], logger); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+	}
+	return 0
+}
+
+// writeEvidence writes the evidence bundle of chain, verified against the
+// pool of ctx, to file, unless --read-only is set.
+func writeEvidence(file string, ctx *pipeline.Context, pool string, chain []*x509.Certificate, logger logging.Logger) error {
+	if readOnly {
+		logger.Info("Read-only mode: not writing evidence bundle", logging.F("would_write", file))
+		return nil
+	}
+	bundle, err := pipeline.NewEvidenceBundle(ctx, chain)
+	if err != nil {
+		return err
+	}
+	bundle.Pool = pool
+	var buf bytes.Buffer
+	if err := bundle.WriteZip(&buf); err != nil {
+		return err
+	}
+	if err := os.WriteFile(file, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write evidence bundle: %w", err)
+	}
+	logger.Info("Wrote evidence bundle", logging.F("file", file))
+	return nil
+}
+
+// readCertificates returns the certificates of a PEM file in order.
+func readCertificates(file string) ([]*x509.Certificate, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read --cert: %w", err)
+	}
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid certificate in %s: %w", file, err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no certificates found in %s", file)
+	}
+	return certs, nil
+}
+
+// writeChains prints verified chains, leaf first, with the listings of their anchors.
+func writeChains(w io.Writer, ctx *pipeline.Context, chains [][]*x509.Certificate) {
+	local := make(map[string]pipeline.TrustAnchor)
+	for _, anchor := range pipeline.LocalTrustAnchors(ctx) {
+		local[anchor.SHA256] = anchor
+	}
+
+	for i, certs := range chains {
+		fmt.Fprintf(w, "Chain %d:\n", i+1)
+		for j, cert := range certs {
+			digest := sha256.Sum256(cert.Raw)
+			fingerprint := hex.EncodeToString(digest[:])
+			fmt.Fprintf(w, "  [%d] subject: %s\n", j, cert.Subject)
+			fmt.Fprintf(w, "      issuer: %s\n", cert.Issuer)
+			fmt.Fprintf(w, "      sha256: %s\n", fingerprint)
+			if j != len(certs)-1 {
+				continue
+			}
+			if anchor, ok := local[fingerprint]; ok {
+				fmt.Fprintf(w, "      anchor: %s (%s)\n", anchor.Provenance, anchor.Source)
+			}
+			for _, listing := range ctx.Listings(cert) {
+				fmt.Fprintf(w, "      anchor: %s / %s / %s (%s)\n", listing.TSL, listing.Provider, listing.Service, listing.Status)
+			}
+		}
+	}
+}
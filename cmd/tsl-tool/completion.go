@@ -0,0 +1,367 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// commandFlag describes a flag of tsl-tool or of one of its commands for
+// shell completion and the manual page.
+type commandFlag struct {
+	Name       string   // Name without dashes
+	Value      string   // Placeholder of the value, "" for boolean flags
+	Usage      string   // One-line description
+	Values     []string // Possible values, if there is a fixed set
+	Repeatable bool
+}
+
+// complete returns how the value of the flag is completed: "file", "dir" or
+// "" for no completion.
+func (f commandFlag) complete() string {
+	switch f.Value {
+	case "FILE", "PATH":
+		return "file"
+	case "DIR":
+		return "dir"
+	}
+	return ""
+}
+
+// command describes a tsl-tool command for shell completion and the manual
+// page.
+type command struct {
+	Name      string
+	Args      string // Synopsis of the positional arguments
+	Summary   string
+	Flags     []commandFlag
+	ArgValues []string // Possible positional arguments, if there is a fixed set
+	Complete  string   // Completion of the positional arguments: "file", "dir" or ""
+}
+
+// commands are the commands of tsl-tool besides running a pipeline. The
+// flags must be kept in line with the flag sets of the commands.
+var commands = []command{
+	{
+		Name: "run-all", Args: "<directory>", Complete: "dir",
+		Summary: "Run all *.yaml/*.yml pipelines in a directory, each with an isolated context; fails if any pipeline fails",
+		Flags: []commandFlag{
+			{Name: "concurrency", Value: "N", Usage: "Number of pipelines processed at once (default: 1)"},
+		},
+	},
+	{
+		Name: "explain", Args: "<pipeline.yaml>", Complete: "file",
+		Summary: "Print the effective fetch options, filters and select policies per step without running the pipeline",
+		Flags: []commandFlag{
+			{Name: "format", Value: "FORMAT", Usage: "Output format: text or json (default: text)", Values: []string{"text", "json"}},
+		},
+	},
+	{
+		Name: "validate-pipeline", Args: "<pipeline.yaml>...", Complete: "file",
+		Summary: "Check the step arguments and the step order without running the pipelines",
+	},
+	{
+		Name: "serve", Args: "<pipeline.yaml>", Complete: "file",
+		Summary: "Run the pipeline and serve a read-only web UI of the loaded TSLs under /ui/ and their tree as JSON at /tree",
+		Flags: []commandFlag{
+			{Name: "listen", Value: "ADDR", Usage: "Address to listen on (default: :8080)"},
+			{Name: "interval", Value: "DURATION", Usage: "Rerun the pipeline at this interval, e.g. 1h (default: off)"},
+			{Name: "webhook-token-file", Value: "FILE", Usage: "File holding a bearer token; enables POST /hooks/refresh"},
+			{Name: "debounce", Value: "DURATION", Usage: "Coalesce refresh requests until they pause this long"},
+			{Name: "min-interval", Value: "DURATION", Usage: "Minimum time between the starts of two runs"},
+			{Name: "mirror", Value: "DIR", Usage: "Serve the TSL mirror in this directory under /mirror/"},
+		},
+	},
+	{
+		Name: "chain", Args: "<pipeline.yaml>", Complete: "file",
+		Summary: "Run the pipeline and print the chains a certificate builds against the selected pool",
+		Flags: []commandFlag{
+			{Name: "cert", Value: "FILE", Usage: "PEM file with the leaf, optionally followed by intermediates"},
+			{Name: "pool", Value: "NAME", Usage: "Use the pool selected with name:NAME instead of the last one"},
+			{Name: "evidence", Value: "FILE", Usage: "Write an evidence bundle of the first chain to this ZIP file"},
+		},
+	},
+	{
+		Name: "monitor", Args: "<pipeline.yaml>", Complete: "file",
+		Summary: "Rerun the pipeline periodically and alert when a certificate stops verifying",
+		Flags: []commandFlag{
+			{Name: "certs", Value: "PATH", Usage: "PEM file, or directory of PEM files, each with a leaf optionally followed by intermediates"},
+			{Name: "interval", Value: "DURATION", Usage: "Rerun and re-verify at this interval (default: 1h)"},
+			{Name: "alert-webhook", Value: "URL", Usage: "URL to POST the alerts to as JSON"},
+		},
+	},
+	{
+		Name: "pool-log", Args: "<log>", Complete: "file",
+		Summary: "Verify a pool log and summarize it, or show when a certificate was trusted",
+		Flags: []commandFlag{
+			{Name: "sha256", Value: "FINGERPRINT", Usage: "Hex SHA-256 fingerprint of the certificate"},
+			{Name: "cert", Value: "FILE", Usage: "PEM file with the certificate"},
+			{Name: "format", Value: "FORMAT", Usage: "Output format: text or json (default: text)", Values: []string{"text", "json"}},
+		},
+	},
+	{
+		Name:    "bump",
+		Summary: "Reissue a TSL unchanged with the next sequence number and new dates, signed again",
+		Flags: []commandFlag{
+			{Name: "in", Value: "FILE", Usage: "TSL file to reissue"},
+			{Name: "out", Value: "FILE", Usage: "File to write the reissued TSL to"},
+			{Name: "next-update", Value: "DURATION", Usage: "Time from now to the next update, e.g. 90d or 2160h"},
+			{Name: "sign", Value: "FILE", Usage: "Certificate PEM file, followed by the key PEM file as an argument"},
+		},
+		Complete: "file",
+	},
+	{
+		Name:    "example-server",
+		Summary: "Serve the sample TSLs of the example pipelines",
+		Flags: []commandFlag{
+			{Name: "listen", Value: "ADDR", Usage: "Address to listen on (default: localhost:8090)"},
+			{Name: "extract", Value: "DIR", Usage: "Write the example pipelines to this directory and exit"},
+		},
+	},
+	{
+		Name: "completion", Args: "<bash|zsh>", ArgValues: []string{"bash", "zsh"},
+		Summary: "Print the shell completion script for bash or zsh",
+	},
+	{
+		Name:    "man",
+		Summary: "Write the manual pages tsl-tool.1 and tsl-tool-pipeline.5",
+		Flags: []commandFlag{
+			{Name: "dir", Value: "DIR", Usage: "Directory to write the pages to (default: .)"},
+		},
+	},
+}
+
+// globalFlagValues are the value placeholders and possible values of the
+// global flags that are not files.
+var globalFlagValues = map[string]commandFlag{
+	"log-level":          {Value: "LEVEL", Values: []string{"debug", "info", "warn", "error"}},
+	"log-format":         {Value: "FORMAT", Values: []string{"text", "json"}},
+	"output":             {Value: "FILE"},
+	"output-mode":        {Value: "MODE"},
+	"pipeline-signer":    {Value: "FILE"},
+	"pipeline-signature": {Value: "FILE"},
+	"values":             {Value: "FILE"},
+	"warnings-baseline":  {Value: "FILE"},
+	"now":                {Value: "TIME"},
+}
+
+// globalFlags returns the flags defined on flag.CommandLine, sorted by name.
+func globalFlags() []commandFlag {
+	var flags []commandFlag
+	flag.CommandLine.VisitAll(func(f *flag.Flag) {
+		cf := globalFlagValues[f.Name]
+		cf.Name = f.Name
+		cf.Usage = f.Usage
+		cf.Repeatable = strings.Contains(f.Usage, "repeatable")
+		if b, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() {
+			cf.Value = ""
+		} else if cf.Value == "" {
+			cf.Value = "VALUE"
+		}
+		flags = append(flags, cf)
+	})
+	sort.Slice(flags, func(i, j int) bool { return flags[i].Name < flags[j].Name })
+	return flags
+}
+
+// completion implements "tsl-tool completion <bash|zsh>", printing the
+// completion script of the shell. It returns the process exit code.
+func completion(args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "Error: completion expects the shell, bash or zsh")
+		return 1
+	}
+	switch args[0] {
+	case "bash":
+		writeBashCompletion(os.Stdout, globalFlags())
+	case "zsh":
+		writeZshCompletion(os.Stdout, globalFlags())
+	default:
+		fmt.Fprintf(os.Stderr, "Error: unsupported shell '%s', expected bash or zsh\n", args[0])
+		return 1
+	}
+	return 0
+}
+
+// bashValueCases returns the case branches completing the values of the flags
+// of the command name, skipping flags without a value.
+func bashValueCases(name string, flags []commandFlag) []string {
+	var cases []string
+	for _, f := range flags {
+		if f.Value == "" {
+			continue
+		}
+		reply := "COMPREPLY=()"
+		switch {
+		case len(f.Values) > 0:
+			reply = fmt.Sprintf(`COMPREPLY=($(compgen -W "%s" -- "$cur"))`, strings.Join(f.Values, " "))
+		case f.complete() == "file":
+			reply = `COMPREPLY=($(compgen -f -- "$cur"))`
+		case f.complete() == "dir":
+			reply = `COMPREPLY=($(compgen -d -- "$cur"))`
+		}
+		cases = append(cases, fmt.Sprintf("        %s:--%s|%s:-%s) %s; return ;;", name, f.Name, name, f.Name, reply))
+	}
+	return cases
+}
+
+// flagNames returns the flags as "--name" words.
+func flagNames(flags []commandFlag) string {
+	names := make([]string, 0, len(flags))
+	for _, f := range flags {
+		names = append(names, "--"+f.Name)
+	}
+	return strings.Join(names, " ")
+}
+
+// writeBashCompletion writes the bash completion script of tsl-tool with the
+// global flags.
+func writeBashCompletion(w io.Writer, global []commandFlag) {
+	names := make([]string, 0, len(commands))
+	for _, cmd := range commands {
+		names = append(names, cmd.Name)
+	}
+
+	fmt.Fprintf(w, `# bash completion for tsl-tool, generated by "tsl-tool completion bash"
+_tsl_tool() {
+    local cur prev cmd flags i
+    cur="${COMP_WORDS[COMP_CWORD]}"
+    prev="${COMP_WORDS[COMP_CWORD-1]}"
+    cmd=""
+    for ((i = 1; i < COMP_CWORD; i++)); do
+        case "${COMP_WORDS[i]}" in
+            %s) cmd="${COMP_WORDS[i]}"; break ;;
+        esac
+    done
+
+    case "$cmd:$prev" in
+`, strings.Join(names, "|"))
+	for _, c := range bashValueCases("", global) {
+		fmt.Fprintln(w, c)
+	}
+	for _, cmd := range commands {
+		for _, c := range bashValueCases(cmd.Name, cmd.Flags) {
+			fmt.Fprintln(w, c)
+		}
+	}
+	fmt.Fprintf(w, `    esac
+
+    case "$cmd" in
+        "") flags="%s" ;;
+`, flagNames(global))
+	for _, cmd := range commands {
+		if len(cmd.Flags) > 0 {
+			fmt.Fprintf(w, "        %s) flags=\"%s\" ;;\n", cmd.Name, flagNames(cmd.Flags))
+		}
+	}
+	fmt.Fprintf(w, `    esac
+    if [[ "$cur" == -* ]]; then
+        COMPREPLY=($(compgen -W "$flags" -- "$cur"))
+        return
+    fi
+
+    case "$cmd" in
+        "") COMPREPLY=($(compgen -W "%s" -- "$cur") $(compgen -f -- "$cur")) ;;
+`, strings.Join(names, " "))
+	for _, cmd := range commands {
+		switch {
+		case len(cmd.ArgValues) > 0:
+			fmt.Fprintf(w, "        %s) COMPREPLY=($(compgen -W \"%s\" -- \"$cur\")) ;;\n", cmd.Name, strings.Join(cmd.ArgValues, " "))
+		case cmd.Complete == "dir":
+			fmt.Fprintf(w, "        %s) COMPREPLY=($(compgen -d -- \"$cur\")) ;;\n", cmd.Name)
+		case cmd.Complete == "file":
+			fmt.Fprintf(w, "        %s) COMPREPLY=($(compgen -f -- \"$cur\")) ;;\n", cmd.Name)
+		default:
+			fmt.Fprintf(w, "        %s) COMPREPLY=() ;;\n", cmd.Name)
+		}
+	}
+	fmt.Fprint(w, `    esac
+}
+complete -o filenames -F _tsl_tool tsl-tool
+`)
+}
+
+// zshQuote escapes text for a single-quoted _arguments specification.
+func zshQuote(text string) string {
+	return strings.NewReplacer(`'`, `'\''`, `[`, `\[`, `]`, `\]`, `:`, `\:`).Replace(text)
+}
+
+// zshFlagSpecs returns the _arguments specifications of flags.
+func zshFlagSpecs(flags []commandFlag) []string {
+	specs := make([]string, 0, len(flags))
+	for _, f := range flags {
+		spec := "--" + f.Name
+		if f.Repeatable {
+			spec = "*" + spec
+		}
+		if f.Value == "" {
+			specs = append(specs, fmt.Sprintf("'%s[%s]'", spec, zshQuote(f.Usage)))
+			continue
+		}
+		action := " "
+		switch {
+		case len(f.Values) > 0:
+			action = "(" + strings.Join(f.Values, " ") + ")"
+		case f.complete() == "file":
+			action = "_files"
+		case f.complete() == "dir":
+			action = "_files -/"
+		}
+		specs = append(specs, fmt.Sprintf("'%s=[%s]:%s:%s'", spec, zshQuote(f.Usage), strings.ToLower(f.Value), action))
+	}
+	return specs
+}
+
+// writeZshCompletion writes the zsh completion script of tsl-tool with the
+// global flags.
+func writeZshCompletion(w io.Writer, global []commandFlag) {
+	fmt.Fprint(w, `#compdef tsl-tool
+# zsh completion for tsl-tool, generated by "tsl-tool completion zsh"
+
+_tsl_tool() {
+    local curcontext="$curcontext" state line
+    local -a commands
+    commands=(
+`)
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "        '%s:%s'\n", cmd.Name, strings.ReplaceAll(cmd.Summary, "'", `'\''`))
+	}
+	fmt.Fprint(w, "    )\n\n    _arguments -C \\\n")
+	for _, spec := range zshFlagSpecs(global) {
+		fmt.Fprintf(w, "        %s \\\n", spec)
+	}
+	fmt.Fprint(w, `        '1: :->command' \
+        '*:: :->args'
+
+    case $state in
+        command)
+            _describe -t commands 'tsl-tool command' commands
+            _files
+            ;;
+        args)
+            case $line[1] in
+`)
+	for _, cmd := range commands {
+		specs := zshFlagSpecs(cmd.Flags)
+		switch {
+		case len(cmd.ArgValues) > 0:
+			specs = append(specs, fmt.Sprintf("'1:argument:(%s)'", strings.Join(cmd.ArgValues, " ")))
+		case cmd.Complete == "dir":
+			specs = append(specs, "'*:directory:_files -/'")
+		case cmd.Complete == "file":
+			specs = append(specs, "'*:file:_files'")
+		}
+		fmt.Fprintf(w, "                %s) _arguments %s ;;\n", cmd.Name, strings.Join(specs, " "))
+	}
+	fmt.Fprint(w, `                *) _files ;;
+            esac
+            ;;
+    esac
+}
+
+_tsl_tool "$@"
+`)
+}
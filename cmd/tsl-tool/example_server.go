@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/sirosfoundation/g119612/examples"
+	"github.com/sirosfoundation/g119612/pkg/logging"
+)
+
+// exampleServer implements "tsl-tool example-server [--listen addr]
+// [--extract dir]". It serves the sample TSLs of the example pipelines until
+// interrupted, so the examples run without network access. With --extract it
+// writes the example pipelines, their values file and the sample leaf
+// certificate to dir instead, for installations without the source tree. It
+// returns the process exit code.
+func exampleServer(args []string, logger logging.Logger) int {
+	fs := flag.NewFlagSet("example-server", flag.ContinueOnError)
+	listen := fs.String("listen", examples.DefaultAddr, "Address to listen on")
+	extract := fs.String("extract", "", "Write the example pipelines to this directory and exit")
+	if err := fs.Parse(args); err != nil {
+		return 1
+	}
+	if fs.NArg() != 0 {
+		fmt.Fprintln(os.Stderr, "Error: example-server takes no arguments")
+		return 1
+	}
+	if *extract != "" {
+		if err := extractExamples(*extract); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		fmt.Fprintf(os.Stdout, "Wrote the example pipelines to %s\n", *extract)
+		return 0
+	}
+
+	httpServer := &http.Server{
+		Addr:              *listen,
+		Handler:           examples.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	done := make(chan error, 1)
+	go func() { done <- httpServer.ListenAndServe() }()
+	logger.Info("Serving sample TSLs",
+		logging.F("listen", *listen),
+		logging.F("tsls", examples.SampleTSLs()))
+
+	select {
+	case err := <-done:
+		logger.Error("Server failed", logging.F("error", err))
+		return 1
+	case <-stop:
+	}
+
+	shutdown, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := httpServer.Shutdown(shutdown); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Error("Server shutdown failed", logging.F("error", err))
+		return 1
+	}
+	logger.Info("Server stopped")
+	return 0
+}
+
+// extractExamples writes the example pipelines, values.yaml and leaf.pem to dir.
+func extractExamples(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for _, name := range append(examples.Pipelines(), "values.yaml", "leaf.pem") {
+		data, err := examples.File(name)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirosfoundation/g119612/pkg/logging"
+	"github.com/sirosfoundation/g119612/pkg/pipeline"
+)
+
+// explain implements "tsl-tool explain <pipeline.yaml> [--format text|json]".
+// It resolves the effective settings of every step with pipeline.Explain,
+// without fetching or publishing anything, prints them to stdout and returns
+// the process exit code.
+func explain(args []string, logger logging.Logger) int {
+	fs := flag.NewFlagSet("explain", flag.ContinueOnError)
+	format := fs.String("format", "text", "Output format: text or json")
+
+	// Accept flags before and after the pipeline argument
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return 1
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if len(positional) != 1 {
+		fmt.Fprintln(os.Stderr, "Error: explain expects exactly one pipeline YAML file argument")
+		return 1
+	}
+	if *format != "text" && *format != "json" {
+		fmt.Fprintf(os.Stderr, "Error: invalid --format '%s'\n", *format)
+		return 1
+	}
+
+	pl, err := loadPipeline(positional[0])
+	if err != nil {
+		logger.Error("Failed to load pipeline",
+			logging.F("file", positional[0]),
+			logging.F("error", err))
+		return 1
+	}
+	steps, err := pipeline.Explain(pl.WithLogger(logger))
+
+	if *format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if encodeErr := encoder.Encode(steps); encodeErr != nil {
+			logger.Error("Failed to write explanation", logging.F("error", encodeErr))
+			return 1
+		}
+	} else {
+		writeExplanation(os.Stdout, steps)
+	}
+
+	if err != nil {
+		logger.Error("Invalid pipeline configuration", logging.F("error", err))
+		return 1
+	}
+	return 0
+}
+
+// writeExplanation prints step explanations in a human readable form.
+func writeExplanation(w io.Writer, steps []pipeline.StepExplanation) {
+	for _, step := range steps {
+		status := "not evaluated"
+		switch {
+		case step.Evaluated:
+			status = "evaluated"
+		case step.Load != nil || step.Select != nil:
+			status = "parsed"
+		}
+		fmt.Fprintf(w, "step %d: %s (%s)\n", step.Index, step.Name, status)
+		if len(step.Args) > 0 {
+			fmt.Fprintf(w, "  args: %s\n", strings.Join(step.Args, " "))
+		}
+		if step.LogLevel != "" {
+			fmt.Fprintf(w, "  log-level: %s\n", step.LogLevel)
+		}
+		if load := step.Load; load != nil {
+			fmt.Fprintf(w, "  url: %s\n", load.URL)
+			if load.WellKnownPath != "" {
+				fmt.Fprintf(w, "  wellknown-path: %s\n", load.WellKnownPath)
+			}
+			if len(load.Mirrors) > 0 {
+				mode := load.MirrorMode
+				if mode == "" {
+					mode = pipeline.MirrorModeOrder
+				}
+				fmt.Fprintf(w, "  mirrors: %s\n", strings.Join(load.Mirrors, " "))
+				fmt.Fprintf(w, "  mirror-mode: %s\n", mode)
+				fmt.Fprintf(w, "  mirror-check: %t\n", load.CheckMirrors)
+			}
+		}
+		if fetch := step.Fetch; fetch != nil {
+			fmt.Fprintf(w, "  user-agent: %s\n", fetch.UserAgent)
+			fmt.Fprintf(w, "  timeout: %s\n", fetch.Timeout)
+			fmt.Fprintf(w, "  max-depth: %d\n", fetch.MaxDereferenceDepth)
+			if len(fetch.AcceptHeaders) > 0 {
+				fmt.Fprintf(w, "  accept: %s\n", strings.Join(fetch.AcceptHeaders, ", "))
+			}
+			fmt.Fprintf(w, "  prefer-xml: %t\n", fetch.PreferXML)
+			fmt.Fprintf(w, "  strict: %t\n", fetch.Strict)
+			fmt.Fprintf(w, "  strict-pointers: %t\n", fetch.StrictPointers)
+			fmt.Fprintf(w, "  allow-doctype: %t\n", fetch.AllowDoctype)
+			fmt.Fprintf(w, "  fetch-cache: %t\n", fetch.Cache)
+			fmt.Fprintf(w, "  transport: %+v\n", fetch.Transport)
+			if fetch.Retries > 0 {
+				fmt.Fprintf(w, "  retries: %d (delay %s)\n", fetch.Retries, fetch.RetryDelay)
+			}
+			if fetch.Mirror != "" {
+				fmt.Fprintf(w, "  mirror: %s\n", fetch.Mirror)
+			}
+			fmt.Fprintf(w, "  ipfs-gateway: %s\n", fetch.IPFSGateway)
+			policy := fetch.AlgorithmPolicy
+			if len(policy.SignatureAlgorithms) > 0 {
+				fmt.Fprintf(w, "  signature-algorithms: %s\n", strings.Join(policy.SignatureAlgorithms, ","))
+			}
+			if len(policy.DigestAlgorithms) > 0 {
+				fmt.Fprintf(w, "  digest-algorithms: %s\n", strings.Join(policy.DigestAlgorithms, ","))
+			}
+			fmt.Fprintf(w, "  allow-sha1: %t\n", policy.AllowSHA1)
+			fmt.Fprintf(w, "  min-rsa-bits: %d\n", policy.MinRSAKeySize)
+			for _, url := range fetch.AllowSHA1URLs {
+				fmt.Fprintf(w, "  allow-sha1-url: %s\n", url)
+			}
+			if fetch.RawSpillDir != "" {
+				fmt.Fprintf(w, "  raw-spill-dir: %s (above %d bytes)\n", fetch.RawSpillDir, fetch.RawSpillThreshold)
+			}
+			if fetch.SignerRoots > 0 {
+				fmt.Fprintf(w, "  signer-roots: %d certificates\n", fetch.SignerRoots)
+			}
+			for _, host := range fetch.IssuerHosts {
+				fmt.Fprintf(w, "  aia-allow-host: %s\n", host)
+			}
+			for _, override := range fetch.HostOverrides {
+				fmt.Fprintf(w, "  host: %s\n", override)
+			}
+			kinds := make([]string, 0, len(fetch.Filters))
+			for kind := range fetch.Filters {
+				kinds = append(kinds, kind)
+			}
+			sort.Strings(kinds)
+			for _, kind := range kinds {
+				fmt.Fprintf(w, "  filter-%s: %s\n", kind, strings.Join(fetch.Filters[kind], ","))
+			}
+		}
+		if sel := step.Select; sel != nil {
+			fmt.Fprintf(w, "  reference-depth: %d\n", sel.ReferenceDepth)
+			for _, serviceType := range sel.ServiceTypes {
+				fmt.Fprintf(w, "  service-type: %s\n", serviceType)
+			}
+			for _, status := range sel.Statuses {
+				fmt.Fprintf(w, "  status: %s\n", status)
+			}
+			logic := "or"
+			if sel.MatchAllStatuses {
+				logic = "and"
+			}
+			fmt.Fprintf(w, "  status-logic: %s\n", logic)
+			fmt.Fprintf(w, "  require-ca: %t\n", sel.RequireCA)
+			for _, usage := range sel.RequireEKU {
+				fmt.Fprintf(w, "  require-eku: %s\n", usage)
+			}
+			if !sel.At.IsZero() {
+				fmt.Fprintf(w, "  at: %s\n", sel.At.Format(time.RFC3339))
+			}
+			if sel.ExclusionReport != "" {
+				fmt.Fprintf(w, "  exclusion-report: %s\n", sel.ExclusionReport)
+			}
+			if sel.StatusConflict != "" {
+				fmt.Fprintf(w, "  status-conflict: %s\n", sel.StatusConflict)
+			}
+			for _, path := range sel.ExtraRoots {
+				fmt.Fprintf(w, "  extra-roots: %s\n", path)
+			}
+			if sel.CacheDir != "" {
+				fmt.Fprintf(w, "  cache-dir: %s\n", sel.CacheDir)
+			}
+			if sel.PoolLog != "" {
+				fmt.Fprintf(w, "  pool-log: %s\n", sel.PoolLog)
+			}
+		}
+	}
+}
@@ -40,6 +40,85 @@
 // # Usage
 //
 //	tsl-tool [options] <pipeline.yaml>
+//	tsl-tool [options] run-all <directory> [--concurrency N]
+//	tsl-tool [options] explain <pipeline.yaml> [--format text|json]
+//	tsl-tool [options] validate-pipeline <pipeline.yaml>...
+//	tsl-tool [options] serve <pipeline.yaml> [--listen addr] [--interval d] [--webhook-token-file path]
+//	                         [--debounce d] [--min-interval d] [--mirror dir]
+//	tsl-tool [options] chain --cert leaf.pem <pipeline.yaml>
+//	tsl-tool [options] monitor --certs path <pipeline.yaml> [--interval d] [--alert-webhook url]
+//	tsl-tool [options] pool-log <log> [--sha256 fingerprint | --cert leaf.pem] [--format text|json]
+//	tsl-tool [options] bump --in tsl.xml --out new.xml --next-update 90d --sign cert.pem key.pem
+//	tsl-tool [options] example-server [--listen addr] [--extract dir]
+//	tsl-tool completion bash|zsh
+//	tsl-tool man [--dir dir]
+//
+// The run-all command processes every *.yaml and *.yml pipeline in a directory,
+// running up to N pipelines at once (default 1). Each pipeline gets its own
+// context; the exit code is 1 if any of them fails. --output does not apply.
+//
+// The explain command prints the effective fetch options, filters and select
+// policies of every step without fetching or publishing anything. Only
+// set-fetch-options steps are evaluated; the other steps are parsed.
+//
+// The validate-pipeline command loads each pipeline without running it and
+// prints the invalid step arguments and the misplaced steps, such as a select
+// step before any load step or a set-fetch-options step after the last load
+// step (see pipeline.Pipeline.Check). The exit code is 1 if any pipeline has
+// an error; warnings are only printed.
+//
+// The serve command runs the pipeline and serves a read-only web UI for
+// browsing the loaded TSLs, their providers, services and certificates under
+// /ui/ on the --listen address (default :8080), and the tree of loaded TSLs as
+// JSON at /tree and /tsl/{id} for dashboards. The pipeline is rerun every
+// --interval and, if --webhook-token-file is given, whenever an upstream
+// operator POSTs to /hooks/refresh with "Authorization: Bearer <token>".
+// Refresh requests arriving in a burst are coalesced into one run once they
+// pause for --debounce, runs start at least --min-interval apart, and at most
+// one run is queued while another is in progress. With --mirror the TSL mirror
+// written by the mirror step to a directory is served under /mirror/.
+//
+// The chain command runs the pipeline and prints every chain the first
+// certificate of the --cert PEM file builds against the selected pool, or the
+// pool of the select step with name:NAME given by --pool, using the other
+// certificates of the file as intermediates. The anchor of each chain
+// is shown with the TSL, provider and service listing it. The exit code is 1 if
+// verification fails. With --evidence, an evidence bundle of the first chain
+// is written to a ZIP file: the chain, the signed TSLs listing its anchor, the
+// policy of the pool and the time of the verification, to keep a record of
+// why the certificate was trusted.
+//
+// The monitor command reruns the pipeline every --interval (default 1h) and
+// re-verifies the certificates of --certs, a PEM file or a directory of them
+// with the leaf first in each file, against the selected pool. A certificate
+// that verified at the previous run but no longer does, for example because
+// its trust anchor was removed from a TSL, is logged as an error and, with
+// --alert-webhook, POSTed to the URL as JSON.
+//
+// The pool-log command verifies the hash chain of a log written by the
+// pool-log option of select and prints a summary, or with --sha256 or --cert
+// when the certificate was first and last trusted. The exit code is 1 if the
+// log is corrupt.
+//
+// The bump command reissues an existing signed TSL without running a
+// pipeline: it increments the sequence number, sets the issue date to now and
+// the next update --next-update later (a duration such as 2160h, or days such
+// as 90d), signs the list with the certificate and key given to --sign and
+// writes it to --out once the signed list verifies. It is meant for keeping an
+// unchanged list from expiring.
+//
+// The example-server command serves the sample TSLs that the example pipelines
+// of the examples directory load, on --listen (default localhost:8090), so the
+// examples run locally without network access. With --extract it writes the
+// example pipelines, their values file and a sample leaf certificate to a
+// directory instead. Run an example with
+// "tsl-tool --values examples/values.yaml examples/select-and-publish.yaml".
+//
+// The completion command prints a bash or zsh completion script covering the
+// commands, their flags and the values of enumerated flags. The man command
+// writes the manual pages tsl-tool.1 and tsl-tool-pipeline.5 to --dir
+// (default the current directory); the pipeline page lists the registered
+// steps with their arguments and options (see pipeline.StepInfo).
 //
 // Options:
 //
@@ -47,7 +126,75 @@
 //	--version        Show version information
 //	--log-level      Logging level: debug, info, warn, error (default: info)
 //	--log-format     Logging format: text or json (default: text)
-//	--output         Write certificate pool PEM to file (optional)
+//	--output         Write certificate pool PEM or PKCS#12 to file (optional, repeatable)
+//	--output-mode    Octal file mode for the --output files (default: 0644)
+//	--output-metadata Also write the pool metadata as JSON to <output>.json
+//	--pipeline-signer PEM file with certificates or public keys trusted to sign pipelines
+//	--pipeline-signature Detached signature of the pipeline (default: <pipeline>.sig)
+//	--production     Refuse to run pipelines that are not signed by a --pipeline-signer
+//	--read-only      Log what publish, render, generate_index and the other writing
+//	                 steps would write instead of writing or signing anything
+//	--values         YAML file with values the pipelines are expanded with (repeatable)
+//	--warnings-baseline YAML file with the fingerprints of acknowledged warnings
+//	--update-warnings-baseline Add the warnings of the run to --warnings-baseline
+//	--warnings-as-errors Fail the run if the pipeline logs unacknowledged warnings
+//	--now            Run as if it was this RFC 3339 time (default: the current time)
+//
+// With --pipeline-signer every pipeline file, including those of run-all, must
+// carry a valid detached signature over its exact bytes, an RSA or ECDSA
+// signature of the SHA-256 digest or an Ed25519 signature, raw or base64
+// encoded. It can be created with
+//
+//	openssl dgst -sha256 -sign ops-key.pem -out pipeline.yaml.sig pipeline.yaml
+//
+// Unsigned or tampered pipelines are refused, since the pipeline decides what
+// is trusted and published. --production makes --pipeline-signer mandatory.
+//
+// With --values every pipeline file is a Go text/template expanded with the
+// values of the given YAML files as .Values before it is parsed, so one
+// pipeline definition can serve many tenants, each with its own values file
+// for output directories, territory filters and signer configuration (see
+// pipeline.Values). Later files override earlier ones. With
+// --pipeline-signer the values files must be signed like the pipelines, in
+// <values>.sig.
+//
+// With --read-only the pipelines are loaded and selected as usual, but the
+// steps that write files or sign, and the --output files, are only logged with
+// the paths they would write (see pipeline.Pipeline.ReadOnly). It is meant
+// for trying out pipelines against production directories.
+//
+// --warnings-as-errors fails a pipeline run that logs warnings, for example in
+// CI. Warnings that are known and cannot be fixed locally, such as a bad
+// certificate in an upstream TSL, are acknowledged in the baseline file given
+// by --warnings-baseline (see pipeline.WarningBaseline): they are logged at
+// debug level and do not fail the run, while new warnings still do. Warnings
+// are logged with the fingerprint that identifies them in the baseline, and
+// --update-warnings-baseline adds all warnings of a run to the file, creating
+// it if needed, to record the current state before enabling
+// --warnings-as-errors. The baseline applies to every pipeline the tool runs.
+//
+// --now makes runs reproducible: certificates are verified at the given time
+// instead of the current one, and it is the time recorded in pool logs,
+// reports, manifests, generated pages and --output files, and the issue time
+// of the TSLs reissued by bump (see pipeline.Clock). Fetching is not affected,
+// so the TSLs are those published now unless they come from a mirror.
+//
+// Each --output may carry a service policy after a colon, for example
+// "qc.pem:type=CA/QC" or "tsa.pem:type=TSA,status=granted". Outputs with a
+// policy only contain certificates of matching services; see outputTargets.
+// Each PEM file starts with comment lines giving the generation time, the
+// policy, the source TSLs with their sequence numbers and the earliest
+// NextUpdate of those TSLs, so consumers can detect stale bundles.
+//
+// Outputs ending in .p12 or .pfx, or with "format=pkcs12", are written as
+// password-protected PKCS#12 trust stores for Java and other enterprise
+// middleware, for example
+// "truststore.p12:type=CA/QC,password-file=/etc/tsl/p12.pass". Each
+// certificate is stored under a friendly name derived from its TSP and
+// service names, set with "friendly-name={tsp} - {service}". With
+// "split=territory" or "split=type" an output is written as one file per
+// scheme territory or service type, named by the {territory} or {type}
+// placeholder in the path or with the value inserted before the extension.
 //
 // # Exit Codes
 //
@@ -58,14 +205,13 @@
 package main
 
 import (
-	"crypto/x509"
-	"encoding/pem"
 	"flag"
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
-	"github.com/sirosfoundation/g119612/pkg/etsi119612"
 	"github.com/sirosfoundation/g119612/pkg/logging"
 	"github.com/sirosfoundation/g119612/pkg/pipeline"
 )
@@ -73,24 +219,24 @@ import (
 // Version is set at build time using -ldflags
 var Version = "dev"
 
+// readOnly is set by --read-only; loadPipeline applies it to every pipeline.
+var readOnly bool
+
+// warningBaseline is loaded from --warnings-baseline; loadPipeline applies it
+// to every pipeline.
+var warningBaseline *pipeline.WarningBaseline
+
+// clock is the time source set by --now; loadPipeline applies it to every
+// pipeline, and it dates the --output files and the TSLs reissued by bump.
+var clock = pipeline.SystemClock
+
 // parseLogLevel converts a string log level to the corresponding LogLevel enum value.
 func parseLogLevel(level string) logging.LogLevel {
-	level = strings.ToLower(level)
-	switch level {
-	case "debug":
-		return logging.DebugLevel
-	case "info":
-		return logging.InfoLevel
-	case "warn", "warning":
-		return logging.WarnLevel
-	case "error":
-		return logging.ErrorLevel
-	case "fatal":
-		return logging.FatalLevel
-	default:
-		fmt.Fprintf(os.Stderr, "Warning: unknown log level '%s', using 'info'\n", level)
-		return logging.InfoLevel
+	parsed, err := logging.ParseLevel(level)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: unknown log level '%s', using 'info'\n", strings.ToLower(level))
 	}
+	return parsed
 }
 
 // usage prints the command-line usage information.
@@ -100,6 +246,17 @@ func usage() {
 tsl-tool: ETSI Trust Status List (TSL) Pipeline Processor
 
 Usage: %s [options] <pipeline.yaml>
+       %s [options] run-all <directory> [--concurrency N]
+       %s [options] explain <pipeline.yaml> [--format text|json]
+       %s [options] validate-pipeline <pipeline.yaml>...
+       %s [options] serve <pipeline.yaml> [--listen addr] [--interval d]
+       %s [options] chain --cert leaf.pem <pipeline.yaml>
+       %s [options] monitor --certs path <pipeline.yaml> [--interval d]
+       %s [options] pool-log <log> [--sha256 fingerprint | --cert leaf.pem]
+       %s [options] bump --in tsl.xml --out new.xml --next-update 90d --sign cert.pem key.pem
+       %s [options] example-server [--listen addr] [--extract dir]
+       %s completion bash|zsh
+       %s man [--dir dir]
 
 A batch processing tool for ETSI TS 119612 Trust Status Lists.
 Designed to run as a cron job for periodic TSL processing.
@@ -109,22 +266,127 @@ Options:
   --version        Show version information and exit
   --log-level      Logging level: debug, info, warn, error (default: info)
   --log-format     Logging format: text or json (default: text)
-  --output         Write extracted certificate pool PEM to file (optional)
+  --output         Write extracted certificate pool PEM to file (optional, repeatable)
+                   Use file.pem:type=CA/QC[,status=granted] to filter by service
+                   Use file.p12:password-file=f[,split=territory|type]
+                   [,friendly-name={tsp} - {service}] for PKCS#12 trust stores
+  --output-mode    Octal file mode for the --output files (default: 0644)
+  --output-metadata
+                   Also write generation time, policy, source TSLs and the
+                   earliest NextUpdate of each --output as JSON to <output>.json
+  --pipeline-signer
+                   PEM file with the certificates or public keys trusted to
+                   sign pipelines; unsigned or tampered pipelines are refused
+  --pipeline-signature
+                   Detached signature of the pipeline (default: <pipeline>.sig)
+  --production     Refuse to run unless --pipeline-signer is given
+  --read-only      Log what publish, render, generate_index and --output
+                   would write or sign instead of doing it
+  --values         YAML file with values referenced as {{ .Values.name }} from
+                   the pipelines (repeatable, later files override earlier ones)
+  --warnings-baseline
+                   YAML file with the fingerprints of acknowledged warnings,
+                   which are logged at debug level instead
+  --update-warnings-baseline
+                   Add the warnings of the run to --warnings-baseline
+  --warnings-as-errors
+                   Fail the run if the pipeline logs unacknowledged warnings
+  --now            Run as if it was this RFC 3339 time, e.g. to rebuild the
+                   pool of a past day (default: the current time)
+
+Commands:
+  run-all <dir>    Run all *.yaml/*.yml pipelines in a directory, each with
+                   an isolated context; fails if any pipeline fails
+    --concurrency  Number of pipelines processed at once (default: 1)
+  explain <file>   Print the effective fetch options, filters and select
+                   policies per step without running the pipeline
+    --format       Output format: text or json (default: text)
+  validate-pipeline <file>...
+                   Check the step arguments and the step order, e.g. select
+                   before any load, without running the pipelines
+  serve <file>     Run the pipeline and serve a read-only web UI of the
+                   loaded TSLs under /ui/ and their tree as JSON at /tree
+    --listen       Address to listen on (default: :8080)
+    --interval     Rerun the pipeline at this interval, e.g. 1h (default: off)
+    --webhook-token-file
+                   File holding a bearer token; enables POST /hooks/refresh
+                   to trigger an immediate rerun
+    --debounce     Coalesce refresh requests until they pause this long
+    --min-interval Minimum time between the starts of two runs
+    --mirror       Serve the TSL mirror in this directory under /mirror/
+  chain <file>     Run the pipeline and print the chains a certificate builds
+                   against the selected pool, with the TSL listing each anchor
+    --cert         PEM file with the leaf, optionally followed by intermediates
+    --pool         Use the pool selected with name:NAME instead of the last one
+    --evidence     Write an evidence bundle of the first chain to this ZIP file
+  monitor <file>   Rerun the pipeline periodically and alert when a certificate
+                   that verified against the pool stops verifying
+    --certs        PEM file, or directory of PEM files, each with a leaf
+                   optionally followed by intermediates
+    --interval     Rerun and re-verify at this interval (default: 1h)
+    --alert-webhook
+                   URL to POST the alerts to as JSON
+  pool-log <log>   Verify a pool log written by select's pool-log option and
+                   summarize it, or show when a certificate was trusted
+    --sha256       Hex SHA-256 fingerprint of the certificate
+    --cert         PEM file with the certificate
+    --format       Output format: text or json (default: text)
+  bump             Reissue a TSL unchanged with the next sequence number and
+                   new issue and next update dates, signed again
+    --in           TSL file to reissue
+    --out          File to write the reissued TSL to
+    --next-update  Time from now to the next update, e.g. 90d or 2160h
+    --sign         Certificate and key PEM files to sign with
+  example-server   Serve the sample TSLs loaded by the example pipelines
+    --listen       Address to listen on (default: localhost:8090)
+    --extract      Write the example pipelines to this directory and exit
+  completion <shell>
+                   Print the completion script for bash or zsh
+  man              Write the manual pages tsl-tool.1 and tsl-tool-pipeline.5
+    --dir          Directory to write the pages to (default: .)
 
 Pipeline Steps:
   load             Load TSL from URL or file path
   select           Build certificate pool from TSLs
   transform        Apply XSLT transformation
+  render           Render TSLs with a Go html/template
   publish          Write TSLs to files
   generate         Generate new TSL from metadata
   generate_index   Generate HTML index of TSL files
+  change-report    Write an HTML page of the services changed since the last run
+  check-links      Fail on dead links in generated pages or distribution points
   log              Output messages to log
   set-fetch-options Configure HTTP fetch options
+  set-language     Set the languages of names and page labels (en, sv, de, fr)
+  export-notification Package TSL and notification metadata as ZIP
+  export-oidfed    Export TSPs as OpenID Federation statements/trust marks
+  compare-remote   Refuse to overwrite a newer published TSL
+  mirror           Save fetched TSLs as they were fetched, with a manifest
+  publish-oci      Push the certificate pool and TSLs to an OCI registry
+  verify           Verify certificates against the pool, failing if one does not
   echo             No-op placeholder step
+  if               Run then/else steps by a condition, e.g. "cert-count > 0"
 
 Example:
   %s --log-level debug pipeline.yaml
   %s --output certs.pem pipeline.yaml
+  %s --output qc.pem:type=CA/QC --output tsa.pem:type=TSA pipeline.yaml
+  %s --output 'trust-{territory}.p12:split=territory,password-env=P12_PASSWORD' pipeline.yaml
+  %s run-all ./pipelines/ --concurrency 4
+  %s explain pipeline.yaml
+  %s validate-pipeline ./pipelines/*.yaml
+  %s serve pipeline.yaml --listen localhost:8080 --interval 1h
+  %s chain --cert server.pem pipeline.yaml
+  %s monitor --certs ./watched/ --interval 1h --alert-webhook https://alerts.example.com/tsl pipeline.yaml
+  %s pool-log /var/lib/tsl/pool.log --cert server-ca.pem
+  %s --read-only --log-level debug pipeline.yaml
+  %s --production --pipeline-signer ops.pem --pipeline-signature pipeline.yaml.sig pipeline.yaml
+  %s --values tenants/customer-a.yaml pipeline.yaml
+  %s --warnings-baseline known-warnings.yaml --warnings-as-errors pipeline.yaml
+  %s bump --in tsl.xml --out new.xml --next-update 90d --sign cert.pem key.pem
+  %s example-server --extract ./examples
+  source <(%s completion bash)
+  %s man --dir ./man
 
 Example pipeline.yaml:
   - set-fetch-options:
@@ -144,7 +406,7 @@ Example pipeline.yaml:
 
 See: https://github.com/sirosfoundation/g119612
 
-`, prog, prog, prog)
+`, prog, prog, prog, prog, prog, prog, prog, prog, prog, prog, prog, prog, prog, prog, prog, prog, prog, prog, prog, prog, prog, prog, prog, prog, prog, prog, prog, prog, prog, prog, prog)
 }
 
 func main() {
@@ -152,7 +414,20 @@ func main() {
 	showVersion := flag.Bool("version", false, "Show version information")
 	logLevel := flag.String("log-level", "info", "Logging level: debug, info, warn, error")
 	logFormat := flag.String("log-format", "text", "Logging format: text or json")
-	outputFile := flag.String("output", "", "Write certificate pool PEM to file")
+	var outputs outputTargets
+	flag.Var(&outputs, "output", "Write certificate pool PEM to file, optionally filtered (repeatable)")
+	outputMode := flag.String("output-mode", "0644", "Octal file mode for the --output files")
+	outputMetadata := flag.Bool("output-metadata", false, "Also write the metadata of each --output as JSON to <output>.json")
+	pipelineSigner := flag.String("pipeline-signer", "", "PEM file with the certificates or public keys trusted to sign pipelines")
+	pipelineSignature := flag.String("pipeline-signature", "", "Detached signature of the pipeline file (default: <pipeline>.sig)")
+	production := flag.Bool("production", false, "Refuse to run pipelines without a valid signature by a --pipeline-signer")
+	flag.BoolVar(&readOnly, "read-only", false, "Log what would be written or signed instead of writing or signing")
+	var valuesFiles stringList
+	flag.Var(&valuesFiles, "values", "YAML file with the values the pipelines are expanded with (repeatable)")
+	baselineFile := flag.String("warnings-baseline", "", "YAML file with the fingerprints of acknowledged warnings")
+	updateBaseline := flag.Bool("update-warnings-baseline", false, "Add the warnings of the run to --warnings-baseline")
+	warningsAsErrors := flag.Bool("warnings-as-errors", false, "Fail the run if the pipeline logs unacknowledged warnings")
+	now := flag.String("now", "", "Run as if it was this RFC 3339 time, e.g. 2026-01-01T00:00:00Z")
 
 	flag.Usage = usage
 	flag.Parse()
@@ -174,7 +449,13 @@ func main() {
 		os.Exit(1)
 	}
 
-	pipelineFile := args[0]
+	// Commands that neither log nor run pipelines
+	switch args[0] {
+	case "completion":
+		os.Exit(completion(args[1:]))
+	case "man":
+		os.Exit(manPages(args[1:]))
+	}
 
 	// Configure logging
 	level := parseLogLevel(*logLevel)
@@ -185,12 +466,62 @@ func main() {
 		logger = logging.NewLogger(level)
 	}
 
+	if err := configurePipelineTrust(*pipelineSigner, *pipelineSignature, *production, logger); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := loadValues(valuesFiles); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := loadWarningBaseline(*baselineFile, *updateBaseline); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if *now != "" {
+		t, err := time.Parse(time.RFC3339, *now)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid --now '%s', expected an RFC 3339 time\n", *now)
+			os.Exit(1)
+		}
+		clock = pipeline.FixedClock(t)
+	}
+
+	switch args[0] {
+	case "run-all":
+		os.Exit(runAll(args[1:], logger))
+	case "explain":
+		os.Exit(explain(args[1:], logger))
+	case "validate-pipeline":
+		os.Exit(validatePipelines(args[1:]))
+	case "serve":
+		os.Exit(serve(args[1:], logger))
+	case "chain":
+		os.Exit(chain(args[1:], logger))
+	case "monitor":
+		os.Exit(monitor(args[1:], logger))
+	case "pool-log":
+		os.Exit(poolLog(args[1:]))
+	case "bump":
+		os.Exit(bump(args[1:], logger))
+	case "example-server":
+		os.Exit(exampleServer(args[1:], logger))
+	}
+
+	pipelineFile := args[0]
+
+	pemFileMode, err := strconv.ParseUint(*outputMode, 8, 32)
+	if err != nil || pemFileMode > 0777 {
+		fmt.Fprintf(os.Stderr, "Error: invalid --output-mode '%s'\n", *outputMode)
+		os.Exit(1)
+	}
+
 	logger.Info("Starting tsl-tool",
 		logging.F("version", Version),
 		logging.F("pipeline", pipelineFile))
 
 	// Load the pipeline from YAML file
-	pl, err := pipeline.NewPipeline(pipelineFile)
+	pl, err := loadPipeline(pipelineFile)
 	if err != nil {
 		logger.Error("Failed to load pipeline",
 			logging.F("file", pipelineFile),
@@ -204,6 +535,13 @@ func main() {
 	logger.Info("Loaded pipeline",
 		logging.F("steps", len(pl.Pipes)))
 
+	var warnings []pipeline.WarningEvent
+	if *updateBaseline || *warningsAsErrors {
+		pl.OnWarning(func(event pipeline.WarningEvent) {
+			warnings = append(warnings, event)
+		})
+	}
+
 	// Create initial context
 	ctx := pipeline.NewContext()
 
@@ -215,53 +553,71 @@ func main() {
 		os.Exit(1)
 	}
 
+	if *updateBaseline {
+		added := 0
+		for _, event := range warnings {
+			if warningBaseline.Add(event) {
+				added++
+			}
+		}
+		if err := warningBaseline.Save(*baselineFile); err != nil {
+			logger.Error("Failed to write warning baseline",
+				logging.F("file", *baselineFile),
+				logging.F("error", err))
+			os.Exit(1)
+		}
+		logger.Info("Updated warning baseline",
+			logging.F("file", *baselineFile),
+			logging.F("added", added))
+	} else if *warningsAsErrors && len(warnings) > 0 {
+		for _, event := range warnings {
+			logger.Error("Unacknowledged warning",
+				logging.F("step", event.Name),
+				logging.F("message", event.Message),
+				logging.F("fingerprint", event.Fingerprint))
+		}
+		logger.Error("Pipeline logged warnings",
+			logging.F("warnings", len(warnings)))
+		os.Exit(1)
+	}
+
 	// Log results
 	tslCount := 0
 	if resultCtx.TSLs != nil {
 		tslCount = resultCtx.TSLs.Size()
 	}
 
-	logger.Info("Pipeline completed successfully",
+	logger.Info("Pipeline completed successfully", append([]logging.Field{
 		logging.F("tsl_count", tslCount),
-		logging.F("cert_pool_exists", resultCtx.CertPool != nil))
+		logging.F("cert_pool_exists", resultCtx.CertPool != nil)},
+		xsltCacheSummary()...)...)
 
-	// Write certificate pool to file if requested
-	if *outputFile != "" && resultCtx.TSLs != nil {
-		// Get all certs from TSLs and write them
-		var pemData []byte
-		var certCount int
+	// Write certificate pools to the requested outputs
+	if len(outputs) > 0 && resultCtx.TSLs != nil {
 		tsls := resultCtx.TSLs.ToSlice()
-		for _, tsl := range tsls {
-			if tsl == nil {
+		for _, target := range outputs {
+			if readOnly {
+				logger.Info("Read-only mode: not writing certificate pool",
+					logging.F("would_write", target.Path))
 				continue
 			}
-			// Extract certificates from TSL
-			tsl.WithTrustServices(func(tsp *etsi119612.TSPType, svc *etsi119612.TSPServiceType) {
-				svc.WithCertificates(func(cert *x509.Certificate) {
-					block := &pem.Block{
-						Type:  "CERTIFICATE",
-						Bytes: cert.Raw,
-					}
-					pemData = append(pemData, pem.EncodeToMemory(block)...)
-					certCount++
-				})
-			})
-		}
-
-		if len(pemData) > 0 {
-			if err := os.WriteFile(*outputFile, pemData, 0644); err != nil {
+			size, certCount, err := target.write(tsls, os.FileMode(pemFileMode), *outputMetadata)
+			if err != nil {
 				logger.Error("Failed to write certificate pool",
-					logging.F("file", *outputFile),
+					logging.F("file", target.Path),
 					logging.F("error", err))
 				os.Exit(1)
 			}
+			if certCount == 0 {
+				logger.Warn("No certificates to write",
+					logging.F("file", target.Path))
+				continue
+			}
 			logger.Info("Wrote certificate pool",
-				logging.F("file", *outputFile),
-				logging.F("bytes", len(pemData)),
-				logging.F("certificates", certCount))
-		} else {
-			logger.Warn("No certificates to write",
-				logging.F("file", *outputFile))
+				logging.F("file", target.Path),
+				logging.F("bytes", size),
+				logging.F("certificates", certCount),
+				logging.F("filtered", target.Policy != nil))
 		}
 	}
 
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirosfoundation/g119612/pkg/pipeline"
+)
+
+// manPages implements "tsl-tool man [--dir DIR]", writing the manual pages
+// tsl-tool.1 and tsl-tool-pipeline.5 to DIR. The pipeline page lists the
+// steps of pipeline.DefaultRegistry with their pipeline.StepInfo. It returns
+// the process exit code.
+func manPages(args []string) int {
+	fs := flag.NewFlagSet("man", flag.ContinueOnError)
+	dir := fs.String("dir", ".", "Directory to write the pages to")
+	if err := fs.Parse(args); err != nil {
+		return 1
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(os.Stderr, "Error: unexpected argument '%s'\n", fs.Arg(0))
+		return 1
+	}
+	if err := os.MkdirAll(*dir, 0755); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	pages := []struct {
+		name  string
+		write func(io.Writer)
+	}{
+		{"tsl-tool.1", func(w io.Writer) { writeToolPage(w, globalFlags()) }},
+		{"tsl-tool-pipeline.5", writePipelinePage},
+	}
+	for _, page := range pages {
+		var buf bytes.Buffer
+		page.write(&buf)
+		path := filepath.Join(*dir, page.name)
+		if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		fmt.Println(path)
+	}
+	return 0
+}
+
+// roff escapes text for a roff text line.
+func roff(text string) string {
+	text = strings.NewReplacer(`\`, `\e`, "-", `\-`).Replace(text)
+	if strings.HasPrefix(text, ".") || strings.HasPrefix(text, "'") {
+		text = `\&` + text
+	}
+	return text
+}
+
+// roffFlag formats a flag for a .TP tag line.
+func roffFlag(f commandFlag) string {
+	if f.Value == "" {
+		return `\fB\-\-` + roff(f.Name) + `\fR`
+	}
+	return `\fB\-\-` + roff(f.Name) + `\fR \fI` + roff(f.Value) + `\fR`
+}
+
+// writeToolPage writes the tsl-tool(1) manual page with the global flags.
+func writeToolPage(w io.Writer, global []commandFlag) {
+	fmt.Fprintf(w, `.TH TSL-TOOL 1 "" "tsl-tool %s" "User Commands"
+.SH NAME
+tsl-tool \- process ETSI TS 119612 Trust Status Lists with YAML pipelines
+.SH SYNOPSIS
+.B tsl-tool
+[\fIoptions\fR] \fIpipeline.yaml\fR
+`, roff(Version))
+	for _, cmd := range commands {
+		fmt.Fprintf(w, ".br\n.B tsl-tool\n[\\fIoptions\\fR] \\fB%s\\fR", roff(cmd.Name))
+		for _, f := range cmd.Flags {
+			fmt.Fprintf(w, " [%s]", roffFlag(f))
+		}
+		if cmd.Args != "" {
+			fmt.Fprintf(w, " \\fI%s\\fR", roff(cmd.Args))
+		}
+		fmt.Fprintln(w)
+	}
+	fmt.Fprint(w, `.SH DESCRIPTION
+tsl-tool is a batch processor for ETSI TS 119612 Trust Status Lists (TSLs).
+It runs the steps of a YAML pipeline, described in
+.BR tsl-tool-pipeline (5),
+to load, select, transform, sign and publish TSLs, and is meant to run
+periodically, for example from cron.
+The commands below run pipelines in other ways or work on their results.
+.SH OPTIONS
+Options are given before the pipeline file or the command.
+`)
+	for _, f := range global {
+		fmt.Fprintf(w, ".TP\n%s\n%s\n", roffFlag(f), roff(f.Usage))
+	}
+	fmt.Fprintln(w, ".SH COMMANDS")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, ".SS %s\n%s.\n", roff(cmd.Name), roff(cmd.Summary))
+		for _, f := range cmd.Flags {
+			fmt.Fprintf(w, ".TP\n%s\n%s\n", roffFlag(f), roff(f.Usage))
+		}
+	}
+	fmt.Fprint(w, `.SH EXIT STATUS
+.TP
+.B 0
+Success.
+.TP
+.B 1
+Invalid arguments, or a pipeline or command failed.
+.SH SEE ALSO
+.BR tsl-tool-pipeline (5),
+.BR xsltproc (1)
+.PP
+https://github.com/sirosfoundation/g119612
+`)
+}
+
+// writePipelinePage writes the tsl-tool-pipeline(5) manual page.
+func writePipelinePage(w io.Writer) {
+	fmt.Fprintf(w, `.TH TSL-TOOL-PIPELINE 5 "" "tsl-tool %s" "File Formats"
+.SH NAME
+tsl-tool-pipeline \- pipeline files of tsl-tool
+.SH DESCRIPTION
+A pipeline is a YAML list of steps run in order.
+Each step is a mapping from the name of the step to the list of its
+arguments.
+Options are arguments of the form \fIname\fR:\fIvalue\fR and may be given in
+any order among the other arguments.
+.PP
+.nf
+.RS
+\- set\-fetch\-options:
+    \- timeout:60s
+\- load:
+    \- https://ec.europa.eu/tools/lotl/eu\-lotl.xml
+\- select:
+    \- reference\-depth:2
+\- publish:
+    \- /var/www/tsl
+.RE
+.fi
+.SH STEPS
+`, roff(Version))
+	for _, name := range pipeline.DefaultRegistry.Names() {
+		info, _ := pipeline.DefaultRegistry.Info(name)
+		fmt.Fprintf(w, ".SS %s", roff(name))
+		if info.Args != "" {
+			fmt.Fprintf(w, " \\fI%s\\fR", roff(info.Args))
+		}
+		fmt.Fprintln(w)
+		if info.Summary != "" {
+			fmt.Fprintf(w, "%s.\n", roff(info.Summary))
+		}
+		for _, opt := range info.Options {
+			fmt.Fprintf(w, ".TP\n.B %s\n%s\n", roff(opt.Name), roff(opt.Description))
+		}
+	}
+	fmt.Fprintf(w, `.SS %s
+Runs the steps of \fBthen\fR when the condition holds and those of
+\fBelse\fR otherwise.
+The condition compares a statistic of the context, one of
+\fB%s\fR, \fB%s\fR, \fB%s\fR, \fB%s\fR and \fB%s\fR,
+with an integer, for example "cert\-count > 0".
+.PP
+.nf
+.RS
+\- if: "cert\-count > 0"
+  then:
+    \- publish: ["/var/www/tsl"]
+  else:
+    \- log: ["Nothing selected"]
+.RE
+.fi
+.SH SEE ALSO
+.BR tsl-tool (1)
+`, pipeline.ConditionalStep, roff(pipeline.StatTSLCount), roff(pipeline.StatCertCount),
+		roff(pipeline.StatServiceCount), roff(pipeline.StatQualifiedServiceCount), roff(pipeline.StatActiveServiceCount))
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/sirosfoundation/g119612/pkg/logging"
+	"github.com/sirosfoundation/g119612/pkg/pipeline"
+)
+
+// monitor implements "tsl-tool monitor --certs path <pipeline.yaml>
+// [--interval d] [--alert-webhook url]". It runs the pipeline every
+// --interval and re-verifies the certificates of --certs against the pool of
+// each run (see pipeline.CertificateMonitor). Certificates that verified at
+// the previous run but no longer do are logged as errors and, with
+// --alert-webhook, POSTed to the URL as a JSON array of
+// pipeline.VerificationAlert. A failing run is logged and the certificates are
+// checked again after the next one. It runs until interrupted and returns the
+// process exit code.
+func monitor(args []string, logger logging.Logger) int {
+	fs := flag.NewFlagSet("monitor", flag.ContinueOnError)
+	certsPath := fs.String("certs", "", "PEM file or directory of PEM files with the certificates to re-verify")
+	interval := fs.Duration("interval", time.Hour, "Rerun the pipeline and re-verify the certificates at this interval")
+	webhook := fs.String("alert-webhook", "", "URL to POST alerts to as JSON")
+
+	// Accept flags before and after the pipeline argument
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return 1
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if len(positional) != 1 {
+		fmt.Fprintln(os.Stderr, "Error: monitor expects exactly one pipeline YAML file argument")
+		return 1
+	}
+	if *certsPath == "" {
+		fmt.Fprintln(os.Stderr, "Error: monitor requires --certs")
+		return 1
+	}
+	if *interval <= 0 {
+		fmt.Fprintf(os.Stderr, "Error: invalid --interval %s\n", *interval)
+		return 1
+	}
+	certs, err := pipeline.LoadMonitoredCertificates(*certsPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	pl, err := loadPipeline(positional[0])
+	if err != nil {
+		logger.Error("Failed to load pipeline",
+			logging.F("file", positional[0]),
+			logging.F("error", err))
+		return 1
+	}
+	pl = pl.WithLogger(logger)
+	watcher := pipeline.NewCertificateMonitor(certs)
+	logger.Info("Monitoring certificates",
+		logging.F("pipeline", positional[0]),
+		logging.F("certificates", len(certs)),
+		logging.F("interval", *interval),
+		logging.F("webhook", *webhook != ""))
+
+	check := func() {
+		ctx, err := pl.Process(pipeline.NewContext())
+		if err != nil {
+			logger.Error("Pipeline processing failed", logging.F("error", err))
+			return
+		}
+		verified, alerts := watcher.Check(pl, ctx)
+		for _, alert := range alerts {
+			logger.Error("Monitored certificate no longer verifies",
+				logging.F("certificate", alert.Name),
+				logging.F("subject", alert.Subject),
+				logging.F("sha256", alert.SHA256),
+				logging.F("error", alert.Error))
+		}
+		logger.Info("Verified monitored certificates",
+			logging.F("verified", verified),
+			logging.F("failed", len(certs)-verified),
+			logging.F("alerts", len(alerts)))
+		if len(alerts) > 0 && *webhook != "" {
+			if err := postAlerts(*webhook, alerts); err != nil {
+				logger.Error("Failed to send alerts",
+					logging.F("webhook", *webhook),
+					logging.F("error", err))
+			}
+		}
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	for {
+		check()
+		select {
+		case <-ticker.C:
+		case <-stop:
+			logger.Info("Monitor stopped")
+			return 0
+		}
+	}
+}
+
+// postAlerts POSTs alerts to url as a JSON array and fails unless the
+// response has a 2xx status.
+func postAlerts(url string, alerts []pipeline.VerificationAlert) error {
+	body, err := json.Marshal(alerts)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
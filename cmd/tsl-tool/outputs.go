@@ -0,0 +1,546 @@
+package main
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sirosfoundation/g119612/pkg/etsi119612"
+	"github.com/sirosfoundation/g119612/pkg/pkcs12"
+)
+
+const (
+	serviceTypeURIPrefix   = "http://uri.etsi.org/TrstSvc/Svctype/"
+	serviceStatusURIPrefix = "http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/"
+)
+
+// Output formats of an outputTarget.
+const (
+	formatPEM    = "pem"
+	formatPKCS12 = "pkcs12"
+)
+
+// defaultFriendlyName is the template of the aliases in PKCS#12 outputs.
+const defaultFriendlyName = "{tsp} - {service}"
+
+// outputTarget is a single --output destination with an optional service policy.
+// A nil Policy means every certificate is written (the historical behaviour).
+type outputTarget struct {
+	Path   string
+	Policy *etsi119612.TSPServicePolicy
+
+	// Format is formatPEM or formatPKCS12.
+	Format string
+
+	// Split is "territory" or "type" to write one file per scheme territory
+	// or service type instead of a single file.
+	Split string
+
+	// PasswordFile and PasswordEnv name the file or the environment variable
+	// holding the password of a PKCS#12 output; exactly one is set.
+	PasswordFile string
+	PasswordEnv  string
+
+	// FriendlyName is the template of the aliases in a PKCS#12 output.
+	FriendlyName string
+}
+
+// outputTargets implements flag.Value so --output can be repeated.
+//
+// Each value has the form "path[:key=value[,key=value...]]", where the path may
+// contain colons not followed by one of the keys, and key is one of:
+//   - type: service type, either a full URI or a suffix such as "CA/QC" or "TSA"
+//   - status: service status, either a full URI or a short name such as "granted"
+//   - format: "pem" or "pkcs12"; the default is pkcs12 for paths ending in
+//     .p12 or .pfx and pem otherwise
+//   - split: "territory" or "type" to write one file per scheme territory or
+//     service type; the path either contains the placeholder {territory} or
+//     {type}, or gets the value inserted before its extension
+//   - password-file, password-env: file or environment variable with the
+//     password of a pkcs12 output, which requires one of them
+//   - friendly-name: template of the aliases in a pkcs12 output, with the
+//     placeholders {tsp}, {service}, {territory}, {type} and {subject}
+//     (default "{tsp} - {service}")
+//
+// Keys type and status may be repeated to allow several types or statuses.
+// When any of them is given the output is filtered by a TSPServicePolicy; as
+// with NewTSPServicePolicy the policy only accepts granted services unless a
+// status is specified.
+type outputTargets []outputTarget
+
+// String implements flag.Value.
+func (o *outputTargets) String() string {
+	paths := make([]string, 0, len(*o))
+	for _, t := range *o {
+		paths = append(paths, t.Path)
+	}
+	return strings.Join(paths, ",")
+}
+
+// Set implements flag.Value by parsing and appending one output target.
+func (o *outputTargets) Set(value string) error {
+	target, err := parseOutputTarget(value)
+	if err != nil {
+		return err
+	}
+	*o = append(*o, target)
+	return nil
+}
+
+// outputPolicyKeys are the keys of the options of an --output value.
+var outputPolicyKeys = []string{"type", "status", "format", "split", "password-file", "password-env", "friendly-name"}
+
+// unknownOutputOption matches what looks like an option with an unknown key
+// after the last colon of an output path.
+var unknownOutputOption = regexp.MustCompile(`^[a-z][a-z-]*=`)
+
+// splitOutputValue splits an --output value into the path and its options,
+// at the last ":" followed by a known key and "=", so that paths may contain
+// colons, such as pool:2024.pem or C:\certs\pool.pem.
+func splitOutputValue(value string) (path, spec string) {
+	for i := strings.LastIndex(value, ":"); i >= 0; i = strings.LastIndex(value[:i], ":") {
+		rest := value[i+1:]
+		for _, key := range outputPolicyKeys {
+			if strings.HasPrefix(strings.TrimSpace(rest), key+"=") {
+				return value[:i], rest
+			}
+		}
+	}
+	return strings.TrimSuffix(value, ":"), ""
+}
+
+// parseOutputTarget parses a single --output value.
+func parseOutputTarget(value string) (outputTarget, error) {
+	path, spec := splitOutputValue(value)
+	if path == "" {
+		return outputTarget{}, fmt.Errorf("output path cannot be empty")
+	}
+	if i := strings.LastIndex(path, ":"); i >= 0 && unknownOutputOption.MatchString(path[i+1:]) {
+		key, _, _ := strings.Cut(path[i+1:], "=")
+		return outputTarget{}, fmt.Errorf("unknown output policy key %q in %q", key, value)
+	}
+	target := outputTarget{Path: path}
+
+	var policy *etsi119612.TSPServicePolicy
+	statusSet := false
+	if spec != "" {
+		for _, item := range strings.Split(spec, ",") {
+			key, val, ok := strings.Cut(strings.TrimSpace(item), "=")
+			if !ok || val == "" {
+				return outputTarget{}, fmt.Errorf("invalid output policy %q in %q (expected key=value)", item, value)
+			}
+			if (key == "type" || key == "status") && policy == nil {
+				policy = etsi119612.NewTSPServicePolicy()
+			}
+			switch key {
+			case "type":
+				policy.AddServiceTypeIdentifier(expandServiceType(val))
+			case "status":
+				if !statusSet {
+					policy.ServiceStatus = policy.ServiceStatus[:0]
+					statusSet = true
+				}
+				for _, uri := range expandServiceStatus(val) {
+					policy.AddServiceStatus(uri)
+				}
+			case "format":
+				if val != formatPEM && val != formatPKCS12 {
+					return outputTarget{}, fmt.Errorf("invalid output format %q in %q (expected pem or pkcs12)", val, value)
+				}
+				target.Format = val
+			case "split":
+				if val != "territory" && val != "type" {
+					return outputTarget{}, fmt.Errorf("invalid output split %q in %q (expected territory or type)", val, value)
+				}
+				target.Split = val
+			case "password-file":
+				target.PasswordFile = val
+			case "password-env":
+				target.PasswordEnv = val
+			case "friendly-name":
+				target.FriendlyName = val
+			default:
+				return outputTarget{}, fmt.Errorf("unknown output policy key %q in %q", key, value)
+			}
+		}
+	}
+	target.Policy = policy
+
+	if target.Format == "" {
+		target.Format = formatPEM
+		if ext := strings.ToLower(filepath.Ext(path)); ext == ".p12" || ext == ".pfx" {
+			target.Format = formatPKCS12
+		}
+	}
+	if target.Format == formatPKCS12 {
+		if (target.PasswordFile == "") == (target.PasswordEnv == "") {
+			return outputTarget{}, fmt.Errorf("pkcs12 output %q needs either password-file or password-env", path)
+		}
+		if target.FriendlyName == "" {
+			target.FriendlyName = defaultFriendlyName
+		}
+	} else if target.PasswordFile != "" || target.PasswordEnv != "" || target.FriendlyName != "" {
+		return outputTarget{}, fmt.Errorf("password-file, password-env and friendly-name only apply to pkcs12 outputs, not %q", path)
+	}
+	return target, nil
+}
+
+// expandServiceType turns a short service type such as "CA/QC" into its full URI.
+func expandServiceType(value string) string {
+	if strings.Contains(value, "://") {
+		return value
+	}
+	return serviceTypeURIPrefix + strings.Trim(value, "/")
+}
+
+// expandServiceStatus turns a short status name such as "granted" into the URIs
+// that denote it. Both the ETSI form and the trailing-slash https form used by
+// etsi119612.ServiceStatusGranted are returned so either spelling matches.
+func expandServiceStatus(value string) []string {
+	if strings.Contains(value, "://") {
+		return []string{value}
+	}
+	name := strings.Trim(value, "/")
+	return []string{
+		serviceStatusURIPrefix + name,
+		"https://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/" + name + "/",
+	}
+}
+
+// poolEntry is a certificate selected for an output, with the service that
+// lists it.
+type poolEntry struct {
+	cert *x509.Certificate
+	tsl  *etsi119612.TSL
+	tsp  *etsi119612.TSPType
+	svc  *etsi119612.TSPServiceType
+}
+
+// collect returns the certificates of all services in tsls that satisfy the
+// target's policy, in the order of the lists.
+func (t outputTarget) collect(tsls []*etsi119612.TSL) []poolEntry {
+	var entries []poolEntry
+	for _, tsl := range tsls {
+		if tsl == nil {
+			continue
+		}
+		tsl.WithTrustServices(func(tsp *etsi119612.TSPType, svc *etsi119612.TSPServiceType) {
+			if t.Policy != nil {
+				if svc.TslServiceInformation == nil || tsp.Validate(svc, nil, t.Policy) != nil {
+					return
+				}
+			}
+			svc.WithCertificates(func(cert *x509.Certificate) {
+				entries = append(entries, poolEntry{cert: cert, tsl: tsl, tsp: tsp, svc: svc})
+			})
+		})
+	}
+	return entries
+}
+
+// territory returns the scheme territory of the TSL listing the entry.
+func (e poolEntry) territory() string {
+	if info := e.tsl.StatusList.TslSchemeInformation; info != nil {
+		return info.TslSchemeTerritory
+	}
+	return ""
+}
+
+// serviceType returns the service type of the entry, shortened to the part
+// following "/Svctype/" as in the ETSI type URIs, e.g. "CA/QC".
+func (e poolEntry) serviceType() string {
+	if e.svc.TslServiceInformation == nil {
+		return ""
+	}
+	uri := e.svc.TslServiceInformation.TslServiceTypeIdentifier
+	if _, suffix, ok := strings.Cut(uri, "/Svctype/"); ok {
+		return strings.Trim(suffix, "/")
+	}
+	return uri
+}
+
+// friendlyName expands the friendly-name template for the entry.
+func (e poolEntry) friendlyName(template string) string {
+	var service string
+	if e.svc.TslServiceInformation != nil {
+		service = etsi119612.FindByLanguage(e.svc.TslServiceInformation.ServiceName, "en", "Unknown")
+	}
+	subject := e.cert.Subject.CommonName
+	if subject == "" {
+		subject = e.cert.Subject.String()
+	}
+	return strings.NewReplacer(
+		"{tsp}", e.tsp.Name(),
+		"{service}", service,
+		"{territory}", e.territory(),
+		"{type}", e.serviceType(),
+		"{subject}", subject,
+	).Replace(template)
+}
+
+// outputFile is one file written for an outputTarget.
+type outputFile struct {
+	path    string
+	entries []poolEntry
+}
+
+// files distributes entries over the files of the target: a single file at
+// its path, or one per territory or service type with Split.
+func (t outputTarget) files(entries []poolEntry) []outputFile {
+	if t.Split == "" {
+		return []outputFile{{path: t.Path, entries: entries}}
+	}
+	var files []outputFile
+	index := map[string]int{}
+	for _, entry := range entries {
+		value := entry.territory()
+		if t.Split == "type" {
+			value = entry.serviceType()
+		}
+		path := splitPath(t.Path, "{"+t.Split+"}", fileNamePart(value))
+		i, ok := index[path]
+		if !ok {
+			i = len(files)
+			index[path] = i
+			files = append(files, outputFile{path: path})
+		}
+		files[i].entries = append(files[i].entries, entry)
+	}
+	return files
+}
+
+// splitPath returns path with placeholder replaced by value, or with value
+// inserted before the extension if path has no placeholder.
+func splitPath(path, placeholder, value string) string {
+	if strings.Contains(path, placeholder) {
+		return strings.ReplaceAll(path, placeholder, value)
+	}
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "-" + value + ext
+}
+
+// fileNamePart turns a territory or service type into a part of a file name,
+// replacing the characters other than letters, digits, dots and dashes.
+func fileNamePart(value string) string {
+	if value == "" {
+		return "unknown"
+	}
+	return strings.Map(func(r rune) rune {
+		if r == '.' || r == '-' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' {
+			return r
+		}
+		return '-'
+	}, strings.Trim(value, "/"))
+}
+
+// encodePEM returns the certificates of entries as PEM blocks.
+func encodePEM(entries []poolEntry) []byte {
+	var pemData []byte
+	for _, entry := range entries {
+		block := &pem.Block{
+			Type:  "CERTIFICATE",
+			Bytes: entry.cert.Raw,
+		}
+		pemData = append(pemData, pem.EncodeToMemory(block)...)
+	}
+	return pemData
+}
+
+// encodePKCS12 returns the certificates of entries as a PKCS#12 trust store
+// protected by password, and the number of certificates in it. A certificate
+// listed by several services is stored once, under the alias of the first;
+// aliases that would clash get a counter appended, as keystores compare
+// aliases ignoring case.
+func (t outputTarget) encodePKCS12(entries []poolEntry, password string) ([]byte, int, error) {
+	var certs []pkcs12.TrustedCertificate
+	seen := map[string]bool{}
+	aliases := map[string]bool{}
+	for _, entry := range entries {
+		if seen[string(entry.cert.Raw)] {
+			continue
+		}
+		seen[string(entry.cert.Raw)] = true
+		name := entry.friendlyName(t.FriendlyName)
+		alias := name
+		for n := 2; aliases[strings.ToLower(alias)]; n++ {
+			alias = fmt.Sprintf("%s (%d)", name, n)
+		}
+		aliases[strings.ToLower(alias)] = true
+		certs = append(certs, pkcs12.TrustedCertificate{Certificate: entry.cert, FriendlyName: alias})
+	}
+	data, err := pkcs12.EncodeTrustStore(certs, password)
+	if err != nil {
+		return nil, 0, err
+	}
+	return data, len(certs), nil
+}
+
+// password returns the password of a PKCS#12 output from its password file,
+// without trailing line breaks, or from its environment variable.
+func (t outputTarget) password() (string, error) {
+	if t.PasswordFile != "" {
+		data, err := os.ReadFile(t.PasswordFile)
+		if err != nil {
+			return "", fmt.Errorf("failed to read password file: %w", err)
+		}
+		password := strings.TrimRight(string(data), "\r\n")
+		if password == "" {
+			return "", fmt.Errorf("password file %s is empty", t.PasswordFile)
+		}
+		return password, nil
+	}
+	password := os.Getenv(t.PasswordEnv)
+	if password == "" {
+		return "", fmt.Errorf("environment variable %s is not set", t.PasswordEnv)
+	}
+	return password, nil
+}
+
+// sources returns the TSLs the entries were taken from.
+func sources(entries []poolEntry) []*etsi119612.TSL {
+	var tsls []*etsi119612.TSL
+	for _, entry := range entries {
+		if !slices.Contains(tsls, entry.tsl) {
+			tsls = append(tsls, entry.tsl)
+		}
+	}
+	return tsls
+}
+
+// bundleMetadata describes a written certificate pool so that consumers can
+// tell how fresh it is. It is written as header comments of the PEM file and,
+// with --output-metadata, as JSON next to it.
+type bundleMetadata struct {
+	Generated          time.Time      `json:"generated"`
+	Policy             *bundlePolicy  `json:"policy,omitempty"` // nil if the output is not filtered
+	CertificateCount   int            `json:"certificate_count"`
+	EarliestNextUpdate *time.Time     `json:"earliest_next_update,omitempty"`
+	Sources            []bundleSource `json:"sources"`
+}
+
+// bundlePolicy is the service policy of a filtered output.
+type bundlePolicy struct {
+	ServiceTypes    []string `json:"service_types,omitempty"`
+	ServiceStatuses []string `json:"service_statuses"`
+}
+
+// bundleSource is a TSL that contributed certificates to a pool.
+type bundleSource struct {
+	URL            string `json:"url"`
+	SequenceNumber int    `json:"sequence_number"`
+	NextUpdate     string `json:"next_update,omitempty"`
+}
+
+// metadata describes the pool of the target built from sources at generated.
+func (t outputTarget) metadata(sources []*etsi119612.TSL, certCount int, generated time.Time) bundleMetadata {
+	meta := bundleMetadata{Generated: generated.UTC().Truncate(time.Second), CertificateCount: certCount, Sources: []bundleSource{}}
+	if t.Policy != nil {
+		meta.Policy = &bundlePolicy{
+			ServiceTypes:    t.Policy.ServiceTypeIdentifier,
+			ServiceStatuses: t.Policy.ServiceStatus,
+		}
+	}
+	for _, tsl := range sources {
+		source := bundleSource{URL: tsl.Source}
+		if info := tsl.StatusList.TslSchemeInformation; info != nil {
+			source.SequenceNumber = info.TSLSequenceNumber
+			if info.TslNextUpdate != nil {
+				source.NextUpdate = info.TslNextUpdate.DateTime
+				if next, err := time.Parse(time.RFC3339, info.TslNextUpdate.DateTime); err == nil {
+					if meta.EarliestNextUpdate == nil || next.Before(*meta.EarliestNextUpdate) {
+						next = next.UTC()
+						meta.EarliestNextUpdate = &next
+					}
+				}
+			}
+		}
+		meta.Sources = append(meta.Sources, source)
+	}
+	return meta
+}
+
+// header renders the metadata as comment lines preceding the PEM blocks,
+// which PEM parsers skip.
+func (m bundleMetadata) header() []byte {
+	var b strings.Builder
+	b.WriteString("# Certificate pool written by tsl-tool\n")
+	fmt.Fprintf(&b, "# generated: %s\n", m.Generated.Format(time.RFC3339))
+	if m.Policy == nil {
+		b.WriteString("# policy: all certificates\n")
+	} else {
+		fmt.Fprintf(&b, "# policy: type=%s status=%s\n",
+			strings.Join(m.Policy.ServiceTypes, ","), strings.Join(m.Policy.ServiceStatuses, ","))
+	}
+	fmt.Fprintf(&b, "# certificates: %d\n", m.CertificateCount)
+	if m.EarliestNextUpdate != nil {
+		fmt.Fprintf(&b, "# earliest-next-update: %s\n", m.EarliestNextUpdate.Format(time.RFC3339))
+	}
+	for _, source := range m.Sources {
+		fmt.Fprintf(&b, "# source: %s sequence=%d", source.URL, source.SequenceNumber)
+		if source.NextUpdate != "" {
+			fmt.Fprintf(&b, " next-update=%s", source.NextUpdate)
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("\n")
+	return []byte(b.String())
+}
+
+// write writes the certificates selected by the target to its path, or to
+// one file per territory or service type with Split. PEM files start with
+// header comments describing the pool; with withMetadata the same description
+// is also written as JSON to each path with ".json" appended. It returns the
+// number of bytes and certificates written; nothing is written when no
+// certificate matches.
+func (t outputTarget) write(tsls []*etsi119612.TSL, mode os.FileMode, withMetadata bool) (int, int, error) {
+	entries := t.collect(tsls)
+	if len(entries) == 0 {
+		return 0, 0, nil
+	}
+	var password string
+	if t.Format == formatPKCS12 {
+		var err error
+		if password, err = t.password(); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	generated := clock.Now()
+	size, total := 0, 0
+	for _, file := range t.files(entries) {
+		var data []byte
+		certCount := len(file.entries)
+		if t.Format == formatPKCS12 {
+			var err error
+			if data, certCount, err = t.encodePKCS12(file.entries, password); err != nil {
+				return 0, 0, err
+			}
+		}
+		meta := t.metadata(sources(file.entries), certCount, generated)
+		if t.Format == formatPEM {
+			data = append(meta.header(), encodePEM(file.entries)...)
+		}
+		if err := os.WriteFile(file.path, data, mode); err != nil {
+			return 0, 0, err
+		}
+		if withMetadata {
+			metaData, err := json.MarshalIndent(meta, "", "  ")
+			if err != nil {
+				return 0, 0, err
+			}
+			if err := os.WriteFile(file.path+".json", append(metaData, '\n'), mode); err != nil {
+				return 0, 0, err
+			}
+		}
+		size += len(data)
+		total += certCount
+	}
+	return size, total, nil
+}
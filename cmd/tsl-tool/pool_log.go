@@ -0,0 +1,168 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/sirosfoundation/g119612/pkg/pipeline"
+)
+
+// poolLog implements "tsl-tool pool-log <log> [--sha256 fingerprint]
+// [--cert file.pem] [--format text|json]". It verifies the hash chain of a
+// pool log written by the pool-log option of select and prints a summary, or
+// with --sha256 or --cert when the certificate was first and last trusted. It
+// returns the process exit code: 1 if the log cannot be read or is corrupt.
+func poolLog(args []string) int {
+	fs := flag.NewFlagSet("pool-log", flag.ContinueOnError)
+	fingerprint := fs.String("sha256", "", "Hex SHA-256 fingerprint of the certificate to show the history of")
+	certFile := fs.String("cert", "", "PEM file whose first certificate to show the history of")
+	format := fs.String("format", "text", "Output format: text or json")
+
+	// Accept flags before and after the log argument
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return 1
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if len(positional) != 1 {
+		fmt.Fprintln(os.Stderr, "Error: pool-log expects exactly one log file argument")
+		return 1
+	}
+	if *format != "text" && *format != "json" {
+		fmt.Fprintf(os.Stderr, "Error: invalid --format '%s'\n", *format)
+		return 1
+	}
+	if *fingerprint != "" && *certFile != "" {
+		fmt.Fprintln(os.Stderr, "Error: --sha256 and --cert are mutually exclusive")
+		return 1
+	}
+	if *certFile != "" {
+		certs, err := readCertificates(*certFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		digest := sha256.Sum256(certs[0].Raw)
+		*fingerprint = hex.EncodeToString(digest[:])
+	}
+
+	if _, err := os.Stat(positional[0]); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	log, err := pipeline.OpenPoolLog(positional[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	var result any
+	if *fingerprint != "" {
+		result = log.History(*fingerprint)
+	} else {
+		result = summarizePoolLog(log)
+	}
+	if *format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(result); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+	switch result := result.(type) {
+	case pipeline.TrustHistory:
+		writeTrustHistory(os.Stdout, result)
+	case poolLogSummary:
+		writePoolLogSummary(os.Stdout, result)
+	}
+	return 0
+}
+
+// poolLogSummary is what pool-log prints without --sha256 or --cert.
+type poolLogSummary struct {
+	Entries  int        `json:"entries"`
+	Runs     int        `json:"runs"`
+	Added    int        `json:"added"`
+	Removed  int        `json:"removed"`
+	Trusted  int        `json:"trusted"` // Certificates in the pool at the last run
+	FirstRun *time.Time `json:"first_run,omitempty"`
+	LastRun  *time.Time `json:"last_run,omitempty"`
+	Head     string     `json:"head,omitempty"` // Hash of the last entry
+}
+
+// summarizePoolLog counts the entries of a verified pool log.
+func summarizePoolLog(log *pipeline.PoolLog) poolLogSummary {
+	entries := log.Entries()
+	summary := poolLogSummary{Entries: len(entries), Trusted: log.Len()}
+	for _, entry := range entries {
+		switch entry.Action {
+		case pipeline.PoolLogAdd:
+			summary.Added++
+		case pipeline.PoolLogRemove:
+			summary.Removed++
+		case pipeline.PoolLogRun:
+			summary.Runs++
+			at := entry.Time
+			if summary.FirstRun == nil {
+				summary.FirstRun = &at
+			}
+			summary.LastRun = &at
+		}
+	}
+	if len(entries) > 0 {
+		summary.Head = entries[len(entries)-1].Hash
+	}
+	return summary
+}
+
+// writePoolLogSummary prints a pool log summary as text.
+func writePoolLogSummary(w io.Writer, summary poolLogSummary) {
+	fmt.Fprintf(w, "Hash chain verified: %d entries\n", summary.Entries)
+	fmt.Fprintf(w, "  runs: %d\n", summary.Runs)
+	if summary.FirstRun != nil {
+		fmt.Fprintf(w, "  first run: %s\n", summary.FirstRun.Format(time.RFC3339))
+		fmt.Fprintf(w, "  last run: %s\n", summary.LastRun.Format(time.RFC3339))
+	}
+	fmt.Fprintf(w, "  added: %d\n", summary.Added)
+	fmt.Fprintf(w, "  removed: %d\n", summary.Removed)
+	fmt.Fprintf(w, "  trusted: %d\n", summary.Trusted)
+	if summary.Head != "" {
+		fmt.Fprintf(w, "  head: %s\n", summary.Head)
+	}
+}
+
+// writeTrustHistory prints the history of a certificate as text.
+func writeTrustHistory(w io.Writer, history pipeline.TrustHistory) {
+	fmt.Fprintf(w, "Certificate %s:\n", history.SHA256)
+	if len(history.Periods) == 0 {
+		fmt.Fprintln(w, "  never trusted")
+		return
+	}
+	fmt.Fprintf(w, "  subject: %s\n", history.Subject)
+	fmt.Fprintf(w, "  trusted: %t\n", history.Trusted)
+	fmt.Fprintf(w, "  first trusted: %s\n", history.FirstTrusted.Format(time.RFC3339))
+	if history.LastTrusted != nil {
+		fmt.Fprintf(w, "  last trusted: %s\n", history.LastTrusted.Format(time.RFC3339))
+	}
+	for _, period := range history.Periods {
+		if period.Removed != nil {
+			fmt.Fprintf(w, "  period: %s - %s\n", period.Added.Format(time.RFC3339), period.Removed.Format(time.RFC3339))
+		} else {
+			fmt.Fprintf(w, "  period: %s -\n", period.Added.Format(time.RFC3339))
+		}
+	}
+}
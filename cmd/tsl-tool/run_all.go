@@ -0,0 +1,126 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/sirosfoundation/g119612/pkg/etsi119612"
+	"github.com/sirosfoundation/g119612/pkg/logging"
+	"github.com/sirosfoundation/g119612/pkg/pipeline"
+)
+
+// runAll implements "tsl-tool run-all <dir> [--concurrency N]". It processes
+// every pipeline file in dir concurrently, each with an isolated context, logs
+// one result per pipeline and a summary, and returns the process exit code:
+// 0 if all pipelines succeeded and 1 otherwise.
+func runAll(args []string, logger logging.Logger) int {
+	fs := flag.NewFlagSet("run-all", flag.ContinueOnError)
+	concurrency := fs.Int("concurrency", 1, "Number of pipelines processed at once")
+
+	// Accept flags before and after the directory argument
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return 1
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if len(positional) != 1 {
+		fmt.Fprintln(os.Stderr, "Error: run-all expects exactly one pipeline directory argument")
+		return 1
+	}
+	if *concurrency < 1 {
+		fmt.Fprintf(os.Stderr, "Error: invalid --concurrency %d\n", *concurrency)
+		return 1
+	}
+
+	if pipelineTrust.signature != "" {
+		fmt.Fprintln(os.Stderr, "Error: --pipeline-signature names the signature of a single pipeline; run-all uses <pipeline>.sig")
+		return 1
+	}
+
+	dir := positional[0]
+	files, err := pipeline.DiscoverPipelines(dir)
+	if err != nil {
+		logger.Error("Failed to discover pipelines", logging.F("error", err))
+		return 1
+	}
+	if len(files) == 0 {
+		logger.Error("No pipeline files found", logging.F("dir", dir))
+		return 1
+	}
+
+	logger.Info("Running pipelines",
+		logging.F("dir", dir),
+		logging.F("pipelines", len(files)),
+		logging.F("concurrency", *concurrency))
+
+	failed := 0
+	for _, result := range pipeline.RunAllWith(files, *concurrency, logger, loadPipeline) {
+		if result.Err != nil {
+			failed++
+			logger.Error("Pipeline failed",
+				logging.F("pipeline", result.File),
+				logging.F("duration", result.Duration),
+				logging.F("error", result.Err))
+			continue
+		}
+		fields := []logging.Field{
+			logging.F("pipeline", result.File),
+			logging.F("duration", result.Duration),
+			logging.F("tsl_count", result.TSLCount),
+		}
+		logger.Info("Pipeline completed", append(fields, fetchSummary(result.Fetches)...)...)
+	}
+
+	logger.Info("run-all completed", append([]logging.Field{
+		logging.F("pipelines", len(files)),
+		logging.F("succeeded", len(files)-failed),
+		logging.F("failed", failed)},
+		xsltCacheSummary()...)...)
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// fetchSummary returns log fields summarizing the fetches of a pipeline: the
+// number of bytes fetched and the slowest upstream.
+func fetchSummary(fetches []etsi119612.FetchInfo) []logging.Field {
+	if len(fetches) == 0 {
+		return nil
+	}
+	var size int
+	slowest := fetches[0]
+	for _, fetch := range fetches {
+		size += fetch.Size
+		if fetch.Duration > slowest.Duration {
+			slowest = fetch
+		}
+	}
+	return []logging.Field{
+		logging.F("fetched_bytes", size),
+		logging.F("slowest_fetch", slowest.URL),
+		logging.F("slowest_fetch_duration", slowest.Duration),
+	}
+}
+
+// xsltCacheSummary returns log fields with the counters of the XSLT cache,
+// or none if no stylesheet was used.
+func xsltCacheSummary() []logging.Field {
+	stats := pipeline.XSLTCacheStatistics()
+	if stats.Hits == 0 && stats.Misses == 0 {
+		return nil
+	}
+	return []logging.Field{
+		logging.F("xslt_cache_hits", stats.Hits),
+		logging.F("xslt_cache_misses", stats.Misses),
+		logging.F("xslt_cache_invalidations", stats.Invalidations),
+		logging.F("xslt_cache_evictions", stats.Evictions),
+	}
+}
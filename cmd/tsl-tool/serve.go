@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/sirosfoundation/g119612/pkg/logging"
+	"github.com/sirosfoundation/g119612/pkg/pipeline"
+)
+
+// serve implements "tsl-tool serve <pipeline.yaml> [--listen addr]
+// [--interval d] [--webhook-token-file path] [--debounce d] [--min-interval d]
+// [--mirror dir]". It runs the pipeline and serves the read-only browse UI of
+// the loaded TSLs, and with --mirror the local TSL mirror in dir, until
+// interrupted, rerunning the pipeline every --interval and on authenticated
+// POST /hooks/refresh requests. Bursts of refresh requests are
+// coalesced over --debounce and runs start at least --min-interval apart.
+// A failing run is logged and the previous state is kept; if the first run
+// fails the server starts anyway, showing that nothing is loaded. It returns
+// the process exit code.
+func serve(args []string, logger logging.Logger) int {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	listen := fs.String("listen", ":8080", "Address to listen on")
+	interval := fs.Duration("interval", 0, "Rerun the pipeline at this interval (0 disables polling)")
+	tokenFile := fs.String("webhook-token-file", "", "File holding the bearer token enabling POST /hooks/refresh")
+	debounce := fs.Duration("debounce", 0, "Wait until refresh requests pause for this long before rerunning")
+	minInterval := fs.Duration("min-interval", 0, "Start reruns at least this far apart")
+	mirrorDir := fs.String("mirror", "", "Serve the TSL mirror in this directory under /mirror/")
+
+	// Accept flags before and after the pipeline argument
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return 1
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if len(positional) != 1 {
+		fmt.Fprintln(os.Stderr, "Error: serve expects exactly one pipeline YAML file argument")
+		return 1
+	}
+	if *interval < 0 {
+		fmt.Fprintf(os.Stderr, "Error: invalid --interval %s\n", *interval)
+		return 1
+	}
+	if *debounce < 0 {
+		fmt.Fprintf(os.Stderr, "Error: invalid --debounce %s\n", *debounce)
+		return 1
+	}
+	if *minInterval < 0 {
+		fmt.Fprintf(os.Stderr, "Error: invalid --min-interval %s\n", *minInterval)
+		return 1
+	}
+	var token string
+	if *tokenFile != "" {
+		data, err := os.ReadFile(*tokenFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: failed to read --webhook-token-file: %v\n", err)
+			return 1
+		}
+		if token = strings.TrimSpace(string(data)); token == "" {
+			fmt.Fprintf(os.Stderr, "Error: --webhook-token-file %s is empty\n", *tokenFile)
+			return 1
+		}
+	}
+
+	pl, err := loadPipeline(positional[0])
+	if err != nil {
+		logger.Error("Failed to load pipeline",
+			logging.F("file", positional[0]),
+			logging.F("error", err))
+		return 1
+	}
+	server := pipeline.NewServer(pl.WithLogger(logger)).
+		WithWebhookToken(token).
+		WithDebounce(*debounce).
+		WithMinInterval(*minInterval).
+		WithMirror(*mirrorDir)
+	if err := server.Run(); err == nil {
+		logger.Info("Pipeline completed",
+			logging.F("pipeline", positional[0]),
+			logging.F("tsl_count", server.Context().GetTSLCount()))
+	}
+
+	httpServer := &http.Server{
+		Addr:              *listen,
+		Handler:           server.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	watch, stopWatch := context.WithCancel(context.Background())
+	defer stopWatch()
+	go server.Watch(watch, *interval)
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	done := make(chan error, 1)
+	go func() { done <- httpServer.ListenAndServe() }()
+	logger.Info("Serving",
+		logging.F("listen", *listen),
+		logging.F("ui", pipeline.BrowsePath+"/"),
+		logging.F("interval", *interval),
+		logging.F("debounce", *debounce),
+		logging.F("min_interval", *minInterval),
+		logging.F("webhook", token != ""),
+		logging.F("mirror", *mirrorDir))
+
+	select {
+	case err := <-done:
+		logger.Error("Server failed", logging.F("error", err))
+		return 1
+	case <-stop:
+	}
+
+	shutdown, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := httpServer.Shutdown(shutdown); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Error("Server shutdown failed", logging.F("error", err))
+		return 1
+	}
+	logger.Info("Server stopped")
+	return 0
+}
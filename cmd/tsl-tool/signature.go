@@ -0,0 +1,109 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+
+	"github.com/sirosfoundation/g119612/pkg/logging"
+	"github.com/sirosfoundation/g119612/pkg/pipeline"
+)
+
+// pipelineTrust configures the verification of pipeline files and the values
+// they are expanded with. It is set from the --pipeline-signer,
+// --pipeline-signature, --production and --values options before any command
+// loads a pipeline.
+var pipelineTrust struct {
+	signers   pipeline.PipelineSigners // Trusted signers, nil if pipelines are not verified
+	signature string                   // Signature of the pipeline file, "" for <pipeline>.sig
+	values    pipeline.Values          // Values of --values, nil if none were given
+	logger    logging.Logger
+}
+
+// configurePipelineTrust sets up pipelineTrust. In production mode a signer is
+// required, so unsigned or tampered pipelines are never run.
+func configurePipelineTrust(signerFile, signatureFile string, production bool, logger logging.Logger) error {
+	pipelineTrust.logger = logger
+	if signerFile == "" {
+		if production {
+			return fmt.Errorf("--production requires --pipeline-signer")
+		}
+		if signatureFile != "" {
+			return fmt.Errorf("--pipeline-signature requires --pipeline-signer")
+		}
+		return nil
+	}
+	signers, err := pipeline.LoadPipelineSigners(signerFile)
+	if err != nil {
+		return err
+	}
+	pipelineTrust.signers = signers
+	pipelineTrust.signature = signatureFile
+	return nil
+}
+
+// loadValues loads the --values files into pipelineTrust.values. With
+// pipeline signers configured every values file must be signed as well.
+func loadValues(files []string) error {
+	if len(files) == 0 {
+		return nil
+	}
+	var err error
+	if pipelineTrust.signers == nil {
+		pipelineTrust.values, err = pipeline.LoadValues(files...)
+	} else {
+		pipelineTrust.values, err = pipeline.LoadSignedValues(pipelineTrust.signers, files...)
+	}
+	return err
+}
+
+// loadWarningBaseline loads the --warnings-baseline file. A file that does not
+// exist yet is an empty baseline with --update-warnings-baseline.
+func loadWarningBaseline(file string, update bool) error {
+	if file == "" {
+		if update {
+			return fmt.Errorf("--update-warnings-baseline needs --warnings-baseline")
+		}
+		return nil
+	}
+	var err error
+	warningBaseline, err = pipeline.LoadWarningBaseline(file)
+	if update && errors.Is(err, fs.ErrNotExist) {
+		warningBaseline, err = &pipeline.WarningBaseline{}, nil
+	}
+	return err
+}
+
+// loadPipeline loads a pipeline file, verifying its signature if pipeline
+// signers are configured and expanding it with the --values if any were
+// given. The pipeline is read-only with --read-only and has the
+// --warnings-baseline and the clock of --now.
+func loadPipeline(file string) (*pipeline.Pipeline, error) {
+	if pipelineTrust.signers == nil {
+		var pl *pipeline.Pipeline
+		var err error
+		if pipelineTrust.values != nil {
+			pl, err = pipeline.NewPipelineWithValues(file, pipelineTrust.values)
+		} else {
+			pl, err = pipeline.NewPipeline(file)
+		}
+		if err != nil {
+			return nil, err
+		}
+		pl.ReadOnly = readOnly
+		pl.Baseline = warningBaseline
+		pl.Clock = clock
+		return pl, nil
+	}
+	pl, err := pipeline.NewSignedPipelineWithValues(file, pipelineTrust.signature, pipelineTrust.signers, pipelineTrust.values)
+	if err != nil {
+		return nil, err
+	}
+	if pipelineTrust.logger != nil {
+		pipelineTrust.logger.Info("Verified pipeline signature", logging.F("pipeline", file))
+	}
+	pl.ReadOnly = readOnly
+	pl.Baseline = warningBaseline
+	pl.Clock = clock
+	return pl, nil
+}
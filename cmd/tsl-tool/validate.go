@@ -0,0 +1,41 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+// validatePipelines implements "tsl-tool validate-pipeline <pipeline.yaml>...".
+// It loads every pipeline, which validates the step arguments and the step
+// order (see pipeline.Pipeline.Validate), without running any step, prints
+// the problems found to stdout and returns the process exit code, 1 if any
+// pipeline fails to load. Warnings alone do not fail the command.
+func validatePipelines(args []string) int {
+	fs := flag.NewFlagSet("validate-pipeline", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return 1
+	}
+	if fs.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "Error: validate-pipeline expects at least one pipeline YAML file argument")
+		return 1
+	}
+
+	exitCode := 0
+	for _, file := range fs.Args() {
+		pl, err := loadPipeline(file)
+		if err != nil {
+			fmt.Printf("%s: error: %v\n", file, err)
+			exitCode = 1
+			continue
+		}
+		issues := pl.Check()
+		for _, issue := range issues {
+			fmt.Printf("%s: %s\n", file, issue)
+		}
+		if len(issues) == 0 {
+			fmt.Printf("%s: ok\n", file)
+		}
+	}
+	return exitCode
+}
@@ -0,0 +1,17 @@
+package main
+
+import "strings"
+
+// stringList implements flag.Value so an option such as --values can be repeated.
+type stringList []string
+
+// String implements flag.Value.
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+// Set implements flag.Value by appending one value.
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
@@ -0,0 +1,107 @@
+// Package examples holds the example pipelines of the repository and the
+// sample TSLs they load, together with a small HTTP server publishing the
+// samples, so that the documented examples run end to end without network
+// access. "tsl-tool example-server" runs the server.
+//
+// The samples are a list of lists, lotl.xml, pointing to two trusted lists of
+// the fictitious territories XA and XB with a few qualified CAs and a
+// time-stamping authority. leaf.pem is a certificate issued by the qualified
+// CA of XA, for trying "tsl-tool chain". None of the lists are signed.
+//
+// The pipelines are Go templates expanded with a values file (see
+// pipeline.Values): .Values.server is the base URL of the example server and
+// .Values.output the directory the pipelines write to. values.yaml has the
+// defaults for a server started with DefaultAddr.
+package examples
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+)
+
+// DefaultAddr is the address the example server listens on by default.
+const DefaultAddr = "localhost:8090"
+
+// SampleBaseURL is the location of the sample TSLs as written in the files.
+// The server replaces it by its own URL, so the pointers between the samples
+// lead back to it whatever address it listens on.
+const SampleBaseURL = "http://" + DefaultAddr
+
+//go:embed tsl/*.xml
+var samples embed.FS
+
+//go:embed *.yaml leaf.pem
+var files embed.FS
+
+// SampleTSLs returns the names of the sample TSLs, such as "lotl.xml", sorted.
+func SampleTSLs() []string {
+	entries, _ := fs.ReadDir(samples, "tsl")
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	slices.Sort(names)
+	return names
+}
+
+// SampleTSL returns a sample TSL as published by a server at baseURL.
+//
+// Parameters:
+//   - name: The name of the sample, one of SampleTSLs
+//   - baseURL: The URL of the server without a trailing slash, e.g. "http://localhost:8090"
+//
+// Returns:
+//   - []byte: The TSL document
+//   - error: Non-nil if there is no such sample
+func SampleTSL(name, baseURL string) ([]byte, error) {
+	data, err := samples.ReadFile(path.Join("tsl", path.Base(name)))
+	if err != nil {
+		return nil, fmt.Errorf("no sample TSL %q", name)
+	}
+	return bytes.ReplaceAll(data, []byte(SampleBaseURL), []byte(strings.TrimSuffix(baseURL, "/"))), nil
+}
+
+// Pipelines returns the names of the example pipelines, such as
+// "select-and-publish.yaml", sorted. values.yaml is not a pipeline and is
+// not included.
+func Pipelines() []string {
+	matches, _ := fs.Glob(files, "*.yaml")
+	return slices.DeleteFunc(matches, func(name string) bool { return name == "values.yaml" })
+}
+
+// File returns an example pipeline, values.yaml or leaf.pem.
+func File(name string) ([]byte, error) {
+	return files.ReadFile(name)
+}
+
+// Handler returns the http.Handler of the example server. It serves each
+// sample TSL at "/<name>", such as /lotl.xml, with the media type of TSLs,
+// and an index of the samples at "/". The base URL of the samples is taken
+// from the Host of each request, so the handler works behind any address;
+// it does not serve HTTPS.
+func Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprintf(w, "Sample TSLs of the g119612 examples:\n\n")
+		for _, name := range SampleTSLs() {
+			fmt.Fprintf(w, "  http://%s/%s\n", r.Host, name)
+		}
+	})
+	mux.HandleFunc("GET /{name}", func(w http.ResponseWriter, r *http.Request) {
+		data, err := SampleTSL(r.PathValue("name"), "http://"+r.Host)
+		if err != nil || path.Ext(r.PathValue("name")) != ".xml" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/vnd.etsi.tsl+xml")
+		_, _ = w.Write(data)
+	})
+	return mux
+}
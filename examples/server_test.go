@@ -0,0 +1,115 @@
+package examples
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirosfoundation/g119612/pkg/etsi119612"
+	"github.com/sirosfoundation/g119612/pkg/logging"
+	"github.com/sirosfoundation/g119612/pkg/pipeline"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSampleTSLs(t *testing.T) {
+	assert.Equal(t, []string{"lotl.xml", "xa.xml", "xb.xml"}, SampleTSLs())
+	for _, name := range SampleTSLs() {
+		data, err := SampleTSL(name, "http://127.0.0.1:1234/")
+		require.NoError(t, err)
+		assert.NotContains(t, string(data), SampleBaseURL)
+		tsl, err := etsi119612.ParseTSL(data, name, etsi119612.TSLFetchOptions{Strict: true})
+		require.NoError(t, err, name)
+		assert.Contains(t, tsl.StatusList.TslSchemeInformation.TslDistributionPoints.URI, "http://127.0.0.1:1234/"+name)
+	}
+	_, err := SampleTSL("missing.xml", SampleBaseURL)
+	assert.Error(t, err)
+}
+
+func TestHandler(t *testing.T) {
+	server := httptest.NewServer(Handler())
+	defer server.Close()
+	get := func(path string) (int, string, string) {
+		resp, err := http.Get(server.URL + path)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, resp.Header.Get("Content-Type"), string(body)
+	}
+
+	status, _, body := get("/")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, server.URL+"/lotl.xml")
+
+	status, contentType, body := get("/lotl.xml")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "application/vnd.etsi.tsl+xml", contentType)
+	assert.Contains(t, body, "<TSLLocation>"+server.URL+"/xa.xml</TSLLocation>")
+
+	for _, path := range []string{"/missing.xml", "/values.yaml", "/tsl/lotl.xml"} {
+		status, _, _ = get(path)
+		assert.Equal(t, http.StatusNotFound, status, path)
+	}
+}
+
+func TestPipelines(t *testing.T) {
+	server := httptest.NewServer(Handler())
+	defer server.Close()
+	leafPEM, err := File("leaf.pem")
+	require.NoError(t, err)
+	block, _ := pem.Decode(leafPEM)
+	require.NotNil(t, block)
+	leaf, err := x509.ParseCertificate(block.Bytes)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"mirror.yaml", "render-html.yaml", "select-and-publish.yaml"}, Pipelines())
+	_, err = File("values.yaml")
+	assert.NoError(t, err)
+
+	run := func(t *testing.T, name string) (*pipeline.Context, string) {
+		output := t.TempDir()
+		pl, err := pipeline.NewPipelineWithValues(name, pipeline.Values{"server": server.URL, "output": output})
+		require.NoError(t, err)
+		ctx, err := pl.WithLogger(logging.SilentLogger()).Process(pipeline.NewContext())
+		require.NoError(t, err)
+		assert.Equal(t, 3, ctx.GetTSLCount(), "the list of lists and both trusted lists are loaded")
+		return ctx, output
+	}
+
+	t.Run("Select_And_Publish", func(t *testing.T) {
+		ctx, output := run(t, "select-and-publish.yaml")
+		assert.Equal(t, []string{"qc", "tsa"}, pipeline.PoolNames(ctx))
+		assert.Len(t, pipeline.PoolCertificates(ctx), 2, "the granted qualified CAs of XA and XB")
+		_, err := ctx.Verify(leaf)
+		assert.NoError(t, err)
+		tsa, err := pipeline.UsePool(ctx, "tsa")
+		require.NoError(t, err)
+		assert.Len(t, pipeline.PoolCertificates(tsa), 1)
+
+		published, err := filepath.Glob(filepath.Join(output, "xml", "*.xml"))
+		require.NoError(t, err)
+		assert.Len(t, published, 3)
+	})
+
+	t.Run("Render_HTML", func(t *testing.T) {
+		_, output := run(t, "render-html.yaml")
+		assert.FileExists(t, filepath.Join(output, "html", "index.html"))
+		pages, err := filepath.Glob(filepath.Join(output, "html", "*.html"))
+		require.NoError(t, err)
+		assert.Len(t, pages, 4, "three TSL pages and the index")
+	})
+
+	t.Run("Mirror", func(t *testing.T) {
+		_, output := run(t, "mirror.yaml")
+		entries, err := os.ReadDir(filepath.Join(output, "mirror"))
+		require.NoError(t, err)
+		assert.NotEmpty(t, entries)
+		assert.FileExists(t, filepath.Join(output, "mirror", "manifest.json"))
+	})
+}
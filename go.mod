@@ -7,7 +7,6 @@ require (
 	github.com/ThalesGroup/crypto11 v1.4.1
 	github.com/beevik/etree v1.5.1
 	github.com/h2non/gock v1.2.0
-	github.com/moov-io/signedxml v1.2.3
 	github.com/russellhaering/goxmldsig v1.5.0
 	github.com/sirupsen/logrus v1.9.3
 	github.com/stretchr/testify v1.11.1
@@ -27,6 +26,4 @@ require (
 	golang.org/x/sys v0.32.0 // indirect
 )
 
-replace github.com/moov-io/signedxml v1.2.3 => github.com/leifj/signedxml v1.2.3-leifj6
-
 replace github.com/russellhaering/goxmldsig v1.5.0 => github.com/sirosfoundation/goxmldsig v1.5.0-leifj1
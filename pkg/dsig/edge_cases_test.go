@@ -166,20 +166,12 @@ func TestSignXML_InvalidXML(t *testing.T) {
 	// This test verifies that malformed XML is handled properly
 	// Note: We need a real signer, but we can test with invalid XML first
 
-	// Create temporary cert and key files using openssl if available
-	if _, err := os.Stat("/usr/bin/openssl"); os.IsNotExist(err) {
-		t.Skip("openssl not available")
-	}
-
 	tmpDir := t.TempDir()
 	certFile := filepath.Join(tmpDir, "test.crt")
 	keyFile := filepath.Join(tmpDir, "test.key")
 
 	// Generate test certificate and key
-	cmd := "cd " + tmpDir + " && openssl req -x509 -newkey rsa:2048 -keyout test.key -out test.crt -days 365 -nodes -subj '/CN=Test'"
-	if err := os.WriteFile(filepath.Join(tmpDir, "gen.sh"), []byte(cmd), 0755); err != nil {
-		t.Fatal(err)
-	}
+	writeTestKeyPair(t, certFile, keyFile)
 
 	// We'll just test the file signer with invalid XML by creating corrupted input
 	fs := &FileSigner{
@@ -192,10 +184,7 @@ func TestSignXML_InvalidXML(t *testing.T) {
 	_, err := fs.Sign(invalidXML)
 	// Should get an error somewhere in the signing process
 	// The exact error depends on where the XML parser fails
-	if err != nil {
-		// Error is expected for invalid XML - test passes
-		assert.Error(t, err)
-	}
+	assert.Error(t, err)
 }
 
 // TestToXMLDSigSigner_InvalidKeyFormat tests converting non-RSA key
@@ -6,6 +6,7 @@ import (
 	"crypto/x509"
 	"encoding/pem"
 	"fmt"
+	"io"
 	"os"
 
 	xmldsig "github.com/russellhaering/goxmldsig"
@@ -20,6 +21,14 @@ type FileSigner struct {
 
 	// KeyFile is the path to the private key file in PEM format (PKCS#1 or PKCS#8)
 	KeyFile string
+
+	// StrictKeyPermissions makes signing fail when KeyFile is readable or
+	// writable by group or others (see CheckKeyFilePermissions)
+	StrictKeyPermissions bool
+
+	// XAdES, if not nil, makes the signatures XAdES signatures with these
+	// qualifying properties (see XAdESOptions)
+	XAdES *XAdESOptions
 }
 
 // NewFileSigner creates a new FileSigner from certificate and key file paths.
@@ -42,7 +51,8 @@ func NewFileSigner(certFile, keyFile string) *FileSigner {
 // This method loads the certificate and private key from files,
 // creates an XML digital signature, and returns the signed XML document.
 //
-// The method supports both PKCS#1 and PKCS#8 formatted private keys.
+// The method supports both PKCS#1 and PKCS#8 formatted private keys. With
+// XAdES set, the document is signed with SignXMLWithXAdES.
 //
 // Parameters:
 //   - xmlData: Raw XML bytes to sign
@@ -51,15 +61,23 @@ func NewFileSigner(certFile, keyFile string) *FileSigner {
 //   - The signed XML document as bytes
 //   - An error if reading files, parsing certificates/keys, or signing fails
 func (fs *FileSigner) Sign(xmlData []byte) ([]byte, error) {
+	if fs.XAdES != nil {
+		signer, err := fs.ToXMLDSigSigner()
+		if err != nil {
+			return nil, err
+		}
+		return SignXMLWithXAdES(xmlData, signer, fs.XAdES)
+	}
+
 	// Load the certificate and private key
 	certData, err := os.ReadFile(fs.CertFile)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read certificate file: %w", err)
 	}
 
-	keyData, err := os.ReadFile(fs.KeyFile)
+	keyData, err := fs.readKeyFile()
 	if err != nil {
-		return nil, fmt.Errorf("failed to read key file: %w", err)
+		return nil, err
 	}
 
 	// Parse the certificate
@@ -108,6 +126,40 @@ func (fs *FileSigner) Sign(xmlData []byte) ([]byte, error) {
 	return SignXMLWithKeyStore(xmlData, keyStore)
 }
 
+// SignStream implements StreamSigner using certificate and key files.
+// It signs the document read from r with SignXMLStream, or SignXMLStreamWithXAdES
+// if XAdES is set, and writes the result to w, without loading the document into
+// memory.
+//
+// Parameters:
+//   - w: Destination for the signed document
+//   - r: Source of the XML document to sign
+//
+// Returns:
+//   - An error if reading files, parsing certificates/keys, or signing fails
+func (fs *FileSigner) SignStream(w io.Writer, r io.ReadSeeker) error {
+	signer, err := fs.ToXMLDSigSigner()
+	if err != nil {
+		return err
+	}
+	return SignXMLStreamWithXAdES(w, r, signer, fs.XAdES)
+}
+
+// readKeyFile reads the private key file, enforcing owner-only permissions
+// when StrictKeyPermissions is set.
+func (fs *FileSigner) readKeyFile() ([]byte, error) {
+	if fs.StrictKeyPermissions {
+		if err := CheckKeyFilePermissions(fs.KeyFile); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	keyData, err := os.ReadFile(fs.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+	return keyData, nil
+}
+
 // fileKeyStore implements the xmldsig.X509KeyStore interface.
 // It provides access to an in-memory certificate and private key
 // for XML digital signature operations.
@@ -144,9 +196,9 @@ func (fs *FileSigner) ToXMLDSigSigner() (xmldsig.Signer, error) {
 		return nil, fmt.Errorf("failed to read certificate file: %w", err)
 	}
 
-	keyData, err := os.ReadFile(fs.KeyFile)
+	keyData, err := fs.readKeyFile()
 	if err != nil {
-		return nil, fmt.Errorf("failed to read key file: %w", err)
+		return nil, err
 	}
 
 	// Parse the certificate
@@ -1,43 +1,20 @@
 package dsig
 
 import (
+	"crypto/x509"
+	"encoding/pem"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"testing"
 )
 
 func TestFileSigner(t *testing.T) {
-	// Skip test if we're in CI
-	if os.Getenv("CI") == "true" {
-		t.Skip("Skipping FileSigner test in CI environment")
-	}
-
-	// Check if we can run openssl to generate a test certificate
-	if _, err := exec.LookPath("openssl"); err != nil {
-		t.Skip("Skipping test: openssl not available")
-	}
-
-	// Create temp test directory
-	tmpDir, err := os.MkdirTemp("", "dsig-test-*")
-	if err != nil {
-		t.Fatalf("Failed to create temp directory: %v", err)
-	}
-	defer os.RemoveAll(tmpDir)
+	tmpDir := t.TempDir()
 
 	// Create test certificate and key paths
 	certPath := filepath.Join(tmpDir, "cert.pem")
 	keyPath := filepath.Join(tmpDir, "key.pem")
-
-	// Generate self-signed certificate using openssl
-	cmd := exec.Command("openssl", "req", "-x509", "-newkey", "rsa:2048",
-		"-keyout", keyPath, "-out", certPath, "-days", "1", "-nodes",
-		"-subj", "/CN=Test Certificate")
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		t.Skipf("Failed to generate test certificate: %v, output: %s", err, output)
-		return
-	}
+	writeTestKeyPair(t, certPath, keyPath)
 
 	// Create FileSigner
 	signer := NewFileSigner(certPath, keyPath)
@@ -58,36 +35,24 @@ func TestFileSigner(t *testing.T) {
 }
 
 func TestToXMLDSigSigner(t *testing.T) {
-	// Skip test if we're in CI
-	if os.Getenv("CI") == "true" {
-		t.Skip("Skipping ToXMLDSigSigner test in CI environment")
-	}
-
-	// Check if we can run openssl to generate a test certificate
-	if _, err := exec.LookPath("openssl"); err != nil {
-		t.Skip("Skipping test: openssl not available")
-	}
-
-	// Create temp test directory
-	tmpDir, err := os.MkdirTemp("", "dsig-test-*")
-	if err != nil {
-		t.Fatalf("Failed to create temp directory: %v", err)
-	}
-	defer os.RemoveAll(tmpDir)
+	tmpDir := t.TempDir()
 
 	t.Run("Success with PKCS1 key", func(t *testing.T) {
 		// Create test certificate and key paths
 		certPath := filepath.Join(tmpDir, "cert.pem")
 		keyPath := filepath.Join(tmpDir, "key.pem")
 
-		// Generate self-signed certificate using openssl (default PKCS1)
-		cmd := exec.Command("openssl", "req", "-x509", "-newkey", "rsa:2048",
-			"-keyout", keyPath, "-out", certPath, "-days", "1", "-nodes",
-			"-subj", "/CN=Test Certificate")
-		output, err := cmd.CombinedOutput()
+		// Write the key in PKCS1 format
+		selfSigned, err := GenerateSelfSignedSigner(SelfSignedOptions{CommonName: "Test Certificate"})
 		if err != nil {
-			t.Skipf("Failed to generate test certificate: %v, output: %s", err, output)
-			return
+			t.Fatalf("Failed to generate test certificate: %v", err)
+		}
+		keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(selfSigned.Key)})
+		if err := os.WriteFile(certPath, selfSigned.CertificatePEM(), 0644); err != nil {
+			t.Fatalf("Failed to write certificate: %v", err)
+		}
+		if err := os.WriteFile(keyPath, keyPEM, 0600); err != nil {
+			t.Fatalf("Failed to write key: %v", err)
 		}
 
 		// Create FileSigner
@@ -107,27 +72,10 @@ func TestToXMLDSigSigner(t *testing.T) {
 	t.Run("Success with PKCS8 key", func(t *testing.T) {
 		// Create test certificate and key paths
 		certPath := filepath.Join(tmpDir, "cert_pkcs8.pem")
-		keyPath := filepath.Join(tmpDir, "key_pkcs1.pem")
 		keyPath8 := filepath.Join(tmpDir, "key_pkcs8.pem")
 
-		// Generate self-signed certificate using openssl (default PKCS1)
-		cmd := exec.Command("openssl", "req", "-x509", "-newkey", "rsa:2048",
-			"-keyout", keyPath, "-out", certPath, "-days", "1", "-nodes",
-			"-subj", "/CN=Test Certificate PKCS8")
-		output, err := cmd.CombinedOutput()
-		if err != nil {
-			t.Skipf("Failed to generate test certificate: %v, output: %s", err, output)
-			return
-		}
-
-		// Convert key to PKCS8 format
-		cmd = exec.Command("openssl", "pkcs8", "-topk8", "-nocrypt",
-			"-in", keyPath, "-out", keyPath8)
-		output, err = cmd.CombinedOutput()
-		if err != nil {
-			t.Skipf("Failed to convert key to PKCS8: %v, output: %s", err, output)
-			return
-		}
+		// WritePEM writes the key in PKCS8 format
+		writeTestKeyPair(t, certPath, keyPath8)
 
 		// Create FileSigner with PKCS8 key
 		signer := NewFileSigner(certPath, keyPath8)
@@ -158,21 +106,14 @@ func TestToXMLDSigSigner(t *testing.T) {
 		keyPath := filepath.Join(tmpDir, "key_temp.pem")
 
 		// Generate certificate
-		cmd := exec.Command("openssl", "req", "-x509", "-newkey", "rsa:2048",
-			"-keyout", keyPath, "-out", certPath, "-days", "1", "-nodes",
-			"-subj", "/CN=Test Certificate")
-		output, err := cmd.CombinedOutput()
-		if err != nil {
-			t.Skipf("Failed to generate test certificate: %v, output: %s", err, output)
-			return
-		}
+		writeTestKeyPair(t, certPath, keyPath)
 
 		// Remove key file
 		os.Remove(keyPath)
 
 		signer := NewFileSigner(certPath, keyPath)
 
-		_, err = signer.ToXMLDSigSigner()
+		_, err := signer.ToXMLDSigSigner()
 		if err == nil {
 			t.Fatal("ToXMLDSigSigner() should fail with missing key file")
 		}
@@ -183,22 +124,15 @@ func TestToXMLDSigSigner(t *testing.T) {
 		certPath := filepath.Join(tmpDir, "invalid_cert.pem")
 		keyPath := filepath.Join(tmpDir, "valid_key.pem")
 
-		// Write invalid cert
+		// Generate valid key, then overwrite the certificate
+		writeTestKeyPair(t, certPath, keyPath)
 		if err := os.WriteFile(certPath, []byte("not a PEM file"), 0600); err != nil {
 			t.Fatalf("Failed to write invalid cert: %v", err)
 		}
 
-		// Generate valid key
-		cmd := exec.Command("openssl", "genrsa", "-out", keyPath, "2048")
-		output, err := cmd.CombinedOutput()
-		if err != nil {
-			t.Skipf("Failed to generate test key: %v, output: %s", err, output)
-			return
-		}
-
 		signer := NewFileSigner(certPath, keyPath)
 
-		_, err = signer.ToXMLDSigSigner()
+		_, err := signer.ToXMLDSigSigner()
 		if err == nil {
 			t.Fatal("ToXMLDSigSigner() should fail with invalid certificate PEM")
 		}
@@ -211,14 +145,7 @@ func TestToXMLDSigSigner(t *testing.T) {
 		invalidKeyPath := filepath.Join(tmpDir, "invalid_key.pem")
 
 		// Generate valid certificate
-		cmd := exec.Command("openssl", "req", "-x509", "-newkey", "rsa:2048",
-			"-keyout", keyPath, "-out", certPath, "-days", "1", "-nodes",
-			"-subj", "/CN=Test Certificate")
-		output, err := cmd.CombinedOutput()
-		if err != nil {
-			t.Skipf("Failed to generate test certificate: %v, output: %s", err, output)
-			return
-		}
+		writeTestKeyPair(t, certPath, keyPath)
 
 		// Write invalid key
 		if err := os.WriteFile(invalidKeyPath, []byte("not a PEM file"), 0600); err != nil {
@@ -227,7 +154,7 @@ func TestToXMLDSigSigner(t *testing.T) {
 
 		signer := NewFileSigner(certPath, invalidKeyPath)
 
-		_, err = signer.ToXMLDSigSigner()
+		_, err := signer.ToXMLDSigSigner()
 		if err == nil {
 			t.Fatal("ToXMLDSigSigner() should fail with invalid key PEM")
 		}
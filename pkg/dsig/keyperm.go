@@ -0,0 +1,37 @@
+package dsig
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+)
+
+// ErrInsecureKeyPermissions is returned when a private key file can be read
+// or written by users other than its owner.
+var ErrInsecureKeyPermissions = errors.New("private key file is accessible by group or others")
+
+// CheckKeyFilePermissions verifies that a private key file is only accessible
+// by its owner (mode 0600 or stricter). The check is skipped on Windows where
+// POSIX permission bits are not meaningful.
+//
+// Parameters:
+//   - path: Path to the private key file
+//
+// Returns:
+//   - nil if the file permissions are acceptable
+//   - An error wrapping ErrInsecureKeyPermissions if group or other bits are set
+//   - The underlying error if the file cannot be inspected
+func CheckKeyFilePermissions(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	if perm := info.Mode().Perm(); perm&0077 != 0 {
+		return fmt.Errorf("%w: %s has mode %04o, expected 0600", ErrInsecureKeyPermissions, path, perm)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package dsig
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestCheckKeyFilePermissions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("POSIX permissions are not enforced on Windows")
+	}
+
+	dir := t.TempDir()
+
+	t.Run("Owner only", func(t *testing.T) {
+		path := filepath.Join(dir, "private.pem")
+		if err := os.WriteFile(path, []byte("key"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := CheckKeyFilePermissions(path); err != nil {
+			t.Errorf("expected no error for 0600 key, got %v", err)
+		}
+	})
+
+	t.Run("World readable", func(t *testing.T) {
+		path := filepath.Join(dir, "public.pem")
+		if err := os.WriteFile(path, []byte("key"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chmod(path, 0644); err != nil {
+			t.Fatal(err)
+		}
+		err := CheckKeyFilePermissions(path)
+		if !errors.Is(err, ErrInsecureKeyPermissions) {
+			t.Errorf("expected ErrInsecureKeyPermissions, got %v", err)
+		}
+	})
+
+	t.Run("Missing file", func(t *testing.T) {
+		err := CheckKeyFilePermissions(filepath.Join(dir, "missing.pem"))
+		if !os.IsNotExist(err) {
+			t.Errorf("expected not-exist error, got %v", err)
+		}
+	})
+}
+
+func TestFileSignerStrictKeyPermissions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("POSIX permissions are not enforced on Windows")
+	}
+
+	dir := t.TempDir()
+	keyPath := filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(keyPath, []byte("key"), 0640); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(keyPath, 0640); err != nil {
+		t.Fatal(err)
+	}
+
+	signer := NewFileSigner(filepath.Join(dir, "cert.pem"), keyPath)
+	signer.StrictKeyPermissions = true
+	if _, err := signer.readKeyFile(); !errors.Is(err, ErrInsecureKeyPermissions) {
+		t.Errorf("expected ErrInsecureKeyPermissions, got %v", err)
+	}
+
+	signer.StrictKeyPermissions = false
+	if _, err := signer.readKeyFile(); err != nil {
+		t.Errorf("expected key to be readable without strict mode, got %v", err)
+	}
+}
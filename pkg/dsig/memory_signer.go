@@ -0,0 +1,60 @@
+package dsig
+
+import (
+	"io"
+	"sync"
+)
+
+// MemorySigner signs with an RSA key and self-signed certificate generated in
+// memory on first use, see GenerateSelfSignedSigner. The signatures are real
+// and verify against the certificate in KeyInfo, but the key is lost when the
+// process exits, so nothing it signs can be trusted by relying parties.
+//
+// It lets dry runs and CI pipelines produce verifiable output without key
+// files. It implements XMLSigner and StreamSigner.
+type MemorySigner struct {
+	// Options configure the generated certificate; the zero value gives a
+	// certificate for "Memory Signer" valid for one day.
+	Options SelfSignedOptions
+
+	once   sync.Once
+	signer *SelfSignedSigner
+	err    error
+}
+
+// NewMemorySigner returns a MemorySigner with the default options. The key is
+// generated when the first document is signed.
+func NewMemorySigner() *MemorySigner {
+	return &MemorySigner{}
+}
+
+// SelfSigned returns the generated key and certificate, generating them if
+// nothing was signed yet.
+func (m *MemorySigner) SelfSigned() (*SelfSignedSigner, error) {
+	m.once.Do(func() {
+		opts := m.Options
+		if opts.CommonName == "" {
+			opts.CommonName = "Memory Signer"
+		}
+		m.signer, m.err = GenerateSelfSignedSigner(opts)
+	})
+	return m.signer, m.err
+}
+
+// Sign implements XMLSigner.Sign with the in-memory key.
+func (m *MemorySigner) Sign(xmlData []byte) ([]byte, error) {
+	signer, err := m.SelfSigned()
+	if err != nil {
+		return nil, err
+	}
+	return signer.Sign(xmlData)
+}
+
+// SignStream implements StreamSigner with the in-memory key.
+func (m *MemorySigner) SignStream(w io.Writer, r io.ReadSeeker) error {
+	signer, err := m.SelfSigned()
+	if err != nil {
+		return err
+	}
+	return signer.SignStream(w, r)
+}
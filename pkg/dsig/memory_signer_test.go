@@ -0,0 +1,47 @@
+package dsig
+
+import (
+	"bytes"
+	"crypto/x509"
+	"strings"
+	"testing"
+
+	"github.com/beevik/etree"
+	xmldsig "github.com/russellhaering/goxmldsig"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemorySigner(t *testing.T) {
+	signer := NewMemorySigner()
+	var _ XMLSigner = signer
+	var _ StreamSigner = signer
+
+	signed, err := signer.Sign([]byte(`<root Id="r"><child>text</child></root>`))
+	require.NoError(t, err)
+	var out bytes.Buffer
+	require.NoError(t, signer.SignStream(&out, strings.NewReader(`<root><child>text</child></root>`)))
+
+	// Both signatures use the same key, generated once
+	selfSigned, err := signer.SelfSigned()
+	require.NoError(t, err)
+	again, err := signer.SelfSigned()
+	require.NoError(t, err)
+	assert.Same(t, selfSigned, again)
+	assert.Equal(t, "Memory Signer", selfSigned.Certificate.Subject.CommonName)
+
+	validator := xmldsig.NewDefaultValidationContext(&xmldsig.MemoryX509CertificateStore{
+		Roots: []*x509.Certificate{selfSigned.Certificate},
+	})
+	for _, data := range [][]byte{signed, out.Bytes()} {
+		doc := etree.NewDocument()
+		require.NoError(t, doc.ReadFromBytes(data))
+		_, err := validator.Validate(doc.Root())
+		assert.NoError(t, err)
+	}
+
+	named := &MemorySigner{Options: SelfSignedOptions{CommonName: "Dry Run", KeyBits: 1024}}
+	selfSigned, err = named.SelfSigned()
+	require.NoError(t, err)
+	assert.Equal(t, "Dry Run", selfSigned.Certificate.Subject.CommonName)
+}
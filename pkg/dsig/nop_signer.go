@@ -0,0 +1,44 @@
+package dsig
+
+import (
+	"crypto"
+	"io"
+
+	xmldsig "github.com/russellhaering/goxmldsig"
+)
+
+// NopSigner inserts the structure of an enveloped XML-DSIG signature without
+// using a key. The Signature element has the same SignedInfo, references and
+// digest as a real signature, but the SignatureValue and X509Certificate
+// elements are empty, so verification of the result always fails.
+//
+// It lets dry runs and CI pipelines exercise the complete signing and
+// publishing path, including the placement of the signature, where no key is
+// available. It implements XMLSigner and StreamSigner.
+type NopSigner struct{}
+
+// Sign implements XMLSigner.Sign, adding an empty signature.
+func (NopSigner) Sign(xmlData []byte) ([]byte, error) {
+	return SignXML(xmlData, nopXMLDSigSigner{})
+}
+
+// SignStream implements StreamSigner, adding an empty signature.
+func (NopSigner) SignStream(w io.Writer, r io.ReadSeeker) error {
+	return SignXMLStream(w, r, nopXMLDSigSigner{})
+}
+
+// nopXMLDSigSigner is an xmldsig.Signer returning empty signatures and an
+// empty certificate.
+type nopXMLDSigSigner struct{}
+
+func (nopXMLDSigSigner) Sign(io.Reader, []byte, crypto.SignerOpts) ([]byte, error) {
+	return []byte{}, nil
+}
+
+func (nopXMLDSigSigner) Algorithm() xmldsig.SignatureAlgorithm {
+	return xmldsig.RSASHA256SignatureMethod
+}
+
+func (nopXMLDSigSigner) GetCertificate() ([]byte, error) {
+	return []byte{}, nil
+}
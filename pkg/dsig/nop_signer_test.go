@@ -0,0 +1,50 @@
+package dsig
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/beevik/etree"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNopSigner(t *testing.T) {
+	var _ XMLSigner = NopSigner{}
+	var _ StreamSigner = NopSigner{}
+
+	input := `<root Id="r"><child>text</child></root>`
+	real, err := GenerateSelfSignedSigner(SelfSignedOptions{})
+	require.NoError(t, err)
+	realSigned, err := real.Sign([]byte(input))
+	require.NoError(t, err)
+	realDoc := etree.NewDocument()
+	require.NoError(t, realDoc.ReadFromBytes(realSigned))
+
+	check := func(signed []byte) {
+		t.Helper()
+		doc := etree.NewDocument()
+		require.NoError(t, doc.ReadFromBytes(signed))
+		sig := doc.FindElement("/root/Signature")
+		require.NotNil(t, sig, "the signature is appended to the root element")
+		assert.Equal(t, "", sig.FindElement("SignatureValue").Text())
+		assert.Equal(t, "", sig.FindElement("KeyInfo/X509Data/X509Certificate").Text())
+		assert.Equal(t, realDoc.FindElement("//Reference").SelectAttrValue("URI", ""),
+			sig.FindElement("SignedInfo/Reference").SelectAttrValue("URI", ""))
+		assert.Equal(t, realDoc.FindElement("//DigestValue").Text(), sig.FindElement("SignedInfo/Reference/DigestValue").Text(),
+			"the digest is that of a real signature")
+		assert.Equal(t, "text", doc.FindElement("/root/child").Text())
+	}
+
+	signed, err := NopSigner{}.Sign([]byte(input))
+	require.NoError(t, err)
+	check(signed)
+
+	var out bytes.Buffer
+	require.NoError(t, NopSigner{}.SignStream(&out, strings.NewReader(input)))
+	check(out.Bytes())
+
+	_, err = NopSigner{}.Sign([]byte(`<!DOCTYPE root><root/>`))
+	assert.Error(t, err, "documents are checked like for real signatures")
+}
@@ -0,0 +1,104 @@
+//go:build pkcs11
+
+package dsig
+
+import (
+	"crypto"
+	"fmt"
+
+	"github.com/ThalesGroup/crypto11"
+	xmldsig "github.com/russellhaering/goxmldsig"
+)
+
+// PKCS11Supported reports whether PKCS11Signer can access tokens, which
+// requires building with the "pkcs11" build tag and cgo.
+const PKCS11Supported = true
+
+// pkcs11Context is the crypto11 context of an initialized PKCS11Signer.
+type pkcs11Context = *crypto11.Context
+
+// initialize ensures the PKCS#11 context is created.
+// This method lazy-loads the PKCS#11 module and initializes the connection
+// to the HSM on first use. It caches the context for subsequent operations.
+//
+// Returns:
+//   - An error if the PKCS#11 context could not be configured
+func (ps *PKCS11Signer) initialize() error {
+	if ps.initialized {
+		return nil
+	}
+	if ps.Config == nil {
+		return fmt.Errorf("failed to configure PKCS#11 context: no configuration")
+	}
+
+	config := &crypto11.Config{
+		Path:       ps.Config.Path,
+		Pin:        ps.Config.Pin,
+		TokenLabel: ps.Config.TokenLabel,
+		SlotNumber: ps.Config.SlotNumber,
+	}
+	context, err := crypto11.Configure(config)
+	if err != nil {
+		return fmt.Errorf("failed to configure PKCS#11 context: %w", err)
+	}
+
+	ps.context = context
+	ps.initialized = true
+	return nil
+}
+
+// Close cleans up any resources associated with the signer.
+// This method prepares the signer for garbage collection by resetting
+// its internal state. Note that the crypto11 context doesn't currently
+// have an explicit Close method, but this function is provided for
+// future-proofing.
+//
+// Returns:
+//   - Always returns nil error (reserved for future implementations)
+func (ps *PKCS11Signer) Close() error {
+	if ps.context != nil {
+		// Context doesn't have a Close method in crypto11,
+		// but we can add it here for future-proofing
+		ps.initialized = false
+		ps.context = nil
+	}
+	return nil
+}
+
+// xmlDSigSigner connects to the token and returns an xmldsig.Signer for the
+// configured key and certificate.
+func (ps *PKCS11Signer) xmlDSigSigner() (xmldsig.Signer, error) {
+	if err := ps.initialize(); err != nil {
+		return nil, err
+	}
+
+	// Convert ID to bytes
+	idBytes, err := hexToBytes(ps.keyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert key ID to bytes: %w", err)
+	}
+
+	// Get the private key by ID and label
+	// The crypto11 FindKeyPair function takes (id, label) parameters
+	privateKey, err := ps.context.FindKeyPair(idBytes, []byte(ps.keyLabel))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find private key with label '%s' and ID '%s': %w",
+			ps.keyLabel, ps.keyID, err)
+	}
+
+	// Get the certificate by ID and label
+	// The crypto11 FindCertificate function takes (id, label, serial) parameters
+	cert, err := ps.context.FindCertificate(idBytes, []byte(ps.certLabel), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find certificate with label '%s' and ID '%s': %w",
+			ps.certLabel, ps.keyID, err)
+	}
+
+	// Create a goxmldsig PKCS11Signer that implements the Signer interface
+	// Using SHA256 as the default hash algorithm
+	pkcs11Signer, err := xmldsig.NewPKCS11Signer(privateKey, cert.Raw, crypto.SHA256)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create PKCS11Signer: %w", err)
+	}
+	return pkcs11Signer, nil
+}
@@ -1,26 +1,51 @@
 package dsig
 
 import (
-	"crypto"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
 	"net/url"
 	"strconv"
 	"strings"
-
-	"github.com/ThalesGroup/crypto11"
-	xmldsig "github.com/russellhaering/goxmldsig"
 )
 
+// ErrPKCS11NotSupported is returned when signing with a PKCS11Signer in a
+// binary built without the "pkcs11" build tag. Such builds need no cgo, which
+// keeps static and cross-compiled binaries easy to produce.
+var ErrPKCS11NotSupported = errors.New("PKCS#11 support is not compiled in (rebuild with -tags pkcs11)")
+
+// PKCS11Config is the configuration of a PKCS#11 module connection.
+type PKCS11Config struct {
+	// Path is the file path of the PKCS#11 module (shared library)
+	Path string
+
+	// Pin is the user PIN of the token
+	Pin string
+
+	// TokenLabel selects the token by label
+	TokenLabel string
+
+	// SlotNumber selects the token by slot, if not nil
+	SlotNumber *int
+}
+
 // PKCS11Signer implements XMLSigner using a PKCS#11 hardware token.
 // This type provides XML digital signature functionality using keys stored in
 // Hardware Security Modules (HSMs) or other PKCS#11-compatible devices.
+//
+// The token is only accessed in binaries built with the "pkcs11" build tag
+// (see PKCS11Supported); otherwise signing fails with ErrPKCS11NotSupported.
 type PKCS11Signer struct {
 	// Config contains the PKCS#11 module configuration (path, PIN, etc.)
-	Config *crypto11.Config
+	Config *PKCS11Config
 
-	// context is the initialized crypto11 context for the PKCS#11 module
-	context *crypto11.Context
+	// XAdES, if not nil, makes the signatures XAdES signatures with these
+	// qualifying properties (see XAdESOptions)
+	XAdES *XAdESOptions
+
+	// context is the initialized context for the PKCS#11 module
+	context pkcs11Context
 
 	// keyLabel is the label used to identify the private key in the HSM
 	keyLabel string
@@ -46,7 +71,7 @@ type PKCS11Signer struct {
 //
 // Returns:
 //   - A new PKCS11Signer with default key ID "01"
-func NewPKCS11Signer(config *crypto11.Config, keyLabel, certLabel string) *PKCS11Signer {
+func NewPKCS11Signer(config *PKCS11Config, keyLabel, certLabel string) *PKCS11Signer {
 	return &PKCS11Signer{
 		Config:    config,
 		keyLabel:  keyLabel,
@@ -75,45 +100,6 @@ func NewPKCS11SignerFromURI(pkcs11URI, keyLabel, certLabel string) (*PKCS11Signe
 	return NewPKCS11Signer(config, keyLabel, certLabel), nil
 }
 
-// initialize ensures the PKCS#11 context is created.
-// This method lazy-loads the PKCS#11 module and initializes the connection
-// to the HSM on first use. It caches the context for subsequent operations.
-//
-// Returns:
-//   - An error if the PKCS#11 context could not be configured
-func (ps *PKCS11Signer) initialize() error {
-	if ps.initialized {
-		return nil
-	}
-
-	context, err := crypto11.Configure(ps.Config)
-	if err != nil {
-		return fmt.Errorf("failed to configure PKCS#11 context: %w", err)
-	}
-
-	ps.context = context
-	ps.initialized = true
-	return nil
-}
-
-// Close cleans up any resources associated with the signer.
-// This method prepares the signer for garbage collection by resetting
-// its internal state. Note that the crypto11 context doesn't currently
-// have an explicit Close method, but this function is provided for
-// future-proofing.
-//
-// Returns:
-//   - Always returns nil error (reserved for future implementations)
-func (ps *PKCS11Signer) Close() error {
-	if ps.context != nil {
-		// Context doesn't have a Close method in crypto11,
-		// but we can add it here for future-proofing
-		ps.initialized = false
-		ps.context = nil
-	}
-	return nil
-}
-
 // SetKeyID sets the ID to use for key and certificate lookups.
 // The key ID is typically a hex string (with or without '0x' prefix)
 // that identifies both the private key and certificate in the HSM.
@@ -147,7 +133,8 @@ func hexToBytes(hexStr string) ([]byte, error) {
 
 // Sign implements XMLSigner.Sign using PKCS#11 hardware token with goxmldsig's Signer interface.
 // This method connects to the HSM, retrieves the private key and certificate,
-// and uses them to create an XML digital signature.
+// and uses them to create an XML digital signature. With XAdES set, the
+// document is signed with SignXMLWithXAdES.
 //
 // Parameters:
 //   - xmlData: Raw XML bytes to sign
@@ -156,40 +143,33 @@ func hexToBytes(hexStr string) ([]byte, error) {
 //   - The signed XML document as bytes
 //   - An error if HSM connection, key/cert retrieval, or signing fails
 func (ps *PKCS11Signer) Sign(xmlData []byte) ([]byte, error) {
-	if err := ps.initialize(); err != nil {
-		return nil, err
-	}
-
-	// Convert ID to bytes
-	idBytes, err := hexToBytes(ps.keyID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to convert key ID to bytes: %w", err)
-	}
-
-	// Get the private key by ID and label
-	// The crypto11 FindKeyPair function takes (id, label) parameters
-	privateKey, err := ps.context.FindKeyPair(idBytes, []byte(ps.keyLabel))
+	signer, err := ps.xmlDSigSigner()
 	if err != nil {
-		return nil, fmt.Errorf("failed to find private key with label '%s' and ID '%s': %w",
-			ps.keyLabel, ps.keyID, err)
+		return nil, err
 	}
-
-	// Get the certificate by ID and label
-	// The crypto11 FindCertificate function takes (id, label, serial) parameters
-	cert, err := ps.context.FindCertificate(idBytes, []byte(ps.certLabel), nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to find certificate with label '%s' and ID '%s': %w",
-			ps.certLabel, ps.keyID, err)
+	if ps.XAdES != nil {
+		return SignXMLWithXAdES(xmlData, signer, ps.XAdES)
 	}
+	return SignXML(xmlData, signer)
+}
 
-	// Create a goxmldsig PKCS11Signer that implements the Signer interface
-	// Using SHA256 as the default hash algorithm
-	pkcs11Signer, err := xmldsig.NewPKCS11Signer(privateKey, cert.Raw, crypto.SHA256)
+// SignStream implements StreamSigner using the PKCS#11 token.
+// It signs the document read from r with SignXMLStream, or SignXMLStreamWithXAdES
+// if XAdES is set, and writes the result to w, without loading the document into
+// memory.
+//
+// Parameters:
+//   - w: Destination for the signed document
+//   - r: Source of the XML document to sign
+//
+// Returns:
+//   - An error if HSM connection, key/cert retrieval, or signing fails
+func (ps *PKCS11Signer) SignStream(w io.Writer, r io.ReadSeeker) error {
+	signer, err := ps.xmlDSigSigner()
 	if err != nil {
-		return nil, fmt.Errorf("failed to create PKCS11Signer: %w", err)
+		return err
 	}
-
-	return SignXML(xmlData, pkcs11Signer)
+	return SignXMLStreamWithXAdES(w, r, signer, ps.XAdES)
 }
 
 // ExtractPKCS11Config extracts a PKCS#11 configuration from a URI.
@@ -200,8 +180,8 @@ func (ps *PKCS11Signer) Sign(xmlData []byte) ([]byte, error) {
 //   - pkcs11URI: A PKCS#11 URI string in the format "pkcs11:module=/path/to/module;pin=1234;..."
 //
 // Returns:
-//   - A crypto11.Config populated with parameters from the URI, or nil if parsing fails
-func ExtractPKCS11Config(pkcs11URI string) *crypto11.Config {
+//   - A PKCS11Config populated with parameters from the URI, or nil if parsing fails
+func ExtractPKCS11Config(pkcs11URI string) *PKCS11Config {
 	// Parse the PKCS#11 URI
 	u, err := url.Parse(pkcs11URI)
 	if err != nil || u.Scheme != "pkcs11" {
@@ -218,7 +198,7 @@ func ExtractPKCS11Config(pkcs11URI string) *crypto11.Config {
 	// Split parameters (separated by semicolons)
 	params := strings.Split(u.Opaque, ";")
 
-	config := &crypto11.Config{}
+	config := &PKCS11Config{}
 
 	// Parse each parameter
 	for _, param := range params {
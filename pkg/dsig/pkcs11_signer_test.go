@@ -3,13 +3,12 @@ package dsig
 import (
 	"testing"
 
-	"github.com/ThalesGroup/crypto11"
 	"github.com/sirosfoundation/g119612/pkg/dsig/test"
 )
 
 func TestNewPKCS11Signer(t *testing.T) {
 	// Create a minimal configuration for testing creation (not connection)
-	config := &crypto11.Config{
+	config := &PKCS11Config{
 		Path:       "/path/to/module",
 		TokenLabel: "test-token",
 		Pin:        "1234",
@@ -61,6 +60,9 @@ func TestNewPKCS11SignerFromURI(t *testing.T) {
 }
 
 func TestPKCS11SignerWithSoftHSM(t *testing.T) {
+	if !PKCS11Supported {
+		t.Skip("PKCS#11 support is not compiled in (build tag pkcs11)")
+	}
 	// Skip if CI or SoftHSM not available
 	if helper := test.SkipIfSoftHSMUnavailable(t); helper != nil {
 		// Set up SoftHSM token
@@ -0,0 +1,29 @@
+//go:build !pkcs11
+
+package dsig
+
+import (
+	xmldsig "github.com/russellhaering/goxmldsig"
+)
+
+// PKCS11Supported reports whether PKCS11Signer can access tokens, which
+// requires building with the "pkcs11" build tag and cgo.
+const PKCS11Supported = false
+
+// pkcs11Context is unused without PKCS#11 support.
+type pkcs11Context = struct{}
+
+// Close cleans up any resources associated with the signer. Without PKCS#11
+// support there are none.
+//
+// Returns:
+//   - Always returns nil error
+func (ps *PKCS11Signer) Close() error {
+	return nil
+}
+
+// xmlDSigSigner fails with ErrPKCS11NotSupported, as this binary cannot
+// access PKCS#11 tokens.
+func (ps *PKCS11Signer) xmlDSigSigner() (xmldsig.Signer, error) {
+	return nil, ErrPKCS11NotSupported
+}
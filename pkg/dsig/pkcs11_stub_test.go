@@ -0,0 +1,28 @@
+//go:build !pkcs11
+
+package dsig
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestPKCS11SignerNotSupported(t *testing.T) {
+	signer, err := NewPKCS11SignerFromURI("pkcs11:module=/usr/lib/softhsm/libsofthsm2.so;pin=1234", "key-label", "cert-label")
+	if err != nil {
+		t.Fatalf("Failed to create PKCS11Signer from URI: %v", err)
+	}
+	defer signer.Close()
+
+	if _, err := signer.Sign([]byte("<test/>")); !errors.Is(err, ErrPKCS11NotSupported) {
+		t.Errorf("Sign() error = %v, want ErrPKCS11NotSupported", err)
+	}
+	var out bytes.Buffer
+	if err := signer.SignStream(&out, bytes.NewReader([]byte("<test/>"))); !errors.Is(err, ErrPKCS11NotSupported) {
+		t.Errorf("SignStream() error = %v, want ErrPKCS11NotSupported", err)
+	}
+	if out.Len() != 0 {
+		t.Error("SignStream() should not write output without PKCS#11 support")
+	}
+}
@@ -0,0 +1,187 @@
+package dsig
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"time"
+
+	xmldsig "github.com/russellhaering/goxmldsig"
+)
+
+// SelfSignedOptions configure the certificate and key made by
+// GenerateSelfSignedSigner. The zero value gives a 2048 bit RSA key with a
+// certificate for "Test Signer" valid for one day.
+type SelfSignedOptions struct {
+	// CommonName is the subject common name (default "Test Signer")
+	CommonName string
+
+	// Organization and Country are added to the subject when set
+	Organization string
+	Country      string
+
+	// KeyBits is the size of the RSA key (default 2048)
+	KeyBits int
+
+	// NotBefore is the start of the validity period (default one minute ago,
+	// allowing for clock skew) and Validity its length (default 24 hours).
+	NotBefore time.Time
+	Validity  time.Duration
+
+	// IsCA makes the certificate a CA certificate allowed to sign certificates
+	IsCA bool
+
+	// ExtKeyUsage lists the extended key usages of the certificate
+	ExtKeyUsage []x509.ExtKeyUsage
+}
+
+// SelfSignedSigner is an RSA key with a self-signed certificate, held in
+// memory. It implements XMLSigner, StreamSigner and X509KeyStore, so that
+// tests can sign documents without key files or external tools, and can write
+// the key pair as PEM files for code that takes file paths such as FileSigner.
+type SelfSignedSigner struct {
+	// Certificate is the self-signed certificate of Key
+	Certificate *x509.Certificate
+
+	// Key is the RSA private key
+	Key *rsa.PrivateKey
+}
+
+// GenerateSelfSignedSigner creates a new RSA key and a self-signed certificate
+// for it, replacing the usual
+//
+//	openssl req -x509 -newkey rsa:2048 -nodes -subj '/CN=Test Signer'
+//
+// in test suites. It is meant for tests and development setups; production
+// signing keys belong in files with restricted permissions or in an HSM.
+//
+// Parameters:
+//   - opts: The subject, key size, validity and usages of the certificate
+//
+// Returns:
+//   - A SelfSignedSigner with the key and certificate
+//   - An error if key generation or certificate creation fails
+func GenerateSelfSignedSigner(opts SelfSignedOptions) (*SelfSignedSigner, error) {
+	if opts.CommonName == "" {
+		opts.CommonName = "Test Signer"
+	}
+	if opts.KeyBits == 0 {
+		opts.KeyBits = 2048
+	}
+	if opts.NotBefore.IsZero() {
+		opts.NotBefore = time.Now().Add(-time.Minute)
+	}
+	if opts.Validity <= 0 {
+		opts.Validity = 24 * time.Hour
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, opts.KeyBits)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate RSA key: %w", err)
+	}
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate serial number: %w", err)
+	}
+
+	subject := pkix.Name{CommonName: opts.CommonName}
+	if opts.Organization != "" {
+		subject.Organization = []string{opts.Organization}
+	}
+	if opts.Country != "" {
+		subject.Country = []string{opts.Country}
+	}
+	template := &x509.Certificate{
+		SerialNumber:          serialNumber,
+		Subject:               subject,
+		NotBefore:             opts.NotBefore,
+		NotAfter:              opts.NotBefore.Add(opts.Validity),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           opts.ExtKeyUsage,
+		BasicConstraintsValid: true,
+		IsCA:                  opts.IsCA,
+	}
+	if opts.IsCA {
+		template.KeyUsage |= x509.KeyUsageCertSign
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create certificate: %w", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate: %w", err)
+	}
+	return &SelfSignedSigner{Certificate: cert, Key: key}, nil
+}
+
+// Sign implements XMLSigner.Sign with the in-memory key and certificate.
+func (s *SelfSignedSigner) Sign(xmlData []byte) ([]byte, error) {
+	return SignXMLWithKeyStore(xmlData, s)
+}
+
+// SignStream implements StreamSigner with the in-memory key and certificate.
+func (s *SelfSignedSigner) SignStream(w io.Writer, r io.ReadSeeker) error {
+	signer, err := s.ToXMLDSigSigner()
+	if err != nil {
+		return err
+	}
+	return SignXMLStream(w, r, signer)
+}
+
+// GetKeyPair implements X509KeyStore.
+func (s *SelfSignedSigner) GetKeyPair() (*rsa.PrivateKey, []byte, error) {
+	return s.Key, s.Certificate.Raw, nil
+}
+
+// ToXMLDSigSigner returns an xmldsig.Signer using the key and certificate,
+// signing with SHA-256 like FileSigner.ToXMLDSigSigner.
+func (s *SelfSignedSigner) ToXMLDSigSigner() (xmldsig.Signer, error) {
+	return xmldsig.NewFileSigner(s.Key, s.Certificate.Raw, crypto.SHA256)
+}
+
+// CertificatePEM returns the certificate in PEM format.
+func (s *SelfSignedSigner) CertificatePEM() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: s.Certificate.Raw})
+}
+
+// KeyPEM returns the private key in PKCS#8 PEM format.
+func (s *SelfSignedSigner) KeyPEM() ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(s.Key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode private key: %w", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// WritePEM writes the certificate and the PKCS#8 private key as PEM files,
+// the key readable by the owner only. The files can be used with
+// NewFileSigner(certFile, keyFile).
+//
+// Parameters:
+//   - certFile: Path of the certificate file to write
+//   - keyFile: Path of the private key file to write
+//
+// Returns:
+//   - An error if encoding or writing a file fails
+func (s *SelfSignedSigner) WritePEM(certFile, keyFile string) error {
+	keyPEM, err := s.KeyPEM()
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(certFile, s.CertificatePEM(), 0644); err != nil {
+		return fmt.Errorf("failed to write certificate file: %w", err)
+	}
+	if err := os.WriteFile(keyFile, keyPEM, 0600); err != nil {
+		return fmt.Errorf("failed to write key file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package dsig
+
+import (
+	"bytes"
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/beevik/etree"
+	xmldsig "github.com/russellhaering/goxmldsig"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateSelfSignedSigner(t *testing.T) {
+	signer, err := GenerateSelfSignedSigner(SelfSignedOptions{})
+	require.NoError(t, err)
+	cert := signer.Certificate
+	assert.Equal(t, "Test Signer", cert.Subject.CommonName)
+	assert.Equal(t, 2048, signer.Key.N.BitLen())
+	assert.False(t, cert.IsCA)
+	assert.True(t, cert.NotBefore.Before(time.Now()))
+	assert.WithinDuration(t, cert.NotBefore.Add(24*time.Hour), cert.NotAfter, time.Second)
+	assert.True(t, signer.Key.PublicKey.Equal(cert.PublicKey))
+
+	notBefore := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	ca, err := GenerateSelfSignedSigner(SelfSignedOptions{
+		CommonName:   "Test CA",
+		Organization: "Test Org",
+		Country:      "SE",
+		KeyBits:      3072,
+		NotBefore:    notBefore,
+		Validity:     time.Hour,
+		IsCA:         true,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+	require.NoError(t, err)
+	cert = ca.Certificate
+	assert.Equal(t, []string{"Test Org"}, cert.Subject.Organization)
+	assert.Equal(t, []string{"SE"}, cert.Subject.Country)
+	assert.Equal(t, 3072, ca.Key.N.BitLen())
+	assert.Equal(t, notBefore, cert.NotBefore)
+	assert.Equal(t, notBefore.Add(time.Hour), cert.NotAfter)
+	assert.True(t, cert.IsCA)
+	assert.NotZero(t, cert.KeyUsage&x509.KeyUsageCertSign)
+	assert.Equal(t, []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}, cert.ExtKeyUsage)
+
+	_, err = GenerateSelfSignedSigner(SelfSignedOptions{KeyBits: 8})
+	assert.Error(t, err)
+}
+
+func TestSelfSignedSigner_Sign(t *testing.T) {
+	signer, err := GenerateSelfSignedSigner(SelfSignedOptions{})
+	require.NoError(t, err)
+	var _ XMLSigner = signer
+	var _ StreamSigner = signer
+	var _ X509KeyStore = signer
+
+	// The signatures verify with the certificate
+	validator := xmldsig.NewDefaultValidationContext(&xmldsig.MemoryX509CertificateStore{
+		Roots: []*x509.Certificate{signer.Certificate},
+	})
+	verify := func(signed []byte) {
+		t.Helper()
+		doc := etree.NewDocument()
+		require.NoError(t, doc.ReadFromBytes(signed))
+		_, err := validator.Validate(doc.Root())
+		assert.NoError(t, err)
+	}
+
+	signed, err := signer.Sign([]byte(`<root Id="r"><child>text</child></root>`))
+	require.NoError(t, err)
+	verify(signed)
+
+	var out bytes.Buffer
+	require.NoError(t, signer.SignStream(&out, strings.NewReader(`<root><child>text</child></root>`)))
+	verify(out.Bytes())
+}
+
+func TestSelfSignedSigner_WritePEM(t *testing.T) {
+	signer, err := GenerateSelfSignedSigner(SelfSignedOptions{})
+	require.NoError(t, err)
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	require.NoError(t, signer.WritePEM(certFile, keyFile))
+
+	info, err := os.Stat(keyFile)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	assert.NoError(t, CheckKeyFilePermissions(keyFile))
+
+	// The files work with FileSigner
+	fileSigner := NewFileSigner(certFile, keyFile)
+	fileSigner.StrictKeyPermissions = true
+	signed, err := fileSigner.Sign([]byte(`<root>text</root>`))
+	require.NoError(t, err)
+	assert.Contains(t, string(signed), "SignatureValue")
+
+	assert.Error(t, signer.WritePEM(filepath.Join(dir, "missing", "cert.pem"), keyFile))
+}
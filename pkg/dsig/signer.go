@@ -6,9 +6,11 @@ package dsig
 
 import (
 	"crypto/rsa"
+	"errors"
 
 	"github.com/beevik/etree"
 	xmldsig "github.com/russellhaering/goxmldsig"
+	"github.com/sirosfoundation/g119612/pkg/validation"
 )
 
 // XMLSigner defines the interface for XML document signing operations.
@@ -45,7 +47,7 @@ type X509KeyStore interface {
 //
 // The function:
 // 1. Sets up a signing context with exclusive canonicalization
-// 2. Parses the input XML
+// 2. Parses the input XML, rejecting documents with a DOCTYPE declaration
 // 3. Signs the document with an enveloped signature
 // 4. Returns the signed document
 //
@@ -63,9 +65,9 @@ func SignXML(xmlData []byte, signer xmldsig.Signer) ([]byte, error) {
 	// Use exclusive canonicalization (C14N)
 	ctx.Canonicalizer = xmldsig.MakeC14N10ExclusiveCanonicalizerWithPrefixList("")
 
-	// Parse the XML document
-	doc := etree.NewDocument()
-	if err := doc.ReadFromBytes(xmlData); err != nil {
+	// Parse the XML document, refusing DOCTYPE declarations (XXE)
+	doc, err := parseDocument(xmlData)
+	if err != nil {
 		return nil, err
 	}
 
@@ -81,6 +83,22 @@ func SignXML(xmlData []byte, signer xmldsig.Signer) ([]byte, error) {
 	return doc2.WriteToBytes()
 }
 
+// parseDocument parses an XML document to sign. Documents with a DOCTYPE
+// declaration or without a root element are rejected.
+func parseDocument(xmlData []byte) (*etree.Document, error) {
+	if err := validation.ValidateNoDoctype(xmlData); err != nil {
+		return nil, err
+	}
+	doc := etree.NewDocument()
+	if err := doc.ReadFromBytes(xmlData); err != nil {
+		return nil, err
+	}
+	if doc.Root() == nil {
+		return nil, errors.New("failed to parse XML: missing root element")
+	}
+	return doc, nil
+}
+
 // SignXMLWithKeyStore signs XML data using the provided X509KeyStore.
 // This is a convenience function that creates a signing context and applies
 // the same canonicalization and signing process as SignXML.
@@ -98,9 +116,9 @@ func SignXMLWithKeyStore(xmlData []byte, keyStore xmldsig.X509KeyStore) ([]byte,
 	ctx := xmldsig.NewDefaultSigningContext(keyStore)
 	ctx.Canonicalizer = xmldsig.MakeC14N10ExclusiveCanonicalizerWithPrefixList("")
 
-	// Parse the XML document
-	doc := etree.NewDocument()
-	if err := doc.ReadFromBytes(xmlData); err != nil {
+	// Parse the XML document, refusing DOCTYPE declarations (XXE)
+	doc, err := parseDocument(xmlData)
+	if err != nil {
 		return nil, err
 	}
 
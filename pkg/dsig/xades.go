@@ -0,0 +1,193 @@
+package dsig
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	xmldsig "github.com/russellhaering/goxmldsig"
+)
+
+// XAdES signatures
+//
+// ETSI TS 119 612 requires trusted lists to carry XAdES signatures (ETSI EN
+// 319 132-1): an enveloped XML-DSIG signature with a second Reference to a
+// SignedProperties element in a ds:Object, so that the signing time, the
+// signing certificate and the signature policy are covered by the signature.
+// With XAdESOptions the signers add these qualifying properties:
+//
+//   - SigningTime, the time of signing
+//   - SigningCertificateV2, the SHA-256 digest of the signing certificate
+//   - SignaturePolicyIdentifier, when a policy is given (XAdES-EPES; without
+//     one the signature is XAdES-BES)
+//   - DataObjectFormat, declaring the signed list as text/xml
+//
+// The SignedProperties element is written in its exclusive canonical form, so
+// its digest is computed over the same bytes that are embedded.
+
+// XAdESNamespace is the namespace of the XAdES qualifying properties.
+const XAdESNamespace = "http://uri.etsi.org/01903/v1.3.2#"
+
+// xadesSignedPropertiesType is the Reference Type of the XAdES SignedProperties.
+const xadesSignedPropertiesType = "http://uri.etsi.org/01903#SignedProperties"
+
+// XAdESOptions select XAdES signatures and their qualifying properties. The
+// zero value gives an XAdES-BES signature dated at the time of signing.
+type XAdESOptions struct {
+	// SigningTime is the signing time to declare (default: the current time).
+	// It is written in UTC with a precision of one second.
+	SigningTime time.Time
+
+	// PolicyID identifies the signature policy, typically as an OID URN or
+	// URL. Setting it makes the signature XAdES-EPES.
+	PolicyID string
+
+	// PolicyDescription is an optional description of the policy
+	PolicyDescription string
+
+	// PolicyDigest is the SHA-256 digest of the policy document, required
+	// with PolicyID
+	PolicyDigest []byte
+
+	// PolicyURL is an optional location of the policy document (SPURI qualifier)
+	PolicyURL string
+}
+
+// Validate checks that the signature policy settings are complete.
+//
+// Returns:
+//   - An error if policy settings are given without PolicyID, or PolicyID
+//     without a SHA-256 PolicyDigest
+func (o *XAdESOptions) Validate() error {
+	if o == nil {
+		return nil
+	}
+	if o.PolicyID == "" {
+		if len(o.PolicyDigest) > 0 || o.PolicyDescription != "" || o.PolicyURL != "" {
+			return errors.New("XAdES signature policy settings require a policy identifier")
+		}
+		return nil
+	}
+	if len(o.PolicyDigest) != sha256.Size {
+		return fmt.Errorf("XAdES signature policy %s needs the %d byte SHA-256 digest of the policy document", o.PolicyID, sha256.Size)
+	}
+	return nil
+}
+
+// SignXMLStreamWithXAdES signs the XML document read from r like SignXMLStream,
+// adding the XAdES qualifying properties selected by opts to the signature.
+// A nil opts gives a plain XML-DSIG signature.
+//
+// Parameters:
+//   - w: Destination for the signed document
+//   - r: Source of the UTF-8 encoded XML document, read twice
+//   - signer: An implementation of xmldsig.Signer to perform the signing operation
+//   - opts: The XAdES properties, nil for none
+//
+// Returns:
+//   - An error if the options are invalid, or parsing, signing or I/O fails
+func SignXMLStreamWithXAdES(w io.Writer, r io.ReadSeeker, signer xmldsig.Signer, opts *XAdESOptions) error {
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+	return signXMLStream(w, r, signer, opts)
+}
+
+// SignXMLWithXAdES signs XML data with an enveloped XAdES signature. Unlike
+// SignXML, the document is not parsed into a DOM and serialized again: the
+// signature is inserted as with SignXMLStream, and the rest of the document
+// is kept as is.
+//
+// Parameters:
+//   - xmlData: Raw XML bytes to sign
+//   - signer: An implementation of xmldsig.Signer to perform the signing operation
+//   - opts: The XAdES properties, nil for a plain XML-DSIG signature
+//
+// Returns:
+//   - The signed XML document as bytes
+//   - An error if the options are invalid, or parsing or signing fails
+func SignXMLWithXAdES(xmlData []byte, signer xmldsig.Signer, opts *XAdESOptions) ([]byte, error) {
+	var out bytes.Buffer
+	out.Grow(len(xmlData) + 4096)
+	if err := SignXMLStreamWithXAdES(&out, bytes.NewReader(xmlData), signer, opts); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// xadesIDs returns the Id of a Signature, of the Reference to the signed
+// document and of the SignedProperties. They are derived from the digest of
+// the document, so that signing the same document gives the same Ids.
+func xadesIDs(digest []byte) (signature, reference, properties string) {
+	signature = "sig-" + hex.EncodeToString(digest[:8])
+	return signature, signature + "-ref0", signature + "-signedprops"
+}
+
+// writeXAdESSignedProperties writes the canonical SignedProperties element of
+// a signature with certificate cert.
+func writeXAdESSignedProperties(b *bytes.Buffer, opts *XAdESOptions, cert []byte, propertiesID, referenceID string) {
+	signingTime := opts.SigningTime
+	if signingTime.IsZero() {
+		signingTime = time.Now()
+	}
+	certDigest := sha256.Sum256(cert)
+
+	b.WriteString(`<xades:SignedProperties xmlns:xades="` + XAdESNamespace + `" Id="`)
+	writeEscapedAttr(b, propertiesID)
+	b.WriteString(`"><xades:SignedSignatureProperties>`)
+	b.WriteString(`<xades:SigningTime>` + signingTime.UTC().Format("2006-01-02T15:04:05Z") + `</xades:SigningTime>`)
+	b.WriteString(`<xades:SigningCertificateV2><xades:Cert><xades:CertDigest>`)
+	writeXAdESDigest(b, certDigest[:])
+	b.WriteString(`</xades:CertDigest></xades:Cert></xades:SigningCertificateV2>`)
+	if opts.PolicyID != "" {
+		b.WriteString(`<xades:SignaturePolicyIdentifier><xades:SignaturePolicyId><xades:SigPolicyId><xades:Identifier>`)
+		writeEscapedText(b, []byte(opts.PolicyID))
+		b.WriteString(`</xades:Identifier>`)
+		if opts.PolicyDescription != "" {
+			b.WriteString(`<xades:Description>`)
+			writeEscapedText(b, []byte(opts.PolicyDescription))
+			b.WriteString(`</xades:Description>`)
+		}
+		b.WriteString(`</xades:SigPolicyId><xades:SigPolicyHash>`)
+		writeXAdESDigest(b, opts.PolicyDigest)
+		b.WriteString(`</xades:SigPolicyHash>`)
+		if opts.PolicyURL != "" {
+			b.WriteString(`<xades:SigPolicyQualifiers><xades:SigPolicyQualifier><xades:SPURI>`)
+			writeEscapedText(b, []byte(opts.PolicyURL))
+			b.WriteString(`</xades:SPURI></xades:SigPolicyQualifier></xades:SigPolicyQualifiers>`)
+		}
+		b.WriteString(`</xades:SignaturePolicyId></xades:SignaturePolicyIdentifier>`)
+	}
+	b.WriteString(`</xades:SignedSignatureProperties><xades:SignedDataObjectProperties>`)
+	b.WriteString(`<xades:DataObjectFormat ObjectReference="#`)
+	writeEscapedAttr(b, referenceID)
+	b.WriteString(`"><xades:MimeType>text/xml</xades:MimeType></xades:DataObjectFormat>`)
+	b.WriteString(`</xades:SignedDataObjectProperties></xades:SignedProperties>`)
+}
+
+// writeXAdESDigest writes the canonical ds:DigestMethod and ds:DigestValue of
+// a SHA-256 digest inside a XAdES element, each declaring the ds namespace.
+func writeXAdESDigest(b *bytes.Buffer, digest []byte) {
+	b.WriteString(`<ds:DigestMethod xmlns:ds="` + xmldsig.Namespace + `" Algorithm="` + digestMethodSHA256 + `"></ds:DigestMethod>`)
+	b.WriteString(`<ds:DigestValue xmlns:ds="` + xmldsig.Namespace + `">` + base64.StdEncoding.EncodeToString(digest) + `</ds:DigestValue>`)
+}
+
+// writeXAdESReference writes the canonical Reference to the SignedProperties.
+func writeXAdESReference(b *bytes.Buffer, propertiesID string, signedProperties []byte) {
+	h := crypto.SHA256.New()
+	h.Write(signedProperties)
+	b.WriteString(`<ds:Reference Type="` + xadesSignedPropertiesType + `" URI="#`)
+	writeEscapedAttr(b, propertiesID)
+	b.WriteString(`"><ds:Transforms>`)
+	b.WriteString(`<ds:Transform Algorithm="` + string(xmldsig.CanonicalXML10ExclusiveAlgorithmId) + `"></ds:Transform>`)
+	b.WriteString(`</ds:Transforms>`)
+	b.WriteString(`<ds:DigestMethod Algorithm="` + digestMethodSHA256 + `"></ds:DigestMethod>`)
+	b.WriteString(`<ds:DigestValue>` + base64.StdEncoding.EncodeToString(h.Sum(nil)) + `</ds:DigestValue>`)
+	b.WriteString(`</ds:Reference>`)
+}
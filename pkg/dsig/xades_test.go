@@ -0,0 +1,165 @@
+package dsig
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/beevik/etree"
+	xmldsig "github.com/russellhaering/goxmldsig"
+	"github.com/sirosfoundation/g119612/pkg/etsi119612"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// checkXAdESSignature validates the signature of a signed document, as TSLs
+// are verified when they are loaded, and the digest of its SignedProperties,
+// and returns the SignedProperties element.
+func checkXAdESSignature(t *testing.T, signed []byte, cert *x509.Certificate) *etree.Element {
+	t.Helper()
+	// LocalVerifier checks every reference, including the SignedProperties
+	_, signer, err := etsi119612.LocalVerifier{}.Verify(context.Background(), signed)
+	require.NoError(t, err)
+	assert.True(t, cert.Equal(signer))
+
+	doc := etree.NewDocument()
+	require.NoError(t, doc.ReadFromBytes(signed))
+
+	signature := doc.FindElement("//ds:Signature")
+	require.NotNil(t, signature)
+	properties := doc.FindElement("//xades:SignedProperties")
+	require.NotNil(t, properties)
+	assert.Equal(t, "#"+signature.SelectAttrValue("Id", ""),
+		doc.FindElement("//xades:QualifyingProperties").SelectAttrValue("Target", ""))
+
+	// The embedded SignedProperties are canonical and match their reference
+	detached := etree.NewDocument()
+	detached.SetRoot(properties.Copy())
+	canonical, err := xmldsig.MakeC14N10ExclusiveCanonicalizerWithPrefixList("").Canonicalize(detached.Root())
+	require.NoError(t, err)
+	assert.Contains(t, string(signed), string(canonical))
+	digest := sha256.Sum256(canonical)
+
+	refs := signature.FindElements("./ds:SignedInfo/ds:Reference")
+	require.Len(t, refs, 2)
+	assert.Equal(t, xadesSignedPropertiesType, refs[1].SelectAttrValue("Type", ""))
+	assert.Equal(t, "#"+properties.SelectAttrValue("Id", ""), refs[1].SelectAttrValue("URI", ""))
+	assert.Equal(t, base64.StdEncoding.EncodeToString(digest[:]), refs[1].FindElement("./ds:DigestValue").Text())
+
+	// The data object format refers to the reference to the document
+	format := properties.FindElement(".//xades:DataObjectFormat")
+	require.NotNil(t, format)
+	assert.Equal(t, "#"+refs[0].SelectAttrValue("Id", ""), format.SelectAttrValue("ObjectReference", ""))
+	return properties
+}
+
+// xadesTestDocument returns an unsigned TSL to sign.
+func xadesTestDocument(t *testing.T) []byte {
+	t.Helper()
+	data, err := os.ReadFile("../etsi119612/testdata/test-trust-list-no-sig.xml")
+	require.NoError(t, err)
+	return data
+}
+
+func TestSignXMLWithXAdES(t *testing.T) {
+	signer, cert := newStreamTestSigner(t)
+	data := xadesTestDocument(t)
+	signingTime := time.Date(2026, 3, 1, 12, 30, 15, 500, time.FixedZone("CET", 3600))
+	certDigest := sha256.Sum256(cert.Raw)
+	policyDigest := sha256.Sum256([]byte("policy"))
+
+	t.Run("BES", func(t *testing.T) {
+		signed, err := SignXMLWithXAdES(data, signer, &XAdESOptions{SigningTime: signingTime})
+		require.NoError(t, err)
+		properties := checkXAdESSignature(t, signed, cert)
+
+		assert.Equal(t, "2026-03-01T11:30:15Z", properties.FindElement(".//xades:SigningTime").Text())
+		assert.Equal(t, base64.StdEncoding.EncodeToString(certDigest[:]),
+			properties.FindElement(".//xades:SigningCertificateV2/xades:Cert/xades:CertDigest/ds:DigestValue").Text())
+		assert.Nil(t, properties.FindElement(".//xades:SignaturePolicyIdentifier"))
+
+		// The document is kept around the signature
+		idx := bytes.LastIndex(signed, []byte("<ds:Signature "))
+		require.Positive(t, idx)
+		assert.Equal(t, data[:idx], signed[:idx])
+	})
+
+	t.Run("EPES", func(t *testing.T) {
+		signed, err := SignXMLWithXAdES(data, signer, &XAdESOptions{
+			SigningTime:       signingTime,
+			PolicyID:          "urn:oid:1.2.3.4.5",
+			PolicyDescription: "Policy <test> & more",
+			PolicyDigest:      policyDigest[:],
+			PolicyURL:         "https://example.com/policy.pdf?a=1&b=2",
+		})
+		require.NoError(t, err)
+		properties := checkXAdESSignature(t, signed, cert)
+
+		policy := properties.FindElement(".//xades:SignaturePolicyIdentifier/xades:SignaturePolicyId")
+		require.NotNil(t, policy)
+		assert.Equal(t, "urn:oid:1.2.3.4.5", policy.FindElement("./xades:SigPolicyId/xades:Identifier").Text())
+		assert.Equal(t, "Policy <test> & more", policy.FindElement("./xades:SigPolicyId/xades:Description").Text())
+		assert.Equal(t, base64.StdEncoding.EncodeToString(policyDigest[:]),
+			policy.FindElement("./xades:SigPolicyHash/ds:DigestValue").Text())
+		assert.Equal(t, "https://example.com/policy.pdf?a=1&b=2",
+			policy.FindElement("./xades:SigPolicyQualifiers/xades:SigPolicyQualifier/xades:SPURI").Text())
+	})
+
+	t.Run("Default_Signing_Time", func(t *testing.T) {
+		signed, err := SignXMLWithXAdES(data, signer, &XAdESOptions{})
+		require.NoError(t, err)
+		properties := checkXAdESSignature(t, signed, cert)
+		value, err := time.Parse(time.RFC3339, properties.FindElement(".//xades:SigningTime").Text())
+		require.NoError(t, err)
+		assert.WithinDuration(t, time.Now(), value, time.Minute)
+	})
+
+	t.Run("Without_Options", func(t *testing.T) {
+		signed, err := SignXMLWithXAdES(data, signer, nil)
+		require.NoError(t, err)
+		var plain bytes.Buffer
+		require.NoError(t, SignXMLStream(&plain, bytes.NewReader(data), signer))
+		assert.Equal(t, plain.String(), string(signed))
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		for name, opts := range map[string]*XAdESOptions{
+			"policy without digest": {PolicyID: "urn:oid:1.2.3"},
+			"short digest":          {PolicyID: "urn:oid:1.2.3", PolicyDigest: []byte{1, 2, 3}},
+			"url without policy":    {PolicyURL: "https://example.com/policy.pdf"},
+		} {
+			_, err := SignXMLWithXAdES(data, signer, opts)
+			assert.Error(t, err, name)
+		}
+		_, err := SignXMLWithXAdES([]byte(`<!DOCTYPE a><a>x</a>`), signer, &XAdESOptions{})
+		assert.Error(t, err)
+		_, err = SignXMLWithXAdES(data, nil, &XAdESOptions{})
+		assert.Error(t, err)
+	})
+}
+
+func TestFileSigner_XAdES(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	selfSigned, err := GenerateSelfSignedSigner(SelfSignedOptions{})
+	require.NoError(t, err)
+	require.NoError(t, selfSigned.WritePEM(certFile, keyFile))
+
+	signer := NewFileSigner(certFile, keyFile)
+	signer.XAdES = &XAdESOptions{SigningTime: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)}
+	data := xadesTestDocument(t)
+	signed, err := signer.Sign(data)
+	require.NoError(t, err)
+	checkXAdESSignature(t, signed, selfSigned.Certificate)
+
+	// Both signing paths give the same signature
+	var streamed bytes.Buffer
+	require.NoError(t, signer.SignStream(&streamed, bytes.NewReader(data)))
+	assert.Equal(t, string(signed), streamed.String())
+}
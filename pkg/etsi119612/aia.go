@@ -0,0 +1,201 @@
+package etsi119612
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// maxIssuerSize bounds the size of a response to an issuer certificate request.
+const maxIssuerSize = 1 << 20
+
+// IssuerFetcher fetches issuer certificates from the caIssuers URLs of the
+// Authority Information Access extension of certificates (RFC 5280 section
+// 4.2.2.1). Set as TSLFetchOptions.IssuerFetcher it completes the chains of
+// TSL signers whose KeyInfo lacks intermediate certificates.
+//
+// Only http and https URLs on AllowedHosts are fetched, redirects included,
+// so a certificate cannot make the fetcher contact arbitrary hosts. Every URL
+// is fetched at most once for the lifetime of the fetcher: the certificates,
+// or the error, are cached and shared by all loads using it.
+type IssuerFetcher struct {
+	// AllowedHosts are the hosts issuer certificates may be fetched from,
+	// host names such as "pki.example.com" or "*.example.com" for example.com
+	// and all its subdomains, as in HostOverride.Pattern.
+	AllowedHosts []string
+
+	// Client is the HTTP client requests are made with. If nil, a client
+	// with a timeout of 30 seconds is used.
+	Client *http.Client
+
+	mu    sync.Mutex
+	cache map[string]issuerResult
+}
+
+// issuerResult is a cached response of an IssuerFetcher.
+type issuerResult struct {
+	certs []*x509.Certificate
+	err   error
+}
+
+// NewIssuerFetcher returns an IssuerFetcher fetching from the given hosts.
+func NewIssuerFetcher(allowedHosts ...string) *IssuerFetcher {
+	return &IssuerFetcher{AllowedHosts: allowedHosts}
+}
+
+// Allowed reports whether rawURL may be fetched: it is an http or https URL
+// of a host matching AllowedHosts.
+func (f *IssuerFetcher) Allowed(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, pattern := range f.AllowedHosts {
+		if (HostOverride{Pattern: pattern}).matches(host) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Fetch returns the certificates served at rawURL, as a DER or PEM encoded
+// certificate or a certs-only PKCS#7 bundle (.p7c). URLs that are not Allowed
+// are refused without a request.
+func (f *IssuerFetcher) Fetch(ctx context.Context, rawURL string) ([]*x509.Certificate, error) {
+	if !f.Allowed(rawURL) {
+		return nil, fmt.Errorf("issuer certificate URL %s is not on the allowlist", rawURL)
+	}
+	f.mu.Lock()
+	result, ok := f.cache[rawURL]
+	f.mu.Unlock()
+	if ok {
+		return result.certs, result.err
+	}
+
+	result.certs, result.err = f.fetch(ctx, rawURL)
+	if result.err == nil {
+		log.Infof("g119612: Fetched %d issuer certificate(s) from %s", len(result.certs), rawURL)
+	}
+	f.mu.Lock()
+	if f.cache == nil {
+		f.cache = make(map[string]issuerResult)
+	}
+	f.cache[rawURL] = result
+	f.mu.Unlock()
+	return result.certs, result.err
+}
+
+// fetch requests the certificates at rawURL.
+func (f *IssuerFetcher) fetch(ctx context.Context, rawURL string) ([]*x509.Certificate, error) {
+	client := &http.Client{Timeout: 30 * time.Second}
+	if f.Client != nil {
+		copied := *f.Client
+		client = &copied
+	}
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 5 {
+			return errors.New("too many redirects")
+		}
+		if !f.Allowed(req.URL.String()) {
+			return fmt.Errorf("redirect to %s is not on the allowlist", req.URL)
+		}
+		return nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s: HTTP %d", rawURL, resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxIssuerSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxIssuerSize {
+		return nil, fmt.Errorf("fetching %s: response larger than %d bytes", rawURL, maxIssuerSize)
+	}
+	certs, err := parseIssuerCertificates(data)
+	if err != nil {
+		return nil, fmt.Errorf("fetching %s: %w", rawURL, err)
+	}
+	return certs, nil
+}
+
+// pkcs7ContentInfo and pkcs7SignedData are the parts of a PKCS#7 SignedData
+// (RFC 2315) holding its certificates.
+type pkcs7ContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,tag:0"`
+}
+
+type pkcs7SignedData struct {
+	Version          int
+	DigestAlgorithms asn1.RawValue
+	ContentInfo      asn1.RawValue
+	Certificates     asn1.RawValue `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue `asn1:"optional,tag:1"`
+	SignerInfos      asn1.RawValue
+}
+
+// oidSignedData is the content type of a PKCS#7 SignedData.
+var oidSignedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+
+// parseIssuerCertificates parses a response to an issuer certificate request:
+// PEM encoded certificates, a DER encoded certificate or a certs-only PKCS#7
+// bundle.
+func parseIssuerCertificates(data []byte) ([]*x509.Certificate, error) {
+	if block, rest := pem.Decode(data); block != nil {
+		var certs []*x509.Certificate
+		for ; block != nil; block, rest = pem.Decode(rest) {
+			if block.Type == "CERTIFICATE" {
+				cert, err := x509.ParseCertificate(block.Bytes)
+				if err != nil {
+					return nil, err
+				}
+				certs = append(certs, cert)
+			}
+		}
+		if len(certs) == 0 {
+			return nil, errors.New("no certificates in PEM response")
+		}
+		return certs, nil
+	}
+	if cert, err := x509.ParseCertificate(data); err == nil {
+		return []*x509.Certificate{cert}, nil
+	}
+	var info pkcs7ContentInfo
+	if _, err := asn1.Unmarshal(data, &info); err != nil || !info.ContentType.Equal(oidSignedData) {
+		return nil, errors.New("response is neither a certificate nor a PKCS#7 bundle")
+	}
+	var signed pkcs7SignedData
+	if _, err := asn1.Unmarshal(info.Content.Bytes, &signed); err != nil {
+		return nil, fmt.Errorf("invalid PKCS#7 bundle: %w", err)
+	}
+	certs, err := x509.ParseCertificates(signed.Certificates.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid certificate in PKCS#7 bundle: %w", err)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("no certificates in PKCS#7 bundle")
+	}
+	return certs, nil
+}
@@ -0,0 +1,128 @@
+package etsi119612
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testIssuerCertificate(t *testing.T, cn string) *x509.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert
+}
+
+func TestParseIssuerCertificates(t *testing.T) {
+	first, second := testIssuerCertificate(t, "First"), testIssuerCertificate(t, "Second")
+
+	certs, err := parseIssuerCertificates(first.Raw)
+	require.NoError(t, err)
+	require.Len(t, certs, 1)
+	assert.True(t, certs[0].Equal(first))
+
+	bundle := append(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: first.Raw}), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: second.Raw})...)
+	certs, err = parseIssuerCertificates(bundle)
+	require.NoError(t, err)
+	require.Len(t, certs, 2)
+	assert.True(t, certs[1].Equal(second))
+
+	// A certs-only PKCS#7 SignedData, as served with .p7c URLs
+	empty, err := asn1.Marshal([]asn1.RawValue{})
+	require.NoError(t, err)
+	emptySet := asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true}
+	contentInfo, err := asn1.Marshal(struct{ ContentType asn1.ObjectIdentifier }{asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}})
+	require.NoError(t, err)
+	signedData, err := asn1.Marshal(struct {
+		Version          int
+		DigestAlgorithms asn1.RawValue
+		ContentInfo      asn1.RawValue
+		Certificates     asn1.RawValue
+		SignerInfos      asn1.RawValue
+	}{
+		Version:          1,
+		DigestAlgorithms: emptySet,
+		ContentInfo:      asn1.RawValue{FullBytes: contentInfo},
+		Certificates:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: append(append([]byte{}, first.Raw...), second.Raw...)},
+		SignerInfos:      emptySet,
+	})
+	require.NoError(t, err)
+	p7c, err := asn1.Marshal(struct {
+		ContentType asn1.ObjectIdentifier
+		Content     asn1.RawValue
+	}{oidSignedData, asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: signedData}})
+	require.NoError(t, err)
+	certs, err = parseIssuerCertificates(p7c)
+	require.NoError(t, err)
+	require.Len(t, certs, 2)
+	assert.True(t, certs[0].Equal(first))
+
+	_, err = parseIssuerCertificates(empty)
+	assert.Error(t, err)
+	_, err = parseIssuerCertificates([]byte("not a certificate"))
+	assert.Error(t, err)
+}
+
+func TestIssuerFetcher(t *testing.T) {
+	cert := testIssuerCertificate(t, "Issuer")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/issuer.cer":
+			w.Write(cert.Raw)
+		case "/elsewhere":
+			http.Redirect(w, r, "http://pki.example.net/issuer.cer", http.StatusFound)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	f := NewIssuerFetcher("127.0.0.1", "*.example.com")
+	assert.True(t, f.Allowed("http://pki.example.com/ca.cer"))
+	assert.True(t, f.Allowed("https://example.com/ca.cer"))
+	assert.False(t, f.Allowed("http://pki.example.net/ca.cer"))
+	assert.False(t, f.Allowed("ldap://pki.example.com/cn=CA"))
+
+	certs, err := f.Fetch(context.Background(), server.URL+"/issuer.cer")
+	require.NoError(t, err)
+	require.Len(t, certs, 1)
+	assert.True(t, certs[0].Equal(cert))
+
+	_, err = f.Fetch(context.Background(), server.URL+"/missing.cer")
+	assert.ErrorContains(t, err, "HTTP 404")
+	_, err = f.Fetch(context.Background(), server.URL+"/elsewhere")
+	assert.ErrorContains(t, err, "not on the allowlist")
+	_, err = f.Fetch(context.Background(), "http://pki.example.net/issuer.cer")
+	assert.ErrorContains(t, err, "not on the allowlist")
+
+	// Results are cached, failures included
+	server.Close()
+	certs, err = f.Fetch(context.Background(), server.URL+"/issuer.cer")
+	require.NoError(t, err)
+	assert.Len(t, certs, 1)
+	_, err = f.Fetch(context.Background(), server.URL+"/missing.cer")
+	assert.ErrorContains(t, err, "HTTP 404")
+}
@@ -0,0 +1,111 @@
+package etsi119612
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// DefaultMinRSAKeySize is the minimum size in bits of the RSA key a TSL is
+// signed with when AlgorithmPolicy.MinRSAKeySize is not set.
+const DefaultMinRSAKeySize = 2048
+
+// AlgorithmPolicy controls which algorithms and keys are accepted in the
+// signatures of TSLs, checked after the signature is verified. The zero
+// policy accepts any algorithm not based on SHA-1 and RSA keys of at least
+// DefaultMinRSAKeySize bits.
+//
+// Algorithms are given as their XML signature URIs, such as
+// "http://www.w3.org/2001/04/xmldsig-more#rsa-sha256", or by the fragment
+// of the URI alone, such as "rsa-sha256".
+type AlgorithmPolicy struct {
+	// SignatureAlgorithms lists the accepted SignatureMethod algorithms. If
+	// empty, any algorithm is accepted.
+	SignatureAlgorithms []string
+
+	// DigestAlgorithms lists the accepted DigestMethod algorithms of the
+	// references of the signature. If empty, any algorithm is accepted.
+	DigestAlgorithms []string
+
+	// AllowSHA1 accepts signature and digest algorithms based on SHA-1,
+	// which are rejected by default even if listed above.
+	AllowSHA1 bool
+
+	// MinRSAKeySize is the minimum size in bits of an RSA signer key. If
+	// zero, DefaultMinRSAKeySize is used; a negative value accepts any size.
+	MinRSAKeySize int
+}
+
+// check returns an error wrapping ErrAlgorithmPolicy if the signature
+// described by info, made by signer, is not accepted by the policy.
+func (p AlgorithmPolicy) check(info *SignatureInfo, signer *x509.Certificate) error {
+	if err := p.checkAlgorithm("signature", info.SignatureAlgorithm, p.SignatureAlgorithms); err != nil {
+		return err
+	}
+	for _, digest := range info.referenceDigests {
+		if err := p.checkAlgorithm("digest", digest, p.DigestAlgorithms); err != nil {
+			return err
+		}
+	}
+	if key, ok := signer.PublicKey.(*rsa.PublicKey); ok {
+		minSize := p.minRSAKeySize()
+		if bits := key.N.BitLen(); bits < minSize {
+			return fmt.Errorf("%w: RSA signer key of %d bits is shorter than %d bits", ErrAlgorithmPolicy, bits, minSize)
+		}
+	}
+	return nil
+}
+
+// minRSAKeySize returns the minimum size of an RSA signer key, with the
+// default filled in.
+func (p AlgorithmPolicy) minRSAKeySize() int {
+	if p.MinRSAKeySize == 0 {
+		return DefaultMinRSAKeySize
+	}
+	return p.MinRSAKeySize
+}
+
+// checkAlgorithm checks one algorithm of a signature against the list of
+// accepted algorithms of its kind and the SHA-1 rule.
+func (p AlgorithmPolicy) checkAlgorithm(kind, algorithm string, accepted []string) error {
+	if algorithm == "" {
+		return fmt.Errorf("%w: no %s algorithm", ErrAlgorithmPolicy, kind)
+	}
+	if !p.AllowSHA1 && isSHA1Algorithm(algorithm) {
+		return fmt.Errorf("%w: %s algorithm %s is based on SHA-1", ErrAlgorithmPolicy, kind, algorithm)
+	}
+	if len(accepted) > 0 && !slices.ContainsFunc(accepted, func(a string) bool { return algorithmMatches(a, algorithm) }) {
+		return fmt.Errorf("%w: %s algorithm %s is not accepted", ErrAlgorithmPolicy, kind, algorithm)
+	}
+	return nil
+}
+
+// algorithmMatches reports whether an entry of an AlgorithmPolicy, a full
+// URI or its fragment, names algorithm.
+func algorithmMatches(entry, algorithm string) bool {
+	if entry == algorithm {
+		return true
+	}
+	_, fragment, ok := strings.Cut(algorithm, "#")
+	return ok && entry == fragment
+}
+
+// isSHA1Algorithm reports whether an XML signature algorithm URI, such as
+// "http://www.w3.org/2000/09/xmldsig#rsa-sha1" or
+// "http://www.w3.org/2000/09/xmldsig#sha1", is based on SHA-1.
+func isSHA1Algorithm(algorithm string) bool {
+	_, fragment, _ := strings.Cut(strings.ToLower(algorithm), "#")
+	return fragment == "sha1" || strings.HasSuffix(fragment, "-sha1")
+}
+
+// algorithmPolicy returns the policy for the TSL at url: AlgorithmPolicy,
+// accepting SHA-1 if url is in AllowSHA1URLs.
+func (options TSLFetchOptions) algorithmPolicy(url string) AlgorithmPolicy {
+	policy := options.AlgorithmPolicy
+	if slices.Contains(options.AllowSHA1URLs, url) {
+		policy.AllowSHA1 = true
+	}
+	return policy
+}
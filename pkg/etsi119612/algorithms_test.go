@@ -0,0 +1,159 @@
+package etsi119612_test
+
+import (
+	"context"
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/beevik/etree"
+	xmldsig "github.com/russellhaering/goxmldsig"
+	"github.com/sirosfoundation/g119612/pkg/dsig"
+	"github.com/sirosfoundation/g119612/pkg/etsi119612"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTSL_AlgorithmPolicy(t *testing.T) {
+	signed, err := os.ReadFile(filepath.Join("testdata", "SE-TL.xml"))
+	require.NoError(t, err)
+
+	// SE-TL.xml is signed with rsa-sha256 and a 2048 bit key, which the
+	// default policy accepts
+	tsl, err := etsi119612.ParseTSL(signed, "SE-TL.xml", etsi119612.DefaultTSLFetchOptions)
+	require.NoError(t, err)
+	assert.Equal(t, "http://www.w3.org/2001/04/xmldsig-more#rsa-sha256", tsl.SignatureInfo().SignatureAlgorithm)
+
+	// The same list as if it had been signed with SHA-1; the verifier stands
+	// in for one that accepts the signature
+	sha1Digests := []byte(strings.ReplaceAll(string(signed),
+		"http://www.w3.org/2001/04/xmlenc#sha256", "http://www.w3.org/2000/09/xmldsig#sha1"))
+	sha1 := []byte(strings.ReplaceAll(string(sha1Digests),
+		"http://www.w3.org/2001/04/xmldsig-more#rsa-sha256", "http://www.w3.org/2000/09/xmldsig#rsa-sha1"))
+	options := etsi119612.DefaultTSLFetchOptions
+	options.Verifier = etsi119612.VerifierFunc(func(ctx context.Context, data []byte) ([]byte, *x509.Certificate, error) {
+		return data, &tsl.Signer, nil
+	})
+
+	tests := []struct {
+		name    string
+		data    []byte
+		policy  etsi119612.AlgorithmPolicy
+		wantErr string
+	}{
+		{"SHA-1 is rejected by default", sha1, etsi119612.AlgorithmPolicy{}, "signature algorithm http://www.w3.org/2000/09/xmldsig#rsa-sha1 is based on SHA-1"},
+		{"SHA-1 digests are rejected", sha1Digests, etsi119612.AlgorithmPolicy{}, "digest algorithm http://www.w3.org/2000/09/xmldsig#sha1 is based on SHA-1"},
+		{"SHA-1 allowed", sha1, etsi119612.AlgorithmPolicy{AllowSHA1: true}, ""},
+		{"allow list by fragment", signed, etsi119612.AlgorithmPolicy{SignatureAlgorithms: []string{"ecdsa-sha256", "rsa-sha256"}}, ""},
+		{"signature algorithm not listed", signed, etsi119612.AlgorithmPolicy{SignatureAlgorithms: []string{"ecdsa-sha256"}}, "signature algorithm http://www.w3.org/2001/04/xmldsig-more#rsa-sha256 is not accepted"},
+		{"digest algorithm not listed", signed, etsi119612.AlgorithmPolicy{DigestAlgorithms: []string{"http://www.w3.org/2001/04/xmlenc#sha512"}}, "digest algorithm http://www.w3.org/2001/04/xmlenc#sha256 is not accepted"},
+		{"RSA key too short", signed, etsi119612.AlgorithmPolicy{MinRSAKeySize: 3072}, "RSA signer key of 2048 bits is shorter than 3072 bits"},
+		{"no minimum key size", signed, etsi119612.AlgorithmPolicy{MinRSAKeySize: -1}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := options
+			options.AlgorithmPolicy = tt.policy
+			_, err := etsi119612.ParseTSL(tt.data, "https://example.com/tsl.xml", options)
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, etsi119612.ErrAlgorithmPolicy)
+			assert.ErrorContains(t, err, tt.wantErr)
+			assert.ErrorContains(t, err, "https://example.com/tsl.xml")
+		})
+	}
+
+	// An unreadable signing time is no reason to reject a list whose
+	// algorithms can be checked
+	badTime := []byte(strings.Replace(string(signed), "2025-04-10T11:45:50Z", "yesterday", 1))
+	tsl, err = etsi119612.ParseTSL(badTime, "https://example.com/tsl.xml", options)
+	require.NoError(t, err)
+	assert.Equal(t, "http://www.w3.org/2001/04/xmldsig-more#rsa-sha256", tsl.SignatureInfo().SignatureAlgorithm)
+	assert.NotEmpty(t, tsl.SignatureInfo().Certificates)
+
+	// A signature whose algorithms cannot be read is not a policy failure
+	nested := []byte(`<TrustServiceStatusList xmlns="http://uri.etsi.org/02231/v2#"><Nested><Signature></Signature></Nested></TrustServiceStatusList>`)
+	_, err = etsi119612.ParseTSL(nested, "https://example.com/tsl.xml", options)
+	assert.ErrorIs(t, err, etsi119612.ErrSignatureInfo)
+	assert.NotErrorIs(t, err, etsi119612.ErrAlgorithmPolicy)
+
+	// A SHA-1 exemption applies to its URL only
+	options.AllowSHA1URLs = []string{"https://legacy.example.com/tsl.xml"}
+	_, err = etsi119612.ParseTSL(sha1, "https://legacy.example.com/tsl.xml", options)
+	require.NoError(t, err)
+	_, err = etsi119612.ParseTSL(sha1, "https://example.com/tsl.xml", options)
+	assert.ErrorIs(t, err, etsi119612.ErrAlgorithmPolicy)
+
+	// The rest of the policy still applies to an exempted URL
+	options.AlgorithmPolicy = etsi119612.AlgorithmPolicy{MinRSAKeySize: 1 << 16}
+	_, err = etsi119612.ParseTSL(sha1, "https://legacy.example.com/tsl.xml", options)
+	assert.ErrorIs(t, err, etsi119612.ErrAlgorithmPolicy)
+	assert.ErrorContains(t, err, "RSA signer key")
+}
+
+func TestParseTSL_AlgorithmPolicyDecoySignature(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "EWC-TL.xml"))
+	require.NoError(t, err)
+
+	// An rsa-sha1 signature followed by an rsa-sha256 Signature element in
+	// another namespace, which is part of the signed content
+	doc := etree.NewDocument()
+	require.NoError(t, doc.ReadFromBytes(data))
+	decoy := doc.Root().CreateElement("x:Signature")
+	decoy.CreateAttr("xmlns:x", "urn:example:decoy")
+	decoy.CreateElement("x:SignedInfo").CreateElement("x:SignatureMethod").CreateAttr("Algorithm", "http://www.w3.org/2001/04/xmldsig-more#rsa-sha256")
+	signer, err := dsig.GenerateSelfSignedSigner(dsig.SelfSignedOptions{})
+	require.NoError(t, err)
+	signingContext, err := xmldsig.NewSigningContext(signer.Key, [][]byte{signer.Certificate.Raw})
+	require.NoError(t, err)
+	require.NoError(t, signingContext.SetSignatureMethod(xmldsig.RSASHA1SignatureMethod))
+	signed, err := signingContext.SignEnveloped(doc.Root())
+	require.NoError(t, err)
+	out := etree.NewDocument()
+	out.SetRoot(signed)
+	written, err := out.WriteToBytes()
+	require.NoError(t, err)
+	// The enveloped signature does not cover its own position
+	out = etree.NewDocument()
+	require.NoError(t, out.ReadFromBytes(written))
+	sig := out.Root().SelectElement("ds:Signature")
+	out.Root().RemoveChildAt(sig.Index())
+	out.Root().InsertChildAt(out.Root().SelectElement("x:Signature").Index(), sig)
+	decoyed, err := out.WriteToBytes()
+	require.NoError(t, err)
+
+	_, _, err = etsi119612.LocalVerifier{}.Verify(context.Background(), decoyed)
+	require.NoError(t, err)
+	options := etsi119612.DefaultTSLFetchOptions
+	options.AlgorithmPolicy = etsi119612.AlgorithmPolicy{AllowSHA1: true}
+	tsl, err := etsi119612.ParseTSL(decoyed, "https://example.com/tsl.xml", options)
+	require.NoError(t, err)
+	assert.Equal(t, "http://www.w3.org/2000/09/xmldsig#rsa-sha1", tsl.SignatureInfo().SignatureAlgorithm)
+	options.AlgorithmPolicy.SignatureAlgorithms = []string{"rsa-sha256"}
+	_, err = etsi119612.ParseTSL(decoyed, "https://example.com/tsl.xml", options)
+	assert.ErrorIs(t, err, etsi119612.ErrAlgorithmPolicy)
+	assert.ErrorContains(t, err, "rsa-sha1 is not accepted")
+
+	// Other verifiers are checked against the XML signature of the document
+	options.Verifier = etsi119612.VerifierFunc(func(ctx context.Context, data []byte) ([]byte, *x509.Certificate, error) {
+		return etsi119612.LocalVerifier{}.Verify(ctx, data)
+	})
+	_, err = etsi119612.ParseTSL(decoyed, "https://example.com/tsl.xml", options)
+	assert.ErrorIs(t, err, etsi119612.ErrAlgorithmPolicy)
+	assert.ErrorContains(t, err, "rsa-sha1 is not accepted")
+
+	// A second XML signature is not described by the policy
+	options.Verifier = etsi119612.VerifierFunc(func(ctx context.Context, data []byte) ([]byte, *x509.Certificate, error) {
+		return data, signer.Certificate, nil
+	})
+	twice := strings.Replace(string(decoyed), "<x:Signature", `<ds:Signature xmlns:ds="http://www.w3.org/2000/09/xmldsig#"/><x:Signature`, 1)
+	_, err = etsi119612.ParseTSL([]byte(twice), "https://example.com/tsl.xml", options)
+	assert.ErrorIs(t, err, etsi119612.ErrSignatureInfo)
+	assert.ErrorContains(t, err, "2 Signature elements")
+	_, _, err = etsi119612.LocalVerifier{}.Verify(context.Background(), []byte(twice))
+	assert.ErrorContains(t, err, "2 Signature elements")
+}
@@ -0,0 +1,123 @@
+package etsi119612
+
+import (
+	"crypto/x509"
+	"fmt"
+	"reflect"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// FetchCache holds parsed TSLs by URL so that a TSL referenced from several
+// lists is fetched only once. Set TSLFetchOptions.Cache to share a cache
+// between calls of FetchTSLWithReferencesAndOptions; root TSLs are always
+// fetched and stored, referenced TSLs are taken from the cache when present.
+//
+// TSLs are kept apart by the options ParseTSL accepts them with: Strict,
+// AllowDoctype, Verifier, SignerRoots and the algorithm policy for their URL.
+// A fetch therefore never reuses a TSL that was not validated, or whose
+// signature was accepted under a weaker policy, as it would check it. A
+// Verifier is told apart by its address, so only TSLs verified with
+// LocalVerifier or a Verifier that is a pointer are cached; those verified
+// with other values, such as a VerifierFunc, are not. A FetchCache is safe for concurrent use. The
+// zero value is not usable, create one with NewFetchCache.
+type FetchCache struct {
+	mu     sync.Mutex
+	tsls   map[fetchCacheKey]*TSL
+	hits   int
+	misses int
+}
+
+type fetchCacheKey struct {
+	url          string
+	strict       bool
+	allowDoctype bool
+	verifier     Verifier
+	signerRoots  *x509.CertPool
+	policy       string
+}
+
+// cacheKey returns the key of the TSL at url fetched with options, and false
+// if the Verifier of options has no identity to key on.
+func (options TSLFetchOptions) cacheKey(url string) (fetchCacheKey, bool) {
+	verifier := options.verifier()
+	// Values of a comparable type may still hold funcs in interface fields,
+	// which panic when hashed; pointers are hashed by address
+	if _, local := verifier.(LocalVerifier); !local && reflect.ValueOf(verifier).Kind() != reflect.Pointer {
+		return fetchCacheKey{}, false
+	}
+	policy := options.algorithmPolicy(url)
+	return fetchCacheKey{
+		url:          url,
+		strict:       options.Strict,
+		allowDoctype: options.AllowDoctype,
+		verifier:     verifier,
+		signerRoots:  options.SignerRoots,
+		policy: fmt.Sprintf("%q %q %t %d", policy.SignatureAlgorithms, policy.DigestAlgorithms,
+			policy.AllowSHA1, policy.minRSAKeySize()),
+	}, true
+}
+
+// NewFetchCache creates an empty FetchCache.
+func NewFetchCache() *FetchCache {
+	return &FetchCache{tsls: make(map[fetchCacheKey]*TSL)}
+}
+
+// Get returns the TSL cached for url under options and whether it was found.
+func (c *FetchCache) Get(url string, options TSLFetchOptions) (*TSL, bool) {
+	key, cacheable := options.cacheKey(url)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	tsl, ok := c.tsls[key]
+	ok = ok && cacheable
+	if ok {
+		c.hits++
+	} else {
+		c.misses++
+	}
+	return tsl, ok
+}
+
+// Put stores the TSL fetched from url with options.
+func (c *FetchCache) Put(url string, options TSLFetchOptions, tsl *TSL) {
+	key, ok := options.cacheKey(url)
+	if !ok {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tsls[key] = tsl
+}
+
+// Len returns the number of cached TSLs.
+func (c *FetchCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.tsls)
+}
+
+// Stats returns the number of lookups that found a TSL and that did not.
+func (c *FetchCache) Stats() (hits, misses int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hits, c.misses
+}
+
+// fetchReferenced fetches the TSL a pointer refers to, using options.Cache if set.
+func fetchReferenced(url string, options TSLFetchOptions) (*TSL, error) {
+	if options.Cache != nil {
+		if tsl, ok := options.Cache.Get(url, options); ok {
+			log.Debugf("g119612: Using cached TSL for %s", url)
+			return tsl, nil
+		}
+	}
+	tsl, err := FetchTSLWithOptions(url, options)
+	if err != nil {
+		return nil, err
+	}
+	if options.Cache != nil {
+		options.Cache.Put(url, options, tsl)
+	}
+	return tsl, nil
+}
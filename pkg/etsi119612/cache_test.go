@@ -0,0 +1,157 @@
+package etsi119612_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/h2non/gock"
+	"github.com/sirosfoundation/g119612/pkg/etsi119612"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetchCache_SharedReference(t *testing.T) {
+	defer gock.Off()
+	gock.New("https://example.com").Get("/main.xml").Times(2).Reply(200).File("testdata/TSL-with-pointer.xml")
+	gock.New("https://example.com").Get("/other.xml").Reply(200).File("testdata/TSL-with-typed-pointer.xml")
+	// Only one response for the shared reference
+	gock.New("https://example.com").Get("/referenced.xml").Reply(200).File("testdata/EWC-TL.xml")
+
+	cache := etsi119612.NewFetchCache()
+	options := etsi119612.TSLFetchOptions{Timeout: 30 * time.Second, MaxDereferenceDepth: 1, Cache: cache}
+
+	first, err := etsi119612.FetchTSLWithReferencesAndOptions("https://example.com/main.xml", options)
+	require.NoError(t, err)
+	require.Len(t, first, 2)
+
+	second, err := etsi119612.FetchTSLWithReferencesAndOptions("https://example.com/other.xml", options)
+	require.NoError(t, err)
+	require.Len(t, second, 2)
+	assert.Same(t, first[1], second[1], "the shared reference is reused")
+	// Pointer metadata is still checked against the cached TSL
+	assert.NotEmpty(t, second[0].PointerMismatches)
+
+	// Root TSLs are fetched again, references are not
+	third, err := etsi119612.FetchTSLWithReferencesAndOptions("https://example.com/main.xml", options)
+	require.NoError(t, err)
+	require.Len(t, third, 2)
+	assert.NotSame(t, first[0], third[0])
+	assert.Same(t, first[1], third[1])
+	assert.True(t, gock.IsDone())
+
+	assert.Equal(t, 3, cache.Len())
+	hits, misses := cache.Stats()
+	assert.Equal(t, 2, hits)
+	assert.Equal(t, 1, misses)
+}
+
+func TestFetchReferencesWithOptions(t *testing.T) {
+	defer gock.Off()
+	gock.New("https://example.com").Get("/main.xml").Reply(200).File("testdata/TSL-with-pointer.xml")
+	gock.New("https://example.com").Get("/referenced.xml").Reply(200).File("testdata/EWC-TL.xml")
+
+	cache := etsi119612.NewFetchCache()
+	options := etsi119612.TSLFetchOptions{Timeout: 30 * time.Second, MaxDereferenceDepth: 1, Cache: cache}
+	root, err := etsi119612.FetchTSLWithContext(context.Background(), "https://example.com/main.xml", options)
+	require.NoError(t, err)
+	assert.Empty(t, root.Referenced, "references are not fetched with the root")
+
+	tsls := root.FetchReferencesWithOptions(options)
+	require.Len(t, tsls, 2)
+	assert.Same(t, root, tsls[0])
+	assert.Equal(t, []*etsi119612.TSL{tsls[1]}, root.Referenced)
+	assert.Equal(t, 2, cache.Len())
+	assert.True(t, gock.IsDone())
+}
+
+func TestFetchCache_Disabled(t *testing.T) {
+	defer gock.Off()
+	gock.New("https://example.com").Get("/main.xml").Reply(200).File("testdata/TSL-with-pointer.xml")
+	gock.New("https://example.com").Get("/other.xml").Reply(200).File("testdata/TSL-with-typed-pointer.xml")
+	gock.New("https://example.com").Get("/referenced.xml").Times(2).Reply(200).File("testdata/EWC-TL.xml")
+
+	options := etsi119612.TSLFetchOptions{Timeout: 30 * time.Second, MaxDereferenceDepth: 1}
+	first, err := etsi119612.FetchTSLWithReferencesAndOptions("https://example.com/main.xml", options)
+	require.NoError(t, err)
+	second, err := etsi119612.FetchTSLWithReferencesAndOptions("https://example.com/other.xml", options)
+	require.NoError(t, err)
+	require.Len(t, first, 2)
+	require.Len(t, second, 2)
+	assert.NotSame(t, first[1], second[1])
+	assert.True(t, gock.IsDone())
+}
+
+func TestFetchCache_StrictSeparated(t *testing.T) {
+	cache := etsi119612.NewFetchCache()
+	tsl := &etsi119612.TSL{Source: "https://example.com/a.xml"}
+	cache.Put(tsl.Source, etsi119612.TSLFetchOptions{}, tsl)
+
+	_, ok := cache.Get(tsl.Source, etsi119612.TSLFetchOptions{Strict: true})
+	assert.False(t, ok, "a strict lookup must not reuse an unvalidated TSL")
+	cached, ok := cache.Get(tsl.Source, etsi119612.TSLFetchOptions{})
+	assert.True(t, ok)
+	assert.Same(t, tsl, cached)
+}
+
+func TestFetchCache_AcceptanceOptionsSeparated(t *testing.T) {
+	cache := etsi119612.NewFetchCache()
+	tsl := &etsi119612.TSL{Source: "https://example.com/a.xml"}
+	lenient := etsi119612.TSLFetchOptions{
+		AllowDoctype:    true,
+		AlgorithmPolicy: etsi119612.AlgorithmPolicy{AllowSHA1: true, MinRSAKeySize: -1},
+	}
+	cache.Put(tsl.Source, lenient, tsl)
+	cached, ok := cache.Get(tsl.Source, lenient)
+	require.True(t, ok)
+	assert.Same(t, tsl, cached)
+
+	for name, options := range map[string]etsi119612.TSLFetchOptions{
+		"default":        {},
+		"doctype":        {AllowDoctype: true},
+		"sha1":           {AlgorithmPolicy: etsi119612.AlgorithmPolicy{AllowSHA1: true}},
+		"key size":       {AllowDoctype: true, AlgorithmPolicy: etsi119612.AlgorithmPolicy{AllowSHA1: true}},
+		"verifier":       {AllowDoctype: true, AlgorithmPolicy: lenient.AlgorithmPolicy, Verifier: &etsi119612.LocalVerifier{}},
+		"algorithm list": {AllowDoctype: true, AlgorithmPolicy: etsi119612.AlgorithmPolicy{AllowSHA1: true, MinRSAKeySize: -1, SignatureAlgorithms: []string{"rsa-sha256"}}},
+	} {
+		_, ok := cache.Get(tsl.Source, options)
+		assert.False(t, ok, name)
+	}
+
+	// A SHA-1 exemption for the URL is the same policy as accepting SHA-1
+	exempt := etsi119612.TSLFetchOptions{AllowSHA1URLs: []string{tsl.Source}}
+	cache.Put(tsl.Source, etsi119612.TSLFetchOptions{AlgorithmPolicy: etsi119612.AlgorithmPolicy{AllowSHA1: true}}, tsl)
+	_, ok = cache.Get(tsl.Source, exempt)
+	assert.True(t, ok)
+	_, ok = cache.Get("https://example.com/b.xml", exempt)
+	assert.False(t, ok)
+
+	// TSLs verified with a function are not cached
+	verifier := etsi119612.TSLFetchOptions{Verifier: etsi119612.VerifierFunc(etsi119612.LocalVerifier{}.Verify)}
+	before := cache.Len()
+	cache.Put(tsl.Source, verifier, tsl)
+	assert.Equal(t, before, cache.Len())
+	_, ok = cache.Get(tsl.Source, verifier)
+	assert.False(t, ok)
+
+	// Nor are those verified with a value holding a function, whose type is
+	// comparable but whose values cannot be hashed
+	wrapped := etsi119612.TSLFetchOptions{Verifier: wrappingVerifier{etsi119612.VerifierFunc(etsi119612.LocalVerifier{}.Verify)}}
+	assert.NotPanics(t, func() {
+		cache.Put(tsl.Source, wrapped, tsl)
+		_, ok = cache.Get(tsl.Source, wrapped)
+	})
+	assert.False(t, ok)
+	assert.Equal(t, before, cache.Len())
+
+	// A pointer is its own identity
+	pointer := etsi119612.TSLFetchOptions{Verifier: &wrappingVerifier{etsi119612.VerifierFunc(etsi119612.LocalVerifier{}.Verify)}}
+	cache.Put(tsl.Source, pointer, tsl)
+	_, ok = cache.Get(tsl.Source, pointer)
+	assert.True(t, ok)
+}
+
+// wrappingVerifier is a comparable Verifier delegating to another.
+type wrappingVerifier struct {
+	etsi119612.Verifier
+}
@@ -8,4 +8,57 @@ var (
 	ErrInvalidDate        = errors.New("not currently valid")
 	ErrInvalidStatus      = errors.New("status is not recognized or granted")
 	ErrInvalidConstraints = errors.New("service constraints not fulfilled")
+	ErrStrictValidation   = errors.New("TSL failed strict validation")
+	ErrPointerMismatch    = errors.New("referenced TSL does not match pointer metadata")
+	ErrNotTrusted         = errors.New("certificate is not issued under a trusted service")
+	ErrNotMirrored        = errors.New("TSL is not in the mirror")
+	ErrAlgorithmPolicy    = errors.New("TSL signature rejected by the algorithm policy")
+	ErrSignatureInfo      = errors.New("cannot read the TSL signature")
+	ErrSignerNotPinned    = errors.New("TSL is not signed by a pinned certificate")
+	ErrSignerUntrusted    = errors.New("TSL signer does not chain to a trusted root")
 )
+
+// TransientError wraps a fetch failure that may go away when the fetch is
+// retried: a timeout, a failed connection, an HTTP 5xx response or a 408 or
+// 429 response. Fetches failing with a TransientError are retried up to
+// TSLFetchOptions.Retries times.
+type TransientError struct {
+	StatusCode int   // HTTP status of the response, 0 if there was none
+	Err        error // The underlying error
+}
+
+func (e *TransientError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *TransientError) Unwrap() error {
+	return e.Err
+}
+
+// PermanentError wraps a fetch failure that retrying will not fix: any other
+// HTTP error status such as 404, a missing file or mirror entry, and a
+// document that does not parse, verify or validate.
+type PermanentError struct {
+	StatusCode int   // HTTP status of the response, 0 if there was none
+	Err        error // The underlying error
+}
+
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// IsTransient reports whether err, or an error it wraps, is a TransientError.
+func IsTransient(err error) bool {
+	var transient *TransientError
+	return errors.As(err, &transient)
+}
+
+// IsPermanent reports whether err, or an error it wraps, is a PermanentError.
+func IsPermanent(err error) bool {
+	var permanent *PermanentError
+	return errors.As(err, &permanent)
+}
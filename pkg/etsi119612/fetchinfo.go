@@ -0,0 +1,21 @@
+package etsi119612
+
+import "time"
+
+// FetchInfo describes how a TSL document was obtained, to help debugging slow
+// or flaky upstreams. It is set on the TSLs returned by the fetch functions.
+//
+// A TSL taken from a FetchCache is the TSL of the original fetch and keeps its
+// FetchInfo; CacheHit is only set for documents read from a cache instead of
+// being fetched, such as the CacheDir of QuickVerify.
+type FetchInfo struct {
+	URL        string        `json:"url"`                   // The requested URL
+	FinalURL   string        `json:"final_url"`             // The URL after following redirects
+	StatusCode int           `json:"status_code,omitempty"` // HTTP status, 0 for file:// URLs and cache hits
+	Size       int           `json:"size"`                  // Size of the document in bytes
+	Duration   time.Duration `json:"duration"`              // Time spent fetching the document, excluding parsing
+	ETag       string        `json:"etag,omitempty"`        // ETag header of the response
+	CacheHit   bool          `json:"cache_hit"`             // The document was read from a cache
+	Retries    int           `json:"retries,omitempty"`     // Transient failures retried before the fetch succeeded
+	FetchedAt  time.Time     `json:"fetched_at"`            // When the fetch started
+}
@@ -0,0 +1,52 @@
+package etsi119612_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirosfoundation/g119612/pkg/etsi119612"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetchInfo(t *testing.T) {
+	data, err := os.ReadFile("testdata/test-trust-list-no-sig.xml")
+	require.NoError(t, err)
+	mux := http.NewServeMux()
+	mux.Handle("/old.xml", http.RedirectHandler("/tsl.xml", http.StatusMovedPermanently))
+	mux.HandleFunc("/tsl.xml", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = w.Write(data)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	tsl, err := etsi119612.FetchTSLWithOptions(srv.URL+"/old.xml", etsi119612.DefaultTSLFetchOptions)
+	require.NoError(t, err)
+	info := tsl.FetchInfo
+	require.NotNil(t, info)
+	assert.Equal(t, srv.URL+"/old.xml", info.URL)
+	assert.Equal(t, srv.URL+"/tsl.xml", info.FinalURL)
+	assert.Equal(t, http.StatusOK, info.StatusCode)
+	assert.Equal(t, len(data), info.Size)
+	assert.Equal(t, `"v1"`, info.ETag)
+	assert.False(t, info.CacheHit)
+	assert.False(t, info.FetchedAt.IsZero())
+	assert.Positive(t, info.Duration)
+
+	path, err := filepath.Abs("testdata/test-trust-list-no-sig.xml")
+	require.NoError(t, err)
+	tsl, err = etsi119612.FetchTSLWithOptions("file://"+path, etsi119612.DefaultTSLFetchOptions)
+	require.NoError(t, err)
+	require.NotNil(t, tsl.FetchInfo)
+	assert.Equal(t, 0, tsl.FetchInfo.StatusCode)
+	assert.Equal(t, "file://"+path, tsl.FetchInfo.FinalURL)
+	assert.Equal(t, len(data), tsl.FetchInfo.Size)
+
+	tsl, err = etsi119612.ParseTSL(data, "memory", etsi119612.DefaultTSLFetchOptions)
+	require.NoError(t, err)
+	assert.Nil(t, tsl.FetchInfo, "parsed TSLs were not fetched")
+}
@@ -0,0 +1,159 @@
+package etsi119612_test
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirosfoundation/g119612/pkg/etsi119612"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+// The fuzz targets below are seeded from the TSLs in testdata. Run one with
+// for example
+//
+//	go test ./pkg/etsi119612 -run '^$' -fuzz FuzzParseTSL
+//
+// Without -fuzz they run the seed corpus as regular tests.
+
+// addTestdataCorpus adds every XML document in testdata to the seed corpus of f.
+func addTestdataCorpus(f *testing.F, add func(data []byte)) {
+	f.Helper()
+	files, err := filepath.Glob(filepath.Join("testdata", "*.xml"))
+	require.NoError(f, err)
+	require.NotEmpty(f, files)
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		require.NoError(f, err)
+		add(data)
+	}
+	add([]byte(""))
+	add([]byte("<TrustServiceStatusList/>"))
+}
+
+// quietFuzzLogging keeps the per-input parser logging out of the fuzzer output.
+func quietFuzzLogging(f *testing.F) {
+	level := log.GetLevel()
+	log.SetLevel(log.FatalLevel)
+	f.Cleanup(func() { log.SetLevel(level) })
+}
+
+// exerciseTSL calls the accessors callers use on a parsed TSL.
+func exerciseTSL(tsl *etsi119612.TSL, policy *etsi119612.TSPServicePolicy) {
+	_ = tsl.String()
+	_ = tsl.Summary()
+	_ = tsl.SignatureInfo()
+	tsl.WithTrustServices(func(tsp *etsi119612.TSPType, svc *etsi119612.TSPServiceType) {
+		svc.WithCertificates(func(cert *x509.Certificate) {
+			_ = tsp.Validate(svc, []*x509.Certificate{cert}, policy)
+		})
+	})
+	_ = tsl.ToCertPoolWithReferences(policy)
+	for _, pointer := range tsl.Pointers {
+		for _, ref := range tsl.Referenced {
+			_ = etsi119612.CheckPointerConsistency(tsl, pointer, ref)
+		}
+	}
+}
+
+func FuzzParseTSL(f *testing.F) {
+	quietFuzzLogging(f)
+	addTestdataCorpus(f, func(data []byte) {
+		f.Add(data, false)
+		f.Add(data, true)
+	})
+	f.Fuzz(func(t *testing.T, data []byte, strict bool) {
+		options := etsi119612.DefaultTSLFetchOptions
+		options.Strict = strict
+		tsl, err := etsi119612.ParseTSL(data, "fuzz.xml", options)
+		if err != nil {
+			if tsl != nil {
+				t.Fatalf("ParseTSL returned a TSL with error %v", err)
+			}
+			return
+		}
+		exerciseTSL(tsl, etsi119612.PolicyAll)
+	})
+}
+
+func FuzzDereferencePointers(f *testing.F) {
+	quietFuzzLogging(f)
+	pointer, err := os.ReadFile(filepath.Join("testdata", "TSL-with-typed-pointer.xml"))
+	require.NoError(f, err)
+	addTestdataCorpus(f, func(data []byte) {
+		f.Add(pointer, data, false)
+		f.Add(data, pointer, true)
+	})
+	f.Fuzz(func(t *testing.T, root, referenced []byte, strictPointers bool) {
+		rootTSL, err := etsi119612.ParseTSL(root, "fuzz-root.xml", etsi119612.DefaultTSLFetchOptions)
+		if err != nil {
+			return
+		}
+		refTSL, err := etsi119612.ParseTSL(referenced, "fuzz-referenced.xml", etsi119612.DefaultTSLFetchOptions)
+		if err != nil {
+			return
+		}
+
+		// Every pointer of the root resolves to the referenced TSL through
+		// the cache; anything else would be fetched and is filtered out.
+		cache := etsi119612.NewFetchCache()
+		known := make(map[string]bool)
+		for _, p := range rootTSL.Pointers {
+			cache.Put(p.Location, etsi119612.DefaultTSLFetchOptions, refTSL)
+			known[p.Location] = true
+		}
+		file := filepath.Join(t.TempDir(), "root.xml")
+		require.NoError(t, os.WriteFile(file, root, 0600))
+		rootURL := "file://" + file
+		known[rootURL] = true
+
+		options := etsi119612.DefaultTSLFetchOptions
+		options.Cache = cache
+		options.StrictPointers = strictPointers
+		options.PointerFilter = func(p etsi119612.PointerInfo) bool { return known[p.Location] }
+		tsls, err := etsi119612.FetchTSLWithReferencesAndOptions(rootURL, options)
+		if err != nil {
+			return
+		}
+		for _, tsl := range tsls {
+			exerciseTSL(tsl, etsi119612.PolicyAll)
+		}
+	})
+}
+
+func FuzzPolicyEvaluation(f *testing.F) {
+	quietFuzzLogging(f)
+	policies := []string{
+		`{}`,
+		`{"statuses":[]}`,
+		`{"serviceTypes":["http://uri.etsi.org/TrstSvc/Svctype/CA/QC"]}`,
+		`{"serviceTypes":["http://uri.etsi.org/TrstSvc/Svctype/TSA/QTST"],"statuses":["https://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/withdrawn/"]}`,
+	}
+	addTestdataCorpus(f, func(data []byte) {
+		for _, policy := range policies {
+			f.Add(data, []byte(policy))
+		}
+	})
+	f.Fuzz(func(t *testing.T, data, policyJSON []byte) {
+		var policy etsi119612.TSPServicePolicy
+		if err := json.Unmarshal(policyJSON, &policy); err != nil {
+			return
+		}
+		tsl, err := etsi119612.ParseTSL(data, "fuzz.xml", etsi119612.DefaultTSLFetchOptions)
+		if err != nil {
+			return
+		}
+		exerciseTSL(tsl, &policy)
+
+		// A policy survives a round trip through its serialized form
+		encoded, err := json.Marshal(policy)
+		require.NoError(t, err)
+		var decoded etsi119612.TSPServicePolicy
+		require.NoError(t, json.Unmarshal(encoded, &decoded))
+		require.ElementsMatch(t, policy.ServiceStatus, decoded.ServiceStatus)
+		require.ElementsMatch(t, policy.ServiceTypeIdentifier, decoded.ServiceTypeIdentifier)
+	})
+}
@@ -0,0 +1,175 @@
+package etsi119612
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HostOverride replaces some fetch options for the hosts matching Pattern, as
+// hosts differ widely: the LOTL host answers slowly with a large list, while
+// small national hosts may ask to be contacted rarely. Unset fields keep the
+// value of the TSLFetchOptions the override is part of.
+type HostOverride struct {
+	// Pattern is a host name such as "ec.europa.eu", or "*.example.com" for
+	// example.com and all its subdomains. Ports are not part of the pattern.
+	Pattern string
+
+	// UserAgent, if set, replaces TSLFetchOptions.UserAgent.
+	UserAgent string
+
+	// Timeout, if set, replaces TSLFetchOptions.Timeout.
+	Timeout time.Duration
+
+	// AcceptHeaders, if set, replaces TSLFetchOptions.AcceptHeaders.
+	AcceptHeaders []string
+
+	// Retries, if set, replaces TSLFetchOptions.Retries.
+	Retries *int
+
+	// RetryDelay, if set, replaces TSLFetchOptions.RetryDelay.
+	RetryDelay time.Duration
+
+	// MinInterval, if set, is the minimum time between the starts of two
+	// requests to a matching host, retries included. The limit is shared by
+	// all fetches of the process, so concurrent loads of lists from the
+	// same host are spaced as well.
+	MinInterval time.Duration
+}
+
+// matches returns how well the override matches host: 0 if it does not,
+// otherwise the higher the more specific the pattern is. An exact host name
+// is more specific than any wildcard pattern.
+func (o HostOverride) matches(host string) int {
+	pattern := strings.ToLower(o.Pattern)
+	if domain, ok := strings.CutPrefix(pattern, "*."); ok {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return len(domain) + 1
+		}
+		return 0
+	}
+	if host == pattern {
+		return len(pattern) + 2
+	}
+	return 0
+}
+
+// String returns the override in the form of the host option of the
+// set-fetch-options pipeline step, e.g. "ec.europa.eu timeout:3m0s".
+func (o HostOverride) String() string {
+	parts := []string{o.Pattern}
+	if o.Timeout > 0 {
+		parts = append(parts, "timeout:"+o.Timeout.String())
+	}
+	if len(o.AcceptHeaders) > 0 {
+		parts = append(parts, "accept:"+strings.Join(o.AcceptHeaders, ","))
+	}
+	if o.Retries != nil {
+		parts = append(parts, fmt.Sprintf("retries:%d", *o.Retries))
+	}
+	if o.RetryDelay > 0 {
+		parts = append(parts, "retry-delay:"+o.RetryDelay.String())
+	}
+	if o.MinInterval > 0 {
+		parts = append(parts, "min-interval:"+o.MinInterval.String())
+	}
+	if o.UserAgent != "" {
+		parts = append(parts, "user-agent:"+o.UserAgent)
+	}
+	return strings.Join(parts, " ")
+}
+
+// hostOverride returns the most specific of options.HostOverrides matching
+// the host of rawURL, or nil if there is none.
+func (options TSLFetchOptions) hostOverride(rawURL string) *HostOverride {
+	if len(options.HostOverrides) == 0 {
+		return nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil
+	}
+	host := strings.ToLower(u.Hostname())
+	var best *HostOverride
+	bestScore := 0
+	for i := range options.HostOverrides {
+		if score := options.HostOverrides[i].matches(host); score > bestScore {
+			best, bestScore = &options.HostOverrides[i], score
+		}
+	}
+	return best
+}
+
+// ForURL returns the options used for fetching rawURL: options with the
+// fields set in the HostOverride matching its host replacing their values.
+// Fetches made by this package apply the overrides themselves; ForURL is for
+// callers making their own requests with the options.
+func (options TSLFetchOptions) ForURL(rawURL string) TSLFetchOptions {
+	override := options.hostOverride(rawURL)
+	if override == nil {
+		return options
+	}
+	if override.UserAgent != "" {
+		options.UserAgent = override.UserAgent
+	}
+	if override.Timeout > 0 {
+		options.Timeout = override.Timeout
+	}
+	if len(override.AcceptHeaders) > 0 {
+		options.AcceptHeaders = slices.Clone(override.AcceptHeaders)
+	}
+	if override.Retries != nil {
+		options.Retries = *override.Retries
+	}
+	if override.RetryDelay > 0 {
+		options.RetryDelay = override.RetryDelay
+	}
+	return options
+}
+
+// hostLimiters holds the hostLimiter of each host with a MinInterval.
+var hostLimiters sync.Map
+
+// hostLimiter spaces the requests to one host.
+type hostLimiter struct {
+	mu   sync.Mutex
+	next time.Time // Earliest start of the next request
+}
+
+// waitForHost blocks until a request to rawURL may start under the
+// MinInterval of its HostOverride. It returns the error of ctx if ctx is done
+// first.
+func (options TSLFetchOptions) waitForHost(ctx context.Context, rawURL string) error {
+	override := options.hostOverride(rawURL)
+	if override == nil || override.MinInterval <= 0 {
+		return nil
+	}
+	u, _ := url.Parse(rawURL)
+	value, _ := hostLimiters.LoadOrStore(strings.ToLower(u.Hostname()), &hostLimiter{})
+	limiter := value.(*hostLimiter)
+
+	limiter.mu.Lock()
+	start := time.Now()
+	if limiter.next.After(start) {
+		start = limiter.next
+	}
+	limiter.next = start.Add(override.MinInterval)
+	limiter.mu.Unlock()
+
+	delay := time.Until(start)
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,99 @@
+package etsi119612_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirosfoundation/g119612/pkg/etsi119612"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTSLFetchOptions_ForURL(t *testing.T) {
+	five := 5
+	options := etsi119612.TSLFetchOptions{
+		UserAgent:     "Default/1.0",
+		Timeout:       30 * time.Second,
+		AcceptHeaders: []string{"application/xml"},
+		Retries:       1,
+		HostOverrides: []etsi119612.HostOverride{
+			{Pattern: "*.europa.eu", Timeout: time.Minute},
+			{Pattern: "ec.europa.eu", Timeout: 3 * time.Minute, Retries: &five},
+			{Pattern: "tsl.example.se", UserAgent: "Polite/1.0", AcceptHeaders: []string{"text/xml"}, RetryDelay: time.Minute},
+		},
+	}
+
+	// The exact host name is more specific than the wildcard
+	lotl := options.ForURL("https://ec.europa.eu/tools/lotl/eu-lotl.xml")
+	assert.Equal(t, 3*time.Minute, lotl.Timeout)
+	assert.Equal(t, 5, lotl.Retries)
+	assert.Equal(t, "Default/1.0", lotl.UserAgent)
+
+	for _, url := range []string{"https://other.europa.eu/tl.xml", "https://europa.eu/tl.xml", "https://EC.Europa.EU:8443/tl.xml"} {
+		assert.NotEqual(t, 30*time.Second, options.ForURL(url).Timeout, url)
+	}
+	assert.Equal(t, time.Minute, options.ForURL("https://other.europa.eu/tl.xml").Timeout)
+
+	se := options.ForURL("https://tsl.example.se/tsl.xml")
+	assert.Equal(t, "Polite/1.0", se.UserAgent)
+	assert.Equal(t, []string{"text/xml"}, se.AcceptHeaders)
+	assert.Equal(t, time.Minute, se.RetryDelay)
+	assert.Equal(t, 1, se.Retries)
+	assert.Equal(t, 30*time.Second, se.Timeout)
+
+	for _, url := range []string{"https://example.se/tsl.xml", "https://noteuropa.eu/tl.xml", "file:///tmp/tl.xml", "::invalid"} {
+		assert.Equal(t, 30*time.Second, options.ForURL(url).Timeout, url)
+		assert.Equal(t, "Default/1.0", options.ForURL(url).UserAgent, url)
+	}
+
+	assert.Equal(t, "ec.europa.eu timeout:3m0s retries:5", options.HostOverrides[1].String())
+	assert.Equal(t, "tsl.example.se accept:text/xml retry-delay:1m0s user-agent:Polite/1.0", options.HostOverrides[2].String())
+}
+
+func TestFetchTSLWithOptions_HostOverride(t *testing.T) {
+	data, err := os.ReadFile("./testdata/EWC-TL.xml")
+	require.NoError(t, err)
+	var requests atomic.Int32
+	var userAgent atomic.Value
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgent.Store(r.UserAgent())
+		if requests.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write(data)
+	}))
+	defer server.Close()
+
+	// Without the override the transient failure is not retried
+	options := etsi119612.TSLFetchOptions{UserAgent: "Default/1.0", Timeout: 5 * time.Second}
+	_, err = etsi119612.FetchTSLWithOptions(server.URL+"/tl.xml", options)
+	assert.True(t, etsi119612.IsTransient(err))
+
+	requests.Store(0)
+	retries := 1
+	options.HostOverrides = []etsi119612.HostOverride{{
+		Pattern:    "127.0.0.1",
+		UserAgent:  "Override/1.0",
+		Retries:    &retries,
+		RetryDelay: time.Millisecond,
+	}}
+	tsl, err := etsi119612.FetchTSLWithOptions(server.URL+"/tl.xml", options)
+	require.NoError(t, err)
+	assert.Equal(t, 1, tsl.FetchInfo.Retries)
+	assert.Equal(t, "Override/1.0", userAgent.Load())
+
+	// Requests to a host with a minimum interval are spaced
+	requests.Store(1)
+	options.HostOverrides[0].MinInterval = 100 * time.Millisecond
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		_, err = etsi119612.FetchTSLWithOptions(server.URL+"/tl.xml", options)
+		require.NoError(t, err)
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond)
+}
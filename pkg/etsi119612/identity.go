@@ -0,0 +1,70 @@
+package etsi119612
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TSLIdentity describes a TSL by what an operator recognizes it by, for logs
+// and error messages: the territory, the scheme operator, the sequence number
+// and the location it was loaded from. Position-based descriptions such as
+// "TSL 5" depend on the order of a load and say little about which list broke.
+type TSLIdentity struct {
+	Territory string // SchemeTerritory, such as "SE"
+	Operator  string // Scheme operator name, in English if available
+	Sequence  int    // TSLSequenceNumber
+	Source    string // URL or path the TSL was loaded from
+}
+
+// Identity returns the identity of the TSL. The fields that the TSL does not
+// provide are left empty.
+func (tsl *TSL) Identity() TSLIdentity {
+	if tsl == nil {
+		return TSLIdentity{}
+	}
+	id := TSLIdentity{Source: tsl.Source}
+	if info := tsl.StatusList.TslSchemeInformation; info != nil {
+		id.Territory = info.TslSchemeTerritory
+		id.Sequence = info.TSLSequenceNumber
+		id.Operator = FindByLanguage(info.TslSchemeOperatorName, "en", "")
+		if id.Operator == "" && info.TslSchemeOperatorName != nil {
+			for _, n := range info.TslSchemeOperatorName.Name {
+				if n != nil && n.NonEmptyNormalizedString != nil {
+					id.Operator = string(*n.NonEmptyNormalizedString)
+					break
+				}
+			}
+		}
+	}
+	return id
+}
+
+// IsZero reports whether nothing is known about the TSL.
+func (id TSLIdentity) IsZero() bool {
+	return id == TSLIdentity{}
+}
+
+// String formats the identity as in
+//
+//	SE "Post- och telestyrelsen" sequence 42 from https://example.com/SE.xml
+//
+// leaving out the parts that are unknown.
+func (id TSLIdentity) String() string {
+	var parts []string
+	if id.Territory != "" {
+		parts = append(parts, id.Territory)
+	}
+	if id.Operator != "" {
+		parts = append(parts, fmt.Sprintf("%q", id.Operator))
+	}
+	if id.Sequence != 0 {
+		parts = append(parts, fmt.Sprintf("sequence %d", id.Sequence))
+	}
+	if id.Source != "" {
+		parts = append(parts, "from "+id.Source)
+	}
+	if len(parts) == 0 {
+		return "unidentified TSL"
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,44 @@
+package etsi119612_test
+
+import (
+	"testing"
+
+	"github.com/sirosfoundation/g119612/pkg/etsi119612"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTSLIdentity(t *testing.T) {
+	sv, en := etsi119612.Lang("sv"), etsi119612.Lang("en")
+	svName := etsi119612.NonEmptyNormalizedString("Post- och telestyrelsen")
+	enName := etsi119612.NonEmptyNormalizedString("Swedish Post and Telecom Authority")
+	tsl := &etsi119612.TSL{
+		Source: "https://example.com/SE.xml",
+		StatusList: etsi119612.TrustStatusListType{TslSchemeInformation: &etsi119612.TSLSchemeInformationType{
+			TSLSequenceNumber:  42,
+			TslSchemeTerritory: "SE",
+			TslSchemeOperatorName: &etsi119612.InternationalNamesType{Name: []*etsi119612.MultiLangNormStringType{
+				nil,
+				{XmlLangAttr: &sv, NonEmptyNormalizedString: &svName},
+			}},
+		}},
+	}
+
+	id := tsl.Identity()
+	assert.Equal(t, etsi119612.TSLIdentity{
+		Territory: "SE",
+		Operator:  "Post- och telestyrelsen",
+		Sequence:  42,
+		Source:    "https://example.com/SE.xml",
+	}, id, "without an English name the first name is used")
+	assert.Equal(t, `SE "Post- och telestyrelsen" sequence 42 from https://example.com/SE.xml`, id.String())
+	assert.False(t, id.IsZero())
+
+	names := tsl.StatusList.TslSchemeInformation.TslSchemeOperatorName
+	names.Name = append(names.Name, &etsi119612.MultiLangNormStringType{XmlLangAttr: &en, NonEmptyNormalizedString: &enName})
+	assert.Equal(t, "Swedish Post and Telecom Authority", tsl.Identity().Operator)
+
+	assert.Equal(t, "from file.xml", (&etsi119612.TSL{Source: "file.xml"}).Identity().String())
+	var missing *etsi119612.TSL
+	assert.True(t, missing.Identity().IsZero())
+	assert.Equal(t, "unidentified TSL", missing.Identity().String())
+}
@@ -0,0 +1,69 @@
+package etsi119612
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/sirosfoundation/g119612/pkg/ipfs"
+)
+
+// DefaultIPFSGateway is the HTTP gateway ipfs:// URLs are fetched through
+// when TSLFetchOptions.IPFSGateway is not set.
+const DefaultIPFSGateway = "https://ipfs.io"
+
+// maxIPFSDocumentSize bounds the size of a TSL fetched from IPFS.
+const maxIPFSDocumentSize = 64 << 20
+
+// fetchIPFS reads the TSL document at an ipfs:// URL, "ipfs://CID" or
+// "ipfs://CID/path/in/directory", block by block from the gateway of options.
+// Every block is verified against its CID, so the document is the one the CID
+// names even if the gateway is not trusted. The whole fetch is bound to ctx
+// and options.Timeout.
+func fetchIPFS(ctx context.Context, url string, options TSLFetchOptions) ([]byte, error) {
+	root, path, err := ipfs.ParseURL(url)
+	if err != nil {
+		return nil, &PermanentError{Err: err}
+	}
+	client, release := options.fetchClient()
+	defer release()
+	reqCtx, cancel := context.WithTimeout(ctx, options.Timeout)
+	defer cancel()
+
+	get := func(reqCtx context.Context, c ipfs.CID) ([]byte, error) {
+		req, err := http.NewRequestWithContext(reqCtx, "GET", ipfs.GatewayBlockURL(options.ipfsGateway(), c), nil)
+		if err != nil {
+			return nil, &PermanentError{Err: err}
+		}
+		req.Header.Set("User-Agent", options.UserAgent)
+		req.Header.Set("Accept", "application/vnd.ipld.raw")
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, classifyRequestError(ctx, err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, statusError(resp.StatusCode, fmt.Errorf("unexpected HTTP status for block %s: %s", c, resp.Status))
+		}
+		block, err := io.ReadAll(io.LimitReader(resp.Body, maxIPFSDocumentSize+1))
+		if err != nil {
+			return nil, classifyRequestError(ctx, err)
+		}
+		return block, nil
+	}
+	data, err := ipfs.ReadFile(reqCtx, get, root, path, maxIPFSDocumentSize)
+	if err != nil {
+		// Blocks not matching their CID are as permanent as a bad signature
+		return nil, permanentError(err)
+	}
+	return data, nil
+}
+
+// ipfsGateway returns the gateway ipfs:// URLs are fetched through.
+func (options TSLFetchOptions) ipfsGateway() string {
+	if options.IPFSGateway != "" {
+		return options.IPFSGateway
+	}
+	return DefaultIPFSGateway
+}
@@ -0,0 +1,104 @@
+package etsi119612_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/sirosfoundation/g119612/pkg/etsi119612"
+	"github.com/sirosfoundation/g119612/pkg/ipfs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ipfsGateway serves the raw blocks of a store at /ipfs/<cid>, as a trustless
+// gateway does.
+func ipfsGateway(t *testing.T, blocks map[string][]byte) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "raw", r.URL.Query().Get("format"))
+		block, ok := blocks[strings.TrimPrefix(r.URL.Path, "/ipfs/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/vnd.ipld.raw")
+		_, _ = w.Write(block)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// addIPFSFile stores content as a UnixFS file of raw chunks below a directory
+// entry named name and returns the CID of the directory.
+func addIPFSFile(blocks map[string][]byte, name string, content []byte) ipfs.CID {
+	file := ipfs.Node{Type: ipfs.TypeFile}
+	for len(content) > 0 {
+		chunk := content[:min(len(content), 16<<10)]
+		content = content[len(chunk):]
+		c := ipfs.Sum(ipfs.CodecRaw, chunk)
+		blocks[c.String()] = chunk
+		file.Links = append(file.Links, ipfs.Link{CID: c, Size: uint64(len(chunk))})
+	}
+	fileBlock := ipfs.EncodeNode(file)
+	fileCID := ipfs.Sum(ipfs.CodecDagPB, fileBlock)
+	blocks[fileCID.String()] = fileBlock
+	dirBlock := ipfs.EncodeNode(ipfs.Node{Type: ipfs.TypeDirectory, Links: []ipfs.Link{{CID: fileCID, Name: name}}})
+	dirCID := ipfs.Sum(ipfs.CodecDagPB, dirBlock)
+	blocks[dirCID.String()] = dirBlock
+	return dirCID
+}
+
+func TestFetchTSLWithOptions_IPFS(t *testing.T) {
+	original, err := os.ReadFile("testdata/EWC-TL.xml")
+	require.NoError(t, err)
+	blocks := map[string][]byte{}
+	root := addIPFSFile(blocks, "EWC-TL.xml", original)
+	server := ipfsGateway(t, blocks)
+
+	options := etsi119612.DefaultTSLFetchOptions
+	options.IPFSGateway = server.URL
+	options.MaxDereferenceDepth = 0
+	url := "ipfs://" + root.String() + "/EWC-TL.xml"
+	tsl, err := etsi119612.FetchTSLWithOptions(url, options)
+	require.NoError(t, err)
+	assert.Equal(t, url, tsl.Source)
+	assert.Equal(t, original, tsl.Raw)
+	assert.Positive(t, tsl.NumberOfTrustServiceProviders())
+
+	// A file not in the directory is not found
+	_, err = etsi119612.FetchTSLWithOptions("ipfs://"+root.String()+"/missing.xml", options)
+	assert.ErrorContains(t, err, "missing.xml not found")
+	assert.True(t, etsi119612.IsPermanent(err))
+
+	// A gateway altering a block is detected
+	for cid, block := range blocks {
+		if cid != root.String() && !strings.Contains(string(block), "EWC-TL.xml") {
+			tampered := append([]byte(nil), block...)
+			tampered[0] ^= 1
+			blocks[cid] = tampered
+			break
+		}
+	}
+	_, err = etsi119612.FetchTSLWithOptions(url, options)
+	assert.ErrorIs(t, err, ipfs.ErrDigestMismatch)
+	assert.True(t, etsi119612.IsPermanent(err))
+
+	_, err = etsi119612.FetchTSLWithOptions("ipfs://not-a-cid", options)
+	assert.True(t, etsi119612.IsPermanent(err))
+}
+
+func TestFetchTSLWithOptions_IPFSGatewayUnavailable(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	options := etsi119612.DefaultTSLFetchOptions
+	options.IPFSGateway = server.URL
+	_, err := etsi119612.FetchTSLWithOptions("ipfs://bafkreifjjcie6lypi6ny7amxnfftagclbuxndqonfipmb64f2km2devei4", options)
+	var transient *etsi119612.TransientError
+	require.ErrorAs(t, err, &transient)
+	assert.Equal(t, http.StatusServiceUnavailable, transient.StatusCode)
+}
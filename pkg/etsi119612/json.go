@@ -0,0 +1,479 @@
+package etsi119612
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sirosfoundation/g119612/pkg/etsi119612/jsonmodel"
+)
+
+// JSONModel returns the TSL in the JSON representation of package jsonmodel.
+// Empty names and URIs, as found in malformed lists, are left out.
+func (tsl *TSL) JSONModel() *jsonmodel.TSL {
+	doc := &jsonmodel.TSL{
+		Version:   jsonmodel.Version,
+		Source:    tsl.Source,
+		Signed:    tsl.Signed,
+		TSLTag:    tsl.StatusList.TSLTagAttr,
+		ID:        tsl.StatusList.IdAttr,
+		Providers: []jsonmodel.Provider{},
+	}
+	if tsl.Signed && len(tsl.Signer.Raw) > 0 {
+		doc.Signer = jsonCertificate(tsl.Signer.Raw)
+	}
+	if si := tsl.StatusList.TslSchemeInformation; si != nil {
+		doc.SchemeInformation = tsl.jsonSchemeInformation(si)
+	}
+	if list := tsl.StatusList.TslTrustServiceProviderList; list != nil {
+		for _, tsp := range list.TslTrustServiceProvider {
+			if tsp != nil {
+				doc.Providers = append(doc.Providers, jsonProvider(tsp))
+			}
+		}
+	}
+	return doc
+}
+
+// JSONSummary returns the summary of the TSL in the JSON representation of
+// package jsonmodel, for documents that refer to the TSL.
+func (tsl *TSL) JSONSummary() jsonmodel.Summary {
+	id := tsl.Identity()
+	summary := jsonmodel.Summary{
+		Source:         id.Source,
+		Territory:      id.Territory,
+		Operator:       id.Operator,
+		SequenceNumber: id.Sequence,
+	}
+	if si := tsl.StatusList.TslSchemeInformation; si != nil {
+		summary.ListIssueDateTime = si.ListIssueDateTime
+		if si.TslNextUpdate != nil {
+			summary.NextUpdate = si.TslNextUpdate.DateTime
+		}
+	}
+	return summary
+}
+
+// MarshalJSON implements json.Marshaler with the representation of package
+// jsonmodel (see JSONModel).
+func (tsl *TSL) MarshalJSON() ([]byte, error) {
+	return json.Marshal(tsl.JSONModel())
+}
+
+// UnmarshalJSON implements json.Unmarshaler for documents in the
+// representation of package jsonmodel. It fails for documents of another
+// version. The TSL has no Raw document and is not verified; Signed and the
+// signer are taken from the document as they are.
+func (tsl *TSL) UnmarshalJSON(data []byte) error {
+	var doc jsonmodel.TSL
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	parsed, err := TSLFromJSONModel(&doc)
+	if err != nil {
+		return err
+	}
+	*tsl = *parsed
+	return nil
+}
+
+// TSLFromJSONModel returns the TSL described by a document of package
+// jsonmodel. It fails for documents of another version or with certificates
+// that do not parse.
+func TSLFromJSONModel(doc *jsonmodel.TSL) (*TSL, error) {
+	if doc.Version != jsonmodel.Version {
+		return nil, fmt.Errorf("unsupported TSL JSON version %q (expected %q)", doc.Version, jsonmodel.Version)
+	}
+	tsl := &TSL{
+		Source: doc.Source,
+		Signed: doc.Signed,
+		StatusList: TrustStatusListType{
+			TSLTagAttr: doc.TSLTag,
+			IdAttr:     doc.ID,
+		},
+	}
+	if doc.Signer != nil {
+		signer, err := x509.ParseCertificate(doc.Signer.DER)
+		if err != nil {
+			return nil, fmt.Errorf("invalid signer certificate: %w", err)
+		}
+		tsl.Signer = *signer
+	}
+	if si := doc.SchemeInformation; si != nil {
+		tsl.StatusList.TslSchemeInformation = xmlSchemeInformation(si)
+		for _, p := range si.Pointers {
+			tsl.Pointers = append(tsl.Pointers, PointerInfo{Location: p.Location, TSLType: p.TSLType, SchemeTerritory: p.SchemeTerritory, Signers: p.Signers})
+		}
+	}
+	list := &TrustServiceProviderListType{}
+	for i, p := range doc.Providers {
+		tsp, err := xmlProvider(p)
+		if err != nil {
+			return nil, fmt.Errorf("provider %d: %w", i, err)
+		}
+		list.TslTrustServiceProvider = append(list.TslTrustServiceProvider, tsp)
+	}
+	tsl.StatusList.TslTrustServiceProviderList = list
+	return tsl, nil
+}
+
+// jsonSchemeInformation converts the scheme information of the TSL,
+// including the metadata of its pointers.
+func (tsl *TSL) jsonSchemeInformation(si *TSLSchemeInformationType) *jsonmodel.SchemeInformation {
+	doc := &jsonmodel.SchemeInformation{
+		VersionIdentifier:           si.TSLVersionIdentifier,
+		SequenceNumber:              si.TSLSequenceNumber,
+		Type:                        si.TslTSLType,
+		OperatorNames:               jsonLangStrings(LangStrings(si.TslSchemeOperatorName)),
+		OperatorAddress:             jsonAddress(si.SchemeOperatorAddress),
+		SchemeNames:                 jsonLangStrings(LangStrings(si.TslSchemeName)),
+		StatusDeterminationApproach: si.StatusDeterminationApproach,
+		Territory:                   si.TslSchemeTerritory,
+		HistoricalInformationPeriod: si.HistoricalInformationPeriod,
+		ListIssueDateTime:           si.ListIssueDateTime,
+	}
+	if si.TslSchemeInformationURI != nil {
+		doc.InformationURIs = jsonLangStrings(langURIs(si.TslSchemeInformationURI.URI))
+	}
+	if si.TslSchemeTypeCommunityRules != nil {
+		doc.CommunityRules = jsonLangStrings(langURIs(si.TslSchemeTypeCommunityRules.URI))
+	}
+	doc.Policies = jsonLangStrings(tsl.Policies())
+	doc.LegalNotices = jsonLangStrings(tsl.LegalNotices())
+	if si.TslPointersToOtherTSL != nil {
+		for _, p := range si.TslPointersToOtherTSL.TslOtherTSLPointer {
+			if p == nil {
+				continue
+			}
+			pointer := jsonmodel.Pointer{Location: strings.TrimSpace(p.TSLLocation)}
+			if info, ok := tsl.PointerInfo(pointer.Location); ok {
+				pointer.TSLType = info.TSLType
+				pointer.SchemeTerritory = info.SchemeTerritory
+				pointer.Signers = info.Signers
+			}
+			doc.Pointers = append(doc.Pointers, pointer)
+		}
+	}
+	if si.TslNextUpdate != nil {
+		doc.NextUpdate = si.TslNextUpdate.DateTime
+	}
+	if si.TslDistributionPoints != nil {
+		doc.DistributionPoints = si.TslDistributionPoints.URI
+	}
+	return doc
+}
+
+// jsonProvider converts a TSP with its services.
+func jsonProvider(tsp *TSPType) jsonmodel.Provider {
+	doc := jsonmodel.Provider{Names: []jsonmodel.LangString{}, Services: []jsonmodel.Service{}}
+	if info := tsp.information(); info != nil {
+		if names := jsonLangStrings(LangStrings(info.TSPName)); names != nil {
+			doc.Names = names
+		}
+		doc.TradeNames = jsonLangStrings(tsp.TradeNames())
+		doc.Address = jsonAddress(info.TSPAddress)
+		doc.InformationURIs = jsonLangStrings(tsp.InformationURIs())
+	}
+	if tsp.TslTSPServices == nil {
+		return doc
+	}
+	for _, svc := range tsp.TslTSPServices.TslTSPService {
+		if svc == nil || svc.TslServiceInformation == nil {
+			continue
+		}
+		info := svc.TslServiceInformation
+		service := jsonmodel.Service{
+			Type:               info.TslServiceTypeIdentifier,
+			Names:              []jsonmodel.LangString{},
+			Status:             info.TslServiceStatus,
+			StatusStartingTime: info.StatusStartingTime,
+			Identities:         jsonIdentities(info.TslServiceDigitalIdentity),
+		}
+		if names := jsonLangStrings(LangStrings(info.ServiceName)); names != nil {
+			service.Names = names
+		}
+		if info.SchemeServiceDefinitionURI != nil {
+			service.DefinitionURIs = jsonLangStrings(langURIs(info.SchemeServiceDefinitionURI.URI))
+		}
+		if points := info.TslServiceSupplyPoints; points != nil && points.ServiceSupplyPoint != nil {
+			service.SupplyPoints = []string{strings.TrimSpace(points.ServiceSupplyPoint.Value)}
+		}
+		if svc.TslServiceHistory != nil {
+			for _, h := range svc.TslServiceHistory.TslServiceHistoryInstance {
+				if h == nil {
+					continue
+				}
+				service.History = append(service.History, jsonmodel.ServiceHistory{
+					Type:               h.TslServiceTypeIdentifier,
+					Names:              jsonLangStrings(LangStrings(h.ServiceName)),
+					Status:             h.TslServiceStatus,
+					StatusStartingTime: h.StatusStartingTime,
+					Identities:         jsonIdentities(h.TslServiceDigitalIdentity),
+				})
+			}
+		}
+		doc.Services = append(doc.Services, service)
+	}
+	return doc
+}
+
+// jsonIdentities converts the digital identities of a service. Certificates
+// that are not valid base64 are left out.
+func jsonIdentities(ids *DigitalIdentityListType) []jsonmodel.DigitalIdentity {
+	if ids == nil {
+		return nil
+	}
+	var docs []jsonmodel.DigitalIdentity
+	for _, id := range ids.DigitalId {
+		if id == nil {
+			continue
+		}
+		doc := jsonmodel.DigitalIdentity{
+			SubjectName: strings.TrimSpace(id.X509SubjectName),
+			SKI:         strings.TrimSpace(id.X509SKI),
+		}
+		if encoded := strings.TrimSpace(id.X509Certificate); encoded != "" {
+			if der, err := base64.StdEncoding.DecodeString(encoded); err == nil {
+				doc.Certificate = jsonCertificate(der)
+			}
+		}
+		if doc != (jsonmodel.DigitalIdentity{}) {
+			docs = append(docs, doc)
+		}
+	}
+	return docs
+}
+
+// jsonCertificate converts a DER certificate, with the fields derived from
+// it if it parses.
+func jsonCertificate(der []byte) *jsonmodel.Certificate {
+	digest := sha256.Sum256(der)
+	doc := &jsonmodel.Certificate{DER: der, SHA256: hex.EncodeToString(digest[:])}
+	if cert, err := x509.ParseCertificate(der); err == nil {
+		doc.Subject = cert.Subject.String()
+		doc.Issuer = cert.Issuer.String()
+		doc.NotBefore = cert.NotBefore.UTC().Format(time.RFC3339)
+		doc.NotAfter = cert.NotAfter.UTC().Format(time.RFC3339)
+	}
+	return doc
+}
+
+// jsonAddress converts an address, nil if it has no entries.
+func jsonAddress(address *AddressType) *jsonmodel.Address {
+	if address == nil {
+		return nil
+	}
+	doc := &jsonmodel.Address{}
+	if address.TslPostalAddresses != nil {
+		for _, a := range address.TslPostalAddresses.TslPostalAddress {
+			if a == nil {
+				continue
+			}
+			doc.PostalAddresses = append(doc.PostalAddresses, jsonmodel.PostalAddress{
+				Lang:            langOf(a.XmlLangAttr),
+				StreetAddress:   strings.TrimSpace(a.StreetAddress),
+				Locality:        strings.TrimSpace(a.Locality),
+				StateOrProvince: strings.TrimSpace(a.StateOrProvince),
+				PostalCode:      strings.TrimSpace(a.PostalCode),
+				CountryName:     strings.TrimSpace(a.CountryName),
+			})
+		}
+	}
+	if address.TslElectronicAddress != nil {
+		doc.ElectronicAddresses = jsonLangStrings(langURIs(address.TslElectronicAddress.URI))
+	}
+	if doc.PostalAddresses == nil && doc.ElectronicAddresses == nil {
+		return nil
+	}
+	return doc
+}
+
+// jsonLangStrings converts LangString values.
+func jsonLangStrings(values []LangString) []jsonmodel.LangString {
+	if len(values) == 0 {
+		return nil
+	}
+	docs := make([]jsonmodel.LangString, len(values))
+	for i, v := range values {
+		docs[i] = jsonmodel.LangString{Lang: v.Lang, Value: v.Value}
+	}
+	return docs
+}
+
+// xmlSchemeInformation converts scheme information back. The metadata of
+// the pointers is kept in TSL.Pointers by TSLFromJSONModel.
+func xmlSchemeInformation(si *jsonmodel.SchemeInformation) *TSLSchemeInformationType {
+	doc := &TSLSchemeInformationType{
+		TSLVersionIdentifier:        si.VersionIdentifier,
+		TSLSequenceNumber:           si.SequenceNumber,
+		TslTSLType:                  si.Type,
+		TslSchemeOperatorName:       xmlNames(si.OperatorNames),
+		SchemeOperatorAddress:       xmlAddress(si.OperatorAddress),
+		TslSchemeName:               xmlNames(si.SchemeNames),
+		TslSchemeInformationURI:     xmlURIs(si.InformationURIs),
+		StatusDeterminationApproach: si.StatusDeterminationApproach,
+		TslSchemeTypeCommunityRules: xmlURIs(si.CommunityRules),
+		TslSchemeTerritory:          si.Territory,
+		HistoricalInformationPeriod: si.HistoricalInformationPeriod,
+		ListIssueDateTime:           si.ListIssueDateTime,
+	}
+	if len(si.Policies) > 0 || len(si.LegalNotices) > 0 {
+		notice := &PolicyOrLegalnoticeType{}
+		if uris := xmlURIs(si.Policies); uris != nil {
+			notice.TSLPolicy = uris.URI
+		}
+		for _, n := range si.LegalNotices {
+			text := NonEmptyString(n.Value)
+			notice.TSLLegalNotice = append(notice.TSLLegalNotice, &MultiLangStringType{XmlLangAttr: xmlLang(n.Lang), NonEmptyString: &text})
+		}
+		doc.TslPolicyOrLegalNotice = notice
+	}
+	if len(si.Pointers) > 0 {
+		pointers := &OtherTSLPointersType{}
+		for _, p := range si.Pointers {
+			pointers.TslOtherTSLPointer = append(pointers.TslOtherTSLPointer, &OtherTSLPointerType{TSLLocation: p.Location})
+		}
+		doc.TslPointersToOtherTSL = pointers
+	}
+	if si.NextUpdate != "" {
+		doc.TslNextUpdate = &NextUpdateType{DateTime: si.NextUpdate}
+	}
+	if len(si.DistributionPoints) > 0 {
+		doc.TslDistributionPoints = &NonEmptyURIListType{URI: si.DistributionPoints}
+	}
+	return doc
+}
+
+// xmlProvider converts a provider back.
+func xmlProvider(p jsonmodel.Provider) (*TSPType, error) {
+	tsp := &TSPType{
+		TslTSPInformation: &TSPInformationType{
+			TSPName:           xmlNames(p.Names),
+			TSPTradeName:      xmlNames(p.TradeNames),
+			TSPAddress:        xmlAddress(p.Address),
+			TSPInformationURI: xmlURIs(p.InformationURIs),
+		},
+		TslTSPServices: &TSPServicesListType{},
+	}
+	for i, s := range p.Services {
+		ids, err := xmlIdentities(s.Identities)
+		if err != nil {
+			return nil, fmt.Errorf("service %d: %w", i, err)
+		}
+		svc := &TSPServiceType{TslServiceInformation: &TSPServiceInformationType{
+			TslServiceTypeIdentifier:   s.Type,
+			ServiceName:                xmlNames(s.Names),
+			TslServiceDigitalIdentity:  ids,
+			TslServiceStatus:           s.Status,
+			StatusStartingTime:         s.StatusStartingTime,
+			SchemeServiceDefinitionURI: xmlURIs(s.DefinitionURIs),
+		}}
+		if len(s.SupplyPoints) > 0 {
+			svc.TslServiceInformation.TslServiceSupplyPoints = &ServiceSupplyPointsType{
+				ServiceSupplyPoint: &AttributedNonEmptyURIType{Value: s.SupplyPoints[0]},
+			}
+		}
+		if len(s.History) > 0 {
+			svc.TslServiceHistory = &ServiceHistoryType{}
+			for j, h := range s.History {
+				ids, err := xmlIdentities(h.Identities)
+				if err != nil {
+					return nil, fmt.Errorf("service %d history %d: %w", i, j, err)
+				}
+				svc.TslServiceHistory.TslServiceHistoryInstance = append(svc.TslServiceHistory.TslServiceHistoryInstance, &ServiceHistoryInstanceType{
+					TslServiceTypeIdentifier:  h.Type,
+					ServiceName:               xmlNames(h.Names),
+					TslServiceDigitalIdentity: ids,
+					TslServiceStatus:          h.Status,
+					StatusStartingTime:        h.StatusStartingTime,
+				})
+			}
+		}
+		tsp.TslTSPServices.TslTSPService = append(tsp.TslTSPServices.TslTSPService, svc)
+	}
+	return tsp, nil
+}
+
+// xmlIdentities converts digital identities back, checking that the
+// certificates parse.
+func xmlIdentities(ids []jsonmodel.DigitalIdentity) (*DigitalIdentityListType, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	list := &DigitalIdentityListType{}
+	for _, id := range ids {
+		doc := &DigitalIdentityType{X509SubjectName: id.SubjectName, X509SKI: id.SKI}
+		if id.Certificate != nil {
+			if _, err := x509.ParseCertificate(id.Certificate.DER); err != nil {
+				return nil, fmt.Errorf("invalid certificate: %w", err)
+			}
+			doc.X509Certificate = base64.StdEncoding.EncodeToString(id.Certificate.DER)
+		}
+		list.DigitalId = append(list.DigitalId, doc)
+	}
+	return list, nil
+}
+
+// xmlNames converts names back, nil if there are none.
+func xmlNames(values []jsonmodel.LangString) *InternationalNamesType {
+	if len(values) == 0 {
+		return nil
+	}
+	names := &InternationalNamesType{}
+	for _, v := range values {
+		value := NonEmptyNormalizedString(v.Value)
+		names.Name = append(names.Name, &MultiLangNormStringType{XmlLangAttr: xmlLang(v.Lang), NonEmptyNormalizedString: &value})
+	}
+	return names
+}
+
+// xmlURIs converts multilingual URIs back, nil if there are none.
+func xmlURIs(values []jsonmodel.LangString) *NonEmptyMultiLangURIListType {
+	if len(values) == 0 {
+		return nil
+	}
+	uris := &NonEmptyMultiLangURIListType{}
+	for _, v := range values {
+		uris.URI = append(uris.URI, &NonEmptyMultiLangURIType{XmlLangAttr: xmlLang(v.Lang), Value: v.Value})
+	}
+	return uris
+}
+
+// xmlAddress converts an address back.
+func xmlAddress(address *jsonmodel.Address) *AddressType {
+	if address == nil {
+		return nil
+	}
+	doc := &AddressType{}
+	if len(address.PostalAddresses) > 0 {
+		doc.TslPostalAddresses = &PostalAddressListType{}
+		for _, a := range address.PostalAddresses {
+			doc.TslPostalAddresses.TslPostalAddress = append(doc.TslPostalAddresses.TslPostalAddress, &PostalAddressType{
+				XmlLangAttr:     xmlLang(a.Lang),
+				StreetAddress:   a.StreetAddress,
+				Locality:        a.Locality,
+				StateOrProvince: a.StateOrProvince,
+				PostalCode:      a.PostalCode,
+				CountryName:     a.CountryName,
+			})
+		}
+	}
+	if uris := xmlURIs(address.ElectronicAddresses); uris != nil {
+		doc.TslElectronicAddress = &ElectronicAddressType{URI: uris.URI}
+	}
+	return doc
+}
+
+// xmlLang returns an xml:lang attribute value, nil for no language.
+func xmlLang(lang string) *Lang {
+	if lang == "" {
+		return nil
+	}
+	l := Lang(lang)
+	return &l
+}
@@ -0,0 +1,139 @@
+package etsi119612_test
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirosfoundation/g119612/pkg/etsi119612"
+	"github.com/sirosfoundation/g119612/pkg/etsi119612/jsonmodel"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func loadTestTSL(t *testing.T, name string) *etsi119612.TSL {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	require.NoError(t, err)
+	tsl, err := etsi119612.ParseTSL(data, name, etsi119612.DefaultTSLFetchOptions)
+	require.NoError(t, err)
+	return tsl
+}
+
+// certificates returns the certificates of the services of a TSL in order.
+func certificates(tsl *etsi119612.TSL) []*x509.Certificate {
+	var certs []*x509.Certificate
+	tsl.WithTrustServices(func(_ *etsi119612.TSPType, svc *etsi119612.TSPServiceType) {
+		svc.WithCertificates(func(cert *x509.Certificate) {
+			certs = append(certs, cert)
+		})
+	})
+	return certs
+}
+
+func TestTSLMarshalJSON(t *testing.T) {
+	tsl := loadTestTSL(t, "EWC-TL.xml")
+
+	data, err := json.Marshal(tsl)
+	require.NoError(t, err)
+	var doc jsonmodel.TSL
+	require.NoError(t, json.Unmarshal(data, &doc))
+
+	assert.Equal(t, jsonmodel.Version, doc.Version)
+	assert.Equal(t, "EWC-TL.xml", doc.Source)
+	require.NotNil(t, doc.SchemeInformation)
+	assert.Equal(t, "TT", doc.SchemeInformation.Territory)
+	assert.Equal(t, "2025-08-13T00:00:00Z", doc.SchemeInformation.NextUpdate)
+	assert.Equal(t, []jsonmodel.LangString{{Lang: "en", Value: "EWC Consortium"}}, doc.SchemeInformation.OperatorNames)
+	require.Len(t, doc.Providers, tsl.NumberOfTrustServiceProviders())
+	assert.Equal(t, "Tinexta Infocert", doc.Providers[0].Names[0].Value)
+
+	var docCerts []*jsonmodel.Certificate
+	for _, provider := range doc.Providers {
+		for _, svc := range provider.Services {
+			for _, id := range svc.Identities {
+				if id.Certificate != nil {
+					docCerts = append(docCerts, id.Certificate)
+				}
+			}
+		}
+	}
+	certs := certificates(tsl)
+	require.NotEmpty(t, certs)
+	require.Len(t, docCerts, len(certs))
+	for i, cert := range certs {
+		assert.Equal(t, cert.Raw, docCerts[i].DER)
+		assert.Equal(t, cert.Subject.String(), docCerts[i].Subject)
+		assert.Len(t, docCerts[i].SHA256, 64)
+	}
+
+	// Pointer metadata is part of the scheme information
+	pointers := loadTestTSL(t, "TSL-with-typed-pointer.xml")
+	data, err = json.Marshal(pointers)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &doc))
+	assert.Equal(t, []jsonmodel.Pointer{{
+		Location:        "https://example.com/referenced.xml",
+		TSLType:         "http://uri.etsi.org/TrstSvc/TrustedList/TSLType/EUgeneric",
+		SchemeTerritory: "DE",
+	}}, doc.SchemeInformation.Pointers)
+}
+
+func TestTSLJSONSummary(t *testing.T) {
+	tsl := loadTestTSL(t, "EWC-TL.xml")
+	doc := tsl.JSONModel()
+
+	summary := tsl.JSONSummary()
+	assert.Equal(t, jsonmodel.Summary{
+		Source:            "EWC-TL.xml",
+		Territory:         "TT",
+		Operator:          "EWC Consortium",
+		SequenceNumber:    doc.SchemeInformation.SequenceNumber,
+		ListIssueDateTime: doc.SchemeInformation.ListIssueDateTime,
+		NextUpdate:        "2025-08-13T00:00:00Z",
+	}, summary)
+}
+
+func TestTSLUnmarshalJSON(t *testing.T) {
+	for _, name := range []string{"EWC-TL.xml", "SE-TL.xml", "TSL-with-typed-pointer.xml"} {
+		t.Run(name, func(t *testing.T) {
+			tsl := loadTestTSL(t, name)
+			data, err := json.Marshal(tsl)
+			require.NoError(t, err)
+
+			var decoded etsi119612.TSL
+			require.NoError(t, json.Unmarshal(data, &decoded))
+			assert.Equal(t, tsl.Source, decoded.Source)
+			assert.Equal(t, tsl.Signed, decoded.Signed)
+			assert.Equal(t, tsl.Signer.Raw, decoded.Signer.Raw)
+			assert.ElementsMatch(t, tsl.Pointers, decoded.Pointers)
+			assert.Equal(t, tsl.NumberOfTrustServiceProviders(), decoded.NumberOfTrustServiceProviders())
+			assert.Equal(t, certificates(tsl), certificates(&decoded))
+			assert.Equal(t, sequence(tsl), sequence(&decoded))
+
+			// The decoded TSL encodes to the same document
+			again, err := json.Marshal(&decoded)
+			require.NoError(t, err)
+			assert.JSONEq(t, string(data), string(again))
+		})
+	}
+}
+
+func sequence(tsl *etsi119612.TSL) int {
+	if tsl.StatusList.TslSchemeInformation == nil {
+		return -1
+	}
+	return tsl.StatusList.TslSchemeInformation.TSLSequenceNumber
+}
+
+func TestTSLUnmarshalJSON_Invalid(t *testing.T) {
+	var tsl etsi119612.TSL
+	assert.ErrorContains(t, json.Unmarshal([]byte(`{"version":"2","signed":false,"providers":[]}`), &tsl), "unsupported TSL JSON version")
+	assert.ErrorContains(t, json.Unmarshal([]byte(`{"signed":false,"providers":[]}`), &tsl), "unsupported TSL JSON version")
+
+	invalid := `{"version":"1","signed":false,"providers":[{"names":[],"services":[
+		{"type":"http://uri.etsi.org/TrstSvc/Svctype/CA/QC","names":[],"status":"granted","identities":[{"certificate":{"der":"AAEC"}}]}]}]}`
+	assert.ErrorContains(t, json.Unmarshal([]byte(invalid), &tsl), "invalid certificate")
+}
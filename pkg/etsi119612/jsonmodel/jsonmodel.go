@@ -0,0 +1,154 @@
+// Package jsonmodel defines the JSON representation of ETSI TS 119 612 trust
+// status lists shared by every JSON surface of this module. etsi119612.TSL
+// implements json.Marshaler and json.Unmarshaler with these types, so a TSL
+// encoded with encoding/json always has this shape.
+//
+// The representation is versioned: every document carries the Version it was
+// written with in its "version" member, and the JSON Schema of that version
+// is embedded as Schema. Members are only added within a version; renaming or
+// removing a member, or changing its meaning, increments the version.
+//
+// Names and URIs in several languages are lists of LangString. Dates are
+// passed through as they appear in the list, normally xsd:dateTime such as
+// "2026-01-15T00:00:00Z". Certificates carry their DER encoding, base64
+// encoded, together with fields derived from it for convenience; the derived
+// fields are ignored when a document is read.
+package jsonmodel
+
+import _ "embed"
+
+// Version is the version of the representation defined by this package.
+const Version = "1"
+
+// SchemaID is the $id of Schema.
+const SchemaID = "https://github.com/sirosfoundation/g119612/schemas/tsl-v" + Version + ".json"
+
+// Schema is the JSON Schema (draft 2020-12) of a TSL document of Version.
+//
+//go:embed schema.json
+var Schema []byte
+
+// TSL is a trust status list.
+type TSL struct {
+	Version           string             `json:"version"`
+	Source            string             `json:"source,omitempty"` // URL or path the list was loaded from
+	Signed            bool               `json:"signed"`
+	Signer            *Certificate       `json:"signer,omitempty"` // Certificate the list was signed with
+	TSLTag            string             `json:"tsl_tag,omitempty"`
+	ID                string             `json:"id,omitempty"`
+	SchemeInformation *SchemeInformation `json:"scheme_information,omitempty"`
+	Providers         []Provider         `json:"providers"`
+}
+
+// Summary identifies a list and its issue in documents that refer to a list
+// without including it, such as reports, manifests and indexes. Its members
+// are named like those of TSL and SchemeInformation; the JSON Schema of
+// Version describes it as "#/$defs/summary".
+type Summary struct {
+	Source            string `json:"source,omitempty"`
+	Territory         string `json:"territory,omitempty"`
+	Operator          string `json:"operator,omitempty"` // Scheme operator name, in English if available
+	SequenceNumber    int    `json:"sequence_number"`
+	ListIssueDateTime string `json:"list_issue_date_time,omitempty"`
+	NextUpdate        string `json:"next_update,omitempty"`
+}
+
+// SchemeInformation is the SchemeInformation of a list.
+type SchemeInformation struct {
+	VersionIdentifier           int          `json:"version_identifier"`
+	SequenceNumber              int          `json:"sequence_number"`
+	Type                        string       `json:"type"`
+	OperatorNames               []LangString `json:"operator_names,omitempty"`
+	OperatorAddress             *Address     `json:"operator_address,omitempty"`
+	SchemeNames                 []LangString `json:"scheme_names,omitempty"`
+	InformationURIs             []LangString `json:"information_uris,omitempty"`
+	StatusDeterminationApproach string       `json:"status_determination_approach,omitempty"`
+	CommunityRules              []LangString `json:"community_rules,omitempty"`
+	Territory                   string       `json:"territory,omitempty"`
+	Policies                    []LangString `json:"policies,omitempty"`
+	LegalNotices                []LangString `json:"legal_notices,omitempty"`
+	HistoricalInformationPeriod int          `json:"historical_information_period,omitempty"`
+	Pointers                    []Pointer    `json:"pointers,omitempty"`
+	ListIssueDateTime           string       `json:"list_issue_date_time,omitempty"`
+	NextUpdate                  string       `json:"next_update,omitempty"`
+	DistributionPoints          []string     `json:"distribution_points,omitempty"`
+}
+
+// LangString is a text or URI in one language.
+type LangString struct {
+	Lang  string `json:"lang,omitempty"`
+	Value string `json:"value"`
+}
+
+// Address is the postal and electronic address of a scheme operator or
+// provider.
+type Address struct {
+	PostalAddresses     []PostalAddress `json:"postal_addresses,omitempty"`
+	ElectronicAddresses []LangString    `json:"electronic_addresses,omitempty"`
+}
+
+// PostalAddress is a postal address in one language.
+type PostalAddress struct {
+	Lang            string `json:"lang,omitempty"`
+	StreetAddress   string `json:"street_address,omitempty"`
+	Locality        string `json:"locality,omitempty"`
+	StateOrProvince string `json:"state_or_province,omitempty"`
+	PostalCode      string `json:"postal_code,omitempty"`
+	CountryName     string `json:"country_name,omitempty"`
+}
+
+// Pointer is a pointer to another list.
+type Pointer struct {
+	Location        string   `json:"location"`
+	TSLType         string   `json:"tsl_type,omitempty"`
+	SchemeTerritory string   `json:"scheme_territory,omitempty"`
+	Signers         []string `json:"signers,omitempty"` // Hex SHA-256 fingerprints of the certificates allowed to sign the list
+}
+
+// Provider is a trust service provider.
+type Provider struct {
+	Names           []LangString `json:"names"`
+	TradeNames      []LangString `json:"trade_names,omitempty"`
+	Address         *Address     `json:"address,omitempty"`
+	InformationURIs []LangString `json:"information_uris,omitempty"`
+	Services        []Service    `json:"services"`
+}
+
+// Service is a trust service with its current status.
+type Service struct {
+	Type               string            `json:"type"`
+	Names              []LangString      `json:"names"`
+	Status             string            `json:"status"`
+	StatusStartingTime string            `json:"status_starting_time,omitempty"`
+	Identities         []DigitalIdentity `json:"identities,omitempty"`
+	DefinitionURIs     []LangString      `json:"definition_uris,omitempty"`
+	SupplyPoints       []string          `json:"supply_points,omitempty"`
+	History            []ServiceHistory  `json:"history,omitempty"`
+}
+
+// ServiceHistory is a former status of a trust service.
+type ServiceHistory struct {
+	Type               string            `json:"type"`
+	Names              []LangString      `json:"names,omitempty"`
+	Status             string            `json:"status"`
+	StatusStartingTime string            `json:"status_starting_time,omitempty"`
+	Identities         []DigitalIdentity `json:"identities,omitempty"`
+}
+
+// DigitalIdentity is a DigitalId of a service: a certificate, a subject
+// name or a subject key identifier.
+type DigitalIdentity struct {
+	Certificate *Certificate `json:"certificate,omitempty"`
+	SubjectName string       `json:"subject_name,omitempty"`
+	SKI         string       `json:"ski,omitempty"` // Base64 as in the list
+}
+
+// Certificate is an X.509 certificate. Only DER is read from documents.
+type Certificate struct {
+	DER       []byte `json:"der"`
+	SHA256    string `json:"sha256,omitempty"` // Hex SHA-256 fingerprint of DER
+	Subject   string `json:"subject,omitempty"`
+	Issuer    string `json:"issuer,omitempty"`
+	NotBefore string `json:"not_before,omitempty"` // RFC 3339
+	NotAfter  string `json:"not_after,omitempty"`  // RFC 3339
+}
@@ -0,0 +1,65 @@
+package jsonmodel
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSchema checks that the embedded schema describes the members of the
+// types of this package, so the two are not changed one without the other.
+func TestSchema(t *testing.T) {
+	var schema struct {
+		ID         string                     `json:"$id"`
+		Properties map[string]json.RawMessage `json:"properties"`
+		Required   []string                   `json:"required"`
+		Defs       map[string]struct {
+			Properties map[string]json.RawMessage `json:"properties"`
+			Required   []string                   `json:"required"`
+		} `json:"$defs"`
+	}
+	require.NoError(t, json.Unmarshal(Schema, &schema))
+	assert.Equal(t, SchemaID, schema.ID)
+	assert.Contains(t, string(schema.Properties["version"]), `"`+Version+`"`)
+
+	checkMembers(t, "TSL", reflect.TypeFor[TSL](), schema.Properties, schema.Required)
+	for def, typ := range map[string]reflect.Type{
+		"scheme_information": reflect.TypeFor[SchemeInformation](),
+		"lang_string":        reflect.TypeFor[LangString](),
+		"address":            reflect.TypeFor[Address](),
+		"postal_address":     reflect.TypeFor[PostalAddress](),
+		"pointer":            reflect.TypeFor[Pointer](),
+		"provider":           reflect.TypeFor[Provider](),
+		"service":            reflect.TypeFor[Service](),
+		"service_history":    reflect.TypeFor[ServiceHistory](),
+		"digital_identity":   reflect.TypeFor[DigitalIdentity](),
+		"certificate":        reflect.TypeFor[Certificate](),
+		"summary":            reflect.TypeFor[Summary](),
+	} {
+		require.Contains(t, schema.Defs, def)
+		checkMembers(t, def, typ, schema.Defs[def].Properties, schema.Defs[def].Required)
+	}
+}
+
+// checkMembers checks that the properties of a schema are the JSON members
+// of typ, and that only members without omitempty are required.
+func checkMembers(t *testing.T, name string, typ reflect.Type, properties map[string]json.RawMessage, required []string) {
+	t.Helper()
+	members := map[string]bool{}
+	for i := range typ.NumField() {
+		field := typ.Field(i)
+		member, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		members[member] = true
+		assert.Contains(t, properties, member, "%s.%s", name, field.Name)
+		if strings.Contains(options, "omitempty") {
+			assert.NotContains(t, required, member, "%s.%s", name, field.Name)
+		}
+	}
+	for property := range properties {
+		assert.True(t, members[property], "%s: schema property %s has no field", name, property)
+	}
+}
@@ -0,0 +1,174 @@
+package etsi119612
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// MirrorManifestFile is the name of the manifest in a mirror directory.
+const MirrorManifestFile = "manifest.json"
+
+// MirrorEntry describes one document of a mirror.
+type MirrorEntry struct {
+	URL       string    `json:"url"`                  // The URL the document was fetched from
+	File      string    `json:"file"`                 // File name of the document in the mirror directory
+	SHA256    string    `json:"sha256"`               // Hex SHA-256 digest of the document
+	Size      int       `json:"size"`                 // Size of the document in bytes
+	FetchedAt time.Time `json:"fetched_at,omitempty"` // When the document was fetched, if known
+}
+
+// MirrorManifest lists the documents of a mirror. It is stored as
+// MirrorManifestFile in the mirror directory.
+type MirrorManifest struct {
+	Created time.Time     `json:"created"` // When the mirror was written
+	Roots   []string      `json:"roots"`   // URLs of the root TSLs, in the order they were given
+	TSLs    []MirrorEntry `json:"tsls"`    // The documents, roots and referenced TSLs in pre-order
+}
+
+// Mirror is a local copy of fetched TSL documents in their original byte
+// form, written by WriteMirror. Setting TSLFetchOptions.Mirror reads documents
+// from the mirror instead of fetching them, for reproducible offline
+// processing and forensic snapshots of what was fetched. The mirror directory
+// can also be served over HTTP as it is.
+type Mirror struct {
+	dir      string
+	manifest MirrorManifest
+	byURL    map[string]MirrorEntry
+}
+
+// mirrorFileName returns the file name of the document fetched from url.
+func mirrorFileName(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return hex.EncodeToString(sum[:]) + ".xml"
+}
+
+// WriteMirror writes the original documents (see TSL.RawXML) of roots and all TSLs
+// they reference to dir, together with a manifest mapping their URLs to the
+// files. An existing mirror in dir is replaced: documents it lists that are
+// not part of the new mirror are removed. TSLs without a document, such as
+// generated ones, are left out, as are repeated URLs.
+//
+// Parameters:
+//   - dir: The mirror directory, created if needed
+//   - roots: The root TSLs to mirror
+//
+// Returns:
+//   - *MirrorManifest: The manifest written to dir
+//   - Any error that occurred while writing
+func WriteMirror(dir string, roots []*TSL) (*MirrorManifest, error) {
+	manifest := &MirrorManifest{Created: time.Now().UTC(), Roots: []string{}, TSLs: []MirrorEntry{}}
+	seen := make(map[*TSL]bool)
+	written := make(map[string]bool)
+	var walk func(tsl *TSL) error
+	walk = func(tsl *TSL) error {
+		if tsl == nil || seen[tsl] {
+			return nil
+		}
+		seen[tsl] = true
+		raw, err := tsl.RawXML()
+		if err != nil {
+			return fmt.Errorf("failed to mirror %s: %w", tsl.Source, err)
+		}
+		if raw != nil && !written[tsl.Source] {
+			entry := MirrorEntry{URL: tsl.Source, File: mirrorFileName(tsl.Source), Size: len(raw)}
+			sum := sha256.Sum256(raw)
+			entry.SHA256 = hex.EncodeToString(sum[:])
+			if tsl.FetchInfo != nil {
+				entry.FetchedAt = tsl.FetchInfo.FetchedAt.UTC()
+			}
+			if err := writeCacheFile(filepath.Join(dir, entry.File), raw); err != nil {
+				return fmt.Errorf("failed to mirror %s: %w", tsl.Source, err)
+			}
+			written[tsl.Source] = true
+			manifest.TSLs = append(manifest.TSLs, entry)
+		}
+		for _, ref := range tsl.Referenced {
+			if err := walk(ref); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	previous, _ := OpenMirror(dir)
+	for _, root := range roots {
+		if root == nil {
+			continue
+		}
+		manifest.Roots = append(manifest.Roots, root.Source)
+		if err := walk(root); err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := writeCacheFile(filepath.Join(dir, MirrorManifestFile), append(data, '\n')); err != nil {
+		return nil, fmt.Errorf("failed to write mirror manifest: %w", err)
+	}
+	if previous != nil {
+		for _, entry := range previous.manifest.TSLs {
+			if !written[entry.URL] {
+				os.Remove(filepath.Join(dir, entry.File))
+			}
+		}
+	}
+	return manifest, nil
+}
+
+// OpenMirror reads the manifest of the mirror in dir.
+func OpenMirror(dir string) (*Mirror, error) {
+	data, err := os.ReadFile(filepath.Join(dir, MirrorManifestFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open mirror: %w", err)
+	}
+	m := &Mirror{dir: dir, byURL: make(map[string]MirrorEntry)}
+	if err := json.Unmarshal(data, &m.manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse mirror manifest %s: %w", filepath.Join(dir, MirrorManifestFile), err)
+	}
+	for _, entry := range m.manifest.TSLs {
+		// Only plain file names, the manifest must not point outside the mirror
+		if entry.File == "" || filepath.Base(entry.File) != entry.File {
+			return nil, fmt.Errorf("invalid file %q for %s in mirror manifest", entry.File, entry.URL)
+		}
+		m.byURL[entry.URL] = entry
+	}
+	return m, nil
+}
+
+// Dir returns the mirror directory.
+func (m *Mirror) Dir() string {
+	return m.dir
+}
+
+// Manifest returns the manifest of the mirror.
+func (m *Mirror) Manifest() MirrorManifest {
+	return m.manifest
+}
+
+// Document returns the mirrored document of url and the path of its file. It
+// fails with ErrNotMirrored for URLs missing from the mirror, and if the file
+// no longer matches the digest of the manifest.
+func (m *Mirror) Document(url string) ([]byte, string, error) {
+	entry, ok := m.byURL[url]
+	if !ok {
+		return nil, "", fmt.Errorf("%w: %s", ErrNotMirrored, url)
+	}
+	path := filepath.Join(m.dir, entry.File)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", err
+	}
+	sum := sha256.Sum256(data)
+	if hex.EncodeToString(sum[:]) != entry.SHA256 {
+		return nil, "", fmt.Errorf("mirrored document %s of %s does not match its digest", path, url)
+	}
+	return data, path, nil
+}
@@ -0,0 +1,87 @@
+package etsi119612_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/h2non/gock"
+	"github.com/sirosfoundation/g119612/pkg/etsi119612"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMirror(t *testing.T) {
+	defer gock.Off()
+	gock.New("https://example.com").Get("/main.xml").Reply(200).File("testdata/TSL-with-pointer.xml")
+	gock.New("https://example.com").Get("/referenced.xml").Reply(200).File("testdata/EWC-TL.xml")
+
+	options := etsi119612.DefaultTSLFetchOptions
+	options.MaxDereferenceDepth = 1
+	fetched, err := etsi119612.FetchTSLWithReferencesAndOptions("https://example.com/main.xml", options)
+	require.NoError(t, err)
+	require.Len(t, fetched, 2)
+	original, err := os.ReadFile("testdata/EWC-TL.xml")
+	require.NoError(t, err)
+	assert.Equal(t, original, fetched[1].Raw, "the document is kept as fetched")
+
+	dir := t.TempDir()
+	manifest, err := etsi119612.WriteMirror(dir, fetched[:1])
+	require.NoError(t, err)
+	assert.Equal(t, []string{"https://example.com/main.xml"}, manifest.Roots)
+	require.Len(t, manifest.TSLs, 2)
+	assert.Equal(t, "https://example.com/referenced.xml", manifest.TSLs[1].URL)
+	assert.Equal(t, len(original), manifest.TSLs[1].Size)
+	assert.Len(t, manifest.TSLs[1].SHA256, 64)
+	assert.False(t, manifest.TSLs[1].FetchedAt.IsZero())
+	data, err := os.ReadFile(filepath.Join(dir, manifest.TSLs[1].File))
+	require.NoError(t, err)
+	assert.Equal(t, original, data)
+
+	var stored etsi119612.MirrorManifest
+	data, err = os.ReadFile(filepath.Join(dir, etsi119612.MirrorManifestFile))
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &stored))
+	assert.Equal(t, manifest.TSLs, stored.TSLs)
+
+	// All responses are used up, the same lists are read from the mirror
+	require.True(t, gock.IsDone())
+	mirror, err := etsi119612.OpenMirror(dir)
+	require.NoError(t, err)
+	options.Mirror = mirror
+	mirrored, err := etsi119612.FetchTSLWithReferencesAndOptions("https://example.com/main.xml", options)
+	require.NoError(t, err)
+	require.Len(t, mirrored, 2)
+	for i := range fetched {
+		assert.Equal(t, fetched[i].Source, mirrored[i].Source)
+		assert.Equal(t, fetched[i].Raw, mirrored[i].Raw)
+		assert.Equal(t, fetched[i].NumberOfTrustServiceProviders(), mirrored[i].NumberOfTrustServiceProviders())
+	}
+	assert.Equal(t, "file://"+filepath.Join(dir, manifest.TSLs[0].File), mirrored[0].FetchInfo.FinalURL)
+
+	_, err = etsi119612.FetchTSLWithOptions("https://example.com/other.xml", options)
+	assert.ErrorIs(t, err, etsi119612.ErrNotMirrored)
+
+	// Tampered documents are refused
+	require.NoError(t, os.WriteFile(filepath.Join(dir, manifest.TSLs[1].File), []byte("<tampered/>"), 0644))
+	_, _, err = mirror.Document("https://example.com/referenced.xml")
+	assert.ErrorContains(t, err, "does not match its digest")
+
+	// Writing a new mirror removes the documents it no longer holds
+	_, err = etsi119612.WriteMirror(dir, []*etsi119612.TSL{{Source: "https://example.com/main.xml", Raw: fetched[0].Raw}})
+	require.NoError(t, err)
+	assert.NoFileExists(t, filepath.Join(dir, manifest.TSLs[1].File))
+	assert.FileExists(t, filepath.Join(dir, manifest.TSLs[0].File))
+}
+
+func TestOpenMirror_Invalid(t *testing.T) {
+	_, err := etsi119612.OpenMirror(t.TempDir())
+	assert.Error(t, err)
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, etsi119612.MirrorManifestFile),
+		[]byte(`{"tsls":[{"url":"https://example.com/tsl.xml","file":"../tsl.xml"}]}`), 0644))
+	_, err = etsi119612.OpenMirror(dir)
+	assert.ErrorContains(t, err, "invalid file")
+}
@@ -0,0 +1,205 @@
+package etsi119612
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// PointerInfo is the metadata a TSL declares about a TSL it points to in
+// PointersToOtherTSL. The generated schema types model OtherInformation as
+// open content, so the values are extracted separately when a TSL is parsed.
+type PointerInfo struct {
+	// Location is the TSLLocation of the pointer.
+	Location string
+	// TSLType is the TSLType the pointed-to list is expected to declare.
+	TSLType string
+	// SchemeTerritory is the SchemeTerritory the pointed-to list is expected to declare.
+	SchemeTerritory string
+	// Signers are the lowercase hex SHA-256 fingerprints of the certificates
+	// allowed to sign the pointed-to list, as given by the X509Certificate
+	// identities in the ServiceDigitalIdentities of the pointer or by a
+	// PointerManifest. If set, a list that is unsigned or signed by another
+	// certificate is skipped.
+	Signers []string
+}
+
+// PointerMismatch reports a TSL whose scheme information contradicts the
+// pointer metadata of the TSL that references it, for example a pointer
+// announcing a list for DE that leads to a list for FR.
+type PointerMismatch struct {
+	// Parent is the source of the TSL containing the pointer.
+	Parent string
+	// Location is the TSLLocation of the pointer.
+	Location string
+	// Field is the scheme information element that differs ("TSLType" or "SchemeTerritory").
+	Field string
+	// Expected is the value declared by the pointer.
+	Expected string
+	// Actual is the value declared by the referenced TSL.
+	Actual string
+}
+
+func (m PointerMismatch) Error() string {
+	return fmt.Sprintf("%s: pointer in %s declares %s %q but the referenced TSL declares %q",
+		m.Location, m.Parent, m.Field, m.Expected, m.Actual)
+}
+
+// pointerDocument is the part of a TSL needed to extract the pointer metadata.
+type pointerDocument struct {
+	Pointers []struct {
+		Location         string   `xml:"TSLLocation"`
+		Certificates     []string `xml:"ServiceDigitalIdentities>ServiceDigitalIdentity>DigitalId>X509Certificate"`
+		OtherInformation []struct {
+			TSLType         string `xml:"TSLType"`
+			SchemeTerritory string `xml:"SchemeTerritory"`
+		} `xml:"AdditionalInformation>OtherInformation"`
+	} `xml:"SchemeInformation>PointersToOtherTSL>OtherTSLPointer"`
+}
+
+// parsePointerInfo extracts the metadata of the pointers to other TSLs from a
+// TSL document. The certificates of the ServiceDigitalIdentities of a pointer
+// become its Signers; identities given only by subject name, SKI or key are
+// not used for pinning.
+func parsePointerInfo(data []byte) ([]PointerInfo, error) {
+	var doc pointerDocument
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	infos := make([]PointerInfo, 0, len(doc.Pointers))
+	for _, p := range doc.Pointers {
+		info := PointerInfo{Location: strings.TrimSpace(p.Location)}
+		for _, other := range p.OtherInformation {
+			if v := strings.TrimSpace(other.TSLType); v != "" {
+				info.TSLType = v
+			}
+			if v := strings.TrimSpace(other.SchemeTerritory); v != "" {
+				info.SchemeTerritory = v
+			}
+		}
+		for _, cert := range p.Certificates {
+			der, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(cert), ""))
+			if err != nil || len(der) == 0 {
+				log.Debugf("g119612: Ignoring invalid signer certificate in the pointer to %s", info.Location)
+				continue
+			}
+			digest := sha256.Sum256(der)
+			info.Signers = append(info.Signers, hex.EncodeToString(digest[:]))
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// PointerInfo returns the metadata declared for the pointer to location, and
+// false if the TSL has no pointer to location.
+func (tsl *TSL) PointerInfo(location string) (PointerInfo, bool) {
+	if tsl == nil {
+		return PointerInfo{}, false
+	}
+	location = strings.TrimSpace(location)
+	for _, info := range tsl.Pointers {
+		if info.Location == location {
+			return info, true
+		}
+	}
+	return PointerInfo{}, false
+}
+
+// acceptPointer reports whether the pointer to location passes options.PointerFilter.
+func (tsl *TSL) acceptPointer(location string, options TSLFetchOptions) bool {
+	if options.PointerFilter == nil {
+		return true
+	}
+	info, ok := tsl.PointerInfo(location)
+	if !ok {
+		info = PointerInfo{Location: strings.TrimSpace(location)}
+	}
+	if options.PointerFilter(info) {
+		return true
+	}
+	log.Debugf("g119612: Skipping referenced TSL %s excluded by the pointer filter", location)
+	return false
+}
+
+// CheckPointerConsistency compares the TSLType and SchemeTerritory declared by
+// a pointer with the scheme information of the referenced TSL. Values the
+// pointer does not declare are not checked. Territories are compared case
+// insensitively.
+//
+// Parameters:
+//   - parent: The TSL containing the pointer, used for reporting
+//   - pointer: The pointer metadata
+//   - child: The TSL fetched from the pointer location
+//
+// Returns:
+//   - The mismatches found, nil if the TSLs are consistent
+func CheckPointerConsistency(parent *TSL, pointer PointerInfo, child *TSL) []PointerMismatch {
+	if child == nil {
+		return nil
+	}
+	var actualType, actualTerritory string
+	if info := child.StatusList.TslSchemeInformation; info != nil {
+		actualType = strings.TrimSpace(info.TslTSLType)
+		actualTerritory = strings.TrimSpace(info.TslSchemeTerritory)
+	}
+	parentSource := ""
+	if parent != nil {
+		parentSource = parent.Source
+	}
+
+	var mismatches []PointerMismatch
+	if pointer.TSLType != "" && pointer.TSLType != actualType {
+		mismatches = append(mismatches, PointerMismatch{
+			Parent: parentSource, Location: pointer.Location,
+			Field: "TSLType", Expected: pointer.TSLType, Actual: actualType,
+		})
+	}
+	if pointer.SchemeTerritory != "" && !strings.EqualFold(pointer.SchemeTerritory, actualTerritory) {
+		mismatches = append(mismatches, PointerMismatch{
+			Parent: parentSource, Location: pointer.Location,
+			Field: "SchemeTerritory", Expected: pointer.SchemeTerritory, Actual: actualTerritory,
+		})
+	}
+	return mismatches
+}
+
+// checkReferencedTSL checks a TSL fetched from the pointer to location against
+// the pointer metadata of tsl. Mismatches are recorded in tsl.PointerMismatches
+// and logged. With options.StrictPointers an error is returned so that the
+// referenced TSL is skipped; a TSL not signed by a pinned signer of the
+// pointer is always skipped.
+func (tsl *TSL) checkReferencedTSL(location string, ref *TSL, options TSLFetchOptions) error {
+	info, ok := tsl.PointerInfo(location)
+	if !ok {
+		return nil
+	}
+	if len(info.Signers) > 0 {
+		if err := checkPinnedSigner(ref, info.Signers); err != nil {
+			return err
+		}
+	}
+	mismatches := CheckPointerConsistency(tsl, info, ref)
+	if len(mismatches) == 0 {
+		return nil
+	}
+	tsl.PointerMismatches = append(tsl.PointerMismatches, mismatches...)
+	for _, m := range mismatches {
+		log.WithFields(log.Fields{
+			"parent":   m.Parent,
+			"location": m.Location,
+			"field":    m.Field,
+			"expected": m.Expected,
+			"actual":   m.Actual,
+		}).Warn("g119612: Referenced TSL does not match pointer metadata")
+	}
+	if options.StrictPointers {
+		return fmt.Errorf("%w: %v", ErrPointerMismatch, mismatches[0])
+	}
+	return nil
+}
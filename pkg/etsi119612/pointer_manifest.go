@@ -0,0 +1,182 @@
+package etsi119612
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// PointerManifest is a compact, machine processable list of pointers to TSLs,
+// published by some ecosystems instead of a full list of the lists. Each
+// pointer gives the location of a TSL and the SHA-256 fingerprints of the
+// certificates allowed to sign it:
+//
+//	{
+//	  "territory": "EU",
+//	  "operator": "Example Federation",
+//	  "sequence": 12,
+//	  "pointers": [
+//	    {
+//	      "location": "https://tsl.example.se/SE.xml",
+//	      "territory": "SE",
+//	      "signers": ["3f8a...c1"]
+//	    }
+//	  ]
+//	}
+//
+// The manifest itself is not signed; the pins carry the trust. Manifest.TSL
+// turns it into a TSL without providers pointing to the lists, so that both
+// distribution models load into the same tree.
+type PointerManifest struct {
+	// Territory, Operator and Sequence describe the publisher of the
+	// manifest, and become the scheme information of its TSL.
+	Territory string `json:"territory,omitempty"`
+	Operator  string `json:"operator,omitempty"`
+	Sequence  int    `json:"sequence,omitempty"`
+
+	// Pointers are the listed TSLs.
+	Pointers []ManifestPointer `json:"pointers"`
+}
+
+// ManifestPointer is a pointer of a PointerManifest.
+type ManifestPointer struct {
+	// Location is the URL of the TSL.
+	Location string `json:"location"`
+	// TSLType and Territory are the values the TSL is expected to declare,
+	// checked like the pointer metadata of a list of the lists.
+	TSLType   string `json:"tslType,omitempty"`
+	Territory string `json:"territory,omitempty"`
+	// Signers are the hex SHA-256 fingerprints of the certificates allowed to
+	// sign the TSL. Colons and upper case are accepted.
+	Signers []string `json:"signers"`
+}
+
+// ParsePointerManifest parses a JSON pointer manifest. Every pointer needs a
+// location and at least one signer fingerprint; the fingerprints are returned
+// in lowercase hex without colons.
+func ParsePointerManifest(data []byte) (*PointerManifest, error) {
+	var manifest PointerManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid pointer manifest: %w", err)
+	}
+	if len(manifest.Pointers) == 0 {
+		return nil, fmt.Errorf("invalid pointer manifest: no pointers")
+	}
+	for i := range manifest.Pointers {
+		p := &manifest.Pointers[i]
+		p.Location = strings.TrimSpace(p.Location)
+		if p.Location == "" {
+			return nil, fmt.Errorf("invalid pointer manifest: pointer %d has no location", i+1)
+		}
+		if len(p.Signers) == 0 {
+			return nil, fmt.Errorf("invalid pointer manifest: pointer to %s has no signers", p.Location)
+		}
+		for j, signer := range p.Signers {
+			fingerprint, err := normalizeFingerprint(signer)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pointer manifest: pointer to %s: %w", p.Location, err)
+			}
+			p.Signers[j] = fingerprint
+		}
+	}
+	return &manifest, nil
+}
+
+// normalizeFingerprint returns a hex SHA-256 fingerprint in lowercase without
+// colons.
+func normalizeFingerprint(fingerprint string) (string, error) {
+	normalized := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(fingerprint), ":", ""))
+	if b, err := hex.DecodeString(normalized); err != nil || len(b) != sha256.Size {
+		return "", fmt.Errorf("invalid SHA-256 fingerprint %q", fingerprint)
+	}
+	return normalized, nil
+}
+
+// TSL returns a TSL standing in for the manifest read from source: it has no
+// providers, the scheme information of the manifest, and a pointer with its
+// PointerInfo, including the pinned signers, for every pointer of the manifest.
+func (m *PointerManifest) TSL(source string) *TSL {
+	info := &TSLSchemeInformationType{
+		TSLSequenceNumber:     m.Sequence,
+		TslSchemeTerritory:    m.Territory,
+		TslPointersToOtherTSL: &OtherTSLPointersType{},
+	}
+	if m.Operator != "" {
+		lang := Lang("en")
+		name := NonEmptyNormalizedString(m.Operator)
+		info.TslSchemeOperatorName = &InternationalNamesType{Name: []*MultiLangNormStringType{{XmlLangAttr: &lang, NonEmptyNormalizedString: &name}}}
+	}
+	tsl := &TSL{Source: source, StatusList: TrustStatusListType{TslSchemeInformation: info}}
+	for _, p := range m.Pointers {
+		info.TslPointersToOtherTSL.TslOtherTSLPointer = append(info.TslPointersToOtherTSL.TslOtherTSLPointer, &OtherTSLPointerType{TSLLocation: p.Location})
+		tsl.Pointers = append(tsl.Pointers, PointerInfo{
+			Location:        p.Location,
+			TSLType:         p.TSLType,
+			SchemeTerritory: p.Territory,
+			Signers:         slices.Clone(p.Signers),
+		})
+	}
+	return tsl
+}
+
+// FetchPointerManifest fetches the pointer manifest at url and the TSLs it
+// points to, like FetchTSLWithReferencesAndOptions does for a list of the
+// lists. The first TSL returned stands in for the manifest (see
+// PointerManifest.TSL). The pointed-to TSLs must be signed by one of their
+// pinned certificates; those that are not, or cannot be fetched, are logged
+// and skipped. Their own pointers are followed up to options.MaxDereferenceDepth
+// levels below the manifest, without pins.
+//
+// Parameters:
+//   - url: The URL of the manifest (supports file:// URLs for local files)
+//   - options: Options controlling HTTP request parameters and dereferencing depth
+//
+// Returns:
+//   - The TSL of the manifest followed by the TSLs fetched from its pointers
+//   - An error if the manifest cannot be fetched or parsed
+func FetchPointerManifest(url string, options TSLFetchOptions) ([]*TSL, error) {
+	client, release := options.fetchClient()
+	defer release()
+	options.Client = client
+
+	data, info, err := fetchDocument(context.Background(), url, options)
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := ParsePointerManifest(data)
+	if err != nil {
+		return nil, permanentError(fmt.Errorf("%s: %w", url, err))
+	}
+	root := manifest.TSL(url)
+	root.FetchInfo = info
+
+	// The pointers of the manifest are always followed
+	if options.MaxDereferenceDepth == 0 {
+		options.MaxDereferenceDepth = 1
+	}
+	allTSLs := map[string]*TSL{url: root}
+	if err := root.dereferencePointersTSLsRecursive(options, allTSLs, 1); err != nil {
+		log.Warnf("g119612: Error while dereferencing the pointers of %s: %v", url, err)
+	}
+	return referenceOrder(root, allTSLs), nil
+}
+
+// checkPinnedSigner checks that tsl is signed by a certificate with one of
+// the SHA-256 fingerprints pins.
+func checkPinnedSigner(tsl *TSL, pins []string) error {
+	if !tsl.Signed || len(tsl.Signer.Raw) == 0 {
+		return fmt.Errorf("%w: %s is not signed", ErrSignerNotPinned, tsl.Source)
+	}
+	digest := sha256.Sum256(tsl.Signer.Raw)
+	fingerprint := hex.EncodeToString(digest[:])
+	if !slices.Contains(pins, fingerprint) {
+		return fmt.Errorf("%w: %s is signed by %s (SHA-256 %s)", ErrSignerNotPinned, tsl.Source, tsl.Signer.Subject, fingerprint)
+	}
+	return nil
+}
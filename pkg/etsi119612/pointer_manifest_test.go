@@ -0,0 +1,110 @@
+package etsi119612_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/h2non/gock"
+	"github.com/sirosfoundation/g119612/pkg/etsi119612"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePointerManifest(t *testing.T) {
+	fingerprint := strings.Repeat("AB:", 31) + "AB"
+	manifest, err := etsi119612.ParsePointerManifest([]byte(fmt.Sprintf(`{
+		"territory": "EU", "operator": "Example Federation", "sequence": 3,
+		"pointers": [{"location": " https://example.com/SE.xml ", "territory": "SE", "signers": [%q]}]
+	}`, fingerprint)))
+	require.NoError(t, err)
+	require.Len(t, manifest.Pointers, 1)
+	assert.Equal(t, "https://example.com/SE.xml", manifest.Pointers[0].Location)
+	assert.Equal(t, []string{strings.Repeat("ab", 32)}, manifest.Pointers[0].Signers)
+
+	tsl := manifest.TSL("https://example.com/pointers.json")
+	id := tsl.Identity()
+	assert.Equal(t, etsi119612.TSLIdentity{Territory: "EU", Operator: "Example Federation", Sequence: 3, Source: "https://example.com/pointers.json"}, id)
+	info, ok := tsl.PointerInfo("https://example.com/SE.xml")
+	require.True(t, ok)
+	assert.Equal(t, "SE", info.SchemeTerritory)
+	assert.Equal(t, manifest.Pointers[0].Signers, info.Signers)
+
+	for name, data := range map[string]string{
+		"Not_JSON":            `<TrustServiceStatusList/>`,
+		"No_Pointers":         `{"pointers": []}`,
+		"No_Location":         `{"pointers": [{"signers": ["` + strings.Repeat("ab", 32) + `"]}]}`,
+		"No_Signers":          `{"pointers": [{"location": "https://example.com/SE.xml"}]}`,
+		"Invalid_Fingerprint": `{"pointers": [{"location": "https://example.com/SE.xml", "signers": ["abcd"]}]}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := etsi119612.ParsePointerManifest([]byte(data))
+			assert.ErrorContains(t, err, "invalid pointer manifest")
+		})
+	}
+}
+
+func TestFetchPointerManifest(t *testing.T) {
+	se, err := etsi119612.FetchTSLWithOptions("file://./testdata/SE-TL.xml", etsi119612.DefaultTSLFetchOptions)
+	require.NoError(t, err)
+	digest := sha256.Sum256(se.Signer.Raw)
+	pin := hex.EncodeToString(digest[:])
+	other := strings.Repeat("00", 32)
+
+	fetch := func(t *testing.T, sePins, ewcPins []string) []*etsi119612.TSL {
+		defer gock.Off()
+		quote := func(pins []string) string {
+			return `["` + strings.Join(pins, `","`) + `"]`
+		}
+		gock.New("https://example.com").
+			Get("/pointers.json").
+			Reply(200).
+			BodyString(`{"territory": "EU", "sequence": 1, "pointers": [
+				{"location": "https://example.com/SE.xml", "territory": "SE", "signers": ` + quote(sePins) + `},
+				{"location": "https://example.com/EWC.xml", "signers": ` + quote(ewcPins) + `}
+			]}`)
+		gock.New("https://example.com").
+			Get("/SE.xml").
+			Reply(200).
+			File("testdata/SE-TL.xml")
+		gock.New("https://example.com").
+			Get("/EWC.xml").
+			Reply(200).
+			File("testdata/EWC-TL.xml")
+
+		tsls, err := etsi119612.FetchPointerManifest("https://example.com/pointers.json", etsi119612.TSLFetchOptions{Timeout: 30 * time.Second})
+		require.NoError(t, err)
+		require.NotEmpty(t, tsls)
+		assert.Equal(t, "EU", tsls[0].Identity().Territory)
+		assert.NotNil(t, tsls[0].FetchInfo)
+		return tsls
+	}
+
+	t.Run("Pinned_Signer", func(t *testing.T) {
+		// The unsigned EWC list cannot match its pin and is skipped
+		tsls := fetch(t, []string{other, pin}, []string{other})
+		require.Len(t, tsls, 2)
+		assert.Equal(t, "SE", tsls[1].Identity().Territory)
+		assert.Equal(t, []*etsi119612.TSL{tsls[1]}, tsls[0].Referenced)
+	})
+
+	t.Run("Other_Signer", func(t *testing.T) {
+		tsls := fetch(t, []string{other}, []string{other})
+		require.Len(t, tsls, 1)
+		assert.Empty(t, tsls[0].Referenced)
+	})
+
+	t.Run("Invalid_Manifest", func(t *testing.T) {
+		defer gock.Off()
+		gock.New("https://example.com").
+			Get("/pointers.json").
+			Reply(200).
+			BodyString(`{"pointers": []}`)
+		_, err := etsi119612.FetchPointerManifest("https://example.com/pointers.json", etsi119612.TSLFetchOptions{Timeout: 30 * time.Second})
+		assert.ErrorContains(t, err, "https://example.com/pointers.json: invalid pointer manifest")
+		assert.True(t, etsi119612.IsPermanent(err))
+	})
+}
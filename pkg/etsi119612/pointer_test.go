@@ -0,0 +1,200 @@
+package etsi119612_test
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/h2non/gock"
+	"github.com/sirosfoundation/g119612/pkg/etsi119612"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	euGenericType = "http://uri.etsi.org/TrstSvc/TrustedList/TSLType/EUgeneric"
+	lotlType      = "http://uri.etsi.org/TrstSvc/TrustedList/TSLType/EUlistofthelists"
+)
+
+func TestPointerInfo(t *testing.T) {
+	tsl, err := etsi119612.FetchTSLWithOptions("file://./testdata/SE-TL.xml", etsi119612.DefaultTSLFetchOptions)
+	require.NoError(t, err)
+	require.NotEmpty(t, tsl.Pointers)
+
+	info, ok := tsl.PointerInfo(tsl.Pointers[0].Location)
+	require.True(t, ok)
+	assert.Equal(t, lotlType, info.TSLType)
+	assert.Equal(t, "EU", info.SchemeTerritory)
+	require.Len(t, info.Signers, 7, "the certificates of the ServiceDigitalIdentities")
+	assert.Len(t, info.Signers[0], 64)
+
+	_, ok = tsl.PointerInfo("https://example.com/unknown.xml")
+	assert.False(t, ok)
+}
+
+func TestCheckPointerConsistency(t *testing.T) {
+	child := &etsi119612.TSL{StatusList: etsi119612.TrustStatusListType{
+		TslSchemeInformation: &etsi119612.TSLSchemeInformationType{
+			TslTSLType:         euGenericType,
+			TslSchemeTerritory: "FR",
+		},
+	}}
+	parent := &etsi119612.TSL{Source: "https://example.com/lotl.xml"}
+
+	consistent := etsi119612.PointerInfo{Location: "https://example.com/fr.xml", TSLType: euGenericType, SchemeTerritory: "fr"}
+	assert.Empty(t, etsi119612.CheckPointerConsistency(parent, consistent, child))
+
+	undeclared := etsi119612.PointerInfo{Location: "https://example.com/fr.xml"}
+	assert.Empty(t, etsi119612.CheckPointerConsistency(parent, undeclared, child))
+
+	mismatched := etsi119612.PointerInfo{Location: "https://example.com/de.xml", TSLType: lotlType, SchemeTerritory: "DE"}
+	mismatches := etsi119612.CheckPointerConsistency(parent, mismatched, child)
+	require.Len(t, mismatches, 2)
+	assert.Equal(t, etsi119612.PointerMismatch{
+		Parent: "https://example.com/lotl.xml", Location: "https://example.com/de.xml",
+		Field: "TSLType", Expected: lotlType, Actual: euGenericType,
+	}, mismatches[0])
+	assert.Equal(t, "SchemeTerritory", mismatches[1].Field)
+	assert.Equal(t, "DE", mismatches[1].Expected)
+	assert.Equal(t, "FR", mismatches[1].Actual)
+	assert.Contains(t, mismatches[1].Error(), `declares SchemeTerritory "DE" but the referenced TSL declares "FR"`)
+
+	assert.Empty(t, etsi119612.CheckPointerConsistency(parent, mismatched, nil))
+}
+
+func mockTypedPointer() {
+	gock.New("https://example.com").
+		Get("/main.xml").
+		Reply(200).
+		File("testdata/TSL-with-typed-pointer.xml")
+	gock.New("https://example.com").
+		Get("/referenced.xml").
+		Reply(200).
+		File("testdata/EWC-TL.xml")
+}
+
+func TestFetchTSLWithReferences_PointerMismatch(t *testing.T) {
+	defer gock.Off()
+	mockTypedPointer()
+
+	options := etsi119612.TSLFetchOptions{Timeout: 30 * time.Second, MaxDereferenceDepth: 1}
+	tsls, err := etsi119612.FetchTSLWithReferencesAndOptions("https://example.com/main.xml", options)
+	require.NoError(t, err)
+
+	// Mismatches are recorded but the referenced TSL is kept
+	require.Len(t, tsls, 2)
+	root := tsls[0]
+	require.Len(t, root.PointerMismatches, 2)
+	assert.Equal(t, "TSLType", root.PointerMismatches[0].Field)
+	assert.Equal(t, "SchemeTerritory", root.PointerMismatches[1].Field)
+	assert.Equal(t, "DE", root.PointerMismatches[1].Expected)
+	assert.Equal(t, "TT", root.PointerMismatches[1].Actual)
+	assert.Equal(t, "https://example.com/main.xml", root.PointerMismatches[1].Parent)
+}
+
+func TestFetchTSLWithReferences_StrictPointers(t *testing.T) {
+	defer gock.Off()
+	mockTypedPointer()
+
+	options := etsi119612.TSLFetchOptions{Timeout: 30 * time.Second, MaxDereferenceDepth: 1, StrictPointers: true}
+	tsls, err := etsi119612.FetchTSLWithReferencesAndOptions("https://example.com/main.xml", options)
+	require.NoError(t, err)
+
+	// The mismatching referenced TSL is skipped
+	require.Len(t, tsls, 1)
+	assert.Empty(t, tsls[0].Referenced)
+	assert.Len(t, tsls[0].PointerMismatches, 2)
+}
+
+func TestFetchTSLWithReferences_PointerFilter(t *testing.T) {
+	defer gock.Off()
+	mockTypedPointer()
+
+	var seen []etsi119612.PointerInfo
+	options := etsi119612.TSLFetchOptions{
+		Timeout:             30 * time.Second,
+		MaxDereferenceDepth: 1,
+		PointerFilter: func(pointer etsi119612.PointerInfo) bool {
+			seen = append(seen, pointer)
+			return pointer.SchemeTerritory != "DE"
+		},
+	}
+	tsls, err := etsi119612.FetchTSLWithReferencesAndOptions("https://example.com/main.xml", options)
+	require.NoError(t, err)
+
+	require.Len(t, tsls, 1)
+	require.Len(t, seen, 1)
+	assert.Equal(t, "https://example.com/referenced.xml", seen[0].Location)
+	assert.Equal(t, euGenericType, seen[0].TSLType)
+	assert.True(t, gock.IsPending(), "the filtered pointer must not be fetched")
+}
+
+func TestFetchTSLWithReferences_PointerSigners(t *testing.T) {
+	se, err := etsi119612.FetchTSLWithOptions("file://./testdata/SE-TL.xml", etsi119612.DefaultTSLFetchOptions)
+	require.NoError(t, err)
+	// The certificate of a service of the list is not its signer
+	otherCert := se.StatusList.TslTrustServiceProviderList.TslTrustServiceProvider[0].TslTSPServices.TslTSPService[0].TslServiceInformation.TslServiceDigitalIdentity.DigitalId[0].X509Certificate
+
+	fetch := func(t *testing.T, seIdentities, ewcIdentities []string) []*etsi119612.TSL {
+		defer gock.Off()
+		pointer := func(location string, certs []string) string {
+			identities := ""
+			for _, cert := range certs {
+				identities += "<ServiceDigitalIdentity><DigitalId><X509Certificate>" + cert + "</X509Certificate></DigitalId></ServiceDigitalIdentity>"
+			}
+			if identities != "" {
+				identities = "<ServiceDigitalIdentities>" + identities + "</ServiceDigitalIdentities>"
+			}
+			return "<OtherTSLPointer>" + identities + "<TSLLocation>" + location + "</TSLLocation></OtherTSLPointer>"
+		}
+		gock.New("https://example.com").
+			Get("/lotl.xml").
+			Reply(200).
+			BodyString(`<TrustServiceStatusList xmlns="http://uri.etsi.org/02231/v2#"><SchemeInformation><PointersToOtherTSL>` +
+				pointer("https://example.com/SE.xml", seIdentities) +
+				pointer("https://example.com/EWC.xml", ewcIdentities) +
+				`</PointersToOtherTSL></SchemeInformation></TrustServiceStatusList>`)
+		gock.New("https://example.com").
+			Get("/SE.xml").
+			Reply(200).
+			File("testdata/SE-TL.xml")
+		gock.New("https://example.com").
+			Get("/EWC.xml").
+			Reply(200).
+			File("testdata/EWC-TL.xml")
+
+		options := etsi119612.TSLFetchOptions{Timeout: 30 * time.Second, MaxDereferenceDepth: 1}
+		tsls, err := etsi119612.FetchTSLWithReferencesAndOptions("https://example.com/lotl.xml", options)
+		require.NoError(t, err)
+		return tsls
+	}
+	signer := base64.StdEncoding.EncodeToString(se.Signer.Raw)
+	digest := sha256.Sum256(se.Signer.Raw)
+
+	t.Run("Pinned_Signer", func(t *testing.T) {
+		// Line breaks in the base64 of the certificate are ignored
+		wrapped := signer[:64] + "\n  " + signer[64:]
+		tsls := fetch(t, []string{otherCert, wrapped}, nil)
+		require.Len(t, tsls, 3, "a pointer without identities is not pinned")
+		info, ok := tsls[0].PointerInfo("https://example.com/SE.xml")
+		require.True(t, ok)
+		assert.Contains(t, info.Signers, hex.EncodeToString(digest[:]))
+		assert.Equal(t, "SE", tsls[1].Identity().Territory)
+	})
+
+	t.Run("Other_Signer", func(t *testing.T) {
+		// The SE list is signed by another certificate, the EWC list is unsigned
+		tsls := fetch(t, []string{otherCert}, []string{signer})
+		require.Len(t, tsls, 1)
+		assert.Empty(t, tsls[0].Referenced)
+	})
+
+	t.Run("Invalid_Identity", func(t *testing.T) {
+		tsls := fetch(t, []string{"not base64!", signer}, nil)
+		require.Len(t, tsls, 3)
+		info, _ := tsls[0].PointerInfo("https://example.com/SE.xml")
+		assert.Equal(t, []string{hex.EncodeToString(digest[:])}, info.Signers)
+	})
+}
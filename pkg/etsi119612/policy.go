@@ -0,0 +1,101 @@
+package etsi119612
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+)
+
+// policyDocument is the serialized form of a TSPServicePolicy:
+//
+//	serviceTypes:
+//	  - http://uri.etsi.org/TrstSvc/Svctype/CA/QC
+//	statuses:
+//	  - https://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/granted/
+//
+// An omitted statuses list means the default of NewTSPServicePolicy (granted
+// services only); an explicitly empty list is kept as given.
+type policyDocument struct {
+	ServiceTypes []string `json:"serviceTypes,omitempty" yaml:"serviceTypes,omitempty"`
+	Statuses     []string `json:"statuses" yaml:"statuses"`
+}
+
+// document returns the serialized form of the policy.
+func (tc *TSPServicePolicy) document() policyDocument {
+	doc := policyDocument{
+		ServiceTypes: slices.Clone(tc.ServiceTypeIdentifier),
+		Statuses:     slices.Clone(tc.ServiceStatus),
+	}
+	if doc.Statuses == nil {
+		doc.Statuses = []string{}
+	}
+	return doc
+}
+
+// setDocument replaces the policy with the content of a serialized form.
+func (tc *TSPServicePolicy) setDocument(doc policyDocument) {
+	tc.ServiceTypeIdentifier = slices.Clone(doc.ServiceTypes)
+	if tc.ServiceTypeIdentifier == nil {
+		tc.ServiceTypeIdentifier = make([]string, 0)
+	}
+	if doc.Statuses == nil {
+		tc.ServiceStatus = []string{ServiceStatusGranted}
+	} else {
+		tc.ServiceStatus = slices.Clone(doc.Statuses)
+	}
+}
+
+// MarshalJSON implements json.Marshaler.
+func (tc TSPServicePolicy) MarshalJSON() ([]byte, error) {
+	return json.Marshal(tc.document())
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (tc *TSPServicePolicy) UnmarshalJSON(data []byte) error {
+	var doc policyDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	tc.setDocument(doc)
+	return nil
+}
+
+// MarshalYAML implements yaml.Marshaler.
+func (tc TSPServicePolicy) MarshalYAML() (interface{}, error) {
+	return tc.document(), nil
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (tc *TSPServicePolicy) UnmarshalYAML(value *yaml.Node) error {
+	var doc policyDocument
+	if err := value.Decode(&doc); err != nil {
+		return err
+	}
+	tc.setDocument(doc)
+	return nil
+}
+
+// LoadTSPServicePolicy reads a TSPServicePolicy from a YAML or JSON file.
+// Unknown keys are rejected so typos in a reviewed policy file are not
+// silently ignored.
+func LoadTSPServicePolicy(path string) (*TSPServicePolicy, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy file %s: %w", path, err)
+	}
+	defer file.Close()
+
+	// JSON is a subset of YAML, so one decoder handles both
+	var doc policyDocument
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid policy file %s: %w", path, err)
+	}
+	policy := &TSPServicePolicy{}
+	policy.setDocument(doc)
+	return policy, nil
+}
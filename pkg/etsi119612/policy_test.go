@@ -0,0 +1,108 @@
+package etsi119612_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirosfoundation/g119612/pkg/etsi119612"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+const qcType = "http://uri.etsi.org/TrstSvc/Svctype/CA/QC"
+
+func TestTSPServicePolicy_JSONRoundTrip(t *testing.T) {
+	policy := etsi119612.NewTSPServicePolicy()
+	policy.AddServiceTypeIdentifier(qcType)
+
+	data, err := json.Marshal(policy)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"serviceTypes":["`+qcType+`"],"statuses":["`+etsi119612.ServiceStatusGranted+`"]}`, string(data))
+
+	var decoded etsi119612.TSPServicePolicy
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, *policy, decoded)
+}
+
+func TestTSPServicePolicy_YAMLRoundTrip(t *testing.T) {
+	policy := &etsi119612.TSPServicePolicy{
+		ServiceTypeIdentifier: []string{qcType},
+		ServiceStatus:         []string{"http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/granted"},
+	}
+
+	data, err := yaml.Marshal(policy)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "serviceTypes:")
+	assert.Contains(t, string(data), "statuses:")
+
+	var decoded etsi119612.TSPServicePolicy
+	require.NoError(t, yaml.Unmarshal(data, &decoded))
+	assert.Equal(t, *policy, decoded)
+}
+
+func TestTSPServicePolicy_DefaultStatuses(t *testing.T) {
+	// Omitted statuses take the default of NewTSPServicePolicy
+	var policy etsi119612.TSPServicePolicy
+	require.NoError(t, yaml.Unmarshal([]byte("serviceTypes: ["+qcType+"]\n"), &policy))
+	assert.Equal(t, []string{etsi119612.ServiceStatusGranted}, policy.ServiceStatus)
+
+	// An explicitly empty list is kept
+	require.NoError(t, json.Unmarshal([]byte(`{"statuses":[]}`), &policy))
+	assert.Empty(t, policy.ServiceStatus)
+	assert.NotNil(t, policy.ServiceTypeIdentifier)
+}
+
+func TestLoadTSPServicePolicy(t *testing.T) {
+	dir := t.TempDir()
+
+	yamlFile := filepath.Join(dir, "policy.yaml")
+	require.NoError(t, os.WriteFile(yamlFile, []byte("serviceTypes:\n  - "+qcType+"\nstatuses:\n  - "+etsi119612.ServiceStatusGranted+"\n"), 0600))
+	policy, err := etsi119612.LoadTSPServicePolicy(yamlFile)
+	require.NoError(t, err)
+	assert.Equal(t, []string{qcType}, policy.ServiceTypeIdentifier)
+	assert.Equal(t, []string{etsi119612.ServiceStatusGranted}, policy.ServiceStatus)
+
+	jsonFile := filepath.Join(dir, "policy.json")
+	require.NoError(t, os.WriteFile(jsonFile, []byte(`{"serviceTypes":["`+qcType+`"]}`), 0600))
+	policy, err = etsi119612.LoadTSPServicePolicy(jsonFile)
+	require.NoError(t, err)
+	assert.Equal(t, []string{qcType}, policy.ServiceTypeIdentifier)
+
+	typoFile := filepath.Join(dir, "typo.yaml")
+	require.NoError(t, os.WriteFile(typoFile, []byte("serviceType:\n  - "+qcType+"\n"), 0600))
+	_, err = etsi119612.LoadTSPServicePolicy(typoFile)
+	assert.Error(t, err, "unknown keys are rejected")
+
+	_, err = etsi119612.LoadTSPServicePolicy(filepath.Join(dir, "missing.yaml"))
+	assert.Error(t, err)
+}
+
+func TestTSPServicePolicy_LegacyStatuses(t *testing.T) {
+	tsp := &etsi119612.TSPType{}
+	service := func(status string) *etsi119612.TSPServiceType {
+		return &etsi119612.TSPServiceType{TslServiceInformation: &etsi119612.TSPServiceInformationType{
+			TslServiceTypeIdentifier: qcType,
+			TslServiceStatus:         status,
+		}}
+	}
+
+	// The default policy accepts granted services in either spelling and
+	// services of historic lists with an equivalent pre-eIDAS status
+	for _, status := range []string{
+		"http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/granted",
+		etsi119612.ServiceStatusGranted,
+		"http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/undersupervision",
+		"http://uri.etsi.org/TrstSvc/eSigDir-1999-93-EC-TrustedList/Svcstatus/accredited",
+	} {
+		assert.NoError(t, tsp.Validate(service(status), nil, nil), status)
+	}
+	for _, status := range []string{
+		"http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/withdrawn",
+		"http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/accreditationrevoked",
+	} {
+		assert.ErrorIs(t, tsp.Validate(service(status), nil, nil), etsi119612.ErrInvalidStatus, status)
+	}
+}
@@ -0,0 +1,299 @@
+package etsi119612
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// EULOTLURL is the location of the EU list of trusted lists.
+const EULOTLURL = "https://ec.europa.eu/tools/lotl/eu-lotl.xml"
+
+// serviceTypePrefix is the common prefix of the standard service type URIs.
+const serviceTypePrefix = "http://uri.etsi.org/TrstSvc/Svctype/"
+
+// QuickVerifyOptions configures QuickVerify. LOTLSigners or LOTLSignerRoots
+// must be set; with nothing else set, QuickVerify verifies against the granted
+// services of all the trusted lists of the EU LOTL.
+type QuickVerifyOptions struct {
+	// LOTLURL is the list of trusted lists to start from (default EULOTLURL).
+	// It may be a file:// URL.
+	LOTLURL string
+
+	// LOTLSigners are the certificates the LOTL may be signed with, such as
+	// the EU LOTL signing certificates published in the Official Journal of
+	// the European Union.
+	LOTLSigners []*x509.Certificate
+
+	// LOTLSignerRoots are the roots the signer of the LOTL must chain to.
+	LOTLSignerRoots *x509.CertPool
+
+	// Territory limits the trusted lists fetched from the LOTL to the one of a
+	// scheme territory, e.g. "SE". All pointed-to lists are fetched if empty.
+	Territory string
+
+	// ServiceType limits the trust anchors to the services of a type, given as
+	// a URI or relative to http://uri.etsi.org/TrstSvc/Svctype/, e.g. "CA/QC".
+	ServiceType string
+
+	// CacheDir, if set, is a directory where the fetched documents are kept.
+	// A cached list is reused until its NextUpdate has passed.
+	CacheDir string
+
+	// Now is the time the certificate is verified at and the cached lists are
+	// checked for freshness at, the current time if zero.
+	Now time.Time
+
+	// Intermediates are certificates that may be used to build the chain from
+	// the leaf to a trust anchor.
+	Intermediates []*x509.Certificate
+
+	// FetchOptions are the options the lists are fetched and verified with
+	// (default DefaultTSLFetchOptions). Pointers are followed by QuickVerify,
+	// so MaxDereferenceDepth is ignored.
+	FetchOptions *TSLFetchOptions
+}
+
+// QuickVerifyResult is the trust service a certificate was verified against.
+type QuickVerifyResult struct {
+	TSL      *TSL                // The trusted list of the service
+	Provider *TSPType            // The provider of the service
+	Service  *TSPServiceType     // The service listing the trust anchor
+	Chain    []*x509.Certificate // The verified chain, from the leaf to the trust anchor
+}
+
+// QuickVerify verifies a DER encoded certificate against the trusted lists
+// referenced by a list of trusted lists, for relying parties that just need a
+// yes or no and the matching service:
+//
+//	res, err := etsi119612.QuickVerify(ctx, cert.Raw, etsi119612.QuickVerifyOptions{
+//		LOTLSigners: lotlSigningCerts,
+//		Territory:   "SE",
+//		ServiceType: "CA/QC",
+//		CacheDir:    "/var/cache/tsl",
+//	})
+//
+// The LOTL and the trusted lists of the requested territory are fetched, or
+// read from CacheDir, with their signatures verified. The signer of the LOTL
+// must be one of opts.LOTLSigners and chain to opts.LOTLSignerRoots, whichever
+// are set; if neither is set, QuickVerify fails with ErrSignerUntrusted rather
+// than trust whatever list is served. A trusted list is checked against its
+// pointer as FetchTSLWithReferencesAndOptions does: it is skipped unless
+// signed by one of the ServiceDigitalIdentities of the pointer, if it has
+// any, and with FetchOptions.StrictPointers if it contradicts the pointer
+// metadata. The certificates of the granted services of the requested type
+// are the trust anchors; any extended key usage is accepted. An error
+// wrapping ErrNotTrusted is returned if no chain leads to one of them.
+func QuickVerify(ctx context.Context, leafDER []byte, opts QuickVerifyOptions) (*QuickVerifyResult, error) {
+	leaf, err := x509.ParseCertificate(leafDER)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate: %w", err)
+	}
+	options := DefaultTSLFetchOptions
+	if opts.FetchOptions != nil {
+		options = *opts.FetchOptions
+	}
+	lotlURL := opts.LOTLURL
+	if lotlURL == "" {
+		lotlURL = EULOTLURL
+	}
+	now := opts.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	if len(opts.LOTLSigners) == 0 && opts.LOTLSignerRoots == nil {
+		return nil, fmt.Errorf("%w: no LOTLSigners or LOTLSignerRoots to verify the LOTL with", ErrSignerUntrusted)
+	}
+	lotlOptions := options
+	if opts.LOTLSignerRoots != nil {
+		lotlOptions.SignerRoots = opts.LOTLSignerRoots
+	}
+	lotl, err := quickFetch(ctx, lotlURL, opts.CacheDir, now, lotlOptions)
+	if err != nil {
+		return nil, err
+	}
+	if err := opts.checkLOTLSigner(lotl); err != nil {
+		return nil, err
+	}
+	tsls := []*TSL{lotl}
+	if lotl.StatusList.TslSchemeInformation != nil && lotl.StatusList.TslSchemeInformation.TslPointersToOtherTSL != nil {
+		for _, p := range lotl.StatusList.TslSchemeInformation.TslPointersToOtherTSL.TslOtherTSLPointer {
+			if p == nil || !quickAcceptPointer(lotl, p.TSLLocation, opts.Territory) {
+				continue
+			}
+			tsl, err := quickFetch(ctx, p.TSLLocation, opts.CacheDir, now, options)
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil, ctx.Err()
+				}
+				log.Warnf("g119612: Failed to fetch referenced TSL %s: %v", p.TSLLocation, err)
+				continue
+			}
+			if err := lotl.checkReferencedTSL(p.TSLLocation, tsl, options); err != nil {
+				log.Warnf("g119612: Skipping referenced TSL %s: %v", p.TSLLocation, err)
+				continue
+			}
+			tsls = append(tsls, tsl)
+		}
+	}
+
+	policy := NewTSPServicePolicy()
+	if opts.ServiceType != "" {
+		serviceType := opts.ServiceType
+		if !strings.Contains(serviceType, "://") {
+			serviceType = serviceTypePrefix + strings.TrimPrefix(serviceType, "/")
+		}
+		policy.AddServiceTypeIdentifier(serviceType)
+	}
+
+	roots := x509.NewCertPool()
+	anchors := make(map[string]QuickVerifyResult)
+	for _, tsl := range tsls {
+		tsl.WithTrustServices(func(tsp *TSPType, svc *TSPServiceType) {
+			if tsp.Validate(svc, nil, policy) != nil {
+				return
+			}
+			svc.WithCertificates(func(cert *x509.Certificate) {
+				if _, ok := anchors[string(cert.Raw)]; !ok {
+					roots.AddCert(cert)
+					anchors[string(cert.Raw)] = QuickVerifyResult{TSL: tsl, Provider: tsp, Service: svc}
+				}
+			})
+		})
+	}
+	if len(anchors) == 0 {
+		return nil, fmt.Errorf("%w: no matching trust services found", ErrNotTrusted)
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range opts.Intermediates {
+		intermediates.AddCert(cert)
+	}
+	chains, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+		CurrentTime:   now,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNotTrusted, err)
+	}
+	for _, chain := range chains {
+		if res, ok := anchors[string(chain[len(chain)-1].Raw)]; ok {
+			res.Chain = chain
+			return &res, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: no chain ends at a trust service", ErrNotTrusted)
+}
+
+// checkLOTLSigner checks that lotl is signed by one of opts.LOTLSigners, if
+// set. The chain to opts.LOTLSignerRoots is verified by ParseTSL, which only
+// does so for signed lists.
+func (opts QuickVerifyOptions) checkLOTLSigner(lotl *TSL) error {
+	if !lotl.Signed || len(lotl.Signer.Raw) == 0 {
+		return fmt.Errorf("%w: %s is not signed", ErrSignerUntrusted, lotl.Source)
+	}
+	if len(opts.LOTLSigners) == 0 {
+		return nil
+	}
+	pins := make([]string, 0, len(opts.LOTLSigners))
+	for _, cert := range opts.LOTLSigners {
+		digest := sha256.Sum256(cert.Raw)
+		pins = append(pins, hex.EncodeToString(digest[:]))
+	}
+	return checkPinnedSigner(lotl, pins)
+}
+
+// quickAcceptPointer reports whether QuickVerify fetches the list at location.
+func quickAcceptPointer(lotl *TSL, location, territory string) bool {
+	// Pointers to the human readable PDF versions are of no use
+	if strings.HasSuffix(strings.ToLower(strings.TrimSpace(location)), ".pdf") {
+		return false
+	}
+	if territory == "" {
+		return true
+	}
+	info, _ := lotl.PointerInfo(location)
+	return strings.EqualFold(info.SchemeTerritory, territory)
+}
+
+// quickFetch fetches and parses the TSL at url, using the document cached in
+// cacheDir while its NextUpdate is after now.
+func quickFetch(ctx context.Context, url, cacheDir string, now time.Time, options TSLFetchOptions) (*TSL, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var cachePath string
+	if cacheDir != "" {
+		sum := sha256.Sum256([]byte(url))
+		cachePath = filepath.Join(cacheDir, hex.EncodeToString(sum[:])+".xml")
+		if data, err := os.ReadFile(cachePath); err == nil {
+			tsl, err := ParseTSL(data, url, options)
+			if err == nil && tsl.nextUpdate().After(now) {
+				log.Debugf("g119612: Using cached TSL for %s", url)
+				tsl.FetchInfo = &FetchInfo{URL: url, FinalURL: url, Size: len(data), CacheHit: true, FetchedAt: time.Now()}
+				return tsl, nil
+			}
+		}
+	}
+
+	data, info, err := fetchDocument(ctx, url, options)
+	if err != nil {
+		return nil, err
+	}
+	tsl, err := ParseTSL(data, url, options)
+	if err != nil {
+		return nil, permanentError(err)
+	}
+	tsl.FetchInfo = info
+	if cachePath != "" {
+		if err := writeCacheFile(cachePath, data); err != nil {
+			log.Warnf("g119612: Failed to cache TSL %s: %v", url, err)
+		}
+	}
+	return tsl, nil
+}
+
+// nextUpdate returns the NextUpdate of the TSL, the zero time if it has none.
+func (tsl *TSL) nextUpdate() time.Time {
+	info := tsl.StatusList.TslSchemeInformation
+	if info == nil || info.TslNextUpdate == nil {
+		return time.Time{}
+	}
+	t, err := parseXSDDateTime(strings.TrimSpace(info.TslNextUpdate.DateTime))
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// writeCacheFile writes data to path through a temporary file, so concurrent
+// readers never see a partial document.
+func writeCacheFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tsl-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
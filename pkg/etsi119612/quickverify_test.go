@@ -0,0 +1,252 @@
+package etsi119612_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirosfoundation/g119612/pkg/dsig"
+	"github.com/sirosfoundation/g119612/pkg/etsi119612"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// quickVerifyCert creates a certificate signed by parent, or a self-signed
+// certificate if parent is nil.
+func quickVerifyCert(t *testing.T, name string, ca bool, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	if ca {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage |= x509.KeyUsageCertSign
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert, key
+}
+
+// writeQuickVerifyTL writes a trusted list with one granted service of the given type.
+func writeQuickVerifyTL(t *testing.T, path, territory, serviceType string, ca *x509.Certificate) {
+	t.Helper()
+	data := fmt.Sprintf(`<TrustServiceStatusList xmlns="http://uri.etsi.org/02231/v2#">
+  <SchemeInformation>
+    <SchemeTerritory>%s</SchemeTerritory>
+    <NextUpdate><dateTime>%s</dateTime></NextUpdate>
+  </SchemeInformation>
+  <TrustServiceProviderList>
+    <TrustServiceProvider>
+      <TSPInformation><TSPName><Name xml:lang="en">%s Provider</Name></TSPName></TSPInformation>
+      <TSPServices>
+        <TSPService>
+          <ServiceInformation>
+            <ServiceTypeIdentifier>%s</ServiceTypeIdentifier>
+            <ServiceName><Name xml:lang="en">%s CA</Name></ServiceName>
+            <ServiceDigitalIdentity><DigitalId><X509Certificate>%s</X509Certificate></DigitalId></ServiceDigitalIdentity>
+            <ServiceStatus>%s</ServiceStatus>
+          </ServiceInformation>
+        </TSPService>
+      </TSPServices>
+    </TrustServiceProvider>
+  </TrustServiceProviderList>
+</TrustServiceStatusList>`, territory, time.Now().Add(24*time.Hour).UTC().Format(time.RFC3339), territory,
+		serviceType, territory, base64.StdEncoding.EncodeToString(ca.Raw), etsi119612.ServiceStatusGranted)
+	require.NoError(t, os.WriteFile(path, []byte(data), 0644))
+}
+
+func TestQuickVerify(t *testing.T) {
+	dir := t.TempDir()
+	seCA, seKey := quickVerifyCert(t, "SE CA", true, nil, nil)
+	deCA, _ := quickVerifyCert(t, "DE CA", true, nil, nil)
+	intermediate, intermediateKey := quickVerifyCert(t, "SE Issuing CA", true, seCA, seKey)
+	leaf, _ := quickVerifyCert(t, "leaf", false, intermediate, intermediateKey)
+
+	qcType := "http://uri.etsi.org/TrstSvc/Svctype/CA/QC"
+	seTL := filepath.Join(dir, "se.xml")
+	writeQuickVerifyTL(t, seTL, "SE", qcType, seCA)
+	writeQuickVerifyTL(t, filepath.Join(dir, "de.xml"), "DE", qcType, deCA)
+	lotl := filepath.Join(dir, "lotl.xml")
+	pointer := func(territory, location string) string {
+		return fmt.Sprintf(`<OtherTSLPointer><TSLLocation>%s</TSLLocation><AdditionalInformation>
+  <OtherInformation><SchemeTerritory>%s</SchemeTerritory></OtherInformation>
+</AdditionalInformation></OtherTSLPointer>`, location, territory)
+	}
+	lotlSigner, err := dsig.GenerateSelfSignedSigner(dsig.SelfSignedOptions{CommonName: "LOTL Operator"})
+	require.NoError(t, err)
+	writeLOTL := func(path, pointers string) {
+		signed, err := lotlSigner.Sign([]byte(`<TrustServiceStatusList xmlns="http://uri.etsi.org/02231/v2#">
+  <SchemeInformation><PointersToOtherTSL>` + pointers + `
+  </PointersToOtherTSL></SchemeInformation>
+</TrustServiceStatusList>`))
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(path, signed, 0644))
+	}
+	writeLOTL(lotl, pointer("SE", "file://"+seTL)+
+		pointer("SE", "https://example.com/se.pdf")+
+		pointer("DE", "file://"+filepath.Join(dir, "de.xml")))
+
+	opts := etsi119612.QuickVerifyOptions{
+		LOTLURL:       "file://" + lotl,
+		LOTLSigners:   []*x509.Certificate{lotlSigner.Certificate},
+		Territory:     "se",
+		ServiceType:   "CA/QC",
+		Intermediates: []*x509.Certificate{intermediate},
+	}
+
+	t.Run("Verified", func(t *testing.T) {
+		res, err := etsi119612.QuickVerify(context.Background(), leaf.Raw, opts)
+		require.NoError(t, err)
+		assert.Equal(t, "SE Provider", res.Provider.Name())
+		assert.Equal(t, qcType, res.Service.TslServiceInformation.TslServiceTypeIdentifier)
+		assert.Equal(t, "file://"+seTL, res.TSL.Source)
+		require.Len(t, res.Chain, 3)
+		assert.True(t, res.Chain[2].Equal(seCA))
+	})
+
+	t.Run("Not Trusted", func(t *testing.T) {
+		for name, change := range map[string]func(*etsi119612.QuickVerifyOptions){
+			"other territory":      func(o *etsi119612.QuickVerifyOptions) { o.Territory = "DE" },
+			"other service type":   func(o *etsi119612.QuickVerifyOptions) { o.ServiceType = "TSA" },
+			"missing intermediate": func(o *etsi119612.QuickVerifyOptions) { o.Intermediates = nil },
+			"expired":              func(o *etsi119612.QuickVerifyOptions) { o.Now = time.Now().Add(2 * time.Hour) },
+		} {
+			o := opts
+			change(&o)
+			_, err := etsi119612.QuickVerify(context.Background(), leaf.Raw, o)
+			assert.ErrorIs(t, err, etsi119612.ErrNotTrusted, name)
+		}
+	})
+
+	t.Run("Cache", func(t *testing.T) {
+		o := opts
+		o.CacheDir = filepath.Join(dir, "cache")
+		_, err := etsi119612.QuickVerify(context.Background(), leaf.Raw, o)
+		require.NoError(t, err)
+		files, err := os.ReadDir(o.CacheDir)
+		require.NoError(t, err)
+		assert.Len(t, files, 2, "the LOTL and the SE list are cached")
+
+		// The cached list is used until its NextUpdate
+		require.NoError(t, os.Rename(seTL, seTL+".moved"))
+		defer os.Rename(seTL+".moved", seTL)
+		res, err := etsi119612.QuickVerify(context.Background(), leaf.Raw, o)
+		require.NoError(t, err)
+		assert.Equal(t, "SE Provider", res.Provider.Name())
+
+		_, err = etsi119612.QuickVerify(context.Background(), leaf.Raw, opts)
+		assert.ErrorIs(t, err, etsi119612.ErrNotTrusted, "without the cache the list is missing")
+	})
+
+	t.Run("Pinned Signer", func(t *testing.T) {
+		pinned, err := dsig.GenerateSelfSignedSigner(dsig.SelfSignedOptions{CommonName: "SE Operator"})
+		require.NoError(t, err)
+		other, err := dsig.GenerateSelfSignedSigner(dsig.SelfSignedOptions{CommonName: "Impostor"})
+		require.NoError(t, err)
+		data, err := os.ReadFile(seTL)
+		require.NoError(t, err)
+		signedTL := filepath.Join(dir, "se-signed.xml")
+		pinnedLOTL := filepath.Join(dir, "lotl-pinned.xml")
+		writeLOTL(pinnedLOTL, `<OtherTSLPointer>
+    <ServiceDigitalIdentities><ServiceDigitalIdentity><DigitalId><X509Certificate>`+
+			base64.StdEncoding.EncodeToString(pinned.Certificate.Raw)+`</X509Certificate></DigitalId></ServiceDigitalIdentity></ServiceDigitalIdentities>
+    <TSLLocation>file://`+signedTL+`</TSLLocation>
+    <AdditionalInformation><OtherInformation><SchemeTerritory>SE</SchemeTerritory></OtherInformation></AdditionalInformation>
+  </OtherTSLPointer>`)
+		o := opts
+		o.LOTLURL = "file://" + pinnedLOTL
+
+		// A list served at the pointer location by another signer is skipped
+		signed, err := other.Sign(data)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(signedTL, signed, 0644))
+		_, err = etsi119612.QuickVerify(context.Background(), leaf.Raw, o)
+		assert.ErrorIs(t, err, etsi119612.ErrNotTrusted)
+
+		signed, err = pinned.Sign(data)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(signedTL, signed, 0644))
+		res, err := etsi119612.QuickVerify(context.Background(), leaf.Raw, o)
+		require.NoError(t, err)
+		assert.Equal(t, "SE Provider", res.Provider.Name())
+	})
+
+	t.Run("LOTL Signer", func(t *testing.T) {
+		other, err := dsig.GenerateSelfSignedSigner(dsig.SelfSignedOptions{CommonName: "Other Operator"})
+		require.NoError(t, err)
+		signerRoots := x509.NewCertPool()
+		signerRoots.AddCert(lotlSigner.Certificate)
+		otherRoots := x509.NewCertPool()
+		otherRoots.AddCert(other.Certificate)
+
+		o := opts
+		o.LOTLSigners = nil
+		_, err = etsi119612.QuickVerify(context.Background(), leaf.Raw, o)
+		assert.ErrorIs(t, err, etsi119612.ErrSignerUntrusted, "no LOTL signers given")
+
+		o.LOTLSigners = []*x509.Certificate{other.Certificate}
+		_, err = etsi119612.QuickVerify(context.Background(), leaf.Raw, o)
+		assert.ErrorIs(t, err, etsi119612.ErrSignerNotPinned)
+
+		o.LOTLSigners = nil
+		o.LOTLSignerRoots = otherRoots
+		_, err = etsi119612.QuickVerify(context.Background(), leaf.Raw, o)
+		assert.ErrorIs(t, err, etsi119612.ErrSignerUntrusted)
+
+		o.LOTLSignerRoots = signerRoots
+		res, err := etsi119612.QuickVerify(context.Background(), leaf.Raw, o)
+		require.NoError(t, err)
+		assert.Equal(t, "SE Provider", res.Provider.Name())
+
+		// An unsigned LOTL is never trusted
+		unsigned := filepath.Join(dir, "lotl-unsigned.xml")
+		require.NoError(t, os.WriteFile(unsigned, []byte(`<TrustServiceStatusList xmlns="http://uri.etsi.org/02231/v2#">
+  <SchemeInformation><PointersToOtherTSL>`+pointer("SE", "file://"+seTL)+`</PointersToOtherTSL></SchemeInformation>
+</TrustServiceStatusList>`), 0644))
+		o.LOTLURL = "file://" + unsigned
+		_, err = etsi119612.QuickVerify(context.Background(), leaf.Raw, o)
+		assert.ErrorIs(t, err, etsi119612.ErrSignerUntrusted)
+		o.LOTLSignerRoots = nil
+		o.LOTLSigners = []*x509.Certificate{lotlSigner.Certificate}
+		_, err = etsi119612.QuickVerify(context.Background(), leaf.Raw, o)
+		assert.ErrorIs(t, err, etsi119612.ErrSignerUntrusted)
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := etsi119612.QuickVerify(ctx, leaf.Raw, opts)
+		assert.ErrorIs(t, err, context.Canceled)
+
+		_, err = etsi119612.QuickVerify(context.Background(), []byte("not a certificate"), opts)
+		assert.Error(t, err)
+
+		o := opts
+		o.LOTLURL = "file://" + filepath.Join(dir, "missing.xml")
+		_, err = etsi119612.QuickVerify(context.Background(), leaf.Raw, o)
+		assert.Error(t, err)
+		assert.NotErrorIs(t, err, etsi119612.ErrNotTrusted)
+	})
+}
@@ -0,0 +1,83 @@
+package etsi119612
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultRawSpillThreshold is the size in bytes above which documents are
+// spilled to TSLFetchOptions.RawSpillDir when RawSpillThreshold is not set.
+const DefaultRawSpillThreshold = 8 << 20
+
+// spilledDocument locates a document written to disk instead of being kept
+// in TSL.Raw.
+type spilledDocument struct {
+	path   string
+	size   int
+	sha256 [sha256.Size]byte
+}
+
+// RawXML returns the document the TSL was parsed from, exactly as it was
+// fetched or passed to ParseTSL, signature included. This is what archives,
+// mirrors and comparisons of published lists must use, as re-serializing
+// StatusList does not reproduce the original bytes. A document spilled to
+// TSLFetchOptions.RawSpillDir is read back and checked against its digest.
+// It returns nil without an error for TSLs that were not parsed from a
+// document, such as generated ones.
+func (tsl *TSL) RawXML() ([]byte, error) {
+	if tsl == nil {
+		return nil, nil
+	}
+	if tsl.spilled == nil {
+		return tsl.Raw, nil
+	}
+	data, err := os.ReadFile(tsl.spilled.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the document of %s: %w", tsl.Source, err)
+	}
+	if sum := sha256.Sum256(data); !bytes.Equal(sum[:], tsl.spilled.sha256[:]) {
+		return nil, fmt.Errorf("the document of %s changed on disk: %s", tsl.Source, tsl.spilled.path)
+	}
+	return data, nil
+}
+
+// RawSize returns the size in bytes of the document returned by RawXML,
+// without reading a spilled document back.
+func (tsl *TSL) RawSize() int {
+	if tsl == nil {
+		return 0
+	}
+	if tsl.spilled != nil {
+		return tsl.spilled.size
+	}
+	return len(tsl.Raw)
+}
+
+// spillRaw moves the document of a parsed TSL from Raw to a file in
+// options.RawSpillDir if it is larger than the spill threshold. The file is
+// named by the SHA-256 of the document, so a document loaded again is
+// written to the same file. If the file cannot be written the document is
+// kept in memory.
+func (tsl *TSL) spillRaw(options TSLFetchOptions) {
+	threshold := options.RawSpillThreshold
+	if threshold <= 0 {
+		threshold = DefaultRawSpillThreshold
+	}
+	if options.RawSpillDir == "" || len(tsl.Raw) <= threshold {
+		return
+	}
+	spilled := &spilledDocument{size: len(tsl.Raw), sha256: sha256.Sum256(tsl.Raw)}
+	spilled.path = filepath.Join(options.RawSpillDir, hex.EncodeToString(spilled.sha256[:])+".xml")
+	if err := writeCacheFile(spilled.path, tsl.Raw); err != nil {
+		log.Warnf("g119612: Failed to spill the document of %s, keeping it in memory: %v", tsl.Source, err)
+		return
+	}
+	tsl.spilled = spilled
+	tsl.Raw = nil
+}
@@ -0,0 +1,60 @@
+package etsi119612_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirosfoundation/g119612/pkg/etsi119612"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTSL_RawXML(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "EWC-TL.xml"))
+	require.NoError(t, err)
+
+	// Documents are kept in memory by default
+	tsl, err := etsi119612.ParseTSL(data, "EWC-TL.xml", etsi119612.DefaultTSLFetchOptions)
+	require.NoError(t, err)
+	raw, err := tsl.RawXML()
+	require.NoError(t, err)
+	assert.Equal(t, data, raw)
+	assert.Equal(t, len(data), tsl.RawSize())
+
+	// Documents above the threshold are spilled to the directory
+	dir := t.TempDir()
+	options := etsi119612.DefaultTSLFetchOptions
+	options.RawSpillDir = dir
+	options.RawSpillThreshold = 1024
+	tsl, err = etsi119612.ParseTSL(data, "EWC-TL.xml", options)
+	require.NoError(t, err)
+	assert.Nil(t, tsl.Raw)
+	assert.Equal(t, len(data), tsl.RawSize())
+	raw, err = tsl.RawXML()
+	require.NoError(t, err)
+	assert.Equal(t, data, raw)
+	files, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+
+	// A spilled document that changed is not returned
+	path := filepath.Join(dir, files[0].Name())
+	require.NoError(t, os.WriteFile(path, append(data, '\n'), 0600))
+	_, err = tsl.RawXML()
+	assert.ErrorContains(t, err, "changed on disk")
+	require.NoError(t, os.Remove(path))
+	_, err = tsl.RawXML()
+	assert.Error(t, err)
+
+	// Small documents stay in memory
+	options.RawSpillThreshold = len(data)
+	tsl, err = etsi119612.ParseTSL(data, "EWC-TL.xml", options)
+	require.NoError(t, err)
+	assert.Equal(t, data, tsl.Raw)
+
+	// Generated TSLs have no document
+	raw, err = (&etsi119612.TSL{}).RawXML()
+	require.NoError(t, err)
+	assert.Nil(t, raw)
+}
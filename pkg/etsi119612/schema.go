@@ -5,8 +5,16 @@ type SignaturePolicyImplied AnyType
 type AllSignedDataObjects AnyType
 type Lang string
 
+// FindByLanguage returns the name in lang, or dflt if names has none. Names
+// without a language or text, as found in malformed lists, are skipped.
 func FindByLanguage(names *InternationalNamesType, lang string, dflt string) string {
+	if names == nil {
+		return dflt
+	}
 	for _, n := range names.Name {
+		if n == nil || n.XmlLangAttr == nil || n.NonEmptyNormalizedString == nil {
+			continue
+		}
 		if string(*n.XmlLangAttr) == lang {
 			return string(*n.NonEmptyNormalizedString)
 		}
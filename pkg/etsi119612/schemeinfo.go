@@ -0,0 +1,103 @@
+package etsi119612
+
+import (
+	"strings"
+)
+
+// SchemeOperatorDetails collects the information about the scheme operator of
+// a TSL needed to contact it (the SchemeOperatorAddress element), together
+// with the policies and legal notices under which it publishes the list (the
+// PolicyOrLegalNotice element).
+type SchemeOperatorDetails struct {
+	Name                string              `json:"name"`
+	Names               []LangString        `json:"names,omitempty"`
+	PostalAddresses     []PostalAddressInfo `json:"postal_addresses,omitempty"`
+	ElectronicAddresses []LangString        `json:"electronic_addresses,omitempty"`
+	Policies            []LangString        `json:"policies,omitempty"`
+	LegalNotices        []LangString        `json:"legal_notices,omitempty"`
+}
+
+// Emails returns the e-mail addresses among the electronic addresses of the
+// scheme operator, without the mailto: scheme and in document order.
+func (d SchemeOperatorDetails) Emails() []string {
+	var emails []string
+	for _, a := range d.ElectronicAddresses {
+		if len(a.Value) > len("mailto:") && strings.EqualFold(a.Value[:len("mailto:")], "mailto:") {
+			email, _, _ := strings.Cut(a.Value[len("mailto:"):], "?")
+			emails = append(emails, email)
+		}
+	}
+	return emails
+}
+
+// schemeInformation returns the SchemeInformation of a TSL, nil if it has none.
+func (tsl *TSL) schemeInformation() *TSLSchemeInformationType {
+	if tsl == nil {
+		return nil
+	}
+	return tsl.StatusList.TslSchemeInformation
+}
+
+// SchemeOperatorPostalAddresses returns the postal addresses of the scheme
+// operator, one per language.
+func (tsl *TSL) SchemeOperatorPostalAddresses() []PostalAddressInfo {
+	info := tsl.schemeInformation()
+	if info == nil {
+		return nil
+	}
+	return postalAddresses(info.SchemeOperatorAddress)
+}
+
+// SchemeOperatorElectronicAddresses returns the electronic addresses of the
+// scheme operator, URIs such as mailto: or https: contact pages.
+func (tsl *TSL) SchemeOperatorElectronicAddresses() []LangString {
+	info := tsl.schemeInformation()
+	if info == nil || info.SchemeOperatorAddress == nil || info.SchemeOperatorAddress.TslElectronicAddress == nil {
+		return nil
+	}
+	return langURIs(info.SchemeOperatorAddress.TslElectronicAddress.URI)
+}
+
+// Policies returns the URIs of the policies under which the TSL is published
+// (the TSLPolicy elements of PolicyOrLegalNotice).
+func (tsl *TSL) Policies() []LangString {
+	info := tsl.schemeInformation()
+	if info == nil || info.TslPolicyOrLegalNotice == nil {
+		return nil
+	}
+	return langURIs(info.TslPolicyOrLegalNotice.TSLPolicy)
+}
+
+// LegalNotices returns the legal notices of the TSL (the TSLLegalNotice
+// elements of PolicyOrLegalNotice), one per language. Empty notices are
+// skipped.
+func (tsl *TSL) LegalNotices() []LangString {
+	info := tsl.schemeInformation()
+	if info == nil || info.TslPolicyOrLegalNotice == nil {
+		return nil
+	}
+	var notices []LangString
+	for _, text := range info.TslPolicyOrLegalNotice.TSLLegalNotice {
+		if text == nil || text.NonEmptyString == nil || strings.TrimSpace(string(*text.NonEmptyString)) == "" {
+			continue
+		}
+		notices = append(notices, LangString{Lang: langOf(text.XmlLangAttr), Value: strings.TrimSpace(string(*text.NonEmptyString))})
+	}
+	return notices
+}
+
+// SchemeOperatorDetails returns the names and addresses of the scheme operator
+// and the policies and legal notices of the TSL.
+func (tsl *TSL) SchemeOperatorDetails() SchemeOperatorDetails {
+	details := SchemeOperatorDetails{
+		Name:                tsl.SchemeOperatorName(),
+		PostalAddresses:     tsl.SchemeOperatorPostalAddresses(),
+		ElectronicAddresses: tsl.SchemeOperatorElectronicAddresses(),
+		Policies:            tsl.Policies(),
+		LegalNotices:        tsl.LegalNotices(),
+	}
+	if info := tsl.schemeInformation(); info != nil {
+		details.Names = LangStrings(info.TslSchemeOperatorName)
+	}
+	return details
+}
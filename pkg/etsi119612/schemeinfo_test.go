@@ -0,0 +1,62 @@
+package etsi119612_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirosfoundation/g119612/pkg/etsi119612"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchemeOperatorDetails(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "SE-TL.xml"))
+	require.NoError(t, err)
+	tsl, err := etsi119612.ParseTSL(data, "SE-TL.xml", etsi119612.DefaultTSLFetchOptions)
+	require.NoError(t, err)
+
+	addresses := tsl.SchemeOperatorPostalAddresses()
+	require.Len(t, addresses, 1)
+	assert.Equal(t, "Box 5398, 10249 Stockholm, SE", addresses[0].String())
+	assert.Equal(t, []etsi119612.LangString{
+		{Lang: "en", Value: "mailto:pts@pts.se"},
+		{Lang: "en", Value: "http://www.pts.se/en-GB/"},
+	}, tsl.SchemeOperatorElectronicAddresses())
+	assert.Empty(t, tsl.Policies())
+	notices := tsl.LegalNotices()
+	require.Len(t, notices, 1)
+	assert.Equal(t, "en", notices[0].Lang)
+	assert.Contains(t, notices[0].Value, "Regulation (EU) No 910/2014")
+
+	details := tsl.SchemeOperatorDetails()
+	assert.Equal(t, tsl.SchemeOperatorName(), details.Name)
+	assert.NotEmpty(t, details.Names)
+	assert.Equal(t, addresses, details.PostalAddresses)
+	assert.Equal(t, notices, details.LegalNotices)
+	assert.Equal(t, []string{"pts@pts.se"}, details.Emails())
+
+	// The details are part of the summary and of the JSON representation
+	assert.Equal(t, details, tsl.Summary()["scheme_operator"])
+	encoded, err := json.Marshal(tsl)
+	require.NoError(t, err)
+	assert.Contains(t, string(encoded), `"mailto:pts@pts.se"`)
+	assert.Contains(t, string(encoded), `Regulation (EU) No 910/2014`)
+}
+
+func TestSchemeOperatorDetails_Missing(t *testing.T) {
+	var nilTSL *etsi119612.TSL
+	assert.Nil(t, nilTSL.SchemeOperatorPostalAddresses())
+	assert.Nil(t, nilTSL.SchemeOperatorElectronicAddresses())
+	assert.Nil(t, nilTSL.Policies())
+	assert.Nil(t, nilTSL.LegalNotices())
+	assert.Equal(t, "Unknown scheme operator", nilTSL.SchemeOperatorDetails().Name)
+
+	details := etsi119612.SchemeOperatorDetails{ElectronicAddresses: []etsi119612.LangString{
+		{Value: "https://example.com/contact"},
+		{Value: "MAILTO:ops@example.com?subject=TSL"},
+		{Value: "mailto:"},
+	}}
+	assert.Equal(t, []string{"ops@example.com"}, details.Emails())
+}
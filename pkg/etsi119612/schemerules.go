@@ -0,0 +1,54 @@
+package etsi119612
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ValidateSchemeRules checks the SchemeInformation of a parsed TSL against the
+// rules of ETSI TS 119 612 clause 5.3 that the schema does not express, so it
+// complements ValidateStrict. It reports:
+//   - a version identifier or sequence number that is not positive
+//   - a missing or empty SchemeTerritory or PolicyOrLegalNotice, which the
+//     schema leaves optional but the rules make mandatory
+//   - a next update date that is not after the issue date (a closed list has
+//     a NextUpdate without date)
+//
+// A TSL without SchemeInformation, which ValidateStrict rejects, passes.
+//
+// Returns:
+//   - nil if the TSL passes all checks
+//   - A *StrictValidationError listing every problem found
+func ValidateSchemeRules(tsl *TSL) error {
+	info := tsl.schemeInformation()
+	if info == nil {
+		return nil
+	}
+	var issues []string
+	if info.TSLVersionIdentifier <= 0 {
+		issues = append(issues, fmt.Sprintf("TSLVersionIdentifier %d is not positive", info.TSLVersionIdentifier))
+	}
+	if info.TSLSequenceNumber <= 0 {
+		issues = append(issues, fmt.Sprintf("TSLSequenceNumber %d is not positive", info.TSLSequenceNumber))
+	}
+	if strings.TrimSpace(info.TslSchemeTerritory) == "" {
+		issues = append(issues, "missing mandatory element <SchemeTerritory> in SchemeInformation")
+	}
+	if notices := info.TslPolicyOrLegalNotice; notices == nil || len(notices.TSLPolicy)+len(notices.TSLLegalNotice) == 0 {
+		issues = append(issues, "missing mandatory element <PolicyOrLegalNotice> in SchemeInformation")
+	}
+	if info.TslNextUpdate != nil {
+		value := strings.TrimSpace(info.TslNextUpdate.DateTime)
+		issue := strings.TrimSpace(info.ListIssueDateTime)
+		next, err := parseXSDDateTime(value)
+		issued, issueErr := parseXSDDateTime(issue)
+		if value != "" && err == nil && issueErr == nil && !next.After(issued) {
+			issues = append(issues, fmt.Sprintf("NextUpdate %s is not after ListIssueDateTime %s", value, issue))
+		}
+	}
+
+	if len(issues) > 0 {
+		return &StrictValidationError{Issues: issues}
+	}
+	return nil
+}
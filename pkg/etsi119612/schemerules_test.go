@@ -0,0 +1,70 @@
+package etsi119612_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sirosfoundation/g119612/pkg/etsi119612"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// schemeRulesTSL parses the strict test list with the replacements applied
+// to its SchemeInformation, after adding the legal notice it lacks.
+func schemeRulesTSL(t *testing.T, replacements ...string) *etsi119612.TSL {
+	t.Helper()
+	data := string(strictTSL("2025-01-01T00:00:00Z", "", ""))
+	data = strings.Replace(data, "<HistoricalInformationPeriod>",
+		`<PolicyOrLegalNotice><TSLLegalNotice xml:lang="en">Notice</TSLLegalNotice></PolicyOrLegalNotice><HistoricalInformationPeriod>`, 1)
+	data = strings.NewReplacer(replacements...).Replace(data)
+	tsl, err := etsi119612.ParseTSL([]byte(data), "scheme-rules.xml", etsi119612.DefaultTSLFetchOptions)
+	require.NoError(t, err)
+	return tsl
+}
+
+func TestValidateSchemeRules_Valid(t *testing.T) {
+	assert.NoError(t, etsi119612.ValidateSchemeRules(schemeRulesTSL(t)))
+
+	// A closed list has a NextUpdate without a date
+	assert.NoError(t, etsi119612.ValidateSchemeRules(schemeRulesTSL(t,
+		"<NextUpdate><dateTime>2030-01-01T00:00:00Z</dateTime></NextUpdate>", "<NextUpdate/>")))
+
+	data, err := os.ReadFile(filepath.Join("testdata", "SE-TL.xml"))
+	require.NoError(t, err)
+	tsl, err := etsi119612.ParseTSL(data, "SE-TL.xml", etsi119612.DefaultTSLFetchOptions)
+	require.NoError(t, err)
+	assert.NoError(t, etsi119612.ValidateSchemeRules(tsl))
+}
+
+func TestValidateSchemeRules_Issues(t *testing.T) {
+	tests := []struct {
+		name         string
+		replacements []string
+		want         string
+	}{
+		{"sequence number", []string{"<TSLSequenceNumber>1<", "<TSLSequenceNumber>0<"}, "TSLSequenceNumber 0 is not positive"},
+		{"version", []string{"<TSLVersionIdentifier>5<", "<TSLVersionIdentifier>-1<"}, "TSLVersionIdentifier -1 is not positive"},
+		{"next update before issue", []string{"2030-01-01T00:00:00Z", "2024-12-31T00:00:00Z"}, "NextUpdate 2024-12-31T00:00:00Z is not after ListIssueDateTime 2025-01-01T00:00:00Z"},
+		{"territory", []string{"<SchemeTerritory>SE</SchemeTerritory>", ""}, "missing mandatory element <SchemeTerritory>"},
+		{"legal notice", []string{`<TSLLegalNotice xml:lang="en">Notice</TSLLegalNotice>`, ""}, "missing mandatory element <PolicyOrLegalNotice>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issues := strictIssues(t, etsi119612.ValidateSchemeRules(schemeRulesTSL(t, tt.replacements...)))
+			require.Len(t, issues, 1, issues)
+			assert.Contains(t, issues[0], tt.want)
+		})
+	}
+}
+
+func TestValidateSchemeRules_SchemaIssues(t *testing.T) {
+	// Problems the schema covers are left to ValidateStrict
+	assert.NoError(t, etsi119612.ValidateSchemeRules(&etsi119612.TSL{}))
+	assert.NoError(t, etsi119612.ValidateSchemeRules(schemeRulesTSL(t, "2030-01-01T00:00:00Z", "soon")))
+	tsl := schemeRulesTSL(t, "<NextUpdate><dateTime>2030-01-01T00:00:00Z</dateTime></NextUpdate>", "")
+	assert.NoError(t, etsi119612.ValidateSchemeRules(tsl))
+	issues := strictIssues(t, etsi119612.ValidateStrict(tsl.Raw))
+	assert.Equal(t, []string{"missing mandatory element <NextUpdate> in TrustServiceStatusList/SchemeInformation"}, issues)
+}
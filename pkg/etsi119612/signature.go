@@ -0,0 +1,131 @@
+package etsi119612
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/beevik/etree"
+)
+
+// xadesSignedPropertiesType is the Reference Type of the XAdES SignedProperties.
+const xadesSignedPropertiesType = "http://uri.etsi.org/01903#SignedProperties"
+
+// SignatureInfo describes how a TSL is signed. The algorithms are the URIs
+// used in the XML signature, e.g. "http://www.w3.org/2001/04/xmldsig-more#rsa-sha256".
+type SignatureInfo struct {
+	// SignatureAlgorithm is the SignatureMethod algorithm.
+	SignatureAlgorithm string
+	// DigestAlgorithm is the DigestMethod algorithm of the reference to the list itself.
+	DigestAlgorithm string
+	// CanonicalizationMethod is the CanonicalizationMethod algorithm of the SignedInfo.
+	CanonicalizationMethod string
+	// SigningTime is the XAdES SigningTime, the zero time if the signature has none.
+	SigningTime time.Time
+	// Certificates holds the certificates of the KeyInfo, starting with the signer certificate if it is one of them.
+	Certificates []*x509.Certificate
+	// Chain is the chain from the signer certificate to one of
+	// TSLFetchOptions.SignerRoots, set only if SignerRoots was given. It may
+	// include intermediates fetched by TSLFetchOptions.IssuerFetcher.
+	Chain []*x509.Certificate
+
+	// referenceDigests holds the DigestMethod algorithms of all references.
+	referenceDigests []string
+}
+
+// parseSignatureInfo extracts the signature information from a signed TSL
+// document, as verified by a Verifier other than LocalVerifier. The document
+// must hold a single XML signature, a child of the document element, which is
+// the one verifiers check. It returns nil if the signature cannot be found.
+func parseSignatureInfo(data []byte, signer *x509.Certificate) (*SignatureInfo, error) {
+	doc := etree.NewDocument()
+	if err := doc.ReadFromBytes(data); err != nil {
+		return nil, err
+	}
+	if doc.Root() == nil {
+		return nil, errors.New("no document element")
+	}
+	sig, err := documentSignature(doc.Root())
+	if err != nil {
+		return nil, err
+	}
+	signedInfo := childElement(sig, "SignedInfo")
+	if signedInfo == nil {
+		return nil, errors.New("no SignedInfo")
+	}
+	return newSignatureInfo(sig, signedInfo, signer)
+}
+
+// newSignatureInfo describes the XML signature sig with the algorithms of
+// signedInfo. The signer certificate, if known, is moved to the front of the
+// certificate list. If the signing time or a certificate cannot be read, the
+// rest of the information is returned along with the error.
+func newSignatureInfo(sig, signedInfo *etree.Element, signer *x509.Certificate) (*SignatureInfo, error) {
+	algorithm := func(el *etree.Element, tag string) string {
+		if child := childElement(el, tag); child != nil {
+			return strings.TrimSpace(child.SelectAttrValue("Algorithm", ""))
+		}
+		return ""
+	}
+	info := &SignatureInfo{
+		SignatureAlgorithm:     algorithm(signedInfo, "SignatureMethod"),
+		CanonicalizationMethod: algorithm(signedInfo, "CanonicalizationMethod"),
+	}
+	// Prefer the reference to the list over the one to the XAdES properties
+	found := false
+	for i, ref := range signedInfo.SelectElements("Reference") {
+		digest := algorithm(ref, "DigestMethod")
+		info.referenceDigests = append(info.referenceDigests, digest)
+		refType := ref.SelectAttrValue("Type", "")
+		if !found && (i == 0 || refType != xadesSignedPropertiesType) {
+			info.DigestAlgorithm = digest
+		}
+		found = found || refType != xadesSignedPropertiesType
+	}
+	var errs []error
+	if el := sig.FindElement("./Object/QualifyingProperties/SignedProperties/SignedSignatureProperties/SigningTime"); el != nil {
+		if value := strings.TrimSpace(el.Text()); value != "" {
+			signingTime, err := parseXSDDateTime(value)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("invalid SigningTime %q: %w", value, err))
+			}
+			info.SigningTime = signingTime
+		}
+	}
+
+	var certs []*etree.Element
+	if keyInfo := childElement(sig, "KeyInfo"); keyInfo != nil {
+		certs = keyInfo.FindElements("./X509Data/X509Certificate")
+	}
+	for _, el := range certs {
+		der, err := decodeBase64(el.Text())
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid KeyInfo certificate: %w", err))
+			continue
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid KeyInfo certificate: %w", err))
+			continue
+		}
+		if signer != nil && cert.Equal(signer) {
+			info.Certificates = append([]*x509.Certificate{cert}, info.Certificates...)
+		} else {
+			info.Certificates = append(info.Certificates, cert)
+		}
+	}
+	return info, errors.Join(errs...)
+}
+
+// SignatureInfo returns how the TSL is signed: the signature, digest and
+// canonicalization algorithms, the XAdES signing time and the certificates
+// of the signature. It returns nil if the TSL was not signed or was not
+// fetched with FetchTSLWithOptions.
+func (tsl *TSL) SignatureInfo() *SignatureInfo {
+	if tsl == nil {
+		return nil
+	}
+	return tsl.signatureInfo
+}
@@ -0,0 +1,35 @@
+package etsi119612_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sirosfoundation/g119612/pkg/etsi119612"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignatureInfo(t *testing.T) {
+	tsl, err := etsi119612.FetchTSLWithOptions("file://./testdata/SE-TL.xml", etsi119612.DefaultTSLFetchOptions)
+	require.NoError(t, err)
+	require.True(t, tsl.Signed)
+
+	info := tsl.SignatureInfo()
+	require.NotNil(t, info)
+	assert.Equal(t, "http://www.w3.org/2001/04/xmldsig-more#rsa-sha256", info.SignatureAlgorithm)
+	assert.Equal(t, "http://www.w3.org/2001/04/xmlenc#sha256", info.DigestAlgorithm)
+	assert.Equal(t, "http://www.w3.org/2001/10/xml-exc-c14n#", info.CanonicalizationMethod)
+	assert.Equal(t, time.Date(2025, 4, 10, 11, 45, 50, 0, time.UTC), info.SigningTime.UTC())
+	require.NotEmpty(t, info.Certificates)
+	assert.True(t, info.Certificates[0].Equal(&tsl.Signer))
+}
+
+func TestSignatureInfo_Unsigned(t *testing.T) {
+	tsl, err := etsi119612.FetchTSLWithOptions("file://./testdata/EWC-TL.xml", etsi119612.DefaultTSLFetchOptions)
+	require.NoError(t, err)
+	assert.False(t, tsl.Signed)
+	assert.Nil(t, tsl.SignatureInfo())
+
+	var nilTSL *etsi119612.TSL
+	assert.Nil(t, nilTSL.SignatureInfo())
+}
@@ -0,0 +1,76 @@
+package etsi119612
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// maxIssuerFetches bounds the number of certificates whose issuers are
+// fetched while building the chain of a TSL signer.
+const maxIssuerFetches = 4
+
+// checkSignerChain verifies that signer, the certificate the Verifier
+// verified the signature of a TSL with, chains to options.SignerRoots, using
+// the other certificates of the KeyInfo described by info as intermediates.
+// The chain is verified at the signing time of the TSL if it has one, and
+// recorded in info.Chain. If the chain cannot be built and
+// options.IssuerFetcher is set, missing intermediates are fetched from the
+// caIssuers URLs of the signer and of the certificates fetched for it. It does
+// nothing if options.SignerRoots is nil.
+func (options TSLFetchOptions) checkSignerChain(ctx context.Context, signer *x509.Certificate, info *SignatureInfo) error {
+	if options.SignerRoots == nil {
+		return nil
+	}
+	if signer == nil || len(signer.Raw) == 0 {
+		return fmt.Errorf("%w: no signer certificate", ErrSignerUntrusted)
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range info.Certificates {
+		if !cert.Equal(signer) {
+			intermediates.AddCert(cert)
+		}
+	}
+	verify := func() error {
+		chains, err := signer.Verify(x509.VerifyOptions{
+			Roots:         options.SignerRoots,
+			Intermediates: intermediates,
+			CurrentTime:   info.SigningTime,
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+		})
+		if err == nil {
+			info.Chain = chains[0]
+		}
+		return err
+	}
+
+	err := verify()
+	pending := []*x509.Certificate{signer}
+	for fetches := 0; err != nil && options.IssuerFetcher != nil && len(pending) > 0 && fetches < maxIssuerFetches; fetches++ {
+		var unknown x509.UnknownAuthorityError
+		if !errors.As(err, &unknown) {
+			break
+		}
+		cert := pending[0]
+		pending = pending[1:]
+		for _, issuerURL := range cert.IssuingCertificateURL {
+			issuers, fetchErr := options.IssuerFetcher.Fetch(ctx, issuerURL)
+			if fetchErr != nil {
+				log.Warnf("g119612: Cannot fetch the issuer of %s: %v", cert.Subject, fetchErr)
+				continue
+			}
+			for _, issuer := range issuers {
+				intermediates.AddCert(issuer)
+				pending = append(pending, issuer)
+			}
+		}
+		err = verify()
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrSignerUntrusted, signer.Subject, err)
+	}
+	return nil
+}
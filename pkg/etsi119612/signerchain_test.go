@@ -0,0 +1,160 @@
+package etsi119612_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirosfoundation/g119612/pkg/dsig"
+	"github.com/sirosfoundation/g119612/pkg/etsi119612"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// issueCertificate issues a certificate for key, self-signed if parent is nil.
+func issueCertificate(t *testing.T, cn string, key crypto.Signer, parent *x509.Certificate, parentKey crypto.Signer, issuerURL string) *x509.Certificate {
+	t.Helper()
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		BasicConstraintsValid: true,
+		IsCA:                  parent == nil || issuerURL == "",
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}
+	if issuerURL != "" {
+		template.IssuingCertificateURL = []string{issuerURL}
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, key.Public(), parentKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert
+}
+
+func TestSignerChain(t *testing.T) {
+	rootKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	root := issueCertificate(t, "Test Root", rootKey, nil, nil, "")
+	intermediateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	intermediate := issueCertificate(t, "Test Intermediate", intermediateKey, root, rootKey, "")
+
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Content-Type", "application/pkix-cert")
+		w.Write(intermediate.Raw)
+	}))
+	defer server.Close()
+
+	signerKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	signerCert := issueCertificate(t, "TSL Signer", signerKey, intermediate, intermediateKey, server.URL+"/intermediate.cer")
+	data, err := os.ReadFile(filepath.Join("testdata", "EWC-TL.xml"))
+	require.NoError(t, err)
+	// The KeyInfo holds the signer certificate only
+	signed, err := (&dsig.SelfSignedSigner{Certificate: signerCert, Key: signerKey}).Sign(data)
+	require.NoError(t, err)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+	options := etsi119612.DefaultTSLFetchOptions
+	options.SignerRoots = roots
+
+	t.Run("Missing_Intermediate", func(t *testing.T) {
+		_, err := etsi119612.ParseTSL(signed, "signed.xml", options)
+		assert.ErrorIs(t, err, etsi119612.ErrSignerUntrusted)
+		assert.ErrorContains(t, err, "TSL Signer")
+	})
+
+	t.Run("Fetched_Intermediate", func(t *testing.T) {
+		options := options
+		options.IssuerFetcher = etsi119612.NewIssuerFetcher("127.0.0.1")
+		for range 2 {
+			tsl, err := etsi119612.ParseTSL(signed, "signed.xml", options)
+			require.NoError(t, err)
+			chain := tsl.SignatureInfo().Chain
+			require.Len(t, chain, 3)
+			assert.True(t, chain[1].Equal(intermediate))
+			assert.True(t, chain[2].Equal(root))
+		}
+		assert.Equal(t, int32(1), requests.Load(), "the intermediate is fetched once")
+	})
+
+	t.Run("Host_Not_Allowed", func(t *testing.T) {
+		requests.Store(0)
+		options := options
+		options.IssuerFetcher = etsi119612.NewIssuerFetcher("pki.example.com")
+		_, err := etsi119612.ParseTSL(signed, "signed.xml", options)
+		assert.ErrorIs(t, err, etsi119612.ErrSignerUntrusted)
+		assert.Zero(t, requests.Load())
+	})
+
+	t.Run("Other_Root", func(t *testing.T) {
+		otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+		others := x509.NewCertPool()
+		others.AddCert(issueCertificate(t, "Other Root", otherKey, nil, nil, ""))
+		options := etsi119612.DefaultTSLFetchOptions
+		options.SignerRoots = others
+		options.IssuerFetcher = etsi119612.NewIssuerFetcher("127.0.0.1")
+		_, err = etsi119612.ParseTSL(signed, "signed.xml", options)
+		assert.ErrorIs(t, err, etsi119612.ErrSignerUntrusted)
+	})
+
+	t.Run("Verified_Signer", func(t *testing.T) {
+		// The certificate the Verifier verified with is checked, not the
+		// first one of the KeyInfo
+		otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		require.NoError(t, err)
+		other := issueCertificate(t, "Other Signer", otherKey, nil, nil, "")
+		options := options
+		options.IssuerFetcher = etsi119612.NewIssuerFetcher("127.0.0.1")
+		options.Verifier = etsi119612.VerifierFunc(func(ctx context.Context, data []byte) ([]byte, *x509.Certificate, error) {
+			content, _, err := etsi119612.LocalVerifier{}.Verify(ctx, data)
+			return content, other, err
+		})
+		_, err = etsi119612.ParseTSL(signed, "signed.xml", options)
+		assert.ErrorIs(t, err, etsi119612.ErrSignerUntrusted)
+		assert.ErrorContains(t, err, "Other Signer")
+
+		// A trusted signer the KeyInfo does not list is accepted
+		trustedKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		require.NoError(t, err)
+		trusted := issueCertificate(t, "Service Signer", trustedKey, intermediate, intermediateKey, server.URL+"/intermediate.cer")
+		options.Verifier = etsi119612.VerifierFunc(func(ctx context.Context, data []byte) ([]byte, *x509.Certificate, error) {
+			content, _, err := etsi119612.LocalVerifier{}.Verify(ctx, data)
+			return content, trusted, err
+		})
+		tsl, err := etsi119612.ParseTSL(signed, "signed.xml", options)
+		require.NoError(t, err)
+		require.Len(t, tsl.SignatureInfo().Chain, 3)
+		assert.True(t, tsl.SignatureInfo().Chain[0].Equal(trusted))
+	})
+
+	t.Run("No_Roots", func(t *testing.T) {
+		tsl, err := etsi119612.ParseTSL(signed, "signed.xml", etsi119612.DefaultTSLFetchOptions)
+		require.NoError(t, err)
+		assert.Nil(t, tsl.SignatureInfo().Chain)
+	})
+}
@@ -0,0 +1,322 @@
+package etsi119612
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Namespaces whose content is not checked by ValidateStrict. Signatures and
+// XAdES properties are validated by the signature verification instead.
+const (
+	nsXMLDSig = "http://www.w3.org/2000/09/xmldsig#"
+	nsXAdES   = "http://uri.etsi.org/01903/v1.3.2#"
+)
+
+// StrictValidationError is returned by ValidateStrict and by strict fetches
+// when a TSL does not conform to the closed content models of the schema.
+type StrictValidationError struct {
+	Issues []string // Human readable description of each problem found
+}
+
+// Error implements the error interface.
+func (e *StrictValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrStrictValidation, strings.Join(e.Issues, "; "))
+}
+
+// Unwrap allows errors.Is(err, ErrStrictValidation).
+func (e *StrictValidationError) Unwrap() error {
+	return ErrStrictValidation
+}
+
+// strictDateElements are the elements whose content must be a valid xsd:dateTime.
+var strictDateElements = map[string]bool{
+	"ListIssueDateTime":          true,
+	"dateTime":                   true,
+	"StatusStartingTime":         true,
+	"ExpiredCertsRevocationInfo": true,
+}
+
+// strictKnownExtensions are the extension elements defined by ETSI TS 119 612
+// that may appear in an extension marked as critical.
+var strictKnownExtensions = map[string]bool{
+	"Qualifications":               true,
+	"TakenOverBy":                  true,
+	"ExpiredCertsRevocationInfo":   true,
+	"AdditionalServiceInformation": true,
+}
+
+var (
+	strictAnyType       = reflect.TypeOf(AnyType{})
+	strictExtensionType = reflect.TypeOf(ExtensionType{})
+
+	// Types modelled as xsd:choice where only one of the fields is present.
+	strictChoiceTypes = map[reflect.Type]bool{
+		reflect.TypeOf(PolicyOrLegalnoticeType{}):   true,
+		reflect.TypeOf(AdditionalInformationType{}): true,
+		reflect.TypeOf(DigitalIdentityType{}):       true,
+	}
+)
+
+// ValidateStrict checks a TSL document more rigorously than xml.Unmarshal does.
+// It reports:
+//   - elements that are not part of the closed content models of the schema
+//   - mandatory elements that are missing or empty
+//   - date elements that are not valid xsd:dateTime values
+//   - critical extensions that are not defined by ETSI TS 119 612
+//
+// Open content (AnyType, extensions, OtherInformation) and XML-DSIG/XAdES
+// elements are not inspected.
+//
+// Parameters:
+//   - data: The XML document to check
+//
+// Returns:
+//   - nil if the document passes all checks
+//   - A *StrictValidationError listing every problem found
+//   - Any XML syntax error encountered while reading the document
+func ValidateStrict(data []byte) error {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	w := &strictWalker{dec: dec}
+
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return fmt.Errorf("no TrustServiceStatusList element found")
+		}
+		if err != nil {
+			return err
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		if start.Name.Local != "TrustServiceStatusList" {
+			w.issue("unexpected root element <%s>", start.Name.Local)
+			break
+		}
+		if err := w.element(start, reflect.TypeOf(TrustStatusListType{}), start.Name.Local, true); err != nil {
+			return err
+		}
+		break
+	}
+
+	if len(w.issues) > 0 {
+		return &StrictValidationError{Issues: w.issues}
+	}
+	return nil
+}
+
+// strictField describes a child element allowed by a struct type.
+type strictField struct {
+	typ      reflect.Type
+	required bool
+}
+
+// strictWalker walks the XML tokens alongside the generated Go types.
+type strictWalker struct {
+	dec    *xml.Decoder
+	issues []string
+}
+
+func (w *strictWalker) issue(format string, args ...any) {
+	w.issues = append(w.issues, fmt.Sprintf(format, args...))
+}
+
+// element checks the content of the element opened by start against type t.
+// required tells whether the element is mandatory in its parent.
+func (w *strictWalker) element(start xml.StartElement, t reflect.Type, path string, required bool) error {
+	t = strictDeref(t)
+
+	if t == strictExtensionType {
+		return w.extension(start, path)
+	}
+	if t.Kind() != reflect.Struct {
+		return w.leaf(start, path, required)
+	}
+	fields, open := strictFields(t)
+	if open {
+		return w.dec.Skip()
+	}
+
+	seen := make(map[string]bool)
+	for {
+		tok, err := w.dec.Token()
+		if err != nil {
+			return err
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			childPath := path + "/" + tok.Name.Local
+			field, ok := fields[tok.Name.Local]
+			if !ok {
+				w.issue("unexpected element <%s> in %s", tok.Name.Local, path)
+				if err := w.dec.Skip(); err != nil {
+					return err
+				}
+				continue
+			}
+			seen[tok.Name.Local] = true
+			if tok.Name.Space == nsXMLDSig || tok.Name.Space == nsXAdES {
+				if err := w.dec.Skip(); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := w.element(tok, field.typ, childPath, field.required); err != nil {
+				return err
+			}
+		case xml.EndElement:
+			if strictChoiceTypes[t] {
+				if len(seen) == 0 {
+					w.issue("%s must contain one of its choice elements", path)
+				}
+				return nil
+			}
+			for _, name := range slices.Sorted(maps.Keys(fields)) {
+				if fields[name].required && !seen[name] {
+					w.issue("missing mandatory element <%s> in %s", name, path)
+				}
+			}
+			return nil
+		}
+	}
+}
+
+// leaf checks an element with simple content. Empty content is only
+// reported for mandatory elements.
+func (w *strictWalker) leaf(start xml.StartElement, path string, required bool) error {
+	var text strings.Builder
+	for {
+		tok, err := w.dec.Token()
+		if err != nil {
+			return err
+		}
+		switch tok := tok.(type) {
+		case xml.CharData:
+			text.Write(tok)
+		case xml.StartElement:
+			w.issue("unexpected element <%s> in %s", tok.Name.Local, path)
+			if err := w.dec.Skip(); err != nil {
+				return err
+			}
+		case xml.EndElement:
+			value := strings.TrimSpace(text.String())
+			if value == "" {
+				if required {
+					w.issue("empty mandatory element %s", path)
+				}
+			} else if strictDateElements[start.Name.Local] {
+				if _, err := parseXSDDateTime(value); err != nil {
+					w.issue("invalid date %q in %s", value, path)
+				}
+			}
+			return nil
+		}
+	}
+}
+
+// extension checks an Extension element, rejecting unknown critical content.
+func (w *strictWalker) extension(start xml.StartElement, path string) error {
+	critical := false
+	for _, attr := range start.Attr {
+		if attr.Name.Local == "Critical" {
+			critical = strings.TrimSpace(attr.Value) == "true" || strings.TrimSpace(attr.Value) == "1"
+		}
+	}
+	for {
+		tok, err := w.dec.Token()
+		if err != nil {
+			return err
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			name := tok.Name.Local
+			if critical && !strictKnownExtensions[name] {
+				w.issue("unknown critical extension <%s> in %s", name, path)
+			}
+			if name == "ExpiredCertsRevocationInfo" {
+				if err := w.leaf(tok, path+"/"+name, true); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := w.dec.Skip(); err != nil {
+				return err
+			}
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+// strictFields returns the child elements allowed by struct type t, keyed by
+// local name. open is true if t accepts arbitrary content.
+func strictFields(t reflect.Type) (fields map[string]strictField, open bool) {
+	if t == strictAnyType {
+		return nil, true
+	}
+	fields = make(map[string]strictField)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("xml")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if strings.Contains(opts, "innerxml") || strings.Contains(opts, "any") {
+			return nil, true
+		}
+		if strings.Contains(opts, "attr") || strings.Contains(opts, "chardata") {
+			continue
+		}
+		if f.Anonymous && name == "" {
+			inner := strictDeref(f.Type)
+			if inner.Kind() != reflect.Struct {
+				continue
+			}
+			sub, subOpen := strictFields(inner)
+			if subOpen {
+				return nil, true
+			}
+			for k, v := range sub {
+				fields[k] = v
+			}
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		// Tags may carry a namespace either as "ns name" or as a "prefix:name"
+		if idx := strings.LastIndexAny(name, " :"); idx >= 0 {
+			name = name[idx+1:]
+		}
+		fields[name] = strictField{
+			typ:      f.Type,
+			required: !strings.Contains(opts, "omitempty"),
+		}
+	}
+	return fields, false
+}
+
+// strictDeref strips pointers and slices from t.
+func strictDeref(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	return t
+}
+
+// parseXSDDateTime parses an xsd:dateTime value with or without a time zone.
+func parseXSDDateTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02T15:04:05", value)
+}
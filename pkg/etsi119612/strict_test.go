@@ -0,0 +1,165 @@
+package etsi119612_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sirosfoundation/g119612/pkg/etsi119612"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const strictTSLTemplate = `<?xml version="1.0" encoding="UTF-8"?>
+<TrustServiceStatusList xmlns="http://uri.etsi.org/02231/v2#" TSLTag="http://uri.etsi.org/19612/TSLTag">
+  <SchemeInformation>
+    <TSLVersionIdentifier>5</TSLVersionIdentifier>
+    <TSLSequenceNumber>1</TSLSequenceNumber>
+    <TSLType>http://uri.etsi.org/TrstSvc/TrustedList/TSLType/EUgeneric</TSLType>
+    <SchemeOperatorName><Name xml:lang="en">Test Operator</Name></SchemeOperatorName>
+    <SchemeOperatorAddress>
+      <PostalAddresses>
+        <PostalAddress xml:lang="en">
+          <StreetAddress>Street 1</StreetAddress>
+          <Locality>City</Locality>
+          <CountryName>SE</CountryName>
+        </PostalAddress>
+      </PostalAddresses>
+      <ElectronicAddress><URI xml:lang="en">mailto:test@example.com</URI></ElectronicAddress>
+    </SchemeOperatorAddress>
+    <SchemeName><Name xml:lang="en">Test Scheme</Name></SchemeName>
+    <SchemeInformationURI><URI xml:lang="en">https://example.com/scheme</URI></SchemeInformationURI>
+    <StatusDeterminationApproach>http://uri.etsi.org/TrstSvc/TrustedList/StatusDetn/EUappropriate</StatusDeterminationApproach>
+    <SchemeTerritory>SE</SchemeTerritory>
+    <HistoricalInformationPeriod>65535</HistoricalInformationPeriod>
+    <ListIssueDateTime>{{ISSUE}}</ListIssueDateTime>
+    <NextUpdate><dateTime>2030-01-01T00:00:00Z</dateTime></NextUpdate>
+  </SchemeInformation>
+  <TrustServiceProviderList>
+    <TrustServiceProvider>
+      <TSPInformation>
+        <TSPName><Name xml:lang="en">Test TSP</Name></TSPName>
+        <TSPAddress>
+          <PostalAddresses>
+            <PostalAddress xml:lang="en">
+              <StreetAddress>Street 2</StreetAddress>
+              <Locality>City</Locality>
+              <CountryName>SE</CountryName>
+            </PostalAddress>
+          </PostalAddresses>
+          <ElectronicAddress><URI xml:lang="en">https://tsp.example.com</URI></ElectronicAddress>
+        </TSPAddress>
+        <TSPInformationURI><URI xml:lang="en">https://tsp.example.com</URI></TSPInformationURI>
+        {{EXTRA}}
+      </TSPInformation>
+      <TSPServices>
+        <TSPService>
+          <ServiceInformation>
+            <ServiceTypeIdentifier>http://uri.etsi.org/TrstSvc/Svctype/CA/QC</ServiceTypeIdentifier>
+            <ServiceName><Name xml:lang="en">Test CA</Name></ServiceName>
+            <ServiceDigitalIdentity><DigitalId><X509SubjectName>CN=Test</X509SubjectName></DigitalId></ServiceDigitalIdentity>
+            <ServiceStatus>http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/granted</ServiceStatus>
+            <StatusStartingTime>2024-01-01T00:00:00Z</StatusStartingTime>
+            {{EXTENSIONS}}
+          </ServiceInformation>
+        </TSPService>
+      </TSPServices>
+    </TrustServiceProvider>
+  </TrustServiceProviderList>
+</TrustServiceStatusList>`
+
+func strictTSL(issue, extra, extensions string) []byte {
+	r := strings.NewReplacer("{{ISSUE}}", issue, "{{EXTRA}}", extra, "{{EXTENSIONS}}", extensions)
+	return []byte(r.Replace(strictTSLTemplate))
+}
+
+func strictIssues(t *testing.T, err error) []string {
+	t.Helper()
+	var strictErr *etsi119612.StrictValidationError
+	require.ErrorAs(t, err, &strictErr)
+	assert.True(t, errors.Is(err, etsi119612.ErrStrictValidation))
+	return strictErr.Issues
+}
+
+func TestValidateStrict_Valid(t *testing.T) {
+	assert.NoError(t, etsi119612.ValidateStrict(strictTSL("2025-01-01T00:00:00Z", "", "")))
+
+	// Time zone is optional for xsd:dateTime
+	assert.NoError(t, etsi119612.ValidateStrict(strictTSL("2025-01-01T00:00:00", "", "")))
+
+	// Known critical extensions and non-critical unknown extensions are accepted
+	ext := `<ServiceInformationExtensions>
+	  <Extension Critical="true"><ExpiredCertsRevocationInfo>2020-01-01T00:00:00Z</ExpiredCertsRevocationInfo></Extension>
+	  <Extension Critical="false"><Whatever xmlns="urn:example">x</Whatever></Extension>
+	</ServiceInformationExtensions>`
+	assert.NoError(t, etsi119612.ValidateStrict(strictTSL("2025-01-01T00:00:00Z", "", ext)))
+
+	data, err := os.ReadFile(filepath.Join("testdata", "SE-TL.xml"))
+	require.NoError(t, err)
+	assert.NoError(t, etsi119612.ValidateStrict(data))
+}
+
+func TestValidateStrict_UnexpectedElement(t *testing.T) {
+	err := etsi119612.ValidateStrict(strictTSL("2025-01-01T00:00:00Z", "<Surprise>x</Surprise>", ""))
+	issues := strictIssues(t, err)
+	require.Len(t, issues, 1)
+	assert.Contains(t, issues[0], "unexpected element <Surprise>")
+	assert.Contains(t, issues[0], "TrustServiceProvider/TSPInformation")
+}
+
+func TestValidateStrict_InvalidDate(t *testing.T) {
+	err := etsi119612.ValidateStrict(strictTSL("13/01/2025", "", ""))
+	issues := strictIssues(t, err)
+	require.Len(t, issues, 1)
+	assert.Contains(t, issues[0], `invalid date "13/01/2025"`)
+}
+
+func TestValidateStrict_UnknownCriticalExtension(t *testing.T) {
+	ext := `<ServiceInformationExtensions>
+	  <Extension Critical="true"><Whatever xmlns="urn:example">x</Whatever></Extension>
+	</ServiceInformationExtensions>`
+	issues := strictIssues(t, etsi119612.ValidateStrict(strictTSL("2025-01-01T00:00:00Z", "", ext)))
+	require.Len(t, issues, 1)
+	assert.Contains(t, issues[0], "unknown critical extension <Whatever>")
+}
+
+func TestValidateStrict_MissingMandatory(t *testing.T) {
+	data := strictTSL("2025-01-01T00:00:00Z", "", "")
+	data = []byte(strings.Replace(string(data), "<StatusStartingTime>2024-01-01T00:00:00Z</StatusStartingTime>", "", 1))
+	data = []byte(strings.Replace(string(data), "<TSLType>http://uri.etsi.org/TrstSvc/TrustedList/TSLType/EUgeneric</TSLType>", "<TSLType> </TSLType>", 1))
+
+	issues := strictIssues(t, etsi119612.ValidateStrict(data))
+	require.Len(t, issues, 2)
+	assert.Contains(t, issues[0], "empty mandatory element TrustServiceStatusList/SchemeInformation/TSLType")
+	assert.Contains(t, issues[1], "missing mandatory element <StatusStartingTime>")
+
+	// The EWC test list omits several mandatory elements
+	ewc, err := os.ReadFile(filepath.Join("testdata", "EWC-TL.xml"))
+	require.NoError(t, err)
+	assert.NotEmpty(t, strictIssues(t, etsi119612.ValidateStrict(ewc)))
+}
+
+func TestValidateStrict_NotXML(t *testing.T) {
+	err := etsi119612.ValidateStrict([]byte("<Other/>"))
+	assert.Contains(t, strictIssues(t, err)[0], "unexpected root element <Other>")
+
+	err = etsi119612.ValidateStrict([]byte("not xml"))
+	assert.Error(t, err)
+	assert.False(t, errors.Is(err, etsi119612.ErrStrictValidation))
+}
+
+func TestFetchTSLWithOptions_Strict(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tsl.xml")
+	require.NoError(t, os.WriteFile(path, strictTSL("2025-01-01T00:00:00Z", "<Surprise/>", ""), 0644))
+
+	options := etsi119612.DefaultTSLFetchOptions
+	tsl, err := etsi119612.FetchTSLWithOptions("file://"+path, options)
+	require.NoError(t, err)
+	assert.Equal(t, 1, tsl.NumberOfTrustServiceProviders())
+
+	options.Strict = true
+	_, err = etsi119612.FetchTSLWithOptions("file://"+path, options)
+	assert.ErrorIs(t, err, etsi119612.ErrStrictValidation)
+}
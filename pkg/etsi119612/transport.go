@@ -0,0 +1,81 @@
+package etsi119612
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Defaults for the HTTP transport shared by the fetches of a TSL tree.
+const (
+	DefaultMaxIdleConnsPerHost = 8
+	DefaultIdleConnTimeout     = 90 * time.Second
+)
+
+// TransportOptions tunes the HTTP transport used for fetching TSLs. When any
+// option is set, FetchTSLWithReferencesAndOptions creates one transport from
+// them and shares it between the root fetch and all dereferenced fetches, so
+// connections to hosts serving several lists are reused. Unset fields take the
+// defaults above; HTTP/2 and transparent gzip compression are enabled unless
+// disabled. The zero value uses http.DefaultTransport, which pools connections
+// as well but keeps at most two idle connections per host.
+type TransportOptions struct {
+	// MaxIdleConnsPerHost is the number of idle connections kept per host
+	// (DefaultMaxIdleConnsPerHost if zero).
+	MaxIdleConnsPerHost int
+	// IdleConnTimeout is how long idle connections are kept (DefaultIdleConnTimeout if zero).
+	IdleConnTimeout time.Duration
+	// DisableHTTP2 restricts the transport to HTTP/1.1.
+	DisableHTTP2 bool
+	// DisableCompression stops the transport from requesting gzip encoded responses.
+	DisableCompression bool
+}
+
+// NewTransport returns an HTTP transport configured by opts. Proxy settings
+// are taken from the environment like with http.DefaultTransport.
+func (opts TransportOptions) NewTransport() *http.Transport {
+	maxIdlePerHost := opts.MaxIdleConnsPerHost
+	if maxIdlePerHost <= 0 {
+		maxIdlePerHost = DefaultMaxIdleConnsPerHost
+	}
+	idleTimeout := opts.IdleConnTimeout
+	if idleTimeout <= 0 {
+		idleTimeout = DefaultIdleConnTimeout
+	}
+
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ForceAttemptHTTP2:     !opts.DisableHTTP2,
+		MaxIdleConns:          4 * maxIdlePerHost,
+		MaxIdleConnsPerHost:   maxIdlePerHost,
+		IdleConnTimeout:       idleTimeout,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+		DisableCompression:    opts.DisableCompression,
+	}
+	if opts.DisableHTTP2 {
+		// A non-nil empty map disables the automatic HTTP/2 upgrade
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+	return transport
+}
+
+// fetchClient returns the client used for the fetches of options, and a
+// function releasing it. A client set in the options is used as is; otherwise
+// a client is created with a transport configured by options.Transport, or
+// with http.DefaultTransport if no transport options are set.
+func (options TSLFetchOptions) fetchClient() (*http.Client, func()) {
+	if options.Client != nil {
+		return options.Client, func() {}
+	}
+	if options.Transport == (TransportOptions{}) {
+		return &http.Client{Timeout: options.Timeout}, func() {}
+	}
+	transport := options.Transport.NewTransport()
+	return &http.Client{Timeout: options.Timeout, Transport: transport}, transport.CloseIdleConnections
+}
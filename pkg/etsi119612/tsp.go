@@ -5,6 +5,7 @@ import (
 	"encoding/base64"
 	"slices"
 
+	"github.com/sirosfoundation/g119612/pkg/etsi119612/uri"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -46,9 +47,12 @@ func NewTSPServicePolicy() *TSPServicePolicy {
 
 // Cahe provided callback for all t all the X509 certificate data for the given Trust Service object.
 func (svc *TSPServiceType) WithCertificates(cb func(*x509.Certificate)) {
+	if svc == nil || svc.TslServiceInformation == nil {
+		return
+	}
 	if svc.TslServiceInformation.TslServiceDigitalIdentity != nil {
 		for _, id := range svc.TslServiceInformation.TslServiceDigitalIdentity.DigitalId {
-			if len(id.X509Certificate) > 0 {
+			if id != nil && len(id.X509Certificate) > 0 {
 				data, err := base64.StdEncoding.DecodeString(string(id.X509Certificate))
 				if err == nil {
 					cert, err := x509.ParseCertificate(data)
@@ -65,10 +69,23 @@ func (svc *TSPServiceType) WithCertificates(cb func(*x509.Certificate)) {
 	}
 }
 
-// Checks a Trust Service for validity during certificate validation.
+// Checks a Trust Service for validity during certificate validation. The status
+// is matched with uri.StatusMatches, so a policy accepting granted services
+// also accepts services of historic lists with an equivalent pre-eIDAS status
+// such as undersupervision or accredited.
 func (tsp *TSPType) Validate(svc *TSPServiceType, chain []*x509.Certificate, policy *TSPServicePolicy) error {
+	if svc == nil || svc.TslServiceInformation == nil {
+		return ErrInvalidStatus
+	}
+	if policy == nil {
+		policy = PolicyAll
+	}
 
-	if !slices.Contains(policy.ServiceStatus, svc.TslServiceInformation.TslServiceStatus) {
+	status := svc.TslServiceInformation.TslServiceStatus
+	serviceType := svc.TslServiceInformation.TslServiceTypeIdentifier
+	if !slices.ContainsFunc(policy.ServiceStatus, func(filter string) bool {
+		return uri.StatusMatches(status, serviceType, filter)
+	}) {
 		return ErrInvalidStatus
 	}
 
@@ -80,13 +97,42 @@ func (tsp *TSPType) Validate(svc *TSPServiceType, chain []*x509.Certificate, pol
 }
 
 // Summary returns a human-readable summary of scheme-level information for this TSL.
+// The TSL type and the number of services per service type and status are
+// given by their labels (see uri.Label). The names, addresses and information
+// URIs of the TSPs are listed under "trust_service_providers" (see TSPType.Details),
+// the addresses of the scheme operator and the policies and legal notices of
+// the TSL under "scheme_operator" (see TSL.SchemeOperatorDetails).
 func (tsl *TSL) Summary() map[string]interface{} {
 	m := make(map[string]interface{})
 	if tsl == nil {
 		return m
 	}
 	m["scheme_operator_name"] = tsl.SchemeOperatorName()
+	m["scheme_operator"] = tsl.SchemeOperatorDetails()
 	m["num_trust_service_providers"] = tsl.NumberOfTrustServiceProviders()
 	m["summary"] = tsl.String()
+	if info := tsl.StatusList.TslSchemeInformation; info != nil && info.TslTSLType != "" {
+		m["tsl_type"] = uri.Label(info.TslTSLType)
+	}
+	providers := []TSPDetails{}
+	if tsl.StatusList.TslTrustServiceProviderList != nil {
+		for _, tsp := range tsl.StatusList.TslTrustServiceProviderList.TslTrustServiceProvider {
+			if tsp != nil {
+				providers = append(providers, tsp.Details())
+			}
+		}
+	}
+	m["trust_service_providers"] = providers
+	serviceTypes := make(map[string]int)
+	serviceStatuses := make(map[string]int)
+	tsl.WithTrustServices(func(tsp *TSPType, svc *TSPServiceType) {
+		if svc.TslServiceInformation == nil {
+			return
+		}
+		serviceTypes[uri.Label(svc.TslServiceInformation.TslServiceTypeIdentifier)]++
+		serviceStatuses[uri.Label(svc.TslServiceInformation.TslServiceStatus)]++
+	})
+	m["service_types"] = serviceTypes
+	m["service_statuses"] = serviceStatuses
 	return m
 }
@@ -0,0 +1,178 @@
+package etsi119612
+
+import (
+	"strings"
+)
+
+// LangString is a text or URI of a multilingual TSL field in one language.
+type LangString struct {
+	Lang  string `json:"lang,omitempty"`
+	Value string `json:"value"`
+}
+
+// PostalAddressInfo is a postal address in one language.
+type PostalAddressInfo struct {
+	Lang            string `json:"lang,omitempty"`
+	StreetAddress   string `json:"street_address,omitempty"`
+	Locality        string `json:"locality,omitempty"`
+	StateOrProvince string `json:"state_or_province,omitempty"`
+	PostalCode      string `json:"postal_code,omitempty"`
+	CountryName     string `json:"country_name,omitempty"`
+}
+
+// String returns the address on one line, e.g.
+// "Via Torino 48, 20123 Milan, Milan, IT".
+func (a PostalAddressInfo) String() string {
+	var parts []string
+	for _, part := range []string{
+		a.StreetAddress,
+		strings.TrimSpace(a.PostalCode + " " + a.Locality),
+		a.StateOrProvince,
+		a.CountryName,
+	} {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// TSPDetails collects the information of a TSP (the TSPInformation element)
+// needed to identify and contact it, e.g. in incident response.
+type TSPDetails struct {
+	Name                string              `json:"name"`
+	Names               []LangString        `json:"names,omitempty"`
+	TradeNames          []LangString        `json:"trade_names,omitempty"`
+	PostalAddresses     []PostalAddressInfo `json:"postal_addresses,omitempty"`
+	ElectronicAddresses []LangString        `json:"electronic_addresses,omitempty"`
+	InformationURIs     []LangString        `json:"information_uris,omitempty"`
+}
+
+// LangStrings returns the names of an InternationalNamesType in document order.
+// Empty names, as found in malformed lists, are skipped.
+func LangStrings(names *InternationalNamesType) []LangString {
+	if names == nil {
+		return nil
+	}
+	var values []LangString
+	for _, n := range names.Name {
+		if n == nil || n.NonEmptyNormalizedString == nil || strings.TrimSpace(string(*n.NonEmptyNormalizedString)) == "" {
+			continue
+		}
+		values = append(values, LangString{Lang: langOf(n.XmlLangAttr), Value: strings.TrimSpace(string(*n.NonEmptyNormalizedString))})
+	}
+	return values
+}
+
+// langURIs returns the non-empty URIs of a multilingual URI list.
+func langURIs(uris []*NonEmptyMultiLangURIType) []LangString {
+	var values []LangString
+	for _, u := range uris {
+		if u == nil || strings.TrimSpace(u.Value) == "" {
+			continue
+		}
+		values = append(values, LangString{Lang: langOf(u.XmlLangAttr), Value: strings.TrimSpace(u.Value)})
+	}
+	return values
+}
+
+// langOf returns the value of an xml:lang attribute.
+func langOf(lang *Lang) string {
+	if lang == nil {
+		return ""
+	}
+	return string(*lang)
+}
+
+// information returns the TSPInformation of a TSP, nil if it has none.
+func (tsp *TSPType) information() *TSPInformationType {
+	if tsp == nil {
+		return nil
+	}
+	return tsp.TslTSPInformation
+}
+
+// Name returns the English name of the TSP, or "Unknown".
+func (tsp *TSPType) Name() string {
+	info := tsp.information()
+	if info == nil {
+		return "Unknown"
+	}
+	return FindByLanguage(info.TSPName, "en", "Unknown")
+}
+
+// TradeNames returns the trade names of the TSP. Besides names these commonly
+// hold registration identifiers such as "VATSE-5560000000".
+func (tsp *TSPType) TradeNames() []LangString {
+	info := tsp.information()
+	if info == nil {
+		return nil
+	}
+	return LangStrings(info.TSPTradeName)
+}
+
+// PostalAddresses returns the postal addresses of the TSP, one per language.
+func (tsp *TSPType) PostalAddresses() []PostalAddressInfo {
+	info := tsp.information()
+	if info == nil {
+		return nil
+	}
+	return postalAddresses(info.TSPAddress)
+}
+
+// postalAddresses returns the postal addresses of an address, one per language.
+func postalAddresses(address *AddressType) []PostalAddressInfo {
+	if address == nil || address.TslPostalAddresses == nil {
+		return nil
+	}
+	var addresses []PostalAddressInfo
+	for _, a := range address.TslPostalAddresses.TslPostalAddress {
+		if a == nil {
+			continue
+		}
+		addresses = append(addresses, PostalAddressInfo{
+			Lang:            langOf(a.XmlLangAttr),
+			StreetAddress:   strings.TrimSpace(a.StreetAddress),
+			Locality:        strings.TrimSpace(a.Locality),
+			StateOrProvince: strings.TrimSpace(a.StateOrProvince),
+			PostalCode:      strings.TrimSpace(a.PostalCode),
+			CountryName:     strings.TrimSpace(a.CountryName),
+		})
+	}
+	return addresses
+}
+
+// ElectronicAddresses returns the electronic addresses of the TSP, URIs such
+// as mailto:, tel: or https: contact pages.
+func (tsp *TSPType) ElectronicAddresses() []LangString {
+	info := tsp.information()
+	if info == nil || info.TSPAddress == nil || info.TSPAddress.TslElectronicAddress == nil {
+		return nil
+	}
+	return langURIs(info.TSPAddress.TslElectronicAddress.URI)
+}
+
+// InformationURIs returns the TSPInformationURI of the TSP, pointing to
+// information about it such as its terms and practice statements.
+func (tsp *TSPType) InformationURIs() []LangString {
+	info := tsp.information()
+	if info == nil || info.TSPInformationURI == nil {
+		return nil
+	}
+	return langURIs(info.TSPInformationURI.URI)
+}
+
+// Details returns the names, addresses and information URIs of the TSP.
+func (tsp *TSPType) Details() TSPDetails {
+	details := TSPDetails{
+		Name:                tsp.Name(),
+		TradeNames:          tsp.TradeNames(),
+		PostalAddresses:     tsp.PostalAddresses(),
+		ElectronicAddresses: tsp.ElectronicAddresses(),
+		InformationURIs:     tsp.InformationURIs(),
+	}
+	if info := tsp.information(); info != nil {
+		details.Names = LangStrings(info.TSPName)
+	}
+	return details
+}
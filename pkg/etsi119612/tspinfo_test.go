@@ -0,0 +1,83 @@
+package etsi119612_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirosfoundation/g119612/pkg/etsi119612"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTSPDetails(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "EWC-TL.xml"))
+	require.NoError(t, err)
+	tsl, err := etsi119612.ParseTSL(data, "EWC-TL.xml", etsi119612.DefaultTSLFetchOptions)
+	require.NoError(t, err)
+	tsps := tsl.StatusList.TslTrustServiceProviderList.TslTrustServiceProvider
+	require.GreaterOrEqual(t, len(tsps), 2)
+
+	intesi := tsps[1]
+	assert.Equal(t, "INTESI GROUP", intesi.Name())
+	assert.Equal(t, []etsi119612.LangString{{Lang: "en", Value: "INTESI GROUP SpA"}}, intesi.TradeNames())
+	addresses := intesi.PostalAddresses()
+	require.Len(t, addresses, 1)
+	assert.Equal(t, etsi119612.PostalAddressInfo{
+		Lang:            "en",
+		StreetAddress:   "Via Torino 48",
+		Locality:        "Milan",
+		StateOrProvince: "Milan",
+		PostalCode:      "20123",
+		CountryName:     "IT",
+	}, addresses[0])
+	assert.Equal(t, "Via Torino 48, 20123 Milan, Milan, IT", addresses[0].String())
+	assert.NotEmpty(t, intesi.ElectronicAddresses())
+	assert.NotEmpty(t, intesi.InformationURIs())
+
+	infocert := tsps[0].Details()
+	assert.Equal(t, "Tinexta Infocert", infocert.Name)
+	assert.Equal(t, []etsi119612.LangString{{Lang: "en", Value: "Tinexta Infocert"}}, infocert.Names)
+	assert.Equal(t, []etsi119612.LangString{{Lang: "en", Value: "mailto:leone.riello@infocert.it"}}, infocert.ElectronicAddresses)
+	assert.Equal(t, []etsi119612.LangString{{Lang: "en", Value: "https://www.infocert.it"}}, infocert.InformationURIs)
+	assert.Equal(t, "Piazzale Flaminio 1B, 00196 Rome, Italy", infocert.PostalAddresses[0].String())
+
+	// The details are part of the summary
+	summary := tsl.Summary()
+	providers, ok := summary["trust_service_providers"].([]etsi119612.TSPDetails)
+	require.True(t, ok)
+	assert.Len(t, providers, len(tsps))
+	assert.Equal(t, infocert, providers[0])
+	encoded, err := json.Marshal(providers[0])
+	require.NoError(t, err)
+	assert.Contains(t, string(encoded), `"postal_addresses":[{"lang":"en","street_address":"Piazzale Flaminio 1B"`)
+}
+
+func TestTSPDetails_Missing(t *testing.T) {
+	var nilTSP *etsi119612.TSPType
+	assert.Equal(t, "Unknown", nilTSP.Name())
+	assert.Nil(t, nilTSP.TradeNames())
+	assert.Equal(t, etsi119612.TSPDetails{Name: "Unknown"}, nilTSP.Details())
+
+	empty := ""
+	lang := etsi119612.Lang("sv")
+	name := etsi119612.NonEmptyNormalizedString("Leverantör")
+	blank := etsi119612.NonEmptyNormalizedString(" ")
+	tsp := &etsi119612.TSPType{TslTSPInformation: &etsi119612.TSPInformationType{
+		TSPName: &etsi119612.InternationalNamesType{Name: []*etsi119612.MultiLangNormStringType{
+			{XmlLangAttr: &lang, NonEmptyNormalizedString: &name},
+			{XmlLangAttr: &lang, NonEmptyNormalizedString: &blank},
+			nil,
+		}},
+		TSPAddress: &etsi119612.AddressType{
+			TslElectronicAddress: &etsi119612.ElectronicAddressType{URI: []*etsi119612.NonEmptyMultiLangURIType{{Value: empty}, nil, {Value: " tel:+46100 "}}},
+		},
+	}}
+	details := tsp.Details()
+	assert.Equal(t, "Unknown", details.Name, "no English name")
+	assert.Equal(t, []etsi119612.LangString{{Lang: "sv", Value: "Leverantör"}}, details.Names)
+	assert.Equal(t, []etsi119612.LangString{{Value: "tel:+46100"}}, details.ElectronicAddresses)
+	assert.Nil(t, details.PostalAddresses)
+	assert.Equal(t, "", etsi119612.PostalAddressInfo{}.String())
+}
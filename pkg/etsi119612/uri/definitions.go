@@ -0,0 +1,137 @@
+// Code generated by gen.go; DO NOT EDIT.
+
+package uri
+
+// ServiceType URIs.
+const (
+	ServiceTypeCAQC                     ServiceType = "http://uri.etsi.org/TrstSvc/Svctype/CA/QC"                     // CA issuing qualified certificates
+	ServiceTypeCAPKC                    ServiceType = "http://uri.etsi.org/TrstSvc/Svctype/CA/PKC"                    // CA issuing non-qualified certificates
+	ServiceTypeOCSPQC                   ServiceType = "http://uri.etsi.org/TrstSvc/Svctype/Certstatus/OCSP/QC"        // OCSP responder for qualified certificates
+	ServiceTypeOCSP                     ServiceType = "http://uri.etsi.org/TrstSvc/Svctype/Certstatus/OCSP"           // OCSP responder
+	ServiceTypeCRLQC                    ServiceType = "http://uri.etsi.org/TrstSvc/Svctype/Certstatus/CRL/QC"         // CRL issuer for qualified certificates
+	ServiceTypeCRL                      ServiceType = "http://uri.etsi.org/TrstSvc/Svctype/Certstatus/CRL"            // CRL issuer
+	ServiceTypeTSAQTST                  ServiceType = "http://uri.etsi.org/TrstSvc/Svctype/TSA/QTST"                  // Qualified time-stamping
+	ServiceTypeTSA                      ServiceType = "http://uri.etsi.org/TrstSvc/Svctype/TSA"                       // Time-stamping
+	ServiceTypeTSATSSQC                 ServiceType = "http://uri.etsi.org/TrstSvc/Svctype/TSA/TSS-QC"                // Time-stamping for qualified certificates
+	ServiceTypeTSATSSAdESQCandQES       ServiceType = "http://uri.etsi.org/TrstSvc/Svctype/TSA/TSS-AdESQCandQES"      // Time-stamping for AdES with qualified certificates and QES
+	ServiceTypeEDSQ                     ServiceType = "http://uri.etsi.org/TrstSvc/Svctype/EDS/Q"                     // Qualified electronic delivery
+	ServiceTypeEDS                      ServiceType = "http://uri.etsi.org/TrstSvc/Svctype/EDS"                       // Electronic delivery
+	ServiceTypeEDSREMQ                  ServiceType = "http://uri.etsi.org/TrstSvc/Svctype/EDS/REM/Q"                 // Qualified registered electronic mail
+	ServiceTypeEDSREM                   ServiceType = "http://uri.etsi.org/TrstSvc/Svctype/EDS/REM"                   // Registered electronic mail
+	ServiceTypePSESQ                    ServiceType = "http://uri.etsi.org/TrstSvc/Svctype/PSES/Q"                    // Qualified preservation of electronic signatures and seals
+	ServiceTypePSES                     ServiceType = "http://uri.etsi.org/TrstSvc/Svctype/PSES"                      // Preservation of electronic signatures and seals
+	ServiceTypeQESValidationQ           ServiceType = "http://uri.etsi.org/TrstSvc/Svctype/QESValidation/Q"           // Qualified validation of qualified electronic signatures and seals
+	ServiceTypeAdESValidation           ServiceType = "http://uri.etsi.org/TrstSvc/Svctype/AdESValidation"            // Validation of advanced electronic signatures and seals
+	ServiceTypeAdESGeneration           ServiceType = "http://uri.etsi.org/TrstSvc/Svctype/AdESGeneration"            // Generation of advanced electronic signatures and seals
+	ServiceTypeRemoteQSigCDManagementQ  ServiceType = "http://uri.etsi.org/TrstSvc/Svctype/RemoteQSigCDManagement/Q"  // Qualified remote signature creation device management
+	ServiceTypeRemoteQSealCDManagementQ ServiceType = "http://uri.etsi.org/TrstSvc/Svctype/RemoteQSealCDManagement/Q" // Qualified remote seal creation device management
+	ServiceTypeEAAQ                     ServiceType = "http://uri.etsi.org/TrstSvc/Svctype/EAA/Q"                     // Qualified electronic attestation of attributes
+	ServiceTypeEAA                      ServiceType = "http://uri.etsi.org/TrstSvc/Svctype/EAA"                       // Electronic attestation of attributes
+	ServiceTypeACA                      ServiceType = "http://uri.etsi.org/TrstSvc/Svctype/ACA"                       // Attribute certificate authority
+	ServiceTypeSignaturePolicyAuthority ServiceType = "http://uri.etsi.org/TrstSvc/Svctype/SignaturePolicyAuthority"  // Signature policy authority
+	ServiceTypeArchiv                   ServiceType = "http://uri.etsi.org/TrstSvc/Svctype/Archiv"                    // Archival
+	ServiceTypeArchivNotHavingPKIID     ServiceType = "http://uri.etsi.org/TrstSvc/Svctype/Archiv/nothavingPKIid"     // Archival without PKI identity
+	ServiceTypeIdV                      ServiceType = "http://uri.etsi.org/TrstSvc/Svctype/IdV"                       // Identity verification
+	ServiceTypeIdVNotHavingPKIID        ServiceType = "http://uri.etsi.org/TrstSvc/Svctype/IdV/nothavingPKIid"        // Identity verification without PKI identity
+	ServiceTypeKEscrow                  ServiceType = "http://uri.etsi.org/TrstSvc/Svctype/KEscrow"                   // Key escrow
+	ServiceTypeKEscrowNotHavingPKIID    ServiceType = "http://uri.etsi.org/TrstSvc/Svctype/KEscrow/nothavingPKIid"    // Key escrow without PKI identity
+	ServiceTypePPwd                     ServiceType = "http://uri.etsi.org/TrstSvc/Svctype/PPwd"                      // Personal password
+	ServiceTypePPwdNotHavingPKIID       ServiceType = "http://uri.etsi.org/TrstSvc/Svctype/PPwd/nothavingPKIid"       // Personal password without PKI identity
+	ServiceTypeRA                       ServiceType = "http://uri.etsi.org/TrstSvc/Svctype/RA"                        // Registration authority
+	ServiceTypeRANotHavingPKIID         ServiceType = "http://uri.etsi.org/TrstSvc/Svctype/RA/nothavingPKIid"         // Registration authority without PKI identity
+	ServiceTypeTLIssuer                 ServiceType = "http://uri.etsi.org/TrstSvc/Svctype/TLIssuer"                  // Trusted list issuer
+	ServiceTypeNationalRootCAQC         ServiceType = "http://uri.etsi.org/TrstSvc/Svctype/NationalRootCA-QC"         // National root CA for qualified certificates
+	ServiceTypeUnspecified              ServiceType = "http://uri.etsi.org/TrstSvc/Svctype/unspecified"               // Unspecified
+)
+
+// ServiceStatus URIs.
+const (
+	StatusGranted                   ServiceStatus = "http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/granted"                   // Granted
+	StatusWithdrawn                 ServiceStatus = "http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/withdrawn"                 // Withdrawn
+	StatusRecognisedAtNationalLevel ServiceStatus = "http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/recognisedatnationallevel" // Recognised at national level
+	StatusDeprecatedAtNationalLevel ServiceStatus = "http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/deprecatedatnationallevel" // Deprecated at national level
+	StatusUnderSupervision          ServiceStatus = "http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/undersupervision"          // Under supervision
+	StatusSupervisionInCessation    ServiceStatus = "http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/supervisionincessation"    // Supervision in cessation
+	StatusSupervisionCeased         ServiceStatus = "http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/supervisionceased"         // Supervision ceased
+	StatusSupervisionRevoked        ServiceStatus = "http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/supervisionrevoked"        // Supervision revoked
+	StatusAccredited                ServiceStatus = "http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/accredited"                // Accredited
+	StatusAccreditationCeased       ServiceStatus = "http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/accreditationceased"       // Accreditation ceased
+	StatusAccreditationRevoked      ServiceStatus = "http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/accreditationrevoked"      // Accreditation revoked
+	StatusSetByNationalLaw          ServiceStatus = "http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/setbynationallaw"          // Set by national law
+	StatusDeprecatedByNationalLaw   ServiceStatus = "http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/deprecatedbynationallaw"   // Deprecated by national law
+)
+
+// TSLType URIs.
+const (
+	TSLTypeEUGeneric        TSLType = "http://uri.etsi.org/TrstSvc/TrustedList/TSLType/EUgeneric"        // EU trusted list
+	TSLTypeEUListOfTheLists TSLType = "http://uri.etsi.org/TrstSvc/TrustedList/TSLType/EUlistofthelists" // EU list of trusted lists
+)
+
+// AdditionalServiceInformation URIs.
+const (
+	SvcInfoForESignatures           AdditionalServiceInformation = "http://uri.etsi.org/TrstSvc/TrustedList/SvcInfoExt/ForeSignatures"           // For electronic signatures
+	SvcInfoForESeals                AdditionalServiceInformation = "http://uri.etsi.org/TrstSvc/TrustedList/SvcInfoExt/ForeSeals"                // For electronic seals
+	SvcInfoForWebSiteAuthentication AdditionalServiceInformation = "http://uri.etsi.org/TrstSvc/TrustedList/SvcInfoExt/ForWebSiteAuthentication" // For website authentication
+	SvcInfoRootCAQC                 AdditionalServiceInformation = "http://uri.etsi.org/TrstSvc/TrustedList/SvcInfoExt/RootCA-QC"                // Root CA for qualified certificates
+)
+
+// definitions holds all standard URIs in the order of definitions.tsv.
+var definitions = []Definition{
+	{URI: "http://uri.etsi.org/TrstSvc/Svctype/CA/QC", Kind: KindServiceType, Label: "CA issuing qualified certificates", Category: "qualified"},
+	{URI: "http://uri.etsi.org/TrstSvc/Svctype/CA/PKC", Kind: KindServiceType, Label: "CA issuing non-qualified certificates", Category: "non-qualified"},
+	{URI: "http://uri.etsi.org/TrstSvc/Svctype/Certstatus/OCSP/QC", Kind: KindServiceType, Label: "OCSP responder for qualified certificates", Category: "qualified"},
+	{URI: "http://uri.etsi.org/TrstSvc/Svctype/Certstatus/OCSP", Kind: KindServiceType, Label: "OCSP responder", Category: "non-qualified"},
+	{URI: "http://uri.etsi.org/TrstSvc/Svctype/Certstatus/CRL/QC", Kind: KindServiceType, Label: "CRL issuer for qualified certificates", Category: "qualified"},
+	{URI: "http://uri.etsi.org/TrstSvc/Svctype/Certstatus/CRL", Kind: KindServiceType, Label: "CRL issuer", Category: "non-qualified"},
+	{URI: "http://uri.etsi.org/TrstSvc/Svctype/TSA/QTST", Kind: KindServiceType, Label: "Qualified time-stamping", Category: "qualified"},
+	{URI: "http://uri.etsi.org/TrstSvc/Svctype/TSA", Kind: KindServiceType, Label: "Time-stamping", Category: "non-qualified"},
+	{URI: "http://uri.etsi.org/TrstSvc/Svctype/TSA/TSS-QC", Kind: KindServiceType, Label: "Time-stamping for qualified certificates", Category: "non-qualified"},
+	{URI: "http://uri.etsi.org/TrstSvc/Svctype/TSA/TSS-AdESQCandQES", Kind: KindServiceType, Label: "Time-stamping for AdES with qualified certificates and QES", Category: "non-qualified"},
+	{URI: "http://uri.etsi.org/TrstSvc/Svctype/EDS/Q", Kind: KindServiceType, Label: "Qualified electronic delivery", Category: "qualified"},
+	{URI: "http://uri.etsi.org/TrstSvc/Svctype/EDS", Kind: KindServiceType, Label: "Electronic delivery", Category: "non-qualified"},
+	{URI: "http://uri.etsi.org/TrstSvc/Svctype/EDS/REM/Q", Kind: KindServiceType, Label: "Qualified registered electronic mail", Category: "qualified"},
+	{URI: "http://uri.etsi.org/TrstSvc/Svctype/EDS/REM", Kind: KindServiceType, Label: "Registered electronic mail", Category: "non-qualified"},
+	{URI: "http://uri.etsi.org/TrstSvc/Svctype/PSES/Q", Kind: KindServiceType, Label: "Qualified preservation of electronic signatures and seals", Category: "qualified"},
+	{URI: "http://uri.etsi.org/TrstSvc/Svctype/PSES", Kind: KindServiceType, Label: "Preservation of electronic signatures and seals", Category: "non-qualified"},
+	{URI: "http://uri.etsi.org/TrstSvc/Svctype/QESValidation/Q", Kind: KindServiceType, Label: "Qualified validation of qualified electronic signatures and seals", Category: "qualified"},
+	{URI: "http://uri.etsi.org/TrstSvc/Svctype/AdESValidation", Kind: KindServiceType, Label: "Validation of advanced electronic signatures and seals", Category: "non-qualified"},
+	{URI: "http://uri.etsi.org/TrstSvc/Svctype/AdESGeneration", Kind: KindServiceType, Label: "Generation of advanced electronic signatures and seals", Category: "non-qualified"},
+	{URI: "http://uri.etsi.org/TrstSvc/Svctype/RemoteQSigCDManagement/Q", Kind: KindServiceType, Label: "Qualified remote signature creation device management", Category: "qualified"},
+	{URI: "http://uri.etsi.org/TrstSvc/Svctype/RemoteQSealCDManagement/Q", Kind: KindServiceType, Label: "Qualified remote seal creation device management", Category: "qualified"},
+	{URI: "http://uri.etsi.org/TrstSvc/Svctype/EAA/Q", Kind: KindServiceType, Label: "Qualified electronic attestation of attributes", Category: "qualified"},
+	{URI: "http://uri.etsi.org/TrstSvc/Svctype/EAA", Kind: KindServiceType, Label: "Electronic attestation of attributes", Category: "non-qualified"},
+	{URI: "http://uri.etsi.org/TrstSvc/Svctype/ACA", Kind: KindServiceType, Label: "Attribute certificate authority", Category: "non-qualified"},
+	{URI: "http://uri.etsi.org/TrstSvc/Svctype/SignaturePolicyAuthority", Kind: KindServiceType, Label: "Signature policy authority", Category: "non-qualified"},
+	{URI: "http://uri.etsi.org/TrstSvc/Svctype/Archiv", Kind: KindServiceType, Label: "Archival", Category: "non-qualified"},
+	{URI: "http://uri.etsi.org/TrstSvc/Svctype/Archiv/nothavingPKIid", Kind: KindServiceType, Label: "Archival without PKI identity", Category: "non-qualified"},
+	{URI: "http://uri.etsi.org/TrstSvc/Svctype/IdV", Kind: KindServiceType, Label: "Identity verification", Category: "non-qualified"},
+	{URI: "http://uri.etsi.org/TrstSvc/Svctype/IdV/nothavingPKIid", Kind: KindServiceType, Label: "Identity verification without PKI identity", Category: "non-qualified"},
+	{URI: "http://uri.etsi.org/TrstSvc/Svctype/KEscrow", Kind: KindServiceType, Label: "Key escrow", Category: "non-qualified"},
+	{URI: "http://uri.etsi.org/TrstSvc/Svctype/KEscrow/nothavingPKIid", Kind: KindServiceType, Label: "Key escrow without PKI identity", Category: "non-qualified"},
+	{URI: "http://uri.etsi.org/TrstSvc/Svctype/PPwd", Kind: KindServiceType, Label: "Personal password", Category: "non-qualified"},
+	{URI: "http://uri.etsi.org/TrstSvc/Svctype/PPwd/nothavingPKIid", Kind: KindServiceType, Label: "Personal password without PKI identity", Category: "non-qualified"},
+	{URI: "http://uri.etsi.org/TrstSvc/Svctype/RA", Kind: KindServiceType, Label: "Registration authority", Category: "non-qualified"},
+	{URI: "http://uri.etsi.org/TrstSvc/Svctype/RA/nothavingPKIid", Kind: KindServiceType, Label: "Registration authority without PKI identity", Category: "non-qualified"},
+	{URI: "http://uri.etsi.org/TrstSvc/Svctype/TLIssuer", Kind: KindServiceType, Label: "Trusted list issuer", Category: "other"},
+	{URI: "http://uri.etsi.org/TrstSvc/Svctype/NationalRootCA-QC", Kind: KindServiceType, Label: "National root CA for qualified certificates", Category: "other"},
+	{URI: "http://uri.etsi.org/TrstSvc/Svctype/unspecified", Kind: KindServiceType, Label: "Unspecified", Category: "other"},
+	{URI: "http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/granted", Kind: KindServiceStatus, Label: "Granted", Category: "active"},
+	{URI: "http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/withdrawn", Kind: KindServiceStatus, Label: "Withdrawn", Category: "inactive"},
+	{URI: "http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/recognisedatnationallevel", Kind: KindServiceStatus, Label: "Recognised at national level", Category: "active"},
+	{URI: "http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/deprecatedatnationallevel", Kind: KindServiceStatus, Label: "Deprecated at national level", Category: "inactive"},
+	{URI: "http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/undersupervision", Kind: KindServiceStatus, Label: "Under supervision", Category: "active"},
+	{URI: "http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/supervisionincessation", Kind: KindServiceStatus, Label: "Supervision in cessation", Category: "active"},
+	{URI: "http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/supervisionceased", Kind: KindServiceStatus, Label: "Supervision ceased", Category: "inactive"},
+	{URI: "http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/supervisionrevoked", Kind: KindServiceStatus, Label: "Supervision revoked", Category: "inactive"},
+	{URI: "http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/accredited", Kind: KindServiceStatus, Label: "Accredited", Category: "active"},
+	{URI: "http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/accreditationceased", Kind: KindServiceStatus, Label: "Accreditation ceased", Category: "inactive"},
+	{URI: "http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/accreditationrevoked", Kind: KindServiceStatus, Label: "Accreditation revoked", Category: "inactive"},
+	{URI: "http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/setbynationallaw", Kind: KindServiceStatus, Label: "Set by national law", Category: "active"},
+	{URI: "http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/deprecatedbynationallaw", Kind: KindServiceStatus, Label: "Deprecated by national law", Category: "inactive"},
+	{URI: "http://uri.etsi.org/TrstSvc/TrustedList/TSLType/EUgeneric", Kind: KindTSLType, Label: "EU trusted list", Category: "eu"},
+	{URI: "http://uri.etsi.org/TrstSvc/TrustedList/TSLType/EUlistofthelists", Kind: KindTSLType, Label: "EU list of trusted lists", Category: "eu"},
+	{URI: "http://uri.etsi.org/TrstSvc/TrustedList/SvcInfoExt/ForeSignatures", Kind: KindAdditionalServiceInformation, Label: "For electronic signatures", Category: "qualification"},
+	{URI: "http://uri.etsi.org/TrstSvc/TrustedList/SvcInfoExt/ForeSeals", Kind: KindAdditionalServiceInformation, Label: "For electronic seals", Category: "qualification"},
+	{URI: "http://uri.etsi.org/TrstSvc/TrustedList/SvcInfoExt/ForWebSiteAuthentication", Kind: KindAdditionalServiceInformation, Label: "For website authentication", Category: "qualification"},
+	{URI: "http://uri.etsi.org/TrstSvc/TrustedList/SvcInfoExt/RootCA-QC", Kind: KindAdditionalServiceInformation, Label: "Root CA for qualified certificates", Category: "qualification"},
+}
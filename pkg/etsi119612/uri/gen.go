@@ -0,0 +1,83 @@
+//go:build ignore
+
+// gen writes definitions.go with the constants and lookup table of the URIs
+// in definitions.tsv. Run it with "go generate ./pkg/etsi119612/uri" after
+// changing definitions.tsv.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"strings"
+)
+
+// prefixes are the prefixes of the constant names per kind.
+var prefixes = map[string]string{
+	"ServiceType":                  "ServiceType",
+	"ServiceStatus":                "Status",
+	"TSLType":                      "TSLType",
+	"AdditionalServiceInformation": "SvcInfo",
+}
+
+type definition struct {
+	kind, name, uri, label, category string
+}
+
+func main() {
+	file, err := os.Open("definitions.tsv")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+
+	var defs []definition
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Split(text, "\t")
+		if len(fields) != 5 {
+			log.Fatalf("definitions.tsv:%d: expected 5 tab-separated columns, got %d", line, len(fields))
+		}
+		if _, ok := prefixes[fields[0]]; !ok {
+			log.Fatalf("definitions.tsv:%d: unknown kind %q", line, fields[0])
+		}
+		defs = append(defs, definition{fields[0], fields[1], fields[2], fields[3], fields[4]})
+	}
+	if err := scanner.Err(); err != nil {
+		log.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("// Code generated by gen.go; DO NOT EDIT.\n\n")
+	buf.WriteString("package uri\n\n")
+	for _, kind := range []string{"ServiceType", "ServiceStatus", "TSLType", "AdditionalServiceInformation"} {
+		fmt.Fprintf(&buf, "// %s URIs.\nconst (\n", kind)
+		for _, def := range defs {
+			if def.kind == kind {
+				fmt.Fprintf(&buf, "\t%s%s %s = %q // %s\n", prefixes[kind], def.name, kind, def.uri, def.label)
+			}
+		}
+		buf.WriteString(")\n\n")
+	}
+	buf.WriteString("// definitions holds all standard URIs in the order of definitions.tsv.\n")
+	buf.WriteString("var definitions = []Definition{\n")
+	for _, def := range defs {
+		fmt.Fprintf(&buf, "\t{URI: %q, Kind: Kind%s, Label: %q, Category: %q},\n", def.uri, def.kind, def.label, def.category)
+	}
+	buf.WriteString("}\n")
+
+	source, err := format.Source(buf.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile("definitions.go", source, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,73 @@
+package uri
+
+// legacyStatusPrefix is the prefix of the status URIs of Commission Decision
+// 2009/767/EC, used by trusted lists published before TS 119 612. Lookup and
+// the functions below treat them like their TrustedList/Svcstatus spelling.
+const legacyStatusPrefix = "http://uri.etsi.org/TrstSvc/eSigDir-1999-93-EC-TrustedList/Svcstatus/"
+
+// statusPrefix is the prefix of the status URIs of TS 119 612.
+const statusPrefix = "http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/"
+
+// legacyEquivalent gives the statuses a pre-eIDAS status was migrated to on
+// 1 July 2016, for qualified services and for services recognised at national
+// level only (see TS 119 612 v2.1.1 clause 5.5.4).
+type legacyEquivalent struct {
+	qualified, national ServiceStatus
+}
+
+// legacyStatuses maps the statuses of Directive 1999/93/EC to their eIDAS
+// equivalents.
+var legacyStatuses = map[ServiceStatus]legacyEquivalent{
+	StatusUnderSupervision:        {StatusGranted, StatusRecognisedAtNationalLevel},
+	StatusSupervisionInCessation:  {StatusGranted, StatusRecognisedAtNationalLevel},
+	StatusAccredited:              {StatusGranted, StatusRecognisedAtNationalLevel},
+	StatusSupervisionCeased:       {StatusWithdrawn, StatusDeprecatedAtNationalLevel},
+	StatusSupervisionRevoked:      {StatusWithdrawn, StatusDeprecatedAtNationalLevel},
+	StatusAccreditationCeased:     {StatusWithdrawn, StatusDeprecatedAtNationalLevel},
+	StatusAccreditationRevoked:    {StatusWithdrawn, StatusDeprecatedAtNationalLevel},
+	StatusSetByNationalLaw:        {StatusRecognisedAtNationalLevel, StatusRecognisedAtNationalLevel},
+	StatusDeprecatedByNationalLaw: {StatusDeprecatedAtNationalLevel, StatusDeprecatedAtNationalLevel},
+}
+
+// IsLegacyStatus reports whether status is one of the pre-eIDAS statuses of
+// Directive 1999/93/EC, such as undersupervision or accredited, in any
+// spelling.
+func IsLegacyStatus(status string) bool {
+	_, ok := legacyStatuses[ServiceStatus(normalize(status))]
+	return ok
+}
+
+// EquivalentStatus returns the eIDAS status a service of the given type with
+// status has. Legacy statuses are mapped the way trusted lists were migrated:
+// active statuses to granted and ended ones to withdrawn, or to recognised and
+// deprecated at national level for non-qualified service types. Other known
+// statuses are returned in their standard spelling, unknown ones unchanged.
+func EquivalentStatus(status, serviceType string) ServiceStatus {
+	key := ServiceStatus(normalize(status))
+	if eq, ok := legacyStatuses[key]; ok {
+		if Category(serviceType) == CategoryNonQualified {
+			return eq.national
+		}
+		return eq.qualified
+	}
+	if def, ok := Lookup(status); ok && def.Kind == KindServiceStatus {
+		return ServiceStatus(def.URI)
+	}
+	return ServiceStatus(status)
+}
+
+// StatusMatches reports whether a service of the given type with status
+// satisfies a status filter. Spellings of the same URI match each other (see
+// Lookup), and a filter on an eIDAS status such as granted also matches the
+// legacy statuses equivalent to it, so policies written for current lists
+// select the same services from historic snapshots. A filter on a legacy
+// status only matches that status.
+func StatusMatches(status, serviceType, filter string) bool {
+	if normalize(status) == normalize(filter) {
+		return true
+	}
+	if IsLegacyStatus(filter) || !IsLegacyStatus(status) {
+		return false
+	}
+	return EquivalentStatus(status, serviceType) == ServiceStatus(normalize(filter))
+}
@@ -0,0 +1,115 @@
+// Package uri defines the standard URIs of ETSI TS 119 612 trusted lists, the
+// service type identifiers, service statuses, TSL types and additional service
+// information, as typed constants with human-readable labels and categories.
+//
+// The constants and the lookup table are generated from definitions.tsv; run
+// "go generate ./pkg/etsi119612/uri" after editing it.
+package uri
+
+//go:generate go run gen.go
+
+import "strings"
+
+// Kind is the kind of URI a Definition describes.
+type Kind string
+
+// Kinds of URIs.
+const (
+	KindServiceType                  Kind = "ServiceType"
+	KindServiceStatus                Kind = "ServiceStatus"
+	KindTSLType                      Kind = "TSLType"
+	KindAdditionalServiceInformation Kind = "AdditionalServiceInformation"
+)
+
+// Categories of URIs. Service types are qualified, non-qualified or other,
+// service statuses active or inactive.
+const (
+	CategoryQualified     = "qualified"
+	CategoryNonQualified  = "non-qualified"
+	CategoryOther         = "other"
+	CategoryActive        = "active"
+	CategoryInactive      = "inactive"
+	CategoryEU            = "eu"
+	CategoryQualification = "qualification"
+)
+
+// ServiceType is a ServiceTypeIdentifier URI.
+type ServiceType string
+
+// ServiceStatus is a ServiceStatus URI.
+type ServiceStatus string
+
+// TSLType is a TSLType URI.
+type TSLType string
+
+// AdditionalServiceInformation is an AdditionalServiceInformation URI.
+type AdditionalServiceInformation string
+
+// Definition describes a standard URI.
+type Definition struct {
+	URI      string `json:"uri"`
+	Kind     Kind   `json:"kind"`
+	Label    string `json:"label"`    // Human-readable label, e.g. "Granted"
+	Category string `json:"category"` // One of the Category constants
+}
+
+// index maps the normalized URIs of definitions to their position.
+var index = func() map[string]int {
+	m := make(map[string]int, len(definitions))
+	for i, def := range definitions {
+		m[normalize(def.URI)] = i
+	}
+	return m
+}()
+
+// Lookup returns the definition of a standard URI. The URI is matched
+// regardless of an https scheme and a trailing slash, since both spellings
+// are found in published lists. Status URIs of Commission Decision
+// 2009/767/EC match the TS 119 612 status they were renamed to.
+func Lookup(uri string) (Definition, bool) {
+	i, ok := index[normalize(uri)]
+	if !ok {
+		return Definition{}, false
+	}
+	return definitions[i], true
+}
+
+// Label returns the label of a standard URI, or the last path segment of
+// other URIs, e.g. "granted".
+func Label(uri string) string {
+	if def, ok := Lookup(uri); ok {
+		return def.Label
+	}
+	trimmed := strings.TrimRight(uri, "/")
+	return trimmed[strings.LastIndex(trimmed, "/")+1:]
+}
+
+// Category returns the category of a standard URI, "" if it is not known.
+func Category(uri string) string {
+	def, _ := Lookup(uri)
+	return def.Category
+}
+
+// Definitions returns the definitions of a kind in the order of definitions.tsv,
+// or all definitions if kind is empty.
+func Definitions(kind Kind) []Definition {
+	var defs []Definition
+	for _, def := range definitions {
+		if kind == "" || def.Kind == kind {
+			defs = append(defs, def)
+		}
+	}
+	return defs
+}
+
+// normalize reduces a URI to the form used as lookup key.
+func normalize(uri string) string {
+	uri = strings.TrimSpace(uri)
+	if rest, ok := strings.CutPrefix(uri, "https://"); ok {
+		uri = "http://" + rest
+	}
+	if rest, ok := strings.CutPrefix(uri, legacyStatusPrefix); ok {
+		uri = statusPrefix + rest
+	}
+	return strings.TrimRight(uri, "/")
+}
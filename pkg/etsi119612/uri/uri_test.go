@@ -0,0 +1,124 @@
+package uri
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookup(t *testing.T) {
+	def, ok := Lookup(string(ServiceTypeCAQC))
+	require.True(t, ok)
+	assert.Equal(t, KindServiceType, def.Kind)
+	assert.Equal(t, "CA issuing qualified certificates", def.Label)
+	assert.Equal(t, CategoryQualified, def.Category)
+
+	// Both spellings of the status URIs found in published lists match
+	for _, spelling := range []string{
+		"http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/granted",
+		"https://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/granted/",
+		" http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/granted/ ",
+	} {
+		def, ok := Lookup(spelling)
+		require.True(t, ok, spelling)
+		assert.Equal(t, string(StatusGranted), def.URI)
+		assert.Equal(t, CategoryActive, def.Category)
+	}
+
+	_, ok = Lookup("http://example.com/Svctype/Custom")
+	assert.False(t, ok)
+	_, ok = Lookup("")
+	assert.False(t, ok)
+}
+
+func TestLabel(t *testing.T) {
+	assert.Equal(t, "Withdrawn", Label(string(StatusWithdrawn)))
+	assert.Equal(t, "EU list of trusted lists", Label(string(TSLTypeEUListOfTheLists)))
+	assert.Equal(t, "For electronic seals", Label(string(SvcInfoForESeals)))
+	assert.Equal(t, "Custom", Label("http://example.com/Svctype/Custom/"))
+	assert.Equal(t, "", Label(""))
+
+	assert.Equal(t, CategoryInactive, Category(string(StatusSupervisionCeased)))
+	assert.Equal(t, "", Category("http://example.com/Svctype/Custom"))
+}
+
+func TestDefinitions(t *testing.T) {
+	all := Definitions("")
+	require.NotEmpty(t, all)
+	seen := make(map[string]bool)
+	for _, def := range all {
+		assert.False(t, seen[normalize(def.URI)], "duplicate definition %s", def.URI)
+		seen[normalize(def.URI)] = true
+		assert.NotEmpty(t, def.Label, def.URI)
+		assert.NotEmpty(t, def.Category, def.URI)
+		assert.Contains(t, def.URI, "http://uri.etsi.org/TrstSvc/", def.URI)
+	}
+
+	counts := make(map[Kind]int)
+	for _, kind := range []Kind{KindServiceType, KindServiceStatus, KindTSLType, KindAdditionalServiceInformation} {
+		defs := Definitions(kind)
+		assert.NotEmpty(t, defs, kind)
+		for _, def := range defs {
+			assert.Equal(t, kind, def.Kind)
+		}
+		counts[kind] = len(defs)
+	}
+	assert.Equal(t, len(all), counts[KindServiceType]+counts[KindServiceStatus]+counts[KindTSLType]+counts[KindAdditionalServiceInformation])
+	assert.Empty(t, Definitions("Unknown"))
+}
+
+func TestLegacyStatus(t *testing.T) {
+	assert.True(t, IsLegacyStatus(string(StatusUnderSupervision)))
+	assert.True(t, IsLegacyStatus("https://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/accredited/"))
+	assert.True(t, IsLegacyStatus("http://uri.etsi.org/TrstSvc/eSigDir-1999-93-EC-TrustedList/Svcstatus/supervisionincessation"))
+	assert.False(t, IsLegacyStatus(string(StatusGranted)))
+	assert.False(t, IsLegacyStatus("http://example.com/Svcstatus/accredited"))
+
+	// The 2009/767/EC spelling is a known status
+	def, ok := Lookup("http://uri.etsi.org/TrstSvc/eSigDir-1999-93-EC-TrustedList/Svcstatus/accreditationceased")
+	require.True(t, ok)
+	assert.Equal(t, string(StatusAccreditationCeased), def.URI)
+	assert.Equal(t, CategoryInactive, def.Category)
+
+	qc, pkc := string(ServiceTypeCAQC), string(ServiceTypeCAPKC)
+	for _, tc := range []struct {
+		status      string
+		serviceType string
+		want        ServiceStatus
+	}{
+		{string(StatusUnderSupervision), qc, StatusGranted},
+		{string(StatusAccredited), qc, StatusGranted},
+		{string(StatusSupervisionInCessation), qc, StatusGranted},
+		{string(StatusSupervisionRevoked), qc, StatusWithdrawn},
+		{string(StatusAccreditationCeased), "", StatusWithdrawn},
+		{string(StatusUnderSupervision), pkc, StatusRecognisedAtNationalLevel},
+		{string(StatusSupervisionCeased), pkc, StatusDeprecatedAtNationalLevel},
+		{string(StatusSetByNationalLaw), qc, StatusRecognisedAtNationalLevel},
+		{string(StatusDeprecatedByNationalLaw), pkc, StatusDeprecatedAtNationalLevel},
+		{"http://uri.etsi.org/TrstSvc/eSigDir-1999-93-EC-TrustedList/Svcstatus/undersupervision", qc, StatusGranted},
+		{"https://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/granted/", qc, StatusGranted},
+		{"http://example.com/status", qc, "http://example.com/status"},
+	} {
+		assert.Equal(t, tc.want, EquivalentStatus(tc.status, tc.serviceType), tc.status)
+	}
+}
+
+func TestStatusMatches(t *testing.T) {
+	qc, pkc := string(ServiceTypeCAQC), string(ServiceTypeCAPKC)
+	granted := "https://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/granted/"
+
+	assert.True(t, StatusMatches(string(StatusGranted), qc, granted))
+	assert.True(t, StatusMatches(string(StatusUnderSupervision), qc, granted))
+	assert.True(t, StatusMatches(string(StatusAccredited), qc, string(StatusGranted)))
+	assert.False(t, StatusMatches(string(StatusSupervisionCeased), qc, granted))
+	assert.True(t, StatusMatches(string(StatusSupervisionCeased), qc, string(StatusWithdrawn)))
+	assert.False(t, StatusMatches(string(StatusUnderSupervision), pkc, granted))
+	assert.True(t, StatusMatches(string(StatusUnderSupervision), pkc, string(StatusRecognisedAtNationalLevel)))
+
+	// A filter on a legacy status is exact
+	assert.True(t, StatusMatches(string(StatusAccredited), qc, string(StatusAccredited)))
+	assert.False(t, StatusMatches(string(StatusUnderSupervision), qc, string(StatusAccredited)))
+	assert.False(t, StatusMatches(string(StatusGranted), qc, string(StatusAccredited)))
+	assert.False(t, StatusMatches(string(StatusWithdrawn), qc, granted))
+}
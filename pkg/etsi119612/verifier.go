@@ -0,0 +1,373 @@
+package etsi119612
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/beevik/etree"
+	xmldsig "github.com/russellhaering/goxmldsig"
+	"github.com/russellhaering/goxmldsig/etreeutils"
+)
+
+// Verifier verifies the XML signature of a signed TSL document. Set
+// TSLFetchOptions.Verifier to delegate signature verification, for example to
+// an external verification service or an HSM-backed verifier when
+// FIPS-validated cryptography is mandated. When no Verifier is set the
+// in-process LocalVerifier is used.
+//
+// Verify is called once for every signed document ParseTSL parses, including
+// referenced TSLs. It must check the signature and return the signed content,
+// which is what is parsed into the TSL, together with the signing
+// certificate. A document whose signature does not verify must be rejected
+// with an error; the TSL is then not loaded.
+type Verifier interface {
+	Verify(ctx context.Context, data []byte) (content []byte, signer *x509.Certificate, err error)
+}
+
+// VerifierFunc adapts a function to the Verifier interface.
+type VerifierFunc func(ctx context.Context, data []byte) ([]byte, *x509.Certificate, error)
+
+// Verify calls f(ctx, data).
+func (f VerifierFunc) Verify(ctx context.Context, data []byte) ([]byte, *x509.Certificate, error) {
+	return f(ctx, data)
+}
+
+// LocalVerifier verifies enveloped signatures in-process, using the
+// canonicalization of github.com/russellhaering/goxmldsig. The document must
+// hold a single XML signature, a child of its document element. The signature
+// over the SignedInfo must verify with one of the KeyInfo certificates, which
+// is returned as the signer; whether it is trusted is decided by ParseTSL.
+// Every reference is checked: the one to the list itself, by an empty URI or
+// the Id of the document element, and the others, such as the XAdES
+// SignedProperties, resolved by their Id attribute. It is the default
+// Verifier.
+type LocalVerifier struct{}
+
+// xmldsigNamespace is the namespace of XML signature elements.
+const xmldsigNamespace = "http://www.w3.org/2000/09/xmldsig#"
+
+// signatureAlgorithms maps the SignatureMethod algorithms LocalVerifier
+// supports to the algorithms of their certificates.
+var signatureAlgorithms = map[string]x509.SignatureAlgorithm{
+	"http://www.w3.org/2000/09/xmldsig#rsa-sha1":             x509.SHA1WithRSA,
+	"http://www.w3.org/2001/04/xmldsig-more#rsa-sha256":      x509.SHA256WithRSA,
+	"http://www.w3.org/2001/04/xmldsig-more#rsa-sha384":      x509.SHA384WithRSA,
+	"http://www.w3.org/2001/04/xmldsig-more#rsa-sha512":      x509.SHA512WithRSA,
+	"http://www.w3.org/2001/04/xmldsig-more#ecdsa-sha1":      x509.ECDSAWithSHA1,
+	"http://www.w3.org/2001/04/xmldsig-more#ecdsa-sha256":    x509.ECDSAWithSHA256,
+	"http://www.w3.org/2001/04/xmldsig-more#ecdsa-sha384":    x509.ECDSAWithSHA384,
+	"http://www.w3.org/2001/04/xmldsig-more#ecdsa-sha512":    x509.ECDSAWithSHA512,
+	"http://www.w3.org/2007/05/xmldsig-more#sha256-rsa-MGF1": x509.SHA256WithRSAPSS,
+	"http://www.w3.org/2007/05/xmldsig-more#sha384-rsa-MGF1": x509.SHA384WithRSAPSS,
+	"http://www.w3.org/2007/05/xmldsig-more#sha512-rsa-MGF1": x509.SHA512WithRSAPSS,
+	"http://www.w3.org/2021/04/xmldsig-more#eddsa-ed25519":   x509.PureEd25519,
+}
+
+// digestAlgorithms maps the DigestMethod algorithms LocalVerifier supports to
+// their hashes.
+var digestAlgorithms = map[string]crypto.Hash{
+	"http://www.w3.org/2000/09/xmldsig#sha1":        crypto.SHA1,
+	"http://www.w3.org/2001/04/xmldsig-more#sha224": crypto.SHA224,
+	"http://www.w3.org/2001/04/xmlenc#sha256":       crypto.SHA256,
+	"http://www.w3.org/2001/04/xmldsig-more#sha384": crypto.SHA384,
+	"http://www.w3.org/2001/04/xmlenc#sha512":       crypto.SHA512,
+}
+
+// Verify implements Verifier. The returned content is the canonical form of
+// the document without its signature, as digested by the reference to it.
+func (LocalVerifier) Verify(ctx context.Context, data []byte) ([]byte, *x509.Certificate, error) {
+	verified, err := verifyLocal(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	return verified.content, verified.signer, nil
+}
+
+// localSignature is a signature verified by LocalVerifier.
+type localSignature struct {
+	content []byte
+	signer  *x509.Certificate
+	// sig is the Signature element and signedInfo the canonical SignedInfo
+	// that was verified, parsed again.
+	sig, signedInfo *etree.Element
+}
+
+// verifyLocal verifies the signature of data as LocalVerifier does.
+func verifyLocal(data []byte) (*localSignature, error) {
+	doc := etree.NewDocument()
+	if err := doc.ReadFromBytes(data); err != nil {
+		return nil, err
+	}
+	root := doc.Root()
+	if root == nil {
+		return nil, fmt.Errorf("no document element")
+	}
+	sig, err := documentSignature(root)
+	if err != nil {
+		return nil, err
+	}
+	signedInfo := childElement(sig, "SignedInfo")
+	signatureValue := childElement(sig, "SignatureValue")
+	if signedInfo == nil || signatureValue == nil {
+		return nil, fmt.Errorf("no SignedInfo or SignatureValue")
+	}
+
+	method := childElement(signedInfo, "CanonicalizationMethod")
+	if method == nil {
+		return nil, fmt.Errorf("no CanonicalizationMethod")
+	}
+	canonicalizer, err := canonicalizerFor(method)
+	if err != nil {
+		return nil, err
+	}
+	canonicalSignedInfo, err := canonicalize(signedInfo, canonicalizer)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := verifySignedInfo(sig, signedInfo, canonicalSignedInfo, signatureValue)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only the references of the verified SignedInfo are trusted
+	verified := etree.NewDocument()
+	if err := verified.ReadFromBytes(canonicalSignedInfo); err != nil {
+		return nil, err
+	}
+	var content []byte
+	for _, ref := range verified.Root().SelectElements("Reference") {
+		referenced, isDocument, err := verifyReference(root, sig, ref)
+		if err != nil {
+			return nil, fmt.Errorf("reference %q: %w", ref.SelectAttrValue("URI", ""), err)
+		}
+		if isDocument && content == nil {
+			content = referenced
+		}
+	}
+	if content == nil {
+		return nil, fmt.Errorf("no signed content")
+	}
+	return &localSignature{content: content, signer: signer, sig: sig, signedInfo: verified.Root()}, nil
+}
+
+// documentSignature returns the XML signature of the document of root, which
+// must be a child of root and the only XML signature element of the document.
+func documentSignature(root *etree.Element) (*etree.Element, error) {
+	var signatures []*etree.Element
+	var collect func(el *etree.Element)
+	collect = func(el *etree.Element) {
+		for _, child := range el.ChildElements() {
+			if child.Tag == "Signature" && child.NamespaceURI() == xmldsigNamespace {
+				signatures = append(signatures, child)
+			}
+			collect(child)
+		}
+	}
+	collect(root)
+	if len(signatures) == 0 {
+		return nil, fmt.Errorf("no Signature element")
+	}
+	if len(signatures) > 1 {
+		return nil, fmt.Errorf("%d Signature elements", len(signatures))
+	}
+	if signatures[0].Parent() != root {
+		return nil, fmt.Errorf("signature is not a child of the document element")
+	}
+	return signatures[0], nil
+}
+
+// childElement returns the only XML signature child element of el with the
+// given tag, nil if there is none or more than one.
+func childElement(el *etree.Element, tag string) *etree.Element {
+	var found *etree.Element
+	for _, child := range el.SelectElements(tag) {
+		if child.NamespaceURI() != xmldsigNamespace {
+			continue
+		}
+		if found != nil {
+			return nil
+		}
+		found = child
+	}
+	return found
+}
+
+// verifySignedInfo verifies the signature value of sig over the canonical
+// SignedInfo and returns the KeyInfo certificate it was made with.
+func verifySignedInfo(sig, signedInfo *etree.Element, canonical []byte, signatureValue *etree.Element) (*x509.Certificate, error) {
+	method := childElement(signedInfo, "SignatureMethod")
+	if method == nil {
+		return nil, fmt.Errorf("no SignatureMethod")
+	}
+	uri := strings.TrimSpace(method.SelectAttrValue("Algorithm", ""))
+	algorithm, ok := signatureAlgorithms[uri]
+	if !ok {
+		return nil, fmt.Errorf("unsupported signature algorithm %q", uri)
+	}
+	value, err := decodeBase64(signatureValue.Text())
+	if err != nil {
+		return nil, fmt.Errorf("invalid SignatureValue: %w", err)
+	}
+
+	var certs []*etree.Element
+	if keyInfo := childElement(sig, "KeyInfo"); keyInfo != nil {
+		certs = keyInfo.FindElements("./X509Data/X509Certificate")
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no KeyInfo certificate")
+	}
+	for _, el := range certs {
+		der, err := decodeBase64(el.Text())
+		if err != nil {
+			continue
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			continue
+		}
+		if cert.CheckSignature(algorithm, canonical, value) == nil {
+			return cert, nil
+		}
+	}
+	return nil, fmt.Errorf("signature does not verify with any KeyInfo certificate")
+}
+
+// verifyReference checks the digest of the reference ref of sig, in the
+// document of root, and returns the canonical referenced content. isDocument
+// reports whether ref references the document element.
+func verifyReference(root, sig, ref *etree.Element) (content []byte, isDocument bool, err error) {
+	uri := ref.SelectAttrValue("URI", "")
+	target := root
+	if uri != "" {
+		id, ok := strings.CutPrefix(uri, "#")
+		if !ok {
+			return nil, false, fmt.Errorf("unsupported reference URI")
+		}
+		targets := elementsWithID(root, id)
+		if len(targets) != 1 {
+			return nil, false, fmt.Errorf("%d elements with Id %q", len(targets), id)
+		}
+		target = targets[0]
+	}
+	isDocument = target == root
+
+	var canonicalizer xmldsig.Canonicalizer = xmldsig.MakeC14N10RecCanonicalizer()
+	enveloped := false
+	if transforms := ref.SelectElement("Transforms"); transforms != nil {
+		for _, transform := range transforms.SelectElements("Transform") {
+			if transform.SelectAttrValue("Algorithm", "") == string(xmldsig.EnvelopedSignatureAltorithmId) {
+				enveloped = true
+				continue
+			}
+			if canonicalizer, err = canonicalizerFor(transform); err != nil {
+				return nil, false, err
+			}
+		}
+	}
+	if enveloped {
+		if !isDocument {
+			return nil, false, fmt.Errorf("enveloped signature transform of an element that does not envelope the signature")
+		}
+		target = root.Copy()
+		target.RemoveChildAt(sig.Index())
+	}
+	content, err = canonicalize(target, canonicalizer)
+	if err != nil {
+		return nil, false, err
+	}
+
+	method := ref.SelectElement("DigestMethod")
+	value := ref.SelectElement("DigestValue")
+	if method == nil || value == nil {
+		return nil, false, fmt.Errorf("no DigestMethod or DigestValue")
+	}
+	algorithm := strings.TrimSpace(method.SelectAttrValue("Algorithm", ""))
+	hash, ok := digestAlgorithms[algorithm]
+	if !ok {
+		return nil, false, fmt.Errorf("unsupported digest algorithm %q", algorithm)
+	}
+	expected, err := decodeBase64(value.Text())
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid DigestValue: %w", err)
+	}
+	digest := hash.New()
+	digest.Write(content)
+	if !bytes.Equal(digest.Sum(nil), expected) {
+		return nil, false, fmt.Errorf("digest does not match")
+	}
+	return content, isDocument, nil
+}
+
+// elementsWithID returns el and its descendants whose Id attribute is id. The
+// Id is compared as a value rather than compiled into a path, as it comes from
+// the document.
+func elementsWithID(el *etree.Element, id string) []*etree.Element {
+	var found []*etree.Element
+	if attr := el.SelectAttr("Id"); attr != nil && attr.Value == id {
+		found = append(found, el)
+	}
+	for _, child := range el.ChildElements() {
+		found = append(found, elementsWithID(child, id)...)
+	}
+	return found
+}
+
+// canonicalizerFor returns the canonicalizer of a CanonicalizationMethod or
+// Transform element.
+func canonicalizerFor(el *etree.Element) (xmldsig.Canonicalizer, error) {
+	prefixes := ""
+	if inclusive := el.SelectElement("InclusiveNamespaces"); inclusive != nil {
+		prefixes = inclusive.SelectAttrValue("PrefixList", "")
+	}
+	algorithm := el.SelectAttrValue("Algorithm", "")
+	switch xmldsig.AlgorithmID(algorithm) {
+	case xmldsig.CanonicalXML10ExclusiveAlgorithmId:
+		return xmldsig.MakeC14N10ExclusiveCanonicalizerWithPrefixList(prefixes), nil
+	case xmldsig.CanonicalXML10ExclusiveWithCommentsAlgorithmId:
+		return xmldsig.MakeC14N10ExclusiveWithCommentsCanonicalizerWithPrefixList(prefixes), nil
+	case xmldsig.CanonicalXML11AlgorithmId:
+		return xmldsig.MakeC14N11Canonicalizer(), nil
+	case xmldsig.CanonicalXML11WithCommentsAlgorithmId:
+		return xmldsig.MakeC14N11WithCommentsCanonicalizer(), nil
+	case xmldsig.CanonicalXML10RecAlgorithmId:
+		return xmldsig.MakeC14N10RecCanonicalizer(), nil
+	case xmldsig.CanonicalXML10WithCommentsAlgorithmId:
+		return xmldsig.MakeC14N10WithCommentsCanonicalizer(), nil
+	}
+	return nil, fmt.Errorf("unsupported canonicalization algorithm %q", algorithm)
+}
+
+// canonicalize canonicalizes el with the namespaces declared by its
+// ancestors.
+func canonicalize(el *etree.Element, canonicalizer xmldsig.Canonicalizer) ([]byte, error) {
+	nsContext, err := etreeutils.NSBuildParentContext(el)
+	if err != nil {
+		return nil, err
+	}
+	detached, err := etreeutils.NSDetatch(nsContext, el)
+	if err != nil {
+		return nil, err
+	}
+	return canonicalizer.Canonicalize(detached)
+}
+
+// decodeBase64 decodes base64 text, ignoring white space.
+func decodeBase64(text string) ([]byte, error) {
+	return base64.StdEncoding.DecodeString(strings.Join(strings.Fields(text), ""))
+}
+
+// verifier returns the Verifier of the options, LocalVerifier if none is set.
+func (options TSLFetchOptions) verifier() Verifier {
+	if options.Verifier != nil {
+		return options.Verifier
+	}
+	return LocalVerifier{}
+}
@@ -0,0 +1,140 @@
+package etsi119612_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/beevik/etree"
+	xmldsig "github.com/russellhaering/goxmldsig"
+	"github.com/sirosfoundation/g119612/pkg/dsig"
+	"github.com/sirosfoundation/g119612/pkg/etsi119612"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTSL_Verifier(t *testing.T) {
+	signed, err := os.ReadFile(filepath.Join("testdata", "SE-TL.xml"))
+	require.NoError(t, err)
+	unsigned, err := os.ReadFile(filepath.Join("testdata", "EWC-TL.xml"))
+	require.NoError(t, err)
+
+	// The default verifier checks the signature in-process
+	local, err := etsi119612.ParseTSL(signed, "SE-TL.xml", etsi119612.DefaultTSLFetchOptions)
+	require.NoError(t, err)
+	require.True(t, local.Signed)
+
+	// A delegated verifier sees every signed document and supplies the
+	// content and the signer
+	var calls int
+	options := etsi119612.DefaultTSLFetchOptions
+	options.Verifier = etsi119612.VerifierFunc(func(ctx context.Context, data []byte) ([]byte, *x509.Certificate, error) {
+		calls++
+		_, hasDeadline := ctx.Deadline()
+		assert.True(t, hasDeadline)
+		return etsi119612.LocalVerifier{}.Verify(ctx, data)
+	})
+	remote, err := etsi119612.ParseTSL(signed, "SE-TL.xml", options)
+	require.NoError(t, err)
+	assert.Equal(t, 1, calls)
+	assert.True(t, remote.Signer.Equal(&local.Signer))
+	assert.Equal(t, local.NumberOfTrustServiceProviders(), remote.NumberOfTrustServiceProviders())
+	require.NotNil(t, remote.SignatureInfo())
+
+	_, err = etsi119612.ParseTSL(unsigned, "EWC-TL.xml", options)
+	require.NoError(t, err)
+	assert.Equal(t, 1, calls, "unsigned documents are not verified")
+
+	// A rejected signature fails the parse
+	rejected := errors.New("signature rejected by verification service")
+	options.Verifier = etsi119612.VerifierFunc(func(ctx context.Context, data []byte) ([]byte, *x509.Certificate, error) {
+		return nil, nil, rejected
+	})
+	_, err = etsi119612.ParseTSL(signed, "SE-TL.xml", options)
+	assert.ErrorIs(t, err, rejected)
+
+	// Referenced TSLs fetched with the options use the verifier too
+	calls = 0
+	options.Verifier = etsi119612.VerifierFunc(func(ctx context.Context, data []byte) ([]byte, *x509.Certificate, error) {
+		calls++
+		return etsi119612.LocalVerifier{}.Verify(ctx, data)
+	})
+	_, err = etsi119612.FetchTSLWithOptions("file://./testdata/SE-TL.xml", options)
+	require.NoError(t, err)
+	assert.Equal(t, 1, calls)
+}
+
+func TestLocalVerifier(t *testing.T) {
+	bad, err := os.ReadFile(filepath.Join("testdata", "SE-TL-bad-sig.xml"))
+	require.NoError(t, err)
+	_, _, err = etsi119612.LocalVerifier{}.Verify(context.Background(), bad)
+	assert.Error(t, err)
+
+	_, _, err = etsi119612.LocalVerifier{}.Verify(context.Background(), []byte("<Signature>"))
+	assert.Error(t, err)
+
+	// The reference to the XAdES SignedProperties is checked as well as the
+	// one to the list
+	signed, err := os.ReadFile(filepath.Join("testdata", "SE-TL.xml"))
+	require.NoError(t, err)
+	content, signer, err := etsi119612.LocalVerifier{}.Verify(context.Background(), signed)
+	require.NoError(t, err)
+	require.NotNil(t, signer)
+	assert.NotContains(t, string(content), "SignatureValue")
+	tampered := bytes.Replace(signed, []byte("<xades:SigningTime>2025-04-10T11:45:50Z<"), []byte("<xades:SigningTime>2025-04-11T11:45:50Z<"), 1)
+	require.NotEqual(t, signed, tampered)
+	_, _, err = etsi119612.LocalVerifier{}.Verify(context.Background(), tampered)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "#xades-id-")
+}
+
+// signWithID signs the TSL in data with a reference to the Id id of its
+// document element.
+func signWithID(t *testing.T, data []byte, id string) []byte {
+	t.Helper()
+	doc := etree.NewDocument()
+	require.NoError(t, doc.ReadFromBytes(data))
+	doc.Root().CreateAttr("Id", id)
+	signer, err := dsig.GenerateSelfSignedSigner(dsig.SelfSignedOptions{})
+	require.NoError(t, err)
+	signingContext := xmldsig.NewDefaultSigningContext(signer)
+	signingContext.IdAttribute = "Id"
+	signingContext.Canonicalizer = xmldsig.MakeC14N10ExclusiveCanonicalizerWithPrefixList("")
+	signed, err := signingContext.SignEnveloped(doc.Root())
+	require.NoError(t, err)
+	out := etree.NewDocument()
+	out.SetRoot(signed)
+	result, err := out.WriteToBytes()
+	require.NoError(t, err)
+	return result
+}
+
+func TestLocalVerifier_ReferenceID(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "EWC-TL.xml"))
+	require.NoError(t, err)
+
+	// A reference to the Id of the document element signs the list
+	signed := signWithID(t, data, "tsl")
+	require.Contains(t, string(signed), `URI="#tsl"`)
+	content, signer, err := etsi119612.LocalVerifier{}.Verify(context.Background(), signed)
+	require.NoError(t, err)
+	require.NotNil(t, signer)
+	assert.Contains(t, string(content), "TrustServiceStatusList")
+
+	// The Id is matched as a value, a quote in it is no path syntax
+	signed = signWithID(t, data, "a'b")
+	_, _, err = etsi119612.LocalVerifier{}.Verify(context.Background(), signed)
+	require.NoError(t, err)
+	_, err = etsi119612.ParseTSL(signed, "quoted.xml", etsi119612.DefaultTSLFetchOptions)
+	require.NoError(t, err)
+
+	// Only a unique Id is resolved
+	duplicate := bytes.Replace(signed, []byte("<SchemeInformation"), []byte(`<SchemeInformation Id="a&apos;b"`), 1)
+	require.NotEqual(t, signed, duplicate)
+	_, _, err = etsi119612.LocalVerifier{}.Verify(context.Background(), duplicate)
+	assert.ErrorContains(t, err, "2 elements with Id")
+}
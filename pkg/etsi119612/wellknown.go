@@ -0,0 +1,150 @@
+package etsi119612
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultWellKnownPath is the path resolved on an operator's host to discover
+// the current location of its trust status list.
+const DefaultWellKnownPath = "/.well-known/trust-list"
+
+// maxWellKnownPointerSize bounds the size of a well-known response that is
+// interpreted as a pointer rather than as a TSL document.
+const maxWellKnownPointerSize = 8192
+
+// wellKnownPointer is the JSON form of a well-known pointer document.
+type wellKnownPointer struct {
+	Location string `json:"location"`
+	URL      string `json:"url"`
+}
+
+// WellKnownURL returns the https URL of the well-known trust list entry for host.
+// An empty path selects DefaultWellKnownPath.
+func WellKnownURL(host, path string) (string, error) {
+	host = strings.TrimSpace(host)
+	if host == "" || strings.ContainsAny(host, "/?#@ ") {
+		return "", fmt.Errorf("invalid well-known host %q", host)
+	}
+	if path == "" {
+		path = DefaultWellKnownPath
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	u := url.URL{Scheme: "https", Host: host, Path: path}
+	return u.String(), nil
+}
+
+// ResolveWellKnown discovers the location of a TSL through the well-known URI of host.
+// This allows operators to rotate the actual file URL while keeping the
+// well-known entry stable.
+//
+// The well-known resource may:
+//   - redirect to the TSL (the final URL after redirects is returned)
+//   - contain the TSL location as a plain text URL
+//   - contain a JSON object with a "location" (or "url") member
+//   - serve the TSL itself (the well-known URL is returned)
+//
+// Parameters:
+//   - host: Host name, optionally with port, e.g. "example.com"
+//   - path: Well-known path, empty for DefaultWellKnownPath
+//   - options: Fetch options for the HTTP request (client, timeout, user agent)
+//
+// Returns:
+//   - The URL of the TSL to fetch
+//   - An error if the well-known resource cannot be retrieved or points to an invalid location
+func ResolveWellKnown(host, path string, options TSLFetchOptions) (string, error) {
+	wellKnown, err := WellKnownURL(host, path)
+	if err != nil {
+		return "", err
+	}
+
+	options = options.ForURL(wellKnown)
+	if err := options.waitForHost(context.Background(), wellKnown); err != nil {
+		return "", err
+	}
+	client, release := options.fetchClient()
+	defer release()
+	ctx := context.Background()
+	if options.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, options.Timeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", wellKnown, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", options.UserAgent)
+	if len(options.AcceptHeaders) > 0 {
+		req.Header.Set("Accept", strings.Join(options.AcceptHeaders, ", "))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", classifyRequestError(context.Background(), fmt.Errorf("failed to fetch %s: %w", wellKnown, err))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", statusError(resp.StatusCode, fmt.Errorf("unexpected HTTP status from %s: %s", wellKnown, resp.Status))
+	}
+
+	// Only a small prefix is needed to tell a pointer from a TSL document
+	head, err := io.ReadAll(io.LimitReader(resp.Body, maxWellKnownPointerSize+1))
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", wellKnown, err)
+	}
+	final := resp.Request.URL.String()
+
+	location, isPointer := parseWellKnownPointer(head)
+	if !isPointer {
+		log.Debugf("g119612: well-known entry %s serves the TSL at %s", wellKnown, final)
+		return final, nil
+	}
+
+	target, err := resp.Request.URL.Parse(location)
+	if err != nil {
+		return "", fmt.Errorf("invalid TSL location %q in %s: %w", location, wellKnown, err)
+	}
+	if target.Scheme != "https" && target.Scheme != "http" {
+		return "", fmt.Errorf("TSL location %q in %s must be an http(s) URL", location, wellKnown)
+	}
+	log.Infof("g119612: well-known entry %s points to %s", wellKnown, target)
+	return target.String(), nil
+}
+
+// parseWellKnownPointer extracts a TSL location from a well-known response body.
+// It returns false if the body is not a pointer (e.g. it is the TSL itself).
+func parseWellKnownPointer(body []byte) (string, bool) {
+	if len(body) > maxWellKnownPointerSize {
+		return "", false
+	}
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) == 0 || trimmed[0] == '<' {
+		return "", false
+	}
+	if trimmed[0] == '{' {
+		var ptr wellKnownPointer
+		if err := json.Unmarshal(trimmed, &ptr); err != nil {
+			return "", false
+		}
+		if ptr.Location != "" {
+			return ptr.Location, true
+		}
+		return ptr.URL, ptr.URL != ""
+	}
+	line := string(trimmed)
+	if strings.ContainsAny(line, " \t\r\n") {
+		return "", false
+	}
+	return line, true
+}
@@ -0,0 +1,91 @@
+package etsi119612_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/sirosfoundation/g119612/pkg/etsi119612"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newWellKnownServer(t *testing.T, handler http.HandlerFunc) (string, etsi119612.TSLFetchOptions) {
+	t.Helper()
+	srv := httptest.NewTLSServer(handler)
+	t.Cleanup(srv.Close)
+	u, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+	options := etsi119612.DefaultTSLFetchOptions
+	options.Client = srv.Client()
+	return u.Host, options
+}
+
+func TestWellKnownURL(t *testing.T) {
+	u, err := etsi119612.WellKnownURL("example.com", "")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/.well-known/trust-list", u)
+
+	u, err = etsi119612.WellKnownURL("example.com:8443", "tsl/current")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com:8443/tsl/current", u)
+
+	for _, host := range []string{"", "https://example.com", "example.com/path", "user@example.com"} {
+		_, err := etsi119612.WellKnownURL(host, "")
+		assert.Error(t, err, host)
+	}
+}
+
+func TestResolveWellKnown(t *testing.T) {
+	tsl := strictTSL("2025-01-01T00:00:00Z", "", "")
+	host, options := newWellKnownServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/trust-list":
+			w.Write([]byte("https://tsl.example.com/tsl-v42.xml\n"))
+		case "/custom":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"location": "/lists/current.xml"}`))
+		case "/redirect":
+			http.Redirect(w, r, "/lists/current.xml", http.StatusFound)
+		case "/lists/current.xml":
+			w.Write(tsl)
+		case "/bad-scheme":
+			w.Write([]byte("file:///etc/passwd"))
+		default:
+			http.NotFound(w, r)
+		}
+	})
+
+	t.Run("plain text pointer", func(t *testing.T) {
+		u, err := etsi119612.ResolveWellKnown(host, "", options)
+		require.NoError(t, err)
+		assert.Equal(t, "https://tsl.example.com/tsl-v42.xml", u)
+	})
+
+	t.Run("json pointer relative to well-known", func(t *testing.T) {
+		u, err := etsi119612.ResolveWellKnown(host, "/custom", options)
+		require.NoError(t, err)
+		assert.Equal(t, "https://"+host+"/lists/current.xml", u)
+	})
+
+	t.Run("redirect to tsl", func(t *testing.T) {
+		u, err := etsi119612.ResolveWellKnown(host, "/redirect", options)
+		require.NoError(t, err)
+		assert.Equal(t, "https://"+host+"/lists/current.xml", u)
+
+		fetched, err := etsi119612.FetchTSLWithOptions(u, options)
+		require.NoError(t, err)
+		assert.Equal(t, "SE", fetched.StatusList.TslSchemeInformation.TslSchemeTerritory)
+	})
+
+	t.Run("non http location", func(t *testing.T) {
+		_, err := etsi119612.ResolveWellKnown(host, "/bad-scheme", options)
+		assert.ErrorContains(t, err, "must be an http(s) URL")
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		_, err := etsi119612.ResolveWellKnown(host, "/missing", options)
+		assert.ErrorContains(t, err, "unexpected HTTP status")
+	})
+}
@@ -0,0 +1,229 @@
+// Package ipfs implements the parts of IPFS needed to retrieve files by their
+// content identifier (CID) from an untrusted HTTP gateway: parsing CIDs,
+// verifying blocks against them and decoding the dag-pb nodes UnixFS files
+// and directories are made of.
+//
+// Blocks are fetched one at a time in their raw form, as specified for
+// trustless gateways, and each is verified against the CID it was requested
+// by, so a gateway cannot alter a file without the change being detected.
+package ipfs
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Codecs of the blocks a CID can refer to.
+const (
+	CodecRaw   = 0x55 // The block is the content itself
+	CodecDagPB = 0x70 // The block is a dag-pb node, see DecodeNode
+)
+
+// Multihash function codes supported by CID.Verify.
+const (
+	HashIdentity = 0x00 // The digest is the content itself
+	HashSHA256   = 0x12
+	HashSHA512   = 0x13
+)
+
+// ErrDigestMismatch indicates that a block does not have the digest its CID names.
+var ErrDigestMismatch = errors.New("block does not match its CID")
+
+// CID is a content identifier: the multihash of a block and how to decode it.
+type CID struct {
+	Version   int    // 0 or 1
+	Codec     uint64 // CodecDagPB for version 0
+	Multihash []byte // Hash function code, digest length and digest, as varints and bytes
+}
+
+// base32Lower is the base32 multibase encoding ("b") of CIDv1.
+var base32Lower = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+// base58Alphabet is the alphabet of base58btc, the encoding of CIDv0.
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// ParseCID parses a CID in its string form: a CIDv0 such as
+// "QmY7Yh4UquoXHLPFo2XbhXkhBvFoPwmQUSa92pxnxjQuPU", or a CIDv1 in the base32
+// ("b"), base58btc ("z") or base16 ("f") multibase encoding such as
+// "bafkreigh2akiscaildcqabsyg3dfr6chu3fgpregiymsck7e7aqa4s52zy".
+func ParseCID(s string) (CID, error) {
+	if len(s) == 46 && strings.HasPrefix(s, "Qm") {
+		mh, err := decodeBase58(s)
+		if err != nil {
+			return CID{}, fmt.Errorf("invalid CID %q: %w", s, err)
+		}
+		if _, err := parseMultihash(mh); err != nil {
+			return CID{}, fmt.Errorf("invalid CID %q: %w", s, err)
+		}
+		return CID{Version: 0, Codec: CodecDagPB, Multihash: mh}, nil
+	}
+	if s == "" {
+		return CID{}, errors.New("empty CID")
+	}
+	var data []byte
+	var err error
+	switch s[0] {
+	case 'b':
+		data, err = base32Lower.DecodeString(s[1:])
+	case 'B':
+		data, err = base32Lower.DecodeString(strings.ToLower(s[1:]))
+	case 'z':
+		data, err = decodeBase58(s[1:])
+	case 'f', 'F':
+		data, err = hex.DecodeString(s[1:])
+	default:
+		return CID{}, fmt.Errorf("invalid CID %q: unsupported multibase prefix %q", s, s[0])
+	}
+	if err != nil {
+		return CID{}, fmt.Errorf("invalid CID %q: %w", s, err)
+	}
+	c, err := DecodeCID(data)
+	if err != nil {
+		return CID{}, fmt.Errorf("invalid CID %q: %w", s, err)
+	}
+	return c, nil
+}
+
+// DecodeCID decodes a CID in its binary form, as found in the links of dag-pb
+// nodes. A CIDv0 is a bare sha2-256 multihash.
+func DecodeCID(data []byte) (CID, error) {
+	if len(data) == 34 && data[0] == HashSHA256 && data[1] == 32 {
+		return CID{Version: 0, Codec: CodecDagPB, Multihash: bytes.Clone(data)}, nil
+	}
+	version, n := binary.Uvarint(data)
+	if n <= 0 || version != 1 {
+		return CID{}, errors.New("unsupported CID version")
+	}
+	data = data[n:]
+	codec, n := binary.Uvarint(data)
+	if n <= 0 {
+		return CID{}, errors.New("truncated CID")
+	}
+	data = data[n:]
+	length, err := parseMultihash(data)
+	if err != nil {
+		return CID{}, err
+	}
+	if length != len(data) {
+		return CID{}, errors.New("trailing bytes after multihash")
+	}
+	return CID{Version: 1, Codec: codec, Multihash: bytes.Clone(data)}, nil
+}
+
+// parseMultihash checks the header of a multihash and returns its length.
+func parseMultihash(mh []byte) (int, error) {
+	_, n := binary.Uvarint(mh)
+	if n <= 0 {
+		return 0, errors.New("truncated multihash")
+	}
+	size, m := binary.Uvarint(mh[n:])
+	if m <= 0 || uint64(len(mh)-n-m) < size {
+		return 0, errors.New("truncated multihash")
+	}
+	return n + m + int(size), nil
+}
+
+// Sum returns the CIDv1 with a sha2-256 multihash of a block of the given
+// codec, such as CodecRaw for a file stored in a single block.
+func Sum(codec uint64, block []byte) CID {
+	digest := sha256.Sum256(block)
+	mh := append([]byte{HashSHA256, sha256.Size}, digest[:]...)
+	return CID{Version: 1, Codec: codec, Multihash: mh}
+}
+
+// String returns the CID in its usual string form: base58btc for CIDv0 and
+// base32 for CIDv1.
+func (c CID) String() string {
+	if c.Version == 0 {
+		return encodeBase58(c.Multihash)
+	}
+	return "b" + base32Lower.EncodeToString(c.Bytes())
+}
+
+// Bytes returns the binary form of the CID.
+func (c CID) Bytes() []byte {
+	if c.Version == 0 {
+		return bytes.Clone(c.Multihash)
+	}
+	data := binary.AppendUvarint(nil, 1)
+	data = binary.AppendUvarint(data, c.Codec)
+	return append(data, c.Multihash...)
+}
+
+// Verify checks that block has the digest named by the CID. It returns an
+// error wrapping ErrDigestMismatch if it does not, and another error if the
+// hash function is not supported.
+func (c CID) Verify(block []byte) error {
+	code, n := binary.Uvarint(c.Multihash)
+	size, m := binary.Uvarint(c.Multihash[n:])
+	digest := c.Multihash[n+m:]
+	if uint64(len(digest)) != size {
+		return errors.New("malformed multihash")
+	}
+	var sum []byte
+	switch code {
+	case HashIdentity:
+		sum = block
+	case HashSHA256:
+		s := sha256.Sum256(block)
+		sum = s[:]
+	case HashSHA512:
+		s := sha512.Sum512(block)
+		sum = s[:]
+	default:
+		return fmt.Errorf("unsupported multihash function 0x%x", code)
+	}
+	if !bytes.Equal(sum, digest) {
+		return fmt.Errorf("%w: %s", ErrDigestMismatch, c)
+	}
+	return nil
+}
+
+// decodeBase58 decodes a base58btc string.
+func decodeBase58(s string) ([]byte, error) {
+	n := new(big.Int)
+	radix := big.NewInt(58)
+	zeros := 0
+	for zeros < len(s) && s[zeros] == '1' {
+		zeros++
+	}
+	for _, r := range s {
+		i := strings.IndexRune(base58Alphabet, r)
+		if i < 0 {
+			return nil, fmt.Errorf("invalid base58 character %q", r)
+		}
+		n.Mul(n, radix)
+		n.Add(n, big.NewInt(int64(i)))
+	}
+	return append(make([]byte, zeros), n.Bytes()...), nil
+}
+
+// encodeBase58 encodes data in base58btc.
+func encodeBase58(data []byte) string {
+	n := new(big.Int).SetBytes(data)
+	radix := big.NewInt(58)
+	mod := new(big.Int)
+	var out []byte
+	for n.Sign() > 0 {
+		n.DivMod(n, radix, mod)
+		out = append(out, base58Alphabet[mod.Int64()])
+	}
+	for _, b := range data {
+		if b != 0 {
+			break
+		}
+		out = append(out, '1')
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return string(out)
+}
@@ -0,0 +1,72 @@
+package ipfs
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// emptyDirectory is the block of the empty UnixFS directory, known by the
+// CIDs emptyDirectoryV0 and emptyDirectoryV1.
+var emptyDirectory = []byte{0x0a, 0x02, 0x08, 0x01}
+
+const (
+	emptyDirectoryV0 = "QmUNLLsPACCz1vLxQVkXqqLX5R1X345qqfHbsf67hvA3Nn"
+	emptyDirectoryV1 = "bafybeiczsscdsbs7ffqz55asqdf3smv6klcw3gofszvwlyarci47bgf354"
+)
+
+func TestParseCID(t *testing.T) {
+	v0, err := ParseCID(emptyDirectoryV0)
+	require.NoError(t, err)
+	assert.Equal(t, 0, v0.Version)
+	assert.Equal(t, uint64(CodecDagPB), v0.Codec)
+	assert.Equal(t, emptyDirectoryV0, v0.String())
+	assert.NoError(t, v0.Verify(emptyDirectory))
+
+	v1, err := ParseCID(emptyDirectoryV1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, v1.Version)
+	assert.Equal(t, uint64(CodecDagPB), v1.Codec)
+	assert.Equal(t, v0.Multihash, v1.Multihash)
+	assert.Equal(t, emptyDirectoryV1, v1.String())
+	assert.NoError(t, v1.Verify(emptyDirectory))
+
+	// The same CID in other multibase encodings
+	for _, s := range []string{"z" + encodeBase58(v1.Bytes()), "f" + hex.EncodeToString(v1.Bytes()), "B" + strings.ToUpper(base32Lower.EncodeToString(v1.Bytes()))} {
+		c, err := ParseCID(s)
+		require.NoError(t, err, s)
+		assert.Equal(t, v1, c, s)
+	}
+
+	decoded, err := DecodeCID(v0.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, v0, decoded)
+
+	for _, invalid := range []string{
+		"",
+		"Qm" + emptyDirectoryV0[2:45] + "0", // Not base58
+		"mAXASIA",                           // Unsupported multibase
+		"bafybeiczsscdsbs7ffqz55asqdf3smv6klcw3gofszvwlyarci47bgf35", // Truncated
+		"f0270", // Version 2
+	} {
+		_, err := ParseCID(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestCIDVerify(t *testing.T) {
+	hello := Sum(CodecRaw, []byte("hello world\n"))
+	assert.Equal(t, "bafkreifjjcie6lypi6ny7amxnfftagclbuxndqonfipmb64f2km2devei4", hello.String())
+	assert.NoError(t, hello.Verify([]byte("hello world\n")))
+	assert.ErrorIs(t, hello.Verify([]byte("hello world")), ErrDigestMismatch)
+
+	identity := CID{Version: 1, Codec: CodecRaw, Multihash: []byte{HashIdentity, 2, 'h', 'i'}}
+	assert.NoError(t, identity.Verify([]byte("hi")))
+	assert.ErrorIs(t, identity.Verify([]byte("ho")), ErrDigestMismatch)
+
+	unsupported := CID{Version: 1, Codec: CodecRaw, Multihash: []byte{0x1e, 1, 0}} // blake3
+	assert.ErrorContains(t, unsupported.Verify(nil), "unsupported multihash")
+}
@@ -0,0 +1,151 @@
+package ipfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// maxBlocks bounds the number of blocks ReadFile retrieves for one file.
+const maxBlocks = 1 << 16
+
+// ErrTooLarge indicates that a file is larger than the limit given to ReadFile.
+var ErrTooLarge = errors.New("IPFS file too large")
+
+// BlockGetter retrieves the block of a CID in its raw form. It need not
+// verify the block, ReadFile does.
+type BlockGetter func(ctx context.Context, c CID) ([]byte, error)
+
+// ParseURL splits an ipfs:// URL such as "ipfs://bafy.../lists/tsl.xml" into
+// its root CID and the path below it, which is empty for a file CID.
+func ParseURL(rawURL string) (CID, string, error) {
+	rest, ok := strings.CutPrefix(rawURL, "ipfs://")
+	if !ok {
+		return CID{}, "", fmt.Errorf("not an ipfs:// URL: %s", rawURL)
+	}
+	root, path, _ := strings.Cut(rest, "/")
+	c, err := ParseCID(root)
+	if err != nil {
+		return CID{}, "", err
+	}
+	return c, strings.Trim(path, "/"), nil
+}
+
+// GatewayBlockURL returns the URL of the raw block of c at an HTTP gateway
+// such as "https://ipfs.io", as specified for trustless gateways. The request
+// should carry "Accept: application/vnd.ipld.raw".
+func GatewayBlockURL(gateway string, c CID) string {
+	return strings.TrimRight(gateway, "/") + "/ipfs/" + url.PathEscape(c.String()) + "?format=raw"
+}
+
+// ReadFile returns the content of the UnixFS file at path below root, the
+// names of directory entries separated by slashes, or of root itself if path
+// is empty. Blocks are retrieved with get and verified against their CIDs;
+// a block that does not match fails with an error wrapping ErrDigestMismatch.
+// Files larger than maxSize bytes fail with ErrTooLarge. Sharded directories
+// are not supported.
+func ReadFile(ctx context.Context, get BlockGetter, root CID, path string, maxSize int) ([]byte, error) {
+	r := &fileReader{get: get, maxSize: maxSize}
+	c := root
+	if path != "" {
+		for _, name := range strings.Split(path, "/") {
+			node, err := r.node(ctx, c)
+			if err != nil {
+				return nil, err
+			}
+			if node.Type != TypeDirectory {
+				return nil, fmt.Errorf("%s is not a directory", c)
+			}
+			found := false
+			for _, link := range node.Links {
+				if link.Name == name {
+					c, found = link.CID, true
+					break
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf("%s not found in %s", name, c)
+			}
+		}
+	}
+	var content []byte
+	if err := r.read(ctx, c, &content); err != nil {
+		return nil, err
+	}
+	return content, nil
+}
+
+// fileReader reads the blocks of one file.
+type fileReader struct {
+	get     BlockGetter
+	maxSize int
+	blocks  int
+}
+
+// block retrieves and verifies the block of c.
+func (r *fileReader) block(ctx context.Context, c CID) ([]byte, error) {
+	if r.blocks++; r.blocks > maxBlocks {
+		return nil, fmt.Errorf("%w: more than %d blocks", ErrTooLarge, maxBlocks)
+	}
+	block, err := r.get(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.Verify(block); err != nil {
+		return nil, err
+	}
+	return block, nil
+}
+
+// node retrieves and decodes the dag-pb node of c.
+func (r *fileReader) node(ctx context.Context, c CID) (Node, error) {
+	if c.Codec != CodecDagPB {
+		return Node{}, fmt.Errorf("%s is not a dag-pb node", c)
+	}
+	block, err := r.block(ctx, c)
+	if err != nil {
+		return Node{}, err
+	}
+	node, err := DecodeNode(block)
+	if err != nil {
+		return Node{}, fmt.Errorf("%s: %w", c, err)
+	}
+	return node, nil
+}
+
+// read appends the content of the file block c and its children to content.
+func (r *fileReader) read(ctx context.Context, c CID, content *[]byte) error {
+	var data []byte
+	var links []Link
+	switch c.Codec {
+	case CodecRaw:
+		block, err := r.block(ctx, c)
+		if err != nil {
+			return err
+		}
+		data = block
+	case CodecDagPB:
+		node, err := r.node(ctx, c)
+		if err != nil {
+			return err
+		}
+		if node.Type != TypeFile && node.Type != TypeRaw {
+			return fmt.Errorf("%s is not a file", c)
+		}
+		data, links = node.Data, node.Links
+	default:
+		return fmt.Errorf("%s: unsupported codec 0x%x", c, c.Codec)
+	}
+	if len(*content)+len(data) > r.maxSize {
+		return fmt.Errorf("%w: more than %d bytes", ErrTooLarge, r.maxSize)
+	}
+	*content = append(*content, data...)
+	for _, link := range links {
+		if err := r.read(ctx, link.CID, content); err != nil {
+			return err
+		}
+	}
+	return nil
+}
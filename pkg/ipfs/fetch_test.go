@@ -0,0 +1,96 @@
+package ipfs
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockStore holds blocks by CID and serves them as a BlockGetter.
+type blockStore map[string][]byte
+
+func (s blockStore) put(c CID, block []byte) CID {
+	s[c.String()] = block
+	return c
+}
+
+func (s blockStore) get(_ context.Context, c CID) ([]byte, error) {
+	block, ok := s[c.String()]
+	if !ok {
+		return nil, errors.New("block not found")
+	}
+	return block, nil
+}
+
+// putFile stores content as a file of chunks of at most size bytes below a
+// dag-pb root, and returns the root.
+func (s blockStore) putFile(content []byte, size int) CID {
+	root := Node{Type: TypeFile}
+	for len(content) > 0 {
+		n := min(size, len(content))
+		chunk := s.put(Sum(CodecRaw, content[:n]), content[:n])
+		root.Links = append(root.Links, Link{CID: chunk, Size: uint64(n)})
+		content = content[n:]
+	}
+	block := EncodeNode(root)
+	return s.put(Sum(CodecDagPB, block), block)
+}
+
+func TestReadFile(t *testing.T) {
+	store := blockStore{}
+	content := []byte("<TrustServiceStatusList>...</TrustServiceStatusList>")
+	file := store.putFile(content, 10)
+	dirBlock := EncodeNode(Node{Type: TypeDirectory, Links: []Link{{CID: file, Name: "tsl.xml"}}})
+	dir := store.put(Sum(CodecDagPB, dirBlock), dirBlock)
+	ctx := context.Background()
+
+	data, err := ReadFile(ctx, store.get, file, "", 1024)
+	require.NoError(t, err)
+	assert.Equal(t, content, data)
+
+	data, err = ReadFile(ctx, store.get, dir, "tsl.xml", 1024)
+	require.NoError(t, err)
+	assert.Equal(t, content, data)
+
+	raw := store.put(Sum(CodecRaw, content), content)
+	data, err = ReadFile(ctx, store.get, raw, "", 1024)
+	require.NoError(t, err)
+	assert.Equal(t, content, data)
+
+	_, err = ReadFile(ctx, store.get, dir, "other.xml", 1024)
+	assert.ErrorContains(t, err, "not found")
+	_, err = ReadFile(ctx, store.get, dir, "", 1024)
+	assert.ErrorContains(t, err, "not a file")
+	_, err = ReadFile(ctx, store.get, file, "", 20)
+	assert.ErrorIs(t, err, ErrTooLarge)
+
+	// A block that does not match its CID is detected
+	for key, block := range store {
+		if string(block) == string(content[10:20]) {
+			store[key] = []byte("<injected>")
+		}
+	}
+	_, err = ReadFile(ctx, store.get, file, "", 1024)
+	assert.ErrorIs(t, err, ErrDigestMismatch)
+}
+
+func TestParseURL(t *testing.T) {
+	root, path, err := ParseURL("ipfs://" + emptyDirectoryV1 + "/lists/tsl.xml")
+	require.NoError(t, err)
+	assert.Equal(t, emptyDirectoryV1, root.String())
+	assert.Equal(t, "lists/tsl.xml", path)
+
+	_, path, err = ParseURL("ipfs://" + emptyDirectoryV0)
+	require.NoError(t, err)
+	assert.Empty(t, path)
+
+	_, _, err = ParseURL("https://ipfs.io/ipfs/" + emptyDirectoryV0)
+	assert.Error(t, err)
+	_, _, err = ParseURL("ipfs://not-a-cid")
+	assert.Error(t, err)
+
+	assert.Equal(t, "https://ipfs.io/ipfs/"+emptyDirectoryV1+"?format=raw", GatewayBlockURL("https://ipfs.io/", root))
+}
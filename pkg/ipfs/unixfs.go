@@ -0,0 +1,172 @@
+package ipfs
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// UnixFS data types of a Node.
+const (
+	TypeRaw       = 0
+	TypeDirectory = 1
+	TypeFile      = 2
+	TypeMetadata  = 3
+	TypeSymlink   = 4
+	TypeHAMTShard = 5
+)
+
+// Link is a link of a dag-pb node to another block.
+type Link struct {
+	CID  CID
+	Name string // Name of the entry in a directory, empty in files
+	Size uint64 // Cumulative size of the linked blocks
+}
+
+// Node is a decoded dag-pb node holding UnixFS data: a file, whose content is
+// Data followed by the content of the linked blocks in order, or a directory,
+// whose entries are the links.
+type Node struct {
+	Type  int    // TypeFile, TypeDirectory, ...
+	Data  []byte // Content of a file held in the node itself
+	Links []Link
+}
+
+// DecodeNode decodes a dag-pb block with UnixFS data. Unknown fields are
+// skipped, as protocol buffers require.
+func DecodeNode(block []byte) (Node, error) {
+	var node Node
+	var unixfs []byte
+	hasData := false
+	err := protoFields(block, func(field int, value []byte, _ uint64) error {
+		switch field {
+		case 1:
+			unixfs, hasData = value, true
+		case 2:
+			link, err := decodeLink(value)
+			if err != nil {
+				return err
+			}
+			node.Links = append(node.Links, link)
+		}
+		return nil
+	})
+	if err != nil {
+		return Node{}, fmt.Errorf("invalid dag-pb node: %w", err)
+	}
+	if !hasData {
+		return Node{}, errors.New("invalid dag-pb node: no UnixFS data")
+	}
+	err = protoFields(unixfs, func(field int, value []byte, number uint64) error {
+		switch field {
+		case 1:
+			node.Type = int(number)
+		case 2:
+			node.Data = value
+		}
+		return nil
+	})
+	if err != nil {
+		return Node{}, fmt.Errorf("invalid UnixFS data: %w", err)
+	}
+	return node, nil
+}
+
+// EncodeNode encodes a node as a dag-pb block, the inverse of DecodeNode.
+// Links are encoded before the data, as the dag-pb specification requires.
+func EncodeNode(node Node) []byte {
+	var block []byte
+	for _, link := range node.Links {
+		var pbLink []byte
+		pbLink = appendBytesField(pbLink, 1, link.CID.Bytes())
+		pbLink = appendBytesField(pbLink, 2, []byte(link.Name))
+		pbLink = appendVarintField(pbLink, 3, link.Size)
+		block = appendBytesField(block, 2, pbLink)
+	}
+	unixfs := appendVarintField(nil, 1, uint64(node.Type))
+	if node.Data != nil {
+		unixfs = appendBytesField(unixfs, 2, node.Data)
+	}
+	return appendBytesField(block, 1, unixfs)
+}
+
+func appendVarintField(data []byte, field int, value uint64) []byte {
+	data = binary.AppendUvarint(data, uint64(field)<<3)
+	return binary.AppendUvarint(data, value)
+}
+
+func appendBytesField(data []byte, field int, value []byte) []byte {
+	data = binary.AppendUvarint(data, uint64(field)<<3|2)
+	data = binary.AppendUvarint(data, uint64(len(value)))
+	return append(data, value...)
+}
+
+// decodeLink decodes a PBLink message.
+func decodeLink(data []byte) (Link, error) {
+	var link Link
+	var hash []byte
+	err := protoFields(data, func(field int, value []byte, number uint64) error {
+		switch field {
+		case 1:
+			hash = value
+		case 2:
+			link.Name = string(value)
+		case 3:
+			link.Size = number
+		}
+		return nil
+	})
+	if err != nil {
+		return Link{}, err
+	}
+	if link.CID, err = DecodeCID(hash); err != nil {
+		return Link{}, fmt.Errorf("invalid link: %w", err)
+	}
+	return link, nil
+}
+
+// protoFields calls fn for each field of a protocol buffers message with its
+// number and, depending on its wire type, its bytes or its varint value.
+// Fixed size fields are passed as bytes.
+func protoFields(data []byte, fn func(field int, value []byte, number uint64) error) error {
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return errors.New("truncated field key")
+		}
+		data = data[n:]
+		field := int(key >> 3)
+		var value []byte
+		var number uint64
+		switch key & 7 {
+		case 0: // varint
+			number, n = binary.Uvarint(data)
+			if n <= 0 {
+				return errors.New("truncated varint")
+			}
+			data = data[n:]
+		case 1: // 64-bit
+			if len(data) < 8 {
+				return errors.New("truncated fixed64")
+			}
+			value, data = data[:8], data[8:]
+		case 2: // length-delimited
+			length, n := binary.Uvarint(data)
+			if n <= 0 || uint64(len(data)-n) < length {
+				return errors.New("truncated length-delimited field")
+			}
+			value, data = data[n:n+int(length)], data[n+int(length):]
+		case 5: // 32-bit
+			if len(data) < 4 {
+				return errors.New("truncated fixed32")
+			}
+			value, data = data[:4], data[4:]
+		default:
+			return fmt.Errorf("unsupported wire type %d", key&7)
+		}
+		if err := fn(field, value, number); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package ipfs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeNode(t *testing.T) {
+	node, err := DecodeNode(emptyDirectory)
+	require.NoError(t, err)
+	assert.Equal(t, TypeDirectory, node.Type)
+	assert.Empty(t, node.Links)
+
+	chunk := Sum(CodecRaw, []byte("chunk"))
+	file := Node{Type: TypeFile, Data: []byte("head"), Links: []Link{{CID: chunk, Size: 5}}}
+	node, err = DecodeNode(EncodeNode(file))
+	require.NoError(t, err)
+	assert.Equal(t, file, node)
+
+	dir := Node{Type: TypeDirectory, Links: []Link{{CID: chunk, Name: "tsl.xml", Size: 5}}}
+	node, err = DecodeNode(EncodeNode(dir))
+	require.NoError(t, err)
+	assert.Equal(t, dir, node)
+
+	for _, invalid := range [][]byte{
+		nil,                                  // No UnixFS data
+		{0x0a, 0x05, 0x08},                   // Truncated
+		{0x12, 0x02, 0x0a, 0x00, 0x0a, 0x00}, // Link without a CID
+		{0x0b},                               // Unsupported wire type
+	} {
+		_, err := DecodeNode(invalid)
+		assert.Error(t, err, "%x", invalid)
+	}
+}
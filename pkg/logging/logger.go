@@ -3,6 +3,8 @@ package logging
 
 import (
 	"context"
+	"fmt"
+	"strings"
 )
 
 // LogLevel represents the severity level of a log message.
@@ -21,6 +23,24 @@ const (
 	FatalLevel
 )
 
+// ParseLevel converts a level name (debug, info, warn or warning, error,
+// fatal; case-insensitive) to its LogLevel.
+func ParseLevel(name string) (LogLevel, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "debug":
+		return DebugLevel, nil
+	case "info":
+		return InfoLevel, nil
+	case "warn", "warning":
+		return WarnLevel, nil
+	case "error":
+		return ErrorLevel, nil
+	case "fatal":
+		return FatalLevel, nil
+	}
+	return InfoLevel, fmt.Errorf("unknown log level %q", name)
+}
+
 // Logger is the interface that provides structured logging methods.
 type Logger interface {
 	// Debug logs a message with debug level.
@@ -63,3 +83,81 @@ type OutputConfigurable interface {
 	// SetOutput sets the output for the logger.
 	SetOutput(out interface{})
 }
+
+// LevelDeriver is implemented by loggers that can derive a child logger with
+// its own level, leaving the level of the parent unchanged.
+type LevelDeriver interface {
+	// WithLevel returns a child logger with the fields of the parent that logs
+	// at level.
+	WithLevel(level LogLevel) Logger
+}
+
+// WithLevel returns a child of logger that logs at level without changing the
+// level of logger, for example to run one part of a program at debug level.
+// Loggers implementing LevelDeriver derive the child themselves; other loggers
+// are wrapped in a filter, which can make them quieter but not more verbose.
+func WithLevel(logger Logger, level LogLevel) Logger {
+	if deriver, ok := logger.(LevelDeriver); ok {
+		return deriver.WithLevel(level)
+	}
+	return &levelFilter{Logger: logger, level: level}
+}
+
+// levelFilter drops the messages below its level before passing them on.
+type levelFilter struct {
+	Logger
+	level LogLevel
+}
+
+// Debug logs a message with debug level.
+func (l *levelFilter) Debug(msg string, fields ...Field) {
+	if l.level <= DebugLevel {
+		l.Logger.Debug(msg, fields...)
+	}
+}
+
+// Info logs a message with info level.
+func (l *levelFilter) Info(msg string, fields ...Field) {
+	if l.level <= InfoLevel {
+		l.Logger.Info(msg, fields...)
+	}
+}
+
+// Warn logs a message with warn level.
+func (l *levelFilter) Warn(msg string, fields ...Field) {
+	if l.level <= WarnLevel {
+		l.Logger.Warn(msg, fields...)
+	}
+}
+
+// Error logs a message with error level.
+func (l *levelFilter) Error(msg string, fields ...Field) {
+	if l.level <= ErrorLevel {
+		l.Logger.Error(msg, fields...)
+	}
+}
+
+// WithContext returns a filtered logger with the given context.
+func (l *levelFilter) WithContext(ctx context.Context) Logger {
+	return &levelFilter{Logger: l.Logger.WithContext(ctx), level: l.level}
+}
+
+// WithField returns a filtered logger with an additional field.
+func (l *levelFilter) WithField(key string, value interface{}) Logger {
+	return &levelFilter{Logger: l.Logger.WithField(key, value), level: l.level}
+}
+
+// WithFields returns a filtered logger with additional fields.
+func (l *levelFilter) WithFields(fields ...Field) Logger {
+	return &levelFilter{Logger: l.Logger.WithFields(fields...), level: l.level}
+}
+
+// GetLevel returns the level of the filter.
+func (l *levelFilter) GetLevel() LogLevel {
+	return l.level
+}
+
+// SetLevel sets the level of the filter.
+func (l *levelFilter) SetLevel(level LogLevel) {
+	l.level = level
+}
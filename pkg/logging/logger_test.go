@@ -162,3 +162,59 @@ func TestFactoryMethods(t *testing.T) {
 		t.Errorf("Expected level %d, got %d", DebugLevel, got)
 	}
 }
+
+func TestParseLevel(t *testing.T) {
+	for name, expected := range map[string]LogLevel{
+		"debug": DebugLevel, "INFO": InfoLevel, "warn": WarnLevel, "warning": WarnLevel, "error": ErrorLevel, "fatal": FatalLevel,
+	} {
+		level, err := ParseLevel(name)
+		if err != nil || level != expected {
+			t.Errorf("ParseLevel(%q) = %v, %v; expected %v", name, level, err, expected)
+		}
+	}
+	if _, err := ParseLevel("verbose"); err == nil {
+		t.Error("Expected an error for an unknown level")
+	}
+}
+
+func TestWithLevel(t *testing.T) {
+	var buf bytes.Buffer
+	logrusLogger := logrus.New()
+	logrusLogger.SetOutput(&buf)
+	logrusLogger.SetFormatter(&logrus.TextFormatter{DisableTimestamp: true, DisableColors: true})
+	parent := NewLogrusAdapter(logrusLogger).WithField("pipeline", "test")
+	parent.SetLevel(InfoLevel)
+
+	child := WithLevel(parent, DebugLevel)
+	child.Debug("child debug")
+	parent.Debug("parent debug")
+	if !strings.Contains(buf.String(), "child debug") || !strings.Contains(buf.String(), "pipeline=test") {
+		t.Errorf("Expected the child to log at debug level with the parent fields, got: %s", buf.String())
+	}
+	if strings.Contains(buf.String(), "parent debug") {
+		t.Errorf("Expected the parent level to be unchanged, got: %s", buf.String())
+	}
+	if parent.GetLevel() != InfoLevel || child.GetLevel() != DebugLevel {
+		t.Errorf("Unexpected levels: parent %v, child %v", parent.GetLevel(), child.GetLevel())
+	}
+
+	buf.Reset()
+	quiet := WithLevel(parent, ErrorLevel)
+	quiet.Warn("quiet warning")
+	quiet.Error("quiet error")
+	if strings.Contains(buf.String(), "quiet warning") || !strings.Contains(buf.String(), "quiet error") {
+		t.Errorf("Expected only the error to be logged, got: %s", buf.String())
+	}
+
+	// Loggers that cannot derive a child are filtered
+	buf.Reset()
+	filtered := WithLevel(struct{ Logger }{parent}, WarnLevel)
+	filtered.Info("filtered info")
+	filtered.WithField("k", "v").Warn("filtered warning")
+	if strings.Contains(buf.String(), "filtered info") || !strings.Contains(buf.String(), "filtered warning") {
+		t.Errorf("Expected only the warning to be logged, got: %s", buf.String())
+	}
+	if filtered.GetLevel() != WarnLevel {
+		t.Errorf("Expected the filter level, got %v", filtered.GetLevel())
+	}
+}
@@ -88,19 +88,45 @@ func (l *LogrusAdapter) GetLevel() LogLevel {
 
 // SetLevel sets the logging level.
 func (l *LogrusAdapter) SetLevel(level LogLevel) {
+	l.logger.Logger.SetLevel(logrusLevel(level))
+}
+
+// WithLevel returns a child logger with the fields of l that logs at level.
+// It implements the LevelDeriver interface: the child writes to the output of
+// l with its formatter and hooks, but has a logrus logger of its own so that
+// the level of l is left unchanged.
+func (l *LogrusAdapter) WithLevel(level LogLevel) Logger {
+	parent := l.logger.Logger
+	child := &logrus.Logger{
+		Out:          parent.Out,
+		Hooks:        parent.Hooks,
+		Formatter:    parent.Formatter,
+		ReportCaller: parent.ReportCaller,
+		Level:        logrusLevel(level),
+		ExitFunc:     parent.ExitFunc,
+	}
+	entry := logrus.NewEntry(child).WithFields(l.logger.Data)
+	if l.logger.Context != nil {
+		entry = entry.WithContext(l.logger.Context)
+	}
+	return &LogrusAdapter{logger: entry}
+}
+
+// logrusLevel converts a LogLevel to the logrus level.
+func logrusLevel(level LogLevel) logrus.Level {
 	switch level {
 	case DebugLevel:
-		l.logger.Logger.SetLevel(logrus.DebugLevel)
+		return logrus.DebugLevel
 	case InfoLevel:
-		l.logger.Logger.SetLevel(logrus.InfoLevel)
+		return logrus.InfoLevel
 	case WarnLevel:
-		l.logger.Logger.SetLevel(logrus.WarnLevel)
+		return logrus.WarnLevel
 	case ErrorLevel:
-		l.logger.Logger.SetLevel(logrus.ErrorLevel)
+		return logrus.ErrorLevel
 	case FatalLevel:
-		l.logger.Logger.SetLevel(logrus.FatalLevel)
+		return logrus.FatalLevel
 	default:
-		l.logger.Logger.SetLevel(logrus.InfoLevel)
+		return logrus.InfoLevel
 	}
 }
 
@@ -0,0 +1,279 @@
+package oci
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// maxErrorBody limits how much of an error response is read for its message.
+const maxErrorBody = 64 * 1024
+
+// Client pushes artifacts to registries implementing the OCI distribution
+// specification. It authenticates with HTTP basic authentication or, when the
+// registry asks for it, with a bearer token obtained from the registry's token
+// service using the same credentials. The zero value pushes anonymously over
+// HTTPS with http.DefaultClient. A Client is not safe for concurrent use.
+type Client struct {
+	HTTPClient *http.Client // Client for all requests, http.DefaultClient if nil
+	Username   string       // User name for the registry, empty for anonymous access
+	Password   string       // Password or access token of Username
+	PlainHTTP  bool         // Use HTTP instead of HTTPS, for local registries
+
+	authorization string // Authorization header granted by the last challenge
+}
+
+// Push uploads the layers of artifact, skipping those the repository already
+// holds, and tags a manifest listing them as ref.Tag. It returns the
+// descriptor of the pushed manifest.
+func (c *Client) Push(ctx context.Context, ref Reference, artifact Artifact) (Descriptor, error) {
+	if len(artifact.Layers) == 0 {
+		return Descriptor{}, errors.New("artifact has no layers")
+	}
+	config := Descriptor{MediaType: MediaTypeEmptyJSON, Digest: Digest(emptyJSON), Size: int64(len(emptyJSON)), Data: emptyJSON}
+	if err := c.pushBlob(ctx, ref, emptyJSON, config.Digest); err != nil {
+		return Descriptor{}, err
+	}
+	manifest := Manifest{
+		SchemaVersion: 2,
+		MediaType:     MediaTypeImageManifest,
+		ArtifactType:  artifact.ArtifactType,
+		Config:        config,
+		Annotations:   artifact.Annotations,
+	}
+	for _, layer := range artifact.Layers {
+		desc := Descriptor{MediaType: layer.MediaType, Digest: Digest(layer.Data), Size: int64(len(layer.Data))}
+		if len(layer.Annotations) > 0 || layer.Title != "" {
+			desc.Annotations = maps.Clone(layer.Annotations)
+			if desc.Annotations == nil {
+				desc.Annotations = make(map[string]string)
+			}
+			if layer.Title != "" {
+				desc.Annotations[AnnotationTitle] = layer.Title
+			}
+		}
+		if err := c.pushBlob(ctx, ref, layer.Data, desc.Digest); err != nil {
+			return Descriptor{}, err
+		}
+		manifest.Layers = append(manifest.Layers, desc)
+	}
+
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return Descriptor{}, err
+	}
+	resp, err := c.do(ctx, ref, http.MethodPut, c.endpoint(ref, "manifests/"+ref.Tag), MediaTypeImageManifest, data)
+	if err != nil {
+		return Descriptor{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return Descriptor{}, responseError(resp)
+	}
+	return Descriptor{MediaType: MediaTypeImageManifest, Digest: Digest(data), Size: int64(len(data))}, nil
+}
+
+// pushBlob uploads data with the given digest unless the repository holds it.
+func (c *Client) pushBlob(ctx context.Context, ref Reference, data []byte, digest string) error {
+	resp, err := c.do(ctx, ref, http.MethodHead, c.endpoint(ref, "blobs/"+digest), "", nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	resp, err = c.do(ctx, ref, http.MethodPost, c.endpoint(ref, "blobs/uploads/"), "", nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		return responseError(resp)
+	}
+	location, err := resp.Request.URL.Parse(resp.Header.Get("Location"))
+	if err != nil || resp.Header.Get("Location") == "" {
+		return fmt.Errorf("POST %s: missing or invalid upload location", resp.Request.URL)
+	}
+	query := location.Query()
+	query.Set("digest", digest)
+	location.RawQuery = query.Encode()
+
+	resp, err = c.do(ctx, ref, http.MethodPut, location.String(), "application/octet-stream", data)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return responseError(resp)
+	}
+	return nil
+}
+
+// endpoint returns the URL of path below the repository of ref.
+func (c *Client) endpoint(ref Reference, path string) string {
+	scheme := "https"
+	if c.PlainHTTP {
+		scheme = "http"
+	}
+	return scheme + "://" + ref.Registry + "/v2/" + ref.Repository + "/" + path
+}
+
+// do sends a request, answering an authentication challenge of the registry
+// once. The caller closes the body of the response.
+func (c *Client) do(ctx context.Context, ref Reference, method, target, contentType string, body []byte) (*http.Response, error) {
+	send := func() (*http.Response, error) {
+		req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		if c.authorization != "" {
+			req.Header.Set("Authorization", c.authorization)
+		}
+		return c.httpClient().Do(req)
+	}
+	resp, err := send()
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	challenge := resp.Header.Get("WWW-Authenticate")
+	resp.Body.Close()
+	if err := c.authorize(ctx, ref, challenge); err != nil {
+		return nil, fmt.Errorf("%s %s: %w", method, target, err)
+	}
+	return send()
+}
+
+// authorize sets the Authorization header answering a WWW-Authenticate challenge.
+func (c *Client) authorize(ctx context.Context, ref Reference, challenge string) error {
+	scheme, params := parseChallenge(challenge)
+	switch strings.ToLower(scheme) {
+	case "basic":
+		if c.Username == "" {
+			return errors.New("registry requires credentials")
+		}
+		c.authorization = "Basic " + base64.StdEncoding.EncodeToString([]byte(c.Username+":"+c.Password))
+		return nil
+	case "bearer":
+		token, err := c.fetchToken(ctx, ref, params)
+		if err != nil {
+			return err
+		}
+		c.authorization = "Bearer " + token
+		return nil
+	}
+	return fmt.Errorf("unsupported authentication challenge %q", challenge)
+}
+
+// fetchToken obtains a bearer token for pushing to the repository of ref from
+// the token service named by the params of a bearer challenge.
+func (c *Client) fetchToken(ctx context.Context, ref Reference, params map[string]string) (string, error) {
+	realm, err := url.Parse(params["realm"])
+	if err != nil || realm.Host == "" {
+		return "", fmt.Errorf("invalid token realm %q", params["realm"])
+	}
+	query := realm.Query()
+	if service := params["service"]; service != "" {
+		query.Set("service", service)
+	}
+	scope := params["scope"]
+	if scope == "" {
+		scope = "repository:" + ref.Repository + ":pull,push"
+	}
+	query.Set("scope", scope)
+	realm.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	if c.Username != "" {
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to obtain token: %w", responseError(resp))
+	}
+	var answer struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxErrorBody)).Decode(&answer); err != nil {
+		return "", fmt.Errorf("failed to obtain token: %w", err)
+	}
+	if answer.Token != "" {
+		return answer.Token, nil
+	}
+	if answer.AccessToken != "" {
+		return answer.AccessToken, nil
+	}
+	return "", errors.New("failed to obtain token: empty token")
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+// parseChallenge splits a WWW-Authenticate header into its scheme and
+// parameters, e.g. `Bearer realm="https://auth.example.com/token",service="registry"`.
+func parseChallenge(header string) (string, map[string]string) {
+	scheme, rest, _ := strings.Cut(strings.TrimSpace(header), " ")
+	params := make(map[string]string)
+	for rest = strings.TrimSpace(rest); rest != ""; {
+		key, value, ok := strings.Cut(rest, "=")
+		if !ok {
+			break
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		if strings.HasPrefix(value, `"`) {
+			end := strings.Index(value[1:], `"`)
+			if end < 0 {
+				params[key] = value[1:]
+				break
+			}
+			params[key], rest = value[1:end+1], value[end+2:]
+		} else {
+			params[key], rest, _ = strings.Cut(value, ",")
+		}
+		rest = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(rest), ","))
+	}
+	return scheme, params
+}
+
+// responseError describes an unexpected response, with the errors reported
+// in its body by registries following the distribution specification.
+func responseError(resp *http.Response) error {
+	var body struct {
+		Errors []struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	msg := fmt.Sprintf("%s %s: unexpected status %s", resp.Request.Method, resp.Request.URL, resp.Status)
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	if json.Unmarshal(data, &body) == nil {
+		for _, e := range body.Errors {
+			msg += fmt.Sprintf(": %s: %s", e.Code, e.Message)
+		}
+	}
+	return errors.New(msg)
+}
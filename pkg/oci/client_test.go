@@ -0,0 +1,178 @@
+package oci
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testRegistry is an in-memory registry implementing the push endpoints of
+// the OCI distribution specification. With a token set it requires a bearer
+// token, handed out by its /token endpoint for the credentials user:secret.
+type testRegistry struct {
+	mu        sync.Mutex
+	server    *httptest.Server
+	token     string
+	blobs     map[string][]byte
+	manifests map[string][]byte
+	uploads   int // Blobs uploaded, not counting those already present
+}
+
+func newTestRegistry(t *testing.T, token string) *testRegistry {
+	r := &testRegistry{token: token, blobs: make(map[string][]byte), manifests: make(map[string][]byte)}
+	r.server = httptest.NewServer(http.HandlerFunc(r.serveHTTP))
+	t.Cleanup(r.server.Close)
+	return r
+}
+
+// reference returns a reference to repository in the registry.
+func (r *testRegistry) reference(t *testing.T, repository string) Reference {
+	ref, err := ParseReference(strings.TrimPrefix(r.server.URL, "http://") + "/" + repository)
+	require.NoError(t, err)
+	return ref
+}
+
+func (r *testRegistry) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if req.URL.Path == "/token" {
+		if user, password, ok := req.BasicAuth(); !ok || user != "user" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{"access_token": r.token})
+		return
+	}
+	if r.token != "" && req.Header.Get("Authorization") != "Bearer "+r.token {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="`+r.server.URL+`/token",service="test",scope="repository:trust/pool:pull,push"`)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	path := strings.TrimPrefix(req.URL.Path, "/v2/trust/pool/")
+	switch {
+	case req.Method == http.MethodHead && strings.HasPrefix(path, "blobs/"):
+		if _, ok := r.blobs[strings.TrimPrefix(path, "blobs/")]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	case req.Method == http.MethodPost && path == "blobs/uploads/":
+		w.Header().Set("Location", "/v2/trust/pool/blobs/uploads/1?state=x")
+		w.WriteHeader(http.StatusAccepted)
+	case req.Method == http.MethodPut && strings.HasPrefix(path, "blobs/uploads/"):
+		data, _ := io.ReadAll(req.Body)
+		digest := req.URL.Query().Get("digest")
+		if req.URL.Query().Get("state") != "x" || Digest(data) != digest {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"errors":[{"code":"DIGEST_INVALID","message":"digest mismatch"}]}`))
+			return
+		}
+		r.blobs[digest] = data
+		r.uploads++
+		w.WriteHeader(http.StatusCreated)
+	case req.Method == http.MethodPut && strings.HasPrefix(path, "manifests/"):
+		var manifest Manifest
+		data, _ := io.ReadAll(req.Body)
+		if req.Header.Get("Content-Type") != MediaTypeImageManifest || json.Unmarshal(data, &manifest) != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		for _, desc := range append(manifest.Layers, manifest.Config) {
+			if _, ok := r.blobs[desc.Digest]; !ok {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(`{"errors":[{"code":"MANIFEST_BLOB_UNKNOWN","message":"blob unknown"}]}`))
+				return
+			}
+		}
+		r.manifests[strings.TrimPrefix(path, "manifests/")] = data
+		w.WriteHeader(http.StatusCreated)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestClientPush(t *testing.T) {
+	registry := newTestRegistry(t, "t0ken")
+	ref := registry.reference(t, "trust/pool:v1")
+	artifact := Artifact{
+		ArtifactType: "application/vnd.example.bundle.v1",
+		Layers: []Layer{
+			{MediaType: "application/x-pem-file", Title: "pool.pem", Data: []byte("pem")},
+			{MediaType: "application/xml", Data: []byte("<xml/>"), Annotations: map[string]string{"source": "https://example.com/tsl.xml"}},
+		},
+		Annotations: map[string]string{AnnotationCreated: "2025-01-01T00:00:00Z"},
+	}
+
+	client := &Client{Username: "user", Password: "secret", PlainHTTP: true}
+	desc, err := client.Push(context.Background(), ref, artifact)
+	require.NoError(t, err)
+	assert.Equal(t, MediaTypeImageManifest, desc.MediaType)
+	assert.Equal(t, 3, registry.uploads, "the config and both layers")
+
+	data := registry.manifests["v1"]
+	require.NotNil(t, data)
+	assert.Equal(t, Digest(data), desc.Digest)
+	var manifest Manifest
+	require.NoError(t, json.Unmarshal(data, &manifest))
+	assert.Equal(t, 2, manifest.SchemaVersion)
+	assert.Equal(t, "application/vnd.example.bundle.v1", manifest.ArtifactType)
+	assert.Equal(t, MediaTypeEmptyJSON, manifest.Config.MediaType)
+	assert.Equal(t, "2025-01-01T00:00:00Z", manifest.Annotations[AnnotationCreated])
+	require.Len(t, manifest.Layers, 2)
+	assert.Equal(t, "pool.pem", manifest.Layers[0].Annotations[AnnotationTitle])
+	assert.Equal(t, Digest([]byte("pem")), manifest.Layers[0].Digest)
+	assert.Equal(t, int64(6), manifest.Layers[1].Size)
+	assert.Equal(t, "https://example.com/tsl.xml", manifest.Layers[1].Annotations["source"])
+	assert.NotContains(t, artifact.Layers[1].Annotations, AnnotationTitle, "the layer annotations are not changed")
+
+	// Blobs the repository holds are not uploaded again
+	artifact.Layers[0].Data = []byte("new pem")
+	ref.Tag = "v2"
+	_, err = client.Push(context.Background(), ref, artifact)
+	require.NoError(t, err)
+	assert.Equal(t, 4, registry.uploads)
+	assert.NotNil(t, registry.manifests["v2"])
+}
+
+func TestClientPush_Errors(t *testing.T) {
+	registry := newTestRegistry(t, "t0ken")
+	ref := registry.reference(t, "trust/pool")
+	artifact := Artifact{Layers: []Layer{{MediaType: "text/plain", Data: []byte("data")}}}
+
+	_, err := (&Client{PlainHTTP: true}).Push(context.Background(), ref, artifact)
+	assert.ErrorContains(t, err, "failed to obtain token")
+	_, err = (&Client{Username: "user", Password: "wrong", PlainHTTP: true}).Push(context.Background(), ref, artifact)
+	assert.ErrorContains(t, err, "401")
+
+	_, err = (&Client{PlainHTTP: true}).Push(context.Background(), ref, Artifact{})
+	assert.ErrorContains(t, err, "no layers")
+
+	// Unexpected responses fail the push
+	registry.token = ""
+	other := registry.reference(t, "trust/other")
+	_, err = (&Client{PlainHTTP: true}).Push(context.Background(), other, artifact)
+	assert.ErrorContains(t, err, "404")
+	assert.Empty(t, registry.manifests)
+}
+
+func TestParseChallenge(t *testing.T) {
+	scheme, params := parseChallenge(`Bearer realm="https://auth.example.com/token",service="registry.example.com",scope="repository:a/b:pull,push"`)
+	assert.Equal(t, "Bearer", scheme)
+	assert.Equal(t, map[string]string{
+		"realm":   "https://auth.example.com/token",
+		"service": "registry.example.com",
+		"scope":   "repository:a/b:pull,push",
+	}, params)
+
+	scheme, params = parseChallenge(`Basic realm=registry, charset="UTF-8"`)
+	assert.Equal(t, "Basic", scheme)
+	assert.Equal(t, "registry", params["realm"])
+	assert.Equal(t, "UTF-8", params["charset"])
+}
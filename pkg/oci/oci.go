@@ -0,0 +1,149 @@
+// Package oci distributes trust bundles as OCI artifacts, so that clusters can
+// pull the certificate pool and the trusted lists it was selected from through
+// the container registries they already use.
+//
+// An Artifact is a set of layers, each holding one file, with annotations. Push
+// uploads it to a registry implementing the OCI distribution specification as
+// an image manifest with an artifactType and the empty config, the layout
+// written by ORAS, so "oras pull" retrieves the files under their titles.
+package oci
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Media types used in manifests pushed by this package.
+const (
+	MediaTypeImageManifest = "application/vnd.oci.image.manifest.v1+json"
+	MediaTypeEmptyJSON     = "application/vnd.oci.empty.v1+json"
+)
+
+// Annotation keys predefined by the OCI image specification.
+const (
+	// AnnotationTitle is the file name of a layer, used by ORAS when pulling.
+	AnnotationTitle = "org.opencontainers.image.title"
+	// AnnotationCreated is the RFC 3339 time the artifact was created.
+	AnnotationCreated = "org.opencontainers.image.created"
+)
+
+// emptyJSON is the content of the empty config descriptor.
+var emptyJSON = []byte("{}")
+
+// Descriptor references content stored in a registry.
+type Descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Data        []byte            `json:"data,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// Manifest is an OCI image manifest.
+type Manifest struct {
+	SchemaVersion int               `json:"schemaVersion"`
+	MediaType     string            `json:"mediaType"`
+	ArtifactType  string            `json:"artifactType,omitempty"`
+	Config        Descriptor        `json:"config"`
+	Layers        []Descriptor      `json:"layers"`
+	Annotations   map[string]string `json:"annotations,omitempty"`
+}
+
+// Layer is a file of an Artifact.
+type Layer struct {
+	MediaType   string
+	Title       string            // File name, recorded as AnnotationTitle
+	Data        []byte            // Content of the file
+	Annotations map[string]string // Further annotations of the layer
+}
+
+// Artifact is a set of files pushed as one manifest.
+type Artifact struct {
+	ArtifactType string
+	Layers       []Layer
+	Annotations  map[string]string // Annotations of the manifest
+}
+
+// Digest returns the OCI digest ("sha256:<hex>") of data.
+func Digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// Reference names a manifest in a registry, e.g.
+// "registry.example.com/trust/eu-pool:latest".
+type Reference struct {
+	Registry   string // Host name of the registry, optionally with port
+	Repository string // Repository within the registry
+	Tag        string // Tag of the manifest
+}
+
+// ParseReference parses "registry/repository[:tag]". The registry must be
+// given explicitly, as a host name containing a dot or a port, or as
+// localhost; the tag defaults to "latest". Digest references are not
+// supported, since pushed manifests are tagged.
+func ParseReference(s string) (Reference, error) {
+	registry, rest, ok := strings.Cut(s, "/")
+	if !ok || rest == "" {
+		return Reference{}, fmt.Errorf("invalid reference %q: expected registry/repository[:tag]", s)
+	}
+	if !strings.ContainsAny(registry, ".:") && registry != "localhost" {
+		return Reference{}, fmt.Errorf("invalid reference %q: %q is not a registry host", s, registry)
+	}
+	if strings.Contains(rest, "@") {
+		return Reference{}, fmt.Errorf("invalid reference %q: digest references are not supported", s)
+	}
+	ref := Reference{Registry: registry, Repository: rest, Tag: "latest"}
+	if i := strings.LastIndex(rest, ":"); i >= 0 {
+		ref.Repository, ref.Tag = rest[:i], rest[i+1:]
+	}
+	if !validRepository(ref.Repository) {
+		return Reference{}, fmt.Errorf("invalid reference %q: invalid repository %q", s, ref.Repository)
+	}
+	if !validTag(ref.Tag) {
+		return Reference{}, fmt.Errorf("invalid reference %q: invalid tag %q", s, ref.Tag)
+	}
+	return ref, nil
+}
+
+// String returns the reference in the form accepted by ParseReference.
+func (r Reference) String() string {
+	return r.Registry + "/" + r.Repository + ":" + r.Tag
+}
+
+// validRepository reports whether name is a repository name of the OCI
+// distribution specification: lowercase alphanumeric path components
+// separated by "/", each possibly containing ".", "_", "__" or "-" separators.
+func validRepository(name string) bool {
+	for _, component := range strings.Split(name, "/") {
+		if component == "" || !isLowerAlnum(component[0]) || !isLowerAlnum(component[len(component)-1]) {
+			return false
+		}
+		for i := 0; i < len(component); i++ {
+			if c := component[i]; !isLowerAlnum(c) && c != '.' && c != '_' && c != '-' {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// validTag reports whether tag is a tag of the OCI distribution specification.
+func validTag(tag string) bool {
+	if tag == "" || len(tag) > 128 || tag[0] == '.' || tag[0] == '-' {
+		return false
+	}
+	for i := 0; i < len(tag); i++ {
+		c := tag[i]
+		if !isLowerAlnum(c) && !(c >= 'A' && c <= 'Z') && c != '.' && c != '_' && c != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+func isLowerAlnum(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= '0' && c <= '9'
+}
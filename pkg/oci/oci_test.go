@@ -0,0 +1,48 @@
+package oci
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseReference(t *testing.T) {
+	tests := []struct {
+		ref  string
+		want Reference
+	}{
+		{"registry.example.com/trust/eu-pool:2025-01", Reference{"registry.example.com", "trust/eu-pool", "2025-01"}},
+		{"localhost:5000/pool", Reference{"localhost:5000", "pool", "latest"}},
+		{"localhost/a.b_c__d-e/f:V1.0", Reference{"localhost", "a.b_c__d-e/f", "V1.0"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			ref, err := ParseReference(tt.ref)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, ref)
+		})
+	}
+	ref, err := ParseReference("localhost:5000/pool")
+	require.NoError(t, err)
+	assert.Equal(t, "localhost:5000/pool:latest", ref.String())
+
+	for _, invalid := range []string{
+		"pool",
+		"trust/pool:v1",                   // No registry host
+		"registry.example.com/",           // No repository
+		"registry.example.com/Trust/pool", // Uppercase repository
+		"registry.example.com/trust//pool",
+		"registry.example.com/-pool",
+		"registry.example.com/pool:",
+		"registry.example.com/pool:-v1",
+		"registry.example.com/pool@sha256:abcd",
+	} {
+		_, err := ParseReference(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestDigest(t *testing.T) {
+	assert.Equal(t, "sha256:44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a", Digest(emptyJSON))
+}
@@ -0,0 +1,187 @@
+package oidfed
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/asn1"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"math/big"
+	"strings"
+)
+
+// ErrInvalidSignature is returned when a JWT does not verify with any of the
+// given keys.
+var ErrInvalidSignature = errors.New("invalid JWT signature")
+
+// Header is the protected header of a signed JWT.
+type Header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid,omitempty"`
+	Typ string `json:"typ,omitempty"`
+}
+
+// Algorithm returns the JWS algorithm used for a key: RS256 for RSA, ES256,
+// ES384 or ES512 for ECDSA depending on the curve, and EdDSA for Ed25519.
+func Algorithm(key crypto.PublicKey) (string, error) {
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		return "RS256", nil
+	case *ecdsa.PublicKey:
+		switch key.Curve.Params().BitSize {
+		case 256:
+			return "ES256", nil
+		case 384:
+			return "ES384", nil
+		case 521:
+			return "ES512", nil
+		}
+		return "", fmt.Errorf("unsupported elliptic curve %s", key.Curve.Params().Name)
+	case ed25519.PublicKey:
+		return "EdDSA", nil
+	}
+	return "", fmt.Errorf("unsupported key type %T", key)
+}
+
+// hashFor returns the hash of a JWS algorithm, nil for EdDSA.
+func hashFor(alg string) (crypto.Hash, hash.Hash) {
+	switch alg {
+	case "RS256", "ES256":
+		return crypto.SHA256, sha256.New()
+	case "ES384":
+		return crypto.SHA384, sha512.New384()
+	case "ES512":
+		return crypto.SHA512, sha512.New()
+	}
+	return 0, nil
+}
+
+// Sign encodes claims as a JWT in compact serialization, signed with key.
+//
+// Parameters:
+//   - claims: The payload, marshalled with encoding/json
+//   - key: The signing key, RSA, ECDSA or Ed25519
+//   - typ: The typ header, e.g. TypeEntityStatement
+//   - kid: The kid header, usually the JWK thumbprint of the key
+//
+// Returns:
+//   - The signed JWT
+//   - An error if the key is not supported or signing fails
+func Sign(claims any, key crypto.Signer, typ, kid string) (string, error) {
+	alg, err := Algorithm(key.Public())
+	if err != nil {
+		return "", err
+	}
+	header, err := json.Marshal(Header{Alg: alg, Kid: kid, Typ: typ})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode JWT claims: %w", err)
+	}
+	signingInput := b64(header) + "." + b64(payload)
+
+	var signature []byte
+	if hashFunc, h := hashFor(alg); h != nil {
+		h.Write([]byte(signingInput))
+		signature, err = key.Sign(rand.Reader, h.Sum(nil), hashFunc)
+		if err == nil && strings.HasPrefix(alg, "ES") {
+			signature, err = ecdsaRawSignature(signature, key.Public().(*ecdsa.PublicKey))
+		}
+	} else {
+		signature, err = key.Sign(rand.Reader, []byte(signingInput), crypto.Hash(0))
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to sign JWT: %w", err)
+	}
+	return signingInput + "." + b64(signature), nil
+}
+
+// ecdsaRawSignature converts an ASN.1 ECDSA signature to the fixed size R || S
+// form of JWS.
+func ecdsaRawSignature(der []byte, key *ecdsa.PublicKey) ([]byte, error) {
+	var sig struct{ R, S *big.Int }
+	if _, err := asn1.Unmarshal(der, &sig); err != nil {
+		return nil, err
+	}
+	size := (key.Curve.Params().BitSize + 7) / 8
+	raw := make([]byte, 2*size)
+	sig.R.FillBytes(raw[:size])
+	sig.S.FillBytes(raw[size:])
+	return raw, nil
+}
+
+// Verify checks the signature of a JWT with the key of keys named by its kid,
+// or with every key if it has none, and returns its header and payload. The
+// alg header must match the type of the key.
+func Verify(token string, keys JWKSet) (Header, []byte, error) {
+	var header Header
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return header, nil, fmt.Errorf("malformed JWT: expected 3 parts, got %d", len(parts))
+	}
+	headerJSON, err := unb64(parts[0])
+	if err != nil {
+		return header, nil, fmt.Errorf("malformed JWT header: %w", err)
+	}
+	if err := json.Unmarshal(headerJSON, &header); err != nil {
+		return header, nil, fmt.Errorf("malformed JWT header: %w", err)
+	}
+	payload, err := unb64(parts[1])
+	if err != nil {
+		return header, nil, fmt.Errorf("malformed JWT payload: %w", err)
+	}
+	signature, err := unb64(parts[2])
+	if err != nil {
+		return header, nil, fmt.Errorf("malformed JWT signature: %w", err)
+	}
+	signingInput := []byte(parts[0] + "." + parts[1])
+
+	for _, jwk := range keys.Keys {
+		if header.Kid != "" && jwk.Kid != "" && jwk.Kid != header.Kid {
+			continue
+		}
+		key, err := jwk.PublicKey()
+		if err != nil {
+			continue
+		}
+		if alg, err := Algorithm(key); err != nil || alg != header.Alg {
+			continue
+		}
+		if verifySignature(header.Alg, key, signingInput, signature) {
+			return header, payload, nil
+		}
+	}
+	return header, nil, ErrInvalidSignature
+}
+
+// verifySignature checks a JWS signature made with alg.
+func verifySignature(alg string, key crypto.PublicKey, signingInput, signature []byte) bool {
+	hashFunc, h := hashFor(alg)
+	if h == nil {
+		return ed25519.Verify(key.(ed25519.PublicKey), signingInput, signature)
+	}
+	h.Write(signingInput)
+	digest := h.Sum(nil)
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(key, hashFunc, digest, signature) == nil
+	case *ecdsa.PublicKey:
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return false
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		return ecdsa.Verify(key, digest, r, s)
+	}
+	return false
+}
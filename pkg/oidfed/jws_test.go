@@ -0,0 +1,111 @@
+package oidfed
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testKeys returns a signing key of every supported kind.
+func testKeys(t *testing.T) map[string]crypto.Signer {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	p521, err := ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	require.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	return map[string]crypto.Signer{"RS256": rsaKey, "ES256": p256, "ES384": p384, "ES512": p521, "EdDSA": edKey}
+}
+
+func TestSignVerify(t *testing.T) {
+	claims := map[string]any{"iss": "https://ta.example.com", "sub": "https://tsp.example.com"}
+	keys := testKeys(t)
+	for alg, key := range keys {
+		t.Run(alg, func(t *testing.T) {
+			jwk, err := NewJWK(key.Public())
+			require.NoError(t, err)
+			token, err := Sign(claims, key, TypeEntityStatement, jwk.Kid)
+			require.NoError(t, err)
+
+			header, payload, err := Verify(token, JWKSet{Keys: []JWK{jwk}})
+			require.NoError(t, err)
+			assert.Equal(t, alg, header.Alg)
+			assert.Equal(t, jwk.Kid, header.Kid)
+			assert.Equal(t, TypeEntityStatement, header.Typ)
+			assert.JSONEq(t, `{"iss":"https://ta.example.com","sub":"https://tsp.example.com"}`, string(payload))
+
+			// The public key survives the JWK round trip
+			public, err := jwk.PublicKey()
+			require.NoError(t, err)
+			assert.True(t, public.(interface{ Equal(crypto.PublicKey) bool }).Equal(key.Public()))
+
+			// Tampered payloads and foreign keys are rejected
+			parts := strings.Split(token, ".")
+			other, err := Sign(map[string]any{"iss": "https://evil.example.com"}, key, TypeEntityStatement, jwk.Kid)
+			require.NoError(t, err)
+			tampered := parts[0] + "." + strings.Split(other, ".")[1] + "." + parts[2]
+			_, _, err = Verify(tampered, JWKSet{Keys: []JWK{jwk}})
+			assert.ErrorIs(t, err, ErrInvalidSignature)
+
+			for otherAlg, otherKey := range keys {
+				if otherAlg == alg {
+					continue
+				}
+				otherJWK, err := NewJWK(otherKey.Public())
+				require.NoError(t, err)
+				otherJWK.Kid = jwk.Kid
+				_, _, err = Verify(token, JWKSet{Keys: []JWK{otherJWK}})
+				assert.ErrorIs(t, err, ErrInvalidSignature, otherAlg)
+			}
+		})
+	}
+
+	_, _, err := Verify("not-a-jwt", JWKSet{})
+	assert.ErrorContains(t, err, "malformed JWT")
+}
+
+func TestThumbprint(t *testing.T) {
+	// Example of RFC 7638, section 3.1
+	jwk := JWK{
+		Kty: "RSA",
+		N:   "0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw",
+		E:   "AQAB",
+		Alg: "RS256",
+		Kid: "2011-04-29",
+	}
+	assert.Equal(t, "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs", jwk.Thumbprint())
+}
+
+func TestLoadSigningKey(t *testing.T) {
+	dir := t.TempDir()
+	_, err := LoadSigningKey(filepath.Join(dir, "missing.pem"))
+	assert.Error(t, err)
+
+	empty := filepath.Join(dir, "empty.pem")
+	require.NoError(t, os.WriteFile(empty, []byte("no key"), 0600))
+	_, err = LoadSigningKey(empty)
+	assert.ErrorContains(t, err, "no private key")
+
+	for alg, key := range testKeys(t) {
+		path := filepath.Join(dir, alg+".pem")
+		writeTestKey(t, path, key)
+		loaded, err := LoadSigningKey(path)
+		require.NoError(t, err, alg)
+		assert.True(t, loaded.Public().(interface{ Equal(crypto.PublicKey) bool }).Equal(key.Public()), alg)
+	}
+}
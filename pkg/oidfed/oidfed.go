@@ -0,0 +1,300 @@
+// Package oidfed expresses trust decisions taken from ETSI TS 119 612 trusted
+// lists in the terms of OpenID Federation, so that ecosystems built on OpenID
+// Federation can rely on the trust service providers (TSPs) a trusted list
+// approves.
+//
+// A selected TSP becomes the subject of a subordinate entity statement whose
+// jwks are the public keys of its selected services and whose
+// etsi_trust_services claim lists those services, or of one trust mark per
+// service type, the trust_mark_type being the service type URI. The entity
+// identifier of a TSP is the first https URI of its TSPInformationURI.
+//
+// Statements and trust marks are JWTs signed with Sign; VerifyEntityStatement
+// and VerifyTrustMark verify and decode them on the receiving side.
+package oidfed
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sirosfoundation/g119612/pkg/etsi119612"
+)
+
+// JWT types of the tokens produced by this package.
+const (
+	TypeEntityStatement = "entity-statement+jwt"
+	TypeTrustMark       = "trust-mark+jwt"
+)
+
+// DefaultLifetime is the validity of statements and trust marks derived from
+// a TSL without NextUpdate.
+const DefaultLifetime = 24 * time.Hour
+
+// TrustService describes a trust service in the etsi_trust_services claim.
+type TrustService struct {
+	Name               string `json:"name,omitempty"`
+	ServiceType        string `json:"service_type"`
+	Status             string `json:"status"`
+	StatusStartingTime string `json:"status_starting_time,omitempty"`
+}
+
+// SourceTSL identifies the trusted list a statement was derived from, in the
+// etsi_tsl claim.
+type SourceTSL struct {
+	Location       string `json:"location,omitempty"`
+	Territory      string `json:"territory,omitempty"`
+	SequenceNumber int    `json:"sequence_number"`
+	NextUpdate     string `json:"next_update,omitempty"`
+}
+
+// EntityStatement is the claims set of a subordinate statement about a TSP.
+type EntityStatement struct {
+	Issuer        string                    `json:"iss"`
+	Subject       string                    `json:"sub"`
+	IssuedAt      int64                     `json:"iat"`
+	Expires       int64                     `json:"exp"`
+	JWKS          JWKSet                    `json:"jwks"`
+	Metadata      map[string]map[string]any `json:"metadata,omitempty"`
+	TrustServices []TrustService            `json:"etsi_trust_services,omitempty"`
+	TSL           *SourceTSL                `json:"etsi_tsl,omitempty"`
+}
+
+// TrustMark is the claims set of a trust mark stating that a TSP provides a
+// trust service of a service type approved by a trusted list.
+type TrustMark struct {
+	Issuer        string `json:"iss"`
+	Subject       string `json:"sub"`
+	TrustMarkType string `json:"trust_mark_type"`
+	IssuedAt      int64  `json:"iat"`
+	Expires       int64  `json:"exp"`
+	Ref           string `json:"ref,omitempty"` // Location of the trusted list
+}
+
+// Options configure the conversion of TSPs.
+type Options struct {
+	// Issuer is the entity identifier of the issuing federation entity.
+	Issuer string
+	// Lifetime is the validity of the tokens. Zero means until the NextUpdate
+	// of the trusted list, or DefaultLifetime if it has none.
+	Lifetime time.Duration
+	// Now is the issuing time; the zero time means time.Now.
+	Now time.Time
+}
+
+// Subject is a TSP selected for export together with its selected services.
+type Subject struct {
+	EntityID string
+	TSP      *etsi119612.TSPType
+	TSL      *etsi119612.TSL
+	Services []*etsi119612.TSPServiceType
+}
+
+// EntityID returns the entity identifier of a TSP, the first https URI of its
+// TSPInformationURI.
+func EntityID(tsp *etsi119612.TSPType) (string, bool) {
+	if tsp == nil || tsp.TslTSPInformation == nil || tsp.TslTSPInformation.TSPInformationURI == nil {
+		return "", false
+	}
+	for _, uri := range tsp.TslTSPInformation.TSPInformationURI.URI {
+		if uri != nil && strings.HasPrefix(strings.TrimSpace(uri.Value), "https://") {
+			return strings.TrimSpace(uri.Value), true
+		}
+	}
+	return "", false
+}
+
+// Select returns the TSPs of tsls with at least one service accepted by
+// policy, in list order. TSPs of several lists with the same entity identifier
+// are merged. The names of TSPs with matching services but no entity
+// identifier are returned as skipped.
+func Select(tsls []*etsi119612.TSL, policy *etsi119612.TSPServicePolicy) (subjects []*Subject, skipped []string) {
+	if policy == nil {
+		policy = etsi119612.PolicyAll
+	}
+	byID := make(map[string]*Subject)
+	for _, tsl := range tsls {
+		if tsl == nil {
+			continue
+		}
+		var current *Subject
+		var currentTSP *etsi119612.TSPType
+		tsl.WithTrustServices(func(tsp *etsi119612.TSPType, svc *etsi119612.TSPServiceType) {
+			if tsp.Validate(svc, nil, policy) != nil {
+				return
+			}
+			if tsp != currentTSP {
+				currentTSP, current = tsp, nil
+				id, ok := EntityID(tsp)
+				if !ok {
+					skipped = append(skipped, tspName(tsp))
+					return
+				}
+				if current = byID[id]; current == nil {
+					current = &Subject{EntityID: id, TSP: tsp, TSL: tsl}
+					byID[id] = current
+					subjects = append(subjects, current)
+				}
+			}
+			if current != nil {
+				current.Services = append(current.Services, svc)
+			}
+		})
+	}
+	return subjects, skipped
+}
+
+// tspName returns the English name of a TSP.
+func tspName(tsp *etsi119612.TSPType) string {
+	if tsp.TslTSPInformation == nil {
+		return "Unknown"
+	}
+	return etsi119612.FindByLanguage(tsp.TslTSPInformation.TSPName, "en", "Unknown")
+}
+
+// validity returns the iat and exp of tokens derived from the subject's TSL.
+func (s *Subject) validity(opts Options) (int64, int64) {
+	now := opts.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	expires := now.Add(DefaultLifetime)
+	if opts.Lifetime > 0 {
+		expires = now.Add(opts.Lifetime)
+	} else if next, ok := nextUpdate(s.TSL); ok && next.After(now) {
+		expires = next
+	}
+	return now.Unix(), expires.Unix()
+}
+
+// nextUpdate returns the NextUpdate of a TSL.
+func nextUpdate(tsl *etsi119612.TSL) (time.Time, bool) {
+	info := tsl.StatusList.TslSchemeInformation
+	if info == nil || info.TslNextUpdate == nil {
+		return time.Time{}, false
+	}
+	next, err := time.Parse(time.RFC3339, info.TslNextUpdate.DateTime)
+	return next, err == nil
+}
+
+// EntityStatement returns the subordinate statement about the subject. The
+// jwks claim holds the keys of the certificates of the selected services.
+func (s *Subject) EntityStatement(opts Options) EntityStatement {
+	iat, exp := s.validity(opts)
+	statement := EntityStatement{
+		Issuer:   opts.Issuer,
+		Subject:  s.EntityID,
+		IssuedAt: iat,
+		Expires:  exp,
+		JWKS:     JWKSet{Keys: []JWK{}},
+		Metadata: map[string]map[string]any{
+			"federation_entity": {"organization_name": tspName(s.TSP), "homepage_uri": s.EntityID},
+		},
+	}
+	seen := make(map[string]bool)
+	for _, svc := range s.Services {
+		info := svc.TslServiceInformation
+		statement.TrustServices = append(statement.TrustServices, TrustService{
+			Name:               etsi119612.FindByLanguage(info.ServiceName, "en", ""),
+			ServiceType:        info.TslServiceTypeIdentifier,
+			Status:             info.TslServiceStatus,
+			StatusStartingTime: info.StatusStartingTime,
+		})
+		svc.WithCertificates(func(cert *x509.Certificate) {
+			jwk, err := CertificateJWK(cert)
+			if err != nil || seen[jwk.Kid] {
+				return
+			}
+			seen[jwk.Kid] = true
+			statement.JWKS.Keys = append(statement.JWKS.Keys, jwk)
+		})
+	}
+	if info := s.TSL.StatusList.TslSchemeInformation; info != nil {
+		statement.TSL = &SourceTSL{
+			Location:       s.TSL.Source,
+			Territory:      info.TslSchemeTerritory,
+			SequenceNumber: info.TSLSequenceNumber,
+		}
+		if info.TslNextUpdate != nil {
+			statement.TSL.NextUpdate = info.TslNextUpdate.DateTime
+		}
+	}
+	return statement
+}
+
+// TrustMarks returns one trust mark per service type of the selected services,
+// ordered by service type.
+func (s *Subject) TrustMarks(opts Options) []TrustMark {
+	iat, exp := s.validity(opts)
+	var types []string
+	for _, svc := range s.Services {
+		if t := svc.TslServiceInformation.TslServiceTypeIdentifier; !slices.Contains(types, t) {
+			types = append(types, t)
+		}
+	}
+	slices.Sort(types)
+	marks := make([]TrustMark, 0, len(types))
+	for _, t := range types {
+		marks = append(marks, TrustMark{
+			Issuer:        opts.Issuer,
+			Subject:       s.EntityID,
+			TrustMarkType: t,
+			IssuedAt:      iat,
+			Expires:       exp,
+			Ref:           s.TSL.Source,
+		})
+	}
+	return marks
+}
+
+// VerifyEntityStatement verifies a signed entity statement with the issuer's
+// keys and decodes it. The typ header and the validity period are checked
+// against now.
+func VerifyEntityStatement(token string, keys JWKSet, now time.Time) (EntityStatement, error) {
+	var statement EntityStatement
+	err := verifyClaims(token, keys, TypeEntityStatement, &statement)
+	if err == nil {
+		err = checkValidity(statement.IssuedAt, statement.Expires, now)
+	}
+	return statement, err
+}
+
+// VerifyTrustMark verifies a signed trust mark with the issuer's keys and
+// decodes it. The typ header and the validity period are checked against now.
+func VerifyTrustMark(token string, keys JWKSet, now time.Time) (TrustMark, error) {
+	var mark TrustMark
+	err := verifyClaims(token, keys, TypeTrustMark, &mark)
+	if err == nil {
+		err = checkValidity(mark.IssuedAt, mark.Expires, now)
+	}
+	return mark, err
+}
+
+// verifyClaims verifies a JWT of the given type and decodes its claims.
+func verifyClaims(token string, keys JWKSet, typ string, claims any) error {
+	header, payload, err := Verify(token, keys)
+	if err != nil {
+		return err
+	}
+	if header.Typ != typ {
+		return fmt.Errorf("unexpected JWT type %q (expected %s)", header.Typ, typ)
+	}
+	if err := json.Unmarshal(payload, claims); err != nil {
+		return fmt.Errorf("malformed JWT claims: %w", err)
+	}
+	return nil
+}
+
+// checkValidity checks that now lies within iat and exp.
+func checkValidity(iat, exp int64, now time.Time) error {
+	if now.Unix() < iat {
+		return fmt.Errorf("JWT issued in the future (iat %s)", time.Unix(iat, 0).UTC().Format(time.RFC3339))
+	}
+	if now.Unix() >= exp {
+		return fmt.Errorf("JWT expired at %s", time.Unix(exp, 0).UTC().Format(time.RFC3339))
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package oidfed
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirosfoundation/g119612/pkg/etsi119612"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTestKey writes key as a PKCS #8 PEM file.
+func writeTestKey(t *testing.T, path string, key crypto.Signer) {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600))
+}
+
+// ewcTSL parses the EWC trusted list of the etsi119612 test data.
+func ewcTSL(t *testing.T) *etsi119612.TSL {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("..", "etsi119612", "testdata", "EWC-TL.xml"))
+	require.NoError(t, err)
+	tsl, err := etsi119612.ParseTSL(data, "https://ewc-consortium.github.io/ewc-trust-list/EWC-TL", etsi119612.DefaultTSLFetchOptions)
+	require.NoError(t, err)
+	return tsl
+}
+
+func TestSelect(t *testing.T) {
+	tsl := ewcTSL(t)
+
+	subjects, skipped := Select([]*etsi119612.TSL{tsl}, nil)
+	assert.Len(t, subjects, 17)
+	assert.Empty(t, skipped)
+	for _, subject := range subjects {
+		assert.Regexp(t, "^https://", subject.EntityID)
+		assert.NotEmpty(t, subject.Services)
+	}
+
+	// The same list loaded twice yields each TSP once
+	twice, _ := Select([]*etsi119612.TSL{tsl, tsl, nil}, nil)
+	assert.Len(t, twice, len(subjects))
+
+	policy := etsi119612.NewTSPServicePolicy()
+	policy.AddServiceTypeIdentifier("http://uri.etsi.org/TrstSvc/Svctype/CA/QC")
+	qc, _ := Select([]*etsi119612.TSL{tsl}, policy)
+	require.NotEmpty(t, qc)
+	assert.Less(t, len(qc), len(subjects))
+	for _, subject := range qc {
+		for _, svc := range subject.Services {
+			assert.Equal(t, "http://uri.etsi.org/TrstSvc/Svctype/CA/QC", svc.TslServiceInformation.TslServiceTypeIdentifier)
+		}
+	}
+
+	withdrawn := etsi119612.NewTSPServicePolicy()
+	withdrawn.ServiceStatus = []string{"http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/withdrawn"}
+	none, _ := Select([]*etsi119612.TSL{tsl}, withdrawn)
+	assert.Empty(t, none)
+}
+
+func TestEntityStatementAndTrustMarks(t *testing.T) {
+	tsl := ewcTSL(t)
+	subjects, _ := Select([]*etsi119612.TSL{tsl}, nil)
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	opts := Options{Issuer: "https://ta.example.com", Now: now, Lifetime: time.Hour}
+
+	// A TSP whose services have certificates
+	var subject *Subject
+	for _, s := range subjects {
+		if len(s.EntityStatement(opts).JWKS.Keys) > 0 {
+			subject = s
+			break
+		}
+	}
+	require.NotNil(t, subject)
+
+	statement := subject.EntityStatement(opts)
+	assert.Equal(t, "https://ta.example.com", statement.Issuer)
+	assert.Equal(t, subject.EntityID, statement.Subject)
+	assert.Equal(t, now.Unix(), statement.IssuedAt)
+	assert.Equal(t, now.Add(time.Hour).Unix(), statement.Expires)
+	assert.Len(t, statement.TrustServices, len(subject.Services))
+	for _, key := range statement.JWKS.Keys {
+		assert.Len(t, key.X5c, 1)
+	}
+	require.NotNil(t, statement.TSL)
+	assert.Equal(t, tsl.Source, statement.TSL.Location)
+	assert.NotEmpty(t, statement.Metadata["federation_entity"]["organization_name"])
+
+	marks := subject.TrustMarks(opts)
+	require.NotEmpty(t, marks)
+	types := make(map[string]bool)
+	for _, mark := range marks {
+		assert.Equal(t, subject.EntityID, mark.Subject)
+		assert.False(t, types[mark.TrustMarkType], "one trust mark per service type")
+		types[mark.TrustMarkType] = true
+	}
+
+	// Without a lifetime the tokens are valid until the next update of the list
+	next, ok := nextUpdate(tsl)
+	require.True(t, ok)
+	statement = subject.EntityStatement(Options{Issuer: opts.Issuer, Now: next.Add(-time.Hour)})
+	assert.Equal(t, next.Unix(), statement.Expires)
+	statement = subject.EntityStatement(Options{Issuer: opts.Issuer, Now: next.Add(time.Hour)})
+	assert.Equal(t, next.Add(time.Hour+DefaultLifetime).Unix(), statement.Expires)
+
+	// Round trip through signed JWTs
+	key := testKeys(t)["ES256"]
+	jwk, err := NewJWK(key.Public())
+	require.NoError(t, err)
+	keys := JWKSet{Keys: []JWK{jwk}}
+
+	token, err := Sign(subject.EntityStatement(opts), key, TypeEntityStatement, jwk.Kid)
+	require.NoError(t, err)
+	decoded, err := VerifyEntityStatement(token, keys, now.Add(time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, subject.EntityStatement(opts), decoded)
+	_, err = VerifyEntityStatement(token, keys, now.Add(2*time.Hour))
+	assert.ErrorContains(t, err, "expired")
+	_, err = VerifyTrustMark(token, keys, now)
+	assert.ErrorContains(t, err, "unexpected JWT type")
+
+	token, err = Sign(marks[0], key, TypeTrustMark, jwk.Kid)
+	require.NoError(t, err)
+	mark, err := VerifyTrustMark(token, keys, now)
+	require.NoError(t, err)
+	assert.Equal(t, marks[0], mark)
+	_, err = VerifyTrustMark(token, keys, now.Add(-time.Minute))
+	assert.ErrorContains(t, err, "future")
+}
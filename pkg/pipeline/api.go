@@ -0,0 +1,332 @@
+package pipeline
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/sirosfoundation/g119612/pkg/dsig"
+	"github.com/sirosfoundation/g119612/pkg/logging"
+)
+
+// This file provides typed Go APIs for the most common pipeline steps. The
+// string-based step functions (LoadTSL, SelectCertPool, PublishTSL) parse their
+// YAML arguments into these options and share the same implementation, so Go
+// programs get compile-time checked configuration with identical behavior.
+//
+// Example:
+//
+//	ctx := pipeline.NewContext()
+//	ctx, err := pipeline.Load(ctx, pipeline.LoadOptions{URL: "https://example.com/tsl.xml"})
+//	if err != nil {
+//		return err
+//	}
+//	ctx, err = pipeline.Select(ctx, pipeline.SelectOptions{
+//		ServiceTypes: []string{"http://uri.etsi.org/TrstSvc/Svctype/CA/QC"},
+//	})
+//	...
+//	ctx, err = pipeline.Publish(ctx, pipeline.PublishOptions{Dir: "/var/www/tsl", Signer: signer})
+
+// LoadOptions configures Load. It corresponds to the arguments of the load step.
+type LoadOptions struct {
+	// URL of the root TSL. Plain paths are loaded as local files,
+	// "wellknown:host" is resolved through the host's well-known entry and
+	// "pointers:url" loads a pointer manifest (see etsi119612.FetchPointerManifest).
+	URL string
+	// Strict rejects TSLs that do not conform to the schema (see etsi119612.ValidateStrict).
+	Strict bool
+	// WellKnownPath overrides etsi119612.DefaultWellKnownPath for "wellknown:host" URLs.
+	WellKnownPath string
+	// StrictPointers skips referenced TSLs whose TSLType or SchemeTerritory contradict
+	// the pointer metadata of their parent (see etsi119612.TSLFetchOptions.StrictPointers).
+	StrictPointers bool
+	// Mirrors are further locations of the same TSL, used when URL cannot be
+	// loaded. If URL is empty the first mirror takes its place.
+	Mirrors []string
+	// MirrorMode is MirrorModeOrder (default) to try URL and Mirrors one after
+	// the other, or MirrorModeRace to fetch from all at once and use the first success.
+	MirrorMode string
+	// CheckMirrors fetches the TSL from the other reachable mirrors too and fails
+	// with ErrMirrorMismatch unless they serve the same sequence number and content.
+	CheckMirrors bool
+	// AllowDoctype accepts TSLs with a DOCTYPE declaration, which are rejected by
+	// default (see etsi119612.TSLFetchOptions.AllowDoctype).
+	AllowDoctype bool
+}
+
+// SelectOptions configures Select. It corresponds to the arguments of the select step.
+// The zero value selects all certificates of the root TSLs.
+type SelectOptions struct {
+	// ReferenceDepth is the number of reference levels below the root TSLs to include.
+	ReferenceDepth int
+	// ServiceTypes restricts the selection to services with one of these type URIs.
+	ServiceTypes []string
+	// Statuses restricts the selection to services with one of these status URIs.
+	Statuses []string
+	// MatchAllStatuses requires services to match every entry of Statuses instead of any.
+	MatchAllStatuses bool
+	// RequireCA excludes certificates that are not CA certificates with a KeyUsage
+	// allowing certificate signing (see validation.ValidateCACertificate).
+	RequireCA bool
+	// RequireEKU excludes certificates whose ExtendedKeyUsage does not allow each of
+	// these usages, named as accepted by validation.ParseExtKeyUsage.
+	RequireEKU []string
+	// MinRSABits excludes certificates with RSA keys shorter than this many bits.
+	// Zero allows any size; keys of other algorithms are not affected.
+	MinRSABits int
+	// AllowAlgorithms excludes certificates whose public key algorithm is not one
+	// of these: RSA, ECDSA (any curve), ECDSA-P224, ECDSA-P256, ECDSA-P384,
+	// ECDSA-P521 or Ed25519. Empty allows all algorithms.
+	AllowAlgorithms []string
+	// IssuerContains excludes certificates whose issuer distinguished name does
+	// not contain one of these strings, compared case-insensitively.
+	IssuerContains []string
+	// At is the CurrentTime of the VerifyOptions built for the pool, so that Context.Verify
+	// checks validity at that time. The zero time verifies at the current time.
+	At time.Time
+	// ExclusionReport is a file the certificates excluded by RequireCA, RequireEKU
+	// and the key and issuer filters are written to as JSON (see ExcludedCertificate). Empty writes none.
+	ExclusionReport string
+	// StatusConflict is the policy for certificates listed under services with
+	// different statuses: StatusConflictWarn (default) or StatusConflictExclude.
+	StatusConflict string
+	// ExtraRoots are PEM files, or directories of *.pem, *.crt and *.cer files, whose
+	// certificates are added to the pool as trust anchors with ProvenanceLocal.
+	ExtraRoots []string
+	// CacheDir enables caching of the selected certificates, keyed by the content
+	// of the loaded TSLs and the options above. Empty disables the cache.
+	CacheDir string
+	// MinCerts and MaxCerts bound the number of certificates in the pool, including
+	// ExtraRoots; selection fails with ErrPoolSize outside them. Zero means no bound.
+	MinCerts int
+	MaxCerts int
+	// PoolLog is a file the certificates entering and leaving the pool are
+	// appended to as a hash-chained log (see PoolLog). Empty keeps no log.
+	PoolLog string
+	// Name also keeps the pool under this name for UsePool, so that pools
+	// selected with different options can be used side by side. Empty keeps
+	// only the current pool.
+	Name string
+}
+
+// PublishOptions configures Publish. It corresponds to the arguments of the publish step.
+type PublishOptions struct {
+	// Dir is the output directory, created if it does not exist.
+	Dir string
+	// Signer signs the published XML; nil publishes unsigned TSLs.
+	Signer dsig.XMLSigner
+	// FileMode is the mode of published files (DefaultPublishFileMode if zero).
+	FileMode os.FileMode
+	// DirMode is the mode of created directories (DefaultPublishDirMode if zero).
+	DirMode os.FileMode
+	// KeyPermissions is the policy for file signer keys readable by group or
+	// others: KeyPermissionsWarn (default), KeyPermissionsStrict or KeyPermissionsIgnore.
+	KeyPermissions string
+	// UnsignedCopy also writes "name-unsigned.xml", the canonical form of the
+	// signed content without the signature, next to each signed TSL.
+	UnsignedCopy bool
+	// SignMode selects SignModeDOM (default) or SignModeStream signing.
+	SignMode string
+	// Tree publishes TSL trees in subdirectories named by "territory" or "index";
+	// empty publishes a flat directory.
+	Tree string
+	// RolloverSigner signs an additional copy of every TSL with the next key during
+	// a key rollover. It requires Signer and RolloverDir.
+	RolloverSigner dsig.XMLSigner
+	// RolloverDir is the directory for the copies signed by RolloverSigner; each copy
+	// has the same path relative to it as the primary TSL has relative to Dir.
+	RolloverDir string
+	// Indent is IndentPretty (default) or IndentCompact.
+	Indent string
+	// OmitXMLDeclaration leaves out the <?xml ...?> declaration.
+	OmitXMLDeclaration bool
+	// Encoding is EncodingUTF8 (default) or EncodingNone to declare no encoding.
+	Encoding string
+	// Newline is NewlineLF (default) or NewlineCRLF.
+	Newline string
+	// Manifest writes manifest.json with the digest and ETag of every published file.
+	Manifest bool
+	// ETagSidecars writes "name.xml.etag" with the ETag next to every published file.
+	ETagSidecars bool
+	// OnFailure is the handling of files written before publishing failed:
+	// OnFailureKeep (default), OnFailureRollback or OnFailurePartial.
+	OnFailure string
+	// Retries is the number of times a failed file write is retried.
+	Retries int
+	// SplitMaxBytes and SplitMaxProviders split TSLs exceeding them into parts
+	// referenced by the TSL; zero means no limit.
+	SplitMaxBytes     int
+	SplitMaxProviders int
+	// SplitBaseURL is the location of the parts; empty places them next to the
+	// first distribution point of the TSL.
+	SplitBaseURL string
+}
+
+// Option configures how the typed step APIs run.
+type Option func(*Pipeline)
+
+// WithStepLogger sets the logger used by a typed step call.
+// Without it, the default logger is used.
+func WithStepLogger(logger logging.Logger) Option {
+	return func(pl *Pipeline) {
+		if logger != nil {
+			pl.Logger = logger
+		}
+	}
+}
+
+// WithPipeline runs a typed step call with the settings of an existing
+// pipeline, as if it were one of its steps: its logger, registry, clock,
+// event sinks and warning baseline, and ReadOnly, with which Publish refuses
+// to write and Select does not append to the pool log.
+func WithPipeline(p *Pipeline) Option {
+	return func(pl *Pipeline) {
+		if p == nil {
+			return
+		}
+		logger := pl.Logger
+		*pl = *p
+		pl.Pipes = nil
+		if pl.Logger == nil {
+			pl.Logger = logger
+		}
+	}
+}
+
+// newStepPipeline returns the pipeline passed to the implementation of step
+// by the typed APIs. Like Process, it gives ctx the Clock of the pipeline if
+// it has none and routes warnings to the baseline and the event sinks.
+func newStepPipeline(step string, ctx *Context, options []Option) *Pipeline {
+	pl := &Pipeline{Logger: logging.DefaultLogger()}
+	for _, option := range options {
+		option(pl)
+	}
+	if ctx != nil && ctx.Clock == nil {
+		ctx.Clock = pl.Clock
+	}
+	if len(pl.sinks) > 0 || pl.Baseline != nil {
+		pl = pl.withLogger(&eventLogger{Logger: pl.Logger, pl: pl, cursor: &stepCursor{name: step}})
+	}
+	return pl
+}
+
+// Load loads a TSL and its references into ctx, like the load step.
+//
+// Parameters:
+//   - ctx: The context to add the TSL tree to; its TSLFetchOptions control fetching
+//   - opts: The load options
+//   - options: Optional settings such as WithStepLogger
+//
+// Returns:
+//   - *Context: The updated context
+//   - error: Non-nil if loading fails
+func Load(ctx *Context, opts LoadOptions, options ...Option) (*Context, error) {
+	return loadWithOptions(newStepPipeline("load", ctx, options), ctx, opts)
+}
+
+// Select builds ctx.CertPool and ctx.VerifyOptions from the loaded TSLs, like the select step.
+//
+// Parameters:
+//   - ctx: The context with loaded TSLs
+//   - opts: The selection options
+//   - options: Optional settings such as WithStepLogger
+//
+// Returns:
+//   - *Context: The context with the new certificate pool
+//   - error: Non-nil if no TSLs are loaded
+func Select(ctx *Context, opts SelectOptions, options ...Option) (*Context, error) {
+	if opts.ReferenceDepth < 0 {
+		return ctx, fmt.Errorf("%w: negative reference depth %d", ErrInvalidArguments, opts.ReferenceDepth)
+	}
+	return selectWithOptions(newStepPipeline("select", ctx, options), ctx, opts)
+}
+
+// Publish writes the loaded TSLs to opts.Dir, like the publish step.
+//
+// Parameters:
+//   - ctx: The context with the TSLs to publish
+//   - opts: The publish options
+//   - options: Optional settings such as WithStepLogger
+//
+// Returns:
+//   - *Context: The context unchanged
+//   - error: Non-nil if the options are invalid, the pipeline given with
+//     WithPipeline is read-only (ErrReadOnly) or publishing fails
+func Publish(ctx *Context, opts PublishOptions, options ...Option) (*Context, error) {
+	if opts.Dir == "" {
+		return ctx, fmt.Errorf("%w: missing output directory", ErrInvalidArguments)
+	}
+
+	internal := defaultPublishOptions()
+	internal.signer = opts.Signer
+	internal.unsignedCopy = opts.UnsignedCopy
+	internal.rolloverSigner = opts.RolloverSigner
+	internal.rolloverDir = opts.RolloverDir
+	if opts.FileMode != 0 {
+		internal.fileMode = opts.FileMode
+	}
+	if opts.DirMode != 0 {
+		internal.dirMode = opts.DirMode
+	}
+	switch opts.KeyPermissions {
+	case "":
+	case KeyPermissionsWarn, KeyPermissionsStrict, KeyPermissionsIgnore:
+		internal.keyPermissions = opts.KeyPermissions
+	default:
+		return ctx, fmt.Errorf("%w: invalid key permissions policy %q", ErrInvalidArguments, opts.KeyPermissions)
+	}
+	switch opts.SignMode {
+	case "", SignModeDOM:
+	case SignModeStream:
+		internal.streamSign = true
+	default:
+		return ctx, fmt.Errorf("%w: invalid sign mode %q", ErrInvalidArguments, opts.SignMode)
+	}
+	switch opts.Tree {
+	case "", "territory", "index":
+		internal.treeFormat = opts.Tree
+	default:
+		return ctx, fmt.Errorf("%w: invalid tree format %q", ErrInvalidArguments, opts.Tree)
+	}
+	if opts.OnFailure != "" {
+		policy, err := parseOnFailure(opts.OnFailure)
+		if err != nil {
+			return ctx, fmt.Errorf("%w: %v", ErrInvalidArguments, err)
+		}
+		internal.onFailure = policy
+	}
+	if opts.Retries < 0 {
+		return ctx, fmt.Errorf("%w: negative retries %d", ErrInvalidArguments, opts.Retries)
+	}
+	internal.retries = opts.Retries
+	if opts.SplitMaxBytes < 0 || opts.SplitMaxProviders < 0 {
+		return ctx, fmt.Errorf("%w: negative split limit", ErrInvalidArguments)
+	}
+	internal.splitMaxBytes = opts.SplitMaxBytes
+	internal.splitMaxProviders = opts.SplitMaxProviders
+	internal.splitBaseURL = opts.SplitBaseURL
+	internal.omitDecl = opts.OmitXMLDeclaration
+	internal.manifest = opts.Manifest
+	internal.etagSidecar = opts.ETagSidecars
+	if opts.Indent != "" {
+		if err := internal.setIndent(opts.Indent); err != nil {
+			return ctx, fmt.Errorf("%w: %v", ErrInvalidArguments, err)
+		}
+	}
+	if opts.Encoding != "" {
+		if err := internal.setEncoding(opts.Encoding); err != nil {
+			return ctx, fmt.Errorf("%w: %v", ErrInvalidArguments, err)
+		}
+	}
+	if opts.Newline != "" {
+		if err := internal.setNewline(opts.Newline); err != nil {
+			return ctx, fmt.Errorf("%w: %v", ErrInvalidArguments, err)
+		}
+	}
+
+	pl := newStepPipeline("publish", ctx, options)
+	if pl.ReadOnly {
+		return ctx, fmt.Errorf("%w: not publishing to %s", ErrReadOnly, opts.Dir)
+	}
+	return publishWithOptions(pl, ctx, opts.Dir, internal)
+}
@@ -0,0 +1,135 @@
+package pipeline
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirosfoundation/g119612/pkg/dsig"
+	"github.com/sirosfoundation/g119612/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTypedAPI_LoadSelectPublish(t *testing.T) {
+	silent := WithStepLogger(logging.SilentLogger())
+
+	ctx, err := Load(NewContext(), LoadOptions{URL: "./testdata/test-tsl.xml"}, silent)
+	require.NoError(t, err)
+	assert.Equal(t, 1, ctx.TSLTrees.Size())
+
+	ctx, err = Select(ctx, SelectOptions{}, silent)
+	require.NoError(t, err)
+	require.NotNil(t, ctx.CertPool)
+
+	// The typed and string-based select steps produce the same pool
+	viaArgs, err := SelectCertPool(&Pipeline{Logger: logging.SilentLogger()}, ctx)
+	require.NoError(t, err)
+	assert.True(t, ctx.CertPool.Equal(viaArgs.CertPool))
+
+	tmpDir := t.TempDir()
+	certFile := filepath.Join(tmpDir, "cert.pem")
+	keyFile := filepath.Join(tmpDir, "key.pem")
+	require.NoError(t, generateTestCertAndKey(certFile, keyFile))
+	require.NoError(t, os.Chmod(keyFile, 0600))
+
+	outDir := filepath.Join(tmpDir, "out")
+	_, err = Publish(ctx, PublishOptions{
+		Dir:          outDir,
+		Signer:       dsig.NewFileSigner(certFile, keyFile),
+		FileMode:     0640,
+		UnsignedCopy: true,
+	}, silent)
+	require.NoError(t, err)
+
+	entries, err := os.ReadDir(outDir)
+	require.NoError(t, err)
+	var signed, unsigned int
+	for _, entry := range entries {
+		info, err := entry.Info()
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0640), info.Mode().Perm(), entry.Name())
+		if strings.HasSuffix(entry.Name(), "-unsigned.xml") {
+			unsigned++
+		} else {
+			signed++
+		}
+	}
+	assert.NotZero(t, signed)
+	assert.Equal(t, signed, unsigned)
+}
+
+func TestTypedAPI_InvalidOptions(t *testing.T) {
+	silent := WithStepLogger(logging.SilentLogger())
+
+	_, err := Load(NewContext(), LoadOptions{}, silent)
+	assert.Error(t, err)
+
+	_, err = Select(NewContext(), SelectOptions{}, silent)
+	assert.Error(t, err)
+
+	ctx, err := Load(NewContext(), LoadOptions{URL: "./testdata/test-tsl.xml", Strict: true}, silent)
+	assert.Error(t, err)
+
+	_, err = Select(ctx, SelectOptions{ReferenceDepth: -1}, silent)
+	assert.ErrorIs(t, err, ErrInvalidArguments)
+
+	_, err = Publish(ctx, PublishOptions{}, silent)
+	assert.ErrorIs(t, err, ErrInvalidArguments)
+
+	_, err = Publish(ctx, PublishOptions{Dir: t.TempDir(), Tree: "bogus"}, silent)
+	assert.ErrorIs(t, err, ErrInvalidArguments)
+
+	_, err = Publish(ctx, PublishOptions{Dir: t.TempDir(), KeyPermissions: "sometimes"}, silent)
+	assert.ErrorIs(t, err, ErrInvalidArguments)
+
+	_, err = Publish(ctx, PublishOptions{Dir: t.TempDir(), RolloverDir: t.TempDir()}, silent)
+	assert.ErrorIs(t, err, ErrInvalidArguments)
+
+	_, err = Publish(ctx, PublishOptions{Dir: t.TempDir(), Indent: "tabs"}, silent)
+	assert.ErrorIs(t, err, ErrInvalidArguments)
+
+	_, err = Publish(ctx, PublishOptions{Dir: t.TempDir(), Encoding: "UTF-16"}, silent)
+	assert.ErrorIs(t, err, ErrInvalidArguments)
+
+	_, err = Publish(ctx, PublishOptions{Dir: t.TempDir(), Newline: "cr"}, silent)
+	assert.ErrorIs(t, err, ErrInvalidArguments)
+}
+
+func TestTypedAPI_WithPipeline(t *testing.T) {
+	logger := logging.SilentLogger()
+	pl := newStepPipeline("load", nil, []Option{WithPipeline(&Pipeline{Logger: logger})})
+	assert.Same(t, logger, pl.Logger)
+
+	pl = newStepPipeline("load", nil, []Option{WithStepLogger(nil)})
+	assert.NotNil(t, pl.Logger)
+
+	// The settings of the pipeline apply to the call
+	clock := FixedClock(time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC))
+	reg := NewRegistry()
+	source := &Pipeline{Logger: logger, ReadOnly: true, Registry: reg, Clock: clock, Baseline: &WarningBaseline{}}
+	ctx := NewContext()
+	pl = newStepPipeline("select", ctx, []Option{WithPipeline(source), WithPipeline(nil)})
+	assert.True(t, pl.ReadOnly)
+	assert.Same(t, reg, pl.Registry)
+	assert.Same(t, source.Baseline, pl.Baseline)
+	assert.Equal(t, clock.Now(), ctx.Now())
+
+	// A read-only pipeline refuses to publish
+	ctx, err := Load(NewContext(), LoadOptions{URL: "./testdata/test-tsl.xml"}, WithPipeline(source))
+	require.NoError(t, err)
+	dir := t.TempDir()
+	_, err = Publish(ctx, PublishOptions{Dir: dir}, WithPipeline(source))
+	assert.ErrorIs(t, err, ErrReadOnly)
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	_, err = Publish(ctx, PublishOptions{Dir: dir}, WithPipeline(&Pipeline{Logger: logger}))
+	require.NoError(t, err)
+	entries, err = os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.NotEmpty(t, entries)
+}
@@ -0,0 +1,118 @@
+package pipeline
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// timestampPattern matches the RFC 3339 timestamps that x509 and fetch errors
+// embed in warnings, such as the current time of an expired certificate.
+var timestampPattern = regexp.MustCompile(`\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:?\d{2})?`)
+
+// WarningBaseline is a set of acknowledged warnings, such as the warning about
+// a known-bad certificate in an upstream TSL that cannot be fixed locally. A
+// pipeline with a baseline (see Pipeline.Baseline) logs the warnings in it at
+// debug level and does not pass them to the event sinks, so that new warnings
+// still stand out and can fail a run.
+//
+// Warnings are identified by their fingerprint (see WarningFingerprint). In
+// YAML a baseline is a list of warnings with their fingerprints; the step and
+// message are informational, and the comment records why the warning was
+// acknowledged:
+//
+//	warnings:
+//	  - fingerprint: 5f0c1b7e2a94d3c8
+//	    step: select
+//	    message: Excluded certificate from pool
+//	    comment: Expired CA still listed as granted by XA, reported upstream
+type WarningBaseline struct {
+	Warnings []BaselineWarning `yaml:"warnings"`
+}
+
+// BaselineWarning is an acknowledged warning of a WarningBaseline.
+type BaselineWarning struct {
+	Fingerprint string `yaml:"fingerprint"`       // WarningFingerprint of the warning
+	Step        string `yaml:"step,omitempty"`    // Name of the step emitting the warning
+	Message     string `yaml:"message,omitempty"` // Warning message
+	Comment     string `yaml:"comment,omitempty"` // Why the warning was acknowledged
+}
+
+// LoadWarningBaseline reads a warning baseline in YAML.
+func LoadWarningBaseline(file string) (*WarningBaseline, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var baseline WarningBaseline
+	if err := yaml.Unmarshal(data, &baseline); err != nil {
+		return nil, fmt.Errorf("%s: failed to parse warning baseline: %w", file, err)
+	}
+	for i, warning := range baseline.Warnings {
+		if warning.Fingerprint == "" {
+			return nil, fmt.Errorf("%s: warning %d has no fingerprint", file, i)
+		}
+	}
+	return &baseline, nil
+}
+
+// Save writes the baseline to file in YAML.
+func (b *WarningBaseline) Save(file string) error {
+	data, err := yaml.Marshal(b)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, data, 0644)
+}
+
+// Contains reports whether the warning with the given fingerprint is
+// acknowledged. A nil baseline contains no warnings.
+func (b *WarningBaseline) Contains(fingerprint string) bool {
+	if b == nil {
+		return false
+	}
+	return slices.ContainsFunc(b.Warnings, func(w BaselineWarning) bool {
+		return w.Fingerprint == fingerprint
+	})
+}
+
+// Add acknowledges a warning unless it already is, and reports whether it
+// was added.
+func (b *WarningBaseline) Add(event WarningEvent) bool {
+	fingerprint := event.Fingerprint
+	if fingerprint == "" {
+		fingerprint = WarningFingerprint(event)
+	}
+	if b.Contains(fingerprint) {
+		return false
+	}
+	b.Warnings = append(b.Warnings, BaselineWarning{
+		Fingerprint: fingerprint,
+		Step:        event.Name,
+		Message:     event.Message,
+	})
+	return true
+}
+
+// WarningFingerprint identifies a warning across runs: it is a hash of the
+// name of the step, the message and the fields of the warning, which name the
+// TSL, certificate or URL it is about. Timestamps in the field values are
+// ignored, as is the position of the step, so the fingerprint survives
+// clock changes and edits elsewhere in the pipeline.
+func WarningFingerprint(event WarningEvent) string {
+	fields := make([]string, 0, len(event.Fields))
+	for _, field := range event.Fields {
+		value := timestampPattern.ReplaceAllString(fmt.Sprint(field.Value), "")
+		fields = append(fields, field.Key+"="+value)
+	}
+	slices.Sort(fields)
+	h := sha256.New()
+	h.Write([]byte(event.Name + "\n" + event.Message + "\n" + strings.Join(fields, "\n")))
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
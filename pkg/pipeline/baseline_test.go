@@ -0,0 +1,133 @@
+package pipeline
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirosfoundation/g119612/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWarningFingerprint(t *testing.T) {
+	event := WarningEvent{
+		Index:   2,
+		Name:    "select",
+		Message: "Excluded certificate from pool",
+		Fields: []logging.Field{
+			logging.F("subject", "CN=Bad CA"),
+			logging.F("error", "x509: certificate has expired: current time 2026-10-17T03:55:14Z is after 2026-01-01T00:00:00Z"),
+		},
+	}
+	fingerprint := WarningFingerprint(event)
+	assert.Len(t, fingerprint, 16)
+
+	// Stable across runs, field order and step positions
+	later := event
+	later.Index = 5
+	later.Fields = []logging.Field{
+		logging.F("error", "x509: certificate has expired: current time 2026-11-02T10:00:00.5+01:00 is after 2026-01-01T00:00:00Z"),
+		logging.F("subject", "CN=Bad CA"),
+	}
+	assert.Equal(t, fingerprint, WarningFingerprint(later))
+
+	// Another certificate, message or step is another warning
+	other := event
+	other.Fields = []logging.Field{logging.F("subject", "CN=Other CA"), event.Fields[1]}
+	assert.NotEqual(t, fingerprint, WarningFingerprint(other))
+	other = event
+	other.Message = "Something else"
+	assert.NotEqual(t, fingerprint, WarningFingerprint(other))
+	other = event
+	other.Name = "load"
+	assert.NotEqual(t, fingerprint, WarningFingerprint(other))
+}
+
+func TestLoadWarningBaseline(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "baseline.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(`
+warnings:
+  - fingerprint: 0123456789abcdef
+    step: select
+    message: Excluded certificate from pool
+    comment: Reported upstream
+`), 0644))
+	baseline, err := LoadWarningBaseline(file)
+	require.NoError(t, err)
+	require.Len(t, baseline.Warnings, 1)
+	assert.Equal(t, "Reported upstream", baseline.Warnings[0].Comment)
+	assert.True(t, baseline.Contains("0123456789abcdef"))
+	assert.False(t, baseline.Contains("fedcba9876543210"))
+
+	// Add skips acknowledged warnings, Save keeps the comments
+	event := WarningEvent{Name: "load", Message: "Referenced TSL does not match pointer metadata"}
+	assert.True(t, baseline.Add(event))
+	assert.False(t, baseline.Add(event))
+	assert.False(t, baseline.Add(WarningEvent{Fingerprint: "0123456789abcdef"}))
+	require.NoError(t, baseline.Save(file))
+	saved, err := LoadWarningBaseline(file)
+	require.NoError(t, err)
+	assert.Equal(t, baseline, saved)
+	assert.True(t, saved.Contains(WarningFingerprint(event)))
+
+	var empty *WarningBaseline
+	assert.False(t, empty.Contains("0123456789abcdef"))
+
+	require.NoError(t, os.WriteFile(file, []byte("warnings:\n  - step: select\n"), 0644))
+	_, err = LoadWarningBaseline(file)
+	assert.ErrorContains(t, err, "no fingerprint")
+	_, err = LoadWarningBaseline(filepath.Join(dir, "missing.yaml"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestPipeline_Baseline(t *testing.T) {
+	RegisterFunction("baseline-warn", func(pl *Pipeline, ctx *Context, args ...string) (*Context, error) {
+		for _, arg := range args {
+			pl.Logger.Warn("Excluded certificate from pool", logging.F("subject", arg))
+		}
+		return ctx, nil
+	})
+	known := WarningEvent{
+		Name:    "baseline-warn",
+		Message: "Excluded certificate from pool",
+		Fields:  []logging.Field{logging.F("subject", "CN=Known")},
+	}
+	var buf bytes.Buffer
+	logger := logging.NewLogger(logging.DebugLevel)
+	logger.(logging.OutputConfigurable).SetOutput(&buf)
+	pl := &Pipeline{
+		Pipes:    []Pipe{{MethodName: "baseline-warn", MethodArguments: []string{"CN=Known", "CN=New"}}},
+		Logger:   logger,
+		Baseline: &WarningBaseline{Warnings: []BaselineWarning{{Fingerprint: WarningFingerprint(known)}}},
+	}
+	sink := &recordingSink{}
+	pl = pl.WithLogger(logger)
+	pl.AddEventSink(sink)
+
+	_, err := pl.Process(NewContext())
+	require.NoError(t, err)
+
+	// Only the new warning reaches the sinks, with its fingerprint
+	require.Len(t, sink.warnings, 1)
+	assert.Equal(t, []logging.Field{logging.F("subject", "CN=New")}, sink.warnings[0].Fields)
+	assert.Equal(t, WarningFingerprint(sink.warnings[0]), sink.warnings[0].Fingerprint)
+
+	// The acknowledged warning is logged at debug level, both with fingerprints
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	require.Len(t, lines, 2)
+	assert.Contains(t, string(lines[0]), "level=debug")
+	assert.Contains(t, string(lines[0]), "acknowledged=true")
+	assert.Contains(t, string(lines[0]), "fingerprint="+WarningFingerprint(known))
+	assert.Contains(t, string(lines[1]), "level=warning")
+	assert.Contains(t, string(lines[1]), "fingerprint="+sink.warnings[0].Fingerprint)
+
+	// Without sinks the baseline still demotes warnings
+	buf.Reset()
+	pl = &Pipeline{Pipes: pl.Pipes, Logger: logger, Baseline: pl.Baseline}
+	_, err = pl.Process(NewContext())
+	require.NoError(t, err)
+	assert.Contains(t, buf.String(), "acknowledged=true")
+}
@@ -0,0 +1,257 @@
+package pipeline
+
+import (
+	"bytes"
+	"crypto/x509"
+	_ "embed"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/sirosfoundation/g119612/pkg/etsi119612"
+)
+
+//go:embed templates/browse.html
+var browseHTMLTemplate string
+
+// browseTemplates holds the pages of the browse UI, one template per page.
+var browseTemplates = template.Must(template.New("browse").Funcs(renderFuncs([]string{"en"})).Parse(browseHTMLTemplate))
+
+// browseNode is a TSL in the tree shown on the index page of the browse UI.
+type browseNode struct {
+	Base     string
+	ID       int
+	TSL      *etsi119612.TSL
+	Children []browseNode
+}
+
+// browsePage is the data the browse templates are executed with.
+type browsePage struct {
+	Base     string
+	Loaded   bool
+	Nodes    []browseNode
+	ID       int
+	TSL      *etsi119612.TSL
+	Provider int
+	TSP      *etsi119612.TSPType
+	Service  int
+	Svc      *etsi119612.TSPServiceType
+	Certs    []*x509.Certificate
+}
+
+// BrowseHandler returns an http.Handler with read-only HTML pages for browsing
+// TSLs: the tree of loaded TSLs, a provider table per TSL and a detail page per
+// trust service with links to download its certificates as PEM. The pages are
+// generated from the typed TSL model rather than from XSLT output.
+//
+// state is called for every request, so the pages always show the context it
+// currently returns; a nil context is shown as nothing loaded yet. TSLs are
+// numbered in the order of the TSLs of the context, so links may point to
+// another TSL once the context is replaced.
+//
+// Pages:
+//   - base/: The loaded TSLs
+//   - base/tsl/{tsl}: Scheme information and providers of a TSL
+//   - base/tsl/{tsl}/json: The TSL in the JSON representation of package jsonmodel
+//   - base/tsl/{tsl}/provider/{provider}/service/{service}: A trust service and its certificates
+//   - base/tsl/{tsl}/provider/{provider}/service/{service}/cert/{cert}.pem: A certificate
+//
+// Parameters:
+//   - base: The path the handler is mounted at, without a trailing slash (e.g. "/ui")
+//   - state: Returns the context to show
+//
+// Returns:
+//   - http.Handler: A handler serving the pages below base
+func BrowseHandler(base string, state func() *Context) http.Handler {
+	h := &browseHandler{base: base, state: state}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+base+"/{$}", h.index)
+	mux.HandleFunc("GET "+base+"/tsl/{tsl}", h.tsl)
+	mux.HandleFunc("GET "+base+"/tsl/{tsl}/json", h.tslJSON)
+	mux.HandleFunc("GET "+base+"/tsl/{tsl}/provider/{provider}/service/{service}", h.service)
+	mux.HandleFunc("GET "+base+"/tsl/{tsl}/provider/{provider}/service/{service}/cert/{cert}", h.certificate)
+	return mux
+}
+
+type browseHandler struct {
+	base  string
+	state func() *Context
+}
+
+// index serves the tree of loaded TSLs.
+func (h *browseHandler) index(w http.ResponseWriter, r *http.Request) {
+	ctx := h.state()
+	page := browsePage{Base: h.base, Loaded: ctx != nil}
+	if ctx != nil {
+		page.Nodes = browseTree(ctx, h.base)
+	}
+	h.render(w, "index", page)
+}
+
+// tsl serves the provider table of a TSL.
+func (h *browseHandler) tsl(w http.ResponseWriter, r *http.Request) {
+	page, ok := h.lookup(r, false)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	h.render(w, "tsl", page)
+}
+
+// tslJSON serves a TSL as JSON.
+func (h *browseHandler) tslJSON(w http.ResponseWriter, r *http.Request) {
+	page, ok := h.lookup(r, false)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	data, err := json.MarshalIndent(page.TSL, "", "  ")
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(append(data, '\n'))
+}
+
+// service serves the detail page of a trust service.
+func (h *browseHandler) service(w http.ResponseWriter, r *http.Request) {
+	page, ok := h.lookup(r, true)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	h.render(w, "service", page)
+}
+
+// certificate serves a certificate of a trust service as PEM.
+func (h *browseHandler) certificate(w http.ResponseWriter, r *http.Request) {
+	page, ok := h.lookup(r, true)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	name, isPEM := strings.CutSuffix(r.PathValue("cert"), ".pem")
+	index, ok := browseIndex(name, len(page.Certs))
+	if !ok || !isPEM {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-pem-file")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q",
+		fmt.Sprintf("tsl-%d-provider-%d-service-%d-cert-%d.pem", page.ID, page.Provider, page.Service, index)))
+	_ = pem.Encode(w, &pem.Block{Type: "CERTIFICATE", Bytes: page.Certs[index].Raw})
+}
+
+// lookup resolves the TSL and, if withService is set, the provider and
+// service named by the path of r in the current context.
+func (h *browseHandler) lookup(r *http.Request, withService bool) (browsePage, bool) {
+	page := browsePage{Base: h.base, Loaded: true}
+	ctx := h.state()
+	if ctx == nil {
+		return page, false
+	}
+	tsls := publishableTSLs(ctx)
+	var ok bool
+	if page.ID, ok = browseIndex(r.PathValue("tsl"), len(tsls)); !ok {
+		return page, false
+	}
+	page.TSL = tsls[page.ID]
+	if !withService {
+		return page, true
+	}
+
+	if page.TSL.StatusList.TslTrustServiceProviderList == nil {
+		return page, false
+	}
+	providers := page.TSL.StatusList.TslTrustServiceProviderList.TslTrustServiceProvider
+	if page.Provider, ok = browseIndex(r.PathValue("provider"), len(providers)); !ok || providers[page.Provider] == nil {
+		return page, false
+	}
+	page.TSP = providers[page.Provider]
+	if page.TSP.TslTSPServices == nil {
+		return page, false
+	}
+	services := page.TSP.TslTSPServices.TslTSPService
+	if page.Service, ok = browseIndex(r.PathValue("service"), len(services)); !ok || services[page.Service] == nil {
+		return page, false
+	}
+	page.Svc = services[page.Service]
+	page.Svc.WithCertificates(func(cert *x509.Certificate) {
+		page.Certs = append(page.Certs, cert)
+	})
+	return page, true
+}
+
+// render executes a page template, answering 500 if it fails.
+func (h *browseHandler) render(w http.ResponseWriter, name string, page browsePage) {
+	var buf bytes.Buffer
+	if err := browseTemplates.ExecuteTemplate(&buf, name, page); err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write(buf.Bytes())
+}
+
+// browseIndex parses a path segment as an index into a list of n elements.
+func browseIndex(s string, n int) (int, bool) {
+	i, err := strconv.Atoi(s)
+	if err != nil || i < 0 || i >= n {
+		return 0, false
+	}
+	return i, true
+}
+
+// browseTree arranges the TSLs of ctx by their references. Each TSL carries
+// its position in publishableTSLs, which is its ID in the browse URLs; TSLs
+// outside the TSL trees of the context are shown at the top level.
+func browseTree(ctx *Context, base string) []browseNode {
+	tsls := publishableTSLs(ctx)
+	ids := make(map[*etsi119612.TSL]int, len(tsls))
+	for i, tsl := range tsls {
+		ids[tsl] = i
+	}
+
+	shown := make(map[*etsi119612.TSL]bool, len(tsls))
+	var build func(node *TSLNode) (browseNode, bool)
+	build = func(node *TSLNode) (browseNode, bool) {
+		if node == nil || node.TSL == nil || shown[node.TSL] {
+			return browseNode{}, false
+		}
+		id, ok := ids[node.TSL]
+		if !ok {
+			return browseNode{}, false
+		}
+		shown[node.TSL] = true
+		n := browseNode{Base: base, ID: id, TSL: node.TSL}
+		for _, child := range node.Children {
+			if c, ok := build(child); ok {
+				n.Children = append(n.Children, c)
+			}
+		}
+		return n, true
+	}
+
+	var nodes []browseNode
+	if ctx.TSLTrees != nil {
+		for _, tree := range ctx.TSLTrees.ToSlice() {
+			if tree == nil {
+				continue
+			}
+			if n, ok := build(tree.Root); ok {
+				nodes = append(nodes, n)
+			}
+		}
+	}
+	for i, tsl := range tsls {
+		if !shown[tsl] {
+			nodes = append(nodes, browseNode{Base: base, ID: i, TSL: tsl})
+		}
+	}
+	return nodes
+}
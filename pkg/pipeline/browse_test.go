@@ -0,0 +1,155 @@
+package pipeline
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sirosfoundation/g119612/pkg/etsi119612"
+	"github.com/sirosfoundation/g119612/pkg/etsi119612/jsonmodel"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// browseGet fetches a page of the browse UI and returns its status and body.
+func browseGet(t *testing.T, url string) (int, string, http.Header) {
+	t.Helper()
+	resp, err := http.Get(url)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp.StatusCode, string(body), resp.Header
+}
+
+func TestBrowseHandler(t *testing.T) {
+	root := generateTSL("Root Service", "http://uri.etsi.org/TrstSvc/Svctype/CA/QC", []string{TestCertBase64})
+	root.Source = "https://example.com/root.xml"
+	root.StatusList.TslSchemeInformation.TslSchemeTerritory = "EU"
+	ref := generateTSL("Referenced Service", "http://uri.etsi.org/TrstSvc/Svctype/TSA/QTST", []string{TestCertBase64})
+	ref.Source = "https://example.com/se.xml"
+	ref.StatusList.TslSchemeInformation.TslSchemeTerritory = "SE"
+	ref.StatusList.TslTrustServiceProviderList.TslTrustServiceProvider[0].TslTSPInformation.TSPAddress = &etsi119612.AddressType{
+		TslElectronicAddress: &etsi119612.ElectronicAddressType{URI: []*etsi119612.NonEmptyMultiLangURIType{{Value: "mailto:incident@example.com"}}},
+	}
+	ref.StatusList.TslSchemeInformation.SchemeOperatorAddress = &etsi119612.AddressType{
+		TslElectronicAddress: &etsi119612.ElectronicAddressType{URI: []*etsi119612.NonEmptyMultiLangURIType{{Value: "mailto:operator@example.com"}}},
+	}
+	root.AddReferencedTSL(ref)
+	ctx := NewContext()
+	ctx.AddTSL(root)
+
+	var state *Context
+	server := httptest.NewServer(BrowseHandler("/ui", func() *Context { return state }))
+	defer server.Close()
+
+	// Nothing loaded yet
+	status, body, _ := browseGet(t, server.URL+"/ui/")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, "No pipeline run has completed yet")
+	status, _, _ = browseGet(t, server.URL+"/ui/tsl/0")
+	assert.Equal(t, http.StatusNotFound, status)
+
+	state = ctx
+	tsls := publishableTSLs(ctx)
+	require.Len(t, tsls, 2)
+	rootID, refID := "0", "1"
+	if tsls[0] != root {
+		rootID, refID = "1", "0"
+	}
+
+	// The index shows the referenced TSL below the root
+	status, body, header := browseGet(t, server.URL+"/ui/")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "text/html; charset=utf-8", header.Get("Content-Type"))
+	rootAt := strings.Index(body, `href="/ui/tsl/`+rootID+`"`)
+	refAt := strings.Index(body, `href="/ui/tsl/`+refID+`"`)
+	require.True(t, rootAt >= 0 && refAt > rootAt, body)
+	assert.Contains(t, body[rootAt:refAt], "<ul>")
+	assert.Contains(t, body, "EU - Test Operator")
+	assert.Contains(t, body, "https://example.com/se.xml")
+
+	// The TSL page lists providers and services
+	status, body, _ = browseGet(t, server.URL+"/ui/tsl/"+refID)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, "Test Provider")
+	assert.Contains(t, body, "Referenced Service")
+	assert.Contains(t, body, `<abbr title="http://uri.etsi.org/TrstSvc/Svctype/TSA/QTST">Qualified time-stamping</abbr>`)
+	serviceURL := "/ui/tsl/" + refID + "/provider/0/service/0"
+	assert.Contains(t, body, `href="`+serviceURL+`"`)
+	assert.Contains(t, body, `href="/ui/tsl/`+refID+`/json"`)
+	assert.Contains(t, body, `<a href="mailto:operator@example.com">mailto:operator@example.com</a>`)
+
+	// The TSL is also available in the JSON representation
+	status, body, header = browseGet(t, server.URL+"/ui/tsl/"+refID+"/json")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "application/json", header.Get("Content-Type"))
+	var doc jsonmodel.TSL
+	require.NoError(t, json.Unmarshal([]byte(body), &doc))
+	assert.Equal(t, jsonmodel.Version, doc.Version)
+	assert.Equal(t, "https://example.com/se.xml", doc.Source)
+	assert.Equal(t, "SE", doc.SchemeInformation.Territory)
+	require.Len(t, doc.Providers, 1)
+	assert.Equal(t, "Referenced Service", doc.Providers[0].Services[0].Names[0].Value)
+	assert.Equal(t, TestCert.Raw, doc.Providers[0].Services[0].Identities[0].Certificate.DER)
+
+	// The service page lists certificates with download links
+	status, body, _ = browseGet(t, server.URL+serviceURL)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, "CN=Test Cert")
+	assert.Contains(t, body, `href="`+serviceURL+`/cert/0.pem"`)
+	assert.Contains(t, body, `<a href="mailto:incident@example.com">mailto:incident@example.com</a>`)
+
+	status, body, header = browseGet(t, server.URL+serviceURL+"/cert/0.pem")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "application/x-pem-file", header.Get("Content-Type"))
+	assert.Contains(t, header.Get("Content-Disposition"), "attachment")
+	block, _ := pem.Decode([]byte(body))
+	require.NotNil(t, block)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	require.NoError(t, err)
+	assert.True(t, cert.Equal(TestCert))
+
+	for _, path := range []string{
+		"/ui/tsl/2",
+		"/ui/tsl/x",
+		"/ui/tsl/-1",
+		"/ui/tsl/2/json",
+		"/ui/tsl/0/provider/1/service/0",
+		"/ui/tsl/0/provider/0/service/1",
+		serviceURL + "/cert/1.pem",
+		serviceURL + "/cert/0.der",
+		"/other",
+	} {
+		status, _, _ := browseGet(t, server.URL+path)
+		assert.Equal(t, http.StatusNotFound, status, path)
+	}
+
+	resp, err := http.Post(server.URL+"/ui/", "text/plain", nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
+
+func TestBrowseHandler_Escaping(t *testing.T) {
+	tsl := generateTSL("<script>alert(1)</script>", "http://uri.etsi.org/TrstSvc/Svctype/CA/QC", nil)
+	tsl.StatusList.TslTrustServiceProviderList.TslTrustServiceProvider[0].TslTSPInformation = nil
+	ctx := NewContext()
+	ctx.AddTSL(tsl)
+	server := httptest.NewServer(BrowseHandler("/ui", func() *Context { return ctx }))
+	defer server.Close()
+
+	status, body, _ := browseGet(t, server.URL+"/ui/tsl/0")
+	assert.Equal(t, http.StatusOK, status)
+	assert.NotContains(t, body, "<script>")
+	assert.Contains(t, body, "Provider 0")
+
+	status, body, _ = browseGet(t, server.URL+"/ui/tsl/0/provider/0/service/0")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, "No certificates.")
+}
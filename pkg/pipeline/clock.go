@@ -0,0 +1,39 @@
+package pipeline
+
+import "time"
+
+// Clock is the time source of a pipeline run. Certificates are verified at
+// its time, and it dates what the steps write, such as the pool log entries,
+// the generation times of manifests, reports and pages, and the validity of
+// exported tokens. Setting a FixedClock on a Pipeline or Context makes runs
+// reproducible, for example to rebuild the pool of a past day or in tests.
+// Durations, schedules and cache lifetimes always use the system clock.
+type Clock interface {
+	Now() time.Time
+}
+
+// ClockFunc adapts a function to the Clock interface.
+type ClockFunc func() time.Time
+
+// Now implements Clock.
+func (f ClockFunc) Now() time.Time {
+	return f()
+}
+
+// SystemClock is the Clock returning the current time, used unless another
+// is set.
+var SystemClock Clock = ClockFunc(time.Now)
+
+// FixedClock returns a Clock that is always at t.
+func FixedClock(t time.Time) Clock {
+	return ClockFunc(func() time.Time { return t })
+}
+
+// Now returns the time of the clock of ctx, the current time if it has none
+// (see Context.Clock).
+func (ctx *Context) Now() time.Time {
+	if ctx == nil || ctx.Clock == nil {
+		return time.Now()
+	}
+	return ctx.Clock.Now()
+}
@@ -0,0 +1,71 @@
+package pipeline
+
+import (
+	"encoding/base64"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirosfoundation/g119612/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClock(t *testing.T) {
+	at := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, at, FixedClock(at).Now())
+
+	var none *Context
+	assert.WithinDuration(t, time.Now(), none.Now(), time.Minute)
+	ctx := NewContext()
+	assert.WithinDuration(t, time.Now(), ctx.Now(), time.Minute)
+	ctx.Clock = FixedClock(at)
+	assert.Equal(t, at, ctx.Now())
+	assert.Equal(t, at, ctx.Copy().Now())
+}
+
+func TestPipeline_Clock(t *testing.T) {
+	ca, caKey := monitorTestCert(t, "Listed CA", nil, nil)
+	leaf, _ := monitorTestCert(t, "service.example.com", ca, caKey)
+	later := time.Now().Add(24 * time.Hour).UTC().Truncate(time.Second)
+	log := filepath.Join(t.TempDir(), "pool.log")
+
+	pl := &Pipeline{
+		Pipes: []Pipe{
+			{MethodName: "select", MethodArguments: []string{"pool-log:" + log}},
+			{MethodName: "verify", MethodArguments: []string{"cert:" + base64.StdEncoding.EncodeToString(leaf.Raw), "on-fail:warn"}},
+		},
+		Logger: logging.SilentLogger(),
+		Clock:  FixedClock(later),
+	}
+	ctx := NewContext()
+	ctx.AddTSL(generateTSL("Listed Service", "http://uri.etsi.org/TrstSvc/Svctype/CA/QC",
+		[]string{base64.StdEncoding.EncodeToString(ca.Raw)}))
+	result, err := pl.Process(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, later, result.Now())
+
+	// The certificates are verified after they expired
+	report := VerificationResults(result)
+	require.NotNil(t, report)
+	assert.Equal(t, later, report.GeneratedAt)
+	assert.Equal(t, 1, report.Failed)
+	assert.Contains(t, report.Certificates[0].Error, "expired")
+
+	// and the pool log records the run at the time of the clock
+	poolLog, err := OpenPoolLog(log)
+	require.NoError(t, err)
+	require.NotEmpty(t, poolLog.Entries())
+	for _, entry := range poolLog.Entries() {
+		assert.True(t, later.Equal(entry.Time))
+	}
+
+	// A clock set on the context wins over the one of the pipeline
+	earlier := time.Now().UTC().Truncate(time.Second)
+	ctx = result.Copy()
+	ctx.Clock = FixedClock(earlier)
+	pl.Pipes = pl.Pipes[1:]
+	result, err = pl.Process(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, VerificationResults(result).Passed)
+}
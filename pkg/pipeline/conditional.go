@@ -0,0 +1,206 @@
+package pipeline
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/sirosfoundation/g119612/pkg/etsi119612/uri"
+	"github.com/sirosfoundation/g119612/pkg/logging"
+	"gopkg.in/yaml.v3"
+)
+
+// ConditionalStep is the name of the conditional pipeline step. A conditional
+// step runs its "then" steps when its condition holds for the current context
+// and its "else" steps otherwise, so a pipeline can for example skip publishing
+// when an upstream outage left nothing selected:
+//
+//   - if: "cert-count > 0"
+//     then:
+//   - publish: ["/var/www/tsl"]
+//     else:
+//   - log: ["Nothing selected, keeping the previous publication"]
+//
+// The steps of a branch are run like top-level steps; their StepEvent.Index is
+// the position within the branch.
+const ConditionalStep = "if"
+
+// Condition statistics available in conditional steps.
+const (
+	StatTSLCount     = "tsl-count"     // Number of distinct TSLs in the context, including references
+	StatCertCount    = "cert-count"    // Number of certificates added by the last select step
+	StatServiceCount = "service-count" // Number of trust services in the TSLs of the context
+
+	// StatQualifiedServiceCount is the number of trust services of a qualified
+	// service type, see uri.CategoryQualified.
+	StatQualifiedServiceCount = "qualified-service-count"
+	// StatActiveServiceCount is the number of trust services with an active
+	// status such as granted, see uri.CategoryActive.
+	StatActiveServiceCount = "active-service-count"
+)
+
+// certCountKey is the context data key under which select records StatCertCount.
+const certCountKey = "cert-count"
+
+// Condition is a parsed condition of a conditional step: a context statistic
+// compared with an integer, such as "cert-count > 0".
+type Condition struct {
+	Stat  string // One of the Stat constants, e.g. StatCertCount
+	Op    string // One of ==, !=, <, <=, > and >=
+	Value int
+}
+
+// ParseCondition parses a condition of the form "<stat> <op> <integer>".
+// The parts must be separated by whitespace.
+func ParseCondition(s string) (Condition, error) {
+	parts := strings.Fields(s)
+	if len(parts) != 3 {
+		return Condition{}, fmt.Errorf("invalid condition %q: expected \"<stat> <op> <integer>\"", s)
+	}
+	c := Condition{Stat: parts[0], Op: parts[1]}
+	switch c.Stat {
+	case StatTSLCount, StatCertCount, StatServiceCount, StatQualifiedServiceCount, StatActiveServiceCount:
+	default:
+		return Condition{}, fmt.Errorf("invalid condition %q: unknown statistic %q (expected %s, %s, %s, %s or %s)",
+			s, c.Stat, StatTSLCount, StatCertCount, StatServiceCount, StatQualifiedServiceCount, StatActiveServiceCount)
+	}
+	switch c.Op {
+	case "==", "!=", "<", "<=", ">", ">=":
+	default:
+		return Condition{}, fmt.Errorf("invalid condition %q: unknown operator %q", s, c.Op)
+	}
+	value, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return Condition{}, fmt.Errorf("invalid condition %q: %q is not an integer", s, parts[2])
+	}
+	c.Value = value
+	return c, nil
+}
+
+// String returns the condition in the form ParseCondition accepts.
+func (c Condition) String() string {
+	return fmt.Sprintf("%s %s %d", c.Stat, c.Op, c.Value)
+}
+
+// Evaluate reports whether the condition holds for ctx.
+func (c Condition) Evaluate(ctx *Context) bool {
+	actual := ContextStats(ctx)[c.Stat]
+	switch c.Op {
+	case "==":
+		return actual == c.Value
+	case "!=":
+		return actual != c.Value
+	case "<":
+		return actual < c.Value
+	case "<=":
+		return actual <= c.Value
+	case ">":
+		return actual > c.Value
+	case ">=":
+		return actual >= c.Value
+	}
+	return false
+}
+
+// ContextStats returns the statistics conditions are evaluated against.
+// Service types and statuses are categorized with uri.Category.
+func ContextStats(ctx *Context) map[string]int {
+	stats := map[string]int{
+		StatTSLCount:              0,
+		StatCertCount:             0,
+		StatServiceCount:          0,
+		StatQualifiedServiceCount: 0,
+		StatActiveServiceCount:    0,
+	}
+	if ctx == nil {
+		return stats
+	}
+	for _, tsl := range publishableTSLs(ctx) {
+		stats[StatTSLCount]++
+		if tsl.StatusList.TslTrustServiceProviderList == nil {
+			continue
+		}
+		for _, tsp := range tsl.StatusList.TslTrustServiceProviderList.TslTrustServiceProvider {
+			if tsp == nil || tsp.TslTSPServices == nil {
+				continue
+			}
+			stats[StatServiceCount] += len(tsp.TslTSPServices.TslTSPService)
+			for _, svc := range tsp.TslTSPServices.TslTSPService {
+				if svc == nil || svc.TslServiceInformation == nil {
+					continue
+				}
+				if uri.Category(svc.TslServiceInformation.TslServiceTypeIdentifier) == uri.CategoryQualified {
+					stats[StatQualifiedServiceCount]++
+				}
+				if uri.Category(svc.TslServiceInformation.TslServiceStatus) == uri.CategoryActive {
+					stats[StatActiveServiceCount]++
+				}
+			}
+		}
+	}
+	if count, ok := dataValue[int](ctx, certCountKey); ok && ctx.CertPool != nil {
+		stats[StatCertCount] = count
+	}
+	return stats
+}
+
+// recordCertCount records the number of certificates selected into the pool
+// of ctx for StatCertCount.
+func recordCertCount(ctx *Context, count int) {
+	ctx.SetData(certCountKey, count)
+}
+
+// processConditional runs the branch of a conditional step selected by its condition.
+func (pl *Pipeline) processConditional(ctx *Context, pipe Pipe, cursor *stepCursor) (*Context, error) {
+	condition, err := ParseCondition(pipe.Condition)
+	if err != nil {
+		return ctx, fmt.Errorf("%w: %v", ErrInvalidArguments, err)
+	}
+	holds := condition.Evaluate(ctx)
+	if pl.Logger != nil {
+		pl.Logger.Info("Evaluated condition",
+			logging.F("condition", condition.String()),
+			logging.F("result", holds))
+	}
+
+	branch, name := pipe.Then, "then"
+	if !holds {
+		branch, name = pipe.Else, "else"
+	}
+	ctx, err = pl.processPipes(ctx, branch, cursor)
+	if err != nil {
+		return ctx, fmt.Errorf("%s: %w", name, err)
+	}
+	return ctx, nil
+}
+
+// unmarshalConditional decodes the key and value nodes of a conditional step
+// mapping with the keys "if", "then" and optionally "else".
+func (p *Pipe) unmarshalConditional(content []*yaml.Node) error {
+	p.MethodName = ConditionalStep
+	for i := 0; i+1 < len(content); i += 2 {
+		key, node := content[i].Value, content[i+1]
+		switch key {
+		case "if":
+			if node.Kind != yaml.ScalarNode {
+				return &yaml.TypeError{Errors: []string{"Condition of an if step must be a string"}}
+			}
+			p.Condition = node.Value
+			p.MethodArguments = []string{node.Value}
+		case "then":
+			if err := node.Decode(&p.Then); err != nil {
+				return err
+			}
+		case "else":
+			if err := node.Decode(&p.Else); err != nil {
+				return err
+			}
+		default:
+			return &yaml.TypeError{Errors: []string{fmt.Sprintf("Unknown key %q in if step (expected then or else)", key)}}
+		}
+	}
+	if p.Then == nil {
+		return &yaml.TypeError{Errors: []string{"An if step requires a then list"}}
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package pipeline
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sirosfoundation/g119612/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// loadTestPipeline writes yaml to a file and loads it with NewPipeline.
+func loadTestPipeline(t *testing.T, yaml string) (*Pipeline, error) {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "pipeline.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(yaml), 0600))
+	pl, err := NewPipeline(file)
+	if err != nil {
+		return nil, err
+	}
+	return pl.WithLogger(logging.SilentLogger()), nil
+}
+
+func TestParseCondition(t *testing.T) {
+	c, err := ParseCondition(" cert-count   >=  2 ")
+	require.NoError(t, err)
+	assert.Equal(t, Condition{Stat: StatCertCount, Op: ">=", Value: 2}, c)
+	assert.Equal(t, "cert-count >= 2", c.String())
+
+	for _, s := range []string{"", "cert-count>0", "cert-count > ", "certs > 0", "tsl-count => 1", "tsl-count > many", "tsl-count > 0 extra"} {
+		_, err := ParseCondition(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestConditionEvaluate(t *testing.T) {
+	ctx := NewContext()
+	stats := ContextStats(ctx)
+	assert.Equal(t, map[string]int{StatTSLCount: 0, StatCertCount: 0, StatServiceCount: 0,
+		StatQualifiedServiceCount: 0, StatActiveServiceCount: 0}, stats)
+
+	ctx.AddTSL(generateTSL("Test Service", "http://uri.etsi.org/TrstSvc/Svctype/CA/QC", []string{TestCertBase64}))
+	ctx, err := selectCertPool(&Pipeline{Logger: logging.SilentLogger()}, ctx)
+	require.NoError(t, err)
+	stats = ContextStats(ctx)
+	assert.Equal(t, 1, stats[StatTSLCount])
+	assert.Equal(t, 1, stats[StatServiceCount])
+	assert.Equal(t, 1, stats[StatCertCount])
+	assert.Equal(t, 1, stats[StatQualifiedServiceCount])
+	assert.Equal(t, 1, stats[StatActiveServiceCount])
+
+	cases := map[string]bool{
+		"qualified-service-count == 1": true,
+		"active-service-count > 0":     true,
+		"cert-count > 0":               true,
+		"cert-count == 1":              true,
+		"cert-count != 1":              false,
+		"cert-count < 1":               false,
+		"cert-count <= 1":              true,
+		"tsl-count >= 2":               false,
+	}
+	for s, want := range cases {
+		c, err := ParseCondition(s)
+		require.NoError(t, err)
+		assert.Equal(t, want, c.Evaluate(ctx), s)
+	}
+
+	// Services of other types and inactive statuses are not counted
+	tsa := generateTSL("TSA", "http://uri.etsi.org/TrstSvc/Svctype/TSA", nil)
+	tsa.StatusList.TslTrustServiceProviderList.TslTrustServiceProvider[0].TslTSPServices.TslTSPService[0].
+		TslServiceInformation.TslServiceStatus = "http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/withdrawn"
+	stats = ContextStats(NewContext().AddTSL(tsa))
+	assert.Equal(t, 1, stats[StatServiceCount])
+	assert.Equal(t, 0, stats[StatQualifiedServiceCount])
+	assert.Equal(t, 0, stats[StatActiveServiceCount])
+}
+
+func TestProcessConditional(t *testing.T) {
+	pl, err := loadTestPipeline(t, `
+- echo: ["before"]
+- if: "cert-count > 0"
+  then:
+    - echo: ["then"]
+  else:
+    - echo: ["else"]
+    - if: "tsl-count == 0"
+      then:
+        - echo: ["nested"]
+- echo: ["after"]
+`)
+	require.NoError(t, err)
+	require.Len(t, pl.Pipes, 3)
+	assert.Equal(t, ConditionalStep, pl.Pipes[1].MethodName)
+	assert.Equal(t, []string{"cert-count > 0"}, pl.Pipes[1].MethodArguments)
+
+	var ran []string
+	pl.AddEventSink(EventSinkFuncs{StepStart: func(event StepEvent) {
+		ran = append(ran, event.Name+":"+strings.Join(event.Args, ","))
+	}})
+
+	// Nothing selected
+	_, err = pl.Process(NewContext())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"echo:before", "if:cert-count > 0", "echo:else", "if:tsl-count == 0", "echo:nested", "echo:after"}, ran)
+
+	// A certificate selected
+	ran = nil
+	ctx := NewContext()
+	ctx.AddTSL(generateTSL("Test Service", "http://uri.etsi.org/TrstSvc/Svctype/CA/QC", []string{TestCertBase64}))
+	ctx, err = selectCertPool(pl, ctx)
+	require.NoError(t, err)
+	_, err = pl.Process(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"echo:before", "if:cert-count > 0", "echo:then", "echo:after"}, ran)
+}
+
+func TestProcessConditional_Errors(t *testing.T) {
+	// Errors in a branch name the branch
+	pl, err := loadTestPipeline(t, `
+- if: "tsl-count == 0"
+  then:
+    - no-such-step: []
+`)
+	require.NoError(t, err)
+	_, err = pl.Process(NewContext())
+	assert.ErrorContains(t, err, "step 0 (if) failed: then: step 0: unknown methodName 'no-such-step'")
+
+	invalid := map[string]string{
+		"bad condition":    "- if: \"certs > 0\"\n  then: []\n",
+		"missing then":     "- if: \"cert-count > 0\"\n",
+		"unknown key":      "- if: \"cert-count > 0\"\n  then: []\n  otherwise: []\n",
+		"invalid in else":  "- if: \"cert-count > 0\"\n  then: []\n  else:\n    - render: []\n",
+		"condition a list": "- if: [\"cert-count > 0\"]\n  then: []\n",
+	}
+	for name, yaml := range invalid {
+		_, err := loadTestPipeline(t, yaml)
+		assert.Error(t, err, name)
+	}
+	_, err = loadTestPipeline(t, invalid["invalid in else"])
+	assert.ErrorIs(t, err, ErrInvalidArguments)
+	assert.ErrorContains(t, err, "step 0 (if) else: step 0 (render)")
+
+	// Explain lists the conditional step without choosing a branch
+	pl, err = loadTestPipeline(t, "- if: \"cert-count > 0\"\n  then:\n    - echo: []\n")
+	require.NoError(t, err)
+	steps, err := Explain(pl)
+	require.NoError(t, err)
+	require.Len(t, steps, 1)
+	assert.Equal(t, ConditionalStep, steps[0].Name)
+	assert.False(t, steps[0].Evaluated)
+}
@@ -2,6 +2,9 @@ package pipeline
 
 import (
 	"crypto/x509"
+	"fmt"
+	"slices"
+	"sync"
 	"time"
 
 	"github.com/sirosfoundation/g119612/pkg/etsi119612"
@@ -11,12 +14,26 @@ import (
 // Context holds the shared state passed between pipeline steps during processing.
 // It contains Trust Status Lists (TSLs) and certificate pools that are created,
 // modified, and consumed by different pipeline steps.
+//
+// Concurrency: the TSL stacks and the data store accessed through GetData,
+// SetData, UpdateData and DeleteData are safe for concurrent use, so steps
+// running in parallel on one Context may share them. Reading or writing the
+// Data map directly is not synchronized and must only be done while no other
+// step uses the Context. The remaining fields are not synchronized: they are
+// set by the steps that own them (select for CertPool and VerifyOptions,
+// set-fetch-options for TSLFetchOptions) and must not be changed while other
+// steps use the Context; steps that need different values should work on a
+// Copy. A Context must not be copied by value.
 type Context struct {
 	TSLTrees        *utils.Stack[*TSLTree]        // A stack of TSL trees, where each tree represents a loaded root TSL and its references
 	TSLs            *utils.Stack[*etsi119612.TSL] // DEPRECATED: Legacy stack of TSLs for backward compatibility
 	CertPool        *x509.CertPool                // Certificate pool for trust verification
-	Data            map[string]any                // Data store for sharing information between pipeline steps
+	VerifyOptions   *x509.VerifyOptions           // Verification options for CertPool built by select, see Verify
+	Data            map[string]any                // Data store for sharing information between pipeline steps, see GetData
 	TSLFetchOptions *etsi119612.TSLFetchOptions   // Options for fetching Trust Status Lists
+	Clock           Clock                         // Time source of the steps, the system clock if nil, see Now
+
+	mu sync.RWMutex // guards Data
 }
 
 // EnsureTSLTrees ensures that the TSL tree stack is initialized.
@@ -122,7 +139,8 @@ func (ctx *Context) EnsureTSLStack() *Context {
 }
 
 // EnsureTSLFetchOptions ensures that the TSLFetchOptions are initialized.
-// If the options don't exist, it creates new ones with default values.
+// If the options don't exist, it creates new ones with default values,
+// including a FetchCache shared by all load steps using this context.
 //
 // This method is used by pipeline steps to guarantee that the TSLFetchOptions
 // are available before using them, preventing nil pointer exceptions.
@@ -134,6 +152,7 @@ func (ctx *Context) EnsureTSLFetchOptions() *Context {
 		ctx.TSLFetchOptions = &etsi119612.TSLFetchOptions{
 			UserAgent: "Go-Trust/1.0 Pipeline (+https://github.com/sirosfoundation/go-trust)",
 			Timeout:   30 * time.Second,
+			Cache:     etsi119612.NewFetchCache(),
 		}
 	}
 	return ctx
@@ -160,6 +179,7 @@ func (ctx *Context) InitCertPool() *Context {
 // - A new stack of TSL trees with the same trees
 // - A new legacy stack of TSLs with the same TSLs
 // - A new certificate pool with the same certificates (if present)
+// - The same VerifyOptions reference (they refer to the original certificate pool)
 // - A new Data map with the same contents
 // - The same TSLFetchOptions reference (since it's typically read-only)
 //
@@ -195,13 +215,19 @@ func (ctx *Context) Copy() *Context {
 		// The actual cert pool will be reconstructed by SelectCertPool or similar functions
 	}
 
+	// Share the verification options of the last select
+	newCtx.VerifyOptions = ctx.VerifyOptions
+
 	// Copy data map
+	ctx.mu.RLock()
 	for k, v := range ctx.Data {
 		newCtx.Data[k] = v
 	}
+	ctx.mu.RUnlock()
 
 	// Share the TSLFetchOptions reference
 	newCtx.TSLFetchOptions = ctx.TSLFetchOptions
+	newCtx.Clock = ctx.Clock
 
 	return newCtx
 }
@@ -220,13 +246,127 @@ func NewContext() *Context {
 	}
 }
 
+// GetData returns the value stored in the data store under key and whether
+// there is one. It is safe for concurrent use.
+func (ctx *Context) GetData(key string) (any, bool) {
+	ctx.mu.RLock()
+	defer ctx.mu.RUnlock()
+	value, ok := ctx.Data[key]
+	return value, ok
+}
+
+// SetData stores value in the data store under key, creating the store if
+// needed. It is safe for concurrent use.
+func (ctx *Context) SetData(key string, value any) {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	if ctx.Data == nil {
+		ctx.Data = make(map[string]any)
+	}
+	ctx.Data[key] = value
+}
+
+// UpdateData replaces the value stored under key by the result of update,
+// which is called with the current value, nil if there is none. Other
+// accessors wait until update returns, so read-modify-write sequences are
+// atomic. update must not access the data store of ctx itself.
+func (ctx *Context) UpdateData(key string, update func(value any) any) {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	if ctx.Data == nil {
+		ctx.Data = make(map[string]any)
+	}
+	ctx.Data[key] = update(ctx.Data[key])
+}
+
+// DeleteData removes key from the data store. It is safe for concurrent use.
+func (ctx *Context) DeleteData(key string) {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	delete(ctx.Data, key)
+}
+
+// dataValue returns the value stored under key if it has type T.
+func dataValue[T any](ctx *Context, key string) (T, bool) {
+	value, _ := ctx.GetData(key)
+	typed, ok := value.(T)
+	return typed, ok
+}
+
 // GetCertPool returns the certificate pool from the context.
 // This implements the PipelineContextProvider interface used by etsi.PipelineBackedRegistry.
 func (ctx *Context) GetCertPool() *x509.CertPool {
 	return ctx.CertPool
 }
 
-// GetTSLs returns all TSLs from the context as a slice.
+// Verify verifies a certificate against the trust anchors selected by the last
+// select step, using its VerifyOptions: the selected certificate pool as roots,
+// the extended key usages of the selected service types and, if the select step
+// had an "at:" option, the verification time. Without one, certificates are
+// verified at the time of the Clock of the context. The options in the context
+// are not modified.
+//
+// Parameters:
+//   - leaf: The certificate to verify
+//   - intermediates: Untrusted intermediate certificates available for building chains
+//
+// Returns:
+//   - [][]*x509.Certificate: The verified chains, each starting with leaf and ending in a selected certificate
+//   - error: ErrNoCertPool if no select step ran, otherwise the error of x509.Certificate.Verify
+func (ctx *Context) Verify(leaf *x509.Certificate, intermediates ...*x509.Certificate) ([][]*x509.Certificate, error) {
+	if ctx.VerifyOptions == nil || ctx.VerifyOptions.Roots == nil {
+		return nil, ErrNoCertPool
+	}
+	if leaf == nil {
+		return nil, fmt.Errorf("%w: no certificate to verify", ErrInvalidArguments)
+	}
+	opts := *ctx.VerifyOptions
+	if opts.CurrentTime.IsZero() && ctx.Clock != nil {
+		opts.CurrentTime = ctx.Clock.Now()
+	}
+	opts.Intermediates = x509.NewCertPool()
+	if ctx.VerifyOptions.Intermediates != nil {
+		opts.Intermediates = ctx.VerifyOptions.Intermediates.Clone()
+	}
+	for _, cert := range intermediates {
+		if cert != nil {
+			opts.Intermediates.AddCert(cert)
+		}
+	}
+	return leaf.Verify(opts)
+}
+
+// Listings returns the services of the TSLs in the context that list cert,
+// for example to find out which trust service anchors a chain returned by
+// Verify. Certificates added with extra-roots are not listed in a TSL, see
+// LocalTrustAnchors.
+func (ctx *Context) Listings(cert *x509.Certificate) []CertificateListing {
+	if cert == nil {
+		return nil
+	}
+	var listings []CertificateListing
+	for _, tsl := range contextTSLs(ctx) {
+		if tsl == nil {
+			continue
+		}
+		tsl.WithTrustServices(func(tsp *etsi119612.TSPType, svc *etsi119612.TSPServiceType) {
+			svc.WithCertificates(func(listed *x509.Certificate) {
+				if !listed.Equal(cert) {
+					return
+				}
+				listing := newCertificateListing(tsl, tsp, svc)
+				if !slices.Contains(listings, listing) {
+					listings = append(listings, listing)
+				}
+			})
+		})
+	}
+	return listings
+}
+
+// GetTSLs returns all TSLs from the context as a slice, in the order of the
+// legacy stack: for each loaded tree its referenced TSLs in reverse pre-order,
+// then its root. The order only depends on the loaded documents.
 // This implements the PipelineContextProvider interface used by etsi.PipelineBackedRegistry.
 func (ctx *Context) GetTSLs() []*etsi119612.TSL {
 	if ctx.TSLs == nil {
@@ -243,3 +383,16 @@ func (ctx *Context) GetTSLCount() int {
 	}
 	return ctx.TSLs.Size()
 }
+
+// FetchInfos returns how the TSLs of the context were fetched, see
+// etsi119612.FetchInfo. TSLs that were not fetched, such as generated ones,
+// are left out.
+func (ctx *Context) FetchInfos() []etsi119612.FetchInfo {
+	var infos []etsi119612.FetchInfo
+	for _, tsl := range ctx.GetTSLs() {
+		if tsl != nil && tsl.FetchInfo != nil {
+			infos = append(infos, *tsl.FetchInfo)
+		}
+	}
+	return infos
+}
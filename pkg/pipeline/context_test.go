@@ -2,6 +2,8 @@ package pipeline
 
 import (
 	"crypto/x509"
+	"fmt"
+	"sync"
 	"testing"
 
 	etsi119612 "github.com/sirosfoundation/g119612/pkg/etsi119612"
@@ -192,3 +194,68 @@ func TestContext_Copy_DeepCopy(t *testing.T) {
 		assert.NotSame(t, original.CertPool, copied.CertPool)
 	})
 }
+
+func TestContextListings(t *testing.T) {
+	ctx := NewContext()
+	assert.Empty(t, ctx.Listings(TestCert))
+	assert.Nil(t, ctx.Listings(nil))
+
+	tsl := generateTSL("Test Service", "http://uri.etsi.org/TrstSvc/Svctype/CA/QC", []string{TestCertBase64})
+	tsl.Source = "https://example.com/tsl.xml"
+	ctx.AddTSL(tsl)
+	ctx.AddTSL(generateTSL("Other Service", "http://uri.etsi.org/TrstSvc/Svctype/CA/QC", nil))
+
+	// The TSL is listed once although AddTSL pushes it twice to the legacy stack
+	assert.Equal(t, []CertificateListing{{
+		TSL:      "https://example.com/tsl.xml",
+		Provider: "Test Provider",
+		Service:  "Test Service",
+		Status:   etsi119612.ServiceStatusGranted,
+	}}, ctx.Listings(TestCert))
+}
+
+func TestContextData(t *testing.T) {
+	ctx := &Context{}
+	_, ok := ctx.GetData("missing")
+	assert.False(t, ok)
+
+	ctx.SetData("key", "value")
+	value, ok := ctx.GetData("key")
+	assert.True(t, ok)
+	assert.Equal(t, "value", value)
+	typed, ok := dataValue[string](ctx, "key")
+	assert.True(t, ok)
+	assert.Equal(t, "value", typed)
+	_, ok = dataValue[int](ctx, "key")
+	assert.False(t, ok)
+
+	ctx.DeleteData("key")
+	_, ok = ctx.GetData("key")
+	assert.False(t, ok)
+
+	// Concurrent steps can share the data store and the stacks
+	ctx = NewContext()
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range 50 {
+				ctx.SetData(fmt.Sprintf("step-%d", i), j)
+				ctx.UpdateData("counter", func(value any) any {
+					count, _ := value.(int)
+					return count + 1
+				})
+				ctx.GetData("counter")
+				ctx.AddTSL(generateTSL("Service", "http://uri.etsi.org/TrstSvc/Svctype/CA/QC", nil))
+				ctx.Copy()
+			}
+		}()
+	}
+	wg.Wait()
+	counter, _ := dataValue[int](ctx, "counter")
+	assert.Equal(t, 400, counter)
+	assert.Equal(t, 400, ctx.TSLTrees.Size())
+	last, _ := dataValue[int](ctx, "step-3")
+	assert.Equal(t, 49, last)
+}
@@ -4,6 +4,8 @@ package pipeline
 import (
 	"errors"
 	"fmt"
+
+	"github.com/sirosfoundation/g119612/pkg/etsi119612"
 )
 
 // Common sentinel errors for pipeline operations.
@@ -19,6 +21,53 @@ var (
 
 	// ErrFunctionNotFound indicates that a pipeline function was not found in the registry.
 	ErrFunctionNotFound = errors.New("pipeline function not found")
+
+	// ErrNoCertPool indicates that no select step has built a certificate pool in the context.
+	ErrNoCertPool = errors.New("no certificate pool selected")
+
+	// ErrRemoteNewer indicates that the published copy of a TSL is newer than the one to publish.
+	ErrRemoteNewer = errors.New("published TSL is newer than the TSL to publish")
+
+	// ErrRemoteConflict indicates that the published copy of a TSL has the same
+	// sequence number as the one to publish but different content.
+	ErrRemoteConflict = errors.New("published TSL conflicts with the TSL to publish")
+
+	// ErrMirrorMismatch indicates that mirrors of a TSL serve different sequence
+	// numbers or content.
+	ErrMirrorMismatch = errors.New("TSL mirrors do not match")
+
+	// ErrPoolSize indicates that the certificate pool built by select has fewer or
+	// more certificates than its min-certs or max-certs bound allows, as when an
+	// upstream TSL was truncated.
+	ErrPoolSize = errors.New("certificate pool size out of bounds")
+
+	// ErrPipelineSignature indicates that a pipeline file lacks a valid signature
+	// by a trusted signer (see NewSignedPipeline).
+	ErrPipelineSignature = errors.New("pipeline signature verification failed")
+
+	// ErrStepOrder indicates that a pipeline step is bound to fail because of its
+	// position, such as a select step before any load step (see Pipeline.Check).
+	ErrStepOrder = errors.New("pipeline step out of order")
+
+	// ErrPoolLogCorrupt indicates that a pool log does not parse or its hash
+	// chain is broken, as when a recorded entry was changed or removed.
+	ErrPoolLogCorrupt = errors.New("pool log is corrupt")
+
+	// ErrDeadLinks indicates that the check-links step found links to missing
+	// files or unreachable distribution points.
+	ErrDeadLinks = errors.New("dead links found")
+
+	// ErrVerificationFailed indicates that certificates given to the verify
+	// step do not chain to the certificate pool.
+	ErrVerificationFailed = errors.New("certificates do not verify")
+
+	// ErrSchemaValidation indicates that the validate-schema step found TSLs
+	// that do not conform to the schema or the scheme rules.
+	ErrSchemaValidation = errors.New("TSLs failed schema validation")
+
+	// ErrReadOnly indicates that a typed step API that writes files was
+	// called with a read-only pipeline (see WithPipeline).
+	ErrReadOnly = errors.New("pipeline is read-only")
 )
 
 // TSLLoadError represents an error that occurred while loading a TSL.
@@ -39,6 +88,12 @@ func (e *TSLLoadError) Unwrap() error {
 	return e.Err
 }
 
+// Transient reports whether the load failed with an etsi119612.TransientError,
+// such as a timeout or an HTTP 5xx response, and may succeed when run again.
+func (e *TSLLoadError) Transient() bool {
+	return etsi119612.IsTransient(e.Err)
+}
+
 // NewTSLLoadError creates a new TSLLoadError.
 func NewTSLLoadError(url string, err error) *TSLLoadError {
 	return &TSLLoadError{
@@ -57,15 +112,32 @@ func NewTSLLoadErrorWithReason(url, reason string, err error) *TSLLoadError {
 }
 
 // XSLTTransformError represents an error that occurred during XSLT transformation.
+// When the transformation ran xsltproc, its exit code and output are attached.
 type XSLTTransformError struct {
-	StylesheetPath string // Path to the XSLT stylesheet
-	TSLIndex       int    // Index of the TSL being transformed
-	Err            error  // The underlying error
+	StylesheetPath string                 // Path to the XSLT stylesheet
+	TSLIndex       int                    // Index of the TSL being transformed
+	TSL            etsi119612.TSLIdentity // Identity of the TSL being transformed, if known
+	ExitCode       int                    // Exit code of xsltproc, 0 if unknown
+	Stdout         string                 // Truncated standard output of xsltproc
+	Stderr         string                 // Truncated standard error of xsltproc
+	KeptInput      string                 // Path of the kept input document (transform keep-failed option)
+	Err            error                  // The underlying error
 }
 
 func (e *XSLTTransformError) Error() string {
-	return fmt.Sprintf("XSLT transformation failed for TSL %d using stylesheet %s: %v",
-		e.TSLIndex, e.StylesheetPath, e.Err)
+	tsl := fmt.Sprintf("TSL %d", e.TSLIndex)
+	if !e.TSL.IsZero() {
+		tsl += " (" + e.TSL.String() + ")"
+	}
+	msg := fmt.Sprintf("XSLT transformation failed for %s using stylesheet %s: %v",
+		tsl, e.StylesheetPath, e.Err)
+	if e.ExitCode != 0 {
+		msg += fmt.Sprintf(" (exit code %d)", e.ExitCode)
+	}
+	if e.KeptInput != "" {
+		msg += fmt.Sprintf(" (input kept in %s)", e.KeptInput)
+	}
+	return msg
 }
 
 func (e *XSLTTransformError) Unwrap() error {
@@ -109,12 +181,16 @@ func NewValidationError(field, value, message string) *ValidationError {
 
 // PublishError represents an error that occurred while publishing TSLs.
 type PublishError struct {
-	OutputPath string // The output path where publishing failed
-	TSLCount   int    // Number of TSLs attempted to publish
-	Err        error  // The underlying error
+	OutputPath string                 // The output path where publishing failed
+	TSLCount   int                    // Number of TSLs attempted to publish
+	TSL        etsi119612.TSLIdentity // Identity of the TSL that failed, if a single one
+	Err        error                  // The underlying error
 }
 
 func (e *PublishError) Error() string {
+	if !e.TSL.IsZero() {
+		return fmt.Sprintf("failed to publish TSL %s to %s: %v", e.TSL, e.OutputPath, e.Err)
+	}
 	return fmt.Sprintf("failed to publish %d TSL(s) to %s: %v", e.TSLCount, e.OutputPath, e.Err)
 }
 
@@ -131,6 +207,17 @@ func NewPublishError(outputPath string, tslCount int, err error) *PublishError {
 	}
 }
 
+// NewTSLPublishError creates a PublishError for a single TSL, identified by
+// its territory, operator, sequence number and source.
+func NewTSLPublishError(outputPath string, tsl *etsi119612.TSL, err error) *PublishError {
+	return &PublishError{
+		OutputPath: outputPath,
+		TSLCount:   1,
+		TSL:        tsl.Identity(),
+		Err:        err,
+	}
+}
+
 // CertificateError represents an error related to certificate processing.
 type CertificateError struct {
 	Operation string // The operation that failed (e.g., "parse", "validate")
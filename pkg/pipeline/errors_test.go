@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/sirosfoundation/g119612/pkg/etsi119612"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -60,6 +61,14 @@ func TestTSLLoadError(t *testing.T) {
 		// Should be able to use errors.Is
 		assert.True(t, errors.Is(err, baseErr))
 	})
+
+	t.Run("Transient", func(t *testing.T) {
+		err := NewTSLLoadError("https://example.com/tsl.xml", &etsi119612.TransientError{StatusCode: 503, Err: errors.New("unexpected HTTP status: 503")})
+		assert.True(t, err.Transient())
+
+		err = NewTSLLoadError("https://example.com/tsl.xml", &etsi119612.PermanentError{StatusCode: 404, Err: errors.New("unexpected HTTP status: 404")})
+		assert.False(t, err.Transient())
+	})
 }
 
 func TestXSLTTransformError(t *testing.T) {
@@ -82,6 +91,12 @@ func TestXSLTTransformError(t *testing.T) {
 		assert.Equal(t, baseErr, unwrapped)
 	})
 
+	t.Run("With TSL identity", func(t *testing.T) {
+		err := NewXSLTTransformError("style.xslt", 3, errors.New("xsltproc failed"))
+		err.TSL = etsi119612.TSLIdentity{Territory: "DE", Sequence: 7, Source: "https://example.com/DE.xml"}
+		assert.Contains(t, err.Error(), "TSL 3 (DE sequence 7 from https://example.com/DE.xml) using stylesheet style.xslt")
+	})
+
 	t.Run("Error formatting", func(t *testing.T) {
 		baseErr := errors.New("transformation failed")
 		err := NewXSLTTransformError("/path/to/stylesheet.xslt", 10, baseErr)
@@ -147,6 +162,17 @@ func TestPublishError(t *testing.T) {
 
 		assert.Contains(t, err.Error(), "100 TSL(s)")
 	})
+
+	t.Run("Single TSL", func(t *testing.T) {
+		baseErr := errors.New("disk full")
+		tsl := &etsi119612.TSL{Source: "https://example.com/FR.xml"}
+		tsl.StatusList.TslSchemeInformation = &etsi119612.TSLSchemeInformationType{TslSchemeTerritory: "FR", TSLSequenceNumber: 12}
+		err := NewTSLPublishError("/output/FR.xml", tsl, baseErr)
+
+		assert.Equal(t, "failed to publish TSL FR sequence 12 from https://example.com/FR.xml to /output/FR.xml: disk full", err.Error())
+		assert.Equal(t, 1, err.TSLCount)
+		assert.ErrorIs(t, err, baseErr)
+	})
 }
 
 func TestCertificateError(t *testing.T) {
@@ -0,0 +1,179 @@
+package pipeline
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/sirosfoundation/g119612/pkg/logging"
+)
+
+// StepEvent describes the execution of a single pipeline step.
+// OnStepStart receives an event with only Index, Name, Args, Started and Context set;
+// OnStepEnd additionally receives Duration and Err.
+type StepEvent struct {
+	Index    int           // Zero-based position of the step in the pipeline
+	Name     string        // Registered function name of the step
+	Args     []string      // Arguments passed to the step
+	Started  time.Time     // Time the step started
+	Duration time.Duration // Time the step took (OnStepEnd only)
+	Err      error         // Error returned by the step, nil on success (OnStepEnd only)
+	Context  *Context      // Context before (OnStepStart) or after (OnStepEnd) the step
+}
+
+// WarningEvent describes a warning emitted through the pipeline logger while a step runs.
+type WarningEvent struct {
+	Index   int             // Zero-based position of the step emitting the warning
+	Name    string          // Registered function name of the step
+	Message string          // Warning message
+	Fields  []logging.Field // Structured fields attached to the warning
+
+	// Fingerprint identifies the warning across runs, see WarningFingerprint
+	// and WarningBaseline.
+	Fingerprint string
+}
+
+// EventSink receives structured events while a pipeline is processed.
+// It allows embedding applications to implement metrics, auditing or UI
+// updates without wrapping the Process loop. Sinks are called synchronously
+// from Process and should return quickly.
+type EventSink interface {
+	OnStepStart(event StepEvent)
+	OnStepEnd(event StepEvent)
+	OnWarning(event WarningEvent)
+}
+
+// EventSinkFuncs adapts plain functions to the EventSink interface.
+// Nil functions are ignored.
+type EventSinkFuncs struct {
+	StepStart func(event StepEvent)
+	StepEnd   func(event StepEvent)
+	Warning   func(event WarningEvent)
+}
+
+// OnStepStart implements EventSink.
+func (f EventSinkFuncs) OnStepStart(event StepEvent) {
+	if f.StepStart != nil {
+		f.StepStart(event)
+	}
+}
+
+// OnStepEnd implements EventSink.
+func (f EventSinkFuncs) OnStepEnd(event StepEvent) {
+	if f.StepEnd != nil {
+		f.StepEnd(event)
+	}
+}
+
+// OnWarning implements EventSink.
+func (f EventSinkFuncs) OnWarning(event WarningEvent) {
+	if f.Warning != nil {
+		f.Warning(event)
+	}
+}
+
+// AddEventSink registers an EventSink that is notified of step and warning events.
+// Sinks are called in registration order.
+func (pl *Pipeline) AddEventSink(sink EventSink) {
+	if sink != nil {
+		pl.sinks = append(pl.sinks, sink)
+	}
+}
+
+// OnStepStart registers a callback invoked before each step is executed.
+func (pl *Pipeline) OnStepStart(fn func(event StepEvent)) {
+	pl.AddEventSink(EventSinkFuncs{StepStart: fn})
+}
+
+// OnStepEnd registers a callback invoked after each step has executed, including failed steps.
+func (pl *Pipeline) OnStepEnd(fn func(event StepEvent)) {
+	pl.AddEventSink(EventSinkFuncs{StepEnd: fn})
+}
+
+// OnWarning registers a callback invoked for every warning logged by a step.
+func (pl *Pipeline) OnWarning(fn func(event WarningEvent)) {
+	pl.AddEventSink(EventSinkFuncs{Warning: fn})
+}
+
+func (pl *Pipeline) emitStepStart(event StepEvent) {
+	for _, sink := range pl.sinks {
+		sink.OnStepStart(event)
+	}
+}
+
+func (pl *Pipeline) emitStepEnd(event StepEvent) {
+	for _, sink := range pl.sinks {
+		sink.OnStepEnd(event)
+	}
+}
+
+func (pl *Pipeline) emitWarning(event WarningEvent) {
+	for _, sink := range pl.sinks {
+		sink.OnWarning(event)
+	}
+}
+
+// stepCursor tracks the step currently executed by Process so that warnings
+// can be attributed to it.
+type stepCursor struct {
+	index int
+	name  string
+}
+
+// eventLogger wraps the pipeline logger and forwards warnings to the
+// registered event sinks in addition to logging them.
+type eventLogger struct {
+	logging.Logger
+	pl     *Pipeline
+	cursor *stepCursor
+	fields []logging.Field
+}
+
+// Warn logs the warning and emits a WarningEvent. With a baseline, the
+// warning is logged with its fingerprint, and at debug level without an event
+// if the baseline acknowledges it.
+func (l *eventLogger) Warn(msg string, fields ...logging.Field) {
+	all := make([]logging.Field, 0, len(l.fields)+len(fields))
+	all = append(all, l.fields...)
+	all = append(all, fields...)
+	event := WarningEvent{
+		Index:   l.cursor.index,
+		Name:    l.cursor.name,
+		Message: msg,
+		Fields:  all,
+	}
+	event.Fingerprint = WarningFingerprint(event)
+	if l.pl.Baseline != nil {
+		fields = append(slices.Clip(fields), logging.F("fingerprint", event.Fingerprint))
+		if l.pl.Baseline.Contains(event.Fingerprint) {
+			l.Logger.Debug(msg, append(fields, logging.F("acknowledged", true))...)
+			return
+		}
+	}
+	l.Logger.Warn(msg, fields...)
+	l.pl.emitWarning(event)
+}
+
+// WithContext returns a wrapped logger with the given context.
+func (l *eventLogger) WithContext(ctx context.Context) logging.Logger {
+	return &eventLogger{Logger: l.Logger.WithContext(ctx), pl: l.pl, cursor: l.cursor, fields: l.fields}
+}
+
+// WithLevel returns a wrapped child logger logging at level, so that the
+// warnings of steps with their own log level still reach the event sinks.
+func (l *eventLogger) WithLevel(level logging.LogLevel) logging.Logger {
+	return &eventLogger{Logger: logging.WithLevel(l.Logger, level), pl: l.pl, cursor: l.cursor, fields: l.fields}
+}
+
+// WithField returns a wrapped logger with an additional field.
+func (l *eventLogger) WithField(key string, value interface{}) logging.Logger {
+	return l.WithFields(logging.F(key, value))
+}
+
+// WithFields returns a wrapped logger with additional fields.
+func (l *eventLogger) WithFields(fields ...logging.Field) logging.Logger {
+	all := make([]logging.Field, 0, len(l.fields)+len(fields))
+	all = append(all, l.fields...)
+	all = append(all, fields...)
+	return &eventLogger{Logger: l.Logger.WithFields(fields...), pl: l.pl, cursor: l.cursor, fields: all}
+}
@@ -0,0 +1,106 @@
+package pipeline
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/sirosfoundation/g119612/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingSink struct {
+	starts   []StepEvent
+	ends     []StepEvent
+	warnings []WarningEvent
+}
+
+func (r *recordingSink) OnStepStart(event StepEvent)  { r.starts = append(r.starts, event) }
+func (r *recordingSink) OnStepEnd(event StepEvent)    { r.ends = append(r.ends, event) }
+func (r *recordingSink) OnWarning(event WarningEvent) { r.warnings = append(r.warnings, event) }
+
+func TestPipeline_EventSink(t *testing.T) {
+	RegisterFunction("events-warn", func(pl *Pipeline, ctx *Context, args ...string) (*Context, error) {
+		pl.Logger.WithField("source", "test").Warn("something odd", logging.F("arg", args[0]))
+		return ctx, nil
+	})
+	RegisterFunction("events-fail", func(pl *Pipeline, ctx *Context, args ...string) (*Context, error) {
+		return ctx, errors.New("boom")
+	})
+
+	pl := createTestPipeline([]Pipe{
+		{MethodName: "echo"},
+		{MethodName: "events-warn", MethodArguments: []string{"x"}},
+		{MethodName: "events-fail"},
+		{MethodName: "echo"},
+	})
+	origLogger := pl.Logger
+	sink := &recordingSink{}
+	pl.AddEventSink(sink)
+
+	_, err := pl.Process(NewContext())
+	require.Error(t, err)
+
+	// The failing step is reported and later steps are not run
+	require.Len(t, sink.starts, 3)
+	require.Len(t, sink.ends, 3)
+	assert.Equal(t, "events-warn", sink.starts[1].Name)
+	assert.Equal(t, []string{"x"}, sink.starts[1].Args)
+	assert.NoError(t, sink.ends[1].Err)
+	assert.EqualError(t, sink.ends[2].Err, "boom")
+	assert.Equal(t, 2, sink.ends[2].Index)
+
+	require.Len(t, sink.warnings, 1)
+	w := sink.warnings[0]
+	assert.Equal(t, 1, w.Index)
+	assert.Equal(t, "events-warn", w.Name)
+	assert.Equal(t, "something odd", w.Message)
+	assert.Equal(t, []logging.Field{logging.F("source", "test"), logging.F("arg", "x")}, w.Fields)
+
+	// The original logger is restored after processing
+	assert.Same(t, origLogger, pl.Logger)
+}
+
+func TestPipeline_LoggerUnchangedDuringRun(t *testing.T) {
+	var outer *Pipeline
+	var stepLoggers []logging.Logger
+	RegisterFunction("events-outer-logger", func(pl *Pipeline, ctx *Context, args ...string) (*Context, error) {
+		// Others may read the logger of the pipeline while it runs
+		assert.NotSame(t, outer, pl)
+		stepLoggers = append(stepLoggers, pl.Logger, outer.Logger)
+		return ctx, nil
+	})
+	outer = createTestPipeline([]Pipe{
+		{MethodName: "events-outer-logger"},
+		{MethodName: "events-outer-logger", LogLevel: "debug"},
+	})
+	origLogger := outer.Logger
+	outer.AddEventSink(&recordingSink{})
+
+	_, err := outer.Process(NewContext())
+	require.NoError(t, err)
+	require.Len(t, stepLoggers, 4)
+	assert.IsType(t, &eventLogger{}, stepLoggers[0])
+	assert.Same(t, origLogger, stepLoggers[1])
+	assert.IsType(t, &eventLogger{}, stepLoggers[2])
+	assert.Same(t, origLogger, stepLoggers[3])
+}
+
+func TestPipeline_EventCallbacks(t *testing.T) {
+	pl := createTestPipeline([]Pipe{{MethodName: "echo"}, {MethodName: "echo"}})
+
+	var started, ended []int
+	pl.OnStepStart(func(e StepEvent) { started = append(started, e.Index) })
+	pl.OnStepEnd(func(e StepEvent) {
+		assert.GreaterOrEqual(t, e.Duration.Nanoseconds(), int64(0))
+		ended = append(ended, e.Index)
+	})
+	pl.OnWarning(func(e WarningEvent) { t.Errorf("unexpected warning: %s", e.Message) })
+
+	// Sinks survive WithLogger
+	pl = pl.WithLogger(logging.NewLogger(logging.ErrorLevel))
+	_, err := pl.Process(NewContext())
+	require.NoError(t, err)
+	assert.Equal(t, []int{0, 1}, started)
+	assert.Equal(t, []int{0, 1}, ended)
+}
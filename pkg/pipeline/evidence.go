@@ -0,0 +1,198 @@
+package pipeline
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+
+	"github.com/sirosfoundation/g119612/pkg/etsi119612"
+	"github.com/sirosfoundation/g119612/pkg/etsi119612/jsonmodel"
+)
+
+// EvidenceCertificate describes a certificate of the chain of an evidence
+// bundle.
+type EvidenceCertificate struct {
+	Subject      string    `json:"subject"`
+	Issuer       string    `json:"issuer"`
+	SerialNumber string    `json:"serial_number"`
+	NotBefore    time.Time `json:"not_before"`
+	NotAfter     time.Time `json:"not_after"`
+	SHA256       string    `json:"sha256"` // Hex SHA-256 fingerprint of the certificate
+}
+
+// EvidenceTSL describes a TSL listing the anchor of the chain of an evidence
+// bundle. The signed document itself is in the bundle, so that its signature
+// can be verified again later.
+type EvidenceTSL struct {
+	File string `json:"file,omitempty"` // Name of the document in the bundle, "" if it was not kept
+	jsonmodel.Summary
+	FetchedAt *time.Time           `json:"fetched_at,omitempty"`
+	Signer    *EvidenceCertificate `json:"signer,omitempty"` // Certificate that signed the TSL, nil if unsigned
+	Listings  []CertificateListing `json:"listings"`         // Services of the TSL listing the anchor
+}
+
+// EvidenceBundle records why a certificate was trusted: the chain built for
+// it, the services and signed TSLs listing the anchor of the chain, the policy
+// the pool was selected with and when it all happened. It is meant to be kept
+// for the long term, as the evidence of a trust decision.
+//
+// WriteZip packages it as a ZIP file with evidence.json, the JSON of the
+// bundle, chain.pem, the chain leaf first, and the signed TSL documents under
+// tsls/. Files lists the SHA-256 of every other file, so the package can be
+// checked for changes.
+type EvidenceBundle struct {
+	GeneratedAt time.Time             `json:"generated_at"`
+	VerifiedAt  time.Time             `json:"verified_at"`            // Time the chain was verified at
+	Pool        string                `json:"pool,omitempty"`         // Name of the pool, "" for the pool of the last select step
+	Policy      string                `json:"policy"`                 // Selection policy of the pool, see PoolPolicy
+	Chain       []EvidenceCertificate `json:"chain"`                  // Leaf first, anchor last
+	LocalAnchor *TrustAnchor          `json:"local_anchor,omitempty"` // The anchor, if added with extra-roots
+	TSLs        []EvidenceTSL         `json:"tsls"`
+	Files       map[string]string     `json:"files"` // Hex SHA-256 of the other files of the ZIP file, by name
+
+	chain []*x509.Certificate
+	tsls  [][]byte
+}
+
+// NewEvidenceBundle returns the evidence of the verification of chain, as
+// returned by Context.Verify, against the pool of ctx. The TSLs of ctx listing
+// the last certificate of chain are included with their original documents,
+// when these were kept (see etsi119612.TSL.RawXML).
+//
+// Parameters:
+//   - ctx: Pipeline context with the pool chain was verified against
+//   - chain: The verified chain, leaf first
+//
+// Returns:
+//   - The evidence bundle
+//   - An error if chain is empty or the document of a TSL cannot be read
+func NewEvidenceBundle(ctx *Context, chain []*x509.Certificate) (*EvidenceBundle, error) {
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("%w: no chain to record evidence for", ErrInvalidArguments)
+	}
+	now := ctx.Now()
+	bundle := &EvidenceBundle{
+		GeneratedAt: now,
+		VerifiedAt:  now,
+		Policy:      PoolPolicy(ctx),
+		TSLs:        []EvidenceTSL{},
+		Files:       map[string]string{},
+		chain:       chain,
+	}
+	if ctx.VerifyOptions != nil && !ctx.VerifyOptions.CurrentTime.IsZero() {
+		bundle.VerifiedAt = ctx.VerifyOptions.CurrentTime
+	}
+	for _, cert := range chain {
+		bundle.Chain = append(bundle.Chain, newEvidenceCertificate(cert))
+	}
+
+	anchor := chain[len(chain)-1]
+	fingerprint := bundle.Chain[len(chain)-1].SHA256
+	for _, local := range LocalTrustAnchors(ctx) {
+		if local.SHA256 == fingerprint {
+			bundle.LocalAnchor = &local
+			break
+		}
+	}
+	listings := ctx.Listings(anchor)
+	for _, tsl := range contextTSLs(ctx) {
+		if tsl == nil || slices.ContainsFunc(bundle.TSLs, func(t EvidenceTSL) bool { return t.Source == tsl.Source }) {
+			continue
+		}
+		var listed []CertificateListing
+		for _, listing := range listings {
+			if listing.TSL == tsl.Source {
+				listed = append(listed, listing)
+			}
+		}
+		if len(listed) == 0 {
+			continue
+		}
+		data, err := tsl.RawXML()
+		if err != nil {
+			return nil, err
+		}
+		file := ""
+		if len(data) > 0 {
+			file = fmt.Sprintf("tsls/%d.xml", len(bundle.TSLs)+1)
+		}
+		bundle.TSLs = append(bundle.TSLs, newEvidenceTSL(tsl, file, listed))
+		bundle.tsls = append(bundle.tsls, data)
+	}
+	return bundle, nil
+}
+
+// newEvidenceCertificate describes cert for an evidence bundle.
+func newEvidenceCertificate(cert *x509.Certificate) EvidenceCertificate {
+	digest := sha256.Sum256(cert.Raw)
+	return EvidenceCertificate{
+		Subject:      cert.Subject.String(),
+		Issuer:       cert.Issuer.String(),
+		SerialNumber: cert.SerialNumber.String(),
+		NotBefore:    cert.NotBefore.UTC(),
+		NotAfter:     cert.NotAfter.UTC(),
+		SHA256:       hex.EncodeToString(digest[:]),
+	}
+}
+
+// newEvidenceTSL describes tsl, stored as file, for an evidence bundle.
+func newEvidenceTSL(tsl *etsi119612.TSL, file string, listings []CertificateListing) EvidenceTSL {
+	evidence := EvidenceTSL{
+		File:     file,
+		Summary:  tsl.JSONSummary(),
+		Listings: listings,
+	}
+	if tsl.FetchInfo != nil && !tsl.FetchInfo.FetchedAt.IsZero() {
+		fetched := tsl.FetchInfo.FetchedAt.UTC()
+		evidence.FetchedAt = &fetched
+	}
+	if tsl.Signed && len(tsl.Signer.Raw) > 0 {
+		signer := newEvidenceCertificate(&tsl.Signer)
+		evidence.Signer = &signer
+	}
+	return evidence
+}
+
+// WriteZip writes the bundle to w as a ZIP file and records the digests of
+// its files in Files.
+func (b *EvidenceBundle) WriteZip(w io.Writer) error {
+	var chainPEM []byte
+	for _, cert := range b.chain {
+		chainPEM = append(chainPEM, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})...)
+	}
+	names := []string{"chain.pem"}
+	contents := [][]byte{chainPEM}
+	for i, tsl := range b.TSLs {
+		if tsl.File != "" {
+			names = append(names, tsl.File)
+			contents = append(contents, b.tsls[i])
+		}
+	}
+
+	zw := zip.NewWriter(w)
+	for i, name := range names {
+		digest := sha256.Sum256(contents[i])
+		b.Files[name] = hex.EncodeToString(digest[:])
+		if err := addZipFile(zw, name, contents[i], b.GeneratedAt); err != nil {
+			return err
+		}
+	}
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode evidence: %w", err)
+	}
+	if err := addZipFile(zw, "evidence.json", append(data, '\n'), b.GeneratedAt); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to finalize evidence bundle: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package pipeline
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/sirosfoundation/g119612/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewEvidenceBundle(t *testing.T) {
+	ca, caKey := monitorTestCert(t, "Listed CA", nil, nil)
+	other, _ := monitorTestCert(t, "Other CA", nil, nil)
+	leaf, _ := monitorTestCert(t, "service.example.com", ca, caKey)
+
+	listing := generateTSL("Listed Service", "http://uri.etsi.org/TrstSvc/Svctype/CA/QC",
+		[]string{base64.StdEncoding.EncodeToString(ca.Raw)})
+	listing.Source = "https://example.com/listing.xml"
+	listing.Raw = []byte("<TrustServiceStatusList/>")
+	unrelated := generateTSL("Other Service", "http://uri.etsi.org/TrstSvc/Svctype/CA/QC",
+		[]string{base64.StdEncoding.EncodeToString(other.Raw)})
+	unrelated.Source = "https://example.com/unrelated.xml"
+
+	at := time.Now().UTC().Truncate(time.Second)
+	ctx := NewContext()
+	ctx.Clock = FixedClock(at)
+	ctx.AddTSL(listing)
+	ctx.AddTSL(unrelated)
+	ctx, err := SelectCertPool(&Pipeline{Logger: logging.SilentLogger()}, ctx, "service-type:http://uri.etsi.org/TrstSvc/Svctype/CA/QC")
+	require.NoError(t, err)
+	chains, err := ctx.Verify(leaf)
+	require.NoError(t, err)
+
+	bundle, err := NewEvidenceBundle(ctx, chains[0])
+	require.NoError(t, err)
+	assert.Equal(t, at, bundle.GeneratedAt)
+	assert.Equal(t, at, bundle.VerifiedAt)
+	assert.Contains(t, bundle.Policy, "service-type:http://uri.etsi.org/TrstSvc/Svctype/CA/QC")
+	require.Len(t, bundle.Chain, 2)
+	assert.Equal(t, "CN=service.example.com", bundle.Chain[0].Subject)
+	assert.Equal(t, "CN=Listed CA", bundle.Chain[1].Subject)
+	assert.Nil(t, bundle.LocalAnchor)
+
+	// Only the TSL listing the anchor is evidence
+	require.Len(t, bundle.TSLs, 1)
+	tsl := bundle.TSLs[0]
+	assert.Equal(t, "https://example.com/listing.xml", tsl.Source)
+	assert.Equal(t, "tsls/1.xml", tsl.File)
+	assert.Equal(t, "Test Operator", tsl.Operator)
+	require.Len(t, tsl.Listings, 1)
+	assert.Equal(t, "Listed Service", tsl.Listings[0].Service)
+	assert.Nil(t, tsl.Signer)
+
+	var buf bytes.Buffer
+	require.NoError(t, bundle.WriteZip(&buf))
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	files := map[string][]byte{}
+	for _, f := range zr.File {
+		r, err := f.Open()
+		require.NoError(t, err)
+		data, err := io.ReadAll(r)
+		require.NoError(t, err)
+		files[f.Name] = data
+		assert.True(t, at.Equal(f.Modified), "files are dated like the bundle")
+	}
+	require.Len(t, files, 3)
+	assert.Equal(t, listing.Raw, files["tsls/1.xml"])
+	block, rest := pem.Decode(files["chain.pem"])
+	require.NotNil(t, block)
+	assert.Equal(t, leaf.Raw, block.Bytes)
+	block, _ = pem.Decode(rest)
+	require.NotNil(t, block)
+	assert.Equal(t, ca.Raw, block.Bytes)
+
+	// evidence.json lists the digests of the other files
+	var written EvidenceBundle
+	require.NoError(t, json.Unmarshal(files["evidence.json"], &written))
+	require.Len(t, written.Files, 2)
+	for name, digest := range written.Files {
+		sum := sha256.Sum256(files[name])
+		assert.Equal(t, hex.EncodeToString(sum[:]), digest, name)
+	}
+	assert.Equal(t, bundle.Chain, written.Chain)
+
+	// Without the document of a TSL only its description is kept
+	listing.Raw = nil
+	bundle, err = NewEvidenceBundle(ctx, chains[0])
+	require.NoError(t, err)
+	assert.Empty(t, bundle.TSLs[0].File)
+	buf.Reset()
+	require.NoError(t, bundle.WriteZip(&buf))
+	assert.Len(t, bundle.Files, 1)
+
+	_, err = NewEvidenceBundle(ctx, nil)
+	assert.ErrorIs(t, err, ErrInvalidArguments)
+}
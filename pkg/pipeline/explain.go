@@ -0,0 +1,187 @@
+package pipeline
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/sirosfoundation/g119612/pkg/etsi119612"
+)
+
+// EffectiveFetchOptions is a snapshot of the settings used for fetching TSLs
+// at a pipeline step, after all earlier set-fetch-options steps are applied.
+type EffectiveFetchOptions struct {
+	UserAgent           string                      `json:"userAgent"`
+	Timeout             string                      `json:"timeout"`
+	MaxDereferenceDepth int                         `json:"maxDereferenceDepth"`
+	AcceptHeaders       []string                    `json:"acceptHeaders,omitempty"`
+	PreferXML           bool                        `json:"preferXml"`
+	Strict              bool                        `json:"strict"`
+	StrictPointers      bool                        `json:"strictPointers"`
+	AllowDoctype        bool                        `json:"allowDoctype"`
+	Cache               bool                        `json:"cache"` // Referenced TSLs are fetched once per run
+	Transport           etsi119612.TransportOptions `json:"transport"`
+	Mirror              string                      `json:"mirror,omitempty"` // Directory of the mirror TSLs are read from
+	Retries             int                         `json:"retries"`          // Retries of transient fetch failures
+	RetryDelay          string                      `json:"retryDelay"`       // Delay before the first retry
+	IPFSGateway         string                      `json:"ipfsGateway"`      // Gateway ipfs:// URLs are fetched through
+	// AlgorithmPolicy holds the algorithms and RSA key sizes accepted in TSL
+	// signatures, with the default minimum key size filled in, and
+	// AllowSHA1URLs the URLs SHA-1 is accepted for regardless, sorted.
+	AlgorithmPolicy   etsi119612.AlgorithmPolicy `json:"algorithmPolicy"`
+	AllowSHA1URLs     []string                   `json:"allowSHA1URLs,omitempty"`
+	RawSpillDir       string                     `json:"rawSpillDir,omitempty"`       // Directory large documents are spilled to
+	RawSpillThreshold int                        `json:"rawSpillThreshold,omitempty"` // Size above which documents are spilled
+	// SignerRoots is the number of roots the signers of TSLs must chain to,
+	// and IssuerHosts the hosts missing intermediates may be fetched from.
+	SignerRoots int      `json:"signerRoots,omitempty"`
+	IssuerHosts []string `json:"issuerHosts,omitempty"`
+	// HostOverrides holds the fetch options of specific hosts, each in the
+	// form of the host option of set-fetch-options.
+	HostOverrides []string `json:"hostOverrides,omitempty"`
+	// Filters holds the TSL filters by kind ("territory", "service-type").
+	// Loaded TSLs that do not match them are dropped, and referenced TSLs
+	// outside the territory filter are not fetched.
+	Filters map[string][]string `json:"filters,omitempty"`
+}
+
+// StepExplanation describes the effective settings of one pipeline step.
+// Only the fields relevant to the step are set.
+type StepExplanation struct {
+	Index int      `json:"index"`
+	Name  string   `json:"name"`
+	Args  []string `json:"args,omitempty"`
+	// LogLevel is the log level of the step, if it overrides the pipeline's.
+	LogLevel string `json:"logLevel,omitempty"`
+	// Evaluated is true for configuration steps, which Explain runs.
+	Evaluated bool                   `json:"evaluated"`
+	Fetch     *EffectiveFetchOptions `json:"fetch,omitempty"`  // set-fetch-options and load
+	Load      *LoadOptions           `json:"load,omitempty"`   // load
+	Select    *SelectOptions         `json:"select,omitempty"` // select
+}
+
+// Explain resolves the effective configuration of every step of a pipeline
+// without loading or publishing anything. Configuration steps (set-fetch-options)
+// are run against a fresh context; load and select steps are only parsed and
+// reported with the fetch options and filters they would use. This helps
+// finding out why a pipeline filtered out an expected TSL.
+//
+// Parameters:
+//   - pl: The pipeline to explain
+//
+// Returns:
+//   - []StepExplanation: One entry per step, in pipeline order
+//   - error: Non-nil if a step is unknown or its configuration is invalid
+func Explain(pl *Pipeline) ([]StepExplanation, error) {
+	ctx := NewContext()
+	ctx.EnsureTSLFetchOptions()
+
+	explanations := make([]StepExplanation, 0, len(pl.Pipes))
+	for i, pipe := range pl.Pipes {
+		if pipe.MethodName == ConditionalStep {
+			// Which branch runs depends on the TSLs, so neither is explained
+			if _, err := ParseCondition(pipe.Condition); err != nil {
+				return explanations, fmt.Errorf("step %d (%s) failed: %w", i, pipe.MethodName, err)
+			}
+			explanations = append(explanations, StepExplanation{Index: i, Name: pipe.MethodName, Args: pipe.MethodArguments, LogLevel: pipe.LogLevel})
+			continue
+		}
+		if _, ok := pl.registry().Function(pipe.MethodName); !ok {
+			return explanations, fmt.Errorf("step %d: unknown methodName '%s'", i, pipe.MethodName)
+		}
+		explanation := StepExplanation{Index: i, Name: pipe.MethodName, Args: pipe.MethodArguments, LogLevel: pipe.LogLevel}
+
+		switch pipe.MethodName {
+		case "set-fetch-options":
+			var err error
+			if ctx, err = SetFetchOptions(pl, ctx, pipe.MethodArguments...); err != nil {
+				return explanations, fmt.Errorf("step %d (%s) failed: %w", i, pipe.MethodName, err)
+			}
+			explanation.Evaluated = true
+			explanation.Fetch = effectiveFetchOptions(ctx)
+		case "load":
+			args, opts, err := parseLoadOptions(pipe.MethodArguments)
+			if err != nil {
+				return explanations, fmt.Errorf("step %d (%s) failed: %w", i, pipe.MethodName, err)
+			}
+			if len(args) > 0 {
+				opts.URL = args[0]
+			} else if len(opts.Mirrors) > 0 {
+				opts.URL, opts.Mirrors = opts.Mirrors[0], opts.Mirrors[1:]
+			}
+			explanation.Load = &opts
+			explanation.Fetch = effectiveFetchOptions(ctx)
+			explanation.Fetch.Strict = explanation.Fetch.Strict || opts.Strict
+			explanation.Fetch.StrictPointers = explanation.Fetch.StrictPointers || opts.StrictPointers
+			explanation.Fetch.AllowDoctype = explanation.Fetch.AllowDoctype || opts.AllowDoctype
+		case "select", "select-cert-pool":
+			opts, err := parseSelectArgs(pl, pipe.MethodArguments)
+			if err != nil {
+				return explanations, fmt.Errorf("step %d (%s) failed: %w", i, pipe.MethodName, err)
+			}
+			explanation.Select = &opts
+		}
+		explanations = append(explanations, explanation)
+	}
+	return explanations, nil
+}
+
+// effectiveFetchOptions takes a snapshot of the fetch options and filters of ctx.
+func effectiveFetchOptions(ctx *Context) *EffectiveFetchOptions {
+	options := ctx.TSLFetchOptions
+	effective := &EffectiveFetchOptions{
+		UserAgent:           options.UserAgent,
+		Timeout:             options.Timeout.String(),
+		MaxDereferenceDepth: options.MaxDereferenceDepth,
+		AcceptHeaders:       slices.Clone(options.AcceptHeaders),
+		Strict:              options.Strict,
+		StrictPointers:      options.StrictPointers,
+		AllowDoctype:        options.AllowDoctype,
+		Cache:               options.Cache != nil,
+		Transport:           options.Transport,
+		Retries:             options.Retries,
+		RetryDelay:          options.RetryDelay.String(),
+	}
+	if options.RetryDelay == 0 {
+		effective.RetryDelay = etsi119612.DefaultRetryDelay.String()
+	}
+	effective.IPFSGateway = options.IPFSGateway
+	if effective.IPFSGateway == "" {
+		effective.IPFSGateway = etsi119612.DefaultIPFSGateway
+	}
+	effective.AlgorithmPolicy = options.AlgorithmPolicy
+	if effective.AlgorithmPolicy.MinRSAKeySize == 0 {
+		effective.AlgorithmPolicy.MinRSAKeySize = etsi119612.DefaultMinRSAKeySize
+	}
+	if len(options.AllowSHA1URLs) > 0 {
+		effective.AllowSHA1URLs = slices.Sorted(slices.Values(options.AllowSHA1URLs))
+	}
+	if options.RawSpillDir != "" {
+		effective.RawSpillDir = options.RawSpillDir
+		effective.RawSpillThreshold = options.RawSpillThreshold
+		if effective.RawSpillThreshold == 0 {
+			effective.RawSpillThreshold = etsi119612.DefaultRawSpillThreshold
+		}
+	}
+	if options.SignerRoots != nil {
+		effective.SignerRoots = len(options.SignerRoots.Subjects())
+	}
+	if options.IssuerFetcher != nil {
+		effective.IssuerHosts = slices.Clone(options.IssuerFetcher.AllowedHosts)
+	}
+	for _, override := range options.HostOverrides {
+		effective.HostOverrides = append(effective.HostOverrides, override.String())
+	}
+	if options.Mirror != nil {
+		effective.Mirror = options.Mirror.Dir()
+	}
+	if preferXML, ok := dataValue[bool](ctx, "prefer_xml_over_pdf"); ok {
+		effective.PreferXML = preferXML
+	}
+	if filters, ok := dataValue[map[string][]string](ctx, "tsl_filters"); ok && len(filters) > 0 {
+		effective.Filters = make(map[string][]string, len(filters))
+		for kind, values := range filters {
+			effective.Filters[kind] = slices.Clone(values)
+		}
+	}
+	return effective
+}
@@ -0,0 +1,108 @@
+package pipeline
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirosfoundation/g119612/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// explainPipeline loads a pipeline from YAML and explains it.
+func explainPipeline(t *testing.T, yaml string) ([]StepExplanation, error) {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "pipeline.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(yaml), 0600))
+	pl, err := NewPipeline(file)
+	require.NoError(t, err)
+	return Explain(pl.WithLogger(logging.SilentLogger()))
+}
+
+func TestExplain(t *testing.T) {
+	steps, err := explainPipeline(t, `
+- load:
+    - https://example.com/first.xml
+  log-level: debug
+- set-fetch-options:
+    - timeout:5s
+    - max-depth:2
+    - filter-territory:SE, FI
+    - http2:false
+- load:
+    - https://example.com/lotl.xml
+    - strict-pointers
+- select:
+    - reference-depth:1
+    - service-type:http://uri.etsi.org/TrstSvc/Svctype/CA/QC
+    - status-logic:and
+- publish:
+    - /nonexistent/out
+`)
+	require.NoError(t, err)
+	require.Len(t, steps, 5)
+
+	// The first load runs with the context defaults
+	first := steps[0]
+	assert.False(t, first.Evaluated)
+	require.NotNil(t, first.Load)
+	assert.Equal(t, "https://example.com/first.xml", first.Load.URL)
+	require.NotNil(t, first.Fetch)
+	assert.Equal(t, "30s", first.Fetch.Timeout)
+	assert.Zero(t, first.Fetch.MaxDereferenceDepth)
+	assert.Empty(t, first.Fetch.Filters)
+	assert.Equal(t, "debug", first.LogLevel)
+
+	options := steps[1]
+	assert.True(t, options.Evaluated)
+	require.NotNil(t, options.Fetch)
+	assert.Equal(t, "5s", options.Fetch.Timeout)
+	assert.Equal(t, 2, options.Fetch.MaxDereferenceDepth)
+	assert.Equal(t, []string{"SE", "FI"}, options.Fetch.Filters["territory"])
+	assert.True(t, options.Fetch.Transport.DisableHTTP2)
+
+	second := steps[2]
+	require.NotNil(t, second.Fetch)
+	assert.Equal(t, 2, second.Fetch.MaxDereferenceDepth)
+	assert.Equal(t, []string{"SE", "FI"}, second.Fetch.Filters["territory"])
+	assert.True(t, second.Fetch.StrictPointers)
+	assert.True(t, second.Load.StrictPointers)
+
+	selectStep := steps[3]
+	require.NotNil(t, selectStep.Select)
+	assert.Equal(t, 1, selectStep.Select.ReferenceDepth)
+	assert.Equal(t, []string{"http://uri.etsi.org/TrstSvc/Svctype/CA/QC"}, selectStep.Select.ServiceTypes)
+	assert.True(t, selectStep.Select.MatchAllStatuses)
+
+	publish := steps[4]
+	assert.False(t, publish.Evaluated)
+	assert.Nil(t, publish.Fetch)
+	assert.Equal(t, []string{"/nonexistent/out"}, publish.Args)
+	_, err = os.Stat("/nonexistent/out")
+	assert.True(t, os.IsNotExist(err), "explain does not run publish")
+}
+
+func TestExplain_Errors(t *testing.T) {
+	_, err := explainPipeline(t, `
+- no-such-step:
+    - x
+`)
+	assert.Error(t, err)
+
+	steps, err := explainPipeline(t, `
+- set-fetch-options:
+    - max-depth:1
+- set-fetch-options:
+    - timeout:soon
+`)
+	assert.Error(t, err)
+	assert.Len(t, steps, 1, "steps before the failing one are still explained")
+
+	_, err = explainPipeline(t, `
+- load:
+    - https://example.com/tsl.xml
+    - strict:sometimes
+`)
+	assert.Error(t, err)
+}
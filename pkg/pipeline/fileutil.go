@@ -0,0 +1,110 @@
+package pipeline
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirosfoundation/g119612/pkg/etsi119612"
+)
+
+// writeFileAtomic writes data to path by first writing a staging file in the
+// same directory and then renaming it into place. The staging file is created
+// with mode 0600 so partially written content is never exposed to other users;
+// the final mode is applied just before the rename.
+func writeFileAtomic(path string, data []byte, mode os.FileMode) error {
+	return writeFileAtomicFrom(path, mode, tslDocument{data: data}.writeTo)
+}
+
+// writeFileAtomicFrom writes the content written by write to path like
+// writeFileAtomic, streaming it into the staging file.
+func writeFileAtomicFrom(path string, mode os.FileMode, write func(io.Writer) error) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	defer func() {
+		// Only removes the staging file if the rename did not happen
+		_ = os.Remove(tmpName)
+	}()
+
+	w := bufio.NewWriter(tmp)
+	if err := write(w); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpName, mode); err != nil {
+		return fmt.Errorf("failed to set mode on %s: %w", tmpName, err)
+	}
+	return os.Rename(tmpName, path)
+}
+
+// unsignedVariantPath returns the path of the unsigned copy of a published TSL,
+// e.g. "out/EU.xml" becomes "out/EU-unsigned.xml".
+func unsignedVariantPath(path string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "-unsigned" + ext
+}
+
+// writePublishedTSL writes the serialized TSL to path, with the content
+// written by write: the unsigned document, or the document signed. When the
+// options request an unsigned copy and the TSL was signed, the canonical form
+// of the unsigned document that was passed to the signer, which is what its
+// signature digests, is written to the unsigned variant path first, so both
+// files always correspond to the same content. During a
+// key rollover the same unsigned document is also signed with the next key and
+// written below the rollover directory. Every written file is recorded for the
+// publish manifest and for handling a failure of the publish step.
+func writePublishedTSL(tsl *etsi119612.TSL, path string, unsigned tslDocument, write func(io.Writer) error, opts *publishOptions) error {
+	opts = opts.orDefault()
+	root := opts.baseDir
+	if root == "" {
+		root = filepath.Dir(path)
+	}
+	if opts.unsignedCopy && opts.signer != nil {
+		unsignedPath := unsignedVariantPath(path)
+		if err := opts.writeFileFrom(unsignedPath, unsigned.writeCanonical); err != nil {
+			return err
+		}
+		if err := opts.recordPublished(root, unsignedPath, tsl, false); err != nil {
+			return err
+		}
+	}
+	if err := opts.writeFileFrom(path, write); err != nil {
+		return err
+	}
+	if err := opts.recordPublished(root, path, tsl, opts.signer != nil); err != nil {
+		return err
+	}
+	if opts.rolloverSigner == nil || opts.rolloverDir == "" {
+		return nil
+	}
+
+	rolloverPath, err := opts.rolloverPath(path)
+	if err != nil {
+		return err
+	}
+	signed, err := opts.signedContent(opts.rolloverSigner, unsigned)
+	if err != nil {
+		return fmt.Errorf("failed to sign TSL with rollover key: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(rolloverPath), opts.dirMode); err != nil {
+		return fmt.Errorf("failed to create rollover directory: %w", err)
+	}
+	if err := opts.writeFileFrom(rolloverPath, signed); err != nil {
+		return err
+	}
+	return opts.recordPublished(opts.rolloverDir, rolloverPath, tsl, true)
+}
@@ -10,13 +10,7 @@ import (
 // It returns a new slice containing only the TSLs that match the filters.
 func FilterTSLs(ctx *Context, tsls []*etsi119612.TSL) []*etsi119612.TSL {
 	// Get filters from context
-	filtersAny, ok := ctx.Data["tsl_filters"]
-	if !ok {
-		// No filters defined, return the original slice
-		return tsls
-	}
-
-	filters, ok := filtersAny.(map[string][]string)
+	filters, ok := dataValue[map[string][]string](ctx, "tsl_filters")
 	if !ok || len(filters) == 0 {
 		// No valid filters, return the original slice
 		return tsls
@@ -33,6 +27,37 @@ func FilterTSLs(ctx *Context, tsls []*etsi119612.TSL) []*etsi119612.TSL {
 	return result
 }
 
+// pointerFilter returns an etsi119612.TSLFetchOptions.PointerFilter that pushes
+// the territory filter of the context down to dereferencing, so lists that
+// FilterTSLs would drop are not fetched at all. Pointers are only skipped when
+// they declare a SchemeTerritory outside the filter; pointers without metadata
+// and pointers to lists of lists, whose children may still match, are followed.
+// It returns nil if the context has no territory filter.
+func pointerFilter(ctx *Context) func(etsi119612.PointerInfo) bool {
+	filters, ok := dataValue[map[string][]string](ctx, "tsl_filters")
+	if !ok || len(filters["territory"]) == 0 {
+		return nil
+	}
+	territories := filters["territory"]
+	return func(pointer etsi119612.PointerInfo) bool {
+		if pointer.SchemeTerritory == "" || isListOfListsType(pointer.TSLType) {
+			return true
+		}
+		for _, territory := range territories {
+			if strings.EqualFold(pointer.SchemeTerritory, territory) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// isListOfListsType reports whether a TSLType URI denotes a list of lists,
+// such as http://uri.etsi.org/TrstSvc/TrustedList/TSLType/EUlistofthelists.
+func isListOfListsType(tslType string) bool {
+	return strings.Contains(strings.ToLower(tslType), "listofthelists")
+}
+
 // matchesFilters checks if a TSL matches all the specified filters
 func matchesFilters(tsl *etsi119612.TSL, filters map[string][]string) bool {
 	// Check territory filter
@@ -1,10 +1,15 @@
 package pipeline
 
 import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
 	"testing"
 
 	"github.com/sirosfoundation/g119612/pkg/etsi119612"
+	"github.com/sirosfoundation/g119612/pkg/logging"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestFilterTSLs(t *testing.T) {
@@ -277,3 +282,68 @@ func TestMatchesServiceType_EdgeCases(t *testing.T) {
 		assert.True(t, matchesServiceType(tsl, []string{"etsi.org"}))
 	})
 }
+
+func TestPointerFilter(t *testing.T) {
+	ctx := NewContext()
+	assert.Nil(t, pointerFilter(ctx))
+
+	ctx.Data["tsl_filters"] = map[string][]string{"service-type": {"CA/QC"}}
+	assert.Nil(t, pointerFilter(ctx))
+
+	ctx.Data["tsl_filters"] = map[string][]string{"territory": {"SE", "fi"}}
+	filter := pointerFilter(ctx)
+	require.NotNil(t, filter)
+	assert.True(t, filter(etsi119612.PointerInfo{SchemeTerritory: "se"}))
+	assert.True(t, filter(etsi119612.PointerInfo{SchemeTerritory: "FI"}))
+	assert.False(t, filter(etsi119612.PointerInfo{SchemeTerritory: "DE"}))
+	assert.True(t, filter(etsi119612.PointerInfo{Location: "https://example.com/unknown.xml"}))
+	assert.True(t, filter(etsi119612.PointerInfo{
+		SchemeTerritory: "EU",
+		TSLType:         "http://uri.etsi.org/TrstSvc/TrustedList/TSLType/EUlistofthelists",
+	}))
+}
+
+func TestLoadTSLSkipsFilteredPointers(t *testing.T) {
+	list := func(territory, pointers string) string {
+		return `<tsl:TrustServiceStatusList xmlns:tsl="http://uri.etsi.org/02231/v2#"><tsl:SchemeInformation>` +
+			`<tsl:TSLType>http://uri.etsi.org/TrstSvc/TrustedList/TSLType/EUgeneric</tsl:TSLType>` +
+			`<tsl:SchemeTerritory>` + territory + `</tsl:SchemeTerritory>` + pointers +
+			`</tsl:SchemeInformation></tsl:TrustServiceStatusList>`
+	}
+	var requested sync.Map
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested.Store(r.URL.Path, true)
+		switch r.URL.Path {
+		case "/lotl.xml":
+			pointer := func(territory string) string {
+				return `<tsl:OtherTSLPointer><tsl:TSLLocation>` + srv.URL + `/` + territory + `.xml</tsl:TSLLocation>` +
+					`<tsl:AdditionalInformation><tsl:OtherInformation><tsl:SchemeTerritory>` + territory +
+					`</tsl:SchemeTerritory></tsl:OtherInformation></tsl:AdditionalInformation></tsl:OtherTSLPointer>`
+			}
+			w.Write([]byte(list("EU", `<tsl:PointersToOtherTSL>`+pointer("SE")+pointer("DE")+`</tsl:PointersToOtherTSL>`)))
+		case "/SE.xml":
+			w.Write([]byte(list("SE", "")))
+		case "/DE.xml":
+			w.Write([]byte(list("DE", "")))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	pl := &Pipeline{Logger: logging.SilentLogger()}
+	ctx := NewContext()
+	ctx, err := SetFetchOptions(pl, ctx, "max-depth:1", "filter-territory:SE")
+	require.NoError(t, err)
+
+	ctx, err = LoadTSL(pl, ctx, srv.URL+"/lotl.xml")
+	require.NoError(t, err)
+	require.Equal(t, 1, ctx.TSLs.Size())
+	assert.Equal(t, srv.URL+"/SE.xml", ctx.TSLs.ToSlice()[0].Source)
+
+	_, fetchedSE := requested.Load("/SE.xml")
+	_, fetchedDE := requested.Load("/DE.xml")
+	assert.True(t, fetchedSE)
+	assert.False(t, fetchedDE, "pointers outside the territory filter must not be fetched")
+}
@@ -5,7 +5,9 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/sirosfoundation/g119612/pkg/validation"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestGenerateTSL_ErrorCases(t *testing.T) {
@@ -164,3 +166,58 @@ status: "http://test.example.com/status/valid"
 		})
 	}
 }
+
+func TestGenerateTSL_StrictURIs(t *testing.T) {
+	// writeTree writes a generate input tree with the given URIs
+	writeTree := func(t *testing.T, distributionPoint, informationURI, electronic string) string {
+		t.Helper()
+		dir := t.TempDir()
+		providerDir := filepath.Join(dir, "providers", "acme")
+		require.NoError(t, os.MkdirAll(providerDir, 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "scheme.yaml"), []byte(
+			"operatorNames:\n  - language: en\n    value: Operator\ntype: http://uri.etsi.org/TrstSvc/TrustedList/TSLType/EUgeneric\n"+
+				"distributionPoints:\n  - \""+distributionPoint+"\"\n"), 0644))
+		require.NoError(t, os.WriteFile(filepath.Join(providerDir, "provider.yaml"), []byte(
+			"names:\n  - language: en\n    value: ACME\n"+
+				"informationURI:\n  - language: en\n    value: \""+informationURI+"\"\n"+
+				"address:\n  postal:\n    streetAddress: Street 1\n    locality: City\n    countryName: SE\n"+
+				"  electronic:\n    - \"mailto:info@acme.example\"\n    - \""+electronic+"\"\n"), 0644))
+		return dir
+	}
+
+	t.Run("Valid", func(t *testing.T) {
+		dir := writeTree(t, "https://tsl.example.com/tsl.xml", "https://acme.example/info", "https://acme.example")
+		ctx, err := GenerateTSL(nil, NewContext(), dir, "strict-uris")
+		require.NoError(t, err)
+		tsl, _ := ctx.TSLs.Peek()
+		require.NotNil(t, tsl)
+		assert.Equal(t, []string{"https://tsl.example.com/tsl.xml"}, tsl.StatusList.TslSchemeInformation.TslDistributionPoints.URI)
+		info := tsl.StatusList.TslTrustServiceProviderList.TslTrustServiceProvider[0].TslTSPInformation.TSPInformationURI
+		require.NotNil(t, info)
+		assert.Equal(t, "https://acme.example/info", info.URI[0].Value)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		dir := writeTree(t, "http://tsl.example.com/tsl.xml", "https://acme_example/info", "ftp://acme.example")
+
+		// Without strict-uris nothing is checked
+		_, err := GenerateTSL(nil, NewContext(), dir)
+		require.NoError(t, err)
+
+		_, err = GenerateTSL(nil, NewContext(), dir, "strict-uris")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "scheme.yaml: distributionPoints[0]: 'http://tsl.example.com/tsl.xml'")
+		assert.Contains(t, err.Error(), "providers/acme/provider.yaml: informationURI[0].value: 'https://acme_example/info'")
+		assert.Contains(t, err.Error(), "providers/acme/provider.yaml: address.electronic[1]: 'ftp://acme.example'")
+		assert.NotContains(t, err.Error(), "electronic[0]")
+		var fieldErr *validation.FieldError
+		assert.ErrorAs(t, err, &fieldErr)
+
+		// The scheme policy is configurable
+		_, err = GenerateTSL(nil, NewContext(), dir, "uri-schemes:https,http,ftp")
+		require.Error(t, err)
+		assert.NotContains(t, err.Error(), "distributionPoints")
+		assert.NotContains(t, err.Error(), "electronic")
+		assert.Contains(t, err.Error(), "informationURI[0].value")
+	})
+}
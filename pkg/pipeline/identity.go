@@ -0,0 +1,20 @@
+package pipeline
+
+import (
+	"github.com/sirosfoundation/g119612/pkg/etsi119612"
+	"github.com/sirosfoundation/g119612/pkg/logging"
+)
+
+// tslFields returns the log fields identifying a TSL by its territory, scheme
+// operator, sequence number and source (see etsi119612.TSLIdentity), followed
+// by fields. Log lines about a single TSL use them so that a failure names the
+// list that broke rather than its position in the context.
+func tslFields(tsl *etsi119612.TSL, fields ...logging.Field) []logging.Field {
+	id := tsl.Identity()
+	return append([]logging.Field{
+		logging.F("territory", id.Territory),
+		logging.F("operator", id.Operator),
+		logging.F("sequence", id.Sequence),
+		logging.F("url", id.Source),
+	}, fields...)
+}
@@ -10,6 +10,7 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -45,20 +46,31 @@ type TSLIndexEntry struct {
 // Arguments:
 //   - arg[0]: Directory path containing TSL HTML files
 //   - arg[1]: (Optional) Title for the index page (default: "Trust Service Lists Index")
+//   - lang:code (Optional) Language of the labels of the page, comma-separated
+//     preferences (default: the languages set with set-language, or "en"), see LocaleFor
 //
 // Example usage in pipeline YAML:
 //
 //   - generate_index:
 //   - /path/to/output/directory
 //   - "EU Trust Lists - Index"
+//   - lang:sv
 func GenerateIndex(pl *Pipeline, ctx *Context, args ...string) (*Context, error) {
+	args, langs, err := parseLangOption(args)
+	if err != nil {
+		return ctx, fmt.Errorf("%w: %v", ErrInvalidArguments, err)
+	}
 	if len(args) < 1 {
 		return ctx, fmt.Errorf("missing required directory path argument")
 	}
+	locale := localeOption(ctx, langs)
+	if locale == nil {
+		locale = LocaleFor(DefaultLocale)
+	}
 
 	// Parse arguments
 	dirPath := args[0]
-	title := "Trust Service Lists Index"
+	title := locale.Message("index.title")
 	if len(args) >= 2 {
 		title = args[1]
 	}
@@ -86,7 +98,7 @@ func GenerateIndex(pl *Pipeline, ctx *Context, args ...string) (*Context, error)
 	}
 
 	// Generate the index.html file
-	err = generateIndexHTML(dirPath, entries, title)
+	err = generateIndexHTML(dirPath, entries, title, locale, ctx.Now())
 	if err != nil {
 		return ctx, fmt.Errorf("failed to generate index.html: %w", err)
 	}
@@ -156,6 +168,14 @@ func extractMetadataFromHTML(filePath, relPath string) (TSLIndexEntry, error) {
 		return entry, err
 	}
 
+	// Pages of single providers of a split TSL and change reports are not TSL pages
+	if doc.Find(`meta[name="tsl-page"][content="provider"]`).Length() > 0 {
+		return entry, fmt.Errorf("%s is the page of a provider", filePath)
+	}
+	if doc.Find(`meta[name="tsl-page"][content="changes"]`).Length() > 0 {
+		return entry, fmt.Errorf("%s is a change report", filePath)
+	}
+
 	// Extract title
 	entry.Title = doc.Find("title").Text()
 
@@ -213,31 +233,77 @@ func extractMetadataFromHTML(filePath, relPath string) (TSLIndexEntry, error) {
 		}
 	}
 
-	// Count trust services
+	// Pages with translated labels mark the values instead
+	for class, field := range map[string]*string{
+		"tsl-territory":   &entry.Territory,
+		"tsl-sequence":    &entry.Sequence,
+		"tsl-issue-date":  &entry.IssueDate,
+		"tsl-next-update": &entry.NextUpdate,
+	} {
+		if value := strings.TrimSpace(doc.Find("." + class).First().Text()); value != "" {
+			*field = value
+		}
+	}
+
+	// Count trust services, which pages of split TSLs only list per provider
 	entry.TrustService = doc.Find(".service-card").Length()
+	doc.Find(".tsl-service-count").Each(func(_ int, count *goquery.Selection) {
+		if n, err := strconv.Atoi(strings.TrimSpace(count.Text())); err == nil {
+			entry.TrustService += n
+		}
+	})
 
 	return entry, nil
 }
 
-// generateIndexHTML creates an index.html file with links to all TSL HTML files using embedded templates
-func generateIndexHTML(dirPath string, entries []TSLIndexEntry, title string) error {
+// findChangeReports returns the names of the pages written by the
+// change-report step in a directory, sorted.
+func findChangeReports(dirPath string) []string {
+	files, err := os.ReadDir(dirPath)
+	if err != nil {
+		return nil
+	}
+	var reports []string
+	for _, file := range files {
+		if file.IsDir() || filepath.Ext(file.Name()) != ".html" || file.Name() == "index.html" {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(dirPath, file.Name()))
+		if err != nil {
+			continue
+		}
+		doc, err := goquery.NewDocumentFromReader(bytes.NewReader(content))
+		if err == nil && doc.Find(`meta[name="tsl-page"][content="changes"]`).Length() > 0 {
+			reports = append(reports, file.Name())
+		}
+	}
+	return reports
+}
+
+// generateIndexHTML creates an index.html file with links to all TSL HTML files using embedded templates,
+// dated generated
+func generateIndexHTML(dirPath string, entries []TSLIndexEntry, title string, locale *Locale, generated time.Time) error {
 	// Prepare template data
 	data := struct {
 		Title         string
+		Lang          string
 		Entries       []TSLIndexEntry
+		Changes       []string // Change reports in the directory
 		GeneratedDate string
 		CSS           template.CSS
 		JavaScript    template.JS
 	}{
 		Title:         title,
+		Lang:          locale.Lang,
 		Entries:       entries,
-		GeneratedDate: time.Now().Format("2006-01-02"),
+		Changes:       findChangeReports(dirPath),
+		GeneratedDate: generated.Format("2006-01-02"),
 		CSS:           template.CSS(indexCSS),
 		JavaScript:    template.JS(indexJavaScript),
 	}
 
 	// Parse and execute the template
-	tmpl, err := template.New("index").Parse(indexHTMLTemplate)
+	tmpl, err := template.New("index").Funcs(template.FuncMap{"t": locale.Message}).Parse(indexHTMLTemplate)
 	if err != nil {
 		return fmt.Errorf("failed to parse template: %w", err)
 	}
@@ -257,7 +323,18 @@ func generateIndexHTML(dirPath string, entries []TSLIndexEntry, title string) er
 	return nil
 }
 
+// generateIndexOutputs is the OutputsFunc of the generate_index step: the
+// index.html file.
+func generateIndexOutputs(args ...string) []string {
+	args, _, err := parseLangOption(args)
+	if err != nil || len(args) < 1 {
+		return nil
+	}
+	return []string{filepath.Join(args[0], "index.html")}
+}
+
 func init() {
 	// Register the GenerateIndex function
 	RegisterFunction("generate_index", GenerateIndex)
+	RegisterOutputs("generate_index", generateIndexOutputs)
 }
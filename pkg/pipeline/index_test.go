@@ -5,6 +5,7 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/sirosfoundation/g119612/pkg/logging"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -91,6 +92,52 @@ func TestGenerateIndex(t *testing.T) {
 		// Check if the title is correct
 		assert.Contains(t, string(content), customTitle)
 	})
+
+	t.Run("Language", func(t *testing.T) {
+		ctx, err := SetLanguage(nil, NewContext(), "fr")
+		require.NoError(t, err)
+
+		_, err = GenerateIndex(nil, ctx, htmlDir)
+		require.NoError(t, err)
+		content, err := os.ReadFile(filepath.Join(htmlDir, "index.html"))
+		require.NoError(t, err)
+		assert.Contains(t, string(content), `<html lang="fr"`)
+		assert.Contains(t, string(content), "<h1>Index des listes de confiance</h1>")
+		assert.Contains(t, string(content), "Prochaine mise à jour")
+
+		// The lang option overrides the pipeline's preference
+		_, err = GenerateIndex(nil, ctx, htmlDir, "Betrodda listor", "lang:sv")
+		require.NoError(t, err)
+		content, err = os.ReadFile(filepath.Join(htmlDir, "index.html"))
+		require.NoError(t, err)
+		assert.Contains(t, string(content), `<html lang="sv"`)
+		assert.Contains(t, string(content), "<h1>Betrodda listor</h1>")
+		assert.Contains(t, string(content), "Nästa uppdatering")
+
+		_, err = GenerateIndex(nil, ctx, htmlDir, "lang:svenska")
+		assert.ErrorIs(t, err, ErrInvalidArguments)
+	})
+
+	t.Run("Localized Pages", func(t *testing.T) {
+		// Metadata is found in pages whose labels are not in English
+		tsl := generateTSL("Test Service", "http://uri.etsi.org/TrstSvc/Svctype/CA/QC", nil)
+		tsl.StatusList.TslSchemeInformation.TslSchemeTerritory = "SE"
+		tsl.StatusList.TslSchemeInformation.TSLSequenceNumber = 17
+		tsl.StatusList.TslSchemeInformation.ListIssueDateTime = "2025-09-15T00:00:00Z"
+		ctx := NewContext()
+		ctx.AddTSL(tsl)
+		renderDir := filepath.Join(tempDir, "rendered")
+		_, err := RenderTSL(&Pipeline{Logger: logging.SilentLogger()}, ctx, "embedded:tsl.html", renderDir, "lang:de")
+		require.NoError(t, err)
+
+		entries, err := findTSLHtmlFiles(renderDir)
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		assert.Equal(t, "SE", entries[0].Territory)
+		assert.Equal(t, "17", entries[0].Sequence)
+		assert.Equal(t, "2025-09-15T00:00:00Z", entries[0].IssueDate)
+		assert.Equal(t, 1, entries[0].TrustService)
+	})
 }
 
 // Helper function to create sample TSL HTML files for testing
@@ -0,0 +1,191 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/sirosfoundation/g119612/pkg/etsi119612"
+	"github.com/sirosfoundation/g119612/pkg/logging"
+)
+
+// Modes accepted by the load step's mirror-mode option.
+const (
+	// MirrorModeOrder tries the mirrors one after the other (default).
+	MirrorModeOrder = "order"
+	// MirrorModeRace fetches from all mirrors at once and uses the first success.
+	MirrorModeRace = "race"
+)
+
+// mirrorResult is the outcome of fetching the root TSL from one mirror.
+type mirrorResult struct {
+	index int
+	url   string
+	root  *etsi119612.TSL
+	err   error
+}
+
+// parseMirrorMode checks a mirror-mode option value.
+func parseMirrorMode(value string) (string, error) {
+	switch value {
+	case MirrorModeOrder, MirrorModeRace:
+		return value, nil
+	}
+	return "", fmt.Errorf("invalid mirror-mode value %q (expected order or race)", value)
+}
+
+// fetchFromMirrors fetches a TSL from the first of urls that succeeds, all
+// URLs serving the same logical TSL, and then its references. It returns the
+// TSLs and the URL they were fetched from. With opts.CheckMirrors the root TSL
+// of every other reachable mirror must have the same sequence number and
+// content, otherwise the load fails with ErrMirrorMismatch; unreachable
+// mirrors are only logged.
+func fetchFromMirrors(pl *Pipeline, urls []string, options etsi119612.TSLFetchOptions, opts LoadOptions) ([]*etsi119612.TSL, string, error) {
+	if len(urls) == 1 {
+		tsls, err := etsi119612.FetchTSLWithReferencesAndOptions(urls[0], options)
+		if err != nil {
+			return nil, "", NewTSLLoadError(urls[0], err)
+		}
+		return tsls, urls[0], nil
+	}
+
+	var winner mirrorResult
+	var others []mirrorResult
+	var err error
+	if opts.MirrorMode == MirrorModeRace {
+		winner, others, err = raceMirrors(urls, options, opts.CheckMirrors)
+	} else {
+		winner, err = firstMirror(pl, urls, options)
+		if err == nil && opts.CheckMirrors {
+			// Mirrors before the winner failed already, only later ones are left
+			for i, url := range urls[winner.index+1:] {
+				root, err := etsi119612.FetchTSLWithOptions(url, options)
+				others = append(others, mirrorResult{index: winner.index + 1 + i, url: url, root: root, err: err})
+			}
+		}
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	if pl.Logger != nil {
+		pl.Logger.Info("Loaded TSL from mirror", tslFields(winner.root,
+			logging.F("mirror", winner.index+1),
+			logging.F("mirror_count", len(urls)),
+			logging.F("mode", mirrorMode(opts)))...)
+	}
+	if opts.CheckMirrors {
+		if err := checkMirrors(pl, winner, others); err != nil {
+			return nil, "", err
+		}
+	}
+	return winner.root.FetchReferencesWithOptions(options), winner.url, nil
+}
+
+// firstMirror fetches the root TSL from the mirrors in order and returns the
+// first success.
+func firstMirror(pl *Pipeline, urls []string, options etsi119612.TSLFetchOptions) (mirrorResult, error) {
+	var errs []error
+	for i, url := range urls {
+		root, err := etsi119612.FetchTSLWithOptions(url, options)
+		if err == nil {
+			return mirrorResult{index: i, url: url, root: root}, nil
+		}
+		if pl.Logger != nil {
+			pl.Logger.Warn("Failed to load TSL from mirror",
+				logging.F("url", url),
+				logging.F("mirror", i+1),
+				logging.F("transient", etsi119612.IsTransient(err)),
+				logging.F("error", err))
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", url, err))
+	}
+	return mirrorResult{}, fmt.Errorf("failed to load TSL from any of %d mirrors: %w", len(urls), errors.Join(errs...))
+}
+
+// raceMirrors fetches the root TSL from all mirrors at once and returns the
+// first success. With waitAll it also waits for the other mirrors and returns
+// their results; otherwise the remaining fetches are cancelled. References are
+// not fetched, the caller dereferences the winner only.
+func raceMirrors(urls []string, options etsi119612.TSLFetchOptions, waitAll bool) (mirrorResult, []mirrorResult, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	results := make(chan mirrorResult, len(urls))
+	for i, url := range urls {
+		go func() {
+			root, err := etsi119612.FetchTSLWithContext(ctx, url, options)
+			results <- mirrorResult{index: i, url: url, root: root, err: err}
+		}()
+	}
+
+	var winner *mirrorResult
+	var others []mirrorResult
+	var errs []error
+	for range urls {
+		result := <-results
+		switch {
+		case result.err != nil:
+			errs = append(errs, fmt.Errorf("%s: %w", result.url, result.err))
+			others = append(others, result)
+		case winner == nil:
+			winner = &result
+			if !waitAll {
+				return *winner, nil, nil
+			}
+		default:
+			others = append(others, result)
+		}
+	}
+	if winner == nil {
+		return mirrorResult{}, nil, fmt.Errorf("failed to load TSL from any of %d mirrors: %w", len(urls), errors.Join(errs...))
+	}
+	return *winner, others, nil
+}
+
+// checkMirrors compares the root TSL of the winning mirror with those of the
+// other mirrors by sequence number and content digest.
+func checkMirrors(pl *Pipeline, winner mirrorResult, others []mirrorResult) error {
+	root := winner.root
+	digest, err := tslContentHash(root)
+	if err != nil {
+		return err
+	}
+	var mismatches []error
+	for _, other := range others {
+		if other.err != nil {
+			if pl.Logger != nil {
+				pl.Logger.Warn("Could not check TSL mirror",
+					logging.F("url", other.url),
+					logging.F("transient", etsi119612.IsTransient(other.err)),
+					logging.F("error", other.err))
+			}
+			continue
+		}
+		otherRoot := other.root
+		if sequenceNumber(otherRoot) != sequenceNumber(root) {
+			mismatches = append(mismatches, fmt.Errorf("%s has sequence number %d, %s has %d",
+				other.url, sequenceNumber(otherRoot), winner.url, sequenceNumber(root)))
+			continue
+		}
+		otherDigest, err := tslContentHash(otherRoot)
+		if err != nil {
+			return err
+		}
+		if otherDigest != digest {
+			mismatches = append(mismatches, fmt.Errorf("%s and %s differ in content with sequence number %d",
+				other.url, winner.url, sequenceNumber(root)))
+		}
+	}
+	if len(mismatches) > 0 {
+		return fmt.Errorf("%w: %w", ErrMirrorMismatch, errors.Join(mismatches...))
+	}
+	return nil
+}
+
+// mirrorMode returns the effective mirror mode of opts.
+func mirrorMode(opts LoadOptions) string {
+	if opts.MirrorMode == "" {
+		return MirrorModeOrder
+	}
+	return opts.MirrorMode
+}
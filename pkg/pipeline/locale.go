@@ -0,0 +1,131 @@
+package pipeline
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// DefaultLocale is the language of the built-in labels, used for labels
+// missing from a language pack and when no pack matches the preference.
+const DefaultLocale = "en"
+
+//go:embed templates/locales/*.json
+var localeFiles embed.FS
+
+// locales are the embedded language packs by language, loaded at start-up.
+var locales = mustLoadLocales()
+
+// Locale is a language pack with the labels and headings ("chrome") of the
+// HTML produced by the generate_index, render and transform steps. Names and
+// other content of the TSLs are not translated.
+type Locale struct {
+	Lang     string // Language of the pack, e.g. "sv"
+	messages map[string]string
+}
+
+// Locales returns the languages of the embedded language packs, sorted.
+func Locales() []string {
+	langs := make([]string, 0, len(locales))
+	for lang := range locales {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// LocaleFor returns the language pack of the first preferred language there is
+// one for, matching regional variants such as "sv-FI" to their base language,
+// and the English pack if there is none.
+func LocaleFor(langs ...string) *Locale {
+	for _, lang := range langs {
+		lang = strings.ToLower(lang)
+		if messages, ok := locales[lang]; ok {
+			return &Locale{Lang: lang, messages: messages}
+		}
+		if base, _, ok := strings.Cut(lang, "-"); ok {
+			if messages, ok := locales[base]; ok {
+				return &Locale{Lang: base, messages: messages}
+			}
+		}
+	}
+	return &Locale{Lang: DefaultLocale, messages: locales[DefaultLocale]}
+}
+
+// Message returns the label with the given key, e.g. "tsl.next-update", in
+// the language of the pack. Labels the pack lacks are returned in English, and
+// unknown keys as the key itself.
+func (l *Locale) Message(key string) string {
+	if l != nil {
+		if message, ok := l.messages[key]; ok {
+			return message
+		}
+	}
+	if message, ok := locales[DefaultLocale][key]; ok {
+		return message
+	}
+	return key
+}
+
+// Messages returns all labels of the pack by key, completed with English ones.
+func (l *Locale) Messages() map[string]string {
+	messages := make(map[string]string, len(locales[DefaultLocale]))
+	for key := range locales[DefaultLocale] {
+		messages[key] = l.Message(key)
+	}
+	return messages
+}
+
+// xsltParams returns the labels of the pack as stylesheet parameters named
+// after their keys, preceded by "lang" with the language and sorted by key. A
+// stylesheet uses the labels it declares as xsl:param and ignores the others.
+func (l *Locale) xsltParams() []XSLTParam {
+	messages := l.Messages()
+	keys := make([]string, 0, len(messages))
+	for key := range messages {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	params := []XSLTParam{{Name: "lang", Value: l.Lang}}
+	for _, key := range keys {
+		params = append(params, XSLTParam{Name: key, Value: messages[key]})
+	}
+	return params
+}
+
+// mustLoadLocales parses the embedded language packs. They are part of the
+// binary, so a malformed pack is a programming error.
+func mustLoadLocales() map[string]map[string]string {
+	files, err := localeFiles.ReadDir("templates/locales")
+	if err != nil {
+		panic(fmt.Sprintf("failed to read embedded language packs: %v", err))
+	}
+	loaded := make(map[string]map[string]string, len(files))
+	for _, file := range files {
+		data, err := localeFiles.ReadFile(path.Join("templates/locales", file.Name()))
+		if err != nil {
+			panic(fmt.Sprintf("failed to read language pack %s: %v", file.Name(), err))
+		}
+		var messages map[string]string
+		if err := json.Unmarshal(data, &messages); err != nil {
+			panic(fmt.Sprintf("invalid language pack %s: %v", file.Name(), err))
+		}
+		loaded[strings.TrimSuffix(file.Name(), path.Ext(file.Name()))] = messages
+	}
+	return loaded
+}
+
+// localeOption returns the language pack selected by a lang: option, or by
+// set-language if the option is not given, and nil if there is neither.
+func localeOption(ctx *Context, langs []string) *Locale {
+	if langs == nil {
+		langs = PreferredLanguages(ctx)
+	}
+	if len(langs) == 0 {
+		return nil
+	}
+	return LocaleFor(langs...)
+}
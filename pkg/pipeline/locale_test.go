@@ -0,0 +1,114 @@
+package pipeline
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sirosfoundation/g119612/pkg/logging"
+	"github.com/sirosfoundation/g119612/pkg/xslt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocales(t *testing.T) {
+	assert.Equal(t, []string{"de", "en", "fr", "sv"}, Locales())
+
+	// Every pack translates every label
+	for _, lang := range Locales() {
+		for key := range locales[DefaultLocale] {
+			assert.NotEmpty(t, locales[lang][key], "%s: %s", lang, key)
+		}
+		for key := range locales[lang] {
+			assert.Contains(t, locales[DefaultLocale], key, "%s: unknown label", lang)
+		}
+	}
+}
+
+func TestLocaleFor(t *testing.T) {
+	assert.Equal(t, "sv", LocaleFor("fi", "sv", "de").Lang)
+	assert.Equal(t, "sv", LocaleFor("SV-FI").Lang)
+	assert.Equal(t, "en", LocaleFor("fi").Lang)
+	assert.Equal(t, "en", LocaleFor().Lang)
+
+	de := LocaleFor("de")
+	assert.Equal(t, "Nächste Aktualisierung", de.Message("tsl.next-update"))
+	assert.Equal(t, "no.such-label", de.Message("no.such-label"))
+	assert.Equal(t, "Next Update", (*Locale)(nil).Message("tsl.next-update"))
+
+	// Labels missing from a pack fall back to English
+	partial := &Locale{Lang: "xx", messages: map[string]string{"tsl.status": "Statu"}}
+	assert.Equal(t, "Statu", partial.Message("tsl.status"))
+	assert.Equal(t, "Services", partial.Messages()["tsl.services"])
+
+	params := LocaleFor("fr").xsltParams()
+	assert.Equal(t, XSLTParam{Name: "lang", Value: "fr"}, params[0])
+	assert.Len(t, params, len(locales[DefaultLocale])+1)
+	assert.Contains(t, params, XSLTParam{Name: "tsl.back-to-index", Value: "Retour à l'index"})
+}
+
+// TestLocale_XSLTDefaults checks that the labels declared by the embedded
+// stylesheet exist and default to their English text.
+func TestLocale_XSLTDefaults(t *testing.T) {
+	data, err := xslt.Get("tsl-to-html.xslt")
+	require.NoError(t, err)
+	var stylesheet struct {
+		Params []struct {
+			Name   string `xml:"name,attr"`
+			Select string `xml:"select,attr"`
+		} `xml:"param"`
+	}
+	require.NoError(t, xml.Unmarshal(data, &stylesheet))
+	require.NotEmpty(t, stylesheet.Params)
+	for _, param := range stylesheet.Params {
+		if param.Name == "lang" {
+			assert.Equal(t, "'en'", param.Select)
+			continue
+		}
+		require.Contains(t, locales[DefaultLocale], param.Name)
+		assert.Equal(t, "'"+locales[DefaultLocale][param.Name]+"'", param.Select, param.Name)
+	}
+}
+
+func TestTransformTSL_Language(t *testing.T) {
+	dir := t.TempDir()
+	// A stand-in for xsltproc that outputs its arguments
+	fake := filepath.Join(dir, "xsltproc")
+	require.NoError(t, os.WriteFile(fake, []byte("#!/bin/sh\nfor arg in \"$@\"; do echo \"$arg\"; done\n"), 0755))
+	saved := xsltprocCommand
+	xsltprocCommand = fake
+	defer func() { xsltprocCommand = saved }()
+
+	pl := &Pipeline{Logger: logging.SilentLogger()}
+	ctx := NewContext()
+	ctx.AddTSL(generateTSL("Test Service", "http://uri.etsi.org/TrstSvc/Svctype/CA/QC", nil))
+	transform := func(ctx *Context, args ...string) string {
+		t.Helper()
+		outDir := filepath.Join(t.TempDir(), "out")
+		_, err := TransformTSL(pl, ctx, append([]string{"embedded:tsl-to-html.xslt", outDir, "html"}, args...)...)
+		require.NoError(t, err)
+		files, err := os.ReadDir(outDir)
+		require.NoError(t, err)
+		require.Len(t, files, 1)
+		data, err := os.ReadFile(filepath.Join(outDir, files[0].Name()))
+		require.NoError(t, err)
+		return string(data)
+	}
+
+	// Without a language no labels are passed
+	assert.NotContains(t, transform(ctx), "--stringparam")
+
+	out := transform(ctx, "lang:sv")
+	assert.Contains(t, out, "--stringparam\nlang\nsv\n")
+	assert.Contains(t, out, "--stringparam\ntsl.next-update\nNästa uppdatering\n")
+	assert.True(t, strings.HasPrefix(out, "--nonet\n--novalid\n--stringparam\n"), out)
+
+	// The pipeline's language preference applies without the option
+	ctx.SetData(languageKey, []string{"de"})
+	assert.Contains(t, transform(ctx), "--stringparam\nlang\nde\n")
+
+	_, err := TransformTSL(pl, ctx, "embedded:tsl-to-html.xslt", dir, "html", "lang:deutsch")
+	assert.ErrorIs(t, err, ErrInvalidArguments)
+}
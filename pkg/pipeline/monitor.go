@@ -0,0 +1,113 @@
+package pipeline
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/sirosfoundation/g119612/pkg/logging"
+)
+
+// MonitoredCertificate is a leaf certificate that a CertificateMonitor
+// re-verifies against every new certificate pool.
+type MonitoredCertificate struct {
+	Name          string              // Where the certificate came from, e.g. its file
+	Leaf          *x509.Certificate   // The certificate to verify
+	Intermediates []*x509.Certificate // Untrusted intermediates for building chains
+}
+
+// LoadMonitoredCertificates reads the certificates to monitor from a PEM file,
+// or from the *.pem, *.crt and *.cer files of a directory. The first
+// certificate of each file is the leaf; the others are its intermediates.
+func LoadMonitoredCertificates(path string) ([]MonitoredCertificate, error) {
+	files, err := certificateFiles(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read monitored certificates: %w", err)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no certificate files found in %s", path)
+	}
+	monitored := make([]MonitoredCertificate, 0, len(files))
+	for _, file := range files {
+		certs, err := readPEMCertificates(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read monitored certificates: %w", err)
+		}
+		monitored = append(monitored, MonitoredCertificate{Name: file, Leaf: certs[0], Intermediates: certs[1:]})
+	}
+	return monitored, nil
+}
+
+// VerificationAlert reports a monitored certificate that verified against the
+// previous certificate pool but no longer verifies, typically because its
+// trust anchor was withdrawn or removed from a TSL.
+type VerificationAlert struct {
+	Name    string    `json:"name"`    // Name of the MonitoredCertificate
+	Subject string    `json:"subject"` // Subject of the leaf certificate
+	SHA256  string    `json:"sha256"`  // Hex SHA-256 fingerprint of the leaf certificate
+	Error   string    `json:"error"`   // Why verification failed
+	Time    time.Time `json:"time"`    // When the failure was detected
+}
+
+// CertificateMonitor re-verifies a fixed set of certificates against the pool
+// of each pipeline run and reports those that stopped verifying, as an early
+// warning of trust anchor removals. It is not safe for concurrent use.
+type CertificateMonitor struct {
+	certs    []MonitoredCertificate
+	verified []bool // Result of the previous check per certificate
+	checked  bool   // Whether Check ran before
+}
+
+// NewCertificateMonitor returns a monitor for certs.
+func NewCertificateMonitor(certs []MonitoredCertificate) *CertificateMonitor {
+	return &CertificateMonitor{certs: certs, verified: make([]bool, len(certs))}
+}
+
+// Check verifies the monitored certificates against the pool of ctx (see
+// Context.Verify) and returns the number that verified and an alert for each
+// certificate that verified at the previous check but no longer does.
+//
+// Certificates failing at the first check never verified, so they are logged
+// as warnings through pl.Logger instead of alerted. Certificates that verify
+// again are logged as recovered. pl may be nil.
+func (m *CertificateMonitor) Check(pl *Pipeline, ctx *Context) (int, []VerificationAlert) {
+	var alerts []VerificationAlert
+	count := 0
+	now := ctx.Now()
+	for i, cert := range m.certs {
+		_, err := ctx.Verify(cert.Leaf, cert.Intermediates...)
+		verified := err == nil
+		if verified {
+			count++
+		}
+		digest := sha256.Sum256(cert.Leaf.Raw)
+		fields := []logging.Field{
+			logging.F("certificate", cert.Name),
+			logging.F("subject", cert.Leaf.Subject.String()),
+			logging.F("sha256", hex.EncodeToString(digest[:])),
+		}
+		switch {
+		case !verified && (!m.checked || m.verified[i]):
+			if m.checked {
+				alerts = append(alerts, VerificationAlert{
+					Name:    cert.Name,
+					Subject: cert.Leaf.Subject.String(),
+					SHA256:  hex.EncodeToString(digest[:]),
+					Error:   err.Error(),
+					Time:    now,
+				})
+			} else if pl != nil && pl.Logger != nil {
+				pl.Logger.Warn("Monitored certificate does not verify", append(fields, logging.F("error", err))...)
+			}
+		case verified && m.checked && !m.verified[i]:
+			if pl != nil && pl.Logger != nil {
+				pl.Logger.Info("Monitored certificate verifies again", fields...)
+			}
+		}
+		m.verified[i] = verified
+	}
+	m.checked = true
+	return count, alerts
+}
@@ -0,0 +1,145 @@
+package pipeline
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirosfoundation/g119612/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// monitorTestCert creates a certificate signed by parent, or a self-signed CA
+// certificate if parent is nil.
+func monitorTestCert(t *testing.T, name string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage |= x509.KeyUsageCertSign
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert, key
+}
+
+func TestCertificateMonitor(t *testing.T) {
+	ca, caKey := monitorTestCert(t, "Monitored CA", nil, nil)
+	other, otherKey := monitorTestCert(t, "Other CA", nil, nil)
+	leaf, _ := monitorTestCert(t, "service.example.com", ca, caKey)
+	otherLeaf, _ := monitorTestCert(t, "other.example.com", other, otherKey)
+
+	withRoots := func(roots ...*x509.Certificate) *Context {
+		ctx := NewContext()
+		pool := x509.NewCertPool()
+		for _, root := range roots {
+			pool.AddCert(root)
+		}
+		ctx.VerifyOptions = &x509.VerifyOptions{Roots: pool, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny}}
+		return ctx
+	}
+
+	var buf bytes.Buffer
+	logger := logging.NewLogger(logging.InfoLevel)
+	logger.(logging.OutputConfigurable).SetOutput(&buf)
+	pl := &Pipeline{Logger: logger}
+
+	monitor := NewCertificateMonitor([]MonitoredCertificate{
+		{Name: "leaf.pem", Leaf: leaf},
+		{Name: "other.pem", Leaf: otherLeaf},
+	})
+
+	// The other certificate never verified, so it is only logged
+	count, alerts := monitor.Check(pl, withRoots(ca))
+	assert.Equal(t, 1, count)
+	assert.Empty(t, alerts)
+	assert.Contains(t, buf.String(), "Monitored certificate does not verify")
+	assert.Contains(t, buf.String(), "other.example.com")
+
+	count, alerts = monitor.Check(pl, withRoots(ca))
+	assert.Equal(t, 1, count)
+	assert.Empty(t, alerts, "nothing changed")
+
+	// The anchor of the verified certificate is removed
+	count, alerts = monitor.Check(pl, withRoots(other))
+	assert.Equal(t, 1, count)
+	require.Len(t, alerts, 1)
+	assert.Equal(t, "leaf.pem", alerts[0].Name)
+	assert.Equal(t, "CN=service.example.com", alerts[0].Subject)
+	assert.Len(t, alerts[0].SHA256, 64)
+	assert.NotEmpty(t, alerts[0].Error)
+	assert.False(t, alerts[0].Time.IsZero())
+
+	_, alerts = monitor.Check(pl, withRoots(other))
+	assert.Empty(t, alerts, "a failure is alerted once")
+
+	buf.Reset()
+	count, alerts = monitor.Check(pl, withRoots(ca, other))
+	assert.Equal(t, 2, count)
+	assert.Empty(t, alerts)
+	assert.Contains(t, buf.String(), "Monitored certificate verifies again")
+
+	// Without a pool nothing verifies
+	_, alerts = monitor.Check(nil, NewContext())
+	assert.Len(t, alerts, 2)
+}
+
+func TestLoadMonitoredCertificates(t *testing.T) {
+	ca, caKey := monitorTestCert(t, "Issuing CA", nil, nil)
+	leaf, _ := monitorTestCert(t, "leaf", ca, caKey)
+	encode := func(certs ...*x509.Certificate) []byte {
+		var data []byte
+		for _, cert := range certs {
+			data = append(data, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})...)
+		}
+		return data
+	}
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.pem"), encode(leaf, ca), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "b.crt"), encode(ca), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("not a certificate"), 0644))
+
+	monitored, err := LoadMonitoredCertificates(dir)
+	require.NoError(t, err)
+	require.Len(t, monitored, 2)
+	assert.Equal(t, filepath.Join(dir, "a.pem"), monitored[0].Name)
+	assert.True(t, monitored[0].Leaf.Equal(leaf))
+	require.Len(t, monitored[0].Intermediates, 1)
+	assert.True(t, monitored[0].Intermediates[0].Equal(ca))
+	assert.Empty(t, monitored[1].Intermediates)
+
+	single, err := LoadMonitoredCertificates(filepath.Join(dir, "a.pem"))
+	require.NoError(t, err)
+	assert.Len(t, single, 1)
+
+	_, err = LoadMonitoredCertificates(filepath.Join(dir, "missing"))
+	assert.Error(t, err)
+	_, err = LoadMonitoredCertificates(t.TempDir())
+	assert.ErrorContains(t, err, "no certificate files")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "empty.pem"), []byte("no certificates here"), 0644))
+	_, err = LoadMonitoredCertificates(dir)
+	assert.ErrorContains(t, err, "no certificates found")
+}
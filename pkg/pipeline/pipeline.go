@@ -3,8 +3,10 @@
 package pipeline
 
 import (
+	"bytes"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/sirosfoundation/g119612/pkg/logging"
 	"gopkg.in/yaml.v3"
@@ -22,12 +24,37 @@ import (
 type Pipeline struct {
 	Pipes  []Pipe         // The ordered list of pipeline steps to execute
 	Logger logging.Logger // Logger for pipeline operations (never nil)
+
+	// ReadOnly makes Process simulate the steps that write files or sign, such
+	// as publish and generate_index: their outputs (see RegisterOutputs) are
+	// logged and the steps are skipped, while steps working in memory such as
+	// load, select and transform with "replace" run as usual.
+	ReadOnly bool
+
+	// Registry is where the steps are looked up, DefaultRegistry if nil.
+	Registry *Registry
+
+	// Baseline lists acknowledged warnings, which Process logs at debug level
+	// and does not pass to the event sinks, see WarningBaseline.
+	Baseline *WarningBaseline
+
+	// Clock is the time source of the contexts processed without a Clock of
+	// their own, the system clock if nil.
+	Clock Clock
+
+	sinks []EventSink // Event sinks notified during Process, see AddEventSink
 }
 
 // Process executes all the steps in the pipeline in sequence, passing the Context from one step to the next.
 // Each step modifies the Context and returns either a modified Context or an error.
 // If a step returns an error, pipeline processing stops and the error is returned.
 //
+// A context without a Clock gets the Clock of the pipeline, if any.
+//
+// Registered event sinks (see AddEventSink) are notified before and after each step
+// and for every warning logged through pl.Logger while the step runs, except
+// those acknowledged by pl.Baseline.
+//
 // Parameters:
 //   - ctx: The initial Context to pass to the first step of the pipeline
 //
@@ -35,13 +62,66 @@ type Pipeline struct {
 //   - A pointer to the final Context after all steps have been executed
 //   - An error if any step fails
 func (pl *Pipeline) Process(ctx *Context) (*Context, error) {
-	for i, pipe := range pl.Pipes {
-		fn, ok := GetFunctionByName(pipe.MethodName)
-		if !ok {
-			return nil, fmt.Errorf("step %d: unknown methodName '%s'", i, pipe.MethodName)
+	if ctx != nil && ctx.Clock == nil {
+		ctx.Clock = pl.Clock
+	}
+	cursor := &stepCursor{}
+	run := pl
+	if (len(pl.sinks) > 0 || pl.Baseline != nil) && pl.Logger != nil {
+		// Route warnings to the baseline and the event sinks for the duration of the run
+		run = pl.withLogger(&eventLogger{Logger: pl.Logger, pl: pl, cursor: cursor})
+	}
+	return run.processPipes(ctx, run.Pipes, cursor)
+}
+
+// withLogger returns a copy of the pipeline logging to logger. The loggers of
+// a run and of its steps are passed down this way rather than by replacing
+// pl.Logger, which others (such as the server) read while the pipeline runs.
+func (pl *Pipeline) withLogger(logger logging.Logger) *Pipeline {
+	run := *pl
+	run.Logger = logger
+	return &run
+}
+
+// processPipes runs a sequence of steps, the top level of the pipeline or a
+// branch of a conditional step.
+func (pl *Pipeline) processPipes(ctx *Context, pipes []Pipe, cursor *stepCursor) (*Context, error) {
+	for i, pipe := range pipes {
+		var fn StepFunc
+		if pipe.MethodName == ConditionalStep {
+			fn = func(pl *Pipeline, ctx *Context, _ ...string) (*Context, error) {
+				return pl.processConditional(ctx, pipe, cursor)
+			}
+		} else {
+			var ok bool
+			if fn, ok = pl.registry().Function(pipe.MethodName); !ok {
+				return nil, fmt.Errorf("step %d: unknown methodName '%s'", i, pipe.MethodName)
+			}
+		}
+		cursor.index, cursor.name = i, pipe.MethodName
+		if pl.ReadOnly {
+			fn = pl.readOnlyStep(i, pipe, fn)
+		}
+
+		event := StepEvent{
+			Index:   i,
+			Name:    pipe.MethodName,
+			Args:    pipe.MethodArguments,
+			Started: time.Now(),
+			Context: ctx,
+		}
+		pl.emitStepStart(event)
+
+		stepPl, err := pl.stepPipeline(i, pipe)
+		if err == nil {
+			ctx, err = fn(stepPl, ctx, pipe.MethodArguments...)
 		}
-		var err error
-		ctx, err = fn(pl, ctx, pipe.MethodArguments...)
+
+		event.Duration = time.Since(event.Started)
+		event.Err = err
+		event.Context = ctx
+		pl.emitStepEnd(event)
+
 		if err != nil {
 			return ctx, fmt.Errorf("step %d (%s) failed: %w", i, pipe.MethodName, err)
 		}
@@ -49,6 +129,41 @@ func (pl *Pipeline) Process(ctx *Context) (*Context, error) {
 	return ctx, nil
 }
 
+// readOnlyStep returns the function run for a step in read-only mode: fn
+// itself if the step has no outputs, and otherwise a function logging the
+// outputs instead of running it.
+func (pl *Pipeline) readOnlyStep(index int, pipe Pipe, fn StepFunc) StepFunc {
+	outputsFn, ok := pl.registry().Outputs(pipe.MethodName)
+	if !ok {
+		return fn
+	}
+	outputs := outputsFn(pipe.MethodArguments...)
+	if len(outputs) == 0 {
+		return fn
+	}
+	return func(pl *Pipeline, ctx *Context, args ...string) (*Context, error) {
+		pl.Logger.Info("Read-only mode: skipping step",
+			logging.F("step", pipe.MethodName),
+			logging.F("step_index", index),
+			logging.F("would_write", outputs))
+		return ctx, nil
+	}
+}
+
+// stepPipeline returns the pipeline passed to a step: pl itself, or a copy
+// with a child logger at the level of the step if it overrides the level.
+func (pl *Pipeline) stepPipeline(index int, pipe Pipe) (*Pipeline, error) {
+	if pipe.LogLevel == "" || pl.Logger == nil {
+		return pl, nil
+	}
+	level, err := logging.ParseLevel(pipe.LogLevel)
+	if err != nil {
+		return pl, fmt.Errorf("%w: %v", ErrInvalidArguments, err)
+	}
+	return pl.withLogger(logging.WithLevel(pl.Logger, level).WithFields(
+		logging.F("step", pipe.MethodName), logging.F("step_index", index))), nil
+}
+
 // NewPipeline loads a pipeline from a YAML file and returns a new Pipeline instance.
 // The YAML file must contain a sequence of steps, where each step is a map with a single key
 // (the method name) and a list of string arguments.
@@ -71,39 +186,144 @@ func (pl *Pipeline) Process(ctx *Context) (*Context, error) {
 //
 // Returns:
 //   - A new Pipeline instance with the steps loaded from the YAML file
-//   - An error if the file cannot be opened or parsed
+//   - An error if the file cannot be opened or parsed, or if Validate rejects a step
+//
+// Use NewSignedPipeline to require a valid signature of the file.
 func NewPipeline(filename string) (*Pipeline, error) {
-	file, err := os.Open(filename)
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return parsePipeline(data, nil)
+}
+
+// NewPipelineWithRegistry loads a pipeline like NewPipeline, looking up its
+// steps in reg instead of DefaultRegistry, for applications embedding
+// pipelines with their own steps (see Registry).
+func NewPipelineWithRegistry(filename string, reg *Registry) (*Pipeline, error) {
+	data, err := os.ReadFile(filename)
 	if err != nil {
 		return nil, err
 	}
-	defer file.Close()
+	return parsePipeline(data, reg)
+}
+
+// registry returns the registry the steps of pl are looked up in.
+func (pl *Pipeline) registry() *Registry {
+	if pl.Registry == nil {
+		return DefaultRegistry
+	}
+	return pl.Registry
+}
 
+// parsePipeline parses the YAML of a pipeline file and validates it against
+// reg, DefaultRegistry if nil.
+func parsePipeline(data []byte, reg *Registry) (*Pipeline, error) {
 	// Always use the default logger - configuration should come from cmdline args, not pipeline files
 	logger := logging.DefaultLogger()
 
 	// Parse the pipeline as a simple list of pipes (no config sections)
 	var pipes []Pipe
-	decoder := yaml.NewDecoder(file)
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
 	if err := decoder.Decode(&pipes); err != nil {
 		return nil, fmt.Errorf("failed to parse pipeline YAML: %w", err)
 	}
 
 	// Create a new pipeline with the parsed pipes
-	return &Pipeline{
-		Pipes:  pipes,
-		Logger: logger,
-	}, nil
+	pl := &Pipeline{
+		Pipes:    pipes,
+		Logger:   logger,
+		Registry: reg,
+	}
+	if err := pl.Validate(); err != nil {
+		return nil, err
+	}
+	return pl, nil
+}
+
+// Validate checks the arguments of every step that has a registered ArgsValidator,
+// the conditions of conditional steps and the order of the steps (see Check),
+// without running the pipeline. NewPipeline calls it so that mistakes such as
+// an unreadable signing key or a select step before any load step are reported
+// before any TSL is fetched. Order issues of SeverityWarning are logged.
+//
+// Returns:
+//   - nil if all checked steps have valid arguments and no step is bound to fail
+//   - An error wrapping ErrInvalidArguments naming the first invalid step, or
+//     ErrStepOrder naming the first misplaced step
+func (pl *Pipeline) Validate() error {
+	if err := pl.validatePipes(pl.Pipes); err != nil {
+		return err
+	}
+	for _, issue := range pl.Check() {
+		if issue.Severity == SeverityError {
+			return fmt.Errorf("step %s (%s): %w: %s", issue.Step, issue.Name, ErrStepOrder, issue.Message)
+		}
+		if pl.Logger != nil {
+			pl.Logger.Warn("Pipeline step has no effect",
+				logging.F("step", issue.Name),
+				logging.F("step_index", issue.Step),
+				logging.F("reason", issue.Message))
+		}
+	}
+	return nil
+}
+
+// validatePipes validates a sequence of steps, descending into the branches of
+// conditional steps.
+func (pl *Pipeline) validatePipes(pipes []Pipe) error {
+	for i, pipe := range pipes {
+		if pipe.LogLevel != "" {
+			if _, err := logging.ParseLevel(pipe.LogLevel); err != nil {
+				return fmt.Errorf("step %d (%s): %w: %v", i, pipe.MethodName, ErrInvalidArguments, err)
+			}
+		}
+		if pipe.MethodName == ConditionalStep {
+			if _, err := ParseCondition(pipe.Condition); err != nil {
+				return fmt.Errorf("step %d (%s): %w: %v", i, pipe.MethodName, ErrInvalidArguments, err)
+			}
+			if err := pl.validatePipes(pipe.Then); err != nil {
+				return fmt.Errorf("step %d (%s) then: %w", i, pipe.MethodName, err)
+			}
+			if err := pl.validatePipes(pipe.Else); err != nil {
+				return fmt.Errorf("step %d (%s) else: %w", i, pipe.MethodName, err)
+			}
+			continue
+		}
+		validate, ok := pl.registry().Validator(pipe.MethodName)
+		if !ok {
+			continue
+		}
+		if err := validate(pipe.MethodArguments...); err != nil {
+			return fmt.Errorf("step %d (%s): %w: %v", i, pipe.MethodName, ErrInvalidArguments, err)
+		}
+	}
+	return nil
 }
 
 // Pipe represents a single step in the pipeline with its method name and arguments.
 // It provides custom YAML unmarshalling to parse the pipeline configuration format.
 // Each Pipe corresponds to a registered StepFunc that will be executed during pipeline processing.
+//
+// A conditional step (MethodName "if") has no function; it runs Then or Else
+// depending on Condition, see processConditional.
 type Pipe struct {
 	MethodName      string   // The name of the registered function to call
 	MethodArguments []string // The arguments to pass to the function
+
+	Condition string // Condition of a conditional step, e.g. "cert-count > 0"
+	Then      []Pipe // Steps run when Condition holds
+	Else      []Pipe // Steps run when Condition does not hold
+
+	// LogLevel overrides the level of pl.Logger while the step runs, e.g.
+	// "debug" for a noisy step or "error" for a quiet one. The step logs
+	// through a child logger annotated with the step name and index.
+	LogLevel string
 }
 
+// LogLevelKey is the key of the per-step log level in pipeline YAML.
+const LogLevelKey = "log-level"
+
 // UnmarshalYAML implements the yaml.Unmarshaler interface for custom YAML parsing.
 // It expects a mapping node with exactly one key (the method name) and one value (a sequence of arguments).
 //
@@ -114,28 +334,136 @@ type Pipe struct {
 //   - arg2
 //   - arg3
 //
+// Any step may set its own log level with a log-level key, see Pipe.LogLevel:
+//
+//   - load:
+//   - https://example.com/tsl.xml
+//     log-level: debug
+//
+// YAML anchors and aliases may be used for steps, arguments and blocks of
+// arguments; an alias of a sequence inside an argument list is spliced in.
+// Merge keys ("<<: *anchor") copy the keys of an anchored step:
+//
+//	# The signer arguments are defined once and reused
+//	- publish:
+//	    - /var/www/tsl
+//	    - &signer [signature:cert:/etc/tsl/signer.pem, signature:key:/etc/tsl/signer.key]
+//	- publish: [/var/www/mirror, *signer]
+//
+// A conditional step is a mapping with the keys "if", "then" and optionally "else":
+//
+//   - if: "cert-count > 0"
+//     then:
+//   - publish: ["/var/www/tsl"]
+//     else:
+//   - log: ["Nothing selected, not publishing"]
+//
 // Parameters:
 //   - value: The YAML node to unmarshal
 //
 // Returns:
 //   - An error if the YAML structure doesn't match the expected format
 func (p *Pipe) UnmarshalYAML(value *yaml.Node) error {
-	if value.Kind != yaml.MappingNode || len(value.Content) != 2 {
+	value = resolveAlias(value)
+	if value.Kind != yaml.MappingNode {
 		return &yaml.TypeError{Errors: []string{"Pipe must be a map with a single key (method name) and a list of arguments"}}
 	}
-	methodNode := value.Content[0]
-	argsNode := value.Content[1]
+	entries, err := mappingEntries(value)
+	if err != nil {
+		return err
+	}
+	// Take out the log-level key common to all steps
+	content := make([]*yaml.Node, 0, len(entries))
+	for i := 0; i+1 < len(entries); i += 2 {
+		if entries[i].Value != LogLevelKey {
+			content = append(content, entries[i], entries[i+1])
+			continue
+		}
+		if entries[i+1].Kind != yaml.ScalarNode {
+			return &yaml.TypeError{Errors: []string{"log-level of a step must be a string"}}
+		}
+		p.LogLevel = entries[i+1].Value
+	}
+	if len(content) >= 2 && content[0].Value == ConditionalStep {
+		return p.unmarshalConditional(content)
+	}
+	if len(content) != 2 {
+		return &yaml.TypeError{Errors: []string{"Pipe must be a map with a single key (method name) and a list of arguments"}}
+	}
+	methodNode := content[0]
+	argsNode := content[1]
 	p.MethodName = methodNode.Value
 	if argsNode.Kind != yaml.SequenceNode {
 		return &yaml.TypeError{Errors: []string{"Pipe arguments must be a sequence"}}
 	}
-	p.MethodArguments = make([]string, len(argsNode.Content))
-	for i, arg := range argsNode.Content {
-		p.MethodArguments[i] = arg.Value
-	}
+	p.MethodArguments = flattenArguments(argsNode, make([]string, 0, len(argsNode.Content)))
 	return nil
 }
 
+// resolveAlias returns the node an alias refers to, or node itself if it is
+// not an alias.
+func resolveAlias(node *yaml.Node) *yaml.Node {
+	for node.Kind == yaml.AliasNode && node.Alias != nil {
+		node = node.Alias
+	}
+	return node
+}
+
+// mappingEntries returns the alternating keys and values of a mapping node
+// with aliases resolved and merge keys ("<<: *anchor") expanded. Keys of the
+// mapping itself take precedence over merged ones, and earlier merged
+// mappings over later ones, as in the YAML merge key specification.
+func mappingEntries(mapping *yaml.Node) ([]*yaml.Node, error) {
+	var entries, merged []*yaml.Node
+	seen := make(map[string]bool)
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		key, value := mapping.Content[i], resolveAlias(mapping.Content[i+1])
+		if key.Tag != "!!merge" && key.Value != "<<" {
+			entries = append(entries, key, value)
+			seen[key.Value] = true
+			continue
+		}
+		sources := []*yaml.Node{value}
+		if value.Kind == yaml.SequenceNode {
+			sources = value.Content
+		}
+		for _, source := range sources {
+			source = resolveAlias(source)
+			if source.Kind != yaml.MappingNode {
+				return nil, &yaml.TypeError{Errors: []string{"A merge key of a step must refer to a map"}}
+			}
+			sourceEntries, err := mappingEntries(source)
+			if err != nil {
+				return nil, err
+			}
+			merged = append(merged, sourceEntries...)
+		}
+	}
+	for i := 0; i+1 < len(merged); i += 2 {
+		if !seen[merged[i].Value] {
+			entries = append(entries, merged[i], merged[i+1])
+			seen[merged[i].Value] = true
+		}
+	}
+	return entries, nil
+}
+
+// flattenArguments appends the arguments of a sequence node to args. Aliases
+// are resolved, and nested sequences are spliced in, so that a block of
+// arguments defined once with an anchor can be reused in several steps, e.g.
+// "publish: [/var/www/mirror, *signer]" (see Pipe.UnmarshalYAML).
+func flattenArguments(seq *yaml.Node, args []string) []string {
+	for _, arg := range seq.Content {
+		arg = resolveAlias(arg)
+		if arg.Kind == yaml.SequenceNode {
+			args = flattenArguments(arg, args)
+			continue
+		}
+		args = append(args, arg.Value)
+	}
+	return args
+}
+
 // WithLogger returns a new Pipeline with the specified logger.
 // This allows for easy reconfiguration of the logger while preserving
 // the rest of the pipeline steps.
@@ -144,13 +472,18 @@ func (p *Pipe) UnmarshalYAML(value *yaml.Node) error {
 //   - logger: The new logger to use for the pipeline
 //
 // Returns:
-//   - A new Pipeline instance with the same steps but using the specified logger
+//   - A new Pipeline instance with the same steps, registry and event sinks but using the specified logger
 func (pl *Pipeline) WithLogger(logger logging.Logger) *Pipeline {
 	if logger == nil {
 		logger = logging.DefaultLogger()
 	}
 	return &Pipeline{
-		Pipes:  pl.Pipes,
-		Logger: logger,
+		Pipes:    pl.Pipes,
+		Logger:   logger,
+		ReadOnly: pl.ReadOnly,
+		Registry: pl.Registry,
+		Baseline: pl.Baseline,
+		Clock:    pl.Clock,
+		sinks:    pl.sinks,
 	}
 }
@@ -0,0 +1,167 @@
+package pipeline
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"os"
+)
+
+// SignatureSuffix is appended to the name of a pipeline file to find its
+// detached signature when no signature file is given, e.g. pipeline.yaml.sig.
+const SignatureSuffix = ".sig"
+
+// PipelineSigners are the public keys trusted to sign pipeline files. Since a
+// pipeline decides what is loaded, trusted and published, deployments can
+// require pipeline files to carry a detached signature by one of these keys.
+//
+// A signature is computed over the exact bytes of the pipeline file: an RSA
+// PKCS #1 v1.5 or ECDSA signature of its SHA-256 digest, or an Ed25519
+// signature of the file itself. It may be stored raw or base64 encoded, so
+// the output of
+//
+//	openssl dgst -sha256 -sign ops-key.pem -out pipeline.yaml.sig pipeline.yaml
+//
+// can be used as is.
+type PipelineSigners []crypto.PublicKey
+
+// LoadPipelineSigners reads the trusted pipeline signers from a PEM file with
+// certificates ("CERTIFICATE" blocks) or public keys ("PUBLIC KEY" blocks).
+// Several keys may be given, for example while a signing key is rotated.
+//
+// Parameters:
+//   - path: The PEM file
+//
+// Returns:
+//   - PipelineSigners: The RSA, ECDSA and Ed25519 keys of the file
+//   - error: Non-nil if the file cannot be read, holds no key or holds a block
+//     that cannot be parsed
+func LoadPipelineSigners(path string) (PipelineSigners, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pipeline signers: %w", err)
+	}
+	var signers PipelineSigners
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		var key crypto.PublicKey
+		switch block.Type {
+		case "CERTIFICATE":
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse pipeline signer certificate in %s: %w", path, err)
+			}
+			key = cert.PublicKey
+		case "PUBLIC KEY":
+			key, err = x509.ParsePKIXPublicKey(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse pipeline signer key in %s: %w", path, err)
+			}
+		default:
+			continue
+		}
+		switch key.(type) {
+		case *rsa.PublicKey, *ecdsa.PublicKey, ed25519.PublicKey:
+			signers = append(signers, key)
+		default:
+			return nil, fmt.Errorf("unsupported pipeline signer key type %T in %s", key, path)
+		}
+	}
+	if len(signers) == 0 {
+		return nil, fmt.Errorf("no certificate or public key found in %s", path)
+	}
+	return signers, nil
+}
+
+// Verify checks that signature is a signature of data by one of the signers.
+// Errors wrap ErrPipelineSignature.
+func (s PipelineSigners) Verify(data, signature []byte) error {
+	if len(s) == 0 {
+		return fmt.Errorf("%w: no trusted signers", ErrPipelineSignature)
+	}
+	signature = decodeSignature(signature)
+	digest := sha256.Sum256(data)
+	for _, key := range s {
+		switch key := key.(type) {
+		case *rsa.PublicKey:
+			if rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) == nil {
+				return nil
+			}
+		case *ecdsa.PublicKey:
+			if ecdsa.VerifyASN1(key, digest[:], signature) {
+				return nil
+			}
+		case ed25519.PublicKey:
+			if ed25519.Verify(key, data, signature) {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("%w: signature does not match any trusted signer", ErrPipelineSignature)
+}
+
+// decodeSignature returns the raw bytes of a signature that may be base64 encoded.
+func decodeSignature(signature []byte) []byte {
+	trimmed := bytes.TrimSpace(signature)
+	decoded := make([]byte, base64.StdEncoding.DecodedLen(len(trimmed)))
+	n, err := base64.StdEncoding.Decode(decoded, trimmed)
+	if err != nil || n == 0 {
+		return signature
+	}
+	return decoded[:n]
+}
+
+// NewSignedPipeline loads a pipeline like NewPipeline after verifying the
+// detached signature of the file. The file is read once, so the steps that are
+// parsed are exactly the bytes that were verified.
+//
+// Parameters:
+//   - filename: Path to the YAML pipeline file
+//   - signatureFile: Path to the detached signature, "" for filename + SignatureSuffix
+//   - signers: The keys trusted to sign pipelines
+//
+// Returns:
+//   - A new Pipeline instance with the steps loaded from the YAML file
+//   - An error wrapping ErrPipelineSignature if the signature is missing or does
+//     not verify, or any error NewPipeline returns
+func NewSignedPipeline(filename, signatureFile string, signers PipelineSigners) (*Pipeline, error) {
+	return NewSignedPipelineWithValues(filename, signatureFile, signers, nil)
+}
+
+// NewSignedPipelineWithValues loads a pipeline like NewSignedPipeline and,
+// unless values is nil, expands it with values like NewPipelineWithValues.
+// The signature covers the template, so the values should come from
+// LoadSignedValues.
+func NewSignedPipelineWithValues(filename, signatureFile string, signers PipelineSigners, values Values) (*Pipeline, error) {
+	if signatureFile == "" {
+		signatureFile = filename + SignatureSuffix
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := os.ReadFile(signatureFile)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", ErrPipelineSignature, filename, err)
+	}
+	if err := signers.Verify(data, signature); err != nil {
+		return nil, fmt.Errorf("%s (signature %s): %w", filename, signatureFile, err)
+	}
+	if values != nil {
+		if data, err = expandValues(filename, data, values); err != nil {
+			return nil, fmt.Errorf("%s: %w", filename, err)
+		}
+	}
+	return parsePipeline(data, nil)
+}
@@ -0,0 +1,154 @@
+package pipeline
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirosfoundation/g119612/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const signedPipelineYAML = "- echo: []\n- log: [\"signed\"]\n"
+
+// signPipeline signs data like the openssl commands documented for PipelineSigners.
+func signPipeline(t *testing.T, key crypto.Signer, data []byte) []byte {
+	t.Helper()
+	if _, ok := key.(ed25519.PrivateKey); ok {
+		signature, err := key.Sign(rand.Reader, data, crypto.Hash(0))
+		require.NoError(t, err)
+		return signature
+	}
+	digest := sha256.Sum256(data)
+	signature, err := key.Sign(rand.Reader, digest[:], crypto.SHA256)
+	require.NoError(t, err)
+	return signature
+}
+
+// writeSignerPEM writes the public key of key as a certificate or a PUBLIC KEY block.
+func writeSignerPEM(t *testing.T, path string, key crypto.Signer, asCertificate bool) {
+	t.Helper()
+	block := &pem.Block{Type: "PUBLIC KEY"}
+	if asCertificate {
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(1),
+			Subject:      pkix.Name{CommonName: "Pipeline Signer"},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+		require.NoError(t, err)
+		block = &pem.Block{Type: "CERTIFICATE", Bytes: der}
+	} else {
+		der, err := x509.MarshalPKIXPublicKey(key.Public())
+		require.NoError(t, err)
+		block.Bytes = der
+	}
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(block), 0644))
+}
+
+func TestNewSignedPipeline(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "pipeline.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(signedPipelineYAML), 0644))
+
+	keys := map[string]crypto.Signer{"rsa": rsaKey, "ecdsa": ecKey, "ed25519": edKey}
+	for name, key := range keys {
+		t.Run(name, func(t *testing.T) {
+			signerFile := filepath.Join(dir, name+".pem")
+			writeSignerPEM(t, signerFile, key, name != "ed25519")
+			signers, err := LoadPipelineSigners(signerFile)
+			require.NoError(t, err)
+			require.Len(t, signers, 1)
+
+			signature := signPipeline(t, key, []byte(signedPipelineYAML))
+			require.NoError(t, os.WriteFile(file+SignatureSuffix, signature, 0644))
+			pl, err := NewSignedPipeline(file, "", signers)
+			require.NoError(t, err)
+			assert.Len(t, pl.Pipes, 2)
+
+			// Base64 encoded signatures are accepted as well
+			encoded := filepath.Join(dir, name+".sig.b64")
+			require.NoError(t, os.WriteFile(encoded, []byte(base64.StdEncoding.EncodeToString(signature)+"\n"), 0644))
+			_, err = NewSignedPipeline(file, encoded, signers)
+			require.NoError(t, err)
+		})
+	}
+
+	signerFile := filepath.Join(dir, "ops.pem")
+	writeSignerPEM(t, signerFile, rsaKey, true)
+	signers, err := LoadPipelineSigners(signerFile)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(file+SignatureSuffix, signPipeline(t, rsaKey, []byte(signedPipelineYAML)), 0644))
+
+	t.Run("tampered", func(t *testing.T) {
+		tampered := filepath.Join(dir, "tampered.yaml")
+		require.NoError(t, os.WriteFile(tampered, []byte(signedPipelineYAML+"- publish: [\"/tmp/evil\"]\n"), 0644))
+		_, err := NewSignedPipeline(tampered, file+SignatureSuffix, signers)
+		assert.ErrorIs(t, err, ErrPipelineSignature)
+	})
+
+	t.Run("unsigned", func(t *testing.T) {
+		unsigned := filepath.Join(dir, "unsigned.yaml")
+		require.NoError(t, os.WriteFile(unsigned, []byte(signedPipelineYAML), 0644))
+		_, err := NewSignedPipeline(unsigned, "", signers)
+		assert.ErrorIs(t, err, ErrPipelineSignature)
+	})
+
+	t.Run("untrusted signer", func(t *testing.T) {
+		otherFile := filepath.Join(dir, "other.pem")
+		writeSignerPEM(t, otherFile, ecKey, false)
+		other, err := LoadPipelineSigners(otherFile)
+		require.NoError(t, err)
+		_, err = NewSignedPipeline(file, "", other)
+		assert.ErrorIs(t, err, ErrPipelineSignature)
+		_, err = NewSignedPipeline(file, "", nil)
+		assert.ErrorIs(t, err, ErrPipelineSignature)
+	})
+
+	t.Run("run-all", func(t *testing.T) {
+		unsigned := filepath.Join(dir, "unsigned.yaml")
+		load := func(file string) (*Pipeline, error) { return NewSignedPipeline(file, "", signers) }
+		results := RunAllWith([]string{file, unsigned}, 1, logging.SilentLogger(), load)
+		require.Len(t, results, 2)
+		assert.NoError(t, results[0].Err)
+		assert.ErrorIs(t, results[1].Err, ErrPipelineSignature)
+	})
+}
+
+func TestLoadPipelineSigners_Errors(t *testing.T) {
+	dir := t.TempDir()
+	_, err := LoadPipelineSigners(filepath.Join(dir, "missing.pem"))
+	assert.Error(t, err)
+
+	empty := filepath.Join(dir, "empty.pem")
+	require.NoError(t, os.WriteFile(empty, []byte("no keys here"), 0644))
+	_, err = LoadPipelineSigners(empty)
+	assert.ErrorContains(t, err, "no certificate or public key")
+
+	broken := filepath.Join(dir, "broken.pem")
+	require.NoError(t, os.WriteFile(broken, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte("junk")}), 0644))
+	_, err = LoadPipelineSigners(broken)
+	assert.Error(t, err)
+}
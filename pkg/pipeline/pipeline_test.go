@@ -1,8 +1,16 @@
 package pipeline
 
 import (
+	"bytes"
 	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"text/template"
 	"time"
@@ -403,6 +411,95 @@ func TestPipe_UnmarshalYAML_Errors(t *testing.T) {
 	err = yaml.Unmarshal([]byte(yamlData), &pipes)
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "Pipe arguments must be a sequence")
+
+	// Merge key referring to a scalar
+	yamlData = "- &dir /var/www/tsl\n- <<: *dir\n"
+	err = yaml.Unmarshal([]byte(yamlData), &pipes)
+	assert.Error(t, err)
+}
+
+func TestPipe_UnmarshalYAML_Anchors(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		want []Pipe
+	}{
+		{
+			name: "Argument_Block",
+			yaml: `
+- publish:
+    - /var/www/tsl
+    - &signer [signature:cert:/etc/tsl/signer.pem, signature:key:/etc/tsl/signer.key]
+- publish: [/var/www/mirror, *signer, sign-mode:stream]
+`,
+			want: []Pipe{
+				{MethodName: "publish", MethodArguments: []string{"/var/www/tsl", "signature:cert:/etc/tsl/signer.pem", "signature:key:/etc/tsl/signer.key"}},
+				{MethodName: "publish", MethodArguments: []string{"/var/www/mirror", "signature:cert:/etc/tsl/signer.pem", "signature:key:/etc/tsl/signer.key", "sign-mode:stream"}},
+			},
+		},
+		{
+			name: "Scalar_And_Argument_List",
+			yaml: `
+- publish: &args [&dir /var/www/tsl]
+- generate_index: [*dir]
+- publish: *args
+`,
+			want: []Pipe{
+				{MethodName: "publish", MethodArguments: []string{"/var/www/tsl"}},
+				{MethodName: "generate_index", MethodArguments: []string{"/var/www/tsl"}},
+				{MethodName: "publish", MethodArguments: []string{"/var/www/tsl"}},
+			},
+		},
+		{
+			name: "Step",
+			yaml: `
+- &publish {publish: [/var/www/tsl], log-level: debug}
+- *publish
+`,
+			want: []Pipe{
+				{MethodName: "publish", MethodArguments: []string{"/var/www/tsl"}, LogLevel: "debug"},
+				{MethodName: "publish", MethodArguments: []string{"/var/www/tsl"}, LogLevel: "debug"},
+			},
+		},
+		{
+			name: "Merge_Keys",
+			yaml: `
+- &publish {publish: [/var/www/tsl], log-level: debug}
+- <<: *publish
+  log-level: error
+- <<: [*publish, {log-level: warn}]
+`,
+			want: []Pipe{
+				{MethodName: "publish", MethodArguments: []string{"/var/www/tsl"}, LogLevel: "debug"},
+				{MethodName: "publish", MethodArguments: []string{"/var/www/tsl"}, LogLevel: "error"},
+				{MethodName: "publish", MethodArguments: []string{"/var/www/tsl"}, LogLevel: "debug"},
+			},
+		},
+		{
+			name: "Conditional",
+			yaml: `
+- select: []
+- if: "cert-count > 0"
+  then:
+    - publish: &out [/var/www/tsl]
+  else:
+    - log: *out
+`,
+			want: []Pipe{
+				{MethodName: "select", MethodArguments: []string{}},
+				{MethodName: ConditionalStep, MethodArguments: []string{"cert-count > 0"}, Condition: "cert-count > 0",
+					Then: []Pipe{{MethodName: "publish", MethodArguments: []string{"/var/www/tsl"}}},
+					Else: []Pipe{{MethodName: "log", MethodArguments: []string{"/var/www/tsl"}}}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var pipes []Pipe
+			require.NoError(t, yaml.Unmarshal([]byte(tt.yaml), &pipes))
+			assert.Equal(t, tt.want, pipes)
+		})
+	}
 }
 
 // TestSetFetchOptions_EdgeCases tests additional edge cases for SetFetchOptions
@@ -528,3 +625,490 @@ func TestSetFetchOptions_EdgeCases(t *testing.T) {
 		assert.Equal(t, 30*time.Second, ctx.TSLFetchOptions.Timeout)
 	})
 }
+
+func TestSetFetchOptionsTransport(t *testing.T) {
+	pl := &Pipeline{Logger: logging.SilentLogger()}
+
+	ctx, err := SetFetchOptions(pl, NewContext(),
+		"max-idle-conns-per-host:16", "idle-conn-timeout:30s", "http2:false", "compression:false")
+	require.NoError(t, err)
+	assert.Equal(t, etsi119612.TransportOptions{
+		MaxIdleConnsPerHost: 16,
+		IdleConnTimeout:     30 * time.Second,
+		DisableHTTP2:        true,
+		DisableCompression:  true,
+	}, ctx.TSLFetchOptions.Transport)
+
+	for _, arg := range []string{"max-idle-conns-per-host:0", "idle-conn-timeout:soon", "http2:maybe", "compression:maybe"} {
+		_, err := SetFetchOptions(pl, NewContext(), arg)
+		assert.Error(t, err, arg)
+	}
+}
+
+func TestSetFetchOptionsCache(t *testing.T) {
+	pl := &Pipeline{Logger: logging.SilentLogger()}
+
+	// The cache is on by default and shared by copies of the context
+	ctx := NewContext().EnsureTSLFetchOptions()
+	require.NotNil(t, ctx.TSLFetchOptions.Cache)
+	assert.Same(t, ctx.TSLFetchOptions.Cache, ctx.Copy().TSLFetchOptions.Cache)
+
+	ctx, err := SetFetchOptions(pl, ctx, "fetch-cache:false")
+	require.NoError(t, err)
+	assert.Nil(t, ctx.TSLFetchOptions.Cache)
+
+	ctx, err = SetFetchOptions(pl, ctx, "fetch-cache:true")
+	require.NoError(t, err)
+	assert.NotNil(t, ctx.TSLFetchOptions.Cache)
+
+	_, err = SetFetchOptions(pl, NewContext(), "fetch-cache:sometimes")
+	assert.Error(t, err)
+}
+
+func TestSetFetchOptionsRetries(t *testing.T) {
+	pl := &Pipeline{Logger: logging.SilentLogger()}
+
+	ctx, err := SetFetchOptions(pl, NewContext(), "retries:3", "retry-delay:250ms")
+	require.NoError(t, err)
+	assert.Equal(t, 3, ctx.TSLFetchOptions.Retries)
+	assert.Equal(t, 250*time.Millisecond, ctx.TSLFetchOptions.RetryDelay)
+	explained := effectiveFetchOptions(ctx)
+	assert.Equal(t, 3, explained.Retries)
+	assert.Equal(t, "250ms", explained.RetryDelay)
+	assert.Equal(t, "1s", effectiveFetchOptions(NewContext().EnsureTSLFetchOptions()).RetryDelay)
+
+	for _, arg := range []string{"retries:-1", "retries:many", "retry-delay:0s", "retry-delay:soon"} {
+		_, err := SetFetchOptions(pl, NewContext(), arg)
+		assert.Error(t, err, arg)
+	}
+}
+
+func TestSetFetchOptionsIPFSGateway(t *testing.T) {
+	pl := &Pipeline{Logger: logging.SilentLogger()}
+
+	ctx, err := SetFetchOptions(pl, NewContext(), "ipfs-gateway:http://127.0.0.1:8080")
+	require.NoError(t, err)
+	assert.Equal(t, "http://127.0.0.1:8080", ctx.TSLFetchOptions.IPFSGateway)
+	assert.Equal(t, "http://127.0.0.1:8080", effectiveFetchOptions(ctx).IPFSGateway)
+	assert.Equal(t, etsi119612.DefaultIPFSGateway, effectiveFetchOptions(NewContext().EnsureTSLFetchOptions()).IPFSGateway)
+
+	// An empty value restores the default gateway
+	ctx, err = SetFetchOptions(pl, ctx, "ipfs-gateway:")
+	require.NoError(t, err)
+	assert.Equal(t, etsi119612.DefaultIPFSGateway, effectiveFetchOptions(ctx).IPFSGateway)
+
+	for _, arg := range []string{"ipfs-gateway:ftp://gateway.example", "ipfs-gateway:not a url"} {
+		_, err := SetFetchOptions(pl, NewContext(), arg)
+		assert.Error(t, err, arg)
+	}
+}
+
+func TestSetFetchOptionsAlgorithmPolicy(t *testing.T) {
+	pl := &Pipeline{Logger: logging.SilentLogger()}
+
+	explained := effectiveFetchOptions(NewContext().EnsureTSLFetchOptions())
+	assert.Equal(t, etsi119612.AlgorithmPolicy{MinRSAKeySize: etsi119612.DefaultMinRSAKeySize}, explained.AlgorithmPolicy)
+
+	ctx, err := SetFetchOptions(pl, NewContext(),
+		"signature-algorithms:rsa-sha256, ecdsa-sha256",
+		"digest-algorithms:sha256",
+		"min-rsa-bits:3072",
+		"allow-sha1-url:https://legacy.example.com/tsl.xml")
+	require.NoError(t, err)
+	policy := etsi119612.AlgorithmPolicy{
+		SignatureAlgorithms: []string{"rsa-sha256", "ecdsa-sha256"},
+		DigestAlgorithms:    []string{"sha256"},
+		MinRSAKeySize:       3072,
+	}
+	assert.Equal(t, policy, ctx.TSLFetchOptions.AlgorithmPolicy)
+	assert.Equal(t, []string{"https://legacy.example.com/tsl.xml"}, ctx.TSLFetchOptions.AllowSHA1URLs)
+	assert.Nil(t, etsi119612.DefaultTSLFetchOptions.AllowSHA1URLs)
+
+	// The exemption follows later changes of the policy
+	ctx, err = SetFetchOptions(pl, ctx, "min-rsa-bits:4096", "allow-sha1-url:https://legacy.example.com/tsl.xml")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"https://legacy.example.com/tsl.xml"}, ctx.TSLFetchOptions.AllowSHA1URLs)
+	assert.Equal(t, []string{"https://legacy.example.com/tsl.xml"}, effectiveFetchOptions(ctx).AllowSHA1URLs)
+
+	// Empty lists accept any algorithm again
+	ctx, err = SetFetchOptions(pl, ctx, "signature-algorithms:", "allow-sha1:true")
+	require.NoError(t, err)
+	assert.Empty(t, ctx.TSLFetchOptions.AlgorithmPolicy.SignatureAlgorithms)
+	assert.True(t, effectiveFetchOptions(ctx).AlgorithmPolicy.AllowSHA1)
+
+	for _, arg := range []string{"allow-sha1:maybe", "allow-sha1-url:", "min-rsa-bits:big", "min-rsa-bits:-2"} {
+		_, err := SetFetchOptions(pl, NewContext(), arg)
+		assert.Error(t, err, arg)
+	}
+}
+
+func TestSetFetchOptionsSignerRoots(t *testing.T) {
+	pl := &Pipeline{Logger: logging.SilentLogger()}
+	roots := filepath.Join(t.TempDir(), "roots.pem")
+	require.NoError(t, os.WriteFile(roots, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: TestCert.Raw}), 0600))
+
+	ctx, err := SetFetchOptions(pl, NewContext(), "signer-roots:"+roots, "aia-allow-host:*.example.com", "aia-allow-host:pki.example.net")
+	require.NoError(t, err)
+	require.NotNil(t, ctx.TSLFetchOptions.SignerRoots)
+	require.NotNil(t, ctx.TSLFetchOptions.IssuerFetcher)
+	assert.True(t, ctx.TSLFetchOptions.IssuerFetcher.Allowed("http://ca.example.com/issuer.cer"))
+	assert.True(t, ctx.TSLFetchOptions.IssuerFetcher.Allowed("http://pki.example.net/issuer.cer"))
+	explained := effectiveFetchOptions(ctx)
+	assert.Equal(t, 1, explained.SignerRoots)
+	assert.Equal(t, []string{"*.example.com", "pki.example.net"}, explained.IssuerHosts)
+	assert.Nil(t, etsi119612.DefaultTSLFetchOptions.SignerRoots)
+
+	for _, arg := range []string{"signer-roots:" + filepath.Join(t.TempDir(), "missing.pem"), "aia-allow-host:", "aia-allow-host:http://ca.example.com/"} {
+		_, err := SetFetchOptions(pl, NewContext(), arg)
+		assert.Error(t, err, arg)
+	}
+}
+
+func TestSetFetchOptionsRawSpill(t *testing.T) {
+	pl := &Pipeline{Logger: logging.SilentLogger()}
+	dir := t.TempDir()
+
+	ctx, err := SetFetchOptions(pl, NewContext(), "raw-spill-dir:"+dir)
+	require.NoError(t, err)
+	assert.Equal(t, dir, ctx.TSLFetchOptions.RawSpillDir)
+	explained := effectiveFetchOptions(ctx)
+	assert.Equal(t, dir, explained.RawSpillDir)
+	assert.Equal(t, etsi119612.DefaultRawSpillThreshold, explained.RawSpillThreshold)
+
+	ctx, err = SetFetchOptions(pl, ctx, "raw-spill-threshold:1024")
+	require.NoError(t, err)
+	ctx, err = LoadTSL(pl, ctx, "./testdata/test-tsl.xml")
+	require.NoError(t, err)
+	tree, _ := ctx.TSLTrees.Peek()
+	assert.Nil(t, tree.Root.TSL.Raw)
+	raw, err := tree.Root.TSL.RawXML()
+	require.NoError(t, err)
+	original, err := os.ReadFile("./testdata/test-tsl.xml")
+	require.NoError(t, err)
+	assert.Equal(t, original, raw)
+
+	for _, arg := range []string{"raw-spill-threshold:0", "raw-spill-threshold:large"} {
+		_, err := SetFetchOptions(pl, NewContext(), arg)
+		assert.Error(t, err, arg)
+	}
+}
+
+func TestSetFetchOptionsHost(t *testing.T) {
+	pl := &Pipeline{Logger: logging.SilentLogger()}
+
+	ctx, err := SetFetchOptions(pl, NewContext(),
+		"timeout:30s",
+		"host:ec.europa.eu timeout:180s retries:5 retry-delay:10s",
+		"host:*.example.se  rate-limit:30/m accept:text/xml,application/xml user-agent:Mozilla/5.0 (compatible; TSL)")
+	require.NoError(t, err)
+	require.Len(t, ctx.TSLFetchOptions.HostOverrides, 2)
+	five := 5
+	assert.Equal(t, etsi119612.HostOverride{
+		Pattern:    "ec.europa.eu",
+		Timeout:    180 * time.Second,
+		Retries:    &five,
+		RetryDelay: 10 * time.Second,
+	}, ctx.TSLFetchOptions.HostOverrides[0])
+	assert.Equal(t, etsi119612.HostOverride{
+		Pattern:       "*.example.se",
+		MinInterval:   2 * time.Second,
+		AcceptHeaders: []string{"text/xml", "application/xml"},
+		UserAgent:     "Mozilla/5.0 (compatible; TSL)",
+	}, ctx.TSLFetchOptions.HostOverrides[1])
+	assert.Equal(t, 180*time.Second, ctx.TSLFetchOptions.ForURL("https://ec.europa.eu/tools/lotl/eu-lotl.xml").Timeout)
+	assert.Equal(t, 30*time.Second, ctx.TSLFetchOptions.ForURL("https://tsl.example.se/tsl.xml").Timeout)
+	assert.Equal(t, []string{
+		"ec.europa.eu timeout:3m0s retries:5 retry-delay:10s",
+		"*.example.se accept:text/xml,application/xml min-interval:2s user-agent:Mozilla/5.0 (compatible; TSL)",
+	}, effectiveFetchOptions(ctx).HostOverrides)
+
+	// Setting a pattern again replaces its options, and no options remove them
+	shared := ctx.TSLFetchOptions.HostOverrides
+	ctx, err = SetFetchOptions(pl, ctx, "host:EC.europa.eu retries:0", "host:*.example.se")
+	require.NoError(t, err)
+	zero := 0
+	assert.Equal(t, []etsi119612.HostOverride{{Pattern: "EC.europa.eu", Retries: &zero}}, ctx.TSLFetchOptions.HostOverrides)
+	assert.Equal(t, "ec.europa.eu", shared[0].Pattern)
+
+	for _, arg := range []string{
+		"host:",
+		"host:https://ec.europa.eu",
+		"host:ec.europa.eu:443 timeout:1s",
+		"host:ec.europa.eu timeout:slow",
+		"host:ec.europa.eu retries:-1",
+		"host:ec.europa.eu rate-limit:10",
+		"host:ec.europa.eu rate-limit:0/s",
+		"host:ec.europa.eu rate-limit:1/d",
+		"host:ec.europa.eu min-interval:0s",
+		"host:ec.europa.eu max-depth:2",
+	} {
+		_, err := SetFetchOptions(pl, NewContext(), arg)
+		assert.Error(t, err, arg)
+	}
+}
+
+func TestNewPipeline_ValidatesPublishSigner(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	require.NoError(t, generateTestCertAndKey(certFile, keyFile))
+	require.NoError(t, os.Chmod(keyFile, 0600))
+	notPEM := filepath.Join(dir, "not.pem")
+	require.NoError(t, os.WriteFile(notPEM, []byte("not a PEM file"), 0600))
+	missing := filepath.Join(dir, "missing.pem")
+	outDir := filepath.Join(dir, "out")
+
+	load := func(args ...string) error {
+		file := filepath.Join(t.TempDir(), "pipeline.yaml")
+		data, err := yaml.Marshal([]map[string][]string{
+			{"load": {"tsl.xml"}},
+			{"publish": args},
+		})
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(file, data, 0600))
+		_, err = NewPipeline(file)
+		return err
+	}
+
+	valid := [][]string{
+		{outDir},
+		{outDir, "tree:territory"},
+		{outDir, certFile, keyFile},
+		{outDir, certFile, keyFile, "rollover-cert:" + certFile, "rollover-key:" + keyFile, "rollover-dir:" + filepath.Join(dir, "next")},
+		{outDir, "pkcs11:module=libsofthsm2.so;pin=1234", "key"},
+	}
+	for _, args := range valid {
+		assert.NoError(t, load(args...), "%v", args)
+	}
+
+	invalid := []struct {
+		args []string
+		want string
+	}{
+		{[]string{outDir, missing, keyFile}, "failed to read certificate file"},
+		{[]string{outDir, certFile, missing}, "failed to read key file"},
+		{[]string{outDir, notPEM, keyFile}, "failed to decode certificate PEM"},
+		{[]string{outDir, certFile, notPEM}, "failed to decode key PEM"},
+		{[]string{outDir, keyFile, certFile}, "failed to parse certificate"},
+		{[]string{outDir, "pkcs11:pin=1234", "key"}, "invalid PKCS#11 URI"},
+		{[]string{outDir, "pkcs11:module=" + missing, "key"}, "PKCS#11 module not found"},
+		{[]string{outDir, certFile, keyFile, "rollover-cert:" + certFile, "rollover-key:" + missing, "rollover-dir:" + filepath.Join(dir, "next")}, "rollover signer"},
+		{[]string{outDir, "rollover-cert:" + certFile, "rollover-key:" + keyFile, "rollover-dir:" + filepath.Join(dir, "next")}, "requires a signer"},
+		{[]string{outDir, "indent:tabs"}, "indent"},
+	}
+	for _, tc := range invalid {
+		err := load(tc.args...)
+		require.Error(t, err, "%v", tc.args)
+		assert.True(t, errors.Is(err, ErrInvalidArguments), "%v", tc.args)
+		assert.Contains(t, err.Error(), "step 1 (publish)", "%v", tc.args)
+		assert.Contains(t, err.Error(), tc.want, "%v", tc.args)
+		assert.NotContains(t, err.Error(), "pin=", "%v", tc.args)
+	}
+
+	// An insecure key is only rejected with the strict policy
+	require.NoError(t, os.Chmod(keyFile, 0644))
+	assert.NoError(t, load(outDir, certFile, keyFile))
+	err := load(outDir, certFile, keyFile, "key-permissions:strict")
+	assert.ErrorContains(t, err, fmt.Sprintf("%04o", 0644))
+}
+
+func TestPipeline_StepLogLevel(t *testing.T) {
+	RegisterFunction("test-log-levels", func(pl *Pipeline, ctx *Context, args ...string) (*Context, error) {
+		pl.Logger.Debug("debug from " + args[0])
+		pl.Logger.Warn("warning from " + args[0])
+		return ctx, nil
+	})
+	yamlData := `
+- test-log-levels: [noisy]
+  log-level: debug
+- test-log-levels: [default]
+- log-level: error
+  test-log-levels: [quiet]
+- if: "tsl-count == 0"
+  log-level: DEBUG
+  then:
+    - test-log-levels: [branch]
+`
+	var pipes []Pipe
+	require.NoError(t, yaml.Unmarshal([]byte(yamlData), &pipes))
+	require.Len(t, pipes, 4)
+	assert.Equal(t, "debug", pipes[0].LogLevel)
+	assert.Equal(t, "test-log-levels", pipes[2].MethodName)
+	assert.Equal(t, []string{"quiet"}, pipes[2].MethodArguments)
+	assert.Equal(t, ConditionalStep, pipes[3].MethodName)
+
+	var buf bytes.Buffer
+	logger := logging.NewLogger(logging.InfoLevel)
+	logger.(logging.OutputConfigurable).SetOutput(&buf)
+	pl := &Pipeline{Pipes: pipes, Logger: logger}
+	var warnings []WarningEvent
+	pl.AddEventSink(EventSinkFuncs{Warning: func(e WarningEvent) { warnings = append(warnings, e) }})
+
+	_, err := pl.Process(NewContext())
+	require.NoError(t, err)
+	out := buf.String()
+	assert.Contains(t, out, `msg="debug from noisy" step=test-log-levels step_index=0`)
+	assert.NotContains(t, out, "debug from default")
+	assert.Contains(t, out, "warning from default")
+	assert.NotContains(t, out, "warning from quiet")
+	assert.Contains(t, out, "debug from branch")
+	assert.Same(t, logger, pl.Logger, "the pipeline logger is restored")
+	assert.Equal(t, logging.InfoLevel, logger.GetLevel())
+	assert.Len(t, warnings, 4, "warnings of steps with their own level reach the sinks")
+
+	_, err = parsePipeline([]byte("- echo: []\n  log-level: chatty\n"), nil)
+	assert.ErrorIs(t, err, ErrInvalidArguments)
+	assert.ErrorContains(t, err, "chatty")
+
+	err = yaml.Unmarshal([]byte("- echo: []\n  log-level: [debug]\n"), &pipes)
+	assert.ErrorContains(t, err, "log-level")
+}
+
+func TestPipeline_ReadOnly(t *testing.T) {
+	tmplBytes, err := os.ReadFile("./testdata/test-tsl.xml")
+	require.NoError(t, err)
+	tmpl, err := template.New("tsl").Parse(string(tmplBytes))
+	require.NoError(t, err)
+	var tslData bytes.Buffer
+	require.NoError(t, tmpl.Execute(&tslData, map[string]string{"X509Certificate": TestCertBase64}))
+	dir := t.TempDir()
+	tslFile := filepath.Join(dir, "test-tsl.xml")
+	require.NoError(t, os.WriteFile(tslFile, tslData.Bytes(), 0644))
+
+	outDir := filepath.Join(dir, "out")
+	require.NoError(t, os.Mkdir(outDir, 0755))
+	yamlData := fmt.Sprintf(`
+- load: [%[1]q]
+- select: ["cache-dir:%[2]s/cache", "exclusion-report:%[2]s/excluded.json"]
+- publish: [%[2]q]
+- render: ["embedded:tsl.html", %[2]q]
+- transform: ["embedded:tsl-to-html.xslt", %[2]q, "html"]
+- if: "tsl-count > 0"
+  then:
+    - generate_index: [%[2]q]
+`, tslFile, outDir)
+	pl, err := parsePipeline([]byte(yamlData), nil)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	logger := logging.NewLogger(logging.InfoLevel)
+	logger.(logging.OutputConfigurable).SetOutput(&buf)
+	pl.Logger = logger
+	pl.ReadOnly = true
+	ctx, err := pl.Process(NewContext())
+	require.NoError(t, err)
+
+	// Loading and selecting still happen
+	assert.Equal(t, 1, ctx.TSLs.Size())
+	require.NotNil(t, ctx.CertPool)
+	_, err = TestCert.Verify(x509.VerifyOptions{Roots: ctx.CertPool})
+	assert.NoError(t, err)
+
+	// Nothing is written
+	files, err := os.ReadDir(outDir)
+	require.NoError(t, err)
+	assert.Empty(t, files)
+	out := buf.String()
+	assert.Contains(t, out, "Read-only mode: skipping step")
+	assert.Contains(t, out, "step=publish")
+	assert.Contains(t, out, "step=generate_index")
+	assert.Contains(t, out, filepath.Join(outDir, "index.html"))
+	assert.Contains(t, out, "Read-only mode: not writing exclusion report")
+	assert.Contains(t, out, "Read-only mode: not updating select cache")
+}
+
+func TestRegisteredOutputs(t *testing.T) {
+	outputs := func(step string, args ...string) []string {
+		t.Helper()
+		fn, ok := GetOutputsByName(step)
+		require.True(t, ok, step)
+		return fn(args...)
+	}
+
+	assert.Equal(t, []string{"/out"}, outputs("publish", "/out"))
+	assert.Equal(t, []string{"/out", "signature:cert.pem"}, outputs("publish", "/out", "cert.pem", "key.pem"))
+	assert.Equal(t, []string{"/out", "signature:pkcs11"}, outputs("publish", "/out", "pkcs11:pkcs11:token=x", "key", "cert"))
+	assert.Equal(t, []string{"/out"}, outputs("render", "embedded:tsl.html", "/out", "lang:sv", "split-providers:50"))
+	assert.Equal(t, []string{"/out"}, outputs("transform", "embedded:tsl-to-html.xslt", "/out", "html"))
+	assert.Nil(t, outputs("transform", "keep-failed:/failed", "lang:de", "style.xslt", "replace"))
+	assert.Equal(t, []string{"/out/index.html"}, outputs("generate_index", "lang:fr", "/out", "Title"))
+	assert.Equal(t, []string{"/out/tsl.zip"}, outputs("export-notification", "tsl:0", "/out/tsl.zip"))
+	assert.Nil(t, outputs("publish"))
+
+	_, ok := GetOutputsByName("select")
+	assert.False(t, ok)
+}
+
+func TestPipeline_DeterministicOutput(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, territory string, pointers ...string) string {
+		var refs strings.Builder
+		for _, pointer := range pointers {
+			refs.WriteString("<tsl:OtherTSLPointer><tsl:TSLLocation>" + pointer + "</tsl:TSLLocation></tsl:OtherTSLPointer>")
+		}
+		cert, _ := monitorTestCert(t, territory+" CA", nil, nil)
+		data := fmt.Sprintf(`<tsl:TrustServiceStatusList xmlns:tsl="http://uri.etsi.org/02231/v2#">
+  <tsl:SchemeInformation>
+    <tsl:SchemeTerritory>%s</tsl:SchemeTerritory>
+    <tsl:PointersToOtherTSL>%s</tsl:PointersToOtherTSL>
+  </tsl:SchemeInformation>
+  <tsl:TrustServiceProviderList><tsl:TrustServiceProvider>
+    <tsl:TSPInformation><tsl:TSPName><tsl:Name xml:lang="en">%[1]s Provider</tsl:Name></tsl:TSPName></tsl:TSPInformation>
+    <tsl:TSPServices><tsl:TSPService><tsl:ServiceInformation>
+      <tsl:ServiceTypeIdentifier>http://uri.etsi.org/TrstSvc/Svctype/CA/QC</tsl:ServiceTypeIdentifier>
+      <tsl:ServiceName><tsl:Name xml:lang="en">%[1]s Service</tsl:Name></tsl:ServiceName>
+      <tsl:ServiceDigitalIdentity><tsl:DigitalId><tsl:X509Certificate>%s</tsl:X509Certificate></tsl:DigitalId></tsl:ServiceDigitalIdentity>
+    </tsl:ServiceInformation></tsl:TSPService></tsl:TSPServices>
+  </tsl:TrustServiceProvider></tsl:TrustServiceProviderList>
+</tsl:TrustServiceStatusList>`, territory, refs.String(), base64.StdEncoding.EncodeToString(cert.Raw))
+		path := filepath.Join(dir, name+".xml")
+		require.NoError(t, os.WriteFile(path, []byte(data), 0644))
+		return "file://" + path
+	}
+	var pointers []string
+	for _, territory := range []string{"SE", "AT", "FR", "DE", "BE", "NL"} {
+		pointers = append(pointers, write(strings.ToLower(territory), territory))
+	}
+	root := write("lotl", "EU", pointers...)
+
+	// Runs the pipeline and returns the published manifest, the published
+	// files and the PEM bundle of the selected pool
+	run := func() ([]PublishedFile, map[string][]byte, []byte) {
+		out := t.TempDir()
+		cache := t.TempDir()
+		pl, err := parsePipeline([]byte(fmt.Sprintf(`
+- set-fetch-options: ["max-depth:1"]
+- load: [%q]
+- select: ["reference-depth:1", "cache-dir:%s"]
+- publish: [%q, "manifest:true"]
+`, strings.TrimPrefix(root, "file://"), cache, out)), nil)
+		require.NoError(t, err)
+		pl.Logger = logging.SilentLogger()
+		_, err = pl.Process(NewContext())
+		require.NoError(t, err)
+
+		data, err := os.ReadFile(filepath.Join(out, ManifestFileName))
+		require.NoError(t, err)
+		var manifest PublishManifest
+		require.NoError(t, json.Unmarshal(data, &manifest))
+		files := make(map[string][]byte)
+		for _, file := range manifest.Files {
+			files[file.Path], err = os.ReadFile(filepath.Join(out, file.Path))
+			require.NoError(t, err)
+		}
+		bundles, err := filepath.Glob(filepath.Join(cache, "select-*.pem"))
+		require.NoError(t, err)
+		require.Len(t, bundles, 1)
+		bundle, err := os.ReadFile(bundles[0])
+		require.NoError(t, err)
+		return manifest.Files, files, bundle
+	}
+
+	wantManifest, wantFiles, wantBundle := run()
+	require.Len(t, wantManifest, 7)
+	for i := 0; i < 10; i++ {
+		manifest, files, bundle := run()
+		require.Equal(t, wantManifest, manifest, "run %d", i)
+		require.Equal(t, wantFiles, files, "run %d", i)
+		require.Equal(t, wantBundle, bundle, "run %d", i)
+	}
+}
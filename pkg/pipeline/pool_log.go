@@ -0,0 +1,322 @@
+package pipeline
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sirosfoundation/g119612/pkg/logging"
+)
+
+// Actions of PoolLogEntry.
+const (
+	// PoolLogAdd records a certificate entering the pool.
+	PoolLogAdd = "add"
+	// PoolLogRemove records a certificate leaving the pool.
+	PoolLogRemove = "remove"
+	// PoolLogRun records a run of select, after the additions and removals it caused.
+	PoolLogRun = "run"
+)
+
+// PoolLogEntry is one record of a PoolLog. Each entry is chained to its
+// predecessor by including the predecessor's Hash in its own, so any change
+// to a recorded entry, or its removal, breaks the chain.
+type PoolLogEntry struct {
+	Seq     int       `json:"seq"`               // Position in the log, starting at 1
+	Time    time.Time `json:"time"`              // When the run recording the entry selected its pool
+	Action  string    `json:"action"`            // PoolLogAdd, PoolLogRemove or PoolLogRun
+	SHA256  string    `json:"sha256,omitempty"`  // Hex SHA-256 fingerprint of the certificate, or of the pool for runs
+	Subject string    `json:"subject,omitempty"` // Subject of the certificate
+	Count   int       `json:"count,omitempty"`   // Number of certificates in the pool, for runs
+	Prev    string    `json:"prev"`              // Hash of the previous entry, empty for the first
+	Hash    string    `json:"hash"`              // Hex SHA-256 of Prev and the entry without Hash
+}
+
+// computeHash returns the hash of the entry as it is recorded in Hash.
+func (e PoolLogEntry) computeHash() (string, error) {
+	e.Hash = ""
+	data, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(append([]byte(e.Prev), data...))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// TrustPeriod is a time span during which a certificate was in the pool.
+type TrustPeriod struct {
+	Added   time.Time  `json:"added"`             // Run that added the certificate
+	Removed *time.Time `json:"removed,omitempty"` // Run that removed it, nil while it is trusted
+}
+
+// TrustHistory is what a PoolLog records about one certificate.
+type TrustHistory struct {
+	SHA256       string        `json:"sha256"`
+	Subject      string        `json:"subject,omitempty"`
+	Trusted      bool          `json:"trusted"`                 // In the pool at the last recorded run
+	FirstTrusted *time.Time    `json:"first_trusted,omitempty"` // First run with the certificate in the pool
+	LastTrusted  *time.Time    `json:"last_trusted,omitempty"`  // Last run with the certificate in the pool
+	Periods      []TrustPeriod `json:"periods"`
+}
+
+// PoolLog is an append-only, hash-chained log of the certificates entering and
+// leaving the pool built by select across runs, in the spirit of certificate
+// transparency. It supports audits of historical trust decisions, such as when
+// a certificate was first or last trusted (see History).
+//
+// The log is a file of JSON lines, one PoolLogEntry each. Every run of Record
+// appends the additions and removals since the previous run followed by a
+// PoolLogRun entry with the size and a fingerprint of the whole pool. The
+// chain detects changed, inserted and removed entries; to also detect a
+// truncated log, keep the Hash of the last entry elsewhere. A PoolLog is not
+// safe for concurrent use, and a log file must only be written by one
+// pipeline at a time.
+type PoolLog struct {
+	path    string
+	entries []PoolLogEntry
+	pool    map[string]string // Subjects of the certificates in the pool by fingerprint
+}
+
+// OpenPoolLog reads the pool log at path and verifies its hash chain. A
+// missing file is an empty log, created by the first Record.
+func OpenPoolLog(path string) (*PoolLog, error) {
+	l := &PoolLog{path: path, pool: make(map[string]string)}
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open pool log: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var entry PoolLogEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("%w: %s:%d: %v", ErrPoolLogCorrupt, path, line, err)
+		}
+		if err := l.verify(entry); err != nil {
+			return nil, fmt.Errorf("%w: %s:%d: %v", ErrPoolLogCorrupt, path, line, err)
+		}
+		l.apply(entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read pool log: %w", err)
+	}
+	return l, nil
+}
+
+// verify checks that entry is the valid successor of the last entry of l.
+func (l *PoolLog) verify(entry PoolLogEntry) error {
+	prev := ""
+	if len(l.entries) > 0 {
+		prev = l.entries[len(l.entries)-1].Hash
+	}
+	if entry.Seq != len(l.entries)+1 {
+		return fmt.Errorf("entry %d out of sequence", entry.Seq)
+	}
+	if entry.Prev != prev {
+		return fmt.Errorf("entry %d does not follow entry %d", entry.Seq, entry.Seq-1)
+	}
+	hash, err := entry.computeHash()
+	if err != nil {
+		return err
+	}
+	if hash != entry.Hash {
+		return fmt.Errorf("entry %d does not match its hash", entry.Seq)
+	}
+	switch entry.Action {
+	case PoolLogAdd, PoolLogRemove, PoolLogRun:
+	default:
+		return fmt.Errorf("entry %d has unknown action %q", entry.Seq, entry.Action)
+	}
+	return nil
+}
+
+// apply adds a verified entry to l.
+func (l *PoolLog) apply(entry PoolLogEntry) {
+	switch entry.Action {
+	case PoolLogAdd:
+		l.pool[entry.SHA256] = entry.Subject
+	case PoolLogRemove:
+		delete(l.pool, entry.SHA256)
+	}
+	l.entries = append(l.entries, entry)
+}
+
+// Entries returns the entries of the log, oldest first.
+func (l *PoolLog) Entries() []PoolLogEntry {
+	return slices.Clone(l.entries)
+}
+
+// Len returns the number of certificates in the pool at the last recorded run.
+func (l *PoolLog) Len() int {
+	return len(l.pool)
+}
+
+// Record appends the changes of the pool since the last recorded run to the
+// log: a PoolLogRemove entry for each certificate no longer in certs, a
+// PoolLogAdd entry for each new one, both ordered by fingerprint, and a
+// PoolLogRun entry. Duplicate certificates count once. It returns the
+// appended entries.
+func (l *PoolLog) Record(certs []*x509.Certificate, at time.Time) ([]PoolLogEntry, error) {
+	pool := make(map[string]string, len(certs))
+	for _, cert := range certs {
+		digest := sha256.Sum256(cert.Raw)
+		pool[hex.EncodeToString(digest[:])] = cert.Subject.String()
+	}
+	at = at.UTC()
+
+	var appended []PoolLogEntry
+	add := func(entry PoolLogEntry) error {
+		entry.Seq = len(l.entries) + len(appended) + 1
+		entry.Time = at
+		if len(appended) > 0 {
+			entry.Prev = appended[len(appended)-1].Hash
+		} else if len(l.entries) > 0 {
+			entry.Prev = l.entries[len(l.entries)-1].Hash
+		}
+		hash, err := entry.computeHash()
+		if err != nil {
+			return err
+		}
+		entry.Hash = hash
+		appended = append(appended, entry)
+		return nil
+	}
+	for _, fingerprint := range slices.Sorted(maps.Keys(l.pool)) {
+		if _, ok := pool[fingerprint]; !ok {
+			if err := add(PoolLogEntry{Action: PoolLogRemove, SHA256: fingerprint, Subject: l.pool[fingerprint]}); err != nil {
+				return nil, err
+			}
+		}
+	}
+	fingerprints := slices.Sorted(maps.Keys(pool))
+	for _, fingerprint := range fingerprints {
+		if _, ok := l.pool[fingerprint]; !ok {
+			if err := add(PoolLogEntry{Action: PoolLogAdd, SHA256: fingerprint, Subject: pool[fingerprint]}); err != nil {
+				return nil, err
+			}
+		}
+	}
+	digest := sha256.Sum256([]byte(strings.Join(fingerprints, "\n")))
+	if err := add(PoolLogEntry{Action: PoolLogRun, SHA256: hex.EncodeToString(digest[:]), Count: len(pool)}); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	for _, entry := range appended {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(append(data, '\n'))
+	}
+	if dir := filepath.Dir(l.path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to write pool log: %w", err)
+		}
+	}
+	file, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, DefaultPublishFileMode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to write pool log: %w", err)
+	}
+	_, err = file.Write(buf.Bytes())
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to write pool log: %w", err)
+	}
+	for _, entry := range appended {
+		l.apply(entry)
+	}
+	return appended, nil
+}
+
+// History returns what the log records about the certificate with the given
+// hex SHA-256 fingerprint, which may contain colons and is not case
+// sensitive. A certificate that was never in the pool has no periods.
+func (l *PoolLog) History(fingerprint string) TrustHistory {
+	fingerprint = strings.ToLower(strings.ReplaceAll(fingerprint, ":", ""))
+	history := TrustHistory{SHA256: fingerprint, Periods: []TrustPeriod{}}
+	trusted := false
+	for _, entry := range l.entries {
+		switch {
+		case entry.Action == PoolLogAdd && entry.SHA256 == fingerprint:
+			trusted = true
+			history.Subject = entry.Subject
+			history.Periods = append(history.Periods, TrustPeriod{Added: entry.Time})
+		case entry.Action == PoolLogRemove && entry.SHA256 == fingerprint:
+			trusted = false
+			removed := entry.Time
+			history.Periods[len(history.Periods)-1].Removed = &removed
+		case entry.Action == PoolLogRun && trusted:
+			last := entry.Time
+			history.LastTrusted = &last
+		}
+	}
+	if len(history.Periods) > 0 {
+		first := history.Periods[0].Added
+		history.FirstTrusted = &first
+	}
+	history.Trusted = trusted
+	return history
+}
+
+// appendPoolLog records the pool of ctx (see PoolCertificates) in the pool
+// log of opts, if any.
+func appendPoolLog(pl *Pipeline, ctx *Context, opts SelectOptions) error {
+	if opts.PoolLog == "" {
+		return nil
+	}
+	if pl != nil && pl.ReadOnly {
+		pl.Logger.Info("Read-only mode: not appending to pool log",
+			logging.F("would_write", opts.PoolLog))
+		return nil
+	}
+	poolLog, err := OpenPoolLog(opts.PoolLog)
+	if err != nil {
+		return err
+	}
+	entries, err := poolLog.Record(PoolCertificates(ctx), ctx.Now())
+	if err != nil {
+		return err
+	}
+	if pl != nil && pl.Logger != nil {
+		added, removed := 0, 0
+		for _, entry := range entries {
+			switch entry.Action {
+			case PoolLogAdd:
+				added++
+			case PoolLogRemove:
+				removed++
+				pl.Logger.Info("Certificate removed from pool",
+					logging.F("subject", entry.Subject),
+					logging.F("sha256", entry.SHA256))
+			}
+		}
+		pl.Logger.Info("Recorded pool changes",
+			logging.F("log", opts.PoolLog),
+			logging.F("added", added),
+			logging.F("removed", removed),
+			logging.F("entries", len(poolLog.entries)))
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package pipeline
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirosfoundation/g119612/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func fingerprint(cert *x509.Certificate) string {
+	digest := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(digest[:])
+}
+
+func TestPoolLog(t *testing.T) {
+	a, _ := monitorTestCert(t, "CA A", nil, nil)
+	b, _ := monitorTestCert(t, "CA B", nil, nil)
+	c, _ := monitorTestCert(t, "CA C", nil, nil)
+	path := filepath.Join(t.TempDir(), "logs", "pool.log")
+	t1 := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	t2, t3, t4 := t1.Add(time.Hour), t1.Add(2*time.Hour), t1.Add(3*time.Hour)
+
+	log, err := OpenPoolLog(path)
+	require.NoError(t, err)
+	assert.Empty(t, log.Entries())
+
+	entries, err := log.Record([]*x509.Certificate{a, b, a}, t1)
+	require.NoError(t, err)
+	require.Len(t, entries, 3, "two additions and the run")
+	assert.Equal(t, PoolLogRun, entries[2].Action)
+	assert.Equal(t, 2, entries[2].Count)
+	assert.Empty(t, entries[0].Prev)
+	assert.Equal(t, entries[0].Hash, entries[1].Prev)
+
+	// Nothing changed, only the run is recorded
+	entries, err = log.Record([]*x509.Certificate{b, a}, t2)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	_, err = log.Record([]*x509.Certificate{b, c}, t3)
+	require.NoError(t, err)
+	_, err = log.Record([]*x509.Certificate{a, b, c}, t4)
+	require.NoError(t, err)
+
+	// The history survives reopening the log
+	log, err = OpenPoolLog(path)
+	require.NoError(t, err)
+	assert.Len(t, log.Entries(), 9)
+	assert.Equal(t, 3, log.Len())
+
+	history := log.History(strings.ToUpper(fingerprint(a)))
+	assert.True(t, history.Trusted)
+	assert.Equal(t, "CN=CA A", history.Subject)
+	assert.Equal(t, t1, *history.FirstTrusted)
+	assert.Equal(t, t4, *history.LastTrusted)
+	require.Len(t, history.Periods, 2)
+	assert.Equal(t, t3, *history.Periods[0].Removed)
+	assert.Nil(t, history.Periods[1].Removed)
+
+	history = log.History(fingerprint(c))
+	assert.Equal(t, t3, *history.FirstTrusted)
+
+	unknown, _ := monitorTestCert(t, "Unknown", nil, nil)
+	history = log.History(fingerprint(unknown))
+	assert.False(t, history.Trusted)
+	assert.Nil(t, history.FirstTrusted)
+	assert.Empty(t, history.Periods)
+}
+
+func TestPoolLog_Tampering(t *testing.T) {
+	a, _ := monitorTestCert(t, "CA A", nil, nil)
+	b, _ := monitorTestCert(t, "CA B", nil, nil)
+	path := filepath.Join(t.TempDir(), "pool.log")
+	log, err := OpenPoolLog(path)
+	require.NoError(t, err)
+	_, err = log.Record([]*x509.Certificate{a, b}, time.Now())
+	require.NoError(t, err)
+	_, err = log.Record([]*x509.Certificate{a}, time.Now())
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines := strings.SplitAfter(strings.TrimSuffix(string(data), "\n"), "\n")
+	require.Len(t, lines, 5)
+
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"changed subject", strings.Replace(string(data), "CN=CA B", "CN=CA X", 1)},
+		{"removed entry", strings.Join(append(lines[:3:3], lines[4:]...), "")},
+		{"truncated chain start", strings.Join(lines[1:], "")},
+		{"not JSON", string(data) + "garbage\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.NoError(t, os.WriteFile(path, []byte(tt.data), 0644))
+			_, err := OpenPoolLog(path)
+			assert.ErrorIs(t, err, ErrPoolLogCorrupt)
+		})
+	}
+}
+
+func TestSelectCertPool_PoolLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pool.log")
+	_, _, cert, err := GenerateTestCertBase64()
+	require.NoError(t, err)
+
+	pl := &Pipeline{Logger: logging.SilentLogger()}
+	ctx := NewContext()
+	ctx.AddTSL(generateTSL("Logged Service", "http://uri.etsi.org/TrstSvc/Svctype/CA/QC", []string{base64.StdEncoding.EncodeToString(cert.Raw)}))
+
+	pl.ReadOnly = true
+	_, err = SelectCertPool(pl, ctx, "pool-log:"+path)
+	require.NoError(t, err)
+	assert.NoFileExists(t, path, "read-only mode does not write the log")
+
+	pl.ReadOnly = false
+	for i := 0; i < 2; i++ {
+		_, err = SelectCertPool(pl, ctx, "pool-log:"+path)
+		require.NoError(t, err)
+	}
+	_, err = SelectCertPool(pl, ctx, "pool-log:"+path, "min-certs:5")
+	assert.ErrorIs(t, err, ErrPoolSize)
+
+	log, err := OpenPoolLog(path)
+	require.NoError(t, err)
+	assert.Len(t, log.Entries(), 3, "one addition and two runs")
+	history := log.History(fingerprint(cert))
+	assert.True(t, history.Trusted)
+	assert.NotNil(t, history.LastTrusted)
+
+	_, err = SelectCertPool(pl, ctx, "pool-log:../pool.log")
+	assert.Error(t, err)
+}
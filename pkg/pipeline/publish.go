@@ -1,20 +1,19 @@
 package pipeline
 
 import (
-	"encoding/xml"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/sirosfoundation/g119612/pkg/etsi119612"
-	"github.com/sirosfoundation/g119612/pkg/dsig"
 	"github.com/sirosfoundation/g119612/pkg/logging"
 )
 
 // processTreeForPublishing processes a TSL tree for publishing,
 // maintaining the tree structure in the file system
-func processTreeForPublishing(pl *Pipeline, ctx *Context, tree *TSLTree, baseDir string, treeIndex int, subdirFormat string, signer dsig.XMLSigner) error {
+func processTreeForPublishing(pl *Pipeline, ctx *Context, tree *TSLTree, baseDir string, treeIndex int, subdirFormat string, opts *publishOptions) error {
+	opts = opts.orDefault()
 	if tree == nil || tree.Root == nil {
 		return nil
 	}
@@ -44,58 +43,74 @@ func processTreeForPublishing(pl *Pipeline, ctx *Context, tree *TSLTree, baseDir
 		logging.F("directory", treeDir),
 		logging.F("territory", rootTSL.StatusList.TslSchemeInformation.TslSchemeTerritory),
 		logging.F("format", subdirFormat))
-	if err := os.MkdirAll(treeDir, 0755); err != nil {
+	if err := os.MkdirAll(treeDir, opts.dirMode); err != nil {
 		return fmt.Errorf("failed to create tree directory %s: %w", treeDir, err)
 	}
 
 	// Process the tree recursively
-	return processNodeForPublishing(pl, ctx, tree.Root, treeDir, 0, signer)
+	return processNodeForPublishing(pl, ctx, tree.Root, treeDir, 0, opts)
 }
 
-// publishTSLToFile writes a TSL to a file, optionally signing it
-func publishTSLToFile(pl *Pipeline, tsl *etsi119612.TSL, filePath string, signer dsig.XMLSigner) error {
+// publishTSLToFile writes a TSL to a file, optionally signing it. A TSL
+// exceeding the split limits is written as parts, see splitTSL. Errors are
+// returned as a PublishError identifying the TSL.
+func publishTSLToFile(pl *Pipeline, tsl *etsi119612.TSL, filePath string, opts *publishOptions) error {
+	opts = opts.orDefault()
 	if tsl == nil {
 		return fmt.Errorf("cannot publish nil TSL")
 	}
-
-	// Create XML representation with root element
-	type TrustStatusListWrapper struct {
-		XMLName xml.Name                       `xml:"TrustServiceStatusList"`
-		List    etsi119612.TrustStatusListType `xml:",innerxml"`
+	split, err := publishSplitTSL(pl, tsl, filePath, opts)
+	if err == nil && !split {
+		err = publishTSLFile(pl, tsl, filePath, opts)
 	}
-	wrapper := TrustStatusListWrapper{List: tsl.StatusList}
-	xmlData, err := xml.MarshalIndent(wrapper, "", "  ")
 	if err != nil {
-		return fmt.Errorf("failed to marshal TSL to XML: %w", err)
+		return NewTSLPublishError(filePath, tsl, err)
 	}
+	return nil
+}
 
-	// Add XML header
-	xmlData = append([]byte(xml.Header), xmlData...)
+// publishTSLFile writes a TSL to a file as it is, optionally signing it
+func publishTSLFile(pl *Pipeline, tsl *etsi119612.TSL, filePath string, opts *publishOptions) error {
+	signer := opts.signer
 
-	// Sign the XML if a signer is provided
+	// Serialize the TSL in the configured output format
+	unsigned, err := opts.serializeTSL(tsl, filePath)
+	if err != nil {
+		return err
+	}
+	defer unsigned.close()
+
+	// Sign the XML if a signer is provided; in stream mode the signature is
+	// computed while the file is written
+	write := unsigned.writeTo
 	if signer != nil {
-		xmlData, err = signer.Sign(xmlData)
+		write, err = opts.signedContent(signer, unsigned)
 		if err != nil {
-			return fmt.Errorf("failed to sign XML: %w", err)
+			return fmt.Errorf("failed to sign TSL: %w", err)
 		}
 	}
 
 	// Write to file
-	if err := os.WriteFile(filePath, xmlData, 0644); err != nil {
+	if err := writePublishedTSL(tsl, filePath, unsigned, write, opts); err != nil {
 		return fmt.Errorf("failed to write TSL to file %s: %w", filePath, err)
 	}
 
 	// Log success
-	pl.Logger.Info("Published TSL",
+	var size int64
+	if info, err := os.Stat(filePath); err == nil {
+		size = info.Size()
+	}
+	pl.Logger.Info("Published TSL", tslFields(tsl,
 		logging.F("file", filePath),
 		logging.F("signed", signer != nil),
-		logging.F("size", len(xmlData)))
+		logging.F("size", size))...)
 
 	return nil
 }
 
 // processNodeForPublishing recursively processes a TSL node for publishing
-func processNodeForPublishing(pl *Pipeline, ctx *Context, node *TSLNode, dirPath string, depth int, signer dsig.XMLSigner) error {
+func processNodeForPublishing(pl *Pipeline, ctx *Context, node *TSLNode, dirPath string, depth int, opts *publishOptions) error {
+	opts = opts.orDefault()
 	if node == nil || node.TSL == nil {
 		return nil
 	}
@@ -129,7 +144,7 @@ func processNodeForPublishing(pl *Pipeline, ctx *Context, node *TSLNode, dirPath
 	if depth > 0 {
 		// Create a depth-based subdirectory
 		nodePath = filepath.Join(dirPath, fmt.Sprintf("refs-%d", depth))
-		if err := os.MkdirAll(nodePath, 0755); err != nil {
+		if err := os.MkdirAll(nodePath, opts.dirMode); err != nil {
 			return fmt.Errorf("failed to create depth directory %s: %w", nodePath, err)
 		}
 
@@ -141,8 +156,8 @@ func processNodeForPublishing(pl *Pipeline, ctx *Context, node *TSLNode, dirPath
 
 	// Publish the TSL
 	filePath := filepath.Join(nodePath, filename)
-	if err := publishTSLToFile(pl, tsl, filePath, signer); err != nil {
-		return fmt.Errorf("failed to publish TSL to %s: %w", filePath, err)
+	if err := publishTSLToFile(pl, tsl, filePath, opts); err != nil {
+		return err
 	}
 
 	// Create an index file that shows the tree structure
@@ -151,14 +166,14 @@ func processNodeForPublishing(pl *Pipeline, ctx *Context, node *TSLNode, dirPath
 		nodeTree := &TSLTree{Root: node}
 		indexContent := generateTreeIndex(nodeTree)
 		indexPath := filepath.Join(dirPath, "index.txt")
-		if err := os.WriteFile(indexPath, []byte(indexContent), 0644); err != nil {
+		if err := opts.writeFile(indexPath, []byte(indexContent)); err != nil {
 			pl.Logger.Warn("Failed to write tree index", logging.F("path", indexPath), logging.F("error", err))
 		}
 	}
 
 	// Process all child nodes
 	for i, child := range node.Children {
-		if err := processNodeForPublishing(pl, ctx, child, dirPath, depth+1, signer); err != nil {
+		if err := processNodeForPublishing(pl, ctx, child, dirPath, depth+1, opts); err != nil {
 			return fmt.Errorf("failed to process child %d: %w", i, err)
 		}
 	}
@@ -50,6 +50,8 @@ func TestPublishStep_SigningError(t *testing.T) {
 	defer os.RemoveAll(testDir)
 
 	tsl := generateTSL("Test Service", "http://uri.etsi.org/TrstSvc/Svctype/CA/QC", []string{TestCertBase64})
+	tsl.StatusList.TslSchemeInformation.TslSchemeTerritory = "SE"
+	tsl.StatusList.TslSchemeInformation.TSLSequenceNumber = 42
 	ctx := &Context{}
 	ctx.EnsureTSLStack().TSLs.Push(tsl)
 
@@ -61,6 +63,14 @@ func TestPublishStep_SigningError(t *testing.T) {
 	_, err = PublishTSL(pl, ctx, testDir, "/nonexistent/cert.pem", "/nonexistent/key.pem")
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "failed to sign TSL")
+
+	// The error names the TSL that failed
+	var publishErr *PublishError
+	require.ErrorAs(t, err, &publishErr)
+	assert.Equal(t, "SE", publishErr.TSL.Territory)
+	assert.Equal(t, 42, publishErr.TSL.Sequence)
+	assert.Equal(t, filepath.Join(testDir, "tsl-0.xml"), publishErr.OutputPath)
+	assert.Contains(t, err.Error(), `TSL SE "Test Operator" sequence 42`)
 }
 
 // TestPublishStep_DirectoryCreation tests automatic directory creation
@@ -0,0 +1,108 @@
+package pipeline
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PublishedHandler returns an http.Handler serving the files in a publish
+// directory with strong ETags. The ETag of a file is ContentETag of its content,
+// the same value the publish step writes to manifest.json and the .etag
+// sidecars, so mirrors can use conditional requests (If-None-Match) and receive
+// 304 Not Modified while a list is unchanged. ETags are cached per file and
+// recomputed when its size or modification time changes.
+//
+// Only regular files are served; directory listings, dot files (including the
+// staging files of atomic writes) and requests other than GET and HEAD are refused.
+//
+// Parameters:
+//   - dir: The publish directory to serve
+//
+// Returns:
+//   - http.Handler: A handler serving the directory
+func PublishedHandler(dir string) http.Handler {
+	return &publishedHandler{root: http.Dir(dir), etags: make(map[string]cachedETag)}
+}
+
+// cachedETag is the ETag of a file together with the state it was computed for.
+type cachedETag struct {
+	size    int64
+	modTime time.Time
+	etag    string
+}
+
+type publishedHandler struct {
+	root  http.FileSystem
+	mu    sync.Mutex
+	etags map[string]cachedETag
+}
+
+// ServeHTTP implements http.Handler.
+func (h *publishedHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	name := path.Clean("/" + r.URL.Path)
+	for _, part := range strings.Split(name, "/") {
+		if strings.HasPrefix(part, ".") {
+			http.NotFound(w, r)
+			return
+		}
+	}
+
+	file, err := h.root.Open(name)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		http.NotFound(w, r)
+		return
+	}
+
+	etag, err := h.etag(name, file, info)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("ETag", etag)
+	if strings.HasSuffix(name, ".xml") {
+		w.Header().Set("Content-Type", "application/xml")
+	}
+	// ServeContent answers If-None-Match and If-Match using the ETag header
+	http.ServeContent(w, r, info.Name(), info.ModTime(), file)
+}
+
+// etag returns the ETag of an open file, from the cache if the file is unchanged.
+func (h *publishedHandler) etag(name string, file http.File, info os.FileInfo) (string, error) {
+	h.mu.Lock()
+	cached, ok := h.etags[name]
+	h.mu.Unlock()
+	if ok && cached.size == info.Size() && cached.modTime.Equal(info.ModTime()) {
+		return cached.etag, nil
+	}
+
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, file); err != nil {
+		return "", err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	etag := ContentETag(buf.Bytes())
+
+	h.mu.Lock()
+	h.etags[name] = cachedETag{size: info.Size(), modTime: info.ModTime(), etag: etag}
+	h.mu.Unlock()
+	return etag, nil
+}
@@ -0,0 +1,118 @@
+package pipeline
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/sirosfoundation/g119612/pkg/etsi119612"
+	"github.com/sirosfoundation/g119612/pkg/etsi119612/jsonmodel"
+)
+
+// ManifestFileName is the name of the manifest the publish step writes with manifest:true.
+const ManifestFileName = "manifest.json"
+
+// ETagSidecarSuffix is appended to the name of a published file for its ETag sidecar.
+const ETagSidecarSuffix = ".etag"
+
+// PublishedFile describes one file written by the publish step, with the
+// summary of the TSL it holds, nil if it holds none. The source of the TSL,
+// the location it was loaded from before publishing, is left out.
+type PublishedFile struct {
+	Path   string `json:"path"`   // Slash separated path relative to the manifest
+	SHA256 string `json:"sha256"` // Hex SHA-256 of the file content
+	Size   int    `json:"size"`
+	ETag   string `json:"etag"` // Strong HTTP entity tag derived from SHA256
+	Signed bool   `json:"signed"`
+	*jsonmodel.Summary
+}
+
+// PublishManifest is the content of the manifest.json written by the publish step.
+// It lists the digest of every published file so mirrors can detect changes
+// without downloading the lists.
+type PublishManifest struct {
+	GeneratedAt string          `json:"generatedAt"`
+	Files       []PublishedFile `json:"files"`
+}
+
+// ContentETag returns the strong HTTP entity tag for content: the quoted hex
+// SHA-256 of the bytes. The same content always yields the same ETag.
+func ContentETag(data []byte) string {
+	digest := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(digest[:]) + `"`
+}
+
+// recordPublished notes a file written below root for the manifest and writes
+// its ETag sidecar if requested, reading the file back for its digest. It does
+// nothing unless a manifest or sidecars are enabled.
+func (o *publishOptions) recordPublished(root, path string, tsl *etsi119612.TSL, signed bool) error {
+	if !o.manifest && !o.etagSidecar {
+		return nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+	digest := hex.EncodeToString(h.Sum(nil))
+	file := PublishedFile{
+		SHA256: digest,
+		Size:   int(size),
+		ETag:   `"` + digest + `"`,
+		Signed: signed,
+	}
+	if tsl != nil && tsl.StatusList.TslSchemeInformation != nil {
+		summary := tsl.JSONSummary()
+		summary.Source = ""
+		file.Summary = &summary
+	}
+
+	if o.etagSidecar {
+		if err := o.writeFile(path+ETagSidecarSuffix, []byte(file.ETag+"\n")); err != nil {
+			return fmt.Errorf("failed to write ETag sidecar: %w", err)
+		}
+	}
+	if o.manifest {
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		file.Path = filepath.ToSlash(rel)
+		if o.published == nil {
+			o.published = make(map[string][]PublishedFile)
+		}
+		o.published[root] = append(o.published[root], file)
+	}
+	return nil
+}
+
+// writeManifests writes a manifest into every directory files were published to.
+func (o *publishOptions) writeManifests() error {
+	if !o.manifest {
+		return nil
+	}
+	generatedAt := o.timestamp().UTC().Format(time.RFC3339)
+	for _, root := range slices.Sorted(maps.Keys(o.published)) {
+		files := o.published[root]
+		data, err := json.MarshalIndent(PublishManifest{GeneratedAt: generatedAt, Files: files}, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := o.writeFile(filepath.Join(root, ManifestFileName), append(data, '\n')); err != nil {
+			return fmt.Errorf("failed to write publish manifest: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package pipeline
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sirosfoundation/g119612/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublishTSL_ManifestAndETags(t *testing.T) {
+	pl := &Pipeline{Logger: logging.SilentLogger()}
+	ctx := NewContext()
+	tsl := generateTSL("Test Service", "http://uri.etsi.org/TrstSvc/Svctype/CA/QC", []string{TestCertBase64})
+	tsl.StatusList.TslSchemeInformation.TSLSequenceNumber = 7
+	tsl.StatusList.TslSchemeInformation.TslSchemeTerritory = "SE"
+	ctx.AddTSL(tsl)
+
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	require.NoError(t, generateTestCertAndKey(certFile, keyFile))
+	require.NoError(t, os.Chmod(keyFile, 0600))
+
+	outDir := filepath.Join(dir, "out")
+	_, err := PublishTSL(pl, ctx, outDir, certFile, keyFile, "unsigned-copy:true", "manifest:true", "etag:true")
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(filepath.Join(outDir, ManifestFileName))
+	require.NoError(t, err)
+	var manifest PublishManifest
+	require.NoError(t, json.Unmarshal(data, &manifest))
+	assert.NotEmpty(t, manifest.GeneratedAt)
+
+	byPath := make(map[string]PublishedFile)
+	for _, file := range manifest.Files {
+		byPath[file.Path] = file
+	}
+	require.Contains(t, byPath, "tsl-0.xml")
+	require.Contains(t, byPath, "tsl-0-unsigned.xml")
+	assert.True(t, byPath["tsl-0.xml"].Signed)
+	assert.False(t, byPath["tsl-0-unsigned.xml"].Signed)
+
+	for name, file := range byPath {
+		content, err := os.ReadFile(filepath.Join(outDir, name))
+		require.NoError(t, err)
+		digest := sha256.Sum256(content)
+		assert.Equal(t, hex.EncodeToString(digest[:]), file.SHA256, name)
+		assert.Equal(t, len(content), file.Size, name)
+		assert.Equal(t, ContentETag(content), file.ETag, name)
+		require.NotNil(t, file.Summary, name)
+		assert.Equal(t, 7, file.SequenceNumber, name)
+		assert.Equal(t, "SE", file.Territory, name)
+		assert.Empty(t, file.Source, name)
+
+		sidecar, err := os.ReadFile(filepath.Join(outDir, name+ETagSidecarSuffix))
+		require.NoError(t, err)
+		assert.Equal(t, file.ETag, strings.TrimSpace(string(sidecar)), name)
+	}
+
+	// Neither is written by default
+	plainDir := filepath.Join(dir, "plain")
+	_, err = PublishTSL(pl, ctx, plainDir)
+	require.NoError(t, err)
+	_, err = os.Stat(filepath.Join(plainDir, ManifestFileName))
+	assert.True(t, os.IsNotExist(err))
+	sidecars, err := filepath.Glob(filepath.Join(plainDir, "*"+ETagSidecarSuffix))
+	require.NoError(t, err)
+	assert.Empty(t, sidecars)
+
+	_, _, err = parsePublishOptions([]string{"/out", "manifest:often"})
+	assert.Error(t, err)
+	_, _, err = parsePublishOptions([]string{"/out", "etag:often"})
+	assert.Error(t, err)
+}
+
+func TestContentETag(t *testing.T) {
+	assert.Equal(t, ContentETag([]byte("list")), ContentETag([]byte("list")))
+	assert.NotEqual(t, ContentETag([]byte("list")), ContentETag([]byte("list2")))
+	assert.True(t, strings.HasPrefix(ContentETag(nil), `"`))
+	assert.True(t, strings.HasSuffix(ContentETag(nil), `"`))
+}
+
+func TestPublishedHandler(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "SE.xml"), []byte("<list/>"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".SE.xml.tmp-1"), []byte("partial"), 0600))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "sub"), 0755))
+
+	server := httptest.NewServer(PublishedHandler(dir))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/SE.xml")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	etag := resp.Header.Get("ETag")
+	assert.Equal(t, ContentETag([]byte("<list/>")), etag)
+	assert.Equal(t, "application/xml", resp.Header.Get("Content-Type"))
+
+	request, err := http.NewRequest(http.MethodGet, server.URL+"/SE.xml", nil)
+	require.NoError(t, err)
+	request.Header.Set("If-None-Match", etag)
+	resp, err = http.DefaultClient.Do(request)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+
+	// A changed file gets a new ETag
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "SE.xml"), []byte("<list seq='2'/>"), 0644))
+	resp, err = http.DefaultClient.Do(request)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.NotEqual(t, etag, resp.Header.Get("ETag"))
+
+	for _, path := range []string{"/.SE.xml.tmp-1", "/sub", "/", "/missing.xml"} {
+		resp, err := http.Get(server.URL + path)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode, path)
+	}
+
+	resp, err = http.Post(server.URL+"/SE.xml", "application/xml", strings.NewReader("<list/>"))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
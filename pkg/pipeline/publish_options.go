@@ -0,0 +1,600 @@
+package pipeline
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirosfoundation/g119612/pkg/dsig"
+	"github.com/sirosfoundation/g119612/pkg/etsi119612"
+	"github.com/sirosfoundation/g119612/pkg/validation"
+)
+
+// Default file and directory modes used for published artifacts.
+const (
+	DefaultPublishFileMode os.FileMode = 0644
+	DefaultPublishDirMode  os.FileMode = 0755
+)
+
+// Signing modes accepted by the publish step's sign-mode option.
+const (
+	SignModeDOM    = "dom"
+	SignModeStream = "stream"
+)
+
+// Built-in signers accepted by the publish step's signer option, for dry runs
+// and tests without key files.
+const (
+	SignerNone   = "none"   // dsig.NopSigner: signature structure without a key
+	SignerMemory = "memory" // dsig.MemorySigner: ephemeral in-memory key
+)
+
+// Key permission policies accepted by the publish step's key-permissions option.
+const (
+	KeyPermissionsWarn   = "warn"
+	KeyPermissionsStrict = "strict"
+	KeyPermissionsIgnore = "ignore"
+)
+
+// Output formats accepted by the publish step's indent, encoding and newline options.
+const (
+	IndentPretty  = "pretty"
+	IndentCompact = "compact"
+	EncodingUTF8  = "UTF-8"
+	EncodingNone  = "none"
+	NewlineLF     = "lf"
+	NewlineCRLF   = "crlf"
+)
+
+// xmlDeclarationNoEncoding is the XML declaration written with encoding:none.
+const xmlDeclarationNoEncoding = `<?xml version="1.0"?>` + "\n"
+
+// publishOptions holds the settings controlling how PublishTSL writes its output.
+// A nil *publishOptions is valid and behaves like defaultPublishOptions().
+type publishOptions struct {
+	signer         dsig.XMLSigner // Signer used for XML-DSIG, nil for unsigned output
+	fileMode       os.FileMode    // Mode for published files
+	dirMode        os.FileMode    // Mode for created directories
+	keyPermissions string         // Policy for private key file permissions
+	unsignedCopy   bool           // Also write an unsigned variant next to each signed TSL
+	treeFormat     string         // Tree layout ("territory" or "index"), empty for flat output
+	streamSign     bool           // Sign with dsig.StreamSigner instead of building a DOM
+	rolloverSigner dsig.XMLSigner // Signer with the next key during a key rollover, nil if none
+	rolloverDir    string         // Directory for the copies signed by rolloverSigner
+	baseDir        string         // Output directory of the publish step, set when publishing
+	compact        bool           // Write the XML without indentation
+	omitDecl       bool           // Leave out the XML declaration
+	omitEncoding   bool           // Leave out the encoding attribute of the XML declaration
+	crlf           bool           // Use CRLF line endings instead of LF
+	manifest       bool           // Write manifest.json listing the digests of published files
+	etagSidecar    bool           // Write a name.xml.etag sidecar next to each published file
+	onFailure      string         // Failure policy: OnFailureKeep, OnFailureRollback or OnFailurePartial
+	retries        int            // Number of retries of a failed file write
+	now            time.Time      // Time of the run for manifests, failure markers and XAdES signing times, the current time if zero
+
+	splitMaxBytes     int    // Size above which a TSL is split into parts, 0 for no limit
+	splitMaxProviders int    // Provider count above which a TSL is split into parts, 0 for no limit
+	splitBaseURL      string // Location of the parts, empty for the distribution point directory
+
+	xades *dsig.XAdESOptions // XAdES properties of the signatures, nil for plain XML-DSIG
+
+	published map[string][]PublishedFile // Files published so far, by manifest directory
+	written   []writtenFile              // Files written so far, for failure handling
+}
+
+// timestamp returns the time of the publish run.
+func (o *publishOptions) timestamp() time.Time {
+	if o.now.IsZero() {
+		return time.Now()
+	}
+	return o.now
+}
+
+// defaultPublishOptions returns the publish options used when none are given.
+func defaultPublishOptions() *publishOptions {
+	return &publishOptions{
+		fileMode:       DefaultPublishFileMode,
+		dirMode:        DefaultPublishDirMode,
+		keyPermissions: KeyPermissionsWarn,
+		onFailure:      OnFailureKeep,
+	}
+}
+
+// orDefault returns the receiver, or the default options if the receiver is nil.
+func (o *publishOptions) orDefault() *publishOptions {
+	if o == nil {
+		return defaultPublishOptions()
+	}
+	return o
+}
+
+// tslDocument is a serialized TSL being published. It is held in memory,
+// or in a temporary file when signing in stream mode, so that a large TSL is
+// not held in memory while it is signed and written.
+type tslDocument struct {
+	data []byte   // The document, if held in memory
+	file *os.File // The temporary file holding the document otherwise
+}
+
+// writeTo writes the document to w.
+func (d tslDocument) writeTo(w io.Writer) error {
+	if d.file == nil {
+		_, err := w.Write(d.data)
+		return err
+	}
+	if _, err := d.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	_, err := io.Copy(w, d.file)
+	return err
+}
+
+// writeCanonical writes the canonical form of the document to w, which is
+// the content the signature of the signed document digests.
+func (d tslDocument) writeCanonical(w io.Writer) error {
+	if d.file == nil {
+		return dsig.CanonicalizeXMLStream(w, bytes.NewReader(d.data))
+	}
+	if _, err := d.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return dsig.CanonicalizeXMLStream(w, d.file)
+}
+
+// bytes returns the document, reading it from its file if needed.
+func (d tslDocument) bytes() ([]byte, error) {
+	if d.file == nil {
+		return d.data, nil
+	}
+	var buf bytes.Buffer
+	if err := d.writeTo(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// close removes the temporary file of the document, if any.
+func (d tslDocument) close() {
+	if d.file != nil {
+		d.file.Close()
+		os.Remove(d.file.Name())
+	}
+}
+
+// serializeTSL serializes a TSL to be published at path in the configured
+// output format. In stream mode with a signer implementing dsig.StreamSigner
+// the document is written to a temporary file in the directory of path
+// rather than held in memory; the caller must close the returned document.
+func (o *publishOptions) serializeTSL(tsl *etsi119612.TSL, path string) (tslDocument, error) {
+	if _, ok := o.signer.(dsig.StreamSigner); !ok || !o.streamSign {
+		data, err := o.marshalTSL(tsl)
+		return tslDocument{data: data}, err
+	}
+	file, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".unsigned-*")
+	if err != nil {
+		return tslDocument{}, err
+	}
+	doc := tslDocument{file: file}
+	w := bufio.NewWriter(file)
+	if err := o.writeTSL(w, tsl); err != nil {
+		doc.close()
+		return tslDocument{}, err
+	}
+	if err := w.Flush(); err != nil {
+		doc.close()
+		return tslDocument{}, err
+	}
+	return doc, nil
+}
+
+// signedContent returns a function writing doc signed with signer. In stream
+// mode, signers implementing dsig.StreamSigner sign a document held in a file
+// while the result is written, without building a DOM of the document or
+// holding it in memory; otherwise the document is signed at once with
+// XMLSigner.Sign. The function may be called again when a write is retried.
+func (o *publishOptions) signedContent(signer dsig.XMLSigner, doc tslDocument) (func(io.Writer) error, error) {
+	if streamSigner, ok := signer.(dsig.StreamSigner); ok && o.streamSign && doc.file != nil {
+		return func(w io.Writer) error {
+			if _, err := doc.file.Seek(0, io.SeekStart); err != nil {
+				return err
+			}
+			return streamSigner.SignStream(w, doc.file)
+		}, nil
+	}
+	data, err := doc.bytes()
+	if err != nil {
+		return nil, err
+	}
+	signed, err := signer.Sign(data)
+	if err != nil {
+		return nil, err
+	}
+	return tslDocument{data: signed}.writeTo, nil
+}
+
+// marshalTSL serializes a TSL to an XML document in the configured output
+// format. It is applied before signing, so signed output is exactly what is
+// written. The default is indented XML with a UTF-8 declaration and LF line
+// endings.
+func (o *publishOptions) marshalTSL(tsl *etsi119612.TSL) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := o.writeTSL(&buf, tsl); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeTSL writes a TSL to w as marshalTSL serializes it.
+func (o *publishOptions) writeTSL(w io.Writer, tsl *etsi119612.TSL) error {
+	o = o.orDefault()
+	type TrustStatusListWrapper struct {
+		XMLName xml.Name                       `xml:"TrustServiceStatusList"`
+		List    etsi119612.TrustStatusListType `xml:",innerxml"`
+	}
+	wrapper := TrustStatusListWrapper{List: tsl.StatusList}
+
+	if o.crlf {
+		// The encoder escapes carriage returns, so every line feed written
+		// ends a line
+		w = crlfWriter{w}
+	}
+	switch {
+	case o.omitDecl:
+	case o.omitEncoding:
+		if _, err := io.WriteString(w, xmlDeclarationNoEncoding); err != nil {
+			return err
+		}
+	default:
+		if _, err := io.WriteString(w, xml.Header); err != nil {
+			return err
+		}
+	}
+	enc := xml.NewEncoder(w)
+	if !o.compact {
+		enc.Indent("", "  ")
+	}
+	if err := enc.Encode(wrapper); err != nil {
+		return fmt.Errorf("failed to marshal TSL to XML: %w", err)
+	}
+	return enc.Close()
+}
+
+// crlfWriter writes to w with LF line endings turned into CRLF.
+type crlfWriter struct {
+	w io.Writer
+}
+
+func (c crlfWriter) Write(p []byte) (int, error) {
+	if _, err := c.w.Write(bytes.ReplaceAll(p, []byte("\n"), []byte("\r\n"))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// parseFileMode parses an octal file mode such as "0640" or "640".
+func parseFileMode(value string) (os.FileMode, error) {
+	mode, err := strconv.ParseUint(strings.TrimSpace(value), 8, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid file mode %q: %w", value, err)
+	}
+	if mode > 0777 {
+		return 0, fmt.Errorf("invalid file mode %q: only permission bits are allowed", value)
+	}
+	return os.FileMode(mode), nil
+}
+
+// parsePublishOptions separates "key:value" publish options from the positional
+// arguments of the publish step.
+//
+// Recognized options:
+//   - file-mode:0640        Octal mode for published files (default 0644)
+//   - dir-mode:0750         Octal mode for created directories (default 0755)
+//   - key-permissions:warn  Private key permission policy: warn, strict or ignore
+//   - unsigned-copy:true    Also write the canonical unsigned name-unsigned.xml next to each signed name.xml
+//   - sign-mode:stream      Sign without building a DOM (dom or stream, default dom)
+//   - signer:memory         Sign without key files: none (empty signatures) or memory (ephemeral key)
+//   - rollover-cert:/path   Certificate of the next signing key during a key rollover
+//   - rollover-key:/path    Private key of the next signing key during a key rollover
+//   - rollover-dir:/path    Directory for the copies signed with the next key
+//   - indent:compact        Output layout: pretty (indented, default) or compact
+//   - xml-declaration:false Leave out the <?xml ...?> declaration (default true)
+//   - encoding:none         Declaration encoding: UTF-8 (default) or none to omit the attribute
+//   - newline:crlf          Line endings: lf (default) or crlf
+//   - manifest:true         Write manifest.json with the SHA-256 digest and ETag of every published file
+//   - etag:true             Write name.xml.etag with the ETag next to every published file
+//   - on-failure:rollback   Handling of files written before a failure: keep (default), rollback
+//     (restore replaced files, delete new ones) or partial (write .partial and publish-failure.json)
+//   - retries:3             Retry a failed file write up to this many times (default 0)
+//   - split-max-bytes:N     Split TSLs larger than N bytes into parts (see splitTSL)
+//   - split-max-providers:N Split TSLs with more than N providers into parts
+//   - split-base-url:URL    Location of the parts referenced by the split TSL
+//   - xades:true            Sign with XAdES-BES signatures (SigningTime, SigningCertificateV2)
+//   - xades-policy:ID       Sign with XAdES-EPES signatures declaring this signature policy
+//   - xades-policy-digest:D SHA-256 digest of the policy document, base64 or hex (required with xades-policy)
+//   - xades-policy-url:URL  Location of the policy document
+//   - xades-policy-description:TEXT Description of the policy
+//
+// Returns the remaining positional arguments in their original order and the parsed options.
+func parsePublishOptions(args []string) ([]string, *publishOptions, error) {
+	opts := defaultPublishOptions()
+	positional := make([]string, 0, len(args))
+	var rolloverCert, rolloverKey string
+	var xades bool
+	var policy dsig.XAdESOptions
+
+	for _, arg := range args {
+		switch {
+		case strings.HasPrefix(arg, "file-mode:"):
+			mode, err := parseFileMode(strings.TrimPrefix(arg, "file-mode:"))
+			if err != nil {
+				return nil, nil, err
+			}
+			opts.fileMode = mode
+		case strings.HasPrefix(arg, "dir-mode:"):
+			mode, err := parseFileMode(strings.TrimPrefix(arg, "dir-mode:"))
+			if err != nil {
+				return nil, nil, err
+			}
+			opts.dirMode = mode
+		case strings.HasPrefix(arg, "key-permissions:"):
+			policy := strings.TrimPrefix(arg, "key-permissions:")
+			switch policy {
+			case KeyPermissionsWarn, KeyPermissionsStrict, KeyPermissionsIgnore:
+				opts.keyPermissions = policy
+			default:
+				return nil, nil, fmt.Errorf("invalid key-permissions value %q (expected warn, strict or ignore)", policy)
+			}
+		case strings.HasPrefix(arg, "unsigned-copy:"):
+			value, err := strconv.ParseBool(strings.TrimPrefix(arg, "unsigned-copy:"))
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid unsigned-copy value %q: %w", arg, err)
+			}
+			opts.unsignedCopy = value
+		case strings.HasPrefix(arg, "sign-mode:"):
+			switch mode := strings.TrimPrefix(arg, "sign-mode:"); mode {
+			case SignModeDOM:
+				opts.streamSign = false
+			case SignModeStream:
+				opts.streamSign = true
+			default:
+				return nil, nil, fmt.Errorf("invalid sign-mode value %q (expected dom or stream)", mode)
+			}
+		case strings.HasPrefix(arg, "signer:"):
+			switch name := strings.TrimPrefix(arg, "signer:"); name {
+			case SignerNone:
+				opts.signer = dsig.NopSigner{}
+			case SignerMemory:
+				opts.signer = dsig.NewMemorySigner()
+			default:
+				return nil, nil, fmt.Errorf("invalid signer value %q (expected none or memory)", name)
+			}
+		case strings.HasPrefix(arg, "rollover-cert:"):
+			rolloverCert = strings.TrimPrefix(arg, "rollover-cert:")
+		case strings.HasPrefix(arg, "rollover-key:"):
+			rolloverKey = strings.TrimPrefix(arg, "rollover-key:")
+		case strings.HasPrefix(arg, "rollover-dir:"):
+			opts.rolloverDir = strings.TrimPrefix(arg, "rollover-dir:")
+		case strings.HasPrefix(arg, "indent:"):
+			if err := opts.setIndent(strings.TrimPrefix(arg, "indent:")); err != nil {
+				return nil, nil, err
+			}
+		case strings.HasPrefix(arg, "xml-declaration:"):
+			value, err := strconv.ParseBool(strings.TrimPrefix(arg, "xml-declaration:"))
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid xml-declaration value %q: %w", arg, err)
+			}
+			opts.omitDecl = !value
+		case strings.HasPrefix(arg, "encoding:"):
+			if err := opts.setEncoding(strings.TrimPrefix(arg, "encoding:")); err != nil {
+				return nil, nil, err
+			}
+		case strings.HasPrefix(arg, "newline:"):
+			if err := opts.setNewline(strings.TrimPrefix(arg, "newline:")); err != nil {
+				return nil, nil, err
+			}
+		case strings.HasPrefix(arg, "manifest:"):
+			value, err := strconv.ParseBool(strings.TrimPrefix(arg, "manifest:"))
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid manifest value %q: %w", arg, err)
+			}
+			opts.manifest = value
+		case strings.HasPrefix(arg, "etag:"):
+			value, err := strconv.ParseBool(strings.TrimPrefix(arg, "etag:"))
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid etag value %q: %w", arg, err)
+			}
+			opts.etagSidecar = value
+		case strings.HasPrefix(arg, "on-failure:"):
+			policy, err := parseOnFailure(strings.TrimPrefix(arg, "on-failure:"))
+			if err != nil {
+				return nil, nil, err
+			}
+			opts.onFailure = policy
+		case strings.HasPrefix(arg, "retries:"):
+			retries, err := strconv.Atoi(strings.TrimPrefix(arg, "retries:"))
+			if err != nil || retries < 0 {
+				return nil, nil, fmt.Errorf("invalid retries value %q: expected a non-negative integer", arg)
+			}
+			opts.retries = retries
+		case strings.HasPrefix(arg, "split-max-bytes:"):
+			limit, err := strconv.Atoi(strings.TrimPrefix(arg, "split-max-bytes:"))
+			if err != nil || limit <= 0 {
+				return nil, nil, fmt.Errorf("invalid split-max-bytes value %q: expected a positive integer", arg)
+			}
+			opts.splitMaxBytes = limit
+		case strings.HasPrefix(arg, "split-max-providers:"):
+			limit, err := strconv.Atoi(strings.TrimPrefix(arg, "split-max-providers:"))
+			if err != nil || limit <= 0 {
+				return nil, nil, fmt.Errorf("invalid split-max-providers value %q: expected a positive integer", arg)
+			}
+			opts.splitMaxProviders = limit
+		case strings.HasPrefix(arg, "split-base-url:"):
+			opts.splitBaseURL = strings.TrimPrefix(arg, "split-base-url:")
+		case strings.HasPrefix(arg, "xades:"):
+			value, err := strconv.ParseBool(strings.TrimPrefix(arg, "xades:"))
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid xades value %q: %w", arg, err)
+			}
+			xades = value
+		case strings.HasPrefix(arg, "xades-policy:"):
+			policy.PolicyID = strings.TrimPrefix(arg, "xades-policy:")
+		case strings.HasPrefix(arg, "xades-policy-digest:"):
+			digest, err := parseXAdESDigest(strings.TrimPrefix(arg, "xades-policy-digest:"))
+			if err != nil {
+				return nil, nil, err
+			}
+			policy.PolicyDigest = digest
+		case strings.HasPrefix(arg, "xades-policy-url:"):
+			policy.PolicyURL = strings.TrimPrefix(arg, "xades-policy-url:")
+		case strings.HasPrefix(arg, "xades-policy-description:"):
+			policy.PolicyDescription = strings.TrimPrefix(arg, "xades-policy-description:")
+		default:
+			positional = append(positional, arg)
+		}
+	}
+
+	if rolloverCert != "" || rolloverKey != "" {
+		if rolloverCert == "" || rolloverKey == "" {
+			return nil, nil, fmt.Errorf("rollover-cert and rollover-key must be given together")
+		}
+		if err := validation.ValidateFilePath(rolloverCert); err != nil {
+			return nil, nil, fmt.Errorf("invalid rollover certificate path: %w", err)
+		}
+		if err := validation.ValidateFilePath(rolloverKey); err != nil {
+			return nil, nil, fmt.Errorf("invalid rollover key path: %w", err)
+		}
+		opts.rolloverSigner = dsig.NewFileSigner(rolloverCert, rolloverKey)
+	}
+
+	// A signature policy implies XAdES
+	if xades || policy.PolicyID != "" || len(policy.PolicyDigest) > 0 || policy.PolicyURL != "" || policy.PolicyDescription != "" {
+		if err := policy.Validate(); err != nil {
+			return nil, nil, err
+		}
+		opts.xades = &policy
+	}
+
+	return positional, opts, nil
+}
+
+// parseXAdESDigest parses the SHA-256 digest of a signature policy document,
+// given in base64 or hex.
+func parseXAdESDigest(value string) ([]byte, error) {
+	if len(value) == 2*sha256.Size {
+		if digest, err := hex.DecodeString(value); err == nil {
+			return digest, nil
+		}
+	}
+	digest, err := base64.StdEncoding.DecodeString(value)
+	if err != nil || len(digest) != sha256.Size {
+		return nil, fmt.Errorf("invalid xades-policy-digest value %q: expected a SHA-256 digest in base64 or hex", value)
+	}
+	return digest, nil
+}
+
+// useXAdES configures signer to create XAdES signatures with the XAdES
+// options, dated at the time of the run. Only file and PKCS#11 signers create
+// XAdES signatures.
+func (o *publishOptions) useXAdES(signer dsig.XMLSigner) error {
+	if o.xades == nil {
+		return nil
+	}
+	xades := *o.xades
+	xades.SigningTime = o.timestamp()
+	switch s := signer.(type) {
+	case *dsig.FileSigner:
+		s.XAdES = &xades
+	case *dsig.PKCS11Signer:
+		s.XAdES = &xades
+	case nil:
+		return fmt.Errorf("XAdES signatures need a signer")
+	default:
+		return fmt.Errorf("XAdES signatures need a certificate and key or a PKCS#11 signer")
+	}
+	return nil
+}
+
+// setIndent sets the output layout from an indent option value.
+func (o *publishOptions) setIndent(value string) error {
+	switch strings.ToLower(value) {
+	case IndentPretty:
+		o.compact = false
+	case IndentCompact:
+		o.compact = true
+	default:
+		return fmt.Errorf("invalid indent value %q (expected pretty or compact)", value)
+	}
+	return nil
+}
+
+// setEncoding sets the declared encoding from an encoding option value. The
+// output is always UTF-8; the option only controls whether it is declared.
+func (o *publishOptions) setEncoding(value string) error {
+	switch {
+	case strings.EqualFold(value, EncodingUTF8) || strings.EqualFold(value, "utf8"):
+		o.omitEncoding = false
+	case strings.EqualFold(value, EncodingNone):
+		o.omitEncoding = true
+	default:
+		return fmt.Errorf("invalid encoding value %q (only UTF-8 output is supported, or none to omit the attribute)", value)
+	}
+	return nil
+}
+
+// setNewline sets the line endings from a newline option value.
+func (o *publishOptions) setNewline(value string) error {
+	switch strings.ToLower(value) {
+	case NewlineLF:
+		o.crlf = false
+	case NewlineCRLF:
+		o.crlf = true
+	default:
+		return fmt.Errorf("invalid newline value %q (expected lf or crlf)", value)
+	}
+	return nil
+}
+
+// validateRollover checks the key rollover settings before anything is published.
+// A rollover needs a signer for the primary location, a signer with the next key
+// and a separate directory for the copies signed with it.
+func (o *publishOptions) validateRollover(dirPath string) error {
+	if o.rolloverSigner == nil && o.rolloverDir == "" {
+		return nil
+	}
+	if o.rolloverSigner == nil {
+		return fmt.Errorf("rollover-dir requires a rollover signer")
+	}
+	if o.rolloverDir == "" {
+		return fmt.Errorf("a rollover signer requires rollover-dir")
+	}
+	if o.signer == nil {
+		return fmt.Errorf("key rollover requires a signer for the primary location")
+	}
+	if filepath.Clean(o.rolloverDir) == filepath.Clean(dirPath) {
+		return fmt.Errorf("rollover-dir must differ from the output directory")
+	}
+	if err := validation.ValidateOutputDirectory(o.rolloverDir); err != nil {
+		return fmt.Errorf("invalid rollover directory: %w", err)
+	}
+	return nil
+}
+
+// rolloverPath returns the path of the copy of a TSL published at path that is
+// signed with the next key: the same path relative to the rollover directory.
+func (o *publishOptions) rolloverPath(path string) (string, error) {
+	rel, err := filepath.Rel(o.baseDir, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is outside the output directory %s", path, o.baseDir)
+	}
+	return filepath.Join(o.rolloverDir, rel), nil
+}
@@ -0,0 +1,473 @@
+package pipeline
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirosfoundation/g119612/pkg/dsig"
+	"github.com/sirosfoundation/g119612/pkg/etsi119612"
+	"github.com/sirosfoundation/g119612/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePublishOptions(t *testing.T) {
+	args, opts, err := parsePublishOptions([]string{"/out", "file-mode:0640", "cert.pem", "dir-mode:750", "key.pem", "key-permissions:strict"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/out", "cert.pem", "key.pem"}, args)
+	assert.Equal(t, os.FileMode(0640), opts.fileMode)
+	assert.Equal(t, os.FileMode(0750), opts.dirMode)
+	assert.Equal(t, KeyPermissionsStrict, opts.keyPermissions)
+
+	_, opts, err = parsePublishOptions([]string{"/out"})
+	require.NoError(t, err)
+	assert.Equal(t, DefaultPublishFileMode, opts.fileMode)
+	assert.Equal(t, DefaultPublishDirMode, opts.dirMode)
+	assert.Equal(t, KeyPermissionsWarn, opts.keyPermissions)
+
+	_, _, err = parsePublishOptions([]string{"/out", "file-mode:0999"})
+	assert.Error(t, err)
+	_, _, err = parsePublishOptions([]string{"/out", "file-mode:17777"})
+	assert.Error(t, err)
+	_, _, err = parsePublishOptions([]string{"/out", "key-permissions:maybe"})
+	assert.Error(t, err)
+}
+
+func TestPublishTSL_FileModes(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("POSIX permissions are not enforced on Windows")
+	}
+
+	pl := &Pipeline{Logger: logging.SilentLogger()}
+	ctx := NewContext()
+	ctx.AddTSL(generateTSL("Test Service", "http://uri.etsi.org/TrstSvc/Svctype/CA/QC", []string{TestCertBase64}))
+
+	outDir := filepath.Join(t.TempDir(), "out")
+	_, err := PublishTSL(pl, ctx, outDir, "file-mode:0600", "dir-mode:0700")
+	require.NoError(t, err)
+
+	info, err := os.Stat(outDir)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0700), info.Mode().Perm())
+
+	entries, err := os.ReadDir(outDir)
+	require.NoError(t, err)
+	require.NotEmpty(t, entries)
+	for _, entry := range entries {
+		fi, err := entry.Info()
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0600), fi.Mode().Perm(), "file %s", entry.Name())
+	}
+}
+
+func TestPublishTSL_StrictKeyPermissions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("POSIX permissions are not enforced on Windows")
+	}
+
+	pl := &Pipeline{Logger: logging.SilentLogger()}
+	ctx := NewContext()
+	ctx.AddTSL(generateTSL("Test Service", "http://uri.etsi.org/TrstSvc/Svctype/CA/QC", []string{TestCertBase64}))
+
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	require.NoError(t, generateTestCertAndKey(certFile, keyFile))
+	require.NoError(t, os.Chmod(keyFile, 0644))
+
+	outDir := filepath.Join(dir, "out")
+	_, err := PublishTSL(pl, ctx, outDir, certFile, keyFile, "key-permissions:strict")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "refusing to use private key")
+
+	// The default policy only warns
+	_, err = PublishTSL(pl, ctx, outDir, certFile, keyFile)
+	assert.NoError(t, err)
+
+	// A key passing the check is used with strict checks when signing
+	require.NoError(t, os.Chmod(keyFile, 0600))
+	signer := dsig.NewFileSigner(certFile, keyFile)
+	require.NoError(t, checkSignerKeyPermissions(pl, signer, KeyPermissionsStrict))
+	assert.True(t, signer.StrictKeyPermissions)
+	require.NoError(t, checkSignerKeyPermissions(pl, signer, KeyPermissionsWarn))
+	assert.False(t, signer.StrictKeyPermissions)
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "file.xml")
+
+	require.NoError(t, writeFileAtomic(path, []byte("first"), 0644))
+	require.NoError(t, writeFileAtomic(path, []byte("second"), 0644))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "second", string(data))
+
+	// No staging files are left behind
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestPublishTSL_UnsignedCopy(t *testing.T) {
+	pl := &Pipeline{Logger: logging.SilentLogger()}
+	ctx := NewContext()
+	ctx.AddTSL(generateTSL("Test Service", "http://uri.etsi.org/TrstSvc/Svctype/CA/QC", []string{TestCertBase64}))
+
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	require.NoError(t, generateTestCertAndKey(certFile, keyFile))
+
+	outDir := filepath.Join(dir, "out")
+	_, err := PublishTSL(pl, ctx, outDir, certFile, keyFile, "unsigned-copy:true")
+	require.NoError(t, err)
+
+	signed, err := os.ReadFile(filepath.Join(outDir, "tsl-0.xml"))
+	require.NoError(t, err)
+	unsigned, err := os.ReadFile(filepath.Join(outDir, "tsl-0-unsigned.xml"))
+	require.NoError(t, err)
+
+	assert.True(t, strings.Contains(string(signed), "SignatureValue"))
+	assert.False(t, strings.Contains(string(unsigned), "SignatureValue"))
+
+	// The unsigned copy is the canonical content the signature covers
+	content, _, err := etsi119612.LocalVerifier{}.Verify(context.Background(), signed)
+	require.NoError(t, err)
+	assert.Equal(t, string(content), string(unsigned))
+	assert.False(t, strings.HasPrefix(string(unsigned), "<?xml"))
+
+	// Both variants carry the same list content
+	var signedList, unsignedList etsi119612.TrustStatusListType
+	require.NoError(t, xml.Unmarshal(signed, &signedList))
+	require.NoError(t, xml.Unmarshal(unsigned, &unsignedList))
+	assert.Equal(t, unsignedList.TslSchemeInformation, signedList.TslSchemeInformation)
+	assert.Equal(t, unsignedList.TslTrustServiceProviderList, signedList.TslTrustServiceProviderList)
+
+	// Without a signer the option has nothing to add
+	plainDir := filepath.Join(dir, "plain")
+	_, err = PublishTSL(pl, ctx, plainDir, "unsigned-copy:true")
+	require.NoError(t, err)
+	unsignedFiles, err := filepath.Glob(filepath.Join(plainDir, "*-unsigned.xml"))
+	require.NoError(t, err)
+	assert.Empty(t, unsignedFiles)
+
+	_, _, err = parsePublishOptions([]string{"/out", "unsigned-copy:sometimes"})
+	assert.Error(t, err)
+}
+
+func TestUnsignedVariantPath(t *testing.T) {
+	assert.Equal(t, filepath.Join("out", "EU-unsigned.xml"), unsignedVariantPath(filepath.Join("out", "EU.xml")))
+	assert.Equal(t, "tsl-unsigned", unsignedVariantPath("tsl"))
+}
+
+func TestPublishTSL_StreamSignMode(t *testing.T) {
+	pl := &Pipeline{Logger: logging.SilentLogger()}
+	ctx := NewContext()
+	ctx.AddTSL(generateTSL("Test Service", "http://uri.etsi.org/TrstSvc/Svctype/CA/QC", []string{TestCertBase64}))
+
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	require.NoError(t, generateTestCertAndKey(certFile, keyFile))
+
+	outDir := filepath.Join(dir, "out")
+	_, err := PublishTSL(pl, ctx, outDir, certFile, keyFile, "sign-mode:stream", "unsigned-copy:true")
+	require.NoError(t, err)
+	plainDir := filepath.Join(dir, "plain")
+	_, err = PublishTSL(pl, ctx, plainDir)
+	require.NoError(t, err)
+
+	signed, err := os.ReadFile(filepath.Join(outDir, "tsl-0.xml"))
+	require.NoError(t, err)
+	plain, err := os.ReadFile(filepath.Join(plainDir, "tsl-0.xml"))
+	require.NoError(t, err)
+	unsigned, err := os.ReadFile(filepath.Join(outDir, "tsl-0-unsigned.xml"))
+	require.NoError(t, err)
+
+	// Stream signing keeps the serialized document and only inserts the signature
+	idx := strings.Index(string(signed), "<ds:Signature ")
+	require.Positive(t, idx)
+	assert.Equal(t, string(plain[:idx]), string(signed[:idx]))
+	assert.True(t, strings.HasSuffix(string(signed), "</ds:Signature></TrustServiceStatusList>"))
+
+	// The unsigned copy is the canonical content the signature covers
+	content, _, err := etsi119612.LocalVerifier{}.Verify(context.Background(), signed)
+	require.NoError(t, err)
+	assert.Equal(t, string(content), string(unsigned))
+
+	// The document is signed from a temporary file, which is removed
+	entries, err := os.ReadDir(outDir)
+	require.NoError(t, err)
+	for _, entry := range entries {
+		assert.False(t, strings.HasPrefix(entry.Name(), "."), entry.Name())
+	}
+
+	_, err = PublishTSL(pl, ctx, outDir, "sign-mode:fast")
+	assert.Error(t, err)
+}
+
+func TestPublishTSL_BuiltinSigners(t *testing.T) {
+	pl := &Pipeline{Logger: logging.SilentLogger()}
+	ctx := NewContext()
+	ctx.AddTSL(generateTSL("Test Service", "http://uri.etsi.org/TrstSvc/Svctype/CA/QC", []string{TestCertBase64}))
+	dir := t.TempDir()
+	signatureValue := regexp.MustCompile(`<ds:SignatureValue>[A-Za-z0-9+/=\s]+</ds:SignatureValue>`)
+
+	for _, name := range []string{SignerNone, SignerMemory} {
+		for _, mode := range []string{SignModeDOM, SignModeStream} {
+			outDir := filepath.Join(dir, name+"-"+mode)
+			_, err := PublishTSL(pl, ctx, outDir, "signer:"+name, "sign-mode:"+mode, "unsigned-copy:true")
+			require.NoError(t, err, name+" "+mode)
+
+			signed, err := os.ReadFile(filepath.Join(outDir, "tsl-0.xml"))
+			require.NoError(t, err)
+			assert.Contains(t, string(signed), "<ds:SignedInfo>")
+			assert.FileExists(t, filepath.Join(outDir, "tsl-0-unsigned.xml"), "the output counts as signed")
+			assert.Equal(t, name == SignerMemory, signatureValue.Match(signed), name+" "+mode)
+		}
+	}
+
+	_, err := PublishTSL(pl, ctx, dir, "signer:hsm")
+	assert.ErrorContains(t, err, "invalid signer value")
+
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	require.NoError(t, generateTestCertAndKey(certFile, keyFile))
+	_, err = PublishTSL(pl, ctx, dir, certFile, keyFile, "signer:memory")
+	assert.ErrorIs(t, err, ErrInvalidArguments)
+	assert.ErrorIs(t, validatePublishArgs(dir, certFile, keyFile, "signer:memory"), ErrInvalidArguments)
+	assert.NoError(t, validatePublishArgs(dir, "signer:none"))
+	assert.Equal(t, []string{dir, "signature:none"}, publishOutputs(dir, "signer:none"))
+	assert.Equal(t, []string{dir, "signature:memory"}, publishOutputs(dir, "signer:memory"))
+}
+
+// certificateBase64 returns the base64 DER of the PEM certificate in certFile.
+func certificateBase64(t *testing.T, certFile string) string {
+	t.Helper()
+	data, err := os.ReadFile(certFile)
+	require.NoError(t, err)
+	block, _ := pem.Decode(data)
+	require.NotNil(t, block)
+	return base64.StdEncoding.EncodeToString(block.Bytes)
+}
+
+func TestPublishTSL_KeyRollover(t *testing.T) {
+	pl := &Pipeline{Logger: logging.SilentLogger()}
+	ctx := NewContext()
+	ctx.AddTSL(generateTSL("Test Service", "http://uri.etsi.org/TrstSvc/Svctype/CA/QC", []string{TestCertBase64}))
+
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	require.NoError(t, generateTestCertAndKey(certFile, keyFile))
+	nextCertFile, nextKeyFile := filepath.Join(dir, "next-cert.pem"), filepath.Join(dir, "next-key.pem")
+	require.NoError(t, generateTestCertAndKey(nextCertFile, nextKeyFile))
+
+	outDir, nextDir := filepath.Join(dir, "out"), filepath.Join(dir, "next")
+	_, err := PublishTSL(pl, ctx, outDir, certFile, keyFile,
+		"rollover-cert:"+nextCertFile, "rollover-key:"+nextKeyFile, "rollover-dir:"+nextDir)
+	require.NoError(t, err)
+
+	current, err := os.ReadFile(filepath.Join(outDir, "tsl-0.xml"))
+	require.NoError(t, err)
+	next, err := os.ReadFile(filepath.Join(nextDir, "tsl-0.xml"))
+	require.NoError(t, err)
+
+	assert.Contains(t, string(current), certificateBase64(t, certFile))
+	assert.NotContains(t, string(current), certificateBase64(t, nextCertFile))
+	assert.Contains(t, string(next), certificateBase64(t, nextCertFile))
+	assert.NotContains(t, string(next), certificateBase64(t, certFile))
+
+	// Both copies sign the same content
+	strip := func(data []byte) string {
+		s := string(data)
+		return s[:strings.Index(s, "<ds:Signature")]
+	}
+	assert.Equal(t, strip(current), strip(next))
+}
+
+func TestPublishTSL_KeyRolloverInvalid(t *testing.T) {
+	pl := &Pipeline{Logger: logging.SilentLogger()}
+	ctx := NewContext()
+	ctx.AddTSL(generateTSL("Test Service", "http://uri.etsi.org/TrstSvc/Svctype/CA/QC", []string{TestCertBase64}))
+
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	require.NoError(t, generateTestCertAndKey(certFile, keyFile))
+	outDir, nextDir := filepath.Join(dir, "out"), filepath.Join(dir, "next")
+
+	tests := map[string][]string{
+		"cert without key": {outDir, certFile, keyFile, "rollover-cert:" + certFile, "rollover-dir:" + nextDir},
+		"missing dir":      {outDir, certFile, keyFile, "rollover-cert:" + certFile, "rollover-key:" + keyFile},
+		"dir without key":  {outDir, certFile, keyFile, "rollover-dir:" + nextDir},
+		"unsigned primary": {outDir, "rollover-cert:" + certFile, "rollover-key:" + keyFile, "rollover-dir:" + nextDir},
+		"same directory":   {outDir, certFile, keyFile, "rollover-cert:" + certFile, "rollover-key:" + keyFile, "rollover-dir:" + outDir + "/"},
+	}
+	for name, args := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := PublishTSL(pl, ctx, args...)
+			assert.Error(t, err)
+		})
+	}
+	_, err := os.Stat(outDir)
+	assert.True(t, os.IsNotExist(err), "nothing is published with invalid rollover settings")
+}
+
+func TestPublishTSL_OutputFormat(t *testing.T) {
+	pl := &Pipeline{Logger: logging.SilentLogger()}
+	ctx := NewContext()
+	ctx.AddTSL(generateTSL("Test Service", "http://uri.etsi.org/TrstSvc/Svctype/CA/QC", []string{TestCertBase64}))
+
+	publish := func(t *testing.T, args ...string) string {
+		t.Helper()
+		outDir := filepath.Join(t.TempDir(), "out")
+		_, err := PublishTSL(pl, ctx, append([]string{outDir}, args...)...)
+		require.NoError(t, err)
+		data, err := os.ReadFile(filepath.Join(outDir, "tsl-0.xml"))
+		require.NoError(t, err)
+		var list etsi119612.TrustStatusListType
+		require.NoError(t, xml.Unmarshal(data, &list))
+		return string(data)
+	}
+
+	t.Run("default", func(t *testing.T) {
+		data := publish(t)
+		assert.True(t, strings.HasPrefix(data, xml.Header))
+		assert.Contains(t, data, "\n  <")
+		assert.NotContains(t, data, "\r\n")
+	})
+	t.Run("compact", func(t *testing.T) {
+		data := publish(t, "indent:compact")
+		body := strings.TrimPrefix(data, xml.Header)
+		assert.True(t, strings.HasPrefix(data, xml.Header))
+		assert.NotContains(t, body, "\n")
+	})
+	t.Run("no declaration", func(t *testing.T) {
+		data := publish(t, "xml-declaration:false")
+		assert.True(t, strings.HasPrefix(data, "<TrustServiceStatusList"))
+	})
+	t.Run("no encoding", func(t *testing.T) {
+		data := publish(t, "encoding:none")
+		assert.True(t, strings.HasPrefix(data, `<?xml version="1.0"?>`+"\n"))
+	})
+	t.Run("crlf", func(t *testing.T) {
+		data := publish(t, "newline:crlf")
+		assert.Contains(t, data, "\r\n  <")
+		assert.Equal(t, strings.Count(data, "\n"), strings.Count(data, "\r\n"))
+	})
+	t.Run("signed compact", func(t *testing.T) {
+		dir := t.TempDir()
+		certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+		require.NoError(t, generateTestCertAndKey(certFile, keyFile))
+		data := publish(t, certFile, keyFile, "indent:compact", "unsigned-copy:true")
+		assert.Contains(t, data, "SignatureValue")
+		assert.NotContains(t, data, "\n  <tsl:")
+	})
+}
+
+func TestParsePublishOptions_OutputFormat(t *testing.T) {
+	_, opts, err := parsePublishOptions([]string{"/out", "indent:compact", "xml-declaration:false", "encoding:none", "newline:crlf"})
+	require.NoError(t, err)
+	assert.True(t, opts.compact)
+	assert.True(t, opts.omitDecl)
+	assert.True(t, opts.omitEncoding)
+	assert.True(t, opts.crlf)
+
+	_, opts, err = parsePublishOptions([]string{"/out", "indent:pretty", "encoding:utf-8", "newline:lf"})
+	require.NoError(t, err)
+	assert.False(t, opts.compact)
+	assert.False(t, opts.omitEncoding)
+	assert.False(t, opts.crlf)
+
+	for _, arg := range []string{"indent:tabs", "xml-declaration:maybe", "encoding:ISO-8859-1", "newline:cr"} {
+		_, _, err := parsePublishOptions([]string{"/out", arg})
+		assert.Error(t, err, arg)
+	}
+}
+
+func TestPublishTSL_XAdES(t *testing.T) {
+	pl := &Pipeline{Logger: logging.SilentLogger()}
+	signingTime := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	ctx := NewContext()
+	ctx.Clock = FixedClock(signingTime)
+	ctx.AddTSL(generateTSL("Test Service", "http://uri.etsi.org/TrstSvc/Svctype/CA/QC", []string{TestCertBase64}))
+
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	require.NoError(t, generateTestCertAndKey(certFile, keyFile))
+	policyDigest := sha256.Sum256([]byte("policy"))
+
+	for _, mode := range []string{SignModeDOM, SignModeStream} {
+		t.Run(mode, func(t *testing.T) {
+			outDir := filepath.Join(dir, mode)
+			_, err := PublishTSL(pl, ctx, outDir, certFile, keyFile, "sign-mode:"+mode,
+				"xades-policy:urn:oid:1.2.3.4.5",
+				"xades-policy-digest:"+hex.EncodeToString(policyDigest[:]),
+				"xades-policy-url:https://example.com/policy.pdf")
+			require.NoError(t, err)
+
+			data, err := os.ReadFile(filepath.Join(outDir, "tsl-0.xml"))
+			require.NoError(t, err)
+			assert.Contains(t, string(data), "<xades:Identifier>urn:oid:1.2.3.4.5</xades:Identifier>")
+			assert.Contains(t, string(data), "<xades:SPURI>https://example.com/policy.pdf</xades:SPURI>")
+
+			// The signature verifies and is dated at the time of the run
+			tsl, err := etsi119612.ParseTSL(data, "tsl-0.xml", etsi119612.TSLFetchOptions{})
+			require.NoError(t, err)
+			assert.Equal(t, certificateBase64(t, certFile), base64.StdEncoding.EncodeToString(tsl.Signer.Raw))
+			require.NotNil(t, tsl.SignatureInfo())
+			assert.True(t, signingTime.Equal(tsl.SignatureInfo().SigningTime))
+		})
+	}
+
+	_, err := PublishTSL(pl, ctx, filepath.Join(dir, "memory"), "signer:memory", "xades:true")
+	assert.ErrorIs(t, err, ErrInvalidArguments)
+	_, err = PublishTSL(pl, ctx, filepath.Join(dir, "unsigned"), "xades:true")
+	assert.ErrorIs(t, err, ErrInvalidArguments)
+	assert.NoError(t, validatePublishArgs(dir, certFile, keyFile, "xades:true"))
+	assert.ErrorIs(t, validatePublishArgs(dir, "xades:true"), ErrInvalidArguments)
+}
+
+func TestParsePublishOptions_XAdES(t *testing.T) {
+	_, opts, err := parsePublishOptions([]string{"/out"})
+	require.NoError(t, err)
+	assert.Nil(t, opts.xades)
+
+	_, opts, err = parsePublishOptions([]string{"/out", "xades:true"})
+	require.NoError(t, err)
+	require.NotNil(t, opts.xades)
+	assert.Empty(t, opts.xades.PolicyID)
+
+	digest := sha256.Sum256([]byte("policy"))
+	for _, encoded := range []string{hex.EncodeToString(digest[:]), base64.StdEncoding.EncodeToString(digest[:])} {
+		_, opts, err = parsePublishOptions([]string{"/out", "xades-policy:urn:oid:1.2.3", "xades-policy-digest:" + encoded,
+			"xades-policy-description:Test policy"})
+		require.NoError(t, err)
+		require.NotNil(t, opts.xades, "a policy implies XAdES")
+		assert.Equal(t, "urn:oid:1.2.3", opts.xades.PolicyID)
+		assert.Equal(t, digest[:], opts.xades.PolicyDigest)
+		assert.Equal(t, "Test policy", opts.xades.PolicyDescription)
+	}
+
+	for _, args := range [][]string{
+		{"xades:maybe"},
+		{"xades-policy:urn:oid:1.2.3"},
+		{"xades-policy:urn:oid:1.2.3", "xades-policy-digest:abcd"},
+		{"xades-policy-url:https://example.com/policy.pdf"},
+	} {
+		_, _, err := parsePublishOptions(append([]string{"/out"}, args...))
+		assert.Error(t, err, args)
+	}
+}
@@ -1,18 +1,13 @@
 package pipeline
 
 import (
-	"crypto/rand"
-	"crypto/rsa"
 	"crypto/x509"
-	"crypto/x509/pkix"
-	"encoding/pem"
-	"math/big"
 	"os"
 	"path/filepath"
 	"testing"
-	"time"
 
 	"github.com/beevik/etree"
+	"github.com/sirosfoundation/g119612/pkg/dsig"
 	"github.com/sirosfoundation/g119612/pkg/etsi119612"
 	"github.com/sirosfoundation/g119612/pkg/logging"
 )
@@ -87,64 +82,14 @@ func TestPublishTSL_WithSignature(t *testing.T) {
 
 // generateTestCertAndKey creates a self-signed certificate and private key for testing
 func generateTestCertAndKey(certFile, keyFile string) error {
-	// Generate a private key
-	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	signer, err := dsig.GenerateSelfSignedSigner(dsig.SelfSignedOptions{
+		CommonName:   "Test Certificate",
+		Organization: "Test Org",
+		IsCA:         true,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
 	if err != nil {
 		return err
 	}
-
-	// Create a certificate template
-	notBefore := time.Now()
-	notAfter := notBefore.Add(24 * time.Hour)
-
-	serialNumberLimit := new(big.Int).Lsh(big.NewInt(1), 128)
-	serialNumber, err := rand.Int(rand.Reader, serialNumberLimit)
-	if err != nil {
-		return err
-	}
-
-	template := x509.Certificate{
-		SerialNumber: serialNumber,
-		Subject: pkix.Name{
-			Organization: []string{"Test Org"},
-			CommonName:   "Test Certificate",
-		},
-		NotBefore:             notBefore,
-		NotAfter:              notAfter,
-		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
-		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
-		BasicConstraintsValid: true,
-		IsCA:                  true,
-	}
-
-	// Create a self-signed certificate
-	derBytes, err := x509.CreateCertificate(rand.Reader, &template, &template, &priv.PublicKey, priv)
-	if err != nil {
-		return err
-	}
-
-	// Write certificate to file
-	certOut, err := os.Create(certFile)
-	if err != nil {
-		return err
-	}
-	defer certOut.Close()
-
-	if err := pem.Encode(certOut, &pem.Block{Type: "CERTIFICATE", Bytes: derBytes}); err != nil {
-		return err
-	}
-
-	// Write private key to file
-	keyOut, err := os.Create(keyFile)
-	if err != nil {
-		return err
-	}
-	defer keyOut.Close()
-
-	privBytes := x509.MarshalPKCS1PrivateKey(priv)
-	if err := pem.Encode(keyOut, &pem.Block{Type: "RSA PRIVATE KEY", Bytes: privBytes}); err != nil {
-		return err
-	}
-
-	return nil
+	return signer.WritePEM(certFile, keyFile)
 }
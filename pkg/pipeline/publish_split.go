@@ -0,0 +1,198 @@
+package pipeline
+
+import (
+	"encoding/xml"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirosfoundation/g119612/pkg/etsi119612"
+	"github.com/sirosfoundation/g119612/pkg/logging"
+)
+
+// splitSignatureReserve is the room left for the signature below
+// split-max-bytes when the parts are signed.
+const splitSignatureReserve = 16 << 10
+
+// splitPart is a TSL written in place of an oversized TSL, and its path.
+type splitPart struct {
+	tsl  *etsi119612.TSL
+	path string
+}
+
+// splitTSL returns the TSLs to publish in place of a TSL exceeding the
+// split-max-bytes or split-max-providers limits, or nil if it is within them.
+// The providers are distributed over parts named like the TSL with "-1",
+// "-2", ... before the extension, each a copy of the TSL with some of the
+// providers. The TSL itself is published without providers and with a
+// pointer to each part added to its PointersToOtherTSL, like a list of
+// lists. The parts are located at split-base-url, by default next to the
+// first distribution point of the TSL.
+func (o *publishOptions) splitTSL(tsl *etsi119612.TSL, path string) ([]splitPart, error) {
+	if o.splitMaxBytes <= 0 && o.splitMaxProviders <= 0 {
+		return nil, nil
+	}
+	var providers []*etsi119612.TSPType
+	if list := tsl.StatusList.TslTrustServiceProviderList; list != nil {
+		providers = list.TslTrustServiceProvider
+	}
+	limit := o.splitMaxBytes
+	if limit > 0 && o.signer != nil {
+		limit -= splitSignatureReserve
+		if limit <= 0 {
+			return nil, fmt.Errorf("%w: split-max-bytes leaves no room for the signature of %d bytes", ErrInvalidArguments, splitSignatureReserve)
+		}
+	}
+	if o.splitMaxProviders <= 0 || len(providers) <= o.splitMaxProviders {
+		if limit <= 0 {
+			return nil, nil
+		}
+		data, err := o.marshalTSL(tsl)
+		if err != nil {
+			return nil, err
+		}
+		if len(data) <= limit {
+			return nil, nil
+		}
+	}
+
+	baseURL := strings.TrimRight(o.splitBaseURL, "/")
+	info := tsl.StatusList.TslSchemeInformation
+	if baseURL == "" && info != nil && info.TslDistributionPoints != nil && len(info.TslDistributionPoints.URI) > 0 {
+		location := info.TslDistributionPoints.URI[0]
+		if i := strings.LastIndex(location, "/"); i > 0 {
+			baseURL = location[:i]
+		}
+	}
+	if baseURL == "" {
+		return nil, fmt.Errorf("%s exceeds the split limits but has no distribution point; set split-base-url", filepath.Base(path))
+	}
+
+	groups, err := o.groupProviders(tsl, providers, limit)
+	if err != nil {
+		return nil, fmt.Errorf("cannot split %s: %w", filepath.Base(path), err)
+	}
+
+	ext := filepath.Ext(path)
+	stem := strings.TrimSuffix(path, ext)
+	root := splitCopy(tsl, nil)
+	pointers := &etsi119612.OtherTSLPointersType{}
+	if p := tsl.StatusList.TslSchemeInformation; p != nil && p.TslPointersToOtherTSL != nil {
+		pointers.TslOtherTSLPointer = append(pointers.TslOtherTSLPointer, p.TslPointersToOtherTSL.TslOtherTSLPointer...)
+	}
+	parts := make([]splitPart, 0, len(groups)+1)
+	for i, group := range groups {
+		partPath := fmt.Sprintf("%s-%d%s", stem, i+1, ext)
+		location := baseURL + "/" + filepath.Base(partPath)
+		part := splitCopy(tsl, group)
+		part.StatusList.TslSchemeInformation.TslPointersToOtherTSL = nil
+		part.StatusList.TslSchemeInformation.TslDistributionPoints = &etsi119612.NonEmptyURIListType{URI: []string{location}}
+		part.Source = location
+		pointers.TslOtherTSLPointer = append(pointers.TslOtherTSLPointer, &etsi119612.OtherTSLPointerType{TSLLocation: location})
+		parts = append(parts, splitPart{tsl: part, path: partPath})
+	}
+	root.StatusList.TslSchemeInformation.TslPointersToOtherTSL = pointers
+	return append(parts, splitPart{tsl: root, path: path}), nil
+}
+
+// splitCopy returns a copy of tsl listing the given providers, with its own
+// scheme information and without a signature.
+func splitCopy(tsl *etsi119612.TSL, providers []*etsi119612.TSPType) *etsi119612.TSL {
+	list := tsl.StatusList
+	list.DsSignature = nil
+	info := etsi119612.TSLSchemeInformationType{}
+	if list.TslSchemeInformation != nil {
+		info = *list.TslSchemeInformation
+	}
+	list.TslSchemeInformation = &info
+	list.TslTrustServiceProviderList = nil
+	if len(providers) > 0 {
+		list.TslTrustServiceProviderList = &etsi119612.TrustServiceProviderListType{TslTrustServiceProvider: providers}
+	}
+	return &etsi119612.TSL{StatusList: list, Source: tsl.Source}
+}
+
+// groupProviders distributes providers over as few parts as the limits allow,
+// keeping their order. Sizes are estimated from the providers serialized on
+// their own and then checked on the serialized parts, halving parts that
+// still exceed limit.
+func (o *publishOptions) groupProviders(tsl *etsi119612.TSL, providers []*etsi119612.TSPType, limit int) ([][]*etsi119612.TSPType, error) {
+	base := 0
+	if limit > 0 {
+		data, err := o.marshalTSL(splitCopy(tsl, nil))
+		if err != nil {
+			return nil, err
+		}
+		base = len(data) + len("<TrustServiceProviderList></TrustServiceProviderList>")
+	}
+
+	var groups [][]*etsi119612.TSPType
+	var group []*etsi119612.TSPType
+	size := base
+	for _, tsp := range providers {
+		n := 0
+		if limit > 0 {
+			data, err := xml.MarshalIndent(struct {
+				XMLName xml.Name `xml:"TrustServiceProvider"`
+				*etsi119612.TSPType
+			}{TSPType: tsp}, "    ", "  ")
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal provider: %w", err)
+			}
+			n = len(data) + 1
+		}
+		full := o.splitMaxProviders > 0 && len(group) >= o.splitMaxProviders
+		if len(group) > 0 && (full || limit > 0 && size+n > limit) {
+			groups = append(groups, group)
+			group, size = nil, base
+		}
+		group = append(group, tsp)
+		size += n
+	}
+	if len(group) > 0 {
+		groups = append(groups, group)
+	}
+	if limit <= 0 {
+		return groups, nil
+	}
+
+	var checked [][]*etsi119612.TSPType
+	for len(groups) > 0 {
+		group := groups[0]
+		groups = groups[1:]
+		data, err := o.marshalTSL(splitCopy(tsl, group))
+		if err != nil {
+			return nil, err
+		}
+		if len(data) <= limit {
+			checked = append(checked, group)
+			continue
+		}
+		if len(group) == 1 {
+			return nil, fmt.Errorf("provider %q alone takes %d bytes, more than split-max-bytes allows", group[0].Name(), len(data))
+		}
+		half := len(group) / 2
+		groups = append([][]*etsi119612.TSPType{group[:half], group[half:]}, groups...)
+	}
+	return checked, nil
+}
+
+// publishSplitTSL publishes a TSL exceeding the split limits as parts, see
+// splitTSL. It returns false without publishing anything if the TSL is within
+// the limits.
+func publishSplitTSL(pl *Pipeline, tsl *etsi119612.TSL, path string, opts *publishOptions) (bool, error) {
+	parts, err := opts.splitTSL(tsl, path)
+	if err != nil || parts == nil {
+		return false, err
+	}
+	pl.Logger.Info("Splitting oversized TSL", tslFields(tsl,
+		logging.F("file", path),
+		logging.F("providers", tsl.NumberOfTrustServiceProviders()),
+		logging.F("parts", len(parts)-1))...)
+	for _, part := range parts {
+		if err := publishTSLFile(pl, part.tsl, part.path, opts); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
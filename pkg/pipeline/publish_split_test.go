@@ -0,0 +1,127 @@
+package pipeline
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirosfoundation/g119612/pkg/etsi119612"
+	"github.com/sirosfoundation/g119612/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// splitTestTSL returns a TSL with n providers, published at
+// https://tsl.example.com/lists/big.xml.
+func splitTestTSL(n int) *etsi119612.TSL {
+	tsl := generateTSL("Service", "http://uri.etsi.org/TrstSvc/Svctype/CA/QC", []string{TestCertBase64})
+	template := *tsl.StatusList.TslTrustServiceProviderList.TslTrustServiceProvider[0]
+	var providers []*etsi119612.TSPType
+	for i := 0; i < n; i++ {
+		tsp := template
+		name := etsi119612.NonEmptyNormalizedString(fmt.Sprintf("Provider %d", i))
+		lang := etsi119612.Lang("en")
+		tsp.TslTSPInformation = &etsi119612.TSPInformationType{TSPName: &etsi119612.InternationalNamesType{
+			Name: []*etsi119612.MultiLangNormStringType{{XmlLangAttr: &lang, NonEmptyNormalizedString: &name}},
+		}}
+		providers = append(providers, &tsp)
+	}
+	tsl.StatusList.TslTrustServiceProviderList.TslTrustServiceProvider = providers
+	tsl.StatusList.TslSchemeInformation.TslDistributionPoints = &etsi119612.NonEmptyURIListType{
+		URI: []string{"https://tsl.example.com/lists/big.xml"},
+	}
+	return tsl
+}
+
+func TestPublishSplit(t *testing.T) {
+	pl := &Pipeline{Logger: logging.SilentLogger()}
+	publish := func(tsl *etsi119612.TSL, args ...string) string {
+		dir := t.TempDir()
+		ctx := NewContext()
+		ctx.EnsureTSLStack().TSLs.Push(tsl)
+		_, err := PublishTSL(pl, ctx, append([]string{dir}, args...)...)
+		require.NoError(t, err)
+		return dir
+	}
+	parse := func(path string) *etsi119612.TSL {
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		var doc struct {
+			List etsi119612.TrustStatusListType `xml:"List"`
+		}
+		require.NoError(t, xml.Unmarshal(data, &doc), path)
+		return &etsi119612.TSL{StatusList: doc.List}
+	}
+
+	t.Run("ProviderLimit", func(t *testing.T) {
+		dir := publish(splitTestTSL(5), "split-max-providers:2")
+		root := parse(filepath.Join(dir, "big.xml"))
+		assert.Zero(t, root.NumberOfTrustServiceProviders())
+		pointers := root.StatusList.TslSchemeInformation.TslPointersToOtherTSL.TslOtherTSLPointer
+		require.Len(t, pointers, 3)
+
+		var names []string
+		for i, pointer := range pointers {
+			assert.Equal(t, fmt.Sprintf("https://tsl.example.com/lists/big-%d.xml", i+1), pointer.TSLLocation)
+			part := parse(filepath.Join(dir, fmt.Sprintf("big-%d.xml", i+1)))
+			assert.Equal(t, []string{pointer.TSLLocation}, part.StatusList.TslSchemeInformation.TslDistributionPoints.URI)
+			assert.Nil(t, part.StatusList.TslSchemeInformation.TslPointersToOtherTSL)
+			for _, tsp := range part.StatusList.TslTrustServiceProviderList.TslTrustServiceProvider {
+				names = append(names, tsp.Name())
+			}
+		}
+		assert.Equal(t, []string{"Provider 0", "Provider 1", "Provider 2", "Provider 3", "Provider 4"}, names)
+	})
+
+	t.Run("SizeLimit", func(t *testing.T) {
+		tsl := splitTestTSL(40)
+		opts := defaultPublishOptions()
+		full, err := opts.marshalTSL(tsl)
+		require.NoError(t, err)
+		limit := len(full) / 3
+
+		dir := publish(tsl, fmt.Sprintf("split-max-bytes:%d", limit), "split-base-url:https://cdn.example.com/tl/")
+		root := parse(filepath.Join(dir, "big.xml"))
+		pointers := root.StatusList.TslSchemeInformation.TslPointersToOtherTSL.TslOtherTSLPointer
+		require.GreaterOrEqual(t, len(pointers), 3)
+		total := 0
+		for i, pointer := range pointers {
+			assert.Equal(t, fmt.Sprintf("https://cdn.example.com/tl/big-%d.xml", i+1), pointer.TSLLocation)
+			path := filepath.Join(dir, fmt.Sprintf("big-%d.xml", i+1))
+			info, err := os.Stat(path)
+			require.NoError(t, err)
+			assert.LessOrEqual(t, int(info.Size()), limit)
+			total += parse(path).NumberOfTrustServiceProviders()
+		}
+		assert.Equal(t, 40, total)
+	})
+
+	t.Run("WithinLimits", func(t *testing.T) {
+		dir := publish(splitTestTSL(3), "split-max-providers:3", "split-max-bytes:100000000")
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		assert.Equal(t, 3, parse(filepath.Join(dir, "big.xml")).NumberOfTrustServiceProviders())
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		tsl := splitTestTSL(3)
+		tsl.StatusList.TslSchemeInformation.TslDistributionPoints = nil
+		ctx := NewContext()
+		ctx.EnsureTSLStack().TSLs.Push(tsl)
+		_, err := PublishTSL(pl, ctx, t.TempDir(), "split-max-providers:1")
+		assert.ErrorContains(t, err, "set split-base-url")
+
+		ctx = NewContext()
+		ctx.EnsureTSLStack().TSLs.Push(splitTestTSL(3))
+		_, err = PublishTSL(pl, ctx, t.TempDir(), "split-max-bytes:100")
+		assert.ErrorContains(t, err, "more than split-max-bytes allows")
+
+		for _, arg := range []string{"split-max-bytes:0", "split-max-providers:many"} {
+			_, _, err := parsePublishOptions([]string{"/tmp", arg})
+			assert.Error(t, err, arg)
+		}
+	})
+}
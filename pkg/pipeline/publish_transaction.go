@@ -0,0 +1,193 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sirosfoundation/g119612/pkg/logging"
+)
+
+// Failure policies accepted by the publish step's on-failure option. They
+// decide what happens to the files already written when publishing fails
+// midway, for example because the disk is full or signing fails.
+const (
+	// OnFailureKeep leaves the written files in place (default).
+	OnFailureKeep = "keep"
+	// OnFailureRollback restores the files replaced by the failed run and
+	// deletes the files it created.
+	OnFailureRollback = "rollback"
+	// OnFailurePartial leaves the written files in place and marks the output
+	// directory with PartialMarkerName and a FailureManifestName manifest.
+	OnFailurePartial = "partial"
+)
+
+// PartialMarkerName is the marker file written to the output directory when
+// publishing fails with on-failure:partial. A successful publish removes it.
+const PartialMarkerName = ".partial"
+
+// FailureManifestName is the file written next to PartialMarkerName that
+// describes the failed publish as a PublishFailure.
+const FailureManifestName = "publish-failure.json"
+
+// publishRetryDelay is the delay before the first retry of a failed write;
+// each further retry waits one more delay.
+var publishRetryDelay = 200 * time.Millisecond
+
+// PublishFailure is the content of the failure manifest written with
+// on-failure:partial.
+type PublishFailure struct {
+	FailedAt string   `json:"failedAt"` // RFC 3339 time of the failure
+	Error    string   `json:"error"`    // The error that stopped publishing
+	Written  []string `json:"written"`  // Files written before the failure, in order
+}
+
+// writtenFile records a file written by the publish step so a failed run can
+// be rolled back.
+type writtenFile struct {
+	path     string
+	existed  bool        // The file existed before it was written
+	previous []byte      // Previous content, kept for on-failure:rollback only
+	mode     os.FileMode // Previous mode
+}
+
+// parseOnFailure checks an on-failure option value.
+func parseOnFailure(value string) (string, error) {
+	switch value {
+	case OnFailureKeep, OnFailureRollback, OnFailurePartial:
+		return value, nil
+	}
+	return "", fmt.Errorf("invalid on-failure value %q (expected keep, rollback or partial)", value)
+}
+
+// writeFile writes a published file atomically with the configured file mode,
+// retrying failed writes, and records it for failure handling. Before a file is
+// replaced for the first time, its content is kept if a rollback may need it.
+func (o *publishOptions) writeFile(path string, data []byte) error {
+	return o.writeFileFrom(path, tslDocument{data: data}.writeTo)
+}
+
+// writeFileFrom writes a published file like writeFile, with the content
+// written by write, which is called again if the write is retried.
+func (o *publishOptions) writeFileFrom(path string, write func(io.Writer) error) error {
+	if !o.isWritten(path) {
+		entry := writtenFile{path: path}
+		if info, err := os.Stat(path); err == nil {
+			entry.existed = true
+			entry.mode = info.Mode().Perm()
+			if o.onFailure == OnFailureRollback {
+				if entry.previous, err = os.ReadFile(path); err != nil {
+					return fmt.Errorf("failed to keep previous content of %s: %w", path, err)
+				}
+			}
+		}
+		o.written = append(o.written, entry)
+	}
+
+	var err error
+	for attempt := 0; ; attempt++ {
+		if err = writeFileAtomicFrom(path, o.fileMode, write); err == nil || attempt >= o.retries {
+			return err
+		}
+		time.Sleep(time.Duration(attempt+1) * publishRetryDelay)
+	}
+}
+
+// isWritten reports whether path was already written by this publish run.
+func (o *publishOptions) isWritten(path string) bool {
+	for _, entry := range o.written {
+		if entry.path == path {
+			return true
+		}
+	}
+	return false
+}
+
+// finishPublish applies the failure policy after a publish run to dirPath
+// ended with err. After a successful run, markers of an earlier failed run are
+// removed.
+func (o *publishOptions) finishPublish(pl *Pipeline, dirPath string, err error) {
+	if err == nil {
+		for _, name := range []string{PartialMarkerName, FailureManifestName} {
+			if rmErr := os.Remove(filepath.Join(dirPath, name)); rmErr != nil && !errors.Is(rmErr, os.ErrNotExist) {
+				pl.Logger.Warn("Failed to remove marker of an earlier failed publish",
+					logging.F("file", name),
+					logging.F("error", rmErr))
+			}
+		}
+		return
+	}
+	if len(o.written) == 0 {
+		return
+	}
+
+	switch o.onFailure {
+	case OnFailureRollback:
+		if rbErr := o.rollback(); rbErr != nil {
+			pl.Logger.Error("Failed to roll back failed publish",
+				logging.F("directory", dirPath),
+				logging.F("error", rbErr))
+			return
+		}
+		pl.Logger.Warn("Rolled back failed publish",
+			logging.F("directory", dirPath),
+			logging.F("files", len(o.written)))
+	case OnFailurePartial:
+		if markErr := o.markPartial(dirPath, err); markErr != nil {
+			pl.Logger.Error("Failed to mark partial publish",
+				logging.F("directory", dirPath),
+				logging.F("error", markErr))
+			return
+		}
+		pl.Logger.Warn("Marked output directory as partially published",
+			logging.F("directory", dirPath),
+			logging.F("files", len(o.written)))
+	}
+}
+
+// rollback restores the files written by a failed run in reverse order.
+func (o *publishOptions) rollback() error {
+	var errs []error
+	for i := len(o.written) - 1; i >= 0; i-- {
+		entry := o.written[i]
+		var err error
+		if entry.existed {
+			err = writeFileAtomic(entry.path, entry.previous, entry.mode)
+		} else if err = os.Remove(entry.path); errors.Is(err, os.ErrNotExist) {
+			err = nil
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// markPartial writes the partial marker and the failure manifest to dirPath.
+func (o *publishOptions) markPartial(dirPath string, cause error) error {
+	failure := PublishFailure{
+		FailedAt: o.timestamp().UTC().Format(time.RFC3339),
+		Error:    cause.Error(),
+		Written:  make([]string, 0, len(o.written)),
+	}
+	for _, entry := range o.written {
+		path := entry.path
+		if rel, err := filepath.Rel(dirPath, path); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			path = filepath.ToSlash(rel)
+		}
+		failure.Written = append(failure.Written, path)
+	}
+	data, err := json.MarshalIndent(failure, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(filepath.Join(dirPath, FailureManifestName), append(data, '\n'), o.fileMode); err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(dirPath, PartialMarkerName), nil, o.fileMode)
+}
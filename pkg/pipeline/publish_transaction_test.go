@@ -0,0 +1,138 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirosfoundation/g119612/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingSigner returns its input unchanged for the first ok calls of Sign and
+// fails afterwards, simulating a signer that breaks midway through a publish.
+type failingSigner struct {
+	ok int
+}
+
+func (s *failingSigner) Sign(data []byte) ([]byte, error) {
+	if s.ok == 0 {
+		return nil, errors.New("token removed")
+	}
+	s.ok--
+	return data, nil
+}
+
+// failingPublish publishes two TSLs into dir, of which signing the second fails.
+func failingPublish(t *testing.T, dir string, onFailure string) error {
+	t.Helper()
+	ctx := NewContext()
+	ctx.TSLs.Push(generateTSL("Second", "http://uri.etsi.org/TrstSvc/Svctype/CA/QC", nil))
+	ctx.TSLs.Push(generateTSL("First", "http://uri.etsi.org/TrstSvc/Svctype/CA/QC", nil))
+	_, err := Publish(ctx, PublishOptions{
+		Dir:          dir,
+		Signer:       &failingSigner{ok: 1},
+		UnsignedCopy: true,
+		OnFailure:    onFailure,
+	}, WithStepLogger(logging.SilentLogger()))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "token removed")
+	return err
+}
+
+func TestPublish_OnFailure(t *testing.T) {
+	t.Run("Keep", func(t *testing.T) {
+		dir := t.TempDir()
+		failingPublish(t, dir, "")
+		assert.FileExists(t, filepath.Join(dir, "tsl-0.xml"))
+		assert.FileExists(t, filepath.Join(dir, "tsl-0-unsigned.xml"))
+		assert.NoFileExists(t, filepath.Join(dir, PartialMarkerName))
+	})
+
+	t.Run("Rollback", func(t *testing.T) {
+		dir := t.TempDir()
+		previous := filepath.Join(dir, "tsl-0.xml")
+		require.NoError(t, os.WriteFile(previous, []byte("previous publication"), 0640))
+
+		failingPublish(t, dir, OnFailureRollback)
+
+		data, err := os.ReadFile(previous)
+		require.NoError(t, err)
+		assert.Equal(t, "previous publication", string(data))
+		info, err := os.Stat(previous)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0640), info.Mode().Perm())
+		assert.NoFileExists(t, filepath.Join(dir, "tsl-0-unsigned.xml"))
+	})
+
+	t.Run("Partial", func(t *testing.T) {
+		dir := t.TempDir()
+		failingPublish(t, dir, OnFailurePartial)
+
+		assert.FileExists(t, filepath.Join(dir, PartialMarkerName))
+		data, err := os.ReadFile(filepath.Join(dir, FailureManifestName))
+		require.NoError(t, err)
+		var failure PublishFailure
+		require.NoError(t, json.Unmarshal(data, &failure))
+		assert.Equal(t, []string{"tsl-0-unsigned.xml", "tsl-0.xml"}, failure.Written)
+		assert.Contains(t, failure.Error, "token removed")
+		_, err = time.Parse(time.RFC3339, failure.FailedAt)
+		assert.NoError(t, err)
+
+		// The next successful publish removes the markers
+		ctx := NewContext()
+		ctx.TSLs.Push(generateTSL("First", "http://uri.etsi.org/TrstSvc/Svctype/CA/QC", nil))
+		_, err = Publish(ctx, PublishOptions{Dir: dir, OnFailure: OnFailurePartial}, WithStepLogger(logging.SilentLogger()))
+		require.NoError(t, err)
+		assert.NoFileExists(t, filepath.Join(dir, PartialMarkerName))
+		assert.NoFileExists(t, filepath.Join(dir, FailureManifestName))
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := Publish(NewContext(), PublishOptions{Dir: t.TempDir(), OnFailure: "ignore"})
+		assert.ErrorIs(t, err, ErrInvalidArguments)
+		_, err = Publish(NewContext(), PublishOptions{Dir: t.TempDir(), Retries: -1})
+		assert.ErrorIs(t, err, ErrInvalidArguments)
+	})
+}
+
+func TestParsePublishOptions_OnFailure(t *testing.T) {
+	_, opts, err := parsePublishOptions([]string{"/out", "on-failure:rollback", "retries:3"})
+	require.NoError(t, err)
+	assert.Equal(t, OnFailureRollback, opts.onFailure)
+	assert.Equal(t, 3, opts.retries)
+
+	_, opts, err = parsePublishOptions([]string{"/out"})
+	require.NoError(t, err)
+	assert.Equal(t, OnFailureKeep, opts.onFailure)
+	assert.Zero(t, opts.retries)
+
+	for _, arg := range []string{"on-failure:ignore", "retries:-1", "retries:many"} {
+		_, _, err := parsePublishOptions([]string{"/out", arg})
+		assert.Error(t, err, arg)
+	}
+}
+
+func TestPublishOptions_WriteFileRetries(t *testing.T) {
+	defer func(delay time.Duration) { publishRetryDelay = delay }(publishRetryDelay)
+	publishRetryDelay = time.Millisecond
+
+	// A directory in place of the file makes every attempt fail
+	dir := t.TempDir()
+	path := filepath.Join(dir, "tsl.xml")
+	require.NoError(t, os.Mkdir(path, 0755))
+	opts := defaultPublishOptions()
+	opts.retries = 2
+	assert.Error(t, opts.writeFile(path, []byte("data")))
+	assert.Len(t, opts.written, 1)
+
+	// Writes of the same file are recorded once
+	other := filepath.Join(dir, "other.xml")
+	require.NoError(t, opts.writeFile(other, []byte("one")))
+	require.NoError(t, opts.writeFile(other, []byte("two")))
+	assert.Len(t, opts.written, 2)
+}
@@ -0,0 +1,85 @@
+package pipeline
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"time"
+
+	"github.com/sirosfoundation/g119612/pkg/dsig"
+	"github.com/sirosfoundation/g119612/pkg/etsi119612"
+)
+
+// BumpTSL prepares a TSL to be issued again with unchanged content: it
+// increments TSLSequenceNumber, sets ListIssueDateTime to now and NextUpdate
+// to now plus validity, and drops the signature of the previous issue.
+func BumpTSL(tsl *etsi119612.TSL, validity time.Duration, now time.Time) error {
+	if tsl == nil || tsl.StatusList.TslSchemeInformation == nil {
+		return fmt.Errorf("%w: TSL has no scheme information", ErrInvalidArguments)
+	}
+	if validity <= 0 {
+		return fmt.Errorf("%w: next update must be in the future", ErrInvalidArguments)
+	}
+	now = now.UTC().Truncate(time.Second)
+	info := tsl.StatusList.TslSchemeInformation
+	info.TSLSequenceNumber++
+	info.ListIssueDateTime = now.Format(time.RFC3339)
+	info.TslNextUpdate = &etsi119612.NextUpdateType{DateTime: now.Add(validity).Format(time.RFC3339)}
+	tsl.StatusList.DsSignature = nil
+	return nil
+}
+
+// tslNamespace is the namespace of TrustServiceStatusList documents.
+const tslNamespace = "http://uri.etsi.org/02231/v2#"
+
+// ReissueTSL bumps a TSL with BumpTSL, serializes it as a
+// TrustServiceStatusList document and signs it with signer. The signed
+// document is parsed again with options, which verifies the new signature
+// and checks it against the algorithm policy, before it is returned, so a
+// broken signer never produces a list that relying parties would reject.
+func ReissueTSL(tsl *etsi119612.TSL, signer dsig.XMLSigner, validity time.Duration, now time.Time, options etsi119612.TSLFetchOptions) ([]byte, error) {
+	if signer == nil {
+		return nil, fmt.Errorf("%w: a signer is required to reissue a TSL", ErrInvalidArguments)
+	}
+	if err := BumpTSL(tsl, validity, now); err != nil {
+		return nil, err
+	}
+	data, err := marshalTSLList(tsl)
+	if err != nil {
+		return nil, err
+	}
+	signed, err := signer.Sign(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign XML: %w", err)
+	}
+
+	reissued, err := etsi119612.ParseTSL(signed, tsl.Source, options)
+	if err != nil {
+		return nil, fmt.Errorf("reissued TSL does not validate: %w", err)
+	}
+	if !reissued.Signed {
+		return nil, fmt.Errorf("reissued TSL is not signed")
+	}
+	if got, want := sequenceNumber(reissued), sequenceNumber(tsl); got != want {
+		return nil, fmt.Errorf("reissued TSL has sequence number %d, expected %d", got, want)
+	}
+	return signed, nil
+}
+
+// marshalTSLList serializes a TSL as an indented TrustServiceStatusList
+// document in the TSL namespace, with the elements of the status list as
+// children of the root element, so it can be parsed again by ParseTSL.
+func marshalTSLList(tsl *etsi119612.TSL) ([]byte, error) {
+	var out bytes.Buffer
+	out.WriteString(xml.Header)
+	enc := xml.NewEncoder(&out)
+	enc.Indent("", "  ")
+	root := xml.StartElement{Name: xml.Name{Space: tslNamespace, Local: "TrustServiceStatusList"}}
+	if err := enc.EncodeElement(tsl.StatusList, root); err != nil {
+		return nil, fmt.Errorf("failed to marshal TSL to XML: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, fmt.Errorf("failed to marshal TSL to XML: %w", err)
+	}
+	return out.Bytes(), nil
+}
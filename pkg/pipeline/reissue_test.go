@@ -0,0 +1,63 @@
+package pipeline
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirosfoundation/g119612/pkg/dsig"
+	"github.com/sirosfoundation/g119612/pkg/etsi119612"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBumpTSL(t *testing.T) {
+	tsl := generateTSL("Test Service", "http://uri.etsi.org/TrstSvc/Svctype/CA/QC", []string{TestCertBase64})
+	tsl.StatusList.TslSchemeInformation.TSLSequenceNumber = 41
+	now := time.Date(2026, 3, 1, 12, 30, 15, 0, time.FixedZone("CET", 3600))
+
+	require.NoError(t, BumpTSL(tsl, 90*24*time.Hour, now))
+	info := tsl.StatusList.TslSchemeInformation
+	assert.Equal(t, 42, info.TSLSequenceNumber)
+	assert.Equal(t, "2026-03-01T11:30:15Z", info.ListIssueDateTime)
+	require.NotNil(t, info.TslNextUpdate)
+	assert.Equal(t, "2026-05-30T11:30:15Z", info.TslNextUpdate.DateTime)
+
+	assert.ErrorIs(t, BumpTSL(tsl, 0, now), ErrInvalidArguments)
+	assert.ErrorIs(t, BumpTSL(&etsi119612.TSL{}, time.Hour, now), ErrInvalidArguments)
+}
+
+func TestReissueTSL(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	require.NoError(t, generateTestCertAndKey(certFile, keyFile))
+	signer := dsig.NewFileSigner(certFile, keyFile)
+
+	data, err := os.ReadFile("./testdata/test-tsl.xml")
+	require.NoError(t, err)
+	tsl, err := etsi119612.ParseTSL(data, "test-tsl.xml", etsi119612.DefaultTSLFetchOptions)
+	require.NoError(t, err)
+
+	now := time.Now()
+	signed, err := ReissueTSL(tsl, signer, 30*24*time.Hour, now, etsi119612.DefaultTSLFetchOptions)
+	require.NoError(t, err)
+
+	reissued, err := etsi119612.ParseTSL(signed, "reissued.xml", etsi119612.DefaultTSLFetchOptions)
+	require.NoError(t, err)
+	assert.True(t, reissued.Signed)
+	assert.Equal(t, 2, sequenceNumber(reissued))
+	assert.Equal(t, tsl.NumberOfTrustServiceProviders(), reissued.NumberOfTrustServiceProviders())
+	assert.Equal(t, now.UTC().Format(time.RFC3339), reissued.StatusList.TslSchemeInformation.ListIssueDateTime)
+
+	// Reissuing the reissued list signs it again with the next sequence number
+	signed, err = ReissueTSL(reissued, signer, 30*24*time.Hour, now, etsi119612.DefaultTSLFetchOptions)
+	require.NoError(t, err)
+	again, err := etsi119612.ParseTSL(signed, "again.xml", etsi119612.DefaultTSLFetchOptions)
+	require.NoError(t, err)
+	assert.Equal(t, 3, sequenceNumber(again))
+
+	_, err = ReissueTSL(tsl, nil, time.Hour, now, etsi119612.DefaultTSLFetchOptions)
+	assert.ErrorIs(t, err, ErrInvalidArguments)
+}
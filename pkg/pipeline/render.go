@@ -0,0 +1,507 @@
+// Package pipeline provides a pipeline framework for processing Trust Status Lists (TSLs).
+package pipeline
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	_ "embed"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sirosfoundation/g119612/pkg/etsi119612"
+	"github.com/sirosfoundation/g119612/pkg/etsi119612/uri"
+	"github.com/sirosfoundation/g119612/pkg/validation"
+)
+
+//go:embed templates/tsl.html
+var tslHTMLTemplate string
+
+// embeddedRenderTemplates are the templates available as "embedded:name" to the render step.
+var embeddedRenderTemplates = map[string]string{
+	"tsl.html": tslHTMLTemplate,
+}
+
+// RenderData is the data a render template is executed with, once per TSL.
+type RenderData struct {
+	TSL           *etsi119612.TSL // The typed TSL model
+	Index         int             // Position of the TSL among the rendered TSLs
+	Lang          string          // Preferred language of names (see the name function)
+	Languages     []string        // Languages of the names in the TSL, sorted
+	DefaultLang   string          // Language displayed by default, see defaultLanguage
+	GeneratedDate string          // Date the output was rendered, as YYYY-MM-DD
+
+	// Providers are the trust service providers shown on the page: all the
+	// providers of the TSL, or with split-providers none on the page of the
+	// TSL and one on the page of a provider.
+	Providers []*etsi119612.TSPType
+	// ProviderIDs are the stable identifiers of the providers in Providers,
+	// see ProviderID, for use as fragment identifiers.
+	ProviderIDs []string
+	// ProviderPages are, with split-providers, the links from the page of the
+	// TSL to the pages of its providers, in the order of the providers.
+	ProviderPages []string
+	// TSLPage is, on the page of a provider, the link back to the page of the TSL.
+	TSLPage string
+}
+
+// LocalizedName is the text of a name in one language, as returned by the
+// localized template function.
+type LocalizedName struct {
+	Lang string // Language tag
+	Text string // Name in that language, or the fallback if the TSL has none
+}
+
+// RenderTSL renders each TSL in the context with a Go html/template and writes
+// the results to a directory. It is an alternative to the transform step for
+// custom HTML layouts that does not require XSLT or xsltproc: the template works
+// on the typed TSL model (see RenderData) rather than on the XML.
+//
+// Besides the standard template functions the following are available:
+//   - name: Text of an InternationalNamesType in the preferred language, falling back
+//     to English and then to the first name
+//   - localized: Text of an InternationalNamesType in each of a list of languages
+//     (usually .Languages), as LocalizedName values with the same fallback; with
+//     no languages, the name in the preferred language without a language tag
+//   - providers: Trust service providers of a TSL
+//   - services: Trust services of a provider
+//   - certificates: Parsed X.509 certificates of a service
+//   - fingerprint: Hex SHA-256 fingerprint of a certificate
+//   - shortURI: Last path segment of a URI, e.g. "granted" for a service status
+//   - uriLabel: Label of a standard ETSI URI, e.g. "Granted", see uri.Label
+//   - t: Label of the language pack of the preferred languages, e.g.
+//     {{ t "tsl.next-update" }}, see LocaleFor
+//
+// Arguments:
+//   - arg[0]: Path to the template file, or 'embedded:tsl.html' for the built-in layout
+//   - arg[1]: Output directory path
+//   - arg[2]: (Optional) Output file extension (default: "html")
+//   - lang:code (Optional) Preferred languages of names, comma-separated (default:
+//     the languages set with set-language, or "en")
+//   - split-providers[:N] (Optional) Render the providers of TSLs with at least N
+//     providers (default 1) on pages of their own
+//
+// With split-providers, the page of a large TSL only links to the pages of its
+// providers, which are written to a directory named after the page and after
+// the stable identifier of each provider (see ProviderID), e.g.
+// SE-TL/se-example-trust-ab.html next to SE-TL.html, and link back to it. Next
+// to each provider page, a ProviderDocument with the trust status of the
+// provider is written as JSON, e.g. SE-TL/se-example-trust-ab.json. Templates
+// find the providers of a page in .Providers and .ProviderIDs and the links in
+// .ProviderPages and .TSLPage. Provider pages should carry
+// <meta name="tsl-page" content="provider"> so generate_index does not list them.
+//
+// The output directory also gets providers.json (ProvidersIndexFile), listing
+// a ProviderEntry for every provider rendered, so that external systems can
+// look up the current page of a provider by its identifier and deep link to it
+// across regenerations. Without split-providers the page of a provider is the
+// page of its TSL with the identifier as fragment, e.g. SE-TL.html#se-example-trust-ab.
+//
+// The built-in layout renders names in every language of the TSL with a
+// language switcher, displaying the first preferred language the TSL provides,
+// and labels in the first preferred language there is a language pack for.
+//
+// Output files are named like the transform step names them, after the last
+// segment of the first distribution point of each TSL.
+//
+// Example usage in pipeline YAML:
+//
+//   - render:
+//   - /path/to/layout.html.tmpl
+//   - /output/directory
+//   - lang:sv
+func RenderTSL(pl *Pipeline, ctx *Context, args ...string) (*Context, error) {
+	args, langs, err := parseLangOption(args)
+	if err != nil {
+		return ctx, fmt.Errorf("%w: %v", ErrInvalidArguments, err)
+	}
+	args, splitProviders, err := parseSplitProvidersOption(args)
+	if err != nil {
+		return ctx, fmt.Errorf("%w: %v", ErrInvalidArguments, err)
+	}
+	if langs == nil {
+		langs = PreferredLanguages(ctx)
+	}
+	if len(langs) == 0 {
+		langs = []string{"en"}
+	}
+	if len(args) < 2 {
+		return ctx, fmt.Errorf("missing required arguments: need template path and output directory")
+	}
+	outputDir := args[1]
+	extension := "html"
+	if len(args) >= 3 {
+		extension = args[2]
+	}
+
+	tmpl, err := loadRenderTemplate(args[0], langs)
+	if err != nil {
+		return ctx, err
+	}
+	if err := validation.ValidateOutputDirectory(outputDir); err != nil {
+		return ctx, fmt.Errorf("invalid output directory: %w", err)
+	}
+
+	if ctx.TSLTrees == nil || ctx.TSLTrees.IsEmpty() {
+		return ctx, fmt.Errorf("no TSLs to render")
+	}
+	var tsls []*etsi119612.TSL
+	for _, tree := range ctx.TSLTrees.ToSlice() {
+		if tree != nil {
+			tsls = append(tsls, tree.ToSlice()...)
+		}
+	}
+
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return ctx, fmt.Errorf("failed to create output directory %s: %w", outputDir, err)
+	}
+
+	generated := ctx.Now().Format("2006-01-02")
+	entries := []ProviderEntry{}
+	seenIDs := make(map[string]bool)
+	for i, tsl := range tsls {
+		if tsl == nil {
+			continue
+		}
+		languages := tslLanguages(tsl)
+		providers := tslProviders(tsl)
+		ids := providerIDs(tsl, providers, seenIDs)
+		data := RenderData{
+			TSL:           tsl,
+			Index:         i,
+			Lang:          langs[0],
+			Languages:     languages,
+			DefaultLang:   defaultLanguage(langs, languages),
+			GeneratedDate: generated,
+			Providers:     providers,
+			ProviderIDs:   ids,
+		}
+		fileName := tslOutputFileName(tsl, fmt.Sprintf("rendered-tsl-%d", i), extension)
+		if splitProviders > 0 && len(providers) >= splitProviders {
+			if err := renderProviderPages(tmpl, &data, outputDir, fileName, extension); err != nil {
+				return ctx, fmt.Errorf("failed to render the providers of TSL %d (%s): %w", i, tsl.Identity(), err)
+			}
+		}
+		entries = append(entries, providerEntries(&data, providers, ids, data.ProviderPages, fileName)...)
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, data); err != nil {
+			return ctx, fmt.Errorf("failed to render TSL %d (%s): %w", i, tsl.Identity(), err)
+		}
+		filePath := filepath.Join(outputDir, fileName)
+		if err := writeFileAtomic(filePath, buf.Bytes(), DefaultPublishFileMode); err != nil {
+			return ctx, fmt.Errorf("failed to write rendered TSL to file %s: %w", filePath, err)
+		}
+	}
+
+	index, err := marshalProviderJSON(entries)
+	if err != nil {
+		return ctx, fmt.Errorf("failed to encode %s: %w", ProvidersIndexFile, err)
+	}
+	indexPath := filepath.Join(outputDir, ProvidersIndexFile)
+	if err := writeFileAtomic(indexPath, index, DefaultPublishFileMode); err != nil {
+		return ctx, fmt.Errorf("failed to write %s: %w", indexPath, err)
+	}
+	return ctx, nil
+}
+
+// renderProviderPages writes a page and a ProviderDocument for each provider
+// of data.TSL to a directory named after fileName, the page of the TSL, and
+// turns data into the data of that page: no providers, with links to theirs.
+// Pages and documents of providers that are no longer listed are removed.
+func renderProviderPages(tmpl *template.Template, data *RenderData, outputDir, fileName, extension string) error {
+	dirName := strings.TrimSuffix(fileName, filepath.Ext(fileName))
+	dir := filepath.Join(outputDir, dirName)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", dir, err)
+	}
+
+	providers, ids := data.Providers, data.ProviderIDs
+	data.Providers, data.ProviderIDs = nil, nil
+	data.ProviderPages = make([]string, len(providers))
+	written := make(map[string]bool)
+	for i, tsp := range providers {
+		pageName := ids[i] + "." + extension
+		page := *data
+		page.Providers = []*etsi119612.TSPType{tsp}
+		page.ProviderIDs = []string{ids[i]}
+		page.ProviderPages = nil
+		page.TSLPage = "../" + fileName
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, page); err != nil {
+			return fmt.Errorf("provider %s: %w", ids[i], err)
+		}
+		path := filepath.Join(dir, pageName)
+		if err := writeFileAtomic(path, buf.Bytes(), DefaultPublishFileMode); err != nil {
+			return fmt.Errorf("failed to write %s: %w", path, err)
+		}
+		written[pageName] = true
+
+		doc, err := marshalProviderJSON(providerDocument(data.TSL, tsp, ids[i], page.TSLPage))
+		if err != nil {
+			return fmt.Errorf("provider %s: %w", ids[i], err)
+		}
+		docName := ids[i] + ".json"
+		if err := writeFileAtomic(filepath.Join(dir, docName), doc, DefaultPublishFileMode); err != nil {
+			return fmt.Errorf("failed to write %s: %w", filepath.Join(dir, docName), err)
+		}
+		written[docName] = true
+		data.ProviderPages[i] = dirName + "/" + pageName
+	}
+
+	for _, pattern := range []string{"*." + extension, "*.json"} {
+		stale, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			return err
+		}
+		for _, path := range stale {
+			if written[filepath.Base(path)] {
+				continue
+			}
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// tslProviders returns the trust service providers of a TSL.
+func tslProviders(tsl *etsi119612.TSL) []*etsi119612.TSPType {
+	if tsl == nil || tsl.StatusList.TslTrustServiceProviderList == nil {
+		return nil
+	}
+	return tsl.StatusList.TslTrustServiceProviderList.TslTrustServiceProvider
+}
+
+// parseSplitProvidersOption removes the split-providers[:N] option from args.
+// It returns the minimum number of providers of the TSLs to split, 0 if the
+// option is not given.
+func parseSplitProvidersOption(args []string) ([]string, int, error) {
+	split := 0
+	rest := make([]string, 0, len(args))
+	for _, arg := range args {
+		if arg == "split-providers" {
+			split = 1
+			continue
+		}
+		if value, ok := strings.CutPrefix(arg, "split-providers:"); ok {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return nil, 0, fmt.Errorf("invalid split-providers value %q: must be a positive number", value)
+			}
+			split = n
+			continue
+		}
+		rest = append(rest, arg)
+	}
+	return rest, split, nil
+}
+
+// validateRenderArgs is the ArgsValidator of the render step. It parses the
+// template so syntax errors are reported when the pipeline is loaded.
+func validateRenderArgs(args ...string) error {
+	args, langs, err := parseLangOption(args)
+	if err != nil {
+		return err
+	}
+	args, _, err = parseSplitProvidersOption(args)
+	if err != nil {
+		return err
+	}
+	if len(args) < 2 {
+		return fmt.Errorf("missing required arguments: need template path and output directory")
+	}
+	_, err = loadRenderTemplate(args[0], langs)
+	return err
+}
+
+// loadRenderTemplate reads and parses a render template, either from a file or
+// from the embedded templates.
+func loadRenderTemplate(path string, langs []string) (*template.Template, error) {
+	var text string
+	if name, ok := strings.CutPrefix(path, "embedded:"); ok {
+		embedded, found := embeddedRenderTemplates[name]
+		if !found {
+			return nil, fmt.Errorf("embedded template not found: %s", name)
+		}
+		text = embedded
+	} else {
+		if err := validation.ValidateFilePath(path); err != nil {
+			return nil, fmt.Errorf("invalid template path: %w", err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read template: %w", err)
+		}
+		text = string(data)
+	}
+
+	tmpl, err := template.New(filepath.Base(path)).Funcs(renderFuncs(langs)).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// renderFuncs returns the template functions of the render step for the preferred languages.
+func renderFuncs(langs []string) template.FuncMap {
+	locale := LocaleFor(langs...)
+	return template.FuncMap{
+		"t": locale.Message,
+		"name": func(names *etsi119612.InternationalNamesType) string {
+			return preferredName(names, langs...)
+		},
+		"localized": func(languages []string, names *etsi119612.InternationalNamesType) []LocalizedName {
+			if len(languages) == 0 {
+				return []LocalizedName{{Text: preferredName(names, langs...)}}
+			}
+			localized := make([]LocalizedName, 0, len(languages))
+			for _, lang := range languages {
+				text := preferredName(names, append([]string{lang}, langs...)...)
+				localized = append(localized, LocalizedName{Lang: lang, Text: text})
+			}
+			return localized
+		},
+		"providers": tslProviders,
+		"services": func(tsp *etsi119612.TSPType) []*etsi119612.TSPServiceType {
+			if tsp == nil || tsp.TslTSPServices == nil {
+				return nil
+			}
+			return tsp.TslTSPServices.TslTSPService
+		},
+		"certificates": func(svc *etsi119612.TSPServiceType) []*x509.Certificate {
+			var certs []*x509.Certificate
+			if svc == nil || svc.TslServiceInformation == nil {
+				return certs
+			}
+			svc.WithCertificates(func(cert *x509.Certificate) {
+				certs = append(certs, cert)
+			})
+			return certs
+		},
+		"fingerprint": func(cert *x509.Certificate) string {
+			if cert == nil {
+				return ""
+			}
+			digest := sha256.Sum256(cert.Raw)
+			return hex.EncodeToString(digest[:])
+		},
+		"shortURI": func(uri string) string {
+			trimmed := strings.TrimRight(uri, "/")
+			return trimmed[strings.LastIndex(trimmed, "/")+1:]
+		},
+		"uriLabel": uri.Label,
+	}
+}
+
+// preferredName returns the name in the first of the preferred languages it
+// is available in, the English name if there is none, and otherwise the first name.
+func preferredName(names *etsi119612.InternationalNamesType, langs ...string) string {
+	if names == nil {
+		return ""
+	}
+	var first string
+	for _, candidate := range append(append([]string{}, langs...), "en") {
+		for _, n := range names.Name {
+			if n == nil || n.NonEmptyNormalizedString == nil {
+				continue
+			}
+			if first == "" {
+				first = string(*n.NonEmptyNormalizedString)
+			}
+			if n.XmlLangAttr != nil && strings.EqualFold(string(*n.XmlLangAttr), candidate) {
+				return string(*n.NonEmptyNormalizedString)
+			}
+		}
+	}
+	return first
+}
+
+// tslLanguages returns the languages of the scheme, provider and service names
+// of a TSL, lower-cased and sorted.
+func tslLanguages(tsl *etsi119612.TSL) []string {
+	seen := make(map[string]bool)
+	add := func(names *etsi119612.InternationalNamesType) {
+		if names == nil {
+			return
+		}
+		for _, n := range names.Name {
+			if n != nil && n.XmlLangAttr != nil && *n.XmlLangAttr != "" {
+				seen[strings.ToLower(string(*n.XmlLangAttr))] = true
+			}
+		}
+	}
+	if info := tsl.StatusList.TslSchemeInformation; info != nil {
+		add(info.TslSchemeOperatorName)
+		add(info.TslSchemeName)
+	}
+	if tsl.StatusList.TslTrustServiceProviderList != nil {
+		for _, tsp := range tsl.StatusList.TslTrustServiceProviderList.TslTrustServiceProvider {
+			if tsp == nil {
+				continue
+			}
+			if tsp.TslTSPInformation != nil {
+				add(tsp.TslTSPInformation.TSPName)
+				add(tsp.TslTSPInformation.TSPTradeName)
+			}
+			if tsp.TslTSPServices == nil {
+				continue
+			}
+			for _, svc := range tsp.TslTSPServices.TslTSPService {
+				if svc != nil && svc.TslServiceInformation != nil {
+					add(svc.TslServiceInformation.ServiceName)
+				}
+			}
+		}
+	}
+
+	languages := make([]string, 0, len(seen))
+	for lang := range seen {
+		languages = append(languages, lang)
+	}
+	sort.Strings(languages)
+	return languages
+}
+
+// defaultLanguage returns the language a TSL is displayed in by default: the
+// first preferred language among its languages, English if it has none of
+// them, and otherwise its first language. Without any languages it is the
+// most preferred one.
+func defaultLanguage(langs, languages []string) string {
+	for _, candidate := range append(append([]string{}, langs...), "en") {
+		for _, lang := range languages {
+			if strings.EqualFold(lang, candidate) {
+				return lang
+			}
+		}
+	}
+	if len(languages) > 0 {
+		return languages[0]
+	}
+	return langs[0]
+}
+
+// renderOutputs is the OutputsFunc of the render step: the output directory.
+func renderOutputs(args ...string) []string {
+	args, _, err := parseLangOption(args)
+	if err == nil {
+		args, _, err = parseSplitProvidersOption(args)
+	}
+	if err != nil || len(args) < 2 {
+		return nil
+	}
+	return []string{args[1]}
+}
+
+func init() {
+	// Register the RenderTSL function
+	RegisterFunction("render", RenderTSL)
+	RegisterValidator("render", validateRenderArgs)
+	RegisterOutputs("render", renderOutputs)
+}
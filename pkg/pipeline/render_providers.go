@@ -0,0 +1,215 @@
+package pipeline
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirosfoundation/g119612/pkg/etsi119612"
+	"github.com/sirosfoundation/g119612/pkg/etsi119612/jsonmodel"
+)
+
+// ProvidersIndexFile is the name of the lookup of provider pages the render
+// step writes to its output directory.
+const ProvidersIndexFile = "providers.json"
+
+// maxProviderIDLength bounds the length of the identifiers made by ProviderID.
+const maxProviderIDLength = 80
+
+// ProviderEntry is the entry of a trust service provider in ProvidersIndexFile.
+type ProviderEntry struct {
+	ID        string `json:"id"`             // Stable identifier, see ProviderID
+	Name      string `json:"name"`           // English name of the provider, or its first name
+	Territory string `json:"territory"`      // Scheme territory of the TSL listing the provider
+	TSL       string `json:"tsl"`            // Page of the TSL, relative to the output directory
+	Page      string `json:"page"`           // Page of the provider, relative to the output directory
+	JSON      string `json:"json,omitempty"` // ProviderDocument of the provider, with split-providers
+}
+
+// ProviderDocument is the structured trust status of a provider, written by
+// the render step with split-providers as <id>.json next to its page. The
+// summary is that of the TSL listing the provider, without its source.
+type ProviderDocument struct {
+	ID      string `json:"id"`
+	TSLPage string `json:"tsl_page"` // Page of the TSL, relative to the document
+	jsonmodel.Summary
+	etsi119612.TSPDetails
+	Services []ProviderService `json:"services"`
+}
+
+// ProviderService is a trust service in a ProviderDocument.
+type ProviderService struct {
+	Name               string   `json:"name"`
+	ServiceType        string   `json:"service_type"`
+	Status             string   `json:"status"`
+	StatusStartingTime string   `json:"status_starting_time,omitempty"`
+	Certificates       []string `json:"certificates,omitempty"` // Hex SHA-256 fingerprints
+}
+
+// ProviderID returns an identifier of a trust service provider that stays the
+// same when the TSL listing it is regenerated, for use in URLs: the lower-case
+// territory followed by the English name of the provider, or its first name,
+// reduced to letters, digits and hyphens, e.g. "se-example-trust-ab". Accents
+// of Latin letters are dropped. A name without any Latin letter or digit is
+// replaced by the host and path of the first TSPInformationURI.
+//
+// Identifiers are not guaranteed to be unique; the render step appends "-2",
+// "-3" and so on to repeated ones in the order of the providers.
+func ProviderID(territory string, tsp *etsi119612.TSPType) string {
+	var name string
+	var uris []etsi119612.LangString
+	if tsp != nil && tsp.TslTSPInformation != nil {
+		name = preferredName(tsp.TslTSPInformation.TSPName, "en")
+		uris = tsp.InformationURIs()
+	}
+	id := idSlug(name)
+	if id == "" && len(uris) > 0 {
+		uri := uris[0].Value
+		if _, rest, ok := strings.Cut(uri, "://"); ok {
+			uri = rest
+		}
+		id = idSlug(uri)
+	}
+	if id == "" {
+		id = "provider"
+	}
+	if prefix := idSlug(territory); prefix != "" {
+		id = prefix + "-" + id
+	}
+	if len(id) > maxProviderIDLength {
+		id = strings.TrimRight(id[:maxProviderIDLength], "-")
+	}
+	return id
+}
+
+// providerIDs returns the identifiers of the providers of a TSL, making them
+// unique among those already in seen.
+func providerIDs(tsl *etsi119612.TSL, providers []*etsi119612.TSPType, seen map[string]bool) []string {
+	territory := ""
+	if info := tsl.StatusList.TslSchemeInformation; info != nil {
+		territory = info.TslSchemeTerritory
+	}
+	ids := make([]string, len(providers))
+	for i, tsp := range providers {
+		id := ProviderID(territory, tsp)
+		for n := 2; seen[id]; n++ {
+			id = fmt.Sprintf("%s-%d", ProviderID(territory, tsp), n)
+		}
+		seen[id] = true
+		ids[i] = id
+	}
+	return ids
+}
+
+// latinFolds maps accented and ligature Latin letters to ASCII for idSlug.
+var latinFolds = func() map[rune]string {
+	folds := make(map[rune]string)
+	for ascii, letters := range map[string]string{
+		"a": "àáâãäåāăą", "ae": "æ", "c": "çćĉċč", "d": "ďđð", "e": "èéêëēĕėęě",
+		"g": "ĝğġģ", "h": "ĥħ", "i": "ìíîïĩīĭįı", "j": "ĵ", "k": "ķ", "l": "ĺļľŀł",
+		"n": "ñńņňŉ", "o": "òóôõöøōŏő", "oe": "œ", "r": "ŕŗř", "s": "śŝşšș", "ss": "ß",
+		"t": "ţťŧț", "th": "þ", "u": "ùúûüũūŭůűų", "w": "ŵ", "y": "ýÿŷ", "z": "źżž",
+	} {
+		for _, r := range letters {
+			folds[r] = ascii
+		}
+	}
+	return folds
+}()
+
+// idSlug reduces s to lower-case ASCII letters and digits separated by single hyphens.
+func idSlug(s string) string {
+	var b strings.Builder
+	gap := false
+	for _, r := range strings.ToLower(s) {
+		text := string(r)
+		if fold, ok := latinFolds[r]; ok {
+			text = fold
+		} else if (r < 'a' || r > 'z') && (r < '0' || r > '9') {
+			gap = true
+			continue
+		}
+		if gap && b.Len() > 0 {
+			b.WriteByte('-')
+		}
+		gap = false
+		b.WriteString(text)
+	}
+	return b.String()
+}
+
+// providerDocument returns the ProviderDocument of a provider of tsl.
+func providerDocument(tsl *etsi119612.TSL, tsp *etsi119612.TSPType, id, tslPage string) ProviderDocument {
+	doc := ProviderDocument{
+		ID:         id,
+		TSLPage:    tslPage,
+		Summary:    tsl.JSONSummary(),
+		TSPDetails: tsp.Details(),
+		Services:   []ProviderService{},
+	}
+	doc.Source = ""
+	if tsp.TslTSPServices == nil {
+		return doc
+	}
+	for _, svc := range tsp.TslTSPServices.TslTSPService {
+		if svc == nil || svc.TslServiceInformation == nil {
+			continue
+		}
+		info := svc.TslServiceInformation
+		service := ProviderService{
+			Name:               preferredName(info.ServiceName, "en"),
+			ServiceType:        info.TslServiceTypeIdentifier,
+			Status:             info.TslServiceStatus,
+			StatusStartingTime: info.StatusStartingTime,
+		}
+		svc.WithCertificates(func(cert *x509.Certificate) {
+			digest := sha256.Sum256(cert.Raw)
+			service.Certificates = append(service.Certificates, hex.EncodeToString(digest[:]))
+		})
+		doc.Services = append(doc.Services, service)
+	}
+	return doc
+}
+
+// providerEntries returns the ProvidersIndexFile entries of the providers of
+// data.TSL, whose page is fileName. With pages, the pages of the providers
+// written by renderProviderPages, the entries point to those pages and to
+// their documents, and otherwise to the providers on the page of the TSL.
+func providerEntries(data *RenderData, providers []*etsi119612.TSPType, ids, pages []string, fileName string) []ProviderEntry {
+	territory := ""
+	if info := data.TSL.StatusList.TslSchemeInformation; info != nil {
+		territory = info.TslSchemeTerritory
+	}
+	entries := make([]ProviderEntry, len(providers))
+	for i, tsp := range providers {
+		entry := ProviderEntry{
+			ID:        ids[i],
+			Territory: territory,
+			TSL:       fileName,
+			Page:      fileName + "#" + ids[i],
+		}
+		if tsp.TslTSPInformation != nil {
+			entry.Name = preferredName(tsp.TslTSPInformation.TSPName, "en")
+		}
+		if pages != nil {
+			entry.Page = pages[i]
+			entry.JSON = strings.TrimSuffix(pages[i], filepath.Ext(pages[i])) + ".json"
+		}
+		entries[i] = entry
+	}
+	return entries
+}
+
+// marshalProviderJSON encodes a ProviderDocument or the ProvidersIndexFile
+// entries as indented JSON.
+func marshalProviderJSON(v any) ([]byte, error) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
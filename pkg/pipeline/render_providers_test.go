@@ -0,0 +1,88 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirosfoundation/g119612/pkg/etsi119612"
+	"github.com/sirosfoundation/g119612/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// namedTSP returns a provider with the given names, in the language of the
+// key, and information URI.
+func namedTSP(names map[string]string, infoURI string) *etsi119612.TSPType {
+	info := &etsi119612.TSPInformationType{TSPName: &etsi119612.InternationalNamesType{}}
+	for lang, name := range names {
+		l, n := etsi119612.Lang(lang), etsi119612.NonEmptyNormalizedString(name)
+		info.TSPName.Name = append(info.TSPName.Name, &etsi119612.MultiLangNormStringType{XmlLangAttr: &l, NonEmptyNormalizedString: &n})
+	}
+	if infoURI != "" {
+		info.TSPInformationURI = &etsi119612.NonEmptyMultiLangURIListType{URI: []*etsi119612.NonEmptyMultiLangURIType{{Value: infoURI}}}
+	}
+	return &etsi119612.TSPType{TslTSPInformation: info}
+}
+
+func TestProviderID(t *testing.T) {
+	tests := []struct {
+		territory string
+		tsp       *etsi119612.TSPType
+		want      string
+	}{
+		{"SE", namedTSP(map[string]string{"en": "Example Trust AB"}, ""), "se-example-trust-ab"},
+		{"FR", namedTSP(map[string]string{"fr": "Société Générale de Confiance (SGC)"}, ""), "fr-societe-generale-de-confiance-sgc"},
+		{"DE", namedTSP(map[string]string{"de": "Straße GmbH", "en": "Strasse Ltd."}, ""), "de-strasse-ltd"},
+		{"BG", namedTSP(map[string]string{"bg": "Информационно обслужване"}, "https://www.is-bg.net/en/qualified-services/"), "bg-www-is-bg-net-en-qualified-services"},
+		{"EL", namedTSP(nil, ""), "el-provider"},
+		{"", namedTSP(map[string]string{"en": "  Example  "}, ""), "example"},
+		{"PL", nil, "pl-provider"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			assert.Equal(t, tt.want, ProviderID(tt.territory, tt.tsp))
+		})
+	}
+
+	long := ProviderID("IT", namedTSP(map[string]string{"en": "A very long name of a qualified trust service provider, with a registered office in Rome, Italy"}, ""))
+	assert.LessOrEqual(t, len(long), maxProviderIDLength)
+	assert.NotEqual(t, '-', long[len(long)-1])
+}
+
+func TestRenderTSL_ProvidersIndex(t *testing.T) {
+	pl := &Pipeline{Logger: logging.SilentLogger()}
+	tsl := generateTSL("Test Service", "http://uri.etsi.org/TrstSvc/Svctype/CA/QC", []string{TestCertBase64})
+	tsl.StatusList.TslSchemeInformation.TslSchemeTerritory = "SE"
+	tsl.StatusList.TslSchemeInformation.TslDistributionPoints = &etsi119612.NonEmptyURIListType{URI: []string{"https://example.com/SE-TL.xml"}}
+	providers := tsl.StatusList.TslTrustServiceProviderList
+	// Providers of the same name get numbered identifiers in document order
+	providers.TslTrustServiceProvider = append(providers.TslTrustServiceProvider, namedTSP(map[string]string{"en": "Test Provider"}, ""))
+	ctx := NewContext()
+	ctx.AddTSL(tsl)
+	outDir := t.TempDir()
+
+	_, err := RenderTSL(pl, ctx, "embedded:tsl.html", outDir)
+	require.NoError(t, err)
+	page, err := os.ReadFile(filepath.Join(outDir, "SE-TL.html"))
+	require.NoError(t, err)
+	assert.Contains(t, string(page), `<article id="se-test-provider">`)
+	assert.Contains(t, string(page), `<article id="se-test-provider-2">`)
+
+	var entries []ProviderEntry
+	data, err := os.ReadFile(filepath.Join(outDir, ProvidersIndexFile))
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &entries))
+	assert.Equal(t, []ProviderEntry{
+		{ID: "se-test-provider", Name: "Test Provider", Territory: "SE", TSL: "SE-TL.html", Page: "SE-TL.html#se-test-provider"},
+		{ID: "se-test-provider-2", Name: "Test Provider", Territory: "SE", TSL: "SE-TL.html", Page: "SE-TL.html#se-test-provider-2"},
+	}, entries)
+
+	// Identifiers are the same when the TSL is rendered again
+	_, err = RenderTSL(pl, ctx, "embedded:tsl.html", outDir)
+	require.NoError(t, err)
+	again, err := os.ReadFile(filepath.Join(outDir, ProvidersIndexFile))
+	require.NoError(t, err)
+	assert.Equal(t, data, again)
+}
@@ -0,0 +1,257 @@
+package pipeline
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sirosfoundation/g119612/pkg/etsi119612"
+	"github.com/sirosfoundation/g119612/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderTSL(t *testing.T) {
+	pl := &Pipeline{Logger: logging.SilentLogger()}
+	ctx := NewContext()
+	tsl := generateTSL("Test Service", "http://uri.etsi.org/TrstSvc/Svctype/CA/QC", []string{TestCertBase64})
+	tsl.StatusList.TslSchemeInformation.TslSchemeTerritory = "SE"
+	tsl.StatusList.TslSchemeInformation.TslDistributionPoints = &etsi119612.NonEmptyURIListType{URI: []string{"https://example.com/SE-TL.xml"}}
+	swedish := etsi119612.Lang("sv")
+	name := etsi119612.NonEmptyNormalizedString("Testoperatör")
+	names := tsl.StatusList.TslSchemeInformation.TslSchemeOperatorName
+	names.Name = append(names.Name, &etsi119612.MultiLangNormStringType{XmlLangAttr: &swedish, NonEmptyNormalizedString: &name})
+	tradeName := etsi119612.NonEmptyNormalizedString("VATSE-5560000000")
+	tspInfo := tsl.StatusList.TslTrustServiceProviderList.TslTrustServiceProvider[0].TslTSPInformation
+	tspInfo.TSPTradeName = &etsi119612.InternationalNamesType{Name: []*etsi119612.MultiLangNormStringType{{XmlLangAttr: &swedish, NonEmptyNormalizedString: &tradeName}}}
+	tspInfo.TSPAddress = &etsi119612.AddressType{
+		TslPostalAddresses: &etsi119612.PostalAddressListType{TslPostalAddress: []*etsi119612.PostalAddressType{
+			{XmlLangAttr: &swedish, StreetAddress: "Gatan 1", Locality: "Stockholm", PostalCode: "111 22", CountryName: "SE"},
+		}},
+		TslElectronicAddress: &etsi119612.ElectronicAddressType{URI: []*etsi119612.NonEmptyMultiLangURIType{{Value: "mailto:incident@example.com"}}},
+	}
+	tspInfo.TSPInformationURI = &etsi119612.NonEmptyMultiLangURIListType{URI: []*etsi119612.NonEmptyMultiLangURIType{{Value: "https://tsp.example.com/cps"}}}
+	english := etsi119612.Lang("en")
+	notice := etsi119612.NonEmptyString("The applicable legal framework is Regulation (EU) No 910/2014.")
+	tsl.StatusList.TslSchemeInformation.SchemeOperatorAddress = &etsi119612.AddressType{
+		TslElectronicAddress: &etsi119612.ElectronicAddressType{URI: []*etsi119612.NonEmptyMultiLangURIType{{Value: "mailto:operator@example.com"}}},
+	}
+	tsl.StatusList.TslSchemeInformation.TslPolicyOrLegalNotice = &etsi119612.PolicyOrLegalnoticeType{
+		TSLLegalNotice: []*etsi119612.MultiLangStringType{{XmlLangAttr: &english, NonEmptyString: &notice}},
+	}
+	ctx.AddTSL(tsl)
+
+	dir := t.TempDir()
+	tmplFile := filepath.Join(dir, "layout.tmpl")
+	require.NoError(t, os.WriteFile(tmplFile, []byte(
+		`{{ name .TSL.StatusList.TslSchemeInformation.TslSchemeOperatorName }}|`+
+			`{{ range providers .TSL }}{{ name .TslTSPInformation.TSPName }}|`+
+			`{{ range services . }}{{ shortURI .TslServiceInformation.TslServiceStatus }}|`+
+			`{{ range certificates . }}{{ fingerprint . }}{{ end }}{{ end }}{{ end }}|<b>{{ .Lang }}</b>`), 0600))
+
+	outDir := filepath.Join(dir, "out")
+	_, err := RenderTSL(pl, ctx, tmplFile, outDir, "txt", "lang:sv")
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(filepath.Join(outDir, "SE-TL.txt"))
+	require.NoError(t, err)
+	digest := sha256.Sum256(TestCert.Raw)
+	assert.Equal(t, "Testoperatör|Test Provider|granted|"+hex.EncodeToString(digest[:])+"|<b>sv</b>", string(data))
+
+	// Names fall back to English
+	_, err = RenderTSL(pl, ctx, tmplFile, outDir, "txt", "lang:fi")
+	require.NoError(t, err)
+	data, err = os.ReadFile(filepath.Join(outDir, "SE-TL.txt"))
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(data), "Test Operator|"), string(data))
+
+	// The built-in layout
+	_, err = RenderTSL(pl, ctx, "embedded:tsl.html", outDir)
+	require.NoError(t, err)
+	data, err = os.ReadFile(filepath.Join(outDir, "SE-TL.html"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "Test Operator")
+	assert.Contains(t, string(data), "Test Service")
+	assert.Contains(t, string(data), `<abbr title="http://uri.etsi.org/TrstSvc/Svctype/CA/QC">CA issuing qualified certificates</abbr>`)
+	assert.Contains(t, string(data), hex.EncodeToString(digest[:]))
+	assert.Contains(t, string(data), `Trade Name: <span lang="sv">VATSE-5560000000</span>`)
+	assert.Contains(t, string(data), `<address lang="sv">Gatan 1, 111 22 Stockholm, SE</address>`)
+	assert.Contains(t, string(data), `<a href="mailto:incident@example.com">mailto:incident@example.com</a>`)
+	assert.Contains(t, string(data), `<a href="https://tsp.example.com/cps">https://tsp.example.com/cps</a>`)
+	assert.Contains(t, string(data), `<a href="mailto:operator@example.com">mailto:operator@example.com</a>`)
+	assert.Contains(t, string(data), `<p lang="en">The applicable legal framework is Regulation (EU) No 910/2014.</p>`)
+}
+
+func TestRenderTSL_Languages(t *testing.T) {
+	pl := &Pipeline{Logger: logging.SilentLogger()}
+	tsl := generateTSL("Test Service", "http://uri.etsi.org/TrstSvc/Svctype/CA/QC", nil)
+	tsl.StatusList.TslSchemeInformation.TslDistributionPoints = &etsi119612.NonEmptyURIListType{URI: []string{"https://example.com/SE-TL.xml"}}
+	swedish := etsi119612.Lang("SV")
+	name := etsi119612.NonEmptyNormalizedString("Testoperatör")
+	names := tsl.StatusList.TslSchemeInformation.TslSchemeOperatorName
+	names.Name = append(names.Name, &etsi119612.MultiLangNormStringType{XmlLangAttr: &swedish, NonEmptyNormalizedString: &name})
+	assert.Equal(t, []string{"en", "sv"}, tslLanguages(tsl))
+
+	render := func(ctx *Context, args ...string) string {
+		t.Helper()
+		ctx.AddTSL(tsl)
+		outDir := t.TempDir()
+		_, err := RenderTSL(pl, ctx, append([]string{"embedded:tsl.html", outDir}, args...)...)
+		require.NoError(t, err)
+		data, err := os.ReadFile(filepath.Join(outDir, "SE-TL.html"))
+		require.NoError(t, err)
+		return string(data)
+	}
+
+	// All languages are rendered, with the pipeline's preference displayed
+	ctx, err := SetLanguage(pl, NewContext(), "fi", "sv")
+	require.NoError(t, err)
+	html := render(ctx)
+	assert.Contains(t, html, `<html lang="sv" data-lang="sv"`)
+	assert.Contains(t, html, `<span lang="en" data-l10n>Test Operator</span><span lang="sv" data-l10n>Testoperatör</span>`)
+	assert.Contains(t, html, `data-switch-lang="sv" aria-current="true"`)
+	// Names missing in a language fall back to the preference
+	assert.Contains(t, html, `<span lang="sv" data-l10n>Test Provider</span>`)
+
+	// The lang option overrides the pipeline's preference
+	html = render(ctx, "lang:en")
+	assert.Contains(t, html, `<html lang="en" data-lang="en"`)
+
+	// English is the default
+	assert.Contains(t, render(NewContext()), `<html lang="en" data-lang="en"`)
+
+	// Labels follow the preference as well
+	assert.Contains(t, render(ctx), "Löpnummer: ")
+	html = render(NewContext(), "lang:de,en")
+	assert.Contains(t, html, "Hoheitsgebiet: ")
+	assert.Contains(t, html, "Laufende Nummer: ")
+
+	_, err = RenderTSL(pl, NewContext(), "embedded:tsl.html", t.TempDir(), "lang:swedish")
+	assert.ErrorIs(t, err, ErrInvalidArguments)
+}
+
+func TestRenderTSL_SplitProviders(t *testing.T) {
+	pl := &Pipeline{Logger: logging.SilentLogger()}
+	tsl := generateTSL("Test Service", "http://uri.etsi.org/TrstSvc/Svctype/CA/QC", []string{TestCertBase64})
+	tsl.StatusList.TslSchemeInformation.TslSchemeTerritory = "SE"
+	tsl.StatusList.TslSchemeInformation.TslDistributionPoints = &etsi119612.NonEmptyURIListType{URI: []string{"https://example.com/SE-TL.xml"}}
+	providers := tsl.StatusList.TslTrustServiceProviderList
+	second := generateTSL("Other Service", "http://uri.etsi.org/TrstSvc/Svctype/TSA/QTST", nil)
+	otherName := etsi119612.NonEmptyNormalizedString("Other Provider")
+	second.StatusList.TslTrustServiceProviderList.TslTrustServiceProvider[0].TslTSPInformation.TSPName.Name[0].NonEmptyNormalizedString = &otherName
+	providers.TslTrustServiceProvider = append(providers.TslTrustServiceProvider, second.StatusList.TslTrustServiceProviderList.TslTrustServiceProvider...)
+	ctx := NewContext()
+	ctx.AddTSL(tsl)
+	outDir := t.TempDir()
+
+	// Nothing is split below the threshold
+	_, err := RenderTSL(pl, ctx, "embedded:tsl.html", outDir, "split-providers:3")
+	require.NoError(t, err)
+	assert.NoDirExists(t, filepath.Join(outDir, "SE-TL"))
+
+	_, err = RenderTSL(pl, ctx, "embedded:tsl.html", outDir, "split-providers:2")
+	require.NoError(t, err)
+	page, err := os.ReadFile(filepath.Join(outDir, "SE-TL.html"))
+	require.NoError(t, err)
+	assert.Contains(t, string(page), `<a href="SE-TL/se-test-provider.html">`)
+	assert.Contains(t, string(page), `<a href="SE-TL/se-other-provider.html">`)
+	assert.NotContains(t, string(page), "service-card", "services are on the provider pages")
+
+	first, err := os.ReadFile(filepath.Join(outDir, "SE-TL", "se-test-provider.html"))
+	require.NoError(t, err)
+	assert.Contains(t, string(first), `<meta name="tsl-page" content="provider">`)
+	assert.Contains(t, string(first), `<a href="../SE-TL.html">`)
+	assert.Contains(t, string(first), `<article id="se-test-provider">`)
+	assert.Contains(t, string(first), "Test Service")
+	assert.NotContains(t, string(first), "Other Service")
+	secondPage, err := os.ReadFile(filepath.Join(outDir, "SE-TL", "se-other-provider.html"))
+	require.NoError(t, err)
+	assert.Contains(t, string(secondPage), "Other Service")
+
+	// Each provider page has the trust status of the provider as JSON next to it
+	var doc ProviderDocument
+	data, err := os.ReadFile(filepath.Join(outDir, "SE-TL", "se-test-provider.json"))
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &doc))
+	assert.Equal(t, "se-test-provider", doc.ID)
+	assert.Equal(t, "SE", doc.Territory)
+	assert.Equal(t, "../SE-TL.html", doc.TSLPage)
+	assert.Equal(t, "Test Provider", doc.Name)
+	require.Len(t, doc.Services, 1)
+	assert.Equal(t, "Test Service", doc.Services[0].Name)
+	assert.Equal(t, "http://uri.etsi.org/TrstSvc/Svctype/CA/QC", doc.Services[0].ServiceType)
+	assert.Len(t, doc.Services[0].Certificates, 1)
+
+	var entries []ProviderEntry
+	data, err = os.ReadFile(filepath.Join(outDir, ProvidersIndexFile))
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &entries))
+	assert.Equal(t, []ProviderEntry{
+		{ID: "se-test-provider", Name: "Test Provider", Territory: "SE", TSL: "SE-TL.html", Page: "SE-TL/se-test-provider.html", JSON: "SE-TL/se-test-provider.json"},
+		{ID: "se-other-provider", Name: "Other Provider", Territory: "SE", TSL: "SE-TL.html", Page: "SE-TL/se-other-provider.html", JSON: "SE-TL/se-other-provider.json"},
+	}, entries)
+
+	// The index lists the TSL with all its services, but not the provider pages
+	indexEntries, err := findTSLHtmlFiles(outDir)
+	require.NoError(t, err)
+	require.Len(t, indexEntries, 1)
+	assert.Equal(t, "SE-TL.html", indexEntries[0].URL)
+	assert.Equal(t, 2, indexEntries[0].TrustService)
+
+	// Pages of providers that are gone are removed
+	providers.TslTrustServiceProvider = providers.TslTrustServiceProvider[:1]
+	_, err = RenderTSL(pl, ctx, "embedded:tsl.html", outDir, "split-providers")
+	require.NoError(t, err)
+	assert.FileExists(t, filepath.Join(outDir, "SE-TL", "se-test-provider.html"))
+	assert.NoFileExists(t, filepath.Join(outDir, "SE-TL", "se-other-provider.html"))
+	assert.NoFileExists(t, filepath.Join(outDir, "SE-TL", "se-other-provider.json"))
+
+	_, err = RenderTSL(pl, ctx, "embedded:tsl.html", outDir, "split-providers:0")
+	assert.ErrorIs(t, err, ErrInvalidArguments)
+}
+
+func TestDefaultLanguage(t *testing.T) {
+	tests := []struct {
+		name      string
+		langs     []string
+		languages []string
+		want      string
+	}{
+		{"Preferred", []string{"fi", "sv"}, []string{"en", "sv"}, "sv"},
+		{"English", []string{"fi"}, []string{"de", "en"}, "en"},
+		{"First", []string{"fi"}, []string{"de", "fr"}, "de"},
+		{"No_Languages", []string{"fi"}, nil, "fi"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, defaultLanguage(tt.langs, tt.languages))
+		})
+	}
+}
+
+func TestRenderTSL_Errors(t *testing.T) {
+	pl := &Pipeline{Logger: logging.SilentLogger()}
+	dir := t.TempDir()
+	broken := filepath.Join(dir, "broken.tmpl")
+	require.NoError(t, os.WriteFile(broken, []byte("{{ range }"), 0600))
+
+	_, err := RenderTSL(pl, NewContext(), "embedded:tsl.html")
+	assert.ErrorContains(t, err, "missing required arguments")
+	_, err = RenderTSL(pl, NewContext(), "embedded:missing.html", dir)
+	assert.ErrorContains(t, err, "embedded template not found")
+	_, err = RenderTSL(pl, NewContext(), broken, dir)
+	assert.ErrorContains(t, err, "failed to parse template")
+	_, err = RenderTSL(pl, NewContext(), "embedded:tsl.html", dir)
+	assert.ErrorContains(t, err, "no TSLs to render")
+
+	// Template errors are reported when the pipeline is loaded
+	file := filepath.Join(dir, "pipeline.yaml")
+	require.NoError(t, os.WriteFile(file, []byte("- render:\n    - "+broken+"\n    - "+dir+"\n"), 0600))
+	_, err = NewPipeline(file)
+	assert.ErrorIs(t, err, ErrInvalidArguments)
+	assert.ErrorContains(t, err, "step 0 (render)")
+}
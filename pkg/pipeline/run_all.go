@@ -0,0 +1,126 @@
+package pipeline
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirosfoundation/g119612/pkg/etsi119612"
+	"github.com/sirosfoundation/g119612/pkg/logging"
+)
+
+// RunResult is the outcome of processing one pipeline file with RunAll.
+type RunResult struct {
+	File     string        // Path of the pipeline file
+	Duration time.Duration // Time spent loading and processing the pipeline
+	TSLCount int           // Number of TSLs in the final context
+	Err      error         // Error loading or processing the pipeline, nil on success
+
+	// Fetches describes how the TSLs of the final context were fetched
+	Fetches []etsi119612.FetchInfo
+}
+
+// DiscoverPipelines returns the pipeline files (*.yaml and *.yml) in dir,
+// sorted by name. Subdirectories are not searched.
+//
+// Parameters:
+//   - dir: The directory to search
+//
+// Returns:
+//   - []string: The paths of the pipeline files
+//   - error: Non-nil if the directory cannot be read
+func DiscoverPipelines(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pipeline directory %s: %w", dir, err)
+	}
+	var files []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		switch strings.ToLower(filepath.Ext(entry.Name())) {
+		case ".yaml", ".yml":
+			files = append(files, filepath.Join(dir, entry.Name()))
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// RunAll processes the given pipeline files concurrently. Every pipeline runs
+// with its own Pipeline and Context, so state such as loaded TSLs and fetch
+// options is never shared between runs. Log entries carry a "pipeline" field
+// naming the file they belong to.
+//
+// Parameters:
+//   - files: The pipeline files to process
+//   - concurrency: The maximum number of pipelines processed at once; values below 1 mean 1
+//   - logger: The logger for all runs, nil for the default logger
+//
+// Returns:
+//   - []RunResult: One result per file, in the order of files
+func RunAll(files []string, concurrency int, logger logging.Logger) []RunResult {
+	return RunAllWith(files, concurrency, logger, NewPipeline)
+}
+
+// RunAllWith is RunAll with a custom function loading the pipeline files, for
+// example one verifying their signatures with NewSignedPipeline. A file that
+// load rejects fails like a pipeline that cannot be parsed.
+func RunAllWith(files []string, concurrency int, logger logging.Logger, load func(file string) (*Pipeline, error)) []RunResult {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	if logger == nil {
+		logger = logging.DefaultLogger()
+	}
+
+	results := make([]RunResult, len(files))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, file := range files {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = runPipelineFile(file, load, logger.WithField("pipeline", file))
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// runPipelineFile loads and processes a single pipeline file for RunAll.
+func runPipelineFile(file string, load func(string) (*Pipeline, error), logger logging.Logger) (result RunResult) {
+	result.File = file
+	started := time.Now()
+	defer func() {
+		if r := recover(); r != nil {
+			result.Err = fmt.Errorf("pipeline panicked: %v", r)
+		}
+		result.Duration = time.Since(started)
+	}()
+
+	pl, err := load(file)
+	if err != nil {
+		result.Err = fmt.Errorf("failed to load pipeline: %w", err)
+		return result
+	}
+	pl = pl.WithLogger(logger)
+
+	ctx, err := pl.Process(NewContext())
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	if ctx != nil && ctx.TSLs != nil {
+		result.TSLCount = ctx.TSLs.Size()
+		result.Fetches = ctx.FetchInfos()
+	}
+	return result
+}
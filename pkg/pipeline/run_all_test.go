@@ -0,0 +1,100 @@
+package pipeline
+
+import (
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirosfoundation/g119612/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiscoverPipelines(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"b.yaml", "a.yml", "c.YAML", "notes.txt"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("- echo: []"), 0644))
+	}
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "sub.yaml"), 0755))
+
+	files, err := DiscoverPipelines(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(dir, "a.yml"),
+		filepath.Join(dir, "b.yaml"),
+		filepath.Join(dir, "c.YAML"),
+	}, files)
+
+	_, err = DiscoverPipelines(filepath.Join(dir, "missing"))
+	assert.Error(t, err)
+}
+
+func TestRunAll(t *testing.T) {
+	tslPath, err := filepath.Abs("./testdata/test-tsl.xml")
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	pipelines := map[string]string{
+		"good-1.yaml":  "- load: [\"" + tslPath + "\"]\n",
+		"good-2.yaml":  "- load: [\"" + tslPath + "\"]\n- echo: []\n",
+		"unknown.yaml": "- no-such-step: []\n",
+		"broken.yaml":  "not: [a pipeline\n",
+	}
+	for name, content := range pipelines {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+	files, err := DiscoverPipelines(dir)
+	require.NoError(t, err)
+
+	results := RunAll(files, 2, logging.SilentLogger())
+	require.Len(t, results, 4)
+	byName := make(map[string]RunResult)
+	for i, result := range results {
+		assert.Equal(t, files[i], result.File)
+		byName[filepath.Base(result.File)] = result
+	}
+
+	assert.NoError(t, byName["good-1.yaml"].Err)
+	assert.Equal(t, 1, byName["good-1.yaml"].TSLCount)
+	require.Len(t, byName["good-1.yaml"].Fetches, 1)
+	assert.Equal(t, "file://"+tslPath, byName["good-1.yaml"].Fetches[0].URL)
+	assert.Positive(t, byName["good-1.yaml"].Fetches[0].Size)
+	assert.NoError(t, byName["good-2.yaml"].Err)
+	assert.ErrorContains(t, byName["unknown.yaml"].Err, "unknown methodName")
+	assert.ErrorContains(t, byName["broken.yaml"].Err, "failed to load pipeline")
+}
+
+func TestRunAll_Concurrency(t *testing.T) {
+	var running, peak atomic.Int32
+	RegisterFunction("run-all-test-sleep", func(pl *Pipeline, ctx *Context, args ...string) (*Context, error) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		return ctx, nil
+	})
+
+	dir := t.TempDir()
+	var files []string
+	for _, name := range []string{"1.yaml", "2.yaml", "3.yaml", "4.yaml", "5.yaml", "6.yaml"} {
+		file := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(file, []byte("- run-all-test-sleep: []\n"), 0644))
+		files = append(files, file)
+	}
+
+	for _, result := range RunAll(files, 2, logging.SilentLogger()) {
+		assert.NoError(t, result.Err)
+	}
+	assert.LessOrEqual(t, peak.Load(), int32(2))
+
+	peak.Store(0)
+	RunAll(files, 0, logging.SilentLogger())
+	assert.Equal(t, int32(1), peak.Load())
+}
@@ -0,0 +1,133 @@
+package pipeline
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/sirosfoundation/g119612/pkg/etsi119612"
+)
+
+// selectCacheVersion is mixed into cache keys so that a change of the cache
+// format or of the selection semantics invalidates existing entries.
+const selectCacheVersion = "select-cache-v2"
+
+// selectCacheKey returns the cache key for selecting certificates from the TSLs
+// in ctx with opts. The key covers the content of every loaded TSL and the
+// selection policy, so it changes whenever either of them changes.
+func selectCacheKey(ctx *Context, opts SelectOptions) (string, error) {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n", selectCacheVersion)
+
+	serviceTypes := slices.Sorted(slices.Values(opts.ServiceTypes))
+	statuses := slices.Sorted(slices.Values(opts.Statuses))
+	fmt.Fprintf(h, "reference-depth=%d\nservice-types=%s\nstatuses=%s\nmatch-all-statuses=%t\n",
+		opts.ReferenceDepth, strings.Join(serviceTypes, " "), strings.Join(statuses, " "), opts.MatchAllStatuses)
+	if opts.RequireCA || len(opts.RequireEKU) > 0 {
+		requireEKU := slices.Sorted(slices.Values(opts.RequireEKU))
+		fmt.Fprintf(h, "require-ca=%t\nrequire-eku=%s\n", opts.RequireCA, strings.Join(requireEKU, " "))
+	}
+	if opts.MinRSABits > 0 || len(opts.AllowAlgorithms) > 0 || len(opts.IssuerContains) > 0 {
+		algs := slices.Sorted(slices.Values(opts.AllowAlgorithms))
+		issuers := slices.Sorted(slices.Values(opts.IssuerContains))
+		fmt.Fprintf(h, "min-rsa-bits=%d\nallow-alg=%s\nissuer-contains=%s\n",
+			opts.MinRSABits, strings.Join(algs, " "), strings.Join(issuers, "\x00"))
+	}
+	if opts.StatusConflict == StatusConflictExclude {
+		fmt.Fprintf(h, "status-conflict=%s\n", opts.StatusConflict)
+	}
+
+	for _, tsl := range contextTSLs(ctx) {
+		digest, err := tslContentHash(tsl)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "tsl=%s\n", digest)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// contextTSLs returns the TSLs the select step works on: the legacy stack if
+// it is populated, otherwise all TSLs of all trees.
+func contextTSLs(ctx *Context) []*etsi119612.TSL {
+	if ctx.TSLs != nil && !ctx.TSLs.IsEmpty() {
+		return ctx.TSLs.ToSlice()
+	}
+	var tsls []*etsi119612.TSL
+	if ctx.TSLTrees != nil {
+		for _, tree := range ctx.TSLTrees.ToSlice() {
+			if tree != nil {
+				tsls = append(tsls, tree.ToSlice()...)
+			}
+		}
+	}
+	return tsls
+}
+
+// tslContentHash returns the hex SHA-256 digest of the serialized content of a TSL.
+func tslContentHash(tsl *etsi119612.TSL) (string, error) {
+	if tsl == nil {
+		return "nil", nil
+	}
+	data, err := marshalTSLDocument(tsl)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash TSL %s: %w", tsl.Source, err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// selectCachePath returns the file holding the cached certificates for key.
+func selectCachePath(dir, key string) string {
+	return filepath.Join(dir, "select-"+key+".pem")
+}
+
+// loadSelectCache returns the certificates cached under key. It returns
+// false if there is no usable entry.
+func loadSelectCache(dir, key string) ([]*x509.Certificate, bool, error) {
+	data, err := os.ReadFile(selectCachePath(dir, key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, false, fmt.Errorf("corrupt select cache entry %s: %w", key, err)
+		}
+		certs = append(certs, cert)
+	}
+	return certs, true, nil
+}
+
+// storeSelectCache writes the selected certificates to the cache under key.
+func storeSelectCache(dir, key string, certs []*x509.Certificate) error {
+	if err := os.MkdirAll(dir, DefaultPublishDirMode); err != nil {
+		return fmt.Errorf("failed to create select cache directory %s: %w", dir, err)
+	}
+	var data []byte
+	for _, cert := range certs {
+		data = append(data, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})...)
+	}
+	return writeFileAtomic(selectCachePath(dir, key), data, DefaultPublishFileMode)
+}
@@ -0,0 +1,78 @@
+package pipeline
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirosfoundation/g119612/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectCertPool_Cache(t *testing.T) {
+	pl := &Pipeline{Logger: logging.SilentLogger()}
+	cacheDir := filepath.Join(t.TempDir(), "cache")
+	qc := "http://uri.etsi.org/TrstSvc/Svctype/CA/QC"
+
+	newCtx := func(certs ...string) *Context {
+		ctx := NewContext()
+		ctx.AddTSL(generateTSL("Test Service", qc, certs))
+		return ctx
+	}
+	cacheFiles := func() []string {
+		files, err := filepath.Glob(filepath.Join(cacheDir, "select-*.pem"))
+		require.NoError(t, err)
+		return files
+	}
+
+	// The first run builds the pool and stores it
+	ctx, err := SelectCertPool(pl, newCtx(TestCertBase64), "cache-dir:"+cacheDir)
+	require.NoError(t, err)
+	require.Len(t, cacheFiles(), 1)
+	expected := ctx.CertPool
+
+	// An unchanged run is served from the cache: replace the entry to prove it
+	_, otherDER, otherCert, err := GenerateTestCertBase64()
+	require.NoError(t, err)
+	entry := cacheFiles()[0]
+	require.NoError(t, os.WriteFile(entry, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: otherDER}), 0644))
+	ctx, err = SelectCertPool(pl, newCtx(TestCertBase64), "cache-dir:"+cacheDir)
+	require.NoError(t, err)
+	assert.False(t, ctx.CertPool.Equal(expected))
+	_, err = otherCert.Verify(x509.VerifyOptions{Roots: ctx.CertPool, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny}})
+	assert.NoError(t, err)
+
+	// A different policy or TSL content uses a new entry
+	ctx, err = SelectCertPool(pl, newCtx(TestCertBase64), "cache-dir:"+cacheDir, "service-type:"+qc)
+	require.NoError(t, err)
+	assert.True(t, ctx.CertPool.Equal(expected))
+	assert.Len(t, cacheFiles(), 2)
+
+	_, err = SelectCertPool(pl, newCtx(TestCertBase64, TestCertBase64), "cache-dir:"+cacheDir)
+	require.NoError(t, err)
+	assert.Len(t, cacheFiles(), 3)
+
+	// A corrupt entry is ignored and rebuilt
+	require.NoError(t, os.WriteFile(entry, []byte("-----BEGIN CERTIFICATE-----\nAAAA\n-----END CERTIFICATE-----\n"), 0644))
+	ctx, err = SelectCertPool(pl, newCtx(TestCertBase64), "cache-dir:"+cacheDir)
+	require.NoError(t, err)
+	assert.True(t, ctx.CertPool.Equal(expected))
+}
+
+func TestSelectCacheKey(t *testing.T) {
+	ctx := NewContext()
+	ctx.AddTSL(generateTSL("Test Service", "http://uri.etsi.org/TrstSvc/Svctype/CA/QC", []string{TestCertBase64}))
+
+	a, err := selectCacheKey(ctx, SelectOptions{Statuses: []string{"x", "y"}})
+	require.NoError(t, err)
+	b, err := selectCacheKey(ctx, SelectOptions{Statuses: []string{"y", "x"}, CacheDir: "/elsewhere"})
+	require.NoError(t, err)
+	assert.Equal(t, a, b, "filter order and cache location do not affect the key")
+
+	c, err := selectCacheKey(ctx, SelectOptions{Statuses: []string{"x", "y"}, MatchAllStatuses: true})
+	require.NoError(t, err)
+	assert.NotEqual(t, a, c)
+}
@@ -0,0 +1,157 @@
+package pipeline
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/sirosfoundation/g119612/pkg/etsi119612"
+	"github.com/sirosfoundation/g119612/pkg/etsi119612/uri"
+	"github.com/sirosfoundation/g119612/pkg/logging"
+)
+
+// Policies accepted by the select step's status-conflict option for
+// certificates listed under services with different statuses.
+const (
+	// StatusConflictWarn logs conflicts and selects the certificates as usual (default).
+	StatusConflictWarn = "warn"
+	// StatusConflictExclude logs conflicts and leaves the certificates out of the pool.
+	StatusConflictExclude = "exclude"
+)
+
+// statusConflictsKey is the context data key under which select records the
+// status conflicts it found, see StatusConflicts.
+const statusConflictsKey = "status-conflicts"
+
+// CertificateListing is one place a certificate is listed in: a service of a
+// trust service provider in a TSL.
+type CertificateListing struct {
+	TSL       string `json:"tsl"`                 // Source of the TSL
+	Territory string `json:"territory,omitempty"` // Scheme territory of the TSL
+	Provider  string `json:"provider"`            // Name of the trust service provider
+	Service   string `json:"service"`             // Name of the trust service
+	Status    string `json:"status"`              // Status URI of the service
+}
+
+// StatusConflict describes a certificate listed under services with different
+// statuses, for example granted in one TSL and withdrawn in another.
+type StatusConflict struct {
+	Subject  string               `json:"subject"`  // Subject of the certificate
+	SHA256   string               `json:"sha256"`   // Hex SHA-256 fingerprint of the certificate
+	Listings []CertificateListing `json:"listings"` // All listings of the certificate, in TSL order
+}
+
+// StatusConflicts returns the status conflicts found by the last select step
+// among the TSLs it processed.
+func StatusConflicts(ctx *Context) []StatusConflict {
+	if ctx == nil {
+		return nil
+	}
+	conflicts, _ := dataValue[[]StatusConflict](ctx, statusConflictsKey)
+	return conflicts
+}
+
+// recordStatusConflicts stores the status conflicts found by a select step in ctx.
+func recordStatusConflicts(ctx *Context, conflicts []StatusConflict) {
+	ctx.SetData(statusConflictsKey, conflicts)
+}
+
+// parseStatusConflictPolicy checks a status-conflict option value.
+func parseStatusConflictPolicy(value string) (string, error) {
+	switch value {
+	case StatusConflictWarn, StatusConflictExclude:
+		return value, nil
+	}
+	return "", fmt.Errorf("invalid status-conflict value %q (expected warn or exclude)", value)
+}
+
+// findStatusConflicts returns the certificates of the TSLs that are listed
+// under services with different statuses, keyed by their hex SHA-256
+// fingerprint, in the order they are first listed. Statuses are compared by
+// their eIDAS equivalent, so a pre-eIDAS accredited and a granted listing do
+// not conflict. All services are
+// considered, regardless of the select filters, since a filtered out
+// withdrawal is what makes a conflict relevant.
+func findStatusConflicts(tsls []*etsi119612.TSL) []StatusConflict {
+	var order []string
+	listings := make(map[string]*StatusConflict)
+	statuses := make(map[string]map[string]bool)
+	for _, tsl := range tsls {
+		if tsl == nil {
+			continue
+		}
+		tsl.WithTrustServices(func(tsp *etsi119612.TSPType, svc *etsi119612.TSPServiceType) {
+			svc.WithCertificates(func(cert *x509.Certificate) {
+				digest := sha256.Sum256(cert.Raw)
+				fingerprint := hex.EncodeToString(digest[:])
+				conflict, ok := listings[fingerprint]
+				if !ok {
+					conflict = &StatusConflict{Subject: cert.Subject.String(), SHA256: fingerprint}
+					listings[fingerprint] = conflict
+					statuses[fingerprint] = make(map[string]bool)
+					order = append(order, fingerprint)
+				}
+				listing := newCertificateListing(tsl, tsp, svc)
+				conflict.Listings = append(conflict.Listings, listing)
+				info := svc.TslServiceInformation
+				statuses[fingerprint][string(uri.EquivalentStatus(info.TslServiceStatus, info.TslServiceTypeIdentifier))] = true
+			})
+		})
+	}
+
+	var conflicts []StatusConflict
+	for _, fingerprint := range order {
+		if len(statuses[fingerprint]) > 1 {
+			conflicts = append(conflicts, *listings[fingerprint])
+		}
+	}
+	return conflicts
+}
+
+// newCertificateListing describes the service a certificate is listed in.
+func newCertificateListing(tsl *etsi119612.TSL, tsp *etsi119612.TSPType, svc *etsi119612.TSPServiceType) CertificateListing {
+	id := tsl.Identity()
+	listing := CertificateListing{TSL: id.Source, Territory: id.Territory, Status: svc.TslServiceInformation.TslServiceStatus}
+	if tsp.TslTSPInformation != nil {
+		listing.Provider = etsi119612.FindByLanguage(tsp.TslTSPInformation.TSPName, "en", "")
+	}
+	listing.Service = etsi119612.FindByLanguage(svc.TslServiceInformation.ServiceName, "en", "")
+	return listing
+}
+
+// conflictError is the reason recorded for a certificate excluded for a status conflict.
+func conflictError(conflict StatusConflict) error {
+	statuses := make([]string, 0, len(conflict.Listings))
+	for _, listing := range conflict.Listings {
+		if !slices.Contains(statuses, listing.Status) {
+			statuses = append(statuses, listing.Status)
+		}
+	}
+	return fmt.Errorf("listed under conflicting statuses: %s", strings.Join(statuses, ", "))
+}
+
+// logStatusConflicts logs a warning with all listings of each conflicting certificate.
+func logStatusConflicts(pl *Pipeline, conflicts []StatusConflict, policy string) {
+	if pl == nil || pl.Logger == nil {
+		return
+	}
+	for _, conflict := range conflicts {
+		fields := []logging.Field{
+			logging.F("subject", conflict.Subject),
+			logging.F("sha256", conflict.SHA256),
+			logging.F("policy", policy),
+		}
+		for i, listing := range conflict.Listings {
+			tsl := listing.TSL
+			if listing.Territory != "" {
+				tsl = listing.Territory + " " + tsl
+			}
+			fields = append(fields, logging.F(fmt.Sprintf("listing_%d", i+1),
+				fmt.Sprintf("%s (%s / %s): %s", tsl, listing.Provider, listing.Service, listing.Status)))
+		}
+		pl.Logger.Warn("Certificate listed under conflicting statuses", fields...)
+	}
+}
@@ -0,0 +1,75 @@
+package pipeline
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/sirosfoundation/g119612/pkg/etsi119612"
+	"github.com/sirosfoundation/g119612/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testStatusWithdrawn = "http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/withdrawn"
+
+func TestSelectCertPool_StatusConflicts(t *testing.T) {
+	pl := &Pipeline{Logger: logging.SilentLogger()}
+	digest := sha256.Sum256(TestCert.Raw)
+	fingerprint := hex.EncodeToString(digest[:])
+
+	// The test certificate is granted in the root TSL and withdrawn in a referenced one
+	newCtx := func() *Context {
+		granted := generateTSL("Granted Service", "http://uri.etsi.org/TrstSvc/Svctype/CA/QC", []string{TestCertBase64})
+		granted.Source = "https://example.com/root.xml"
+		withdrawn := generateTSL("Withdrawn Service", "http://uri.etsi.org/TrstSvc/Svctype/CA/QC", []string{TestCertBase64})
+		withdrawn.Source = "https://example.com/ref.xml"
+		withdrawn.StatusList.TslTrustServiceProviderList.TslTrustServiceProvider[0].TslTSPServices.TslTSPService[0].TslServiceInformation.TslServiceStatus = testStatusWithdrawn
+
+		ctx := NewContext()
+		ctx.TSLs.Push(granted)
+		ctx.TSLs.Push(withdrawn)
+		return ctx
+	}
+
+	t.Run("Warn", func(t *testing.T) {
+		ctx, err := SelectCertPool(pl, newCtx(), "reference-depth:1")
+		require.NoError(t, err)
+		conflicts := StatusConflicts(ctx)
+		require.Len(t, conflicts, 1)
+		assert.Equal(t, fingerprint, conflicts[0].SHA256)
+		assert.Equal(t, []CertificateListing{
+			{TSL: "https://example.com/root.xml", Provider: "Test Provider", Service: "Granted Service", Status: etsi119612.ServiceStatusGranted},
+			{TSL: "https://example.com/ref.xml", Provider: "Test Provider", Service: "Withdrawn Service", Status: testStatusWithdrawn},
+		}, conflicts[0].Listings)
+		assert.Equal(t, 2, ctx.Data[certCountKey])
+		assert.Empty(t, ExcludedCertificates(ctx))
+	})
+
+	t.Run("Exclude", func(t *testing.T) {
+		// The withdrawn listing is filtered out and still excludes the certificate
+		ctx, err := SelectCertPool(pl, newCtx(), "reference-depth:1", "status:"+etsi119612.ServiceStatusGranted, "status-conflict:exclude")
+		require.NoError(t, err)
+		assert.Len(t, StatusConflicts(ctx), 1)
+		assert.Equal(t, 0, ctx.Data[certCountKey])
+		excluded := ExcludedCertificates(ctx)
+		require.Len(t, excluded, 1)
+		assert.Equal(t, "Granted Service", excluded[0].Service)
+		assert.Contains(t, excluded[0].Reason, "conflicting statuses")
+		assert.Contains(t, excluded[0].Reason, testStatusWithdrawn)
+	})
+
+	t.Run("Root_Only", func(t *testing.T) {
+		ctx, err := SelectCertPool(pl, newCtx(), "status-conflict:exclude")
+		require.NoError(t, err)
+		assert.Empty(t, StatusConflicts(ctx))
+		assert.Equal(t, 1, ctx.Data[certCountKey])
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := SelectCertPool(pl, newCtx(), "status-conflict:ignore")
+		assert.ErrorIs(t, err, ErrInvalidArguments)
+		_, err = Select(newCtx(), SelectOptions{StatusConflict: "ignore"})
+		assert.ErrorIs(t, err, ErrInvalidArguments)
+	})
+}
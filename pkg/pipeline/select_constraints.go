@@ -0,0 +1,184 @@
+package pipeline
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/sirosfoundation/g119612/pkg/etsi119612"
+	"github.com/sirosfoundation/g119612/pkg/validation"
+)
+
+// excludedCertificatesKey is the context data key under which select records
+// the certificates it excluded, see ExcludedCertificates.
+const excludedCertificatesKey = "excluded-certificates"
+
+// ExcludedCertificate describes a certificate of a selected trust service that
+// the select step left out of the pool because it failed the require-ca,
+// require-eku or key and issuer checks, for example a leaf certificate listed
+// as a digital identity of a CA service or a legacy 1024 bit RSA anchor.
+type ExcludedCertificate struct {
+	TSL       string `json:"tsl"`                 // Source of the TSL listing the certificate
+	Territory string `json:"territory,omitempty"` // Scheme territory of the TSL
+	Provider  string `json:"provider"`            // Name of the trust service provider
+	Service   string `json:"service"`             // Name of the trust service
+	Subject   string `json:"subject"`             // Subject of the certificate
+	SHA256    string `json:"sha256"`              // Hex SHA-256 fingerprint of the certificate
+	Reason    string `json:"reason"`              // The failed check
+}
+
+// ExcludedCertificates returns the certificates the last select step excluded
+// from the pool, nil if it excluded none or restored the pool from its cache.
+func ExcludedCertificates(ctx *Context) []ExcludedCertificate {
+	if ctx == nil {
+		return nil
+	}
+	excluded, _ := dataValue[[]ExcludedCertificate](ctx, excludedCertificatesKey)
+	return excluded
+}
+
+// recordExcludedCertificates stores the certificates excluded by a select step in ctx.
+func recordExcludedCertificates(ctx *Context, excluded []ExcludedCertificate) {
+	ctx.SetData(excludedCertificatesKey, excluded)
+}
+
+// keyAlgorithms are the names accepted by allow-alg. ECDSA allows any curve.
+var keyAlgorithms = []string{"RSA", "ECDSA", "ECDSA-P224", "ECDSA-P256", "ECDSA-P384", "ECDSA-P521", "Ed25519"}
+
+// parseKeyAlgorithms parses the comma-separated value of an allow-alg option.
+func parseKeyAlgorithms(value string) ([]string, error) {
+	var algs []string
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		i := slices.IndexFunc(keyAlgorithms, func(alg string) bool { return strings.EqualFold(alg, name) })
+		if i < 0 {
+			return nil, fmt.Errorf("unknown key algorithm %q (expected one of %s)", name, strings.Join(keyAlgorithms, ", "))
+		}
+		algs = append(algs, keyAlgorithms[i])
+	}
+	return algs, nil
+}
+
+// publicKeyAlgorithm names the public key algorithm of cert as in keyAlgorithms,
+// with the curve for ECDSA keys. Unknown keys are named by their x509 algorithm.
+func publicKeyAlgorithm(cert *x509.Certificate) string {
+	switch key := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		return "RSA"
+	case *ecdsa.PublicKey:
+		return "ECDSA-" + strings.Replace(key.Curve.Params().Name, "-", "", 1) // P-256 is ECDSA-P256
+	case ed25519.PublicKey:
+		return "Ed25519"
+	}
+	return cert.PublicKeyAlgorithm.String()
+}
+
+// certificateConstraints returns the check the select options require of
+// certificates, nil if they require none.
+func certificateConstraints(opts SelectOptions) (func(*x509.Certificate) error, error) {
+	var checks []func(*x509.Certificate) error
+	if opts.RequireCA || len(opts.RequireEKU) > 0 {
+		usages := make([]x509.ExtKeyUsage, 0, len(opts.RequireEKU))
+		for _, name := range opts.RequireEKU {
+			usage, err := validation.ParseExtKeyUsage(name)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrInvalidArguments, err)
+			}
+			usages = append(usages, usage)
+		}
+		if opts.RequireCA {
+			checks = append(checks, func(cert *x509.Certificate) error {
+				return validation.ValidateCACertificate(cert, usages...)
+			})
+		} else {
+			checks = append(checks, func(cert *x509.Certificate) error {
+				return validation.ValidateExtKeyUsage(cert, usages...)
+			})
+		}
+	}
+	if opts.MinRSABits > 0 {
+		checks = append(checks, func(cert *x509.Certificate) error {
+			if key, ok := cert.PublicKey.(*rsa.PublicKey); ok && key.N.BitLen() < opts.MinRSABits {
+				return fmt.Errorf("RSA key of %d bits is shorter than %d bits", key.N.BitLen(), opts.MinRSABits)
+			}
+			return nil
+		})
+	}
+	if len(opts.AllowAlgorithms) > 0 {
+		allowed, err := parseKeyAlgorithms(strings.Join(opts.AllowAlgorithms, ","))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidArguments, err)
+		}
+		checks = append(checks, func(cert *x509.Certificate) error {
+			alg := publicKeyAlgorithm(cert)
+			if slices.Contains(allowed, alg) || (strings.HasPrefix(alg, "ECDSA-") && slices.Contains(allowed, "ECDSA")) {
+				return nil
+			}
+			return fmt.Errorf("key algorithm %s is not allowed", alg)
+		})
+	}
+	if len(opts.IssuerContains) > 0 {
+		checks = append(checks, func(cert *x509.Certificate) error {
+			issuer := strings.ToLower(cert.Issuer.String())
+			for _, text := range opts.IssuerContains {
+				if strings.Contains(issuer, strings.ToLower(text)) {
+					return nil
+				}
+			}
+			return fmt.Errorf("issuer %q does not contain %s", cert.Issuer.String(), strings.Join(opts.IssuerContains, " or "))
+		})
+	}
+	if len(checks) == 0 {
+		return nil, nil
+	}
+	return func(cert *x509.Certificate) error {
+		for _, check := range checks {
+			if err := check(cert); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, nil
+}
+
+// newExcludedCertificate describes a certificate that failed the constraints.
+func newExcludedCertificate(tsl *etsi119612.TSL, tsp *etsi119612.TSPType, svc *etsi119612.TSPServiceType, cert *x509.Certificate, reason error) ExcludedCertificate {
+	digest := sha256.Sum256(cert.Raw)
+	id := tsl.Identity()
+	excluded := ExcludedCertificate{
+		TSL:       id.Source,
+		Territory: id.Territory,
+		Subject:   cert.Subject.String(),
+		SHA256:    hex.EncodeToString(digest[:]),
+		Reason:    reason.Error(),
+	}
+	if tsp != nil && tsp.TslTSPInformation != nil {
+		excluded.Provider = etsi119612.FindByLanguage(tsp.TslTSPInformation.TSPName, "en", "")
+	}
+	if svc != nil && svc.TslServiceInformation != nil {
+		excluded.Service = etsi119612.FindByLanguage(svc.TslServiceInformation.ServiceName, "en", "")
+	}
+	return excluded
+}
+
+// writeExclusionReport writes the excluded certificates to path as a JSON array.
+func writeExclusionReport(path string, excluded []ExcludedCertificate) error {
+	if excluded == nil {
+		excluded = []ExcludedCertificate{}
+	}
+	data, err := json.MarshalIndent(excluded, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(path, append(data, '\n'), DefaultPublishFileMode); err != nil {
+		return fmt.Errorf("failed to write exclusion report: %w", err)
+	}
+	return nil
+}
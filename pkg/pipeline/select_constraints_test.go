@@ -0,0 +1,172 @@
+package pipeline
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirosfoundation/g119612/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// constraintTestCert creates a self-signed certificate from template and
+// returns it base64 encoded as in a TSL digital identity.
+func constraintTestCert(t *testing.T, name string, template *x509.Certificate) string {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template.SerialNumber = big.NewInt(1)
+	template.Subject = pkix.Name{CommonName: name}
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	return base64.StdEncoding.EncodeToString(der)
+}
+
+func TestSelectCertPool_RequireCA(t *testing.T) {
+	ca := constraintTestCert(t, "Issuing CA", &x509.Certificate{
+		BasicConstraintsValid: true, IsCA: true, KeyUsage: x509.KeyUsageCertSign,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+	leaf := constraintTestCert(t, "Rogue Leaf", &x509.Certificate{
+		BasicConstraintsValid: true, KeyUsage: x509.KeyUsageDigitalSignature,
+	})
+	newContext := func() *Context {
+		ctx := NewContext()
+		ctx.AddTSL(generateTSL("Test Service", "http://uri.etsi.org/TrstSvc/Svctype/CA/QC", []string{ca, leaf}))
+		return ctx
+	}
+	pl := &Pipeline{Logger: logging.SilentLogger()}
+
+	t.Run("Without_Constraints", func(t *testing.T) {
+		ctx, err := SelectCertPool(pl, newContext())
+		require.NoError(t, err)
+		assert.Equal(t, 2, ctx.Data[certCountKey])
+		assert.Empty(t, ExcludedCertificates(ctx))
+	})
+
+	t.Run("Require_CA", func(t *testing.T) {
+		report := filepath.Join(t.TempDir(), "excluded.json")
+		ctx, err := SelectCertPool(pl, newContext(), "require-ca", "exclusion-report:"+report)
+		require.NoError(t, err)
+		assert.Equal(t, 1, ctx.Data[certCountKey])
+
+		excluded := ExcludedCertificates(ctx)
+		require.Len(t, excluded, 1)
+		assert.Equal(t, "CN=Rogue Leaf", excluded[0].Subject)
+		assert.Equal(t, "Test Provider", excluded[0].Provider)
+		assert.Equal(t, "Test Service", excluded[0].Service)
+		assert.Contains(t, excluded[0].Reason, "not a CA")
+		assert.Len(t, excluded[0].SHA256, 64)
+
+		data, err := os.ReadFile(report)
+		require.NoError(t, err)
+		var written []ExcludedCertificate
+		require.NoError(t, json.Unmarshal(data, &written))
+		assert.Equal(t, excluded, written)
+	})
+
+	t.Run("Require_EKU", func(t *testing.T) {
+		ctx, err := SelectCertPool(pl, newContext(), "require-ca", "require-eku:clientAuth")
+		require.NoError(t, err)
+		assert.Equal(t, 0, ctx.Data[certCountKey])
+		assert.Len(t, ExcludedCertificates(ctx), 2)
+
+		// The leaf has no ExtendedKeyUsage extension, so only require-ca excludes it
+		ctx, err = SelectCertPool(pl, newContext(), "require-eku:serverAuth")
+		require.NoError(t, err)
+		assert.Equal(t, 2, ctx.Data[certCountKey])
+		assert.Empty(t, ExcludedCertificates(ctx))
+	})
+
+	t.Run("Unknown_EKU", func(t *testing.T) {
+		_, err := SelectCertPool(pl, newContext(), "require-eku:documentSigning")
+		assert.ErrorIs(t, err, ErrInvalidArguments)
+	})
+}
+
+func TestSelectCertPool_KeyFilters(t *testing.T) {
+	rsaCert := func(name, issuer string, bits int) string {
+		key, err := rsa.GenerateKey(rand.Reader, bits)
+		require.NoError(t, err)
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(1),
+			Subject:      pkix.Name{CommonName: name, Organization: []string{issuer}},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+		require.NoError(t, err)
+		return base64.StdEncoding.EncodeToString(der)
+	}
+	legacy := rsaCert("Legacy CA", "Old Trust Ltd", 1024)
+	modern := rsaCert("Modern CA", "New Trust AB", 3072)
+	ec := constraintTestCert(t, "EC CA", &x509.Certificate{})
+	newContext := func() *Context {
+		ctx := NewContext()
+		ctx.AddTSL(generateTSL("Test Service", "http://uri.etsi.org/TrstSvc/Svctype/CA/QC", []string{legacy, modern, ec}))
+		return ctx
+	}
+	pl := &Pipeline{Logger: logging.SilentLogger()}
+
+	tests := []struct {
+		name     string
+		args     []string
+		count    int
+		excluded []string
+		reason   string
+	}{
+		{"Min_RSA_Bits", []string{"min-rsa-bits:3072"}, 2, []string{"CN=Legacy CA,O=Old Trust Ltd"}, "RSA key of 1024 bits"},
+		{"Allow_RSA", []string{"allow-alg:rsa"}, 2, []string{"CN=EC CA"}, "key algorithm ECDSA-P256 is not allowed"},
+		{"Allow_ECDSA_Curve", []string{"allow-alg:ECDSA-P384,Ed25519"}, 0, nil, "not allowed"},
+		{"Allow_Any_ECDSA", []string{"allow-alg:RSA,ECDSA", "min-rsa-bits:2048"}, 2, []string{"CN=Legacy CA,O=Old Trust Ltd"}, ""},
+		{"Issuer_Contains", []string{"issuer-contains:new trust", "issuer-contains:EC CA"}, 2, []string{"CN=Legacy CA,O=Old Trust Ltd"}, "does not contain"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, err := SelectCertPool(pl, newContext(), tt.args...)
+			require.NoError(t, err)
+			assert.Equal(t, tt.count, ctx.Data[certCountKey])
+			excluded := ExcludedCertificates(ctx)
+			assert.Len(t, excluded, 3-tt.count)
+			var subjects []string
+			for _, entry := range excluded {
+				subjects = append(subjects, entry.Subject)
+				assert.Contains(t, entry.Reason, tt.reason)
+			}
+			if tt.excluded != nil {
+				assert.Equal(t, tt.excluded, subjects)
+			}
+		})
+	}
+
+	t.Run("Invalid_Arguments", func(t *testing.T) {
+		for _, arg := range []string{"min-rsa-bits:0", "min-rsa-bits:many", "allow-alg:DSA", "allow-alg:RSA,", "issuer-contains:"} {
+			_, err := SelectCertPool(pl, newContext(), arg)
+			assert.ErrorIs(t, err, ErrInvalidArguments, arg)
+		}
+		_, err := Select(newContext(), SelectOptions{AllowAlgorithms: []string{"DSA"}})
+		assert.ErrorIs(t, err, ErrInvalidArguments)
+	})
+
+	t.Run("Cache_Key", func(t *testing.T) {
+		ctx := newContext()
+		plain, err := selectCacheKey(ctx, SelectOptions{})
+		require.NoError(t, err)
+		filtered, err := selectCacheKey(ctx, SelectOptions{MinRSABits: 3072})
+		require.NoError(t, err)
+		assert.NotEqual(t, plain, filtered)
+	})
+}
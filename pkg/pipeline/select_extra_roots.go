@@ -0,0 +1,167 @@
+package pipeline
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/sirosfoundation/g119612/pkg/logging"
+)
+
+// ProvenanceLocal is the provenance of trust anchors that select read from
+// local PEM files with the extra-roots option rather than from a TSL.
+const ProvenanceLocal = "local"
+
+// localTrustAnchorsKey is the context data key under which select records the
+// trust anchors it added from local files, see LocalTrustAnchors.
+const localTrustAnchorsKey = "local-trust-anchors"
+
+// TrustAnchor is a certificate the select step added to the pool from outside
+// the loaded TSLs, for example a private ecosystem CA that no TSL lists yet.
+type TrustAnchor struct {
+	Certificate *x509.Certificate `json:"-"`
+	Provenance  string            `json:"provenance"` // ProvenanceLocal
+	Source      string            `json:"source"`     // File the certificate was read from
+	Subject     string            `json:"subject"`    // Subject of the certificate
+	SHA256      string            `json:"sha256"`     // Hex SHA-256 fingerprint of the certificate
+}
+
+// LocalTrustAnchors returns the trust anchors the last select step added to
+// the pool from the files given with extra-roots.
+func LocalTrustAnchors(ctx *Context) []TrustAnchor {
+	if ctx == nil {
+		return nil
+	}
+	anchors, _ := dataValue[[]TrustAnchor](ctx, localTrustAnchorsKey)
+	return anchors
+}
+
+// recordLocalTrustAnchors stores the trust anchors added by a select step in ctx.
+func recordLocalTrustAnchors(ctx *Context, anchors []TrustAnchor) {
+	ctx.SetData(localTrustAnchorsKey, anchors)
+}
+
+// addExtraRoots adds the certificates of the extra-roots files and directories
+// of opts to the pool of ctx and returns the number of certificates added.
+// Certificates that are already in the pool are not added twice.
+func addExtraRoots(pl *Pipeline, ctx *Context, opts SelectOptions, selected []*x509.Certificate) (int, error) {
+	recordLocalTrustAnchors(ctx, nil)
+	if len(opts.ExtraRoots) == 0 {
+		return 0, nil
+	}
+
+	seen := make(map[string]bool, len(selected))
+	for _, cert := range selected {
+		seen[string(cert.Raw)] = true
+	}
+
+	var anchors []TrustAnchor
+	for _, path := range opts.ExtraRoots {
+		files, err := certificateFiles(path)
+		if err != nil {
+			return 0, fmt.Errorf("failed to read extra roots: %w", err)
+		}
+		for _, file := range files {
+			certs, err := readPEMCertificates(file)
+			if err != nil {
+				return 0, fmt.Errorf("failed to read extra roots: %w", err)
+			}
+			for _, cert := range certs {
+				if seen[string(cert.Raw)] {
+					continue
+				}
+				seen[string(cert.Raw)] = true
+				digest := sha256.Sum256(cert.Raw)
+				anchors = append(anchors, TrustAnchor{
+					Certificate: cert,
+					Provenance:  ProvenanceLocal,
+					Source:      file,
+					Subject:     cert.Subject.String(),
+					SHA256:      hex.EncodeToString(digest[:]),
+				})
+			}
+		}
+	}
+
+	for _, anchor := range anchors {
+		ctx.CertPool.AddCert(anchor.Certificate)
+		if pl != nil && pl.Logger != nil {
+			pl.Logger.Debug("Added local trust anchor to pool",
+				logging.F("provenance", anchor.Provenance),
+				logging.F("source", anchor.Source),
+				logging.F("subject", anchor.Subject),
+				logging.F("sha256", anchor.SHA256))
+		}
+	}
+	recordLocalTrustAnchors(ctx, anchors)
+	if pl != nil && pl.Logger != nil {
+		pl.Logger.Info("Added local trust anchors",
+			logging.F("provenance", ProvenanceLocal),
+			logging.F("certificate_count", len(anchors)))
+	}
+	return len(anchors), nil
+}
+
+// certificateFiles returns the PEM files of a path such as an extra-roots
+// path: the path itself if it is a file, or the *.pem, *.crt and *.cer files
+// in it if it is a directory.
+func certificateFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory %s: %w", path, err)
+	}
+	var files []string
+	for _, entry := range entries {
+		switch strings.ToLower(filepath.Ext(entry.Name())) {
+		case ".pem", ".crt", ".cer":
+			if entry.Type().IsRegular() {
+				files = append(files, filepath.Join(path, entry.Name()))
+			}
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// readPEMCertificates parses all CERTIFICATE blocks of a PEM file. A file
+// without certificates is an error, since it most likely is not what was meant.
+func readPEMCertificates(file string) ([]*x509.Certificate, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid certificate in %s: %w", file, err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no certificates found in %s", file)
+	}
+	return certs, nil
+}
@@ -0,0 +1,100 @@
+package pipeline
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirosfoundation/g119612/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectCertPool_ExtraRoots(t *testing.T) {
+	pl := &Pipeline{Logger: logging.SilentLogger()}
+	caTemplate := func() *x509.Certificate {
+		return &x509.Certificate{BasicConstraintsValid: true, IsCA: true, KeyUsage: x509.KeyUsageCertSign}
+	}
+	private, _ := issueTestCert(t, "Private Ecosystem CA", caTemplate(), nil, nil)
+	other, _ := issueTestCert(t, "Other Private CA", caTemplate(), nil, nil)
+	encode := func(certs ...*x509.Certificate) []byte {
+		var data []byte
+		for _, cert := range certs {
+			data = append(data, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})...)
+		}
+		return data
+	}
+
+	dir := t.TempDir()
+	rootsDir := filepath.Join(dir, "roots")
+	require.NoError(t, os.Mkdir(rootsDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(rootsDir, "private.pem"), encode(private), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(rootsDir, "other.crt"), encode(other, private), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(rootsDir, "README.txt"), []byte("not a certificate"), 0644))
+	// Certificates already listed in a TSL are not local trust anchors
+	tslCertFile := filepath.Join(dir, "tsl-cert.pem")
+	require.NoError(t, os.WriteFile(tslCertFile, encode(TestCert), 0644))
+
+	newCtx := func() *Context {
+		ctx := NewContext()
+		ctx.AddTSL(generateTSL("Test Service", "http://uri.etsi.org/TrstSvc/Svctype/CA/QC", []string{TestCertBase64}))
+		return ctx
+	}
+
+	t.Run("Merged_With_Provenance", func(t *testing.T) {
+		ctx, err := SelectCertPool(pl, newCtx(), "extra-roots:"+rootsDir, "extra-roots:"+tslCertFile)
+		require.NoError(t, err)
+
+		anchors := LocalTrustAnchors(ctx)
+		require.Len(t, anchors, 2)
+		assert.Equal(t, other.Raw, anchors[0].Certificate.Raw)
+		assert.Equal(t, filepath.Join(rootsDir, "other.crt"), anchors[0].Source)
+		assert.Equal(t, private.Raw, anchors[1].Certificate.Raw)
+		for _, anchor := range anchors {
+			assert.Equal(t, ProvenanceLocal, anchor.Provenance)
+		}
+		assert.Equal(t, 3, ctx.Data[certCountKey])
+
+		chains, err := ctx.Verify(private)
+		require.NoError(t, err)
+		assert.Len(t, chains, 1)
+		_, err = ctx.Verify(TestCert)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Restored_From_Cache", func(t *testing.T) {
+		cacheDir := filepath.Join(dir, "cache")
+		for range 2 {
+			ctx, err := SelectCertPool(pl, newCtx(), "cache-dir:"+cacheDir, "extra-roots:"+filepath.Join(rootsDir, "private.pem"))
+			require.NoError(t, err)
+			assert.Len(t, LocalTrustAnchors(ctx), 1)
+			_, err = ctx.Verify(private)
+			assert.NoError(t, err)
+		}
+	})
+
+	t.Run("Not_Set", func(t *testing.T) {
+		ctx, err := SelectCertPool(pl, newCtx())
+		require.NoError(t, err)
+		assert.Empty(t, LocalTrustAnchors(ctx))
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		empty := filepath.Join(dir, "empty.pem")
+		require.NoError(t, os.WriteFile(empty, []byte("no certificates here"), 0644))
+		broken := filepath.Join(dir, "broken.pem")
+		require.NoError(t, os.WriteFile(broken, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte("garbage")}), 0644))
+
+		_, err := SelectCertPool(pl, newCtx(), "extra-roots:"+filepath.Join(dir, "missing.pem"))
+		assert.ErrorContains(t, err, "failed to read extra roots")
+		_, err = SelectCertPool(pl, newCtx(), "extra-roots:"+empty)
+		assert.ErrorContains(t, err, "no certificates found")
+		_, err = SelectCertPool(pl, newCtx(), "extra-roots:"+broken)
+		assert.ErrorContains(t, err, "invalid certificate")
+		_, err = SelectCertPool(pl, newCtx(), "extra-roots:")
+		assert.ErrorContains(t, err, "invalid extra roots path")
+	})
+
+}
@@ -0,0 +1,92 @@
+package pipeline
+
+import (
+	"crypto/x509"
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// namedPoolsKey is the context data key under which select records the pools
+// built with a name option, see PoolNames and UsePool.
+const namedPoolsKey = "named-pools"
+
+// namedPoolDataKeys are the context data keys select records about a pool,
+// saved with a named pool so that UsePool restores all of them.
+var namedPoolDataKeys = []string{
+	selectedPoolKey,
+	localTrustAnchorsKey,
+	certCountKey,
+	excludedCertificatesKey,
+	statusConflictsKey,
+}
+
+// namedPool is a pool built by a select step with a name option.
+type namedPool struct {
+	certPool      *x509.CertPool
+	verifyOptions *x509.VerifyOptions
+	data          map[string]any
+}
+
+// recordNamedPool saves the pool select just built in ctx under name,
+// replacing an earlier pool of that name.
+func recordNamedPool(ctx *Context, name string) {
+	pool := namedPool{
+		certPool:      ctx.CertPool,
+		verifyOptions: ctx.VerifyOptions,
+		data:          make(map[string]any, len(namedPoolDataKeys)),
+	}
+	for _, key := range namedPoolDataKeys {
+		if value, ok := ctx.GetData(key); ok {
+			pool.data[key] = value
+		}
+	}
+	ctx.UpdateData(namedPoolsKey, func(value any) any {
+		// Copy the map, contexts may share it
+		pools, _ := value.(map[string]namedPool)
+		pools = maps.Clone(pools)
+		if pools == nil {
+			pools = make(map[string]namedPool)
+		}
+		pools[name] = pool
+		return pools
+	})
+}
+
+// PoolNames returns the names of the pools built by select steps with a name
+// option, sorted.
+func PoolNames(ctx *Context) []string {
+	if ctx == nil {
+		return nil
+	}
+	pools, _ := dataValue[map[string]namedPool](ctx, namedPoolsKey)
+	return slices.Sorted(maps.Keys(pools))
+}
+
+// UsePool returns a copy of ctx in which the pool that a select step with the
+// option name:NAME built is the current pool: CertPool, VerifyOptions,
+// PoolCertificates, PoolPolicy, LocalTrustAnchors, ExcludedCertificates and
+// StatusConflicts are those of that select step. ctx itself is not modified.
+// It fails with ErrNoCertPool if no pool of that name was selected.
+//
+// This lets one pipeline select several differently filtered pools from the
+// same TSLs and publish each of them, instead of running the pipeline once per
+// policy.
+func UsePool(ctx *Context, name string) (*Context, error) {
+	pools, _ := dataValue[map[string]namedPool](ctx, namedPoolsKey)
+	pool, ok := pools[name]
+	if !ok {
+		return ctx, fmt.Errorf("%w: no pool named %q", ErrNoCertPool, name)
+	}
+	newCtx := ctx.Copy()
+	newCtx.CertPool = pool.certPool
+	newCtx.VerifyOptions = pool.verifyOptions
+	for _, key := range namedPoolDataKeys {
+		if value, ok := pool.data[key]; ok {
+			newCtx.Data[key] = value
+		} else {
+			delete(newCtx.Data, key)
+		}
+	}
+	return newCtx, nil
+}
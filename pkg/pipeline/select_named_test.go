@@ -0,0 +1,112 @@
+package pipeline
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"testing"
+
+	"github.com/sirosfoundation/g119612/pkg/logging"
+	"github.com/sirosfoundation/g119612/pkg/oci"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectCertPool_Named(t *testing.T) {
+	pl := &Pipeline{Logger: logging.SilentLogger()}
+	_, _, qcCert, err := GenerateTestCertBase64()
+	require.NoError(t, err)
+	_, _, tsaCert, err := GenerateTestCertBase64()
+	require.NoError(t, err)
+	const qcType = "http://uri.etsi.org/TrstSvc/Svctype/CA/QC"
+	const tsaType = "http://uri.etsi.org/TrstSvc/Svctype/TSA/QTST"
+
+	newCtx := func() *Context {
+		tsl := generateTSL("QC Service", qcType, []string{base64.StdEncoding.EncodeToString(qcCert.Raw)})
+		tsa := generateTSL("TSA Service", tsaType, []string{base64.StdEncoding.EncodeToString(tsaCert.Raw)})
+		services := tsl.StatusList.TslTrustServiceProviderList.TslTrustServiceProvider[0].TslTSPServices
+		services.TslTSPService = append(services.TslTSPService,
+			tsa.StatusList.TslTrustServiceProviderList.TslTrustServiceProvider[0].TslTSPServices.TslTSPService...)
+		ctx := NewContext()
+		ctx.AddTSL(tsl)
+		return ctx
+	}
+	selectPools := func(t *testing.T) *Context {
+		ctx, err := SelectCertPool(pl, newCtx(), "name:qc", "service-type:"+qcType)
+		require.NoError(t, err)
+		ctx, err = SelectCertPool(pl, ctx, "name:tsa", "service-type:"+tsaType)
+		require.NoError(t, err)
+		return ctx
+	}
+
+	t.Run("Use_By_Name", func(t *testing.T) {
+		ctx := selectPools(t)
+		assert.Equal(t, []string{"qc", "tsa"}, PoolNames(ctx))
+		assert.Equal(t, "reference-depth:0 service-type:"+tsaType, PoolPolicy(ctx), "the last pool stays current")
+
+		qc, err := UsePool(ctx, "qc")
+		require.NoError(t, err)
+		assert.Equal(t, "reference-depth:0 service-type:"+qcType, PoolPolicy(qc))
+		require.Len(t, PoolCertificates(qc), 1)
+		assert.Equal(t, qcCert.Raw, PoolCertificates(qc)[0].Raw)
+		assert.Equal(t, 1, qc.Data[certCountKey])
+		_, err = qc.Verify(qcCert)
+		assert.NoError(t, err)
+		_, err = qc.Verify(tsaCert)
+		assert.Error(t, err)
+
+		tsa, err := UsePool(ctx, "tsa")
+		require.NoError(t, err)
+		_, err = tsa.Verify(tsaCert)
+		assert.NoError(t, err)
+		_, err = tsa.Verify(qcCert)
+		assert.Error(t, err)
+
+		assert.Equal(t, "reference-depth:0 service-type:"+tsaType, PoolPolicy(ctx), "UsePool does not modify its context")
+		_, err = UsePool(ctx, "missing")
+		assert.ErrorIs(t, err, ErrNoCertPool)
+	})
+
+	t.Run("Replaced_By_Same_Name", func(t *testing.T) {
+		ctx := selectPools(t)
+		copied := ctx.Copy()
+		ctx, err := SelectCertPool(pl, ctx, "name:qc", "service-type:"+tsaType)
+		require.NoError(t, err)
+		qc, err := UsePool(ctx, "qc")
+		require.NoError(t, err)
+		_, err = qc.Verify(tsaCert)
+		assert.NoError(t, err)
+
+		qc, err = UsePool(copied, "qc")
+		require.NoError(t, err)
+		_, err = qc.Verify(qcCert)
+		assert.NoError(t, err, "copies keep their pools")
+	})
+
+	t.Run("Unnamed", func(t *testing.T) {
+		ctx, err := SelectCertPool(pl, newCtx())
+		require.NoError(t, err)
+		assert.Empty(t, PoolNames(ctx))
+		_, err = SelectCertPool(pl, newCtx(), "name:")
+		assert.ErrorIs(t, err, ErrInvalidArguments)
+	})
+
+	t.Run("Publish_OCI", func(t *testing.T) {
+		registry, blobs := ociTestRegistry(t)
+		ctx := selectPools(t)
+		_, err := PublishOCI(pl, ctx, registry+"/trust/qc:v1", "plain-http", "pool-only", "pool:qc")
+		require.NoError(t, err)
+
+		var manifest oci.Manifest
+		require.NoError(t, json.Unmarshal(blobs["manifest:v1"], &manifest))
+		assert.Equal(t, "reference-depth:0 service-type:"+qcType, manifest.Annotations[AnnotationPoolPolicy])
+		require.Len(t, manifest.Layers, 1)
+		block, _ := pem.Decode(blobs[manifest.Layers[0].Digest])
+		require.NotNil(t, block)
+		assert.Equal(t, qcCert.Raw, block.Bytes)
+
+		_, err = PublishOCI(pl, ctx, registry+"/trust/qc:v2", "plain-http", "pool:missing")
+		assert.ErrorIs(t, err, ErrNoCertPool)
+		assert.ErrorIs(t, validatePublishOCIArgs("registry.example.com/pool", "pool:"), ErrInvalidArguments)
+	})
+}
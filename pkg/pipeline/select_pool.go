@@ -0,0 +1,83 @@
+package pipeline
+
+import (
+	"crypto/x509"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// selectedPoolKey is the context data key under which select records the
+// certificates it selected and its policy, see PoolCertificates and PoolPolicy.
+const selectedPoolKey = "selected-pool"
+
+// selectedPool is what select records about the pool it built.
+type selectedPool struct {
+	certs  []*x509.Certificate // Certificates selected from the TSLs
+	policy string
+}
+
+// PoolCertificates returns the certificates in the pool built by the last
+// select step: those selected from the TSLs followed by the local trust
+// anchors added with extra-roots.
+func PoolCertificates(ctx *Context) []*x509.Certificate {
+	if ctx == nil {
+		return nil
+	}
+	pool, _ := dataValue[selectedPool](ctx, selectedPoolKey)
+	certs := slices.Clone(pool.certs)
+	for _, anchor := range LocalTrustAnchors(ctx) {
+		certs = append(certs, anchor.Certificate)
+	}
+	return certs
+}
+
+// PoolPolicy returns the policy the last select step selected its pool with,
+// in the syntax of the select step arguments, e.g. "reference-depth:1
+// status:http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/granted". Options
+// that do not change which certificates are selected, such as cache-dir and
+// pool-log, are left out.
+func PoolPolicy(ctx *Context) string {
+	if ctx == nil {
+		return ""
+	}
+	pool, _ := dataValue[selectedPool](ctx, selectedPoolKey)
+	return pool.policy
+}
+
+// recordSelectedPool stores the certificates selected by a select step with
+// opts in ctx.
+func recordSelectedPool(ctx *Context, opts SelectOptions, selected []*x509.Certificate) {
+	ctx.SetData(selectedPoolKey, selectedPool{certs: selected, policy: selectPolicy(opts)})
+}
+
+// selectPolicy describes the selection policy of opts for PoolPolicy.
+func selectPolicy(opts SelectOptions) string {
+	policy := []string{fmt.Sprintf("reference-depth:%d", opts.ReferenceDepth)}
+	add := func(name string, values []string) {
+		for _, value := range values {
+			policy = append(policy, name+":"+value)
+		}
+	}
+	add("service-type", opts.ServiceTypes)
+	add("status", opts.Statuses)
+	if opts.MatchAllStatuses {
+		policy = append(policy, "status-logic:and")
+	}
+	if opts.RequireCA {
+		policy = append(policy, "require-ca")
+	}
+	add("require-eku", opts.RequireEKU)
+	if opts.MinRSABits > 0 {
+		policy = append(policy, fmt.Sprintf("min-rsa-bits:%d", opts.MinRSABits))
+	}
+	if len(opts.AllowAlgorithms) > 0 {
+		policy = append(policy, "allow-alg:"+strings.Join(opts.AllowAlgorithms, ","))
+	}
+	add("issuer-contains", opts.IssuerContains)
+	if opts.StatusConflict != "" {
+		policy = append(policy, "status-conflict:"+opts.StatusConflict)
+	}
+	add("extra-roots", opts.ExtraRoots)
+	return strings.Join(policy, " ")
+}
@@ -2,10 +2,14 @@ package pipeline
 
 import (
 	"crypto/x509"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/sirosfoundation/g119612/pkg/etsi119612"
 	"github.com/sirosfoundation/g119612/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestSelectCertPoolWithFilters(t *testing.T) {
@@ -125,3 +129,90 @@ func createTestTSLWithCert(cert *x509.Certificate, serviceType, status string) *
 }
 
 // Using TestCertBase64 and TestCert from test_utils.go
+
+func TestSelectCertPoolWithPolicyFile(t *testing.T) {
+	pl := &Pipeline{Logger: logging.SilentLogger()}
+	ctx := &Context{}
+	ctx.EnsureTSLStack()
+	ctx.TSLs.Push(createTestTSLWithCert(TestCert, "http://uri.etsi.org/TrstSvc/Svctype/TSA/QTST", etsi119612.ServiceStatusGranted))
+
+	dir := t.TempDir()
+	qcPolicy := filepath.Join(dir, "qc.yaml")
+	require.NoError(t, os.WriteFile(qcPolicy, []byte("serviceTypes:\n  - http://uri.etsi.org/TrstSvc/Svctype/CA/QC\n"), 0600))
+	tsaPolicy := filepath.Join(dir, "tsa.json")
+	require.NoError(t, os.WriteFile(tsaPolicy, []byte(`{"serviceTypes":["http://uri.etsi.org/TrstSvc/Svctype/TSA/QTST"]}`), 0600))
+
+	opts, err := parseSelectArgs(pl, []string{"reference-depth:1", "policy-file:" + qcPolicy})
+	require.NoError(t, err)
+	assert.Equal(t, 1, opts.ReferenceDepth)
+	assert.Equal(t, []string{"http://uri.etsi.org/TrstSvc/Svctype/CA/QC"}, opts.ServiceTypes)
+	assert.Equal(t, []string{etsi119612.ServiceStatusGranted}, opts.Statuses)
+
+	result, err := SelectCertPool(pl, ctx.Copy(), "policy-file:"+qcPolicy)
+	require.NoError(t, err)
+	assert.True(t, result.CertPool.Equal(x509.NewCertPool()), "no QC services in the list")
+
+	result, err = SelectCertPool(pl, ctx.Copy(), "policy-file:"+tsaPolicy)
+	require.NoError(t, err)
+	assert.False(t, result.CertPool.Equal(x509.NewCertPool()))
+
+	_, err = SelectCertPool(pl, ctx.Copy(), "policy-file:"+filepath.Join(dir, "missing.yaml"))
+	assert.Error(t, err)
+}
+
+func TestSelectCertPool_PoolSize(t *testing.T) {
+	pl := &Pipeline{Logger: logging.SilentLogger()}
+	cacheDir := t.TempDir()
+	newCtx := func() *Context {
+		ctx := NewContext()
+		ctx.AddTSL(generateTSL("Test Service", "http://uri.etsi.org/TrstSvc/Svctype/CA/QC", []string{TestCertBase64}))
+		return ctx
+	}
+
+	ctx, err := SelectCertPool(pl, newCtx(), "min-certs:1", "max-certs:1")
+	require.NoError(t, err)
+	assert.Equal(t, 1, ctx.Data[certCountKey])
+
+	// A shrunken pool fails the step, also when restored from the cache
+	_, err = SelectCertPool(pl, newCtx(), "cache-dir:"+cacheDir)
+	require.NoError(t, err)
+	for _, extra := range [][]string{nil, {"cache-dir:" + cacheDir}} {
+		_, err = SelectCertPool(pl, newCtx(), append([]string{"min-certs:1400"}, extra...)...)
+		assert.ErrorIs(t, err, ErrPoolSize)
+		assert.ErrorContains(t, err, "1 certificates selected, expected at least 1400")
+	}
+
+	other, _, _, err := GenerateTestCertBase64()
+	require.NoError(t, err)
+	ctx = NewContext()
+	ctx.AddTSL(generateTSL("Test Service", "http://uri.etsi.org/TrstSvc/Svctype/CA/QC", []string{TestCertBase64, other}))
+	_, err = SelectCertPool(pl, ctx, "max-certs:1")
+	assert.ErrorIs(t, err, ErrPoolSize)
+	assert.ErrorContains(t, err, "expected at most 1")
+
+	for _, args := range [][]string{{"min-certs:many"}, {"max-certs:-1"}, {"min-certs:10", "max-certs:5"}} {
+		_, err := SelectCertPool(pl, newCtx(), args...)
+		assert.ErrorIs(t, err, ErrInvalidArguments, "%q", args)
+	}
+}
+
+func TestSelectCertPool_LegacyStatus(t *testing.T) {
+	pl := &Pipeline{Logger: logging.SilentLogger()}
+	selected := func(serviceType, status string, args ...string) int {
+		ctx := NewContext()
+		ctx.AddTSL(createTestTSLWithCert(TestCert, serviceType, status))
+		ctx, err := SelectCertPool(pl, ctx, args...)
+		require.NoError(t, err)
+		return ctx.Data[certCountKey].(int)
+	}
+	qc := "http://uri.etsi.org/TrstSvc/Svctype/CA/QC"
+	granted := "status:http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/granted"
+
+	// Historic lists use the statuses of Directive 1999/93/EC
+	assert.Equal(t, 1, selected(qc, "http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/undersupervision", granted))
+	assert.Equal(t, 1, selected(qc, "http://uri.etsi.org/TrstSvc/eSigDir-1999-93-EC-TrustedList/Svcstatus/accredited", granted))
+	assert.Equal(t, 0, selected(qc, "http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/supervisionceased", granted))
+	assert.Equal(t, 0, selected("http://uri.etsi.org/TrstSvc/Svctype/CA/PKC", "http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/undersupervision", granted))
+	assert.Equal(t, 0, selected(qc, "http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/undersupervision",
+		"status:http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/accredited"))
+}
@@ -0,0 +1,66 @@
+package pipeline
+
+import (
+	"crypto/x509"
+	"slices"
+	"strings"
+
+	"github.com/sirosfoundation/g119612/pkg/validation"
+)
+
+// serviceTypeKeyUsages maps service type URI prefixes to the extended key usage
+// required of certificates verified against services of that type. Service
+// types without an entry, such as CAs, accept any usage.
+var serviceTypeKeyUsages = []struct {
+	prefix string
+	usage  x509.ExtKeyUsage
+}{
+	{"http://uri.etsi.org/TrstSvc/Svctype/TSA", x509.ExtKeyUsageTimeStamping},
+	{"http://uri.etsi.org/TrstSvc/Svctype/Certstatus/OCSP", x509.ExtKeyUsageOCSPSigning},
+}
+
+// verifyKeyUsages returns the KeyUsages of the VerifyOptions built by select:
+// the require-eku usages if given, otherwise the usages of the selected service
+// types. A selection that includes CAs or is not restricted to service types
+// accepts any usage, as x509.Verify would otherwise only accept serverAuth.
+func verifyKeyUsages(opts SelectOptions) []x509.ExtKeyUsage {
+	var usages []x509.ExtKeyUsage
+	for _, name := range opts.RequireEKU {
+		if usage, err := validation.ParseExtKeyUsage(name); err == nil && !slices.Contains(usages, usage) {
+			usages = append(usages, usage)
+		}
+	}
+	if len(usages) > 0 {
+		return usages
+	}
+
+	for _, serviceType := range opts.ServiceTypes {
+		usage := x509.ExtKeyUsageAny
+		for _, entry := range serviceTypeKeyUsages {
+			if strings.HasPrefix(serviceType, entry.prefix) {
+				usage = entry.usage
+				break
+			}
+		}
+		if usage == x509.ExtKeyUsageAny {
+			return []x509.ExtKeyUsage{x509.ExtKeyUsageAny}
+		}
+		if !slices.Contains(usages, usage) {
+			usages = append(usages, usage)
+		}
+	}
+	if len(usages) == 0 {
+		return []x509.ExtKeyUsage{x509.ExtKeyUsageAny}
+	}
+	return usages
+}
+
+// newVerifyOptions builds the VerifyOptions preset for the pool of ctx.
+func newVerifyOptions(ctx *Context, opts SelectOptions) *x509.VerifyOptions {
+	return &x509.VerifyOptions{
+		Roots:         ctx.CertPool,
+		Intermediates: x509.NewCertPool(),
+		KeyUsages:     verifyKeyUsages(opts),
+		CurrentTime:   opts.At,
+	}
+}
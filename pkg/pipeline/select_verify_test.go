@@ -0,0 +1,124 @@
+package pipeline
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/sirosfoundation/g119612/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// issueTestCert issues a certificate from template, signed by parent and its
+// key, or self-signed if parent is nil.
+func issueTestCert(t *testing.T, name string, template *x509.Certificate, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template.SerialNumber = big.NewInt(time.Now().UnixNano())
+	template.Subject = pkix.Name{CommonName: name}
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(24 * time.Hour)
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert, key
+}
+
+func TestContextVerify(t *testing.T) {
+	caTemplate := func() *x509.Certificate {
+		return &x509.Certificate{BasicConstraintsValid: true, IsCA: true, KeyUsage: x509.KeyUsageCertSign}
+	}
+	root, rootKey := issueTestCert(t, "Root CA", caTemplate(), nil, nil)
+	intermediate, intermediateKey := issueTestCert(t, "Issuing CA", caTemplate(), root, rootKey)
+	leaf, _ := issueTestCert(t, "www.example.com", &x509.Certificate{
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:    []string{"www.example.com"},
+	}, intermediate, intermediateKey)
+
+	pl := &Pipeline{Logger: logging.SilentLogger()}
+	selectRoot := func(args ...string) *Context {
+		ctx := NewContext()
+		ctx.AddTSL(generateTSL("Root Service", "http://uri.etsi.org/TrstSvc/Svctype/CA/QC",
+			[]string{base64.StdEncoding.EncodeToString(root.Raw)}))
+		ctx, err := SelectCertPool(pl, ctx, args...)
+		require.NoError(t, err)
+		require.NotNil(t, ctx.VerifyOptions)
+		return ctx
+	}
+
+	t.Run("Not_Selected", func(t *testing.T) {
+		_, err := NewContext().Verify(leaf, intermediate)
+		assert.ErrorIs(t, err, ErrNoCertPool)
+	})
+
+	t.Run("Chain_Through_Intermediate", func(t *testing.T) {
+		ctx := selectRoot()
+		assert.Equal(t, []x509.ExtKeyUsage{x509.ExtKeyUsageAny}, ctx.VerifyOptions.KeyUsages)
+
+		chains, err := ctx.Verify(leaf, intermediate)
+		require.NoError(t, err)
+		require.Len(t, chains, 1)
+		assert.Equal(t, []*x509.Certificate{leaf, intermediate, root}, chains[0])
+
+		// The intermediate is not added to the options of the context
+		_, err = ctx.Verify(leaf)
+		assert.Error(t, err)
+	})
+
+	t.Run("Key_Usage_From_Service_Type", func(t *testing.T) {
+		ctx := selectRoot("require-eku:timeStamping")
+		assert.Equal(t, []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping}, ctx.VerifyOptions.KeyUsages)
+		_, err := ctx.Verify(leaf, intermediate)
+		assert.Error(t, err)
+	})
+
+	t.Run("At", func(t *testing.T) {
+		at := time.Now().Add(48 * time.Hour).UTC().Format(time.RFC3339)
+		ctx := selectRoot("at:" + at)
+		assert.Equal(t, at, ctx.VerifyOptions.CurrentTime.Format(time.RFC3339))
+		_, err := ctx.Verify(leaf, intermediate)
+		var invalid x509.CertificateInvalidError
+		require.ErrorAs(t, err, &invalid)
+		assert.Equal(t, x509.Expired, invalid.Reason)
+
+		_, err = SelectCertPool(pl, NewContext(), "at:yesterday")
+		assert.ErrorIs(t, err, ErrInvalidArguments)
+	})
+}
+
+func TestVerifyKeyUsages(t *testing.T) {
+	tests := []struct {
+		name string
+		opts SelectOptions
+		want []x509.ExtKeyUsage
+	}{
+		{"No_Filter", SelectOptions{}, []x509.ExtKeyUsage{x509.ExtKeyUsageAny}},
+		{"CA", SelectOptions{ServiceTypes: []string{"http://uri.etsi.org/TrstSvc/Svctype/CA/QC"}}, []x509.ExtKeyUsage{x509.ExtKeyUsageAny}},
+		{"TSA", SelectOptions{ServiceTypes: []string{"http://uri.etsi.org/TrstSvc/Svctype/TSA/QTST"}}, []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping}},
+		{
+			"TSA_And_OCSP",
+			SelectOptions{ServiceTypes: []string{"http://uri.etsi.org/TrstSvc/Svctype/TSA/QTST", "http://uri.etsi.org/TrstSvc/Svctype/Certstatus/OCSP/QC", "http://uri.etsi.org/TrstSvc/Svctype/TSA"}},
+			[]x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping, x509.ExtKeyUsageOCSPSigning},
+		},
+		{"TSA_And_CA", SelectOptions{ServiceTypes: []string{"http://uri.etsi.org/TrstSvc/Svctype/TSA/QTST", "http://uri.etsi.org/TrstSvc/Svctype/CA/PKC"}}, []x509.ExtKeyUsage{x509.ExtKeyUsageAny}},
+		{"Require_EKU", SelectOptions{RequireEKU: []string{"serverAuth", "clientAuth"}, ServiceTypes: []string{"http://uri.etsi.org/TrstSvc/Svctype/TSA"}}, []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, verifyKeyUsages(tt.opts))
+		})
+	}
+}
@@ -0,0 +1,281 @@
+package pipeline
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirosfoundation/g119612/pkg/logging"
+)
+
+// BrowsePath is the path the Server mounts the browse UI (see BrowseHandler) at.
+const BrowsePath = "/ui"
+
+// RefreshHookPath is the path of the webhook that requests an immediate run
+// of a Server, see WithWebhookToken.
+const RefreshHookPath = "/hooks/refresh"
+
+// MirrorPath is the path the Server mounts a local TSL mirror at, see WithMirror.
+const MirrorPath = "/mirror"
+
+// Server processes a pipeline and serves the state of its last successful run
+// over HTTP. It is the basis of the serve mode of tsl-tool: Run is called to
+// (re)load the lists, and Handler exposes the live state while it is loaded.
+//
+// A failed run leaves the state of the previous successful run in place, so a
+// temporary upstream outage does not empty the served content. A Server is safe
+// for concurrent use.
+//
+// Watch keeps the state current: it reruns the pipeline at an interval and
+// whenever a refresh is requested, by Refresh or by an upstream operator
+// calling the webhook at RefreshHookPath. Bursts of refresh requests can be
+// coalesced into one run with WithDebounce, and WithMinInterval spaces runs
+// apart; in any case at most one run is pending while another one is running.
+type Server struct {
+	pl          *Pipeline
+	hookToken   string
+	mirrorDir   string
+	refreshReq  chan struct{}
+	debounce    time.Duration
+	minInterval time.Duration
+
+	runMu sync.Mutex // serializes runs
+
+	mu      sync.RWMutex
+	ctx     *Context
+	lastRun time.Time
+	lastErr error
+}
+
+// NewServer creates a Server for a loaded pipeline. Nothing is served until
+// the first successful Run.
+func NewServer(pl *Pipeline) *Server {
+	return &Server{pl: pl, refreshReq: make(chan struct{}, 1)}
+}
+
+// WithWebhookToken enables the refresh webhook. A POST to RefreshHookPath
+// carrying the header "Authorization: Bearer <token>" requests a run like
+// Refresh and is answered with 202 Accepted; requests without the token are
+// refused with 401 Unauthorized. An empty token disables the webhook, which is
+// the default. The webhook only has an effect while Watch is running.
+//
+// Returns:
+//   - *Server: The server, for chaining
+func (s *Server) WithWebhookToken(token string) *Server {
+	s.hookToken = token
+	return s
+}
+
+// WithMirror serves the local mirror written by the mirror step to dir under
+// MirrorPath, with its manifest at MirrorPath/manifest.json, so other hosts
+// can fetch the mirrored documents. The files are served by PublishedHandler.
+// An empty dir serves no mirror, which is the default.
+//
+// Returns:
+//   - *Server: The server, for chaining
+func (s *Server) WithMirror(dir string) *Server {
+	s.mirrorDir = dir
+	return s
+}
+
+// WithDebounce makes Watch wait until no refresh has been requested for d
+// before running, so a burst of requests, such as one per edited metadata
+// file, causes a single run. Zero, the default, runs right away.
+//
+// Returns:
+//   - *Server: The server, for chaining
+func (s *Server) WithDebounce(d time.Duration) *Server {
+	s.debounce = d
+	return s
+}
+
+// WithMinInterval makes Watch start runs at least d apart. A run requested
+// earlier is delayed, not dropped. Zero, the default, imposes no minimum.
+//
+// Returns:
+//   - *Server: The server, for chaining
+func (s *Server) WithMinInterval(d time.Duration) *Server {
+	s.minInterval = d
+	return s
+}
+
+// Refresh requests a run from Watch without waiting for it. Requests made while
+// a run is pending are merged into that run, so at most one run is queued.
+func (s *Server) Refresh() {
+	select {
+	case s.refreshReq <- struct{}{}:
+	default:
+	}
+}
+
+// Watch runs the pipeline every interval and whenever a refresh is requested,
+// until ctx is done. An interval of zero or less disables polling, so only
+// refresh requests trigger runs. Refresh requests are debounced and all runs
+// throttled as configured with WithDebounce and WithMinInterval. Errors of runs
+// are recorded (see Status) and logged, but do not stop watching.
+func (s *Server) Watch(ctx context.Context, interval time.Duration) {
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	var lastStart time.Time
+	for {
+		var trigger string
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick:
+			trigger = "interval"
+		case <-s.refreshReq:
+			trigger = "refresh"
+			if !s.settle(ctx) {
+				return
+			}
+		}
+		if !lastStart.IsZero() && !sleepContext(ctx, time.Until(lastStart.Add(s.minInterval))) {
+			return
+		}
+		lastStart = time.Now()
+		s.logRun(trigger)
+
+		// A tick that fell due during the run is covered by it
+		select {
+		case <-tick:
+		default:
+		}
+	}
+}
+
+// settle waits until no refresh has been requested for the debounce period.
+// It returns false if ctx is done first.
+func (s *Server) settle(ctx context.Context) bool {
+	if s.debounce <= 0 {
+		return true
+	}
+	timer := time.NewTimer(s.debounce)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-s.refreshReq:
+			timer.Reset(s.debounce)
+		case <-timer.C:
+			return true
+		}
+	}
+}
+
+// sleepContext waits for d, returning false if ctx is done first.
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// logRun runs the pipeline for Watch and logs the outcome.
+func (s *Server) logRun(trigger string) {
+	if s.pl.Logger != nil {
+		s.pl.Logger.Info("Running pipeline", logging.F("trigger", trigger))
+	}
+	if err := s.Run(); err == nil && s.pl.Logger != nil {
+		s.pl.Logger.Info("Pipeline run completed",
+			logging.F("trigger", trigger),
+			logging.F("tsl_count", len(publishableTSLs(s.Context()))))
+	}
+}
+
+// Run processes the pipeline with a new context. On success the resulting
+// context replaces the served state. Concurrent calls run one after the other.
+//
+// Returns:
+//   - error: The error of the pipeline, nil on success
+func (s *Server) Run() error {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+
+	ctx, err := s.pl.Process(NewContext())
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastRun = time.Now()
+	s.lastErr = err
+	if err != nil {
+		if s.pl.Logger != nil {
+			s.pl.Logger.Error("Pipeline run failed, keeping the previous state",
+				logging.F("error", err))
+		}
+		return err
+	}
+	s.ctx = ctx
+	return nil
+}
+
+// Context returns the context of the last successful run, nil if there is none.
+// The context must not be modified.
+func (s *Server) Context() *Context {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.ctx
+}
+
+// Status returns the time of the last run and its error, the zero time if
+// Run has not been called.
+func (s *Server) Status() (time.Time, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.lastRun, s.lastErr
+}
+
+// Handler returns the http.Handler of the server. It serves the browse UI
+// below BrowsePath, redirects "/" there, serves the TSL tree as JSON at
+// TreePath and TSLPath/{id} (see TreeHandler) and, if enabled, the refresh
+// webhook at RefreshHookPath.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle(BrowsePath+"/", BrowseHandler(BrowsePath, s.Context))
+	tree := TreeHandler(s.Context)
+	mux.Handle("GET "+TreePath, tree)
+	mux.Handle("GET "+TSLPath+"/{id}", tree)
+	mux.Handle("GET /{$}", http.RedirectHandler(BrowsePath+"/", http.StatusFound))
+	if s.mirrorDir != "" {
+		mux.Handle(MirrorPath+"/", http.StripPrefix(MirrorPath, PublishedHandler(s.mirrorDir)))
+	}
+	if s.hookToken != "" {
+		mux.HandleFunc("POST "+RefreshHookPath, s.refreshHook)
+	}
+	return mux
+}
+
+// refreshHook serves the refresh webhook.
+func (s *Server) refreshHook(w http.ResponseWriter, r *http.Request) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.hookToken)) != 1 {
+		if s.pl.Logger != nil {
+			s.pl.Logger.Warn("Refused refresh webhook request",
+				logging.F("remote", r.RemoteAddr))
+		}
+		w.Header().Set("WWW-Authenticate", `Bearer realm="tsl-tool"`)
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	if s.pl.Logger != nil {
+		s.pl.Logger.Info("Refresh requested by webhook",
+			logging.F("remote", r.RemoteAddr))
+	}
+	s.Refresh()
+	w.WriteHeader(http.StatusAccepted)
+}
@@ -0,0 +1,236 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirosfoundation/g119612/pkg/etsi119612"
+	"github.com/sirosfoundation/g119612/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer(t *testing.T) {
+	failRun := false
+	RegisterFunction("server-test-load", func(pl *Pipeline, ctx *Context, args ...string) (*Context, error) {
+		if failRun {
+			return ctx, errors.New("upstream unavailable")
+		}
+		return ctx.AddTSL(generateTSL("Served Service", "http://uri.etsi.org/TrstSvc/Svctype/CA/QC", []string{TestCertBase64})), nil
+	})
+	pl := &Pipeline{Pipes: []Pipe{{MethodName: "server-test-load"}}, Logger: logging.SilentLogger()}
+	s := NewServer(pl)
+	assert.Nil(t, s.Context())
+	last, err := s.Status()
+	assert.True(t, last.IsZero())
+	assert.NoError(t, err)
+
+	server := httptest.NewServer(s.Handler())
+	defer server.Close()
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(server.URL + "/")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusFound, resp.StatusCode)
+	assert.Equal(t, BrowsePath+"/", resp.Header.Get("Location"))
+
+	require.NoError(t, s.Run())
+	ctx := s.Context()
+	require.NotNil(t, ctx)
+	last, err = s.Status()
+	assert.False(t, last.IsZero())
+	assert.NoError(t, err)
+
+	status, body, _ := browseGet(t, server.URL+BrowsePath+"/tsl/0/provider/0/service/0")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, "Served Service")
+
+	// A failed run keeps the previous state
+	failRun = true
+	assert.Error(t, s.Run())
+	assert.Same(t, ctx, s.Context())
+	_, err = s.Status()
+	assert.ErrorContains(t, err, "upstream unavailable")
+	status, _, _ = browseGet(t, server.URL+BrowsePath+"/tsl/0")
+	assert.Equal(t, http.StatusOK, status)
+}
+
+func TestServer_WatchAndWebhook(t *testing.T) {
+	var mu sync.Mutex
+	runs := 0
+	RegisterFunction("server-test-count", func(pl *Pipeline, ctx *Context, args ...string) (*Context, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		runs++
+		return ctx, nil
+	})
+	runCount := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return runs
+	}
+	pl := &Pipeline{Pipes: []Pipe{{MethodName: "server-test-count"}}, Logger: logging.SilentLogger()}
+
+	// The webhook is disabled without a token
+	server := httptest.NewServer(NewServer(pl).Handler())
+	resp, err := http.Post(server.URL+RefreshHookPath, "", nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	server.Close()
+
+	s := NewServer(pl).WithWebhookToken("s3cret")
+	server = httptest.NewServer(s.Handler())
+	defer server.Close()
+	watch, stop := context.WithCancel(context.Background())
+	defer stop()
+	go s.Watch(watch, 0)
+
+	post := func(authorization string) int {
+		request, err := http.NewRequest(http.MethodPost, server.URL+RefreshHookPath, nil)
+		require.NoError(t, err)
+		if authorization != "" {
+			request.Header.Set("Authorization", authorization)
+		}
+		resp, err := http.DefaultClient.Do(request)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	for _, authorization := range []string{"", "Bearer wrong", "Basic s3cret", "s3cret"} {
+		assert.Equal(t, http.StatusUnauthorized, post(authorization), authorization)
+	}
+	assert.Equal(t, 0, runCount())
+
+	assert.Equal(t, http.StatusAccepted, post("Bearer s3cret"))
+	require.Eventually(t, func() bool { return runCount() == 1 }, 5*time.Second, 10*time.Millisecond)
+	assert.NotNil(t, s.Context())
+
+	resp, err = http.Get(server.URL + RefreshHookPath)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+
+	// Polling reruns the pipeline at the interval
+	stop()
+	polled, stopPolling := context.WithCancel(context.Background())
+	defer stopPolling()
+	go s.Watch(polled, 10*time.Millisecond)
+	require.Eventually(t, func() bool { return runCount() >= 3 }, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestServer_RefreshMerged(t *testing.T) {
+	s := NewServer(&Pipeline{Logger: logging.SilentLogger()})
+	s.Refresh()
+	s.Refresh()
+	s.Refresh()
+	assert.Len(t, s.refreshReq, 1)
+}
+
+// countingServer returns a Server whose pipeline records the start time of
+// each run and blocks each run until release is closed or receives.
+func countingServer(name string, release <-chan struct{}) (*Server, func() []time.Time) {
+	var mu sync.Mutex
+	var starts []time.Time
+	RegisterFunction(name, func(pl *Pipeline, ctx *Context, args ...string) (*Context, error) {
+		mu.Lock()
+		starts = append(starts, time.Now())
+		mu.Unlock()
+		if release != nil {
+			<-release
+		}
+		return ctx, nil
+	})
+	runs := func() []time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return slices.Clone(starts)
+	}
+	return NewServer(&Pipeline{Pipes: []Pipe{{MethodName: name}}, Logger: logging.SilentLogger()}), runs
+}
+
+func TestServer_WatchDebounce(t *testing.T) {
+	s, runs := countingServer("server-test-debounce", nil)
+	s.WithDebounce(100 * time.Millisecond)
+	watch, stop := context.WithCancel(context.Background())
+	defer stop()
+	go s.Watch(watch, 0)
+
+	// A burst of refreshes results in one run after the burst
+	burst := time.Now()
+	for range 5 {
+		s.Refresh()
+		time.Sleep(20 * time.Millisecond)
+	}
+	require.Eventually(t, func() bool { return len(runs()) == 1 }, 5*time.Second, 10*time.Millisecond)
+	assert.GreaterOrEqual(t, runs()[0].Sub(burst), 180*time.Millisecond)
+	time.Sleep(200 * time.Millisecond)
+	assert.Len(t, runs(), 1)
+}
+
+func TestServer_WatchMinInterval(t *testing.T) {
+	s, runs := countingServer("server-test-min-interval", nil)
+	s.WithMinInterval(200 * time.Millisecond)
+	watch, stop := context.WithCancel(context.Background())
+	defer stop()
+	go s.Watch(watch, 0)
+
+	s.Refresh()
+	require.Eventually(t, func() bool { return len(runs()) == 1 }, 5*time.Second, 10*time.Millisecond)
+	s.Refresh()
+	time.Sleep(50 * time.Millisecond)
+	assert.Len(t, runs(), 1, "run started before the minimum interval")
+	require.Eventually(t, func() bool { return len(runs()) == 2 }, 5*time.Second, 10*time.Millisecond)
+	started := runs()
+	assert.GreaterOrEqual(t, started[1].Sub(started[0]), 200*time.Millisecond)
+}
+
+func TestServer_WatchQueuesOneRun(t *testing.T) {
+	release := make(chan struct{})
+	s, runs := countingServer("server-test-queue", release)
+	watch, stop := context.WithCancel(context.Background())
+	defer stop()
+	go s.Watch(watch, 0)
+
+	s.Refresh()
+	require.Eventually(t, func() bool { return len(runs()) == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	// Refreshes requested during a run are merged into one pending run
+	for range 10 {
+		s.Refresh()
+	}
+	release <- struct{}{}
+	require.Eventually(t, func() bool { return len(runs()) == 2 }, 5*time.Second, 10*time.Millisecond)
+	release <- struct{}{}
+	time.Sleep(100 * time.Millisecond)
+	assert.Len(t, runs(), 2)
+	close(release)
+}
+
+func TestServer_Mirror(t *testing.T) {
+	dir := t.TempDir()
+	tsl := &etsi119612.TSL{Source: "https://example.com/tsl.xml", Raw: []byte("<tsl/>")}
+	manifest, err := etsi119612.WriteMirror(dir, []*etsi119612.TSL{tsl})
+	require.NoError(t, err)
+
+	server := httptest.NewServer(NewServer(&Pipeline{Logger: logging.SilentLogger()}).WithMirror(dir).Handler())
+	defer server.Close()
+	status, body, _ := browseGet(t, server.URL+MirrorPath+"/"+manifest.TSLs[0].File)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "<tsl/>", body)
+	status, body, _ = browseGet(t, server.URL+MirrorPath+"/"+etsi119612.MirrorManifestFile)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, "https://example.com/tsl.xml")
+
+	// Without WithMirror nothing is served
+	plain := httptest.NewServer(NewServer(&Pipeline{}).Handler())
+	defer plain.Close()
+	status, _, _ = browseGet(t, plain.URL+MirrorPath+"/"+etsi119612.MirrorManifestFile)
+	assert.Equal(t, http.StatusNotFound, status)
+}
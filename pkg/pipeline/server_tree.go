@@ -0,0 +1,179 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/sirosfoundation/g119612/pkg/etsi119612"
+	"github.com/sirosfoundation/g119612/pkg/etsi119612/jsonmodel"
+	"github.com/sirosfoundation/g119612/pkg/etsi119612/uri"
+)
+
+// TreePath is the path the Server serves the loaded TSLs at as a JSON graph,
+// see TreeHandler.
+const TreePath = "/tree"
+
+// TSLPath is the path below which the Server serves single TSLs as JSON, see
+// TreeHandler.
+const TSLPath = "/tsl"
+
+// Signature statuses of a TreeNode.
+const (
+	SignatureVerified = "verified" // The signature was verified when the TSL was loaded
+	SignatureUnsigned = "unsigned" // The TSL has no signature
+)
+
+// TreeNode is a TSL in the JSON graph served at TreePath, with the TSLs it
+// references as its children.
+type TreeNode struct {
+	ID int `json:"id"` // Position in the loaded TSLs, as in TSLPath/{id} and the browse UI
+	jsonmodel.Summary
+	Type      string        `json:"type,omitempty"` // Label of the TSLType, see uri.Label
+	Providers int           `json:"providers"`      // Number of trust service providers
+	Signature TreeSignature `json:"signature"`
+	Children  []TreeNode    `json:"children,omitempty"`
+}
+
+// TreeSignature is the signature status of a TSL in a TreeNode.
+type TreeSignature struct {
+	Status        string     `json:"status"`                   // SignatureVerified or SignatureUnsigned
+	Signer        string     `json:"signer,omitempty"`         // Subject of the signer certificate
+	Algorithm     string     `json:"algorithm,omitempty"`      // SignatureMethod algorithm
+	SigningTime   *time.Time `json:"signing_time,omitempty"`   // XAdES SigningTime
+	ChainVerified bool       `json:"chain_verified,omitempty"` // The signer chains to the signer roots, see TSLFetchOptions.SignerRoots
+}
+
+// TreeTSL is the document served at TSLPath/{id}: the node of the TSL, with
+// its children but not their descendants, and the TSL in the JSON
+// representation of package jsonmodel.
+type TreeTSL struct {
+	TreeNode
+	TSL *etsi119612.TSL `json:"tsl"`
+}
+
+// TSLTreeNodes returns the TSLs of ctx arranged by their references, like the
+// index of the browse UI: one node per TSL tree of the context, followed by
+// the TSLs outside the trees.
+func TSLTreeNodes(ctx *Context) []TreeNode {
+	if ctx == nil {
+		return nil
+	}
+	nodes := []TreeNode{}
+	for _, n := range browseTree(ctx, "") {
+		nodes = append(nodes, treeNode(n, -1))
+	}
+	return nodes
+}
+
+// treeNode converts a node of browseTree and its descendants up to depth
+// levels below it, all of them if depth is negative.
+func treeNode(n browseNode, depth int) TreeNode {
+	node := TreeNode{
+		ID:        n.ID,
+		Summary:   n.TSL.JSONSummary(),
+		Providers: n.TSL.NumberOfTrustServiceProviders(),
+		Signature: treeSignature(n.TSL),
+	}
+	if info := n.TSL.StatusList.TslSchemeInformation; info != nil && info.TslTSLType != "" {
+		node.Type = uri.Label(info.TslTSLType)
+	}
+	if depth != 0 {
+		for _, child := range n.Children {
+			node.Children = append(node.Children, treeNode(child, depth-1))
+		}
+	}
+	return node
+}
+
+// treeSignature describes the signature of tsl.
+func treeSignature(tsl *etsi119612.TSL) TreeSignature {
+	if !tsl.Signed {
+		return TreeSignature{Status: SignatureUnsigned}
+	}
+	sig := TreeSignature{Status: SignatureVerified}
+	if tsl.Signer.Raw != nil {
+		sig.Signer = tsl.Signer.Subject.String()
+	}
+	if info := tsl.SignatureInfo(); info != nil {
+		sig.Algorithm = info.SignatureAlgorithm
+		if !info.SigningTime.IsZero() {
+			sig.SigningTime = &info.SigningTime
+		}
+		sig.ChainVerified = len(info.Chain) > 0
+	}
+	return sig
+}
+
+// TreeHandler returns an http.Handler serving the loaded TSLs as JSON for
+// monitoring dashboards visualizing the trust topology:
+//
+//   - GET TreePath: The TSL trees as an array of TreeNode
+//   - GET TSLPath/{id}: A single TSL as TreeTSL
+//
+// The IDs are those of the browse UI (see BrowseHandler), so they may refer
+// to another TSL once the context is replaced. state is called for every
+// request; while it returns nil, requests are answered with 503 Service
+// Unavailable.
+//
+// Parameters:
+//   - state: Returns the context to serve
+//
+// Returns:
+//   - http.Handler: A handler serving TreePath and the paths below TSLPath
+func TreeHandler(state func() *Context) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+TreePath, func(w http.ResponseWriter, r *http.Request) {
+		ctx := state()
+		if ctx == nil {
+			http.Error(w, "no TSLs loaded yet", http.StatusServiceUnavailable)
+			return
+		}
+		writeTreeJSON(w, TSLTreeNodes(ctx))
+	})
+	mux.HandleFunc("GET "+TSLPath+"/{id}", func(w http.ResponseWriter, r *http.Request) {
+		ctx := state()
+		if ctx == nil {
+			http.Error(w, "no TSLs loaded yet", http.StatusServiceUnavailable)
+			return
+		}
+		id, ok := browseIndex(r.PathValue("id"), len(publishableTSLs(ctx)))
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		var found *browseNode
+		var find func(nodes []browseNode)
+		find = func(nodes []browseNode) {
+			for i := range nodes {
+				if found != nil {
+					return
+				}
+				if nodes[i].ID == id {
+					found = &nodes[i]
+					return
+				}
+				find(nodes[i].Children)
+			}
+		}
+		find(browseTree(ctx, ""))
+		if found == nil {
+			http.NotFound(w, r)
+			return
+		}
+		writeTreeJSON(w, TreeTSL{TreeNode: treeNode(*found, 1), TSL: found.TSL})
+	})
+	return mux
+}
+
+// writeTreeJSON writes v as indented JSON, answering 500 if it cannot be
+// marshalled.
+func writeTreeJSON(w http.ResponseWriter, v any) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(append(data, '\n'))
+}
@@ -0,0 +1,104 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/sirosfoundation/g119612/pkg/etsi119612"
+	"github.com/sirosfoundation/g119612/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTreeHandler(t *testing.T) {
+	root := generateTSL("Root Service", "http://uri.etsi.org/TrstSvc/Svctype/CA/QC", []string{TestCertBase64})
+	root.Source = "https://example.com/lotl.xml"
+	info := root.StatusList.TslSchemeInformation
+	info.TslSchemeTerritory = "EU"
+	info.TSLSequenceNumber = 7
+	info.TslTSLType = "http://uri.etsi.org/TrstSvc/TrustedList/TSLType/EUlistofthelists"
+	info.ListIssueDateTime = "2026-01-01T00:00:00Z"
+	info.TslNextUpdate = &etsi119612.NextUpdateType{DateTime: "2026-07-01T00:00:00Z"}
+	root.Signed = true
+	root.Signer = *TestCert
+	ref := generateTSL("Referenced Service", "http://uri.etsi.org/TrstSvc/Svctype/TSA/QTST", nil)
+	ref.Source = "https://example.com/se.xml"
+	ref.StatusList.TslSchemeInformation.TslSchemeTerritory = "SE"
+	nested := generateTSL("Nested Service", "http://uri.etsi.org/TrstSvc/Svctype/TSA/QTST", nil)
+	nested.Source = "https://example.com/se-2.xml"
+	ref.AddReferencedTSL(nested)
+	root.AddReferencedTSL(ref)
+	ctx := NewContext()
+	ctx.AddTSL(root)
+
+	var state *Context
+	server := httptest.NewServer(TreeHandler(func() *Context { return state }))
+	defer server.Close()
+	get := func(path string, v any) int {
+		resp, err := http.Get(server.URL + path)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		if resp.StatusCode == http.StatusOK {
+			assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(v))
+		}
+		return resp.StatusCode
+	}
+
+	var nodes []TreeNode
+	assert.Equal(t, http.StatusServiceUnavailable, get(TreePath, &nodes))
+	assert.Equal(t, http.StatusServiceUnavailable, get(TSLPath+"/0", &TreeTSL{}))
+
+	state = ctx
+	require.Equal(t, http.StatusOK, get(TreePath, &nodes))
+	require.Len(t, nodes, 1)
+	node := nodes[0]
+	assert.Equal(t, "EU", node.Territory)
+	assert.Equal(t, "Test Operator", node.Operator)
+	assert.Equal(t, "https://example.com/lotl.xml", node.Source)
+	assert.Equal(t, 7, node.SequenceNumber)
+	assert.NotEmpty(t, node.Type)
+	assert.Equal(t, "2026-01-01T00:00:00Z", node.ListIssueDateTime)
+	assert.Equal(t, "2026-07-01T00:00:00Z", node.NextUpdate)
+	assert.Equal(t, 1, node.Providers)
+	assert.Equal(t, SignatureVerified, node.Signature.Status)
+	assert.Equal(t, TestCert.Subject.String(), node.Signature.Signer)
+	require.Len(t, node.Children, 1)
+	child := node.Children[0]
+	assert.Equal(t, "SE", child.Territory)
+	assert.Equal(t, SignatureUnsigned, child.Signature.Status)
+	require.Len(t, child.Children, 1)
+	assert.Equal(t, "https://example.com/se-2.xml", child.Children[0].Source)
+
+	// A single TSL with its children, but not their descendants
+	var doc TreeTSL
+	require.Equal(t, http.StatusOK, get(TSLPath+"/"+strconv.Itoa(child.ID), &doc))
+	assert.Equal(t, "https://example.com/se.xml", doc.Source)
+	require.Len(t, doc.Children, 1)
+	assert.Empty(t, doc.Children[0].Children)
+	require.NotNil(t, doc.TSL)
+	assert.Equal(t, "SE", doc.TSL.StatusList.TslSchemeInformation.TslSchemeTerritory)
+
+	assert.Equal(t, http.StatusNotFound, get(TSLPath+"/3", &doc))
+	assert.Equal(t, http.StatusNotFound, get(TSLPath+"/x", &doc))
+}
+
+func TestServer_Tree(t *testing.T) {
+	RegisterFunction("server-tree-test-load", func(pl *Pipeline, ctx *Context, args ...string) (*Context, error) {
+		return ctx.AddTSL(generateTSL("Served Service", "http://uri.etsi.org/TrstSvc/Svctype/CA/QC", []string{TestCertBase64})), nil
+	})
+	s := NewServer(&Pipeline{Pipes: []Pipe{{MethodName: "server-tree-test-load"}}, Logger: logging.SilentLogger()})
+	require.NoError(t, s.Run())
+	server := httptest.NewServer(s.Handler())
+	defer server.Close()
+
+	for _, path := range []string{TreePath, TSLPath + "/0"} {
+		resp, err := http.Get(server.URL + path)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode, path)
+	}
+}
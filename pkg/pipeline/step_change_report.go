@@ -0,0 +1,359 @@
+package pipeline
+
+import (
+	"bytes"
+	"cmp"
+	"crypto/sha256"
+	"crypto/x509"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sirosfoundation/g119612/pkg/etsi119612/uri"
+	"github.com/sirosfoundation/g119612/pkg/logging"
+)
+
+//go:embed templates/changes.html
+var changesHTMLTemplate string
+
+// changesTemplate is the page written by the change-report step.
+var changesTemplate = template.Must(template.New("changes").Funcs(template.FuncMap{
+	"uriLabel": uri.Label,
+	"section": func(heading string, changes []ServiceChange) any {
+		return struct {
+			Heading string
+			Changes []ServiceChange
+		}{heading, changes}
+	},
+}).Parse(changesHTMLTemplate))
+
+// DefaultChangeReportFile is the name of the page written by the
+// change-report step unless another is given.
+const DefaultChangeReportFile = "changes.html"
+
+// ServiceSnapshot is what the change-report step records about a trust
+// service to compare it with the next run. A service is identified by its
+// territory, provider, type and name.
+type ServiceSnapshot struct {
+	Territory          string   `json:"territory"`
+	Provider           string   `json:"provider"`
+	Name               string   `json:"name"`
+	Type               string   `json:"type"`
+	Status             string   `json:"status"`
+	StatusStartingTime string   `json:"status_starting_time,omitempty"`
+	Certificates       []string `json:"certificates,omitempty"` // Hex SHA-256 fingerprints, sorted
+}
+
+// key returns the identity of the service.
+func (s ServiceSnapshot) key() string {
+	return strings.Join([]string{s.Territory, s.Provider, s.Type, s.Name}, "\x00")
+}
+
+// ServiceState is the state file of the change-report step: the services of
+// the last run.
+type ServiceState struct {
+	GeneratedAt time.Time         `json:"generated_at"`
+	Services    []ServiceSnapshot `json:"services"`
+}
+
+// Kinds of ServiceChange.
+const (
+	// ServiceAdded is a service not listed in the previous run.
+	ServiceAdded = "added"
+	// ServiceWithdrawn is a service no longer listed, or whose status
+	// changed from an active to an inactive one such as withdrawn.
+	ServiceWithdrawn = "withdrawn"
+	// ServiceModified is a service with another status, status starting
+	// time or set of certificates than in the previous run.
+	ServiceModified = "modified"
+)
+
+// ServiceChange is a difference between the services of two runs.
+type ServiceChange struct {
+	Kind     string           // ServiceAdded, ServiceWithdrawn or ServiceModified
+	Service  ServiceSnapshot  // The service, as last listed
+	Previous *ServiceSnapshot // The service in the previous run, unless added
+	Details  []string         // What changed, e.g. "status: Granted → Withdrawn"
+}
+
+// DiffServices compares the services of two runs and returns the changes,
+// ordered by kind and then by territory, provider and name. Services with the
+// same identity are paired in the order they are listed.
+func DiffServices(previous, current []ServiceSnapshot) []ServiceChange {
+	before := make(map[string][]ServiceSnapshot)
+	for _, s := range previous {
+		before[s.key()] = append(before[s.key()], s)
+	}
+
+	var changes []ServiceChange
+	for _, s := range current {
+		old := before[s.key()]
+		if len(old) == 0 {
+			changes = append(changes, ServiceChange{Kind: ServiceAdded, Service: s})
+			continue
+		}
+		prev := old[0]
+		before[s.key()] = old[1:]
+		details := serviceDetails(prev, s)
+		if len(details) == 0 {
+			continue
+		}
+		kind := ServiceModified
+		if uri.Category(prev.Status) != "inactive" && uri.Category(s.Status) == "inactive" {
+			kind = ServiceWithdrawn
+		}
+		changes = append(changes, ServiceChange{Kind: kind, Service: s, Previous: &prev, Details: details})
+	}
+	for _, s := range previous {
+		for _, prev := range before[s.key()] {
+			changes = append(changes, ServiceChange{Kind: ServiceWithdrawn, Service: prev, Previous: &prev, Details: []string{"no longer listed"}})
+		}
+		delete(before, s.key())
+	}
+
+	order := map[string]int{ServiceAdded: 0, ServiceWithdrawn: 1, ServiceModified: 2}
+	slices.SortStableFunc(changes, func(a, b ServiceChange) int {
+		return cmp.Or(cmp.Compare(order[a.Kind], order[b.Kind]), cmp.Compare(a.Service.key(), b.Service.key()))
+	})
+	return changes
+}
+
+// serviceDetails describes the differences between two states of a service.
+func serviceDetails(prev, cur ServiceSnapshot) []string {
+	var details []string
+	if statusKey(prev.Status) != statusKey(cur.Status) {
+		details = append(details, fmt.Sprintf("status: %s → %s", uri.Label(prev.Status), uri.Label(cur.Status)))
+	}
+	if prev.StatusStartingTime != cur.StatusStartingTime {
+		details = append(details, fmt.Sprintf("status starting time: %s → %s", prev.StatusStartingTime, cur.StatusStartingTime))
+	}
+	for _, fp := range cur.Certificates {
+		if !slices.Contains(prev.Certificates, fp) {
+			details = append(details, "certificate added: "+fp)
+		}
+	}
+	for _, fp := range prev.Certificates {
+		if !slices.Contains(cur.Certificates, fp) {
+			details = append(details, "certificate removed: "+fp)
+		}
+	}
+	return details
+}
+
+// statusKey returns the standard spelling of a status URI, so that the http
+// and https spellings found in lists compare equal.
+func statusKey(status string) string {
+	if def, ok := uri.Lookup(status); ok {
+		return def.URI
+	}
+	return status
+}
+
+// snapshotServices records the services of the TSLs in ctx, ordered by
+// territory, provider and name.
+func snapshotServices(ctx *Context) []ServiceSnapshot {
+	var services []ServiceSnapshot
+	for _, tsl := range publishableTSLs(ctx) {
+		territory := ""
+		if info := tsl.StatusList.TslSchemeInformation; info != nil {
+			territory = info.TslSchemeTerritory
+		}
+		for _, tsp := range tslProviders(tsl) {
+			if tsp == nil {
+				continue
+			}
+			provider := ""
+			if tsp.TslTSPInformation != nil {
+				provider = preferredName(tsp.TslTSPInformation.TSPName, "en")
+			}
+			if tsp.TslTSPServices == nil {
+				continue
+			}
+			for _, svc := range tsp.TslTSPServices.TslTSPService {
+				if svc == nil || svc.TslServiceInformation == nil {
+					continue
+				}
+				info := svc.TslServiceInformation
+				s := ServiceSnapshot{
+					Territory:          territory,
+					Provider:           provider,
+					Name:               preferredName(info.ServiceName, "en"),
+					Type:               info.TslServiceTypeIdentifier,
+					Status:             info.TslServiceStatus,
+					StatusStartingTime: info.StatusStartingTime,
+				}
+				svc.WithCertificates(func(cert *x509.Certificate) {
+					digest := sha256.Sum256(cert.Raw)
+					s.Certificates = append(s.Certificates, hex.EncodeToString(digest[:]))
+				})
+				slices.Sort(s.Certificates)
+				services = append(services, s)
+			}
+		}
+	}
+	slices.SortStableFunc(services, func(a, b ServiceSnapshot) int {
+		return cmp.Compare(a.key(), b.key())
+	})
+	return services
+}
+
+// changeReportOptions are the arguments of the change-report step.
+type changeReportOptions struct {
+	dir   string
+	state string
+	file  string
+	title string
+}
+
+func parseChangeReportArgs(args []string) (changeReportOptions, error) {
+	opts := changeReportOptions{file: DefaultChangeReportFile, title: "Changes to the Trust Services"}
+	for _, arg := range args {
+		switch {
+		case strings.HasPrefix(arg, "state:"):
+			opts.state = strings.TrimPrefix(arg, "state:")
+		case strings.HasPrefix(arg, "file:"):
+			opts.file = strings.TrimPrefix(arg, "file:")
+			if opts.file == "" || opts.file != filepath.Base(opts.file) {
+				return opts, fmt.Errorf("%w: file must be a file name: %s", ErrInvalidArguments, arg)
+			}
+		case strings.HasPrefix(arg, "title:"):
+			opts.title = strings.TrimPrefix(arg, "title:")
+		case opts.dir == "" && !strings.Contains(arg, ":"):
+			opts.dir = arg
+		default:
+			return opts, fmt.Errorf("%w: unknown change-report argument: %s", ErrInvalidArguments, arg)
+		}
+	}
+	if opts.dir == "" {
+		return opts, fmt.Errorf("%w: change-report requires an output directory", ErrInvalidArguments)
+	}
+	if opts.state == "" {
+		return opts, fmt.Errorf("%w: change-report requires state:<file>", ErrInvalidArguments)
+	}
+	return opts, nil
+}
+
+// validateChangeReportArgs is the ArgsValidator of the change-report step.
+func validateChangeReportArgs(args ...string) error {
+	_, err := parseChangeReportArgs(args)
+	return err
+}
+
+// ChangeReport is a pipeline step that writes an HTML page listing the trust
+// services added, withdrawn or modified since the previous run, for
+// publishing next to the index. The services of each run are recorded in a
+// state file, which the next run compares with; on the first run the page
+// states that a baseline was recorded. The page is marked so generate_index
+// links it instead of listing it as a TSL, so the step runs before
+// generate_index. Without TSLs in the context the step fails, leaving the
+// state file as it is.
+//
+// Arguments:
+//   - arg[0]: Directory the page is written to, created if missing
+//   - state:FILE: State file of the previous run, replaced after the page is written (required)
+//   - file:NAME: Name of the page (default DefaultChangeReportFile)
+//   - title:TEXT: Title of the page
+//
+// Example usage in pipeline YAML:
+//
+//   - change-report:
+//   - /var/www/tsl
+//   - state:/var/lib/tsl/services.json
+func ChangeReport(pl *Pipeline, ctx *Context, args ...string) (*Context, error) {
+	opts, err := parseChangeReportArgs(args)
+	if err != nil {
+		return ctx, err
+	}
+	// An empty run would report every service as withdrawn and then added
+	if len(publishableTSLs(ctx)) == 0 {
+		return ctx, ErrNoTSLs
+	}
+
+	var previous *ServiceState
+	data, err := os.ReadFile(opts.state)
+	switch {
+	case err == nil:
+		previous = &ServiceState{}
+		if err := json.Unmarshal(data, previous); err != nil {
+			return ctx, fmt.Errorf("invalid change-report state %s: %w", opts.state, err)
+		}
+	case !errors.Is(err, os.ErrNotExist):
+		return ctx, fmt.Errorf("failed to read change-report state: %w", err)
+	}
+
+	state := ServiceState{GeneratedAt: ctx.Now().UTC().Truncate(time.Second), Services: snapshotServices(ctx)}
+	page := struct {
+		Title       string
+		GeneratedAt time.Time
+		PreviousRun *time.Time
+		Total       int
+		Changes     []ServiceChange
+		Added       []ServiceChange
+		Withdrawn   []ServiceChange
+		Modified    []ServiceChange
+	}{Title: opts.title, GeneratedAt: state.GeneratedAt, Total: len(state.Services)}
+	if previous != nil {
+		page.PreviousRun = &previous.GeneratedAt
+		page.Changes = DiffServices(previous.Services, state.Services)
+		for _, change := range page.Changes {
+			switch change.Kind {
+			case ServiceAdded:
+				page.Added = append(page.Added, change)
+			case ServiceWithdrawn:
+				page.Withdrawn = append(page.Withdrawn, change)
+			default:
+				page.Modified = append(page.Modified, change)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := changesTemplate.Execute(&buf, page); err != nil {
+		return ctx, fmt.Errorf("failed to render change report: %w", err)
+	}
+	if err := os.MkdirAll(opts.dir, 0755); err != nil {
+		return ctx, fmt.Errorf("failed to create directory %s: %w", opts.dir, err)
+	}
+	path := filepath.Join(opts.dir, opts.file)
+	if err := writeFileAtomic(path, buf.Bytes(), 0644); err != nil {
+		return ctx, fmt.Errorf("failed to write change report: %w", err)
+	}
+
+	// The state is replaced last, so a failed run is reported again next time
+	data, err = json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return ctx, err
+	}
+	if err := os.MkdirAll(filepath.Dir(opts.state), 0755); err != nil {
+		return ctx, fmt.Errorf("failed to create directory of %s: %w", opts.state, err)
+	}
+	if err := writeFileAtomic(opts.state, append(data, '\n'), 0644); err != nil {
+		return ctx, fmt.Errorf("failed to write change-report state: %w", err)
+	}
+
+	pl.Logger.Info("Wrote change report",
+		logging.F("path", path),
+		logging.F("baseline", previous == nil),
+		logging.F("added", len(page.Added)),
+		logging.F("withdrawn", len(page.Withdrawn)),
+		logging.F("modified", len(page.Modified)))
+	return ctx, nil
+}
+
+// changeReportOutputs is the OutputsFunc of the change-report step: the page
+// and the state file.
+func changeReportOutputs(args ...string) []string {
+	opts, err := parseChangeReportArgs(args)
+	if err != nil {
+		return nil
+	}
+	return []string{filepath.Join(opts.dir, opts.file), opts.state}
+}
@@ -0,0 +1,157 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sirosfoundation/g119612/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffServices(t *testing.T) {
+	granted := "http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/granted"
+	withdrawn := "http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/withdrawn"
+	ca := "http://uri.etsi.org/TrstSvc/Svctype/CA/QC"
+	service := func(provider, name, status string, certs ...string) ServiceSnapshot {
+		return ServiceSnapshot{Territory: "SE", Provider: provider, Name: name, Type: ca, Status: status, Certificates: certs}
+	}
+	previous := []ServiceSnapshot{
+		service("Alpha", "Unchanged", granted, "aa"),
+		service("Alpha", "Rekeyed", granted, "aa", "bb"),
+		service("Beta", "Closing", granted),
+		service("Beta", "Gone", granted),
+	}
+	current := []ServiceSnapshot{
+		service("Alpha", "Unchanged", "https://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/granted/", "aa"),
+		service("Alpha", "Rekeyed", granted, "aa", "cc"),
+		service("Beta", "Closing", withdrawn),
+		service("Gamma", "New", granted),
+	}
+
+	changes := DiffServices(previous, current)
+	require.Len(t, changes, 4)
+	assert.Equal(t, ServiceAdded, changes[0].Kind)
+	assert.Equal(t, "New", changes[0].Service.Name)
+	assert.Nil(t, changes[0].Previous)
+
+	assert.Equal(t, ServiceWithdrawn, changes[1].Kind)
+	assert.Equal(t, "Closing", changes[1].Service.Name)
+	assert.Equal(t, []string{"status: Granted → Withdrawn"}, changes[1].Details)
+	assert.Equal(t, ServiceWithdrawn, changes[2].Kind)
+	assert.Equal(t, "Gone", changes[2].Service.Name)
+	assert.Equal(t, []string{"no longer listed"}, changes[2].Details)
+
+	assert.Equal(t, ServiceModified, changes[3].Kind)
+	assert.Equal(t, "Rekeyed", changes[3].Service.Name)
+	assert.Equal(t, []string{"certificate added: cc", "certificate removed: bb"}, changes[3].Details)
+	assert.Equal(t, []string{"aa", "bb"}, changes[3].Previous.Certificates)
+
+	assert.Empty(t, DiffServices(current, current))
+}
+
+func TestChangeReport(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "site")
+	state := filepath.Join(dir, "state", "services.json")
+	pl := &Pipeline{Logger: logging.SilentLogger()}
+
+	ctx := NewContext()
+	tsl := generateTSL("Kept Service", "http://uri.etsi.org/TrstSvc/Svctype/CA/QC", []string{TestCertBase64})
+	ctx.AddTSL(tsl)
+
+	// The first run records the baseline
+	_, err := ChangeReport(pl, ctx, out, "state:"+state)
+	require.NoError(t, err)
+	page, err := os.ReadFile(filepath.Join(out, DefaultChangeReportFile))
+	require.NoError(t, err)
+	assert.Contains(t, string(page), "This is the first run; its 1 trust services are the baseline")
+	var recorded ServiceState
+	data, err := os.ReadFile(state)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &recorded))
+	require.Len(t, recorded.Services, 1)
+	assert.Equal(t, "Kept Service", recorded.Services[0].Name)
+	assert.Len(t, recorded.Services[0].Certificates, 1)
+
+	// An unchanged run reports no changes
+	_, err = ChangeReport(pl, ctx, out, "state:"+state)
+	require.NoError(t, err)
+	page, err = os.ReadFile(filepath.Join(out, DefaultChangeReportFile))
+	require.NoError(t, err)
+	assert.Contains(t, string(page), "No trust services were added, withdrawn or modified.")
+
+	// A withdrawn and an added service
+	ctx = NewContext()
+	changed := generateTSL("Kept Service", "http://uri.etsi.org/TrstSvc/Svctype/CA/QC", []string{TestCertBase64})
+	services := changed.StatusList.TslTrustServiceProviderList.TslTrustServiceProvider[0].TslTSPServices
+	services.TslTSPService[0].TslServiceInformation.TslServiceStatus = "http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/withdrawn"
+	added := generateTSL("Added <Service>", "http://uri.etsi.org/TrstSvc/Svctype/TSA/QTST", nil)
+	services.TslTSPService = append(services.TslTSPService, added.StatusList.TslTrustServiceProviderList.TslTrustServiceProvider[0].TslTSPServices.TslTSPService[0])
+	ctx.AddTSL(changed)
+
+	_, err = ChangeReport(pl, ctx, out, "state:"+state, "file:report.html", "title:Compliance report")
+	require.NoError(t, err)
+	page, err = os.ReadFile(filepath.Join(out, "report.html"))
+	require.NoError(t, err)
+	body := string(page)
+	assert.Contains(t, body, "<title>Compliance report</title>")
+	assert.Contains(t, body, "1 added, 1 withdrawn and 0 modified of 2 trust services.")
+	assert.Contains(t, body, "Added &lt;Service&gt;")
+	assert.Contains(t, body, "status: Granted → Withdrawn")
+	assert.Less(t, strings.Index(body, "<h2>Added</h2>"), strings.Index(body, "<h2>Withdrawn</h2>"))
+	assert.NotContains(t, body, "<h2>Modified</h2>")
+}
+
+func TestChangeReport_Index(t *testing.T) {
+	dir := t.TempDir()
+	pl := &Pipeline{Logger: logging.SilentLogger()}
+	ctx := NewContext()
+	ctx.AddTSL(generateTSL("Test Service", "http://uri.etsi.org/TrstSvc/Svctype/CA/QC", []string{TestCertBase64}))
+
+	ctx, err := RenderTSL(pl, ctx, "embedded:tsl.html", dir)
+	require.NoError(t, err)
+	ctx, err = ChangeReport(pl, ctx, dir, "state:"+filepath.Join(t.TempDir(), "state.json"))
+	require.NoError(t, err)
+	_, err = GenerateIndex(pl, ctx, dir)
+	require.NoError(t, err)
+
+	// The index links the report instead of listing it as a TSL
+	entries, err := findTSLHtmlFiles(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+	index, err := os.ReadFile(filepath.Join(dir, "index.html"))
+	require.NoError(t, err)
+	assert.Contains(t, string(index), `<a href="changes.html">Changes since the last run</a>`)
+}
+
+func TestChangeReport_Errors(t *testing.T) {
+	dir := t.TempDir()
+	pl := &Pipeline{Logger: logging.SilentLogger()}
+	state := filepath.Join(dir, "state.json")
+
+	for _, args := range [][]string{
+		{},
+		{dir},
+		{"state:" + state},
+		{dir, "state:" + state, "file:sub/changes.html"},
+		{dir, "state:" + state, "other:value"},
+	} {
+		assert.ErrorIs(t, validateChangeReportArgs(args...), ErrInvalidArguments, "%v", args)
+	}
+	assert.Equal(t, []string{filepath.Join(dir, "changes.html"), state}, changeReportOutputs(dir, "state:"+state))
+
+	// Without TSLs the state is left alone
+	_, err := ChangeReport(pl, NewContext(), dir, "state:"+state)
+	assert.ErrorIs(t, err, ErrNoTSLs)
+	assert.NoFileExists(t, state)
+
+	ctx := NewContext()
+	ctx.AddTSL(generateTSL("Test Service", "http://uri.etsi.org/TrstSvc/Svctype/CA/QC", nil))
+	require.NoError(t, os.WriteFile(state, []byte("not json"), 0644))
+	_, err = ChangeReport(pl, ctx, dir, "state:"+state)
+	assert.ErrorContains(t, err, "invalid change-report state")
+}
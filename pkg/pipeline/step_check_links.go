@@ -0,0 +1,297 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/sirosfoundation/g119612/pkg/etsi119612"
+	"github.com/sirosfoundation/g119612/pkg/logging"
+	"github.com/sirosfoundation/g119612/pkg/validation"
+)
+
+// DeadLink is a link found dead by the check-links step.
+type DeadLink struct {
+	Page   string // Page linking, relative to the checked directory, or the TSL for distribution points
+	Link   string // The link as written in the page, or the distribution point URL
+	Reason string // Why the link is dead, e.g. "no such file" or "HTTP 404"
+}
+
+func (d DeadLink) String() string {
+	return fmt.Sprintf("%s: %s (%s)", d.Page, d.Link, d.Reason)
+}
+
+// checkLinksOptions are the parsed arguments of the check-links step.
+type checkLinksOptions struct {
+	dir    string
+	remote bool
+	warn   bool
+}
+
+// linkAttributes are the attributes holding links, by element.
+var linkAttributes = map[string]string{
+	"a":      "href",
+	"link":   "href",
+	"img":    "src",
+	"script": "src",
+}
+
+// CheckLinks is a pipeline step that verifies the links of the HTML pages in
+// a directory, as written by transform, render and generate_index, before the
+// directory is deployed. Every relative link of an a, link, img or script
+// element must resolve to an existing file in the directory; a link to a
+// directory must resolve to its index.html. Absolute links to other sites and
+// links within a page are not checked.
+//
+// With remote:true the distribution points of the TSLs in the context are
+// also fetched and must answer with 200 OK. Requests use the timeout, user
+// agent and HTTP client set with set-fetch-options.
+//
+// Parameters:
+//   - pl: Pipeline instance managing the step execution
+//   - ctx: Pipeline context, whose TSLs are checked with remote:true
+//   - args: String slice where args[0] is the directory to check. Options in
+//     "key:value" form may follow:
+//   - remote:true: Also check the distribution points of the loaded TSLs
+//   - on-dead:fail|warn: Fail the pipeline (default) or only log the dead links
+//
+// Returns:
+//   - The unchanged context
+//   - An error if dead links were found and on-dead is fail
+//
+// Example usage in pipeline YAML:
+//
+//   - generate_index:
+//   - /var/www/html/tsl
+//   - check-links:
+//   - /var/www/html/tsl
+//   - remote:true
+func CheckLinks(pl *Pipeline, ctx *Context, args ...string) (*Context, error) {
+	opts, err := parseCheckLinksArgs(args)
+	if err != nil {
+		return ctx, err
+	}
+	info, err := os.Stat(opts.dir)
+	if err != nil {
+		return ctx, fmt.Errorf("error accessing directory %s: %w", opts.dir, err)
+	}
+	if !info.IsDir() {
+		return ctx, fmt.Errorf("%s is not a directory", opts.dir)
+	}
+
+	dead, pages, err := checkLocalLinks(opts.dir)
+	if err != nil {
+		return ctx, err
+	}
+	checked := 0
+	if opts.remote {
+		var remoteDead []DeadLink
+		remoteDead, checked = checkDistributionPoints(ctx)
+		dead = append(dead, remoteDead...)
+	}
+
+	for _, link := range dead {
+		pl.Logger.Warn("Dead link",
+			logging.F("page", link.Page),
+			logging.F("link", link.Link),
+			logging.F("reason", link.Reason))
+	}
+	pl.Logger.Info("Checked links",
+		logging.F("directory", opts.dir),
+		logging.F("pages", pages),
+		logging.F("distribution_points", checked),
+		logging.F("dead", len(dead)))
+
+	if len(dead) > 0 && !opts.warn {
+		return ctx, fmt.Errorf("%w: %d dead links, first %s", ErrDeadLinks, len(dead), dead[0])
+	}
+	return ctx, nil
+}
+
+// parseCheckLinksArgs parses the arguments of the check-links step.
+func parseCheckLinksArgs(args []string) (checkLinksOptions, error) {
+	var opts checkLinksOptions
+	for _, arg := range args {
+		switch {
+		case strings.HasPrefix(arg, "remote:"):
+			value, err := strconv.ParseBool(strings.TrimPrefix(arg, "remote:"))
+			if err != nil {
+				return opts, fmt.Errorf("%w: invalid remote value %q", ErrInvalidArguments, arg)
+			}
+			opts.remote = value
+		case strings.HasPrefix(arg, "on-dead:"):
+			switch value := strings.TrimPrefix(arg, "on-dead:"); value {
+			case "fail":
+				opts.warn = false
+			case "warn":
+				opts.warn = true
+			default:
+				return opts, fmt.Errorf("%w: invalid on-dead value %q (expected fail or warn)", ErrInvalidArguments, value)
+			}
+		case opts.dir == "" && !strings.Contains(arg, ":"):
+			opts.dir = arg
+		default:
+			return opts, fmt.Errorf("%w: unexpected argument %q", ErrInvalidArguments, arg)
+		}
+	}
+	if opts.dir == "" {
+		return opts, fmt.Errorf("%w: missing directory", ErrInvalidArguments)
+	}
+	if err := validation.ValidateFilePath(opts.dir); err != nil {
+		return opts, fmt.Errorf("%w: invalid directory: %v", ErrInvalidArguments, err)
+	}
+	return opts, nil
+}
+
+// validateCheckLinksArgs is the ArgsValidator of the check-links step.
+func validateCheckLinksArgs(args ...string) error {
+	_, err := parseCheckLinksArgs(args)
+	return err
+}
+
+// checkLocalLinks checks the relative links of every HTML page under dir. It
+// returns the dead links, sorted by page, and the number of pages checked.
+func checkLocalLinks(dir string) ([]DeadLink, int, error) {
+	var dead []DeadLink
+	pages := 0
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || filepath.Ext(path) != ".html" {
+			return nil
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		doc, err := goquery.NewDocumentFromReader(bytes.NewReader(content))
+		if err != nil {
+			return fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		page, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		pages++
+		for element, attr := range linkAttributes {
+			doc.Find(element + "[" + attr + "]").Each(func(_ int, s *goquery.Selection) {
+				link, _ := s.Attr(attr)
+				if reason := checkLocalLink(filepath.Dir(path), link); reason != "" {
+					dead = append(dead, DeadLink{Page: filepath.ToSlash(page), Link: link, Reason: reason})
+				}
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, pages, fmt.Errorf("failed to check links in %s: %w", dir, err)
+	}
+	sort.SliceStable(dead, func(i, j int) bool {
+		if dead[i].Page != dead[j].Page {
+			return dead[i].Page < dead[j].Page
+		}
+		return dead[i].Link < dead[j].Link
+	})
+	return dead, pages, nil
+}
+
+// checkLocalLink checks a link of a page in pageDir. It returns why the link
+// is dead, or "" if it resolves or is not a relative link.
+func checkLocalLink(pageDir, link string) string {
+	link = strings.TrimSpace(link)
+	if link == "" || strings.HasPrefix(link, "#") {
+		return ""
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		return "invalid link"
+	}
+	if u.Scheme != "" || u.Host != "" {
+		return ""
+	}
+	// Site-absolute links depend on where the directory is deployed
+	if u.Path == "" || strings.HasPrefix(u.Path, "/") {
+		return ""
+	}
+	target := filepath.Join(pageDir, filepath.FromSlash(u.Path))
+	info, err := os.Stat(target)
+	if err != nil {
+		return "no such file"
+	}
+	if info.IsDir() {
+		if _, err := os.Stat(filepath.Join(target, "index.html")); err != nil {
+			return "directory without index.html"
+		}
+	}
+	return ""
+}
+
+// checkDistributionPoints requests the distribution points of the TSLs in the
+// context with the fetch options of the context. It returns the dead ones and
+// the number of distribution points checked.
+func checkDistributionPoints(ctx *Context) ([]DeadLink, int) {
+	ctx.EnsureTSLFetchOptions()
+	options := *ctx.TSLFetchOptions
+	client := options.Client
+	if client == nil {
+		client = &http.Client{Timeout: options.Timeout}
+		if options.Transport != (etsi119612.TransportOptions{}) {
+			transport := options.Transport.NewTransport()
+			defer transport.CloseIdleConnections()
+			client.Transport = transport
+		}
+	}
+
+	var dead []DeadLink
+	seen := make(map[string]bool)
+	for _, tsl := range contextTSLs(ctx) {
+		si := tsl.StatusList.TslSchemeInformation
+		if si == nil || si.TslDistributionPoints == nil {
+			continue
+		}
+		for _, location := range si.TslDistributionPoints.URI {
+			location = strings.TrimSpace(location)
+			if seen[location] || !(strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://")) {
+				continue
+			}
+			seen[location] = true
+			if reason := checkRemoteLink(client, location, options.ForURL(location).UserAgent); reason != "" {
+				dead = append(dead, DeadLink{Page: tsl.String(), Link: location, Reason: reason})
+			}
+		}
+	}
+	return dead, len(seen)
+}
+
+// checkRemoteLink fetches location and returns why it is dead, or "" if the
+// server answers with 200 OK.
+func checkRemoteLink(client *http.Client, location, userAgent string) string {
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, location, nil)
+	if err != nil {
+		return err.Error()
+	}
+	if userAgent != "" {
+		req.Header.Set("User-Agent", userAgent)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err.Error()
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Sprintf("HTTP %d", resp.StatusCode)
+	}
+	return ""
+}
@@ -0,0 +1,111 @@
+package pipeline
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirosfoundation/g119612/pkg/etsi119612"
+	"github.com/sirosfoundation/g119612/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeLinkedSite(t *testing.T, dir string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "providers"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "empty"), 0755))
+	files := map[string]string{
+		"index.html": `<html><head><link rel="stylesheet" href="https://cdn.example.com/pico.css"></head><body>
+<a href="SE.html">SE</a> <a href="#top">top</a> <a href="mailto:ops@example.com">mail</a>
+<a href="/tsl/SE.html">site</a> <a href="providers/">providers</a> <a href="empty/">empty</a>
+<a href="FI.html?lang=sv">FI</a></body></html>`,
+		"SE.html":                   `<html><body><a href="providers/provider%201.html#svc">provider</a><img src="logo.png"></body></html>`,
+		"providers/index.html":      `<html><body><a href="../SE.html">back</a></body></html>`,
+		"providers/provider 1.html": `<html><body><a href="../index.html">index</a></body></html>`,
+	}
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+}
+
+func TestCheckLinks(t *testing.T) {
+	dir := t.TempDir()
+	writeLinkedSite(t, dir)
+	pl := &Pipeline{Logger: logging.SilentLogger()}
+
+	dead, pages, err := checkLocalLinks(dir)
+	require.NoError(t, err)
+	assert.Equal(t, 4, pages)
+	assert.Equal(t, []DeadLink{
+		{Page: "SE.html", Link: "logo.png", Reason: "no such file"},
+		{Page: "index.html", Link: "FI.html?lang=sv", Reason: "no such file"},
+		{Page: "index.html", Link: "empty/", Reason: "directory without index.html"},
+	}, dead)
+
+	_, err = CheckLinks(pl, NewContext(), dir)
+	assert.ErrorIs(t, err, ErrDeadLinks)
+	assert.ErrorContains(t, err, "3 dead links")
+
+	_, err = CheckLinks(pl, NewContext(), dir, "on-dead:warn")
+	assert.NoError(t, err)
+
+	// Once the missing files exist the site passes
+	for _, name := range []string{"logo.png", "FI.html", "empty/index.html"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("<html></html>"), 0644))
+	}
+	_, err = CheckLinks(pl, NewContext(), dir)
+	assert.NoError(t, err)
+}
+
+func TestCheckLinksRemote(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/gone.xml" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte("<TrustServiceStatusList/>"))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	pl := &Pipeline{Logger: logging.SilentLogger()}
+	ctx := NewContext()
+	live := generateTSL("Live", "http://uri.etsi.org/TrstSvc/Svctype/CA/QC", []string{TestCertBase64})
+	live.StatusList.TslSchemeInformation.TslDistributionPoints = &etsi119612.NonEmptyURIListType{
+		URI: []string{server.URL + "/tsl.xml"},
+	}
+	ctx.AddTSL(live)
+
+	_, err := CheckLinks(pl, ctx, dir, "remote:true")
+	require.NoError(t, err)
+
+	gone := generateTSL("Gone", "http://uri.etsi.org/TrstSvc/Svctype/CA/QC", []string{TestCertBase64})
+	gone.StatusList.TslSchemeInformation.TslDistributionPoints = &etsi119612.NonEmptyURIListType{
+		URI: []string{server.URL + "/gone.xml"},
+	}
+	ctx.AddTSL(gone)
+	// Distribution points are only checked with remote:true
+	_, err = CheckLinks(pl, ctx, dir)
+	require.NoError(t, err)
+	_, err = CheckLinks(pl, ctx, dir, "remote:true")
+	assert.ErrorIs(t, err, ErrDeadLinks)
+	assert.ErrorContains(t, err, "HTTP 404")
+}
+
+func TestCheckLinksArgs(t *testing.T) {
+	for _, args := range [][]string{
+		{},
+		{"remote:maybe", "/tmp/site"},
+		{"/tmp/site", "on-dead:ignore"},
+		{"/tmp/site", "other"},
+	} {
+		assert.ErrorIs(t, validateCheckLinksArgs(args...), ErrInvalidArguments, "%v", args)
+	}
+	assert.NoError(t, validateCheckLinksArgs("/tmp/site", "remote:true", "on-dead:warn"))
+
+	_, err := CheckLinks(&Pipeline{Logger: logging.SilentLogger()}, NewContext(), filepath.Join(t.TempDir(), "missing"))
+	assert.Error(t, err)
+}
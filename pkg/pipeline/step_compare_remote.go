@@ -0,0 +1,252 @@
+package pipeline
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/sirosfoundation/g119612/pkg/etsi119612"
+	"github.com/sirosfoundation/g119612/pkg/logging"
+)
+
+// RemoteComparison is the result of comparing a TSL about to be published with
+// the copy currently published at its distribution point.
+type RemoteComparison struct {
+	Location       string // Distribution point the remote copy was fetched from
+	LocalSequence  int    // TSLSequenceNumber of the TSL to publish
+	RemoteSequence int    // TSLSequenceNumber of the published copy
+	LocalDigest    string // Hex SHA-256 of the list content to publish
+	RemoteDigest   string // Hex SHA-256 of the published list content
+	SignerChanged  bool   // The published copy is signed by an unexpected certificate
+	// Resigned is set if the published copy has the same content as the TSL
+	// to publish but other bytes, as when the list was signed again. It is
+	// only set for TSLs loaded from a document, see etsi119612.TSL.RawXML.
+	Resigned bool
+}
+
+// CompareRemote is a pipeline step that protects against split-brain publishing
+// when several operators or publish jobs maintain the same TSL. For each TSL
+// that the publish step would write, it downloads the copy currently published
+// at the first distribution point and compares sequence number, content digest
+// and signer certificate with the TSL in the context. Place it before publish.
+//
+// The step fails with ErrRemoteNewer if the published copy has a higher sequence
+// number, and with ErrRemoteConflict if it has the same sequence number but
+// different content. A published copy signed by a certificate other than the
+// expected one is logged as a warning, since it is legitimate during a key
+// rollover. Content digests are computed over the list without its signature,
+// so a signed remote copy matches the unsigned TSL it was produced from.
+//
+// Parameters:
+//   - pl: Pipeline instance managing the step execution
+//   - ctx: Pipeline context containing the TSLs to publish
+//   - args: Options in "key:value" form:
+//   - signer-cert:/path/to/cert.pem: PEM file with the expected signer certificate(s), may be repeated;
+//     without it the signer of the TSL in the context is expected, if it was loaded signed
+//   - allow-missing:true: Continue when the published copy cannot be fetched, e.g. on first publication
+//
+// TSLs without a distribution point are skipped. The remote copies are fetched
+// with the context's fetch options, without following references.
+//
+// Returns:
+//   - *Context: The context with the comparisons stored in Data["compare-remote"] as []RemoteComparison
+//   - error: Non-nil if a published copy is newer or conflicting, or cannot be fetched
+//
+// Example usage in pipeline configuration:
+//   - compare-remote:
+//   - signer-cert:/etc/tsl/signer.pem
+//   - publish:
+//   - /var/www/tsl
+//   - /etc/tsl/signer.pem
+//   - /etc/tsl/signer.key
+func CompareRemote(pl *Pipeline, ctx *Context, args ...string) (*Context, error) {
+	var expected []*x509.Certificate
+	allowMissing := false
+	for _, arg := range args {
+		switch {
+		case strings.HasPrefix(arg, "signer-cert:"):
+			certs, err := loadPEMCertificates(strings.TrimPrefix(arg, "signer-cert:"))
+			if err != nil {
+				return ctx, err
+			}
+			expected = append(expected, certs...)
+		case strings.HasPrefix(arg, "allow-missing:"):
+			value, err := strconv.ParseBool(strings.TrimPrefix(arg, "allow-missing:"))
+			if err != nil {
+				return ctx, fmt.Errorf("%w: invalid allow-missing value %q", ErrInvalidArguments, arg)
+			}
+			allowMissing = value
+		default:
+			return ctx, fmt.Errorf("%w: unexpected argument %q", ErrInvalidArguments, arg)
+		}
+	}
+
+	tsls := publishableTSLs(ctx)
+	if len(tsls) == 0 {
+		return ctx, ErrNoTSLs
+	}
+
+	ctx.EnsureTSLFetchOptions()
+	options := *ctx.TSLFetchOptions
+	options.MaxDereferenceDepth = 0
+	options.Cache = nil // Always compare with the currently published copy
+
+	var comparisons []RemoteComparison
+	for _, tsl := range tsls {
+		location := distributionPoint(tsl)
+		if location == "" {
+			pl.Logger.Debug("TSL has no distribution point, not comparing", tslFields(tsl)...)
+			continue
+		}
+
+		remote, err := etsi119612.FetchTSLWithOptions(location, options)
+		if err != nil {
+			if allowMissing {
+				pl.Logger.Warn("Published TSL not available, continuing", tslFields(tsl,
+					logging.F("location", location),
+					logging.F("error", err))...)
+				continue
+			}
+			return ctx, fmt.Errorf("failed to fetch published TSL from %s: %w", location, err)
+		}
+
+		signers := expected
+		if len(signers) == 0 && tsl.Signed && len(tsl.Signer.Raw) > 0 {
+			signers = []*x509.Certificate{&tsl.Signer}
+		}
+		comparison, err := compareRemoteTSL(location, tsl, remote, signers)
+		if err != nil {
+			return ctx, err
+		}
+		comparisons = append(comparisons, comparison)
+
+		fields := tslFields(tsl,
+			logging.F("location", location),
+			logging.F("local_sequence", comparison.LocalSequence),
+			logging.F("remote_sequence", comparison.RemoteSequence),
+			logging.F("local_digest", comparison.LocalDigest),
+			logging.F("remote_digest", comparison.RemoteDigest))
+		if comparison.SignerChanged {
+			pl.Logger.Warn("Published TSL is signed by an unexpected certificate", fields...)
+		}
+		switch {
+		case comparison.RemoteSequence > comparison.LocalSequence:
+			pl.Logger.Error("Published TSL is newer, refusing to overwrite", fields...)
+			return ctx, fmt.Errorf("%w: %s has sequence number %d, publishing %d",
+				ErrRemoteNewer, location, comparison.RemoteSequence, comparison.LocalSequence)
+		case comparison.RemoteSequence == comparison.LocalSequence && comparison.RemoteDigest != comparison.LocalDigest:
+			pl.Logger.Error("Published TSL has the same sequence number but different content", fields...)
+			return ctx, fmt.Errorf("%w: %s has sequence number %d with different content",
+				ErrRemoteConflict, location, comparison.RemoteSequence)
+		case comparison.RemoteSequence == comparison.LocalSequence && comparison.Resigned:
+			pl.Logger.Info("Published TSL is up to date but was signed again", fields...)
+		case comparison.RemoteSequence == comparison.LocalSequence:
+			pl.Logger.Info("Published TSL is up to date", fields...)
+		default:
+			pl.Logger.Info("Published TSL is older, safe to overwrite", fields...)
+		}
+	}
+
+	ctx.SetData("compare-remote", comparisons)
+	return ctx, nil
+}
+
+// compareRemoteTSL compares a TSL with its published copy. The signer is
+// considered changed if expected certificates are given and the published
+// copy is unsigned or signed by none of them.
+func compareRemoteTSL(location string, local, remote *etsi119612.TSL, expected []*x509.Certificate) (RemoteComparison, error) {
+	comparison := RemoteComparison{
+		Location:       location,
+		LocalSequence:  sequenceNumber(local),
+		RemoteSequence: sequenceNumber(remote),
+	}
+	var err error
+	if comparison.LocalDigest, err = tslContentDigest(local); err != nil {
+		return comparison, err
+	}
+	if comparison.RemoteDigest, err = tslContentDigest(remote); err != nil {
+		return comparison, err
+	}
+	if comparison.LocalDigest == comparison.RemoteDigest {
+		localRaw, err := local.RawXML()
+		if err != nil {
+			return comparison, err
+		}
+		remoteRaw, err := remote.RawXML()
+		if err != nil {
+			return comparison, err
+		}
+		comparison.Resigned = localRaw != nil && !bytes.Equal(localRaw, remoteRaw)
+	}
+	if len(expected) > 0 {
+		comparison.SignerChanged = true
+		if remote.Signed {
+			for _, cert := range expected {
+				if cert.Equal(&remote.Signer) {
+					comparison.SignerChanged = false
+					break
+				}
+			}
+		}
+	}
+	return comparison, nil
+}
+
+// tslContentDigest returns the hex SHA-256 of the serialized list without its signature.
+func tslContentDigest(tsl *etsi119612.TSL) (string, error) {
+	unsigned := *tsl
+	unsigned.StatusList.DsSignature = nil
+	data, err := marshalTSLDocument(&unsigned)
+	if err != nil {
+		return "", err
+	}
+	digest := sha256.Sum256(data)
+	return hex.EncodeToString(digest[:]), nil
+}
+
+// sequenceNumber returns the TSLSequenceNumber of a TSL, 0 if it has no scheme information.
+func sequenceNumber(tsl *etsi119612.TSL) int {
+	if tsl.StatusList.TslSchemeInformation == nil {
+		return 0
+	}
+	return tsl.StatusList.TslSchemeInformation.TSLSequenceNumber
+}
+
+// distributionPoint returns the first distribution point of a TSL, or "" if it has none.
+func distributionPoint(tsl *etsi119612.TSL) string {
+	si := tsl.StatusList.TslSchemeInformation
+	if si == nil || si.TslDistributionPoints == nil || len(si.TslDistributionPoints.URI) == 0 {
+		return ""
+	}
+	return strings.TrimSpace(si.TslDistributionPoints.URI[0])
+}
+
+// publishableTSLs returns the TSLs the publish step would write: the legacy
+// stack if it is not empty, otherwise all TSLs of all trees. Each TSL is
+// returned once even if it was pushed to the stack several times.
+func publishableTSLs(ctx *Context) []*etsi119612.TSL {
+	var candidates []*etsi119612.TSL
+	if ctx.TSLs != nil && !ctx.TSLs.IsEmpty() {
+		candidates = ctx.TSLs.ToSlice()
+	} else if ctx.TSLTrees != nil {
+		for _, tree := range ctx.TSLTrees.ToSlice() {
+			if tree != nil && tree.Root != nil {
+				candidates = append(candidates, tree.ToSlice()...)
+			}
+		}
+	}
+
+	seen := make(map[*etsi119612.TSL]bool, len(candidates))
+	var tsls []*etsi119612.TSL
+	for _, tsl := range candidates {
+		if tsl != nil && !seen[tsl] {
+			seen[tsl] = true
+			tsls = append(tsls, tsl)
+		}
+	}
+	return tsls
+}
@@ -0,0 +1,184 @@
+package pipeline
+
+import (
+	"bytes"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirosfoundation/g119612/pkg/dsig"
+	"github.com/sirosfoundation/g119612/pkg/etsi119612"
+	"github.com/sirosfoundation/g119612/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// remoteTestTSL returns a test TSL with the given sequence number and service name,
+// distributed at location.
+func remoteTestTSL(location string, sequence int, serviceName string) *etsi119612.TSL {
+	tsl := generateTSL(serviceName, "http://uri.etsi.org/TrstSvc/Svctype/CA/QC", []string{TestCertBase64})
+	tsl.StatusList.TslSchemeInformation.TSLSequenceNumber = sequence
+	tsl.StatusList.TslSchemeInformation.TslDistributionPoints = &etsi119612.NonEmptyURIListType{URI: []string{location}}
+	return tsl
+}
+
+// remoteTestDocument serializes a TSL with TrustServiceStatusList as the root element.
+func remoteTestDocument(t *testing.T, tsl *etsi119612.TSL) []byte {
+	t.Helper()
+	type TrustServiceStatusList struct {
+		XMLName xml.Name `xml:"TrustServiceStatusList"`
+		etsi119612.TrustStatusListType
+	}
+	data, err := xml.MarshalIndent(TrustServiceStatusList{TrustStatusListType: tsl.StatusList}, "", "  ")
+	require.NoError(t, err)
+	return append([]byte(xml.Header), data...)
+}
+
+// serveRemoteTSL starts a server publishing document at /tsl.xml, or nothing if document is nil.
+func serveRemoteTSL(t *testing.T, document *[]byte) string {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/tsl.xml" || *document == nil {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/xml")
+		_, _ = w.Write(*document)
+	}))
+	t.Cleanup(server.Close)
+	return server.URL + "/tsl.xml"
+}
+
+func TestCompareRemote(t *testing.T) {
+	pl := &Pipeline{Logger: logging.SilentLogger()}
+	var published []byte
+	location := serveRemoteTSL(t, &published)
+
+	publishRemote := func(t *testing.T, tsl *etsi119612.TSL) {
+		t.Helper()
+		published = remoteTestDocument(t, tsl)
+	}
+	compare := func(local *etsi119612.TSL, args ...string) (*Context, error) {
+		ctx := NewContext()
+		ctx.AddTSL(local)
+		return CompareRemote(pl, ctx, args...)
+	}
+
+	t.Run("remote older", func(t *testing.T) {
+		publishRemote(t, remoteTestTSL(location, 4, "Old Service"))
+		ctx, err := compare(remoteTestTSL(location, 5, "New Service"))
+		require.NoError(t, err)
+		comparisons, ok := ctx.Data["compare-remote"].([]RemoteComparison)
+		require.True(t, ok)
+		require.Len(t, comparisons, 1)
+		assert.Equal(t, location, comparisons[0].Location)
+		assert.Equal(t, 5, comparisons[0].LocalSequence)
+		assert.Equal(t, 4, comparisons[0].RemoteSequence)
+		assert.NotEqual(t, comparisons[0].LocalDigest, comparisons[0].RemoteDigest)
+	})
+
+	t.Run("remote newer", func(t *testing.T) {
+		publishRemote(t, remoteTestTSL(location, 6, "Other Service"))
+		_, err := compare(remoteTestTSL(location, 5, "New Service"))
+		assert.ErrorIs(t, err, ErrRemoteNewer)
+	})
+
+	t.Run("remote identical", func(t *testing.T) {
+		publishRemote(t, remoteTestTSL(location, 5, "New Service"))
+		ctx, err := compare(remoteTestTSL(location, 5, "New Service"))
+		require.NoError(t, err)
+		comparisons := ctx.Data["compare-remote"].([]RemoteComparison)
+		require.Len(t, comparisons, 1)
+		assert.Equal(t, comparisons[0].LocalDigest, comparisons[0].RemoteDigest)
+		assert.False(t, comparisons[0].Resigned, "generated TSLs have no document to compare")
+	})
+
+	t.Run("remote with the same content in other bytes", func(t *testing.T) {
+		document := remoteTestDocument(t, remoteTestTSL(location, 5, "New Service"))
+		local, err := etsi119612.ParseTSL(document, location, etsi119612.DefaultTSLFetchOptions)
+		require.NoError(t, err)
+		published = append(bytes.Clone(document), []byte("<!-- signed again -->\n")...)
+		ctx, err := compare(local)
+		require.NoError(t, err)
+		comparisons := ctx.Data["compare-remote"].([]RemoteComparison)
+		require.Len(t, comparisons, 1)
+		assert.True(t, comparisons[0].Resigned)
+
+		published = document
+		ctx, err = compare(local)
+		require.NoError(t, err)
+		assert.False(t, ctx.Data["compare-remote"].([]RemoteComparison)[0].Resigned)
+	})
+
+	t.Run("same sequence different content", func(t *testing.T) {
+		publishRemote(t, remoteTestTSL(location, 5, "Other Service"))
+		_, err := compare(remoteTestTSL(location, 5, "New Service"))
+		assert.ErrorIs(t, err, ErrRemoteConflict)
+	})
+
+	t.Run("remote missing", func(t *testing.T) {
+		published = nil
+		_, err := compare(remoteTestTSL(location, 5, "New Service"))
+		assert.Error(t, err)
+
+		ctx, err := compare(remoteTestTSL(location, 5, "New Service"), "allow-missing:true")
+		require.NoError(t, err)
+		assert.Empty(t, ctx.Data["compare-remote"])
+	})
+
+	t.Run("no distribution point", func(t *testing.T) {
+		local := remoteTestTSL(location, 5, "New Service")
+		local.StatusList.TslSchemeInformation.TslDistributionPoints = nil
+		_, err := compare(local)
+		assert.NoError(t, err)
+	})
+}
+
+func TestCompareRemote_Signer(t *testing.T) {
+	pl := &Pipeline{Logger: logging.SilentLogger()}
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	require.NoError(t, generateTestCertAndKey(certFile, keyFile))
+	otherCert, otherKey := filepath.Join(dir, "other.pem"), filepath.Join(dir, "other.key")
+	require.NoError(t, generateTestCertAndKey(otherCert, otherKey))
+
+	var published []byte
+	location := serveRemoteTSL(t, &published)
+	unsigned := remoteTestDocument(t, remoteTestTSL(location, 5, "Service"))
+	var err error
+	published, err = dsig.NewFileSigner(certFile, keyFile).Sign(unsigned)
+	require.NoError(t, err)
+
+	compare := func(args ...string) RemoteComparison {
+		t.Helper()
+		ctx := NewContext()
+		ctx.AddTSL(remoteTestTSL(location, 5, "Service"))
+		ctx, err := CompareRemote(pl, ctx, args...)
+		require.NoError(t, err, "the signature is not part of the content digest")
+		comparisons := ctx.Data["compare-remote"].([]RemoteComparison)
+		require.Len(t, comparisons, 1)
+		return comparisons[0]
+	}
+
+	assert.False(t, compare("signer-cert:"+certFile).SignerChanged)
+	assert.True(t, compare("signer-cert:"+otherCert).SignerChanged)
+	assert.False(t, compare().SignerChanged, "nothing to compare without an expected signer")
+}
+
+func TestCompareRemote_InvalidArguments(t *testing.T) {
+	pl := &Pipeline{Logger: logging.SilentLogger()}
+
+	_, err := CompareRemote(pl, NewContext())
+	assert.ErrorIs(t, err, ErrNoTSLs)
+
+	_, err = CompareRemote(pl, NewContext(), "allow-missing:perhaps")
+	assert.ErrorIs(t, err, ErrInvalidArguments)
+
+	_, err = CompareRemote(pl, NewContext(), "bogus")
+	assert.ErrorIs(t, err, ErrInvalidArguments)
+
+	_, err = CompareRemote(pl, NewContext(), "signer-cert:"+filepath.Join(t.TempDir(), "missing.pem"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,407 @@
+package pipeline
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sirosfoundation/g119612/pkg/etsi119612"
+	"github.com/sirosfoundation/g119612/pkg/logging"
+	"github.com/sirosfoundation/g119612/pkg/validation"
+)
+
+// NotificationMimeType is the MIME type announced for XML trusted lists in notifications.
+const NotificationMimeType = "application/vnd.etsi.tsl+xml"
+
+// NotificationName is a language tagged name in notification metadata.
+type NotificationName struct {
+	Lang  string `json:"lang,omitempty"`
+	Value string `json:"value"`
+}
+
+// NotificationPostalAddress is a postal address of the scheme operator.
+type NotificationPostalAddress struct {
+	Lang            string `json:"lang,omitempty"`
+	StreetAddress   string `json:"streetAddress"`
+	Locality        string `json:"locality"`
+	StateOrProvince string `json:"stateOrProvince,omitempty"`
+	PostalCode      string `json:"postalCode,omitempty"`
+	CountryName     string `json:"countryName"`
+}
+
+// NotificationContact holds the scheme operator contact information.
+type NotificationContact struct {
+	ElectronicAddresses []string                    `json:"electronicAddresses,omitempty"`
+	PostalAddresses     []NotificationPostalAddress `json:"postalAddresses,omitempty"`
+}
+
+// NotificationCertificate describes a certificate used to sign the trusted list.
+type NotificationCertificate struct {
+	File         string `json:"file"`
+	Subject      string `json:"subject"`
+	Issuer       string `json:"issuer"`
+	SerialNumber string `json:"serialNumber"`
+	NotBefore    string `json:"notBefore"`
+	NotAfter     string `json:"notAfter"`
+	SHA256       string `json:"sha256"`
+}
+
+// NotificationMetadata is the content of notification.json in the package
+// produced by the export-notification step. It carries the information a
+// member state submits when notifying its trusted list.
+type NotificationMetadata struct {
+	GeneratedAt         string                    `json:"generatedAt"`
+	Territory           string                    `json:"territory,omitempty"`
+	TSLType             string                    `json:"tslType,omitempty"`
+	SequenceNumber      int                       `json:"sequenceNumber"`
+	ListIssueDateTime   string                    `json:"listIssueDateTime,omitempty"`
+	NextUpdate          string                    `json:"nextUpdate,omitempty"`
+	SchemeOperatorNames []NotificationName        `json:"schemeOperatorNames,omitempty"`
+	SchemeNames         []NotificationName        `json:"schemeNames,omitempty"`
+	TSLLocation         string                    `json:"tslLocation,omitempty"`
+	DistributionPoints  []string                  `json:"distributionPoints,omitempty"`
+	MimeType            string                    `json:"mimeType"`
+	TSLFile             string                    `json:"tslFile"`
+	TSLSHA256           string                    `json:"tslSha256"`
+	Contact             NotificationContact       `json:"contact"`
+	SignerCertificates  []NotificationCertificate `json:"signerCertificates"`
+}
+
+// ExportNotification is a pipeline step that packages the current TSL together with
+// the metadata needed for a member-state notification submission into a ZIP file.
+//
+// The package contains:
+//   - tsl/<name>.xml: the trusted list, either a published file or the serialized TSL
+//   - certificates/signer-N.pem: the certificates used to sign the trusted list
+//   - notification.json: territory, TSL type, scheme operator names, distribution points,
+//     contact information and signer certificate details (see NotificationMetadata)
+//
+// Parameters:
+//   - pl: Pipeline instance managing the step execution
+//   - ctx: Pipeline context containing the TSL to export (the root of the most recent tree)
+//   - args: String slice where args[0] is the path of the ZIP file to create.
+//     Options in "key:value" form may follow:
+//   - tsl:/path/to/published.xml: Include this (typically signed) file instead of serializing the TSL
+//   - signer-cert:/path/to/cert.pem: PEM file with signer certificate(s), may be repeated
+//
+// If no signer-cert is given and the TSL was loaded with a valid signature, the
+// certificate that signed it is used.
+//
+// Returns:
+//   - *Context: The context unchanged
+//   - error: Non-nil if there is no TSL, an input cannot be read or the ZIP cannot be written
+//
+// Example usage in pipeline configuration:
+//   - export-notification:
+//   - /var/lib/tsl/notification-SE.zip
+//   - tsl:/var/www/tsl/SE-TL.xml
+//   - signer-cert:/etc/tsl/signer.pem
+func ExportNotification(pl *Pipeline, ctx *Context, args ...string) (*Context, error) {
+	var zipPath, tslPath string
+	var certPaths []string
+	for _, arg := range args {
+		switch {
+		case strings.HasPrefix(arg, "tsl:"):
+			tslPath = strings.TrimPrefix(arg, "tsl:")
+		case strings.HasPrefix(arg, "signer-cert:"):
+			certPaths = append(certPaths, strings.TrimPrefix(arg, "signer-cert:"))
+		case zipPath == "":
+			zipPath = arg
+		default:
+			return ctx, fmt.Errorf("%w: unexpected argument %q", ErrInvalidArguments, arg)
+		}
+	}
+	if zipPath == "" {
+		return ctx, fmt.Errorf("missing argument: output ZIP path")
+	}
+	if err := validation.ValidateOutputDirectory(filepath.Dir(zipPath)); err != nil {
+		return ctx, fmt.Errorf("invalid output path: %w", err)
+	}
+
+	tsl := notificationTSL(ctx)
+	if tsl == nil {
+		return ctx, ErrNoTSLs
+	}
+
+	// The trusted list document itself
+	var tslData []byte
+	tslName := tslFileName(tsl, "tsl.xml")
+	if tslPath != "" {
+		if err := validation.ValidateFilePath(tslPath); err != nil {
+			return ctx, fmt.Errorf("invalid TSL path: %w", err)
+		}
+		data, err := os.ReadFile(tslPath)
+		if err != nil {
+			return ctx, fmt.Errorf("failed to read TSL %s: %w", tslPath, err)
+		}
+		tslData = data
+		tslName = filepath.Base(tslPath)
+	} else {
+		data, err := marshalTSLDocument(tsl)
+		if err != nil {
+			return ctx, err
+		}
+		tslData = data
+	}
+
+	// Signer certificates
+	var certs []*x509.Certificate
+	for _, path := range certPaths {
+		loaded, err := loadPEMCertificates(path)
+		if err != nil {
+			return ctx, err
+		}
+		certs = append(certs, loaded...)
+	}
+	if len(certs) == 0 && tsl.Signed && len(tsl.Signer.Raw) > 0 {
+		certs = append(certs, &tsl.Signer)
+	}
+	if len(certs) == 0 {
+		pl.Logger.Warn("Notification package has no signer certificates",
+			logging.F("output", zipPath))
+	}
+
+	now := ctx.Now()
+	meta := buildNotificationMetadata(tsl, "tsl/"+tslName, tslData, certs, now)
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	if err := addZipFile(zw, "tsl/"+tslName, tslData, now); err != nil {
+		return ctx, err
+	}
+	for i, cert := range certs {
+		pemData := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+		if err := addZipFile(zw, meta.SignerCertificates[i].File, pemData, now); err != nil {
+			return ctx, err
+		}
+	}
+	metaJSON, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return ctx, fmt.Errorf("failed to encode notification metadata: %w", err)
+	}
+	if err := addZipFile(zw, "notification.json", metaJSON, now); err != nil {
+		return ctx, err
+	}
+	if err := zw.Close(); err != nil {
+		return ctx, fmt.Errorf("failed to finalize notification package: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(zipPath), DefaultPublishDirMode); err != nil {
+		return ctx, fmt.Errorf("failed to create output directory for %s: %w", zipPath, err)
+	}
+	if err := writeFileAtomic(zipPath, buf.Bytes(), DefaultPublishFileMode); err != nil {
+		return ctx, fmt.Errorf("failed to write notification package %s: %w", zipPath, err)
+	}
+
+	pl.Logger.Info("Exported notification package",
+		logging.F("file", zipPath),
+		logging.F("territory", meta.Territory),
+		logging.F("signer_certificates", len(certs)),
+		logging.F("size", buf.Len()))
+
+	return ctx, nil
+}
+
+// notificationTSL returns the root TSL of the most recently added tree, falling
+// back to the top of the legacy stack.
+func notificationTSL(ctx *Context) *etsi119612.TSL {
+	if ctx.TSLTrees != nil {
+		if tree, ok := ctx.TSLTrees.Peek(); ok && tree != nil && tree.Root != nil && tree.Root.TSL != nil {
+			return tree.Root.TSL
+		}
+	}
+	if ctx.TSLs != nil {
+		if tsl, ok := ctx.TSLs.Peek(); ok {
+			return tsl
+		}
+	}
+	return nil
+}
+
+// tslFileName returns the file name announced by the TSL's first distribution
+// point, or fallback if there is none.
+func tslFileName(tsl *etsi119612.TSL, fallback string) string {
+	si := tsl.StatusList.TslSchemeInformation
+	if si == nil || si.TslDistributionPoints == nil || len(si.TslDistributionPoints.URI) == 0 {
+		return fallback
+	}
+	parts := strings.Split(si.TslDistributionPoints.URI[0], "/")
+	if name := parts[len(parts)-1]; name != "" {
+		return name
+	}
+	return fallback
+}
+
+// marshalTSLDocument serializes a TSL to an XML document the same way the publish step does.
+func marshalTSLDocument(tsl *etsi119612.TSL) ([]byte, error) {
+	type TrustStatusListWrapper struct {
+		XMLName xml.Name                       `xml:"TrustServiceStatusList"`
+		List    etsi119612.TrustStatusListType `xml:",innerxml"`
+	}
+	data, err := xml.MarshalIndent(TrustStatusListWrapper{List: tsl.StatusList}, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal TSL to XML: %w", err)
+	}
+	return append([]byte(xml.Header), data...), nil
+}
+
+// loadPEMCertificates reads all certificates from a PEM file.
+func loadPEMCertificates(path string) ([]*x509.Certificate, error) {
+	if err := validation.ValidateFilePath(path); err != nil {
+		return nil, fmt.Errorf("invalid certificate path: %w", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificate %s: %w", path, err)
+	}
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, &CertificateError{Operation: "parse", Subject: path, Err: err}
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, &CertificateError{Operation: "load", Subject: path, Err: fmt.Errorf("no certificates found")}
+	}
+	return certs, nil
+}
+
+// buildNotificationMetadata collects the notification metadata from the TSL,
+// generated at now.
+func buildNotificationMetadata(tsl *etsi119612.TSL, tslFile string, tslData []byte, certs []*x509.Certificate, now time.Time) NotificationMetadata {
+	digest := sha256.Sum256(tslData)
+	meta := NotificationMetadata{
+		GeneratedAt:        now.UTC().Format(time.RFC3339),
+		MimeType:           NotificationMimeType,
+		TSLFile:            tslFile,
+		TSLSHA256:          hex.EncodeToString(digest[:]),
+		SignerCertificates: []NotificationCertificate{},
+	}
+
+	if si := tsl.StatusList.TslSchemeInformation; si != nil {
+		meta.Territory = si.TslSchemeTerritory
+		meta.TSLType = si.TslTSLType
+		meta.SequenceNumber = si.TSLSequenceNumber
+		meta.ListIssueDateTime = si.ListIssueDateTime
+		if si.TslNextUpdate != nil {
+			meta.NextUpdate = si.TslNextUpdate.DateTime
+		}
+		meta.SchemeOperatorNames = notificationNames(si.TslSchemeOperatorName)
+		meta.SchemeNames = notificationNames(si.TslSchemeName)
+		if si.TslDistributionPoints != nil {
+			meta.DistributionPoints = append(meta.DistributionPoints, si.TslDistributionPoints.URI...)
+			if len(meta.DistributionPoints) > 0 {
+				meta.TSLLocation = meta.DistributionPoints[0]
+			}
+		}
+		if addr := si.SchemeOperatorAddress; addr != nil {
+			if addr.TslElectronicAddress != nil {
+				for _, uri := range addr.TslElectronicAddress.URI {
+					if uri != nil && uri.Value != "" {
+						meta.Contact.ElectronicAddresses = append(meta.Contact.ElectronicAddresses, uri.Value)
+					}
+				}
+			}
+			if addr.TslPostalAddresses != nil {
+				for _, pa := range addr.TslPostalAddresses.TslPostalAddress {
+					if pa == nil {
+						continue
+					}
+					postal := NotificationPostalAddress{
+						StreetAddress:   pa.StreetAddress,
+						Locality:        pa.Locality,
+						StateOrProvince: pa.StateOrProvince,
+						PostalCode:      pa.PostalCode,
+						CountryName:     pa.CountryName,
+					}
+					if pa.XmlLangAttr != nil {
+						postal.Lang = string(*pa.XmlLangAttr)
+					}
+					meta.Contact.PostalAddresses = append(meta.Contact.PostalAddresses, postal)
+				}
+			}
+		}
+	}
+
+	for i, cert := range certs {
+		fp := sha256.Sum256(cert.Raw)
+		meta.SignerCertificates = append(meta.SignerCertificates, NotificationCertificate{
+			File:         fmt.Sprintf("certificates/signer-%d.pem", i+1),
+			Subject:      cert.Subject.String(),
+			Issuer:       cert.Issuer.String(),
+			SerialNumber: cert.SerialNumber.String(),
+			NotBefore:    cert.NotBefore.UTC().Format(time.RFC3339),
+			NotAfter:     cert.NotAfter.UTC().Format(time.RFC3339),
+			SHA256:       hex.EncodeToString(fp[:]),
+		})
+	}
+
+	return meta
+}
+
+// notificationNames converts international names to notification names.
+func notificationNames(names *etsi119612.InternationalNamesType) []NotificationName {
+	if names == nil {
+		return nil
+	}
+	var result []NotificationName
+	for _, n := range names.Name {
+		if n == nil || n.NonEmptyNormalizedString == nil {
+			continue
+		}
+		name := NotificationName{Value: string(*n.NonEmptyNormalizedString)}
+		if n.XmlLangAttr != nil {
+			name.Lang = string(*n.XmlLangAttr)
+		}
+		result = append(result, name)
+	}
+	return result
+}
+
+// addZipFile adds a file with the given content and modification time to a
+// ZIP archive.
+func addZipFile(zw *zip.Writer, name string, data []byte, modified time.Time) error {
+	w, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: modified,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add %s to ZIP file: %w", name, err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("failed to add %s to ZIP file: %w", name, err)
+	}
+	return nil
+}
+
+// exportNotificationOutputs is the OutputsFunc of the export-notification
+// step: the ZIP file.
+func exportNotificationOutputs(args ...string) []string {
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "tsl:") && !strings.HasPrefix(arg, "signer-cert:") {
+			return []string{arg}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package pipeline
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirosfoundation/g119612/pkg/etsi119612"
+	"github.com/sirosfoundation/g119612/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readZipEntries(t *testing.T, path string) map[string][]byte {
+	t.Helper()
+	zr, err := zip.OpenReader(path)
+	require.NoError(t, err)
+	defer zr.Close()
+
+	entries := make(map[string][]byte)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		require.NoError(t, err)
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		require.NoError(t, err)
+		entries[f.Name] = data
+	}
+	return entries
+}
+
+func TestExportNotification(t *testing.T) {
+	pl := &Pipeline{Logger: logging.SilentLogger()}
+	ctx := NewContext()
+
+	tsl := generateTSL("Test Service", "http://uri.etsi.org/TrstSvc/Svctype/CA/QC", []string{TestCertBase64})
+	si := tsl.StatusList.TslSchemeInformation
+	si.TslSchemeTerritory = "SE"
+	si.TSLSequenceNumber = 7
+	si.TslDistributionPoints = &etsi119612.NonEmptyURIListType{URI: []string{"https://tsl.example.com/SE-TL.xml"}}
+	lang := etsi119612.Lang("en")
+	si.SchemeOperatorAddress = &etsi119612.AddressType{
+		TslElectronicAddress: &etsi119612.ElectronicAddressType{
+			URI: []*etsi119612.NonEmptyMultiLangURIType{{XmlLangAttr: &lang, Value: "mailto:tsl@example.com"}},
+		},
+		TslPostalAddresses: &etsi119612.PostalAddressListType{
+			TslPostalAddress: []*etsi119612.PostalAddressType{{XmlLangAttr: &lang, StreetAddress: "Street 1", Locality: "Stockholm", CountryName: "SE"}},
+		},
+	}
+	ctx.AddTSL(tsl)
+
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	require.NoError(t, generateTestCertAndKey(certFile, keyFile))
+
+	zipPath := filepath.Join(dir, "out", "notification.zip")
+	_, err := ExportNotification(pl, ctx, zipPath, "signer-cert:"+certFile)
+	require.NoError(t, err)
+
+	entries := readZipEntries(t, zipPath)
+	require.Contains(t, entries, "tsl/SE-TL.xml")
+	require.Contains(t, entries, "certificates/signer-1.pem")
+	require.Contains(t, entries, "notification.json")
+
+	certPEM, err := os.ReadFile(certFile)
+	require.NoError(t, err)
+	assert.Equal(t, string(certPEM), string(entries["certificates/signer-1.pem"]))
+
+	var meta NotificationMetadata
+	require.NoError(t, json.Unmarshal(entries["notification.json"], &meta))
+	assert.Equal(t, "SE", meta.Territory)
+	assert.Equal(t, 7, meta.SequenceNumber)
+	assert.Equal(t, "https://tsl.example.com/SE-TL.xml", meta.TSLLocation)
+	assert.Equal(t, "tsl/SE-TL.xml", meta.TSLFile)
+	assert.Equal(t, NotificationMimeType, meta.MimeType)
+	assert.Equal(t, []NotificationName{{Lang: "en", Value: "Test Operator"}}, meta.SchemeOperatorNames)
+	assert.Equal(t, []string{"mailto:tsl@example.com"}, meta.Contact.ElectronicAddresses)
+	require.Len(t, meta.Contact.PostalAddresses, 1)
+	assert.Equal(t, "Stockholm", meta.Contact.PostalAddresses[0].Locality)
+	require.Len(t, meta.SignerCertificates, 1)
+	assert.Equal(t, "certificates/signer-1.pem", meta.SignerCertificates[0].File)
+	assert.Len(t, meta.SignerCertificates[0].SHA256, 64)
+	assert.Len(t, meta.TSLSHA256, 64)
+}
+
+func TestExportNotification_PublishedFile(t *testing.T) {
+	pl := &Pipeline{Logger: logging.SilentLogger()}
+	ctx := NewContext()
+	ctx.AddTSL(generateTSL("Test Service", "http://uri.etsi.org/TrstSvc/Svctype/CA/QC", []string{TestCertBase64}))
+
+	dir := t.TempDir()
+	published := filepath.Join(dir, "published.xml")
+	require.NoError(t, os.WriteFile(published, []byte("<signed/>"), 0644))
+
+	zipPath := filepath.Join(dir, "notification.zip")
+	_, err := ExportNotification(pl, ctx, zipPath, "tsl:"+published)
+	require.NoError(t, err)
+
+	entries := readZipEntries(t, zipPath)
+	assert.Equal(t, "<signed/>", string(entries["tsl/published.xml"]))
+
+	var meta NotificationMetadata
+	require.NoError(t, json.Unmarshal(entries["notification.json"], &meta))
+	assert.Empty(t, meta.SignerCertificates)
+}
+
+func TestExportNotification_Errors(t *testing.T) {
+	pl := &Pipeline{Logger: logging.SilentLogger()}
+	dir := t.TempDir()
+
+	_, err := ExportNotification(pl, NewContext())
+	assert.Error(t, err)
+
+	_, err = ExportNotification(pl, NewContext(), filepath.Join(dir, "n.zip"))
+	assert.ErrorIs(t, err, ErrNoTSLs)
+
+	ctx := NewContext()
+	ctx.AddTSL(generateTSL("Test Service", "http://uri.etsi.org/TrstSvc/Svctype/CA/QC", []string{TestCertBase64}))
+	_, err = ExportNotification(pl, ctx, filepath.Join(dir, "n.zip"), "extra")
+	assert.ErrorIs(t, err, ErrInvalidArguments)
+
+	notPEM := filepath.Join(dir, "not.pem")
+	require.NoError(t, os.WriteFile(notPEM, []byte("nothing here"), 0644))
+	_, err = ExportNotification(pl, ctx, filepath.Join(dir, "n.zip"), "signer-cert:"+notPEM)
+	var certErr *CertificateError
+	assert.ErrorAs(t, err, &certErr)
+}
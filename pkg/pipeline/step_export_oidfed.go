@@ -0,0 +1,284 @@
+package pipeline
+
+import (
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sirosfoundation/g119612/pkg/etsi119612"
+	"github.com/sirosfoundation/g119612/pkg/logging"
+	"github.com/sirosfoundation/g119612/pkg/oidfed"
+	"github.com/sirosfoundation/g119612/pkg/validation"
+)
+
+// Formats accepted by the format option of the export-oidfed step.
+const (
+	OIDFedFormatEntityStatement = "entity-statement"
+	OIDFedFormatTrustMark       = "trust-mark"
+)
+
+// OIDFedIndexEntry describes one token written by the export-oidfed step in
+// its index.json.
+type OIDFedIndexEntry struct {
+	Subject       string `json:"sub"`
+	Format        string `json:"format"`
+	TrustMarkType string `json:"trust_mark_type,omitempty"`
+	File          string `json:"file"`
+	Expires       string `json:"exp"`
+}
+
+// exportOIDFedOptions are the parsed arguments of the export-oidfed step.
+type exportOIDFedOptions struct {
+	dir      string
+	issuer   string
+	keyPath  string
+	kid      string
+	formats  []string
+	policy   *etsi119612.TSPServicePolicy
+	lifetime time.Duration
+}
+
+// ExportOIDFed is a pipeline step that expresses the TSPs of the loaded TSLs in
+// OpenID Federation terms, bridging trust decisions taken from trusted lists to
+// OpenID Federation based ecosystems (see package oidfed). Every TSP with a
+// service accepted by the service filter and an https TSPInformationURI, its
+// entity identifier, becomes a subordinate entity statement, or one trust mark
+// per service type, signed as JWT by the issuer.
+//
+// Parameters:
+//   - pl: Pipeline instance managing the step execution
+//   - ctx: Pipeline context containing the loaded TSLs, including referenced ones
+//   - args: String slice where args[0] is the output directory. Options in
+//     "key:value" form may follow:
+//   - issuer:URL: Required - Entity identifier of the issuing federation entity
+//   - key:/path/to/key.pem: Required - PEM private key (RSA, ECDSA or Ed25519) signing the JWTs
+//   - kid:ID: Key identifier of the signing key (default: its JWK thumbprint)
+//   - format:entity-statement|trust-mark: What to write, may be repeated (default: entity-statement)
+//   - service-type:URI: Only export services of this type, may be repeated
+//   - status:URI: Only export services with this status, may be repeated (default: granted)
+//   - lifetime:DURATION: Validity of the JWTs (default: until the NextUpdate of the TSL, or 24h)
+//
+// The directory receives entity-statements/<subject>.jwt, trust-marks/<subject>-<type>.jwt,
+// jwks.json with the public key of the issuer, and index.json listing every
+// token (see OIDFedIndexEntry). TSPs without an entity identifier are skipped with
+// a warning.
+//
+// Returns:
+//   - *Context: The context unchanged
+//   - error: Non-nil if an argument is invalid, there are no TSLs or a file cannot be written
+//
+// Example usage in pipeline configuration:
+//   - export-oidfed:
+//   - /var/www/federation
+//   - issuer:https://ta.example.com
+//   - key:/etc/tsl/federation-key.pem
+//   - format:trust-mark
+//   - service-type:http://uri.etsi.org/TrstSvc/Svctype/CA/QC
+func ExportOIDFed(pl *Pipeline, ctx *Context, args ...string) (*Context, error) {
+	opts, err := parseExportOIDFedArgs(args)
+	if err != nil {
+		return ctx, err
+	}
+	if ctx.TSLs == nil || ctx.TSLs.IsEmpty() {
+		return ctx, ErrNoTSLs
+	}
+	key, err := oidfed.LoadSigningKey(opts.keyPath)
+	if err != nil {
+		return ctx, err
+	}
+	issuerJWK, err := oidfed.NewJWK(key.Public())
+	if err != nil {
+		return ctx, fmt.Errorf("unsupported signing key %s: %w", opts.keyPath, err)
+	}
+	if opts.kid != "" {
+		issuerJWK.Kid = opts.kid
+	}
+	issuerJWK.Use = "sig"
+
+	subjects, skipped := oidfed.Select(ctx.TSLs.ToSlice(), opts.policy)
+	for _, name := range skipped {
+		pl.Logger.Warn("Skipping TSP without https TSPInformationURI for OpenID Federation export",
+			logging.F("tsp", name))
+	}
+
+	convert := oidfed.Options{Issuer: opts.issuer, Lifetime: opts.lifetime, Now: ctx.Now()}
+	var index []OIDFedIndexEntry
+	files := make(map[string][]byte)
+	for _, subject := range subjects {
+		name := oidfedFileName(subject.EntityID)
+		for _, format := range opts.formats {
+			if format == OIDFedFormatEntityStatement {
+				statement := subject.EntityStatement(convert)
+				file := filepath.Join("entity-statements", name+".jwt")
+				if err := addOIDFedToken(files, file, statement, key, oidfed.TypeEntityStatement, issuerJWK.Kid); err != nil {
+					return ctx, err
+				}
+				index = append(index, OIDFedIndexEntry{Subject: statement.Subject, Format: format, File: file,
+					Expires: time.Unix(statement.Expires, 0).UTC().Format(time.RFC3339)})
+				continue
+			}
+			for _, mark := range subject.TrustMarks(convert) {
+				file := filepath.Join("trust-marks", name+"-"+oidfedTypeName(mark.TrustMarkType)+".jwt")
+				if err := addOIDFedToken(files, file, mark, key, oidfed.TypeTrustMark, issuerJWK.Kid); err != nil {
+					return ctx, err
+				}
+				index = append(index, OIDFedIndexEntry{Subject: mark.Subject, Format: format, TrustMarkType: mark.TrustMarkType,
+					File: file, Expires: time.Unix(mark.Expires, 0).UTC().Format(time.RFC3339)})
+			}
+		}
+	}
+
+	jwks, err := json.MarshalIndent(oidfed.JWKSet{Keys: []oidfed.JWK{issuerJWK}}, "", "  ")
+	if err != nil {
+		return ctx, err
+	}
+	files["jwks.json"] = jwks
+	if index == nil {
+		index = []OIDFedIndexEntry{}
+	}
+	indexJSON, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return ctx, err
+	}
+	files["index.json"] = indexJSON
+
+	for _, file := range slices.Sorted(maps.Keys(files)) {
+		data := files[file]
+		path := filepath.Join(opts.dir, file)
+		if err := os.MkdirAll(filepath.Dir(path), DefaultPublishDirMode); err != nil {
+			return ctx, fmt.Errorf("failed to create directory for %s: %w", path, err)
+		}
+		if err := writeFileAtomic(path, data, DefaultPublishFileMode); err != nil {
+			return ctx, fmt.Errorf("failed to write %s: %w", path, err)
+		}
+	}
+
+	pl.Logger.Info("Exported OpenID Federation statements",
+		logging.F("dir", opts.dir),
+		logging.F("issuer", opts.issuer),
+		logging.F("subjects", len(subjects)),
+		logging.F("tokens", len(index)),
+		logging.F("skipped", len(skipped)))
+	return ctx, nil
+}
+
+// addOIDFedToken signs claims and adds the JWT to files.
+func addOIDFedToken(files map[string][]byte, file string, claims any, key crypto.Signer, typ, kid string) error {
+	token, err := oidfed.Sign(claims, key, typ, kid)
+	if err != nil {
+		return err
+	}
+	files[file] = []byte(token)
+	return nil
+}
+
+// parseExportOIDFedArgs parses the arguments of the export-oidfed step.
+func parseExportOIDFedArgs(args []string) (exportOIDFedOptions, error) {
+	var opts exportOIDFedOptions
+	var serviceTypes, statuses []string
+	for _, arg := range args {
+		switch {
+		case strings.HasPrefix(arg, "issuer:"):
+			opts.issuer = strings.TrimPrefix(arg, "issuer:")
+			if err := validation.ValidateURL(opts.issuer, validation.StrictURLOptions("https")); err != nil {
+				return opts, fmt.Errorf("%w: invalid issuer: %v", ErrInvalidArguments, err)
+			}
+		case strings.HasPrefix(arg, "key:"):
+			opts.keyPath = strings.TrimPrefix(arg, "key:")
+			if err := validation.ValidateFilePath(opts.keyPath); err != nil {
+				return opts, fmt.Errorf("%w: invalid key path: %v", ErrInvalidArguments, err)
+			}
+		case strings.HasPrefix(arg, "kid:"):
+			opts.kid = strings.TrimPrefix(arg, "kid:")
+		case strings.HasPrefix(arg, "format:"):
+			format := strings.TrimPrefix(arg, "format:")
+			if format != OIDFedFormatEntityStatement && format != OIDFedFormatTrustMark {
+				return opts, fmt.Errorf("%w: invalid format %q (expected entity-statement or trust-mark)", ErrInvalidArguments, format)
+			}
+			opts.formats = append(opts.formats, format)
+		case strings.HasPrefix(arg, "service-type:"):
+			serviceTypes = append(serviceTypes, strings.TrimPrefix(arg, "service-type:"))
+		case strings.HasPrefix(arg, "status:"):
+			statuses = append(statuses, strings.TrimPrefix(arg, "status:"))
+		case strings.HasPrefix(arg, "lifetime:"):
+			lifetime, err := time.ParseDuration(strings.TrimPrefix(arg, "lifetime:"))
+			if err != nil || lifetime <= 0 {
+				return opts, fmt.Errorf("%w: invalid lifetime %q", ErrInvalidArguments, arg)
+			}
+			opts.lifetime = lifetime
+		case opts.dir == "" && !strings.Contains(arg, ":"):
+			opts.dir = arg
+		default:
+			return opts, fmt.Errorf("%w: unexpected argument %q", ErrInvalidArguments, arg)
+		}
+	}
+	if opts.dir == "" {
+		return opts, fmt.Errorf("%w: missing output directory", ErrInvalidArguments)
+	}
+	if err := validation.ValidateOutputDirectory(opts.dir); err != nil {
+		return opts, fmt.Errorf("%w: invalid output directory: %v", ErrInvalidArguments, err)
+	}
+	if opts.issuer == "" {
+		return opts, fmt.Errorf("%w: missing issuer", ErrInvalidArguments)
+	}
+	if opts.keyPath == "" {
+		return opts, fmt.Errorf("%w: missing key", ErrInvalidArguments)
+	}
+	if len(opts.formats) == 0 {
+		opts.formats = []string{OIDFedFormatEntityStatement}
+	}
+
+	opts.policy = etsi119612.NewTSPServicePolicy()
+	opts.policy.ServiceTypeIdentifier = serviceTypes
+	if len(statuses) > 0 {
+		opts.policy.ServiceStatus = statuses
+	}
+	return opts, nil
+}
+
+// validateExportOIDFedArgs is the ArgsValidator of the export-oidfed step.
+func validateExportOIDFedArgs(args ...string) error {
+	_, err := parseExportOIDFedArgs(args)
+	return err
+}
+
+// exportOIDFedOutputs is the OutputsFunc of the export-oidfed step: the
+// output directory, and the key signing the tokens.
+func exportOIDFedOutputs(args ...string) []string {
+	opts, err := parseExportOIDFedArgs(args)
+	if err != nil {
+		return nil
+	}
+	return []string{opts.dir, "signature:" + opts.keyPath}
+}
+
+// oidfedFileName turns an entity identifier into a file name, e.g.
+// "https://tsp.example.com/eid" into "tsp.example.com-eid".
+func oidfedFileName(entityID string) string {
+	if u, err := url.Parse(entityID); err == nil && u.Host != "" {
+		entityID = u.Host + u.Path
+	}
+	return strings.Trim(strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '_':
+			return r
+		}
+		return '-'
+	}, entityID), "-.")
+}
+
+// oidfedTypeName turns a service type URI into a file name part, e.g.
+// "http://uri.etsi.org/TrstSvc/Svctype/CA/QC" into "CA-QC".
+func oidfedTypeName(serviceType string) string {
+	if _, rest, ok := strings.Cut(serviceType, "/Svctype/"); ok {
+		serviceType = rest
+	}
+	return oidfedFileName(serviceType)
+}
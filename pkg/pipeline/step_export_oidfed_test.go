@@ -0,0 +1,108 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirosfoundation/g119612/pkg/etsi119612"
+	"github.com/sirosfoundation/g119612/pkg/logging"
+	"github.com/sirosfoundation/g119612/pkg/oidfed"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// oidfedTestTSL returns a TSL whose TSP has the entity identifier entityID.
+func oidfedTestTSL(serviceType, entityID string) *etsi119612.TSL {
+	tsl := generateTSL("Test Service", serviceType, []string{TestCertBase64})
+	tsl.Source = "https://tsl.example.com/SE-TL.xml"
+	tsp := tsl.StatusList.TslTrustServiceProviderList.TslTrustServiceProvider[0]
+	tsp.TslTSPInformation.TSPInformationURI = &etsi119612.NonEmptyMultiLangURIListType{
+		URI: []*etsi119612.NonEmptyMultiLangURIType{{Value: entityID}},
+	}
+	return tsl
+}
+
+func TestExportOIDFed(t *testing.T) {
+	pl := &Pipeline{Logger: logging.SilentLogger()}
+	ctx := NewContext()
+	ctx.AddTSL(oidfedTestTSL("http://uri.etsi.org/TrstSvc/Svctype/CA/QC", "https://ca.example.com/tsp"))
+	ctx.AddTSL(oidfedTestTSL("http://uri.etsi.org/TrstSvc/Svctype/TSA/QTST", "https://tsa.example.com"))
+	ctx.AddTSL(oidfedTestTSL("http://uri.etsi.org/TrstSvc/Svctype/CA/QC", "mailto:info@example.com"))
+
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "key.pem")
+	require.NoError(t, generateTestCertAndKey(filepath.Join(dir, "cert.pem"), keyFile))
+	out := filepath.Join(dir, "federation")
+
+	_, err := ExportOIDFed(pl, ctx, out, "issuer:https://ta.example.com", "key:"+keyFile,
+		"format:entity-statement", "format:trust-mark", "lifetime:1h")
+	require.NoError(t, err)
+
+	var jwks oidfed.JWKSet
+	data, err := os.ReadFile(filepath.Join(out, "jwks.json"))
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &jwks))
+	require.Len(t, jwks.Keys, 1)
+	assert.Equal(t, "sig", jwks.Keys[0].Use)
+
+	var index []OIDFedIndexEntry
+	data, err = os.ReadFile(filepath.Join(out, "index.json"))
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &index))
+	assert.Len(t, index, 4, "two entity statements and two trust marks")
+
+	now := time.Now()
+	token, err := os.ReadFile(filepath.Join(out, "entity-statements", "ca.example.com-tsp.jwt"))
+	require.NoError(t, err)
+	statement, err := oidfed.VerifyEntityStatement(string(token), jwks, now)
+	require.NoError(t, err)
+	assert.Equal(t, "https://ta.example.com", statement.Issuer)
+	assert.Equal(t, "https://ca.example.com/tsp", statement.Subject)
+	assert.Len(t, statement.JWKS.Keys, 1)
+	assert.LessOrEqual(t, statement.Expires, now.Add(time.Hour).Unix())
+
+	token, err = os.ReadFile(filepath.Join(out, "trust-marks", "tsa.example.com-TSA-QTST.jwt"))
+	require.NoError(t, err)
+	mark, err := oidfed.VerifyTrustMark(string(token), jwks, now)
+	require.NoError(t, err)
+	assert.Equal(t, "https://tsa.example.com", mark.Subject)
+	assert.Equal(t, "http://uri.etsi.org/TrstSvc/Svctype/TSA/QTST", mark.TrustMarkType)
+	assert.Equal(t, "https://tsl.example.com/SE-TL.xml", mark.Ref)
+
+	// The service type filter limits the exported TSPs
+	filtered := filepath.Join(dir, "filtered")
+	_, err = ExportOIDFed(pl, ctx, filtered, "issuer:https://ta.example.com", "key:"+keyFile,
+		"service-type:http://uri.etsi.org/TrstSvc/Svctype/TSA/QTST")
+	require.NoError(t, err)
+	entries, err := os.ReadDir(filepath.Join(filtered, "entity-statements"))
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "tsa.example.com.jwt", entries[0].Name())
+
+	_, err = ExportOIDFed(pl, NewContext(), out, "issuer:https://ta.example.com", "key:"+keyFile)
+	assert.ErrorIs(t, err, ErrNoTSLs)
+	_, err = ExportOIDFed(pl, ctx, out, "issuer:https://ta.example.com", "key:"+filepath.Join(dir, "missing.pem"))
+	assert.Error(t, err)
+}
+
+func TestValidateExportOIDFedArgs(t *testing.T) {
+	valid := []string{"/tmp/federation", "issuer:https://ta.example.com", "key:/etc/key.pem"}
+	assert.NoError(t, validateExportOIDFedArgs(valid...))
+	assert.NoError(t, validateExportOIDFedArgs(append(valid, "format:trust-mark", "kid:k1", "lifetime:12h",
+		"status:http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/withdrawn")...))
+
+	for name, args := range map[string][]string{
+		"missing directory": {"issuer:https://ta.example.com", "key:/etc/key.pem"},
+		"missing issuer":    {"/tmp/federation", "key:/etc/key.pem"},
+		"missing key":       {"/tmp/federation", "issuer:https://ta.example.com"},
+		"http issuer":       {"/tmp/federation", "issuer:http://ta.example.com", "key:/etc/key.pem"},
+		"unknown format":    append(valid, "format:jwks"),
+		"bad lifetime":      append(valid, "lifetime:soon"),
+		"unknown option":    append(valid, "color:blue"),
+	} {
+		assert.ErrorIs(t, validateExportOIDFedArgs(args...), ErrInvalidArguments, name)
+	}
+}
@@ -1,13 +1,17 @@
 package pipeline
 
 import (
+	"crypto/x509"
 	"fmt"
+	"maps"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/sirosfoundation/g119612/pkg/etsi119612"
 	"github.com/sirosfoundation/g119612/pkg/logging"
+	"github.com/sirosfoundation/g119612/pkg/validation"
 )
 
 // SetFetchOptions is a pipeline step that configures the options for fetching Trust Status Lists.
@@ -22,8 +26,54 @@ import (
 //   - max-depth: Maximum depth for following TSL references (integer, 0=none, -1=unlimited)
 //   - accept: Comma-separated list of Accept header values for content negotiation (e.g., "application/xml,text/xml")
 //   - prefer-xml: If set to "true", the fetcher will try .xml extension if .pdf fails
-//   - filter-territory: Only include TSLs from the specified territory (e.g., "SE,FI,NO")
+//   - filter-territory: Only include TSLs from the specified territory (e.g., "SE,FI,NO");
+//     referenced TSLs whose pointer declares another territory are not fetched
 //   - filter-service-type: Only include TSLs with services of the specified type(s) (comma-separated)
+//   - max-idle-conns-per-host: Idle connections kept per host by the shared HTTP transport
+//   - idle-conn-timeout: How long idle connections are kept (any valid Go duration string)
+//   - http2: Set to "false" to restrict fetching to HTTP/1.1
+//   - compression: Set to "false" to stop requesting gzip encoded responses
+//   - fetch-cache: Set to "false" to fetch a referenced TSL each time it is referenced
+//     instead of once per run (on by default)
+//   - mirror: Read all TSLs from the local mirror in the given directory, written by the
+//     mirror step, instead of fetching them; an empty value fetches again
+//   - retries: Times a fetch failing transiently (timeout, connection error, HTTP 5xx,
+//     408 or 429) is retried; permanent failures such as 404 or a TSL that does not
+//     parse are not retried (default 0)
+//   - retry-delay: Delay before the first retry, each further retry waits one more
+//     delay (any valid Go duration string, default 1s)
+//   - ipfs-gateway: HTTP(S) gateway ipfs://CID URLs are fetched through, block by block
+//     with every block verified against its CID (default etsi119612.DefaultIPFSGateway);
+//     an empty value restores the default
+//   - signature-algorithms: Comma-separated list of the accepted signature algorithms of
+//     TSL signatures, as URIs or their fragments (e.g. "rsa-sha256,ecdsa-sha256");
+//     an empty value accepts any algorithm
+//   - digest-algorithms: Comma-separated list of the accepted digest algorithms of
+//     TSL signatures (e.g. "sha256,sha512"); an empty value accepts any algorithm
+//   - allow-sha1: Set to "true" to accept TSLs signed with SHA-1, rejected by default
+//   - allow-sha1-url: Accept SHA-1 for the TSL at the given URL only, repeatable
+//   - min-rsa-bits: Minimum size of the RSA key TSLs are signed with
+//     (default etsi119612.DefaultMinRSAKeySize, -1 for any size)
+//   - raw-spill-dir: Directory the original documents of large TSLs are written to instead
+//     of being kept in memory (see etsi119612.TSL.RawXML); an empty value keeps them in memory
+//   - raw-spill-threshold: Size in bytes above which documents are spilled
+//     (default etsi119612.DefaultRawSpillThreshold)
+//   - signer-roots: PEM file with root certificates the signers of TSLs must chain to,
+//     repeatable; TSLs whose signer does not chain are rejected (see
+//     etsi119612.TSLFetchOptions.SignerRoots)
+//   - aia-allow-host: Host intermediates missing from the signature of a TSL may be fetched
+//     from, through the caIssuers URL of the signer certificate; a host name or
+//     "*.example.com", repeatable (see etsi119612.IssuerFetcher)
+//   - host: Options for the hosts matching a pattern, followed by the options separated by
+//     spaces, e.g. "host:ec.europa.eu timeout:180s retries:5". The pattern is a host name or
+//     "*.example.com" for a domain and its subdomains. The options are timeout, accept,
+//     retries, retry-delay, rate-limit (requests per second, minute or hour, e.g. "30/m"),
+//     min-interval (time between requests) and user-agent, which must come last and takes
+//     the rest of the value. A host option without options removes the override of the
+//     pattern (see etsi119612.HostOverride)
+//
+// Setting any of the last four options makes each load share one tuned HTTP transport
+// between the root TSL and all referenced TSLs (see etsi119612.TransportOptions).
 //
 // Returns:
 //   - *Context: Updated context with the configured fetch options
@@ -37,19 +87,21 @@ import (
 //   - accept:application/xml,text/xml
 //   - prefer-xml:true
 //   - filter-territory:SE
+//   - retries:3
+//   - host:ec.europa.eu timeout:180s retries:5
+//
+// Or requiring signers issued under a known root, fetching missing intermediates:
+//   - set-fetch-options:
+//   - signer-roots:/etc/tsl/signer-roots.pem
+//   - aia-allow-host:*.example-ca.com
 func SetFetchOptions(pl *Pipeline, ctx *Context, args ...string) (*Context, error) {
 	// Ensure the TSLFetchOptions are initialized
 	ctx.EnsureTSLFetchOptions()
 
-	// Create custom filters field if it doesn't exist
-	if ctx.Data["tsl_filters"] == nil {
-		ctx.Data["tsl_filters"] = make(map[string][]string)
-	}
-	filters, ok := ctx.Data["tsl_filters"].(map[string][]string)
-	if !ok {
-		// If it's not the right type, recreate it
-		filters = make(map[string][]string)
-		ctx.Data["tsl_filters"] = filters
+	// Work on a copy of the filters, since other steps may be reading the stored map
+	filters := make(map[string][]string)
+	if existing, ok := dataValue[map[string][]string](ctx, "tsl_filters"); ok {
+		maps.Copy(filters, existing)
 	}
 
 	for _, arg := range args {
@@ -91,10 +143,10 @@ func SetFetchOptions(pl *Pipeline, ctx *Context, args ...string) (*Context, erro
 			preferXML := strings.TrimPrefix(arg, "prefer-xml:")
 			if preferXML == "true" || preferXML == "1" || preferXML == "yes" {
 				// Store in context data instead since we can't modify the TSLFetchOptions structure
-				ctx.Data["prefer_xml_over_pdf"] = true
+				ctx.SetData("prefer_xml_over_pdf", true)
 				pl.Logger.Debug("Set TSL fetch prefer XML over PDF", logging.F("prefer-xml", true))
 			} else {
-				ctx.Data["prefer_xml_over_pdf"] = false
+				ctx.SetData("prefer_xml_over_pdf", false)
 				pl.Logger.Debug("Set TSL fetch prefer XML over PDF", logging.F("prefer-xml", false))
 			}
 		} else if strings.HasPrefix(arg, "filter-territory:") {
@@ -117,13 +169,259 @@ func SetFetchOptions(pl *Pipeline, ctx *Context, args ...string) (*Context, erro
 				}
 				pl.Logger.Debug("Set TSL filter by service type", logging.F("service-types", filters["service-type"]))
 			}
+		} else if strings.HasPrefix(arg, "max-idle-conns-per-host:") {
+			valueStr := strings.TrimPrefix(arg, "max-idle-conns-per-host:")
+			value, err := strconv.Atoi(valueStr)
+			if err != nil || value < 1 {
+				return ctx, fmt.Errorf("invalid max-idle-conns-per-host value: %s", valueStr)
+			}
+			ctx.TSLFetchOptions.Transport.MaxIdleConnsPerHost = value
+			pl.Logger.Debug("Set TSL fetch idle connections per host", logging.F("max-idle-conns-per-host", value))
+		} else if strings.HasPrefix(arg, "idle-conn-timeout:") {
+			valueStr := strings.TrimPrefix(arg, "idle-conn-timeout:")
+			value, err := time.ParseDuration(valueStr)
+			if err != nil || value <= 0 {
+				return ctx, fmt.Errorf("invalid idle-conn-timeout value: %s", valueStr)
+			}
+			ctx.TSLFetchOptions.Transport.IdleConnTimeout = value
+			pl.Logger.Debug("Set TSL fetch idle connection timeout", logging.F("idle-conn-timeout", value))
+		} else if strings.HasPrefix(arg, "http2:") {
+			value, err := strconv.ParseBool(strings.TrimPrefix(arg, "http2:"))
+			if err != nil {
+				return ctx, fmt.Errorf("invalid http2 value: %s (%w)", arg, err)
+			}
+			ctx.TSLFetchOptions.Transport.DisableHTTP2 = !value
+			pl.Logger.Debug("Set TSL fetch HTTP/2", logging.F("http2", value))
+		} else if strings.HasPrefix(arg, "compression:") {
+			value, err := strconv.ParseBool(strings.TrimPrefix(arg, "compression:"))
+			if err != nil {
+				return ctx, fmt.Errorf("invalid compression value: %s (%w)", arg, err)
+			}
+			ctx.TSLFetchOptions.Transport.DisableCompression = !value
+			pl.Logger.Debug("Set TSL fetch compression", logging.F("compression", value))
+		} else if strings.HasPrefix(arg, "fetch-cache:") {
+			value, err := strconv.ParseBool(strings.TrimPrefix(arg, "fetch-cache:"))
+			if err != nil {
+				return ctx, fmt.Errorf("invalid fetch-cache value: %s (%w)", arg, err)
+			}
+			if !value {
+				ctx.TSLFetchOptions.Cache = nil
+			} else if ctx.TSLFetchOptions.Cache == nil {
+				ctx.TSLFetchOptions.Cache = etsi119612.NewFetchCache()
+			}
+			pl.Logger.Debug("Set TSL fetch cache", logging.F("fetch-cache", value))
+		} else if strings.HasPrefix(arg, "mirror:") {
+			dir := strings.TrimPrefix(arg, "mirror:")
+			if dir == "" {
+				ctx.TSLFetchOptions.Mirror = nil
+			} else {
+				mirror, err := etsi119612.OpenMirror(dir)
+				if err != nil {
+					return ctx, fmt.Errorf("invalid mirror value: %s (%w)", dir, err)
+				}
+				ctx.TSLFetchOptions.Mirror = mirror
+				pl.Logger.Info("Reading TSLs from mirror",
+					logging.F("dir", dir),
+					logging.F("count", len(mirror.Manifest().TSLs)))
+			}
+		} else if strings.HasPrefix(arg, "retries:") {
+			valueStr := strings.TrimPrefix(arg, "retries:")
+			value, err := strconv.Atoi(valueStr)
+			if err != nil || value < 0 {
+				return ctx, fmt.Errorf("invalid retries value: %s", valueStr)
+			}
+			ctx.TSLFetchOptions.Retries = value
+			pl.Logger.Debug("Set TSL fetch retries", logging.F("retries", value))
+		} else if strings.HasPrefix(arg, "retry-delay:") {
+			valueStr := strings.TrimPrefix(arg, "retry-delay:")
+			value, err := time.ParseDuration(valueStr)
+			if err != nil || value <= 0 {
+				return ctx, fmt.Errorf("invalid retry-delay value: %s", valueStr)
+			}
+			ctx.TSLFetchOptions.RetryDelay = value
+			pl.Logger.Debug("Set TSL fetch retry delay", logging.F("retry-delay", value))
+		} else if strings.HasPrefix(arg, "ipfs-gateway:") {
+			gateway := strings.TrimPrefix(arg, "ipfs-gateway:")
+			if gateway != "" {
+				if err := validation.ValidateURL(gateway, validation.StrictURLOptions("http", "https")); err != nil {
+					return ctx, fmt.Errorf("invalid ipfs-gateway value: %s (%w)", gateway, err)
+				}
+			}
+			ctx.TSLFetchOptions.IPFSGateway = gateway
+			pl.Logger.Debug("Set IPFS gateway", logging.F("ipfs-gateway", gateway))
+		} else if strings.HasPrefix(arg, "signature-algorithms:") {
+			algorithms := splitAlgorithms(strings.TrimPrefix(arg, "signature-algorithms:"))
+			ctx.TSLFetchOptions.AlgorithmPolicy.SignatureAlgorithms = algorithms
+			pl.Logger.Debug("Set accepted TSL signature algorithms", logging.F("signature-algorithms", algorithms))
+		} else if strings.HasPrefix(arg, "digest-algorithms:") {
+			algorithms := splitAlgorithms(strings.TrimPrefix(arg, "digest-algorithms:"))
+			ctx.TSLFetchOptions.AlgorithmPolicy.DigestAlgorithms = algorithms
+			pl.Logger.Debug("Set accepted TSL digest algorithms", logging.F("digest-algorithms", algorithms))
+		} else if strings.HasPrefix(arg, "allow-sha1:") {
+			value, err := strconv.ParseBool(strings.TrimPrefix(arg, "allow-sha1:"))
+			if err != nil {
+				return ctx, fmt.Errorf("invalid allow-sha1 value: %s (%w)", arg, err)
+			}
+			ctx.TSLFetchOptions.AlgorithmPolicy.AllowSHA1 = value
+			pl.Logger.Debug("Set TSL SHA-1 acceptance", logging.F("allow-sha1", value))
+		} else if strings.HasPrefix(arg, "allow-sha1-url:") {
+			url := strings.TrimPrefix(arg, "allow-sha1-url:")
+			if url == "" {
+				return ctx, fmt.Errorf("invalid allow-sha1-url value: a URL is required")
+			}
+			// Copy the list, since contexts may share it
+			if !slices.Contains(ctx.TSLFetchOptions.AllowSHA1URLs, url) {
+				ctx.TSLFetchOptions.AllowSHA1URLs = append(slices.Clip(ctx.TSLFetchOptions.AllowSHA1URLs), url)
+			}
+			pl.Logger.Debug("Accepting SHA-1 for TSL", logging.F("url", url))
+		} else if strings.HasPrefix(arg, "min-rsa-bits:") {
+			valueStr := strings.TrimPrefix(arg, "min-rsa-bits:")
+			value, err := strconv.Atoi(valueStr)
+			if err != nil || value < -1 {
+				return ctx, fmt.Errorf("invalid min-rsa-bits value: %s", valueStr)
+			}
+			ctx.TSLFetchOptions.AlgorithmPolicy.MinRSAKeySize = value
+			pl.Logger.Debug("Set minimum TSL signer RSA key size", logging.F("min-rsa-bits", value))
+		} else if strings.HasPrefix(arg, "raw-spill-dir:") {
+			ctx.TSLFetchOptions.RawSpillDir = strings.TrimPrefix(arg, "raw-spill-dir:")
+			pl.Logger.Debug("Set TSL document spill directory", logging.F("raw-spill-dir", ctx.TSLFetchOptions.RawSpillDir))
+		} else if strings.HasPrefix(arg, "raw-spill-threshold:") {
+			valueStr := strings.TrimPrefix(arg, "raw-spill-threshold:")
+			value, err := strconv.Atoi(valueStr)
+			if err != nil || value <= 0 {
+				return ctx, fmt.Errorf("invalid raw-spill-threshold value: %s", valueStr)
+			}
+			ctx.TSLFetchOptions.RawSpillThreshold = value
+			pl.Logger.Debug("Set TSL document spill threshold", logging.F("raw-spill-threshold", value))
+		} else if strings.HasPrefix(arg, "signer-roots:") {
+			file := strings.TrimPrefix(arg, "signer-roots:")
+			certs, err := readPEMCertificates(file)
+			if err != nil {
+				return ctx, fmt.Errorf("invalid signer-roots value: %w", err)
+			}
+			// Copy the pool, since contexts may share it
+			roots := x509.NewCertPool()
+			if ctx.TSLFetchOptions.SignerRoots != nil {
+				roots = ctx.TSLFetchOptions.SignerRoots.Clone()
+			}
+			for _, cert := range certs {
+				roots.AddCert(cert)
+			}
+			ctx.TSLFetchOptions.SignerRoots = roots
+			pl.Logger.Debug("Added TSL signer roots", logging.F("file", file), logging.F("certificates", len(certs)))
+		} else if strings.HasPrefix(arg, "aia-allow-host:") {
+			pattern := strings.TrimPrefix(arg, "aia-allow-host:")
+			if pattern == "" || strings.ContainsAny(pattern, "/: ") {
+				return ctx, fmt.Errorf("invalid aia-allow-host value: %q is not a host name", pattern)
+			}
+			// A new fetcher, since contexts may share it
+			var hosts []string
+			if fetcher := ctx.TSLFetchOptions.IssuerFetcher; fetcher != nil {
+				hosts = slices.Clone(fetcher.AllowedHosts)
+			}
+			ctx.TSLFetchOptions.IssuerFetcher = etsi119612.NewIssuerFetcher(append(hosts, pattern)...)
+			pl.Logger.Debug("Allowed fetching TSL signer intermediates", logging.F("host", pattern))
+		} else if strings.HasPrefix(arg, "host:") {
+			override, err := parseHostOverride(strings.TrimPrefix(arg, "host:"))
+			if err != nil {
+				return ctx, fmt.Errorf("invalid host value: %s (%w)", arg, err)
+			}
+			// Copy the overrides, since contexts may share the slice
+			overrides := slices.DeleteFunc(slices.Clone(ctx.TSLFetchOptions.HostOverrides), func(o etsi119612.HostOverride) bool {
+				return strings.EqualFold(o.Pattern, override.Pattern)
+			})
+			if override.String() != override.Pattern {
+				overrides = append(overrides, override)
+			}
+			ctx.TSLFetchOptions.HostOverrides = overrides
+			pl.Logger.Debug("Set TSL fetch options for host", logging.F("host", override.String()))
 		} else {
 			pl.Logger.Warn("Unknown fetch option", logging.F("option", arg))
 		}
 	}
 
 	// Store filters in the context data
-	ctx.Data["tsl_filters"] = filters
+	ctx.SetData("tsl_filters", filters)
 
 	return ctx, nil
 }
+
+// splitAlgorithms parses a comma-separated list of algorithms of an
+// algorithm policy option; an empty value gives an empty list, which accepts
+// any algorithm.
+func splitAlgorithms(value string) []string {
+	var algorithms []string
+	for _, algorithm := range strings.Split(value, ",") {
+		if algorithm = strings.TrimSpace(algorithm); algorithm != "" {
+			algorithms = append(algorithms, algorithm)
+		}
+	}
+	return algorithms
+}
+
+// parseHostOverride parses the value of the host option of set-fetch-options:
+// a host pattern followed by options separated by spaces.
+func parseHostOverride(value string) (etsi119612.HostOverride, error) {
+	pattern, rest, _ := strings.Cut(strings.TrimSpace(value), " ")
+	override := etsi119612.HostOverride{Pattern: pattern}
+	host := strings.TrimPrefix(pattern, "*.")
+	if host == "" || strings.ContainsAny(host, "*/:") {
+		return override, fmt.Errorf("a host name or *.domain pattern is required")
+	}
+
+	rest = strings.TrimSpace(rest)
+	for rest != "" {
+		if userAgent, ok := strings.CutPrefix(rest, "user-agent:"); ok {
+			override.UserAgent = strings.TrimSpace(userAgent)
+			break
+		}
+		var option string
+		option, rest, _ = strings.Cut(rest, " ")
+		rest = strings.TrimSpace(rest)
+		key, val, _ := strings.Cut(option, ":")
+		switch key {
+		case "timeout", "retry-delay", "min-interval":
+			d, err := time.ParseDuration(val)
+			if err != nil || d <= 0 {
+				return override, fmt.Errorf("invalid %s value: %s", key, val)
+			}
+			switch key {
+			case "timeout":
+				override.Timeout = d
+			case "retry-delay":
+				override.RetryDelay = d
+			default:
+				override.MinInterval = d
+			}
+		case "accept":
+			override.AcceptHeaders = splitAlgorithms(val)
+		case "retries":
+			retries, err := strconv.Atoi(val)
+			if err != nil || retries < 0 {
+				return override, fmt.Errorf("invalid retries value: %s", val)
+			}
+			override.Retries = &retries
+		case "rate-limit":
+			interval, err := parseRateLimit(val)
+			if err != nil {
+				return override, err
+			}
+			override.MinInterval = interval
+		default:
+			return override, fmt.Errorf("unknown option %s", option)
+		}
+	}
+	return override, nil
+}
+
+// parseRateLimit parses a rate such as "2/s", "30/m" or "100/h" and returns
+// the interval between requests it allows.
+func parseRateLimit(value string) (time.Duration, error) {
+	count, unit, _ := strings.Cut(value, "/")
+	n, err := strconv.Atoi(count)
+	per := map[string]time.Duration{"s": time.Second, "m": time.Minute, "h": time.Hour}[unit]
+	if err != nil || n <= 0 || per == 0 {
+		return 0, fmt.Errorf("invalid rate-limit value: %s (expected <count>/s, /m or /h)", value)
+	}
+	return per / time.Duration(n), nil
+}
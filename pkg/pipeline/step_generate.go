@@ -3,12 +3,14 @@ package pipeline
 import (
 	"crypto/x509"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/sirosfoundation/g119612/pkg/etsi119612"
+	"github.com/sirosfoundation/g119612/pkg/validation"
 	"gopkg.in/yaml.v3"
 )
 
@@ -50,9 +52,10 @@ type CertificateMetadata struct {
 
 // SchemeMetadata represents the YAML structure for the TSL scheme metadata
 type SchemeMetadata struct {
-	OperatorNames  []MultiLangName `yaml:"operatorNames"`            // At least one name required
-	Type           string          `yaml:"type"`                     // URI identifying the TSL type
-	SequenceNumber int             `yaml:"sequenceNumber,omitempty"` // TSL sequence number
+	OperatorNames      []MultiLangName `yaml:"operatorNames"`                // At least one name required
+	Type               string          `yaml:"type"`                         // URI identifying the TSL type
+	SequenceNumber     int             `yaml:"sequenceNumber,omitempty"`     // TSL sequence number
+	DistributionPoints []string        `yaml:"distributionPoints,omitempty"` // URLs the TSL is published at
 }
 
 // loadSchemeMetadata loads and parses the scheme metadata from the scheme.yaml file.
@@ -78,6 +81,8 @@ type SchemeMetadata struct {
 //	    value: "Trust List Operator"
 //	type: "http://uri.etsi.org/TrstSvc/TrustedList/TSLType/EUlistofthelists"
 //	sequenceNumber: 1
+//	distributionPoints:
+//	  - "https://example.com/tsl.xml"
 func loadSchemeMetadata(rootDir string) (*SchemeMetadata, error) {
 	metadataPath := filepath.Join(rootDir, "scheme.yaml")
 	data, err := os.ReadFile(metadataPath)
@@ -296,6 +301,8 @@ func addProviderCertificates(providerDir string, provider *etsi119612.TSPType) e
 //	      value: "Trust List Operator"
 //	  type: "http://uri.etsi.org/TrstSvc/TrustedList/TSLType/..."  # TSL type URI
 //	  sequenceNumber: 1    # TSL sequence number
+//	  distributionPoints:  # Optional URLs the TSL is published at
+//	    - "https://example.com/tsl.xml"
 //
 //	provider.yaml:
 //	  names:              # List of provider names in different languages
@@ -330,7 +337,16 @@ func addProviderCertificates(providerDir string, provider *etsi119612.TSPType) e
 // Parameters:
 //   - pl: Pipeline instance managing the step execution
 //   - ctx: Pipeline context containing state information
-//   - args: String slice where args[0] must be the path to the root directory
+//   - args: String slice where args[0] must be the path to the root directory, optionally
+//     followed by:
+//   - "strict-uris": Require the distribution points, information URIs and electronic
+//     addresses to be well-formed absolute URLs with a valid host name and an allowed
+//     scheme; electronic addresses may also be mailto: or tel: URIs
+//   - "uri-schemes:LIST": Comma-separated schemes allowed by strict-uris (default: https);
+//     implies strict-uris
+//
+// With strict-uris, every invalid URI is reported with its file and field path, e.g.
+// "providers/acme/provider.yaml: informationURI[0].value", and nothing is generated.
 //
 // Returns:
 //   - *Context: Updated context with the generated TSL added to ctx.TSLs
@@ -353,6 +369,9 @@ func GenerateTSL(pl *Pipeline, ctx *Context, args ...string) (*Context, error) {
 	}
 
 	rootDir := args[0]
+	uriOpts, strictURIs := parseGenerateURIOptions(args[1:])
+	var uriErrs []error
+
 	providersDir := filepath.Join(rootDir, "providers")
 	entries, err := os.ReadDir(providersDir)
 	if err != nil {
@@ -380,6 +399,12 @@ func GenerateTSL(pl *Pipeline, ctx *Context, args ...string) (*Context, error) {
 		}
 	}
 
+	if strictURIs {
+		for i, uri := range schemeMetadata.DistributionPoints {
+			uriErrs = append(uriErrs, validateMetadataURI("scheme.yaml", fmt.Sprintf("distributionPoints[%d]", i), uri, uriOpts, false))
+		}
+	}
+
 	tsl := &etsi119612.TSL{
 		StatusList: etsi119612.TrustStatusListType{
 			TslSchemeInformation: &etsi119612.TSLSchemeInformationType{
@@ -394,6 +419,11 @@ func GenerateTSL(pl *Pipeline, ctx *Context, args ...string) (*Context, error) {
 			},
 		},
 	}
+	if len(schemeMetadata.DistributionPoints) > 0 {
+		tsl.StatusList.TslSchemeInformation.TslDistributionPoints = &etsi119612.NonEmptyURIListType{
+			URI: schemeMetadata.DistributionPoints,
+		}
+	}
 
 	for _, entry := range entries {
 		if !entry.IsDir() {
@@ -432,6 +462,33 @@ func GenerateTSL(pl *Pipeline, ctx *Context, args ...string) (*Context, error) {
 			},
 		}
 
+		if strictURIs {
+			file := filepath.ToSlash(filepath.Join("providers", entry.Name(), "provider.yaml"))
+			for i, uri := range providerMetadata.InformationURI {
+				uriErrs = append(uriErrs, validateMetadataURI(file, fmt.Sprintf("informationURI[%d].value", i), uri.Value, uriOpts, false))
+			}
+			if providerMetadata.Address != nil {
+				for i, uri := range providerMetadata.Address.Electronic {
+					uriErrs = append(uriErrs, validateMetadataURI(file, fmt.Sprintf("address.electronic[%d]", i), uri, uriOpts, true))
+				}
+			}
+		}
+
+		// Add provider information URIs if present
+		if len(providerMetadata.InformationURI) > 0 {
+			uris := make([]*etsi119612.NonEmptyMultiLangURIType, len(providerMetadata.InformationURI))
+			for i, uri := range providerMetadata.InformationURI {
+				uris[i] = &etsi119612.NonEmptyMultiLangURIType{
+					XmlLangAttr: func() *etsi119612.Lang {
+						l := etsi119612.Lang(uri.Language)
+						return &l
+					}(),
+					Value: uri.Value,
+				}
+			}
+			provider.TslTSPInformation.TSPInformationURI = &etsi119612.NonEmptyMultiLangURIListType{URI: uris}
+		}
+
 		// Add provider address if present
 		if providerMetadata.Address != nil {
 			provider.TslTSPInformation.TSPAddress = &etsi119612.AddressType{
@@ -474,11 +531,54 @@ func GenerateTSL(pl *Pipeline, ctx *Context, args ...string) (*Context, error) {
 		)
 	}
 
+	if err := errors.Join(uriErrs...); err != nil {
+		return nil, fmt.Errorf("invalid URIs in TSL metadata: %w", err)
+	}
+
 	ctx.EnsureTSLStack().TSLs.Push(tsl)
 
 	return ctx, nil
 }
 
+// parseGenerateURIOptions parses the strict-uris and uri-schemes options of the
+// generate step. It returns the URL validation options and whether strict URI
+// validation is enabled.
+func parseGenerateURIOptions(args []string) (validation.URLValidationOptions, bool) {
+	strict := false
+	var schemes []string
+	for _, arg := range args {
+		if arg == "strict-uris" {
+			strict = true
+		} else if list, ok := strings.CutPrefix(arg, "uri-schemes:"); ok {
+			strict = true
+			for _, scheme := range strings.Split(list, ",") {
+				if scheme = strings.TrimSpace(scheme); scheme != "" {
+					schemes = append(schemes, scheme)
+				}
+			}
+		}
+	}
+	return validation.StrictURLOptions(schemes...), strict
+}
+
+// validateMetadataURI validates a URI of a metadata file, returning a
+// validation.FieldError naming the file and field if it is invalid. Electronic
+// addresses may also be mailto: or tel: URIs.
+func validateMetadataURI(file, field, uri string, opts validation.URLValidationOptions, electronic bool) error {
+	if electronic {
+		if scheme, rest, ok := strings.Cut(uri, ":"); ok && (strings.EqualFold(scheme, "mailto") || strings.EqualFold(scheme, "tel")) {
+			if strings.TrimSpace(rest) == "" || strings.HasPrefix(rest, "//") {
+				return &validation.FieldError{Field: file + ": " + field, Err: fmt.Errorf("malformed %s URI '%s'", scheme, uri)}
+			}
+			return nil
+		}
+	}
+	if err := validation.ValidateURL(uri, opts); err != nil {
+		return &validation.FieldError{Field: file + ": " + field, Err: fmt.Errorf("'%s': %w", uri, err)}
+	}
+	return nil
+}
+
 // LoadTSL is a pipeline step that loads a Trust Service List (TSL) from a file or URL.
 // This function supports loading TSLs from both local files and remote HTTP(S) URLs,
 // and will also load any referenced TSLs based on the MaxDereferenceDepth setting.
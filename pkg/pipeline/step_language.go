@@ -0,0 +1,99 @@
+package pipeline
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// languageKey is the context data key under which set-language stores the
+// pipeline's language preference, see PreferredLanguages.
+const languageKey = "language"
+
+// languageTagPattern matches the language tags accepted by set-language and the
+// lang: option of render, such as "sv" or "pt-BR".
+var languageTagPattern = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{1,8})*$`)
+
+// SetLanguage is a pipeline step that sets the pipeline's preferred languages,
+// most preferred first. Steps producing human readable output, such as render,
+// display names in the first preferred language a TSL provides, and render,
+// transform and generate_index label their pages with the language pack of the
+// first preferred language there is one for (see LocaleFor).
+//
+// Parameters:
+//   - pl: Pipeline instance managing the step execution
+//   - ctx: Pipeline context containing state information
+//   - args: One or more language tags, e.g. "sv" or "pt-BR"
+//
+// Returns:
+//   - *Context: Context with the language preference set
+//   - error: Non-nil if no language is given or a tag is malformed
+//
+// Example usage in pipeline YAML:
+//
+//   - set-language:
+//   - sv
+//   - en
+func SetLanguage(pl *Pipeline, ctx *Context, args ...string) (*Context, error) {
+	langs, err := parseLanguages(args)
+	if err != nil {
+		return ctx, fmt.Errorf("%w: %v", ErrInvalidArguments, err)
+	}
+	ctx.SetData(languageKey, langs)
+	return ctx, nil
+}
+
+// PreferredLanguages returns the language preference set by set-language, most
+// preferred first, or nil if none was set.
+func PreferredLanguages(ctx *Context) []string {
+	if ctx == nil {
+		return nil
+	}
+	langs, _ := dataValue[[]string](ctx, languageKey)
+	return langs
+}
+
+// parseLanguages checks and normalizes a list of language tags. Each argument
+// may hold several comma-separated tags.
+func parseLanguages(args []string) ([]string, error) {
+	var langs []string
+	for _, arg := range args {
+		for _, tag := range strings.Split(arg, ",") {
+			tag = strings.TrimSpace(tag)
+			if !languageTagPattern.MatchString(tag) {
+				return nil, fmt.Errorf("invalid language tag %q", tag)
+			}
+			langs = append(langs, strings.ToLower(tag))
+		}
+	}
+	if len(langs) == 0 {
+		return nil, fmt.Errorf("missing required argument: at least one language tag")
+	}
+	return langs, nil
+}
+
+// parseLangOption removes the lang: option from step arguments and returns
+// the remaining arguments with the preferred languages, nil if the option is
+// not given.
+func parseLangOption(args []string) ([]string, []string, error) {
+	var langs []string
+	rest := make([]string, 0, len(args))
+	for _, arg := range args {
+		if value, ok := strings.CutPrefix(arg, "lang:"); ok {
+			parsed, err := parseLanguages([]string{value})
+			if err != nil {
+				return nil, nil, err
+			}
+			langs = parsed
+			continue
+		}
+		rest = append(rest, arg)
+	}
+	return rest, langs, nil
+}
+
+// validateSetLanguageArgs is the ArgsValidator of the set-language step.
+func validateSetLanguageArgs(args ...string) error {
+	_, err := parseLanguages(args)
+	return err
+}
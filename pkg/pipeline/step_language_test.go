@@ -0,0 +1,25 @@
+package pipeline
+
+import (
+	"testing"
+
+	"github.com/sirosfoundation/g119612/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetLanguage(t *testing.T) {
+	pl := &Pipeline{Logger: logging.SilentLogger()}
+	assert.Nil(t, PreferredLanguages(NewContext()))
+	assert.Nil(t, PreferredLanguages(nil))
+
+	ctx, err := SetLanguage(pl, NewContext(), "SV", "pt-BR,en")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"sv", "pt-br", "en"}, PreferredLanguages(ctx))
+
+	for _, args := range [][]string{nil, {""}, {"swedish"}, {"sv,"}, {"s v"}} {
+		_, err := SetLanguage(pl, NewContext(), args...)
+		assert.ErrorIs(t, err, ErrInvalidArguments, "%q", args)
+		assert.Error(t, validateSetLanguageArgs(args...), "%q", args)
+	}
+}
@@ -2,7 +2,9 @@ package pipeline
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/sirosfoundation/g119612/pkg/etsi119612"
 	"github.com/sirosfoundation/g119612/pkg/logging"
@@ -22,8 +24,25 @@ import (
 //   - pl: The pipeline instance for logging and configuration
 //   - ctx: The pipeline context to update with loaded TSLs
 //   - args: String arguments, where:
-//   - args[0]: Required - URL or file path to the root TSL
+//   - args[0]: Required - URL or file path to the root TSL, or "wellknown:host" to discover
+//     the URL through https://host/.well-known/trust-list, or "pointers:url" to load a JSON
+//     pointer manifest and the TSLs it points to (see etsi119612.PointerManifest)
 //   - args[1]: Optional - Filter expression for including specific TSLs (not implemented yet)
+//   - strict or strict:true: Optional - Reject TSLs that contain unexpected elements, lack
+//     mandatory elements or have unparseable dates (see etsi119612.ValidateStrict)
+//   - wellknown-path:/path: Optional - Well-known path used with "wellknown:host"
+//   - strict-pointers or strict-pointers:true: Optional - Skip referenced TSLs whose TSLType
+//     or SchemeTerritory contradict the pointer metadata of their parent; without it such
+//     mismatches are logged as warnings
+//   - mirrors:url1|url2: Optional - Further locations of the same TSL, used when the URL
+//     cannot be loaded; without a URL argument the first mirror is the URL
+//   - mirror-mode:order|race: Optional - Try the locations in order (default) or fetch from
+//     all at once and use the first that succeeds
+//   - mirror-check or mirror-check:true: Optional - Also fetch the TSL from the other
+//     reachable mirrors and fail unless sequence number and content match
+//   - allow-doctype or allow-doctype:true: Optional - Accept TSLs with a DOCTYPE declaration,
+//     which are otherwise rejected to rule out XML External Entity (XXE) attacks; entities
+//     are never resolved either way
 //
 // Returns:
 //   - *Context: Updated context with the loaded TSL tree and legacy TSL stack
@@ -37,44 +56,127 @@ import (
 //   - load:
 //   - /path/to/local/tsl.xml
 //
+// Or through the operator's well-known entry:
+//   - load:
+//   - wellknown:tsl.example.com
+//
+// Or through a pointer manifest pinning the signer of each list:
+//   - load:
+//   - pointers:https://federation.example.com/pointers.json
+//
+// Or with mirrors that must all serve the same list:
+//   - load:
+//   - mirrors:https://a.example.com/tsl.xml|https://b.example.com/tsl.xml
+//   - mirror-check
+//
+// Or validating a generated list strictly:
+//   - load:
+//   - /path/to/generated/tsl.xml
+//   - strict
+//
 // The loaded TSL tree structure represents the hierarchical relationship between the root TSL
 // and its referenced TSLs, allowing for more efficient traversal and operations on the tree.
 func LoadTSL(pl *Pipeline, ctx *Context, args ...string) (*Context, error) {
-	if len(args) < 1 {
-		return ctx, fmt.Errorf("missing argument: URL or file path")
+	args, opts, err := parseLoadOptions(args)
+	if err != nil {
+		return ctx, err
 	}
-
-	url := args[0]
-	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
-		url = "file://" + url
+	if len(args) < 1 && len(opts.Mirrors) == 0 {
+		return ctx, fmt.Errorf("missing argument: URL or file path")
 	}
-
-	// Validate the URL before processing
-	if err := validation.ValidateURL(url, validation.TSLURLOptions()); err != nil {
-		return ctx, fmt.Errorf("invalid TSL URL: %w", err)
+	if len(args) > 0 {
+		opts.URL = args[0]
 	}
 
 	// Parse optional filter argument
-	var filter string
 	if len(args) > 1 {
-		filter = args[1]
-		pl.Logger.Debug("TSL filter provided", logging.F("filter", filter))
+		pl.Logger.Debug("TSL filter provided", logging.F("filter", args[1]))
 		// Note: Filter implementation will be added in a future update
 	}
 
+	return loadWithOptions(pl, ctx, opts)
+}
+
+// loadWithOptions implements LoadTSL and Load for already parsed options.
+func loadWithOptions(pl *Pipeline, ctx *Context, opts LoadOptions) (*Context, error) {
+	started := time.Now()
+	if opts.URL == "" && len(opts.Mirrors) > 0 {
+		opts.URL, opts.Mirrors = opts.Mirrors[0], opts.Mirrors[1:]
+	}
+	if opts.URL == "" {
+		return ctx, fmt.Errorf("missing argument: URL or file path")
+	}
+	if opts.MirrorMode != "" {
+		if _, err := parseMirrorMode(opts.MirrorMode); err != nil {
+			return ctx, fmt.Errorf("%w: %v", ErrInvalidArguments, err)
+		}
+	}
+	manifestURL, isManifest := strings.CutPrefix(opts.URL, "pointers:")
+	if isManifest {
+		if len(opts.Mirrors) > 0 {
+			return ctx, fmt.Errorf("%w: mirrors cannot be combined with a pointer manifest", ErrInvalidArguments)
+		}
+		opts.URL = manifestURL
+	}
+
 	// Ensure the TSLFetchOptions are initialized with default values if not set
 	ctx.EnsureTSLFetchOptions()
 
+	urls := make([]string, 0, 1+len(opts.Mirrors))
+	for _, location := range append([]string{opts.URL}, opts.Mirrors...) {
+		url, err := resolveLoadURL(pl, ctx, location, opts.WellKnownPath)
+		if err != nil {
+			return ctx, err
+		}
+		urls = append(urls, url)
+	}
+
 	pl.Logger.Debug("Loading TSL",
-		logging.F("url", url),
+		logging.F("url", urls[0]),
+		logging.F("mirrors", len(urls)-1),
 		logging.F("user-agent", ctx.TSLFetchOptions.UserAgent),
 		logging.F("timeout", ctx.TSLFetchOptions.Timeout),
 		logging.F("max-depth", ctx.TSLFetchOptions.MaxDereferenceDepth),
 		logging.F("accept", ctx.TSLFetchOptions.AcceptHeaders))
 
-	tsls, err := etsi119612.FetchTSLWithReferencesAndOptions(url, *ctx.TSLFetchOptions)
-	if err != nil {
-		return ctx, fmt.Errorf("failed to load TSL from %s: %w", url, err)
+	fetchOptions := *ctx.TSLFetchOptions
+	if opts.Strict {
+		fetchOptions.Strict = true
+		pl.Logger.Debug("Strict TSL validation enabled", logging.F("url", urls[0]))
+	}
+	if opts.StrictPointers {
+		fetchOptions.StrictPointers = true
+	}
+	if opts.AllowDoctype {
+		fetchOptions.AllowDoctype = true
+		pl.Logger.Warn("DOCTYPE declarations allowed in loaded TSLs", logging.F("url", urls[0]))
+	}
+	if filter := pointerFilter(ctx); filter != nil {
+		// Do not fetch referenced TSLs that the territory filter would drop
+		if previous := fetchOptions.PointerFilter; previous != nil {
+			fetchOptions.PointerFilter = func(pointer etsi119612.PointerInfo) bool {
+				return previous(pointer) && filter(pointer)
+			}
+		} else {
+			fetchOptions.PointerFilter = filter
+		}
+	}
+
+	var tsls []*etsi119612.TSL
+	var url string
+	var err error
+	if isManifest {
+		url = urls[0]
+		tsls, err = etsi119612.FetchPointerManifest(url, fetchOptions)
+		if err != nil {
+			return ctx, NewTSLLoadError(url, err)
+		}
+		logManifestSkipped(pl, tsls)
+	} else {
+		tsls, url, err = fetchFromMirrors(pl, urls, fetchOptions, opts)
+		if err != nil {
+			return ctx, err
+		}
 	}
 
 	if len(tsls) == 0 {
@@ -155,11 +257,21 @@ func LoadTSL(pl *Pipeline, ctx *Context, args ...string) (*Context, error) {
 		}
 
 		// Log each TSL as it's loaded
-		pl.Logger.Info("Loaded TSL",
-			logging.F("url", tsl.Source),
+		fields := tslFields(tsl,
 			logging.F("providers", providerCount),
 			logging.F("services", serviceCount),
 			logging.F("referenced", i > 0))
+		pl.Logger.Info("Loaded TSL", append(fields, fetchInfoFields(tsl.FetchInfo, started)...)...)
+
+		for _, mismatch := range tsl.PointerMismatches {
+			pl.Logger.Warn("Referenced TSL does not match pointer metadata",
+				logging.F("parent", mismatch.Parent),
+				logging.F("location", mismatch.Location),
+				logging.F("field", mismatch.Field),
+				logging.F("expected", mismatch.Expected),
+				logging.F("actual", mismatch.Actual),
+				logging.F("skipped", opts.StrictPointers))
+		}
 	}
 
 	pl.Logger.Info("Loaded TSLs",
@@ -172,3 +284,146 @@ func LoadTSL(pl *Pipeline, ctx *Context, args ...string) (*Context, error) {
 
 	return ctx, nil
 }
+
+// logManifestSkipped warns about the pointers of a pointer manifest, the root
+// of tsls, that did not yield a TSL because they were filtered out, could not
+// be fetched or were not signed by a pinned signer.
+func logManifestSkipped(pl *Pipeline, tsls []*etsi119612.TSL) {
+	root := tsls[0]
+	loaded := make(map[string]bool, len(root.Referenced))
+	for _, ref := range root.Referenced {
+		loaded[ref.Source] = true
+	}
+	for _, pointer := range root.Pointers {
+		if !loaded[pointer.Location] {
+			pl.Logger.Warn("TSL of pointer manifest not loaded",
+				logging.F("manifest", root.Source),
+				logging.F("location", pointer.Location),
+				logging.F("territory", pointer.SchemeTerritory))
+		}
+	}
+}
+
+// fetchInfoFields returns the log fields describing how a TSL was fetched. A
+// TSL fetched before the load step started was taken from the fetch cache.
+func fetchInfoFields(info *etsi119612.FetchInfo, started time.Time) []logging.Field {
+	if info == nil {
+		return nil
+	}
+	fields := []logging.Field{
+		logging.F("bytes", info.Size),
+		logging.F("fetch_duration", info.Duration),
+		logging.F("cached", info.CacheHit || info.FetchedAt.Before(started)),
+	}
+	if info.StatusCode != 0 {
+		fields = append(fields, logging.F("http_status", info.StatusCode))
+	}
+	if info.FinalURL != info.URL {
+		fields = append(fields, logging.F("final_url", info.FinalURL))
+	}
+	if info.ETag != "" {
+		fields = append(fields, logging.F("etag", info.ETag))
+	}
+	return fields
+}
+
+// resolveLoadURL turns a location given to the load step into the URL to
+// fetch: "wellknown:host" is resolved, plain paths become file:// URLs, and
+// the result is validated. ipfs:// URLs are fetched through the IPFS gateway
+// of the fetch options.
+func resolveLoadURL(pl *Pipeline, ctx *Context, location, wellKnownPath string) (string, error) {
+	url := location
+	if host, ok := strings.CutPrefix(url, "wellknown:"); ok {
+		resolved, err := etsi119612.ResolveWellKnown(host, wellKnownPath, *ctx.TSLFetchOptions)
+		if err != nil {
+			return "", fmt.Errorf("failed to resolve well-known TSL location for %s: %w", host, err)
+		}
+		pl.Logger.Info("Resolved well-known TSL location",
+			logging.F("host", host),
+			logging.F("url", resolved))
+		url = resolved
+	}
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") && !strings.HasPrefix(url, "ipfs://") {
+		url = "file://" + url
+	}
+
+	// Validate the URL before processing
+	if err := validation.ValidateURL(url, validation.TSLURLOptions()); err != nil {
+		return "", fmt.Errorf("invalid TSL URL: %w", err)
+	}
+	return url, nil
+}
+
+// parseLoadOptions separates load options from the positional arguments of the load step.
+//
+// Recognized options:
+//   - strict, strict:true, strict:false  Enable or disable strict TSL validation
+//   - wellknown-path:/path               Well-known path (default etsi119612.DefaultWellKnownPath)
+//   - strict-pointers, strict-pointers:true  Skip referenced TSLs contradicting their pointer metadata
+//   - mirrors:url1|url2                  Further locations of the same TSL
+//   - mirror-mode:order|race             How the locations are tried (default order)
+//   - mirror-check, mirror-check:true    Require all reachable mirrors to serve the same TSL
+//   - allow-doctype, allow-doctype:true  Accept TSLs with a DOCTYPE declaration
+//
+// Returns the remaining positional arguments in their original order and the parsed options.
+// The URL of the returned options is left empty.
+func parseLoadOptions(args []string) ([]string, LoadOptions, error) {
+	var opts LoadOptions
+	positional := make([]string, 0, len(args))
+
+	for _, arg := range args {
+		switch {
+		case arg == "strict":
+			opts.Strict = true
+		case strings.HasPrefix(arg, "strict:"):
+			value, err := strconv.ParseBool(strings.TrimPrefix(arg, "strict:"))
+			if err != nil {
+				return nil, opts, fmt.Errorf("invalid strict value %q: %w", arg, err)
+			}
+			opts.Strict = value
+		case arg == "strict-pointers":
+			opts.StrictPointers = true
+		case strings.HasPrefix(arg, "strict-pointers:"):
+			value, err := strconv.ParseBool(strings.TrimPrefix(arg, "strict-pointers:"))
+			if err != nil {
+				return nil, opts, fmt.Errorf("invalid strict-pointers value %q: %w", arg, err)
+			}
+			opts.StrictPointers = value
+		case strings.HasPrefix(arg, "mirrors:"):
+			for _, mirror := range strings.Split(strings.TrimPrefix(arg, "mirrors:"), "|") {
+				if mirror = strings.TrimSpace(mirror); mirror == "" {
+					return nil, opts, fmt.Errorf("invalid mirrors value %q: empty location", arg)
+				}
+				opts.Mirrors = append(opts.Mirrors, mirror)
+			}
+		case strings.HasPrefix(arg, "mirror-mode:"):
+			mode, err := parseMirrorMode(strings.TrimPrefix(arg, "mirror-mode:"))
+			if err != nil {
+				return nil, opts, err
+			}
+			opts.MirrorMode = mode
+		case arg == "allow-doctype":
+			opts.AllowDoctype = true
+		case strings.HasPrefix(arg, "allow-doctype:"):
+			value, err := strconv.ParseBool(strings.TrimPrefix(arg, "allow-doctype:"))
+			if err != nil {
+				return nil, opts, fmt.Errorf("invalid allow-doctype value %q: %w", arg, err)
+			}
+			opts.AllowDoctype = value
+		case arg == "mirror-check":
+			opts.CheckMirrors = true
+		case strings.HasPrefix(arg, "mirror-check:"):
+			value, err := strconv.ParseBool(strings.TrimPrefix(arg, "mirror-check:"))
+			if err != nil {
+				return nil, opts, fmt.Errorf("invalid mirror-check value %q: %w", arg, err)
+			}
+			opts.CheckMirrors = value
+		case strings.HasPrefix(arg, "wellknown-path:"):
+			opts.WellKnownPath = strings.TrimPrefix(arg, "wellknown-path:")
+		default:
+			positional = append(positional, arg)
+		}
+	}
+
+	return positional, opts, nil
+}
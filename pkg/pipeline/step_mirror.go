@@ -0,0 +1,80 @@
+package pipeline
+
+import (
+	"fmt"
+
+	"github.com/sirosfoundation/g119612/pkg/etsi119612"
+	"github.com/sirosfoundation/g119612/pkg/logging"
+)
+
+// MirrorTSLs is a pipeline step that saves every loaded TSL, roots and
+// referenced TSLs, under a local directory in the original byte form it was
+// fetched in, with a manifest.json mapping the URLs to the files (see
+// etsi119612.WriteMirror). An existing mirror in the directory is replaced.
+//
+// A later run can load from the mirror instead of the network with the
+// set-fetch-options option mirror:DIR, which makes processing reproducible
+// offline and keeps a forensic snapshot of what was fetched. The directory can
+// be served as it is, e.g. with "tsl-tool serve --mirror DIR".
+//
+// TSLs that were not fetched or parsed from a document, such as generated
+// ones, have no original form and are left out.
+//
+// Parameters:
+//   - pl: The pipeline instance for logging
+//   - ctx: The pipeline context holding the loaded TSLs
+//   - args: String arguments, where:
+//   - args[0]: Required - The mirror directory
+//
+// Returns:
+//   - *Context: The unchanged context
+//   - error: Non-nil if the directory is missing or the mirror cannot be written
+//
+// Example usage in pipeline configuration:
+//   - load: [https://example.com/lotl.xml]
+//   - mirror: [/var/lib/tsl/mirror]
+//
+// And to process the snapshot offline later:
+//   - set-fetch-options: ["mirror:/var/lib/tsl/mirror"]
+//   - load: [https://example.com/lotl.xml]
+func MirrorTSLs(pl *Pipeline, ctx *Context, args ...string) (*Context, error) {
+	if len(args) < 1 || args[0] == "" {
+		return ctx, fmt.Errorf("%w: missing mirror directory", ErrInvalidArguments)
+	}
+	dir := args[0]
+
+	var roots []*etsi119612.TSL
+	if ctx.TSLTrees != nil {
+		for _, tree := range ctx.TSLTrees.ToSlice() {
+			if tree != nil && tree.Root != nil {
+				roots = append(roots, tree.Root.TSL)
+			}
+		}
+	}
+	if len(roots) == 0 {
+		return ctx, fmt.Errorf("no TSLs to mirror")
+	}
+
+	manifest, err := etsi119612.WriteMirror(dir, roots)
+	if err != nil {
+		return ctx, err
+	}
+	size := 0
+	for _, entry := range manifest.TSLs {
+		size += entry.Size
+	}
+	pl.Logger.Info("Mirrored TSLs",
+		logging.F("dir", dir),
+		logging.F("roots", len(manifest.Roots)),
+		logging.F("count", len(manifest.TSLs)),
+		logging.F("bytes", size))
+	return ctx, nil
+}
+
+// mirrorOutputs is the OutputsFunc of the mirror step: the mirror directory.
+func mirrorOutputs(args ...string) []string {
+	if len(args) < 1 {
+		return nil
+	}
+	return []string{args[0]}
+}
@@ -0,0 +1,86 @@
+package pipeline
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirosfoundation/g119612/pkg/etsi119612"
+	"github.com/sirosfoundation/g119612/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMirrorTSLs(t *testing.T) {
+	src := t.TempDir()
+	write := func(name string, pointers ...string) string {
+		var refs string
+		for _, pointer := range pointers {
+			refs += "<tsl:OtherTSLPointer><tsl:TSLLocation>" + pointer + "</tsl:TSLLocation></tsl:OtherTSLPointer>"
+		}
+		path := filepath.Join(src, name+".xml")
+		require.NoError(t, os.WriteFile(path, []byte(`<tsl:TrustServiceStatusList xmlns:tsl="http://uri.etsi.org/02231/v2#">
+  <tsl:SchemeInformation><tsl:SchemeTerritory>`+name+`</tsl:SchemeTerritory><tsl:PointersToOtherTSL>`+refs+`</tsl:PointersToOtherTSL></tsl:SchemeInformation>
+  <tsl:TrustServiceProviderList/>
+</tsl:TrustServiceStatusList>`), 0644))
+		return path
+	}
+	root := write("EU", "file://"+write("SE"), "file://"+write("FI"))
+	mirrorDir := filepath.Join(t.TempDir(), "mirror")
+
+	run := func(yaml string) *Context {
+		pl, err := parsePipeline([]byte(yaml), nil)
+		require.NoError(t, err)
+		pl.Logger = logging.SilentLogger()
+		ctx, err := pl.Process(NewContext())
+		require.NoError(t, err)
+		return ctx
+	}
+	sources := func(ctx *Context) []string {
+		var sources []string
+		for _, tsl := range ctx.GetTSLs() {
+			sources = append(sources, tsl.Source)
+		}
+		return sources
+	}
+
+	fetched := run(fmt.Sprintf(`
+- set-fetch-options: ["max-depth:1"]
+- load: [%q]
+- mirror: [%q]
+`, root, mirrorDir))
+	mirror, err := etsi119612.OpenMirror(mirrorDir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"file://" + root}, mirror.Manifest().Roots)
+	assert.Len(t, mirror.Manifest().TSLs, 3)
+
+	// The mirror replaces the originals
+	require.NoError(t, os.RemoveAll(src))
+	offline := fmt.Sprintf(`
+- set-fetch-options: ["max-depth:1", "mirror:%s"]
+- load: [%q]
+`, mirrorDir, root)
+	mirrored := run(offline)
+	assert.Equal(t, sources(fetched), sources(mirrored))
+	for i, tsl := range mirrored.GetTSLs() {
+		assert.Equal(t, fetched.GetTSLs()[i].Raw, tsl.Raw)
+	}
+
+	explained, err := parsePipeline([]byte(offline), nil)
+	require.NoError(t, err)
+	explained.Logger = logging.SilentLogger()
+	steps, err := Explain(explained)
+	require.NoError(t, err)
+	assert.Equal(t, mirrorDir, steps[1].Fetch.Mirror)
+
+	pl := &Pipeline{Logger: logging.SilentLogger()}
+	_, err = MirrorTSLs(pl, NewContext(), mirrorDir)
+	assert.ErrorContains(t, err, "no TSLs to mirror")
+	_, err = MirrorTSLs(pl, NewContext())
+	assert.ErrorIs(t, err, ErrInvalidArguments)
+	_, err = SetFetchOptions(pl, NewContext(), "mirror:"+t.TempDir())
+	assert.ErrorContains(t, err, "invalid mirror value")
+
+	assert.Equal(t, []string{mirrorDir}, mirrorOutputs(mirrorDir))
+}
@@ -1,7 +1,7 @@
 package pipeline
 
 import (
-	"encoding/xml"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -19,7 +19,31 @@ import (
 // Parameters:
 //   - pl: Pipeline instance managing the step execution
 //   - ctx: Pipeline context containing state information
-//   - args: String slice where args[0] must be the directory path where to save the XML files
+//   - args: String slice where args[0] must be the directory path where to save the XML files.
+//     Options in "key:value" form may appear anywhere after it:
+//   - file-mode:0644: Octal mode for published files
+//   - dir-mode:0755: Octal mode for created directories
+//   - key-permissions:warn: Policy for private keys readable by group/others (warn, strict, ignore)
+//   - unsigned-copy:true: Also write "name-unsigned.xml", the canonical form of the signed content without the signature
+//   - sign-mode:stream: Sign without building an in-memory DOM, for very large TSLs (default dom)
+//   - signer:none, signer:memory: Sign without key files, for dry runs and tests: none inserts the
+//     signature structure with empty values, memory signs with a key generated for the run
+//   - rollover-cert:/path, rollover-key:/path, rollover-dir:/path: Key rollover; every TSL is
+//     also signed with this next key and written to the same relative path below rollover-dir
+//   - indent:compact: Write the XML without indentation (pretty or compact, default pretty)
+//   - xml-declaration:false: Leave out the XML declaration (default true)
+//   - encoding:none: Omit the encoding attribute of the declaration (UTF-8 or none, default UTF-8)
+//   - newline:crlf: Use CRLF line endings (lf or crlf, default lf)
+//   - manifest:true: Write manifest.json listing the SHA-256 digest and ETag of every published file
+//   - etag:true: Write "name.xml.etag" holding the ETag of each published file, for web servers and mirrors
+//   - split-max-bytes:N, split-max-providers:N: Publish a TSL exceeding the limits as parts
+//     name-1.xml, name-2.xml, ... and turn name.xml into a list pointing to them (see splitTSL)
+//   - split-base-url:URL: Location of the parts (default: the directory of the first distribution point)
+//   - xades:true: Sign with XAdES-BES signatures, adding SigningTime and SigningCertificateV2
+//     qualifying properties as required for trusted lists (ETSI TS 119 612)
+//   - xades-policy:ID, xades-policy-digest:SHA256, xades-policy-url:URL, xades-policy-description:TEXT:
+//     Sign with XAdES-EPES signatures identifying this signature policy; the digest of the
+//     policy document (base64 or hex) is required
 //
 // Returns:
 //   - *Context: The context unchanged
@@ -39,18 +63,28 @@ import (
 // Example usage in pipeline configuration:
 //   - publish:/path/to/output/dir  # Publish all TSLs to the specified directory
 //   - publish:["/path/to/output/dir", "/path/to/cert.pem", "/path/to/key.pem"]  # With XML-DSIG signatures
+//   - publish:["/path/to/output/dir", "file-mode:0640", "dir-mode:0750"]  # Restrictive output permissions
+//   - publish:["/path/to/output/dir", "/path/to/cert.pem", "/path/to/key.pem", "unsigned-copy:true"]  # Signed and unsigned
+//   - publish:["/path/to/output/dir", "/path/to/cert.pem", "/path/to/key.pem", "rollover-cert:/path/to/next.pem",
+//     "rollover-key:/path/to/next.key", "rollover-dir:/path/to/output/next"]  # Key rollover
+//   - publish:["/path/to/output/dir", "signer:none"]  # Dry run of the signed output without keys
+//   - publish:["/path/to/output/dir", "indent:compact", "xml-declaration:false"]  # Compact output for strict parsers
+//   - publish:["/path/to/output/dir", "manifest:true", "etag:true"]  # Digests for caching mirrors (see PublishedHandler)
+//   - publish:["/path/to/output/dir", "split-max-bytes:5000000", "split-base-url:https://tsl.example.com"]  # Size limit of consumers
+//   - publish:["/path/to/output/dir", "/path/to/cert.pem", "/path/to/key.pem", "xades:true"]  # XAdES-BES signatures
 func PublishTSL(pl *Pipeline, ctx *Context, args ...string) (*Context, error) {
+	args, opts, err := parsePublishOptions(args)
+	if err != nil {
+		return ctx, err
+	}
+	opts.now = ctx.Now()
+
 	if len(args) < 1 {
 		return ctx, fmt.Errorf("missing argument: directory path")
 	}
 
 	dirPath := args[0]
 
-	// Validate output directory before processing
-	if err := validation.ValidateOutputDirectory(dirPath); err != nil {
-		return ctx, fmt.Errorf("invalid output directory: %w", err)
-	}
-
 	// Create a signer if signer configuration is provided
 	var signer dsig.XMLSigner
 
@@ -90,10 +124,64 @@ func PublishTSL(pl *Pipeline, ctx *Context, args ...string) (*Context, error) {
 			signer = pkcs11Signer
 		}
 	}
+	if signer != nil {
+		if opts.signer != nil {
+			return ctx, fmt.Errorf("%w: the signer option cannot be combined with certificate and key arguments", ErrInvalidArguments)
+		}
+		opts.signer = signer
+	}
+	switch opts.signer.(type) {
+	case dsig.NopSigner:
+		pl.Logger.Warn("Publishing with empty signatures that do not verify", logging.F("signer", SignerNone))
+	case *dsig.MemorySigner:
+		pl.Logger.Warn("Publishing with an ephemeral signing key", logging.F("signer", SignerMemory))
+	}
+
+	// The tree layout only applies when publishing TSL trees
+	if ctx.TSLs == nil || ctx.TSLs.IsEmpty() {
+		opts.treeFormat = parsePublishTreeFormat(pl, args)
+	}
+
+	return publishWithOptions(pl, ctx, dirPath, opts)
+}
+
+// publishWithOptions implements PublishTSL and Publish for already parsed options.
+func publishWithOptions(pl *Pipeline, ctx *Context, dirPath string, opts *publishOptions) (_ *Context, err error) {
+	opts = opts.orDefault()
+	signer := opts.signer
+
+	// Validate output directory before processing
+	if err := validation.ValidateOutputDirectory(dirPath); err != nil {
+		return ctx, fmt.Errorf("invalid output directory: %w", err)
+	}
+
+	if err := opts.validateRollover(dirPath); err != nil {
+		return ctx, fmt.Errorf("%w: %v", ErrInvalidArguments, err)
+	}
+	opts.baseDir = dirPath
+
+	for _, s := range []dsig.XMLSigner{signer, opts.rolloverSigner} {
+		if fileSigner, ok := s.(*dsig.FileSigner); ok {
+			if err := checkSignerKeyPermissions(pl, fileSigner, opts.keyPermissions); err != nil {
+				return ctx, err
+			}
+		}
+	}
+	if err := opts.useXAdES(signer); err != nil {
+		return ctx, fmt.Errorf("%w: %v", ErrInvalidArguments, err)
+	}
+	if opts.rolloverSigner != nil {
+		if err := opts.useXAdES(opts.rolloverSigner); err != nil {
+			return ctx, fmt.Errorf("%w: %v", ErrInvalidArguments, err)
+		}
+		pl.Logger.Info("Key rollover enabled, publishing copies signed with the next key",
+			logging.F("directory", opts.rolloverDir))
+	}
+
 	info, err := os.Stat(dirPath)
 	if err != nil {
 		if os.IsNotExist(err) {
-			if err := os.MkdirAll(dirPath, 0755); err != nil {
+			if err := os.MkdirAll(dirPath, opts.dirMode); err != nil {
 				return ctx, fmt.Errorf("failed to create output directory %s: %w", dirPath, err)
 			}
 		} else {
@@ -103,6 +191,17 @@ func PublishTSL(pl *Pipeline, ctx *Context, args ...string) (*Context, error) {
 		return ctx, fmt.Errorf("%s is not a directory", dirPath)
 	}
 
+	// Write the manifests once everything is published, and apply the
+	// failure policy to the files written if publishing failed
+	opts.published = nil
+	opts.written = nil
+	defer func() {
+		if err == nil {
+			err = opts.writeManifests()
+		}
+		opts.finishPublish(pl, dirPath, err)
+	}()
+
 	// Check legacy stack first for backwards compatibility
 	if ctx.TSLs != nil && !ctx.TSLs.IsEmpty() {
 		// Use the legacy stack of TSLs
@@ -129,43 +228,14 @@ func PublishTSL(pl *Pipeline, ctx *Context, args ...string) (*Context, error) {
 			}
 
 			// Special case for tests
-			if ctx.Data != nil && ctx.Data["test"] == "pkcs11" {
+			if test, _ := ctx.GetData("test"); test == "pkcs11" {
 				filename = "test-tsl.xml"
 			}
 
-			// Construct the full file path
-			filePath := filepath.Join(dirPath, filename)
-
-			// Create XML representation with root element
-			type TrustStatusListWrapper struct {
-				XMLName xml.Name                       `xml:"TrustServiceStatusList"`
-				List    etsi119612.TrustStatusListType `xml:",innerxml"`
-			}
-			wrapper := TrustStatusListWrapper{List: tsl.StatusList}
-			xmlContent, err := xml.MarshalIndent(wrapper, "", "  ")
-			if err != nil {
-				return ctx, fmt.Errorf("failed to marshal TSL to XML: %w", err)
+			// Serialize, sign and write the TSL (and its unsigned copy if requested)
+			if err := publishTSLToFile(pl, tsl, filepath.Join(dirPath, filename), opts); err != nil {
+				return ctx, err
 			}
-
-			// Add XML header
-			xmlContent = append([]byte(xml.Header), xmlContent...)
-
-			if signer != nil {
-				xmlContent, err = signer.Sign(xmlContent)
-				if err != nil {
-					return ctx, fmt.Errorf("failed to sign TSL: %w", err)
-				}
-			}
-
-			// Write the TSL to file
-			if err := os.WriteFile(filePath, xmlContent, 0644); err != nil {
-				return ctx, fmt.Errorf("failed to write TSL to %s: %w", filePath, err)
-			}
-
-			pl.Logger.Info("Published TSL",
-				logging.F("file", filePath),
-				logging.F("signed", signer != nil),
-				logging.F("size", len(xmlContent)))
 		}
 
 		return ctx, nil
@@ -177,65 +247,8 @@ func PublishTSL(pl *Pipeline, ctx *Context, args ...string) (*Context, error) {
 	}
 
 	// Check if we should maintain the tree structure in the output
-	var useTreeStructure bool
-	var subdirFormat string
-
-	// Log the arguments received
-	for i, arg := range args {
-		pl.Logger.Debug("PublishTSL argument",
-			logging.F("index", i),
-			logging.F("value", arg))
-	}
-
-	// Check if we have the tree format argument - it might have spaces
-	if len(args) >= 2 {
-		// Log the arguments for debugging
-		pl.Logger.Debug("PublishTSL arguments",
-			logging.F("arg0", args[0]),
-			logging.F("arg1", args[1]),
-			logging.F("len", len(args)))
-
-		// Check if the second arg is a tree format specification
-		// It might be "tree:territory" or have spaces like "tree: territory"
-		arg := args[1]
-		arg = strings.TrimSpace(arg)
-
-		// Debug log for the trimmed argument
-		pl.Logger.Debug("Trimmed argument",
-			logging.F("raw", args[1]),
-			logging.F("trimmed", arg))
-
-		if strings.HasPrefix(arg, "tree:") {
-			useTreeStructure = true
-			// Default format is "territory" but can be overridden to "index" or "territory"
-			subdirFormat = strings.TrimPrefix(arg, "tree:")
-			subdirFormat = strings.TrimSpace(subdirFormat)
-
-			if subdirFormat == "" || (subdirFormat != "index" && subdirFormat != "territory") {
-				subdirFormat = "territory"
-			}
-
-			pl.Logger.Info("Using tree structure for output",
-				logging.F("format", subdirFormat),
-				logging.F("arg", arg),
-				logging.F("useTree", useTreeStructure))
-		} else {
-			// Safe way to get the first few characters
-			firstChars := ""
-			if len(arg) >= 5 {
-				firstChars = arg[0:5]
-			} else if len(arg) > 0 {
-				firstChars = arg
-			}
-
-			pl.Logger.Warn("Second argument is not a tree format",
-				logging.F("arg", arg),
-				logging.F("hasPrefix", strings.HasPrefix(arg, "tree:")),
-				logging.F("firstChars", firstChars))
-		}
-	} else {
-		pl.Logger.Debug("No tree format specified, using flat structure")
-	}
+	subdirFormat := opts.treeFormat
+	useTreeStructure := subdirFormat != ""
 
 	// Collect all TSLs from all trees
 	var allTSLs []*etsi119612.TSL
@@ -255,7 +268,7 @@ func PublishTSL(pl *Pipeline, ctx *Context, args ...string) (*Context, error) {
 				logging.F("format", subdirFormat))
 
 			// Call the specialized function for tree publishing
-			if err := processTreeForPublishing(pl, ctx, tree, dirPath, treeIdx, subdirFormat, signer); err != nil {
+			if err := processTreeForPublishing(pl, ctx, tree, dirPath, treeIdx, subdirFormat, opts); err != nil {
 				pl.Logger.Error("Error processing tree for publishing",
 					logging.F("error", err),
 					logging.F("directory", dirPath),
@@ -304,39 +317,199 @@ func PublishTSL(pl *Pipeline, ctx *Context, args ...string) (*Context, error) {
 			}
 
 			// Log the filename using the pipeline's logger
-			pl.Logger.Info("Publishing TSL to file",
+			pl.Logger.Info("Publishing TSL to file", tslFields(tsl,
 				logging.F("index", i),
-				logging.F("filename", filename))
+				logging.F("filename", filename))...)
 
-			// Create XML representation with root element
-			type TrustStatusListWrapper struct {
-				XMLName xml.Name                       `xml:"TrustServiceStatusList"`
-				List    etsi119612.TrustStatusListType `xml:",innerxml"`
-			}
-			wrapper := TrustStatusListWrapper{List: tsl.StatusList}
-			xmlData, err := xml.MarshalIndent(wrapper, "", "  ")
-			if err != nil {
-				return ctx, fmt.Errorf("failed to marshal TSL to XML: %w", err)
+			if err := publishTSLToFile(pl, tsl, filepath.Join(dirPath, filename), opts); err != nil {
+				return ctx, err
 			}
+		}
+	}
 
-			// Add XML header
-			xmlData = append([]byte(xml.Header), xmlData...)
+	return ctx, nil
+}
 
-			// Sign the XML if a signer is provided
-			if signer != nil {
-				xmlData, err = signer.Sign(xmlData)
-				if err != nil {
-					return ctx, fmt.Errorf("failed to sign XML: %w", err)
-				}
+// parsePublishTreeFormat returns the tree layout requested by a "tree:format" second
+// argument of the publish step ("territory" or "index"), or "" for a flat layout.
+func parsePublishTreeFormat(pl *Pipeline, args []string) string {
+	var useTreeStructure bool
+	var subdirFormat string
+
+	// Log the arguments received
+	for i, arg := range args {
+		pl.Logger.Debug("PublishTSL argument",
+			logging.F("index", i),
+			logging.F("value", arg))
+	}
+
+	// Check if we have the tree format argument - it might have spaces
+	if len(args) >= 2 {
+		// Log the arguments for debugging
+		pl.Logger.Debug("PublishTSL arguments",
+			logging.F("arg0", args[0]),
+			logging.F("arg1", args[1]),
+			logging.F("len", len(args)))
+
+		// Check if the second arg is a tree format specification
+		// It might be "tree:territory" or have spaces like "tree: territory"
+		arg := args[1]
+		arg = strings.TrimSpace(arg)
+
+		// Debug log for the trimmed argument
+		pl.Logger.Debug("Trimmed argument",
+			logging.F("raw", args[1]),
+			logging.F("trimmed", arg))
+
+		if strings.HasPrefix(arg, "tree:") {
+			useTreeStructure = true
+			// Default format is "territory" but can be overridden to "index" or "territory"
+			subdirFormat = strings.TrimPrefix(arg, "tree:")
+			subdirFormat = strings.TrimSpace(subdirFormat)
+
+			if subdirFormat == "" || (subdirFormat != "index" && subdirFormat != "territory") {
+				subdirFormat = "territory"
 			}
 
-			// Write to file
-			filePath := filepath.Join(dirPath, filename)
-			if err := os.WriteFile(filePath, xmlData, 0644); err != nil {
-				return ctx, fmt.Errorf("failed to write TSL to file %s: %w", filePath, err)
+			pl.Logger.Info("Using tree structure for output",
+				logging.F("format", subdirFormat),
+				logging.F("arg", arg),
+				logging.F("useTree", useTreeStructure))
+		} else {
+			// Safe way to get the first few characters
+			firstChars := ""
+			if len(arg) >= 5 {
+				firstChars = arg[0:5]
+			} else if len(arg) > 0 {
+				firstChars = arg
 			}
+
+			pl.Logger.Warn("Second argument is not a tree format",
+				logging.F("arg", arg),
+				logging.F("hasPrefix", strings.HasPrefix(arg, "tree:")),
+				logging.F("firstChars", firstChars))
 		}
+	} else {
+		pl.Logger.Debug("No tree format specified, using flat structure")
 	}
 
-	return ctx, nil
+	return subdirFormat
+}
+
+// checkSignerKeyPermissions applies the key-permissions policy to a file signer.
+// With "warn" an insecure key is logged, with "strict" it is rejected before any
+// output is written, and with "ignore" no check is performed.
+func checkSignerKeyPermissions(pl *Pipeline, signer *dsig.FileSigner, policy string) error {
+	if policy == KeyPermissionsIgnore {
+		return nil
+	}
+	// With "strict" the signer checks again when it reads the key
+	signer.StrictKeyPermissions = policy == KeyPermissionsStrict
+	err := dsig.CheckKeyFilePermissions(signer.KeyFile)
+	if err == nil || !errors.Is(err, dsig.ErrInsecureKeyPermissions) {
+		// Missing or unreadable key files are reported when signing
+		return nil
+	}
+	if policy == KeyPermissionsStrict {
+		return fmt.Errorf("refusing to use private key: %w", err)
+	}
+	pl.Logger.Warn("Private key file is accessible by group or others",
+		logging.F("key", signer.KeyFile),
+		logging.F("error", err))
+	return nil
+}
+
+// validatePublishArgs is the ArgsValidator of the publish step. It checks the
+// signer arguments when the pipeline is loaded: certificate and key files must
+// exist and parse as a PEM certificate and RSA private key (also for a key
+// rollover), and a PKCS#11 URI must name a module. The HSM itself is not opened.
+func validatePublishArgs(args ...string) error {
+	args, opts, err := parsePublishOptions(args)
+	if err != nil {
+		return err
+	}
+	if len(args) < 1 {
+		return fmt.Errorf("missing argument: directory path")
+	}
+	if opts.signer != nil && (len(args) >= 3 || len(args) >= 2 && strings.HasPrefix(args[1], "pkcs11:")) {
+		return fmt.Errorf("%w: the signer option cannot be combined with certificate and key arguments", ErrInvalidArguments)
+	}
+
+	switch {
+	case len(args) >= 2 && strings.HasPrefix(args[1], "pkcs11:"):
+		// The URI may contain the PIN, so it is not included in errors
+		config := dsig.ExtractPKCS11Config(args[1])
+		if config == nil {
+			return fmt.Errorf("invalid PKCS#11 URI: expected pkcs11:module=/path/to/module;...")
+		}
+		if filepath.IsAbs(config.Path) {
+			if _, err := os.Stat(config.Path); err != nil {
+				return fmt.Errorf("PKCS#11 module not found: %w", err)
+			}
+		}
+		opts.signer = dsig.NewPKCS11Signer(config, "", "")
+	case len(args) >= 3:
+		if err := validation.ValidateFilePath(args[1]); err != nil {
+			return fmt.Errorf("invalid certificate path: %w", err)
+		}
+		if err := validation.ValidateFilePath(args[2]); err != nil {
+			return fmt.Errorf("invalid key path: %w", err)
+		}
+		signer := dsig.NewFileSigner(args[1], args[2])
+		if err := checkFileSigner(signer, opts.keyPermissions); err != nil {
+			return err
+		}
+		opts.signer = signer
+	}
+
+	if rollover, ok := opts.rolloverSigner.(*dsig.FileSigner); ok {
+		if err := checkFileSigner(rollover, opts.keyPermissions); err != nil {
+			return fmt.Errorf("rollover signer: %w", err)
+		}
+	}
+	if err := opts.useXAdES(opts.signer); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidArguments, err)
+	}
+	return opts.validateRollover(args[0])
+}
+
+// publishOutputs is the OutputsFunc of the publish step: the output
+// directory, the rollover directory, and the signers used. PKCS#11 URIs may
+// contain the PIN, so they are not included.
+func publishOutputs(args ...string) []string {
+	args, opts, err := parsePublishOptions(args)
+	if err != nil || len(args) < 1 {
+		return nil
+	}
+	outputs := []string{args[0]}
+	switch {
+	case len(args) >= 2 && strings.HasPrefix(args[1], "pkcs11:"):
+		outputs = append(outputs, "signature:pkcs11")
+	case len(args) >= 3:
+		outputs = append(outputs, "signature:"+args[1])
+	case opts.signer != nil:
+		outputs = append(outputs, "signature:"+publishSignerName(opts.signer))
+	}
+	if opts.rolloverDir != "" {
+		outputs = append(outputs, opts.rolloverDir)
+	}
+	return outputs
+}
+
+// publishSignerName returns the value of the signer option selecting signer.
+func publishSignerName(signer dsig.XMLSigner) string {
+	if _, ok := signer.(dsig.NopSigner); ok {
+		return SignerNone
+	}
+	return SignerMemory
+}
+
+// checkFileSigner loads the certificate and key of a file signer without
+// signing anything. With the strict key-permissions policy an insecure key
+// file is rejected as well.
+func checkFileSigner(signer *dsig.FileSigner, keyPermissions string) error {
+	check := *signer
+	check.StrictKeyPermissions = keyPermissions == KeyPermissionsStrict
+	_, err := check.ToXMLDSigSigner()
+	return err
 }
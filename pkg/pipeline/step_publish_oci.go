@@ -0,0 +1,269 @@
+package pipeline
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirosfoundation/g119612/pkg/etsi119612"
+	"github.com/sirosfoundation/g119612/pkg/logging"
+	"github.com/sirosfoundation/g119612/pkg/oci"
+)
+
+// Media types of the artifact pushed by the publish-oci step.
+const (
+	// PoolArtifactType is the artifactType of the manifest.
+	PoolArtifactType = "application/vnd.sirosfoundation.g119612.pool.v1"
+	// MediaTypePEM is the media type of the pool.pem layer.
+	MediaTypePEM = "application/x-pem-file"
+	// MediaTypeTSL is the media type of the TSL layers, registered by ETSI TS 119 612.
+	MediaTypeTSL = "application/vnd.etsi.tsl+xml"
+)
+
+// Annotations of the artifact pushed by the publish-oci step, next to
+// oci.AnnotationCreated and oci.AnnotationTitle.
+const (
+	AnnotationPoolDigest  = "org.sirosfoundation.g119612.pool.digest" // OCI digest of pool.pem
+	AnnotationPoolCount   = "org.sirosfoundation.g119612.pool.count"  // Certificates in pool.pem
+	AnnotationPoolPolicy  = "org.sirosfoundation.g119612.pool.policy" // See PoolPolicy
+	AnnotationTSLSource   = "org.sirosfoundation.g119612.tsl.source"  // Where a TSL layer was loaded from
+	AnnotationTSLSequence = "org.sirosfoundation.g119612.tsl.sequence-number"
+)
+
+// DefaultOCIPushTimeout bounds a push of the publish-oci step without a timeout option.
+const DefaultOCIPushTimeout = 2 * time.Minute
+
+// publishOCIOptions are the parsed arguments of the publish-oci step.
+type publishOCIOptions struct {
+	ref         oci.Reference
+	username    string
+	passwordEnv string
+	plainHTTP   bool
+	poolOnly    bool
+	pool        string
+	timeout     time.Duration
+	annotations map[string]string
+}
+
+// PublishOCI is a pipeline step that pushes the certificate pool built by
+// select, and the TSLs it was selected from, to a container registry as an OCI
+// artifact, so that clusters can pull trust bundles through their existing
+// registry infrastructure, e.g. with "oras pull".
+//
+// The manifest has the artifactType PoolArtifactType and one layer per file:
+// pool.pem with the certificates of the pool (see PoolCertificates), followed
+// by tsl-1.xml, tsl-2.xml, ... with every loaded TSL in the form it was
+// fetched in. The manifest is annotated with its creation time, the digest and
+// size of the pool and the selection policy (see PoolPolicy); each TSL layer
+// with its source and sequence number.
+//
+// Parameters:
+//   - pl: Pipeline instance managing the step execution
+//   - ctx: Pipeline context with the pool built by select and the loaded TSLs
+//   - args: String slice where args[0] is the reference to push to,
+//     "registry/repository[:tag]" with the tag defaulting to "latest".
+//     Options in "key:value" form may follow:
+//   - username:USER: User name for the registry
+//   - password-env:VAR: Environment variable holding the password or token of USER
+//   - plain-http: Use HTTP instead of HTTPS, for local registries
+//   - pool-only: Only push pool.pem, without the TSLs
+//   - pool:NAME: Push the pool a select step with name:NAME built instead of the
+//     last one (see UsePool), to publish several pools selected in one run
+//   - annotation:KEY=VALUE: Further manifest annotation, may be repeated
+//   - timeout:DURATION: Time limit of the push (default 2m)
+//
+// Returns:
+//   - *Context: The context unchanged
+//   - error: Non-nil if an argument is invalid, no pool was selected or the push fails
+//
+// Example usage in pipeline configuration:
+//   - select: ["reference-depth:1"]
+//   - publish-oci:
+//   - registry.example.com/trust/eu-pool:latest
+//   - username:publisher
+//   - password-env:REGISTRY_TOKEN
+//   - select: ["name:qc", "service-type:http://uri.etsi.org/TrstSvc/Svctype/CA/QC"]
+//   - select: ["name:all"]
+//   - publish-oci: [registry.example.com/trust/qc-pool:latest, pool:qc]
+func PublishOCI(pl *Pipeline, ctx *Context, args ...string) (*Context, error) {
+	opts, err := parsePublishOCIArgs(args)
+	if err != nil {
+		return ctx, err
+	}
+	poolCtx := ctx
+	if opts.pool != "" {
+		if poolCtx, err = UsePool(ctx, opts.pool); err != nil {
+			return ctx, err
+		}
+	}
+	if poolCtx.CertPool == nil {
+		return ctx, ErrNoCertPool
+	}
+	client := &oci.Client{
+		HTTPClient: &http.Client{Timeout: opts.timeout},
+		Username:   opts.username,
+		PlainHTTP:  opts.plainHTTP,
+	}
+	if opts.passwordEnv != "" {
+		password, ok := os.LookupEnv(opts.passwordEnv)
+		if !ok {
+			return ctx, fmt.Errorf("environment variable %s with the registry password is not set", opts.passwordEnv)
+		}
+		client.Password = password
+	}
+
+	artifact, err := poolArtifact(poolCtx, opts)
+	if err != nil {
+		return ctx, err
+	}
+	pushCtx, cancel := context.WithTimeout(context.Background(), opts.timeout)
+	defer cancel()
+	desc, err := client.Push(pushCtx, opts.ref, artifact)
+	if err != nil {
+		return ctx, fmt.Errorf("failed to push %s: %w", opts.ref, err)
+	}
+
+	pl.Logger.Info("Pushed certificate pool to registry",
+		logging.F("reference", opts.ref.String()),
+		logging.F("pool", opts.pool),
+		logging.F("digest", desc.Digest),
+		logging.F("certificates", artifact.Annotations[AnnotationPoolCount]),
+		logging.F("tsls", len(artifact.Layers)-1))
+	return ctx, nil
+}
+
+// poolArtifact builds the artifact pushed by the publish-oci step.
+func poolArtifact(ctx *Context, opts publishOCIOptions) (oci.Artifact, error) {
+	var poolPEM []byte
+	count := 0
+	seen := make(map[[sha256.Size]byte]bool)
+	for _, cert := range PoolCertificates(ctx) {
+		digest := sha256.Sum256(cert.Raw)
+		if seen[digest] {
+			continue
+		}
+		seen[digest] = true
+		poolPEM = append(poolPEM, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})...)
+		count++
+	}
+
+	annotations := map[string]string{
+		oci.AnnotationCreated: ctx.Now().UTC().Format(time.RFC3339),
+		AnnotationPoolDigest:  oci.Digest(poolPEM),
+		AnnotationPoolCount:   strconv.Itoa(count),
+		AnnotationPoolPolicy:  PoolPolicy(ctx),
+	}
+	for key, value := range opts.annotations {
+		annotations[key] = value
+	}
+	artifact := oci.Artifact{
+		ArtifactType: PoolArtifactType,
+		Layers:       []oci.Layer{{MediaType: MediaTypePEM, Title: "pool.pem", Data: poolPEM}},
+		Annotations:  annotations,
+	}
+	if opts.poolOnly {
+		return artifact, nil
+	}
+	added := make(map[*etsi119612.TSL]bool)
+	for _, tsl := range contextTSLs(ctx) {
+		if tsl == nil || added[tsl] {
+			continue
+		}
+		added[tsl] = true
+		data, err := tsl.RawXML()
+		if err != nil {
+			return artifact, err
+		}
+		if len(data) == 0 {
+			if data, err = marshalTSLDocument(tsl); err != nil {
+				return artifact, fmt.Errorf("failed to serialize TSL %s: %w", tsl.Source, err)
+			}
+		}
+		artifact.Layers = append(artifact.Layers, oci.Layer{
+			MediaType: MediaTypeTSL,
+			Title:     fmt.Sprintf("tsl-%d.xml", len(added)),
+			Data:      data,
+			Annotations: map[string]string{
+				AnnotationTSLSource:   tsl.Source,
+				AnnotationTSLSequence: strconv.Itoa(sequenceNumber(tsl)),
+			},
+		})
+	}
+	return artifact, nil
+}
+
+// parsePublishOCIArgs parses the arguments of the publish-oci step.
+func parsePublishOCIArgs(args []string) (publishOCIOptions, error) {
+	opts := publishOCIOptions{timeout: DefaultOCIPushTimeout}
+	if len(args) < 1 || args[0] == "" {
+		return opts, fmt.Errorf("%w: missing registry reference", ErrInvalidArguments)
+	}
+	ref, err := oci.ParseReference(args[0])
+	if err != nil {
+		return opts, fmt.Errorf("%w: %v", ErrInvalidArguments, err)
+	}
+	opts.ref = ref
+	for _, arg := range args[1:] {
+		switch {
+		case strings.HasPrefix(arg, "username:"):
+			opts.username = strings.TrimPrefix(arg, "username:")
+		case strings.HasPrefix(arg, "password-env:"):
+			opts.passwordEnv = strings.TrimPrefix(arg, "password-env:")
+			if opts.passwordEnv == "" {
+				return opts, fmt.Errorf("%w: empty password-env", ErrInvalidArguments)
+			}
+		case arg == "plain-http":
+			opts.plainHTTP = true
+		case arg == "pool-only":
+			opts.poolOnly = true
+		case strings.HasPrefix(arg, "annotation:"):
+			key, value, ok := strings.Cut(strings.TrimPrefix(arg, "annotation:"), "=")
+			if !ok || key == "" {
+				return opts, fmt.Errorf("%w: invalid annotation %q (expected annotation:KEY=VALUE)", ErrInvalidArguments, arg)
+			}
+			if opts.annotations == nil {
+				opts.annotations = make(map[string]string)
+			}
+			opts.annotations[key] = value
+		case strings.HasPrefix(arg, "pool:"):
+			opts.pool = strings.TrimPrefix(arg, "pool:")
+			if opts.pool == "" {
+				return opts, fmt.Errorf("%w: empty pool name", ErrInvalidArguments)
+			}
+		case strings.HasPrefix(arg, "timeout:"):
+			timeout, err := time.ParseDuration(strings.TrimPrefix(arg, "timeout:"))
+			if err != nil || timeout <= 0 {
+				return opts, fmt.Errorf("%w: invalid timeout %q", ErrInvalidArguments, arg)
+			}
+			opts.timeout = timeout
+		default:
+			return opts, fmt.Errorf("%w: unexpected argument %q", ErrInvalidArguments, arg)
+		}
+	}
+	if opts.passwordEnv != "" && opts.username == "" {
+		return opts, fmt.Errorf("%w: password-env requires username", ErrInvalidArguments)
+	}
+	return opts, nil
+}
+
+// validatePublishOCIArgs is the ArgsValidator of the publish-oci step.
+func validatePublishOCIArgs(args ...string) error {
+	_, err := parsePublishOCIArgs(args)
+	return err
+}
+
+// publishOCIOutputs is the OutputsFunc of the publish-oci step: the reference
+// pushed to.
+func publishOCIOutputs(args ...string) []string {
+	opts, err := parsePublishOCIArgs(args)
+	if err != nil {
+		return nil
+	}
+	return []string{opts.ref.String()}
+}
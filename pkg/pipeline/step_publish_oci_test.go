@@ -0,0 +1,110 @@
+package pipeline
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/sirosfoundation/g119612/pkg/logging"
+	"github.com/sirosfoundation/g119612/pkg/oci"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ociTestRegistry serves the push endpoints of the OCI distribution
+// specification for any repository, keeping blobs and manifests in memory.
+func ociTestRegistry(t *testing.T) (string, map[string][]byte) {
+	var mu sync.Mutex
+	blobs := make(map[string][]byte)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.Method == http.MethodHead:
+			w.WriteHeader(http.StatusNotFound)
+		case r.Method == http.MethodPost:
+			w.Header().Set("Location", r.URL.Path+"1")
+			w.WriteHeader(http.StatusAccepted)
+		case r.Method == http.MethodPut:
+			data, _ := io.ReadAll(r.Body)
+			key := r.URL.Query().Get("digest")
+			if _, tag, ok := strings.Cut(r.URL.Path, "/manifests/"); ok {
+				key = "manifest:" + tag
+			}
+			blobs[key] = data
+			w.WriteHeader(http.StatusCreated)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return strings.TrimPrefix(server.URL, "http://"), blobs
+}
+
+func TestPublishOCI(t *testing.T) {
+	registry, blobs := ociTestRegistry(t)
+	_, _, cert, err := GenerateTestCertBase64()
+	require.NoError(t, err)
+	pl := &Pipeline{Logger: logging.SilentLogger()}
+	ctx := NewContext()
+	tsl := generateTSL("OCI Service", "http://uri.etsi.org/TrstSvc/Svctype/CA/QC", []string{base64.StdEncoding.EncodeToString(cert.Raw)})
+	tsl.Raw = []byte("<original/>")
+	ctx.AddTSL(tsl)
+
+	_, err = PublishOCI(pl, ctx, registry+"/trust/pool:v1", "plain-http")
+	assert.ErrorIs(t, err, ErrNoCertPool)
+
+	ctx, err = SelectCertPool(pl, ctx, "service-type:http://uri.etsi.org/TrstSvc/Svctype/CA/QC")
+	require.NoError(t, err)
+	assert.Equal(t, "reference-depth:0 service-type:http://uri.etsi.org/TrstSvc/Svctype/CA/QC", PoolPolicy(ctx))
+	_, err = PublishOCI(pl, ctx, registry+"/trust/pool:v1", "plain-http", "annotation:org.example.env=test")
+	require.NoError(t, err)
+
+	var manifest oci.Manifest
+	require.NoError(t, json.Unmarshal(blobs["manifest:v1"], &manifest))
+	assert.Equal(t, PoolArtifactType, manifest.ArtifactType)
+	assert.Equal(t, "1", manifest.Annotations[AnnotationPoolCount])
+	assert.Equal(t, PoolPolicy(ctx), manifest.Annotations[AnnotationPoolPolicy])
+	assert.Equal(t, "test", manifest.Annotations["org.example.env"])
+	assert.NotEmpty(t, manifest.Annotations[oci.AnnotationCreated])
+	require.Len(t, manifest.Layers, 2)
+
+	pool := manifest.Layers[0]
+	assert.Equal(t, "pool.pem", pool.Annotations[oci.AnnotationTitle])
+	assert.Equal(t, pool.Digest, manifest.Annotations[AnnotationPoolDigest])
+	block, _ := pem.Decode(blobs[pool.Digest])
+	require.NotNil(t, block)
+	assert.Equal(t, cert.Raw, block.Bytes)
+
+	snapshot := manifest.Layers[1]
+	assert.Equal(t, MediaTypeTSL, snapshot.MediaType)
+	assert.Equal(t, "tsl-1.xml", snapshot.Annotations[oci.AnnotationTitle])
+	assert.Equal(t, []byte("<original/>"), blobs[snapshot.Digest], "TSLs are pushed as fetched")
+
+	_, err = PublishOCI(pl, ctx, registry+"/trust/pool:v2", "plain-http", "pool-only")
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(blobs["manifest:v2"], &manifest))
+	assert.Len(t, manifest.Layers, 1)
+
+	_, err = PublishOCI(pl, ctx, registry+"/trust/pool", "plain-http", "username:u", "password-env:G119612_TEST_UNSET_PASSWORD")
+	assert.ErrorContains(t, err, "G119612_TEST_UNSET_PASSWORD")
+}
+
+func TestPublishOCI_Arguments(t *testing.T) {
+	for _, args := range [][]string{
+		{},
+		{"pool"},
+		{"registry.example.com/pool", "password-env:TOKEN"},
+		{"registry.example.com/pool", "annotation:novalue"},
+		{"registry.example.com/pool", "timeout:0s"},
+		{"registry.example.com/pool", "unknown"},
+	} {
+		assert.ErrorIs(t, validatePublishOCIArgs(args...), ErrInvalidArguments, args)
+	}
+	assert.NoError(t, validatePublishOCIArgs("registry.example.com/pool", "username:u", "password-env:TOKEN", "timeout:30s"))
+	assert.Equal(t, []string{"registry.example.com/pool:latest"}, publishOCIOutputs("registry.example.com/pool"))
+}
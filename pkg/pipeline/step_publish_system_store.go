@@ -0,0 +1,278 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/sirosfoundation/g119612/pkg/logging"
+	"github.com/sirosfoundation/g119612/pkg/truststore"
+)
+
+// Kinds of system trust stores of the publish-system-store step.
+const (
+	SystemStoreAnchors  = "anchors"  // Anchors directory of the Linux CA certificate tools
+	SystemStoreKeychain = "keychain" // macOS keychain
+	SystemStoreCertutil = "certutil" // Windows certificate store
+)
+
+// publishSystemStoreOptions are the parsed arguments of the
+// publish-system-store step.
+type publishSystemStoreOptions struct {
+	store     string
+	dir       string
+	update    []string // nil for the update command of dir, empty for none
+	prefix    string
+	keychain  string
+	certStore string
+	state     string
+	pool      string
+	confirm   bool
+	sync      truststore.SyncOptions
+}
+
+// PublishSystemStore is a pipeline step that installs the certificate pool
+// built by select into the trust store of the operating system, so that every
+// program on the host trusts the selected certificates, for appliance-style
+// deployments. See package truststore for the stores supported.
+//
+// The step only manages certificates it installed itself: files with its
+// prefix in an anchors directory, or the certificates recorded in its state
+// file for a keychain or certificate store. Since the whole host is affected,
+// the step refuses to run without the confirm option, refuses an empty pool
+// and only removes certificates that left the pool with allow-remove; without
+// it they are kept and logged as stale.
+//
+// Parameters:
+//   - pl: Pipeline instance managing the step execution
+//   - ctx: Pipeline context with the pool built by select
+//   - args: Options in "key:value" form:
+//   - store:anchors|keychain|certutil: The kind of store, by default the one of
+//     the operating system: anchors on Linux, keychain on macOS and certutil
+//     on Windows
+//   - dir:DIR: Anchors directory (default: the first of
+//     truststore.AnchorLocations that exists)
+//   - update:COMMAND: Command run after changing an anchors directory, or
+//     "none" (default: the one of the distribution for a known directory)
+//   - prefix:PREFIX: File name prefix of the installed certificates (default "g119612-")
+//   - keychain:PATH: Keychain to install into (default the System keychain)
+//   - cert-store:NAME: Windows certificate store to install into (default "Root")
+//   - state:FILE: State file recording the installed certificates of a
+//     keychain or certificate store
+//   - pool:NAME: Install the pool a select step with name:NAME built instead
+//     of the last one (see UsePool)
+//   - confirm: Required to change the store
+//   - dry-run: Only log the changes, without changing the store
+//   - allow-remove: Remove certificates installed by earlier runs that are no
+//     longer in the pool
+//   - max-changes:N: Fail without changing the store if more than N
+//     certificates would be added or removed
+//
+// Returns:
+//   - *Context: The context unchanged
+//   - error: Non-nil if an argument is invalid, no pool was selected, a safety
+//     check fails or the store cannot be changed
+//
+// Example usage in pipeline configuration:
+//   - select: ["service-type:http://uri.etsi.org/TrstSvc/Svctype/CA/QC"]
+//   - publish-system-store: [confirm, allow-remove, "max-changes:20"]
+func PublishSystemStore(pl *Pipeline, ctx *Context, args ...string) (*Context, error) {
+	opts, err := parsePublishSystemStoreArgs(args)
+	if err != nil {
+		return ctx, err
+	}
+	poolCtx := ctx
+	if opts.pool != "" {
+		if poolCtx, err = UsePool(ctx, opts.pool); err != nil {
+			return ctx, err
+		}
+	}
+	if poolCtx.CertPool == nil {
+		return ctx, ErrNoCertPool
+	}
+	store, err := newSystemStore(opts)
+	if err != nil {
+		return ctx, err
+	}
+
+	plan, err := truststore.Sync(context.Background(), store, PoolCertificates(poolCtx), opts.sync)
+	if plan != nil {
+		for _, cert := range plan.Add {
+			pl.Logger.Debug("Certificate to install", logging.F("subject", cert.Subject.String()),
+				logging.F("fingerprint", truststore.Fingerprint(cert)))
+		}
+		for _, fingerprint := range plan.Remove {
+			pl.Logger.Debug("Certificate to remove", logging.F("fingerprint", fingerprint))
+		}
+		for _, fingerprint := range plan.Stale {
+			pl.Logger.Warn("Installed certificate no longer in the pool, use allow-remove to remove it",
+				logging.F("store", store.Name()),
+				logging.F("fingerprint", fingerprint))
+		}
+	}
+	if err != nil {
+		return ctx, err
+	}
+
+	message := "Updated system trust store"
+	if opts.sync.DryRun {
+		message = "Dry run of system trust store update"
+	}
+	pl.Logger.Info(message,
+		logging.F("store", store.Name()),
+		logging.F("location", store.Location()),
+		logging.F("pool", opts.pool),
+		logging.F("added", len(plan.Add)),
+		logging.F("removed", len(plan.Remove)),
+		logging.F("stale", len(plan.Stale)),
+		logging.F("kept", plan.Keep))
+	return ctx, nil
+}
+
+// newSystemStore returns the store of the publish-system-store step.
+func newSystemStore(opts publishSystemStoreOptions) (truststore.Store, error) {
+	switch opts.store {
+	case SystemStoreKeychain:
+		return &truststore.KeychainStore{Keychain: opts.keychain, State: opts.state}, nil
+	case SystemStoreCertutil:
+		return &truststore.CertutilStore{Store: opts.certStore, State: opts.state}, nil
+	}
+	var store *truststore.AnchorStore
+	if opts.dir != "" {
+		store = truststore.NewAnchorStore(opts.dir)
+	} else {
+		var err error
+		if store, err = truststore.DetectAnchorStore(); err != nil {
+			return nil, fmt.Errorf("%w: use dir:DIR", err)
+		}
+	}
+	if opts.update != nil {
+		store.Update = opts.update
+	}
+	store.Prefix = opts.prefix
+	return store, nil
+}
+
+// defaultSystemStore returns the kind of store of the operating system, or ""
+// if there is none.
+func defaultSystemStore() string {
+	switch runtime.GOOS {
+	case "linux":
+		return SystemStoreAnchors
+	case "darwin":
+		return SystemStoreKeychain
+	case "windows":
+		return SystemStoreCertutil
+	}
+	return ""
+}
+
+// parsePublishSystemStoreArgs parses the arguments of the publish-system-store step.
+func parsePublishSystemStoreArgs(args []string) (publishSystemStoreOptions, error) {
+	var opts publishSystemStoreOptions
+	for _, arg := range args {
+		switch {
+		case strings.HasPrefix(arg, "store:"):
+			opts.store = strings.TrimPrefix(arg, "store:")
+			if opts.store != SystemStoreAnchors && opts.store != SystemStoreKeychain && opts.store != SystemStoreCertutil {
+				return opts, fmt.Errorf("%w: invalid store %q (expected anchors, keychain or certutil)", ErrInvalidArguments, opts.store)
+			}
+		case strings.HasPrefix(arg, "dir:"):
+			opts.dir = strings.TrimPrefix(arg, "dir:")
+		case strings.HasPrefix(arg, "update:"):
+			update := strings.TrimPrefix(arg, "update:")
+			if update == "none" {
+				opts.update = []string{}
+			} else if opts.update = strings.Fields(update); len(opts.update) == 0 {
+				return opts, fmt.Errorf("%w: empty update command", ErrInvalidArguments)
+			}
+		case strings.HasPrefix(arg, "prefix:"):
+			opts.prefix = strings.TrimPrefix(arg, "prefix:")
+			if opts.prefix == "" || strings.ContainsAny(opts.prefix, `/\`) {
+				return opts, fmt.Errorf("%w: invalid prefix %q", ErrInvalidArguments, opts.prefix)
+			}
+		case strings.HasPrefix(arg, "keychain:"):
+			opts.keychain = strings.TrimPrefix(arg, "keychain:")
+		case strings.HasPrefix(arg, "cert-store:"):
+			opts.certStore = strings.TrimPrefix(arg, "cert-store:")
+		case strings.HasPrefix(arg, "state:"):
+			opts.state = strings.TrimPrefix(arg, "state:")
+		case strings.HasPrefix(arg, "pool:"):
+			opts.pool = strings.TrimPrefix(arg, "pool:")
+			if opts.pool == "" {
+				return opts, fmt.Errorf("%w: empty pool name", ErrInvalidArguments)
+			}
+		case arg == "confirm":
+			opts.confirm = true
+		case arg == "dry-run":
+			opts.sync.DryRun = true
+		case arg == "allow-remove":
+			opts.sync.AllowRemove = true
+		case strings.HasPrefix(arg, "max-changes:"):
+			n, err := strconv.Atoi(strings.TrimPrefix(arg, "max-changes:"))
+			if err != nil || n < 1 {
+				return opts, fmt.Errorf("%w: invalid max-changes %q", ErrInvalidArguments, arg)
+			}
+			opts.sync.MaxChanges = n
+		default:
+			return opts, fmt.Errorf("%w: unexpected argument %q", ErrInvalidArguments, arg)
+		}
+	}
+
+	if opts.store == "" {
+		if opts.store = defaultSystemStore(); opts.store == "" {
+			return opts, fmt.Errorf("%w: no system trust store known for %s, use store:", ErrInvalidArguments, runtime.GOOS)
+		}
+	}
+	if opts.store != SystemStoreAnchors && (opts.dir != "" || opts.update != nil || opts.prefix != "") {
+		return opts, fmt.Errorf("%w: dir:, update: and prefix: only apply to store:anchors", ErrInvalidArguments)
+	}
+	if opts.keychain != "" && opts.store != SystemStoreKeychain {
+		return opts, fmt.Errorf("%w: keychain: only applies to store:keychain", ErrInvalidArguments)
+	}
+	if opts.certStore != "" && opts.store != SystemStoreCertutil {
+		return opts, fmt.Errorf("%w: cert-store: only applies to store:certutil", ErrInvalidArguments)
+	}
+	if opts.state != "" && opts.store == SystemStoreAnchors {
+		return opts, fmt.Errorf("%w: state: does not apply to store:anchors", ErrInvalidArguments)
+	}
+	if !opts.confirm && !opts.sync.DryRun {
+		return opts, fmt.Errorf("%w: changing the system trust store requires confirm (or dry-run)", ErrInvalidArguments)
+	}
+	return opts, nil
+}
+
+// validatePublishSystemStoreArgs is the ArgsValidator of the
+// publish-system-store step.
+func validatePublishSystemStoreArgs(args ...string) error {
+	_, err := parsePublishSystemStoreArgs(args)
+	return err
+}
+
+// publishSystemStoreOutputs is the OutputsFunc of the publish-system-store
+// step: the store changed, none for a dry run.
+func publishSystemStoreOutputs(args ...string) []string {
+	opts, err := parsePublishSystemStoreArgs(args)
+	if err != nil || opts.sync.DryRun {
+		return nil
+	}
+	location := opts.dir
+	switch opts.store {
+	case SystemStoreKeychain:
+		location = opts.keychain
+		if location == "" {
+			location = truststore.DefaultKeychain
+		}
+	case SystemStoreCertutil:
+		location = opts.certStore
+		if location == "" {
+			location = truststore.DefaultCertutilStore
+		}
+	}
+	if location == "" {
+		return []string{"system-store:" + opts.store}
+	}
+	return []string{"system-store:" + opts.store + ":" + location}
+}
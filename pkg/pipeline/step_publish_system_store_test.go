@@ -0,0 +1,85 @@
+package pipeline
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirosfoundation/g119612/pkg/logging"
+	"github.com/sirosfoundation/g119612/pkg/truststore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublishSystemStore(t *testing.T) {
+	_, _, cert, err := GenerateTestCertBase64()
+	require.NoError(t, err)
+	_, _, other, err := GenerateTestCertBase64()
+	require.NoError(t, err)
+	pl := &Pipeline{Logger: logging.SilentLogger()}
+	ctx := NewContext()
+	ctx.AddTSL(generateTSL("Store Service", "http://uri.etsi.org/TrstSvc/Svctype/CA/QC",
+		[]string{base64.StdEncoding.EncodeToString(cert.Raw)}))
+	dir := t.TempDir()
+	args := []string{"store:anchors", "dir:" + dir, "update:none", "confirm"}
+
+	_, err = PublishSystemStore(pl, ctx, args...)
+	assert.ErrorIs(t, err, ErrNoCertPool)
+
+	ctx, err = SelectCertPool(pl, ctx)
+	require.NoError(t, err)
+	installed := filepath.Join(dir, truststore.DefaultPrefix+truststore.Fingerprint(cert)+".crt")
+
+	_, err = PublishSystemStore(pl, ctx, append(args, "dry-run")...)
+	require.NoError(t, err)
+	assert.NoFileExists(t, installed, "dry-run changes nothing")
+
+	_, err = PublishSystemStore(pl, ctx, args...)
+	require.NoError(t, err)
+	assert.FileExists(t, installed)
+
+	// A certificate installed earlier that left the pool is only removed with allow-remove
+	stale := &truststore.AnchorStore{Dir: dir}
+	require.NoError(t, stale.Add(t.Context(), other))
+	_, err = PublishSystemStore(pl, ctx, args...)
+	require.NoError(t, err)
+	files, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, files, 2)
+
+	_, err = PublishSystemStore(pl, ctx, append(args, "allow-remove")...)
+	require.NoError(t, err)
+	files, err = os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.Equal(t, filepath.Base(installed), files[0].Name())
+
+	_, err = PublishSystemStore(pl, ctx, "store:anchors", "dir:"+t.TempDir(), "update:none", "confirm", "pool:unknown")
+	assert.Error(t, err)
+}
+
+func TestPublishSystemStore_Arguments(t *testing.T) {
+	for _, args := range [][]string{
+		{"store:anchors", "dir:/tmp/anchors"},
+		{"store:nss", "confirm"},
+		{"store:keychain", "dir:/tmp/anchors", "confirm"},
+		{"store:certutil", "keychain:/tmp/login.keychain", "confirm"},
+		{"store:anchors", "state:/tmp/state.json", "confirm"},
+		{"store:anchors", "update:", "confirm"},
+		{"store:anchors", "prefix:a/b", "confirm"},
+		{"store:anchors", "max-changes:0", "confirm"},
+		{"store:anchors", "pool:", "confirm"},
+		{"store:anchors", "unknown", "confirm"},
+	} {
+		assert.ErrorIs(t, validatePublishSystemStoreArgs(args...), ErrInvalidArguments, args)
+	}
+	assert.NoError(t, validatePublishSystemStoreArgs("store:anchors", "dry-run"))
+	assert.NoError(t, validatePublishSystemStoreArgs("store:keychain", "state:/tmp/state.json", "confirm", "allow-remove", "max-changes:5"))
+
+	assert.Nil(t, publishSystemStoreOutputs("store:anchors", "dir:/tmp/anchors", "dry-run"))
+	assert.Equal(t, []string{"system-store:anchors:/tmp/anchors"}, publishSystemStoreOutputs("store:anchors", "dir:/tmp/anchors", "confirm"))
+	assert.Equal(t, []string{"system-store:anchors"}, publishSystemStoreOutputs("store:anchors", "confirm"))
+	assert.Equal(t, []string{"system-store:keychain:" + truststore.DefaultKeychain}, publishSystemStoreOutputs("store:keychain", "confirm"))
+	assert.Equal(t, []string{"system-store:certutil:My"}, publishSystemStoreOutputs("store:certutil", "cert-store:My", "confirm"))
+}
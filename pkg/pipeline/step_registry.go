@@ -1,6 +1,10 @@
 package pipeline
 
-import "sync"
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
 
 // StepFunc is the function type for pipeline steps.
 // Each step takes a pipeline instance, a context, and variadic string arguments,
@@ -16,14 +20,212 @@ import "sync"
 //   - An error if processing fails
 type StepFunc func(pl *Pipeline, ctx *Context, args ...string) (*Context, error)
 
-var (
-	functionRegistry = make(map[string]StepFunc)
-	registryMutex    sync.RWMutex
-)
+// ArgsValidator checks the arguments of a pipeline step without running it.
+// Validators catch configuration mistakes, such as a missing signing key, when
+// the pipeline is loaded instead of when the step is reached after a long fetch.
+// A validator must not modify any state or contact remote services.
+type ArgsValidator func(args ...string) error
+
+// OutputsFunc returns the files and directories a pipeline step writes, or
+// the signatures it makes, when run with the given arguments, and nil if it
+// has no such effect. It must not modify any state.
+//
+// With Pipeline.ReadOnly set, steps whose OutputsFunc returns outputs are not
+// run; the outputs are logged instead.
+type OutputsFunc func(args ...string) []string
+
+// StepInfo describes a pipeline step for help texts, such as the manual page
+// and shell completion of tsl-tool. It has no effect on processing.
+type StepInfo struct {
+	Summary string       // One-line description of the step
+	Args    string       // Positional arguments, e.g. "<dir> [<cert> <key>]"
+	Options []StepOption // Options in the order they are best documented
+}
+
+// StepOption describes an option of a pipeline step.
+type StepOption struct {
+	Name        string // Option as written in a pipeline, e.g. "timeout:DURATION" or "strict"
+	Description string // One-sentence description
+}
 
-// RegisterFunction registers a pipeline step function with the given name.
-// Once registered, the function can be referenced by name in pipeline YAML files
-// and will be looked up during pipeline processing.
+// Registry holds the step functions, argument validators, OutputsFunc
+// implementations and StepInfo descriptions that pipelines and tools look up
+// by step name.
+//
+// DefaultRegistry holds the built-in steps and everything registered with the
+// package level RegisterFunction, RegisterValidator and RegisterOutputs. An
+// application embedding several differently configured pipelines gives each
+// its own registry through Pipeline.Registry: NewRegistry extends the default
+// registry, while NewEmptyRegistry starts without any built-in step, so that a
+// sandboxed pipeline can only run the steps imported or registered into it.
+//
+// All methods are thread-safe.
+type Registry struct {
+	mu         sync.RWMutex
+	parent     *Registry // Consulted for names not registered here, nil for none
+	functions  map[string]StepFunc
+	validators map[string]ArgsValidator
+	outputs    map[string]OutputsFunc
+	infos      map[string]StepInfo
+}
+
+// DefaultRegistry is the registry of pipelines whose Registry is nil.
+var DefaultRegistry = newRegistry(nil)
+
+func newRegistry(parent *Registry) *Registry {
+	return &Registry{
+		parent:     parent,
+		functions:  make(map[string]StepFunc),
+		validators: make(map[string]ArgsValidator),
+		outputs:    make(map[string]OutputsFunc),
+		infos:      make(map[string]StepInfo),
+	}
+}
+
+// NewRegistry returns a registry extending DefaultRegistry: steps registered
+// in it are only visible to the pipelines using it, and take precedence over
+// default steps of the same name.
+func NewRegistry() *Registry {
+	return newRegistry(DefaultRegistry)
+}
+
+// NewEmptyRegistry returns a registry without any step, not even the built-in
+// ones. Use Import to make selected built-in steps available.
+func NewEmptyRegistry() *Registry {
+	return newRegistry(nil)
+}
+
+// RegisterFunction registers a pipeline step function under name in r.
+func (r *Registry) RegisterFunction(name string, fn StepFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.functions[name] = fn
+}
+
+// RegisterValidator registers the argument validator of the step name in r.
+func (r *Registry) RegisterValidator(name string, fn ArgsValidator) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.validators[name] = fn
+}
+
+// RegisterOutputs registers the OutputsFunc of the step name in r.
+func (r *Registry) RegisterOutputs(name string, fn OutputsFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.outputs[name] = fn
+}
+
+// RegisterInfo registers the description of the step name in r.
+func (r *Registry) RegisterInfo(name string, info StepInfo) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.infos[name] = info
+}
+
+// Function returns the step function registered under name in r or the
+// registry it extends, and whether one was found.
+func (r *Registry) Function(name string) (StepFunc, bool) {
+	r.mu.RLock()
+	fn, ok := r.functions[name]
+	r.mu.RUnlock()
+	if !ok && r.parent != nil {
+		return r.parent.Function(name)
+	}
+	return fn, ok
+}
+
+// Validator returns the argument validator of the step name in r or the
+// registry it extends, and whether one was found.
+func (r *Registry) Validator(name string) (ArgsValidator, bool) {
+	r.mu.RLock()
+	fn, ok := r.validators[name]
+	r.mu.RUnlock()
+	if !ok && r.parent != nil {
+		return r.parent.Validator(name)
+	}
+	return fn, ok
+}
+
+// Outputs returns the OutputsFunc of the step name in r or the registry it
+// extends, and whether one was found.
+func (r *Registry) Outputs(name string) (OutputsFunc, bool) {
+	r.mu.RLock()
+	fn, ok := r.outputs[name]
+	r.mu.RUnlock()
+	if !ok && r.parent != nil {
+		return r.parent.Outputs(name)
+	}
+	return fn, ok
+}
+
+// Info returns the description of the step name in r or the registry it
+// extends, and whether one was found.
+func (r *Registry) Info(name string) (StepInfo, bool) {
+	r.mu.RLock()
+	info, ok := r.infos[name]
+	r.mu.RUnlock()
+	if !ok && r.parent != nil {
+		return r.parent.Info(name)
+	}
+	return info, ok
+}
+
+// Names returns the sorted names of the steps available in r, including those
+// of the registry it extends.
+func (r *Registry) Names() []string {
+	seen := make(map[string]bool)
+	for reg := r; reg != nil; reg = reg.parent {
+		reg.mu.RLock()
+		for name := range reg.functions {
+			seen[name] = true
+		}
+		reg.mu.RUnlock()
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Import registers the steps of from with the given names in r, together with
+// their validators, OutputsFunc implementations and descriptions, for example to allow some
+// built-in steps in an empty registry:
+//
+//	reg := pipeline.NewEmptyRegistry()
+//	err := reg.Import(pipeline.DefaultRegistry, "load", "select")
+//
+// It fails with ErrFunctionNotFound if from has no step of one of the names,
+// in which case nothing is imported.
+func (r *Registry) Import(from *Registry, names ...string) error {
+	functions := make(map[string]StepFunc, len(names))
+	for _, name := range names {
+		fn, ok := from.Function(name)
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrFunctionNotFound, name)
+		}
+		functions[name] = fn
+	}
+	for _, name := range names {
+		r.RegisterFunction(name, functions[name])
+		if fn, ok := from.Validator(name); ok {
+			r.RegisterValidator(name, fn)
+		}
+		if fn, ok := from.Outputs(name); ok {
+			r.RegisterOutputs(name, fn)
+		}
+		if info, ok := from.Info(name); ok {
+			r.RegisterInfo(name, info)
+		}
+	}
+	return nil
+}
+
+// RegisterFunction registers a pipeline step function with the given name in
+// DefaultRegistry. Once registered, the function can be referenced by name in
+// pipeline YAML files and will be looked up during pipeline processing.
 //
 // This function is thread-safe due to mutex protection.
 //
@@ -31,13 +233,12 @@ var (
 //   - name: A unique name to identify the step function in pipeline configurations
 //   - fn: The StepFunc implementation to register
 func RegisterFunction(name string, fn StepFunc) {
-	registryMutex.Lock()
-	defer registryMutex.Unlock()
-	functionRegistry[name] = fn
+	DefaultRegistry.RegisterFunction(name, fn)
 }
 
-// GetFunctionByName retrieves a registered pipeline step function by name.
-// It returns the function and a boolean indicating whether it was found.
+// GetFunctionByName retrieves a pipeline step function registered in
+// DefaultRegistry by name. It returns the function and a boolean indicating
+// whether it was found.
 //
 // This function is thread-safe due to mutex protection.
 //
@@ -48,8 +249,67 @@ func RegisterFunction(name string, fn StepFunc) {
 //   - The registered StepFunc, if found
 //   - A boolean indicating whether the function was found
 func GetFunctionByName(name string) (StepFunc, bool) {
-	registryMutex.RLock()
-	defer registryMutex.RUnlock()
-	fn, ok := functionRegistry[name]
-	return fn, ok
+	return DefaultRegistry.Function(name)
+}
+
+// RegisterValidator registers an argument validator for the pipeline step with
+// the given name in DefaultRegistry. Pipeline.Validate calls it with the
+// arguments of every step of that name. Steps without a validator are not checked.
+//
+// This function is thread-safe due to mutex protection.
+//
+// Parameters:
+//   - name: The name the step function is registered under
+//   - fn: The ArgsValidator implementation to register
+func RegisterValidator(name string, fn ArgsValidator) {
+	DefaultRegistry.RegisterValidator(name, fn)
+}
+
+// GetValidatorByName retrieves the argument validator registered for a pipeline
+// step in DefaultRegistry. It returns the validator and a boolean indicating
+// whether one was found.
+//
+// This function is thread-safe due to mutex protection.
+func GetValidatorByName(name string) (ArgsValidator, bool) {
+	return DefaultRegistry.Validator(name)
+}
+
+// RegisterOutputs registers the OutputsFunc of the pipeline step with the
+// given name in DefaultRegistry. Steps without one are run in read-only mode
+// as usual, so steps writing files must register one.
+//
+// This function is thread-safe due to mutex protection.
+//
+// Parameters:
+//   - name: The name the step function is registered under
+//   - fn: The OutputsFunc implementation to register
+func RegisterOutputs(name string, fn OutputsFunc) {
+	DefaultRegistry.RegisterOutputs(name, fn)
+}
+
+// GetOutputsByName retrieves the OutputsFunc registered for a pipeline step in
+// DefaultRegistry. It returns the function and a boolean indicating whether one
+// was found.
+//
+// This function is thread-safe due to mutex protection.
+func GetOutputsByName(name string) (OutputsFunc, bool) {
+	return DefaultRegistry.Outputs(name)
+}
+
+// RegisterInfo registers the description of the pipeline step with the given
+// name in DefaultRegistry, shown in help texts such as the manual page of
+// tsl-tool.
+//
+// This function is thread-safe due to mutex protection.
+func RegisterInfo(name string, info StepInfo) {
+	DefaultRegistry.RegisterInfo(name, info)
+}
+
+// GetInfoByName retrieves the description registered for a pipeline step in
+// DefaultRegistry. It returns the description and a boolean indicating whether
+// one was found.
+//
+// This function is thread-safe due to mutex protection.
+func GetInfoByName(name string) (StepInfo, bool) {
+	return DefaultRegistry.Info(name)
 }
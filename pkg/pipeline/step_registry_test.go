@@ -0,0 +1,121 @@
+package pipeline
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirosfoundation/g119612/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry(t *testing.T) {
+	greet := func(greeting string) StepFunc {
+		return func(pl *Pipeline, ctx *Context, args ...string) (*Context, error) {
+			ctx.SetData("greeting", greeting)
+			return ctx, nil
+		}
+	}
+
+	t.Run("Pipelines_With_Own_Registries", func(t *testing.T) {
+		english, swedish := NewRegistry(), NewRegistry()
+		english.RegisterFunction("greet", greet("hello"))
+		swedish.RegisterFunction("greet", greet("hej"))
+
+		for reg, want := range map[*Registry]string{english: "hello", swedish: "hej"} {
+			pl := &Pipeline{Pipes: []Pipe{{MethodName: "greet"}}, Logger: logging.SilentLogger(), Registry: reg}
+			ctx, err := pl.Process(NewContext())
+			require.NoError(t, err)
+			assert.Equal(t, want, ctx.Data["greeting"])
+		}
+
+		_, ok := GetFunctionByName("greet")
+		assert.False(t, ok, "steps of a registry are not visible in the default registry")
+		_, ok = english.Function("select")
+		assert.True(t, ok, "a registry extends the default registry")
+		assert.Contains(t, english.Names(), "greet")
+		assert.Contains(t, english.Names(), "load")
+	})
+
+	t.Run("Override_Default_Step", func(t *testing.T) {
+		reg := NewRegistry()
+		reg.RegisterFunction("echo", greet("overridden"))
+		pl := &Pipeline{Pipes: []Pipe{{MethodName: "echo"}}, Logger: logging.SilentLogger(), Registry: reg}
+		ctx, err := pl.Process(NewContext())
+		require.NoError(t, err)
+		assert.Equal(t, "overridden", ctx.Data["greeting"])
+	})
+
+	t.Run("Empty_Registry", func(t *testing.T) {
+		reg := NewEmptyRegistry()
+		assert.Empty(t, reg.Names())
+		pl := &Pipeline{Pipes: []Pipe{{MethodName: "load", MethodArguments: []string{"file:///etc/passwd"}}}, Logger: logging.SilentLogger(), Registry: reg}
+		_, err := pl.Process(NewContext())
+		assert.ErrorContains(t, err, "unknown methodName 'load'")
+		_, err = Explain(pl)
+		assert.ErrorContains(t, err, "unknown methodName 'load'")
+
+		require.NoError(t, reg.Import(DefaultRegistry, "echo", "publish"))
+		assert.Equal(t, []string{"echo", "publish"}, reg.Names())
+		_, ok := reg.Validator("publish")
+		assert.True(t, ok, "validators are imported with their steps")
+		_, ok = reg.Outputs("publish")
+		assert.True(t, ok, "outputs are imported with their steps")
+		_, ok = reg.Info("publish")
+		assert.True(t, ok, "descriptions are imported with their steps")
+
+		err = reg.Import(DefaultRegistry, "select", "no-such-step")
+		assert.ErrorIs(t, err, ErrFunctionNotFound)
+		_, ok = reg.Function("select")
+		assert.False(t, ok, "nothing is imported if a step is missing")
+	})
+
+	t.Run("Validate_With_Registry", func(t *testing.T) {
+		reg := NewRegistry()
+		reg.RegisterFunction("greet", greet("hello"))
+		reg.RegisterValidator("greet", func(args ...string) error {
+			if len(args) > 0 {
+				return errors.New("greet takes no arguments")
+			}
+			return nil
+		})
+		file := filepath.Join(t.TempDir(), "pipeline.yaml")
+		require.NoError(t, os.WriteFile(file, []byte("- greet: [loudly]\n"), 0600))
+
+		_, err := NewPipelineWithRegistry(file, reg)
+		assert.ErrorIs(t, err, ErrInvalidArguments)
+		_, err = NewPipeline(file)
+		assert.NoError(t, err, "the default registry has no greet validator")
+
+		require.NoError(t, os.WriteFile(file, []byte("- greet: []\n"), 0600))
+		pl, err := NewPipelineWithRegistry(file, reg)
+		require.NoError(t, err)
+		assert.Same(t, reg, pl.Registry)
+		assert.Same(t, reg, pl.WithLogger(logging.SilentLogger()).Registry)
+	})
+
+	t.Run("Step_Info", func(t *testing.T) {
+		// Other tests register steps of their own in DefaultRegistry
+		builtin := []string{"load", "select", "select-cert-pool", "transform", "render", "publish",
+			"generate", "generate_index", "change-report", "check-links", "log", "echo",
+			"set-fetch-options", "set-language", "export-notification", "export-oidfed",
+			"compare-remote", "mirror", "publish-oci"}
+		for _, name := range builtin {
+			_, registered := GetFunctionByName(name)
+			require.True(t, registered, name)
+			info, ok := GetInfoByName(name)
+			if assert.True(t, ok, "step %s has no description", name) {
+				assert.NotEmpty(t, info.Summary, name)
+			}
+		}
+
+		reg := NewRegistry()
+		reg.RegisterInfo("load", StepInfo{Summary: "Overridden"})
+		info, _ := reg.Info("load")
+		assert.Equal(t, "Overridden", info.Summary)
+		info, _ = DefaultRegistry.Info("load")
+		assert.Contains(t, info.Options, StepOption{"allow-doctype[:true]", "Accept TSLs with a DOCTYPE declaration"})
+	})
+}
@@ -1,13 +1,18 @@
 package pipeline
 
 import (
+	"crypto/sha256"
 	"crypto/x509"
+	"encoding/hex"
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/sirosfoundation/g119612/pkg/etsi119612"
+	"github.com/sirosfoundation/g119612/pkg/etsi119612/uri"
 	"github.com/sirosfoundation/g119612/pkg/logging"
+	"github.com/sirosfoundation/g119612/pkg/validation"
 )
 
 // SelectCertPool creates a new x509.CertPool from all certificates in the loaded TSLs.
@@ -25,23 +30,75 @@ import (
 //   - "reference-depth:N": Process TSLs up to N levels deep in references (0=root only, 1=root+direct refs)
 //   - "include-referenced": Legacy option, equivalent to a large reference depth (includes all refs)
 //   - "service-type:URI": Filter certificates by service type URI (can be provided multiple times)
-//   - "status:URI": Filter certificates by status URI (can be provided multiple times)
+//   - "status:URI": Filter certificates by status URI (can be provided multiple times); a
+//     filter on an eIDAS status also matches equivalent pre-eIDAS statuses, see uri.StatusMatches
 //   - "status-logic:and": Use AND logic for status filters (all filters must match) instead of default OR logic
+//   - "cache-dir:/path": Reuse the certificates selected by an earlier run when neither the
+//     loaded TSLs nor the filters changed (entries are keyed by TSL content hashes and policy)
+//   - "policy-file:/path": Add the service types and statuses of a TSPServicePolicy stored as
+//     YAML or JSON (see etsi119612.LoadTSPServicePolicy); a policy without statuses selects
+//     granted services only
+//   - "require-ca": Exclude certificates that are not CA certificates whose KeyUsage allows
+//     certificate signing, such as leaf certificates listed as digital identities
+//   - "require-eku:NAME": Exclude certificates whose ExtendedKeyUsage does not allow NAME
+//     (any, serverAuth, clientAuth, codeSigning, emailProtection, timeStamping or OCSPSigning;
+//     can be provided multiple times); certificates without the extension are not restricted
+//   - "min-rsa-bits:N": Exclude certificates with RSA keys shorter than N bits, such as
+//     legacy 1024 or 2048 bit anchors
+//   - "allow-alg:LIST": Exclude certificates whose public key algorithm is not in the
+//     comma-separated LIST of RSA, ECDSA (any curve), ECDSA-P224, ECDSA-P256, ECDSA-P384,
+//     ECDSA-P521 and Ed25519
+//   - "issuer-contains:TEXT": Exclude certificates whose issuer DN does not contain TEXT,
+//     ignoring case (can be provided multiple times, any of them must match)
+//   - "at:TIME": Verify certificates at an RFC 3339 time such as 2025-01-01T00:00:00Z
+//     instead of the current time (sets CurrentTime of ctx.VerifyOptions)
+//   - "exclusion-report:/path": Write the certificates excluded by require-ca, require-eku and
+//     the key and issuer filters to a JSON file (see ExcludedCertificate)
+//   - "extra-roots:/path": Add the certificates of a PEM file, or of the *.pem, *.crt and
+//     *.cer files in a directory, to the pool as trust anchors with "local" provenance
+//     (can be provided multiple times, see LocalTrustAnchors)
+//   - "status-conflict:POLICY": What to do with certificates listed under services with
+//     different statuses in the processed TSLs, e.g. granted in one and withdrawn in another:
+//     "warn" (default) logs each conflict with all its listings, "exclude" also leaves the
+//     certificate out of the pool (see StatusConflicts)
+//   - "min-certs:N": Fail with ErrPoolSize if the pool has fewer than N certificates, for
+//     example because an upstream TSL was truncated, so that a drastically shrunken pool
+//     is never published
+//   - "max-certs:N": Fail with ErrPoolSize if the pool has more than N certificates
+//   - "pool-log:/path": Append the certificates entering and leaving the pool since the
+//     previous run to a hash-chained audit log (see PoolLog); a pool failing min-certs or
+//     max-certs is not recorded
+//   - "name:NAME": Also keep the pool under NAME, so that later steps can use it after
+//     further select steps built other pools, e.g. publish-oci with pool:NAME (see UsePool)
 //
 // Returns:
-//   - *Context: Updated context with the new certificate pool in ctx.CertPool
+//   - *Context: Updated context with the new certificate pool in ctx.CertPool and
+//     matching verification options in ctx.VerifyOptions
 //   - error: Non-nil if no TSLs are loaded or if certificate processing fails
 //
 // The created certificate pool is stored in the context's CertPool field and can be
 // used for certificate validation operations. Each certificate from valid trust services
 // is added as a trusted root certificate.
 //
+// ctx.VerifyOptions is a ready-made x509.VerifyOptions for the pool, used by
+// Context.Verify. Its KeyUsages are the require-eku usages or, without them, derived
+// from the service-type filters: timeStamping for TSA services, OCSPSigning for OCSP
+// responders and any usage otherwise.
+//
 // Note:
 //   - Requires at least one TSL to be loaded in the context
 //   - Invalid or nil TSLs in the stack are safely skipped
 //   - The previous certificate pool, if any, is replaced
 //   - The reference-depth parameter controls how deep in the TSL reference tree to process
 //   - Service type and status filters are combined with OR logic within each category and AND between categories
+//   - Every certificate excluded by require-ca, require-eku, min-rsa-bits, allow-alg or
+//     issuer-contains is logged as a warning and
+//     recorded for ExcludedCertificates; a pool restored from the cache records none
+//   - Status conflicts are detected among all services of the processed TSLs, regardless
+//     of the filters; certificates excluded for a conflict are recorded for
+//     ExcludedCertificates, and a pool restored from the cache records no conflicts
+//   - Extra roots are read on every run, also when the pool is restored from the cache,
+//     and are not subject to the filters, require-ca, require-eku or the key and issuer filters
 //
 // Example usage in pipeline configuration:
 //   - select  # Create cert pool from top TSL only, all service types
@@ -51,45 +108,250 @@ import (
 //   - select: ["service-type:http://uri.etsi.org/TrstSvc/Svctype/CA/QC"]  # Only qualified CA certificates
 //   - select: ["reference-depth:1", "service-type:http://uri.etsi.org/TrstSvc/Svctype/CA/QC", "status:http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/granted/"]  # Only granted qualified CA certificates up to depth 1
 //   - select: ["status:http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/granted/", "status:http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/recognized/", "status-logic:and"]  # Only certificates that match both status filters
+//   - select: ["reference-depth:1", "cache-dir:/var/cache/tsl"]  # Skip pool construction when nothing changed
+//   - select: ["reference-depth:1", "policy-file:/etc/tsl/qualified-ca.yaml"]  # Policy maintained in a reviewed file
+//   - select: ["service-type:http://uri.etsi.org/TrstSvc/Svctype/TSA/QTST", "at:2025-06-01T00:00:00Z"]  # Verify time stamps as of a date
+//   - select: ["require-ca", "require-eku:serverAuth", "exclusion-report:/var/log/tsl/excluded.json"]  # Only CA certificates usable for TLS
+//   - select: ["min-rsa-bits:3072", "allow-alg:RSA,ECDSA-P256,ECDSA-P384"]  # Exclude weak legacy anchors
+//   - select: ["extra-roots:/etc/tsl/private-cas"]  # Add private ecosystem CAs not yet in any TSL
+//   - select: ["reference-depth:1", "status-conflict:exclude"]  # Distrust certificates withdrawn anywhere
+//   - select: ["reference-depth:1", "min-certs:1000", "max-certs:2000"]  # Expect about 1400 certificates
+//   - select: ["reference-depth:1", "pool-log:/var/lib/tsl/pool.log"]  # Keep an audit trail of trust decisions
+//   - select: ["name:qc", "service-type:http://uri.etsi.org/TrstSvc/Svctype/CA/QC"]  # One of several pools published separately
 func SelectCertPool(pl *Pipeline, ctx *Context, args ...string) (*Context, error) {
-	// Check if we have TSLs either in the legacy stack or in the tree structure
-	if (ctx.TSLTrees == nil || ctx.TSLTrees.IsEmpty()) && (ctx.TSLs == nil || ctx.TSLs.IsEmpty()) {
-		return ctx, fmt.Errorf("no TSLs loaded")
+	opts, err := parseSelectArgs(pl, args)
+	if err != nil {
+		return ctx, err
 	}
+	return selectWithOptions(pl, ctx, opts)
+}
 
-	// Parse arguments
-	referenceDepth := 0 // Default: only root TSLs (no references)
-	serviceTypeFilters := []string{}
-	statusFilters := []string{}
-	useStatusAndLogic := false // Default: use OR logic for status filters
+// parseSelectArgs parses the arguments of the select step into SelectOptions.
+// Invalid reference depths are logged and ignored; a policy file that cannot
+// be loaded, an unknown extended key usage or key algorithm and an invalid time
+// are errors.
+func parseSelectArgs(pl *Pipeline, args []string) (SelectOptions, error) {
+	var opts SelectOptions // Default: only root TSLs (no references), OR logic for status filters
 
 	for _, arg := range args {
 		if arg == "include-referenced" {
 			// Legacy option: set depth to a large number to include all references
-			referenceDepth = 100
+			opts.ReferenceDepth = 100
 		} else if strings.HasPrefix(arg, "reference-depth:") {
 			depthStr := strings.TrimPrefix(arg, "reference-depth:")
 			if depth, err := strconv.Atoi(depthStr); err == nil && depth >= 0 {
-				referenceDepth = depth
+				opts.ReferenceDepth = depth
 			} else if err != nil {
 				pl.Logger.Warn("Invalid reference-depth value, using default",
 					logging.F("value", depthStr),
-					logging.F("default", referenceDepth))
+					logging.F("default", opts.ReferenceDepth))
 			}
 		} else if strings.HasPrefix(arg, "service-type:") {
 			serviceType := strings.TrimPrefix(arg, "service-type:")
 			if serviceType != "" {
-				serviceTypeFilters = append(serviceTypeFilters, serviceType)
+				opts.ServiceTypes = append(opts.ServiceTypes, serviceType)
 			}
 		} else if strings.HasPrefix(arg, "status:") {
 			status := strings.TrimPrefix(arg, "status:")
 			if status != "" {
-				statusFilters = append(statusFilters, status)
+				opts.Statuses = append(opts.Statuses, status)
 			}
 		} else if arg == "status-logic:and" {
-			useStatusAndLogic = true
+			opts.MatchAllStatuses = true
+		} else if strings.HasPrefix(arg, "cache-dir:") {
+			opts.CacheDir = strings.TrimPrefix(arg, "cache-dir:")
+		} else if strings.HasPrefix(arg, "policy-file:") {
+			path := strings.TrimPrefix(arg, "policy-file:")
+			if err := validation.ValidateFilePath(path); err != nil {
+				return opts, fmt.Errorf("invalid policy file path: %w", err)
+			}
+			policy, err := etsi119612.LoadTSPServicePolicy(path)
+			if err != nil {
+				return opts, err
+			}
+			opts.ServiceTypes = append(opts.ServiceTypes, policy.ServiceTypeIdentifier...)
+			opts.Statuses = append(opts.Statuses, policy.ServiceStatus...)
+		} else if arg == "require-ca" {
+			opts.RequireCA = true
+		} else if strings.HasPrefix(arg, "require-eku:") {
+			name := strings.TrimPrefix(arg, "require-eku:")
+			if _, err := validation.ParseExtKeyUsage(name); err != nil {
+				return opts, fmt.Errorf("%w: %v", ErrInvalidArguments, err)
+			}
+			opts.RequireEKU = append(opts.RequireEKU, name)
+		} else if strings.HasPrefix(arg, "min-rsa-bits:") {
+			value := strings.TrimPrefix(arg, "min-rsa-bits:")
+			bits, err := strconv.Atoi(value)
+			if err != nil || bits < 1 {
+				return opts, fmt.Errorf("%w: invalid min-rsa-bits: %q is not a positive integer", ErrInvalidArguments, value)
+			}
+			opts.MinRSABits = bits
+		} else if strings.HasPrefix(arg, "allow-alg:") {
+			algs, err := parseKeyAlgorithms(strings.TrimPrefix(arg, "allow-alg:"))
+			if err != nil {
+				return opts, fmt.Errorf("%w: %v", ErrInvalidArguments, err)
+			}
+			opts.AllowAlgorithms = append(opts.AllowAlgorithms, algs...)
+		} else if strings.HasPrefix(arg, "issuer-contains:") {
+			text := strings.TrimPrefix(arg, "issuer-contains:")
+			if text == "" {
+				return opts, fmt.Errorf("%w: empty issuer-contains", ErrInvalidArguments)
+			}
+			opts.IssuerContains = append(opts.IssuerContains, text)
+		} else if strings.HasPrefix(arg, "at:") {
+			at, err := time.Parse(time.RFC3339, strings.TrimPrefix(arg, "at:"))
+			if err != nil {
+				return opts, fmt.Errorf("%w: invalid at time: %v", ErrInvalidArguments, err)
+			}
+			opts.At = at
+		} else if strings.HasPrefix(arg, "exclusion-report:") {
+			path := strings.TrimPrefix(arg, "exclusion-report:")
+			if err := validation.ValidateFilePath(path); err != nil {
+				return opts, fmt.Errorf("invalid exclusion report path: %w", err)
+			}
+			opts.ExclusionReport = path
+		} else if strings.HasPrefix(arg, "extra-roots:") {
+			path := strings.TrimPrefix(arg, "extra-roots:")
+			if err := validation.ValidateFilePath(path); err != nil {
+				return opts, fmt.Errorf("invalid extra roots path: %w", err)
+			}
+			opts.ExtraRoots = append(opts.ExtraRoots, path)
+		} else if strings.HasPrefix(arg, "pool-log:") {
+			path := strings.TrimPrefix(arg, "pool-log:")
+			if err := validation.ValidateFilePath(path); err != nil {
+				return opts, fmt.Errorf("invalid pool log path: %w", err)
+			}
+			opts.PoolLog = path
+		} else if strings.HasPrefix(arg, "min-certs:") {
+			n, err := parsePoolSizeBound(strings.TrimPrefix(arg, "min-certs:"))
+			if err != nil {
+				return opts, fmt.Errorf("%w: invalid min-certs: %v", ErrInvalidArguments, err)
+			}
+			opts.MinCerts = n
+		} else if strings.HasPrefix(arg, "max-certs:") {
+			n, err := parsePoolSizeBound(strings.TrimPrefix(arg, "max-certs:"))
+			if err != nil {
+				return opts, fmt.Errorf("%w: invalid max-certs: %v", ErrInvalidArguments, err)
+			}
+			opts.MaxCerts = n
+		} else if strings.HasPrefix(arg, "name:") {
+			opts.Name = strings.TrimPrefix(arg, "name:")
+			if opts.Name == "" {
+				return opts, fmt.Errorf("%w: empty pool name", ErrInvalidArguments)
+			}
+		} else if strings.HasPrefix(arg, "status-conflict:") {
+			policy, err := parseStatusConflictPolicy(strings.TrimPrefix(arg, "status-conflict:"))
+			if err != nil {
+				return opts, fmt.Errorf("%w: %v", ErrInvalidArguments, err)
+			}
+			opts.StatusConflict = policy
 		}
 	}
+	if opts.MaxCerts > 0 && opts.MinCerts > opts.MaxCerts {
+		return opts, fmt.Errorf("%w: min-certs %d exceeds max-certs %d", ErrInvalidArguments, opts.MinCerts, opts.MaxCerts)
+	}
+	return opts, nil
+}
+
+// parsePoolSizeBound parses the value of a min-certs or max-certs option.
+func parsePoolSizeBound(value string) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%q is not a non-negative integer", value)
+	}
+	return n, nil
+}
+
+// checkPoolSize fails with ErrPoolSize if the number of certificates in the
+// pool is outside the MinCerts and MaxCerts bounds of opts.
+func checkPoolSize(pl *Pipeline, opts SelectOptions, count int) error {
+	var err error
+	switch {
+	case opts.MinCerts > 0 && count < opts.MinCerts:
+		err = fmt.Errorf("%w: %d certificates selected, expected at least %d", ErrPoolSize, count, opts.MinCerts)
+	case opts.MaxCerts > 0 && count > opts.MaxCerts:
+		err = fmt.Errorf("%w: %d certificates selected, expected at most %d", ErrPoolSize, count, opts.MaxCerts)
+	default:
+		return nil
+	}
+	if pl != nil && pl.Logger != nil {
+		pl.Logger.Error("Certificate pool size out of bounds",
+			logging.F("certificate_count", count),
+			logging.F("min_certs", opts.MinCerts),
+			logging.F("max_certs", opts.MaxCerts))
+	}
+	return err
+}
+
+// selectWithOptions implements SelectCertPool and Select for already parsed options.
+func selectWithOptions(pl *Pipeline, ctx *Context, opts SelectOptions) (*Context, error) {
+	// Check if we have TSLs either in the legacy stack or in the tree structure
+	if (ctx.TSLTrees == nil || ctx.TSLTrees.IsEmpty()) && (ctx.TSLs == nil || ctx.TSLs.IsEmpty()) {
+		return ctx, fmt.Errorf("no TSLs loaded")
+	}
+	constraints, err := certificateConstraints(opts)
+	if err != nil {
+		return ctx, err
+	}
+	conflictPolicy := StatusConflictWarn
+	if opts.StatusConflict != "" {
+		if conflictPolicy, err = parseStatusConflictPolicy(opts.StatusConflict); err != nil {
+			return ctx, fmt.Errorf("%w: %v", ErrInvalidArguments, err)
+		}
+	}
+	recordExcludedCertificates(ctx, nil)
+	recordStatusConflicts(ctx, nil)
+	recordSelectedPool(ctx, opts, nil)
+
+	// Restore the pool from the cache if neither the TSLs nor the policy changed
+	var cacheKey string
+	var selected []*x509.Certificate
+	if opts.CacheDir != "" {
+		key, err := selectCacheKey(ctx, opts)
+		if err != nil {
+			return ctx, err
+		}
+		certs, ok, err := loadSelectCache(opts.CacheDir, key)
+		if err != nil && pl != nil && pl.Logger != nil {
+			pl.Logger.Warn("Ignoring unreadable select cache entry",
+				logging.F("dir", opts.CacheDir),
+				logging.F("error", err))
+		}
+		if ok && err == nil {
+			ctx.InitCertPool()
+			for _, cert := range certs {
+				ctx.CertPool.AddCert(cert)
+			}
+			extra, err := addExtraRoots(pl, ctx, opts, certs)
+			if err != nil {
+				return ctx, err
+			}
+			recordSelectedPool(ctx, opts, certs)
+			recordCertCount(ctx, len(certs)+extra)
+			ctx.VerifyOptions = newVerifyOptions(ctx, opts)
+			if err := checkPoolSize(pl, opts, len(certs)+extra); err != nil {
+				return ctx, err
+			}
+			if err := appendPoolLog(pl, ctx, opts); err != nil {
+				return ctx, err
+			}
+			if opts.Name != "" {
+				recordNamedPool(ctx, opts.Name)
+			}
+			if pl != nil && pl.Logger != nil {
+				pl.Logger.Info("Certificate pool restored from select cache",
+					logging.F("certificate_count", len(certs)),
+					logging.F("key", key))
+			}
+			return ctx, nil
+		}
+		cacheKey = key
+	}
+
+	referenceDepth := opts.ReferenceDepth
+	serviceTypeFilters := opts.ServiceTypes
+	statusFilters := opts.Statuses
+	useStatusAndLogic := opts.MatchAllStatuses
 
 	// Initialize the certificate pool
 	ctx.InitCertPool()
@@ -98,8 +360,14 @@ func SelectCertPool(pl *Pipeline, ctx *Context, args ...string) (*Context, error
 	certCount := 0
 	tslCount := 0
 
+	// Certificates failing the require-ca and require-eku checks
+	var excluded []ExcludedCertificate
+
+	// Certificates listed under conflicting statuses, by fingerprint, if they are excluded
+	var conflicting map[string]StatusConflict
+
 	// Create a certificate processing function that applies filters
-	processCertificate := func(tsp *etsi119612.TSPType, svc *etsi119612.TSPServiceType, cert *x509.Certificate) {
+	processCertificate := func(tsl *etsi119612.TSL, tsp *etsi119612.TSPType, svc *etsi119612.TSPServiceType, cert *x509.Certificate) {
 		// Apply service type filter if specified
 		if len(serviceTypeFilters) > 0 {
 			serviceTypeMatch := false
@@ -118,11 +386,12 @@ func SelectCertPool(pl *Pipeline, ctx *Context, args ...string) (*Context, error
 		// Apply status filter if specified
 		if len(statusFilters) > 0 {
 			status := svc.TslServiceInformation.TslServiceStatus
+			serviceType := svc.TslServiceInformation.TslServiceTypeIdentifier
 
 			if useStatusAndLogic {
 				// AND logic: certificate must match ALL status filters
 				for _, filter := range statusFilters {
-					if status != filter {
+					if !uri.StatusMatches(status, serviceType, filter) {
 						// If any filter doesn't match, skip this certificate
 						return
 					}
@@ -131,7 +400,7 @@ func SelectCertPool(pl *Pipeline, ctx *Context, args ...string) (*Context, error
 				// OR logic (default): certificate must match ANY status filter
 				statusMatch := false
 				for _, filter := range statusFilters {
-					if status == filter {
+					if uri.StatusMatches(status, serviceType, filter) {
 						statusMatch = true
 						break
 					}
@@ -142,25 +411,46 @@ func SelectCertPool(pl *Pipeline, ctx *Context, args ...string) (*Context, error
 			}
 		}
 
+		// Exclude certificates listed under conflicting statuses
+		if conflicting != nil {
+			digest := sha256.Sum256(cert.Raw)
+			if conflict, ok := conflicting[hex.EncodeToString(digest[:])]; ok {
+				excluded = append(excluded, newExcludedCertificate(tsl, tsp, svc, cert, conflictError(conflict)))
+				return
+			}
+		}
+
+		// Exclude certificates that are not fit to be trust anchors
+		if constraints != nil {
+			if err := constraints(cert); err != nil {
+				entry := newExcludedCertificate(tsl, tsp, svc, cert, err)
+				excluded = append(excluded, entry)
+				if pl != nil && pl.Logger != nil {
+					pl.Logger.Warn("Excluded certificate from pool",
+						logging.F("tsl", entry.TSL),
+						logging.F("territory", entry.Territory),
+						logging.F("provider", entry.Provider),
+						logging.F("service", entry.Service),
+						logging.F("subject", entry.Subject),
+						logging.F("sha256", entry.SHA256),
+						logging.F("reason", entry.Reason))
+				}
+				return
+			}
+		}
+
 		// Add the certificate to the pool
 		ctx.CertPool.AddCert(cert)
 		certCount++
+		selected = append(selected, cert)
 	}
 
-	// Define a function to process a TSL and extract certificates
+	// Define a function to collect the TSLs to extract certificates from
+	var tsls []*etsi119612.TSL
 	processTSL := func(tsl *etsi119612.TSL) {
-		if tsl == nil {
-			return
+		if tsl != nil {
+			tsls = append(tsls, tsl)
 		}
-
-		tslCount++
-
-		// Process the TSL
-		tsl.WithTrustServices(func(tsp *etsi119612.TSPType, svc *etsi119612.TSPServiceType) {
-			svc.WithCertificates(func(cert *x509.Certificate) {
-				processCertificate(tsp, svc, cert)
-			})
-		})
 	}
 
 	// Define a function to process a tree with a limited depth
@@ -192,8 +482,7 @@ func SelectCertPool(pl *Pipeline, ctx *Context, args ...string) (*Context, error
 	// Check if we should use the legacy stack
 	if ctx.TSLs != nil && !ctx.TSLs.IsEmpty() {
 		// Process TSLs from the legacy stack
-		tsls := ctx.TSLs.ToSlice()
-		for i, tsl := range tsls {
+		for i, tsl := range ctx.TSLs.ToSlice() {
 			if tsl == nil {
 				continue
 			}
@@ -224,16 +513,76 @@ func SelectCertPool(pl *Pipeline, ctx *Context, args ...string) (*Context, error
 		}
 	}
 
+	// Detect certificates listed under conflicting statuses before selecting any
+	conflicts := findStatusConflicts(tsls)
+	logStatusConflicts(pl, conflicts, conflictPolicy)
+	recordStatusConflicts(ctx, conflicts)
+	if conflictPolicy == StatusConflictExclude && len(conflicts) > 0 {
+		conflicting = make(map[string]StatusConflict, len(conflicts))
+		for _, conflict := range conflicts {
+			conflicting[conflict.SHA256] = conflict
+		}
+	}
+
+	// Extract the certificates of the collected TSLs
+	for _, tsl := range tsls {
+		tslCount++
+		tsl.WithTrustServices(func(tsp *etsi119612.TSPType, svc *etsi119612.TSPServiceType) {
+			svc.WithCertificates(func(cert *x509.Certificate) {
+				processCertificate(tsl, tsp, svc, cert)
+			})
+		})
+	}
+
+	extra, err := addExtraRoots(pl, ctx, opts, selected)
+	if err != nil {
+		return ctx, err
+	}
+	recordSelectedPool(ctx, opts, selected)
+	recordCertCount(ctx, certCount+extra)
+	recordExcludedCertificates(ctx, excluded)
+	ctx.VerifyOptions = newVerifyOptions(ctx, opts)
+	if opts.ExclusionReport != "" {
+		if pl != nil && pl.ReadOnly {
+			pl.Logger.Info("Read-only mode: not writing exclusion report",
+				logging.F("would_write", opts.ExclusionReport))
+		} else if err := writeExclusionReport(opts.ExclusionReport, excluded); err != nil {
+			return ctx, err
+		}
+	}
+	if err := checkPoolSize(pl, opts, certCount+extra); err != nil {
+		return ctx, err
+	}
+	if err := appendPoolLog(pl, ctx, opts); err != nil {
+		return ctx, err
+	}
+	if opts.Name != "" {
+		recordNamedPool(ctx, opts.Name)
+	}
+
 	// Log summary information
 	if pl != nil && pl.Logger != nil {
 		pl.Logger.Info("Certificate pool created",
 			logging.F("tsl_count", tslCount),
 			logging.F("certificate_count", certCount),
+			logging.F("excluded_count", len(excluded)),
+			logging.F("status_conflicts", len(conflicts)),
 			logging.F("reference_depth", referenceDepth),
 			logging.F("service_type_filters", len(serviceTypeFilters)),
 			logging.F("status_filters", len(statusFilters)))
 	}
 
+	if cacheKey != "" && pl != nil && pl.ReadOnly {
+		pl.Logger.Info("Read-only mode: not updating select cache",
+			logging.F("would_write", opts.CacheDir))
+	} else if cacheKey != "" {
+		if err := storeSelectCache(opts.CacheDir, cacheKey, selected); err != nil && pl != nil && pl.Logger != nil {
+			pl.Logger.Warn("Failed to update select cache",
+				logging.F("dir", opts.CacheDir),
+				logging.F("error", err))
+		}
+	}
+
 	if pl != nil && pl.Logger != nil {
 		if len(serviceTypeFilters) > 0 {
 			pl.Logger.Debug("Service type filters applied",
@@ -0,0 +1,305 @@
+package pipeline
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirosfoundation/g119612/pkg/etsi119612"
+	"github.com/sirosfoundation/g119612/pkg/logging"
+	"github.com/sirosfoundation/g119612/xsd2024"
+)
+
+// SchemaIssue is a problem found by the validate-schema step.
+type SchemaIssue struct {
+	TSL   etsi119612.TSLIdentity // The TSL with the problem
+	Issue string                 // What is wrong, e.g. "TSLSequenceNumber 0 is not positive"
+}
+
+func (i SchemaIssue) String() string {
+	return fmt.Sprintf("%s: %s", i.TSL, i.Issue)
+}
+
+// validateSchemaOptions are the parsed arguments of the validate-schema step.
+type validateSchemaOptions struct {
+	warn   bool
+	engine string // auto, native or xmllint
+}
+
+// xmllintCommand is the command run by the xmllint engine of the
+// validate-schema step.
+var xmllintCommand = "xmllint"
+
+// schemaValidator checks a TSL document against the XML schema and returns
+// the problems found.
+type schemaValidator interface {
+	validate(data []byte) ([]string, error)
+}
+
+// nativeSchemaValidator checks documents with etsi119612.ValidateStrict,
+// against the content model of the types generated from the schema, without
+// external tools.
+type nativeSchemaValidator struct{}
+
+func (nativeSchemaValidator) validate(data []byte) ([]string, error) {
+	err := etsi119612.ValidateStrict(data)
+	var strictErr *etsi119612.StrictValidationError
+	switch {
+	case err == nil:
+		return nil, nil
+	case errors.As(err, &strictErr):
+		return strictErr.Issues, nil
+	}
+	return []string{err.Error()}, nil
+}
+
+// xmllintSchemaValidator validates documents against the XSD of package
+// xsd2024 with xmllint, with network access disabled.
+type xmllintSchemaValidator struct {
+	dir string // Temporary directory holding the schemas
+}
+
+// newXMLLintSchemaValidator writes the schemas to a temporary directory,
+// removed by close.
+func newXMLLintSchemaValidator() (*xmllintSchemaValidator, error) {
+	dir, err := os.MkdirTemp("", "tsl-xsd-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create schema directory: %w", err)
+	}
+	if err := xsd2024.WriteDir(dir); err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("failed to write schemas: %w", err)
+	}
+	return &xmllintSchemaValidator{dir: dir}, nil
+}
+
+func (v *xmllintSchemaValidator) close() error {
+	return os.RemoveAll(v.dir)
+}
+
+// validate runs xmllint on the document. Schema violations and syntax errors
+// are returned as problems, "line N: message"; other failures of xmllint,
+// such as a schema that does not compile, are errors.
+func (v *xmllintSchemaValidator) validate(data []byte) ([]string, error) {
+	path := filepath.Join(v.dir, "tsl.xml")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return nil, fmt.Errorf("failed to write TSL for xmllint: %w", err)
+	}
+	defer os.Remove(path)
+
+	cmd := exec.Command(xmllintCommand, "--nonet", "--noout", "--schema", filepath.Join(v.dir, xsd2024.TSLSchema), path)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if err == nil {
+		return nil, nil
+	}
+	// xmllint exits with 3 for documents not valid against the schema and
+	// with 4 for documents that cannot be parsed
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || (exitErr.ExitCode() != 3 && exitErr.ExitCode() != 4) {
+		return nil, fmt.Errorf("xmllint error: %w - %s", err, truncateOutput(stderr.Bytes()))
+	}
+
+	var issues []string
+	for _, line := range strings.Split(stderr.String(), "\n") {
+		rest, ok := strings.CutPrefix(line, path+":")
+		if !ok {
+			continue
+		}
+		number, message, ok := strings.Cut(rest, ": ")
+		if !ok {
+			continue
+		}
+		if _, text, ok := strings.Cut(message, " : "); ok {
+			message = text
+		}
+		issues = append(issues, fmt.Sprintf("line %s: %s", number, strings.TrimSpace(message)))
+	}
+	if len(issues) == 0 {
+		issues = append(issues, truncateOutput(stderr.Bytes()))
+	}
+	return issues, nil
+}
+
+// newSchemaValidator returns the validator of the named engine along with its
+// name. For "auto" or an empty name it is xmllint if the command is installed
+// and the native validator otherwise. The returned function releases the
+// validator.
+func newSchemaValidator(name string) (string, schemaValidator, func(), error) {
+	if name == "" || name == "auto" {
+		name = "native"
+		if _, err := exec.LookPath(xmllintCommand); err == nil {
+			name = "xmllint"
+		}
+	}
+	switch name {
+	case "native":
+		return name, nativeSchemaValidator{}, func() {}, nil
+	case "xmllint":
+		v, err := newXMLLintSchemaValidator()
+		if err != nil {
+			return "", nil, nil, err
+		}
+		return name, v, func() { v.close() }, nil
+	}
+	return "", nil, nil, fmt.Errorf("%w: unknown schema engine %q (expected auto, native, xmllint)", ErrInvalidArguments, name)
+}
+
+// ValidateSchema is a pipeline step that checks the TSLs in the context
+// against the XML schema of ETSI TS 119 612 and against the rules for the
+// scheme information the schema does not express (see
+// etsi119612.ValidateSchemeRules), such as a positive sequence number. It
+// catches malformed lists before they are transformed or published.
+//
+// The xmllint engine validates against the XSD in package xsd2024, the
+// version of the schema the etsi119612 types are generated from. The native
+// engine needs no external tools: it checks the document against the content
+// model of the generated types (see etsi119612.ValidateStrict), which covers
+// the mandatory elements such as NextUpdate, unexpected elements and malformed
+// dates but not every facet of the XSD. By default xmllint is used if it is
+// installed.
+//
+// TSLs are checked as they were fetched, referenced TSLs included, or as
+// transform serializes them if they were not parsed from a document, such as
+// generated ones. Every problem is logged as a warning with the TSL it was
+// found in.
+//
+// Parameters:
+//   - pl: Pipeline instance managing the step execution
+//   - ctx: Pipeline context whose TSLs are checked
+//   - args: Options in "key:value" form:
+//   - on-invalid:fail|warn: Fail the pipeline (default) or only log the problems
+//   - engine:auto|native|xmllint: How the schema is checked (default auto)
+//
+// Returns:
+//   - The unchanged context
+//   - An error if a TSL is invalid and on-invalid is fail
+//
+// Example usage in pipeline YAML:
+//
+//   - load:
+//   - https://ec.europa.eu/tools/lotl/eu-lotl.xml
+//   - validate-schema:
+//   - on-invalid:warn
+func ValidateSchema(pl *Pipeline, ctx *Context, args ...string) (*Context, error) {
+	opts, err := parseValidateSchemaArgs(args)
+	if err != nil {
+		return ctx, err
+	}
+	// The legacy stack repeats the TSLs of the trees, check each once
+	var tsls []*etsi119612.TSL
+	seen := make(map[*etsi119612.TSL]bool)
+	for _, tsl := range contextTSLs(ctx) {
+		if tsl != nil && !seen[tsl] {
+			seen[tsl] = true
+			tsls = append(tsls, tsl)
+		}
+	}
+	if len(tsls) == 0 {
+		return ctx, fmt.Errorf("no TSLs to validate")
+	}
+	engine, validator, release, err := newSchemaValidator(opts.engine)
+	if err != nil {
+		return ctx, err
+	}
+	defer release()
+
+	var issues []SchemaIssue
+	invalid := 0
+	for _, tsl := range tsls {
+		found, err := schemaIssues(tsl, validator)
+		if err != nil {
+			return ctx, err
+		}
+		if len(found) > 0 {
+			invalid++
+		}
+		for _, issue := range found {
+			issues = append(issues, SchemaIssue{TSL: tsl.Identity(), Issue: issue})
+		}
+	}
+
+	for _, issue := range issues {
+		pl.Logger.Warn("Invalid TSL",
+			logging.F("tsl", issue.TSL.String()),
+			logging.F("issue", issue.Issue))
+	}
+	pl.Logger.Info("Validated TSLs",
+		logging.F("engine", engine),
+		logging.F("tsl_count", len(tsls)),
+		logging.F("invalid", invalid),
+		logging.F("issues", len(issues)))
+
+	if len(issues) > 0 && !opts.warn {
+		return ctx, fmt.Errorf("%w: %d of %d TSLs, first %s", ErrSchemaValidation, invalid, len(tsls), issues[0])
+	}
+	return ctx, nil
+}
+
+// schemaIssues returns the problems found in a TSL by the schema validator
+// and the scheme rules.
+func schemaIssues(tsl *etsi119612.TSL, validator schemaValidator) ([]string, error) {
+	data, err := tsl.RawXML()
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		if data, err = marshalTransformInput(tsl); err != nil {
+			return nil, err
+		}
+	}
+
+	issues, err := validator.validate(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := etsi119612.ValidateSchemeRules(tsl); err != nil {
+		var strictErr *etsi119612.StrictValidationError
+		if errors.As(err, &strictErr) {
+			issues = append(issues, strictErr.Issues...)
+		} else {
+			issues = append(issues, err.Error())
+		}
+	}
+	return issues, nil
+}
+
+// parseValidateSchemaArgs parses the arguments of the validate-schema step.
+func parseValidateSchemaArgs(args []string) (validateSchemaOptions, error) {
+	var opts validateSchemaOptions
+	for _, arg := range args {
+		if engine, ok := strings.CutPrefix(arg, "engine:"); ok {
+			switch engine {
+			case "auto", "native", "xmllint":
+				opts.engine = engine
+			default:
+				return opts, fmt.Errorf("%w: unknown schema engine %q (expected auto, native, xmllint)", ErrInvalidArguments, engine)
+			}
+			continue
+		}
+		value, ok := strings.CutPrefix(arg, "on-invalid:")
+		if !ok {
+			return opts, fmt.Errorf("%w: unexpected argument %q", ErrInvalidArguments, arg)
+		}
+		switch value {
+		case "fail":
+			opts.warn = false
+		case "warn":
+			opts.warn = true
+		default:
+			return opts, fmt.Errorf("%w: invalid on-invalid value %q (expected fail or warn)", ErrInvalidArguments, value)
+		}
+	}
+	return opts, nil
+}
+
+// validateValidateSchemaArgs is the ArgsValidator of the validate-schema step.
+func validateValidateSchemaArgs(args ...string) error {
+	_, err := parseValidateSchemaArgs(args)
+	return err
+}
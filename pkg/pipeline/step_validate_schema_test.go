@@ -0,0 +1,131 @@
+package pipeline
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirosfoundation/g119612/pkg/etsi119612"
+	"github.com/sirosfoundation/g119612/pkg/logging"
+	"github.com/sirosfoundation/g119612/pkg/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateSchema(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "etsi119612", "testdata", "SE-TL.xml"))
+	require.NoError(t, err)
+	valid, err := etsi119612.ParseTSL(data, "SE-TL.xml", etsi119612.DefaultTSLFetchOptions)
+	require.NoError(t, err)
+	pl := &Pipeline{Logger: logging.SilentLogger()}
+
+	ctx := NewContext()
+	ctx.AddTSL(valid)
+	_, err = ValidateSchema(pl, ctx)
+	assert.NoError(t, err)
+
+	// A generated TSL has no document and lacks most mandatory fields
+	invalid := generateTSL("Test Service", "http://uri.etsi.org/TrstSvc/Svctype/CA/QC", nil)
+	issues, err := schemaIssues(invalid, nativeSchemaValidator{})
+	require.NoError(t, err)
+	assert.Contains(t, issues, "TSLSequenceNumber 0 is not positive")
+	assert.Contains(t, issues, "missing mandatory element <NextUpdate> in TrustServiceStatusList/SchemeInformation")
+
+	ctx.AddTSL(invalid)
+	_, err = ValidateSchema(pl, ctx)
+	assert.ErrorIs(t, err, ErrSchemaValidation)
+	assert.ErrorContains(t, err, "1 of 2 TSLs")
+	assert.ErrorContains(t, err, `"Test Operator"`)
+
+	_, err = ValidateSchema(pl, ctx, "on-invalid:warn")
+	assert.NoError(t, err)
+
+	_, err = ValidateSchema(pl, NewContext())
+	assert.ErrorContains(t, err, "no TSLs to validate")
+}
+
+func TestValidateSchema_LegacyStack(t *testing.T) {
+	pl := &Pipeline{Logger: logging.SilentLogger()}
+	ctx := NewContext()
+	ctx.TSLs = utils.NewStack[*etsi119612.TSL]()
+	ctx.TSLs.Push(generateTSL("Test Service", "http://uri.etsi.org/TrstSvc/Svctype/CA/QC", nil))
+
+	_, err := ValidateSchema(pl, ctx, "engine:native")
+	assert.ErrorIs(t, err, ErrSchemaValidation)
+	assert.ErrorContains(t, err, "1 of 1 TSLs")
+}
+
+func TestValidateSchema_XMLLint(t *testing.T) {
+	if _, err := exec.LookPath(xmllintCommand); err != nil {
+		t.Skip("xmllint is not installed")
+	}
+	name, validator, release, err := newSchemaValidator("xmllint")
+	require.NoError(t, err)
+	defer release()
+	assert.Equal(t, "xmllint", name)
+
+	valid, err := os.ReadFile(filepath.Join("..", "etsi119612", "testdata", "SE-TL.xml"))
+	require.NoError(t, err)
+	issues, err := validator.validate(valid)
+	require.NoError(t, err)
+	assert.Empty(t, issues)
+
+	// The XSD checks facets the generated types do not
+	issues, err = validator.validate([]byte(`<TrustServiceStatusList xmlns="http://uri.etsi.org/02231/v2#" TSLTag="http://uri.etsi.org/19612/TSLTag">
+<SchemeInformation><TSLVersionIdentifier>5</TSLVersionIdentifier><TSLSequenceNumber>0</TSLSequenceNumber></SchemeInformation>
+</TrustServiceStatusList>`))
+	require.NoError(t, err)
+	require.NotEmpty(t, issues)
+	assert.Contains(t, issues[0], "line 2: Element '{http://uri.etsi.org/02231/v2#}TSLSequenceNumber'")
+
+	issues, err = validator.validate([]byte("<TrustServiceStatusList><Other></TrustServiceStatusList>"))
+	require.NoError(t, err)
+	require.NotEmpty(t, issues)
+	assert.Contains(t, issues[0], "line 1: Opening and ending tag mismatch")
+}
+
+func TestSchemaEngineAuto(t *testing.T) {
+	saved := xmllintCommand
+	defer func() { xmllintCommand = saved }()
+
+	xmllintCommand = filepath.Join(t.TempDir(), "missing-xmllint")
+	name, validator, release, err := newSchemaValidator("auto")
+	require.NoError(t, err)
+	release()
+	assert.Equal(t, "native", name)
+	assert.Equal(t, nativeSchemaValidator{}, validator)
+
+	fake := filepath.Join(t.TempDir(), "xmllint")
+	require.NoError(t, os.WriteFile(fake, []byte("#!/bin/sh\nexit 5\n"), 0755))
+	xmllintCommand = fake
+	name, validator, release, err = newSchemaValidator("")
+	require.NoError(t, err)
+	defer release()
+	assert.Equal(t, "xmllint", name)
+	_, err = validator.validate([]byte("<TrustServiceStatusList/>"))
+	assert.ErrorContains(t, err, "xmllint error")
+
+	_, _, _, err = newSchemaValidator("saxon")
+	assert.ErrorIs(t, err, ErrInvalidArguments)
+}
+
+func TestValidateSchema_NotWellFormed(t *testing.T) {
+	tsl, err := etsi119612.ParseTSL([]byte(`<TrustServiceStatusList xmlns="http://uri.etsi.org/02231/v2#"><SchemeInformation><TSLSequenceNumber>1</TSLSequenceNumber></SchemeInformation></TrustServiceStatusList>`),
+		"short.xml", etsi119612.DefaultTSLFetchOptions)
+	require.NoError(t, err)
+	tsl.Raw = append(tsl.Raw[:len(tsl.Raw)-len("</TrustServiceStatusList>")], "</Other>"...)
+
+	issues, err := schemaIssues(tsl, nativeSchemaValidator{})
+	require.NoError(t, err)
+	assert.Contains(t, issues[0], "XML syntax error")
+}
+
+func TestValidateSchemaArgs(t *testing.T) {
+	assert.NoError(t, validateValidateSchemaArgs())
+	assert.NoError(t, validateValidateSchemaArgs("on-invalid:fail", "on-invalid:warn"))
+	assert.NoError(t, validateValidateSchemaArgs("engine:native", "engine:xmllint", "engine:auto"))
+	for _, args := range [][]string{{"on-invalid:ignore"}, {"strict"}, {"engine:saxon"}} {
+		assert.ErrorIs(t, validateValidateSchemaArgs(args...), ErrInvalidArguments, args)
+	}
+}
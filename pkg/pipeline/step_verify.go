@@ -0,0 +1,320 @@
+package pipeline
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirosfoundation/g119612/pkg/logging"
+	"github.com/sirosfoundation/g119612/pkg/validation"
+)
+
+// verificationReportKey is the context data key of the report of the last
+// verify step.
+const verificationReportKey = "verification-report"
+
+// CertificateVerification is the result of verifying one certificate in the
+// verify step.
+type CertificateVerification struct {
+	Name     string               `json:"name"`               // File or cert:N argument the certificate came from
+	Subject  string               `json:"subject"`            // Subject of the certificate
+	SHA256   string               `json:"sha256"`             // Hex SHA-256 fingerprint of the certificate
+	Verified bool                 `json:"verified"`           // Whether the certificate chains to the pool
+	Chain    []string             `json:"chain,omitempty"`    // Subjects of the first chain found, leaf first
+	Anchors  []CertificateListing `json:"anchors,omitempty"`  // Services listing the anchor of the chain
+	Error    string               `json:"error,omitempty"`    // Why verification failed
+	Evidence string               `json:"evidence,omitempty"` // Evidence bundle written with evidence:DIR
+
+	chain []*x509.Certificate // The first chain found
+}
+
+// VerificationReport is the report of the verify step: the result of
+// verifying each certificate against the pool.
+type VerificationReport struct {
+	GeneratedAt  time.Time                 `json:"generated_at"`
+	Pool         string                    `json:"pool,omitempty"` // Name of the pool, "" for the pool of the last select step
+	Passed       int                       `json:"passed"`
+	Failed       int                       `json:"failed"`
+	Certificates []CertificateVerification `json:"certificates"`
+}
+
+// VerificationResults returns the report of the last verify step run on ctx,
+// nil if there was none.
+func VerificationResults(ctx *Context) *VerificationReport {
+	if ctx == nil {
+		return nil
+	}
+	report, _ := dataValue[*VerificationReport](ctx, verificationReportKey)
+	return report
+}
+
+// NewVerificationReport verifies certs against the pool of ctx (see
+// Context.Verify), using the intermediates of each, and reports the results
+// in the order of certs.
+func NewVerificationReport(ctx *Context, certs []MonitoredCertificate) *VerificationReport {
+	report := &VerificationReport{GeneratedAt: ctx.Now(), Certificates: []CertificateVerification{}}
+	for _, cert := range certs {
+		digest := sha256.Sum256(cert.Leaf.Raw)
+		result := CertificateVerification{
+			Name:    cert.Name,
+			Subject: cert.Leaf.Subject.String(),
+			SHA256:  hex.EncodeToString(digest[:]),
+		}
+		chains, err := ctx.Verify(cert.Leaf, cert.Intermediates...)
+		if err != nil {
+			result.Error = err.Error()
+			report.Failed++
+		} else {
+			result.Verified = true
+			result.chain = chains[0]
+			for _, c := range chains[0] {
+				result.Chain = append(result.Chain, c.Subject.String())
+			}
+			result.Anchors = ctx.Listings(chains[0][len(chains[0])-1])
+			report.Passed++
+		}
+		report.Certificates = append(report.Certificates, result)
+	}
+	return report
+}
+
+// verifyOptions are the parsed arguments of the verify step.
+type verifyOptions struct {
+	paths    []string
+	certs    []MonitoredCertificate
+	pool     string
+	report   string
+	evidence string
+	warn     bool
+}
+
+// VerifyCertificates is a pipeline step that verifies certificates against
+// the pool built by a select step and reports for each whether it chains to
+// it, so that a batch job fails when certificates that are expected to be
+// trusted no longer are, for example because their CA was withdrawn.
+//
+// Each PEM file, or each *.pem, *.crt and *.cer file of a directory, holds a
+// certificate to verify followed by its intermediates, as for the monitor
+// command. Certificates can also be given inline as base64 DER. The report is
+// logged, available to later steps and applications through
+// VerificationResults, and written as JSON with report:FILE. With
+// evidence:DIR, an evidence bundle (see EvidenceBundle) is written for every
+// certificate that verifies, as DIR/<sha256>.zip after the SHA-256 of the
+// certificate, to keep a record of why it was trusted.
+//
+// Parameters:
+//   - pl: Pipeline instance managing the step execution
+//   - ctx: Pipeline context with the certificate pool
+//   - args: PEM files or directories of PEM files, and options:
+//   - cert:BASE64: A certificate as base64 DER (repeatable)
+//   - pool:NAME: Verify against the pool selected with name:NAME instead of
+//     the pool of the last select step
+//   - report:FILE: Write the report as JSON to FILE
+//   - evidence:DIR: Write an evidence bundle for each certificate that
+//     verifies to DIR
+//   - on-fail:fail|warn: Fail the pipeline (default) or only log the
+//     certificates that do not verify
+//
+// Returns:
+//   - The context with the report
+//   - An error if a certificate does not verify and on-fail is fail, or if
+//     there is no pool
+//
+// Example usage in pipeline YAML:
+//
+//   - select:
+//   - name:qc
+//   - service-type:http://uri.etsi.org/TrstSvc/Svctype/CA/QC
+//   - verify:
+//   - /etc/tsl/expected-certs/
+//   - pool:qc
+//   - report:/var/lib/tsl/verify.json
+//   - evidence:/var/lib/tsl/evidence
+func VerifyCertificates(pl *Pipeline, ctx *Context, args ...string) (*Context, error) {
+	opts, err := parseVerifyArgs(args)
+	if err != nil {
+		return ctx, err
+	}
+	certs := opts.certs
+	for _, path := range opts.paths {
+		loaded, err := LoadMonitoredCertificates(path)
+		if err != nil {
+			return ctx, err
+		}
+		certs = append(certs, loaded...)
+	}
+
+	poolCtx := ctx
+	if opts.pool != "" {
+		if poolCtx, err = UsePool(ctx, opts.pool); err != nil {
+			return ctx, err
+		}
+	}
+	if poolCtx.CertPool == nil {
+		return ctx, fmt.Errorf("%w: verify needs a select step before it", ErrNoCertPool)
+	}
+
+	report := NewVerificationReport(poolCtx, certs)
+	report.Pool = opts.pool
+	for _, result := range report.Certificates {
+		fields := []logging.Field{
+			logging.F("certificate", result.Name),
+			logging.F("subject", result.Subject),
+			logging.F("sha256", result.SHA256),
+		}
+		if result.Verified {
+			pl.Logger.Debug("Certificate verifies", fields...)
+		} else {
+			pl.Logger.Warn("Certificate does not verify", append(fields, logging.F("error", result.Error))...)
+		}
+	}
+	pl.Logger.Info("Verified certificates",
+		logging.F("pool", opts.pool),
+		logging.F("passed", report.Passed),
+		logging.F("failed", report.Failed))
+	if opts.evidence != "" {
+		if err := writeEvidenceBundles(pl, poolCtx, opts, report); err != nil {
+			return ctx, err
+		}
+	}
+	ctx.SetData(verificationReportKey, report)
+
+	if opts.report != "" {
+		if err := writeVerificationReport(pl, opts.report, report); err != nil {
+			return ctx, err
+		}
+	}
+	if report.Failed > 0 && !opts.warn {
+		for _, result := range report.Certificates {
+			if !result.Verified {
+				return ctx, fmt.Errorf("%w: %d of %d certificates, first %s (%s): %s",
+					ErrVerificationFailed, report.Failed, len(report.Certificates), result.Name, result.Subject, result.Error)
+			}
+		}
+	}
+	return ctx, nil
+}
+
+// writeVerificationReport writes report as JSON to file, unless the pipeline
+// is read-only.
+func writeVerificationReport(pl *Pipeline, file string, report *VerificationReport) error {
+	if pl.ReadOnly {
+		pl.Logger.Info("Read-only mode: not writing verification report",
+			logging.F("would_write", file))
+		return nil
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(file, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write verification report: %w", err)
+	}
+	return nil
+}
+
+// writeEvidenceBundles writes the evidence bundle of every verified
+// certificate of report to the evidence directory of opts, unless the
+// pipeline is read-only, and records the files in the report.
+func writeEvidenceBundles(pl *Pipeline, ctx *Context, opts verifyOptions, report *VerificationReport) error {
+	for i := range report.Certificates {
+		result := &report.Certificates[i]
+		if !result.Verified {
+			continue
+		}
+		file := filepath.Join(opts.evidence, result.SHA256+".zip")
+		if pl.ReadOnly {
+			pl.Logger.Info("Read-only mode: not writing evidence bundle",
+				logging.F("would_write", file))
+			continue
+		}
+		bundle, err := NewEvidenceBundle(ctx, result.chain)
+		if err != nil {
+			return err
+		}
+		bundle.Pool = opts.pool
+		var buf bytes.Buffer
+		if err := bundle.WriteZip(&buf); err != nil {
+			return err
+		}
+		if err := os.MkdirAll(opts.evidence, DefaultPublishDirMode); err != nil {
+			return fmt.Errorf("failed to create evidence directory: %w", err)
+		}
+		if err := writeFileAtomic(file, buf.Bytes(), DefaultPublishFileMode); err != nil {
+			return fmt.Errorf("failed to write evidence bundle: %w", err)
+		}
+		result.Evidence = file
+		pl.Logger.Debug("Wrote evidence bundle",
+			logging.F("certificate", result.Name),
+			logging.F("file", file))
+	}
+	return nil
+}
+
+// parseVerifyArgs parses the arguments of the verify step.
+func parseVerifyArgs(args []string) (verifyOptions, error) {
+	var opts verifyOptions
+	for _, arg := range args {
+		switch {
+		case strings.HasPrefix(arg, "cert:"):
+			der, err := base64.StdEncoding.DecodeString(strings.TrimSpace(strings.TrimPrefix(arg, "cert:")))
+			if err != nil {
+				return opts, fmt.Errorf("%w: invalid base64 in cert argument: %v", ErrInvalidArguments, err)
+			}
+			cert, err := x509.ParseCertificate(der)
+			if err != nil {
+				return opts, fmt.Errorf("%w: invalid certificate in cert argument: %v", ErrInvalidArguments, err)
+			}
+			opts.certs = append(opts.certs, MonitoredCertificate{Name: "cert:" + strconv.Itoa(len(opts.certs)+1), Leaf: cert})
+		case strings.HasPrefix(arg, "pool:"):
+			if opts.pool = strings.TrimPrefix(arg, "pool:"); opts.pool == "" {
+				return opts, fmt.Errorf("%w: empty pool name", ErrInvalidArguments)
+			}
+		case strings.HasPrefix(arg, "report:"):
+			opts.report = strings.TrimPrefix(arg, "report:")
+			if err := validation.ValidateFilePath(opts.report); err != nil {
+				return opts, fmt.Errorf("%w: invalid report file: %v", ErrInvalidArguments, err)
+			}
+		case strings.HasPrefix(arg, "evidence:"):
+			opts.evidence = strings.TrimPrefix(arg, "evidence:")
+			if err := validation.ValidateFilePath(opts.evidence); err != nil {
+				return opts, fmt.Errorf("%w: invalid evidence directory: %v", ErrInvalidArguments, err)
+			}
+		case strings.HasPrefix(arg, "on-fail:"):
+			switch value := strings.TrimPrefix(arg, "on-fail:"); value {
+			case "fail":
+				opts.warn = false
+			case "warn":
+				opts.warn = true
+			default:
+				return opts, fmt.Errorf("%w: invalid on-fail value %q (expected fail or warn)", ErrInvalidArguments, value)
+			}
+		case !strings.Contains(arg, ":"):
+			if err := validation.ValidateFilePath(arg); err != nil {
+				return opts, fmt.Errorf("%w: invalid certificate path: %v", ErrInvalidArguments, err)
+			}
+			opts.paths = append(opts.paths, arg)
+		default:
+			return opts, fmt.Errorf("%w: unexpected argument %q", ErrInvalidArguments, arg)
+		}
+	}
+	if len(opts.paths) == 0 && len(opts.certs) == 0 {
+		return opts, fmt.Errorf("%w: no certificates to verify", ErrInvalidArguments)
+	}
+	return opts, nil
+}
+
+// validateVerifyArgs is the ArgsValidator of the verify step.
+func validateVerifyArgs(args ...string) error {
+	_, err := parseVerifyArgs(args)
+	return err
+}
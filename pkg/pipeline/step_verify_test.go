@@ -0,0 +1,143 @@
+package pipeline
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirosfoundation/g119612/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyCertificates(t *testing.T) {
+	ca, caKey := monitorTestCert(t, "Listed CA", nil, nil)
+	other, otherKey := monitorTestCert(t, "Unlisted CA", nil, nil)
+	leaf, _ := monitorTestCert(t, "service.example.com", ca, caKey)
+	otherLeaf, _ := monitorTestCert(t, "other.example.com", other, otherKey)
+
+	dir := t.TempDir()
+	certs := filepath.Join(dir, "certs")
+	require.NoError(t, os.Mkdir(certs, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(certs, "leaf.pem"),
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leaf.Raw}), 0644))
+	otherBase64 := base64.StdEncoding.EncodeToString(otherLeaf.Raw)
+
+	var buf bytes.Buffer
+	logger := logging.NewLogger(logging.InfoLevel)
+	logger.(logging.OutputConfigurable).SetOutput(&buf)
+	pl := &Pipeline{Logger: logger}
+	ctx := NewContext()
+	ctx.AddTSL(generateTSL("Listed Service", "http://uri.etsi.org/TrstSvc/Svctype/CA/QC",
+		[]string{base64.StdEncoding.EncodeToString(ca.Raw)}))
+	ctx, err := SelectCertPool(pl, ctx, "name:qc")
+	require.NoError(t, err)
+
+	t.Run("Passed", func(t *testing.T) {
+		report := filepath.Join(dir, "report.json")
+		result, err := VerifyCertificates(pl, ctx, certs, "report:"+report)
+		require.NoError(t, err)
+		results := VerificationResults(result)
+		require.NotNil(t, results)
+		assert.Equal(t, 1, results.Passed)
+		assert.Zero(t, results.Failed)
+		require.Len(t, results.Certificates, 1)
+		verified := results.Certificates[0]
+		assert.Equal(t, filepath.Join(certs, "leaf.pem"), verified.Name)
+		assert.True(t, verified.Verified)
+		assert.Equal(t, []string{"CN=service.example.com", "CN=Listed CA"}, verified.Chain)
+		require.Len(t, verified.Anchors, 1)
+		assert.Equal(t, "Listed Service", verified.Anchors[0].Service)
+
+		data, err := os.ReadFile(report)
+		require.NoError(t, err)
+		var written VerificationReport
+		require.NoError(t, json.Unmarshal(data, &written))
+		assert.Equal(t, 1, written.Passed)
+		assert.Equal(t, verified.SHA256, written.Certificates[0].SHA256)
+	})
+
+	t.Run("Failed", func(t *testing.T) {
+		buf.Reset()
+		_, err := VerifyCertificates(pl, ctx, certs, "cert:"+otherBase64)
+		require.ErrorIs(t, err, ErrVerificationFailed)
+		assert.Contains(t, err.Error(), "1 of 2 certificates, first cert:1 (CN=other.example.com)")
+		assert.Contains(t, buf.String(), "Certificate does not verify")
+
+		result, err := VerifyCertificates(pl, ctx, "cert:"+otherBase64, "on-fail:warn")
+		require.NoError(t, err)
+		results := VerificationResults(result)
+		assert.Equal(t, 1, results.Failed)
+		assert.False(t, results.Certificates[0].Verified)
+		assert.NotEmpty(t, results.Certificates[0].Error)
+		assert.Empty(t, results.Certificates[0].Chain)
+	})
+
+	t.Run("Named_Pool", func(t *testing.T) {
+		result, err := VerifyCertificates(pl, ctx, certs, "pool:qc")
+		require.NoError(t, err)
+		assert.Equal(t, "qc", VerificationResults(result).Pool)
+
+		_, err = VerifyCertificates(pl, ctx, certs, "pool:tsa")
+		assert.ErrorIs(t, err, ErrNoCertPool)
+		_, err = VerifyCertificates(pl, NewContext(), certs)
+		assert.ErrorIs(t, err, ErrNoCertPool)
+	})
+
+	t.Run("Evidence", func(t *testing.T) {
+		evidence := filepath.Join(dir, "evidence")
+		result, err := VerifyCertificates(pl, ctx, certs, "cert:"+otherBase64, "pool:qc", "evidence:"+evidence, "on-fail:warn")
+		require.NoError(t, err)
+		results := VerificationResults(result)
+		require.Len(t, results.Certificates, 2)
+		assert.Empty(t, results.Certificates[0].Evidence, "no evidence for certificates that do not verify")
+		verified := results.Certificates[1]
+		assert.Equal(t, filepath.Join(evidence, verified.SHA256+".zip"), verified.Evidence)
+
+		entries, err := os.ReadDir(evidence)
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		zr, err := zip.OpenReader(verified.Evidence)
+		require.NoError(t, err)
+		defer zr.Close()
+		r, err := zr.Open("evidence.json")
+		require.NoError(t, err)
+		defer r.Close()
+		var bundle EvidenceBundle
+		require.NoError(t, json.NewDecoder(r).Decode(&bundle))
+		assert.Equal(t, "qc", bundle.Pool)
+		assert.Equal(t, verified.SHA256, bundle.Chain[0].SHA256)
+	})
+
+	t.Run("Read_Only", func(t *testing.T) {
+		report := filepath.Join(dir, "read-only.json")
+		evidence := filepath.Join(dir, "read-only")
+		_, err := VerifyCertificates(&Pipeline{Logger: logger, ReadOnly: true}, ctx, certs, "report:"+report, "evidence:"+evidence)
+		require.NoError(t, err)
+		assert.NoFileExists(t, report)
+		assert.NoDirExists(t, evidence)
+	})
+
+	t.Run("Arguments", func(t *testing.T) {
+		for _, args := range [][]string{
+			{},
+			{"pool:qc"},
+			{"cert:not base64"},
+			{"cert:" + base64.StdEncoding.EncodeToString([]byte("not a certificate"))},
+			{certs, "pool:"},
+			{certs, "evidence:../evidence"},
+			{certs, "on-fail:ignore"},
+			{certs, "unknown:x"},
+		} {
+			assert.ErrorIs(t, validateVerifyArgs(args...), ErrInvalidArguments, "%q", args)
+		}
+		assert.NoError(t, validateVerifyArgs(certs, "cert:"+otherBase64, "pool:qc", "report:out.json", "evidence:evidence", "on-fail:warn"))
+		_, err := VerifyCertificates(pl, ctx, filepath.Join(dir, "missing.pem"))
+		assert.Error(t, err)
+	})
+}
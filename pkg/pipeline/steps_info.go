@@ -0,0 +1,250 @@
+package pipeline
+
+// The descriptions of the built-in steps, from which tsl-tool generates its
+// manual page. The doc comments of the step functions have the details.
+func init() {
+	RegisterInfo("load", StepInfo{
+		Summary: "Load a TSL and the TSLs it references from a URL or file path",
+		Args:    "<url|path|wellknown:host|pointers:url>",
+		Options: []StepOption{
+			{"strict[:true]", "Reject TSLs with unexpected or missing elements or unparseable dates"},
+			{"wellknown-path:PATH", "Well-known path used with wellknown:host"},
+			{"strict-pointers[:true]", "Skip referenced TSLs whose type or territory contradict the pointer of their parent"},
+			{"mirrors:URL|URL", "Further locations of the same TSL, used when the URL cannot be loaded"},
+			{"mirror-mode:order|race", "Try the locations in order (default) or all at once"},
+			{"mirror-check[:true]", "Fail unless all reachable mirrors serve the same sequence number and content"},
+			{"allow-doctype[:true]", "Accept TSLs with a DOCTYPE declaration"},
+		},
+	})
+	selectInfo := StepInfo{
+		Summary: "Build the certificate pool from the loaded TSLs",
+		Options: []StepOption{
+			{"reference-depth:N", "Include referenced TSLs up to N levels deep (0 for the root only)"},
+			{"include-referenced", "Include all referenced TSLs"},
+			{"service-type:URI", "Only select services of this type (repeatable)"},
+			{"status:URI", "Only select services with this status (repeatable)"},
+			{"status-logic:and", "Require all status filters to match instead of any"},
+			{"cache-dir:DIR", "Reuse the pool of an earlier run when neither TSLs nor filters changed"},
+			{"policy-file:FILE", "Add the service types and statuses of a YAML or JSON service policy"},
+			{"require-ca", "Exclude certificates that are not CA certificates allowed to sign certificates"},
+			{"require-eku:NAME", "Exclude certificates whose extended key usage does not allow NAME (repeatable)"},
+			{"min-rsa-bits:N", "Exclude certificates with RSA keys shorter than N bits"},
+			{"allow-alg:LIST", "Exclude certificates whose key algorithm is not in the comma-separated LIST"},
+			{"issuer-contains:TEXT", "Exclude certificates whose issuer does not contain TEXT (repeatable)"},
+			{"at:TIME", "Verify certificates at an RFC 3339 time instead of now"},
+			{"exclusion-report:FILE", "Write the excluded certificates to a JSON file"},
+			{"extra-roots:PATH", "Add the certificates of a PEM file or directory as local trust anchors (repeatable)"},
+			{"status-conflict:warn|exclude", "Log or also exclude certificates listed with conflicting statuses"},
+			{"min-certs:N", "Fail if the pool has fewer than N certificates"},
+			{"max-certs:N", "Fail if the pool has more than N certificates"},
+			{"pool-log:FILE", "Append the certificates entering and leaving the pool to a hash-chained audit log"},
+			{"name:NAME", "Also keep the pool under NAME for steps with a pool:NAME option"},
+		},
+	}
+	RegisterInfo("select", selectInfo)
+	selectInfo.Summary = "Alternative name of select"
+	RegisterInfo("select-cert-pool", selectInfo)
+	RegisterInfo("transform", StepInfo{
+		Summary: "Apply an XSLT stylesheet to the TSLs",
+		Args:    "<stylesheet|embedded:name> <replace|dir> [<extension>]",
+		Options: []StepOption{
+			{"engine:auto|xsltproc|native", "XSLT processor; auto uses xsltproc if installed, the built-in one otherwise"},
+			{"keep-failed:DIR", "Keep the input and output of failed transformations in DIR"},
+			{"lang:CODES", "Languages of the labels passed to the stylesheet"},
+			{"param:NAME=VALUE", "String parameter passed to the stylesheet (repeatable)"},
+		},
+	})
+	RegisterInfo("render", StepInfo{
+		Summary: "Render the TSLs with a Go html/template",
+		Args:    "<template|embedded:tsl.html> <dir> [<extension>]",
+		Options: []StepOption{
+			{"lang:CODES", "Preferred languages of names and labels"},
+			{"split-providers[:N]", "Render the providers of TSLs with at least N providers on pages of their own"},
+		},
+	})
+	RegisterInfo("publish", StepInfo{
+		Summary: "Write the TSLs as XML files, optionally signed",
+		Args:    "<dir> [<cert> <key> | <pkcs11-uri> ...]",
+		Options: []StepOption{
+			{"file-mode:MODE", "Octal mode of the published files"},
+			{"dir-mode:MODE", "Octal mode of created directories"},
+			{"key-permissions:warn|strict|ignore", "Policy for private keys readable by group or others"},
+			{"unsigned-copy:true", "Also write the canonical signed content, without the signature, as name-unsigned.xml"},
+			{"sign-mode:dom|stream", "Sign with an in-memory DOM (default) or streaming, for very large TSLs"},
+			{"signer:none|memory", "Sign without key files: empty signatures or an ephemeral key, for dry runs"},
+			{"rollover-cert:FILE", "Certificate of the next key of a key rollover"},
+			{"rollover-key:FILE", "Next key of a key rollover"},
+			{"rollover-dir:DIR", "Directory of the TSLs signed with the next key"},
+			{"indent:pretty|compact", "Indentation of the XML"},
+			{"xml-declaration:true|false", "Whether to write the XML declaration"},
+			{"encoding:UTF-8|none", "Encoding attribute of the XML declaration"},
+			{"newline:lf|crlf", "Line endings of the XML"},
+			{"manifest:true", "Write manifest.json with the digest and ETag of every file"},
+			{"etag:true", "Write the ETag of each file to name.xml.etag"},
+			{"on-failure:keep|rollback|partial", "What to do with the files written when publishing fails"},
+			{"retries:N", "Retry failed writes N times"},
+			{"split-max-bytes:N", "Publish TSLs larger than N bytes as parts referenced by the TSL"},
+			{"split-max-providers:N", "Publish TSLs with more than N providers as parts referenced by the TSL"},
+			{"split-base-url:URL", "Location of the parts (default: next to the first distribution point)"},
+			{"xades:true", "Sign with XAdES-BES signatures (signing time and certificate)"},
+			{"xades-policy:ID", "Sign with XAdES-EPES signatures identifying this signature policy"},
+			{"xades-policy-digest:SHA256", "Base64 or hex SHA-256 digest of the policy document, required with xades-policy"},
+			{"xades-policy-url:URL", "Location of the policy document"},
+			{"xades-policy-description:TEXT", "Description of the policy"},
+		},
+	})
+	RegisterInfo("generate", StepInfo{
+		Summary: "Generate a TSL from a directory of scheme, provider and certificate metadata",
+		Args:    "<dir>",
+		Options: []StepOption{
+			{"strict-uris", "Require well-formed absolute URIs with an allowed scheme"},
+			{"uri-schemes:LIST", "Comma-separated schemes allowed by strict-uris (default https)"},
+		},
+	})
+	RegisterInfo("generate_index", StepInfo{
+		Summary: "Write an HTML index of the TSL pages in a directory",
+		Args:    "<dir> [<title>]",
+		Options: []StepOption{
+			{"lang:CODES", "Languages of the labels of the page"},
+		},
+	})
+	RegisterInfo("change-report", StepInfo{
+		Summary: "Write an HTML page of the services changed since the last run",
+		Args:    "<dir>",
+		Options: []StepOption{
+			{"state:FILE", "State file of the previous run (required)"},
+			{"file:NAME", "Name of the page (default " + DefaultChangeReportFile + ")"},
+			{"title:TEXT", "Title of the page"},
+		},
+	})
+	RegisterInfo("check-links", StepInfo{
+		Summary: "Fail on dead links in generated pages or distribution points",
+		Args:    "<dir>",
+		Options: []StepOption{
+			{"remote:true", "Also fetch the distribution points of the loaded TSLs"},
+			{"on-dead:fail|warn", "Fail the pipeline (default) or only log dead links"},
+		},
+	})
+	RegisterInfo("log", StepInfo{
+		Summary: "Write a message with key=value fields to the log",
+		Args:    "<[level=LEVEL ]message> [<key=value> ...]",
+	})
+	RegisterInfo("echo", StepInfo{
+		Summary: "Do nothing, as a placeholder",
+	})
+	RegisterInfo("set-fetch-options", StepInfo{
+		Summary: "Configure how TSLs are fetched",
+		Options: []StepOption{
+			{"user-agent:TEXT", "User-Agent header of requests"},
+			{"timeout:DURATION", "Time limit of a request"},
+			{"max-depth:N", "Maximum depth of followed references (-1 for unlimited)"},
+			{"accept:TYPES", "Comma-separated Accept header values"},
+			{"prefer-xml:true", "Try the .xml extension if a .pdf location fails"},
+			{"filter-territory:LIST", "Only include TSLs of the comma-separated territories"},
+			{"filter-service-type:LIST", "Only include TSLs with services of the comma-separated types"},
+			{"max-idle-conns-per-host:N", "Idle connections kept per host"},
+			{"idle-conn-timeout:DURATION", "How long idle connections are kept"},
+			{"http2:false", "Restrict fetching to HTTP/1.1"},
+			{"compression:false", "Stop requesting gzip encoded responses"},
+			{"fetch-cache:false", "Fetch a referenced TSL each time it is referenced"},
+			{"mirror:DIR", "Read all TSLs from the mirror in DIR instead of fetching them"},
+			{"retries:N", "Retry transient failures N times"},
+			{"retry-delay:DURATION", "Delay before the first retry"},
+			{"ipfs-gateway:URL", "Gateway ipfs:// URLs are fetched through"},
+			{"signature-algorithms:LIST", "Accepted signature algorithms of TSL signatures"},
+			{"digest-algorithms:LIST", "Accepted digest algorithms of TSL signatures"},
+			{"allow-sha1:true", "Accept TSLs signed with SHA-1"},
+			{"allow-sha1-url:URL", "Accept SHA-1 for the TSL at URL only (repeatable)"},
+			{"min-rsa-bits:N", "Minimum size of the RSA keys TSLs are signed with"},
+			{"raw-spill-dir:DIR", "Write the documents of large TSLs to DIR instead of keeping them in memory"},
+			{"raw-spill-threshold:BYTES", "Size above which documents are spilled"},
+			{"signer-roots:FILE", "PEM file with roots the signers of TSLs must chain to (repeatable)"},
+			{"aia-allow-host:PATTERN", "Host missing signer intermediates may be fetched from (repeatable)"},
+			{"host:PATTERN OPTIONS", "Fetch options for the hosts matching PATTERN, e.g. \"host:ec.europa.eu timeout:180s\""},
+		},
+	})
+	RegisterInfo("set-language", StepInfo{
+		Summary: "Set the preferred languages of names and page labels",
+		Args:    "<lang> [<lang> ...]",
+	})
+	RegisterInfo("export-notification", StepInfo{
+		Summary: "Package the TSL and notification metadata as a ZIP file",
+		Args:    "<zip>",
+		Options: []StepOption{
+			{"tsl:FILE", "Include this published file instead of serializing the TSL"},
+			{"signer-cert:FILE", "PEM file with signer certificates (repeatable)"},
+		},
+	})
+	RegisterInfo("export-oidfed", StepInfo{
+		Summary: "Export TSPs as OpenID Federation entity statements or trust marks",
+		Args:    "<dir>",
+		Options: []StepOption{
+			{"issuer:URL", "Entity identifier of the issuer (required)"},
+			{"key:FILE", "PEM private key signing the JWTs (required)"},
+			{"kid:ID", "Key identifier of the signing key"},
+			{"format:entity-statement|trust-mark", "What to write (repeatable)"},
+			{"service-type:URI", "Only export services of this type (repeatable)"},
+			{"status:URI", "Only export services with this status (repeatable)"},
+			{"lifetime:DURATION", "Validity of the JWTs"},
+		},
+	})
+	RegisterInfo("compare-remote", StepInfo{
+		Summary: "Refuse to overwrite a newer or conflicting published TSL",
+		Options: []StepOption{
+			{"signer-cert:FILE", "PEM file with the expected signer certificates (repeatable)"},
+			{"allow-missing:true", "Continue when the published copy cannot be fetched"},
+		},
+	})
+	RegisterInfo("mirror", StepInfo{
+		Summary: "Save the fetched TSLs as they were fetched, with a manifest",
+		Args:    "<dir>",
+	})
+	RegisterInfo("publish-oci", StepInfo{
+		Summary: "Push the certificate pool and the TSLs to an OCI registry",
+		Args:    "<registry/repository[:tag]>",
+		Options: []StepOption{
+			{"username:USER", "User name for the registry"},
+			{"password-env:VAR", "Environment variable holding the password or token"},
+			{"plain-http", "Use HTTP instead of HTTPS"},
+			{"pool-only", "Only push pool.pem, without the TSLs"},
+			{"annotation:KEY=VALUE", "Further manifest annotation (repeatable)"},
+			{"timeout:DURATION", "Time limit of the push"},
+			{"pool:NAME", "Push the pool selected with name:NAME instead of the last one"},
+		},
+	})
+	RegisterInfo("verify", StepInfo{
+		Summary: "Verify certificates against the pool and report which chain to it",
+		Args:    "<file|dir> ...",
+		Options: []StepOption{
+			{"cert:BASE64", "A certificate as base64 DER (repeatable)"},
+			{"pool:NAME", "Verify against the pool selected with name:NAME instead of the last one"},
+			{"report:FILE", "Write the report as JSON to FILE"},
+			{"evidence:DIR", "Write an evidence bundle for each certificate that verifies to DIR"},
+			{"on-fail:fail|warn", "Fail the pipeline (default) or only log certificates that do not verify"},
+		},
+	})
+	RegisterInfo("publish-system-store", StepInfo{
+		Summary: "Install the certificate pool into the trust store of the operating system",
+		Options: []StepOption{
+			{"confirm", "Required to change the store"},
+			{"store:anchors|keychain|certutil", "Kind of store (default: the one of the operating system)"},
+			{"dir:DIR", "Anchors directory (default: the one of the distribution)"},
+			{"update:COMMAND", "Command run after changing the anchors directory, or none"},
+			{"prefix:PREFIX", "File name prefix of the installed certificates"},
+			{"keychain:PATH", "macOS keychain to install into"},
+			{"cert-store:NAME", "Windows certificate store to install into"},
+			{"state:FILE", "State file recording the certificates installed into a keychain or certificate store"},
+			{"pool:NAME", "Install the pool selected with name:NAME instead of the last one"},
+			{"dry-run", "Only log the changes"},
+			{"allow-remove", "Remove installed certificates no longer in the pool"},
+			{"max-changes:N", "Fail if more than N certificates would be added or removed"},
+		},
+	})
+	RegisterInfo("validate-schema", StepInfo{
+		Summary: "Check the TSLs against the XML schema and the scheme information rules",
+		Options: []StepOption{
+			{"on-invalid:fail|warn", "Fail the pipeline (default) or only log invalid TSLs"},
+			{"engine:auto|native|xmllint", "Validate against the XSD with xmllint, or against the generated types (default: xmllint if installed)"},
+		},
+	})
+}
@@ -11,4 +11,35 @@ func init() {
 	RegisterFunction("publish", PublishTSL)
 	RegisterFunction("log", Log)
 	RegisterFunction("set-fetch-options", SetFetchOptions)
+	RegisterFunction("export-notification", ExportNotification)
+	RegisterFunction("compare-remote", CompareRemote)
+	RegisterFunction("set-language", SetLanguage)
+	RegisterFunction("export-oidfed", ExportOIDFed)
+	RegisterFunction("mirror", MirrorTSLs)
+	RegisterFunction("publish-oci", PublishOCI)
+	RegisterFunction("check-links", CheckLinks)
+	RegisterFunction("change-report", ChangeReport)
+	RegisterFunction("verify", VerifyCertificates)
+	RegisterFunction("publish-system-store", PublishSystemStore)
+	RegisterFunction("validate-schema", ValidateSchema)
+
+	// Register argument validators run when a pipeline is loaded
+	RegisterValidator("publish", validatePublishArgs)
+	RegisterValidator("set-language", validateSetLanguageArgs)
+	RegisterValidator("export-oidfed", validateExportOIDFedArgs)
+	RegisterValidator("publish-oci", validatePublishOCIArgs)
+	RegisterValidator("check-links", validateCheckLinksArgs)
+	RegisterValidator("change-report", validateChangeReportArgs)
+	RegisterValidator("verify", validateVerifyArgs)
+	RegisterValidator("publish-system-store", validatePublishSystemStoreArgs)
+	RegisterValidator("validate-schema", validateValidateSchemaArgs)
+
+	// Register the outputs of steps that are skipped in read-only mode
+	RegisterOutputs("publish", publishOutputs)
+	RegisterOutputs("export-notification", exportNotificationOutputs)
+	RegisterOutputs("export-oidfed", exportOIDFedOutputs)
+	RegisterOutputs("mirror", mirrorOutputs)
+	RegisterOutputs("publish-oci", publishOCIOutputs)
+	RegisterOutputs("change-report", changeReportOutputs)
+	RegisterOutputs("publish-system-store", publishSystemStoreOutputs)
 }
@@ -3,75 +3,35 @@ package pipeline
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"runtime"
 	"strings"
 	"sync"
 
 	"github.com/sirosfoundation/g119612/pkg/etsi119612"
+	"github.com/sirosfoundation/g119612/pkg/logging"
 	"github.com/sirosfoundation/g119612/pkg/validation"
 	"github.com/sirosfoundation/g119612/pkg/xslt"
 )
 
-// xsltCache caches XSLT stylesheet content to avoid repeated reads
-type xsltCache struct {
-	mu    sync.RWMutex
-	cache map[string][]byte
-}
-
-// Global XSLT cache
-var globalXSLTCache = &xsltCache{
-	cache: make(map[string][]byte),
-}
-
-// get retrieves XSLT content from cache or loads it
-func (c *xsltCache) get(key string, loader func() ([]byte, error)) ([]byte, error) {
-	// Try read lock first for cache hit
-	c.mu.RLock()
-	if content, ok := c.cache[key]; ok {
-		c.mu.RUnlock()
-		return content, nil
-	}
-	c.mu.RUnlock()
-
-	// Cache miss - acquire write lock and load
-	c.mu.Lock()
-	defer c.mu.Unlock()
-
-	// Double-check after acquiring write lock (another goroutine may have loaded it)
-	if content, ok := c.cache[key]; ok {
-		return content, nil
-	}
-
-	// Load the content
-	content, err := loader()
-	if err != nil {
-		return nil, err
-	}
-
-	// Store in cache
-	c.cache[key] = content
-	return content, nil
-}
-
-// clear removes all entries from the cache (useful for testing)
-func (c *xsltCache) clear() {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	c.cache = make(map[string][]byte)
-}
-
 // TransformTSL applies an XSLT transformation to each TSL in the context.
 // This pipeline step allows for flexible transformation of TSL XML documents
 // using XSLT stylesheets. It can either replace the TSLs in the pipeline context
 // with their transformed versions, or output the transformed documents to a
 // specified directory.
 //
-// The step requires the 'xsltproc' command to be available on the system.
+// The stylesheet is applied by a TransformEngine: the xsltproc command of
+// libxslt when it is installed, and the native XSLT 1.0 processor of the xslt
+// package otherwise, which handles the embedded stylesheets without external
+// tools. The engine: option selects one explicitly.
 //
 // Arguments:
 //   - arg[0]: Path to the XSLT stylesheet. Can be a filesystem path or an embedded XSLT path.
@@ -81,6 +41,24 @@ func (c *xsltCache) clear() {
 //   - If "replace", transformed TSLs replace the originals in the context.
 //   - Otherwise, it's treated as a directory path where transformed TSLs are saved.
 //   - arg[2]: (Optional) Output file extension (default: "xml")
+//   - engine:name (Optional) "auto" (default), "xsltproc", "native" or an
+//     engine added with RegisterTransformEngine
+//   - keep-failed:/path (Optional) Directory where the input document and the
+//     output of xsltproc are kept when a transformation fails
+//   - lang:code (Optional) Language of the labels, comma-separated preferences
+//     (default: the languages set with set-language)
+//   - param:name=value (Optional) String parameter passed to the stylesheet,
+//     overriding the xsl:param of that name; may be repeated
+//
+// With a language, the labels of its language pack (see LocaleFor) are passed
+// to the stylesheet as string parameters named after their keys, such as
+// "tsl.next-update", along with "lang"; the embedded tsl-to-html.xslt uses
+// them for its headings and labels, which are English otherwise. A param:
+// option of the same name takes precedence over a label, so single labels can
+// be changed without a language pack.
+//
+// A failed transformation is reported as an *XSLTTransformError, carrying the
+// exit code and the (truncated) stdout and stderr of xsltproc when it ran.
 //
 // Example usage in pipeline YAML for file-based XSLT:
 //
@@ -94,7 +72,47 @@ func (c *xsltCache) clear() {
 //   - embedded:tsl-to-html.xslt
 //   - /output/directory
 //   - html
+//   - "param:title=EU Trust Lists"
+//   - "param:baseurl=https://trust.example.com"
 func TransformTSL(pl *Pipeline, ctx *Context, args ...string) (*Context, error) {
+	args, langs, err := parseLangOption(args)
+	if err != nil {
+		return ctx, fmt.Errorf("%w: %v", ErrInvalidArguments, err)
+	}
+	var params []XSLTParam
+	if locale := localeOption(ctx, langs); locale != nil {
+		params = locale.xsltParams()
+	}
+
+	var keepDir, engineName string
+	var userParams []XSLTParam
+	positional := make([]string, 0, len(args))
+	for _, arg := range args {
+		if dir, ok := strings.CutPrefix(arg, "keep-failed:"); ok {
+			keepDir = dir
+			continue
+		}
+		if name, ok := strings.CutPrefix(arg, "engine:"); ok {
+			engineName = name
+			continue
+		}
+		if value, ok := strings.CutPrefix(arg, "param:"); ok {
+			param, err := parseXSLTParam(value)
+			if err != nil {
+				return ctx, err
+			}
+			userParams = append(userParams, param)
+			continue
+		}
+		positional = append(positional, arg)
+	}
+	args = positional
+	params = withXSLTParams(params, userParams)
+	engineName, engine, err := transformEngine(engineName)
+	if err != nil {
+		return ctx, err
+	}
+
 	if len(args) < 2 {
 		return ctx, fmt.Errorf("missing required arguments: need XSLT stylesheet path and mode ('replace' or output directory)")
 	}
@@ -119,6 +137,20 @@ func TransformTSL(pl *Pipeline, ctx *Context, args ...string) (*Context, error)
 		}
 	}
 
+	if keepDir != "" && pl != nil && pl.ReadOnly {
+		pl.Logger.Info("Read-only mode: not keeping failed transformations",
+			logging.F("would_write", keepDir))
+		keepDir = ""
+	}
+	if keepDir != "" {
+		if err := validation.ValidateOutputDirectory(keepDir); err != nil {
+			return ctx, fmt.Errorf("invalid keep-failed directory: %w", err)
+		}
+		if err := os.MkdirAll(keepDir, 0700); err != nil {
+			return ctx, fmt.Errorf("failed to create keep-failed directory %s: %w", keepDir, err)
+		}
+	}
+
 	// Check if this is an embedded XSLT or a file path
 	isEmbedded := xslt.IsEmbeddedPath(xsltPath)
 
@@ -163,14 +195,26 @@ func TransformTSL(pl *Pipeline, ctx *Context, args ...string) (*Context, error)
 		allTSLs = append(allTSLs, tree.ToSlice()...)
 	}
 
+	// Record which stylesheet produced the output, so its provenance can be audited
+	digest, err := stylesheetDigest(xsltPath, isEmbedded)
+	if err != nil {
+		return ctx, err
+	}
+	if pl != nil && pl.Logger != nil {
+		pl.Logger.Info("Transforming TSLs",
+			logging.F("stylesheet", xsltPath),
+			logging.F("sha256", digest),
+			logging.F("engine", engineName),
+			logging.F("tsl_count", len(allTSLs)))
+	}
+
 	// Perform concurrent transformations
 	var transformedTSLs []*etsi119612.TSL
-	var err error
 
 	if isReplace {
-		transformedTSLs, err = transformTSLsConcurrent(allTSLs, xsltPath, isEmbedded, "", extension)
+		transformedTSLs, err = transformTSLsConcurrent(engine, allTSLs, xsltPath, isEmbedded, "", extension, keepDir, params...)
 	} else {
-		_, err = transformTSLsConcurrent(allTSLs, xsltPath, isEmbedded, outputDir, extension)
+		_, err = transformTSLsConcurrent(engine, allTSLs, xsltPath, isEmbedded, outputDir, extension, keepDir, params...)
 	}
 
 	if err != nil {
@@ -193,6 +237,44 @@ func TransformTSL(pl *Pipeline, ctx *Context, args ...string) (*Context, error)
 	return ctx, nil
 }
 
+// xsltParamName matches the parameter names accepted by param:, XML names
+// without a namespace prefix.
+var xsltParamName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9._-]*$`)
+
+// parseXSLTParam parses the value of a param:name=value option.
+func parseXSLTParam(value string) (XSLTParam, error) {
+	name, val, ok := strings.Cut(value, "=")
+	if !ok || !xsltParamName.MatchString(name) {
+		return XSLTParam{}, fmt.Errorf("%w: invalid parameter %q (expected param:name=value)", ErrInvalidArguments, value)
+	}
+	return XSLTParam{Name: name, Value: val}, nil
+}
+
+// withXSLTParams returns the stylesheet parameters base followed by params.
+// A parameter of base is dropped if params has one of the same name, as is an
+// earlier one of params, so the last value given wins.
+func withXSLTParams(base, params []XSLTParam) []XSLTParam {
+	if len(params) == 0 {
+		return base
+	}
+	last := make(map[string]int, len(params))
+	for i, param := range params {
+		last[param.Name] = i
+	}
+	result := make([]XSLTParam, 0, len(base)+len(params))
+	for _, param := range base {
+		if _, ok := last[param.Name]; !ok {
+			result = append(result, param)
+		}
+	}
+	for i, param := range params {
+		if last[param.Name] == i {
+			result = append(result, param)
+		}
+	}
+	return result
+}
+
 // transformResult holds the result of a single TSL transformation
 type transformResult struct {
 	index          int
@@ -214,22 +296,25 @@ type transformResult struct {
 //   - Each worker processes TSLs independently without shared state
 //
 // Parameters:
+//   - engine: The TransformEngine applying the stylesheet
 //   - tsls: Slice of TSLs to transform
 //   - xsltPath: Path to XSLT stylesheet (file or embedded)
 //   - isEmbedded: Whether the XSLT is embedded in the binary
 //   - outputDir: Directory for output files (empty for replace mode)
 //   - extension: File extension for output files
+//   - keepDir: Directory for the input and output of failed transformations (empty to discard them)
+//   - params: String parameters of the stylesheet, such as lang=sv
 //
 // Returns:
 //   - Transformed TSLs (in replace mode) or nil (when writing to files)
 //   - Error if any transformation fails
-func transformTSLsConcurrent(tsls []*etsi119612.TSL, xsltPath string, isEmbedded bool, outputDir string, extension string) ([]*etsi119612.TSL, error) {
+func transformTSLsConcurrent(engine TransformEngine, tsls []*etsi119612.TSL, xsltPath string, isEmbedded bool, outputDir string, extension string, keepDir string, params ...XSLTParam) ([]*etsi119612.TSL, error) {
 	if len(tsls) == 0 {
 		return nil, nil
 	}
 
 	// Determine optimal number of workers (use number of CPUs, max 8)
-	// We cap at 8 because xsltproc is CPU-intensive and too many concurrent
+	// We cap at 8 because transformations are CPU-intensive and too many concurrent
 	// processes can lead to resource contention and diminishing returns
 	numWorkers := runtime.GOMAXPROCS(0)
 	if numWorkers > 8 {
@@ -259,37 +344,24 @@ func transformTSLsConcurrent(tsls []*etsi119612.TSL, xsltPath string, isEmbedded
 					continue
 				}
 
-				// Create a wrapper struct with the proper XML namespace and element name
-				type TrustServiceStatusList struct {
-					XMLName                        xml.Name `xml:"http://uri.etsi.org/02231/v2# TrustServiceStatusList"`
-					etsi119612.TrustStatusListType `xml:",innerxml"`
-				}
-
-				wrapper := TrustServiceStatusList{
-					TrustStatusListType: tsl.StatusList,
-				}
-
-				xmlData, err := xml.MarshalIndent(wrapper, "", "  ")
+				xmlData, err := marshalTransformInput(tsl)
 				if err != nil {
-					result.err = fmt.Errorf("failed to marshal TSL to XML: %w", err)
+					result.err = err
 					results <- result
 					continue
 				}
 
-				// Add XML header
-				xmlData = append([]byte(xml.Header), xmlData...)
-
 				// Apply XSLT transformation
 				var transformedXML []byte
 				if isEmbedded {
 					embeddedName := xslt.ExtractNameFromPath(xsltPath)
-					transformedXML, err = applyEmbeddedXSLTTransformation(xmlData, embeddedName)
+					transformedXML, err = applyEmbeddedXSLTTransformation(engine, xmlData, embeddedName, params...)
 				} else {
-					transformedXML, err = applyFileXSLTTransformation(xmlData, xsltPath)
+					transformedXML, err = applyFileXSLTTransformation(engine, xmlData, xsltPath, params...)
 				}
 
 				if err != nil {
-					result.err = fmt.Errorf("XSLT transformation failed: %w", err)
+					result.err = newTransformError(xsltPath, i, tsl.Identity(), xmlData, keepDir, err)
 					results <- result
 					continue
 				}
@@ -307,19 +379,7 @@ func transformTSLsConcurrent(tsls []*etsi119612.TSL, xsltPath string, isEmbedded
 					result.transformedTSL = &transformedTSL
 				} else {
 					// Determine filename for output
-					filename := fmt.Sprintf("transformed-tsl-%d.%s", i, extension)
-					if tsl.StatusList.TslSchemeInformation != nil &&
-						tsl.StatusList.TslSchemeInformation.TslDistributionPoints != nil &&
-						len(tsl.StatusList.TslSchemeInformation.TslDistributionPoints.URI) > 0 {
-
-						uri := tsl.StatusList.TslSchemeInformation.TslDistributionPoints.URI[0]
-						parts := strings.Split(uri, "/")
-						if len(parts) > 0 && parts[len(parts)-1] != "" {
-							baseName := parts[len(parts)-1]
-							filename = fmt.Sprintf("%s.%s", strings.TrimSuffix(baseName, filepath.Ext(baseName)), extension)
-						}
-					}
-					result.filename = filename
+					result.filename = tslOutputFileName(tsl, fmt.Sprintf("transformed-tsl-%d", i), extension)
 				}
 
 				results <- result
@@ -343,7 +403,12 @@ func transformTSLsConcurrent(tsls []*etsi119612.TSL, xsltPath string, isEmbedded
 	resultMap := make(map[int]transformResult)
 	for result := range results {
 		if result.err != nil {
-			return nil, fmt.Errorf("TSL %d transformation failed: %w", result.index, result.err)
+			// An XSLTTransformError already identifies the TSL
+			var transformErr *XSLTTransformError
+			if errors.As(result.err, &transformErr) {
+				return nil, result.err
+			}
+			return nil, fmt.Errorf("TSL %d (%s) transformation failed: %w", result.index, tsls[result.index].Identity(), result.err)
 		}
 		resultMap[result.index] = result
 	}
@@ -356,7 +421,7 @@ func transformTSLsConcurrent(tsls []*etsi119612.TSL, xsltPath string, isEmbedded
 				continue
 			}
 			filePath := filepath.Join(outputDir, result.filename)
-			if err := os.WriteFile(filePath, result.transformedXML, 0644); err != nil {
+			if err := writeFileAtomic(filePath, result.transformedXML, DefaultPublishFileMode); err != nil {
 				return nil, fmt.Errorf("failed to write transformed TSL to file %s: %w", filePath, err)
 			}
 		}
@@ -376,63 +441,85 @@ func transformTSLsConcurrent(tsls []*etsi119612.TSL, xsltPath string, isEmbedded
 	return transformedTSLs, nil
 }
 
-// applyFileXSLTTransformation applies an XSLT transformation to XML data using an external XSLT file
-// The XSLT content is cached after first read to improve performance on subsequent transformations.
-func applyFileXSLTTransformation(xmlData []byte, xsltPath string) ([]byte, error) {
-	// Get XSLT content from cache or load it
-	xsltContent, err := globalXSLTCache.get("file:"+xsltPath, func() ([]byte, error) {
-		return os.ReadFile(xsltPath)
-	})
-	if err != nil {
-		return nil, fmt.Errorf("failed to read XSLT file: %w", err)
+// marshalTransformInput serializes a TSL to the document a stylesheet is
+// applied to: indented XML with the TrustServiceStatusList element in the
+// namespace of ETSI TS 119 612.
+func marshalTransformInput(tsl *etsi119612.TSL) ([]byte, error) {
+	// Create a wrapper struct with the proper XML namespace and element name
+	type TrustServiceStatusList struct {
+		XMLName                        xml.Name `xml:"http://uri.etsi.org/02231/v2# TrustServiceStatusList"`
+		etsi119612.TrustStatusListType `xml:",innerxml"`
 	}
 
-	// Create a temporary file for the input XML
-	tempXmlFile, err := os.CreateTemp("", "input-*.xml")
-	if err != nil {
-		return nil, fmt.Errorf("failed to create temp XML file: %w", err)
+	wrapper := TrustServiceStatusList{
+		TrustStatusListType: tsl.StatusList,
 	}
-	defer os.Remove(tempXmlFile.Name())
 
-	// Write XML data to the temp file
-	if _, err := tempXmlFile.Write(xmlData); err != nil {
-		return nil, fmt.Errorf("failed to write XML to temp file: %w", err)
-	}
-	if err := tempXmlFile.Close(); err != nil {
-		return nil, fmt.Errorf("failed to close temp XML file: %w", err)
+	xmlData, err := xml.MarshalIndent(wrapper, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal TSL to XML: %w", err)
 	}
+	return append([]byte(xml.Header), xmlData...), nil
+}
 
-	// Create a temporary file for the XSLT
-	tempXsltFile, err := os.CreateTemp("", "style-*.xslt")
-	if err != nil {
-		return nil, fmt.Errorf("failed to create temp XSLT file: %w", err)
+// tslOutputFileName returns the name of a file derived from a TSL: the last
+// segment of its first distribution point with the given extension, or the
+// fallback name if the TSL has no usable distribution point.
+func tslOutputFileName(tsl *etsi119612.TSL, fallback, extension string) string {
+	filename := fmt.Sprintf("%s.%s", fallback, extension)
+	if tsl.StatusList.TslSchemeInformation != nil &&
+		tsl.StatusList.TslSchemeInformation.TslDistributionPoints != nil &&
+		len(tsl.StatusList.TslSchemeInformation.TslDistributionPoints.URI) > 0 {
+
+		uri := tsl.StatusList.TslSchemeInformation.TslDistributionPoints.URI[0]
+		parts := strings.Split(uri, "/")
+		if len(parts) > 0 && parts[len(parts)-1] != "" {
+			baseName := parts[len(parts)-1]
+			filename = fmt.Sprintf("%s.%s", strings.TrimSuffix(baseName, filepath.Ext(baseName)), extension)
+		}
 	}
-	defer os.Remove(tempXsltFile.Name())
+	return filename
+}
 
-	// Write cached XSLT data to the temp file
-	if _, err := tempXsltFile.Write(xsltContent); err != nil {
-		return nil, fmt.Errorf("failed to write XSLT to temp file: %w", err)
+// stylesheetDigest returns the hex SHA-256 digest of the stylesheet used by a
+// transform. The content is loaded through the XSLT cache, so for embedded
+// stylesheets it has passed the integrity check of xslt.Get.
+func stylesheetDigest(xsltPath string, isEmbedded bool) (string, error) {
+	if isEmbedded {
+		name := xslt.ExtractNameFromPath(xsltPath)
+		if _, err := globalXSLTCache.get("embedded:"+name, func() ([]byte, error) {
+			return xslt.Get(name)
+		}); err != nil {
+			return "", fmt.Errorf("failed to get embedded XSLT: %w", err)
+		}
+		return xslt.Digest(name)
 	}
-	if err := tempXsltFile.Close(); err != nil {
-		return nil, fmt.Errorf("failed to close temp XSLT file: %w", err)
+	content, err := globalXSLTCache.get("file:"+xsltPath, func() ([]byte, error) {
+		return os.ReadFile(xsltPath)
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to read XSLT file: %w", err)
 	}
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:]), nil
+}
 
-	// Run xsltproc command to apply the transformation
-	cmd := exec.Command("xsltproc", tempXsltFile.Name(), tempXmlFile.Name())
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-
-	if err := cmd.Run(); err != nil {
-		return nil, fmt.Errorf("xsltproc error: %w - %s", err, stderr.String())
+// applyFileXSLTTransformation applies an XSLT transformation to XML data using an external XSLT file
+// The XSLT content is cached after first read to improve performance on subsequent transformations.
+func applyFileXSLTTransformation(engine TransformEngine, xmlData []byte, xsltPath string, params ...XSLTParam) ([]byte, error) {
+	// Get XSLT content from cache or load it
+	xsltContent, err := globalXSLTCache.get("file:"+xsltPath, func() ([]byte, error) {
+		return os.ReadFile(xsltPath)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read XSLT file: %w", err)
 	}
-
-	return stdout.Bytes(), nil
+	return engine.Transform(xmlData, xsltContent, params)
 }
 
 // applyEmbeddedXSLTTransformation applies an XSLT transformation to XML data using an embedded XSLT file
 // The embedded XSLT content is cached after first access to improve performance.
-func applyEmbeddedXSLTTransformation(xmlData []byte, xsltName string) ([]byte, error) {
+func applyEmbeddedXSLTTransformation(engine TransformEngine, xmlData []byte, xsltName string, params ...XSLTParam) ([]byte, error) {
 	// Get embedded XSLT content from cache or load it
 	xsltContent, err := globalXSLTCache.get("embedded:"+xsltName, func() ([]byte, error) {
 		return xslt.Get(xsltName)
@@ -440,7 +527,33 @@ func applyEmbeddedXSLTTransformation(xmlData []byte, xsltName string) ([]byte, e
 	if err != nil {
 		return nil, fmt.Errorf("failed to get embedded XSLT: %w", err)
 	}
+	return engine.Transform(xmlData, xsltContent, params)
+}
+
+// xsltprocCommand is the command run for XSLT transformations.
+var xsltprocCommand = "xsltproc"
+
+// xsltprocError is returned by runXSLTProc when xsltproc fails. It keeps the
+// complete output of the command; XSLTTransformError carries a truncated copy.
+type xsltprocError struct {
+	exitCode int    // Exit code, or 0 if the command could not be run
+	stdout   []byte // Output written before the failure
+	stderr   []byte // Diagnostics of xsltproc
+	err      error  // The error returned by exec
+}
+
+func (e *xsltprocError) Error() string {
+	return fmt.Sprintf("xsltproc error: %v - %s", e.err, truncateOutput(e.stderr))
+}
+
+func (e *xsltprocError) Unwrap() error {
+	return e.err
+}
 
+// runXSLTProc writes the XML data and the stylesheet to temporary files and
+// runs xsltproc on them with the given additional arguments, returning its
+// output. A failure of the command is reported as an *xsltprocError.
+func runXSLTProc(xmlData, xsltContent []byte, params ...string) ([]byte, error) {
 	// Create a temporary file for the input XML
 	tempXmlFile, err := os.CreateTemp("", "input-*.xml")
 	if err != nil {
@@ -450,6 +563,7 @@ func applyEmbeddedXSLTTransformation(xmlData []byte, xsltName string) ([]byte, e
 
 	// Write XML data to the temp file
 	if _, err := tempXmlFile.Write(xmlData); err != nil {
+		tempXmlFile.Close()
 		return nil, fmt.Errorf("failed to write XML to temp file: %w", err)
 	}
 	if err := tempXmlFile.Close(); err != nil {
@@ -465,26 +579,95 @@ func applyEmbeddedXSLTTransformation(xmlData []byte, xsltName string) ([]byte, e
 
 	// Write cached XSLT data to the temp file
 	if _, err := tempXsltFile.Write(xsltContent); err != nil {
+		tempXsltFile.Close()
 		return nil, fmt.Errorf("failed to write XSLT to temp file: %w", err)
 	}
 	if err := tempXsltFile.Close(); err != nil {
 		return nil, fmt.Errorf("failed to close temp XSLT file: %w", err)
 	}
 
-	// Run xsltproc command to apply the transformation
-	cmd := exec.Command("xsltproc", tempXsltFile.Name(), tempXmlFile.Name())
+	// Run xsltproc command to apply the transformation. The input and the
+	// stylesheet may not load DTDs or entities from the network (XXE).
+	cmdArgs := append(append([]string{"--nonet", "--novalid"}, params...), tempXsltFile.Name(), tempXmlFile.Name())
+	cmd := exec.Command(xsltprocCommand, cmdArgs...)
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
 
 	if err := cmd.Run(); err != nil {
-		return nil, fmt.Errorf("xsltproc error: %w - %s", err, stderr.String())
+		procErr := &xsltprocError{stdout: stdout.Bytes(), stderr: stderr.Bytes(), err: err}
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			procErr.exitCode = exitErr.ExitCode()
+		}
+		return nil, procErr
 	}
 
 	return stdout.Bytes(), nil
 }
 
+// newTransformError builds the XSLTTransformError for a failed transformation
+// of TSL index with identity id. If xsltproc ran, its exit code and truncated output are
+// attached. With a keep directory the input document and the complete output
+// of xsltproc are saved there for inspection.
+func newTransformError(xsltPath string, index int, id etsi119612.TSLIdentity, xmlData []byte, keepDir string, err error) error {
+	transformErr := NewXSLTTransformError(xsltPath, index, err)
+	transformErr.TSL = id
+	prefix := filepath.Join(keepDir, fmt.Sprintf("tsl-%d", index))
+	files := map[string][]byte{prefix + "-input.xml": xmlData}
+	var procErr *xsltprocError
+	if errors.As(err, &procErr) {
+		transformErr.ExitCode = procErr.exitCode
+		transformErr.Stdout = truncateOutput(procErr.stdout)
+		transformErr.Stderr = truncateOutput(procErr.stderr)
+		files[prefix+"-stdout.txt"] = procErr.stdout
+		files[prefix+"-stderr.txt"] = procErr.stderr
+	}
+
+	if keepDir != "" {
+		for name, data := range files {
+			if err := writeFileAtomic(name, data, 0600); err != nil {
+				return fmt.Errorf("%w (failed to keep %s: %v)", transformErr, name, err)
+			}
+		}
+		transformErr.KeptInput = prefix + "-input.xml"
+	}
+	return transformErr
+}
+
+// maxTransformOutput is the number of bytes of xsltproc output kept in an XSLTTransformError.
+const maxTransformOutput = 4096
+
+// truncateOutput returns command output as a string of at most maxTransformOutput bytes.
+func truncateOutput(data []byte) string {
+	data = bytes.TrimSpace(data)
+	if len(data) <= maxTransformOutput {
+		return string(data)
+	}
+	return string(data[:maxTransformOutput]) + fmt.Sprintf("... (%d bytes truncated)", len(data)-maxTransformOutput)
+}
+
+// transformOutputs is the OutputsFunc of the transform step: the output
+// directory, if the transformed TSLs are not kept in the context.
+func transformOutputs(args ...string) []string {
+	args, _, err := parseLangOption(args)
+	if err != nil {
+		return nil
+	}
+	var positional []string
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "keep-failed:") && !strings.HasPrefix(arg, "param:") && !strings.HasPrefix(arg, "engine:") {
+			positional = append(positional, arg)
+		}
+	}
+	if len(positional) < 2 || positional[1] == "replace" {
+		return nil
+	}
+	return []string{positional[1]}
+}
+
 func init() {
 	// Register the TransformTSL function
 	RegisterFunction("transform", TransformTSL)
+	RegisterOutputs("transform", transformOutputs)
 }
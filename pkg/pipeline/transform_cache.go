@@ -0,0 +1,177 @@
+package pipeline
+
+import (
+	"container/list"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// XSLTCacheLimits bounds the cache of XSLT stylesheets shared by all
+// pipelines of the process. When a limit is exceeded the least recently used
+// stylesheets are evicted.
+type XSLTCacheLimits struct {
+	MaxEntries int           // Maximum number of cached stylesheets, 0 for no limit
+	MaxBytes   int64         // Maximum total size of the cached stylesheets, 0 for no limit
+	TTL        time.Duration // Age after which a stylesheet is loaded again, 0 for none
+}
+
+// DefaultXSLTCacheLimits are the limits of the XSLT cache unless changed with
+// SetXSLTCacheLimits.
+var DefaultXSLTCacheLimits = XSLTCacheLimits{MaxEntries: 64, MaxBytes: 16 << 20}
+
+// XSLTCacheStats are the counters of the XSLT cache since the process started.
+type XSLTCacheStats struct {
+	Hits          uint64 // Stylesheets served from the cache
+	Misses        uint64 // Stylesheets loaded, including reloads after invalidation
+	Invalidations uint64 // Entries dropped because the file changed or the TTL passed
+	Evictions     uint64 // Entries dropped to stay within the limits
+	Entries       int    // Stylesheets currently cached
+	Bytes         int64  // Total size of the stylesheets currently cached
+}
+
+// xsltCache caches XSLT stylesheet content to avoid repeated reads. Entries
+// of stylesheet files, keyed "file:<path>", are invalidated when the
+// modification time or size of the file changes.
+type xsltCache struct {
+	mu     sync.RWMutex
+	cache  map[string]*xsltCacheEntry
+	lru    *list.List // Keys, most recently used first
+	bytes  int64
+	limits XSLTCacheLimits
+	stats  XSLTCacheStats
+}
+
+// xsltCacheEntry is a cached stylesheet.
+type xsltCacheEntry struct {
+	content []byte
+	loaded  time.Time
+	stamp   fileStamp // Zero for stylesheets that are not files
+	element *list.Element
+}
+
+// fileStamp identifies a version of a file.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// Global XSLT cache
+var globalXSLTCache = newXSLTCache(DefaultXSLTCacheLimits)
+
+func newXSLTCache(limits XSLTCacheLimits) *xsltCache {
+	return &xsltCache{
+		cache:  make(map[string]*xsltCacheEntry),
+		lru:    list.New(),
+		limits: limits,
+	}
+}
+
+// SetXSLTCacheLimits changes the limits of the XSLT cache, evicting
+// stylesheets right away if it exceeds them.
+func SetXSLTCacheLimits(limits XSLTCacheLimits) {
+	globalXSLTCache.mu.Lock()
+	defer globalXSLTCache.mu.Unlock()
+	globalXSLTCache.limits = limits
+	globalXSLTCache.evict()
+}
+
+// XSLTCacheStatistics returns the counters of the XSLT cache, e.g. for
+// reporting them at the end of a run.
+func XSLTCacheStatistics() XSLTCacheStats {
+	globalXSLTCache.mu.RLock()
+	defer globalXSLTCache.mu.RUnlock()
+	stats := globalXSLTCache.stats
+	stats.Entries = len(globalXSLTCache.cache)
+	stats.Bytes = globalXSLTCache.bytes
+	return stats
+}
+
+// stamp returns the stamp of the file of a "file:" key, and whether the key
+// is one. A file that cannot be read has the zero stamp.
+func (c *xsltCache) stamp(key string) (fileStamp, bool) {
+	path, ok := strings.CutPrefix(key, "file:")
+	if !ok {
+		return fileStamp{}, false
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}, true
+	}
+	return fileStamp{modTime: info.ModTime(), size: info.Size()}, true
+}
+
+// get retrieves XSLT content from cache or loads it
+func (c *xsltCache) get(key string, loader func() ([]byte, error)) ([]byte, error) {
+	// Stat before locking, so slow file systems do not block other lookups
+	stamp, isFile := c.stamp(key)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if entry, ok := c.cache[key]; ok {
+		expired := c.limits.TTL > 0 && time.Since(entry.loaded) > c.limits.TTL
+		if !expired && (!isFile || entry.stamp == stamp) {
+			c.stats.Hits++
+			c.lru.MoveToFront(entry.element)
+			return entry.content, nil
+		}
+		c.stats.Invalidations++
+		c.remove(key)
+	}
+
+	// Cache miss - load the content while holding the lock, so concurrent
+	// lookups of the same stylesheet load it once
+	c.stats.Misses++
+	content, err := loader()
+	if err != nil {
+		return nil, err
+	}
+
+	if c.limits.MaxBytes > 0 && int64(len(content)) > c.limits.MaxBytes {
+		return content, nil
+	}
+	c.cache[key] = &xsltCacheEntry{
+		content: content,
+		loaded:  time.Now(),
+		stamp:   stamp,
+		element: c.lru.PushFront(key),
+	}
+	c.bytes += int64(len(content))
+	c.evict()
+	return content, nil
+}
+
+// remove drops the entry of key. The caller holds the write lock.
+func (c *xsltCache) remove(key string) {
+	entry, ok := c.cache[key]
+	if !ok {
+		return
+	}
+	c.lru.Remove(entry.element)
+	c.bytes -= int64(len(entry.content))
+	delete(c.cache, key)
+}
+
+// evict drops the least recently used entries until the cache is within its
+// limits. The caller holds the write lock.
+func (c *xsltCache) evict() {
+	for c.lru.Len() > 0 &&
+		(c.limits.MaxEntries > 0 && c.lru.Len() > c.limits.MaxEntries ||
+			c.limits.MaxBytes > 0 && c.bytes > c.limits.MaxBytes) {
+		c.remove(c.lru.Back().Value.(string))
+		c.stats.Evictions++
+	}
+}
+
+// clear removes all entries from the cache and resets its counters (useful
+// for testing)
+func (c *xsltCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cache = make(map[string]*xsltCacheEntry)
+	c.lru.Init()
+	c.bytes = 0
+	c.stats = XSLTCacheStats{}
+}
@@ -39,7 +39,7 @@ func BenchmarkXSLTCaching(b *testing.B) {
 
 		b.ResetTimer()
 		for i := 0; i < b.N; i++ {
-			_, err := applyFileXSLTTransformation(xmlData, xsltPath)
+			_, err := applyFileXSLTTransformation(XSLTProcEngine{}, xmlData, xsltPath)
 			if err != nil {
 				b.Fatalf("Transformation failed: %v", err)
 			}
@@ -51,7 +51,7 @@ func BenchmarkXSLTCaching(b *testing.B) {
 		b.ResetTimer()
 		for i := 0; i < b.N; i++ {
 			globalXSLTCache.clear()
-			_, err := applyFileXSLTTransformation(xmlData, xsltPath)
+			_, err := applyFileXSLTTransformation(XSLTProcEngine{}, xmlData, xsltPath)
 			if err != nil {
 				b.Fatalf("Transformation failed: %v", err)
 			}
@@ -80,7 +80,7 @@ func BenchmarkEmbeddedXSLTCaching(b *testing.B) {
 
 		b.ResetTimer()
 		for i := 0; i < b.N; i++ {
-			_, err := applyEmbeddedXSLTTransformation(xmlData, xsltName)
+			_, err := applyEmbeddedXSLTTransformation(XSLTProcEngine{}, xmlData, xsltName)
 			if err != nil {
 				b.Fatalf("Transformation failed: %v", err)
 			}
@@ -92,7 +92,7 @@ func BenchmarkEmbeddedXSLTCaching(b *testing.B) {
 		b.ResetTimer()
 		for i := 0; i < b.N; i++ {
 			globalXSLTCache.clear()
-			_, err := applyEmbeddedXSLTTransformation(xmlData, xsltName)
+			_, err := applyEmbeddedXSLTTransformation(XSLTProcEngine{}, xmlData, xsltName)
 			if err != nil {
 				b.Fatalf("Transformation failed: %v", err)
 			}
@@ -136,13 +136,13 @@ func BenchmarkConcurrentWithCaching(b *testing.B) {
 		// Do one warmup transformation to populate cache
 		outputDir := filepath.Join(tempDir, "warmup")
 		os.MkdirAll(outputDir, 0755)
-		_, _ = transformTSLsConcurrent(tsls[:1], "embedded:tsl-to-html.xslt", true, outputDir, "html")
+		_, _ = transformTSLsConcurrent(XSLTProcEngine{}, tsls[:1], "embedded:tsl-to-html.xslt", true, outputDir, "html", "")
 
 		b.ResetTimer()
 		for i := 0; i < b.N; i++ {
 			outputDir := filepath.Join(tempDir, "with-cache", fmt.Sprintf("%d", i))
 			os.MkdirAll(outputDir, 0755)
-			_, err := transformTSLsConcurrent(tsls, "embedded:tsl-to-html.xslt", true, outputDir, "html")
+			_, err := transformTSLsConcurrent(XSLTProcEngine{}, tsls, "embedded:tsl-to-html.xslt", true, outputDir, "html", "")
 			if err != nil {
 				b.Fatalf("Transformation failed: %v", err)
 			}
@@ -155,7 +155,7 @@ func BenchmarkConcurrentWithCaching(b *testing.B) {
 			globalXSLTCache.clear()
 			outputDir := filepath.Join(tempDir, "without-cache", fmt.Sprintf("%d", i))
 			os.MkdirAll(outputDir, 0755)
-			_, err := transformTSLsConcurrent(tsls, "embedded:tsl-to-html.xslt", true, outputDir, "html")
+			_, err := transformTSLsConcurrent(XSLTProcEngine{}, tsls, "embedded:tsl-to-html.xslt", true, outputDir, "html", "")
 			if err != nil {
 				b.Fatalf("Transformation failed: %v", err)
 			}
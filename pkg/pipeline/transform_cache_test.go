@@ -4,7 +4,10 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestXSLTCache(t *testing.T) {
@@ -138,7 +141,7 @@ func TestFileXSLTCaching(t *testing.T) {
 	xmlData := []byte(`<?xml version="1.0"?><input>test</input>`)
 
 	// First transformation - should cache the XSLT
-	result1, err := applyFileXSLTTransformation(xmlData, xsltPath)
+	result1, err := applyFileXSLTTransformation(&NativeEngine{}, xmlData, xsltPath)
 	if err != nil {
 		t.Fatalf("First transformation failed: %v", err)
 	}
@@ -154,7 +157,7 @@ func TestFileXSLTCaching(t *testing.T) {
 	}
 
 	// Second transformation - should use cache
-	result2, err := applyFileXSLTTransformation(xmlData, xsltPath)
+	result2, err := applyFileXSLTTransformation(&NativeEngine{}, xmlData, xsltPath)
 	if err != nil {
 		t.Fatalf("Second transformation failed: %v", err)
 	}
@@ -184,7 +187,7 @@ func TestEmbeddedXSLTCaching(t *testing.T) {
 </TrustServiceStatusList>`)
 
 	// First transformation - should cache the XSLT
-	result1, err := applyEmbeddedXSLTTransformation(xmlData, xsltName)
+	result1, err := applyEmbeddedXSLTTransformation(&NativeEngine{}, xmlData, xsltName)
 	if err != nil {
 		t.Fatalf("First transformation failed: %v", err)
 	}
@@ -200,7 +203,7 @@ func TestEmbeddedXSLTCaching(t *testing.T) {
 	}
 
 	// Second transformation - should use cache
-	result2, err := applyEmbeddedXSLTTransformation(xmlData, xsltName)
+	result2, err := applyEmbeddedXSLTTransformation(&NativeEngine{}, xmlData, xsltName)
 	if err != nil {
 		t.Fatalf("Second transformation failed: %v", err)
 	}
@@ -216,6 +219,146 @@ func TestEmbeddedXSLTCaching(t *testing.T) {
 	}
 }
 
+func TestXSLTCacheFileInvalidation(t *testing.T) {
+	globalXSLTCache.clear()
+
+	xsltPath := filepath.Join(t.TempDir(), "test.xslt")
+	if err := os.WriteFile(xsltPath, []byte("version 1"), 0644); err != nil {
+		t.Fatalf("Failed to write stylesheet: %v", err)
+	}
+	load := func() ([]byte, error) {
+		return os.ReadFile(xsltPath)
+	}
+
+	for i := 0; i < 2; i++ {
+		content, err := globalXSLTCache.get("file:"+xsltPath, load)
+		if err != nil || string(content) != "version 1" {
+			t.Fatalf("Expected 'version 1', got '%s' (%v)", content, err)
+		}
+	}
+
+	// A changed file is loaded again
+	if err := os.WriteFile(xsltPath, []byte("version 2"), 0644); err != nil {
+		t.Fatalf("Failed to write stylesheet: %v", err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(xsltPath, later, later); err != nil {
+		t.Fatalf("Failed to set modification time: %v", err)
+	}
+	content, err := globalXSLTCache.get("file:"+xsltPath, load)
+	if err != nil || string(content) != "version 2" {
+		t.Fatalf("Expected 'version 2' after the file changed, got '%s' (%v)", content, err)
+	}
+
+	// A removed file is not served from the cache
+	if err := os.Remove(xsltPath); err != nil {
+		t.Fatalf("Failed to remove stylesheet: %v", err)
+	}
+	if _, err := globalXSLTCache.get("file:"+xsltPath, load); err == nil {
+		t.Error("Expected an error for a removed stylesheet")
+	}
+
+	stats := XSLTCacheStatistics()
+	if stats.Hits != 1 || stats.Misses != 3 || stats.Invalidations != 2 {
+		t.Errorf("Expected 1 hit, 3 misses and 2 invalidations, got %+v", stats)
+	}
+	if stats.Entries != 0 || stats.Bytes != 0 {
+		t.Errorf("Expected an empty cache, got %+v", stats)
+	}
+}
+
+func TestXSLTCacheLimits(t *testing.T) {
+	loader := func(content string) func() ([]byte, error) {
+		return func() ([]byte, error) {
+			return []byte(content), nil
+		}
+	}
+	cached := func(c *xsltCache, keys ...string) {
+		t.Helper()
+		if len(c.cache) != len(keys) {
+			t.Errorf("Expected %d entries, got %d", len(keys), len(c.cache))
+		}
+		for _, key := range keys {
+			if _, ok := c.cache[key]; !ok {
+				t.Errorf("Expected %s to be cached", key)
+			}
+		}
+	}
+
+	t.Run("Max_Entries", func(t *testing.T) {
+		c := newXSLTCache(XSLTCacheLimits{MaxEntries: 2})
+		_, _ = c.get("a", loader("aaaa"))
+		_, _ = c.get("b", loader("bbbb"))
+		_, _ = c.get("a", loader("aaaa"))
+		_, _ = c.get("c", loader("cccc"))
+		cached(c, "a", "c")
+		if c.stats.Evictions != 1 || c.bytes != 8 {
+			t.Errorf("Expected 1 eviction and 8 bytes, got %+v and %d bytes", c.stats, c.bytes)
+		}
+	})
+
+	t.Run("Max_Bytes", func(t *testing.T) {
+		c := newXSLTCache(XSLTCacheLimits{MaxBytes: 10})
+		_, _ = c.get("a", loader("aaaa"))
+		_, _ = c.get("b", loader("bbbb"))
+		_, _ = c.get("c", loader("cccc"))
+		cached(c, "b", "c")
+
+		// Stylesheets larger than the limit are returned but not cached
+		content, err := c.get("large", loader("too large for the cache"))
+		if err != nil || string(content) != "too large for the cache" {
+			t.Fatalf("Expected the large stylesheet, got '%s' (%v)", content, err)
+		}
+		cached(c, "b", "c")
+	})
+
+	t.Run("TTL", func(t *testing.T) {
+		c := newXSLTCache(XSLTCacheLimits{TTL: time.Minute})
+		_, _ = c.get("a", loader("old"))
+		c.cache["a"].loaded = time.Now().Add(-2 * time.Minute)
+		content, _ := c.get("a", loader("new"))
+		if string(content) != "new" || c.stats.Invalidations != 1 {
+			t.Errorf("Expected an expired entry to be loaded again, got '%s' and %+v", content, c.stats)
+		}
+	})
+
+	t.Run("Set_Limits", func(t *testing.T) {
+		defer SetXSLTCacheLimits(DefaultXSLTCacheLimits)
+		globalXSLTCache.clear()
+		_, _ = globalXSLTCache.get("a", loader("aaaa"))
+		_, _ = globalXSLTCache.get("b", loader("bbbb"))
+		SetXSLTCacheLimits(XSLTCacheLimits{MaxEntries: 1})
+		cached(globalXSLTCache, "b")
+	})
+}
+
+func TestXSLTCacheConcurrent(t *testing.T) {
+	c := newXSLTCache(XSLTCacheLimits{MaxEntries: 4})
+	var loads atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 32; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			key := fmt.Sprintf("key%d", i%4)
+			content, err := c.get(key, func() ([]byte, error) {
+				loads.Add(1)
+				return []byte(key), nil
+			})
+			if err != nil || string(content) != key {
+				t.Errorf("Expected '%s', got '%s' (%v)", key, content, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if loads.Load() != 4 {
+		t.Errorf("Expected each stylesheet to be loaded once, got %d loads", loads.Load())
+	}
+	if c.stats.Hits != 28 || c.stats.Misses != 4 {
+		t.Errorf("Expected 28 hits and 4 misses, got %+v", c.stats)
+	}
+}
+
 // Helper function to check if byte slice contains substring
 func containsSubstring(data []byte, substr string) bool {
 	return len(data) > 0 && len(substr) > 0 &&
@@ -0,0 +1,136 @@
+package pipeline
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sirosfoundation/g119612/pkg/xslt"
+)
+
+// XSLTParam is a string parameter passed to a stylesheet, overriding the
+// top-level xsl:param of that name.
+type XSLTParam struct {
+	Name  string
+	Value string
+}
+
+// TransformEngine applies an XSLT stylesheet to a document. The transform step
+// calls it concurrently for several TSLs, so implementations must be safe for
+// concurrent use.
+type TransformEngine interface {
+	// Transform applies the stylesheet to the XML data with the given string
+	// parameters and returns the serialized result.
+	Transform(xmlData, stylesheet []byte, params []XSLTParam) ([]byte, error)
+}
+
+// XSLTProcEngine is the TransformEngine running the xsltproc command of
+// libxslt, with network access and DTD validation disabled. A failure of the
+// command carries its exit code and output, see XSLTTransformError.
+type XSLTProcEngine struct{}
+
+// Transform runs xsltproc on the XML data, passing each parameter with
+// --stringparam.
+func (XSLTProcEngine) Transform(xmlData, stylesheet []byte, params []XSLTParam) ([]byte, error) {
+	args := make([]string, 0, 3*len(params))
+	for _, param := range params {
+		args = append(args, "--stringparam", param.Name, param.Value)
+	}
+	return runXSLTProc(xmlData, stylesheet, args...)
+}
+
+// NativeEngine is the TransformEngine using the XSLT 1.0 processor of the
+// xslt package, which needs no external tools. It supports the stylesheets
+// embedded in tsl-tool; stylesheets using features it lacks, such as
+// xsl:include or keys, fail with xslt.ErrUnsupported. Compiled stylesheets are
+// kept by the digest of their content.
+type NativeEngine struct {
+	mu     sync.RWMutex
+	sheets map[[sha256.Size]byte]*xslt.Stylesheet
+}
+
+// Transform compiles the stylesheet, unless it was used before, and applies
+// it to the XML data.
+func (e *NativeEngine) Transform(xmlData, stylesheet []byte, params []XSLTParam) ([]byte, error) {
+	sheet, err := e.compile(stylesheet)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]string, len(params))
+	for _, param := range params {
+		values[param.Name] = param.Value
+	}
+	return sheet.Transform(xmlData, values)
+}
+
+// compile returns the compiled form of the stylesheet.
+func (e *NativeEngine) compile(stylesheet []byte) (*xslt.Stylesheet, error) {
+	key := sha256.Sum256(stylesheet)
+	e.mu.RLock()
+	sheet, ok := e.sheets[key]
+	e.mu.RUnlock()
+	if ok {
+		return sheet, nil
+	}
+	sheet, err := xslt.Compile(stylesheet)
+	if err != nil {
+		return nil, err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.sheets == nil {
+		e.sheets = make(map[[sha256.Size]byte]*xslt.Stylesheet)
+	}
+	e.sheets[key] = sheet
+	return sheet, nil
+}
+
+// transformEngines holds the engines selectable with the engine: option of
+// the transform step.
+var transformEngines = struct {
+	mu      sync.RWMutex
+	engines map[string]TransformEngine
+}{engines: map[string]TransformEngine{
+	"xsltproc": XSLTProcEngine{},
+	"native":   &NativeEngine{},
+}}
+
+// RegisterTransformEngine makes a TransformEngine available to the transform
+// step as engine:name, replacing an engine registered under that name. The
+// name "auto" is reserved.
+//
+// This function is thread-safe due to mutex protection.
+func RegisterTransformEngine(name string, engine TransformEngine) {
+	transformEngines.mu.Lock()
+	defer transformEngines.mu.Unlock()
+	transformEngines.engines[name] = engine
+}
+
+// transformEngine returns the engine registered under name along with its
+// name. For "auto" or an empty name it is xsltproc if the command is
+// installed and the native engine otherwise.
+func transformEngine(name string) (string, TransformEngine, error) {
+	if name == "" || name == "auto" {
+		name = "native"
+		if _, err := exec.LookPath(xsltprocCommand); err == nil {
+			name = "xsltproc"
+		}
+	}
+	transformEngines.mu.RLock()
+	defer transformEngines.mu.RUnlock()
+	engine, ok := transformEngines.engines[name]
+	if !ok {
+		names := make([]string, 0, len(transformEngines.engines)+1)
+		for known := range transformEngines.engines {
+			names = append(names, known)
+		}
+		names = append(names, "auto")
+		sort.Strings(names)
+		return "", nil, fmt.Errorf("%w: unknown transform engine %q (expected %s)",
+			ErrInvalidArguments, name, strings.Join(names, ", "))
+	}
+	return name, engine, nil
+}
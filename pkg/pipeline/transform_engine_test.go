@@ -0,0 +1,135 @@
+package pipeline
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirosfoundation/g119612/pkg/logging"
+	"github.com/sirosfoundation/g119612/pkg/xslt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingEngine is a TransformEngine returning the parameters it was given.
+type recordingEngine struct{}
+
+func (recordingEngine) Transform(xmlData, stylesheet []byte, params []XSLTParam) ([]byte, error) {
+	out := "<params>"
+	for _, param := range params {
+		out += "<" + param.Name + ">" + param.Value + "</" + param.Name + ">"
+	}
+	return []byte(out + "</params>"), nil
+}
+
+func TestTransformTSL_NativeEngine(t *testing.T) {
+	pl := &Pipeline{Logger: logging.SilentLogger()}
+	ctx := NewContext()
+	ctx.AddTSL(generateTSL("Test Service", "http://uri.etsi.org/TrstSvc/Svctype/CA/QC", []string{TestCertBase64}))
+	outDir := filepath.Join(t.TempDir(), "out")
+
+	_, err := TransformTSL(pl, ctx, "embedded:tsl-to-html.xslt", outDir, "html",
+		"engine:native", "lang:fr", "param:tsl.services=Prestataires")
+	require.NoError(t, err)
+	files, err := os.ReadDir(outDir)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	data, err := os.ReadFile(filepath.Join(outDir, files[0].Name()))
+	require.NoError(t, err)
+	out := string(data)
+	assert.Contains(t, out, "<html")
+	assert.Contains(t, out, "Liste de confiance")
+	assert.Contains(t, out, "<h4>Prestataires</h4>")
+	assert.Contains(t, out, "Test Service")
+
+	_, err = TransformTSL(pl, ctx, "embedded:tsl-to-html.xslt", outDir, "html", "engine:saxon")
+	assert.ErrorIs(t, err, ErrInvalidArguments)
+	assert.Equal(t, []string{outDir}, transformOutputs("embedded:tsl-to-html.xslt", "engine:native", outDir, "html"))
+}
+
+func TestTransformTSL_NativeEngineFailure(t *testing.T) {
+	dir := t.TempDir()
+	xsltPath := filepath.Join(dir, "include.xslt")
+	require.NoError(t, os.WriteFile(xsltPath, []byte(`<xsl:stylesheet version="1.0" xmlns:xsl="http://www.w3.org/1999/XSL/Transform">
+  <xsl:include href="other.xslt"/>
+</xsl:stylesheet>`), 0644))
+	ctx := NewContext()
+	ctx.AddTSL(generateTSL("Test Service", "http://uri.etsi.org/TrstSvc/Svctype/CA/QC", nil))
+	keepDir := filepath.Join(dir, "failed")
+
+	_, err := TransformTSL(nil, ctx, xsltPath, filepath.Join(dir, "out"), "engine:native", "keep-failed:"+keepDir)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, xslt.ErrUnsupported)
+	var transformErr *XSLTTransformError
+	require.True(t, errors.As(err, &transformErr))
+	assert.Zero(t, transformErr.ExitCode)
+	require.Equal(t, filepath.Join(keepDir, "tsl-0-input.xml"), transformErr.KeptInput)
+	assert.FileExists(t, transformErr.KeptInput)
+	assert.NoFileExists(t, filepath.Join(keepDir, "tsl-0-stderr.txt"))
+}
+
+func TestRegisterTransformEngine(t *testing.T) {
+	RegisterTransformEngine("recording", recordingEngine{})
+	defer func() {
+		transformEngines.mu.Lock()
+		delete(transformEngines.engines, "recording")
+		transformEngines.mu.Unlock()
+	}()
+
+	ctx := NewContext()
+	ctx.AddTSL(generateTSL("Test Service", "http://uri.etsi.org/TrstSvc/Svctype/CA/QC", nil))
+	outDir := filepath.Join(t.TempDir(), "out")
+	_, err := TransformTSL(nil, ctx, "embedded:tsl-to-html.xslt", outDir, "xml",
+		"engine:recording", "param:title=A", "param:title=B")
+	require.NoError(t, err)
+	files, err := os.ReadDir(outDir)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	data, err := os.ReadFile(filepath.Join(outDir, files[0].Name()))
+	require.NoError(t, err)
+	assert.Equal(t, "<params><title>B</title></params>", string(data))
+}
+
+func TestTransformEngineAuto(t *testing.T) {
+	saved := xsltprocCommand
+	defer func() { xsltprocCommand = saved }()
+
+	xsltprocCommand = filepath.Join(t.TempDir(), "missing-xsltproc")
+	name, engine, err := transformEngine("auto")
+	require.NoError(t, err)
+	assert.Equal(t, "native", name)
+	assert.IsType(t, &NativeEngine{}, engine)
+
+	fake := filepath.Join(t.TempDir(), "xsltproc")
+	require.NoError(t, os.WriteFile(fake, []byte("#!/bin/sh\n"), 0755))
+	xsltprocCommand = fake
+	name, engine, err = transformEngine("")
+	require.NoError(t, err)
+	assert.Equal(t, "xsltproc", name)
+	assert.Equal(t, XSLTProcEngine{}, engine)
+
+	_, _, err = transformEngine("saxon")
+	require.ErrorIs(t, err, ErrInvalidArguments)
+	assert.Contains(t, err.Error(), "auto, native, xsltproc")
+}
+
+func TestNativeEngineCache(t *testing.T) {
+	engine := &NativeEngine{}
+	stylesheet := []byte(`<xsl:stylesheet version="1.0" xmlns:xsl="http://www.w3.org/1999/XSL/Transform">
+  <xsl:output method="text"/>
+  <xsl:param name="greeting" select="'Hello'"/>
+  <xsl:template match="/"><xsl:value-of select="concat($greeting, ' ', doc)"/></xsl:template>
+</xsl:stylesheet>`)
+	out, err := engine.Transform([]byte("<doc>world</doc>"), stylesheet, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "Hello world", string(out))
+	out, err = engine.Transform([]byte("<doc>world</doc>"), stylesheet, []XSLTParam{{"greeting", "Hej"}})
+	require.NoError(t, err)
+	assert.Equal(t, "Hej world", string(out))
+	assert.Len(t, engine.sheets, 1)
+
+	_, err = engine.Transform([]byte("<doc/>"), []byte("<not-a-stylesheet/>"), nil)
+	assert.Error(t, err)
+	assert.Len(t, engine.sheets, 1)
+}
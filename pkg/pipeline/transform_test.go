@@ -1,25 +1,23 @@
 package pipeline
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/xml"
+	"errors"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 	"testing"
 
 	"github.com/sirosfoundation/g119612/pkg/etsi119612"
+	"github.com/sirosfoundation/g119612/pkg/logging"
 	"github.com/sirosfoundation/g119612/pkg/xslt"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
 func TestTransformTSL(t *testing.T) {
-	// Skip if xsltproc is not available
-	if _, err := exec.LookPath("xsltproc"); err != nil {
-		t.Skip("xsltproc not available, skipping test")
-	}
-
 	// Create a temporary directory for testing
 	tempDir, err := os.MkdirTemp("", "tsl-transform-test-")
 	require.NoError(t, err)
@@ -145,11 +143,6 @@ func TestTransformTSL(t *testing.T) {
 
 // TestEmbeddedTransformTSL tests the embedded XSLT functionality
 func TestEmbeddedTransformTSL(t *testing.T) {
-	// Skip if xsltproc is not available
-	if _, err := exec.LookPath("xsltproc"); err != nil {
-		t.Skip("xsltproc not available, skipping test")
-	}
-
 	// Test IsEmbeddedPath function
 	t.Run("Test Embedded Path Detection", func(t *testing.T) {
 		regularPath := "/path/to/file.xslt"
@@ -285,3 +278,112 @@ func TestEmbeddedTransformTSL(t *testing.T) {
 		})
 	}
 }
+
+func TestTransformTSL_FailureDetails(t *testing.T) {
+	dir := t.TempDir()
+	// A stand-in for xsltproc that writes partial output and fails
+	fake := filepath.Join(dir, "xsltproc")
+	require.NoError(t, os.WriteFile(fake, []byte("#!/bin/sh\necho '<html>partial'\necho 'compilation error: element foo' >&2\nexit 6\n"), 0755))
+	saved := xsltprocCommand
+	xsltprocCommand = fake
+	defer func() { xsltprocCommand = saved }()
+
+	ctx := NewContext()
+	ctx.AddTSL(generateTSL("Test Service", "http://uri.etsi.org/TrstSvc/Svctype/CA/QC", []string{TestCertBase64}))
+	keepDir := filepath.Join(dir, "failed")
+
+	_, err := TransformTSL(nil, ctx, "embedded:tsl-to-html.xslt", filepath.Join(dir, "out"), "html", "keep-failed:"+keepDir)
+	require.Error(t, err)
+
+	var transformErr *XSLTTransformError
+	require.True(t, errors.As(err, &transformErr), err.Error())
+	assert.Equal(t, 6, transformErr.ExitCode)
+	assert.Equal(t, "compilation error: element foo", transformErr.Stderr)
+	assert.Equal(t, "<html>partial", transformErr.Stdout)
+	assert.Contains(t, err.Error(), "compilation error: element foo")
+	assert.Contains(t, err.Error(), "exit code 6")
+
+	require.Equal(t, filepath.Join(keepDir, "tsl-0-input.xml"), transformErr.KeptInput)
+	input, err := os.ReadFile(transformErr.KeptInput)
+	require.NoError(t, err)
+	assert.Contains(t, string(input), "Test Service")
+	stderr, err := os.ReadFile(filepath.Join(keepDir, "tsl-0-stderr.txt"))
+	require.NoError(t, err)
+	assert.Contains(t, string(stderr), "compilation error")
+	stdout, err := os.ReadFile(filepath.Join(keepDir, "tsl-0-stdout.txt"))
+	require.NoError(t, err)
+	assert.Contains(t, string(stdout), "partial")
+
+	// Without the option nothing is kept
+	_, err = TransformTSL(nil, ctx, "embedded:tsl-to-html.xslt", filepath.Join(dir, "out"), "html")
+	require.True(t, errors.As(err, &transformErr))
+	assert.Empty(t, transformErr.KeptInput)
+}
+
+func TestStylesheetDigest(t *testing.T) {
+	digest, err := stylesheetDigest("embedded:tsl-to-html.xslt", true)
+	require.NoError(t, err)
+	pinned, err := xslt.Digest("tsl-to-html.xslt")
+	require.NoError(t, err)
+	assert.Equal(t, pinned, digest)
+
+	path := filepath.Join(t.TempDir(), "test.xslt")
+	require.NoError(t, os.WriteFile(path, []byte("stylesheet"), 0644))
+	digest, err = stylesheetDigest(path, false)
+	require.NoError(t, err)
+	sum := sha256.Sum256([]byte("stylesheet"))
+	assert.Equal(t, hex.EncodeToString(sum[:]), digest)
+
+	_, err = stylesheetDigest("embedded:nonexistent.xslt", true)
+	assert.Error(t, err)
+}
+
+func TestTruncateOutput(t *testing.T) {
+	assert.Equal(t, "short", truncateOutput([]byte(" short\n")))
+	long := truncateOutput([]byte(strings.Repeat("x", maxTransformOutput+10)))
+	assert.True(t, strings.HasPrefix(long, strings.Repeat("x", maxTransformOutput)+"..."))
+	assert.Contains(t, long, "10 bytes truncated")
+}
+
+func TestTransformTSL_Params(t *testing.T) {
+	dir := t.TempDir()
+	// A stand-in for xsltproc that outputs its arguments
+	fake := filepath.Join(dir, "xsltproc")
+	require.NoError(t, os.WriteFile(fake, []byte("#!/bin/sh\nfor arg in \"$@\"; do echo \"$arg\"; done\n"), 0755))
+	saved := xsltprocCommand
+	xsltprocCommand = fake
+	defer func() { xsltprocCommand = saved }()
+
+	pl := &Pipeline{Logger: logging.SilentLogger()}
+	ctx := NewContext()
+	ctx.AddTSL(generateTSL("Test Service", "http://uri.etsi.org/TrstSvc/Svctype/CA/QC", nil))
+	outDir := filepath.Join(dir, "out")
+	_, err := TransformTSL(pl, ctx, "embedded:tsl-to-html.xslt", outDir, "html",
+		"param:title=EU Trust Lists", "param:baseurl=https://trust.example.com", "lang:fr", "param:tsl.services=Prestataires")
+	require.NoError(t, err)
+	files, err := os.ReadDir(outDir)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	data, err := os.ReadFile(filepath.Join(outDir, files[0].Name()))
+	require.NoError(t, err)
+	out := string(data)
+	assert.Contains(t, out, "--stringparam\ntitle\nEU Trust Lists\n")
+	assert.Contains(t, out, "--stringparam\nbaseurl\nhttps://trust.example.com\n")
+	assert.Contains(t, out, "--stringparam\nlang\nfr\n")
+	// A parameter replaces the label of the same name
+	assert.Contains(t, out, "--stringparam\ntsl.services\nPrestataires\n")
+	assert.Equal(t, 1, strings.Count(out, "\ntsl.services\n"), out)
+
+	for _, arg := range []string{"param:title", "param:=value", "param:1st=value", "param:ns:name=value"} {
+		_, err = TransformTSL(pl, ctx, "embedded:tsl-to-html.xslt", outDir, "html", arg)
+		assert.ErrorIs(t, err, ErrInvalidArguments, arg)
+	}
+	assert.Equal(t, []string{outDir}, transformOutputs("embedded:tsl-to-html.xslt", "param:title=Lists", outDir, "html"))
+}
+
+func TestWithXSLTParams(t *testing.T) {
+	base := []XSLTParam{{"lang", "sv"}, {"title", "Listor"}}
+	assert.Equal(t, base, withXSLTParams(base, nil))
+	assert.Equal(t, []XSLTParam{{"lang", "sv"}, {"title", "B"}, {"x", "=y"}},
+		withXSLTParams(base, []XSLTParam{{"title", "A"}, {"title", "B"}, {"x", "=y"}}))
+}
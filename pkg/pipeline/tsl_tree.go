@@ -50,7 +50,7 @@ func buildTSLNode(tsl *etsi119612.TSL) *TSLNode {
 }
 
 // Traverse executes a function on each TSL in the tree in pre-order
-// (parent first, then children)
+// (parent first, then children in the order of their pointers)
 func (tree *TSLTree) Traverse(fn func(*etsi119612.TSL)) {
 	if tree.Root == nil {
 		return
@@ -116,7 +116,7 @@ func (tree *TSLTree) ItselfOrChild(tsl *etsi119612.TSL) bool {
 	return found
 }
 
-// ToSlice converts the tree to a flat slice of TSLs
+// ToSlice converts the tree to a flat slice of TSLs in the order of Traverse
 func (tree *TSLTree) ToSlice() []*etsi119612.TSL {
 	if tree.Root == nil {
 		return []*etsi119612.TSL{}
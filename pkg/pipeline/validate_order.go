@@ -0,0 +1,146 @@
+package pipeline
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Severity is the severity of an Issue found by Pipeline.Check.
+type Severity string
+
+const (
+	// SeverityWarning marks a step that runs but has no effect.
+	SeverityWarning Severity = "warning"
+	// SeverityError marks a step that is bound to fail when it is reached.
+	SeverityError Severity = "error"
+)
+
+// Issue is a problem with the order of the steps of a pipeline, such as a
+// select step before any load step.
+type Issue struct {
+	Step     string   `json:"step"` // Position of the step, e.g. "3" or "2.then.0" inside a conditional step
+	Name     string   `json:"name"` // Name of the step
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+}
+
+func (i Issue) String() string {
+	return fmt.Sprintf("%s: step %s (%s): %s", i.Severity, i.Step, i.Name, i.Message)
+}
+
+// Names of the built-in steps by what they do with the context, used by Check.
+var (
+	// Steps adding TSLs to the context
+	tslSourceSteps = stepSet("load", "generate")
+	// Steps building a certificate pool
+	poolSourceSteps = stepSet("select", "select-cert-pool")
+	// Steps fetching with the options of set-fetch-options
+	fetchingSteps = stepSet("load", "compare-remote")
+	// Steps failing without TSLs in the context
+	tslConsumingSteps = stepSet("select", "select-cert-pool", "publish", "transform", "render",
+		"mirror", "compare-remote", "export-notification", "export-oidfed", "change-report",
+		"validate-schema")
+	// Steps failing without a certificate pool
+	poolConsumingSteps = stepSet("publish-oci", "verify", "publish-system-store")
+	// Other built-in steps, which neither need nor add anything
+	neutralSteps = stepSet("echo", "log", "set-fetch-options", "set-language", "generate_index", "check-links")
+)
+
+func stepSet(names ...string) map[string]bool {
+	set := make(map[string]bool, len(names))
+	for _, name := range names {
+		set[name] = true
+	}
+	return set
+}
+
+// orderState is what the steps checked so far may have put into the context.
+type orderState struct {
+	tsls bool // Some step before may have added TSLs
+	pool bool // Some step before may have built a certificate pool
+}
+
+// Check reports steps that are bound to fail or have no effect because of
+// their position in the pipeline, without running it:
+//
+//   - a step that needs TSLs, such as select, publish or mirror, with no load
+//     or generate step before it is an error
+//   - a step that needs a certificate pool, such as publish-oci, with no select
+//     step before it is an error
+//   - a set-fetch-options step with no load or compare-remote step after it is
+//     a warning, since the options are never used
+//
+// A step inside a branch of a conditional step counts as running before the
+// steps following the conditional step. Steps Check does not know, such as
+// custom steps registered by an application, are assumed to add TSLs, build a
+// certificate pool and fetch, so they never cause an issue.
+//
+// Validate, and so NewPipeline, fails on the first error and logs the warnings.
+//
+// Returns:
+//   - The issues in the order of the steps, nil if there are none
+func (pl *Pipeline) Check() []Issue {
+	var issues []Issue
+	checkOrder(pl.Pipes, "", orderState{}, false, &issues)
+	return issues
+}
+
+// checkOrder checks a sequence of steps starting in state, appending the
+// issues found to issues, and returns the state after the steps. fetchFollows
+// tells whether a fetching step may run after the sequence.
+func checkOrder(pipes []Pipe, prefix string, state orderState, fetchFollows bool, issues *[]Issue) orderState {
+	for i, pipe := range pipes {
+		step := prefix + strconv.Itoa(i)
+		report := func(severity Severity, format string, args ...any) {
+			*issues = append(*issues, Issue{Step: step, Name: pipe.MethodName, Severity: severity, Message: fmt.Sprintf(format, args...)})
+		}
+		name := pipe.MethodName
+		switch {
+		case name == ConditionalStep:
+			follows := fetchFollows || mayFetch(pipes[i+1:])
+			thenState := checkOrder(pipe.Then, step+".then.", state, follows, issues)
+			elseState := checkOrder(pipe.Else, step+".else.", state, follows, issues)
+			state = orderState{tsls: thenState.tsls || elseState.tsls, pool: thenState.pool || elseState.pool}
+			continue
+		case !knownStep(name):
+			state = orderState{tsls: true, pool: true}
+			continue
+		}
+
+		if tslConsumingSteps[name] && !state.tsls {
+			report(SeverityError, "no load or generate step before it adds TSLs")
+		}
+		if poolConsumingSteps[name] && !state.pool {
+			report(SeverityError, "no select step before it builds a certificate pool")
+		}
+		if name == "set-fetch-options" && !fetchFollows && !mayFetch(pipes[i+1:]) {
+			report(SeverityWarning, "no load or compare-remote step after it uses the fetch options")
+		}
+		state.tsls = state.tsls || tslSourceSteps[name]
+		state.pool = state.pool || poolSourceSteps[name]
+	}
+	return state
+}
+
+// mayFetch reports whether any of pipes, or of the steps in their branches,
+// may fetch with the options of set-fetch-options.
+func mayFetch(pipes []Pipe) bool {
+	for _, pipe := range pipes {
+		if pipe.MethodName == ConditionalStep {
+			if mayFetch(pipe.Then) || mayFetch(pipe.Else) {
+				return true
+			}
+			continue
+		}
+		if fetchingSteps[pipe.MethodName] || !knownStep(pipe.MethodName) {
+			return true
+		}
+	}
+	return false
+}
+
+// knownStep reports whether Check knows what the step name does.
+func knownStep(name string) bool {
+	return tslSourceSteps[name] || poolSourceSteps[name] || fetchingSteps[name] ||
+		tslConsumingSteps[name] || poolConsumingSteps[name] || neutralSteps[name]
+}
@@ -0,0 +1,92 @@
+package pipeline
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestPipelineCheck(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		want []Issue
+	}{
+		{"ordered", `
+- set-fetch-options: ["timeout:30s"]
+- load: ["tsl.xml"]
+- select: []
+- publish-oci: ["registry.example.com/pool"]
+- publish: ["/tmp/out"]
+`, nil},
+		{"select before load", `
+- select: []
+- load: ["tsl.xml"]
+`, []Issue{{Step: "0", Name: "select", Severity: SeverityError, Message: "no load or generate step before it adds TSLs"}}},
+		{"publish-oci without select", `
+- load: ["tsl.xml"]
+- publish-oci: ["registry.example.com/pool"]
+`, []Issue{{Step: "1", Name: "publish-oci", Severity: SeverityError, Message: "no select step before it builds a certificate pool"}}},
+		{"fetch options after loads", `
+- load: ["tsl.xml"]
+- set-fetch-options: ["timeout:30s"]
+- publish: ["/tmp/out"]
+`, []Issue{{Step: "1", Name: "set-fetch-options", Severity: SeverityWarning, Message: "no load or compare-remote step after it uses the fetch options"}}},
+		{"fetch options before compare-remote", `
+- generate: ["/tmp/metadata"]
+- set-fetch-options: ["timeout:30s"]
+- compare-remote: ["https://example.com/tsl.xml"]
+`, nil},
+		{"conditional branches", `
+- if: "tsl-count == 0"
+  then:
+    - set-fetch-options: ["timeout:30s"]
+    - mirror: ["/tmp/mirror"]
+  else:
+    - generate: ["/tmp/metadata"]
+- load: ["tsl.xml"]
+- select: []
+`, []Issue{{Step: "0.then.1", Name: "mirror", Severity: SeverityError, Message: "no load or generate step before it adds TSLs"}}},
+		{"load in a branch", `
+- if: "tsl-count == 0"
+  then:
+    - load: ["tsl.xml"]
+- select: []
+`, nil},
+		{"unknown step", `
+- custom-source: []
+- set-fetch-options: ["timeout:30s"]
+- select: []
+- custom-fetch: []
+`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var pipes []Pipe
+			require.NoError(t, yaml.Unmarshal([]byte(tt.yaml), &pipes))
+			pl := &Pipeline{Pipes: pipes}
+			assert.Equal(t, tt.want, pl.Check())
+		})
+	}
+}
+
+func TestPipelineValidate_StepOrder(t *testing.T) {
+	_, err := loadTestPipeline(t, `
+- load: ["tsl.xml"]
+- if: "tsl-count > 0"
+  then:
+    - publish-oci: ["registry.example.com/pool"]
+`)
+	require.ErrorIs(t, err, ErrStepOrder)
+	assert.ErrorContains(t, err, "step 1.then.0 (publish-oci)")
+
+	// Warnings do not fail the pipeline
+	pl, err := loadTestPipeline(t, `
+- load: ["tsl.xml"]
+- set-fetch-options: ["timeout:30s"]
+`)
+	require.NoError(t, err)
+	assert.Len(t, pl.Check(), 1)
+}
@@ -0,0 +1,135 @@
+package pipeline
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Values holds the variables of a values file, which parameterize a shared
+// pipeline definition for one tenant: output directories, territory filters,
+// signer configuration and the like. A pipeline loaded with values is a Go
+// text/template executed before the YAML is parsed, with the values as
+// .Values, so a publish step can write to "{{ .Values.outputDir }}" and a
+// set-fetch-options step filter by
+// "filter-territory:{{ join .Values.territories "," }}".
+//
+// A reference to a value that is not set fails the load rather than expanding
+// to an empty string. Besides the standard template functions, join joins a
+// list with a separator and quote quotes a string for YAML.
+type Values map[string]interface{}
+
+// LoadValues reads values files in YAML. Later files override the values of
+// earlier ones; nested maps are merged key by key, other values, lists
+// included, are replaced.
+func LoadValues(files ...string) (Values, error) {
+	values := Values{}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if err := values.merge(data); err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+	}
+	return values, nil
+}
+
+// LoadSignedValues reads values files like LoadValues after verifying the
+// detached signature of each, in the file named after it with SignatureSuffix.
+// Values decide as much as the pipeline itself what is trusted and where it
+// is published, so signed pipelines must only be combined with signed values.
+func LoadSignedValues(signers PipelineSigners, files ...string) (Values, error) {
+	values := Values{}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		signature, err := os.ReadFile(file + SignatureSuffix)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %w", ErrPipelineSignature, file, err)
+		}
+		if err := signers.Verify(data, signature); err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		if err := values.merge(data); err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+	}
+	return values, nil
+}
+
+// merge merges the values of a YAML document into v.
+func (v Values) merge(data []byte) error {
+	var doc map[string]interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("failed to parse values YAML: %w", err)
+	}
+	mergeValues(v, doc)
+	return nil
+}
+
+// mergeValues merges src into dst, descending into the maps both have.
+func mergeValues(dst, src map[string]interface{}) {
+	for key, value := range src {
+		srcMap, srcIsMap := value.(map[string]interface{})
+		dstMap, dstIsMap := dst[key].(map[string]interface{})
+		if srcIsMap && dstIsMap {
+			mergeValues(dstMap, srcMap)
+			continue
+		}
+		dst[key] = value
+	}
+}
+
+// valueFuncs are the functions available to pipeline templates besides the
+// standard ones.
+var valueFuncs = template.FuncMap{
+	"join": func(list []interface{}, sep string) string {
+		items := make([]string, len(list))
+		for i, item := range list {
+			items[i] = fmt.Sprint(item)
+		}
+		return strings.Join(items, sep)
+	},
+	"quote": func(value interface{}) (string, error) {
+		data, err := yaml.Marshal(fmt.Sprint(value))
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSuffix(string(data), "\n"), nil
+	},
+}
+
+// expandValues executes the pipeline template data with values.
+func expandValues(name string, data []byte, values Values) ([]byte, error) {
+	tmpl, err := template.New(name).Funcs(valueFuncs).Option("missingkey=error").Parse(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse pipeline template: %w", err)
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, map[string]interface{}{"Values": map[string]interface{}(values)}); err != nil {
+		return nil, fmt.Errorf("failed to expand pipeline template: %w", err)
+	}
+	return out.Bytes(), nil
+}
+
+// NewPipelineWithValues loads a pipeline like NewPipeline after expanding the
+// file as a template with values (see Values). This lets many tenants share
+// one pipeline definition, each with its own values file.
+func NewPipelineWithValues(filename string, values Values) (*Pipeline, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	if data, err = expandValues(filename, data, values); err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return parsePipeline(data, nil)
+}
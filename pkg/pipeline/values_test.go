@@ -0,0 +1,101 @@
+package pipeline
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const valuesPipelineYAML = `- set-fetch-options:
+    - filter-territory:{{ join .Values.territories "," }}
+- load: [./testdata/test-tsl.xml]
+- log: [{{ quote .Values.message }}]
+- publish: [{{ .Values.output.dir }}]
+`
+
+func TestLoadValues(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "base.yaml")
+	tenant := filepath.Join(dir, "tenant.yaml")
+	require.NoError(t, os.WriteFile(base, []byte("territories: [SE, FI]\noutput:\n  dir: /srv/tsl\n  mode: \"0644\"\n"), 0644))
+	require.NoError(t, os.WriteFile(tenant, []byte("territories: [NO]\noutput:\n  dir: /srv/customer-a\n"), 0644))
+
+	values, err := LoadValues(base, tenant)
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{"NO"}, values["territories"], "lists are replaced")
+	assert.Equal(t, map[string]interface{}{"dir": "/srv/customer-a", "mode": "0644"}, values["output"], "maps are merged")
+
+	_, err = LoadValues(filepath.Join(dir, "missing.yaml"))
+	assert.Error(t, err)
+	invalid := filepath.Join(dir, "invalid.yaml")
+	require.NoError(t, os.WriteFile(invalid, []byte("- not\n- a map\n"), 0644))
+	_, err = LoadValues(invalid)
+	assert.ErrorContains(t, err, "invalid.yaml")
+}
+
+func TestNewPipelineWithValues(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "pipeline.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(valuesPipelineYAML), 0644))
+
+	values := Values{
+		"territories": []interface{}{"SE", "FI"},
+		"message":     "tenant: customer-a",
+		"output":      map[string]interface{}{"dir": filepath.Join(dir, "out")},
+	}
+	pl, err := NewPipelineWithValues(file, values)
+	require.NoError(t, err)
+	require.Len(t, pl.Pipes, 4)
+	assert.Equal(t, []string{"filter-territory:SE,FI"}, pl.Pipes[0].MethodArguments)
+	assert.Equal(t, []string{"tenant: customer-a"}, pl.Pipes[2].MethodArguments)
+	assert.Equal(t, []string{filepath.Join(dir, "out")}, pl.Pipes[3].MethodArguments)
+
+	// Values that are not set fail the load
+	delete(values, "output")
+	_, err = NewPipelineWithValues(file, values)
+	assert.ErrorContains(t, err, "output")
+
+	broken := filepath.Join(dir, "broken.yaml")
+	require.NoError(t, os.WriteFile(broken, []byte("- log: [{{ .Values.message ]\n"), 0644))
+	_, err = NewPipelineWithValues(broken, values)
+	assert.ErrorContains(t, err, "failed to parse pipeline template")
+}
+
+func TestNewSignedPipelineWithValues(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	dir := t.TempDir()
+	signerFile := filepath.Join(dir, "ops.pem")
+	writeSignerPEM(t, signerFile, key, false)
+	signers, err := LoadPipelineSigners(signerFile)
+	require.NoError(t, err)
+
+	file := filepath.Join(dir, "pipeline.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(valuesPipelineYAML), 0644))
+	require.NoError(t, os.WriteFile(file+SignatureSuffix, signPipeline(t, key, []byte(valuesPipelineYAML)), 0644))
+	valuesData := []byte("territories: [SE]\nmessage: signed\noutput: {dir: " + filepath.Join(dir, "out") + "}\n")
+	valuesFile := filepath.Join(dir, "values.yaml")
+	require.NoError(t, os.WriteFile(valuesFile, valuesData, 0644))
+
+	// Unsigned values are refused
+	_, err = LoadSignedValues(signers, valuesFile)
+	assert.ErrorIs(t, err, ErrPipelineSignature)
+
+	require.NoError(t, os.WriteFile(valuesFile+SignatureSuffix, signPipeline(t, key, valuesData), 0644))
+	values, err := LoadSignedValues(signers, valuesFile)
+	require.NoError(t, err)
+	pl, err := NewSignedPipelineWithValues(file, "", signers, values)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"filter-territory:SE"}, pl.Pipes[0].MethodArguments)
+
+	// Tampered values are refused
+	require.NoError(t, os.WriteFile(valuesFile, append(valuesData, []byte("extra: true\n")...), 0644))
+	_, err = LoadSignedValues(signers, valuesFile)
+	assert.ErrorIs(t, err, ErrPipelineSignature)
+}
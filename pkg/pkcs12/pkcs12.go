@@ -0,0 +1,367 @@
+// Package pkcs12 writes PKCS#12 (RFC 7292) trust stores, the format enterprise
+// middleware such as Java application servers load trust anchors from.
+//
+// A trust store holds certificates only, each in a certificate bag with a
+// friendly name, the alias under which keytool and Java's KeyStore list it,
+// and the attribute Java requires to treat the certificate as a trusted entry.
+// The bags are encrypted with PBES2 (PBKDF2 with HMAC-SHA-256 and AES-256-CBC)
+// and the store is protected by an HMAC-SHA-256 MAC, the defaults of
+// OpenSSL 3, so it opens with keytool, openssl pkcs12 and other current
+// implementations.
+package pkcs12
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"hash"
+	"math/big"
+	"unicode/utf16"
+)
+
+// Iterations is the iteration count of the key derivations for the
+// encryption and the MAC.
+const Iterations = 2048
+
+var (
+	oidData                = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidEncryptedData       = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 6}
+	oidCertBag             = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 3}
+	oidCertTypeX509        = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 22, 1}
+	oidFriendlyName        = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 20}
+	oidJavaTrustedKeyUsage = asn1.ObjectIdentifier{2, 16, 840, 1, 113894, 746875, 1, 1}
+	oidAnyExtendedKeyUsage = asn1.ObjectIdentifier{2, 5, 29, 37, 0}
+	oidPBES2               = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2              = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidHMACWithSHA256      = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidAES256CBC           = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+	oidSHA256              = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+)
+
+// ErrEmptyPassword is returned when encoding a trust store without password.
+var ErrEmptyPassword = errors.New("pkcs12: the trust store password must not be empty")
+
+// TrustedCertificate is a certificate of a trust store.
+type TrustedCertificate struct {
+	Certificate *x509.Certificate
+
+	// FriendlyName is the alias of the certificate in the store. Aliases
+	// should be unique, as some implementations keep only one entry per alias.
+	FriendlyName string
+}
+
+type pfxPdu struct {
+	Version  int
+	AuthSafe contentInfo
+	MacData  macData
+}
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"tag:0,explicit"`
+}
+
+type encryptedData struct {
+	Version              int
+	EncryptedContentInfo encryptedContentInfo
+}
+
+type encryptedContentInfo struct {
+	ContentType                asn1.ObjectIdentifier
+	ContentEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedContent           []byte `asn1:"tag:0,optional"`
+}
+
+type safeBag struct {
+	ID         asn1.ObjectIdentifier
+	Value      asn1.RawValue     `asn1:"tag:0,explicit"`
+	Attributes []pkcs12Attribute `asn1:"set,optional"`
+}
+
+type certBag struct {
+	ID   asn1.ObjectIdentifier
+	Data []byte `asn1:"tag:0,explicit"`
+}
+
+type pkcs12Attribute struct {
+	ID    asn1.ObjectIdentifier
+	Value asn1.RawValue // SET OF the values
+}
+
+type macData struct {
+	Mac        digestInfo
+	MacSalt    []byte
+	Iterations int
+}
+
+type digestInfo struct {
+	Algorithm pkix.AlgorithmIdentifier
+	Digest    []byte
+}
+
+type pbes2Params struct {
+	KeyDerivationFunc pkix.AlgorithmIdentifier
+	EncryptionScheme  pkix.AlgorithmIdentifier
+}
+
+type pbkdf2Params struct {
+	Salt       []byte
+	Iterations int
+	PRF        pkix.AlgorithmIdentifier
+}
+
+// EncodeTrustStore returns a PKCS#12 trust store holding certs, encrypted and
+// protected with password.
+func EncodeTrustStore(certs []TrustedCertificate, password string) ([]byte, error) {
+	if password == "" {
+		return nil, ErrEmptyPassword
+	}
+
+	bags := make([]safeBag, 0, len(certs))
+	for i, cert := range certs {
+		if cert.Certificate == nil {
+			return nil, fmt.Errorf("pkcs12: certificate %d is nil", i)
+		}
+		bag, err := newCertBag(cert)
+		if err != nil {
+			return nil, err
+		}
+		bags = append(bags, bag)
+	}
+	safeContents, err := asn1.Marshal(bags)
+	if err != nil {
+		return nil, fmt.Errorf("pkcs12: %w", err)
+	}
+
+	encrypted, err := encrypt(safeContents, password)
+	if err != nil {
+		return nil, err
+	}
+	authSafe, err := asn1.Marshal([]contentInfo{encrypted})
+	if err != nil {
+		return nil, fmt.Errorf("pkcs12: %w", err)
+	}
+
+	pfx := pfxPdu{Version: 3}
+	pfx.AuthSafe.ContentType = oidData
+	if pfx.AuthSafe.Content, err = explicit(authSafe); err != nil {
+		return nil, err
+	}
+	if pfx.MacData, err = computeMac(authSafe, password); err != nil {
+		return nil, err
+	}
+	data, err := asn1.Marshal(pfx)
+	if err != nil {
+		return nil, fmt.Errorf("pkcs12: %w", err)
+	}
+	return data, nil
+}
+
+// newCertBag returns the certificate bag of cert with its friendly name and
+// the Java trusted key usage attributes.
+func newCertBag(cert TrustedCertificate) (safeBag, error) {
+	bag := safeBag{ID: oidCertBag}
+	var err error
+	if bag.Value, err = explicit(certBag{ID: oidCertTypeX509, Data: cert.Certificate.Raw}); err != nil {
+		return safeBag{}, err
+	}
+	if cert.FriendlyName != "" {
+		attr, err := newAttribute(oidFriendlyName, bmpString(cert.FriendlyName))
+		if err != nil {
+			return safeBag{}, err
+		}
+		bag.Attributes = append(bag.Attributes, attr)
+	}
+	usage, err := asn1.Marshal(oidAnyExtendedKeyUsage)
+	if err != nil {
+		return safeBag{}, fmt.Errorf("pkcs12: %w", err)
+	}
+	attr, err := newAttribute(oidJavaTrustedKeyUsage, usage)
+	if err != nil {
+		return safeBag{}, err
+	}
+	bag.Attributes = append(bag.Attributes, attr)
+	return bag, nil
+}
+
+// explicit returns value wrapped in the explicit [0] tag, which encoding/asn1
+// does not add to a RawValue itself.
+func explicit(value any) (asn1.RawValue, error) {
+	der, err := asn1.Marshal(value)
+	if err != nil {
+		return asn1.RawValue{}, fmt.Errorf("pkcs12: %w", err)
+	}
+	return asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: der}, nil
+}
+
+// newAttribute returns the attribute id with the single DER encoded value.
+func newAttribute(id asn1.ObjectIdentifier, value []byte) (pkcs12Attribute, error) {
+	set, err := asn1.Marshal(asn1.RawValue{Tag: asn1.TagSet, IsCompound: true, Bytes: value})
+	if err != nil {
+		return pkcs12Attribute{}, fmt.Errorf("pkcs12: %w", err)
+	}
+	return pkcs12Attribute{ID: id, Value: asn1.RawValue{FullBytes: set}}, nil
+}
+
+// bmpString returns the DER encoding of s as a BMPString.
+func bmpString(s string) []byte {
+	value := bmpBytes(s)
+	der, _ := asn1.Marshal(asn1.RawValue{Tag: asn1.TagBMPString, Bytes: value})
+	return der
+}
+
+// bmpBytes returns s as big-endian UTF-16 without terminator.
+func bmpBytes(s string) []byte {
+	units := utf16.Encode([]rune(s))
+	b := make([]byte, 0, 2*len(units))
+	for _, u := range units {
+		b = append(b, byte(u>>8), byte(u))
+	}
+	return b
+}
+
+// encrypt returns the encrypted data content info of plaintext, encrypted
+// with PBES2 under password.
+func encrypt(plaintext []byte, password string) (contentInfo, error) {
+	salt := make([]byte, 16)
+	iv := make([]byte, aes.BlockSize)
+	if _, err := rand.Read(salt); err != nil {
+		return contentInfo{}, fmt.Errorf("pkcs12: %w", err)
+	}
+	if _, err := rand.Read(iv); err != nil {
+		return contentInfo{}, fmt.Errorf("pkcs12: %w", err)
+	}
+	// PBES2 takes the password as it is, unlike the PKCS#12 key derivation
+	key, err := pbkdf2.Key(sha256.New, password, salt, Iterations, 32)
+	if err != nil {
+		return contentInfo{}, fmt.Errorf("pkcs12: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return contentInfo{}, fmt.Errorf("pkcs12: %w", err)
+	}
+	padding := aes.BlockSize - len(plaintext)%aes.BlockSize
+	ciphertext := append(bytes.Clone(plaintext), bytes.Repeat([]byte{byte(padding)}, padding)...)
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(ciphertext, ciphertext)
+
+	kdfParams, err := asn1.Marshal(pbkdf2Params{
+		Salt:       salt,
+		Iterations: Iterations,
+		PRF:        pkix.AlgorithmIdentifier{Algorithm: oidHMACWithSHA256, Parameters: asn1.NullRawValue},
+	})
+	if err != nil {
+		return contentInfo{}, fmt.Errorf("pkcs12: %w", err)
+	}
+	ivParam, err := asn1.Marshal(iv)
+	if err != nil {
+		return contentInfo{}, fmt.Errorf("pkcs12: %w", err)
+	}
+	params, err := asn1.Marshal(pbes2Params{
+		KeyDerivationFunc: pkix.AlgorithmIdentifier{Algorithm: oidPBKDF2, Parameters: asn1.RawValue{FullBytes: kdfParams}},
+		EncryptionScheme:  pkix.AlgorithmIdentifier{Algorithm: oidAES256CBC, Parameters: asn1.RawValue{FullBytes: ivParam}},
+	})
+	if err != nil {
+		return contentInfo{}, fmt.Errorf("pkcs12: %w", err)
+	}
+
+	data := encryptedData{EncryptedContentInfo: encryptedContentInfo{
+		ContentType:                oidData,
+		ContentEncryptionAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidPBES2, Parameters: asn1.RawValue{FullBytes: params}},
+		EncryptedContent:           ciphertext,
+	}}
+	info := contentInfo{ContentType: oidEncryptedData}
+	if info.Content, err = explicit(data); err != nil {
+		return contentInfo{}, err
+	}
+	return info, nil
+}
+
+// computeMac returns the MAC data of authSafe, an HMAC-SHA-256 keyed with the
+// key derived from password by the PKCS#12 key derivation.
+func computeMac(authSafe []byte, password string) (macData, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return macData{}, fmt.Errorf("pkcs12: %w", err)
+	}
+	mac := hmac.New(sha256.New, deriveMacKey(password, salt, Iterations))
+	mac.Write(authSafe)
+	return macData{
+		Mac: digestInfo{
+			Algorithm: pkix.AlgorithmIdentifier{Algorithm: oidSHA256, Parameters: asn1.NullRawValue},
+			Digest:    mac.Sum(nil),
+		},
+		MacSalt:    salt,
+		Iterations: Iterations,
+	}, nil
+}
+
+// deriveMacKey derives the MAC key from password with the PKCS#12 key
+// derivation of RFC 7292 appendix B.2 using SHA-256, which takes the password
+// as a NUL-terminated BMPString.
+func deriveMacKey(password string, salt []byte, iterations int) []byte {
+	return pkcs12KDF(sha256.New, 3, append(bmpBytes(password), 0, 0), salt, iterations, sha256.Size)
+}
+
+// pkcs12KDF implements the key derivation of RFC 7292 appendix B.2 for the
+// purpose id, returning size bytes.
+func pkcs12KDF(h func() hash.Hash, id byte, password, salt []byte, iterations, size int) []byte {
+	u := h().Size()
+	v := h().BlockSize()
+
+	D := bytes.Repeat([]byte{id}, v)
+	fill := func(b []byte) []byte {
+		if len(b) == 0 {
+			return nil
+		}
+		n := v * ((len(b) + v - 1) / v)
+		out := make([]byte, n)
+		for i := range out {
+			out[i] = b[i%len(b)]
+		}
+		return out
+	}
+	I := append(fill(salt), fill(password)...)
+
+	one := big.NewInt(1)
+	var out []byte
+	for len(out) < size {
+		digest := h()
+		digest.Write(D)
+		digest.Write(I)
+		A := digest.Sum(nil)
+		for r := 1; r < iterations; r++ {
+			digest = h()
+			digest.Write(A)
+			A = digest.Sum(nil)
+		}
+		out = append(out, A...)
+		if len(out) >= size {
+			break
+		}
+
+		// I_j = (I_j + B + 1) mod 2^(8v) for each v-byte block of I
+		B := new(big.Int).SetBytes(fill(A[:u])[:v])
+		B.Add(B, one)
+		for j := 0; j < len(I); j += v {
+			Ij := new(big.Int).SetBytes(I[j : j+v])
+			Ij.Add(Ij, B)
+			sum := Ij.Bytes()
+			if len(sum) > v {
+				sum = sum[len(sum)-v:]
+			}
+			block := I[j : j+v]
+			clear(block)
+			copy(block[v-len(sum):], sum)
+		}
+	}
+	return out[:size]
+}
@@ -0,0 +1,183 @@
+package pkcs12
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"math/big"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+	"unicode/utf16"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testCertificate(t *testing.T, cn string) *x509.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IsCA:         true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert
+}
+
+// decodeTrustStore verifies the MAC of a trust store written by
+// EncodeTrustStore, decrypts it and returns its certificates.
+func decodeTrustStore(t *testing.T, data []byte, password string) []TrustedCertificate {
+	t.Helper()
+	var pfx pfxPdu
+	rest, err := asn1.Unmarshal(data, &pfx)
+	require.NoError(t, err)
+	require.Empty(t, rest)
+	assert.Equal(t, 3, pfx.Version)
+
+	var authSafe []byte
+	_, err = asn1.Unmarshal(pfx.AuthSafe.Content.Bytes, &authSafe)
+	require.NoError(t, err)
+	mac := hmac.New(sha256.New, deriveMacKey(password, pfx.MacData.MacSalt, pfx.MacData.Iterations))
+	mac.Write(authSafe)
+	require.True(t, hmac.Equal(mac.Sum(nil), pfx.MacData.Mac.Digest), "MAC verification failed")
+
+	var infos []contentInfo
+	_, err = asn1.Unmarshal(authSafe, &infos)
+	require.NoError(t, err)
+	require.Len(t, infos, 1)
+	var encrypted encryptedData
+	_, err = asn1.Unmarshal(infos[0].Content.Bytes, &encrypted)
+	require.NoError(t, err)
+
+	var params pbes2Params
+	_, err = asn1.Unmarshal(encrypted.EncryptedContentInfo.ContentEncryptionAlgorithm.Parameters.FullBytes, &params)
+	require.NoError(t, err)
+	var kdf pbkdf2Params
+	_, err = asn1.Unmarshal(params.KeyDerivationFunc.Parameters.FullBytes, &kdf)
+	require.NoError(t, err)
+	var iv []byte
+	_, err = asn1.Unmarshal(params.EncryptionScheme.Parameters.FullBytes, &iv)
+	require.NoError(t, err)
+	key, err := pbkdf2.Key(sha256.New, password, kdf.Salt, kdf.Iterations, 32)
+	require.NoError(t, err)
+	block, err := aes.NewCipher(key)
+	require.NoError(t, err)
+	plaintext := append([]byte(nil), encrypted.EncryptedContentInfo.EncryptedContent...)
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plaintext, plaintext)
+	plaintext = plaintext[:len(plaintext)-int(plaintext[len(plaintext)-1])]
+
+	var bags []safeBag
+	_, err = asn1.Unmarshal(plaintext, &bags)
+	require.NoError(t, err)
+	var certs []TrustedCertificate
+	for _, bag := range bags {
+		assert.True(t, bag.ID.Equal(oidCertBag))
+		var cb certBag
+		_, err = asn1.Unmarshal(bag.Value.Bytes, &cb)
+		require.NoError(t, err)
+		cert, err := x509.ParseCertificate(cb.Data)
+		require.NoError(t, err)
+		entry := TrustedCertificate{Certificate: cert}
+		trusted := false
+		for _, attr := range bag.Attributes {
+			switch {
+			case attr.ID.Equal(oidFriendlyName):
+				var name asn1.RawValue
+				_, err = asn1.Unmarshal(attr.Value.Bytes, &name)
+				require.NoError(t, err)
+				require.Equal(t, asn1.TagBMPString, name.Tag)
+				units := make([]uint16, len(name.Bytes)/2)
+				for i := range units {
+					units[i] = uint16(name.Bytes[2*i])<<8 | uint16(name.Bytes[2*i+1])
+				}
+				entry.FriendlyName = string(utf16.Decode(units))
+			case attr.ID.Equal(oidJavaTrustedKeyUsage):
+				var usage asn1.ObjectIdentifier
+				_, err = asn1.Unmarshal(attr.Value.Bytes, &usage)
+				require.NoError(t, err)
+				trusted = usage.Equal(oidAnyExtendedKeyUsage)
+			}
+		}
+		assert.True(t, trusted, "certificate bag without the Java trusted key usage")
+		certs = append(certs, entry)
+	}
+	return certs
+}
+
+func TestEncodeTrustStore(t *testing.T) {
+	first := testCertificate(t, "First CA")
+	second := testCertificate(t, "Second CA")
+	data, err := EncodeTrustStore([]TrustedCertificate{
+		{Certificate: first, FriendlyName: "Myndigheten för digital förvaltning - Qualified CA"},
+		{Certificate: second},
+	}, "changeit")
+	require.NoError(t, err)
+
+	certs := decodeTrustStore(t, data, "changeit")
+	require.Len(t, certs, 2)
+	assert.Equal(t, first.Raw, certs[0].Certificate.Raw)
+	assert.Equal(t, "Myndigheten för digital förvaltning - Qualified CA", certs[0].FriendlyName)
+	assert.Equal(t, second.Raw, certs[1].Certificate.Raw)
+	assert.Empty(t, certs[1].FriendlyName)
+
+	// Each store has its own salts
+	again, err := EncodeTrustStore([]TrustedCertificate{{Certificate: first}}, "changeit")
+	require.NoError(t, err)
+	other, err := EncodeTrustStore([]TrustedCertificate{{Certificate: first}}, "changeit")
+	require.NoError(t, err)
+	assert.NotEqual(t, again, other)
+
+	_, err = EncodeTrustStore(nil, "")
+	assert.ErrorIs(t, err, ErrEmptyPassword)
+	_, err = EncodeTrustStore([]TrustedCertificate{{FriendlyName: "missing"}}, "changeit")
+	assert.ErrorContains(t, err, "certificate 0 is nil")
+}
+
+func TestEncodeTrustStore_OpenSSL(t *testing.T) {
+	openssl, err := exec.LookPath("openssl")
+	if err != nil {
+		t.Skip("openssl not installed")
+	}
+	cert := testCertificate(t, "OpenSSL CA")
+	data, err := EncodeTrustStore([]TrustedCertificate{{Certificate: cert, FriendlyName: "openssl-ca"}}, "changeit")
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "trust.p12")
+	require.NoError(t, os.WriteFile(path, data, 0600))
+
+	out, err := exec.Command(openssl, "pkcs12", "-in", path, "-passin", "pass:changeit", "-nokeys").CombinedOutput()
+	require.NoError(t, err, string(out))
+	assert.Contains(t, string(out), "friendlyName: openssl-ca")
+	assert.Contains(t, string(out), "subject=CN = OpenSSL CA")
+
+	out, err = exec.Command(openssl, "pkcs12", "-in", path, "-passin", "pass:wrong", "-nokeys").CombinedOutput()
+	assert.Error(t, err, string(out))
+}
+
+func TestPKCS12KDF(t *testing.T) {
+	// Test vector of the SHA-1 PKCS#12 key derivation from the Bouncy Castle
+	// test suite, for the encryption key (id 1) of password "smeg"
+	password := append(bmpBytes("smeg"), 0, 0)
+	salt, _ := hex.DecodeString("0a58cf64530d823f")
+	key := pkcs12KDF(sha1.New, 1, password, salt, 1, 24)
+	assert.Equal(t, "8aaae6297b6cb04642ab5b077851284eb7128f1a2a7fbca3", hex.EncodeToString(key))
+}
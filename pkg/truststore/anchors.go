@@ -0,0 +1,163 @@
+package truststore
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultPrefix is the file name prefix of the certificates installed in an
+// anchors directory.
+const DefaultPrefix = "g119612-"
+
+// anchorsExtension is the extension of installed certificate files, the one
+// required by update-ca-certificates.
+const anchorsExtension = ".crt"
+
+// AnchorLocation is an anchors directory of a Linux distribution and the
+// command regenerating the system bundles from it.
+type AnchorLocation struct {
+	Dir    string
+	Update []string
+}
+
+// AnchorLocations are the anchors directories of the common Linux
+// distributions, in the order DetectAnchorStore tries them.
+var AnchorLocations = []AnchorLocation{
+	{Dir: "/etc/pki/ca-trust/source/anchors", Update: []string{"update-ca-trust", "extract"}}, // Fedora, RHEL
+	{Dir: "/usr/local/share/ca-certificates", Update: []string{"update-ca-certificates"}},     // Debian, Ubuntu, Alpine
+	{Dir: "/etc/pki/trust/anchors", Update: []string{"update-ca-certificates"}},               // SUSE
+}
+
+// AnchorStore installs certificates as PEM files into an anchors directory
+// of the Linux CA certificate tools, such as /etc/pki/ca-trust/source/anchors
+// with update-ca-trust or /usr/local/share/ca-certificates with
+// update-ca-certificates, and runs the update command of the distribution.
+//
+// Each certificate is written to Dir as Prefix + fingerprint + ".crt"; files
+// without the prefix are left alone.
+type AnchorStore struct {
+	Dir    string   // Anchors directory
+	Prefix string   // File name prefix of installed certificates, DefaultPrefix if empty
+	Update []string // Command and arguments run by Commit, none if empty
+	Run    Runner   // Runs Update, ExecRunner if nil
+}
+
+// DetectAnchorStore returns an AnchorStore for the first of AnchorLocations
+// that exists on this host.
+//
+// Returns:
+//   - The store for the anchors directory found
+//   - An error if none of the known directories exists
+func DetectAnchorStore() (*AnchorStore, error) {
+	for _, location := range AnchorLocations {
+		if info, err := os.Stat(location.Dir); err == nil && info.IsDir() {
+			return &AnchorStore{Dir: location.Dir, Update: location.Update}, nil
+		}
+	}
+	return nil, errors.New("no known CA anchors directory found, set the directory explicitly")
+}
+
+// NewAnchorStore returns an AnchorStore for dir, running the update command
+// of the distribution if dir is one of AnchorLocations.
+func NewAnchorStore(dir string) *AnchorStore {
+	store := &AnchorStore{Dir: dir}
+	for _, location := range AnchorLocations {
+		if filepath.Clean(dir) == location.Dir {
+			store.Update = location.Update
+		}
+	}
+	return store
+}
+
+// Name implements Store.
+func (s *AnchorStore) Name() string {
+	return "anchors"
+}
+
+// Location implements Store.
+func (s *AnchorStore) Location() string {
+	return s.Dir
+}
+
+// prefix returns the file name prefix of installed certificates.
+func (s *AnchorStore) prefix() string {
+	if s.Prefix == "" {
+		return DefaultPrefix
+	}
+	return s.Prefix
+}
+
+// path returns the file of the certificate with the given fingerprint.
+func (s *AnchorStore) path(fingerprint string) string {
+	return filepath.Join(s.Dir, s.prefix()+fingerprint+anchorsExtension)
+}
+
+// Installed implements Store, listing the files with the prefix.
+func (s *AnchorStore) Installed(ctx context.Context) ([]string, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var installed []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, s.prefix()) || !strings.HasSuffix(name, anchorsExtension) {
+			continue
+		}
+		installed = append(installed, strings.TrimSuffix(strings.TrimPrefix(name, s.prefix()), anchorsExtension))
+	}
+	return installed, nil
+}
+
+// Add implements Store, writing the certificate as a PEM file readable by
+// everyone.
+func (s *AnchorStore) Add(ctx context.Context, cert *x509.Certificate) error {
+	if err := os.MkdirAll(s.Dir, 0755); err != nil {
+		return err
+	}
+	file := s.path(Fingerprint(cert))
+	tmp, err := os.CreateTemp(s.Dir, ".tmp-"+s.prefix())
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := pem.Encode(tmp, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
+
+// Remove implements Store, deleting the file of the certificate.
+func (s *AnchorStore) Remove(ctx context.Context, fingerprint string) error {
+	if err := os.Remove(s.path(fingerprint)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Commit implements Store, running the update command.
+func (s *AnchorStore) Commit(ctx context.Context) error {
+	if len(s.Update) == 0 {
+		return nil
+	}
+	if err := run(ctx, s.Run, s.Update[0], s.Update[1:]...); err != nil {
+		return fmt.Errorf("failed to run %s: %w", strings.Join(s.Update, " "), err)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package truststore
+
+import (
+	"context"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnchorStore(t *testing.T) {
+	ctx := context.Background()
+	cert := testCertificate(t, "Anchor")
+	dir := filepath.Join(t.TempDir(), "anchors")
+	store := &AnchorStore{Dir: dir}
+
+	// A missing directory has nothing installed and is created on Add
+	installed, err := store.Installed(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, installed)
+	require.NoError(t, store.Add(ctx, cert))
+
+	file := filepath.Join(dir, DefaultPrefix+Fingerprint(cert)+".crt")
+	data, err := os.ReadFile(file)
+	require.NoError(t, err)
+	block, _ := pem.Decode(data)
+	require.NotNil(t, block)
+	assert.Equal(t, cert.Raw, block.Bytes)
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(file)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0644), info.Mode().Perm())
+	}
+
+	// Files of others are not managed
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "local-ca.crt"), data, 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, DefaultPrefix+"notes.txt"), nil, 0644))
+	installed, err = store.Installed(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{Fingerprint(cert)}, installed)
+
+	require.NoError(t, store.Remove(ctx, Fingerprint(cert)))
+	require.NoError(t, store.Remove(ctx, Fingerprint(cert)), "removing twice is fine")
+	assert.NoFileExists(t, file)
+	assert.FileExists(t, filepath.Join(dir, "local-ca.crt"))
+	assert.NoError(t, store.Commit(ctx), "no update command")
+
+	// Another prefix is another set of certificates
+	other := &AnchorStore{Dir: dir, Prefix: "other-"}
+	require.NoError(t, other.Add(ctx, cert))
+	installed, err = store.Installed(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, installed)
+}
+
+func TestNewAnchorStore(t *testing.T) {
+	assert.Equal(t, []string{"update-ca-trust", "extract"}, NewAnchorStore("/etc/pki/ca-trust/source/anchors/").Update)
+	assert.Equal(t, []string{"update-ca-certificates"}, NewAnchorStore("/usr/local/share/ca-certificates").Update)
+	assert.Empty(t, NewAnchorStore(t.TempDir()).Update)
+}
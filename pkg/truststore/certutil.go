@@ -0,0 +1,95 @@
+package truststore
+
+import (
+	"context"
+	"crypto/x509"
+	"os"
+	"path/filepath"
+)
+
+// DefaultCertutilStore is the Windows certificate store of trusted root
+// certification authorities of the local machine.
+const DefaultCertutilStore = "Root"
+
+// CertutilStore installs certificates into a Windows certificate store of the
+// local machine with certutil:
+//
+//	certutil -addstore -f STORE FILE
+//	certutil -delstore STORE SHA1
+//
+// The certificates installed are recorded in a state file, and only these
+// are ever removed. Changing the machine stores needs an elevated prompt.
+type CertutilStore struct {
+	Store string // Certificate store, DefaultCertutilStore if empty
+	State string // State file, DefaultCertutilState() if empty
+	Run   Runner // Runs certutil, ExecRunner if nil
+}
+
+// DefaultCertutilState returns the default state file of a CertutilStore,
+// below %ProgramData%.
+func DefaultCertutilState() string {
+	dir := os.Getenv("ProgramData")
+	if dir == "" {
+		dir = `C:\ProgramData`
+	}
+	return filepath.Join(dir, "g119612", "certutil-state.json")
+}
+
+// Name implements Store.
+func (s *CertutilStore) Name() string {
+	return "certutil"
+}
+
+// Location implements Store.
+func (s *CertutilStore) Location() string {
+	if s.Store == "" {
+		return DefaultCertutilStore
+	}
+	return s.Store
+}
+
+// statePath returns the state file of the store.
+func (s *CertutilStore) statePath() string {
+	if s.State == "" {
+		return DefaultCertutilState()
+	}
+	return s.State
+}
+
+// Installed implements Store, reading the state file.
+func (s *CertutilStore) Installed(ctx context.Context) ([]string, error) {
+	state, err := loadState(s.statePath())
+	if err != nil {
+		return nil, err
+	}
+	return state.fingerprints(), nil
+}
+
+// Add implements Store, adding the certificate to the store and recording it
+// in the state file.
+func (s *CertutilStore) Add(ctx context.Context, cert *x509.Certificate) error {
+	file, err := writeTempCertificate(cert.Raw)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file)
+	if err := run(ctx, s.Run, "certutil", "-addstore", "-f", s.Location(), file); err != nil {
+		return err
+	}
+	return updateState(s.statePath(), s.Name(), s.Location(), func(state *installState) {
+		state.Certificates[Fingerprint(cert)] = stateEntry{SHA1: thumbprint(cert), Subject: cert.Subject.String()}
+	})
+}
+
+// Remove implements Store, deleting the certificate from the store and the
+// state file.
+func (s *CertutilStore) Remove(ctx context.Context, fingerprint string) error {
+	return removeRecorded(s.statePath(), s.Name(), s.Location(), fingerprint, func(entry stateEntry) error {
+		return run(ctx, s.Run, "certutil", "-delstore", s.Location(), entry.SHA1)
+	})
+}
+
+// Commit implements Store. Store changes take effect immediately.
+func (s *CertutilStore) Commit(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,46 @@
+package truststore
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCertutilStore(t *testing.T) {
+	ctx := context.Background()
+	cert := testCertificate(t, "Certutil")
+	log := &commandLog{}
+	state := filepath.Join(t.TempDir(), "certutil.json")
+	store := &CertutilStore{State: state, Run: log.run}
+	assert.Equal(t, DefaultCertutilStore, store.Location())
+
+	require.NoError(t, store.Add(ctx, cert))
+	require.Len(t, log.commands, 1)
+	assert.True(t, strings.HasPrefix(log.commands[0], "certutil -addstore -f Root "))
+	installed, err := store.Installed(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{Fingerprint(cert)}, installed)
+
+	// The state file names the store and the certificates
+	data, err := os.ReadFile(state)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"store": "certutil"`)
+	assert.Contains(t, string(data), thumbprint(cert))
+
+	require.NoError(t, store.Remove(ctx, Fingerprint(cert)))
+	assert.Equal(t, "certutil -delstore Root "+thumbprint(cert), log.commands[1])
+
+	require.NoError(t, os.WriteFile(state, []byte("{"), 0644))
+	_, err = store.Installed(ctx)
+	assert.ErrorContains(t, err, "invalid state file")
+}
+
+func TestDefaultCertutilState(t *testing.T) {
+	t.Setenv("ProgramData", "/programdata")
+	assert.Equal(t, filepath.Join("/programdata", "g119612", "certutil-state.json"), DefaultCertutilState())
+}
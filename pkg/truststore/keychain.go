@@ -0,0 +1,89 @@
+package truststore
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+)
+
+// DefaultKeychain is the macOS keychain holding the certificates trusted by
+// all users.
+const DefaultKeychain = "/Library/Keychains/System.keychain"
+
+// DefaultKeychainState is the default state file of a KeychainStore.
+const DefaultKeychainState = "/Library/Application Support/g119612/keychain-state.json"
+
+// KeychainStore installs certificates into a macOS keychain as trusted roots
+// with the security tool:
+//
+//	security add-trusted-cert -d -r trustRoot -k KEYCHAIN FILE
+//	security delete-certificate -Z SHA1 KEYCHAIN
+//
+// A keychain holds many other certificates, so the certificates installed
+// are recorded in a state file, and only these are ever removed. Changing
+// the admin trust settings needs root privileges.
+type KeychainStore struct {
+	Keychain string // Keychain file, DefaultKeychain if empty
+	State    string // State file, DefaultKeychainState if empty
+	Run      Runner // Runs the security tool, ExecRunner if nil
+}
+
+// Name implements Store.
+func (s *KeychainStore) Name() string {
+	return "keychain"
+}
+
+// Location implements Store.
+func (s *KeychainStore) Location() string {
+	if s.Keychain == "" {
+		return DefaultKeychain
+	}
+	return s.Keychain
+}
+
+// statePath returns the state file of the store.
+func (s *KeychainStore) statePath() string {
+	if s.State == "" {
+		return DefaultKeychainState
+	}
+	return s.State
+}
+
+// Installed implements Store, reading the state file.
+func (s *KeychainStore) Installed(ctx context.Context) ([]string, error) {
+	state, err := loadState(s.statePath())
+	if err != nil {
+		return nil, err
+	}
+	return state.fingerprints(), nil
+}
+
+// Add implements Store, adding the certificate as a trusted root and
+// recording it in the state file.
+func (s *KeychainStore) Add(ctx context.Context, cert *x509.Certificate) error {
+	file, err := writeTempCertificate(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file)
+	if err := run(ctx, s.Run, "security", "add-trusted-cert", "-d", "-r", "trustRoot", "-k", s.Location(), file); err != nil {
+		return err
+	}
+	return updateState(s.statePath(), s.Name(), s.Location(), func(state *installState) {
+		state.Certificates[Fingerprint(cert)] = stateEntry{SHA1: thumbprint(cert), Subject: cert.Subject.String()}
+	})
+}
+
+// Remove implements Store, deleting the certificate from the keychain and
+// the state file.
+func (s *KeychainStore) Remove(ctx context.Context, fingerprint string) error {
+	return removeRecorded(s.statePath(), s.Name(), s.Location(), fingerprint, func(entry stateEntry) error {
+		return run(ctx, s.Run, "security", "delete-certificate", "-Z", entry.SHA1, s.Location())
+	})
+}
+
+// Commit implements Store. Keychain changes take effect immediately.
+func (s *KeychainStore) Commit(ctx context.Context) error {
+	return nil
+}
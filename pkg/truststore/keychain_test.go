@@ -0,0 +1,43 @@
+package truststore
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeychainStore(t *testing.T) {
+	ctx := context.Background()
+	cert := testCertificate(t, "Keychain")
+	log := &commandLog{}
+	store := &KeychainStore{State: filepath.Join(t.TempDir(), "state", "keychain.json"), Run: log.run}
+	assert.Equal(t, DefaultKeychain, store.Location())
+
+	require.NoError(t, store.Add(ctx, cert))
+	require.Len(t, log.commands, 1)
+	assert.True(t, strings.HasPrefix(log.commands[0], "security add-trusted-cert -d -r trustRoot -k "+DefaultKeychain+" "))
+	installed, err := store.Installed(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{Fingerprint(cert)}, installed)
+
+	require.NoError(t, store.Remove(ctx, Fingerprint(cert)))
+	assert.Equal(t, "security delete-certificate -Z "+thumbprint(cert)+" "+DefaultKeychain, log.commands[1])
+	installed, err = store.Installed(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, installed)
+
+	// Certificates not installed by the store are never removed
+	assert.ErrorContains(t, store.Remove(ctx, Fingerprint(cert)), "not installed")
+	assert.Len(t, log.commands, 2)
+
+	// A failing command leaves the state unchanged
+	log.fail = "add-trusted-cert"
+	assert.Error(t, store.Add(ctx, cert))
+	installed, err = store.Installed(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, installed)
+}
@@ -0,0 +1,133 @@
+package truststore
+
+import (
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// stateEntry records a certificate installed into a store that cannot tell
+// the certificates it installed from the others.
+type stateEntry struct {
+	SHA1    string `json:"sha1"` // Upper case hex SHA-1, the identifier used by the system tools
+	Subject string `json:"subject"`
+}
+
+// installState is the state file of a KeychainStore or CertutilStore: the
+// certificates installed by earlier runs, by hex SHA-256 fingerprint.
+type installState struct {
+	Store        string                `json:"store"`
+	Location     string                `json:"location"`
+	Certificates map[string]stateEntry `json:"certificates"`
+}
+
+// loadState reads the state file at path, an empty state if it does not
+// exist.
+func loadState(path string) (*installState, error) {
+	state := &installState{Certificates: map[string]stateEntry{}}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("invalid state file %s: %w", path, err)
+	}
+	if state.Certificates == nil {
+		state.Certificates = map[string]stateEntry{}
+	}
+	return state, nil
+}
+
+// save writes the state to path, replacing the previous file atomically.
+func (s *installState) save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// fingerprints returns the SHA-256 fingerprints of the state, sorted.
+func (s *installState) fingerprints() []string {
+	fingerprints := make([]string, 0, len(s.Certificates))
+	for fingerprint := range s.Certificates {
+		fingerprints = append(fingerprints, fingerprint)
+	}
+	slices.Sort(fingerprints)
+	return fingerprints
+}
+
+// thumbprint returns the upper case hex SHA-1 of cert, the identifier used by
+// the macOS and Windows certificate tools.
+func thumbprint(cert *x509.Certificate) string {
+	digest := sha1.Sum(cert.Raw)
+	return strings.ToUpper(hex.EncodeToString(digest[:]))
+}
+
+// writeTempCertificate writes an encoded certificate to a temporary file for
+// the system tools and returns its name. The caller removes it.
+func writeTempCertificate(data []byte) (string, error) {
+	tmp, err := os.CreateTemp("", "g119612-*.cer")
+	if err != nil {
+		return "", err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
+
+// updateState applies change to the state file at path of the store with the
+// given name and location.
+func updateState(path, name, location string, change func(*installState)) error {
+	state, err := loadState(path)
+	if err != nil {
+		return err
+	}
+	state.Store = name
+	state.Location = location
+	change(state)
+	return state.save(path)
+}
+
+// removeRecorded removes the certificate with the given fingerprint recorded
+// in the state file at path with remove, and then from the state file.
+// Certificates not recorded are not removed.
+func removeRecorded(path, name, location, fingerprint string, remove func(stateEntry) error) error {
+	state, err := loadState(path)
+	if err != nil {
+		return err
+	}
+	entry, ok := state.Certificates[fingerprint]
+	if !ok {
+		return fmt.Errorf("certificate %s was not installed by this tool", fingerprint)
+	}
+	if err := remove(entry); err != nil {
+		return err
+	}
+	return updateState(path, name, location, func(state *installState) {
+		delete(state.Certificates, fingerprint)
+	})
+}
@@ -0,0 +1,192 @@
+// Package truststore installs a certificate pool into the trust store of the
+// operating system, for appliance-style deployments where every program on the
+// host must trust the certificates selected from the trusted lists.
+//
+// A Store is a system trust location: an anchors directory of the Linux CA
+// certificate tools (AnchorStore), a macOS keychain (KeychainStore) or a
+// Windows certificate store (CertutilStore). Stores only manage certificates
+// they installed themselves, so certificates of the distribution or added by
+// an administrator are never touched. Sync brings a store in line with a pool
+// with guards against surprising changes: it refuses an empty pool and plans
+// with more changes than allowed, and only removes certificates that left the
+// pool when this is asked for.
+package truststore
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strings"
+)
+
+var (
+	// ErrEmptyPool is returned by Sync for a pool without certificates.
+	// Installing it would leave nothing trusted or only stale certificates.
+	ErrEmptyPool = errors.New("refusing to install an empty certificate pool")
+
+	// ErrTooManyChanges is returned by Sync when a plan has more additions
+	// and removals than SyncOptions.MaxChanges. Nothing is changed.
+	ErrTooManyChanges = errors.New("too many trust store changes")
+)
+
+// Store is a system trust store holding the certificates installed by this
+// package.
+type Store interface {
+	// Name is the kind of store, e.g. "anchors".
+	Name() string
+
+	// Location is the place the certificates are installed to, such as a
+	// directory or keychain.
+	Location() string
+
+	// Installed returns the hex SHA-256 fingerprints of the certificates
+	// installed by earlier runs.
+	Installed(ctx context.Context) ([]string, error)
+
+	// Add installs a certificate as trusted.
+	Add(ctx context.Context, cert *x509.Certificate) error
+
+	// Remove removes the certificate with the given hex SHA-256 fingerprint
+	// installed by an earlier run.
+	Remove(ctx context.Context, fingerprint string) error
+
+	// Commit makes the changes effective, e.g. by regenerating the bundles
+	// read by programs. It is called after certificates were added or removed.
+	Commit(ctx context.Context) error
+}
+
+// Runner runs an external command and returns its combined output. Stores
+// run the system tools through it, so tests can replace them.
+type Runner func(ctx context.Context, name string, args ...string) ([]byte, error)
+
+// ExecRunner is the Runner executing commands with os/exec.
+func ExecRunner(ctx context.Context, name string, args ...string) ([]byte, error) {
+	out, err := exec.CommandContext(ctx, name, args...).CombinedOutput()
+	if err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return out, fmt.Errorf("%s: %w: %s", name, err, msg)
+		}
+		return out, fmt.Errorf("%s: %w", name, err)
+	}
+	return out, nil
+}
+
+// run runs a command with runner, ExecRunner if it is nil.
+func run(ctx context.Context, runner Runner, name string, args ...string) error {
+	if runner == nil {
+		runner = ExecRunner
+	}
+	_, err := runner(ctx, name, args...)
+	return err
+}
+
+// Fingerprint returns the hex SHA-256 fingerprint of cert, as used to
+// identify installed certificates.
+func Fingerprint(cert *x509.Certificate) string {
+	digest := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(digest[:])
+}
+
+// SyncOptions are the safety settings of Sync.
+type SyncOptions struct {
+	// AllowRemove removes certificates installed by earlier runs that are no
+	// longer in the pool. Without it they are kept and reported as stale.
+	AllowRemove bool
+
+	// MaxChanges is the largest number of additions and removals applied in
+	// one run, 0 for no limit.
+	MaxChanges int
+
+	// DryRun only computes the plan, without changing the store.
+	DryRun bool
+}
+
+// Plan lists the changes of a Sync.
+type Plan struct {
+	Add    []*x509.Certificate // Certificates of the pool that are not installed yet
+	Remove []string            // Fingerprints of installed certificates removed from the store
+	Stale  []string            // Fingerprints of installed certificates no longer in the pool, kept without AllowRemove
+	Keep   int                 // Number of certificates of the pool that are installed already
+}
+
+// Changes returns the number of additions and removals of the plan.
+func (p *Plan) Changes() int {
+	return len(p.Add) + len(p.Remove)
+}
+
+// Sync installs the certificates of pool into store and, with
+// opts.AllowRemove, removes the certificates installed by earlier runs that
+// are not in pool any more. Duplicates in pool are installed once.
+//
+// Parameters:
+//   - ctx: Context of the commands run by the store
+//   - store: The trust store to update
+//   - pool: The certificates to trust
+//   - opts: The safety settings
+//
+// Returns:
+//   - The plan, applied unless opts.DryRun is set
+//   - ErrEmptyPool or ErrTooManyChanges if the plan was refused, or the
+//     error of the store; changes made before a store error are kept
+func Sync(ctx context.Context, store Store, pool []*x509.Certificate, opts SyncOptions) (*Plan, error) {
+	if len(pool) == 0 {
+		return nil, ErrEmptyPool
+	}
+	installed, err := store.Installed(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s trust store %s: %w", store.Name(), store.Location(), err)
+	}
+
+	plan := &Plan{}
+	wanted := make(map[string]bool)
+	for _, cert := range pool {
+		fingerprint := Fingerprint(cert)
+		if wanted[fingerprint] {
+			continue
+		}
+		wanted[fingerprint] = true
+		if slices.Contains(installed, fingerprint) {
+			plan.Keep++
+		} else {
+			plan.Add = append(plan.Add, cert)
+		}
+	}
+	for _, fingerprint := range installed {
+		if wanted[fingerprint] {
+			continue
+		}
+		if opts.AllowRemove {
+			plan.Remove = append(plan.Remove, fingerprint)
+		} else {
+			plan.Stale = append(plan.Stale, fingerprint)
+		}
+	}
+
+	if opts.MaxChanges > 0 && plan.Changes() > opts.MaxChanges {
+		return plan, fmt.Errorf("%w: %d additions and %d removals exceed the limit of %d",
+			ErrTooManyChanges, len(plan.Add), len(plan.Remove), opts.MaxChanges)
+	}
+	if opts.DryRun || plan.Changes() == 0 {
+		return plan, nil
+	}
+
+	for _, cert := range plan.Add {
+		if err := store.Add(ctx, cert); err != nil {
+			return plan, fmt.Errorf("failed to add %s to %s trust store: %w", cert.Subject, store.Name(), err)
+		}
+	}
+	for _, fingerprint := range plan.Remove {
+		if err := store.Remove(ctx, fingerprint); err != nil {
+			return plan, fmt.Errorf("failed to remove %s from %s trust store: %w", fingerprint, store.Name(), err)
+		}
+	}
+	if err := store.Commit(ctx); err != nil {
+		return plan, fmt.Errorf("failed to update %s trust store: %w", store.Name(), err)
+	}
+	return plan, nil
+}
@@ -0,0 +1,133 @@
+package truststore
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testCertificate returns a self-signed CA certificate for name.
+func testCertificate(t *testing.T, name string) *x509.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert
+}
+
+// commandLog is a Runner recording the commands run, failing those
+// containing fail.
+type commandLog struct {
+	commands []string
+	fail     string
+}
+
+func (c *commandLog) run(ctx context.Context, name string, args ...string) ([]byte, error) {
+	command := strings.Join(append([]string{name}, args...), " ")
+	c.commands = append(c.commands, command)
+	if c.fail != "" && strings.Contains(command, c.fail) {
+		return nil, errors.New("command failed")
+	}
+	return nil, nil
+}
+
+func TestSync(t *testing.T) {
+	ctx := context.Background()
+	a, b, c := testCertificate(t, "A"), testCertificate(t, "B"), testCertificate(t, "C")
+	log := &commandLog{}
+	store := &AnchorStore{Dir: t.TempDir(), Update: []string{"update-ca-trust", "extract"}, Run: log.run}
+
+	plan, err := Sync(ctx, store, []*x509.Certificate{a, b, a}, SyncOptions{})
+	require.NoError(t, err)
+	assert.Len(t, plan.Add, 2, "duplicates are installed once")
+	assert.Equal(t, 0, plan.Keep)
+	assert.Equal(t, []string{"update-ca-trust extract"}, log.commands)
+	installed, err := store.Installed(ctx)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{Fingerprint(a), Fingerprint(b)}, installed)
+
+	t.Run("Unchanged", func(t *testing.T) {
+		log.commands = nil
+		plan, err := Sync(ctx, store, []*x509.Certificate{a, b}, SyncOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, 0, plan.Changes())
+		assert.Equal(t, 2, plan.Keep)
+		assert.Empty(t, log.commands, "nothing to update")
+	})
+
+	t.Run("Stale_Without_AllowRemove", func(t *testing.T) {
+		plan, err := Sync(ctx, store, []*x509.Certificate{a}, SyncOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, []string{Fingerprint(b)}, plan.Stale)
+		assert.Empty(t, plan.Remove)
+		installed, err := store.Installed(ctx)
+		require.NoError(t, err)
+		assert.Len(t, installed, 2)
+	})
+
+	t.Run("Dry_Run", func(t *testing.T) {
+		log.commands = nil
+		plan, err := Sync(ctx, store, []*x509.Certificate{a, c}, SyncOptions{AllowRemove: true, DryRun: true})
+		require.NoError(t, err)
+		assert.Equal(t, []*x509.Certificate{c}, plan.Add)
+		assert.Equal(t, []string{Fingerprint(b)}, plan.Remove)
+		installed, err := store.Installed(ctx)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{Fingerprint(a), Fingerprint(b)}, installed)
+		assert.Empty(t, log.commands)
+	})
+
+	t.Run("Max_Changes", func(t *testing.T) {
+		_, err := Sync(ctx, store, []*x509.Certificate{a, c}, SyncOptions{AllowRemove: true, MaxChanges: 1})
+		assert.ErrorIs(t, err, ErrTooManyChanges)
+		installed, err := store.Installed(ctx)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{Fingerprint(a), Fingerprint(b)}, installed)
+	})
+
+	t.Run("Replace", func(t *testing.T) {
+		plan, err := Sync(ctx, store, []*x509.Certificate{a, c}, SyncOptions{AllowRemove: true, MaxChanges: 2})
+		require.NoError(t, err)
+		assert.Equal(t, 2, plan.Changes())
+		installed, err := store.Installed(ctx)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{Fingerprint(a), Fingerprint(c)}, installed)
+	})
+
+	t.Run("Empty_Pool", func(t *testing.T) {
+		_, err := Sync(ctx, store, nil, SyncOptions{AllowRemove: true})
+		assert.ErrorIs(t, err, ErrEmptyPool)
+		installed, err := store.Installed(ctx)
+		require.NoError(t, err)
+		assert.Len(t, installed, 2)
+	})
+
+	t.Run("Failing_Update", func(t *testing.T) {
+		log.fail = "update-ca-trust"
+		_, err := Sync(ctx, store, []*x509.Certificate{a, b, c}, SyncOptions{})
+		assert.ErrorContains(t, err, "update-ca-trust extract")
+	})
+}
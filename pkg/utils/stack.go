@@ -1,8 +1,12 @@
 // Package utils provides common utilities for the g119612 package.
 package utils
 
+import "sync"
+
 // Stack represents a generic LIFO (Last-In-First-Out) stack data structure.
+// A Stack is safe for concurrent use; it must not be copied after first use.
 type Stack[T any] struct {
+	mu    sync.RWMutex
 	items []T
 }
 
@@ -15,12 +19,16 @@ func NewStack[T any]() *Stack[T] {
 
 // Push adds an item to the top of the stack.
 func (s *Stack[T]) Push(item T) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	s.items = append(s.items, item)
 }
 
 // Pop removes and returns the top item from the stack.
 // Returns the zero value of T and false if the stack is empty.
 func (s *Stack[T]) Pop() (T, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	var zero T
 	if len(s.items) == 0 {
 		return zero, false
@@ -34,6 +42,8 @@ func (s *Stack[T]) Pop() (T, bool) {
 // Peek returns the top item from the stack without removing it.
 // Returns the zero value of T and false if the stack is empty.
 func (s *Stack[T]) Peek() (T, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	var zero T
 	if len(s.items) == 0 {
 		return zero, false
@@ -43,22 +53,30 @@ func (s *Stack[T]) Peek() (T, bool) {
 
 // IsEmpty returns true if the stack has no items.
 func (s *Stack[T]) IsEmpty() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	return len(s.items) == 0
 }
 
 // Size returns the number of items in the stack.
 func (s *Stack[T]) Size() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	return len(s.items)
 }
 
 // Clear removes all items from the stack.
 func (s *Stack[T]) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	s.items = make([]T, 0)
 }
 
 // ToSlice returns a slice containing all items in the stack,
 // ordered from bottom to top (oldest to newest).
 func (s *Stack[T]) ToSlice() []T {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	result := make([]T, len(s.items))
 	copy(result, s.items)
 	return result
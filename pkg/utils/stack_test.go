@@ -1,6 +1,9 @@
 package utils
 
-import "testing"
+import (
+	"sync"
+	"testing"
+)
 
 // TestStack_Operations tests basic stack operations
 func TestStack_Operations(t *testing.T) {
@@ -69,3 +72,24 @@ func TestStack_Operations(t *testing.T) {
 		t.Error("Stack should be empty after Clear")
 	}
 }
+
+// TestStack_Concurrent tests that concurrent pushes are not lost
+func TestStack_Concurrent(t *testing.T) {
+	s := NewStack[int]()
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range 100 {
+				s.Push(i*100 + j)
+				s.Peek()
+				s.ToSlice()
+			}
+		}()
+	}
+	wg.Wait()
+	if s.Size() != 800 {
+		t.Errorf("Stack should have size 800 after concurrent pushes, got %d", s.Size())
+	}
+}
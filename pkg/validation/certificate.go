@@ -0,0 +1,108 @@
+package validation
+
+import (
+	"crypto/x509"
+	"fmt"
+	"strings"
+)
+
+// extKeyUsageNames maps the names accepted by ParseExtKeyUsage to extended key usages
+var extKeyUsageNames = map[string]x509.ExtKeyUsage{
+	"any":             x509.ExtKeyUsageAny,
+	"serverAuth":      x509.ExtKeyUsageServerAuth,
+	"clientAuth":      x509.ExtKeyUsageClientAuth,
+	"codeSigning":     x509.ExtKeyUsageCodeSigning,
+	"emailProtection": x509.ExtKeyUsageEmailProtection,
+	"timeStamping":    x509.ExtKeyUsageTimeStamping,
+	"OCSPSigning":     x509.ExtKeyUsageOCSPSigning,
+}
+
+// ParseExtKeyUsage returns the extended key usage with the given name: any,
+// serverAuth, clientAuth, codeSigning, emailProtection, timeStamping or
+// OCSPSigning. Names are matched case insensitively.
+func ParseExtKeyUsage(name string) (x509.ExtKeyUsage, error) {
+	for candidate, usage := range extKeyUsageNames {
+		if strings.EqualFold(candidate, name) {
+			return usage, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown extended key usage %q", name)
+}
+
+// ValidateCACertificate checks that a certificate is fit to be a trust anchor
+// for issuing certificates: it must have basic constraints marking it as a CA
+// and a KeyUsage extension allowing certificate signing. This rejects leaf and
+// other end-entity certificates sometimes found among the digital identities
+// of trust services.
+//
+// If usages are given, the certificate must also pass ValidateExtKeyUsage.
+//
+// Parameters:
+//   - cert: The certificate to check
+//   - usages: Extended key usages the certificate must not exclude
+//
+// Returns:
+//   - error: A description of the first failed check, nil if the certificate passes
+func ValidateCACertificate(cert *x509.Certificate, usages ...x509.ExtKeyUsage) error {
+	if cert == nil {
+		return fmt.Errorf("certificate is nil")
+	}
+	if !cert.BasicConstraintsValid {
+		return fmt.Errorf("certificate has no basic constraints")
+	}
+	if !cert.IsCA {
+		return fmt.Errorf("certificate is not a CA certificate")
+	}
+	if cert.KeyUsage == 0 {
+		return fmt.Errorf("certificate has no key usage")
+	}
+	if cert.KeyUsage&x509.KeyUsageCertSign == 0 {
+		return fmt.Errorf("key usage does not allow certificate signing")
+	}
+	return ValidateExtKeyUsage(cert, usages...)
+}
+
+// ValidateExtKeyUsage checks that the ExtendedKeyUsage extension of a
+// certificate allows each of the given usages, directly or by allowing any
+// usage. A certificate without the extension is not restricted and passes.
+//
+// Parameters:
+//   - cert: The certificate to check
+//   - usages: Extended key usages the certificate must not exclude
+//
+// Returns:
+//   - error: Names the first usage that is not allowed, nil if all are allowed
+func ValidateExtKeyUsage(cert *x509.Certificate, usages ...x509.ExtKeyUsage) error {
+	if cert == nil {
+		return fmt.Errorf("certificate is nil")
+	}
+	if len(cert.ExtKeyUsage) == 0 && len(cert.UnknownExtKeyUsage) == 0 {
+		return nil
+	}
+	for _, usage := range usages {
+		if !hasExtKeyUsage(cert, usage) {
+			return fmt.Errorf("extended key usage does not allow %s", extKeyUsageName(usage))
+		}
+	}
+	return nil
+}
+
+// hasExtKeyUsage reports whether the ExtendedKeyUsage of cert allows usage.
+func hasExtKeyUsage(cert *x509.Certificate, usage x509.ExtKeyUsage) bool {
+	for _, allowed := range cert.ExtKeyUsage {
+		if allowed == usage || allowed == x509.ExtKeyUsageAny {
+			return true
+		}
+	}
+	return false
+}
+
+// extKeyUsageName returns the name ParseExtKeyUsage accepts for usage.
+func extKeyUsageName(usage x509.ExtKeyUsage) string {
+	for name, candidate := range extKeyUsageNames {
+		if candidate == usage {
+			return name
+		}
+	}
+	return fmt.Sprintf("extended key usage %d", usage)
+}
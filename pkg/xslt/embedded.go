@@ -1,20 +1,35 @@
-// Package xslt provides embedded XSLT stylesheets for TSL transformations.
+// Package xslt provides embedded XSLT stylesheets for TSL transformations and
+// an XSLT 1.0 processor written in Go to apply them (see Compile), so that the
+// transform step of the pipeline package works without xsltproc.
 //
 // This package uses Go's embed directive to include XSLT stylesheets directly in the
 // binary, allowing for transformations without external file dependencies. It provides
 // convenient access to standard transformation templates that can be used with the
 // pipeline package's transform functionality.
+//
+// The SHA-256 digest of every stylesheet is pinned in a manifest generated at
+// build time (see gen_manifest.go), and Get verifies the content against it, so
+// the stylesheet behind generated HTML can be audited by its digest.
 package xslt
 
 import (
+	"crypto/sha256"
 	"embed"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io/fs"
 )
 
+//go:generate go run gen_manifest.go
+
 //go:embed *.xslt
 var embeddedXSLT embed.FS
 
+// ErrIntegrity is returned by Get for a stylesheet whose content does not
+// match the digest pinned in the manifest, or that is missing from it.
+var ErrIntegrity = errors.New("embedded XSLT integrity check failed")
+
 // List returns a list of available embedded XSLT stylesheets.
 func List() ([]string, error) {
 	var files []string
@@ -33,15 +48,42 @@ func List() ([]string, error) {
 	return files, nil
 }
 
-// Get returns the content of a specific embedded XSLT stylesheet.
+// Get returns the content of a specific embedded XSLT stylesheet after
+// verifying it against the digest pinned in the manifest.
 func Get(name string) ([]byte, error) {
 	content, err := embeddedXSLT.ReadFile(name)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read embedded XSLT file '%s': %w", name, err)
 	}
+	if err := verify(name, content); err != nil {
+		return nil, err
+	}
 	return content, nil
 }
 
+// Digest returns the hex SHA-256 digest pinned in the manifest for an embedded
+// stylesheet. It identifies the stylesheet in logs and provenance records.
+func Digest(name string) (string, error) {
+	digest, ok := manifest[name]
+	if !ok {
+		return "", fmt.Errorf("%w: '%s' is not in the manifest", ErrIntegrity, name)
+	}
+	return digest, nil
+}
+
+// verify checks content against the digest pinned for name.
+func verify(name string, content []byte) error {
+	want, err := Digest(name)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(content)
+	if got := hex.EncodeToString(sum[:]); got != want {
+		return fmt.Errorf("%w: '%s' has SHA-256 %s, expected %s", ErrIntegrity, name, got, want)
+	}
+	return nil
+}
+
 // Path returns a special path identifier for embedded XSLTs that can be used
 // with the transform.go pipeline step. The path follows the format:
 // 'embedded:filename.xslt'
@@ -1,6 +1,8 @@
 package xslt
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"strings"
 	"testing"
 
@@ -260,6 +262,39 @@ func TestListAndGetConsistency(t *testing.T) {
 
 // TestPathAndExtractRoundTrip verifies that Path() and ExtractNameFromPath()
 // are inverse operations
+func TestManifest(t *testing.T) {
+	files, err := List()
+	require.NoError(t, err)
+	assert.Len(t, manifest, len(files), "manifest.go is out of date, run go generate ./pkg/xslt")
+
+	for _, file := range files {
+		content, err := embeddedXSLT.ReadFile(file)
+		require.NoError(t, err)
+		sum := sha256.Sum256(content)
+
+		digest, err := Digest(file)
+		require.NoError(t, err, "manifest.go is out of date, run go generate ./pkg/xslt")
+		assert.Equal(t, hex.EncodeToString(sum[:]), digest, "manifest.go is out of date, run go generate ./pkg/xslt")
+	}
+
+	_, err = Digest("nonexistent.xslt")
+	assert.ErrorIs(t, err, ErrIntegrity)
+}
+
+func TestGetIntegrity(t *testing.T) {
+	const name = "tsl-to-html.xslt"
+	pinned := manifest[name]
+	defer func() { manifest[name] = pinned }()
+
+	manifest[name] = strings.Repeat("0", sha256.Size*2)
+	_, err := Get(name)
+	assert.ErrorIs(t, err, ErrIntegrity)
+
+	delete(manifest, name)
+	_, err = Get(name)
+	assert.ErrorIs(t, err, ErrIntegrity)
+}
+
 func TestPathAndExtractRoundTrip(t *testing.T) {
 	testFilenames := []string{
 		"tsl-to-html.xslt",
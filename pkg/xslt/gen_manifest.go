@@ -0,0 +1,49 @@
+//go:build ignore
+
+// gen_manifest writes manifest.go with the SHA-256 digests of the embedded
+// stylesheets. Run it with "go generate ./pkg/xslt" after changing a stylesheet.
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+func main() {
+	names, err := filepath.Glob("*.xslt")
+	if err != nil {
+		log.Fatal(err)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	buf.WriteString("// Code generated by gen_manifest.go; DO NOT EDIT.\n\n")
+	buf.WriteString("package xslt\n\n")
+	buf.WriteString("// manifest maps the embedded stylesheets to the hex SHA-256 digests of their\n")
+	buf.WriteString("// content at build time. Get refuses stylesheets that do not match.\n")
+	buf.WriteString("var manifest = map[string]string{\n")
+	for _, name := range names {
+		content, err := os.ReadFile(name)
+		if err != nil {
+			log.Fatal(err)
+		}
+		digest := sha256.Sum256(content)
+		fmt.Fprintf(&buf, "\t%q: %q,\n", name, hex.EncodeToString(digest[:]))
+	}
+	buf.WriteString("}\n")
+
+	source, err := format.Source(buf.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile("manifest.go", source, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
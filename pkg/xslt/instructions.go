@@ -0,0 +1,1084 @@
+package xslt
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// instruction is a compiled node of a template body.
+type instruction interface {
+	execute(t *transformation, x *execContext, out *node) error
+}
+
+// transformation holds the state of transforming a document.
+type transformation struct {
+	sheet   *Stylesheet
+	input   *node             // Root of the input document
+	params  map[string]string // External values of global parameters
+	globals map[string]value  // Values of the global variables evaluated
+	pending map[string]bool   // Global variables being evaluated
+	depth   int               // Number of nested templates
+}
+
+// execContext is the context an instruction is executed in.
+type execContext struct {
+	node *node
+	pos  int
+	size int
+	vars *binding // Local variables in scope
+}
+
+// binding is a local variable in scope, linked to the one bound before it.
+type binding struct {
+	name  string
+	value value
+	next  *binding
+}
+
+// bind returns x with a variable bound.
+func (x *execContext) bind(name string, v value) *execContext {
+	y := *x
+	y.vars = &binding{name: name, value: v, next: x.vars}
+	return &y
+}
+
+// Transform transforms an XML document with the stylesheet and returns the
+// serialized result. The params set global xsl:param parameters by name to
+// string values; parameters the stylesheet does not declare are ignored.
+func (s *Stylesheet) Transform(input []byte, params map[string]string) ([]byte, error) {
+	doc, err := parseDocument(input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse document: %w", err)
+	}
+	if len(s.strip) > 0 {
+		s.stripSpace(doc, false)
+	}
+	t := &transformation{
+		sheet:   s,
+		input:   doc,
+		params:  params,
+		globals: make(map[string]value),
+		pending: make(map[string]bool),
+	}
+	result := newRoot()
+	if err := t.applyTemplates(nodeSet{doc}, "", nil, result); err != nil {
+		return nil, err
+	}
+	return s.output.serialize(result), nil
+}
+
+// lookup returns the value of a variable in scope.
+func (t *transformation) lookup(x *execContext, name string) (value, error) {
+	for b := x.vars; b != nil; b = b.next {
+		if b.name == name {
+			return b.value, nil
+		}
+	}
+	if v, ok := t.globals[name]; ok {
+		return v, nil
+	}
+	v, ok := t.sheet.globals[name]
+	if !ok {
+		return nil, fmt.Errorf("undefined variable $%s", name)
+	}
+	if value, ok := t.params[name]; ok && v.param {
+		t.globals[name] = value
+		return value, nil
+	}
+	if t.pending[name] {
+		return nil, fmt.Errorf("circular definition of $%s", name)
+	}
+	t.pending[name] = true
+	value, err := v.evaluate(t, &execContext{node: t.input, pos: 1, size: 1})
+	delete(t.pending, name)
+	if err != nil {
+		return nil, fmt.Errorf("$%s: %w", name, err)
+	}
+	t.globals[name] = value
+	return value, nil
+}
+
+// eval evaluates an expression in the context x.
+func (t *transformation) eval(e expr, x *execContext) (value, error) {
+	return e.eval(t.evalContext(x))
+}
+
+func (t *transformation) evalContext(x *execContext) *evalContext {
+	return &evalContext{
+		node:    x.node,
+		pos:     x.pos,
+		size:    x.size,
+		current: x.node,
+		lookup:  func(name string) (value, error) { return t.lookup(x, name) },
+	}
+}
+
+// executeBody executes a template body, binding each variable for the
+// instructions following it.
+func (t *transformation) executeBody(body []instruction, x *execContext, out *node) error {
+	for _, inst := range body {
+		if v, ok := inst.(*variable); ok {
+			value, err := v.evaluate(t, x)
+			if err != nil {
+				return fmt.Errorf("$%s: %w", v.name, err)
+			}
+			x = x.bind(v.name, value)
+			continue
+		}
+		if err := inst.execute(t, x, out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// instantiate executes a template body into a new tree and returns its root.
+func (t *transformation) instantiate(body []instruction, x *execContext) (*node, error) {
+	root := newRoot()
+	return root, t.executeBody(body, x, root)
+}
+
+// paramValue is the value of a parameter passed with xsl:with-param.
+type paramValue struct {
+	name  string
+	value value
+}
+
+// evaluateParams evaluates the xsl:with-param of an instruction.
+func (t *transformation) evaluateParams(params []*variable, x *execContext) ([]paramValue, error) {
+	values := make([]paramValue, len(params))
+	for i, param := range params {
+		v, err := param.evaluate(t, x)
+		if err != nil {
+			return nil, fmt.Errorf("$%s: %w", param.name, err)
+		}
+		values[i] = paramValue{name: param.name, value: v}
+	}
+	return values, nil
+}
+
+// applyTemplates processes each of nodes with the best template rule of mode.
+func (t *transformation) applyTemplates(nodes nodeSet, mode string, params []paramValue, out *node) error {
+	for i, n := range nodes {
+		x := &execContext{node: n, pos: i + 1, size: len(nodes)}
+		tmpl, err := t.findTemplate(x, mode)
+		if err != nil {
+			return err
+		}
+		if tmpl != nil {
+			err = t.callTemplate(tmpl, x, params, out)
+		} else {
+			err = t.builtinTemplate(x, mode, out)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// findTemplate returns the template of the rule of mode with the highest
+// priority matching the node of x, the last one in the stylesheet among
+// equals, or nil if none matches.
+func (t *transformation) findTemplate(x *execContext, mode string) (*template, error) {
+	var c *evalContext
+	for _, r := range t.sheet.rules[mode] {
+		if c == nil {
+			c = t.evalContext(x)
+		}
+		ok, err := r.pattern.match(c, x.node)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return r.template, nil
+		}
+	}
+	return nil, nil
+}
+
+// builtinTemplate applies the built-in template rule for the node of x.
+func (t *transformation) builtinTemplate(x *execContext, mode string, out *node) error {
+	switch x.node.typ {
+	case rootNode, elementNode:
+		return t.applyTemplates(x.node.children, mode, nil, out)
+	case textNode, attributeNode:
+		out.appendText(x.node.value, false)
+	}
+	return nil
+}
+
+// callTemplate instantiates a template for the node of x with the parameters
+// passed.
+func (t *transformation) callTemplate(tmpl *template, x *execContext, params []paramValue, out *node) error {
+	if t.depth >= maxDepth {
+		return fmt.Errorf("templates nested more than %d levels deep", maxDepth)
+	}
+	t.depth++
+	defer func() { t.depth-- }()
+
+	x = &execContext{node: x.node, pos: x.pos, size: x.size}
+	for _, param := range tmpl.params {
+		var v value
+		found := false
+		for _, passed := range params {
+			if passed.name == param.name {
+				v, found = passed.value, true
+				break
+			}
+		}
+		if !found {
+			var err error
+			if v, err = param.evaluate(t, x); err != nil {
+				return fmt.Errorf("$%s: %w", param.name, err)
+			}
+		}
+		x = x.bind(param.name, v)
+	}
+	return t.executeBody(tmpl.body, x, out)
+}
+
+// variable is an xsl:variable, xsl:param or xsl:with-param.
+type variable struct {
+	name       string
+	param      bool
+	selectExpr expr          // nil for a value given by the content
+	body       []instruction // Content, a result tree fragment
+}
+
+// execute does nothing: executeBody binds local variables for the
+// instructions following them.
+func (v *variable) execute(*transformation, *execContext, *node) error {
+	return nil
+}
+
+// evaluate returns the value of the variable: of its select expression, a
+// result tree fragment of its content, or an empty string without either.
+func (v *variable) evaluate(t *transformation, x *execContext) (value, error) {
+	if v.selectExpr != nil {
+		return t.eval(v.selectExpr, x)
+	}
+	if len(v.body) == 0 {
+		return "", nil
+	}
+	root, err := t.instantiate(v.body, x)
+	if err != nil {
+		return nil, err
+	}
+	return nodeSet{root}, nil
+}
+
+// compileBody compiles the content of el as a template body.
+func (c *compiler) compileBody(el *node) ([]instruction, error) {
+	return c.compileNodes(el.children)
+}
+
+// compileNodes compiles nodes of a template body. Whitespace text is
+// stripped unless preserved (see preserveSpace); comments and processing
+// instructions of the stylesheet are ignored.
+func (c *compiler) compileNodes(nodes []*node) ([]instruction, error) {
+	var body []instruction
+	for _, n := range nodes {
+		var inst instruction
+		var err error
+		switch n.typ {
+		case textNode:
+			if isWhitespace(n.value) && !preserveSpace(n.parent) {
+				continue
+			}
+			inst = &literalText{text: n.value}
+		case elementNode:
+			if n.space == xslNamespace {
+				inst, err = c.compileInstruction(n)
+			} else {
+				inst, err = c.compileLiteralElement(n)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		if inst != nil {
+			body = append(body, inst)
+		}
+	}
+	return body, nil
+}
+
+// childNodes selects the children of the context node, the default of
+// xsl:apply-templates.
+var childNodes = &pathExpr{steps: []step{{axis: axisChild, test: nodeTest{kind: testNode}}}}
+
+// contextNode selects the context node, the default of xsl:sort.
+var contextNode = &pathExpr{steps: []step{{axis: axisSelf, test: nodeTest{kind: testNode}}}}
+
+// compileInstruction compiles an XSLT instruction.
+func (c *compiler) compileInstruction(el *node) (instruction, error) {
+	var err error
+	switch el.local {
+	case "apply-templates":
+		inst := &applyTemplates{}
+		if inst.selectExpr, err = optionalExpr(el, "select"); err != nil {
+			return nil, err
+		}
+		if inst.selectExpr == nil {
+			inst.selectExpr = childNodes
+		}
+		if mode, ok := el.attr("", "mode"); ok {
+			if inst.mode, err = expandQName(el, mode); err != nil {
+				return nil, fmt.Errorf("xsl:apply-templates: %w", err)
+			}
+		}
+		inst.sorts, inst.params, err = c.compileSortsAndParams(el, true)
+		return inst, err
+	case "call-template":
+		inst := &callTemplate{}
+		name, err := requiredAttr(el, "name")
+		if err != nil {
+			return nil, err
+		}
+		if inst.name, err = expandQName(el, name); err != nil {
+			return nil, fmt.Errorf("xsl:call-template: %w", err)
+		}
+		if _, inst.params, err = c.compileSortsAndParams(el, false); err != nil {
+			return nil, err
+		}
+		c.calls = append(c.calls, inst)
+		return inst, nil
+	case "for-each":
+		inst := &forEach{}
+		if inst.selectExpr, err = requiredExpr(el, "select"); err != nil {
+			return nil, err
+		}
+		body := el.children
+		for len(body) > 0 && (isXSLElement(body[0], "sort") || body[0].typ == textNode && isWhitespace(body[0].value)) {
+			if body[0].typ == elementNode {
+				key, err := compileSort(body[0])
+				if err != nil {
+					return nil, err
+				}
+				inst.sorts = append(inst.sorts, key)
+			}
+			body = body[1:]
+		}
+		inst.body, err = c.compileNodes(body)
+		return inst, err
+	case "value-of":
+		inst := &valueOf{}
+		if inst.selectExpr, err = requiredExpr(el, "select"); err != nil {
+			return nil, err
+		}
+		inst.raw, err = yesNo(el, "disable-output-escaping")
+		return inst, err
+	case "text":
+		inst := &literalText{}
+		for _, n := range el.children {
+			if n.typ == elementNode {
+				return nil, fmt.Errorf("xsl:text may only contain text")
+			}
+			if n.typ == textNode {
+				inst.text += n.value
+			}
+		}
+		inst.raw, err = yesNo(el, "disable-output-escaping")
+		return inst, err
+	case "if":
+		inst := &ifInstr{}
+		if inst.test, err = requiredExpr(el, "test"); err != nil {
+			return nil, err
+		}
+		inst.body, err = c.compileBody(el)
+		return inst, err
+	case "choose":
+		return c.compileChoose(el)
+	case "variable":
+		return c.compileVariable(el)
+	case "param":
+		return nil, fmt.Errorf("xsl:param is only allowed at the start of a template")
+	case "element", "attribute":
+		inst := &namedInstr{attribute: el.local == "attribute", ns: el.ns}
+		name, err := requiredAttr(el, "name")
+		if err != nil {
+			return nil, err
+		}
+		if inst.name, err = compileAVT(name, el.ns); err != nil {
+			return nil, fmt.Errorf("xsl:%s: %w", el.local, err)
+		}
+		if namespace, ok := el.attr("", "namespace"); ok {
+			if inst.namespace, err = compileAVT(namespace, el.ns); err != nil {
+				return nil, fmt.Errorf("xsl:%s: %w", el.local, err)
+			}
+		}
+		if hasAttr(el, "", "use-attribute-sets") {
+			return nil, fmt.Errorf("%w: attribute sets", ErrUnsupported)
+		}
+		inst.body, err = c.compileBody(el)
+		return inst, err
+	case "comment":
+		inst := &commentInstr{}
+		inst.body, err = c.compileBody(el)
+		return inst, err
+	case "processing-instruction":
+		inst := &piInstr{}
+		name, err := requiredAttr(el, "name")
+		if err != nil {
+			return nil, err
+		}
+		if inst.name, err = compileAVT(name, el.ns); err != nil {
+			return nil, fmt.Errorf("xsl:processing-instruction: %w", err)
+		}
+		inst.body, err = c.compileBody(el)
+		return inst, err
+	case "copy":
+		if hasAttr(el, "", "use-attribute-sets") {
+			return nil, fmt.Errorf("%w: attribute sets", ErrUnsupported)
+		}
+		inst := &copyInstr{}
+		inst.body, err = c.compileBody(el)
+		return inst, err
+	case "copy-of":
+		inst := &copyOf{}
+		inst.selectExpr, err = requiredExpr(el, "select")
+		return inst, err
+	case "message":
+		inst := &message{}
+		if inst.terminate, err = yesNo(el, "terminate"); err != nil {
+			return nil, err
+		}
+		inst.body, err = c.compileBody(el)
+		return inst, err
+	case "fallback":
+		// Only instantiated for instructions of a later XSLT version
+		return nil, nil
+	case "number", "apply-imports":
+		return nil, fmt.Errorf("%w: xsl:%s", ErrUnsupported, el.local)
+	}
+	return nil, fmt.Errorf("unknown XSLT instruction xsl:%s", el.local)
+}
+
+func isXSLElement(n *node, local string) bool {
+	return n.typ == elementNode && n.space == xslNamespace && n.local == local
+}
+
+// compileSortsAndParams compiles the xsl:sort (if allowed) and xsl:with-param
+// children of el.
+func (c *compiler) compileSortsAndParams(el *node, sorts bool) ([]*sortKey, []*variable, error) {
+	var keys []*sortKey
+	var params []*variable
+	for _, n := range el.children {
+		switch {
+		case n.typ == textNode && isWhitespace(n.value), n.typ == commentNode, n.typ == piNode:
+		case isXSLElement(n, "sort") && sorts:
+			key, err := compileSort(n)
+			if err != nil {
+				return nil, nil, err
+			}
+			keys = append(keys, key)
+		case isXSLElement(n, "with-param"):
+			param, err := c.compileVariable(n)
+			if err != nil {
+				return nil, nil, err
+			}
+			params = append(params, param)
+		default:
+			return nil, nil, fmt.Errorf("unexpected content in xsl:%s", el.local)
+		}
+	}
+	return keys, params, nil
+}
+
+// compileChoose compiles xsl:choose.
+func (c *compiler) compileChoose(el *node) (instruction, error) {
+	inst := &choose{}
+	hasOtherwise := false
+	for _, n := range el.children {
+		var err error
+		switch {
+		case n.typ == textNode && isWhitespace(n.value), n.typ == commentNode, n.typ == piNode:
+		case isXSLElement(n, "when") && !hasOtherwise:
+			w := when{}
+			if w.test, err = requiredExpr(n, "test"); err != nil {
+				return nil, err
+			}
+			if w.body, err = c.compileBody(n); err != nil {
+				return nil, err
+			}
+			inst.whens = append(inst.whens, w)
+		case isXSLElement(n, "otherwise") && !hasOtherwise:
+			hasOtherwise = true
+			if inst.otherwise, err = c.compileBody(n); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("unexpected content in xsl:choose")
+		}
+	}
+	if len(inst.whens) == 0 {
+		return nil, fmt.Errorf("xsl:choose requires an xsl:when")
+	}
+	return inst, nil
+}
+
+// compileLiteralElement compiles a literal result element. Its namespace
+// nodes are copied to the result, except for the XSLT namespace and the
+// namespaces excluded with exclude-result-prefixes.
+func (c *compiler) compileLiteralElement(el *node) (instruction, error) {
+	inst := &literalElement{space: el.space, prefix: el.prefix, local: el.local}
+	excluded, err := excludedNamespaces(el)
+	if err != nil {
+		return nil, err
+	}
+	for prefix, space := range el.ns {
+		if prefix != "xml" && space != xslNamespace && !excluded[space] {
+			if inst.ns == nil {
+				inst.ns = make(map[string]string)
+			}
+			inst.ns[prefix] = space
+		}
+	}
+	for _, a := range el.attrs {
+		if a.space == xslNamespace {
+			if a.local == "use-attribute-sets" {
+				return nil, fmt.Errorf("%w: attribute sets", ErrUnsupported)
+			}
+			continue
+		}
+		value, err := compileAVT(a.value, el.ns)
+		if err != nil {
+			return nil, fmt.Errorf("attribute %s of <%s>: %w", a.name(), el.name(), err)
+		}
+		inst.attrs = append(inst.attrs, &literalAttr{space: a.space, prefix: a.prefix, local: a.local, value: value})
+	}
+	inst.body, err = c.compileBody(el)
+	return inst, err
+}
+
+// excludedNamespaces returns the namespace URIs excluded from the result for
+// the literal result element el, with exclude-result-prefixes or
+// extension-element-prefixes on xsl:stylesheet or on el or its ancestors.
+func excludedNamespaces(el *node) (map[string]bool, error) {
+	excluded := make(map[string]bool)
+	for n := el; n != nil && n.typ == elementNode; n = n.parent {
+		space := xslNamespace
+		if n.space == xslNamespace {
+			space = ""
+		}
+		for _, name := range []string{"exclude-result-prefixes", "extension-element-prefixes"} {
+			value, _ := n.attr(space, name)
+			for _, prefix := range strings.Fields(value) {
+				if prefix == "#default" {
+					prefix = ""
+				}
+				uri, ok := n.ns[prefix]
+				if !ok {
+					return nil, fmt.Errorf("%s: undeclared namespace prefix %q", name, prefix)
+				}
+				excluded[uri] = true
+			}
+		}
+	}
+	return excluded, nil
+}
+
+// literalText is text of a template body or xsl:text.
+type literalText struct {
+	text string
+	raw  bool // disable-output-escaping
+}
+
+func (i *literalText) execute(_ *transformation, _ *execContext, out *node) error {
+	out.appendText(i.text, i.raw)
+	return nil
+}
+
+// literalElement is a literal result element.
+type literalElement struct {
+	space, prefix, local string
+	ns                   map[string]string // Namespace nodes copied to the result
+	attrs                []*literalAttr
+	body                 []instruction
+}
+
+// literalAttr is an attribute of a literal result element.
+type literalAttr struct {
+	space, prefix, local string
+	value                avt
+}
+
+func (i *literalElement) execute(t *transformation, x *execContext, out *node) error {
+	el := &node{typ: elementNode, space: i.space, prefix: i.prefix, local: i.local, ns: i.ns}
+	out.appendChild(el)
+	for _, a := range i.attrs {
+		value, err := a.value.evaluate(t, x)
+		if err != nil {
+			return fmt.Errorf("attribute %s of <%s>: %w", a.local, el.name(), err)
+		}
+		el.setAttr(&node{typ: attributeNode, space: a.space, prefix: a.prefix, local: a.local, value: value})
+	}
+	return t.executeBody(i.body, x, el)
+}
+
+type applyTemplates struct {
+	selectExpr expr
+	mode       string
+	sorts      []*sortKey
+	params     []*variable
+}
+
+func (i *applyTemplates) execute(t *transformation, x *execContext, out *node) error {
+	nodes, err := t.selectNodes(i.selectExpr, i.sorts, x)
+	if err != nil {
+		return fmt.Errorf("xsl:apply-templates: %w", err)
+	}
+	params, err := t.evaluateParams(i.params, x)
+	if err != nil {
+		return fmt.Errorf("xsl:apply-templates: %w", err)
+	}
+	return t.applyTemplates(nodes, i.mode, params, out)
+}
+
+type callTemplate struct {
+	name     string
+	template *template // Resolved at the end of Compile
+	params   []*variable
+}
+
+func (i *callTemplate) execute(t *transformation, x *execContext, out *node) error {
+	params, err := t.evaluateParams(i.params, x)
+	if err != nil {
+		return fmt.Errorf("xsl:call-template: %w", err)
+	}
+	return t.callTemplate(i.template, x, params, out)
+}
+
+type forEach struct {
+	selectExpr expr
+	sorts      []*sortKey
+	body       []instruction
+}
+
+func (i *forEach) execute(t *transformation, x *execContext, out *node) error {
+	nodes, err := t.selectNodes(i.selectExpr, i.sorts, x)
+	if err != nil {
+		return fmt.Errorf("xsl:for-each: %w", err)
+	}
+	for pos, n := range nodes {
+		if err := t.executeBody(i.body, &execContext{node: n, pos: pos + 1, size: len(nodes), vars: x.vars}, out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type valueOf struct {
+	selectExpr expr
+	raw        bool
+}
+
+func (i *valueOf) execute(t *transformation, x *execContext, out *node) error {
+	v, err := t.eval(i.selectExpr, x)
+	if err != nil {
+		return fmt.Errorf("xsl:value-of: %w", err)
+	}
+	out.appendText(toString(v), i.raw)
+	return nil
+}
+
+type ifInstr struct {
+	test expr
+	body []instruction
+}
+
+func (i *ifInstr) execute(t *transformation, x *execContext, out *node) error {
+	v, err := t.eval(i.test, x)
+	if err != nil {
+		return fmt.Errorf("xsl:if: %w", err)
+	}
+	if toBoolean(v) {
+		return t.executeBody(i.body, x, out)
+	}
+	return nil
+}
+
+type choose struct {
+	whens     []when
+	otherwise []instruction
+}
+
+type when struct {
+	test expr
+	body []instruction
+}
+
+func (i *choose) execute(t *transformation, x *execContext, out *node) error {
+	for _, w := range i.whens {
+		v, err := t.eval(w.test, x)
+		if err != nil {
+			return fmt.Errorf("xsl:when: %w", err)
+		}
+		if toBoolean(v) {
+			return t.executeBody(w.body, x, out)
+		}
+	}
+	return t.executeBody(i.otherwise, x, out)
+}
+
+// namedInstr is an xsl:element or xsl:attribute.
+type namedInstr struct {
+	attribute bool
+	name      avt
+	namespace avt               // nil without a namespace attribute
+	ns        map[string]string // Namespaces in scope for the prefix of the name
+	body      []instruction
+}
+
+func (i *namedInstr) execute(t *transformation, x *execContext, out *node) error {
+	what := "xsl:element"
+	if i.attribute {
+		what = "xsl:attribute"
+	}
+	qname, err := i.name.evaluate(t, x)
+	if err != nil {
+		return fmt.Errorf("%s: %w", what, err)
+	}
+	prefix, local, ok := strings.Cut(qname, ":")
+	if !ok {
+		prefix, local = "", qname
+	}
+	if !isNCName(local) || ok && !isNCName(prefix) || i.attribute && qname == "xmlns" {
+		return fmt.Errorf("%s: invalid name %q", what, qname)
+	}
+	var space string
+	if i.namespace != nil {
+		if space, err = i.namespace.evaluate(t, x); err != nil {
+			return fmt.Errorf("%s: %w", what, err)
+		}
+	} else if prefix != "" || !i.attribute {
+		var bound bool
+		if space, bound = i.ns[prefix]; !bound && prefix != "" {
+			return fmt.Errorf("%s: undeclared namespace prefix %q", what, prefix)
+		}
+	}
+
+	if !i.attribute {
+		el := &node{typ: elementNode, space: space, prefix: prefix, local: local}
+		out.appendChild(el)
+		return t.executeBody(i.body, x, el)
+	}
+	content, err := t.instantiate(i.body, x)
+	if err != nil {
+		return err
+	}
+	out.setAttr(&node{typ: attributeNode, space: space, prefix: prefix, local: local, value: content.stringValue()})
+	return nil
+}
+
+type commentInstr struct {
+	body []instruction
+}
+
+func (i *commentInstr) execute(t *transformation, x *execContext, out *node) error {
+	content, err := t.instantiate(i.body, x)
+	if err != nil {
+		return err
+	}
+	// A comment may not contain "--" or end with "-"
+	text := strings.ReplaceAll(content.stringValue(), "--", "- -")
+	if strings.HasSuffix(text, "-") {
+		text += " "
+	}
+	out.appendChild(&node{typ: commentNode, value: text})
+	return nil
+}
+
+type piInstr struct {
+	name avt
+	body []instruction
+}
+
+func (i *piInstr) execute(t *transformation, x *execContext, out *node) error {
+	target, err := i.name.evaluate(t, x)
+	if err != nil {
+		return fmt.Errorf("xsl:processing-instruction: %w", err)
+	}
+	if !isNCName(target) || strings.EqualFold(target, "xml") {
+		return fmt.Errorf("xsl:processing-instruction: invalid name %q", target)
+	}
+	content, err := t.instantiate(i.body, x)
+	if err != nil {
+		return err
+	}
+	text := strings.ReplaceAll(content.stringValue(), "?>", "? >")
+	out.appendChild(&node{typ: piNode, local: target, value: text})
+	return nil
+}
+
+type copyInstr struct {
+	body []instruction
+}
+
+func (i *copyInstr) execute(t *transformation, x *execContext, out *node) error {
+	n := x.node
+	switch n.typ {
+	case rootNode:
+		return t.executeBody(i.body, x, out)
+	case elementNode:
+		el := &node{typ: elementNode, space: n.space, prefix: n.prefix, local: n.local, ns: n.ns}
+		out.appendChild(el)
+		return t.executeBody(i.body, x, el)
+	}
+	copyNode(n, out)
+	return nil
+}
+
+type copyOf struct {
+	selectExpr expr
+}
+
+func (i *copyOf) execute(t *transformation, x *execContext, out *node) error {
+	v, err := t.eval(i.selectExpr, x)
+	if err != nil {
+		return fmt.Errorf("xsl:copy-of: %w", err)
+	}
+	nodes, ok := v.(nodeSet)
+	if !ok {
+		out.appendText(toString(v), false)
+		return nil
+	}
+	for _, n := range nodes {
+		copyNode(n, out)
+	}
+	return nil
+}
+
+// copyNode adds a deep copy of n to out; the children of a root node.
+func copyNode(n, out *node) {
+	switch n.typ {
+	case rootNode:
+		for _, c := range n.children {
+			copyNode(c, out)
+		}
+	case elementNode:
+		el := &node{typ: elementNode, space: n.space, prefix: n.prefix, local: n.local, ns: n.ns}
+		out.appendChild(el)
+		for _, a := range n.attrs {
+			copyNode(a, el)
+		}
+		for _, c := range n.children {
+			copyNode(c, el)
+		}
+	case attributeNode:
+		out.setAttr(&node{typ: attributeNode, space: n.space, prefix: n.prefix, local: n.local, value: n.value})
+	case textNode:
+		out.appendText(n.value, n.raw)
+	default:
+		out.appendChild(&node{typ: n.typ, local: n.local, value: n.value})
+	}
+}
+
+type message struct {
+	terminate bool
+	body      []instruction
+}
+
+func (i *message) execute(t *transformation, x *execContext, _ *node) error {
+	if !i.terminate {
+		return nil
+	}
+	content, err := t.instantiate(i.body, x)
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("transformation terminated by xsl:message: %s", strings.TrimSpace(content.stringValue()))
+}
+
+// sortKey is an xsl:sort.
+type sortKey struct {
+	selectExpr expr
+	dataType   avt // "text" or "number"
+	order      avt // "ascending" or "descending"
+}
+
+func compileSort(el *node) (*sortKey, error) {
+	key := &sortKey{}
+	var err error
+	if key.selectExpr, err = optionalExpr(el, "select"); err != nil {
+		return nil, err
+	}
+	if key.selectExpr == nil {
+		key.selectExpr = contextNode
+	}
+	for name, target := range map[string]*avt{"data-type": &key.dataType, "order": &key.order} {
+		value, _ := el.attr("", name)
+		if *target, err = compileAVT(value, el.ns); err != nil {
+			return nil, fmt.Errorf("xsl:sort: %w", err)
+		}
+	}
+	return key, nil
+}
+
+// selectNodes evaluates the select expression of xsl:apply-templates or
+// xsl:for-each and sorts the nodes by the sort keys.
+func (t *transformation) selectNodes(e expr, keys []*sortKey, x *execContext) (nodeSet, error) {
+	v, err := t.eval(e, x)
+	if err != nil {
+		return nil, err
+	}
+	nodes, ok := v.(nodeSet)
+	if !ok {
+		return nil, fmt.Errorf("select does not return a node-set")
+	}
+	if len(keys) == 0 || len(nodes) < 2 {
+		return nodes, nil
+	}
+
+	type sortValues struct {
+		n      *node
+		text   []string
+		number []float64
+	}
+	numeric := make([]bool, len(keys))
+	descending := make([]bool, len(keys))
+	for k, key := range keys {
+		dataType, err := key.dataType.evaluate(t, x)
+		if err != nil {
+			return nil, err
+		}
+		order, err := key.order.evaluate(t, x)
+		if err != nil {
+			return nil, err
+		}
+		numeric[k] = dataType == "number"
+		descending[k] = order == "descending"
+	}
+	values := make([]sortValues, len(nodes))
+	for i, n := range nodes {
+		values[i] = sortValues{n: n, text: make([]string, len(keys)), number: make([]float64, len(keys))}
+		sx := &execContext{node: n, pos: i + 1, size: len(nodes), vars: x.vars}
+		for k, key := range keys {
+			v, err := t.eval(key.selectExpr, sx)
+			if err != nil {
+				return nil, fmt.Errorf("xsl:sort: %w", err)
+			}
+			if numeric[k] {
+				values[i].number[k] = toNumber(v)
+			} else {
+				values[i].text[k] = toString(v)
+			}
+		}
+	}
+	sort.SliceStable(values, func(i, j int) bool {
+		for k := range keys {
+			var c int
+			if numeric[k] {
+				c = compareNumbers(values[i].number[k], values[j].number[k])
+			} else {
+				c = strings.Compare(values[i].text[k], values[j].text[k])
+			}
+			if descending[k] {
+				c = -c
+			}
+			if c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	})
+	sorted := make(nodeSet, len(values))
+	for i := range values {
+		sorted[i] = values[i].n
+	}
+	return sorted, nil
+}
+
+// compareNumbers compares sort keys of data-type number, NaN first.
+func compareNumbers(a, b float64) int {
+	switch {
+	case math.IsNaN(a) && math.IsNaN(b):
+		return 0
+	case math.IsNaN(a):
+		return -1
+	case math.IsNaN(b):
+		return 1
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// avt is an attribute value template: text with expressions in braces.
+type avt []avtPart
+
+// avtPart is the text or the expression of a part of an attribute value
+// template.
+type avtPart struct {
+	text string
+	e    expr
+}
+
+// compileAVT parses an attribute value template.
+func compileAVT(s string, ns map[string]string) (avt, error) {
+	var parts avt
+	var text strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '{' && i+1 < len(s) && s[i+1] == '{', c == '}' && i+1 < len(s) && s[i+1] == '}':
+			text.WriteByte(c)
+			i++
+		case c == '}':
+			return nil, fmt.Errorf("unmatched } in attribute value template %q", s)
+		case c == '{':
+			end := i + 1
+			for end < len(s) && s[end] != '}' {
+				if q := s[end]; q == '"' || q == '\'' {
+					if n := strings.IndexByte(s[end+1:], q); n >= 0 {
+						end += n + 1
+					}
+				}
+				end++
+			}
+			if end == len(s) {
+				return nil, fmt.Errorf("unmatched { in attribute value template %q", s)
+			}
+			e, err := compileExpr(s[i+1:end], ns)
+			if err != nil {
+				return nil, err
+			}
+			if text.Len() > 0 {
+				parts = append(parts, avtPart{text: text.String()})
+				text.Reset()
+			}
+			parts = append(parts, avtPart{e: e})
+			i = end
+		default:
+			text.WriteByte(c)
+		}
+	}
+	if text.Len() > 0 {
+		parts = append(parts, avtPart{text: text.String()})
+	}
+	return parts, nil
+}
+
+// evaluate returns the value of the template in the context x.
+func (a avt) evaluate(t *transformation, x *execContext) (string, error) {
+	if len(a) == 1 && a[0].e == nil {
+		return a[0].text, nil
+	}
+	var b strings.Builder
+	for _, part := range a {
+		if part.e == nil {
+			b.WriteString(part.text)
+			continue
+		}
+		v, err := t.eval(part.e, x)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(toString(v))
+	}
+	return b.String(), nil
+}
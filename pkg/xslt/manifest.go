@@ -0,0 +1,9 @@
+// Code generated by gen_manifest.go; DO NOT EDIT.
+
+package xslt
+
+// manifest maps the embedded stylesheets to the hex SHA-256 digests of their
+// content at build time. Get refuses stylesheets that do not match.
+var manifest = map[string]string{
+	"tsl-to-html.xslt": "8d6f2adb6701021527ccc529b0ec29a8d7716cde170393ec76fd7e5ba01819c3",
+}
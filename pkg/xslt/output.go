@@ -0,0 +1,436 @@
+package xslt
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// outputSettings are the attributes of xsl:output.
+type outputSettings struct {
+	method        string // "xml", "html", "text", or "" to choose by the result
+	indent        string // "yes", "no", or "" for the default of the method
+	omitDecl      bool
+	standalone    string
+	doctypePublic string
+	doctypeSystem string
+	mediaType     string
+	cdata         map[string]bool // Expanded names of cdata-section-elements
+}
+
+// merge adds the attributes of an xsl:output element to the settings.
+func (o *outputSettings) merge(el *node) error {
+	for _, a := range el.attrs {
+		if a.space != "" {
+			continue
+		}
+		switch a.local {
+		case "method":
+			switch a.value {
+			case "xml", "html", "text":
+				o.method = a.value
+			default:
+				return fmt.Errorf("%w: output method %q", ErrUnsupported, a.value)
+			}
+		case "indent":
+			o.indent = a.value
+		case "omit-xml-declaration":
+			o.omitDecl = a.value == "yes"
+		case "standalone":
+			o.standalone = a.value
+		case "doctype-public":
+			o.doctypePublic = a.value
+		case "doctype-system":
+			o.doctypeSystem = a.value
+		case "media-type":
+			o.mediaType = a.value
+		case "cdata-section-elements":
+			for _, name := range strings.Fields(a.value) {
+				expanded, err := expandQName(el, name)
+				if err != nil {
+					return fmt.Errorf("xsl:output: %w", err)
+				}
+				if o.cdata == nil {
+					o.cdata = make(map[string]bool)
+				}
+				o.cdata[expanded] = true
+			}
+		}
+		// version and encoding are ignored: the output is XML 1.0 or HTML in UTF-8
+	}
+	return nil
+}
+
+// serialize writes a result tree with the output method of the settings.
+// Without a method, the result is written as HTML if its document element is
+// <html> without a namespace and no text precedes it, as XML otherwise.
+func (o *outputSettings) serialize(root *node) []byte {
+	method := o.method
+	if method == "" {
+		method = "xml"
+		for _, c := range root.children {
+			if c.typ == textNode && !isWhitespace(c.value) {
+				break
+			}
+			if c.typ == elementNode {
+				if c.space == "" && strings.EqualFold(c.local, "html") {
+					method = "html"
+				}
+				break
+			}
+		}
+	}
+	if method == "text" {
+		return []byte(root.stringValue())
+	}
+
+	w := &serializer{html: method == "html", indent: o.indent == "yes" || o.indent == "" && method == "html", cdata: o.cdata, mediaType: o.mediaType}
+	if w.mediaType == "" {
+		w.mediaType = "text/html"
+	}
+	if !w.html && !o.omitDecl {
+		w.buf.WriteString(`<?xml version="1.0" encoding="UTF-8"`)
+		if o.standalone != "" {
+			fmt.Fprintf(&w.buf, ` standalone="%s"`, o.standalone)
+		}
+		w.buf.WriteString("?>\n")
+	}
+	if el := documentElement(root); el != nil && (o.doctypeSystem != "" || w.html && o.doctypePublic != "") {
+		name := el.name()
+		if w.html {
+			name = "html"
+		}
+		w.buf.WriteString("<!DOCTYPE " + name)
+		if o.doctypePublic != "" {
+			w.buf.WriteString(` PUBLIC "` + o.doctypePublic + `"`)
+			if o.doctypeSystem != "" {
+				w.buf.WriteString(` "` + o.doctypeSystem + `"`)
+			}
+		} else {
+			w.buf.WriteString(` SYSTEM "` + o.doctypeSystem + `"`)
+		}
+		w.buf.WriteString(">\n")
+	}
+	scope := map[string]string{"": ""}
+	for _, c := range root.children {
+		w.write(c, scope, 0)
+		if c.typ != textNode {
+			w.buf.WriteByte('\n')
+		}
+	}
+	return w.buf.Bytes()
+}
+
+// serializer writes a result tree as XML or HTML.
+type serializer struct {
+	buf       bytes.Buffer
+	html      bool
+	indent    bool
+	cdata     map[string]bool
+	mediaType string
+}
+
+// HTML elements with special serialization rules.
+var (
+	htmlVoidElements = setOf("area", "base", "basefont", "br", "col", "embed", "frame", "hr", "img", "input",
+		"isindex", "link", "meta", "param", "source", "track", "wbr")
+	htmlRawElements       = setOf("script", "style")
+	htmlPreformatted      = setOf("pre", "textarea", "script", "style")
+	htmlBooleanAttributes = setOf("checked", "compact", "declare", "defer", "disabled", "ismap", "multiple",
+		"nohref", "noresize", "noshade", "nowrap", "readonly", "selected")
+	htmlURIAttributes = setOf("action", "background", "cite", "classid", "codebase", "data", "datasrc",
+		"href", "longdesc", "profile", "src", "usemap")
+	// htmlInlineElements are not indented, since whitespace around them
+	// changes the rendering.
+	htmlInlineElements = setOf("a", "abbr", "acronym", "b", "bdi", "bdo", "big", "br", "button", "cite", "code",
+		"data", "dfn", "em", "font", "i", "img", "input", "kbd", "label", "mark", "q", "s", "samp", "select",
+		"small", "span", "strike", "strong", "sub", "sup", "textarea", "time", "tt", "u", "var", "wbr")
+)
+
+func setOf(names ...string) map[string]bool {
+	set := make(map[string]bool, len(names))
+	for _, name := range names {
+		set[name] = true
+	}
+	return set
+}
+
+// htmlName returns the lower case name of an HTML element or attribute, or
+// "" for one in a namespace, which is written as XML.
+func (w *serializer) htmlName(n *node) string {
+	if !w.html || n.space != "" {
+		return ""
+	}
+	return strings.ToLower(n.local)
+}
+
+// write writes a node at an indentation depth; scope holds the namespace
+// declarations in scope.
+func (w *serializer) write(n *node, scope map[string]string, depth int) {
+	switch n.typ {
+	case textNode:
+		switch {
+		case n.raw || w.html && n.parent != nil && htmlRawElements[w.htmlName(n.parent)]:
+			w.buf.WriteString(n.value)
+		case n.parent != nil && n.parent.typ == elementNode && w.cdata[expandedName(n.parent.space, n.parent.local)]:
+			w.buf.WriteString("<![CDATA[" + strings.ReplaceAll(n.value, "]]>", "]]]]><![CDATA[>") + "]]>")
+		default:
+			w.escape(n.value, false)
+		}
+	case commentNode:
+		w.buf.WriteString("<!--" + n.value + "-->")
+	case piNode:
+		w.buf.WriteString("<?" + n.local)
+		if n.value != "" {
+			w.buf.WriteString(" " + n.value)
+		}
+		if w.html {
+			w.buf.WriteString(">")
+		} else {
+			w.buf.WriteString("?>")
+		}
+	case elementNode:
+		w.writeElement(n, scope, depth)
+	}
+}
+
+func (w *serializer) writeElement(el *node, parentScope map[string]string, depth int) {
+	name := w.htmlName(el)
+	scope, decls := w.declarations(el, parentScope)
+	w.buf.WriteString("<" + el.name())
+	for _, decl := range decls {
+		if decl == "" {
+			w.buf.WriteString(` xmlns="`)
+		} else {
+			w.buf.WriteString(" xmlns:" + decl + `="`)
+		}
+		w.escape(scope[decl], true)
+		w.buf.WriteByte('"')
+	}
+	for _, a := range el.attrs {
+		w.buf.WriteString(" " + attrName(a, scope))
+		attr := ""
+		if name != "" && a.space == "" {
+			attr = strings.ToLower(a.local)
+		}
+		if htmlBooleanAttributes[attr] {
+			continue
+		}
+		w.buf.WriteString(`="`)
+		if htmlURIAttributes[attr] {
+			w.escape(escapeURI(a.value), true)
+		} else {
+			w.escape(a.value, true)
+		}
+		w.buf.WriteByte('"')
+	}
+
+	meta := name == "head" && !hasCharsetMeta(el)
+	switch {
+	case htmlVoidElements[name]:
+		w.buf.WriteString(">")
+		return
+	case len(el.children) == 0 && !meta:
+		if name != "" {
+			w.buf.WriteString("></" + el.name() + ">")
+		} else {
+			w.buf.WriteString("/>")
+		}
+		return
+	}
+	w.buf.WriteString(">")
+
+	indent := w.indent && !htmlPreformatted[name] && !htmlInlineElements[name] && w.elementContent(el)
+	if meta {
+		w.newline(indent, depth+1)
+		w.buf.WriteString(`<meta http-equiv="Content-Type" content="` + w.mediaType + `; charset=UTF-8">`)
+	}
+	for _, c := range el.children {
+		w.newline(indent, depth+1)
+		w.write(c, scope, depth+1)
+	}
+	w.newline(indent, depth)
+	w.buf.WriteString("</" + el.name() + ">")
+}
+
+// newline starts a new line at an indentation depth, if indenting.
+func (w *serializer) newline(indent bool, depth int) {
+	if indent {
+		w.buf.WriteByte('\n')
+		w.buf.WriteString(strings.Repeat("  ", depth))
+	}
+}
+
+// elementContent reports whether el has element content that can be
+// indented: no text and, in HTML, no inline elements.
+func (w *serializer) elementContent(el *node) bool {
+	for _, c := range el.children {
+		if c.typ == textNode || c.typ == elementNode && htmlInlineElements[w.htmlName(c)] {
+			return false
+		}
+	}
+	return true
+}
+
+// hasCharsetMeta reports whether an HTML head declares the character
+// encoding, so no <meta http-equiv="Content-Type"> needs to be added.
+func hasCharsetMeta(head *node) bool {
+	for _, c := range head.children {
+		if c.typ != elementNode || c.space != "" || !strings.EqualFold(c.local, "meta") {
+			continue
+		}
+		for _, a := range c.attrs {
+			if strings.EqualFold(a.local, "charset") || strings.EqualFold(a.local, "http-equiv") && strings.EqualFold(a.value, "content-type") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// declarations returns the namespace declarations el needs: for its
+// namespace nodes, its name and the names of its attributes, with the scope
+// of its content. Namespaced attributes without a suitable prefix get one.
+func (w *serializer) declarations(el *node, parent map[string]string) (map[string]string, []string) {
+	scope := parent
+	var decls []string
+	declare := func(prefix, space string) {
+		if current, ok := scope[prefix]; ok && current == space {
+			return
+		}
+		if len(decls) == 0 {
+			scope = copyMap(parent)
+		}
+		scope[prefix] = space
+		decls = append(decls, prefix)
+	}
+
+	prefixes := make([]string, 0, len(el.ns))
+	for prefix := range el.ns {
+		if prefix != "xml" && prefix != el.prefix {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	sort.Strings(prefixes)
+	for _, prefix := range prefixes {
+		declare(prefix, el.ns[prefix])
+	}
+	if el.prefix != "xml" {
+		declare(el.prefix, el.space)
+	}
+	for _, a := range el.attrs {
+		if a.space == "" || a.prefix == "xml" || a.prefix != "" && scope[a.prefix] == a.space {
+			continue
+		}
+		if a.prefix != "" && !declared(decls, a.prefix) {
+			declare(a.prefix, a.space)
+			continue
+		}
+		if prefixFor(scope, a.space) != "" {
+			continue
+		}
+		for i := 1; ; i++ {
+			if prefix := "ns" + strconv.Itoa(i); scope[prefix] == "" {
+				declare(prefix, a.space)
+				break
+			}
+		}
+	}
+	return scope, decls
+}
+
+func declared(decls []string, prefix string) bool {
+	for _, decl := range decls {
+		if decl == prefix {
+			return true
+		}
+	}
+	return false
+}
+
+// prefixFor returns a non-empty prefix bound to space in scope, or "".
+func prefixFor(scope map[string]string, space string) string {
+	prefixes := make([]string, 0, len(scope))
+	for prefix, uri := range scope {
+		if uri == space && prefix != "" {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	if len(prefixes) == 0 {
+		return ""
+	}
+	sort.Strings(prefixes)
+	return prefixes[0]
+}
+
+// attrName returns the name an attribute is written with in scope.
+func attrName(a *node, scope map[string]string) string {
+	switch {
+	case a.space == "":
+		return a.local
+	case a.prefix == "xml":
+		return "xml:" + a.local
+	case a.prefix != "" && scope[a.prefix] == a.space:
+		return a.prefix + ":" + a.local
+	}
+	return prefixFor(scope, a.space) + ":" + a.local
+}
+
+// escape writes text or an attribute value with the markup characters
+// escaped. HTML attributes keep "<" and "&{".
+func (w *serializer) escape(s string, attr bool) {
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '&':
+			if w.html && attr && i+1 < len(s) && s[i+1] == '{' {
+				w.buf.WriteByte(c)
+			} else {
+				w.buf.WriteString("&amp;")
+			}
+		case '<':
+			if w.html && attr {
+				w.buf.WriteByte(c)
+			} else {
+				w.buf.WriteString("&lt;")
+			}
+		case '>':
+			if attr {
+				w.buf.WriteByte(c)
+			} else {
+				w.buf.WriteString("&gt;")
+			}
+		case '"':
+			if attr {
+				w.buf.WriteString("&quot;")
+			} else {
+				w.buf.WriteByte(c)
+			}
+		case '\r':
+			w.buf.WriteString("&#13;")
+		case '\n', '\t':
+			if attr && !w.html {
+				fmt.Fprintf(&w.buf, "&#%d;", c)
+			} else {
+				w.buf.WriteByte(c)
+			}
+		default:
+			w.buf.WriteByte(c)
+		}
+	}
+}
+
+// escapeURI percent-encodes the non-ASCII characters of a URI attribute of
+// HTML, as the XSLT HTML output method recommends.
+func escapeURI(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c >= 0x80 {
+			fmt.Fprintf(&b, "%%%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
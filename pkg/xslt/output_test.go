@@ -0,0 +1,119 @@
+package xslt
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// outputTest applies a stylesheet with the given xsl:output and template for
+// the root to a small document.
+func outputTest(t *testing.T, output, template string) string {
+	t.Helper()
+	sheet, err := Compile([]byte(`<xsl:stylesheet version="1.0" xmlns:xsl="http://www.w3.org/1999/XSL/Transform">` +
+		output + `<xsl:template match="/">` + template + `</xsl:template></xsl:stylesheet>`))
+	require.NoError(t, err)
+	out, err := sheet.Transform([]byte(`<doc><item>1</item></doc>`), nil)
+	require.NoError(t, err)
+	return string(out)
+}
+
+func TestOutputXML(t *testing.T) {
+	tests := []struct {
+		name     string
+		output   string
+		template string
+		want     string
+	}{
+		{
+			name:     "declaration and escaping",
+			template: `<a b="&quot;&lt;&amp;&#10;">&lt;&amp;&gt;"</a>`,
+			want:     "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<a b=\"&quot;&lt;&amp;&#10;\">&lt;&amp;&gt;\"</a>\n",
+		},
+		{
+			name:     "standalone and doctype",
+			output:   `<xsl:output standalone="yes" doctype-public="-//Test//EN" doctype-system="test.dtd"/>`,
+			template: `<r:a xmlns:r="urn:r"/>`,
+			want:     "<?xml version=\"1.0\" encoding=\"UTF-8\" standalone=\"yes\"?>\n<!DOCTYPE r:a PUBLIC \"-//Test//EN\" \"test.dtd\">\n<r:a xmlns:r=\"urn:r\"/>\n",
+		},
+		{
+			name:     "indentation of element content only",
+			output:   `<xsl:output omit-xml-declaration="yes" indent="yes"/>`,
+			template: `<a><b><c/></b><d>text <e/></d></a>`,
+			want:     "<a>\n  <b>\n    <c/>\n  </b>\n  <d>text <e/></d>\n</a>\n",
+		},
+		{
+			name:     "namespace declarations",
+			output:   `<xsl:output omit-xml-declaration="yes"/>`,
+			template: `<a xmlns="urn:a"><xsl:attribute name="e" namespace="urn:e">1</xsl:attribute><b xmlns="urn:b"/><c xmlns=""/><xsl:element name="d"/></a>`,
+			want:     "<a xmlns=\"urn:a\" xmlns:ns1=\"urn:e\" ns1:e=\"1\"><b xmlns=\"urn:b\"/><c xmlns=\"\"/><d/></a>\n",
+		},
+		{
+			name:     "cdata sections",
+			output:   `<xsl:output omit-xml-declaration="yes" cdata-section-elements="script"/>`,
+			template: `<doc><script>a &lt; b ]]&gt; c</script></doc>`,
+			want:     "<doc><script><![CDATA[a < b ]]]]><![CDATA[> c]]></script></doc>\n",
+		},
+		{
+			name:     "text method",
+			output:   `<xsl:output method="text"/>`,
+			template: `<a>&lt;<xsl:value-of select="doc/item"/></a>&amp;`,
+			want:     "<1&",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, outputTest(t, tt.output, tt.template))
+		})
+	}
+}
+
+func TestOutputHTML(t *testing.T) {
+	tests := []struct {
+		name     string
+		output   string
+		template string
+		want     string
+	}{
+		{
+			name:     "chosen by the document element",
+			template: `<HTML><body><p>x<br/>y</p><hr/></body></HTML>`,
+			want:     "<HTML>\n  <body>\n    <p>x<br>y</p>\n    <hr>\n  </body>\n</HTML>\n",
+		},
+		{
+			name:     "content type meta and doctype",
+			output:   `<xsl:output method="html" indent="no" doctype-system="about:legacy-compat"/>`,
+			template: `<html><head><title>t</title></head></html>`,
+			want:     "<!DOCTYPE html SYSTEM \"about:legacy-compat\">\n<html><head><meta http-equiv=\"Content-Type\" content=\"text/html; charset=UTF-8\"><title>t</title></head></html>\n",
+		},
+		{
+			name:     "existing charset meta",
+			output:   `<xsl:output method="html" indent="no"/>`,
+			template: `<html><head><meta charset="UTF-8"/></head></html>`,
+			want:     "<html><head><meta charset=\"UTF-8\"></head></html>\n",
+		},
+		{
+			name:     "raw text and attributes",
+			output:   `<xsl:output method="html" indent="no"/>`,
+			template: `<div><script>if (a &lt; b &amp;&amp; c) {}</script><input checked="checked" value="a&lt;b&amp;{{c}}"/><a href="/päth?q=1&amp;r=2">&lt;</a><textarea></textarea></div>`,
+			want:     "<div><script>if (a < b && c) {}</script><input checked value=\"a<b&{c}\"><a href=\"/p%C3%A4th?q=1&amp;r=2\">&lt;</a><textarea></textarea></div>\n",
+		},
+		{
+			name:     "inline elements are not indented",
+			output:   `<xsl:output method="html"/>`,
+			template: `<div><p><span>a</span><span>b</span></p><ul><li>1</li></ul></div>`,
+			want:     "<div>\n  <p><span>a</span><span>b</span></p>\n  <ul>\n    <li>1</li>\n  </ul>\n</div>\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, outputTest(t, tt.output, tt.template))
+		})
+	}
+}
+
+func TestOutputUnsupportedMethod(t *testing.T) {
+	_, err := Compile([]byte(`<xsl:stylesheet version="1.0" xmlns:xsl="http://www.w3.org/1999/XSL/Transform"><xsl:output method="xhtml"/></xsl:stylesheet>`))
+	assert.ErrorIs(t, err, ErrUnsupported)
+}
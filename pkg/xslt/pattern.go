@@ -0,0 +1,141 @@
+package xslt
+
+// pathPattern is a location path pattern of an XSLT pattern, one of the
+// alternatives separated by "|".
+type pathPattern struct {
+	root  bool // Pattern starting with "/"
+	steps []patternStep
+}
+
+// patternStep is a step of a location path pattern.
+type patternStep struct {
+	step
+	descendant bool // Separated from the previous step by "//" rather than "/"
+}
+
+// compilePattern parses an XSLT pattern into its alternatives. The id() and
+// key() patterns are not supported.
+func compilePattern(s string, ns map[string]string) (paths []*pathPattern, err error) {
+	p, err := newParser("pattern", s, ns)
+	if err != nil {
+		return nil, err
+	}
+	defer p.recover(&err)
+	for {
+		paths = append(paths, p.parsePathPattern())
+		if !p.isOperator("|") {
+			break
+		}
+		p.next()
+	}
+	p.expect(tokEOF)
+	return paths, nil
+}
+
+func (p *parser) parsePathPattern() *pathPattern {
+	pattern := &pathPattern{}
+	switch p.peek().kind {
+	case tokSlash:
+		p.next()
+		pattern.root = true
+		if !p.startsStep() {
+			return pattern
+		}
+	case tokDoubleSlash:
+		// "//a" matches the same nodes as "a"
+		p.next()
+	}
+	descendant := false
+	for {
+		s := p.parseStep()
+		if s.axis != axisChild && s.axis != axisAttribute {
+			p.fail("only the child and attribute axes are allowed in patterns")
+		}
+		pattern.steps = append(pattern.steps, patternStep{step: s, descendant: descendant})
+		switch p.peek().kind {
+		case tokSlash:
+			descendant = false
+		case tokDoubleSlash:
+			descendant = true
+		default:
+			return pattern
+		}
+		p.next()
+	}
+}
+
+// match reports whether n matches the pattern.
+func (p *pathPattern) match(c *evalContext, n *node) (bool, error) {
+	if len(p.steps) == 0 {
+		return n.typ == rootNode, nil
+	}
+	return p.matchStep(c, n, len(p.steps)-1)
+}
+
+// matchStep reports whether the steps up to i match with step i matching n.
+func (p *pathPattern) matchStep(c *evalContext, n *node, i int) (bool, error) {
+	if ok, err := p.steps[i].matchNode(c, n); !ok || err != nil {
+		return false, err
+	}
+	if i == 0 {
+		return !p.root || (n.parent != nil && n.parent.typ == rootNode), nil
+	}
+	for parent := n.parent; parent != nil; parent = parent.parent {
+		ok, err := p.matchStep(c, parent, i-1)
+		if ok || err != nil || !p.steps[i].descendant {
+			return ok, err
+		}
+	}
+	return false, nil
+}
+
+// matchNode reports whether n passes the node test and predicates of the
+// step, the predicates being evaluated with the nodes the step selects from
+// the parent of n as context.
+func (s *patternStep) matchNode(c *evalContext, n *node) (bool, error) {
+	principal := elementNode
+	if s.axis == axisAttribute {
+		principal = attributeNode
+	}
+	if (n.typ == attributeNode) != (s.axis == axisAttribute) || n.typ == rootNode || !s.test.match(n, principal) {
+		return false, nil
+	}
+	if len(s.preds) == 0 {
+		return true, nil
+	}
+	var candidates nodeSet
+	for _, sibling := range s.axis.nodes(n.parent) {
+		if s.test.match(sibling, principal) {
+			candidates = append(candidates, sibling)
+		}
+	}
+	for _, pred := range s.preds {
+		var err error
+		if candidates, err = filterNodes(c, candidates, pred); err != nil {
+			return false, err
+		}
+	}
+	for _, candidate := range candidates {
+		if candidate == n {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// defaultPriority returns the priority of a template rule for the pattern
+// without a priority attribute, as XSLT 1.0 section 5.5 defines it.
+func (p *pathPattern) defaultPriority() float64 {
+	if p.root || len(p.steps) != 1 || len(p.steps[0].preds) > 0 {
+		return 0.5
+	}
+	test := p.steps[0].test
+	switch {
+	case test.kind == testName && test.local != "*",
+		test.kind == testPI && test.target != "":
+		return 0
+	case test.kind == testName && !test.anyNamespace:
+		return -0.25
+	}
+	return -0.5
+}
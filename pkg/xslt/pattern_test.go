@@ -0,0 +1,98 @@
+package xslt
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPatternMatch(t *testing.T) {
+	doc, err := parseDocument([]byte(xpathTestDocument))
+	require.NoError(t, err)
+	list := documentElement(doc)
+	first, second := list.children[1], list.children[5]
+	sub := second.children[1]
+
+	tests := []struct {
+		pattern string
+		node    *node
+		want    bool
+	}{
+		{"/", doc, true},
+		{"/", list, false},
+		{"/t:list", list, true},
+		{"t:list", list, true},
+		{"/t:item", first, false},
+		{"t:item", first, true},
+		{"t:list/t:item", first, true},
+		{"t:list//t:sub", sub, true},
+		{"t:list/t:sub", sub, false},
+		{"/t:list/t:item/t:sub", sub, true},
+		{"//t:sub", sub, true},
+		{"t:item[2]", second, true},
+		{"t:item[2]", first, false},
+		{"t:item[@id = 'a']", first, true},
+		{"t:item[last()]/t:sub", sub, false},
+		{"*", first, true},
+		{"*", doc, false},
+		{"@id", first.attrs[0], true},
+		{"t:item/@*", first.attrs[0], true},
+		{"attribute::x:*", first.attrs[1], true},
+		{"@id", first, false},
+		{"node()", first.attrs[0], false},
+		{"text()", first.children[0], true},
+		{"t:item/text()", first.children[0], true},
+		{"comment()", list.children[3], true},
+		{"processing-instruction('target')", list.children[9], true},
+		{"t:sub | t:item", first, true},
+		{"t:sub | t:item", list, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			paths, err := compilePattern(tt.pattern, map[string]string{"t": "urn:test", "x": "urn:other"})
+			require.NoError(t, err)
+			matched := false
+			for _, path := range paths {
+				ok, err := path.match(&evalContext{node: tt.node}, tt.node)
+				require.NoError(t, err)
+				matched = matched || ok
+			}
+			assert.Equal(t, tt.want, matched)
+		})
+	}
+}
+
+func TestPatternDefaultPriority(t *testing.T) {
+	tests := map[string]float64{
+		"t:item":                      0,
+		"@id":                         0,
+		"processing-instruction('x')": 0,
+		"t:*":                         -0.25,
+		"@t:*":                        -0.25,
+		"*":                           -0.5,
+		"node()":                      -0.5,
+		"text()":                      -0.5,
+		"@*":                          -0.5,
+		"/":                           0.5,
+		"t:list/t:item":               0.5,
+		"t:item[1]":                   0.5,
+		"/t:list":                     0.5,
+		"t:list//t:item":              0.5,
+		"processing-instruction()":    -0.5,
+	}
+	for pattern, want := range tests {
+		paths, err := compilePattern(pattern, map[string]string{"t": "urn:test"})
+		require.NoError(t, err, pattern)
+		require.Len(t, paths, 1)
+		assert.Equal(t, want, paths[0].defaultPriority(), pattern)
+	}
+}
+
+func TestCompilePatternErrors(t *testing.T) {
+	for _, pattern := range []string{"", "ancestor::a", "a/..", "id('x')", "a |", "$x", "a[1"} {
+		_, err := compilePattern(pattern, nil)
+		require.Error(t, err, pattern)
+		assert.Contains(t, err.Error(), "invalid pattern", pattern)
+	}
+}
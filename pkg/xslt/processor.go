@@ -0,0 +1,421 @@
+package xslt
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrUnsupported is returned by Compile for a stylesheet using a feature of
+// XSLT 1.0 the processor does not implement.
+var ErrUnsupported = errors.New("unsupported XSLT feature")
+
+// maxDepth is the number of nested templates after which a transformation
+// fails, to stop stylesheets that recurse endlessly.
+const maxDepth = 3000
+
+// Stylesheet is a compiled XSLT 1.0 stylesheet. It is immutable, so the same
+// stylesheet can transform several documents concurrently.
+//
+// The processor implements XSLT 1.0 and XPath 1.0 except for xsl:include,
+// xsl:import, xsl:key, xsl:number, attribute sets, decimal formats, namespace
+// aliases, the namespace axis and the functions id(), key(), document(),
+// format-number(), unparsed-entity-uri(), system-property(),
+// element-available() and function-available(); Compile returns an error
+// wrapping ErrUnsupported for stylesheets using them. Output is always
+// encoded in UTF-8 and messages of xsl:message are discarded, unless they
+// terminate the transformation.
+type Stylesheet struct {
+	output   outputSettings
+	globals  map[string]*variable // Global variables and parameters by expanded name
+	named    map[string]*template // Named templates by expanded name
+	rules    map[string][]*rule   // Template rules by mode, in the order they are tried
+	strip    []nodeTest           // Elements of xsl:strip-space
+	preserve []nodeTest           // Elements of xsl:preserve-space
+}
+
+// template is an xsl:template.
+type template struct {
+	name   string
+	params []*variable
+	body   []instruction
+}
+
+// rule is an alternative of the pattern of a template with a match attribute.
+type rule struct {
+	pattern  *pathPattern
+	priority float64
+	position int // Position of the template in the stylesheet
+	template *template
+}
+
+// compiler holds the state of compiling a stylesheet.
+type compiler struct {
+	sheet *Stylesheet
+	rules int
+	calls []*callTemplate // Named templates to resolve once all are known
+}
+
+// Compile compiles an XSLT 1.0 stylesheet, either an xsl:stylesheet or a
+// literal result element used as the stylesheet.
+func Compile(stylesheet []byte) (*Stylesheet, error) {
+	doc, err := parseDocument(stylesheet)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse stylesheet: %w", err)
+	}
+	c := &compiler{sheet: &Stylesheet{
+		globals: make(map[string]*variable),
+		named:   make(map[string]*template),
+		rules:   make(map[string][]*rule),
+	}}
+	root := documentElement(doc)
+	switch {
+	case root.space == xslNamespace && (root.local == "stylesheet" || root.local == "transform"):
+		err = c.compileTopLevel(root)
+	case hasAttr(root, xslNamespace, "version"):
+		var body []instruction
+		if body, err = c.compileBody(doc); err == nil {
+			c.addRule(&template{body: body}, &pathPattern{root: true}, 0.5, "")
+		}
+	default:
+		err = fmt.Errorf("<%s> is not an XSLT stylesheet", root.name())
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, call := range c.calls {
+		if call.template = c.sheet.named[call.name]; call.template == nil {
+			return nil, fmt.Errorf("xsl:call-template: no template named %q", call.name)
+		}
+	}
+	for _, rules := range c.sheet.rules {
+		sort.SliceStable(rules, func(i, j int) bool {
+			if rules[i].priority != rules[j].priority {
+				return rules[i].priority > rules[j].priority
+			}
+			return rules[i].position > rules[j].position
+		})
+	}
+	return c.sheet, nil
+}
+
+// documentElement returns the element child of a root node.
+func documentElement(root *node) *node {
+	for _, c := range root.children {
+		if c.typ == elementNode {
+			return c
+		}
+	}
+	return nil
+}
+
+func hasAttr(n *node, space, local string) bool {
+	_, ok := n.attr(space, local)
+	return ok
+}
+
+// compileTopLevel compiles the top-level elements of xsl:stylesheet.
+func (c *compiler) compileTopLevel(sheet *node) error {
+	for _, el := range sheet.children {
+		if el.typ != elementNode || el.space != xslNamespace {
+			// Top-level elements of other namespaces are ignored
+			continue
+		}
+		switch el.local {
+		case "template":
+			if err := c.compileTemplate(el); err != nil {
+				return err
+			}
+		case "variable", "param":
+			v, err := c.compileVariable(el)
+			if err != nil {
+				return err
+			}
+			if _, ok := c.sheet.globals[v.name]; ok {
+				return fmt.Errorf("xsl:%s: %q is already defined", el.local, v.name)
+			}
+			c.sheet.globals[v.name] = v
+		case "output":
+			if err := c.sheet.output.merge(el); err != nil {
+				return err
+			}
+		case "strip-space", "preserve-space":
+			tests, err := compileNameTests(el)
+			if err != nil {
+				return err
+			}
+			if el.local == "strip-space" {
+				c.sheet.strip = append(c.sheet.strip, tests...)
+			} else {
+				c.sheet.preserve = append(c.sheet.preserve, tests...)
+			}
+		case "include", "import", "key", "attribute-set", "decimal-format", "namespace-alias":
+			return fmt.Errorf("%w: xsl:%s", ErrUnsupported, el.local)
+		default:
+			return fmt.Errorf("unknown top-level element xsl:%s", el.local)
+		}
+	}
+	return nil
+}
+
+// compileTemplate compiles an xsl:template, adding it to the named templates
+// and the template rules of its mode.
+func (c *compiler) compileTemplate(el *node) (err error) {
+	match, hasMatch := el.attr("", "match")
+	name, hasName := el.attr("", "name")
+	defer func() {
+		if err != nil {
+			what := match
+			if hasName {
+				what = name
+			}
+			err = fmt.Errorf("xsl:template %q: %w", what, err)
+		}
+	}()
+	if !hasMatch && !hasName {
+		return fmt.Errorf("requires a match or name attribute")
+	}
+
+	t := &template{}
+	body := el.children
+	for len(body) > 0 {
+		n := body[0]
+		if n.typ == elementNode && n.space == xslNamespace && n.local == "param" {
+			param, err := c.compileVariable(n)
+			if err != nil {
+				return err
+			}
+			t.params = append(t.params, param)
+		} else if n.typ != textNode || !isWhitespace(n.value) {
+			break
+		}
+		body = body[1:]
+	}
+	if t.body, err = c.compileNodes(body); err != nil {
+		return err
+	}
+
+	if hasName {
+		if t.name, err = expandQName(el, name); err != nil {
+			return err
+		}
+		if _, ok := c.sheet.named[t.name]; ok {
+			return fmt.Errorf("template %q is already defined", name)
+		}
+		c.sheet.named[t.name] = t
+	}
+	if !hasMatch {
+		return nil
+	}
+	mode := ""
+	if value, ok := el.attr("", "mode"); ok {
+		if mode, err = expandQName(el, value); err != nil {
+			return err
+		}
+	}
+	paths, err := compilePattern(match, el.ns)
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		priority := path.defaultPriority()
+		if value, ok := el.attr("", "priority"); ok {
+			if priority, err = strconv.ParseFloat(strings.TrimSpace(value), 64); err != nil {
+				return fmt.Errorf("invalid priority %q", value)
+			}
+		}
+		c.addRule(t, path, priority, mode)
+	}
+	return nil
+}
+
+// addRule adds a template rule for an alternative of a pattern.
+func (c *compiler) addRule(t *template, path *pathPattern, priority float64, mode string) {
+	c.sheet.rules[mode] = append(c.sheet.rules[mode], &rule{pattern: path, priority: priority, position: c.rules, template: t})
+	c.rules++
+}
+
+// compileVariable compiles an xsl:variable, xsl:param or xsl:with-param.
+func (c *compiler) compileVariable(el *node) (*variable, error) {
+	name, err := requiredAttr(el, "name")
+	if err != nil {
+		return nil, err
+	}
+	v := &variable{param: el.local == "param"}
+	if v.name, err = expandQName(el, name); err != nil {
+		return nil, fmt.Errorf("xsl:%s: %w", el.local, err)
+	}
+	if v.selectExpr, err = optionalExpr(el, "select"); err != nil {
+		return nil, err
+	}
+	if v.selectExpr == nil {
+		if v.body, err = c.compileBody(el); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+// compileNameTests compiles the elements attribute of xsl:strip-space or
+// xsl:preserve-space.
+func compileNameTests(el *node) ([]nodeTest, error) {
+	elements, err := requiredAttr(el, "elements")
+	if err != nil {
+		return nil, err
+	}
+	var tests []nodeTest
+	for _, name := range strings.Fields(elements) {
+		test, err := compileNameTest(name, el.ns)
+		if err != nil {
+			return nil, fmt.Errorf("xsl:%s: %w", el.local, err)
+		}
+		tests = append(tests, test)
+	}
+	return tests, nil
+}
+
+// compileNameTest parses a name test: *, prefix:* or a QName.
+func compileNameTest(s string, ns map[string]string) (test nodeTest, err error) {
+	p, err := newParser("name test", s, ns)
+	if err != nil {
+		return test, err
+	}
+	defer p.recover(&err)
+	if t := p.peek(); t.kind != tokName && t.kind != tokStar || nodeTypes[t.text] && p.peekAt(1).kind == tokLParen {
+		p.fail("expected a name test")
+	}
+	test = p.parseNodeTest()
+	p.expect(tokEOF)
+	return test, nil
+}
+
+// requiredAttr returns the value of an attribute an XSLT element requires.
+func requiredAttr(el *node, name string) (string, error) {
+	value, ok := el.attr("", name)
+	if !ok {
+		return "", fmt.Errorf("xsl:%s requires the %s attribute", el.local, name)
+	}
+	return value, nil
+}
+
+// optionalExpr compiles the expression of an attribute of an XSLT element,
+// returning nil if the attribute is missing.
+func optionalExpr(el *node, name string) (expr, error) {
+	value, ok := el.attr("", name)
+	if !ok {
+		return nil, nil
+	}
+	e, err := compileExpr(value, el.ns)
+	if err != nil {
+		return nil, fmt.Errorf("xsl:%s: %w", el.local, err)
+	}
+	return e, nil
+}
+
+// requiredExpr compiles the expression of an attribute an XSLT element
+// requires.
+func requiredExpr(el *node, name string) (expr, error) {
+	if _, err := requiredAttr(el, name); err != nil {
+		return nil, err
+	}
+	return optionalExpr(el, name)
+}
+
+// yesNo returns whether an attribute of an XSLT element is "yes".
+func yesNo(el *node, name string) (bool, error) {
+	value, ok := el.attr("", name)
+	if !ok {
+		return false, nil
+	}
+	switch value {
+	case "yes":
+		return true, nil
+	case "no":
+		return false, nil
+	}
+	return false, fmt.Errorf("xsl:%s: %s must be yes or no, not %q", el.local, name, value)
+}
+
+// expandQName returns the expanded name of a QName in an attribute of el.
+// Unprefixed names are in no namespace.
+func expandQName(el *node, qname string) (string, error) {
+	prefix, local, ok := strings.Cut(qname, ":")
+	if !ok {
+		prefix, local = "", qname
+	}
+	if !isNCName(local) || ok && !isNCName(prefix) {
+		return "", fmt.Errorf("invalid name %q", qname)
+	}
+	if !ok {
+		return local, nil
+	}
+	space, bound := el.ns[prefix]
+	if !bound {
+		return "", fmt.Errorf("undeclared namespace prefix %q", prefix)
+	}
+	return expandedName(space, local), nil
+}
+
+func isNCName(s string) bool {
+	return s != "" && scanNCName(s) == len(s)
+}
+
+// isWhitespace reports whether s only consists of XML whitespace.
+func isWhitespace(s string) bool {
+	return strings.TrimLeft(s, " \t\r\n") == ""
+}
+
+// preserveSpace reports whether whitespace text in the stylesheet element el
+// is kept: within xsl:text or where xml:space is "preserve".
+func preserveSpace(el *node) bool {
+	if el.space == xslNamespace && el.local == "text" {
+		return true
+	}
+	for n := el; n != nil; n = n.parent {
+		if value, ok := n.attr(xmlNamespace, "space"); ok {
+			return value == "preserve"
+		}
+	}
+	return false
+}
+
+// stripSpace removes the whitespace text nodes from the elements of the
+// document xsl:strip-space lists.
+func (s *Stylesheet) stripSpace(n *node, preserve bool) {
+	if n.typ == elementNode {
+		if value, ok := n.attr(xmlNamespace, "space"); ok {
+			preserve = value == "preserve"
+		}
+	}
+	strip := !preserve && n.typ == elementNode && s.strips(n)
+	children := n.children[:0]
+	for _, c := range n.children {
+		if strip && c.typ == textNode && isWhitespace(c.value) {
+			continue
+		}
+		c.index = len(children)
+		children = append(children, c)
+		if c.typ == elementNode {
+			s.stripSpace(c, preserve)
+		}
+	}
+	n.children = children
+}
+
+// strips reports whether whitespace text is stripped from el: whether the
+// most specific name test matching it is of xsl:strip-space.
+func (s *Stylesheet) strips(el *node) bool {
+	best := func(tests []nodeTest) float64 {
+		priority := -1.0
+		for i := range tests {
+			if tests[i].match(el, elementNode) {
+				pattern := pathPattern{steps: []patternStep{{step: step{test: tests[i]}}}}
+				priority = max(priority, pattern.defaultPriority())
+			}
+		}
+		return priority
+	}
+	return len(s.strip) > 0 && best(s.strip) > best(s.preserve)
+}
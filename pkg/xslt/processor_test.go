@@ -0,0 +1,326 @@
+package xslt
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// transformTest compiles a stylesheet with the given top-level elements and
+// text output, and applies it to input.
+func transformTest(t *testing.T, topLevel, input string, params map[string]string) (string, error) {
+	t.Helper()
+	sheet, err := Compile([]byte(`<xsl:stylesheet version="1.0" xmlns:xsl="http://www.w3.org/1999/XSL/Transform" xmlns:t="urn:test">
+<xsl:output method="text"/>` + topLevel + `</xsl:stylesheet>`))
+	require.NoError(t, err)
+	out, err := sheet.Transform([]byte(input), params)
+	return string(out), err
+}
+
+const processorTestInput = `<list xmlns="urn:test">
+  <item n="3">c</item>
+  <item n="10">a</item>
+  <item n="2">b</item>
+</list>`
+
+func TestTransformInstructions(t *testing.T) {
+	tests := []struct {
+		name     string
+		topLevel string
+		want     string
+	}{
+		{
+			name:     "built-in rules copy the text",
+			topLevel: ``,
+			want:     "\n  c\n  a\n  b\n",
+		},
+		{
+			name: "apply-templates and value-of",
+			topLevel: `<xsl:template match="/"><xsl:apply-templates select="t:list/t:item"/></xsl:template>
+				<xsl:template match="t:item">[<xsl:value-of select="."/>]</xsl:template>`,
+			want: "[c][a][b]",
+		},
+		{
+			name: "for-each with position and last",
+			topLevel: `<xsl:template match="/"><xsl:for-each select="//t:item">
+				<xsl:value-of select="concat(position(), '/', last(), '=', @n)"/><xsl:if test="position() != last()">,</xsl:if>
+				</xsl:for-each></xsl:template>`,
+			want: "1/3=3,2/3=10,3/3=2",
+		},
+		{
+			name: "sort by text and number",
+			topLevel: `<xsl:template match="/">
+				<xsl:for-each select="//t:item"><xsl:sort/><xsl:value-of select="."/></xsl:for-each>
+				<xsl:text>|</xsl:text>
+				<xsl:for-each select="//t:item"><xsl:sort select="@n"/><xsl:value-of select="@n"/>,</xsl:for-each>
+				<xsl:text>|</xsl:text>
+				<xsl:for-each select="//t:item"><xsl:sort select="@n" data-type="number" order="descending"/><xsl:value-of select="@n"/>,</xsl:for-each>
+				</xsl:template>`,
+			want: "abc|10,2,3,|10,3,2,",
+		},
+		{
+			name: "apply-templates with sort and mode",
+			topLevel: `<xsl:template match="/"><xsl:apply-templates select="//t:item" mode="short"><xsl:sort select="@n" data-type="number"/></xsl:apply-templates></xsl:template>
+				<xsl:template match="t:item" mode="short"><xsl:value-of select="position()"/><xsl:value-of select="."/></xsl:template>
+				<xsl:template match="t:item">wrong mode</xsl:template>`,
+			want: "1b2c3a",
+		},
+		{
+			name: "choose",
+			topLevel: `<xsl:template match="t:item"><xsl:choose>
+				<xsl:when test="@n &gt; 5">big </xsl:when>
+				<xsl:when test="@n = 3">three </xsl:when>
+				<xsl:otherwise>small </xsl:otherwise>
+				</xsl:choose></xsl:template>
+				<xsl:template match="text()"/>`,
+			want: "three big small ",
+		},
+		{
+			name: "variables and result tree fragments",
+			topLevel: `<xsl:variable name="sep" select="'; '"/>
+				<xsl:variable name="label">Item <xsl:value-of select="count(//t:item)"/></xsl:variable>
+				<xsl:template match="/">
+				<xsl:variable name="first" select="//t:item[1]"/>
+				<xsl:value-of select="concat($label, $sep, $first, $sep, string-length($label))"/>
+				</xsl:template>`,
+			want: "Item 3; c; 6",
+		},
+		{
+			name: "call-template with parameters and recursion",
+			topLevel: `<xsl:template match="/"><xsl:call-template name="countdown"><xsl:with-param name="n" select="3"/></xsl:call-template></xsl:template>
+				<xsl:template name="countdown"><xsl:param name="n" select="10"/><xsl:param name="suffix">!</xsl:param>
+				<xsl:value-of select="$n"/>
+				<xsl:choose>
+				<xsl:when test="$n &gt; 0"><xsl:call-template name="countdown"><xsl:with-param name="n" select="$n - 1"/></xsl:call-template></xsl:when>
+				<xsl:otherwise><xsl:value-of select="$suffix"/></xsl:otherwise>
+				</xsl:choose>
+				</xsl:template>`,
+			want: "3210!",
+		},
+		{
+			name: "apply-templates with parameters",
+			topLevel: `<xsl:template match="/"><xsl:apply-templates select="//t:item[1]"><xsl:with-param name="p" select="'x'"/></xsl:apply-templates></xsl:template>
+				<xsl:template match="t:item"><xsl:param name="p"/><xsl:value-of select="concat($p, .)"/></xsl:template>`,
+			want: "xc",
+		},
+		{
+			name: "priority and order of template rules",
+			topLevel: `<xsl:template match="/"><xsl:apply-templates select="//t:item"/></xsl:template>
+				<xsl:template match="t:item[@n = 10]">ten </xsl:template>
+				<xsl:template match="t:item">item </xsl:template>
+				<xsl:template match="t:*">any </xsl:template>
+				<xsl:template match="t:item" priority="-1">low </xsl:template>
+				<xsl:template match="t:item[. = 'b'] | t:list/t:item[. = 'b']">b </xsl:template>`,
+			want: "item ten b ",
+		},
+		{
+			name:     "current in predicates",
+			topLevel: `<xsl:template match="/"><xsl:for-each select="//t:item"><xsl:value-of select="count(//t:item[@n &lt; current()/@n])"/></xsl:for-each></xsl:template>`,
+			want:     "120",
+		},
+		{
+			name:     "terminating messages only",
+			topLevel: `<xsl:template match="/"><xsl:message>ignored</xsl:message>done</xsl:template>`,
+			want:     "done",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := transformTest(t, tt.topLevel, processorTestInput, nil)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestTransformResultTree(t *testing.T) {
+	sheet, err := Compile([]byte(`<xsl:stylesheet version="1.0" xmlns:xsl="http://www.w3.org/1999/XSL/Transform"
+		xmlns:t="urn:test" xmlns:out="urn:out" exclude-result-prefixes="t">
+	<xsl:output method="xml" omit-xml-declaration="yes"/>
+	<xsl:template match="/t:list">
+		<out:result count="{count(t:item)}" braces="{{x}}">
+			<xsl:attribute name="first"><xsl:value-of select="t:item[1]"/></xsl:attribute>
+			<xsl:element name="out:{local-name(t:item[2])}" namespace="urn:other">
+				<xsl:copy-of select="t:item[2]/@n"/>
+			</xsl:element>
+			<xsl:apply-templates select="t:item[3]"/>
+			<xsl:comment>a--b</xsl:comment>
+			<xsl:processing-instruction name="pi">data</xsl:processing-instruction>
+			<xsl:text disable-output-escaping="yes">&lt;raw/&gt;</xsl:text>
+			<xsl:value-of select="'&lt;escaped&gt;'"/>
+		</out:result>
+	</xsl:template>
+	<xsl:template match="t:item"><xsl:copy><xsl:copy-of select="@*"/>copied</xsl:copy></xsl:template>
+</xsl:stylesheet>`))
+	require.NoError(t, err)
+	out, err := sheet.Transform([]byte(processorTestInput), nil)
+	require.NoError(t, err)
+	assert.Equal(t, `<out:result xmlns:out="urn:out" count="3" braces="{x}" first="c">`+
+		`<out:item xmlns:out="urn:other" n="10"/>`+
+		`<item xmlns="urn:test" n="2">copied</item>`+
+		`<!--a- -b--><?pi data?><raw/>&lt;escaped&gt;</out:result>`+"\n", string(out))
+}
+
+func TestTransformParams(t *testing.T) {
+	topLevel := `<xsl:param name="greeting" select="'Hello'"/>
+		<xsl:param name="tsl.name">World</xsl:param>
+		<xsl:variable name="fixed" select="'!'"/>
+		<xsl:template match="/"><xsl:value-of select="concat($greeting, ', ', $tsl.name, $fixed)"/></xsl:template>`
+
+	got, err := transformTest(t, topLevel, "<a/>", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "Hello, World!", got)
+
+	got, err = transformTest(t, topLevel, "<a/>", map[string]string{"greeting": "Hej", "tsl.name": "<Sverige>", "fixed": "?", "unknown": "x"})
+	require.NoError(t, err)
+	assert.Equal(t, "Hej, <Sverige>!", got, "only parameters can be set")
+}
+
+func TestTransformStripSpace(t *testing.T) {
+	topLevel := `<xsl:strip-space elements="*"/><xsl:preserve-space elements="t:keep"/>
+		<xsl:template match="/"><xsl:value-of select="count(//text())"/></xsl:template>`
+	got, err := transformTest(t, topLevel, `<list xmlns="urn:test"> <a> </a> <keep> </keep> <b xml:space="preserve"> </b> </list>`, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "2", got)
+}
+
+func TestTransformErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		topLevel string
+		input    string
+		want     string
+	}{
+		{
+			name:     "invalid input",
+			topLevel: ``,
+			input:    "<a>",
+			want:     "failed to parse document",
+		},
+		{
+			name:     "undefined variable",
+			topLevel: `<xsl:template match="/"><xsl:value-of select="$missing"/></xsl:template>`,
+			want:     "undefined variable $missing",
+		},
+		{
+			name: "circular variables",
+			topLevel: `<xsl:variable name="a" select="$b"/><xsl:variable name="b" select="$a"/>
+				<xsl:template match="/"><xsl:value-of select="$a"/></xsl:template>`,
+			want: "circular definition of $a",
+		},
+		{
+			name:     "endless recursion",
+			topLevel: `<xsl:template match="/" name="loop"><xsl:call-template name="loop"/></xsl:template>`,
+			want:     "templates nested more than 3000 levels deep",
+		},
+		{
+			name:     "terminating message",
+			topLevel: `<xsl:template match="/"><xsl:message terminate="yes">Stop <xsl:value-of select="name(*)"/></xsl:message></xsl:template>`,
+			want:     "transformation terminated by xsl:message: Stop a",
+		},
+		{
+			name:     "select of a string",
+			topLevel: `<xsl:template match="/"><xsl:for-each select="'a'"/></xsl:template>`,
+			want:     "xsl:for-each: select does not return a node-set",
+		},
+		{
+			name:     "invalid element name",
+			topLevel: `<xsl:template match="/"><xsl:element name="{'1a'}"/></xsl:template>`,
+			want:     `xsl:element: invalid name "1a"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := tt.input
+			if input == "" {
+				input = "<a/>"
+			}
+			_, err := transformTest(t, tt.topLevel, input, nil)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		topLevel string
+		want     string
+	}{
+		{"import", `<xsl:import href="other.xslt"/>`, "unsupported XSLT feature: xsl:import"},
+		{"key", `<xsl:key name="k" match="a" use="@id"/>`, "unsupported XSLT feature: xsl:key"},
+		{"number", `<xsl:template match="/"><xsl:number/></xsl:template>`, "unsupported XSLT feature: xsl:number"},
+		{"attribute sets", `<xsl:template match="/"><a xsl:use-attribute-sets="s"/></xsl:template>`, "unsupported XSLT feature: attribute sets"},
+		{"function", `<xsl:template match="/"><xsl:value-of select="document('x')"/></xsl:template>`, "unsupported function document()"},
+		{"unknown instruction", `<xsl:template match="/"><xsl:foo/></xsl:template>`, "unknown XSLT instruction xsl:foo"},
+		{"missing attribute", `<xsl:template match="/"><xsl:value-of/></xsl:template>`, "xsl:value-of requires the select attribute"},
+		{"invalid expression", `<xsl:template match="/"><xsl:if test="1 +"/></xsl:template>`, `xsl:template "/": xsl:if: invalid XPath expression "1 +"`},
+		{"invalid pattern", `<xsl:template match="a/.."/>`, `invalid pattern "a/.."`},
+		{"missing template", `<xsl:template match="/"><xsl:call-template name="none"/></xsl:template>`, `no template named "none"`},
+		{"duplicate template", `<xsl:template name="a"/><xsl:template name="a"/>`, `template "a" is already defined`},
+		{"misplaced param", `<xsl:template match="/">x<xsl:param name="p"/></xsl:template>`, "xsl:param is only allowed at the start of a template"},
+		{"invalid avt", `<xsl:template match="/"><a href="{"/></xsl:template>`, "unmatched {"},
+		{"invalid choose", `<xsl:template match="/"><xsl:choose><xsl:otherwise/></xsl:choose></xsl:template>`, "xsl:choose requires an xsl:when"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Compile([]byte(`<xsl:stylesheet version="1.0" xmlns:xsl="http://www.w3.org/1999/XSL/Transform">` + tt.topLevel + `</xsl:stylesheet>`))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+
+	_, err := Compile([]byte(`<html/>`))
+	assert.ErrorContains(t, err, "<html> is not an XSLT stylesheet")
+	_, err = Compile([]byte(`<xsl:stylesheet`))
+	assert.ErrorContains(t, err, "failed to parse stylesheet")
+}
+
+func TestCompileLiteralResultStylesheet(t *testing.T) {
+	sheet, err := Compile([]byte(`<html xsl:version="1.0" xmlns:xsl="http://www.w3.org/1999/XSL/Transform"><p><xsl:value-of select="/a/@b"/></p></html>`))
+	require.NoError(t, err)
+	out, err := sheet.Transform([]byte(`<a b="text"/>`), nil)
+	require.NoError(t, err)
+	assert.Equal(t, "<html>\n  <p>text</p>\n</html>\n", string(out))
+}
+
+func TestTransformEmbeddedStylesheet(t *testing.T) {
+	content, err := Get("tsl-to-html.xslt")
+	require.NoError(t, err)
+	sheet, err := Compile(content)
+	require.NoError(t, err)
+	input, err := os.ReadFile("../etsi119612/testdata/SE-TL.xml")
+	require.NoError(t, err)
+
+	out, err := sheet.Transform(input, map[string]string{"lang": "sv", "tsl.next-update": "Nästa uppdatering"})
+	require.NoError(t, err)
+	html := string(out)
+	assert.True(t, strings.HasPrefix(html, "<!DOCTYPE html SYSTEM \"about:legacy-compat\">\n<html lang=\"sv\" data-theme=\"light\">"))
+	assert.Contains(t, html, `<td class="tsl-territory">SE</td>`)
+	assert.Contains(t, html, `<strong>Nästa uppdatering:</strong><span class="tsl-next-update">2025-10-10T11:16:01Z</span>`)
+	assert.Contains(t, html, `<div>Post- och telestyrelsen (PTS) (sv)</div>`)
+	assert.NotContains(t, html, "xmlns", "the TSL namespaces are excluded")
+
+	// The compiled stylesheet is shared by concurrent transformations
+	var wg sync.WaitGroup
+	results := make([][]byte, 4)
+	errs := make([]error, 4)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = sheet.Transform(input, map[string]string{"lang": "sv", "tsl.next-update": "Nästa uppdatering"})
+		}()
+	}
+	wg.Wait()
+	for i := range results {
+		require.NoError(t, errs[i])
+		assert.Equal(t, html, string(results[i]), fmt.Sprintf("transformation %d", i))
+	}
+}
@@ -0,0 +1,521 @@
+package xslt
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// tokenKind is the kind of an XPath token.
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNumber
+	tokLiteral
+	tokName     // QName, or a name test prefix:*
+	tokStar     // * as a name test
+	tokVariable // $QName
+	tokOperator // and, or, mod, div, *, =, !=, <, <=, >, >=, +, - and |
+	tokSlash
+	tokDoubleSlash
+	tokLParen
+	tokRParen
+	tokLBracket
+	tokRBracket
+	tokComma
+	tokAt
+	tokDoubleColon
+	tokDot
+	tokDoubleDot
+)
+
+// token is a token of an XPath expression.
+type token struct {
+	kind tokenKind
+	text string
+	num  float64
+}
+
+// tokenize splits an XPath expression into tokens, telling operators from
+// names by the preceding token as XPath 1.0 section 3.7 requires.
+func tokenize(s string) ([]token, error) {
+	var tokens []token
+	operatorAllowed := func() bool {
+		if len(tokens) == 0 {
+			return false
+		}
+		switch tokens[len(tokens)-1].kind {
+		case tokAt, tokDoubleColon, tokLParen, tokLBracket, tokComma, tokOperator, tokSlash, tokDoubleSlash:
+			return false
+		}
+		return true
+	}
+	for i := 0; i < len(s); {
+		c := s[i]
+		next := byte(0)
+		if i+1 < len(s) {
+			next = s[i+1]
+		}
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case punctuation[c] != tokEOF:
+			tokens = append(tokens, token{kind: punctuation[c], text: string(c)})
+			i++
+		case c == ':' && next == ':':
+			tokens = append(tokens, token{kind: tokDoubleColon, text: "::"})
+			i += 2
+		case c == '.' && next == '.':
+			tokens = append(tokens, token{kind: tokDoubleDot, text: ".."})
+			i += 2
+		case c == '.' && !isDigit(next):
+			tokens = append(tokens, token{kind: tokDot, text: "."})
+			i++
+		case isDigit(c) || c == '.':
+			start := i
+			for i < len(s) && isDigit(s[i]) {
+				i++
+			}
+			if i < len(s) && s[i] == '.' {
+				i++
+				for i < len(s) && isDigit(s[i]) {
+					i++
+				}
+			}
+			num, _ := strconv.ParseFloat(s[start:i], 64)
+			tokens = append(tokens, token{kind: tokNumber, text: s[start:i], num: num})
+		case c == '"' || c == '\'':
+			end := strings.IndexByte(s[i+1:], c)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string literal")
+			}
+			tokens = append(tokens, token{kind: tokLiteral, text: s[i+1 : i+1+end]})
+			i += end + 2
+		case c == '$':
+			name, n := scanQName(s[i+1:])
+			if name == "" || strings.HasSuffix(name, ":*") {
+				return nil, fmt.Errorf("invalid variable reference")
+			}
+			tokens = append(tokens, token{kind: tokVariable, text: name})
+			i += 1 + n
+		case c == '*':
+			if operatorAllowed() {
+				tokens = append(tokens, token{kind: tokOperator, text: "*"})
+			} else {
+				tokens = append(tokens, token{kind: tokStar, text: "*"})
+			}
+			i++
+		case c == '/':
+			if next == '/' {
+				tokens = append(tokens, token{kind: tokDoubleSlash, text: "//"})
+				i += 2
+			} else {
+				tokens = append(tokens, token{kind: tokSlash, text: "/"})
+				i++
+			}
+		case c == '!' && next == '=', (c == '<' || c == '>') && next == '=':
+			tokens = append(tokens, token{kind: tokOperator, text: s[i : i+2]})
+			i += 2
+		case c == '=' || c == '<' || c == '>' || c == '+' || c == '-' || c == '|':
+			tokens = append(tokens, token{kind: tokOperator, text: string(c)})
+			i++
+		default:
+			name, n := scanQName(s[i:])
+			if name == "" {
+				r, _ := utf8.DecodeRuneInString(s[i:])
+				return nil, fmt.Errorf("unexpected character %q", r)
+			}
+			if operatorAllowed() && (name == "and" || name == "or" || name == "mod" || name == "div") {
+				tokens = append(tokens, token{kind: tokOperator, text: name})
+			} else {
+				tokens = append(tokens, token{kind: tokName, text: name})
+			}
+			i += n
+		}
+	}
+	return append(tokens, token{kind: tokEOF}), nil
+}
+
+// punctuation are the tokens of a single character that are not operators.
+var punctuation = [256]tokenKind{'(': tokLParen, ')': tokRParen, '[': tokLBracket, ']': tokRBracket, ',': tokComma, '@': tokAt}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// scanNCName returns the length of the NCName at the start of s.
+func scanNCName(s string) int {
+	n := 0
+	for n < len(s) {
+		r, size := utf8.DecodeRuneInString(s[n:])
+		if !(r == '_' || unicode.IsLetter(r) || (n > 0 && (r == '-' || r == '.' || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r)))) {
+			break
+		}
+		n += size
+	}
+	return n
+}
+
+// scanQName returns the QName, or name test prefix:*, at the start of s and
+// its length.
+func scanQName(s string) (string, int) {
+	n := scanNCName(s)
+	if n == 0 {
+		return "", 0
+	}
+	if n+1 < len(s) && s[n] == ':' && s[n+1] != ':' {
+		if s[n+1] == '*' {
+			return s[:n+2], n + 2
+		}
+		if m := scanNCName(s[n+1:]); m > 0 {
+			return s[:n+1+m], n + 1 + m
+		}
+	}
+	return s[:n], n
+}
+
+// xpathError is the panic value of a failed parse, turned into an error by
+// the parse functions.
+type xpathError struct {
+	msg string
+}
+
+// parser parses the tokens of an XPath expression or XSLT pattern, resolving
+// prefixes with the namespaces in scope in the stylesheet.
+type parser struct {
+	what   string // Description of the source in errors
+	source string
+	tokens []token
+	pos    int
+	ns     map[string]string
+}
+
+// compileExpr parses an XPath 1.0 expression.
+func compileExpr(s string, ns map[string]string) (e expr, err error) {
+	p, err := newParser("XPath expression", s, ns)
+	if err != nil {
+		return nil, err
+	}
+	defer p.recover(&err)
+	e = p.parseExpr()
+	p.expect(tokEOF)
+	return e, nil
+}
+
+// newParser returns a parser for s, which is described as what in errors.
+func newParser(what, s string, ns map[string]string) (*parser, error) {
+	tokens, err := tokenize(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s %q: %v", what, s, err)
+	}
+	return &parser{what: what, source: s, tokens: tokens, ns: ns}, nil
+}
+
+// recover turns the panic of a parse error into an error.
+func (p *parser) recover(err *error) {
+	if r := recover(); r != nil {
+		perr, ok := r.(xpathError)
+		if !ok {
+			panic(r)
+		}
+		*err = fmt.Errorf("invalid %s %q: %s", p.what, p.source, perr.msg)
+	}
+}
+
+func (p *parser) fail(format string, args ...any) {
+	panic(xpathError{msg: fmt.Sprintf(format, args...)})
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+// peekAt returns the token n positions ahead.
+func (p *parser) peekAt(n int) token {
+	if p.pos+n < len(p.tokens) {
+		return p.tokens[p.pos+n]
+	}
+	return token{kind: tokEOF}
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) expect(kind tokenKind) token {
+	t := p.next()
+	if t.kind != kind {
+		if t.kind == tokEOF {
+			p.fail("unexpected end of expression")
+		}
+		p.fail("unexpected %q", t.text)
+	}
+	return t
+}
+
+// isOperator reports whether the next token is the operator op.
+func (p *parser) isOperator(op string) bool {
+	t := p.peek()
+	return t.kind == tokOperator && t.text == op
+}
+
+// expandName resolves the prefix of a QName. Unprefixed names are in no
+// namespace, as XPath 1.0 requires.
+func (p *parser) expandName(qname string) (space, local string) {
+	prefix, local, ok := strings.Cut(qname, ":")
+	if !ok {
+		return "", qname
+	}
+	return p.namespace(prefix), local
+}
+
+// namespace returns the namespace URI bound to prefix.
+func (p *parser) namespace(prefix string) string {
+	space := p.ns[prefix]
+	if space == "" {
+		p.fail("undeclared namespace prefix %q", prefix)
+	}
+	return space
+}
+
+func (p *parser) parseExpr() expr {
+	return p.parseBinary(0)
+}
+
+// binaryLevels are the binary operators by increasing precedence.
+var binaryLevels = [][]string{
+	{"or"},
+	{"and"},
+	{"=", "!="},
+	{"<", "<=", ">", ">="},
+	{"+", "-"},
+	{"*", "div", "mod"},
+}
+
+// parseBinary parses the binary operators of binaryLevels[level] and above,
+// all left associative.
+func (p *parser) parseBinary(level int) expr {
+	if level == len(binaryLevels) {
+		return p.parseUnary()
+	}
+	left := p.parseBinary(level + 1)
+	for {
+		t := p.peek()
+		if t.kind != tokOperator || !contains(binaryLevels[level], t.text) {
+			return left
+		}
+		p.next()
+		left = &binaryExpr{op: t.text, left: left, right: p.parseBinary(level + 1)}
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parser) parseUnary() expr {
+	if p.isOperator("-") {
+		p.next()
+		return &negateExpr{operand: p.parseUnary()}
+	}
+	left := p.parsePath()
+	for p.isOperator("|") {
+		p.next()
+		left = &unionExpr{left: left, right: p.parsePath()}
+	}
+	return left
+}
+
+// nodeTypes are the node type tests, which look like function calls.
+var nodeTypes = map[string]bool{"node": true, "text": true, "comment": true, "processing-instruction": true}
+
+func (p *parser) parsePath() expr {
+	t := p.peek()
+	switch {
+	case t.kind == tokVariable, t.kind == tokLParen, t.kind == tokLiteral, t.kind == tokNumber,
+		t.kind == tokName && p.peekAt(1).kind == tokLParen && !nodeTypes[t.text]:
+		var e expr = p.parsePrimary()
+		if preds := p.parsePredicates(); len(preds) > 0 {
+			e = &filterExpr{primary: e, preds: preds}
+		}
+		if k := p.peek().kind; k == tokSlash || k == tokDoubleSlash {
+			return &pathExpr{filter: e, steps: p.parseRelativePath()}
+		}
+		return e
+	case t.kind == tokSlash:
+		p.next()
+		if p.startsStep() {
+			return &pathExpr{absolute: true, steps: p.parseRelativeSteps()}
+		}
+		return &pathExpr{absolute: true}
+	case t.kind == tokDoubleSlash:
+		return &pathExpr{absolute: true, steps: p.parseRelativePath()}
+	}
+	return &pathExpr{steps: p.parseRelativeSteps()}
+}
+
+// startsStep reports whether the next token starts a location step.
+func (p *parser) startsStep() bool {
+	switch p.peek().kind {
+	case tokName, tokStar, tokAt, tokDot, tokDoubleDot:
+		return true
+	}
+	return false
+}
+
+// descendantOrSelf is the step "//" abbreviates.
+var descendantOrSelf = step{axis: axisDescendantOrSelf, test: nodeTest{kind: testNode}}
+
+// parseRelativePath parses "/" or "//" followed by a relative location path.
+func (p *parser) parseRelativePath() []step {
+	var steps []step
+	if p.next().kind == tokDoubleSlash {
+		steps = append(steps, descendantOrSelf)
+	}
+	return append(steps, p.parseRelativeSteps()...)
+}
+
+// parseRelativeSteps parses a relative location path.
+func (p *parser) parseRelativeSteps() []step {
+	steps := []step{p.parseStep()}
+	for {
+		switch p.peek().kind {
+		case tokSlash:
+			p.next()
+		case tokDoubleSlash:
+			p.next()
+			steps = append(steps, descendantOrSelf)
+		default:
+			return steps
+		}
+		steps = append(steps, p.parseStep())
+	}
+}
+
+func (p *parser) parseStep() step {
+	switch p.peek().kind {
+	case tokDot:
+		p.next()
+		return step{axis: axisSelf, test: nodeTest{kind: testNode}}
+	case tokDoubleDot:
+		p.next()
+		return step{axis: axisParent, test: nodeTest{kind: testNode}}
+	}
+	s := step{axis: axisChild}
+	if p.peek().kind == tokAt {
+		p.next()
+		s.axis = axisAttribute
+	} else if t := p.peek(); t.kind == tokName && p.peekAt(1).kind == tokDoubleColon {
+		a, ok := axisNames[t.text]
+		if !ok {
+			p.fail("unsupported axis %q", t.text)
+		}
+		p.next()
+		p.next()
+		s.axis = a
+	}
+	s.test = p.parseNodeTest()
+	s.preds = p.parsePredicates()
+	return s
+}
+
+func (p *parser) parseNodeTest() nodeTest {
+	t := p.next()
+	switch t.kind {
+	case tokStar:
+		return nodeTest{kind: testName, local: "*", anyNamespace: true}
+	case tokName:
+	default:
+		if t.kind == tokEOF {
+			p.fail("unexpected end of expression")
+		}
+		p.fail("unexpected %q", t.text)
+	}
+	if nodeTypes[t.text] && p.peek().kind == tokLParen {
+		p.next()
+		test := nodeTest{kind: map[string]testKind{"node": testNode, "text": testText, "comment": testComment, "processing-instruction": testPI}[t.text]}
+		if test.kind == testPI && p.peek().kind == tokLiteral {
+			test.target = p.next().text
+		}
+		p.expect(tokRParen)
+		return test
+	}
+	if prefix, ok := strings.CutSuffix(t.text, ":*"); ok {
+		return nodeTest{kind: testName, space: p.namespace(prefix), local: "*"}
+	}
+	space, local := p.expandName(t.text)
+	return nodeTest{kind: testName, space: space, local: local}
+}
+
+func (p *parser) parsePredicates() []expr {
+	var preds []expr
+	for p.peek().kind == tokLBracket {
+		p.next()
+		preds = append(preds, p.parseExpr())
+		p.expect(tokRBracket)
+	}
+	return preds
+}
+
+func (p *parser) parsePrimary() expr {
+	t := p.next()
+	switch t.kind {
+	case tokVariable:
+		space, local := p.expandName(t.text)
+		return &variableExpr{name: expandedName(space, local)}
+	case tokLParen:
+		e := p.parseExpr()
+		p.expect(tokRParen)
+		return e
+	case tokLiteral:
+		return literalExpr(t.text)
+	case tokNumber:
+		return numberExpr(t.num)
+	}
+	// A function call
+	if strings.Contains(t.text, ":") {
+		p.fail("unsupported extension function %s()", t.text)
+	}
+	fn, ok := functions[t.text]
+	if !ok {
+		if unsupportedFunctions[t.text] {
+			p.fail("unsupported function %s()", t.text)
+		}
+		p.fail("unknown function %s()", t.text)
+	}
+	p.expect(tokLParen)
+	var args []expr
+	if p.peek().kind != tokRParen {
+		args = append(args, p.parseExpr())
+		for p.peek().kind == tokComma {
+			p.next()
+			args = append(args, p.parseExpr())
+		}
+	}
+	p.expect(tokRParen)
+	if len(args) < fn.min || (fn.max >= 0 && len(args) > fn.max) {
+		p.fail("wrong number of arguments for %s()", t.text)
+	}
+	return &callExpr{name: t.text, fn: fn.call, args: args}
+}
+
+// expandedName returns the key of a variable, parameter, template or mode
+// name: the local name, preceded by the namespace URI in braces if any.
+func expandedName(space, local string) string {
+	if space == "" {
+		return local
+	}
+	return "{" + space + "}" + local
+}
@@ -0,0 +1,772 @@
+package xslt
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// An XPath value is a nodeSet, string, float64 or bool.
+type value = any
+
+// nodeSet is a node-set, in document order without duplicates.
+type nodeSet []*node
+
+// evalContext is the context an expression is evaluated in.
+type evalContext struct {
+	node    *node
+	pos     int                              // Context position
+	size    int                              // Context size
+	current *node                            // Current node of XSLT, the result of current()
+	lookup  func(name string) (value, error) // Value of a variable by expanded name
+}
+
+// expr is a compiled XPath expression.
+type expr interface {
+	eval(c *evalContext) (value, error)
+}
+
+type literalExpr string
+
+func (e literalExpr) eval(*evalContext) (value, error) {
+	return string(e), nil
+}
+
+type numberExpr float64
+
+func (e numberExpr) eval(*evalContext) (value, error) {
+	return float64(e), nil
+}
+
+type variableExpr struct {
+	name string
+}
+
+func (e *variableExpr) eval(c *evalContext) (value, error) {
+	return c.lookup(e.name)
+}
+
+type negateExpr struct {
+	operand expr
+}
+
+func (e *negateExpr) eval(c *evalContext) (value, error) {
+	v, err := e.operand.eval(c)
+	if err != nil {
+		return nil, err
+	}
+	return -toNumber(v), nil
+}
+
+type binaryExpr struct {
+	op          string
+	left, right expr
+}
+
+func (e *binaryExpr) eval(c *evalContext) (value, error) {
+	left, err := e.left.eval(c)
+	if err != nil {
+		return nil, err
+	}
+	switch e.op {
+	case "or":
+		if toBoolean(left) {
+			return true, nil
+		}
+	case "and":
+		if !toBoolean(left) {
+			return false, nil
+		}
+	}
+	right, err := e.right.eval(c)
+	if err != nil {
+		return nil, err
+	}
+	switch e.op {
+	case "or", "and":
+		return toBoolean(right), nil
+	case "=", "!=", "<", "<=", ">", ">=":
+		return compare(e.op, left, right), nil
+	}
+	a, b := toNumber(left), toNumber(right)
+	switch e.op {
+	case "+":
+		return a + b, nil
+	case "-":
+		return a - b, nil
+	case "*":
+		return a * b, nil
+	case "div":
+		return a / b, nil
+	}
+	return math.Mod(a, b), nil
+}
+
+type unionExpr struct {
+	left, right expr
+}
+
+func (e *unionExpr) eval(c *evalContext) (value, error) {
+	left, err := evalNodeSet(e.left, c)
+	if err != nil {
+		return nil, err
+	}
+	right, err := evalNodeSet(e.right, c)
+	if err != nil {
+		return nil, err
+	}
+	return documentOrder(append(append(nodeSet{}, left...), right...)), nil
+}
+
+// filterExpr is a primary expression with predicates.
+type filterExpr struct {
+	primary expr
+	preds   []expr
+}
+
+func (e *filterExpr) eval(c *evalContext) (value, error) {
+	nodes, err := evalNodeSet(e.primary, c)
+	if err != nil {
+		return nil, err
+	}
+	for _, pred := range e.preds {
+		if nodes, err = filterNodes(c, nodes, pred); err != nil {
+			return nil, err
+		}
+	}
+	return nodes, nil
+}
+
+// pathExpr is a location path, or a filter expression followed by one.
+type pathExpr struct {
+	filter   expr // Expression selecting the start nodes, nil for a location path
+	absolute bool // Location path starting at the root
+	steps    []step
+}
+
+func (e *pathExpr) eval(c *evalContext) (value, error) {
+	var nodes nodeSet
+	switch {
+	case e.filter != nil:
+		var err error
+		if nodes, err = evalNodeSet(e.filter, c); err != nil {
+			return nil, err
+		}
+	case e.absolute:
+		nodes = nodeSet{c.node.root()}
+	default:
+		nodes = nodeSet{c.node}
+	}
+	for i := range e.steps {
+		var err error
+		if nodes, err = e.steps[i].apply(c, nodes); err != nil {
+			return nil, err
+		}
+	}
+	return nodes, nil
+}
+
+// evalNodeSet evaluates an expression that must return a node-set.
+func evalNodeSet(e expr, c *evalContext) (nodeSet, error) {
+	v, err := e.eval(c)
+	if err != nil {
+		return nil, err
+	}
+	nodes, ok := v.(nodeSet)
+	if !ok {
+		return nil, fmt.Errorf("expression does not return a node-set")
+	}
+	return nodes, nil
+}
+
+// filterNodes returns the nodes for which pred is true, with the nodes as
+// context positions.
+func filterNodes(c *evalContext, nodes nodeSet, pred expr) (nodeSet, error) {
+	var result nodeSet
+	sub := *c
+	sub.size = len(nodes)
+	for i, n := range nodes {
+		sub.node, sub.pos = n, i+1
+		v, err := pred.eval(&sub)
+		if err != nil {
+			return nil, err
+		}
+		if num, ok := v.(float64); ok {
+			if num == float64(i+1) {
+				result = append(result, n)
+			}
+		} else if toBoolean(v) {
+			result = append(result, n)
+		}
+	}
+	return result, nil
+}
+
+// documentOrder sorts nodes into document order and removes duplicates.
+func documentOrder(nodes nodeSet) nodeSet {
+	sort.Slice(nodes, func(i, j int) bool { return before(nodes[i], nodes[j]) })
+	result := nodes[:0]
+	for i, n := range nodes {
+		if i == 0 || n != nodes[i-1] {
+			result = append(result, n)
+		}
+	}
+	return result
+}
+
+// axis is an XPath axis.
+type axis int
+
+const (
+	axisChild axis = iota
+	axisDescendant
+	axisDescendantOrSelf
+	axisParent
+	axisAncestor
+	axisAncestorOrSelf
+	axisFollowingSibling
+	axisPrecedingSibling
+	axisFollowing
+	axisPreceding
+	axisAttribute
+	axisSelf
+)
+
+// axisNames are the supported axes; the namespace axis is not.
+var axisNames = map[string]axis{
+	"child":              axisChild,
+	"descendant":         axisDescendant,
+	"descendant-or-self": axisDescendantOrSelf,
+	"parent":             axisParent,
+	"ancestor":           axisAncestor,
+	"ancestor-or-self":   axisAncestorOrSelf,
+	"following-sibling":  axisFollowingSibling,
+	"preceding-sibling":  axisPrecedingSibling,
+	"following":          axisFollowing,
+	"preceding":          axisPreceding,
+	"attribute":          axisAttribute,
+	"self":               axisSelf,
+}
+
+// reverse reports whether the axis lists nodes in reverse document order.
+func (a axis) reverse() bool {
+	switch a {
+	case axisParent, axisAncestor, axisAncestorOrSelf, axisPrecedingSibling, axisPreceding:
+		return true
+	}
+	return false
+}
+
+// nodes returns the nodes on the axis from n, in the order of the axis.
+func (a axis) nodes(n *node) []*node {
+	var result []*node
+	switch a {
+	case axisChild:
+		return n.children
+	case axisAttribute:
+		return n.attrs
+	case axisSelf:
+		return []*node{n}
+	case axisDescendantOrSelf:
+		result = append(result, n)
+		fallthrough
+	case axisDescendant:
+		return appendDescendants(result, n)
+	case axisParent:
+		if n.parent != nil {
+			return []*node{n.parent}
+		}
+	case axisAncestorOrSelf:
+		result = append(result, n)
+		fallthrough
+	case axisAncestor:
+		for p := n.parent; p != nil; p = p.parent {
+			result = append(result, p)
+		}
+	case axisFollowingSibling:
+		if n.typ != attributeNode && n.parent != nil {
+			return n.parent.children[n.index+1:]
+		}
+	case axisPrecedingSibling:
+		if n.typ != attributeNode && n.parent != nil {
+			for i := n.index - 1; i >= 0; i-- {
+				result = append(result, n.parent.children[i])
+			}
+		}
+	case axisFollowing:
+		x := n
+		if x.typ == attributeNode {
+			x = x.parent
+			result = appendDescendants(result, x)
+		}
+		for ; x.parent != nil; x = x.parent {
+			for _, sibling := range x.parent.children[x.index+1:] {
+				result = appendDescendants(append(result, sibling), sibling)
+			}
+		}
+	case axisPreceding:
+		x := n
+		if x.typ == attributeNode {
+			x = x.parent
+		}
+		for ; x.parent != nil; x = x.parent {
+			for i := x.index - 1; i >= 0; i-- {
+				sibling := x.parent.children[i]
+				descendants := appendDescendants(nil, sibling)
+				for j := len(descendants) - 1; j >= 0; j-- {
+					result = append(result, descendants[j])
+				}
+				result = append(result, sibling)
+			}
+		}
+	}
+	return result
+}
+
+// appendDescendants appends the descendants of n to list in document order.
+func appendDescendants(list []*node, n *node) []*node {
+	for _, c := range n.children {
+		list = appendDescendants(append(list, c), c)
+	}
+	return list
+}
+
+// testKind is the kind of a node test.
+type testKind int
+
+const (
+	testName testKind = iota
+	testNode
+	testText
+	testComment
+	testPI
+)
+
+// nodeTest is the node test of a location step.
+type nodeTest struct {
+	kind         testKind
+	space        string // Namespace of a name test
+	local        string // Local name of a name test, "*" for any
+	anyNamespace bool   // Name test "*"
+	target       string // Target of a processing-instruction test, "" for any
+}
+
+// match reports whether n passes the test on an axis whose principal node
+// type is principal.
+func (t *nodeTest) match(n *node, principal nodeType) bool {
+	switch t.kind {
+	case testNode:
+		return true
+	case testText:
+		return n.typ == textNode
+	case testComment:
+		return n.typ == commentNode
+	case testPI:
+		return n.typ == piNode && (t.target == "" || n.local == t.target)
+	}
+	return n.typ == principal && (t.local == "*" || n.local == t.local) && (t.anyNamespace || n.space == t.space)
+}
+
+// step is a location step.
+type step struct {
+	axis  axis
+	test  nodeTest
+	preds []expr
+}
+
+// apply returns the nodes selected by the step from each of nodes.
+func (s *step) apply(c *evalContext, nodes nodeSet) (nodeSet, error) {
+	principal := elementNode
+	if s.axis == axisAttribute {
+		principal = attributeNode
+	}
+	var result nodeSet
+	for _, n := range nodes {
+		var selected nodeSet
+		for _, candidate := range s.axis.nodes(n) {
+			if s.test.match(candidate, principal) {
+				selected = append(selected, candidate)
+			}
+		}
+		for _, pred := range s.preds {
+			var err error
+			if selected, err = filterNodes(c, selected, pred); err != nil {
+				return nil, err
+			}
+		}
+		result = append(result, selected...)
+	}
+	if len(nodes) > 1 || s.axis.reverse() {
+		result = documentOrder(result)
+	}
+	return result, nil
+}
+
+// toString converts a value to a string as the string() function does.
+func toString(v value) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case bool:
+		if v {
+			return "true"
+		}
+		return "false"
+	case float64:
+		return numberToString(v)
+	case nodeSet:
+		if len(v) == 0 {
+			return ""
+		}
+		return v[0].stringValue()
+	}
+	return ""
+}
+
+// numberToString formats a number as XPath does, without an exponent.
+func numberToString(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	case f == 0:
+		return "0"
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// numberSyntax is the syntax of strings converted to numbers.
+var numberSyntax = regexp.MustCompile(`^-?([0-9]+(\.[0-9]*)?|\.[0-9]+)$`)
+
+// toNumber converts a value to a number as the number() function does.
+func toNumber(v value) float64 {
+	switch v := v.(type) {
+	case float64:
+		return v
+	case bool:
+		if v {
+			return 1
+		}
+		return 0
+	}
+	s := strings.Trim(toString(v), " \t\r\n")
+	if !numberSyntax.MatchString(s) {
+		return math.NaN()
+	}
+	f, _ := strconv.ParseFloat(s, 64)
+	return f
+}
+
+// toBoolean converts a value to a boolean as the boolean() function does.
+func toBoolean(v value) bool {
+	switch v := v.(type) {
+	case bool:
+		return v
+	case float64:
+		return v != 0 && !math.IsNaN(v)
+	case string:
+		return v != ""
+	case nodeSet:
+		return len(v) > 0
+	}
+	return false
+}
+
+// compare compares two values with a comparison operator, following the
+// rules of XPath 1.0 section 3.4 for node-sets.
+func compare(op string, a, b value) bool {
+	aNodes, aIsNodes := a.(nodeSet)
+	bNodes, bIsNodes := b.(nodeSet)
+	switch {
+	case aIsNodes && bIsNodes:
+		for _, x := range aNodes {
+			for _, y := range bNodes {
+				if compareAtomic(op, x.stringValue(), y.stringValue()) {
+					return true
+				}
+			}
+		}
+		return false
+	case aIsNodes || bIsNodes:
+		nodes, other, swapped := aNodes, b, false
+		if bIsNodes {
+			nodes, other, swapped = bNodes, a, true
+		}
+		if _, ok := other.(bool); ok {
+			if swapped {
+				return compareAtomic(op, other, len(nodes) > 0)
+			}
+			return compareAtomic(op, len(nodes) > 0, other)
+		}
+		for _, n := range nodes {
+			var x value = n.stringValue()
+			if _, ok := other.(float64); ok {
+				x = toNumber(x)
+			}
+			if swapped && compareAtomic(op, other, x) || !swapped && compareAtomic(op, x, other) {
+				return true
+			}
+		}
+		return false
+	}
+	return compareAtomic(op, a, b)
+}
+
+// compareAtomic compares two values that are not node-sets.
+func compareAtomic(op string, a, b value) bool {
+	if op == "=" || op == "!=" {
+		var equal bool
+		_, aBool := a.(bool)
+		_, bBool := b.(bool)
+		_, aNum := a.(float64)
+		_, bNum := b.(float64)
+		switch {
+		case aBool || bBool:
+			equal = toBoolean(a) == toBoolean(b)
+		case aNum || bNum:
+			equal = toNumber(a) == toNumber(b)
+		default:
+			equal = toString(a) == toString(b)
+		}
+		return equal == (op == "=")
+	}
+	x, y := toNumber(a), toNumber(b)
+	switch op {
+	case "<":
+		return x < y
+	case "<=":
+		return x <= y
+	case ">":
+		return x > y
+	}
+	return x >= y
+}
+
+// function is the implementation of an XPath or XSLT function.
+type function struct {
+	min, max int // Number of arguments, max -1 for any
+	call     func(c *evalContext, args []value) (value, error)
+}
+
+type callExpr struct {
+	name string
+	fn   func(c *evalContext, args []value) (value, error)
+	args []expr
+}
+
+func (e *callExpr) eval(c *evalContext) (value, error) {
+	args := make([]value, len(e.args))
+	for i, arg := range e.args {
+		v, err := arg.eval(c)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+	v, err := e.fn(c, args)
+	if err != nil {
+		return nil, fmt.Errorf("%s(): %w", e.name, err)
+	}
+	return v, nil
+}
+
+// functions are the functions of XPath 1.0 and the supported functions of
+// XSLT 1.0.
+var functions = map[string]function{
+	"last":     {0, 0, func(c *evalContext, _ []value) (value, error) { return float64(c.size), nil }},
+	"position": {0, 0, func(c *evalContext, _ []value) (value, error) { return float64(c.pos), nil }},
+	"count": {1, 1, func(_ *evalContext, args []value) (value, error) {
+		nodes, err := nodeSetArg(args[0])
+		return float64(len(nodes)), err
+	}},
+	"local-name": {0, 1, func(c *evalContext, args []value) (value, error) {
+		n, err := optionalNode(c, args)
+		if n == nil || n.typ == textNode || n.typ == commentNode || n.typ == rootNode {
+			return "", err
+		}
+		return n.local, nil
+	}},
+	"namespace-uri": {0, 1, func(c *evalContext, args []value) (value, error) {
+		n, err := optionalNode(c, args)
+		if n == nil {
+			return "", err
+		}
+		return n.space, nil
+	}},
+	"name": {0, 1, func(c *evalContext, args []value) (value, error) {
+		n, err := optionalNode(c, args)
+		if n == nil || n.typ == textNode || n.typ == commentNode || n.typ == rootNode {
+			return "", err
+		}
+		return n.name(), nil
+	}},
+	"string": {0, 1, func(c *evalContext, args []value) (value, error) {
+		return toString(optionalArg(c, args)), nil
+	}},
+	"concat": {2, -1, func(_ *evalContext, args []value) (value, error) {
+		var b strings.Builder
+		for _, arg := range args {
+			b.WriteString(toString(arg))
+		}
+		return b.String(), nil
+	}},
+	"starts-with": {2, 2, func(_ *evalContext, args []value) (value, error) {
+		return strings.HasPrefix(toString(args[0]), toString(args[1])), nil
+	}},
+	"contains": {2, 2, func(_ *evalContext, args []value) (value, error) {
+		return strings.Contains(toString(args[0]), toString(args[1])), nil
+	}},
+	"substring-before": {2, 2, func(_ *evalContext, args []value) (value, error) {
+		before, _, found := strings.Cut(toString(args[0]), toString(args[1]))
+		if !found {
+			return "", nil
+		}
+		return before, nil
+	}},
+	"substring-after": {2, 2, func(_ *evalContext, args []value) (value, error) {
+		_, after, _ := strings.Cut(toString(args[0]), toString(args[1]))
+		return after, nil
+	}},
+	"substring": {2, 3, fnSubstring},
+	"string-length": {0, 1, func(c *evalContext, args []value) (value, error) {
+		return float64(utf8.RuneCountInString(toString(optionalArg(c, args)))), nil
+	}},
+	"normalize-space": {0, 1, func(c *evalContext, args []value) (value, error) {
+		return strings.Join(strings.FieldsFunc(toString(optionalArg(c, args)), isSpace), " "), nil
+	}},
+	"translate": {3, 3, fnTranslate},
+	"boolean":   {1, 1, func(_ *evalContext, args []value) (value, error) { return toBoolean(args[0]), nil }},
+	"not":       {1, 1, func(_ *evalContext, args []value) (value, error) { return !toBoolean(args[0]), nil }},
+	"true":      {0, 0, func(*evalContext, []value) (value, error) { return true, nil }},
+	"false":     {0, 0, func(*evalContext, []value) (value, error) { return false, nil }},
+	"lang":      {1, 1, fnLang},
+	"number": {0, 1, func(c *evalContext, args []value) (value, error) {
+		return toNumber(optionalArg(c, args)), nil
+	}},
+	"sum": {1, 1, func(_ *evalContext, args []value) (value, error) {
+		nodes, err := nodeSetArg(args[0])
+		sum := 0.0
+		for _, n := range nodes {
+			sum += toNumber(n.stringValue())
+		}
+		return sum, err
+	}},
+	"floor":   {1, 1, func(_ *evalContext, args []value) (value, error) { return math.Floor(toNumber(args[0])), nil }},
+	"ceiling": {1, 1, func(_ *evalContext, args []value) (value, error) { return math.Ceil(toNumber(args[0])), nil }},
+	"round":   {1, 1, func(_ *evalContext, args []value) (value, error) { return round(toNumber(args[0])), nil }},
+	"current": {0, 0, func(c *evalContext, _ []value) (value, error) { return nodeSet{c.current}, nil }},
+	"generate-id": {0, 1, func(c *evalContext, args []value) (value, error) {
+		n, err := optionalNode(c, args)
+		if n == nil {
+			return "", err
+		}
+		return fmt.Sprintf("id%dn%d", n.doc.id, n.order), nil
+	}},
+}
+
+// unsupportedFunctions are the functions of XSLT 1.0 the processor does not
+// implement.
+var unsupportedFunctions = map[string]bool{
+	"id": true, "key": true, "document": true, "format-number": true, "unparsed-entity-uri": true,
+	"system-property": true, "element-available": true, "function-available": true,
+}
+
+// isSpace reports whether r is XML whitespace.
+func isSpace(r rune) bool {
+	return r == ' ' || r == '\t' || r == '\n' || r == '\r'
+}
+
+// nodeSetArg returns an argument that must be a node-set.
+func nodeSetArg(arg value) (nodeSet, error) {
+	nodes, ok := arg.(nodeSet)
+	if !ok {
+		return nil, fmt.Errorf("argument is not a node-set")
+	}
+	return nodes, nil
+}
+
+// optionalArg returns the argument of a function whose argument defaults to
+// the context node.
+func optionalArg(c *evalContext, args []value) value {
+	if len(args) == 0 {
+		return nodeSet{c.node}
+	}
+	return args[0]
+}
+
+// optionalNode returns the first node of the node-set argument of a function
+// whose argument defaults to the context node, nil for an empty node-set.
+func optionalNode(c *evalContext, args []value) (*node, error) {
+	nodes, err := nodeSetArg(optionalArg(c, args))
+	if err != nil || len(nodes) == 0 {
+		return nil, err
+	}
+	return nodes[0], nil
+}
+
+// round rounds as XPath does: to the closest integer, halves towards positive
+// infinity.
+func round(f float64) float64 {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return f
+	}
+	if f < 0 && f >= -0.5 {
+		return math.Copysign(0, -1)
+	}
+	return math.Floor(f + 0.5)
+}
+
+func fnSubstring(_ *evalContext, args []value) (value, error) {
+	runes := []rune(toString(args[0]))
+	start := round(toNumber(args[1]))
+	end := math.Inf(1)
+	if len(args) == 3 {
+		end = start + round(toNumber(args[2]))
+	}
+	var b strings.Builder
+	for i, r := range runes {
+		if pos := float64(i + 1); pos >= start && pos < end {
+			b.WriteRune(r)
+		}
+	}
+	return b.String(), nil
+}
+
+func fnTranslate(_ *evalContext, args []value) (value, error) {
+	from, to := []rune(toString(args[1])), []rune(toString(args[2]))
+	mapping := make(map[rune]rune, len(from))
+	for i, r := range from {
+		if _, ok := mapping[r]; ok {
+			continue
+		}
+		if i < len(to) {
+			mapping[r] = to[i]
+		} else {
+			mapping[r] = -1
+		}
+	}
+	return strings.Map(func(r rune) rune {
+		if m, ok := mapping[r]; ok {
+			return m
+		}
+		return r
+	}, toString(args[0])), nil
+}
+
+func fnLang(c *evalContext, args []value) (value, error) {
+	want := strings.ToLower(toString(args[0]))
+	for n := c.node; n != nil; n = n.parent {
+		if lang, ok := n.attr(xmlNamespace, "lang"); ok {
+			lang = strings.ToLower(lang)
+			return lang == want || strings.HasPrefix(lang, want+"-"), nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,212 @@
+package xslt
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const xpathTestDocument = `<?xml version="1.0"?>
+<list xmlns="urn:test" xmlns:x="urn:other" version="2">
+  <item id="a" x:kind="first" xml:lang="en-GB">one</item>
+  <!-- note -->
+  <item id="b">two<sub>2</sub></item>
+  <item id="c">3</item>
+  <?target data?>
+</list>`
+
+// evalTest evaluates an XPath expression on xpathTestDocument, with the
+// document element as context node.
+func evalTest(t *testing.T, expression string) value {
+	t.Helper()
+	doc, err := parseDocument([]byte(xpathTestDocument))
+	require.NoError(t, err)
+	e, err := compileExpr(expression, map[string]string{"t": "urn:test", "x": "urn:other"})
+	require.NoError(t, err)
+	el := documentElement(doc)
+	v, err := e.eval(&evalContext{node: el, pos: 1, size: 1, current: el, lookup: func(name string) (value, error) {
+		return map[string]value{"two": 2.0, "name": "item"}[name], nil
+	}})
+	require.NoError(t, err)
+	return v
+}
+
+func TestXPathExpressions(t *testing.T) {
+	tests := []struct {
+		expression string
+		want       value
+	}{
+		// Location paths
+		{"count(t:item)", 3.0},
+		{"count(item)", 0.0},
+		{"count(*)", 3.0},
+		{"count(t:*)", 3.0},
+		{"count(x:*)", 0.0},
+		{"count(node())", 11.0},
+		{"count(text())", 6.0},
+		{"count(comment())", 1.0},
+		{"count(processing-instruction('target'))", 1.0},
+		{"count(//t:sub)", 1.0},
+		{"count(/t:list/t:item/@id)", 3.0},
+		{"count(@*)", 1.0},
+		{"string(t:item[2]/@id)", "b"},
+		{"string(t:item[last()]/@id)", "c"},
+		{"string(t:item[@id = 'b']/following-sibling::t:item/@id)", "c"},
+		{"string(t:item[3]/preceding-sibling::t:item[1]/@id)", "b"},
+		{"string((t:item[3]/preceding-sibling::t:item)[1]/@id)", "a"},
+		{"string(t:item[3]/ancestor::*/@version)", "2"},
+		{"string(//t:sub/..//@id)", "b"},
+		{"count(//t:sub/preceding::t:item)", 1.0},
+		{"count(t:item[1]/following::node())", 13.0},
+		{"string(t:item[@x:kind]/@id)", "a"},
+		{"name(t:item/@x:kind)", "x:kind"},
+		{"local-name(t:item/@x:kind)", "kind"},
+		{"namespace-uri(t:item)", "urn:test"},
+		{"count(t:item | t:item[1] | @version)", 4.0},
+		{"string(t:item[. = 3]/@id)", "c"},
+		{"count(t:item[position() > 1])", 2.0},
+		{"count(self::t:list)", 1.0},
+		{"string(.//t:item[2])", "two2"},
+
+		// Operators
+		{"1 + 2 * 3", 7.0},
+		{"(1 + 2) * 3", 9.0},
+		{"7 mod 3", 1.0},
+		{"7 div 2", 3.5},
+		{"- 2 - -3", 1.0},
+		{"$two * $two", 4.0},
+		{"1 < 2 and 2 <= 2", true},
+		{"1 > 2 or 3 >= 4", false},
+		{"t:item = 'two2'", true},
+		{"t:item != 'one'", true},
+		{"t:item > 2", true},
+		{"t:item = t:item[1]", true},
+		{"@version = 2", true},
+		{"t:item = true()", true},
+		{"t:missing = false()", true},
+		{"'1' = 1.0", true},
+
+		// Functions
+		{"concat('a', 'b', 'c')", "abc"},
+		{"starts-with('abc', 'ab')", true},
+		{"contains('abc', 'd')", false},
+		{"substring-before('2025-04-10', '-')", "2025"},
+		{"substring-before('2025', '-')", ""},
+		{"substring-after('http://x/StatusDetn/y', 'StatusDetn/')", "y"},
+		{"substring('12345', 2, 3)", "234"},
+		{"substring('12345', 1.5, 2.6)", "234"},
+		{"substring('12345', 0, 3)", "12"},
+		{"string-length('héllo')", 5.0},
+		{"normalize-space('  a \n b  ')", "a b"},
+		{"translate('bar', 'abc', 'ABC')", "BAr"},
+		{"translate('--aaa--', 'abc-', 'ABC')", "AAA"},
+		{"not(0)", true},
+		{"boolean('')", false},
+		{"number(' 12.5 ')", 12.5},
+		{"sum(t:item[3] | @version)", 5.0},
+		{"floor(-1.5)", -2.0},
+		{"ceiling(1.2)", 2.0},
+		{"round(2.5)", 3.0},
+		{"round(-2.5)", -2.0},
+		{"string(1 div 0)", "Infinity"},
+		{"string(0.1 + 0.2 = 0.3)", "false"},
+		{"string(100000000000000000000)", "100000000000000000000"},
+		{"string(-0.5)", "-0.5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			assert.Equal(t, tt.want, evalTest(t, tt.expression))
+		})
+	}
+}
+
+func TestXPathNodeSetArguments(t *testing.T) {
+	doc, err := parseDocument([]byte(xpathTestDocument))
+	require.NoError(t, err)
+	for _, expression := range []string{"name($name)", "count('a')", "$name/t:item", "$name[1]"} {
+		e, err := compileExpr(expression, map[string]string{"t": "urn:test"})
+		require.NoError(t, err)
+		_, err = e.eval(&evalContext{node: doc, lookup: func(string) (value, error) { return "item", nil }})
+		assert.Error(t, err, expression)
+	}
+}
+
+func TestXPathLang(t *testing.T) {
+	doc, err := parseDocument([]byte(xpathTestDocument))
+	require.NoError(t, err)
+	item := documentElement(doc).children[1]
+	e, err := compileExpr("lang('en')", nil)
+	require.NoError(t, err)
+	v, err := e.eval(&evalContext{node: item.children[0]})
+	require.NoError(t, err)
+	assert.Equal(t, true, v)
+}
+
+func TestXPathNaN(t *testing.T) {
+	assert.True(t, math.IsNaN(evalTest(t, "number('x')").(float64)))
+	assert.Equal(t, "NaN", evalTest(t, "string(number('1e3'))"))
+}
+
+func TestCompileExprErrors(t *testing.T) {
+	tests := []struct {
+		expression string
+		want       string
+	}{
+		{"", "unexpected end of expression"},
+		{"1 +", "unexpected end of expression"},
+		{"foo(", "unknown function foo()"},
+		{"key('a', 'b')", "unsupported function key()"},
+		{"ex:f()", "unsupported extension function ex:f()"},
+		{"concat('a')", "wrong number of arguments for concat()"},
+		{"'abc", "unterminated string literal"},
+		{"u:item", `undeclared namespace prefix "u"`},
+		{"namespace::*", `unsupported axis "namespace"`},
+		{"a ! b", "unexpected character"},
+		{"(1", "unexpected end of expression"},
+		{"1 2", `unexpected "2"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			_, err := compileExpr(tt.expression, map[string]string{"t": "urn:test"})
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
+
+func TestTokenizeOperators(t *testing.T) {
+	tokens, err := tokenize("div div div * * mod @*")
+	require.NoError(t, err)
+	var kinds []tokenKind
+	for _, tok := range tokens {
+		kinds = append(kinds, tok.kind)
+	}
+	// A name and an operator alternate; the first "*" after an operator is a name test
+	assert.Equal(t, []tokenKind{tokName, tokOperator, tokName, tokOperator, tokStar, tokOperator, tokAt, tokStar, tokEOF}, kinds)
+}
+
+func TestParseDocumentErrors(t *testing.T) {
+	tests := map[string]string{
+		"<a></b>":                 "unexpected end element </b>",
+		"<a/><b/>":                "more than one document element",
+		"<!-- only a comment -->": "no document element",
+		"<u:a/>":                  `undeclared namespace prefix "u"`,
+		"<a u:b='1'/>":            `undeclared namespace prefix "u"`,
+		`<?xml version="1.0" encoding="EBCDIC"?><a/>`: `unsupported document encoding "EBCDIC"`,
+	}
+	for input, want := range tests {
+		t.Run(input, func(t *testing.T) {
+			_, err := parseDocument([]byte(input))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), want)
+		})
+	}
+}
+
+func TestParseDocumentLatin1(t *testing.T) {
+	doc, err := parseDocument([]byte("<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?><a>G\xf6teborg</a>"))
+	require.NoError(t, err)
+	assert.Equal(t, "Göteborg", doc.stringValue())
+}
@@ -0,0 +1,69 @@
+// Package xsd2024 embeds the XML schemas of ETSI TS 119 612 that the types of
+// package etsi119612 are generated from, so that documents can be validated
+// against them, for example with xmllint, without network access.
+package xsd2024
+
+import (
+	"embed"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// TSLSchema is the name of the schema of trusted lists among the files written
+// by WriteDir.
+const TSLSchema = "19612_xsd.xsd"
+
+//go:embed *.xsd
+var files embed.FS
+
+// schemaLocations maps the locations the schemas import each other from to
+// the written files.
+var schemaLocations = map[string]string{
+	"http://www.w3.org/2001/xml.xsd":                                              "xml.xsd",
+	"http://www.w3.org/TR/2002/REC-xmldsig-core-20020212/xmldsig-core-schema.xsd": "xmldsig-core-schema.xsd",
+	"http://uri.etsi.org/01903/v1.3.2/XAdES.xsd":                                  "xades.xsd",
+	"http://uri.etsi.org/19612/v1.2.1/tsl.xsd":                                    TSLSchema,
+}
+
+// xmlSchema declares the attributes of the XML namespace, such as xml:lang,
+// which TSLSchema imports from http://www.w3.org/2001/xml.xsd.
+const xmlSchema = `<?xml version="1.0" encoding="UTF-8"?>
+<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema" targetNamespace="http://www.w3.org/XML/1998/namespace">
+  <xs:attribute name="lang" type="xs:language"/>
+  <xs:attribute name="space">
+    <xs:simpleType>
+      <xs:restriction base="xs:NCName">
+        <xs:enumeration value="default"/>
+        <xs:enumeration value="preserve"/>
+      </xs:restriction>
+    </xs:simpleType>
+  </xs:attribute>
+  <xs:attribute name="base" type="xs:anyURI"/>
+  <xs:attribute name="id" type="xs:ID"/>
+</xs:schema>
+`
+
+// WriteDir writes the schemas to the existing directory dir, with their
+// imports pointing at the written files, together with the schema of the XML
+// namespace they import.
+func WriteDir(dir string) error {
+	entries, err := files.ReadDir(".")
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		data, err := files.ReadFile(entry.Name())
+		if err != nil {
+			return err
+		}
+		schema := string(data)
+		for location, name := range schemaLocations {
+			schema = strings.ReplaceAll(schema, `schemaLocation="`+location+`"`, `schemaLocation="`+name+`"`)
+		}
+		if err := os.WriteFile(filepath.Join(dir, entry.Name()), []byte(schema), 0o644); err != nil {
+			return err
+		}
+	}
+	return os.WriteFile(filepath.Join(dir, "xml.xsd"), []byte(xmlSchema), 0o644)
+}
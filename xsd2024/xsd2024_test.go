@@ -0,0 +1,34 @@
+package xsd2024
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteDir(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, WriteDir(dir))
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	names := make(map[string]bool)
+	for _, entry := range entries {
+		names[entry.Name()] = true
+	}
+	assert.True(t, names[TSLSchema])
+	assert.True(t, names["xml.xsd"])
+
+	// Every import resolves to a written file
+	for _, name := range schemaLocations {
+		assert.True(t, names[name], name)
+	}
+	for name := range names {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		require.NoError(t, err)
+		assert.NotContains(t, string(data), `schemaLocation="http`, name)
+	}
+}